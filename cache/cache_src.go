@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+type cacheImageSource struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	stubs.NoGetBlobAtInitialize
+
+	ref    cacheReference
+	source private.ImageSource
+}
+
+// newImageSource returns an ImageSource reading ref.wrapped, using and populating the cache in ref.dir.
+func newImageSource(ctx context.Context, sys *types.SystemContext, ref cacheReference) (private.ImageSource, error) {
+	if err := os.MkdirAll(ref.dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	src, err := ref.wrapped.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	source := imagesource.FromPublic(src)
+	s := &cacheImageSource{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			HasThreadSafeGetBlob: source.HasThreadSafeGetBlob(),
+		}),
+		NoGetBlobAtInitialize: stubs.NoGetBlobAt(ref),
+
+		ref:    ref,
+		source: source,
+	}
+	s.Compat = impl.AddCompat(s)
+	return s, nil
+}
+
+// Reference returns the reference used to set up this source.
+func (s *cacheImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+// Close removes resources associated with an initialized ImageSource, if any.
+func (s *cacheImageSource) Close() error {
+	return s.source.Close()
+}
+
+// blobPath returns the path of a blob (or a manifest) with blobDigest in the cache.
+func (s *cacheImageSource) blobPath(blobDigest digest.Digest) (string, error) {
+	if err := blobDigest.Validate(); err != nil { // Make sure blobDigest.Encoded() is safe to use as a file name
+		return "", err
+	}
+	return filepath.Join(s.ref.dir, "blobs", blobDigest.Algorithm().String(), blobDigest.Encoded()), nil
+}
+
+// createTempFile returns a temporary file in the cache directory which can be renamed to a blob path.
+func (s *cacheImageSource) createTempFile(blobDigest digest.Digest) (*os.File, string, error) {
+	blobPath, err := s.blobPath(blobDigest)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		return nil, "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(blobPath), ".tmp-"+blobDigest.Encoded())
+	if err != nil {
+		return nil, "", err
+	}
+	return f, blobPath, nil
+}
+
+// storeManifest stores m, which is known to match manifestDigest, in the cache.
+// Failures are only logged; the cache is an optimization.
+func (s *cacheImageSource) storeManifest(manifestDigest digest.Digest, m []byte) {
+	f, blobPath, err := s.createTempFile(manifestDigest)
+	if err == nil {
+		_, err = f.Write(m)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), blobPath)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}
+	if err != nil {
+		log.Debugf("Error caching manifest %s: %v", manifestDigest, err)
+	}
+}
+
+// GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
+// It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
+// this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
+func (s *cacheImageSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	// Only manifests identified by a digest can be served from the cache; a tag must always be resolved by the wrapped source.
+	var expectedDigest digest.Digest
+	if instanceDigest != nil {
+		expectedDigest = *instanceDigest
+	} else if canonical, ok := s.ref.DockerReference().(reference.Canonical); ok {
+		expectedDigest = canonical.Digest()
+	}
+	if expectedDigest != "" {
+		blobPath, err := s.blobPath(expectedDigest)
+		if err != nil {
+			return nil, "", err
+		}
+		m, err := os.ReadFile(blobPath)
+		if err == nil {
+			log.DebugfContext(ctx, "Using cached manifest %s", expectedDigest)
+			return m, manifest.GuessMIMEType(m), nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("reading cached manifest: %w", err)
+		}
+	}
+
+	m, mimeType, err := s.source.GetManifest(ctx, instanceDigest)
+	if err != nil {
+		return nil, "", err
+	}
+	if expectedDigest != "" {
+		matches, err := manifest.MatchesDigest(m, expectedDigest)
+		if err != nil {
+			return nil, "", fmt.Errorf("computing manifest digest: %w", err)
+		}
+		if !matches {
+			return nil, "", fmt.Errorf("manifest received from %s does not match digest %s", transports.ImageName(s.ref.wrapped), expectedDigest)
+		}
+	} else {
+		expectedDigest, err = manifest.Digest(m)
+		if err != nil {
+			return nil, "", fmt.Errorf("computing manifest digest: %w", err)
+		}
+	}
+	s.storeManifest(expectedDigest, m)
+	return m, mimeType, nil
+}
+
+// GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
+// The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
+// May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
+func (s *cacheImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	blobPath, err := s.blobPath(info.Digest)
+	if err != nil {
+		return nil, -1, err
+	}
+	f, err := os.Open(blobPath)
+	if err == nil {
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, -1, err
+		}
+		log.DebugfContext(ctx, "Using cached blob %s", info.Digest)
+		return f, fi.Size(), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, -1, fmt.Errorf("reading cached blob: %w", err)
+	}
+
+	stream, size, err := s.source.GetBlob(ctx, info, cache)
+	if err != nil {
+		return nil, -1, err
+	}
+	tempFile, blobPath, err := s.createTempFile(info.Digest)
+	if err != nil {
+		log.DebugfContext(ctx, "Not caching blob %s: %v", info.Digest, err)
+		return stream, size, nil
+	}
+	return &cachingReader{
+		source:   stream,
+		tempFile: tempFile,
+		digester: info.Digest.Algorithm().Digester(),
+		expected: info.Digest,
+		blobPath: blobPath,
+	}, size, nil
+}
+
+// GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve signatures for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (s *cacheImageSource) GetSignaturesWithFormat(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Signature, error) {
+	// Signatures can be added or revoked at any time, so they are never cached.
+	return s.source.GetSignaturesWithFormat(ctx, instanceDigest)
+}
+
+// LayerInfosForCopy returns either nil (meaning the values in the manifest are fine), or updated values for the layer
+// blobsums that are listed in the image's manifest.  If values are returned, they should be used when using GetBlob()
+// to read the image's layers.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve BlobInfos for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+// The Digest field is guaranteed to be provided; Size may be -1.
+// WARNING: The list may contain duplicates, and they are semantically relevant.
+func (s *cacheImageSource) LayerInfosForCopy(ctx context.Context, instanceDigest *digest.Digest) ([]types.BlobInfo, error) {
+	return s.source.LayerInfosForCopy(ctx, instanceDigest)
+}
+
+// cachingReader passes data from source through, and adds it to the cache if it is read completely and matches the expected digest.
+type cachingReader struct {
+	source   io.ReadCloser
+	tempFile *os.File // nil if the data is not being cached (any more)
+	digester digest.Digester
+	expected digest.Digest
+	blobPath string
+}
+
+// abandon stops caching the data, e.g. after a write error.
+func (r *cachingReader) abandon() {
+	if r.tempFile != nil {
+		r.tempFile.Close()
+		os.Remove(r.tempFile.Name())
+		r.tempFile = nil
+	}
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.source.Read(p)
+	if r.tempFile != nil && n > 0 {
+		if _, writeErr := io.MultiWriter(r.tempFile, r.digester.Hash()).Write(p[:n]); writeErr != nil {
+			log.Debugf("Not caching blob %s: %v", r.expected, writeErr)
+			r.abandon()
+		}
+	}
+	if err == io.EOF && r.tempFile != nil {
+		r.commit()
+	}
+	return n, err
+}
+
+// commit adds the complete data to the cache, if it matches the expected digest.
+func (r *cachingReader) commit() {
+	defer r.abandon() // A no-op on success
+	if r.digester.Digest() != r.expected {
+		log.Debugf("Not caching blob %s: digest mismatch, got %s", r.expected, r.digester.Digest())
+		return
+	}
+	if err := r.tempFile.Close(); err != nil {
+		log.Debugf("Not caching blob %s: %v", r.expected, err)
+		return
+	}
+	if err := os.Rename(r.tempFile.Name(), r.blobPath); err != nil {
+		log.Debugf("Not caching blob %s: %v", r.expected, err)
+		return
+	}
+	r.tempFile = nil
+}
+
+func (r *cachingReader) Close() error {
+	r.abandon()
+	return r.source.Close()
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ private.ImageSource = (*cacheImageSource)(nil)
+
+// writeDirImage creates a dir: image in dir, with the specified manifest and blobs, and returns its reference.
+func writeDirImage(t *testing.T, dir string, m []byte, blobs [][]byte) types.ImageReference {
+	ref, err := directory.NewReference(dir)
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	for _, blob := range blobs {
+		_, err := dest.PutBlob(context.Background(), bytes.NewReader(blob), types.BlobInfo{Size: -1}, memory.New(), false)
+		require.NoError(t, err)
+	}
+	err = dest.PutManifest(context.Background(), m, nil)
+	require.NoError(t, err)
+	err = dest.Commit(context.Background(), nil)
+	require.NoError(t, err)
+	return ref
+}
+
+func TestCacheImageSource(t *testing.T) {
+	ctx := context.Background()
+	imageDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "cache") // Does not exist yet
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	m := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` +
+		blobDigest.String() + `","size":13},"layers":[]}`)
+	manifestDigest, err := manifest.Digest(m)
+	require.NoError(t, err)
+	dirRef := writeDirImage(t, imageDir, m, [][]byte{blob})
+
+	ref, err := NewReference(cacheDir, dirRef)
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+
+	// Initially, everything comes from the wrapped source.
+	m2, _, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+	rc, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: blobDigest, Size: -1}, memory.New())
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, blob, data)
+	require.NoError(t, rc.Close())
+
+	// Both are now cached.
+	for _, d := range []digest.Digest{manifestDigest, blobDigest} {
+		_, err := os.Stat(filepath.Join(cacheDir, "blobs", d.Algorithm().String(), d.Encoded()))
+		assert.NoError(t, err, d.String())
+	}
+
+	// Remove the data from the wrapped image; the blob and the manifest (when requested by digest) are read from the cache.
+	err = os.Remove(filepath.Join(imageDir, blobDigest.Encoded()))
+	require.NoError(t, err)
+	err = os.Remove(filepath.Join(imageDir, "manifest.json"))
+	require.NoError(t, err)
+	rc, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: blobDigest, Size: -1}, memory.New())
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(blob)), size)
+	data, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, blob, data)
+	require.NoError(t, rc.Close())
+	m2, _, err = src.GetManifest(ctx, &manifestDigest)
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+	// … but a manifest not identified by a digest is always read from the wrapped source.
+	_, _, err = src.GetManifest(ctx, nil)
+	assert.Error(t, err)
+}
+
+func TestCacheImageSourceIncompleteReads(t *testing.T) {
+	ctx := context.Background()
+	imageDir := t.TempDir()
+	cacheDir := t.TempDir()
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	dirRef := writeDirImage(t, imageDir, []byte(`{}`), [][]byte{blob})
+	ref, err := NewReference(cacheDir, dirRef)
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+	blobPath := filepath.Join(cacheDir, "blobs", blobDigest.Algorithm().String(), blobDigest.Encoded())
+
+	// A blob which is not read completely is not cached.
+	rc, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: blobDigest, Size: -1}, memory.New())
+	require.NoError(t, err)
+	_, err = rc.Read(make([]byte, 4))
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	_, err = os.Stat(blobPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// Data which does not match the expected digest is not cached.
+	err = os.WriteFile(filepath.Join(imageDir, blobDigest.Encoded()), []byte("corrupted"), 0644)
+	require.NoError(t, err)
+	rc, _, err = src.GetBlob(ctx, types.BlobInfo{Digest: blobDigest, Size: -1}, memory.New())
+	require.NoError(t, err)
+	_, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	_, err = os.Stat(blobPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Dir(blobPath))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
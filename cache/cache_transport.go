@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/transports/plugin"
+	"github.com/containers/image/v5/types"
+)
+
+func init() {
+	transports.Register(Transport)
+}
+
+// Transport is an ImageTransport which reads images through a local pull-through cache directory,
+// using references of the form "cache:<dir>:<transport>:<reference>".
+var Transport = cacheTransport{}
+
+type cacheTransport struct{}
+
+func (t cacheTransport) Name() string {
+	return "cache"
+}
+
+// parseWrappedImageName parses a full image name, including the transport, of the image wrapped by a cache reference.
+// We can't use alltransports.ParseImageName, that would be an import cycle.
+func parseWrappedImageName(imgName string) (types.ImageReference, error) {
+	transportName, withinTransport, valid := strings.Cut(imgName, ":")
+	if !valid {
+		return nil, fmt.Errorf(`Invalid image name %q, expected colon-separated transport:reference`, imgName)
+	}
+	transport := plugin.Find(transportName)
+	if transport == nil {
+		return nil, fmt.Errorf(`Invalid image name %q, unknown transport %q`, imgName, transportName)
+	}
+	return transport.ParseReference(withinTransport)
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an ImageReference.
+func (t cacheTransport) ParseReference(reference string) (types.ImageReference, error) {
+	dir, wrapped, valid := strings.Cut(reference, ":")
+	if !valid {
+		return nil, fmt.Errorf(`Invalid cache: reference %q, expected <dir>:<transport>:<reference>`, reference)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf(`Invalid cache: reference %q, the cache directory must not be empty`, reference)
+	}
+	wrappedRef, err := parseWrappedImageName(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return NewReference(dir, wrappedRef)
+}
+
+// ValidatePolicyConfigurationScope checks that scope is a valid name for a signature.PolicyTransportScopes keys
+// (i.e. a valid PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() return value).
+// It is acceptable to allow an invalid value which will never be matched, it can "only" cause user confusion.
+// scope passed to this function will not be "", that value is always allowed.
+func (t cacheTransport) ValidatePolicyConfigurationScope(scope string) error {
+	transportName, withinTransport, valid := strings.Cut(scope, ":")
+	if !valid {
+		return fmt.Errorf("Invalid scope %q: expected <transport>:<scope>", scope)
+	}
+	transport := plugin.Find(transportName)
+	if transport == nil {
+		return fmt.Errorf("Invalid scope %q: unknown transport %q", scope, transportName)
+	}
+	if withinTransport == "" {
+		return nil
+	}
+	return transport.ValidatePolicyConfigurationScope(withinTransport)
+}
+
+// cacheReference is an ImageReference for images read through a cache directory.
+type cacheReference struct {
+	dir     string // As specified by the user. May be relative, contain symlinks, etc.
+	wrapped types.ImageReference
+}
+
+// NewReference returns a reference to the image at wrapped, read through a cache in dir.
+func NewReference(dir string, wrapped types.ImageReference) (types.ImageReference, error) {
+	if strings.Contains(dir, ":") {
+		return nil, fmt.Errorf("Invalid cache directory %q: paths including a colon are not supported", dir)
+	}
+	if wrapped.Transport().Name() == Transport.Name() {
+		return nil, errors.New("cache: references can not be nested")
+	}
+	return cacheReference{dir: dir, wrapped: wrapped}, nil
+}
+
+func (ref cacheReference) Transport() types.ImageTransport {
+	return Transport
+}
+
+// StringWithinTransport returns a string representation of the reference, which MUST be such that
+// reference.Transport().ParseReference(reference.StringWithinTransport()) returns an equivalent reference.
+// NOTE: The returned string is not promised to be equal to the original input to ParseReference;
+// e.g. default attribute values omitted by the user may be filled in the return value, or vice versa.
+// WARNING: Do not use the return value in the UI to describe an image, it does not contain the Transport().Name() prefix.
+func (ref cacheReference) StringWithinTransport() string {
+	return ref.dir + ":" + transports.ImageName(ref.wrapped)
+}
+
+// DockerReference returns a Docker reference associated with this reference
+// (fully explicit, i.e. !reference.IsNameOnly, but reflecting user intent,
+// not e.g. after redirect or alias processing), or nil if unknown/not applicable.
+func (ref cacheReference) DockerReference() reference.Named {
+	return ref.wrapped.DockerReference()
+}
+
+// PolicyConfigurationIdentity returns a string representation of the reference, suitable for policy lookup.
+// This MUST reflect user intent, not e.g. after processing of third-party redirects or aliases;
+// The value SHOULD be fully explicit about its semantics, with no hidden defaults, AND canonical
+// (i.e. various references with exactly the same semantics should return the same configuration identity)
+// It is fine for the return value to be equal to StringWithinTransport(), and it is desirable but
+// not required/guaranteed that it will be a valid input to Transport().ParseReference().
+// Returns "" if configuration identities for these references are not supported.
+//
+// The identity of the wrapped image is used, prefixed by the name of its transport; the cache directory is irrelevant.
+func (ref cacheReference) PolicyConfigurationIdentity() string {
+	return ref.wrapped.Transport().Name() + ":" + ref.wrapped.PolicyConfigurationIdentity()
+}
+
+// PolicyConfigurationNamespaces returns a list of other policy configuration namespaces to search
+// for if explicit configuration for PolicyConfigurationIdentity() is not set.  The list will be processed
+// in order, terminating on first match, and an implicit "" is always checked at the end.
+// It is STRONGLY recommended for the first element, if any, to be a prefix of PolicyConfigurationIdentity(),
+// and each following element to be a prefix of the element preceding it.
+func (ref cacheReference) PolicyConfigurationNamespaces() []string {
+	prefix := ref.wrapped.Transport().Name() + ":"
+	res := []string{}
+	for _, ns := range ref.wrapped.PolicyConfigurationNamespaces() {
+		res = append(res, prefix+ns)
+	}
+	return append(res, prefix)
+}
+
+// NewImage returns a types.ImageCloser for this reference, possibly specialized for this ImageTransport.
+// The caller must call .Close() on the returned ImageCloser.
+// NOTE: If any kind of signature verification should happen, build an UnparsedImage from the value returned by NewImageSource,
+// verify that UnparsedImage, and convert it into a real Image via image.FromUnparsedImage.
+// WARNING: This may not do the right thing for a manifest list, see image.FromSource for details.
+func (ref cacheReference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	return image.FromReference(ctx, sys, ref)
+}
+
+// NewImageSource returns a types.ImageSource for this reference.
+// The caller must call .Close() on the returned ImageSource.
+func (ref cacheReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	return newImageSource(ctx, sys, ref)
+}
+
+// NewImageDestination returns a types.ImageDestination for this reference.
+// The caller must call .Close() on the returned ImageDestination.
+func (ref cacheReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	return nil, errors.New(`"cache:" locations can only be read from, not written to`)
+}
+
+// DeleteImage deletes the named image from the registry, if supported.
+func (ref cacheReference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
+	return errors.New(`Deleting images not implemented for "cache:" images`)
+}
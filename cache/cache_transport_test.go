@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	_ "github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/transports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportName(t *testing.T) {
+	assert.Equal(t, "cache", Transport.Name())
+}
+
+func TestTransportParseReference(t *testing.T) {
+	for _, c := range []struct{ input, expected string }{
+		{"/var/cache/c:docker://busybox", "/var/cache/c:docker://busybox:latest"},
+		{"rel/dir:docker://quay.io/a/b@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			"rel/dir:docker://quay.io/a/b@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+	} {
+		ref, err := Transport.ParseReference(c.input)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, ref.StringWithinTransport(), c.input)
+	}
+
+	for _, input := range []string{
+		"",
+		"/no-wrapped-reference",
+		":docker://busybox",                // Empty directory
+		"/c:docker",                        // Invalid wrapped reference
+		"/c:unknown-transport:ref",         // Unknown transport
+		"/c:docker://UPPERCASE",            // Invalid docker reference
+		"/c:cache:/d:docker://busybox",     // Nested cache
+		"/c:docker://busybox:tag:whatever", // Invalid docker reference
+	} {
+		_, err := Transport.ParseReference(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
+	for _, scope := range []string{
+		"docker:",
+		"docker:docker.io/library/busybox",
+		"docker:*.example.com",
+		"dir:",
+		"dir:/some/path",
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.NoError(t, err, scope)
+	}
+
+	for _, scope := range []string{
+		"docker",
+		"unknown:foo",
+		"dir:relative",
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.Error(t, err, scope)
+	}
+}
+
+func TestReferencePolicyConfiguration(t *testing.T) {
+	ref, err := Transport.ParseReference("/var/cache/c:docker://example.com/ns/repo:tag")
+	require.NoError(t, err)
+	assert.Equal(t, "docker:example.com/ns/repo:tag", ref.PolicyConfigurationIdentity())
+	assert.Equal(t, []string{
+		"docker:example.com/ns/repo:*",
+		"docker:example.com/ns/repo",
+		"docker:example.com/ns",
+		"docker:example.com",
+		"docker:*.com",
+		"docker:",
+	}, ref.PolicyConfigurationNamespaces())
+	assert.Equal(t, "example.com/ns/repo:tag", ref.DockerReference().String())
+
+	for _, ns := range ref.PolicyConfigurationNamespaces() {
+		err := Transport.ValidatePolicyConfigurationScope(ns)
+		assert.NoError(t, err, ns)
+	}
+}
+
+func TestNewReference(t *testing.T) {
+	tmpDir := t.TempDir()
+	dirRef, err := directory.NewReference(tmpDir)
+	require.NoError(t, err)
+
+	ref, err := NewReference("/cache", dirRef)
+	require.NoError(t, err)
+	assert.Equal(t, "cache:/cache:dir:"+tmpDir, transports.ImageName(ref))
+	assert.Equal(t, "dir:"+tmpDir, ref.PolicyConfigurationIdentity())
+
+	_, err = NewReference("/with:colon", dirRef)
+	assert.Error(t, err)
+
+	_, err = NewReference("/cache", ref)
+	assert.Error(t, err)
+}
+
+func TestReferenceNewImageDestination(t *testing.T) {
+	ref, err := Transport.ParseReference("/cache:dir:" + t.TempDir())
+	require.NoError(t, err)
+	_, err = ref.NewImageDestination(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+func TestReferenceDeleteImage(t *testing.T) {
+	ref, err := Transport.ParseReference("/cache:dir:" + t.TempDir())
+	require.NoError(t, err)
+	err = ref.DeleteImage(context.Background(), nil)
+	assert.Error(t, err)
+}
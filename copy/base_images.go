@@ -0,0 +1,88 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// recordBaseImageLayers records the layers of c.options.BaseImages in c.blobInfoCache, so that the destination
+// can reuse them instead of uploading them.
+// Failures are only logged; the base images are only a hint.
+func (c *copier) recordBaseImageLayers(ctx context.Context) {
+	for _, ref := range c.options.BaseImages {
+		if err := c.recordBaseImageLayersOf(ctx, ref); err != nil {
+			log.WarnfContext(ctx, "Ignoring base image %s: %v", transports.ImageName(ref), err)
+		}
+	}
+}
+
+// recordBaseImageLayersOf records the layers of all instances of ref in c.blobInfoCache.
+func (c *copier) recordBaseImageLayersOf(ctx context.Context, ref types.ImageReference) (retErr error) {
+	publicSrc, err := ref.NewImageSource(ctx, c.options.DestinationCtx)
+	if err != nil {
+		return err
+	}
+	src := imagesource.FromPublic(publicSrc)
+	defer func() {
+		if err := src.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	recorder, ok := src.(private.BlobLocationRecorder)
+	if !ok {
+		log.DebugfContext(ctx, "Base image %s can not record blob locations, ignoring it", transports.ImageName(ref))
+		return nil
+	}
+
+	layers := set.New[digest.Digest]()
+	digests := []digest.Digest{}
+	addLayers := func(manifestBlob []byte, mimeType string) error {
+		parsed, err := manifest.FromBlob(manifestBlob, mimeType)
+		if err != nil {
+			return err
+		}
+		for _, layer := range parsed.LayerInfos() {
+			if !layers.Contains(layer.Digest) {
+				layers.Add(layer.Digest)
+				digests = append(digests, layer.Digest)
+			}
+		}
+		return nil
+	}
+
+	manifestBlob, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if !manifest.MIMETypeIsMultiImage(mimeType) {
+		if err := addLayers(manifestBlob, mimeType); err != nil {
+			return err
+		}
+	} else {
+		list, err := manifest.ListFromBlob(manifestBlob, mimeType)
+		if err != nil {
+			return err
+		}
+		for _, instanceDigest := range list.Instances() {
+			instanceBlob, instanceMIMEType, err := src.GetManifest(ctx, &instanceDigest)
+			if err != nil {
+				return fmt.Errorf("reading manifest %s: %w", instanceDigest.String(), err)
+			}
+			if err := addLayers(instanceBlob, instanceMIMEType); err != nil {
+				return fmt.Errorf("parsing manifest %s: %w", instanceDigest.String(), err)
+			}
+		}
+	}
+	log.DebugfContext(ctx, "Recording %d layers of base image %s", len(digests), transports.ImageName(ref))
+	recorder.RecordBlobLocations(ctx, digests, c.blobInfoCache)
+	return nil
+}
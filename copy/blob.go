@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync/atomic"
+	"time"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
+	"github.com/containers/image/v5/pkg/faultinject"
+	"github.com/containers/image/v5/pkg/metrics"
 	"github.com/containers/image/v5/types"
-	"github.com/sirupsen/logrus"
 )
 
 // copyBlobFromStream copies a blob with srcInfo (with known Digest and Annotations and possibly known Size) from srcReader to dest,
@@ -18,43 +22,86 @@ import (
 // and returns a complete blobInfo of the copied blob.
 func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Reader, srcInfo types.BlobInfo,
 	getOriginalLayerCopyWriter func(decompressor compressiontypes.DecompressorFunc) io.Writer,
-	isConfig bool, toEncrypt bool, bar *progressBar, layerIndex int, emptyLayer bool) (types.BlobInfo, error) {
+	isConfig bool, toEncrypt bool, bar *progressBar, layerIndex int, emptyLayer bool) (_ types.BlobInfo, retErr error) {
 	// The copying happens through a pipeline of connected io.Readers;
 	// that pipeline is built by updating stream.
+	// === Inject faults into the source data, if requested for testing.
+	// A modified srcReader is not a private.VerifiedBlobReader, so the data is verified against its digest below.
+	if ic.c.options.SourceCtx != nil {
+		fault := faultinject.Lookup(ctx, ic.c.options.SourceCtx.FaultInjector, faultinject.CopyBlob, srcInfo.Digest.String())
+		srcReader = fault.Reader(srcReader, faultinject.CopyBlob, srcInfo.Digest.String())
+	}
 	// === Input: srcReader
 	stream := sourceStream{
 		reader: srcReader,
 		info:   srcInfo,
 	}
 
+	// === Count the data read from the source, for metrics.
+	countingReader := &countingReader{reader: stream.reader}
+	stream.reader = countingReader
+	defer ic.c.recordBlobTransferMetrics(isConfig, countingReader, time.Now())
+
+	// === Enforce ic.c.options.MaxLayerSize, in case the source serves more data than the manifest lists, or the size is unknown.
+	var layerSizeLimit *layerSizeLimitReader // = nil
+	if !isConfig && ic.c.options.MaxLayerSize > 0 {
+		layerSizeLimit = &layerSizeLimitReader{reader: stream.reader, digest: srcInfo.Digest, max: ic.c.options.MaxLayerSize}
+		stream.reader = layerSizeLimit
+	}
+
+	// === Report the download phase, ending when the whole source blob has been read.
+	downloadReader := newPhaseReader(stream.reader, ic.c.startPhase(PhaseDownload, srcInfo))
+	stream.reader = downloadReader
+	defer func() { finishPhaseReader(downloadReader, retErr) }()
+
 	// === Process input through digestingReader to validate against the expected digest.
 	// Be paranoid; in case PutBlob somehow managed to ignore an error from digestingReader,
 	// use a separate validation failure indicator.
 	// Note that for this check we don't use the stronger "validationSucceeded" indicator, because
 	// dest.PutBlob may detect that the layer already exists, in which case we don't
 	// read stream to the end, and validation does not happen.
-	digestingReader, err := newDigestingReader(stream.reader, srcInfo.Digest)
-	if err != nil {
-		return types.BlobInfo{}, fmt.Errorf("preparing to verify blob %s: %w", srcInfo.Digest, err)
+	// If the source remembers that it has already verified the blob, don’t spend time hashing it again.
+	verifiedReader, _ := srcReader.(private.VerifiedBlobReader)
+	var digestingReader *digestingReader
+	if verifiedReader != nil && verifiedReader.DigestVerified() {
+		digestingReader = newTrustedDigestingReader(stream.reader, srcInfo.Digest)
+	} else {
+		var err error
+		digestingReader, err = newDigestingReader(stream.reader, srcInfo.Digest, ic.c.options.PipelinedDigesting)
+		if err != nil {
+			return types.BlobInfo{}, fmt.Errorf("preparing to verify blob %s: %w", srcInfo.Digest, err)
+		}
 	}
+	defer digestingReader.close()
 	stream.reader = digestingReader
 
 	// === Update progress bars
 	stream.reader = bar.ProxyReader(stream.reader)
 
 	// === Decrypt the stream, if required.
-	decryptionStep, err := ic.blobPipelineDecryptionStep(&stream, srcInfo)
+	decryptionStep, err := ic.blobPipelineDecryptionStep(&stream, toEncrypt, srcInfo)
 	if err != nil {
 		return types.BlobInfo{}, err
 	}
 
 	// === Detect compression of the input stream.
 	// This requires us to “peek ahead” into the stream to read the initial part, which requires us to chain through another io.Reader returned by DetectCompression.
-	detectedCompression, err := blobPipelineDetectCompressionStep(&stream, srcInfo)
+	detectedCompression, err := blobPipelineDetectCompressionStep(ctx, &stream, srcInfo)
 	if err != nil {
 		return types.BlobInfo{}, err
 	}
 
+	// === Count the uncompressed size of the layer against ic.c.options.MaxTotalUncompressedSize, if required.
+	uncompressedSizeStep := ic.blobPipelineUncompressedSizeStep(&stream, isConfig, detectedCompression)
+	defer func() { _ = uncompressedSizeStep.close() }()
+
+	// === Send a copy of the uncompressed stream to ic.c.options.LayerScanner, if required.
+	scanStep, err := ic.blobPipelineLayerScanStep(ctx, &stream, srcInfo, isConfig, detectedCompression, decryptionStep.decrypting, layerIndex)
+	if err != nil {
+		return types.BlobInfo{}, err
+	}
+	defer func() { _ = scanStep.abort(retErr) }()
+
 	// === Send a copy of the original, uncompressed, stream, to a separate path if necessary.
 	var originalLayerReader io.Reader // DO NOT USE this other than to drain the input if no other consumer in the pipeline has done so.
 	if getOriginalLayerCopyWriter != nil {
@@ -66,11 +113,23 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 	// short-circuit conditions
 	canModifyBlob := !isConfig && ic.cannotModifyManifestReason == ""
 	// === Deal with layer compression/decompression if necessary
-	compressionStep, err := ic.blobPipelineCompressionStep(&stream, canModifyBlob, srcInfo, detectedCompression)
+	compressionStep, err := ic.blobPipelineCompressionStep(ctx, &stream, canModifyBlob, srcInfo, detectedCompression)
 	if err != nil {
 		return types.BlobInfo{}, err
 	}
 	defer compressionStep.close()
+	var compressionPhase Phase
+	switch compressionStep.operation {
+	case bpcOpDecompressCompressed:
+		compressionPhase = PhaseDecompress
+	case bpcOpCompressUncompressed, bpcOpRecompressCompressed:
+		compressionPhase = PhaseCompress
+	}
+	if compressionPhase != "" {
+		compressionReader := newPhaseReader(stream.reader, ic.c.startPhase(compressionPhase, srcInfo))
+		stream.reader = compressionReader
+		defer func() { finishPhaseReader(compressionReader, retErr) }()
+	}
 
 	// === Encrypt the stream for valid mediatypes if ociEncryptConfig provided
 	if decryptionStep.decrypting && toEncrypt {
@@ -83,13 +142,14 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 		return types.BlobInfo{}, err
 	}
 
-	// === Report progress using the ic.c.options.Progress channel, if required.
-	if ic.c.options.Progress != nil && ic.c.options.ProgressInterval > 0 {
+	// === Report progress using the ic.c.options.Progress channel and ic.c.options.ProgressJSONWriter, if required.
+	if ic.c.reportProgress != nil {
 		progressReader := newProgressReader(
 			stream.reader,
-			ic.c.options.Progress,
-			ic.c.options.ProgressInterval,
+			ic.c.reportProgress,
+			ic.c.progressInterval,
 			srcInfo,
+			ic.c.operationID,
 		)
 		defer progressReader.reportDone()
 		stream.reader = progressReader
@@ -104,10 +164,23 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 	if !isConfig {
 		options.LayerIndex = &layerIndex
 	}
+	uploadPhase := ic.c.startPhase(PhaseUpload, srcInfo)
 	destBlob, err := ic.c.dest.PutBlobWithOptions(ctx, &errorAnnotationReader{stream.reader}, stream.info, options)
 	if err != nil {
+		uploadPhase.finish(-1, err)
+		// Report exceeded limits directly, regardless of how the destination has reported the read failure.
+		if layerSizeLimit != nil && layerSizeLimit.exceeded != nil {
+			return types.BlobInfo{}, layerSizeLimit.exceeded
+		}
+		if limitErr := uncompressedSizeStep.close(); limitErr != nil {
+			return types.BlobInfo{}, limitErr
+		}
+		if rejected := scanStep.abort(err); rejected != nil {
+			return types.BlobInfo{}, rejected
+		}
 		return types.BlobInfo{}, fmt.Errorf("writing blob: %w", err)
 	}
+	uploadPhase.finish(destBlob.Size, nil)
 	uploadedInfo := updatedBlobInfoFromUpload(stream.info, destBlob)
 
 	compressionStep.updateCompressionEdits(&uploadedInfo.CompressionOperation, &uploadedInfo.CompressionAlgorithm, &uploadedInfo.Annotations)
@@ -121,13 +194,23 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 	// So, read everything from originalLayerReader, which will cause the rest to be
 	// sent there if we are not already at EOF.
 	if getOriginalLayerCopyWriter != nil {
-		logrus.Debugf("Consuming rest of the original blob to satisfy getOriginalLayerCopyWriter")
+		log.DebugfContext(ctx, "Consuming rest of the original blob to satisfy getOriginalLayerCopyWriter")
 		_, err := io.Copy(io.Discard, originalLayerReader)
 		if err != nil {
 			return types.BlobInfo{}, fmt.Errorf("reading input blob %s: %w", srcInfo.Digest, err)
 		}
 	}
 
+	// The scanner may reject the layer only after it has seen all of the data.
+	if err := scanStep.finish(); err != nil {
+		return types.BlobInfo{}, err
+	}
+
+	// The counting goroutine may only notice an exceeded limit after all of the compressed data has been consumed.
+	if err := uncompressedSizeStep.close(); err != nil {
+		return types.BlobInfo{}, err
+	}
+
 	if digestingReader.validationFailed { // Coverage: This should never happen.
 		return types.BlobInfo{}, fmt.Errorf("Internal error writing blob %s, digest verification failed but was ignored", srcInfo.Digest)
 	}
@@ -135,6 +218,9 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 		return types.BlobInfo{}, fmt.Errorf("Internal error writing blob %s, blob with digest %s saved with digest %s", srcInfo.Digest, stream.info.Digest, uploadedInfo.Digest)
 	}
 	if digestingReader.validationSucceeded {
+		if verifiedReader != nil && !digestingReader.trusted {
+			verifiedReader.RecordDigestVerified()
+		}
 		if err := compressionStep.recordValidatedDigestData(ic.c, uploadedInfo, srcInfo, encryptionStep, decryptionStep); err != nil {
 			return types.BlobInfo{}, err
 		}
@@ -143,6 +229,28 @@ func (ic *imageCopier) copyBlobFromStream(ctx context.Context, srcReader io.Read
 	return uploadedInfo, nil
 }
 
+// recordBlobTransferMetrics records metrics about a blob transfer which started at start, and read data through reader.
+func (c *copier) recordBlobTransferMetrics(isConfig bool, reader *countingReader, start time.Time) {
+	labels := map[string]string{metrics.LabelBlobKind: metrics.BlobKindLayer}
+	if isConfig {
+		labels[metrics.LabelBlobKind] = metrics.BlobKindConfig
+	}
+	c.metrics.AddCounter(metrics.BlobBytesTotal, float64(reader.count.Load()), labels)
+	c.metrics.ObserveHistogram(metrics.BlobTransferDurationSeconds, time.Since(start).Seconds(), labels)
+}
+
+// countingReader counts the bytes read from reader.
+type countingReader struct {
+	reader io.Reader
+	count  atomic.Int64 // Atomic because the reader may be consumed by a separate goroutine, e.g. in compressGoroutine.
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
 // sourceStream encapsulates an input consumed by copyBlobFromStream, in progress of being built.
 // This allows handles of individual aspects to build the copy pipeline without _too much_
 // specific cooperation by the caller.
@@ -1,18 +1,20 @@
 package copy
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
 
 	internalblobinfocache "github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/bufferpool"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/compression"
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -43,7 +45,7 @@ type bpDetectCompressionStepData struct {
 // blobPipelineDetectCompressionStep updates *stream to detect its current compression format.
 // srcInfo is only used for error messages.
 // Returns data for other steps.
-func blobPipelineDetectCompressionStep(stream *sourceStream, srcInfo types.BlobInfo) (bpDetectCompressionStepData, error) {
+func blobPipelineDetectCompressionStep(ctx context.Context, stream *sourceStream, srcInfo types.BlobInfo) (bpDetectCompressionStepData, error) {
 	// This requires us to “peek ahead” into the stream to read the initial part, which requires us to chain through another io.Reader returned by DetectCompression.
 	format, decompressor, reader, err := compression.DetectCompressionFormat(stream.reader) // We could skip this in some cases, but let's keep the code path uniform
 	if err != nil {
@@ -63,7 +65,7 @@ func blobPipelineDetectCompressionStep(stream *sourceStream, srcInfo types.BlobI
 	}
 
 	if expectedBaseFormat, known := expectedBaseCompressionFormats[stream.info.MediaType]; known && res.isCompressed && format.BaseVariantName() != expectedBaseFormat.Name() {
-		logrus.Debugf("blob %s with type %s should be compressed with %s, but compressor appears to be %s", srcInfo.Digest.String(), srcInfo.MediaType, expectedBaseFormat.Name(), format.Name())
+		log.DebugfContext(ctx, "blob %s with type %s should be compressed with %s, but compressor appears to be %s", srcInfo.Digest.String(), srcInfo.MediaType, expectedBaseFormat.Name(), format.Name())
 	}
 	return res, nil
 }
@@ -95,22 +97,22 @@ const (
 // srcInfo is primarily used for error messages.
 // Returns data for other steps; the caller should eventually call updateCompressionEdits and perhaps recordValidatedBlobData,
 // and must eventually call close.
-func (ic *imageCopier) blobPipelineCompressionStep(stream *sourceStream, canModifyBlob bool, srcInfo types.BlobInfo,
+func (ic *imageCopier) blobPipelineCompressionStep(ctx context.Context, stream *sourceStream, canModifyBlob bool, srcInfo types.BlobInfo,
 	detected bpDetectCompressionStepData) (*bpCompressionStepData, error) {
 	// WARNING: If you are adding new reasons to change the blob, update also the OptimizeDestinationImageAlreadyExists
 	// short-circuit conditions
 	layerCompressionChangeSupported := ic.src.CanChangeLayerCompression(stream.info.MediaType)
 	if !layerCompressionChangeSupported {
-		logrus.Debugf("Compression change for blob %s (%q) not supported", srcInfo.Digest, stream.info.MediaType)
+		log.DebugfContext(ctx, "Compression change for blob %s (%q) not supported", srcInfo.Digest, stream.info.MediaType)
 	}
 	if canModifyBlob && layerCompressionChangeSupported {
-		for _, fn := range []func(*sourceStream, bpDetectCompressionStepData) (*bpCompressionStepData, error){
+		for _, fn := range []func(context.Context, *sourceStream, bpDetectCompressionStepData) (*bpCompressionStepData, error){
 			ic.bpcPreserveEncrypted,
 			ic.bpcCompressUncompressed,
 			ic.bpcRecompressCompressed,
 			ic.bpcDecompressCompressed,
 		} {
-			res, err := fn(stream, detected)
+			res, err := fn(ctx, stream, detected)
 			if err != nil {
 				return nil, err
 			}
@@ -119,14 +121,14 @@ func (ic *imageCopier) blobPipelineCompressionStep(stream *sourceStream, canModi
 			}
 		}
 	}
-	return ic.bpcPreserveOriginal(stream, detected, layerCompressionChangeSupported), nil
+	return ic.bpcPreserveOriginal(ctx, stream, detected, layerCompressionChangeSupported), nil
 }
 
 // bpcPreserveEncrypted checks if the input is encrypted, and returns a *bpCompressionStepData if so.
-func (ic *imageCopier) bpcPreserveEncrypted(stream *sourceStream, _ bpDetectCompressionStepData) (*bpCompressionStepData, error) {
+func (ic *imageCopier) bpcPreserveEncrypted(ctx context.Context, stream *sourceStream, _ bpDetectCompressionStepData) (*bpCompressionStepData, error) {
 	if isOciEncrypted(stream.info.MediaType) {
 		// We can’t do anything with an encrypted blob unless decrypted.
-		logrus.Debugf("Using original blob without modification for encrypted blob")
+		log.DebugfContext(ctx, "Using original blob without modification for encrypted blob")
 		return &bpCompressionStepData{
 			operation:              bpcOpPreserveOpaque,
 			uploadedOperation:      types.PreserveOriginal,
@@ -139,9 +141,9 @@ func (ic *imageCopier) bpcPreserveEncrypted(stream *sourceStream, _ bpDetectComp
 }
 
 // bpcCompressUncompressed checks if we should be compressing an uncompressed input, and returns a *bpCompressionStepData if so.
-func (ic *imageCopier) bpcCompressUncompressed(stream *sourceStream, detected bpDetectCompressionStepData) (*bpCompressionStepData, error) {
+func (ic *imageCopier) bpcCompressUncompressed(ctx context.Context, stream *sourceStream, detected bpDetectCompressionStepData) (*bpCompressionStepData, error) {
 	if ic.c.dest.DesiredLayerCompression() == types.Compress && !detected.isCompressed {
-		logrus.Debugf("Compressing blob on the fly")
+		log.DebugfContext(ctx, "Compressing blob on the fly")
 		var uploadedAlgorithm *compressiontypes.Algorithm
 		if ic.compressionFormat != nil {
 			uploadedAlgorithm = ic.compressionFormat
@@ -170,13 +172,13 @@ func (ic *imageCopier) bpcCompressUncompressed(stream *sourceStream, detected bp
 }
 
 // bpcRecompressCompressed checks if we should be recompressing a compressed input to another format, and returns a *bpCompressionStepData if so.
-func (ic *imageCopier) bpcRecompressCompressed(stream *sourceStream, detected bpDetectCompressionStepData) (*bpCompressionStepData, error) {
+func (ic *imageCopier) bpcRecompressCompressed(ctx context.Context, stream *sourceStream, detected bpDetectCompressionStepData) (*bpCompressionStepData, error) {
 	if ic.c.dest.DesiredLayerCompression() == types.Compress && detected.isCompressed &&
 		ic.compressionFormat != nil &&
 		(ic.compressionFormat.Name() != detected.format.Name() && ic.compressionFormat.Name() != detected.format.BaseVariantName()) {
 		// When the blob is compressed, but the desired format is different, it first needs to be decompressed and finally
 		// re-compressed using the desired format.
-		logrus.Debugf("Blob will be converted")
+		log.DebugfContext(ctx, "Blob will be converted")
 
 		decompressed, err := detected.decompressor(stream.reader)
 		if err != nil {
@@ -211,9 +213,9 @@ func (ic *imageCopier) bpcRecompressCompressed(stream *sourceStream, detected bp
 }
 
 // bpcDecompressCompressed checks if we should be decompressing a compressed input, and returns a *bpCompressionStepData if so.
-func (ic *imageCopier) bpcDecompressCompressed(stream *sourceStream, detected bpDetectCompressionStepData) (*bpCompressionStepData, error) {
+func (ic *imageCopier) bpcDecompressCompressed(ctx context.Context, stream *sourceStream, detected bpDetectCompressionStepData) (*bpCompressionStepData, error) {
 	if ic.c.dest.DesiredLayerCompression() == types.Decompress && detected.isCompressed {
-		logrus.Debugf("Blob will be decompressed")
+		log.DebugfContext(ctx, "Blob will be decompressed")
 		s, err := detected.decompressor(stream.reader)
 		if err != nil {
 			return nil, err
@@ -239,9 +241,9 @@ func (ic *imageCopier) bpcDecompressCompressed(stream *sourceStream, detected bp
 // bpcPreserveOriginal returns a *bpCompressionStepData for not changing the original blob.
 // This does not change the sourceStream parameter; we include it for symmetry with other
 // pipeline steps.
-func (ic *imageCopier) bpcPreserveOriginal(_ *sourceStream, detected bpDetectCompressionStepData,
+func (ic *imageCopier) bpcPreserveOriginal(ctx context.Context, _ *sourceStream, detected bpDetectCompressionStepData,
 	layerCompressionChangeSupported bool) *bpCompressionStepData {
-	logrus.Debugf("Using original blob without modification")
+	log.DebugfContext(ctx, "Using original blob without modification")
 	// Remember if the original blob was compressed, and if so how, so that if
 	// LayerInfosForCopy() returned something that differs from what was in the
 	// source's manifest, and UpdatedImage() needs to call UpdateLayerInfos(),
@@ -322,6 +324,11 @@ func (d *bpCompressionStepData) recordValidatedDigestData(c *copier, uploadedInf
 		default:
 			return fmt.Errorf("Internal error: Unexpected d.operation value %#v", d.operation)
 		}
+		if len(d.uploadedAnnotations) != 0 {
+			// We have created the annotations ourselves while compressing, so they are trustworthy;
+			// record them so that a later substitution of this blob can carry them over.
+			c.blobInfoCache.RecordDigestCompressionAnnotations(uploadedInfo.Digest, d.uploadedAnnotations)
+		}
 	}
 	if d.uploadedCompressorName != "" && d.uploadedCompressorName != internalblobinfocache.UnknownCompression {
 		if d.uploadedCompressorName != compressiontypes.ZstdChunkedAlgorithmName {
@@ -334,6 +341,11 @@ func (d *bpCompressionStepData) recordValidatedDigestData(c *copier, uploadedInf
 			// with the same digest and both ZstdAlgorithmName and ZstdChunkedAlgorithmName , which causes warnings about
 			// inconsistent data to be logged.
 			c.blobInfoCache.RecordDigestCompressorName(uploadedInfo.Digest, d.uploadedCompressorName)
+		} else if d.uploadedAlgorithm != nil && len(d.uploadedAnnotations) != 0 {
+			// We have created a zstd:chunked blob ourselves; record its base variant, which is consistent with what
+			// blobPipelineDetectCompressionStep finds. The zstd:chunked metadata is recorded as annotations above, and
+			// substituting this blob carries them over.
+			c.blobInfoCache.RecordDigestCompressorName(uploadedInfo.Digest, d.uploadedAlgorithm.BaseVariantName())
 		}
 	}
 	if srcInfo.Digest != "" && srcInfo.Digest != uploadedInfo.Digest &&
@@ -360,9 +372,11 @@ func doCompression(dest io.Writer, src io.Reader, metadata map[string]string, co
 		return err
 	}
 
-	buf := make([]byte, compressionBufferSize)
+	pool := bufferpool.ForSize(compressionBufferSize)
+	buf := pool.Get()
+	defer pool.Put(buf)
 
-	_, err = io.CopyBuffer(compressor, src, buf) // Sets err to nil, i.e. causes dest.Close()
+	_, err = io.CopyBuffer(compressor, src, *buf) // Sets err to nil, i.e. causes dest.Close()
 	if err != nil {
 		compressor.Close()
 		return err
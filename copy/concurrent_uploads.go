@@ -0,0 +1,57 @@
+package copy
+
+import (
+	"sync"
+
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// blobUploadKey identifies an upload of a blob to a destination.
+type blobUploadKey struct {
+	destination string // See blobUploadDestination
+	digest      digest.Digest
+}
+
+// blobUploadTracker tracks blob uploads in progress within this process, so that concurrent copies
+// of the same blob to the same destination can be coalesced into a single upload.
+type blobUploadTracker struct {
+	mutex      sync.Mutex                      // Protects the members below
+	inProgress map[blobUploadKey]chan struct{} // The channel is closed when the upload finishes
+}
+
+// blobUploads tracks all uploads done by this package.
+var blobUploads = &blobUploadTracker{inProgress: map[blobUploadKey]chan struct{}{}}
+
+// claim registers an upload of key by the caller.
+// If no such upload is in progress, it returns (nil, release); the caller is responsible for the upload, and must call release()
+// when it finishes, successfully or not.
+// Otherwise, it returns (done, nil), where done is closed when the other upload finishes; the caller should then check
+// whether the blob can be reused, and if not, call claim again.
+func (t *blobUploadTracker) claim(key blobUploadKey) (<-chan struct{}, func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if done, ok := t.inProgress[key]; ok {
+		return done, nil
+	}
+	done := make(chan struct{})
+	t.inProgress[key] = done
+	return nil, func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		delete(t.inProgress, key)
+		close(done)
+	}
+}
+
+// blobUploadDestination returns a string identifying the location where blobs uploaded to ref are stored,
+// for use in blobUploadKey.
+// For destinations with a Docker reference, this is the repository, so that uploads for different tags are coalesced;
+// otherwise, it is the full image name.
+func blobUploadDestination(ref types.ImageReference) string {
+	if named := ref.DockerReference(); named != nil {
+		return ref.Transport().Name() + ":" + named.Name()
+	}
+	return transports.ImageName(ref)
+}
@@ -0,0 +1,66 @@
+package copy
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobUploadTrackerClaim(t *testing.T) {
+	tracker := &blobUploadTracker{inProgress: map[blobUploadKey]chan struct{}{}}
+	key1 := blobUploadKey{destination: "docker:example.com/repo", digest: digest.FromString("1")}
+	key2 := blobUploadKey{destination: "docker:example.com/repo", digest: digest.FromString("2")}
+
+	done, release1 := tracker.claim(key1)
+	assert.Nil(t, done)
+	require.NotNil(t, release1)
+
+	// A different key is independent
+	done, release2 := tracker.claim(key2)
+	assert.Nil(t, done)
+	require.NotNil(t, release2)
+	release2()
+
+	// The same key waits for the first upload
+	done, release := tracker.claim(key1)
+	assert.Nil(t, release)
+	require.NotNil(t, done)
+	select {
+	case <-done:
+		t.Fatal("upload reported finished before release")
+	default:
+	}
+	release1()
+	select {
+	case <-done:
+	default:
+		t.Fatal("upload not reported finished after release")
+	}
+
+	// After release, the key can be claimed again
+	done, release = tracker.claim(key1)
+	assert.Nil(t, done)
+	require.NotNil(t, release)
+	release()
+	assert.Empty(t, tracker.inProgress)
+}
+
+func TestBlobUploadDestination(t *testing.T) {
+	ref1, err := docker.ParseReference("//example.com/repo:tag1")
+	require.NoError(t, err)
+	ref2, err := docker.ParseReference("//example.com/repo:tag2")
+	require.NoError(t, err)
+	ref3, err := docker.ParseReference("//example.com/other:tag1")
+	require.NoError(t, err)
+	assert.Equal(t, blobUploadDestination(ref1), blobUploadDestination(ref2))
+	assert.NotEqual(t, blobUploadDestination(ref1), blobUploadDestination(ref3))
+
+	dir := t.TempDir()
+	dirRef, err := directory.NewReference(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "dir:"+dir, blobUploadDestination(dirRef))
+}
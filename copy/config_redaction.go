@@ -0,0 +1,284 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage/pkg/regexp"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// RedactedValue replaces values redacted due to Options.ConfigRedaction.
+const RedactedValue = "<redacted>"
+
+// ConfigRedaction specifies sensitive values to remove from image configs while copying, see Options.ConfigRedaction.
+type ConfigRedaction struct {
+	// NamePatterns are patterns, in path.Match syntax, matched against names of environment variables and build arguments,
+	// e.g. "*_TOKEN" or "AWS_SECRET_ACCESS_KEY".
+	// Matching environment variables are removed from the config (and from the legacy container_config of Docker images).
+	// In commands recorded in the image history, values of matching NAME=value assignments (as recorded for build arguments,
+	// ENV instructions, and in RUN commands) are replaced by RedactedValue.
+	NamePatterns []string
+	// If StripHistoryCommands is set, the commands recorded in the image history (created_by) are removed entirely.
+	StripHistoryCommands bool
+}
+
+// historyAssignmentRegexp matches NAME=value assignments in history commands. The value may be quoted.
+var historyAssignmentRegexp = regexp.Delayed(`(^|[\s|;&(])([A-Za-z_][A-Za-z0-9_]*)=("[^"]*"|'[^']*'|[^\s;&|)]*)`)
+
+// validate returns an error if r is not valid.
+func (r *ConfigRedaction) validate() error {
+	for _, p := range r.NamePatterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid config redaction pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// nameMatches returns true if name matches one of r.NamePatterns.
+func (r *ConfigRedaction) nameMatches(name string) bool {
+	return slices.ContainsFunc(r.NamePatterns, func(p string) bool {
+		matches, err := path.Match(p, name)
+		return err == nil && matches // Patterns were validated by validate().
+	})
+}
+
+// redactCommand returns command with values of assignments to names matching r.NamePatterns redacted.
+func (r *ConfigRedaction) redactCommand(command string) string {
+	return historyAssignmentRegexp.ReplaceAllStringFunc(command, func(match string) string {
+		m := historyAssignmentRegexp.FindStringSubmatch(match)
+		if !r.nameMatches(m[2]) {
+			return match
+		}
+		return m[1] + m[2] + "=" + RedactedValue
+	})
+}
+
+// redactConfig returns configBlob modified according to r, and true if anything was redacted.
+// If nothing was redacted, it returns configBlob unmodified.
+func (r *ConfigRedaction) redactConfig(configBlob []byte) ([]byte, bool, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(configBlob, &config); err != nil {
+		return nil, false, fmt.Errorf("parsing image config: %w", err)
+	}
+	changed := false
+	for _, key := range []string{"config", "container_config"} {
+		raw, ok := config[key]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		var runConfig map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &runConfig); err != nil {
+			return nil, false, fmt.Errorf("parsing image config %q: %w", key, err)
+		}
+		rawEnv, ok := runConfig["Env"]
+		if !ok {
+			continue
+		}
+		var env []string
+		if err := json.Unmarshal(rawEnv, &env); err != nil {
+			return nil, false, fmt.Errorf("parsing image config %q environment: %w", key, err)
+		}
+		redactedEnv := slices.DeleteFunc(slices.Clone(env), func(v string) bool {
+			name, _, _ := strings.Cut(v, "=")
+			return r.nameMatches(name)
+		})
+		if len(redactedEnv) == len(env) {
+			continue
+		}
+		if err := setJSONField(runConfig, "Env", redactedEnv); err != nil {
+			return nil, false, err
+		}
+		if err := setJSONField(config, key, runConfig); err != nil {
+			return nil, false, err
+		}
+		changed = true
+	}
+
+	if raw, ok := config["history"]; ok && string(raw) != "null" {
+		var history []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &history); err != nil {
+			return nil, false, fmt.Errorf("parsing image history: %w", err)
+		}
+		historyChanged := false
+		for _, entry := range history {
+			rawCreatedBy, ok := entry["created_by"]
+			if !ok {
+				continue
+			}
+			if r.StripHistoryCommands {
+				delete(entry, "created_by")
+				historyChanged = true
+				continue
+			}
+			var createdBy string
+			if err := json.Unmarshal(rawCreatedBy, &createdBy); err != nil {
+				return nil, false, fmt.Errorf("parsing image history: %w", err)
+			}
+			if redacted := r.redactCommand(createdBy); redacted != createdBy {
+				if err := setJSONField(entry, "created_by", redacted); err != nil {
+					return nil, false, err
+				}
+				historyChanged = true
+			}
+		}
+		if historyChanged {
+			if err := setJSONField(config, "history", history); err != nil {
+				return nil, false, err
+			}
+			changed = true
+		}
+	}
+
+	if !changed {
+		return configBlob, false, nil
+	}
+	res, err := marshalJSONWithoutHTMLEscaping(config)
+	if err != nil {
+		return nil, false, err
+	}
+	return res, true, nil
+}
+
+// setJSONField sets m[key] to the JSON representation of value.
+func setJSONField(m map[string]json.RawMessage, key string, value any) error {
+	raw, err := marshalJSONWithoutHTMLEscaping(value)
+	if err != nil {
+		return err
+	}
+	m[key] = raw
+	return nil
+}
+
+// marshalJSONWithoutHTMLEscaping is json.Marshal, except that it does not escape <, > and &,
+// so that redacted values and shell commands stay readable.
+func marshalJSONWithoutHTMLEscaping(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// prepareConfigRedaction checks whether ic.c.options.ConfigRedaction modifies the image config,
+// and fails if the manifest can’t be modified in that case.
+func (ic *imageCopier) prepareConfigRedaction(ctx context.Context) error {
+	if ic.c.options.ConfigRedaction == nil {
+		return nil
+	}
+	if ic.src.ManifestMIMEType == manifest.DockerV2Schema1MediaType || ic.src.ManifestMIMEType == manifest.DockerV2Schema1SignedMediaType {
+		return errors.New("Redacting the image config of schema1 images is not supported")
+	}
+	if ic.src.ConfigInfo().Digest == "" {
+		return nil // No config, e.g. some artifacts.
+	}
+	configBlob, err := ic.src.ConfigBlob(ctx)
+	if err != nil {
+		return fmt.Errorf("reading config blob: %w", err)
+	}
+	_, changed, err := ic.c.options.ConfigRedaction.redactConfig(configBlob)
+	if err != nil {
+		return err
+	}
+	if changed {
+		if ic.cannotModifyManifestReason != "" {
+			return fmt.Errorf("Redacting the image config would change the manifest, which we cannot do: %q", ic.cannotModifyManifestReason)
+		}
+		ic.configRedacted = true
+	}
+	return nil
+}
+
+// redactedConfigImage is a types.Image with an updated config and manifest, as created by redactImageConfig.
+type redactedConfigImage struct {
+	types.Image
+	manifest         []byte
+	manifestMIMEType string
+	configBlob       []byte
+	configInfo       types.BlobInfo
+}
+
+func (i *redactedConfigImage) Manifest(ctx context.Context) ([]byte, string, error) {
+	return i.manifest, i.manifestMIMEType, nil
+}
+
+func (i *redactedConfigImage) ConfigInfo() types.BlobInfo {
+	return i.configInfo
+}
+
+func (i *redactedConfigImage) ConfigBlob(ctx context.Context) ([]byte, error) {
+	return i.configBlob, nil
+}
+
+// redactImageConfig returns img with its config modified according to ic.c.options.ConfigRedaction, if any.
+func (ic *imageCopier) redactImageConfig(ctx context.Context, img types.Image) (types.Image, error) {
+	if !ic.configRedacted {
+		return img, nil
+	}
+	man, mimeType, err := img.Manifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	if mimeType == manifest.DockerV2Schema1MediaType || mimeType == manifest.DockerV2Schema1SignedMediaType {
+		// The config would be embedded in the manifest.
+		return nil, errors.New("Redacting the image config of schema1 images is not supported")
+	}
+	configBlob, err := img.ConfigBlob(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading config blob: %w", err)
+	}
+	redactedConfig, changed, err := ic.c.options.ConfigRedaction.redactConfig(configBlob)
+	if err != nil {
+		return nil, err
+	}
+	if !changed { // Possible if a manifest conversion has already dropped the redacted fields.
+		return img, nil
+	}
+	configInfo := img.ConfigInfo()
+	configInfo.Digest = digest.FromBytes(redactedConfig)
+	configInfo.Size = int64(len(redactedConfig))
+
+	var updatedManifest []byte
+	switch mimeType {
+	case manifest.DockerV2Schema2MediaType:
+		m, err := manifest.Schema2FromManifest(man)
+		if err != nil {
+			return nil, err
+		}
+		m.ConfigDescriptor.Digest = configInfo.Digest
+		m.ConfigDescriptor.Size = configInfo.Size
+		updatedManifest, err = m.Serialize()
+		if err != nil {
+			return nil, err
+		}
+	default: // OCI, or an OCI artifact
+		m, err := manifest.OCI1FromManifest(man)
+		if err != nil {
+			return nil, err
+		}
+		m.Config.Digest = configInfo.Digest
+		m.Config.Size = configInfo.Size
+		updatedManifest, err = m.Serialize()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &redactedConfigImage{
+		Image:            img,
+		manifest:         updatedManifest,
+		manifestMIMEType: mimeType,
+		configBlob:       redactedConfig,
+		configInfo:       configInfo,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package copy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRedactionValidate(t *testing.T) {
+	for _, patterns := range [][]string{nil, {}, {"*_TOKEN", "AWS_*", "PASSWORD"}} {
+		r := ConfigRedaction{NamePatterns: patterns}
+		assert.NoError(t, r.validate(), "%#v", patterns)
+	}
+	r := ConfigRedaction{NamePatterns: []string{"OK", "[INVALID"}}
+	assert.Error(t, r.validate())
+}
+
+func TestConfigRedactionRedactCommand(t *testing.T) {
+	r := ConfigRedaction{NamePatterns: []string{"*_TOKEN", "PASSWORD"}}
+	for _, c := range []struct{ input, expected string }{
+		{"", ""},
+		{"/bin/sh -c make", "/bin/sh -c make"},
+		{ // Build arguments, as recorded by docker build and buildah
+			"|3 API_TOKEN=abc VERSION=1.0 PASSWORD=secret /bin/sh -c make",
+			"|3 API_TOKEN=<redacted> VERSION=1.0 PASSWORD=<redacted> /bin/sh -c make",
+		},
+		{"/bin/sh -c #(nop)  ENV PASSWORD=secret", "/bin/sh -c #(nop)  ENV PASSWORD=<redacted>"},
+		{`/bin/sh -c #(nop)  ENV PASSWORD="two words" OTHER=1`, `/bin/sh -c #(nop)  ENV PASSWORD=<redacted> OTHER=1`},
+		{`/bin/sh -c PASSWORD='x y';make`, `/bin/sh -c PASSWORD=<redacted>;make`},
+		{"/bin/sh -c export GH_TOKEN=abc&&make", "/bin/sh -c export GH_TOKEN=<redacted>&&make"},
+		{"/bin/sh -c make OPTION=PASSWORD=x", "/bin/sh -c make OPTION=PASSWORD=x"}, // Not an assignment to PASSWORD
+		{"/bin/sh -c NOT_PASSWORD=x", "/bin/sh -c NOT_PASSWORD=x"},
+	} {
+		assert.Equal(t, c.expected, r.redactCommand(c.input), c.input)
+	}
+}
+
+func TestConfigRedactionRedactConfig(t *testing.T) {
+	const config = `{"architecture":"amd64","os":"linux",` +
+		`"config":{"Env":["PATH=/usr/bin","API_TOKEN=abc"],"Cmd":["/bin/sh"]},` +
+		`"container_config":{"Env":["API_TOKEN=abc"]},` +
+		`"history":[{"created_by":"|1 API_TOKEN=abc /bin/sh -c make","comment":"c"},{"empty_layer":true}],` +
+		`"rootfs":{"type":"layers","diff_ids":[]}}`
+
+	// Nothing to redact
+	for _, r := range []ConfigRedaction{
+		{},
+		{NamePatterns: []string{"UNUSED_*"}},
+	} {
+		res, changed, err := r.redactConfig([]byte(config))
+		require.NoError(t, err)
+		assert.False(t, changed)
+		assert.Equal(t, config, string(res))
+	}
+
+	r := ConfigRedaction{NamePatterns: []string{"*_TOKEN"}}
+	res, changed, err := r.redactConfig([]byte(config))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotContains(t, string(res), "abc")
+	var parsed struct {
+		Architecture string `json:"architecture"`
+		Config       struct {
+			Env []string
+			Cmd []string
+		} `json:"config"`
+		ContainerConfig struct {
+			Env []string
+		} `json:"container_config"`
+		History []map[string]any `json:"history"`
+	}
+	err = json.Unmarshal(res, &parsed)
+	require.NoError(t, err)
+	assert.Equal(t, "amd64", parsed.Architecture)
+	assert.Equal(t, []string{"PATH=/usr/bin"}, parsed.Config.Env)
+	assert.Equal(t, []string{"/bin/sh"}, parsed.Config.Cmd)
+	assert.Equal(t, []string{}, parsed.ContainerConfig.Env)
+	assert.Equal(t, []map[string]any{
+		{"created_by": "|1 API_TOKEN=<redacted> /bin/sh -c make", "comment": "c"},
+		{"empty_layer": true},
+	}, parsed.History)
+
+	r = ConfigRedaction{StripHistoryCommands: true}
+	res, changed, err = r.redactConfig([]byte(config))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	var parsedHistory struct {
+		History []map[string]any `json:"history"`
+	}
+	err = json.Unmarshal(res, &parsedHistory)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{{"comment": "c"}, {"empty_layer": true}}, parsedHistory.History)
+
+	// Invalid config
+	_, _, err = r.redactConfig([]byte("not JSON"))
+	assert.Error(t, err)
+	_, _, err = r.redactConfig([]byte(`{"config":{"Env":"not an array"}}`))
+	assert.Error(t, err)
+}
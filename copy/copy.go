@@ -2,6 +2,7 @@ package copy
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
@@ -14,18 +15,22 @@ import (
 	"github.com/containers/image/v5/internal/image"
 	"github.com/containers/image/v5/internal/imagedestination"
 	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/log"
 	internalManifest "github.com/containers/image/v5/internal/manifest"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/tracing"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/blobinfocache"
 	compression "github.com/containers/image/v5/pkg/compression/types"
+	"github.com/containers/image/v5/pkg/logging"
+	"github.com/containers/image/v5/pkg/metrics"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/signature/signer"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
 	encconfig "github.com/containers/ocicrypt/config"
 	digest "github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/semaphore"
 	"golang.org/x/term"
 )
@@ -70,26 +75,54 @@ const (
 // specific images from the source reference.
 type ImageListSelection int
 
+const (
+	// SignListAndInstances is the default value which, when set in
+	// Options.ImageListSigning, indicates that when copying a list of images
+	// with signing enabled, both the list and every copied instance are signed.
+	SignListAndInstances ImageListSigning = iota
+	// SignListOnly is a value which, when set in Options.ImageListSigning,
+	// indicates that when copying a list of images with signing enabled,
+	// only the list itself is signed (as e.g. `cosign verify` of the list digest expects).
+	SignListOnly
+	// SignInstancesOnly is a value which, when set in Options.ImageListSigning,
+	// indicates that when copying a list of images with signing enabled,
+	// only the individual instances are signed (as e.g. CRI-O, which verifies the per-platform image, expects).
+	SignInstancesOnly
+)
+
+// ImageListSigning is one of SignListAndInstances, SignListOnly, or SignInstancesOnly,
+// to control which manifests copy.Image() signs when copying a list of images.
+// It has no effect when copying a single image, which is always signed if signing is enabled.
+type ImageListSigning int
+
 // Options allows supplying non-default configuration modifying the behavior of CopyImage.
 type Options struct {
 	RemoveSignatures bool // Remove any pre-existing signatures. Signers and SignBy… will still add a new signature.
 	// Signers to use to add signatures during the copy.
 	// Callers are still responsible for closing these Signer objects; they can be reused for multiple copy.Image operations in a row.
 	Signers                          []*signer.Signer
-	SignBy                           string          // If non-empty, asks for a signature to be added during the copy, and specifies a key ID, as accepted by signature.NewGPGSigningMechanism().SignDockerManifest(),
-	SignPassphrase                   string          // Passphrase to use when signing with the key ID from `SignBy`.
-	SignBySigstorePrivateKeyFile     string          // If non-empty, asks for a signature to be added during the copy, using a sigstore private key file at the provided path.
-	SignSigstorePrivateKeyPassphrase []byte          // Passphrase to use when signing with `SignBySigstorePrivateKeyFile`.
-	SignIdentity                     reference.Named // Identify to use when signing, defaults to the docker reference of the destination
+	SignBy                           string           // If non-empty, asks for a signature to be added during the copy, and specifies a key ID, as accepted by signature.NewGPGSigningMechanism().SignDockerManifest(),
+	SignPassphrase                   string           // Passphrase to use when signing with the key ID from `SignBy`.
+	SignBySigstorePrivateKeyFile     string           // If non-empty, asks for a signature to be added during the copy, using a sigstore private key file at the provided path.
+	SignSigstorePrivateKeyPassphrase []byte           // Passphrase to use when signing with `SignBySigstorePrivateKeyFile`.
+	SignIdentity                     reference.Named  // Identify to use when signing, defaults to the docker reference of the destination
+	ImageListSigning                 ImageListSigning // set to SignListAndInstances (the default), SignListOnly, or SignInstancesOnly to control what is signed when copying a list of images
 
 	ReportWriter     io.Writer
 	SourceCtx        *types.SystemContext
 	DestinationCtx   *types.SystemContext
 	ProgressInterval time.Duration                 // time to wait between reports to signal the progress channel
 	Progress         chan types.ProgressProperties // Reported to when ProgressInterval has arrived for a single artifact+offset.
+	// Some destinations (e.g. containers-storage) also report progress of applying layers after their data was transferred,
+	// using types.ProgressEventApply* events on Progress (and on ProgressJSONWriter).
 
 	// Preserve digests, and fail if we cannot.
 	PreserveDigests bool
+	// If PreserveListDigest is set, the source must be a list of images, and the list written to the destination
+	// must be byte-for-byte identical to it, so that it has the same digest; the copy fails otherwise.
+	// This implies PreserveDigests, requires ImageListSelection to be CopyAllImages or CopySpecificImages,
+	// and can't be combined with options which modify the list, like EnsureCompressionVariantsExist, SkipUnavailableInstances or SkipFailedInstances.
+	PreserveListDigest bool
 	// manifest MIME type of image set by user. "" is default and means use the autodetection to the manifest MIME type
 	ForceManifestMIMEType string
 	ImageListSelection    ImageListSelection // set to either CopySystemImage (the default), CopyAllImages, or CopySpecificImages to control which instances we copy when the source reference is a list; ignored if the source reference is not a list
@@ -102,6 +135,7 @@ type Options struct {
 
 	// If OciEncryptConfig is non-nil, it indicates that an image should be encrypted.
 	// The encryption options is derived from the construction of EncryptConfig object.
+	// age recipients and KMS keys are supported in addition to the schemes built into ocicrypt, see pkg/agecrypt and pkg/kmscrypt.
 	OciEncryptConfig *encconfig.EncryptConfig
 	// OciEncryptLayers represents the list of layers to encrypt.
 	// If nil, don't encrypt any layers.
@@ -109,8 +143,14 @@ type Options struct {
 	// integers in the slice represent 0-indexed layer indices, with support for negative
 	// indexing. i.e. 0 is the first layer, -1 is the last (top-most) layer.
 	OciEncryptLayers *[]int
+	// OciEncryptLayerPolicy, if set, selects the layers to encrypt, as an alternative to OciEncryptLayers;
+	// see e.g. EncryptLayersAfter and EncryptLayersWithAnnotation. It must not be set together with OciEncryptLayers.
+	OciEncryptLayerPolicy EncryptLayerPolicy
 	// OciDecryptConfig contains the config that can be used to decrypt an image if it is
 	// encrypted if non-nil. If nil, it does not attempt to decrypt an image.
+	// If an already-encrypted layer is selected for encryption (by OciEncryptLayers or OciEncryptLayerPolicy) and OciEncryptConfig is set,
+	// the layer is not decrypted; its key is unwrapped using this config and rewrapped for the OciEncryptConfig recipients only,
+	// and the encrypted data is copied unmodified. This allows rotating recipients without re-encrypting the layer data.
 	OciDecryptConfig *encconfig.DecryptConfig
 
 	// A weighted semaphore to limit the amount of concurrently copied layers and configs. Applies to all copy operations using the semaphore. If set, MaxParallelDownloads is ignored.
@@ -137,6 +177,109 @@ type Options struct {
 	// DestinationCtx.CompressionFormat is used exclusively, and blobs of other
 	// compression algorithms are not reused.
 	ForceCompressionFormat bool
+	// If SkipUnavailableInstances is set, when copying a list of images, instances which fail to copy
+	// because the instance or some of its blobs are missing at the source are removed from the
+	// list written to the destination, instead of failing the whole copy.
+	// Any other failure (e.g. a policy rejection, an authentication failure, or an error writing to the destination)
+	// still fails the whole copy.
+	// The copy still fails if no instance could be copied, or if the list can not be modified (e.g. because it is signed).
+	SkipUnavailableInstances bool
+	// If SkipFailedInstances is set, when copying a list of images, instances which fail to copy for any reason
+	// (e.g. a policy rejection, or an error writing to the destination) are removed from the list written to the destination,
+	// instead of failing the whole copy; this is a best-effort superset of SkipUnavailableInstances.
+	// Canceling ctx still fails the whole copy.
+	// The copy still fails if no instance could be copied, or if the list can not be modified (e.g. because it is signed).
+	SkipFailedInstances bool
+	// If not nil, OnSkippedInstance is called for every instance skipped due to SkipUnavailableInstances or SkipFailedInstances,
+	// with the digest of the instance in the source list and the error which caused it to be skipped.
+	OnSkippedInstance func(instanceDigest digest.Digest, err error)
+	// If not nil, Report is overwritten with details about the copy, e.g. which instances (and platforms) were skipped
+	// due to SkipUnavailableInstances or SkipFailedInstances, and why. It is only meaningful if the copy succeeds.
+	Report *Report
+	// If SkipExistingInstances is set, when copying a list of images to a destination which already contains a list of images,
+	// instances whose digest is already listed in the destination’s list are not copied again; only new or changed instances
+	// are copied, and then the updated list is written. This makes periodically synchronizing lists where only a few
+	// instances change much cheaper.
+	// Instances at the destination are assumed to be complete, and to not need any of the edits this copy would make
+	// (e.g. compression or manifest format conversions); their signatures are not copied.
+	// Skipped instances must still be allowed by the policy.
+	// Instances are always copied if they are to be signed. This can't be used together with EnsureCompressionVariantsExist.
+	SkipExistingInstances bool
+
+	// BaseImages lists images which the copied images are likely to be based on, typically copies of a common base image
+	// at the destination, accessed using DestinationCtx; digested references avoid reading a tag which might have moved.
+	// Before copying any layers, the layers of these images (of all instances, for lists of images) are recorded
+	// in the blob info cache as available at the base images’ locations, so that layers shared with a base image
+	// are reused from it (e.g. mounted from the base image’s repository) instead of uploaded, even if this process
+	// has never copied them before. This is only a hint: base images which can’t be read are ignored, with a warning,
+	// and transports which can’t reuse blobs this way ignore this field.
+	BaseImages []types.ImageReference
+
+	// If not nil, receives metrics about the blobs copied.
+	// Metrics about accessing the source and destination are reported via SourceCtx.MetricsRecorder and DestinationCtx.MetricsRecorder.
+	MetricsRecorder metrics.Recorder
+
+	// If PipelinedDigesting is set, digests of blobs read from the source are computed in a separate goroutine per blob,
+	// overlapping with reading, decompressing and writing the data.  This uses a bit more memory and CPU time,
+	// but can improve throughput for large blobs if the single-threaded digest computation is the bottleneck.
+	// The digest of each blob is still computed by a single goroutine: the algorithms used for blob digests (SHA-256 and SHA-512)
+	// process the data strictly sequentially, so they can’t be split across several workers (“tree hashing”)
+	// while producing the same digest values.
+	PipelinedDigesting bool
+
+	// If VerifyLayerDiffIDs is set, the uncompressed digest of every copied layer is compared with the corresponding
+	// rootfs.diff_ids entry of the image’s config, and the copy fails as soon as a layer does not match.
+	// This detects registries serving blobs which match the digests in the manifest, but not the config (e.g. after the manifest was edited).
+	// It requires reading and decompressing layers which could otherwise be reused without reading them,
+	// unless their uncompressed digest is already recorded in the blob info cache.
+	// Layers which stay encrypted are not verified. Copying schema1 images, which have no config, fails if this is set.
+	VerifyLayerDiffIDs bool
+
+	// Limits on the copied image, e.g. to enforce quotas in services copying images on behalf of others.
+	// Zero values mean no limit. Exceeding any of them fails the copy with a *LimitExceededError.
+	// Limits are checked against sizes listed in the manifest before copying any layers, and again while copying,
+	// in case the manifest omits sizes or the source serves more data than listed.
+	//
+	// MaxLayerSize is the maximum size of a single layer blob, as read from the source (usually compressed).
+	MaxLayerSize int64
+	// MaxTotalUncompressedSize is the maximum total uncompressed size of layers read from the source,
+	// across all images copied by this call (e.g. all instances of a multi-platform image).
+	// Layers reused at the destination are not read, and do not count against this limit.
+	// Partial pulls (e.g. of zstd:chunked layers) are not used if this is set, because their uncompressed size can’t be counted.
+	MaxTotalUncompressedSize int64
+	// MaxLayerCount is the maximum number of layers of a single image.
+	MaxLayerCount int
+
+	// OperationID identifies this copy in log records (using the logging.OperationIDKey attribute) and in ProgressProperties.
+	// If empty, a random ID is generated.
+	OperationID string
+
+	// If not nil, is notified about the start and end of phases of the copy, e.g. to find out which one is a bottleneck.
+	PhaseHooks PhaseHooks
+	// PhaseTimeouts limits the duration of individual phases of the copy, e.g. so that a single stuck blob transfer fails
+	// quickly, even if the context passed to Image allows a long overall copy. By default, phases are not limited.
+	PhaseTimeouts PhaseTimeouts
+
+	// If not nil, LayerScanner receives the uncompressed contents of every layer read from the source while it is being copied,
+	// and can reject the image before its manifest is written to the destination, e.g. to scan for malware or secrets.
+	// This requires reading and decompressing layers which could otherwise be reused without reading them.
+	// Layers which stay encrypted, foreign layers which are not copied, and images skipped because of
+	// OptimizeDestinationImageAlreadyExists or SkipExistingInstances are not scanned.
+	LayerScanner LayerScanner
+
+	// If not nil, ConfigRedaction specifies sensitive values (e.g. secrets passed as build arguments or environment variables)
+	// to remove from the configs of copied images, e.g. before mirroring images to an external location.
+	// Modifying the config changes the manifest, so this fails if the manifest can’t be modified (e.g. with PreserveDigests,
+	// or if the image is signed and RemoveSignatures is not set). Images with schema1 manifests are not supported.
+	// Note that this only edits the config; values which are also stored in layer contents are not removed from the layers.
+	ConfigRedaction *ConfigRedaction
+
+	// If not nil, newline-delimited JSON progress events (see JSONProgressEvent) are written to ProgressJSONWriter,
+	// as a stable interface for wrapping tools. This is independent of Progress and ReportWriter.
+	// JSONProgressBlobProgress events are written every ProgressInterval, or every second if ProgressInterval is not set.
+	// Writes may happen concurrently from several goroutines, but they are serialized; a failed write stops further events
+	// without failing the copy.
+	ProgressJSONWriter io.Writer
 }
 
 // OptionCompressionVariant allows to supply information about
@@ -165,6 +308,15 @@ type copier struct {
 	concurrentBlobCopiesSemaphore *semaphore.Weighted // Limits the amount of concurrently copied blobs
 	signers                       []*signer.Signer    // Signers to use to create new signatures for the image
 	signersToClose                []*signer.Signer    // Signers that should be closed when this copier is destroyed.
+	metrics                       metrics.Recorder    // never nil
+	operationID                   string              // never ""
+
+	jsonProgress     *jsonProgressWriter            // nil if options.ProgressJSONWriter is not set
+	phaseHooks       PhaseHooks                     // nil if phases are not reported
+	reportProgress   func(types.ProgressProperties) // nil if blob progress is not reported
+	progressInterval time.Duration                  // interval between ProgressEventRead events
+
+	uncompressedSizeLimit *uncompressedSizeLimit // nil if options.MaxTotalUncompressedSize is not set
 }
 
 // Internal function to validate `requireCompressionFormatMatch` for copySingleImageOptions
@@ -178,14 +330,58 @@ func shouldRequireCompressionFormatMatch(options *Options) (bool, error) {
 // Image copies image from srcRef to destRef, using policyContext to validate
 // source image admissibility.  It returns the manifest which was written to
 // the new copy of the image.
+//
+// If the application has configured an OpenTelemetry TracerProvider (see go.opentelemetry.io/otel.SetTracerProvider),
+// the copy and its phases are recorded as spans, as children of any span in ctx.
+//
+// Log records of the copy are written to the logger for ctx (see pkg/logging), with an added
+// logging.OperationIDKey attribute set to options.OperationID or a generated ID.
 func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef, srcRef types.ImageReference, options *Options) (copiedManifest []byte, retErr error) {
+	ctx, span := tracing.Start(ctx, "copy.Image",
+		attribute.String("image.source", transports.ImageName(srcRef)),
+		attribute.String("image.destination", transports.ImageName(destRef)))
+	defer func() { tracing.End(span, retErr) }()
+
 	if options == nil {
 		options = &Options{}
 	}
+	if options.Report != nil {
+		*options.Report = Report{}
+	}
+	operationID := options.OperationID
+	if operationID == "" {
+		id, err := newOperationID()
+		if err != nil {
+			return nil, err
+		}
+		operationID = id
+	}
+	ctx = logging.WithOperationID(ctx, operationID)
+	span.SetAttributes(attribute.String("copy.operation_id", operationID))
 
 	if err := validateImageListSelection(options.ImageListSelection); err != nil {
 		return nil, err
 	}
+	if err := validateImageListSigning(options.ImageListSigning); err != nil {
+		return nil, err
+	}
+	if options.OciEncryptLayers != nil && options.OciEncryptLayerPolicy != nil {
+		return nil, errors.New("OciEncryptLayers and OciEncryptLayerPolicy can not be used together")
+	}
+	if err := validatePreserveListDigest(options); err != nil {
+		return nil, err
+	}
+	if options.SkipExistingInstances && len(options.EnsureCompressionVariantsExist) > 0 {
+		return nil, errors.New("SkipExistingInstances can not be used together with EnsureCompressionVariantsExist")
+	}
+	if options.ConfigRedaction != nil {
+		if err := options.ConfigRedaction.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := options.PhaseTimeouts.validate(); err != nil {
+		return nil, err
+	}
 
 	reportWriter := io.Discard
 
@@ -245,10 +441,31 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 		// For now, use DestinationCtx (because blob reuse changes the behavior of the destination side more).
 		// Conceptually the cache settings should be in copy.Options instead.
 		blobInfoCache: internalblobinfocache.FromBlobInfoCache(blobinfocache.DefaultCache(options.DestinationCtx)),
+		metrics:       metrics.Discard,
+		operationID:   operationID,
+	}
+	if options.MetricsRecorder != nil {
+		c.metrics = options.MetricsRecorder
+	}
+	if options.MaxTotalUncompressedSize > 0 {
+		c.uncompressedSizeLimit = &uncompressedSizeLimit{max: options.MaxTotalUncompressedSize}
 	}
-	defer c.close()
+	if options.ProgressJSONWriter != nil {
+		c.jsonProgress = newJSONProgressWriter(options.ProgressJSONWriter, operationID)
+		c.jsonProgress.copyStarted(transports.ImageName(srcRef), transports.ImageName(destRef))
+		defer func() { c.jsonProgress.copyFinished(retErr) }()
+	}
+	c.setupProgressReporting()
+	if reporter, ok := dest.(private.LayerApplyProgressReporter); ok && c.reportProgress != nil {
+		reporter.SetLayerApplyProgress(func(progress types.ProgressProperties) {
+			progress.OperationID = c.operationID
+			c.reportProgress(progress)
+		}, c.progressInterval)
+	}
+	defer c.close(ctx)
 	c.blobInfoCache.Open()
 	defer c.blobInfoCache.Close()
+	c.recordBaseImageLayers(ctx)
 
 	// Set the concurrentBlobCopiesSemaphore if we can copy layers in parallel.
 	if dest.HasThreadSafePutBlob() && rawSource.HasThreadSafeGetBlob() {
@@ -274,12 +491,19 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 		return nil, err
 	}
 
-	multiImage, err := isMultiImage(ctx, c.unparsedToplevel)
+	resolvePhase := c.startPhase(PhaseResolve, types.BlobInfo{})
+	resolveCtx, finishResolveTimeout := withPhaseTimeout(ctx, c.options.PhaseTimeouts.ManifestResolution, "manifest resolution")
+	multiImage, err := isMultiImage(resolveCtx, c.unparsedToplevel)
+	err = finishResolveTimeout(err)
+	resolvePhase.finish(-1, err)
 	if err != nil {
 		return nil, fmt.Errorf("determining manifest MIME type for %s: %w", transports.ImageName(srcRef), err)
 	}
 
 	if !multiImage {
+		if options.PreserveListDigest {
+			return nil, fmt.Errorf("PreserveListDigest is set, but %s is not a list of images", transports.ImageName(srcRef))
+		}
 		if len(options.EnsureCompressionVariantsExist) > 0 {
 			return nil, fmt.Errorf("EnsureCompressionVariantsExist is not implemented when not creating a multi-architecture image")
 		}
@@ -315,7 +539,7 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 		if err != nil {
 			return nil, fmt.Errorf("choosing an image from manifest list %s: %w", transports.ImageName(srcRef), err)
 		}
-		logrus.Debugf("Source is a manifest list; copying (only) instance %s for current system", instanceDigest)
+		log.DebugfContext(ctx, "Source is a manifest list; copying (only) instance %s for current system", instanceDigest)
 		unparsedInstance := image.UnparsedInstance(rawSource, &instanceDigest)
 		single, err := c.copySingleImage(ctx, unparsedInstance, nil, copySingleImageOptions{requireCompressionFormatMatch: requireCompressionFormatMatch})
 		if err != nil {
@@ -330,9 +554,9 @@ func Image(ctx context.Context, policyContext *signature.PolicyContext, destRef,
 		// Copy some or all of the images.
 		switch c.options.ImageListSelection {
 		case CopyAllImages:
-			logrus.Debugf("Source is a manifest list; copying all instances")
+			log.DebugfContext(ctx, "Source is a manifest list; copying all instances")
 		case CopySpecificImages:
-			logrus.Debugf("Source is a manifest list; copying some instances")
+			log.DebugfContext(ctx, "Source is a manifest list; copying some instances")
 		}
 		if copiedManifest, err = c.copyMultipleImages(ctx); err != nil {
 			return nil, err
@@ -356,14 +580,23 @@ func (c *copier) Printf(format string, a ...any) {
 }
 
 // close tears down state owned by copier.
-func (c *copier) close() {
+func (c *copier) close(ctx context.Context) {
 	for i, s := range c.signersToClose {
 		if err := s.Close(); err != nil {
-			logrus.Warnf("Error closing per-copy signer %d: %v", i+1, err)
+			log.WarnfContext(ctx, "Error closing per-copy signer %d: %v", i+1, err)
 		}
 	}
 }
 
+// newOperationID returns a new random ID for a copy operation.
+func newOperationID() (string, error) {
+	randBytes := make([]byte, 8)
+	if _, err := rand.Read(randBytes); err != nil {
+		return "", fmt.Errorf("generating copy operation ID: %w", err)
+	}
+	return fmt.Sprintf("%016x", randBytes), nil
+}
+
 // validateImageListSelection returns an error if the passed-in value is not one that we recognize as a valid ImageListSelection value
 func validateImageListSelection(selection ImageListSelection) error {
 	switch selection {
@@ -374,6 +607,36 @@ func validateImageListSelection(selection ImageListSelection) error {
 	}
 }
 
+// validateImageListSigning returns an error if the passed-in value is not one that we recognize as a valid ImageListSigning value
+func validateImageListSigning(signing ImageListSigning) error {
+	switch signing {
+	case SignListAndInstances, SignListOnly, SignInstancesOnly:
+		return nil
+	default:
+		return fmt.Errorf("Invalid value for options.ImageListSigning: %d", signing)
+	}
+}
+
+// validatePreserveListDigest returns an error if options.PreserveListDigest is set together with options which can’t preserve the list digest.
+func validatePreserveListDigest(options *Options) error {
+	if !options.PreserveListDigest {
+		return nil
+	}
+	if options.ImageListSelection == CopySystemImage {
+		return errors.New("PreserveListDigest requires options.ImageListSelection to be CopyAllImages or CopySpecificImages")
+	}
+	if len(options.EnsureCompressionVariantsExist) > 0 {
+		return errors.New("PreserveListDigest can not be used together with EnsureCompressionVariantsExist")
+	}
+	if options.SkipUnavailableInstances {
+		return errors.New("PreserveListDigest can not be used together with SkipUnavailableInstances")
+	}
+	if options.SkipFailedInstances {
+		return errors.New("PreserveListDigest can not be used together with SkipFailedInstances")
+	}
+	return nil
+}
+
 // Checks if the destination supports accepting multiple images by checking if it can support
 // manifest types that are lists of other manifests.
 func supportsMultipleImages(dest types.ImageDestination) bool {
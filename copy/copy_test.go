@@ -0,0 +1,878 @@
+package copy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/directory"
+	internalblobinfocache "github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/pkg/faultinject"
+	"github.com/containers/image/v5/pkg/logging"
+	"github.com/containers/image/v5/pkg/metrics"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// createTestImage creates a dir: image with a config and one layer, and returns its reference, the config and the layer.
+func createTestImage(t *testing.T) (types.ImageReference, []byte, []byte) {
+	return createTestImageWithConfig(t, []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`))
+}
+
+// createTestImageWithConfig is createTestImage with a caller-provided config.
+func createTestImageWithConfig(t *testing.T, config []byte) (types.ImageReference, []byte, []byte) {
+	layer, err := os.ReadFile("fixtures/Hello.gz")
+	require.NoError(t, err)
+	return createTestImageWithConfigAndLayer(t, config, layer), config, layer
+}
+
+// createTestImageWithConfigAndLayer creates a dir: image with the specified config and a single gzip-compressed layer, and returns its reference.
+func createTestImageWithConfigAndLayer(t *testing.T, config, layer []byte) types.ImageReference {
+	ref, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	for _, blob := range [][]byte{config, layer} {
+		_, err := dest.PutBlob(context.Background(), bytes.NewReader(blob), types.BlobInfo{Size: -1}, memory.New(), false)
+		require.NoError(t, err)
+	}
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `",` +
+		`"config":{"mediaType":"` + imgspecv1.MediaTypeImageConfig + `","digest":"` + digest.FromBytes(config).String() + `","size":` + strconv.Itoa(len(config)) + `},` +
+		`"layers":[{"mediaType":"` + imgspecv1.MediaTypeImageLayerGzip + `","digest":"` + digest.FromBytes(layer).String() + `","size":` + strconv.Itoa(len(layer)) + `}]}`)
+	err = dest.PutManifest(context.Background(), manifest, nil)
+	require.NoError(t, err)
+	err = dest.Commit(context.Background(), nil)
+	require.NoError(t, err)
+	return ref
+}
+
+// acceptAnythingPolicyContext returns a PolicyContext which accepts any image.
+func acceptAnythingPolicyContext(t *testing.T) *signature.PolicyContext {
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = policyContext.Destroy() })
+	return policyContext
+}
+
+func TestImageTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	origProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(origProvider)
+
+	srcRef, _, _ := createTestImage(t)
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, nil)
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root, ok := spans["copy.Image"]
+	require.True(t, ok)
+	assert.False(t, root.Parent().IsValid())
+	for _, name := range []string{"copy.fetchManifest", "copy.checkPolicy", "copy.layer", "copy.config"} {
+		span, ok := spans[name]
+		require.True(t, ok, name)
+		assert.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID(), name)
+	}
+}
+
+// fakeMetricsRecorder is a metrics.Recorder which accumulates all values.
+type fakeMetricsRecorder struct {
+	mutex      sync.Mutex
+	counters   map[string]float64 // Indexed by name and labels
+	histograms map[string]int     // The number of observations, indexed by name and labels
+}
+
+func metricKey(name string, labels map[string]string) string {
+	return fmt.Sprintf("%s%v", name, labels)
+}
+
+func (r *fakeMetricsRecorder) AddCounter(name string, value float64, labels map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.counters[metricKey(name, labels)] += value
+}
+
+func (r *fakeMetricsRecorder) ObserveHistogram(name string, value float64, labels map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.histograms[metricKey(name, labels)]++
+}
+
+func TestImageMetrics(t *testing.T) {
+	srcRef, config, layer := createTestImage(t)
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	recorder := &fakeMetricsRecorder{counters: map[string]float64{}, histograms: map[string]int{}}
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{MetricsRecorder: recorder})
+	require.NoError(t, err)
+
+	layerLabels := map[string]string{metrics.LabelBlobKind: metrics.BlobKindLayer}
+	configLabels := map[string]string{metrics.LabelBlobKind: metrics.BlobKindConfig}
+	assert.Equal(t, map[string]float64{
+		metricKey(metrics.BlobBytesTotal, layerLabels):                                                float64(len(layer)),
+		metricKey(metrics.BlobBytesTotal, configLabels):                                               float64(len(config)),
+		metricKey(metrics.BlobReuseTotal, map[string]string{metrics.LabelResult: metrics.ResultMiss}): 1,
+	}, recorder.counters)
+	assert.Equal(t, map[string]int{
+		metricKey(metrics.BlobTransferDurationSeconds, layerLabels):  1,
+		metricKey(metrics.BlobTransferDurationSeconds, configLabels): 1,
+	}, recorder.histograms)
+}
+
+func TestImageOperationID(t *testing.T) {
+	srcRef, _, _ := createTestImage(t)
+
+	for _, operationID := range []string{"", "op1"} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		logOutput := bytes.Buffer{}
+		ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&logOutput, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		progress := make(chan types.ProgressProperties)
+		progressIDs := []string{}
+		progressDone := make(chan struct{})
+		go func() {
+			defer close(progressDone)
+			for p := range progress {
+				progressIDs = append(progressIDs, p.OperationID)
+			}
+		}()
+		_, err = Image(ctx, acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+			OperationID:      operationID,
+			Progress:         progress,
+			ProgressInterval: time.Millisecond,
+		})
+		require.NoError(t, err)
+		close(progress)
+		<-progressDone
+
+		loggedIDs := map[string]struct{}{}
+		decoder := json.NewDecoder(&logOutput)
+		for decoder.More() {
+			var record map[string]any
+			err := decoder.Decode(&record)
+			require.NoError(t, err)
+			id, ok := record[logging.OperationIDKey].(string)
+			require.True(t, ok, record)
+			loggedIDs[id] = struct{}{}
+		}
+		require.Len(t, loggedIDs, 1)
+		var loggedID string
+		for id := range loggedIDs {
+			loggedID = id
+		}
+		if operationID != "" {
+			assert.Equal(t, operationID, loggedID)
+		} else {
+			assert.NotEmpty(t, loggedID)
+		}
+		require.NotEmpty(t, progressIDs)
+		for _, id := range progressIDs {
+			assert.Equal(t, loggedID, id)
+		}
+	}
+}
+
+// recordingPhaseHooks is a PhaseHooks which records all events.
+type recordingPhaseHooks struct {
+	mutex    sync.Mutex
+	started  []PhaseEvent
+	finished []PhaseEvent
+}
+
+func (h *recordingPhaseHooks) PhaseStarted(event PhaseEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.started = append(h.started, event)
+}
+
+func (h *recordingPhaseHooks) PhaseFinished(event PhaseEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.finished = append(h.finished, event)
+}
+
+func TestImagePhaseHooks(t *testing.T) {
+	srcRef, config, layer := createTestImage(t)
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	hooks := &recordingPhaseHooks{}
+	manifest, err := Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		OperationID:    "op1",
+		PhaseHooks:     hooks,
+		DestinationCtx: &types.SystemContext{DirForceDecompress: true},
+	})
+	require.NoError(t, err)
+
+	uncompressedLayer, err := os.ReadFile("fixtures/Hello.uncompressed")
+	require.NoError(t, err)
+	configDigest := digest.FromBytes(config)
+	layerDigest := digest.FromBytes(layer)
+	type phaseSummary struct {
+		phase Phase
+		blob  digest.Digest
+		bytes int64
+	}
+	expected := []phaseSummary{
+		{PhaseResolve, "", -1},
+		{PhaseDownload, configDigest, int64(len(config))},
+		{PhaseUpload, configDigest, int64(len(config))},
+		{PhaseDownload, layerDigest, int64(len(layer))},
+		{PhaseDecompress, layerDigest, int64(len(uncompressedLayer))},
+		{PhaseUpload, layerDigest, int64(len(uncompressedLayer))},
+		{PhaseManifestPush, "", int64(len(manifest))},
+	}
+	summaries := []phaseSummary{}
+	for _, e := range hooks.finished {
+		assert.Equal(t, "op1", e.OperationID)
+		assert.NoError(t, e.Err)
+		summaries = append(summaries, phaseSummary{e.Phase, e.Blob.Digest, e.Bytes})
+	}
+	assert.ElementsMatch(t, expected, summaries)
+	require.Len(t, hooks.started, len(hooks.finished))
+	for _, e := range hooks.started {
+		assert.Equal(t, "op1", e.OperationID)
+		assert.Zero(t, e.Duration)
+	}
+}
+
+// failingWriter is an io.Writer which always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestImageProgressJSON(t *testing.T) {
+	srcRef, config, layer := createTestImage(t)
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	var out bytes.Buffer
+	progress := make(chan types.ProgressProperties, 100)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		OperationID:        "op1",
+		ProgressJSONWriter: &out,
+		// The Progress channel still works when both are set.
+		Progress:         progress,
+		ProgressInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	close(progress)
+	channelEvents := 0
+	for range progress {
+		channelEvents++
+	}
+	assert.NotZero(t, channelEvents)
+
+	events := []JSONProgressEvent{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var e JSONProgressEvent
+		err := decoder.Decode(&e)
+		require.NoError(t, err)
+		assert.Equal(t, "op1", e.OperationID)
+		assert.False(t, e.Time.IsZero())
+		assert.Empty(t, e.Error)
+		events = append(events, e)
+	}
+	require.NotEmpty(t, events)
+	assert.Equal(t, JSONProgressCopyStarted, events[0].Event)
+	assert.Equal(t, "dir:"+srcRef.StringWithinTransport(), events[0].Source)
+	assert.Equal(t, JSONProgressCopyFinished, events[len(events)-1].Event)
+
+	for _, blob := range []struct {
+		digest digest.Digest
+		size   int64
+	}{
+		{digest.FromBytes(config), int64(len(config))},
+		{digest.FromBytes(layer), int64(len(layer))},
+	} {
+		var blobEvents []JSONProgressEventType
+		var finished *JSONProgressEvent
+		for i := range events {
+			if events[i].Digest == blob.digest && events[i].Phase == "" {
+				blobEvents = append(blobEvents, events[i].Event)
+				if events[i].Event == JSONProgressBlobFinished {
+					finished = &events[i]
+				}
+			}
+		}
+		assert.Equal(t, []JSONProgressEventType{JSONProgressBlobStarted, JSONProgressBlobFinished}, blobEvents, blob.digest)
+		require.NotNil(t, finished)
+		assert.Equal(t, blob.size, finished.Bytes)
+		assert.Equal(t, blob.size, finished.Total)
+	}
+	phasesFinished := map[Phase]int{}
+	for _, e := range events {
+		if e.Event == JSONProgressPhaseFinished {
+			phasesFinished[e.Phase]++
+		}
+	}
+	assert.Equal(t, map[Phase]int{PhaseResolve: 1, PhaseDownload: 2, PhaseUpload: 2, PhaseManifestPush: 1}, phasesFinished)
+
+	// A failing writer does not fail the copy
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ProgressJSONWriter: failingWriter{},
+	})
+	assert.NoError(t, err)
+}
+
+func TestImageVerifyLayerDiffIDs(t *testing.T) {
+	layer, err := os.ReadFile("fixtures/Hello.gz")
+	require.NoError(t, err)
+	gzReader, err := gzip.NewReader(bytes.NewReader(layer))
+	require.NoError(t, err)
+	diffID, err := digest.Canonical.FromReader(gzReader)
+	require.NoError(t, err)
+	configWithDiffIDs := func(diffIDs string) []byte {
+		return []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[` + diffIDs + `]}}`)
+	}
+
+	for _, c := range []struct {
+		name, config, expectedError string
+	}{
+		{"matching", `"` + diffID.String() + `"`, ""},
+		{"mismatch", `"` + digest.FromString("tampered").String() + `"`, "but the image config expects DiffID"},
+		{"missing", ``, "image config lists 0 layer DiffIDs, but the manifest has 1 layers"},
+	} {
+		srcRef, _, _ := createTestImageWithConfig(t, configWithDiffIDs(c.config))
+		for _, verify := range []bool{false, true} {
+			destRef, err := directory.NewReference(t.TempDir())
+			require.NoError(t, err)
+			_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+				DestinationCtx:     &types.SystemContext{BlobInfoCacheDir: t.TempDir()},
+				VerifyLayerDiffIDs: verify,
+			})
+			if !verify || c.expectedError == "" {
+				assert.NoError(t, err, c.name)
+			} else {
+				assert.ErrorContains(t, err, c.expectedError, c.name)
+			}
+		}
+	}
+}
+
+func TestImageLimits(t *testing.T) {
+	srcRef, _, layer := createTestImage(t)
+	gzReader, err := gzip.NewReader(bytes.NewReader(layer))
+	require.NoError(t, err)
+	uncompressed, err := io.ReadAll(gzReader)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		name          string
+		options       Options
+		expectedLimit Limit
+	}{
+		{"no limits", Options{}, ""},
+		{"limits met", Options{MaxLayerSize: int64(len(layer)), MaxTotalUncompressedSize: int64(len(uncompressed)), MaxLayerCount: 1}, ""},
+		{"layer too large", Options{MaxLayerSize: int64(len(layer) - 1)}, LimitLayerSize},
+		{"uncompressed too large", Options{MaxTotalUncompressedSize: int64(len(uncompressed) - 1)}, LimitTotalUncompressedSize},
+	} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		options := c.options
+		options.DestinationCtx = &types.SystemContext{BlobInfoCacheDir: t.TempDir()}
+		_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &options)
+		if c.expectedLimit == "" {
+			assert.NoError(t, err, c.name)
+		} else {
+			var limitErr *LimitExceededError
+			require.ErrorAs(t, err, &limitErr, c.name)
+			assert.Equal(t, c.expectedLimit, limitErr.Limit, c.name)
+		}
+	}
+}
+
+// partialPullReference is a dir: reference which claims to support partial pulls; the destination pretends
+// that all of the data of every layer is already available, and records the digests of layers pulled that way.
+type partialPullReference struct {
+	types.ImageReference
+	pulled *[]digest.Digest
+}
+
+func (ref partialPullReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	src, err := ref.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return partialPullSource{ImageSource: src.(private.ImageSource)}, nil
+}
+
+func (ref partialPullReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	dest, err := ref.ImageReference.NewImageDestination(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return partialPullDestination{ImageDestination: dest.(private.ImageDestination), pulled: ref.pulled}, nil
+}
+
+type partialPullSource struct {
+	private.ImageSource
+}
+
+func (s partialPullSource) SupportsGetBlobAt() bool {
+	return true
+}
+
+type partialPullDestination struct {
+	private.ImageDestination
+	pulled *[]digest.Digest
+}
+
+func (d partialPullDestination) SupportsPutBlobPartial() bool {
+	return true
+}
+
+func (d partialPullDestination) PutBlobPartial(ctx context.Context, chunkAccessor private.BlobChunkAccessor, srcInfo types.BlobInfo, options private.PutBlobPartialOptions) (private.UploadedBlob, error) {
+	*d.pulled = append(*d.pulled, srcInfo.Digest)
+	return private.UploadedBlob{Digest: srcInfo.Digest, Size: srcInfo.Size}, nil
+}
+
+func TestImageLimitsPartialPull(t *testing.T) {
+	srcRef, _, layer := createTestImage(t)
+
+	// Without a limit, the layer is pulled partially.
+	pulled := []digest.Digest{}
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), partialPullReference{ImageReference: destRef, pulled: &pulled},
+		partialPullReference{ImageReference: srcRef}, &Options{DestinationCtx: &types.SystemContext{BlobInfoCacheDir: t.TempDir()}})
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{digest.FromBytes(layer)}, pulled)
+
+	// The uncompressed size of partially pulled layers can’t be counted, so MaxTotalUncompressedSize disables partial pulls.
+	pulled = []digest.Digest{}
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), partialPullReference{ImageReference: destRef, pulled: &pulled},
+		partialPullReference{ImageReference: srcRef}, &Options{
+			DestinationCtx:           &types.SystemContext{BlobInfoCacheDir: t.TempDir()},
+			MaxTotalUncompressedSize: 1,
+		})
+	var limitErr *LimitExceededError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, LimitTotalUncompressedSize, limitErr.Limit)
+	assert.Empty(t, pulled)
+}
+
+func TestImageFaultInjection(t *testing.T) {
+	srcRef, _, layer := createTestImage(t)
+	layerDigest := digest.FromBytes(layer)
+
+	for _, c := range []struct {
+		name          string
+		fault         *faultinject.Fault
+		expectedError string
+	}{
+		{"no fault", nil, ""},
+		{"corrupted", &faultinject.Fault{Corrupt: true}, layerDigest.String()},
+		{"truncated", &faultinject.Fault{Truncate: true, TruncateAfter: 10}, io.ErrUnexpectedEOF.Error()},
+		{"timeout", &faultinject.Fault{Timeout: true}, "injected timeout"},
+	} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		sourceCtx := &types.SystemContext{
+			FaultInjector: faultinject.InjectorFunc(func(ctx context.Context, point faultinject.Point, target string) *faultinject.Fault {
+				if point == faultinject.CopyBlob && target == layerDigest.String() {
+					return c.fault
+				}
+				return nil
+			}),
+		}
+		_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{SourceCtx: sourceCtx})
+		if c.expectedError == "" {
+			assert.NoError(t, err, c.name)
+		} else {
+			assert.ErrorContains(t, err, c.expectedError, c.name)
+		}
+	}
+}
+
+// recordingLayerScanner is a LayerScanner which records the scanned layers, and rejects them if reject is set.
+type recordingLayerScanner struct {
+	readAll bool  // Read the whole stream before returning
+	reject  error // Returned by ScanLayer
+
+	mutex    sync.Mutex
+	layers   []LayerScanInfo
+	contents [][]byte
+}
+
+func (s *recordingLayerScanner) ScanLayer(ctx context.Context, layer LayerScanInfo, stream io.Reader) error {
+	var contents []byte
+	if s.readAll {
+		c, err := io.ReadAll(stream)
+		if err != nil {
+			return err
+		}
+		contents = c
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.layers = append(s.layers, layer)
+	s.contents = append(s.contents, contents)
+	return s.reject
+}
+
+func TestImageLayerScanner(t *testing.T) {
+	srcRef, _, layer := createTestImage(t)
+	gzReader, err := gzip.NewReader(bytes.NewReader(layer))
+	require.NoError(t, err)
+	uncompressed, err := io.ReadAll(gzReader)
+	require.NoError(t, err)
+	rejection := errors.New("malware found")
+
+	for _, c := range []struct {
+		name    string
+		readAll bool
+		reject  error
+	}{
+		{"accepted", true, nil},
+		{"accepted without reading", false, nil},
+		{"rejected", true, rejection},
+		{"rejected without reading", false, rejection},
+	} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		// Copy twice, to make sure layers which already exist at the destination are scanned as well.
+		for i := 0; i < 2; i++ {
+			scanner := &recordingLayerScanner{readAll: c.readAll, reject: c.reject}
+			copiedManifest, err := Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+				DestinationCtx: &types.SystemContext{BlobInfoCacheDir: t.TempDir()},
+				LayerScanner:   scanner,
+			})
+			require.Len(t, scanner.layers, 1, c.name)
+			assert.Equal(t, srcRef.StringWithinTransport(), scanner.layers[0].Source.StringWithinTransport(), c.name)
+			assert.Equal(t, 0, scanner.layers[0].LayerIndex, c.name)
+			assert.Equal(t, digest.FromBytes(layer), scanner.layers[0].Blob.Digest, c.name)
+			if c.readAll {
+				assert.Equal(t, uncompressed, scanner.contents[0], c.name)
+			}
+			if c.reject == nil {
+				require.NoError(t, err, c.name)
+				assert.Equal(t, digest.FromBytes(copiedManifest), scanner.layers[0].ManifestDigest, c.name)
+			} else {
+				var rejected *LayerRejectedError
+				require.ErrorAs(t, err, &rejected, c.name)
+				assert.Equal(t, 0, rejected.LayerIndex, c.name)
+				assert.Equal(t, digest.FromBytes(layer), rejected.Digest, c.name)
+				assert.ErrorIs(t, err, rejection, c.name)
+				_, err := os.Stat(destRef.StringWithinTransport() + "/manifest.json")
+				assert.ErrorIs(t, err, os.ErrNotExist, c.name)
+			}
+		}
+	}
+}
+
+func TestImageLocalBlobDigestCache(t *testing.T) {
+	srcRef, _, layer := createTestImage(t)
+	layerInfo := types.BlobInfo{Digest: digest.FromBytes(layer), Size: int64(len(layer))}
+	sys := &types.SystemContext{LocalBlobDigestCache: true}
+	// layerVerified returns true if the source has recorded a verification of the layer.
+	layerVerified := func() bool {
+		src, err := srcRef.NewImageSource(context.Background(), sys)
+		require.NoError(t, err)
+		defer src.Close()
+		reader, _, err := src.GetBlob(context.Background(), layerInfo, memory.New())
+		require.NoError(t, err)
+		defer reader.Close()
+		verified, ok := reader.(private.VerifiedBlobReader)
+		require.True(t, ok)
+		return verified.DigestVerified()
+	}
+
+	for _, c := range []struct {
+		sourceCtx *types.SystemContext
+		verified  bool
+	}{
+		{nil, false},
+		{sys, true},
+		{sys, true}, // Copying again uses, and keeps, the recorded verification
+	} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+			SourceCtx:      c.sourceCtx,
+			DestinationCtx: &types.SystemContext{BlobInfoCacheDir: t.TempDir()},
+		})
+		require.NoError(t, err)
+		copied, err := os.ReadFile(filepath.Join(destRef.StringWithinTransport(), layerInfo.Digest.Encoded()))
+		require.NoError(t, err)
+		assert.Equal(t, layer, copied)
+		if c.verified && !layerVerified() {
+			t.Skip("Extended attributes are not supported")
+		}
+		assert.Equal(t, c.verified, layerVerified())
+	}
+}
+
+// earlyReturnReference is a dir: reference; uploads of layers to it fail early, while the layer is still being read
+// in the background, and the source then stalls reading layers, so that the reads overlap with cleanup after the failure.
+type earlyReturnReference struct {
+	types.ImageReference
+	aborted chan struct{} // Closed when a layer upload has failed
+}
+
+func (ref earlyReturnReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	src, err := ref.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return earlyReturnSource{ImageSource: src.(private.ImageSource), aborted: ref.aborted}, nil
+}
+
+func (ref earlyReturnReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	dest, err := ref.ImageReference.NewImageDestination(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return earlyReturnDestination{ImageDestination: dest.(private.ImageDestination), aborted: ref.aborted}, nil
+}
+
+type earlyReturnSource struct {
+	private.ImageSource
+	aborted chan struct{}
+}
+
+func (s earlyReturnSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	rc, size, err := s.ImageSource.GetBlob(ctx, info, cache)
+	if err != nil {
+		return nil, 0, err
+	}
+	return stallingReader{ReadCloser: rc, aborted: s.aborted}, size, nil
+}
+
+// stallingReader delays reads after aborted is closed.
+type stallingReader struct {
+	io.ReadCloser
+	aborted chan struct{}
+}
+
+func (r stallingReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.aborted:
+		time.Sleep(100 * time.Millisecond)
+	default:
+	}
+	return r.ReadCloser.Read(p)
+}
+
+type earlyReturnDestination struct {
+	private.ImageDestination
+	aborted chan struct{}
+}
+
+func (d earlyReturnDestination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	if options.IsConfig {
+		return d.ImageDestination.PutBlobWithOptions(ctx, stream, inputInfo, options)
+	}
+	// Continue reading in the background, like a HTTP transport still sending a request body after the server has responded.
+	started := make(chan struct{})
+	go func() {
+		_, _ = stream.Read(make([]byte, 1))
+		close(started)
+		_, _ = io.Copy(io.Discard, stream)
+	}()
+	<-started
+	close(d.aborted)
+	return private.UploadedBlob{}, errors.New("upload aborted")
+}
+
+func TestImagePipelinedDigestingEarlyReturn(t *testing.T) {
+	var layer bytes.Buffer
+	gz := gzip.NewWriter(&layer)
+	for i := 0; i < 1<<16; i++ {
+		_, err := fmt.Fprintf(gz, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, gz.Close())
+	srcRef := createTestImageWithConfigAndLayer(t, []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`), layer.Bytes())
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+
+	aborted := make(chan struct{})
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t),
+		earlyReturnReference{ImageReference: destRef, aborted: aborted}, earlyReturnReference{ImageReference: srcRef, aborted: aborted}, &Options{
+			DestinationCtx:         &types.SystemContext{CompressionFormat: &compression.Zstd},
+			ForceCompressionFormat: true,
+			PipelinedDigesting:     true,
+		})
+	assert.ErrorContains(t, err, "upload aborted")
+	time.Sleep(200 * time.Millisecond) // Let the compression goroutine finish reading, to detect any failures.
+}
+
+// locationRecordingReference is a reference to an image, the source of which records the digests passed to RecordBlobLocations.
+type locationRecordingReference struct {
+	types.ImageReference
+	recorded *[]digest.Digest
+}
+
+func (ref locationRecordingReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	src, err := ref.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return locationRecordingSource{ImageSource: src.(private.ImageSource), recorded: ref.recorded}, nil
+}
+
+type locationRecordingSource struct {
+	private.ImageSource
+	recorded *[]digest.Digest
+}
+
+func (s locationRecordingSource) RecordBlobLocations(ctx context.Context, digests []digest.Digest, cache internalblobinfocache.BlobInfoCache2) {
+	*s.recorded = append(*s.recorded, digests...)
+}
+
+func TestImageBaseImages(t *testing.T) {
+	srcRef, _, _ := createTestImage(t)
+	baseRef, _, baseLayer := createTestImageWithConfig(t, []byte(`{"architecture":"arm64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`))
+	baseListRef, _, _ := createTestImageList(t) // Contains a missing instance
+	missingRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+
+	recorded := []digest.Digest{}
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		BaseImages: []types.ImageReference{
+			locationRecordingReference{ImageReference: missingRef, recorded: &recorded},
+			locationRecordingReference{ImageReference: baseListRef, recorded: &recorded},
+			locationRecordingReference{ImageReference: baseRef, recorded: &recorded},
+			baseRef, // dir: can’t record blob locations
+		},
+	})
+	require.NoError(t, err)
+	// Base images which can’t be read are ignored.
+	assert.Equal(t, []digest.Digest{digest.FromBytes(baseLayer)}, recorded)
+}
+
+// stagingSpaceReference is a dir: reference, the destination of which reports insufficient staging space
+// for more than available bytes, and records the sizes it was asked about.
+type stagingSpaceReference struct {
+	types.ImageReference
+	available int64
+	checked   *[]int64
+}
+
+func (ref stagingSpaceReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	dest, err := ref.ImageReference.NewImageDestination(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return stagingSpaceDestination{ImageDestination: dest.(private.ImageDestination), available: ref.available, checked: ref.checked}, nil
+}
+
+type stagingSpaceDestination struct {
+	private.ImageDestination
+	available int64
+	checked   *[]int64
+}
+
+func (d stagingSpaceDestination) CheckStagingSpace(ctx context.Context, size int64) error {
+	*d.checked = append(*d.checked, size)
+	if size > d.available {
+		return types.InsufficientSpaceError{Path: "/staging", Required: size, Available: d.available}
+	}
+	return nil
+}
+
+func TestImageCheckStagingSpace(t *testing.T) {
+	srcRef, config, layer := createTestImage(t)
+	required := int64(len(config) + len(layer))
+
+	for _, available := range []int64{required, required - 1} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		checked := []int64{}
+		_, err = Image(context.Background(), acceptAnythingPolicyContext(t),
+			stagingSpaceReference{ImageReference: destRef, available: available, checked: &checked}, srcRef, nil)
+		assert.Equal(t, []int64{required}, checked)
+		if available >= required {
+			require.NoError(t, err)
+		} else {
+			var spaceErr types.InsufficientSpaceError
+			require.ErrorAs(t, err, &spaceErr)
+			assert.Equal(t, required, spaceErr.Required)
+			// No layers were copied.
+			_, err = os.Stat(filepath.Join(destRef.StringWithinTransport(), digest.FromBytes(layer).Encoded()))
+			assert.True(t, errors.Is(err, os.ErrNotExist))
+		}
+	}
+}
+
+func TestImageConfigRedaction(t *testing.T) {
+	srcRef, _, _ := createTestImageWithConfig(t, []byte(`{"architecture":"amd64","os":"linux",`+
+		`"config":{"Env":["PATH=/usr/bin","API_TOKEN=abc"]},`+
+		`"history":[{"created_by":"|1 API_TOKEN=abc /bin/sh -c make"}],`+
+		`"rootfs":{"type":"layers","diff_ids":[]}}`))
+
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	manifestBlob, err := Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ConfigRedaction: &ConfigRedaction{NamePatterns: []string{"*_TOKEN"}},
+	})
+	require.NoError(t, err)
+	var m imgspecv1.Manifest
+	err = json.Unmarshal(manifestBlob, &m)
+	require.NoError(t, err)
+	config, err := os.ReadFile(filepath.Join(destRef.StringWithinTransport(), m.Config.Digest.Encoded()))
+	require.NoError(t, err)
+	assert.Equal(t, m.Config.Digest, digest.FromBytes(config))
+	assert.Equal(t, int64(len(config)), m.Config.Size)
+	assert.Contains(t, string(config), "PATH=/usr/bin")
+	assert.Contains(t, string(config), "API_TOKEN=<redacted>")
+	assert.NotContains(t, string(config), "abc")
+
+	// The redaction can't be done if the manifest must not be modified.
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ConfigRedaction: &ConfigRedaction{NamePatterns: []string{"*_TOKEN"}},
+		PreserveDigests: true,
+	})
+	assert.ErrorContains(t, err, "Redacting the image config would change the manifest")
+	// … unless there is nothing to redact.
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ConfigRedaction: &ConfigRedaction{NamePatterns: []string{"UNUSED"}},
+		PreserveDigests: true,
+	})
+	assert.NoError(t, err)
+
+	// Invalid patterns are rejected.
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ConfigRedaction: &ConfigRedaction{NamePatterns: []string{"[INVALID"}},
+	})
+	assert.Error(t, err)
+}
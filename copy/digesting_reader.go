@@ -1,13 +1,18 @@
 package copy
 
 import (
+	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"sync"
 
 	digest "github.com/opencontainers/go-digest"
 )
 
+// digestPipelineDepth is the number of chunks a pipelined digestingReader may read ahead of the hashing goroutine.
+const digestPipelineDepth = 16
+
 type digestingReader struct {
 	source              io.Reader
 	digester            digest.Digester
@@ -15,12 +20,31 @@ type digestingReader struct {
 	expectedDigest      digest.Digest
 	validationFailed    bool
 	validationSucceeded bool
+	validationErr       error           // The error returned at EOF (io.EOF or a digest mismatch), once validation has finished
+	pipeline            *digestPipeline // nil if hashing synchronously in Read
+	trusted             bool            // The source has already verified the data, so it is not hashed again
+}
+
+// digestPipeline is the state of a hashing goroutine used by a pipelined digestingReader.
+type digestPipeline struct {
+	chunks chan []byte   // Data to hash, in order; closed when there will be no more data.
+	free   chan []byte   // Chunks which have been hashed, and can be reused.
+	done   chan struct{} // Closed when the hashing goroutine has consumed all of chunks.
+
+	// Read may be called from a different goroutine than close, e.g. by compressGoroutine,
+	// which can continue reading for a while after the consumer of the blob pipeline has returned.
+	mutex  sync.Mutex // Protects closed, and sending to chunks.
+	closed bool       // chunks has been closed.
 }
 
 // newDigestingReader returns an io.Reader implementation with contents of source, which will eventually return a non-EOF error
 // or set validationSucceeded/validationFailed to true if the source stream does/does not match expectedDigest.
 // (neither is set if EOF is never reached).
-func newDigestingReader(source io.Reader, expectedDigest digest.Digest) (*digestingReader, error) {
+//
+// If pipelined, the digest is computed in a separate goroutine, concurrently with the consumer of the returned reader
+// processing the data; this costs an extra copy of the data, but the computed digest is the same.
+// The caller must call close() when done with the returned reader.
+func newDigestingReader(source io.Reader, expectedDigest digest.Digest, pipelined bool) (*digestingReader, error) {
 	var digester digest.Digester
 	if err := expectedDigest.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid digest specification %q: %w", expectedDigest, err)
@@ -31,19 +55,98 @@ func newDigestingReader(source io.Reader, expectedDigest digest.Digest) (*digest
 	}
 	digester = digestAlgorithm.Digester()
 
-	return &digestingReader{
+	res := &digestingReader{
 		source:           source,
 		digester:         digester,
 		hash:             digester.Hash(),
 		expectedDigest:   expectedDigest,
 		validationFailed: false,
-	}, nil
+	}
+	if pipelined {
+		res.pipeline = &digestPipeline{
+			chunks: make(chan []byte, digestPipelineDepth),
+			free:   make(chan []byte, digestPipelineDepth+1),
+			done:   make(chan struct{}),
+		}
+		go res.hashChunks()
+	}
+	return res, nil
+}
+
+// newTrustedDigestingReader returns a digestingReader for source, which is known to match expectedDigest
+// (e.g. because it was verified by a previous copy); the data is not hashed, and validationSucceeded is set at EOF.
+func newTrustedDigestingReader(source io.Reader, expectedDigest digest.Digest) *digestingReader {
+	return &digestingReader{
+		source:         source,
+		expectedDigest: expectedDigest,
+		trusted:        true,
+	}
+}
+
+// hashChunks is the hashing goroutine of a pipelined digestingReader.
+func (d *digestingReader) hashChunks() {
+	defer close(d.pipeline.done)
+	for chunk := range d.pipeline.chunks {
+		_, _ = d.hash.Write(chunk) // The hash.Hash interface requires Write to never return an error.
+		select {
+		case d.pipeline.free <- chunk:
+		default: // Enough chunks are available for reuse already, let this one be garbage-collected.
+		}
+	}
 }
 
+// send passes a copy of data to the hashing goroutine of a pipelined digestingReader.
+// It returns false if the pipeline has already been closed.
+func (d *digestingReader) send(data []byte) bool {
+	d.pipeline.mutex.Lock()
+	defer d.pipeline.mutex.Unlock()
+	if d.pipeline.closed {
+		return false
+	}
+	var chunk []byte
+	select {
+	case chunk = <-d.pipeline.free:
+	default:
+	}
+	d.pipeline.chunks <- append(chunk[:0], data...)
+	return true
+}
+
+// finishPipeline waits until the hashing goroutine of a pipelined digestingReader has consumed all data.
+// It returns false if the pipeline had already been closed before this call.
+func (d *digestingReader) finishPipeline() bool {
+	d.pipeline.mutex.Lock()
+	wasOpen := !d.pipeline.closed
+	if wasOpen {
+		close(d.pipeline.chunks)
+		d.pipeline.closed = true
+	}
+	d.pipeline.mutex.Unlock()
+	<-d.pipeline.done
+	return wasOpen
+}
+
+// errReadAfterClose is returned by a pipelined digestingReader if Read is called after close, before reaching EOF.
+var errReadAfterClose = errors.New("internal error: digestingReader.Read called after close")
+
 func (d *digestingReader) Read(p []byte) (int, error) {
+	if d.validationErr != nil {
+		// The data has already been validated, and a pipeline has been finished; just repeat the result.
+		return 0, d.validationErr
+	}
 	n, err := d.source.Read(p)
+	if d.trusted {
+		if err == io.EOF {
+			d.validationSucceeded = true
+		}
+		return n, err
+	}
 	if n > 0 {
-		if n2, err := d.hash.Write(p[:n]); n2 != n || err != nil {
+		if d.pipeline != nil {
+			if !d.send(p[:n]) {
+				return 0, errReadAfterClose
+			}
+		} else if n2, err := d.hash.Write(p[:n]); n2 != n || err != nil {
 			// Coverage: This should not happen, the hash.Hash interface requires
 			// d.digest.Write to never return an error, and the io.Writer interface
 			// requires n2 == len(input) if no error is returned.
@@ -51,12 +154,25 @@ func (d *digestingReader) Read(p []byte) (int, error) {
 		}
 	}
 	if err == io.EOF {
+		if d.pipeline != nil && !d.finishPipeline() {
+			return 0, errReadAfterClose
+		}
 		actualDigest := d.digester.Digest()
 		if actualDigest != d.expectedDigest {
 			d.validationFailed = true
-			return 0, fmt.Errorf("Digest did not match, expected %s, got %s", d.expectedDigest, actualDigest)
+			d.validationErr = fmt.Errorf("Digest did not match, expected %s, got %s", d.expectedDigest, actualDigest)
+			return 0, d.validationErr
 		}
 		d.validationSucceeded = true
+		d.validationErr = io.EOF
 	}
 	return n, err
 }
+
+// close releases resources associated with d.
+// For a pipelined reader, it may be called concurrently with Read; Read calls which send data after close fail.
+func (d *digestingReader) close() {
+	if d.pipeline != nil {
+		d.finishPipeline()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"testing"
+	"testing/iotest"
 
 	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
@@ -21,7 +22,7 @@ func TestNewDigestingReader(t *testing.T) {
 		"sha256:0",        // Invalid hex value
 		"sha256:01",       // Invalid length of hex value
 	} {
-		_, err := newDigestingReader(source, input)
+		_, err := newDigestingReader(source, input, false)
 		assert.Error(t, err, input.String())
 	}
 }
@@ -35,43 +36,110 @@ func TestDigestingReaderRead(t *testing.T) {
 		{[]byte("abc"), "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
 		{make([]byte, 65537), "sha256:3266304f31be278d06c3bd3eb9aa3e00c59bedec0a890de466568b0b90b0e01f"},
 	}
-	// Valid input
-	for _, c := range cases {
-		source := bytes.NewReader(c.input)
-		reader, err := newDigestingReader(source, c.digest)
-		require.NoError(t, err, c.digest.String())
-		dest := bytes.Buffer{}
-		n, err := io.Copy(&dest, reader)
-		assert.NoError(t, err, c.digest.String())
-		assert.Equal(t, int64(len(c.input)), n, c.digest.String())
-		assert.Equal(t, c.input, dest.Bytes(), c.digest.String())
-		assert.False(t, reader.validationFailed, c.digest.String())
-		assert.True(t, reader.validationSucceeded, c.digest.String())
-	}
-	// Modified input
-	for _, c := range cases {
-		source := bytes.NewReader(bytes.Join([][]byte{c.input, []byte("x")}, nil))
-		reader, err := newDigestingReader(source, c.digest)
-		require.NoError(t, err, c.digest.String())
-		dest := bytes.Buffer{}
-		_, err = io.Copy(&dest, reader)
-		assert.Error(t, err, c.digest.String())
-		assert.True(t, reader.validationFailed, c.digest.String())
-		assert.False(t, reader.validationSucceeded, c.digest.String())
-	}
-	// Truncated input
-	for _, c := range cases {
-		source := bytes.NewReader(c.input)
-		reader, err := newDigestingReader(source, c.digest)
-		require.NoError(t, err, c.digest.String())
-		if len(c.input) != 0 {
+	for _, pipelined := range []bool{false, true} {
+		// Valid input
+		for _, c := range cases {
+			source := bytes.NewReader(c.input)
+			reader, err := newDigestingReader(source, c.digest, pipelined)
+			require.NoError(t, err, c.digest.String())
 			dest := bytes.Buffer{}
-			truncatedLen := int64(len(c.input) - 1)
-			n, err := io.CopyN(&dest, reader, truncatedLen)
+			n, err := io.Copy(&dest, reader)
 			assert.NoError(t, err, c.digest.String())
-			assert.Equal(t, truncatedLen, n, c.digest.String())
+			assert.Equal(t, int64(len(c.input)), n, c.digest.String())
+			assert.Equal(t, c.input, dest.Bytes(), c.digest.String())
+			assert.False(t, reader.validationFailed, c.digest.String())
+			assert.True(t, reader.validationSucceeded, c.digest.String())
+			// Reads after EOF consistently return EOF, even after close.
+			for i := 0; i < 2; i++ {
+				n2, err := reader.Read(make([]byte, 1))
+				assert.Equal(t, 0, n2, c.digest.String())
+				assert.Equal(t, io.EOF, err, c.digest.String())
+				reader.close()
+			}
+		}
+		// Modified input
+		for _, c := range cases {
+			source := bytes.NewReader(bytes.Join([][]byte{c.input, []byte("x")}, nil))
+			reader, err := newDigestingReader(source, c.digest, pipelined)
+			require.NoError(t, err, c.digest.String())
+			dest := bytes.Buffer{}
+			_, err = io.Copy(&dest, reader)
+			assert.Error(t, err, c.digest.String())
+			assert.True(t, reader.validationFailed, c.digest.String())
+			assert.False(t, reader.validationSucceeded, c.digest.String())
+			reader.close()
+			_, err2 := reader.Read(make([]byte, 1))
+			assert.Equal(t, err, err2, c.digest.String())
 		}
-		assert.False(t, reader.validationFailed, c.digest.String())
-		assert.False(t, reader.validationSucceeded, c.digest.String())
+		// Truncated input
+		for _, c := range cases {
+			source := bytes.NewReader(c.input)
+			reader, err := newDigestingReader(source, c.digest, pipelined)
+			require.NoError(t, err, c.digest.String())
+			if len(c.input) != 0 {
+				dest := bytes.Buffer{}
+				truncatedLen := int64(len(c.input) - 1)
+				n, err := io.CopyN(&dest, reader, truncatedLen)
+				assert.NoError(t, err, c.digest.String())
+				assert.Equal(t, truncatedLen, n, c.digest.String())
+			}
+			assert.False(t, reader.validationFailed, c.digest.String())
+			assert.False(t, reader.validationSucceeded, c.digest.String())
+			reader.close()
+		}
+	}
+}
+
+func TestDigestingReaderPipelinedManyChunks(t *testing.T) {
+	input := make([]byte, 1<<20)
+	for i := range input {
+		input[i] = byte(i * 7)
 	}
+	// iotest.HalfReader causes many reads of varying sizes, so that chunks are queued and reused.
+	reader, err := newDigestingReader(iotest.HalfReader(bytes.NewReader(input)), digest.FromBytes(input), true)
+	require.NoError(t, err)
+	defer reader.close()
+	dest := bytes.Buffer{}
+	_, err = io.Copy(&dest, reader)
+	require.NoError(t, err)
+	assert.Equal(t, input, dest.Bytes())
+	assert.True(t, reader.validationSucceeded)
+}
+
+// infiniteReader is an io.Reader which never runs out of data.
+type infiniteReader struct{}
+
+func (infiniteReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestDigestingReaderPipelinedCloseDuringRead(t *testing.T) {
+	reader, err := newDigestingReader(infiniteReader{}, digest.FromString("unused"), true)
+	require.NoError(t, err)
+	readErr := make(chan error, 1)
+	go func() { // As if in compressGoroutine
+		_, err := io.Copy(io.Discard, reader)
+		readErr <- err
+	}()
+	reader.close()
+	assert.ErrorIs(t, <-readErr, errReadAfterClose)
+	_, err = reader.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, errReadAfterClose)
+}
+
+func TestTrustedDigestingReader(t *testing.T) {
+	input := []byte("abc")
+	// The data is not hashed, so a mismatching digest is not detected.
+	reader := newTrustedDigestingReader(bytes.NewReader(input), digest.FromString("something else"))
+	defer reader.close()
+	dest := bytes.Buffer{}
+	_, err := io.CopyN(&dest, reader, 2)
+	require.NoError(t, err)
+	assert.False(t, reader.validationSucceeded)
+	_, err = io.Copy(&dest, reader)
+	require.NoError(t, err)
+	assert.Equal(t, input, dest.Bytes())
+	assert.False(t, reader.validationFailed)
+	assert.True(t, reader.validationSucceeded)
 }
@@ -6,8 +6,12 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/containers/image/v5/internal/set"
+	_ "github.com/containers/image/v5/pkg/agecrypt" // Register the age key wrapper with ocicrypt
+	_ "github.com/containers/image/v5/pkg/kmscrypt" // Register the KMS key wrapper with ocicrypt
 	"github.com/containers/image/v5/types"
 	"github.com/containers/ocicrypt"
+	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -17,6 +21,9 @@ func isOciEncrypted(mediatype string) bool {
 	return strings.HasSuffix(mediatype, "+encrypted")
 }
 
+// encryptedKeysAnnotationPrefix is the prefix of annotations containing wrapped layer keys, one annotation per ocicrypt key wrapper.
+const encryptedKeysAnnotationPrefix = "org.opencontainers.image.enc.keys."
+
 // isEncrypted checks if an image is encrypted
 func isEncrypted(i types.Image) bool {
 	layers := i.LayerInfos()
@@ -25,16 +32,108 @@ func isEncrypted(i types.Image) bool {
 	})
 }
 
+// EncryptLayerPolicy selects the layers of an image to encrypt, for Options.OciEncryptLayerPolicy.
+type EncryptLayerPolicy interface {
+	// LayersToEncrypt returns the indices of layers (0 = the base layer) which should be encrypted.
+	// With manifest lists, it is called separately for every copied instance.
+	LayersToEncrypt(layers []types.BlobInfo) ([]int, error)
+}
+
+// encryptLayersAfterPolicy implements EncryptLayersAfter.
+type encryptLayersAfterPolicy struct {
+	baseTopLayers []digest.Digest
+}
+
+// EncryptLayersAfter returns a policy which encrypts the layers above the layer with one of baseTopLayers digests,
+// typically the top layer of a base image, so that only the layers added on top of a public base image are encrypted.
+// If more than one digest is provided (e.g. the top layers of the per-architecture variants of a multi-platform base image),
+// the topmost matching layer of each image is used.
+// Copying an image which does not contain any of baseTopLayers fails.
+func EncryptLayersAfter(baseTopLayers ...digest.Digest) EncryptLayerPolicy {
+	return &encryptLayersAfterPolicy{baseTopLayers: slices.Clone(baseTopLayers)}
+}
+
+func (p *encryptLayersAfterPolicy) LayersToEncrypt(layers []types.BlobInfo) ([]int, error) {
+	for i := len(layers) - 1; i >= 0; i-- {
+		if slices.Contains(p.baseTopLayers, layers[i].Digest) {
+			res := []int{}
+			for j := i + 1; j < len(layers); j++ {
+				res = append(res, j)
+			}
+			return res, nil
+		}
+	}
+	return nil, fmt.Errorf("choosing layers to encrypt: none of the base image layers %v are present in the image", p.baseTopLayers)
+}
+
+// encryptLayersWithAnnotationPolicy implements EncryptLayersWithAnnotation.
+type encryptLayersWithAnnotationPolicy struct {
+	key, value string
+}
+
+// EncryptLayersWithAnnotation returns a policy which encrypts the layers with an annotation key set to value in the manifest.
+func EncryptLayersWithAnnotation(key, value string) EncryptLayerPolicy {
+	return &encryptLayersWithAnnotationPolicy{key: key, value: value}
+}
+
+func (p *encryptLayersWithAnnotationPolicy) LayersToEncrypt(layers []types.BlobInfo) ([]int, error) {
+	res := []int{}
+	for i, layer := range layers {
+		if v, ok := layer.Annotations[p.key]; ok && v == p.value {
+			res = append(res, i)
+		}
+	}
+	return res, nil
+}
+
+// layersToEncrypt returns the indices of srcInfos to encrypt, per ic.c.options.
+func (ic *imageCopier) layersToEncrypt(srcInfos []types.BlobInfo) (*set.Set[int], error) {
+	res := set.New[int]()
+	totalLayers := len(srcInfos)
+	switch {
+	case ic.c.options.OciEncryptLayerPolicy != nil:
+		layers, err := ic.c.options.OciEncryptLayerPolicy.LayersToEncrypt(slices.Clone(srcInfos))
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range layers {
+			if l < 0 || l >= totalLayers {
+				return nil, fmt.Errorf("when choosing layers to encrypt, layer index %d out of range (%d layers exist)", l, totalLayers)
+			}
+			res.Add(l)
+		}
+
+	case ic.c.options.OciEncryptLayers != nil:
+		for _, l := range *ic.c.options.OciEncryptLayers {
+			switch {
+			case l >= 0 && l < totalLayers:
+				res.Add(l)
+			case l < 0 && l+totalLayers >= 0: // Implies (l + totalLayers) < totalLayers
+				res.Add(l + totalLayers) // If l is negative, it is reverse indexed.
+			default:
+				return nil, fmt.Errorf("when choosing layers to encrypt, layer index %d out of range (%d layers exist)", l, totalLayers)
+			}
+		}
+
+		if len(*ic.c.options.OciEncryptLayers) == 0 { // Encrypt all layers
+			for i := 0; i < totalLayers; i++ {
+				res.Add(i)
+			}
+		}
+	}
+	return res, nil
+}
+
 // bpDecryptionStepData contains data that the copy pipeline needs about the decryption step.
 type bpDecryptionStepData struct {
 	decrypting bool // We are actually decrypting the stream
 }
 
 // blobPipelineDecryptionStep updates *stream to decrypt if, it necessary.
-// srcInfo is only used for error messages.
+// srcInfo is primarily used for error messages.
 // Returns data for other steps; the caller should eventually use updateCryptoOperation.
-func (ic *imageCopier) blobPipelineDecryptionStep(stream *sourceStream, srcInfo types.BlobInfo) (*bpDecryptionStepData, error) {
-	if !isOciEncrypted(stream.info.MediaType) || ic.c.options.OciDecryptConfig == nil {
+func (ic *imageCopier) blobPipelineDecryptionStep(stream *sourceStream, toEncrypt bool, srcInfo types.BlobInfo) (*bpDecryptionStepData, error) {
+	if !isOciEncrypted(stream.info.MediaType) || ic.c.options.OciDecryptConfig == nil || ic.canRewrapLayerKeys(srcInfo, toEncrypt) {
 		return &bpDecryptionStepData{
 			decrypting: false,
 		}, nil
@@ -74,6 +173,58 @@ func (d *bpDecryptionStepData) updateCryptoOperation(operation *types.LayerCrypt
 	}
 }
 
+// canRewrapLayerKeys returns true if a layer with srcInfo, which should be encrypted per toEncrypt, is already encrypted,
+// so that instead of decrypting and re-encrypting the layer data, we only need to rewrap its layer key for the new recipients
+// (using rewrapLayerKeys) and copy the encrypted data unmodified.
+func (ic *imageCopier) canRewrapLayerKeys(srcInfo types.BlobInfo, toEncrypt bool) bool {
+	return toEncrypt && isOciEncrypted(srcInfo.MediaType) &&
+		ic.c.options.OciDecryptConfig != nil && ic.c.options.OciEncryptConfig != nil
+}
+
+// rewrapLayerKeys returns annotations for an already-encrypted layer with srcInfo, with the layer key unwrapped
+// using ic.c.options.OciDecryptConfig and wrapped for the recipients of ic.c.options.OciEncryptConfig,
+// replacing the original recipients. The encrypted layer data does not change.
+func (ic *imageCopier) rewrapLayerKeys(srcInfo types.BlobInfo) (map[string]string, error) {
+	ec := *ic.c.options.OciEncryptConfig
+	ec.DecryptConfig = *ic.c.options.OciDecryptConfig
+	desc := imgspecv1.Descriptor{
+		MediaType:   srcInfo.MediaType,
+		Digest:      srcInfo.Digest,
+		Size:        srcInfo.Size,
+		Annotations: srcInfo.Annotations,
+	}
+	// For an already-encrypted layer, EncryptLayer does not touch the data; it unwraps the layer key using ec.DecryptConfig,
+	// and the finalizer wraps it for the recipients in ec — appending the new wrapped keys to the existing ones, separated by ",".
+	_, finalizer, err := ocicrypt.EncryptLayer(&ec, nil, desc)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping key of layer %s: %w", srcInfo.Digest, err)
+	}
+	encryptAnnotations, err := finalizer()
+	if err != nil {
+		return nil, fmt.Errorf("wrapping key of layer %s: %w", srcInfo.Digest, err)
+	}
+
+	res := maps.Clone(srcInfo.Annotations)
+	maps.DeleteFunc(res, func(k string, _ string) bool {
+		return strings.HasPrefix(k, encryptedKeysAnnotationPrefix)
+	})
+	for k, v := range encryptAnnotations {
+		if orig, ok := srcInfo.Annotations[k]; ok && strings.HasPrefix(k, encryptedKeysAnnotationPrefix) {
+			// Drop the keys wrapped for the original recipients.
+			if v == orig {
+				continue
+			}
+			newKeys, ok := strings.CutPrefix(v, orig+",")
+			if !ok {
+				return nil, fmt.Errorf("internal error: unexpected wrapped keys in %q when rewrapping key of layer %s", k, srcInfo.Digest)
+			}
+			v = newKeys
+		}
+		res[k] = v
+	}
+	return res, nil
+}
+
 // bpEncryptionStepData contains data that the copy pipeline needs about the encryption step.
 type bpEncryptionStepData struct {
 	encrypting bool // We are actually encrypting the stream
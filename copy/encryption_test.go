@@ -0,0 +1,198 @@
+package copy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/agecrypt"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/ocicrypt"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptLayersAfter(t *testing.T) {
+	layers := []types.BlobInfo{
+		{Digest: digest.Digest("sha256:1111111111111111111111111111111111111111111111111111111111111111")},
+		{Digest: digest.Digest("sha256:2222222222222222222222222222222222222222222222222222222222222222")},
+		{Digest: digest.Digest("sha256:3333333333333333333333333333333333333333333333333333333333333333")},
+		{Digest: digest.Digest("sha256:4444444444444444444444444444444444444444444444444444444444444444")},
+	}
+	for _, c := range []struct {
+		base     []digest.Digest
+		expected []int
+	}{
+		{[]digest.Digest{layers[0].Digest}, []int{1, 2, 3}},
+		{[]digest.Digest{layers[1].Digest}, []int{2, 3}},
+		{[]digest.Digest{layers[3].Digest}, []int{}},
+		{[]digest.Digest{"sha256:5555555555555555555555555555555555555555555555555555555555555555", layers[1].Digest}, []int{2, 3}},
+		{[]digest.Digest{layers[0].Digest, layers[2].Digest}, []int{3}}, // The topmost match is used
+	} {
+		res, err := EncryptLayersAfter(c.base...).LayersToEncrypt(layers)
+		require.NoError(t, err, "%v", c.base)
+		assert.Equal(t, c.expected, res, "%v", c.base)
+	}
+
+	_, err := EncryptLayersAfter("sha256:5555555555555555555555555555555555555555555555555555555555555555").LayersToEncrypt(layers)
+	assert.Error(t, err)
+	_, err = EncryptLayersAfter().LayersToEncrypt(layers)
+	assert.Error(t, err)
+}
+
+func TestEncryptLayersWithAnnotation(t *testing.T) {
+	layers := []types.BlobInfo{
+		{},
+		{Annotations: map[string]string{"encrypt": "true"}},
+		{Annotations: map[string]string{"encrypt": "false"}},
+		{Annotations: map[string]string{"other": "true"}},
+		{Annotations: map[string]string{"encrypt": "true", "other": "x"}},
+	}
+	res, err := EncryptLayersWithAnnotation("encrypt", "true").LayersToEncrypt(layers)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 4}, res)
+
+	res, err = EncryptLayersWithAnnotation("missing", "").LayersToEncrypt(layers)
+	require.NoError(t, err)
+	assert.Equal(t, []int{}, res)
+}
+
+// fixedEncryptLayerPolicy is an EncryptLayerPolicy which returns a fixed result.
+type fixedEncryptLayerPolicy []int
+
+func (p fixedEncryptLayerPolicy) LayersToEncrypt(layers []types.BlobInfo) ([]int, error) {
+	return p, nil
+}
+
+func TestImageCopierLayersToEncrypt(t *testing.T) {
+	srcInfos := make([]types.BlobInfo, 3)
+	for _, c := range []struct {
+		layers   *[]int
+		policy   EncryptLayerPolicy
+		expected []int // nil if an error is expected
+	}{
+		{nil, nil, []int{}},
+		{&[]int{}, nil, []int{0, 1, 2}},
+		{&[]int{0, -1}, nil, []int{0, 2}},
+		{&[]int{3}, nil, nil},
+		{&[]int{-4}, nil, nil},
+		{nil, fixedEncryptLayerPolicy{}, []int{}},
+		{nil, fixedEncryptLayerPolicy{1, 2, 1}, []int{1, 2}},
+		{nil, fixedEncryptLayerPolicy{3}, nil},
+		{nil, fixedEncryptLayerPolicy{-1}, nil},
+	} {
+		ic := &imageCopier{c: &copier{options: &Options{OciEncryptLayers: c.layers, OciEncryptLayerPolicy: c.policy}}}
+		res, err := ic.layersToEncrypt(srcInfos)
+		if c.expected == nil {
+			assert.Error(t, err, "%#v", c)
+		} else {
+			require.NoError(t, err, "%#v", c)
+			assert.ElementsMatch(t, c.expected, res.Values(), "%#v", c)
+		}
+	}
+}
+
+func TestImageOciEncryptLayerPolicy(t *testing.T) {
+	srcRef, _, layer := createTestImage(t)
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	encryptConfig, err := agecrypt.EncryptConfig([]string{identity.Recipient().String()})
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		policy    EncryptLayerPolicy
+		encrypted bool
+	}{
+		{EncryptLayersAfter(digest.FromBytes(layer)), false},
+		{fixedEncryptLayerPolicy{0}, true},
+	} {
+		destRef, err := directory.NewReference(t.TempDir())
+		require.NoError(t, err)
+		manifestBlob, err := Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+			OciEncryptConfig:      encryptConfig,
+			OciEncryptLayerPolicy: c.policy,
+		})
+		require.NoError(t, err)
+		m, err := manifest.OCI1FromManifest(manifestBlob)
+		require.NoError(t, err)
+		require.Len(t, m.Layers, 1)
+		assert.Equal(t, c.encrypted, strings.HasSuffix(m.Layers[0].MediaType, "+encrypted"), m.Layers[0].MediaType)
+	}
+
+	// OciEncryptLayers and OciEncryptLayerPolicy are mutually exclusive
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		OciEncryptConfig:      encryptConfig,
+		OciEncryptLayers:      &[]int{},
+		OciEncryptLayerPolicy: fixedEncryptLayerPolicy{0},
+	})
+	assert.Error(t, err)
+}
+
+func TestImageRewrapLayerKeys(t *testing.T) {
+	srcRef, _, _ := createTestImage(t)
+	oldIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	newIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	oldEncryptConfig, err := agecrypt.EncryptConfig([]string{oldIdentity.Recipient().String()})
+	require.NoError(t, err)
+	newEncryptConfig, err := agecrypt.EncryptConfig([]string{newIdentity.Recipient().String()})
+	require.NoError(t, err)
+	oldDecryptConfig, err := agecrypt.DecryptConfig([][]byte{[]byte(oldIdentity.String())})
+	require.NoError(t, err)
+	newDecryptConfig, err := agecrypt.DecryptConfig([][]byte{[]byte(newIdentity.String())})
+	require.NoError(t, err)
+
+	encryptedRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	encryptedManifestBlob, err := Image(context.Background(), acceptAnythingPolicyContext(t), encryptedRef, srcRef, &Options{
+		OciEncryptConfig: oldEncryptConfig,
+		OciEncryptLayers: &[]int{},
+	})
+	require.NoError(t, err)
+	encryptedManifest, err := manifest.OCI1FromManifest(encryptedManifestBlob)
+	require.NoError(t, err)
+	require.Len(t, encryptedManifest.Layers, 1)
+	encryptedLayer := encryptedManifest.Layers[0]
+
+	rewrappedRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	rewrappedManifestBlob, err := Image(context.Background(), acceptAnythingPolicyContext(t), rewrappedRef, encryptedRef, &Options{
+		OciEncryptConfig: newEncryptConfig,
+		OciEncryptLayers: &[]int{},
+		OciDecryptConfig: oldDecryptConfig,
+	})
+	require.NoError(t, err)
+	rewrappedManifest, err := manifest.OCI1FromManifest(rewrappedManifestBlob)
+	require.NoError(t, err)
+	require.Len(t, rewrappedManifest.Layers, 1)
+	rewrappedLayer := rewrappedManifest.Layers[0]
+
+	// The encrypted data is unchanged, only the wrapped keys differ
+	assert.Equal(t, encryptedLayer.MediaType, rewrappedLayer.MediaType)
+	assert.Equal(t, encryptedLayer.Digest, rewrappedLayer.Digest)
+	assert.Equal(t, encryptedLayer.Size, rewrappedLayer.Size)
+	assert.Equal(t, encryptedLayer.Annotations["org.opencontainers.image.enc.pubopts"], rewrappedLayer.Annotations["org.opencontainers.image.enc.pubopts"])
+	assert.NotEqual(t, encryptedLayer.Annotations["org.opencontainers.image.enc.keys.age"], rewrappedLayer.Annotations["org.opencontainers.image.enc.keys.age"])
+	// Only the new recipient can decrypt the layer
+	_, _, err = ocicrypt.DecryptLayer(newDecryptConfig, nil, rewrappedLayer, true)
+	assert.NoError(t, err)
+	_, _, err = ocicrypt.DecryptLayer(oldDecryptConfig, nil, rewrappedLayer, true)
+	assert.Error(t, err)
+
+	// A decryption config which can’t unwrap the layer key
+	failedRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), failedRef, encryptedRef, &Options{
+		OciEncryptConfig: oldEncryptConfig,
+		OciEncryptLayers: &[]int{},
+		OciDecryptConfig: newDecryptConfig,
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	internalblobinfocache "github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/image"
+	internalManifest "github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// SizeEstimate is the result of EstimateSize.
+type SizeEstimate struct {
+	TotalBlobs    int   // Number of unique blobs (layers and configs) referenced by the selected images
+	TotalBytes    int64 // Total size of the unique blobs, not including blobs of unknown size
+	TransferBlobs int   // Number of unique blobs which are not known to be present at the destination
+	TransferBytes int64 // Total size of the blobs which are not known to be present at the destination, not including blobs of unknown size
+	// Number of unique blobs with unknown size, which are not included in TotalBytes and TransferBytes.
+	// This happens e.g. for layers of docker schema1 images.
+	UnknownSizeBlobs int
+}
+
+// String returns a human-readable summary of e.
+func (e *SizeEstimate) String() string {
+	res := fmt.Sprintf("would transfer %d of %d bytes (%d of %d blobs)", e.TransferBytes, e.TotalBytes, e.TransferBlobs, e.TotalBlobs)
+	if e.UnknownSizeBlobs != 0 {
+		res += fmt.Sprintf(", %d blobs of unknown size", e.UnknownSizeBlobs)
+	}
+	return res
+}
+
+// TransferEstimate is the result of EstimateTransfer.
+type TransferEstimate struct {
+	SizeEstimate
+	// DownloadBytes is the amount of data which would be read from the source: manifests, all configs
+	// (which are always read, to process the image), and layers not known to be present at the destination.
+	// Blobs of unknown size are not included.
+	DownloadBytes int64
+	// UploadBytes is the amount of data which would be written to the destination: manifests, and blobs
+	// not known to be present at the destination. Blobs of unknown size are not included.
+	UploadBytes int64
+}
+
+// EstimateSize estimates how much data would be copied by copying the image in src to dest,
+// without transferring any blobs and without modifying dest.
+// It is equivalent to EstimateTransfer using the blob info cache for options.DestinationCtx, as used by Image.
+func EstimateSize(ctx context.Context, dest types.ImageDestination, src types.ImageSource, options *Options) (*SizeEstimate, error) {
+	var sys *types.SystemContext
+	if options != nil {
+		sys = options.DestinationCtx
+	}
+	res, err := EstimateTransfer(ctx, dest, src, blobinfocache.DefaultCache(sys), options)
+	if err != nil {
+		return nil, err
+	}
+	return &res.SizeEstimate, nil
+}
+
+// EstimateTransfer estimates how much data would be downloaded from src and uploaded to dest by copying the image in src to dest,
+// without transferring any blobs and without modifying dest, e.g. to decide where to run the copy before it starts.
+//
+// Only the ImageListSelection, Instances, PreferGzipInstances, SourceCtx and DestinationCtx fields of options are used,
+// with the same meaning as in Image.
+// The manifests and configs of the selected images are read from src.
+// A blob is considered present at the destination if dest says so, or if cache knows it
+// can be reused within dest; destinations which can’t check for blob presence without modifying the destination
+// are assumed to contain no blobs.
+// The estimate does not account for compression format changes, partial pulls, or signatures.
+func EstimateTransfer(ctx context.Context, dest types.ImageDestination, src types.ImageSource, cache types.BlobInfoCache, options *Options) (*TransferEstimate, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	if err := validateImageListSelection(options.ImageListSelection); err != nil {
+		return nil, err
+	}
+
+	instances, topLevelManifestSize, err := estimateSizeInstances(ctx, src, options)
+	if err != nil {
+		return nil, err
+	}
+	res := &TransferEstimate{DownloadBytes: topLevelManifestSize}
+	if options.ImageListSelection != CopySystemImage || (len(instances) == 1 && instances[0] == nil) {
+		res.UploadBytes = topLevelManifestSize // With CopySystemImage, a list is read but only the selected instance is written.
+	}
+	blobs := []types.BlobInfo{}
+	configs := set.New[digest.Digest]()
+	seen := map[digest.Digest]struct{}{}
+	for _, instanceDigest := range instances {
+		img, err := image.FromUnparsedImage(ctx, options.SourceCtx, image.UnparsedInstance(src, instanceDigest))
+		if err != nil {
+			return nil, fmt.Errorf("reading image %s: %w", optionalDigestString(instanceDigest), err)
+		}
+		if instanceDigest != nil {
+			manifestBlob, _, err := img.Manifest(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("reading manifest %s: %w", instanceDigest, err)
+			}
+			res.DownloadBytes += int64(len(manifestBlob))
+			res.UploadBytes += int64(len(manifestBlob))
+		}
+		candidates := slices.Clone(img.LayerInfos())
+		if config := img.ConfigInfo(); config.Digest != "" {
+			candidates = append(candidates, config)
+			configs.Add(config.Digest)
+		}
+		for _, blob := range candidates {
+			if _, ok := seen[blob.Digest]; ok {
+				continue
+			}
+			seen[blob.Digest] = struct{}{}
+			blobs = append(blobs, blob)
+		}
+	}
+
+	internalCache := internalblobinfocache.FromBlobInfoCache(cache)
+	internalCache.Open()
+	defer internalCache.Close()
+	checker, canCheck := dest.(private.BlobPresenceChecker)
+	for _, blob := range blobs {
+		present := false
+		if canCheck {
+			present, err = checker.HasBlob(ctx, blob, internalCache)
+			if err != nil {
+				return nil, fmt.Errorf("checking for blob %s at the destination: %w", blob.Digest, err)
+			}
+		}
+		res.TotalBlobs++
+		if !present {
+			res.TransferBlobs++
+		}
+		if blob.Size == -1 {
+			res.UnknownSizeBlobs++
+			continue
+		}
+		res.TotalBytes += blob.Size
+		if !present {
+			res.TransferBytes += blob.Size
+			res.UploadBytes += blob.Size
+		}
+		if !present || configs.Contains(blob.Digest) {
+			res.DownloadBytes += blob.Size
+		}
+	}
+	return res, nil
+}
+
+// estimateSizeInstances returns the instances of src which would be copied per options
+// (a single nil value if src is not a manifest list), and the size of the top-level manifest.
+func estimateSizeInstances(ctx context.Context, src types.ImageSource, options *Options) ([]*digest.Digest, int64, error) {
+	manifestBlob, manifestType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, -1, fmt.Errorf("reading manifest: %w", err)
+	}
+	manifestSize := int64(len(manifestBlob))
+	if !manifest.MIMETypeIsMultiImage(manifestType) {
+		return []*digest.Digest{nil}, manifestSize, nil
+	}
+	list, err := internalManifest.ListFromBlob(manifestBlob, manifestType)
+	if err != nil {
+		return nil, -1, fmt.Errorf("parsing manifest list: %w", err)
+	}
+	switch options.ImageListSelection {
+	case CopySystemImage:
+		instanceDigest, err := list.ChooseInstanceByCompression(options.SourceCtx, options.PreferGzipInstances)
+		if err != nil {
+			return nil, -1, fmt.Errorf("choosing an image from manifest list: %w", err)
+		}
+		return []*digest.Digest{&instanceDigest}, manifestSize, nil
+	default: // CopyAllImages, CopySpecificImages
+		res := []*digest.Digest{}
+		for _, instanceDigest := range list.Instances() {
+			if options.ImageListSelection == CopySpecificImages && !slices.Contains(options.Instances, instanceDigest) {
+				continue
+			}
+			instanceDigest := instanceDigest
+			res = append(res, &instanceDigest)
+		}
+		return res, manifestSize, nil
+	}
+}
+
+// optionalDigestString returns a description of an optional instance digest.
+func optionalDigestString(instanceDigest *digest.Digest) string {
+	if instanceDigest == nil {
+		return "(primary manifest)"
+	}
+	return instanceDigest.String()
+}
@@ -0,0 +1,124 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateSize(t *testing.T) {
+	srcRef, config, layer := createTestImage(t)
+	src, err := srcRef.NewImageSource(context.Background(), nil)
+	require.NoError(t, err)
+	defer src.Close()
+
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	dest, err := destRef.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	options := &Options{DestinationCtx: &types.SystemContext{BlobInfoCacheDir: t.TempDir()}}
+
+	// Nothing at the destination
+	res, err := EstimateSize(context.Background(), dest, src, options)
+	require.NoError(t, err)
+	total := int64(len(config) + len(layer))
+	assert.Equal(t, &SizeEstimate{
+		TotalBlobs:    2,
+		TotalBytes:    total,
+		TransferBlobs: 2,
+		TransferBytes: total,
+	}, res)
+
+	// The layer already exists at the destination
+	_, err = dest.PutBlob(context.Background(), bytes.NewReader(layer), types.BlobInfo{Size: -1}, memory.New(), false)
+	require.NoError(t, err)
+	res, err = EstimateSize(context.Background(), dest, src, options)
+	require.NoError(t, err)
+	assert.Equal(t, &SizeEstimate{
+		TotalBlobs:    2,
+		TotalBytes:    total,
+		TransferBlobs: 1,
+		TransferBytes: int64(len(config)),
+	}, res)
+
+	// A destination which can’t check for blob presence is assumed to contain nothing
+	res, err = EstimateSize(context.Background(), struct{ types.ImageDestination }{dest}, src, options)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.TransferBlobs)
+	assert.Equal(t, total, res.TransferBytes)
+
+	// Invalid list selection
+	_, err = EstimateSize(context.Background(), dest, src, &Options{ImageListSelection: ImageListSelection(99)})
+	assert.Error(t, err)
+}
+
+func TestEstimateTransfer(t *testing.T) {
+	ctx := context.Background()
+	srcRef, config, layer := createTestImage(t)
+	src, err := srcRef.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+	manifestBlob, _, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	manifestSize := int64(len(manifestBlob))
+
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	dest, err := destRef.NewImageDestination(ctx, nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	cache := memory.New()
+
+	// Nothing at the destination
+	res, err := EstimateTransfer(ctx, dest, src, cache, nil)
+	require.NoError(t, err)
+	total := int64(len(config) + len(layer))
+	assert.Equal(t, total, res.TransferBytes)
+	assert.Equal(t, manifestSize+total, res.DownloadBytes)
+	assert.Equal(t, manifestSize+total, res.UploadBytes)
+
+	// With everything at the destination, the manifest and config are still read.
+	for _, blob := range [][]byte{config, layer} {
+		_, err = dest.PutBlob(ctx, bytes.NewReader(blob), types.BlobInfo{Size: -1}, cache, false)
+		require.NoError(t, err)
+	}
+	res, err = EstimateTransfer(ctx, dest, src, cache, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.TransferBlobs)
+	assert.Equal(t, manifestSize+int64(len(config)), res.DownloadBytes)
+	assert.Equal(t, manifestSize, res.UploadBytes)
+
+	// A manifest list: the list and the selected instance manifest are read and written.
+	listRef, availableDigest, _ := createTestImageList(t)
+	listSrc, err := listRef.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer listSrc.Close()
+	listBlob, _, err := listSrc.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	instanceBlob, _, err := listSrc.GetManifest(ctx, &availableDigest)
+	require.NoError(t, err)
+	manifestsSize := int64(len(listBlob) + len(instanceBlob))
+	res, err = EstimateTransfer(ctx, dest, listSrc, cache, &Options{
+		ImageListSelection: CopySpecificImages,
+		Instances:          []digest.Digest{availableDigest},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.TotalBlobs)
+	assert.Equal(t, manifestsSize+int64(len(config)), res.DownloadBytes)
+	assert.Equal(t, manifestsSize, res.UploadBytes)
+}
+
+func TestSizeEstimateString(t *testing.T) {
+	assert.Equal(t, "would transfer 10 of 30 bytes (1 of 3 blobs)",
+		(&SizeEstimate{TotalBlobs: 3, TotalBytes: 30, TransferBlobs: 1, TransferBytes: 10}).String())
+	assert.Equal(t, "would transfer 10 of 30 bytes (2 of 4 blobs), 1 blobs of unknown size",
+		(&SizeEstimate{TotalBlobs: 4, TotalBytes: 30, TransferBlobs: 2, TransferBytes: 10, UnknownSizeBlobs: 1}).String())
+}
@@ -0,0 +1,191 @@
+package copy
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Limit identifies one of the limits which can be set in Options.
+type Limit string
+
+const (
+	// LimitLayerSize corresponds to Options.MaxLayerSize.
+	LimitLayerSize Limit = "layer size"
+	// LimitTotalUncompressedSize corresponds to Options.MaxTotalUncompressedSize.
+	LimitTotalUncompressedSize Limit = "total uncompressed size"
+	// LimitLayerCount corresponds to Options.MaxLayerCount.
+	LimitLayerCount Limit = "layer count"
+)
+
+// LimitExceededError is returned by Image if the copied image exceeds one of the limits set in Options.
+type LimitExceededError struct {
+	Limit Limit
+	Max   int64 // The value of the limit
+	// Actual is the value which exceeded the limit. If the limit was exceeded while copying,
+	// this is only a lower bound: the copy was aborted without reading the rest of the data.
+	Actual int64
+	Digest digest.Digest // The layer exceeding the limit, only set for LimitLayerSize
+}
+
+func (e *LimitExceededError) Error() string {
+	switch e.Limit {
+	case LimitLayerSize:
+		return fmt.Sprintf("layer %s size %d exceeds the limit of %d bytes", e.Digest, e.Actual, e.Max)
+	case LimitTotalUncompressedSize:
+		return fmt.Sprintf("total uncompressed size of layers %d exceeds the limit of %d bytes", e.Actual, e.Max)
+	case LimitLayerCount:
+		return fmt.Sprintf("image has %d layers, exceeding the limit of %d layers", e.Actual, e.Max)
+	default:
+		return fmt.Sprintf("%s %d exceeds the limit of %d", e.Limit, e.Actual, e.Max)
+	}
+}
+
+// checkLayerLimits checks the layers of an image, as listed in its manifest, against the limits in ic.c.options.
+func (ic *imageCopier) checkLayerLimits(srcInfos []types.BlobInfo) error {
+	options := ic.c.options
+	if options.MaxLayerCount > 0 && len(srcInfos) > options.MaxLayerCount {
+		return &LimitExceededError{Limit: LimitLayerCount, Max: int64(options.MaxLayerCount), Actual: int64(len(srcInfos))}
+	}
+	if options.MaxLayerSize > 0 {
+		for _, srcInfo := range srcInfos {
+			if srcInfo.Size > options.MaxLayerSize {
+				return &LimitExceededError{Limit: LimitLayerSize, Max: options.MaxLayerSize, Actual: srcInfo.Size, Digest: srcInfo.Digest}
+			}
+		}
+	}
+	return nil
+}
+
+// layerSizeLimitReader fails reading from reader once more than max bytes were read.
+type layerSizeLimitReader struct {
+	reader   io.Reader
+	digest   digest.Digest
+	max      int64
+	count    int64
+	exceeded *LimitExceededError // Set once the limit was exceeded
+}
+
+func (r *layerSizeLimitReader) Read(p []byte) (int, error) {
+	if r.exceeded != nil {
+		return 0, r.exceeded
+	}
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	if r.count > r.max {
+		r.exceeded = &LimitExceededError{Limit: LimitLayerSize, Max: r.max, Actual: r.count, Digest: r.digest}
+		return n, r.exceeded
+	}
+	return n, err
+}
+
+// uncompressedSizeLimit tracks the total uncompressed size of layers copied by a copier against Options.MaxTotalUncompressedSize.
+// It is safe for concurrent use.
+type uncompressedSizeLimit struct {
+	max   int64
+	total atomic.Int64
+}
+
+// add records n more bytes of uncompressed layer data, and returns a *LimitExceededError if the limit is now exceeded.
+func (l *uncompressedSizeLimit) add(n int64) *LimitExceededError {
+	total := l.total.Add(n)
+	if total > l.max {
+		return &LimitExceededError{Limit: LimitTotalUncompressedSize, Max: l.max, Actual: total}
+	}
+	return nil
+}
+
+// bpUncompressedSizeStepData contains data that the copy pipeline needs about the uncompressed size limit step.
+type bpUncompressedSizeStepData struct {
+	limit      *uncompressedSizeLimit // nil if the step does nothing
+	pipeWriter *io.PipeWriter         // Feeds the counting goroutine, nil if the stream is counted directly
+	done       chan struct{}          // Closed when the counting goroutine exits
+	closed     bool
+	exceeded   atomic.Pointer[LimitExceededError] // Set if the limit was exceeded; atomic because the stream may be consumed by a separate goroutine
+}
+
+// blobPipelineUncompressedSizeStep updates *stream to count its uncompressed size against ic.c.uncompressedSizeLimit, if any.
+// Compressed streams are decompressed in a separate goroutine, only for counting.
+// The caller must call .close() on the returned object.
+func (ic *imageCopier) blobPipelineUncompressedSizeStep(stream *sourceStream, isConfig bool, detected bpDetectCompressionStepData) *bpUncompressedSizeStepData {
+	res := &bpUncompressedSizeStepData{}
+	if ic.c.uncompressedSizeLimit == nil || isConfig {
+		return res
+	}
+	res.limit = ic.c.uncompressedSizeLimit
+	if !detected.isCompressed {
+		stream.reader = &uncompressedSizeCountingReader{reader: stream.reader, step: res}
+		return res
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	res.pipeWriter = pipeWriter
+	res.done = make(chan struct{})
+	go res.countDecompressed(pipeReader, detected.decompressor)
+	stream.reader = io.TeeReader(stream.reader, pipeWriter)
+	return res
+}
+
+// countDecompressed decompresses data from pipeReader and counts it, until pipeReader reaches EOF or the limit is exceeded.
+// A decompression failure is not reported; the rest of the copy pipeline handles corrupt input.
+func (d *bpUncompressedSizeStepData) countDecompressed(pipeReader *io.PipeReader, decompressor compressiontypes.DecompressorFunc) {
+	defer close(d.done)
+	decompressed, err := decompressor(pipeReader)
+	if err == nil {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := decompressed.Read(buf)
+			if limitErr := d.limit.add(int64(n)); limitErr != nil {
+				d.exceeded.Store(limitErr)
+				break
+			}
+			if err != nil {
+				break
+			}
+		}
+		decompressed.Close()
+	}
+	if limitErr := d.exceeded.Load(); limitErr != nil {
+		_ = pipeReader.CloseWithError(limitErr) // Fails the TeeReader, and so the rest of the copy.
+		return
+	}
+	// Consume any data following the compressed stream, so that the TeeReader does not block.
+	_, _ = io.Copy(io.Discard, pipeReader)
+	pipeReader.Close()
+}
+
+// close waits for counting of the stream to finish, and returns a *LimitExceededError if the limit has been exceeded.
+// It may be called more than once.
+func (d *bpUncompressedSizeStepData) close() error {
+	if !d.closed && d.pipeWriter != nil {
+		d.pipeWriter.Close()
+		<-d.done
+	}
+	d.closed = true
+	if limitErr := d.exceeded.Load(); limitErr != nil {
+		return limitErr
+	}
+	return nil
+}
+
+// uncompressedSizeCountingReader counts data of an uncompressed stream against step.limit.
+type uncompressedSizeCountingReader struct {
+	reader io.Reader
+	step   *bpUncompressedSizeStepData
+}
+
+func (r *uncompressedSizeCountingReader) Read(p []byte) (int, error) {
+	if limitErr := r.step.exceeded.Load(); limitErr != nil {
+		return 0, limitErr
+	}
+	n, err := r.reader.Read(p)
+	if limitErr := r.step.limit.add(int64(n)); limitErr != nil {
+		r.step.exceeded.Store(limitErr)
+		return n, limitErr
+	}
+	return n, err
+}
@@ -0,0 +1,56 @@
+package copy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckLayerLimits(t *testing.T) {
+	layers := []types.BlobInfo{
+		{Digest: digest.FromString("layer1"), Size: 10},
+		{Digest: digest.FromString("layer2"), Size: -1},
+		{Digest: digest.FromString("layer3"), Size: 30},
+	}
+	for _, c := range []struct {
+		options  Options
+		expected *LimitExceededError
+	}{
+		{Options{}, nil},
+		{Options{MaxLayerCount: 3, MaxLayerSize: 30}, nil},
+		{Options{MaxLayerCount: 2}, &LimitExceededError{Limit: LimitLayerCount, Max: 2, Actual: 3}},
+		{Options{MaxLayerSize: 29}, &LimitExceededError{Limit: LimitLayerSize, Max: 29, Actual: 30, Digest: layers[2].Digest}},
+	} {
+		ic := &imageCopier{c: &copier{options: &c.options}}
+		err := ic.checkLayerLimits(layers)
+		if c.expected == nil {
+			assert.NoError(t, err)
+		} else {
+			assert.Equal(t, c.expected, err)
+		}
+	}
+}
+
+func TestLayerSizeLimitReader(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 100)
+	d := digest.FromBytes(data)
+
+	r := &layerSizeLimitReader{reader: bytes.NewReader(data), digest: d, max: 100}
+	res, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, res)
+	assert.Nil(t, r.exceeded)
+
+	r = &layerSizeLimitReader{reader: bytes.NewReader(data), digest: d, max: 99}
+	_, err = io.ReadAll(r)
+	var limitErr *LimitExceededError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, LimitLayerSize, limitErr.Limit)
+	assert.Equal(t, d, limitErr.Digest)
+	assert.Equal(t, limitErr, r.exceeded)
+}
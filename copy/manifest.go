@@ -7,13 +7,14 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/containers/image/v5/internal/log"
 	internalManifest "github.com/containers/image/v5/internal/manifest"
 	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/internal/tracing"
 	"github.com/containers/image/v5/manifest"
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 // preferredManifestMIMETypes lists manifest MIME types in order of our preference, if we can't use the original manifest and need to convert.
@@ -70,11 +71,11 @@ type manifestConversionPlan struct {
 }
 
 // determineManifestConversion returns a plan for what formats, and possibly conversions, to use based on in.
-func determineManifestConversion(in determineManifestConversionInputs) (manifestConversionPlan, error) {
+func determineManifestConversion(ctx context.Context, in determineManifestConversionInputs) (manifestConversionPlan, error) {
 	srcType := in.srcMIMEType
 	normalizedSrcType := manifest.NormalizedMIMEType(srcType)
 	if srcType != normalizedSrcType {
-		logrus.Debugf("Source manifest MIME type %q, treating it as %q", srcType, normalizedSrcType)
+		log.DebugfContext(ctx, "Source manifest MIME type %q, treating it as %q", srcType, normalizedSrcType)
 		srcType = normalizedSrcType
 	}
 
@@ -163,7 +164,7 @@ func determineManifestConversion(in determineManifestConversionInputs) (manifest
 		// make the choice; it is already doing that to an extent, to improve error
 		// messages.  But it is nice to hide the “if we can't modify, do no conversion”
 		// special case in here; the caller can then worry (or not) only about a good UI.
-		logrus.Debugf("We can't modify the manifest, hoping for the best...")
+		log.DebugfContext(ctx, "We can't modify the manifest, hoping for the best...")
 		return manifestConversionPlan{ // Take our chances - FIXME? Or should we fail without trying?
 			preferredMIMEType:       srcType,
 			otherMIMETypeCandidates: []string{},
@@ -184,7 +185,7 @@ func determineManifestConversion(in determineManifestConversionInputs) (manifest
 		}
 	}
 
-	logrus.Debugf("Manifest has MIME type %s, ordered candidate list [%s]", srcType, strings.Join(prioritizedTypes.list, ", "))
+	log.DebugfContext(ctx, "Manifest has MIME type %s, ordered candidate list [%s]", srcType, strings.Join(prioritizedTypes.list, ", "))
 	if len(prioritizedTypes.list) == 0 { // Coverage: destSupportedManifestMIMETypes and supportedByDest, which is a subset, is not empty (or we would have exited above), so this should never happen.
 		return manifestConversionPlan{}, errors.New("Internal error: no candidate MIME types")
 	}
@@ -194,14 +195,17 @@ func determineManifestConversion(in determineManifestConversionInputs) (manifest
 	}
 	res.preferredMIMETypeNeedsConversion = res.preferredMIMEType != srcType
 	if !res.preferredMIMETypeNeedsConversion {
-		logrus.Debugf("... will first try using the original manifest unmodified")
+		log.DebugfContext(ctx, "... will first try using the original manifest unmodified")
 	}
 	return res, nil
 }
 
 // isMultiImage returns true if img is a list of images
 func isMultiImage(ctx context.Context, img types.UnparsedImage) (bool, error) {
+	// This is the first time the manifest is read during a copy, so this span covers the manifest fetch.
+	ctx, span := tracing.Start(ctx, "copy.fetchManifest")
 	_, mt, err := img.Manifest(ctx)
+	tracing.End(span, err)
 	if err != nil {
 		return false, err
 	}
@@ -214,7 +218,7 @@ func isMultiImage(ctx context.Context, img types.UnparsedImage) (bool, error) {
 // of manifests (regardless of whether we are converting to it or using it
 // unmodified) and a slice of other list types which might be supported by the
 // destination.
-func (c *copier) determineListConversion(currentListMIMEType string, destSupportedMIMETypes []string, forcedListMIMEType string) (string, []string, error) {
+func (c *copier) determineListConversion(ctx context.Context, currentListMIMEType string, destSupportedMIMETypes []string, forcedListMIMEType string) (string, []string, error) {
 	// If there's no list of supported types, then anything we support is expected to be supported.
 	if len(destSupportedMIMETypes) == 0 {
 		destSupportedMIMETypes = manifest.SupportedListMIMETypes
@@ -237,16 +241,16 @@ func (c *copier) determineListConversion(currentListMIMEType string, destSupport
 		}
 	}
 
-	logrus.Debugf("Manifest list has MIME type %q, ordered candidate list [%s]", currentListMIMEType, strings.Join(destSupportedMIMETypes, ", "))
+	log.DebugfContext(ctx, "Manifest list has MIME type %q, ordered candidate list [%s]", currentListMIMEType, strings.Join(destSupportedMIMETypes, ", "))
 	if len(prioritizedTypes.list) == 0 {
 		return "", nil, fmt.Errorf("destination does not support any supported manifest list types (%v)", manifest.SupportedListMIMETypes)
 	}
 	selectedType := prioritizedTypes.list[0]
 	otherSupportedTypes := prioritizedTypes.list[1:]
 	if selectedType != currentListMIMEType {
-		logrus.Debugf("... will convert to %s first, and then try %v", selectedType, otherSupportedTypes)
+		log.DebugfContext(ctx, "... will convert to %s first, and then try %v", selectedType, otherSupportedTypes)
 	} else {
-		logrus.Debugf("... will use the original manifest list type, and then try %v", otherSupportedTypes)
+		log.DebugfContext(ctx, "... will use the original manifest list type, and then try %v", otherSupportedTypes)
 	}
 	// Done.
 	return selectedType, otherSupportedTypes, nil
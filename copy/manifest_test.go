@@ -172,7 +172,7 @@ func TestDetermineManifestConversion(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		res, err := determineManifestConversion(determineManifestConversionInputs{
+		res, err := determineManifestConversion(context.Background(), determineManifestConversionInputs{
 			srcMIMEType:                    c.sourceType,
 			destSupportedManifestMIMETypes: c.destTypes,
 			forceManifestMIMEType:          "",
@@ -185,7 +185,7 @@ func TestDetermineManifestConversion(t *testing.T) {
 
 	// Whatever the input is, with cannotModifyManifestReason we return "keep the original as is"
 	for _, c := range cases {
-		res, err := determineManifestConversion(determineManifestConversionInputs{
+		res, err := determineManifestConversion(context.Background(), determineManifestConversionInputs{
 			srcMIMEType:                    c.sourceType,
 			destSupportedManifestMIMETypes: c.destTypes,
 			forceManifestMIMEType:          "",
@@ -202,7 +202,7 @@ func TestDetermineManifestConversion(t *testing.T) {
 
 	// With forceManifestMIMEType, the output is always the forced manifest type (in this case oci manifest)
 	for _, c := range cases {
-		res, err := determineManifestConversion(determineManifestConversionInputs{
+		res, err := determineManifestConversion(context.Background(), determineManifestConversionInputs{
 			srcMIMEType:                    c.sourceType,
 			destSupportedManifestMIMETypes: c.destTypes,
 			forceManifestMIMEType:          v1.MediaTypeImageManifest,
@@ -399,7 +399,7 @@ func TestDetermineManifestConversion(t *testing.T) {
 
 			in := c.in
 			restriction.edit(&in)
-			res, err := determineManifestConversion(in)
+			res, err := determineManifestConversion(context.Background(), in)
 			if c.expected.preferredMIMEType != "" {
 				require.NoError(t, err, desc)
 				assert.Equal(t, c.expected, res, desc)
@@ -510,7 +510,7 @@ func TestDetermineManifestConversion(t *testing.T) {
 	} {
 		in := c.in
 		in.requestedCompressionFormat = &compression.Xz
-		_, err := determineManifestConversion(in)
+		_, err := determineManifestConversion(context.Background(), in)
 		assert.Error(t, err, c.description)
 	}
 }
@@ -598,7 +598,7 @@ func TestDetermineManifestListConversion(t *testing.T) {
 
 	for _, c := range cases {
 		copier := &copier{}
-		preferredMIMEType, otherCandidates, err := copier.determineListConversion(c.sourceType, c.destTypes, "")
+		preferredMIMEType, otherCandidates, err := copier.determineListConversion(context.Background(), c.sourceType, c.destTypes, "")
 		require.NoError(t, err, c.description)
 		if c.expectedUpdate == "" {
 			assert.Equal(t, manifest.NormalizedMIMEType(c.sourceType), preferredMIMEType, c.description)
@@ -611,7 +611,7 @@ func TestDetermineManifestListConversion(t *testing.T) {
 	// With forceManifestMIMEType, the output is always the forced manifest type (in this case OCI index)
 	for _, c := range cases {
 		copier := &copier{}
-		preferredMIMEType, otherCandidates, err := copier.determineListConversion(c.sourceType, c.destTypes, v1.MediaTypeImageIndex)
+		preferredMIMEType, otherCandidates, err := copier.determineListConversion(context.Background(), c.sourceType, c.destTypes, v1.MediaTypeImageIndex)
 		require.NoError(t, err, c.description)
 		assert.Equal(t, v1.MediaTypeImageIndex, preferredMIMEType, c.description)
 		assert.Equal(t, []string{}, otherCandidates, c.description)
@@ -619,6 +619,6 @@ func TestDetermineManifestListConversion(t *testing.T) {
 
 	// The destination doesn’t support list formats at all
 	copier := &copier{}
-	_, _, err := copier.determineListConversion(v1.MediaTypeImageIndex, supportOnlyS1, "")
+	_, _, err := copier.determineListConversion(context.Background(), v1.MediaTypeImageIndex, supportOnlyS1, "")
 	assert.Error(t, err)
 }
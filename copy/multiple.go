@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"maps"
 	"slices"
 	"sort"
@@ -12,13 +13,18 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/log"
 	internalManifest "github.com/containers/image/v5/internal/manifest"
 	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/internal/tracing"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
 	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type instanceCopyKind int
@@ -99,7 +105,7 @@ func validateCompressionVariantExists(input []OptionCompressionVariant) error {
 }
 
 // prepareInstanceCopies prepares a list of instances which needs to copied to the manifest list.
-func prepareInstanceCopies(list internalManifest.List, instanceDigests []digest.Digest, options *Options) ([]instanceCopy, error) {
+func prepareInstanceCopies(ctx context.Context, list internalManifest.List, instanceDigests []digest.Digest, options *Options) ([]instanceCopy, error) {
 	res := []instanceCopy{}
 	if options.ImageListSelection == CopySpecificImages && len(options.EnsureCompressionVariantsExist) > 0 {
 		// List can already contain compressed instance for a compression selected in `EnsureCompressionVariantsExist`
@@ -120,7 +126,7 @@ func prepareInstanceCopies(list internalManifest.List, instanceDigests []digest.
 	for i, instanceDigest := range instanceDigests {
 		if options.ImageListSelection == CopySpecificImages &&
 			!slices.Contains(options.Instances, instanceDigest) {
-			logrus.Debugf("Skipping instance %s (%d/%d)", instanceDigest, i+1, len(instanceDigests))
+			log.DebugfContext(ctx, "Skipping instance %s (%d/%d)", instanceDigest, i+1, len(instanceDigests))
 			continue
 		}
 		instanceDetails, err := list.Instance(instanceDigest)
@@ -156,6 +162,108 @@ func prepareInstanceCopies(list internalManifest.List, instanceDigests []digest.
 	return res, nil
 }
 
+// sourceReadError wraps a failure to read a manifest or a blob from the source.
+// It allows canSkipInstance to tell such failures apart from destination, policy, authentication and other errors.
+type sourceReadError struct {
+	err error
+}
+
+func (e sourceReadError) Error() string {
+	return e.err.Error()
+}
+
+func (e sourceReadError) Unwrap() error {
+	return e.err
+}
+
+// isSourceNotFoundError returns true iff err reports that a manifest or a blob is missing at the source.
+func isSourceNotFoundError(err error) bool {
+	var sre sourceReadError
+	if !errors.As(err, &sre) {
+		return false
+	}
+	var ec errcode.ErrorCoder
+	if errors.As(sre.err, &ec) {
+		switch ec.ErrorCode() {
+		case v2.ErrorCodeManifestUnknown, v2.ErrorCodeBlobUnknown:
+			return true
+		}
+	}
+	return errors.Is(sre.err, fs.ErrNotExist)
+}
+
+// canSkipInstance returns true if an instance of a list of images which failed to copy with err can be skipped
+// instead of failing the whole copy.
+func (c *copier) canSkipInstance(ctx context.Context, cannotModifyManifestListReason string, err error) bool {
+	if !c.options.SkipUnavailableInstances && !c.options.SkipFailedInstances {
+		return false
+	}
+	if ctx.Err() != nil { // Don’t turn cancellation into a successful copy of a pruned list.
+		return false
+	}
+	if !c.options.SkipFailedInstances && !isSourceNotFoundError(err) {
+		return false
+	}
+	if cannotModifyManifestListReason != "" {
+		log.DebugfContext(ctx, "Not skipping the failed instance, the list can not be modified: %s", cannotModifyManifestListReason)
+		return false
+	}
+	return true
+}
+
+// skipInstance reports that instanceDigest, for platform, is being skipped because of err.
+// replica is true if only an additional compression variant of the instance is being skipped.
+func (c *copier) skipInstance(ctx context.Context, instanceDigest digest.Digest, platform *imgspecv1.Platform, replica bool, err error) {
+	log.WarnfContext(ctx, "Skipping image %s (%s): %v", instanceDigest, formatPlatform(platform), err)
+	if isSourceNotFoundError(err) {
+		c.Printf("Skipping unavailable image %s (%s)\n", instanceDigest, formatPlatform(platform))
+	} else {
+		c.Printf("Skipping failed image %s (%s)\n", instanceDigest, formatPlatform(platform))
+	}
+	if c.options.Report != nil {
+		c.options.Report.SkippedInstances = append(c.options.Report.SkippedInstances, SkippedInstance{
+			Digest:   instanceDigest,
+			Platform: platform,
+			Replica:  replica,
+			Err:      err,
+		})
+	}
+	if c.options.OnSkippedInstance != nil {
+		c.options.OnSkippedInstance(instanceDigest, err)
+	}
+}
+
+// destinationListInstances returns the digests of instances of the list of images at the destination.
+// Failures to read the destination are not fatal, they only mean that no instances can be skipped, so the result is empty in that case.
+func (c *copier) destinationListInstances(ctx context.Context) *set.Set[digest.Digest] {
+	res := set.New[digest.Digest]()
+	destImageSource, err := c.dest.Reference().NewImageSource(ctx, c.options.DestinationCtx)
+	if err != nil {
+		log.DebugfContext(ctx, "Unable to create destination image %s source, copying all instances: %v", c.dest.Reference(), err)
+		return res
+	}
+	defer destImageSource.Close()
+
+	destManifest, destManifestType, err := destImageSource.GetManifest(ctx, nil)
+	if err != nil {
+		log.DebugfContext(ctx, "Unable to get destination image %s manifest, copying all instances: %v", c.dest.Reference(), err)
+		return res
+	}
+	if !manifest.MIMETypeIsMultiImage(destManifestType) {
+		log.DebugfContext(ctx, "Destination image %s is not a list of images, copying all instances", c.dest.Reference())
+		return res
+	}
+	destList, err := internalManifest.ListFromBlob(destManifest, destManifestType)
+	if err != nil {
+		log.DebugfContext(ctx, "Unable to parse destination image %s manifest list, copying all instances: %v", c.dest.Reference(), err)
+		return res
+	}
+	for _, instanceDigest := range destList.Instances() {
+		res.Add(instanceDigest)
+	}
+	return res
+}
+
 // copyMultipleImages copies some or all of an image list's instances, using
 // c.policyContext to validate source image admissibility.
 func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte, retErr error) {
@@ -164,6 +272,7 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	if err != nil {
 		return nil, fmt.Errorf("reading manifest list: %w", err)
 	}
+	sourceManifestList := manifestList
 	originalList, err := internalManifest.ListFromBlob(manifestList, manifestType)
 	if err != nil {
 		return nil, fmt.Errorf("parsing manifest list %q: %w", string(manifestList), err)
@@ -203,7 +312,7 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	if destIsDigestedReference {
 		cannotModifyManifestListReason = "Destination specifies a digest"
 	}
-	if c.options.PreserveDigests {
+	if c.options.PreserveDigests || c.options.PreserveListDigest {
 		cannotModifyManifestListReason = "Instructed to preserve digests"
 	}
 
@@ -215,7 +324,7 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	case imgspecv1.MediaTypeImageManifest:
 		forceListMIMEType = imgspecv1.MediaTypeImageIndex
 	}
-	selectedListType, otherManifestMIMETypeCandidates, err := c.determineListConversion(manifestType, c.dest.SupportedManifestMIMETypes(), forceListMIMEType)
+	selectedListType, otherManifestMIMETypeCandidates, err := c.determineListConversion(ctx, manifestType, c.dest.SupportedManifestMIMETypes(), forceListMIMEType)
 	if err != nil {
 		return nil, fmt.Errorf("determining manifest list type to write to destination: %w", err)
 	}
@@ -228,23 +337,63 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	// Copy each image, or just the ones we want to copy, in turn.
 	instanceDigests := updatedList.Instances()
 	instanceEdits := []internalManifest.ListEdit{}
-	instanceCopyList, err := prepareInstanceCopies(updatedList, instanceDigests, c.options)
+	instanceCopyList, err := prepareInstanceCopies(ctx, updatedList, instanceDigests, c.options)
 	if err != nil {
 		return nil, fmt.Errorf("preparing instances for copy: %w", err)
 	}
 	c.Printf("Copying %d images generated from %d images in list\n", len(instanceCopyList), len(instanceDigests))
+	existingInstances := set.New[digest.Digest]()
+	if c.options.SkipExistingInstances {
+		if c.shouldSignImage(true) {
+			log.DebugfContext(ctx, "Not skipping instances existing at the destination, they need to be signed")
+		} else {
+			existingInstances = c.destinationListInstances(ctx)
+		}
+	}
+	skippedInstances := set.New[digest.Digest]()
+	copiedInstances := 0
 	for i, instance := range instanceCopyList {
 		// Update instances to be edited by their `ListOperation` and
 		// populate necessary fields.
 		switch instance.op {
 		case instanceCopyCopy:
-			logrus.Debugf("Copying instance %s (%d/%d)", instance.sourceDigest, i+1, len(instanceCopyList))
+			if existingInstances.Contains(instance.sourceDigest) {
+				// The instance is not copied, but the list written to the destination still references it,
+				// so it must be allowed by the policy just like a copied instance.
+				unparsedInstance := image.UnparsedInstance(c.rawSource, &instanceCopyList[i].sourceDigest)
+				if err := c.checkPolicy(ctx, unparsedInstance); err != nil {
+					return nil, fmt.Errorf("checking image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				}
+				// The list entry stays as is, there is nothing to edit.
+				log.DebugfContext(ctx, "Skipping instance %s (%d/%d), it already exists at the destination", instance.sourceDigest, i+1, len(instanceCopyList))
+				c.Printf("Skipping image %s (%d/%d): already present at destination\n", instance.sourceDigest, i+1, len(instanceCopyList))
+				copiedInstances++
+				continue
+			}
+			log.DebugfContext(ctx, "Copying instance %s (%d/%d)", instance.sourceDigest, i+1, len(instanceCopyList))
 			c.Printf("Copying image %s (%d/%d)\n", instance.sourceDigest, i+1, len(instanceCopyList))
 			unparsedInstance := image.UnparsedInstance(c.rawSource, &instanceCopyList[i].sourceDigest)
-			updated, err := c.copySingleImage(ctx, unparsedInstance, &instanceCopyList[i].sourceDigest, copySingleImageOptions{requireCompressionFormatMatch: instance.copyForceCompressionFormat})
+			instanceCtx, span := tracing.Start(ctx, "copy.instance", attribute.String("instance.digest", instance.sourceDigest.String()))
+			updated, err := c.copySingleImage(instanceCtx, unparsedInstance, &instanceCopyList[i].sourceDigest, copySingleImageOptions{requireCompressionFormatMatch: instance.copyForceCompressionFormat})
+			tracing.End(span, err)
 			if err != nil {
-				return nil, fmt.Errorf("copying image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				err = fmt.Errorf("copying image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				if !c.canSkipInstance(ctx, cannotModifyManifestListReason, err) {
+					return nil, err
+				}
+				var platform *imgspecv1.Platform
+				if instanceDetails, detailsErr := originalList.Instance(instance.sourceDigest); detailsErr == nil {
+					platform = instanceDetails.ReadOnly.Platform
+				}
+				c.skipInstance(ctx, instance.sourceDigest, platform, false, err)
+				skippedInstances.Add(instance.sourceDigest)
+				instanceEdits = append(instanceEdits, internalManifest.ListEdit{
+					ListOperation: internalManifest.ListOpRemove,
+					RemoveDigest:  instance.sourceDigest,
+				})
+				continue
 			}
+			copiedInstances++
 			// Record the result of a possible conversion here.
 			instanceEdits = append(instanceEdits, internalManifest.ListEdit{
 				ListOperation:               internalManifest.ListOpUpdate,
@@ -254,15 +403,28 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 				UpdateCompressionAlgorithms: updated.compressionAlgorithms,
 				UpdateMediaType:             updated.manifestMIMEType})
 		case instanceCopyClone:
-			logrus.Debugf("Replicating instance %s (%d/%d)", instance.sourceDigest, i+1, len(instanceCopyList))
+			if skippedInstances.Contains(instance.sourceDigest) {
+				log.DebugfContext(ctx, "Not replicating skipped instance %s (%d/%d)", instance.sourceDigest, i+1, len(instanceCopyList))
+				continue
+			}
+			log.DebugfContext(ctx, "Replicating instance %s (%d/%d)", instance.sourceDigest, i+1, len(instanceCopyList))
 			c.Printf("Replicating image %s (%d/%d)\n", instance.sourceDigest, i+1, len(instanceCopyList))
 			unparsedInstance := image.UnparsedInstance(c.rawSource, &instanceCopyList[i].sourceDigest)
-			updated, err := c.copySingleImage(ctx, unparsedInstance, &instanceCopyList[i].sourceDigest, copySingleImageOptions{
+			instanceCtx, span := tracing.Start(ctx, "copy.instance", attribute.String("instance.digest", instance.sourceDigest.String()),
+				attribute.String("instance.compression", instance.cloneCompressionVariant.Algorithm.Name()))
+			updated, err := c.copySingleImage(instanceCtx, unparsedInstance, &instanceCopyList[i].sourceDigest, copySingleImageOptions{
 				requireCompressionFormatMatch: true,
 				compressionFormat:             &instance.cloneCompressionVariant.Algorithm,
 				compressionLevel:              instance.cloneCompressionVariant.Level})
+			tracing.End(span, err)
 			if err != nil {
-				return nil, fmt.Errorf("replicating image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				err = fmt.Errorf("replicating image %d/%d from manifest list: %w", i+1, len(instanceCopyList), err)
+				if !c.canSkipInstance(ctx, cannotModifyManifestListReason, err) {
+					return nil, err
+				}
+				// The original instance stays in the list, we just don’t add the replica.
+				c.skipInstance(ctx, instance.sourceDigest, instance.clonePlatform, true, err)
+				continue
 			}
 			// Record the result of a possible conversion here.
 			instanceEdits = append(instanceEdits, internalManifest.ListEdit{
@@ -280,6 +442,10 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 		}
 	}
 
+	if !skippedInstances.Empty() && copiedInstances == 0 {
+		return nil, errors.New("none of the images in the list could be copied")
+	}
+
 	// Now reset the digest/size/types of the manifests in the list to account for any conversions that we made.
 	if err = updatedList.EditInstances(instanceEdits); err != nil {
 		return nil, fmt.Errorf("updating manifest list: %w", err)
@@ -291,7 +457,7 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	for _, thisListType := range append([]string{selectedListType}, otherManifestMIMETypeCandidates...) {
 		var attemptedList internalManifest.ListPublic = updatedList
 
-		logrus.Debugf("Trying to use manifest list type %s…", thisListType)
+		log.DebugfContext(ctx, "Trying to use manifest list type %s…", thisListType)
 
 		// Perform the list conversion, if we need one.
 		if thisListType != updatedList.MIMEType() {
@@ -317,19 +483,29 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 			if cannotModifyManifestListReason != "" {
 				return nil, fmt.Errorf("Manifest list must be converted to type %q to be written to destination, but we cannot modify it: %q", thisListType, cannotModifyManifestListReason)
 			}
-			logrus.Debugf("Manifest list has been updated")
+			log.DebugfContext(ctx, "Manifest list has been updated")
 		} else {
 			// We can just use the original value, so use it instead of the one we just rebuilt, so that we don't change the digest.
 			attemptedManifestList = manifestList
 		}
 
+		// The checks above should make this impossible, but PreserveListDigest is a promise to the caller, so verify it explicitly.
+		if c.options.PreserveListDigest && !bytes.Equal(attemptedManifestList, sourceManifestList) {
+			return nil, fmt.Errorf("PreserveListDigest is set, but the manifest list to be written (digest %s) differs from the source (digest %s)",
+				digest.FromBytes(attemptedManifestList), digest.FromBytes(sourceManifestList))
+		}
+
 		// Save the manifest list.
-		err = c.dest.PutManifest(ctx, attemptedManifestList, nil)
+		manifestPhase := c.startPhase(PhaseManifestPush, types.BlobInfo{})
+		manifestCtx, finishManifestTimeout := withPhaseTimeout(ctx, c.options.PhaseTimeouts.ManifestPush, "writing manifest list")
+		err = finishManifestTimeout(c.dest.PutManifest(manifestCtx, attemptedManifestList, nil))
 		if err != nil {
-			logrus.Debugf("Upload of manifest list type %s failed: %v", thisListType, err)
+			manifestPhase.finish(-1, err)
+			log.DebugfContext(ctx, "Upload of manifest list type %s failed: %v", thisListType, err)
 			errs = append(errs, fmt.Sprintf("%s(%v)", thisListType, err))
 			continue
 		}
+		manifestPhase.finish(int64(len(attemptedManifestList)), nil)
 		errs = nil
 		manifestList = attemptedManifestList
 		break
@@ -339,11 +515,13 @@ func (c *copier) copyMultipleImages(ctx context.Context) (copiedManifest []byte,
 	}
 
 	// Sign the manifest list.
-	newSigs, err := c.createSignatures(ctx, manifestList, c.options.SignIdentity)
-	if err != nil {
-		return nil, err
+	if c.shouldSignList() {
+		newSigs, err := c.createSignatures(ctx, manifestList, c.options.SignIdentity)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(slices.Clone(sigs), newSigs...)
 	}
-	sigs = append(slices.Clone(sigs), newSigs...)
 
 	c.Printf("Storing list signatures\n")
 	if err := c.dest.PutSignaturesWithFormat(ctx, sigs, nil); err != nil {
@@ -1,13 +1,32 @@
 package copy
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 
+	"github.com/containers/image/v5/directory"
 	internalManifest "github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
 	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
 	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -26,7 +45,7 @@ func TestPrepareCopyInstancesforInstanceCopyCopy(t *testing.T) {
 		digest.Digest("sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"),
 	}
 
-	instancesToCopy, err := prepareInstanceCopies(list, sourceInstances, &Options{})
+	instancesToCopy, err := prepareInstanceCopies(context.Background(), list, sourceInstances, &Options{})
 	require.NoError(t, err)
 	compare := []instanceCopy{}
 
@@ -37,13 +56,13 @@ func TestPrepareCopyInstancesforInstanceCopyCopy(t *testing.T) {
 	assert.Equal(t, instancesToCopy, compare)
 
 	// Test CopySpecificImages where selected instance is sourceInstances[1]
-	instancesToCopy, err = prepareInstanceCopies(list, sourceInstances, &Options{Instances: []digest.Digest{sourceInstances[1]}, ImageListSelection: CopySpecificImages})
+	instancesToCopy, err = prepareInstanceCopies(context.Background(), list, sourceInstances, &Options{Instances: []digest.Digest{sourceInstances[1]}, ImageListSelection: CopySpecificImages})
 	require.NoError(t, err)
 	compare = []instanceCopy{{op: instanceCopyCopy,
 		sourceDigest: sourceInstances[1]}}
 	assert.Equal(t, instancesToCopy, compare)
 
-	_, err = prepareInstanceCopies(list, sourceInstances, &Options{Instances: []digest.Digest{sourceInstances[1]}, ImageListSelection: CopySpecificImages, ForceCompressionFormat: true})
+	_, err = prepareInstanceCopies(context.Background(), list, sourceInstances, &Options{Instances: []digest.Digest{sourceInstances[1]}, ImageListSelection: CopySpecificImages, ForceCompressionFormat: true})
 	require.EqualError(t, err, "cannot use ForceCompressionFormat with undefined default compression format")
 }
 
@@ -64,13 +83,13 @@ func TestPrepareCopyInstancesforInstanceCopyClone(t *testing.T) {
 	}
 
 	// CopySpecificImage must fail with error
-	_, err = prepareInstanceCopies(list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist,
+	_, err = prepareInstanceCopies(context.Background(), list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist,
 		Instances:          []digest.Digest{sourceInstances[1]},
 		ImageListSelection: CopySpecificImages})
 	require.EqualError(t, err, "EnsureCompressionVariantsExist is not implemented for CopySpecificImages")
 
 	// Test copying all images with replication
-	instancesToCopy, err := prepareInstanceCopies(list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist})
+	instancesToCopy, err := prepareInstanceCopies(context.Background(), list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist})
 	require.NoError(t, err)
 
 	// Following test ensures
@@ -96,7 +115,7 @@ func TestPrepareCopyInstancesforInstanceCopyClone(t *testing.T) {
 	// Test option with multiple copy request for same compression format
 	// above expection should stay same, if out ensureCompressionVariantsExist requests zstd twice
 	ensureCompressionVariantsExist = []OptionCompressionVariant{{Algorithm: compression.Zstd}, {Algorithm: compression.Zstd}}
-	instancesToCopy, err = prepareInstanceCopies(list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist})
+	instancesToCopy, err = prepareInstanceCopies(context.Background(), list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist})
 	require.NoError(t, err)
 	expectedResponse = []simplerInstanceCopy{}
 	for _, instance := range sourceInstances {
@@ -116,7 +135,7 @@ func TestPrepareCopyInstancesforInstanceCopyClone(t *testing.T) {
 		digest.Digest("sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"),
 		digest.Digest("sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"),
 	}
-	instancesToCopy, err = prepareInstanceCopies(list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist})
+	instancesToCopy, err = prepareInstanceCopies(context.Background(), list, sourceInstances, &Options{EnsureCompressionVariantsExist: ensureCompressionVariantsExist})
 	require.NoError(t, err)
 	// two copies but clone should happen only once
 	numberOfCopyClone := 0
@@ -160,3 +179,317 @@ func convertInstanceCopyToSimplerInstanceCopy(copies []instanceCopy) []simplerIn
 	}
 	return res
 }
+
+// createTestImageList creates a dir: image containing an OCI index with one available instance, and one instance
+// which is missing, and returns its reference and the digests of the two instances.
+func createTestImageList(t *testing.T) (types.ImageReference, digest.Digest, digest.Digest) {
+	ref, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	layer, err := os.ReadFile("fixtures/Hello.gz")
+	require.NoError(t, err)
+	for _, blob := range [][]byte{config, layer} {
+		_, err := dest.PutBlob(context.Background(), bytes.NewReader(blob), types.BlobInfo{Size: -1}, memory.New(), false)
+		require.NoError(t, err)
+	}
+	instance := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `",` +
+		`"config":{"mediaType":"` + imgspecv1.MediaTypeImageConfig + `","digest":"` + digest.FromBytes(config).String() + `","size":` + strconv.Itoa(len(config)) + `},` +
+		`"layers":[{"mediaType":"` + imgspecv1.MediaTypeImageLayerGzip + `","digest":"` + digest.FromBytes(layer).String() + `","size":` + strconv.Itoa(len(layer)) + `}]}`)
+	availableDigest := digest.FromBytes(instance)
+	err = dest.PutManifest(context.Background(), instance, &availableDigest)
+	require.NoError(t, err)
+	missingDigest := digest.FromString("this instance is missing")
+	index := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageIndex + `","manifests":[` +
+		`{"mediaType":"` + imgspecv1.MediaTypeImageManifest + `","digest":"` + availableDigest.String() + `","size":` + strconv.Itoa(len(instance)) + `,"platform":{"architecture":"amd64","os":"linux"}},` +
+		`{"mediaType":"` + imgspecv1.MediaTypeImageManifest + `","digest":"` + missingDigest.String() + `","size":100,"platform":{"architecture":"arm64","os":"linux"}}]}`)
+	err = dest.PutManifest(context.Background(), index, nil)
+	require.NoError(t, err)
+	err = dest.Commit(context.Background(), nil)
+	require.NoError(t, err)
+	return ref, availableDigest, missingDigest
+}
+
+func TestImageSkipUnavailableInstances(t *testing.T) {
+	srcRef, availableDigest, missingDigest := createTestImageList(t)
+
+	// By default, a missing instance fails the copy
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{ImageListSelection: CopyAllImages})
+	assert.Error(t, err)
+
+	// With SkipUnavailableInstances, the missing instance is removed from the list
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	skipped := []digest.Digest{}
+	report := Report{SkippedInstances: []SkippedInstance{{Digest: availableDigest}}} // Overwritten by the copy
+	copiedManifest, err := Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection:       CopyAllImages,
+		SkipUnavailableInstances: true,
+		OnSkippedInstance: func(instanceDigest digest.Digest, err error) {
+			assert.Error(t, err)
+			skipped = append(skipped, instanceDigest)
+		},
+		Report: &report,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{missingDigest}, skipped)
+	require.Len(t, report.SkippedInstances, 1)
+	assert.Equal(t, missingDigest, report.SkippedInstances[0].Digest)
+	assert.Equal(t, &imgspecv1.Platform{Architecture: "arm64", OS: "linux"}, report.SkippedInstances[0].Platform)
+	assert.False(t, report.SkippedInstances[0].Replica)
+	assert.Error(t, report.SkippedInstances[0].Err)
+	list, err := internalManifest.ListFromBlob(copiedManifest, internalManifest.GuessMIMEType(copiedManifest))
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{availableDigest}, list.Instances())
+
+	// The list can’t be modified: fail
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection:       CopyAllImages,
+		SkipUnavailableInstances: true,
+		PreserveDigests:          true,
+	})
+	assert.Error(t, err)
+
+	// Only a missing instance is selected: fail
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection:       CopySpecificImages,
+		Instances:                []digest.Digest{missingDigest},
+		SkipUnavailableInstances: true,
+	})
+	assert.Error(t, err)
+}
+
+// failingBlobReference is a dir: reference, the destination of which fails to upload the blob with failingDigest.
+type failingBlobReference struct {
+	types.ImageReference
+	failingDigest digest.Digest
+}
+
+func (ref failingBlobReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	dest, err := ref.ImageReference.NewImageDestination(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return failingBlobDestination{ImageDestination: dest.(private.ImageDestination), failingDigest: ref.failingDigest}, nil
+}
+
+type failingBlobDestination struct {
+	private.ImageDestination
+	failingDigest digest.Digest
+}
+
+func (d failingBlobDestination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	if inputInfo.Digest == d.failingDigest {
+		return private.UploadedBlob{}, errors.New("upload failed")
+	}
+	return d.ImageDestination.PutBlobWithOptions(ctx, stream, inputInfo, options)
+}
+
+func TestImageSkipFailedInstances(t *testing.T) {
+	srcRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	src, err := srcRef.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer src.Close()
+	instanceDigests := []digest.Digest{}
+	layerDigests := []digest.Digest{}
+	manifestEntries := []string{}
+	for _, instance := range []struct{ arch, layerFile, layerMIMEType string }{
+		{"amd64", "fixtures/Hello.gz", imgspecv1.MediaTypeImageLayerGzip},
+		{"arm64", "fixtures/Hello.zst", imgspecv1.MediaTypeImageLayerZstd},
+	} {
+		config := []byte(`{"architecture":"` + instance.arch + `","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+		layer, err := os.ReadFile(instance.layerFile)
+		require.NoError(t, err)
+		for _, blob := range [][]byte{config, layer} {
+			_, err := src.PutBlob(context.Background(), bytes.NewReader(blob), types.BlobInfo{Size: -1}, memory.New(), false)
+			require.NoError(t, err)
+		}
+		manifestBlob := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `",` +
+			`"config":{"mediaType":"` + imgspecv1.MediaTypeImageConfig + `","digest":"` + digest.FromBytes(config).String() + `","size":` + strconv.Itoa(len(config)) + `},` +
+			`"layers":[{"mediaType":"` + instance.layerMIMEType + `","digest":"` + digest.FromBytes(layer).String() + `","size":` + strconv.Itoa(len(layer)) + `}]}`)
+		manifestDigest := digest.FromBytes(manifestBlob)
+		err = src.PutManifest(context.Background(), manifestBlob, &manifestDigest)
+		require.NoError(t, err)
+		instanceDigests = append(instanceDigests, manifestDigest)
+		layerDigests = append(layerDigests, digest.FromBytes(layer))
+		manifestEntries = append(manifestEntries, `{"mediaType":"`+imgspecv1.MediaTypeImageManifest+`","digest":"`+manifestDigest.String()+`","size":`+strconv.Itoa(len(manifestBlob))+`,"platform":{"architecture":"`+instance.arch+`","os":"linux"}}`)
+	}
+	index := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageIndex + `","manifests":[` + strings.Join(manifestEntries, ",") + `]}`)
+	err = src.PutManifest(context.Background(), index, nil)
+	require.NoError(t, err)
+	err = src.Commit(context.Background(), nil)
+	require.NoError(t, err)
+
+	// SkipUnavailableInstances doesn’t skip instances failing for other reasons
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), failingBlobReference{ImageReference: destRef, failingDigest: layerDigests[1]}, srcRef, &Options{
+		ImageListSelection:       CopyAllImages,
+		SkipUnavailableInstances: true,
+	})
+	assert.ErrorContains(t, err, "upload failed")
+
+	// With SkipFailedInstances, the instance which failed to upload is removed from the list
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	report := Report{}
+	copiedManifest, err := Image(context.Background(), acceptAnythingPolicyContext(t), failingBlobReference{ImageReference: destRef, failingDigest: layerDigests[1]}, srcRef, &Options{
+		ImageListSelection:  CopyAllImages,
+		SkipFailedInstances: true,
+		Report:              &report,
+	})
+	require.NoError(t, err)
+	require.Len(t, report.SkippedInstances, 1)
+	assert.Equal(t, instanceDigests[1], report.SkippedInstances[0].Digest)
+	assert.Equal(t, &imgspecv1.Platform{Architecture: "arm64", OS: "linux"}, report.SkippedInstances[0].Platform)
+	assert.ErrorContains(t, report.SkippedInstances[0].Err, "upload failed")
+	list, err := internalManifest.ListFromBlob(copiedManifest, internalManifest.GuessMIMEType(copiedManifest))
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{instanceDigests[0]}, list.Instances())
+
+	// No instance could be copied: fail
+	destRef, err = directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), failingBlobReference{ImageReference: destRef, failingDigest: layerDigests[0]}, srcRef, &Options{
+		ImageListSelection:  CopySpecificImages,
+		Instances:           []digest.Digest{instanceDigests[0]},
+		SkipFailedInstances: true,
+	})
+	assert.Error(t, err)
+}
+
+func TestIsSourceNotFoundError(t *testing.T) {
+	for _, c := range []struct {
+		err      error
+		expected bool
+	}{
+		{sourceReadError{fs.ErrNotExist}, true},
+		{fmt.Errorf("reading blob: %w", sourceReadError{&fs.PathError{Op: "open", Path: "/x", Err: syscall.ENOENT}}), true},
+		{sourceReadError{v2.ErrorCodeManifestUnknown}, true},
+		{sourceReadError{v2.ErrorCodeBlobUnknown.WithMessage("blob unknown")}, true},
+		// Other source errors
+		{sourceReadError{errors.New("connection refused")}, false},
+		{sourceReadError{errcode.ErrorCodeUnauthorized}, false},
+		{sourceReadError{errcode.ErrorCodeDenied.WithMessage("access denied")}, false},
+		// Not source errors
+		{fs.ErrNotExist, false},
+		{fmt.Errorf("writing blob: %w", v2.ErrorCodeBlobUnknown), false},
+		{fmt.Errorf("Source image rejected: %w", errors.New("Running image docker://busybox:latest is rejected by policy.")), false},
+		{nil, false},
+	} {
+		res := isSourceNotFoundError(c.err)
+		assert.Equal(t, c.expected, res, "%#v", c.err)
+	}
+}
+
+func TestImagePreserveListDigest(t *testing.T) {
+	srcRef, availableDigest, _ := createTestImageList(t)
+	src, err := srcRef.NewImageSource(context.Background(), nil)
+	require.NoError(t, err)
+	sourceList, _, err := src.GetManifest(context.Background(), nil)
+	require.NoError(t, err)
+	err = src.Close()
+	require.NoError(t, err)
+
+	// Success
+	destRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	copiedManifest, err := Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection: CopySpecificImages,
+		Instances:          []digest.Digest{availableDigest},
+		PreserveListDigest: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sourceList, copiedManifest)
+
+	// The list would have to be converted: fail
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection:    CopySpecificImages,
+		Instances:             []digest.Digest{availableDigest},
+		ForceManifestMIMEType: manifest.DockerV2ListMediaType,
+		PreserveListDigest:    true,
+	})
+	assert.Error(t, err)
+
+	// Incompatible options
+	for _, options := range []Options{
+		{ImageListSelection: CopySystemImage, PreserveListDigest: true},
+		{ImageListSelection: CopyAllImages, SkipUnavailableInstances: true, PreserveListDigest: true},
+		{ImageListSelection: CopyAllImages, SkipFailedInstances: true, PreserveListDigest: true},
+		{
+			ImageListSelection:             CopyAllImages,
+			EnsureCompressionVariantsExist: []OptionCompressionVariant{{Algorithm: compression.Zstd}},
+			PreserveListDigest:             true,
+		},
+	} {
+		_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &options)
+		assert.Error(t, err, "%#v", options)
+	}
+
+	// The source is not a list: fail
+	singleRef, _, _ := createTestImage(t)
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, singleRef, &Options{
+		ImageListSelection: CopyAllImages,
+		PreserveListDigest: true,
+	})
+	assert.Error(t, err)
+}
+
+func TestImageSkipExistingInstances(t *testing.T) {
+	srcRef, availableDigest, missingDigest := createTestImageList(t)
+	destRef, err := layout.NewReference(t.TempDir(), "latest")
+	require.NoError(t, err)
+
+	// If the destination does not exist, instances are copied as usual.
+	var report bytes.Buffer
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection:    CopySpecificImages,
+		Instances:             []digest.Digest{availableDigest},
+		SkipExistingInstances: true,
+		ReportWriter:          &report,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, report.String(), "Copying image "+availableDigest.String())
+
+	// The destination now lists both instances (although only one was copied), so the missing one would be
+	// read from the source only if it were not skipped.
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{ImageListSelection: CopyAllImages})
+	assert.Error(t, err)
+	report.Reset()
+	copiedManifest, err := Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection:    CopyAllImages,
+		SkipExistingInstances: true,
+		ReportWriter:          &report,
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, report.String(), "Copying image ")
+	assert.Contains(t, report.String(), "Skipping image "+missingDigest.String())
+	list, err := internalManifest.ListFromBlob(copiedManifest, internalManifest.GuessMIMEType(copiedManifest))
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{availableDigest, missingDigest}, list.Instances())
+
+	// Instances which are skipped are still subject to the policy.
+	rejectPolicyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRReject()},
+	})
+	require.NoError(t, err)
+	defer func() { _ = rejectPolicyContext.Destroy() }()
+	_, err = Image(context.Background(), rejectPolicyContext, destRef, srcRef, &Options{
+		ImageListSelection:    CopyAllImages,
+		SkipExistingInstances: true,
+	})
+	assert.ErrorContains(t, err, "Source image rejected")
+
+	// Incompatible options
+	_, err = Image(context.Background(), acceptAnythingPolicyContext(t), destRef, srcRef, &Options{
+		ImageListSelection:             CopyAllImages,
+		EnsureCompressionVariantsExist: []OptionCompressionVariant{{Algorithm: compression.Zstd}},
+		SkipExistingInstances:          true,
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+package copy
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/containers/image/v5/types"
+)
+
+// Phase identifies a part of the work done by Image, as reported to PhaseHooks.
+type Phase string
+
+const (
+	// PhaseResolve is reading the top-level source manifest, to determine what to copy.
+	PhaseResolve Phase = "resolve"
+	// PhaseDownload is reading a blob from the source, until the end of the blob is reached.
+	PhaseDownload Phase = "download"
+	// PhaseDecompress is decompressing a blob.
+	PhaseDecompress Phase = "decompress"
+	// PhaseCompress is compressing an uncompressed blob, or recompressing a blob using a different algorithm.
+	PhaseCompress Phase = "compress"
+	// PhaseUpload is writing a blob to the destination.
+	PhaseUpload Phase = "upload"
+	// PhaseManifestPush is writing a manifest or manifest list to the destination.
+	PhaseManifestPush Phase = "manifest-push"
+	// PhaseSign is creating signatures.
+	PhaseSign Phase = "sign"
+)
+
+// PhaseEvent describes the start or the end of a phase.
+//
+// Blobs are copied as a stream, so the PhaseDownload, PhaseDecompress, PhaseCompress and PhaseUpload phases of a single blob overlap;
+// a phase which ends much later than the phases before it in the pipeline is likely to be the bottleneck.
+type PhaseEvent struct {
+	Phase       Phase
+	OperationID string         // See Options.OperationID
+	Blob        types.BlobInfo // The source blob, for PhaseDownload, PhaseDecompress, PhaseCompress and PhaseUpload
+
+	// The following fields are only set for PhaseHooks.PhaseFinished:
+	Duration time.Duration
+	// Bytes read (PhaseDownload), produced (PhaseDecompress, PhaseCompress), or written (PhaseUpload, PhaseManifestPush);
+	// -1 if not applicable or unknown.
+	Bytes int64
+	Err   error // nil if the phase succeeded
+}
+
+// PhaseHooks receives notifications about the phases of Image.
+// The methods may be called concurrently (e.g. for blobs copied in parallel), and they should return quickly.
+type PhaseHooks interface {
+	PhaseStarted(event PhaseEvent)
+	PhaseFinished(event PhaseEvent)
+}
+
+// phaseTracker reports a single phase to PhaseHooks.
+// A nil *phaseTracker is valid and does nothing.
+type phaseTracker struct {
+	hooks PhaseHooks
+	event PhaseEvent
+	start time.Time
+	once  sync.Once
+}
+
+// startPhase reports that phase has started, and returns a tracker to report its end.
+// blob should be set for phases processing blobs.
+func (c *copier) startPhase(phase Phase, blob types.BlobInfo) *phaseTracker {
+	if c.phaseHooks == nil {
+		return nil
+	}
+	p := &phaseTracker{
+		hooks: c.phaseHooks,
+		event: PhaseEvent{
+			Phase:       phase,
+			OperationID: c.operationID,
+			Blob:        blob,
+		},
+		start: time.Now(),
+	}
+	p.hooks.PhaseStarted(p.event)
+	return p
+}
+
+// finish reports the end of the phase, if it was not already reported.
+func (p *phaseTracker) finish(bytes int64, err error) {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		event := p.event
+		event.Duration = time.Since(p.start)
+		event.Bytes = bytes
+		event.Err = err
+		p.hooks.PhaseFinished(event)
+	})
+}
+
+// phaseReader passes data from reader, and finishes phase when reader reaches EOF or fails.
+type phaseReader struct {
+	reader io.Reader
+	phase  *phaseTracker
+	count  atomic.Int64 // Atomic because the reader may be consumed by a separate goroutine, e.g. in compressGoroutine.
+}
+
+// newPhaseReader returns a reader which finishes phase at the end of reader, or reader itself if phase is nil.
+// The caller should also call finishPhaseReader, to report the end of the phase if the end of reader is never reached.
+func newPhaseReader(reader io.Reader, phase *phaseTracker) io.Reader {
+	if phase == nil {
+		return reader
+	}
+	return &phaseReader{reader: reader, phase: phase}
+}
+
+func (r *phaseReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	count := r.count.Add(int64(n))
+	if err == io.EOF {
+		r.phase.finish(count, nil)
+	} else if err != nil {
+		r.phase.finish(count, err)
+	}
+	return n, err
+}
+
+// finishPhaseReader reports the end of the phase of reader, if it was created by newPhaseReader
+// and it has not already reported it; err is the outcome of the operation consuming the reader.
+func finishPhaseReader(reader io.Reader, err error) {
+	if r, ok := reader.(*phaseReader); ok {
+		r.phase.finish(r.count.Load(), err)
+	}
+}
@@ -7,12 +7,13 @@ import (
 	"github.com/containers/image/v5/types"
 )
 
-// progressReader is a reader that reports its progress to a types.ProgressProperties channel on an interval.
+// progressReader is a reader that reports its progress as types.ProgressProperties on an interval.
 type progressReader struct {
 	source       io.Reader
-	channel      chan<- types.ProgressProperties
+	report       func(types.ProgressProperties)
 	interval     time.Duration
 	artifact     types.BlobInfo
+	operationID  string
 	lastUpdate   time.Time
 	offset       uint64
 	offsetUpdate uint64
@@ -20,45 +21,50 @@ type progressReader struct {
 
 // newProgressReader creates a new progress reader for:
 // `source`:   The source when internally reading bytes
-// `channel`:  The reporter channel to which the progress will be sent
+// `report`:   The function to which the progress will be sent, e.g. writing to an Options.Progress channel
 // `interval`: The update interval to indicate how often the progress should update
 // `artifact`: The blob metadata which is currently being progressed
+// `operationID`: The ID of the copy operation, included in all events
 func newProgressReader(
 	source io.Reader,
-	channel chan<- types.ProgressProperties,
+	report func(types.ProgressProperties),
 	interval time.Duration,
 	artifact types.BlobInfo,
+	operationID string,
 ) *progressReader {
-	// The progress reader constructor informs the progress channel
+	// The progress reader constructor informs the progress consumer
 	// that a new artifact will be read
-	channel <- types.ProgressProperties{
-		Event:    types.ProgressEventNewArtifact,
-		Artifact: artifact,
-	}
+	report(types.ProgressProperties{
+		Event:       types.ProgressEventNewArtifact,
+		Artifact:    artifact,
+		OperationID: operationID,
+	})
 	return &progressReader{
 		source:       source,
-		channel:      channel,
+		report:       report,
 		interval:     interval,
 		artifact:     artifact,
+		operationID:  operationID,
 		lastUpdate:   time.Now(),
 		offset:       0,
 		offsetUpdate: 0,
 	}
 }
 
-// reportDone indicates to the internal channel that the progress has been
+// reportDone indicates to the progress consumer that the progress has been
 // finished
 func (r *progressReader) reportDone() {
-	r.channel <- types.ProgressProperties{
+	r.report(types.ProgressProperties{
 		Event:        types.ProgressEventDone,
 		Artifact:     r.artifact,
 		Offset:       r.offset,
 		OffsetUpdate: r.offsetUpdate,
-	}
+		OperationID:  r.operationID,
+	})
 }
 
 // Read continuously reads bytes into the progress reader and reports the
-// status via the internal channel
+// status to the progress consumer
 func (r *progressReader) Read(p []byte) (int, error) {
 	n, err := r.source.Read(p)
 	r.offset += uint64(n)
@@ -66,12 +72,13 @@ func (r *progressReader) Read(p []byte) (int, error) {
 
 	// Fire the progress reader in the provided interval
 	if time.Since(r.lastUpdate) > r.interval {
-		r.channel <- types.ProgressProperties{
+		r.report(types.ProgressProperties{
 			Event:        types.ProgressEventRead,
 			Artifact:     r.artifact,
 			Offset:       r.offset,
 			OffsetUpdate: r.offsetUpdate,
-		}
+			OperationID:  r.operationID,
+		})
 		r.lastUpdate = time.Now()
 		r.offsetUpdate = 0
 	}
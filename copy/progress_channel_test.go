@@ -22,8 +22,9 @@ func newSUT(
 		res := <-channel
 		assert.Equal(t, res.Event, types.ProgressEventNewArtifact)
 		assert.Equal(t, res.Artifact, artifact)
+		assert.Equal(t, "operation-id", res.OperationID)
 	}()
-	res := newProgressReader(reader, channel, duration, artifact)
+	res := newProgressReader(reader, func(p types.ProgressProperties) { channel <- p }, duration, artifact, "operation-id")
 
 	return res
 }
@@ -38,6 +39,7 @@ func TestNewProgressReader(t *testing.T) {
 	go func() {
 		res := <-channel
 		assert.Equal(t, res.Event, types.ProgressEventDone)
+		assert.Equal(t, "operation-id", res.OperationID)
 	}()
 	sut.reportDone()
 }
@@ -72,6 +74,7 @@ func TestReadWithEvent(t *testing.T) {
 		assert.Equal(t, res.Event, types.ProgressEventRead)
 		assert.Equal(t, res.Offset, uint64(5))
 		assert.Equal(t, res.OffsetUpdate, uint64(5))
+		assert.Equal(t, "operation-id", res.OperationID)
 	}()
 	read, err := reader.Read(b)
 	assert.Equal(t, read, 5)
@@ -0,0 +1,241 @@
+package copy
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// defaultJSONProgressInterval is the interval between JSONProgressBlobProgress events if Options.ProgressInterval is not set.
+const defaultJSONProgressInterval = time.Second
+
+// JSONProgressEventType identifies the kind of a JSONProgressEvent.
+type JSONProgressEventType string
+
+const (
+	// JSONProgressCopyStarted is the first event of a copy.Image operation.
+	JSONProgressCopyStarted JSONProgressEventType = "copy-started"
+	// JSONProgressCopyFinished is the last event of a copy.Image operation; Error is set if the copy failed.
+	JSONProgressCopyFinished JSONProgressEventType = "copy-finished"
+	// JSONProgressPhaseStarted is the start of a Phase.
+	JSONProgressPhaseStarted JSONProgressEventType = "phase-started"
+	// JSONProgressPhaseFinished is the end of a Phase; Error is set if the phase failed.
+	JSONProgressPhaseFinished JSONProgressEventType = "phase-finished"
+	// JSONProgressBlobStarted is emitted when data of a blob starts being copied.
+	JSONProgressBlobStarted JSONProgressEventType = "blob-started"
+	// JSONProgressBlobProgress is emitted periodically while a blob is being copied.
+	JSONProgressBlobProgress JSONProgressEventType = "blob-progress"
+	// JSONProgressBlobFinished is emitted when copying a blob has ended.
+	JSONProgressBlobFinished JSONProgressEventType = "blob-finished"
+	// JSONProgressBlobSkipped is emitted when a blob is not copied because it already exists at the destination.
+	JSONProgressBlobSkipped JSONProgressEventType = "blob-skipped"
+	// JSONProgressLayerApplyStarted is emitted when the destination starts applying a layer whose data has been received.
+	JSONProgressLayerApplyStarted JSONProgressEventType = "layer-apply-started"
+	// JSONProgressLayerApplyProgress is emitted periodically while the destination is applying a layer.
+	JSONProgressLayerApplyProgress JSONProgressEventType = "layer-apply-progress"
+	// JSONProgressLayerApplyFinished is emitted when the destination has finished applying a layer.
+	JSONProgressLayerApplyFinished JSONProgressEventType = "layer-apply-finished"
+)
+
+// JSONProgressEvent is a single line written to Options.ProgressJSONWriter.
+// New event types and fields may be added in the future; consumers should ignore values they don’t recognize.
+type JSONProgressEvent struct {
+	Event       JSONProgressEventType `json:"event"`
+	Time        time.Time             `json:"time"`
+	OperationID string                `json:"operationID"` // See Options.OperationID
+	// Source and Destination are the transport-qualified image names, only set for JSONProgressCopyStarted.
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// Phase is only set for JSONProgressPhaseStarted and JSONProgressPhaseFinished.
+	Phase Phase `json:"phase,omitempty"`
+	// Digest and MediaType identify the source blob, for blob events and phases processing blobs.
+	Digest    digest.Digest `json:"digest,omitempty"`
+	MediaType string        `json:"mediaType,omitempty"`
+	// Bytes is the number of bytes copied so far (blob events), or processed by the phase (JSONProgressPhaseFinished);
+	// omitted if zero or unknown.
+	Bytes int64 `json:"bytes,omitempty"`
+	// Total is the size of the source blob, if known.
+	Total int64 `json:"total,omitempty"`
+	// DurationMS is the duration of the phase in milliseconds, only set for JSONProgressPhaseFinished.
+	DurationMS int64 `json:"durationMS,omitempty"`
+	// Error is set if the phase or the copy failed.
+	Error string `json:"error,omitempty"`
+}
+
+// jsonProgressWriter writes JSONProgressEvents, one per line, to a writer.
+// It is safe for concurrent use.
+type jsonProgressWriter struct {
+	operationID string
+
+	mutex   sync.Mutex // Protects the members below
+	encoder *json.Encoder
+	failed  bool // A write has failed; don’t try writing any more events.
+}
+
+// newJSONProgressWriter returns a jsonProgressWriter for events of operationID, writing to writer.
+func newJSONProgressWriter(writer io.Writer, operationID string) *jsonProgressWriter {
+	return &jsonProgressWriter{
+		operationID: operationID,
+		encoder:     json.NewEncoder(writer),
+	}
+}
+
+// emit fills in the common fields of event, and writes it.
+func (w *jsonProgressWriter) emit(event JSONProgressEvent) {
+	event.Time = time.Now().UTC()
+	event.OperationID = w.operationID
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.failed {
+		return
+	}
+	if err := w.encoder.Encode(event); err != nil {
+		// Failing the copy just because the progress consumer went away would be unhelpful.
+		log.Debugf("Error writing JSON progress, not writing any more events: %v", err)
+		w.failed = true
+	}
+}
+
+// blobEvent returns a JSONProgressEvent of type eventType for blob.
+func blobEvent(eventType JSONProgressEventType, blob types.BlobInfo) JSONProgressEvent {
+	res := JSONProgressEvent{
+		Event:     eventType,
+		Digest:    blob.Digest,
+		MediaType: blob.MediaType,
+	}
+	if blob.Size > 0 {
+		res.Total = blob.Size
+	}
+	return res
+}
+
+// copyStarted reports the start of a copy from src to dest.
+func (w *jsonProgressWriter) copyStarted(src, dest string) {
+	w.emit(JSONProgressEvent{
+		Event:       JSONProgressCopyStarted,
+		Source:      src,
+		Destination: dest,
+	})
+}
+
+// copyFinished reports the end of the copy, with err as its outcome.
+func (w *jsonProgressWriter) copyFinished(err error) {
+	event := JSONProgressEvent{Event: JSONProgressCopyFinished}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	w.emit(event)
+}
+
+// PhaseStarted implements PhaseHooks.
+func (w *jsonProgressWriter) PhaseStarted(event PhaseEvent) {
+	res := blobEvent(JSONProgressPhaseStarted, event.Blob)
+	res.Phase = event.Phase
+	w.emit(res)
+}
+
+// PhaseFinished implements PhaseHooks.
+func (w *jsonProgressWriter) PhaseFinished(event PhaseEvent) {
+	res := blobEvent(JSONProgressPhaseFinished, event.Blob)
+	res.Phase = event.Phase
+	if event.Bytes > 0 {
+		res.Bytes = event.Bytes
+	}
+	res.DurationMS = event.Duration.Milliseconds()
+	if event.Err != nil {
+		res.Error = event.Err.Error()
+	}
+	w.emit(res)
+}
+
+// reportProgress converts progress, as reported to Options.Progress, into a JSONProgressEvent.
+func (w *jsonProgressWriter) reportProgress(progress types.ProgressProperties) {
+	var eventType JSONProgressEventType
+	switch progress.Event {
+	case types.ProgressEventNewArtifact:
+		eventType = JSONProgressBlobStarted
+	case types.ProgressEventRead:
+		eventType = JSONProgressBlobProgress
+	case types.ProgressEventDone:
+		eventType = JSONProgressBlobFinished
+	case types.ProgressEventSkipped:
+		eventType = JSONProgressBlobSkipped
+	case types.ProgressEventApplyStarted:
+		eventType = JSONProgressLayerApplyStarted
+	case types.ProgressEventApplyRead:
+		eventType = JSONProgressLayerApplyProgress
+	case types.ProgressEventApplyDone:
+		eventType = JSONProgressLayerApplyFinished
+	default:
+		return
+	}
+	res := blobEvent(eventType, progress.Artifact)
+	res.Bytes = int64(progress.Offset)
+	w.emit(res)
+}
+
+// multiPhaseHooks reports phases to several PhaseHooks.
+type multiPhaseHooks []PhaseHooks
+
+func (hooks multiPhaseHooks) PhaseStarted(event PhaseEvent) {
+	for _, h := range hooks {
+		h.PhaseStarted(event)
+	}
+}
+
+func (hooks multiPhaseHooks) PhaseFinished(event PhaseEvent) {
+	for _, h := range hooks {
+		h.PhaseFinished(event)
+	}
+}
+
+// setupProgressReporting sets c.phaseHooks, c.reportProgress and c.progressInterval
+// based on c.options.PhaseHooks, c.options.Progress and c.jsonProgress.
+func (c *copier) setupProgressReporting() {
+	var hooks multiPhaseHooks
+	if c.options.PhaseHooks != nil {
+		hooks = append(hooks, c.options.PhaseHooks)
+	}
+	var reporters []func(types.ProgressProperties)
+	if c.options.Progress != nil && c.options.ProgressInterval > 0 {
+		channel := c.options.Progress
+		reporters = append(reporters, func(progress types.ProgressProperties) {
+			channel <- progress
+		})
+	}
+	if c.jsonProgress != nil {
+		hooks = append(hooks, c.jsonProgress)
+		reporters = append(reporters, c.jsonProgress.reportProgress)
+	}
+
+	switch len(hooks) {
+	case 0:
+		c.phaseHooks = nil
+	case 1:
+		c.phaseHooks = hooks[0]
+	default:
+		c.phaseHooks = hooks
+	}
+	switch len(reporters) {
+	case 0:
+		c.reportProgress = nil
+	case 1:
+		c.reportProgress = reporters[0]
+	default:
+		c.reportProgress = func(progress types.ProgressProperties) {
+			for _, r := range reporters {
+				r(progress)
+			}
+		}
+	}
+	c.progressInterval = c.options.ProgressInterval
+	if c.progressInterval <= 0 {
+		c.progressInterval = defaultJSONProgressInterval // Only relevant if c.jsonProgress is set.
+	}
+}
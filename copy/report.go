@@ -0,0 +1,43 @@
+package copy
+
+import (
+	"fmt"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Report describes the outcome of a successful copy, beyond the written manifest. See Options.Report.
+type Report struct {
+	// SkippedInstances lists the instances of a list of images which were not copied due to SkipUnavailableInstances
+	// or SkipFailedInstances, in the order in which they were attempted.
+	SkippedInstances []SkippedInstance
+}
+
+// SkippedInstance describes an instance of a list of images which failed to copy and was skipped.
+type SkippedInstance struct {
+	Digest digest.Digest // The digest of the instance in the source list
+	// The platform of the instance, as listed in the source list; nil if the list does not specify it.
+	Platform *imgspecv1.Platform
+	// Replica is true if the failure happened while creating an additional compression variant
+	// (see EnsureCompressionVariantsExist); the original instance is still in the written list in that case.
+	Replica bool
+	Err     error // The reason why the instance was skipped
+}
+
+// formatPlatform returns a human-readable description of platform, e.g. "linux/arm64/v8", or "unknown platform".
+func formatPlatform(platform *imgspecv1.Platform) string {
+	if platform == nil || (platform.OS == "" && platform.Architecture == "") {
+		return "unknown platform"
+	}
+	parts := []string{platform.OS, platform.Architecture}
+	if platform.Variant != "" {
+		parts = append(parts, platform.Variant)
+	}
+	res := strings.Join(parts, "/")
+	if platform.OSVersion != "" {
+		res = fmt.Sprintf("%s (%s)", res, platform.OSVersion)
+	}
+	return res
+}
@@ -0,0 +1,182 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/manifest"
+	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// LayerScanner inspects the contents of layers while they are being copied, see Options.LayerScanner.
+type LayerScanner interface {
+	// ScanLayer is called for each layer read from the source, with a stream of the uncompressed (and decrypted, if applicable)
+	// contents of the layer, as it is being copied. It may be called concurrently for layers copied in parallel.
+	//
+	// ScanLayer should read stream until EOF; the copy of the layer does not make progress while ScanLayer is not reading.
+	// If ScanLayer returns nil before reaching EOF, the rest of the stream is discarded.
+	// If it returns an error, the copy is aborted with a *LayerRejectedError, before the manifest is written to the destination.
+	// If the copy fails for other reasons, reading stream fails, and the value returned by ScanLayer is ignored.
+	ScanLayer(ctx context.Context, layer LayerScanInfo, stream io.Reader) error
+}
+
+// LayerScanInfo describes a layer passed to LayerScanner.ScanLayer.
+type LayerScanInfo struct {
+	Source         types.ImageReference // The source reference; for instances of multi-platform images, this refers to the list.
+	ManifestDigest digest.Digest        // The digest of the source manifest of the single-platform image containing the layer
+	LayerIndex     int                  // The index of the layer in the manifest
+	Blob           types.BlobInfo       // The source blob, as listed in the manifest
+}
+
+// LayerRejectedError is returned by Image if Options.LayerScanner has rejected a layer.
+type LayerRejectedError struct {
+	LayerIndex int
+	Digest     digest.Digest // The digest of the source blob
+	Err        error         // The error returned by LayerScanner.ScanLayer
+}
+
+func (e *LayerRejectedError) Error() string {
+	return fmt.Sprintf("layer %d (%s) rejected by scanner: %v", e.LayerIndex, e.Digest, e.Err)
+}
+
+func (e *LayerRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// bpLayerScanStepData contains data that the copy pipeline needs about the layer scanning step.
+type bpLayerScanStepData struct {
+	tee        io.Reader      // The stream which sends data to the scanner, nil if the step does nothing
+	pipeWriter *io.PipeWriter // Feeds the scanning goroutine
+	done       chan struct{}  // Closed when the scanning goroutine exits
+	err        error          // Set by the scanning goroutine; valid only after done is closed
+	finished   bool
+}
+
+// blobPipelineLayerScanStep updates *stream, the contents of srcInfo, to send a copy of its uncompressed contents to ic.c.options.LayerScanner, if any.
+// decrypting is true if the stream has been decrypted by this copy.
+// The caller must call .finish() after the stream has been successfully copied, or .abort() otherwise.
+func (ic *imageCopier) blobPipelineLayerScanStep(ctx context.Context, stream *sourceStream, srcInfo types.BlobInfo, isConfig bool,
+	detected bpDetectCompressionStepData, decrypting bool, layerIndex int) (*bpLayerScanStepData, error) {
+	res := &bpLayerScanStepData{}
+	scanner := ic.c.options.LayerScanner
+	if scanner == nil || isConfig || (isOciEncrypted(srcInfo.MediaType) && !decrypting) {
+		return res, nil
+	}
+	manifestDigest, err := manifest.Digest(ic.src.ManifestBlob)
+	if err != nil {
+		return nil, err
+	}
+	info := LayerScanInfo{
+		Source:         ic.c.rawSource.Reference(),
+		ManifestDigest: manifestDigest,
+		LayerIndex:     layerIndex,
+		Blob:           srcInfo,
+	}
+	var decompressor compressiontypes.DecompressorFunc // = nil
+	if detected.isCompressed {
+		decompressor = detected.decompressor
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	res.pipeWriter = pipeWriter
+	res.done = make(chan struct{})
+	go res.scan(ctx, scanner, info, pipeReader, decompressor)
+	stream.reader = io.TeeReader(stream.reader, pipeWriter)
+	res.tee = stream.reader
+	return res, nil
+}
+
+// scan runs scanner on the contents of pipeReader, decompressing them using decompressor if it is not nil.
+// If the scanner rejects the layer, pipeReader is closed with an error, which fails the TeeReader, and so the rest of the copy.
+func (d *bpLayerScanStepData) scan(ctx context.Context, scanner LayerScanner, info LayerScanInfo, pipeReader *io.PipeReader,
+	decompressor compressiontypes.DecompressorFunc) {
+	defer close(d.done)
+	d.err = errors.New("Internal error: unexpected panic in layer scanning")
+	defer func() { _ = pipeReader.CloseWithError(d.err) }() // CloseWithError(nil) is equivalent to Close()
+
+	d.err = scanLayer(ctx, scanner, info, pipeReader, decompressor)
+}
+
+// scanLayer runs scanner on the contents of input, decompressing them using decompressor if it is not nil, and consumes all of input.
+// It returns nil if reading input failed, because the copy fails for that reason anyway.
+func scanLayer(ctx context.Context, scanner LayerScanner, info LayerScanInfo, input io.Reader, decompressor compressiontypes.DecompressorFunc) error {
+	source := &readErrorRecorder{reader: input}
+	var stream io.Reader = source
+	if decompressor != nil {
+		s, err := decompressor(stream)
+		if err != nil {
+			if source.err != nil {
+				return nil
+			}
+			return fmt.Errorf("decompressing layer %s for scanning: %w", info.Blob.Digest, err)
+		}
+		defer s.Close()
+		stream = s
+	}
+
+	if err := scanner.ScanLayer(ctx, info, stream); err != nil {
+		if source.err != nil {
+			return nil
+		}
+		return &LayerRejectedError{LayerIndex: info.LayerIndex, Digest: info.Blob.Digest, Err: err}
+	}
+	// Consume the rest of the input, including any data following the compressed stream, so that the TeeReader does not block.
+	// (Some decompressors’ WriteTo, used by io.Copy, returns io.EOF if the scanner has already read everything.)
+	if _, err := io.Copy(io.Discard, stream); err != nil && err != io.EOF && source.err == nil {
+		return fmt.Errorf("decompressing layer %s for scanning: %w", info.Blob.Digest, err)
+	}
+	_, _ = io.Copy(io.Discard, source)
+	return nil
+}
+
+// finish sends the rest of the stream to the scanner, waits for it to finish, and returns its outcome.
+func (d *bpLayerScanStepData) finish() error {
+	if d.tee == nil {
+		return nil
+	}
+	if _, err := io.Copy(io.Discard, d.tee); err != nil {
+		if rejected := d.abort(err); rejected != nil {
+			return rejected
+		}
+		return fmt.Errorf("reading layer for scanning: %w", err)
+	}
+	_ = d.abort(nil)
+	return d.err
+}
+
+// abort closes the stream to the scanner using err, waits for the scanner to finish,
+// and returns a *LayerRejectedError if the scanner has rejected the layer, or nil.
+// It may be called more than once.
+func (d *bpLayerScanStepData) abort(err error) error {
+	if d.tee == nil {
+		return nil
+	}
+	if !d.finished {
+		d.finished = true
+		_ = d.pipeWriter.CloseWithError(err) // CloseWithError(nil) is equivalent to Close()
+		<-d.done
+	}
+	var rejected *LayerRejectedError
+	if errors.As(d.err, &rejected) {
+		return rejected
+	}
+	return nil
+}
+
+// readErrorRecorder records the first error other than io.EOF returned by reader.
+type readErrorRecorder struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *readErrorRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
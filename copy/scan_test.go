@@ -0,0 +1,101 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// layerScannerFunc is a LayerScanner implemented by a function.
+type layerScannerFunc func(ctx context.Context, layer LayerScanInfo, stream io.Reader) error
+
+func (f layerScannerFunc) ScanLayer(ctx context.Context, layer LayerScanInfo, stream io.Reader) error {
+	return f(ctx, layer, stream)
+}
+
+// failingReader returns data, and then fails with err.
+type failingReader struct {
+	data *bytes.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestScanLayer(t *testing.T) {
+	data := []byte("layer contents")
+	var compressed bytes.Buffer
+	w, err := compression.CompressStream(&compressed, compression.Gzip, nil)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	rejection := errors.New("rejected")
+	readAll := func(result *[]byte, err error) LayerScanner {
+		return layerScannerFunc(func(ctx context.Context, layer LayerScanInfo, stream io.Reader) error {
+			contents, readErr := io.ReadAll(stream)
+			*result = contents
+			if readErr != nil {
+				return readErr
+			}
+			return err
+		})
+	}
+
+	// Uncompressed and compressed input
+	for _, c := range []struct {
+		input        []byte
+		decompressor bool
+	}{
+		{data, false},
+		{compressed.Bytes(), true},
+	} {
+		var res []byte
+		input := bytes.NewReader(c.input)
+		decompressor := compression.GzipDecompressor
+		if !c.decompressor {
+			decompressor = nil
+		}
+		err := scanLayer(context.Background(), readAll(&res, nil), LayerScanInfo{}, input, decompressor)
+		require.NoError(t, err)
+		assert.Equal(t, data, res)
+		assert.Equal(t, 0, input.Len())
+	}
+
+	// The scanner rejects the layer
+	var res []byte
+	err = scanLayer(context.Background(), readAll(&res, rejection), LayerScanInfo{LayerIndex: 1}, bytes.NewReader(data), nil)
+	var rejected *LayerRejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, 1, rejected.LayerIndex)
+	assert.ErrorIs(t, err, rejection)
+
+	// The input is consumed even if the scanner returns early
+	input := bytes.NewReader(compressed.Bytes())
+	err = scanLayer(context.Background(), layerScannerFunc(func(ctx context.Context, layer LayerScanInfo, stream io.Reader) error {
+		return nil
+	}), LayerScanInfo{}, input, compression.GzipDecompressor)
+	require.NoError(t, err)
+	assert.Equal(t, 0, input.Len())
+
+	// Reading the input fails: the scanner’s result is ignored
+	err = scanLayer(context.Background(), readAll(&res, rejection), LayerScanInfo{},
+		&failingReader{data: bytes.NewReader(data), err: errors.New("copy failed")}, nil)
+	assert.NoError(t, err)
+
+	// Decompression fails
+	err = scanLayer(context.Background(), readAll(&res, nil), LayerScanInfo{}, bytes.NewReader(data), compression.GzipDecompressor)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, rejection)
+}
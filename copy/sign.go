@@ -8,9 +8,12 @@ import (
 	"github.com/containers/image/v5/internal/private"
 	internalsig "github.com/containers/image/v5/internal/signature"
 	internalSigner "github.com/containers/image/v5/internal/signer"
+	"github.com/containers/image/v5/internal/tracing"
 	"github.com/containers/image/v5/signature/sigstore"
 	"github.com/containers/image/v5/signature/simplesigning"
 	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // setupSigners initializes c.signers.
@@ -50,6 +53,17 @@ func (c *copier) setupSigners() error {
 	return nil
 }
 
+// shouldSignImage returns true if copySingleImage should create signatures for an image;
+// isListInstance is true if the image is being copied as an instance of a list of images.
+func (c *copier) shouldSignImage(isListInstance bool) bool {
+	return len(c.signers) != 0 && (!isListInstance || c.options.ImageListSigning != SignListOnly)
+}
+
+// shouldSignList returns true if copyMultipleImages should create signatures for the list of images.
+func (c *copier) shouldSignList() bool {
+	return len(c.signers) != 0 && c.options.ImageListSigning != SignInstancesOnly
+}
+
 // sourceSignatures returns signatures from unparsedSource,
 // and verifies that they can be used (to avoid copying a large image when we
 // can tell in advance that it would ultimately fail)
@@ -76,11 +90,17 @@ func (c *copier) sourceSignatures(ctx context.Context, unparsed private.Unparsed
 }
 
 // createSignatures creates signatures for manifest and an optional identity.
-func (c *copier) createSignatures(ctx context.Context, manifest []byte, identity reference.Named) ([]internalsig.Signature, error) {
+func (c *copier) createSignatures(ctx context.Context, manifest []byte, identity reference.Named) (_ []internalsig.Signature, retErr error) {
 	if len(c.signers) == 0 {
 		// We must exit early here, otherwise copies with no Docker reference wouldn’t be possible.
 		return nil, nil
 	}
+	ctx, span := tracing.Start(ctx, "copy.sign", attribute.Int("signers", len(c.signers)))
+	defer func() { tracing.End(span, retErr) }()
+	signPhase := c.startPhase(PhaseSign, types.BlobInfo{})
+	defer func() { signPhase.finish(-1, retErr) }()
+	ctx, finishTimeout := withPhaseTimeout(ctx, c.options.PhaseTimeouts.Signing, "signing")
+	defer func() { retErr = finishTimeout(retErr) }()
 
 	if identity != nil {
 		if reference.IsNameOnly(identity) {
@@ -101,7 +121,8 @@ func (c *copier) createSignatures(ctx context.Context, manifest []byte, identity
 		} else {
 			c.Printf("Creating signature %d: %s\n", signerIndex+1, msg)
 		}
-		newSig, err := internalSigner.SignImageManifest(ctx, signer, manifest, identity)
+		// A signer configured with several keys creates one signature per key, all for the same manifest.
+		newSigs, err := internalSigner.SignImageManifestWithAllKeys(ctx, signer, manifest, identity)
 		if err != nil {
 			if len(c.signers) == 1 {
 				return nil, fmt.Errorf("creating signature: %w", err)
@@ -109,7 +130,7 @@ func (c *copier) createSignatures(ctx context.Context, manifest []byte, identity
 				return nil, fmt.Errorf("creating signature %d: %w", signerIndex, err)
 			}
 		}
-		res = append(res, newSig)
+		res = append(res, newSigs...)
 	}
 	return res, nil
 }
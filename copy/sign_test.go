@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strconv"
 	"testing"
 
 	"github.com/containers/image/v5/directory"
@@ -39,6 +40,20 @@ func (s *stubSignerImpl) Close() error {
 	return nil
 }
 
+// stubMultipleKeysSignerImpl is a stubSignerImpl which creates one signature per key, recording the key index in the annotations.
+type stubMultipleKeysSignerImpl struct {
+	stubSignerImpl
+	keys int
+}
+
+func (s *stubMultipleKeysSignerImpl) SignImageManifestWithAllKeys(ctx context.Context, m []byte, dockerReference reference.Named) ([]internalsig.Signature, error) {
+	res := []internalsig.Signature{}
+	for i := 0; i < s.keys; i++ {
+		res = append(res, internalsig.SigstoreFromComponents(dockerReference.String(), m, map[string]string{"key": strconv.Itoa(i)}))
+	}
+	return res, nil
+}
+
 func TestCreateSignatures(t *testing.T) {
 	stubSigner := internalSigner.NewSigner(&stubSignerImpl{})
 	defer stubSigner.Close()
@@ -138,7 +153,7 @@ func TestCreateSignatures(t *testing.T) {
 			options:      options,
 			reportWriter: io.Discard,
 		}
-		defer c.close()
+		defer c.close(context.Background())
 		err := c.setupSigners()
 		require.NoError(t, err, cc.name)
 		sigs, err := c.createSignatures(context.Background(), manifestBlob, identity)
@@ -161,3 +176,69 @@ func TestCreateSignatures(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateSignaturesWithMultipleKeys(t *testing.T) {
+	dockerRef, err := docker.ParseReference("//busybox")
+	require.NoError(t, err)
+	dockerDest, err := dockerRef.NewImageDestination(context.Background(),
+		&types.SystemContext{RegistriesDirPath: "/this/does/not/exist", DockerPerHostCertDirPath: "/this/does/not/exist"})
+	require.NoError(t, err)
+	defer dockerDest.Close()
+
+	singleKeySigner := internalSigner.NewSigner(&stubSignerImpl{})
+	defer singleKeySigner.Close()
+	multipleKeysSigner := internalSigner.NewSigner(&stubMultipleKeysSignerImpl{keys: 3})
+	defer multipleKeysSigner.Close()
+
+	manifestBlob := []byte("Something")
+	c := &copier{
+		dest:         imagedestination.FromPublic(dockerDest),
+		options:      &Options{Signers: []*signer.Signer{multipleKeysSigner, singleKeySigner}},
+		reportWriter: io.Discard,
+	}
+	defer c.close(context.Background())
+	err = c.setupSigners()
+	require.NoError(t, err)
+	sigs, err := c.createSignatures(context.Background(), manifestBlob, nil)
+	require.NoError(t, err)
+	require.Len(t, sigs, 4)
+	for i, sig := range sigs {
+		stubSig, ok := sig.(internalsig.Sigstore)
+		require.True(t, ok)
+		assert.Equal(t, manifestBlob, stubSig.UntrustedPayload())
+		assert.Equal(t, "docker.io/library/busybox:latest", stubSig.UntrustedMIMEType())
+		if i < 3 {
+			assert.Equal(t, map[string]string{"key": strconv.Itoa(i)}, stubSig.UntrustedAnnotations())
+		} else {
+			assert.Empty(t, stubSig.UntrustedAnnotations())
+		}
+	}
+}
+
+func TestShouldSignImageAndList(t *testing.T) {
+	stubSigner := internalSigner.NewSigner(&stubSignerImpl{})
+	defer stubSigner.Close()
+
+	for _, c := range []struct {
+		signing                         ImageListSigning
+		signers                         []*signer.Signer
+		singleImage, listInstance, list bool
+	}{
+		{SignListAndInstances, nil, false, false, false},
+		{SignListOnly, nil, false, false, false},
+		{SignListAndInstances, []*signer.Signer{stubSigner}, true, true, true},
+		{SignListOnly, []*signer.Signer{stubSigner}, true, false, true},
+		{SignInstancesOnly, []*signer.Signer{stubSigner}, true, true, false},
+	} {
+		c2 := &copier{
+			options: &Options{ImageListSigning: c.signing},
+			signers: c.signers,
+		}
+		assert.Equal(t, c.singleImage, c2.shouldSignImage(false), c.signing)
+		assert.Equal(t, c.listInstance, c2.shouldSignImage(true), c.signing)
+		assert.Equal(t, c.list, c2.shouldSignList(), c.signing)
+	}
+
+	err := validateImageListSigning(ImageListSigning(99))
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"reflect"
 	"slices"
 	"strings"
@@ -13,19 +14,22 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/pkg/platform"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/internal/tracing"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/compression"
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
+	"github.com/containers/image/v5/pkg/metrics"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
 	chunkedToc "github.com/containers/storage/pkg/chunked/toc"
 	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 	"github.com/vbauerster/mpb/v8"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // imageCopier tracks state specific to a single image (possibly an item of a manifest list)
@@ -35,8 +39,10 @@ type imageCopier struct {
 	src                           *image.SourcedImage
 	manifestConversionPlan        manifestConversionPlan
 	diffIDsAreNeeded              bool
-	cannotModifyManifestReason    string // The reason the manifest cannot be modified, or an empty string if it can
+	expectedDiffIDs               []digest.Digest // If not nil, DiffIDs from the config that layers must match, per Options.VerifyLayerDiffIDs
+	cannotModifyManifestReason    string          // The reason the manifest cannot be modified, or an empty string if it can
 	canSubstituteBlobs            bool
+	configRedacted                bool                        // Options.ConfigRedaction modifies the config, see prepareConfigRedaction
 	compressionFormat             *compressiontypes.Algorithm // Compression algorithm to use, if the user explicitly requested one, or nil.
 	compressionLevel              *int
 	requireCompressionFormatMatch bool
@@ -56,6 +62,20 @@ type copySingleImageResult struct {
 	compressionAlgorithms []compressiontypes.Algorithm
 }
 
+// checkPolicy returns an error unless c.policyContext allows running unparsedImage.
+func (c *copier) checkPolicy(ctx context.Context, unparsedImage *image.UnparsedImage) error {
+	policyCtx, policySpan := tracing.Start(ctx, "copy.checkPolicy")
+	policyCtx, finishPolicyTimeout := withPhaseTimeout(policyCtx, c.options.PhaseTimeouts.PolicyEvaluation, "policy evaluation")
+	allowed, err := c.policyContext.IsRunningImageAllowed(policyCtx, unparsedImage)
+	err = finishPolicyTimeout(err)
+	policySpan.SetAttributes(attribute.Bool("policy.allowed", allowed))
+	tracing.End(policySpan, err)
+	if !allowed || err != nil { // Be paranoid and fail if either return value indicates so.
+		return fmt.Errorf("Source image rejected: %w", err)
+	}
+	return nil
+}
+
 // copySingleImage copies a single (non-manifest-list) image unparsedImage, using c.policyContext to validate
 // source image admissibility.
 func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.UnparsedImage, targetInstance *digest.Digest, opts copySingleImageOptions) (copySingleImageResult, error) {
@@ -64,7 +84,7 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 	multiImage, err := isMultiImage(ctx, unparsedImage)
 	if err != nil {
 		// FIXME FIXME: How to name a reference for the sub-image?
-		return copySingleImageResult{}, fmt.Errorf("determining manifest MIME type for %s: %w", transports.ImageName(unparsedImage.Reference()), err)
+		return copySingleImageResult{}, fmt.Errorf("determining manifest MIME type for %s: %w", transports.ImageName(unparsedImage.Reference()), sourceReadError{err})
 	}
 	if multiImage {
 		return copySingleImageResult{}, fmt.Errorf("Unexpectedly received a manifest list instead of a manifest for a single image")
@@ -73,8 +93,8 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 	// Please keep this policy check BEFORE reading any other information about the image.
 	// (The multiImage check above only matches the MIME type, which we have received anyway.
 	// Actual parsing of anything should be deferred.)
-	if allowed, err := c.policyContext.IsRunningImageAllowed(ctx, unparsedImage); !allowed || err != nil { // Be paranoid and fail if either return value indicates so.
-		return copySingleImageResult{}, fmt.Errorf("Source image rejected: %w", err)
+	if err := c.checkPolicy(ctx, unparsedImage); err != nil {
+		return copySingleImageResult{}, err
 	}
 	src, err := image.FromUnparsedImage(ctx, c.options.SourceCtx, unparsedImage)
 	if err != nil {
@@ -129,7 +149,7 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 	if destIsDigestedReference {
 		cannotModifyManifestReason = "Destination specifies a digest"
 	}
-	if c.options.PreserveDigests {
+	if c.options.PreserveDigests || c.options.PreserveListDigest {
 		cannotModifyManifestReason = "Instructed to preserve digests"
 	}
 
@@ -162,10 +182,14 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 	if err := ic.updateEmbeddedDockerReference(); err != nil {
 		return copySingleImageResult{}, err
 	}
+	if err := ic.prepareConfigRedaction(ctx); err != nil {
+		return copySingleImageResult{}, err
+	}
 
-	destRequiresOciEncryption := (isEncrypted(src) && ic.c.options.OciDecryptConfig == nil) || c.options.OciEncryptLayers != nil
+	destRequiresOciEncryption := (isEncrypted(src) && ic.c.options.OciDecryptConfig == nil) || c.options.OciEncryptLayers != nil ||
+		c.options.OciEncryptLayerPolicy != nil
 
-	ic.manifestConversionPlan, err = determineManifestConversion(determineManifestConversionInputs{
+	ic.manifestConversionPlan, err = determineManifestConversion(ctx, determineManifestConversionInputs{
 		srcMIMEType:                    ic.src.ManifestMIMEType,
 		destSupportedManifestMIMETypes: ic.c.dest.SupportedManifestMIMETypes(),
 		forceManifestMIMEType:          c.options.ForceManifestMIMEType,
@@ -189,14 +213,14 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 
 	// If enabled, fetch and compare the destination's manifest. And as an optimization skip updating the destination iff equal
 	if c.options.OptimizeDestinationImageAlreadyExists {
-		shouldUpdateSigs := len(sigs) > 0 || len(c.signers) != 0 // TODO: Consider allowing signatures updates only and skipping the image's layers/manifest copy if possible
+		shouldUpdateSigs := len(sigs) > 0 || c.shouldSignImage(targetInstance != nil) // TODO: Consider allowing signatures updates only and skipping the image's layers/manifest copy if possible
 		noPendingManifestUpdates := ic.noPendingManifestUpdates()
 
-		logrus.Debugf("Checking if we can skip copying: has signatures=%t, OCI encryption=%t, no manifest updates=%t, compression match required for resuing blobs=%t", shouldUpdateSigs, destRequiresOciEncryption, noPendingManifestUpdates, opts.requireCompressionFormatMatch)
-		if !shouldUpdateSigs && !destRequiresOciEncryption && noPendingManifestUpdates && !ic.requireCompressionFormatMatch {
+		log.DebugfContext(ctx, "Checking if we can skip copying: has signatures=%t, OCI encryption=%t, no manifest updates=%t, compression match required for resuing blobs=%t, config redacted=%t", shouldUpdateSigs, destRequiresOciEncryption, noPendingManifestUpdates, opts.requireCompressionFormatMatch, ic.configRedacted)
+		if !shouldUpdateSigs && !destRequiresOciEncryption && noPendingManifestUpdates && !ic.requireCompressionFormatMatch && !ic.configRedacted {
 			matchedResult, err := ic.compareImageDestinationManifestEqual(ctx, targetInstance)
 			if err != nil {
-				logrus.Warnf("Failed to compare destination image manifest: %v", err)
+				log.WarnfContext(ctx, "Failed to compare destination image manifest: %v", err)
 				return copySingleImageResult{}, err
 			}
 
@@ -224,7 +248,7 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 		manifestDigest:   manifestDigest,
 	}
 	if err != nil {
-		logrus.Debugf("Writing manifest using preferred type %s failed: %v", ic.manifestConversionPlan.preferredMIMEType, err)
+		log.DebugfContext(ctx, "Writing manifest using preferred type %s failed: %v", ic.manifestConversionPlan.preferredMIMEType, err)
 		// … if it fails, and the failure is either because the manifest is rejected by the registry, or
 		// because we failed to create a manifest of the specified type because the specific manifest type
 		// doesn't support the type of compression we're trying to use (e.g. docker v2s2 and zstd), we may
@@ -250,11 +274,11 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 		// errs is a list of errors when trying various manifest types. Also serves as an "upload succeeded" flag when set to nil.
 		errs := []string{fmt.Sprintf("%s(%v)", ic.manifestConversionPlan.preferredMIMEType, err)}
 		for _, manifestMIMEType := range ic.manifestConversionPlan.otherMIMETypeCandidates {
-			logrus.Debugf("Trying to use manifest type %s…", manifestMIMEType)
+			log.DebugfContext(ctx, "Trying to use manifest type %s…", manifestMIMEType)
 			ic.manifestUpdates.ManifestMIMEType = manifestMIMEType
 			attemptedManifest, attemptedManifestDigest, err := ic.copyUpdatedConfigAndManifest(ctx, targetInstance)
 			if err != nil {
-				logrus.Debugf("Upload of manifest type %s failed: %v", manifestMIMEType, err)
+				log.DebugfContext(ctx, "Upload of manifest type %s failed: %v", manifestMIMEType, err)
 				errs = append(errs, fmt.Sprintf("%s(%v)", manifestMIMEType, err))
 				continue
 			}
@@ -276,11 +300,13 @@ func (c *copier) copySingleImage(ctx context.Context, unparsedImage *image.Unpar
 		targetInstance = &wipResult.manifestDigest
 	}
 
-	newSigs, err := c.createSignatures(ctx, wipResult.manifest, c.options.SignIdentity)
-	if err != nil {
-		return copySingleImageResult{}, err
+	if c.shouldSignImage(targetInstance != nil) {
+		newSigs, err := c.createSignatures(ctx, wipResult.manifest, c.options.SignIdentity)
+		if err != nil {
+			return copySingleImageResult{}, err
+		}
+		sigs = append(slices.Clone(sigs), newSigs...)
 	}
-	sigs = append(slices.Clone(sigs), newSigs...)
 
 	if len(sigs) > 0 {
 		c.Printf("Storing signatures\n")
@@ -318,7 +344,7 @@ func checkImageDestinationForCurrentRuntime(ctx context.Context, sys *types.Syst
 			options.append(fmt.Sprintf("%s+%s+%q", wantedPlatform.OS, wantedPlatform.Architecture, wantedPlatform.Variant))
 		}
 		if !match {
-			logrus.Infof("Image operating system mismatch: image uses OS %q+architecture %q+%q, expecting one of %q",
+			log.InfofContext(ctx, "Image operating system mismatch: image uses OS %q+architecture %q+%q, expecting one of %q",
 				c.OS, c.Architecture, c.Variant, strings.Join(options.list, ", "))
 		}
 	}
@@ -360,14 +386,14 @@ func (ic *imageCopier) compareImageDestinationManifestEqual(ctx context.Context,
 
 	destImageSource, err := ic.c.dest.Reference().NewImageSource(ctx, ic.c.options.DestinationCtx)
 	if err != nil {
-		logrus.Debugf("Unable to create destination image %s source: %v", ic.c.dest.Reference(), err)
+		log.DebugfContext(ctx, "Unable to create destination image %s source: %v", ic.c.dest.Reference(), err)
 		return nil, nil
 	}
 	defer destImageSource.Close()
 
 	destManifest, destManifestType, err := destImageSource.GetManifest(ctx, targetInstance)
 	if err != nil {
-		logrus.Debugf("Unable to get destination image %s/%s manifest: %v", destImageSource, targetInstance, err)
+		log.DebugfContext(ctx, "Unable to get destination image %s/%s manifest: %v", destImageSource, targetInstance, err)
 		return nil, nil
 	}
 
@@ -376,7 +402,7 @@ func (ic *imageCopier) compareImageDestinationManifestEqual(ctx context.Context,
 		return nil, fmt.Errorf("calculating manifest digest: %w", err)
 	}
 
-	logrus.Debugf("Comparing source and destination manifest digests: %v vs. %v", srcManifestDigest, destManifestDigest)
+	log.DebugfContext(ctx, "Comparing source and destination manifest digests: %v vs. %v", srcManifestDigest, destManifestDigest)
 	if srcManifestDigest != destManifestDigest {
 		return nil, nil
 	}
@@ -406,6 +432,22 @@ func (ic *imageCopier) compareImageDestinationManifestEqual(ctx context.Context,
 	}, nil
 }
 
+// checkStagingSpace fails early if ic.c.dest stages blobs in a temporary directory which does not have space for
+// the config and srcInfos, as far as their sizes are known.
+func (ic *imageCopier) checkStagingSpace(ctx context.Context, srcInfos []types.BlobInfo) error {
+	checker, ok := ic.c.dest.(private.StagingSpaceChecker)
+	if !ok {
+		return nil
+	}
+	size := max(ic.src.ConfigInfo().Size, 0)
+	for _, srcInfo := range srcInfos {
+		if srcInfo.Size > 0 {
+			size += srcInfo.Size
+		}
+	}
+	return checker.CheckStagingSpace(ctx, size)
+}
+
 // copyLayers copies layers from ic.src/ic.c.rawSource to dest, using and updating ic.manifestUpdates if necessary and ic.cannotModifyManifestReason == "".
 func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algorithm, error) {
 	srcInfos := ic.src.LayerInfos()
@@ -422,6 +464,18 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 		srcInfos = updatedSrcInfos
 		srcInfosUpdated = true
 	}
+	if err := ic.checkLayerLimits(srcInfos); err != nil {
+		return nil, err
+	}
+	if err := ic.checkStagingSpace(ctx, srcInfos); err != nil {
+		return nil, err
+	}
+	if ic.c.options.VerifyLayerDiffIDs {
+		ic.expectedDiffIDs, err = ic.configLayerDiffIDs(ctx, numLayers)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	type copyLayerData struct {
 		destInfo types.BlobInfo
@@ -453,36 +507,24 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 				cld.err = errors.New("getting DiffID for foreign layers is unimplemented")
 			} else {
 				cld.destInfo = srcLayer
-				logrus.Debugf("Skipping foreign layer %q copy to %s", cld.destInfo.Digest, ic.c.dest.Reference().Transport().Name())
+				log.DebugfContext(ctx, "Skipping foreign layer %q copy to %s", cld.destInfo.Digest, ic.c.dest.Reference().Transport().Name())
 			}
 		} else {
-			cld.destInfo, cld.diffID, cld.err = ic.copyLayer(ctx, srcLayer, toEncrypt, pool, index, srcRef, manifestLayerInfos[index].EmptyLayer)
+			layerCtx, span := tracing.Start(ctx, "copy.layer",
+				attribute.Int("layer.index", index),
+				attribute.String("blob.digest", srcLayer.Digest.String()),
+				attribute.Int64("blob.size", srcLayer.Size))
+			layerCtx, finishLayerTimeout := withPhaseTimeout(layerCtx, ic.c.options.PhaseTimeouts.BlobTransfer, fmt.Sprintf("copying blob %s", srcLayer.Digest))
+			cld.destInfo, cld.diffID, cld.err = ic.copyLayer(layerCtx, srcLayer, toEncrypt, pool, index, srcRef, manifestLayerInfos[index].EmptyLayer)
+			cld.err = finishLayerTimeout(cld.err)
+			tracing.End(span, cld.err)
 		}
 		data[index] = cld
 	}
 
-	// Decide which layers to encrypt
-	layersToEncrypt := set.New[int]()
-	var encryptAll bool
-	if ic.c.options.OciEncryptLayers != nil {
-		encryptAll = len(*ic.c.options.OciEncryptLayers) == 0
-		totalLayers := len(srcInfos)
-		for _, l := range *ic.c.options.OciEncryptLayers {
-			switch {
-			case l >= 0 && l < totalLayers:
-				layersToEncrypt.Add(l)
-			case l < 0 && l+totalLayers >= 0: // Implies (l + totalLayers) < totalLayers
-				layersToEncrypt.Add(l + totalLayers) // If l is negative, it is reverse indexed.
-			default:
-				return nil, fmt.Errorf("when choosing layers to encrypt, layer index %d out of range (%d layers exist)", l, totalLayers)
-			}
-		}
-
-		if encryptAll {
-			for i := 0; i < len(srcInfos); i++ {
-				layersToEncrypt.Add(i)
-			}
-		}
+	layersToEncrypt, err := ic.layersToEncrypt(srcInfos)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := func() error { // A scope for defer
@@ -528,7 +570,8 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 	if ic.diffIDsAreNeeded {
 		ic.manifestUpdates.InformationOnly.LayerDiffIDs = diffIDs
 	}
-	if srcInfosUpdated || layerDigestsDiffer(srcInfos, destInfos) {
+	// Annotations can differ without a digest change if only the wrapped keys of an encrypted layer were updated.
+	if srcInfosUpdated || layerDigestsDiffer(srcInfos, destInfos) || layerAnnotationsDiffer(srcInfos, destInfos) {
 		ic.manifestUpdates.LayerInfos = destInfos
 	}
 	algos, err := algorithmsByNames(compressionAlgos.Values())
@@ -538,6 +581,13 @@ func (ic *imageCopier) copyLayers(ctx context.Context) ([]compressiontypes.Algor
 	return algos, nil
 }
 
+// layerAnnotationsDiffer returns true iff the annotations in a and b differ (ignoring all other fields)
+func layerAnnotationsDiffer(a, b []types.BlobInfo) bool {
+	return !slices.EqualFunc(a, b, func(a, b types.BlobInfo) bool {
+		return maps.Equal(a.Annotations, b.Annotations)
+	})
+}
+
 // layerDigestsDiffer returns true iff the digests in a and b differ (ignoring sizes and possible other fields)
 func layerDigestsDiffer(a, b []types.BlobInfo) bool {
 	return !slices.EqualFunc(a, b, func(a, b types.BlobInfo) bool {
@@ -569,12 +619,19 @@ func (ic *imageCopier) copyUpdatedConfigAndManifest(ctx context.Context, instanc
 		}
 		pendingImage = pi
 	}
+	pendingImage, err := ic.redactImageConfig(ctx, pendingImage)
+	if err != nil {
+		return nil, "", fmt.Errorf("redacting the image config: %w", err)
+	}
 	man, _, err := pendingImage.Manifest(ctx)
 	if err != nil {
 		return nil, "", fmt.Errorf("reading manifest: %w", err)
 	}
 
-	if err := ic.copyConfig(ctx, pendingImage); err != nil {
+	configCtx, span := tracing.Start(ctx, "copy.config", attribute.String("blob.digest", pendingImage.ConfigInfo().Digest.String()))
+	err = ic.copyConfig(configCtx, pendingImage)
+	tracing.End(span, err)
+	if err != nil {
 		return nil, "", err
 	}
 
@@ -586,10 +643,15 @@ func (ic *imageCopier) copyUpdatedConfigAndManifest(ctx context.Context, instanc
 	if instanceDigest != nil {
 		instanceDigest = &manifestDigest
 	}
-	if err := ic.c.dest.PutManifest(ctx, man, instanceDigest); err != nil {
-		logrus.Debugf("Error %v while writing manifest %q", err, string(man))
+	manifestPhase := ic.c.startPhase(PhaseManifestPush, types.BlobInfo{})
+	manifestCtx, finishManifestTimeout := withPhaseTimeout(ctx, ic.c.options.PhaseTimeouts.ManifestPush, "writing manifest")
+	err = finishManifestTimeout(ic.c.dest.PutManifest(manifestCtx, man, instanceDigest))
+	if err != nil {
+		manifestPhase.finish(-1, err)
+		log.DebugfContext(ctx, "Error %v while writing manifest %q", err, string(man))
 		return nil, "", fmt.Errorf("writing manifest: %w", err)
 	}
+	manifestPhase.finish(int64(len(man)), nil)
 	return man, manifestDigest, nil
 }
 
@@ -603,7 +665,9 @@ func (ic *imageCopier) copyConfig(ctx context.Context, src types.Image) error {
 		}
 		defer ic.c.concurrentBlobCopiesSemaphore.Release(1)
 
-		destInfo, err := func() (types.BlobInfo, error) { // A scope for defer
+		ctx, finishTimeout := withPhaseTimeout(ctx, ic.c.options.PhaseTimeouts.BlobTransfer, fmt.Sprintf("copying config %s", srcInfo.Digest))
+		destInfo, err := func() (_ types.BlobInfo, retErr error) { // A scope for defer
+			defer func() { retErr = finishTimeout(retErr) }()
 			progressPool := ic.c.newProgressPool()
 			defer progressPool.Wait()
 			bar, err := ic.c.createProgressBar(progressPool, false, srcInfo, "config", "done")
@@ -615,7 +679,7 @@ func (ic *imageCopier) copyConfig(ctx context.Context, src types.Image) error {
 
 			configBlob, err := src.ConfigBlob(ctx)
 			if err != nil {
-				return types.BlobInfo{}, fmt.Errorf("reading config blob %s: %w", srcInfo.Digest, err)
+				return types.BlobInfo{}, fmt.Errorf("reading config blob %s: %w", srcInfo.Digest, sourceReadError{err})
 			}
 
 			destInfo, err := ic.copyBlobFromStream(ctx, bytes.NewReader(configBlob), srcInfo, nil, true, false, bar, -1, false)
@@ -668,6 +732,31 @@ func compressionEditsFromBlobInfo(srcInfo types.BlobInfo) (types.LayerCompressio
 	}
 }
 
+// configLayerDiffIDs returns the DiffIDs listed in the source image’s config, for Options.VerifyLayerDiffIDs,
+// after checking that they correspond to the numLayers layers in the manifest.
+func (ic *imageCopier) configLayerDiffIDs(ctx context.Context, numLayers int) ([]digest.Digest, error) {
+	switch ic.src.ManifestMIMEType {
+	case manifest.DockerV2Schema1MediaType, manifest.DockerV2Schema1SignedMediaType:
+		return nil, fmt.Errorf("verifying layer DiffIDs is not possible for %s images, which have no config", ic.src.ManifestMIMEType)
+	}
+	config, err := ic.src.OCIConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image configuration to verify layer DiffIDs: %w", err)
+	}
+	if len(config.RootFS.DiffIDs) != numLayers {
+		return nil, fmt.Errorf("image config lists %d layer DiffIDs, but the manifest has %d layers", len(config.RootFS.DiffIDs), numLayers)
+	}
+	return config.RootFS.DiffIDs, nil
+}
+
+// verifyLayerDiffID checks that diffID, the uncompressed digest of layer srcInfo at layerIndex, matches the image config.
+func (ic *imageCopier) verifyLayerDiffID(layerIndex int, srcInfo types.BlobInfo, diffID digest.Digest) error {
+	if expected := ic.expectedDiffIDs[layerIndex]; diffID != expected {
+		return fmt.Errorf("layer %d (%s) has uncompressed digest %s, but the image config expects DiffID %s", layerIndex, srcInfo.Digest, diffID, expected)
+	}
+	return nil
+}
+
 // copyLayer copies a layer with srcInfo (with known Digest and Annotations and possibly known Size) in src to dest, perhaps (de/re/)compressing it,
 // and returns a complete blobInfo of the copied layer, and a value for LayerDiffIDs if diffIDIsNeeded
 // srcRef can be used as an additional hint to the destination during checking whether a layer can be reused but srcRef can be nil.
@@ -690,22 +779,42 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 
 	ic.c.printCopyInfo("blob", srcInfo)
 
+	// When encrypting to decrypting, only use the simple code path. We might be able to optimize more
+	// (e.g. if we know the DiffID of an encrypted compressed layer, it might not be necessary to pull, decrypt and decompress again),
+	// but it’s not trivially safe to do such things, so until someone takes the effort to make a comprehensive argument, let’s not.
+	encryptingOrDecrypting := toEncrypt || (isOciEncrypted(srcInfo.MediaType) && ic.c.options.OciDecryptConfig != nil)
+	// If the layer is already encrypted and only needs to be made available to different recipients, the encrypted data
+	// does not change at all; so, update the wrapped keys in the annotations, and copy (or reuse) the layer data as is.
+	rewrappingKeys := ic.canRewrapLayerKeys(srcInfo, toEncrypt)
+	if rewrappingKeys {
+		annotations, err := ic.rewrapLayerKeys(srcInfo)
+		if err != nil {
+			return types.BlobInfo{}, "", err
+		}
+		srcInfo.Annotations = annotations
+	}
+	// A layer which stays encrypted has no computable DiffID to compare with the config.
+	verifyDiffID := ic.expectedDiffIDs != nil && (!isOciEncrypted(srcInfo.MediaType) || (ic.c.options.OciDecryptConfig != nil && !rewrappingKeys))
+
 	diffIDIsNeeded := false
 	var cachedDiffID digest.Digest = ""
-	if ic.diffIDsAreNeeded {
+	if ic.diffIDsAreNeeded || verifyDiffID {
 		cachedDiffID = ic.c.blobInfoCache.UncompressedDigest(srcInfo.Digest) // May be ""
 		diffIDIsNeeded = cachedDiffID == ""
 	}
-	// When encrypting to decrypting, only use the simple code path. We might be able to optimize more
-	// (e.g. if we know the DiffID of an encrypted compressed layer, it might not be necessary to pull, decrypt and decompress again),
-	// but it’s not trivially safe to do such things, so until someone takes the effort to make a comprehensive argument, let’s not.
-	encryptingOrDecrypting := toEncrypt || (isOciEncrypted(srcInfo.MediaType) && ic.c.options.OciDecryptConfig != nil)
-	canAvoidProcessingCompleteLayer := !diffIDIsNeeded && !encryptingOrDecrypting
+	if verifyDiffID && cachedDiffID != "" {
+		if err := ic.verifyLayerDiffID(layerIndex, srcInfo, cachedDiffID); err != nil {
+			return types.BlobInfo{}, "", err
+		}
+	}
+	// Layers which stay encrypted are not scanned, so they don’t need to be read.
+	scanning := ic.c.options.LayerScanner != nil && (!isOciEncrypted(srcInfo.MediaType) || (ic.c.options.OciDecryptConfig != nil && !rewrappingKeys))
+	canAvoidProcessingCompleteLayer := !diffIDIsNeeded && !scanning && (!encryptingOrDecrypting || rewrappingKeys)
 
 	// Don’t read the layer from the source if we already have the blob, and optimizations are acceptable.
 	if canAvoidProcessingCompleteLayer {
 		canChangeLayerCompression := ic.src.CanChangeLayerCompression(srcInfo.MediaType)
-		logrus.Debugf("Checking if we can reuse blob %s: general substitution = %v, compression for MIME type %q = %v",
+		log.DebugfContext(ctx, "Checking if we can reuse blob %s: general substitution = %v, compression for MIME type %q = %v",
 			srcInfo.Digest, ic.canSubstituteBlobs, srcInfo.MediaType, canChangeLayerCompression)
 		canSubstitute := ic.canSubstituteBlobs && canChangeLayerCompression
 
@@ -725,22 +834,48 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 			tocDigest = *d
 		}
 
-		reused, reusedBlob, err := ic.c.dest.TryReusingBlobWithOptions(ctx, srcInfo, private.TryReusingBlobOptions{
-			Cache:                   ic.c.blobInfoCache,
-			CanSubstitute:           canSubstitute,
-			EmptyLayer:              emptyLayer,
-			LayerIndex:              &layerIndex,
-			SrcRef:                  srcRef,
-			PossibleManifestFormats: append([]string{ic.manifestConversionPlan.preferredMIMEType}, ic.manifestConversionPlan.otherMIMETypeCandidates...),
-			RequiredCompression:     requiredCompression,
-			OriginalCompression:     srcInfo.CompressionAlgorithm,
-			TOCDigest:               tocDigest,
-		})
-		if err != nil {
-			return types.BlobInfo{}, "", fmt.Errorf("trying to reuse blob %s at destination: %w", srcInfo.Digest, err)
+		uploadKey := blobUploadKey{destination: blobUploadDestination(ic.c.dest.Reference()), digest: srcInfo.Digest}
+		var reused bool
+		var reusedBlob private.ReusedBlob
+		for {
+			reused, reusedBlob, err = ic.c.dest.TryReusingBlobWithOptions(ctx, srcInfo, private.TryReusingBlobOptions{
+				Cache:                   ic.c.blobInfoCache,
+				CanSubstitute:           canSubstitute,
+				EmptyLayer:              emptyLayer,
+				LayerIndex:              &layerIndex,
+				SrcRef:                  srcRef,
+				PossibleManifestFormats: append([]string{ic.manifestConversionPlan.preferredMIMEType}, ic.manifestConversionPlan.otherMIMETypeCandidates...),
+				RequiredCompression:     requiredCompression,
+				OriginalCompression:     srcInfo.CompressionAlgorithm,
+				TOCDigest:               tocDigest,
+			})
+			if err != nil {
+				return types.BlobInfo{}, "", fmt.Errorf("trying to reuse blob %s at destination: %w", srcInfo.Digest, err)
+			}
+			if reused {
+				break
+			}
+			// If another copy in this process is uploading the same blob to the same destination right now,
+			// wait for it to finish and check again, instead of uploading the same data concurrently.
+			otherUploadDone, release := blobUploads.claim(uploadKey)
+			if release != nil {
+				defer release()
+				break
+			}
+			log.DebugfContext(ctx, "Waiting for a concurrent upload of blob %s to finish", srcInfo.Digest)
+			select {
+			case <-ctx.Done():
+				return types.BlobInfo{}, "", ctx.Err()
+			case <-otherUploadDone:
+			}
 		}
+		reuseResult := metrics.ResultMiss
 		if reused {
-			logrus.Debugf("Skipping blob %s (already present):", srcInfo.Digest)
+			reuseResult = metrics.ResultHit
+		}
+		ic.c.metrics.AddCounter(metrics.BlobReuseTotal, 1, map[string]string{metrics.LabelResult: reuseResult})
+		if reused {
+			log.DebugfContext(ctx, "Skipping blob %s (already present):", srcInfo.Digest)
 			if err := func() error { // A scope for defer
 				label := "skipped: already exists"
 				if reusedBlob.MatchedByTOCDigest {
@@ -758,11 +893,12 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 			}
 
 			// Throw an event that the layer has been skipped
-			if ic.c.options.Progress != nil && ic.c.options.ProgressInterval > 0 {
-				ic.c.options.Progress <- types.ProgressProperties{
-					Event:    types.ProgressEventSkipped,
-					Artifact: srcInfo,
-				}
+			if ic.c.reportProgress != nil {
+				ic.c.reportProgress(types.ProgressProperties{
+					Event:       types.ProgressEventSkipped,
+					Artifact:    srcInfo,
+					OperationID: ic.c.operationID,
+				})
 			}
 
 			return updatedBlobInfoFromReuse(srcInfo, reusedBlob), cachedDiffID, nil
@@ -773,7 +909,10 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 	// of the source file are not known yet and must be fetched.
 	// Attempt a partial only when the source allows to retrieve a blob partially and
 	// the destination has support for it.
-	if canAvoidProcessingCompleteLayer && ic.c.rawSource.SupportsGetBlobAt() && ic.c.dest.SupportsPutBlobPartial() {
+	// The uncompressed size of a partially pulled layer can’t be counted against MaxTotalUncompressedSize,
+	// so partial pulls are not used if that limit is set.
+	if canAvoidProcessingCompleteLayer && ic.c.uncompressedSizeLimit == nil &&
+		ic.c.rawSource.SupportsGetBlobAt() && ic.c.dest.SupportsPutBlobPartial() {
 		reused, blobInfo, err := func() (bool, types.BlobInfo, error) { // A scope for defer
 			bar, err := ic.c.createProgressBar(pool, true, srcInfo, "blob", "done")
 			if err != nil {
@@ -800,10 +939,10 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 				}
 				bar.mark100PercentComplete()
 				hideProgressBar = false
-				logrus.Debugf("Retrieved partial blob %v", srcInfo.Digest)
+				log.DebugfContext(ctx, "Retrieved partial blob %v", srcInfo.Digest)
 				return true, updatedBlobInfoFromUpload(srcInfo, uploadedBlob), nil
 			}
-			logrus.Debugf("Failed to retrieve partial blob: %v", err)
+			log.DebugfContext(ctx, "Failed to retrieve partial blob: %v", err)
 			return false, types.BlobInfo{}, nil
 		}()
 		if err != nil {
@@ -824,7 +963,7 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 
 		srcStream, srcBlobSize, err := ic.c.rawSource.GetBlob(ctx, srcInfo, ic.c.blobInfoCache)
 		if err != nil {
-			return types.BlobInfo{}, "", fmt.Errorf("reading blob %s: %w", srcInfo.Digest, err)
+			return types.BlobInfo{}, "", fmt.Errorf("reading blob %s: %w", srcInfo.Digest, sourceReadError{err})
 		}
 		defer srcStream.Close()
 
@@ -842,7 +981,7 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 				if diffIDResult.err != nil {
 					return types.BlobInfo{}, "", fmt.Errorf("computing layer DiffID: %w", diffIDResult.err)
 				}
-				logrus.Debugf("Computed DiffID %s for layer %s", diffIDResult.digest, srcInfo.Digest)
+				log.DebugfContext(ctx, "Computed DiffID %s for layer %s", diffIDResult.digest, srcInfo.Digest)
 				// Don’t record any associations that involve encrypted data. This is a bit crude,
 				// some blob substitutions (replacing pulls of encrypted data with local reuse of known decryption outcomes)
 				// might be safe, but it’s not trivially obvious, so let’s be conservative for now.
@@ -856,6 +995,11 @@ func (ic *imageCopier) copyLayer(ctx context.Context, srcInfo types.BlobInfo, to
 				}
 				diffID = diffIDResult.digest
 			}
+			if verifyDiffID {
+				if err := ic.verifyLayerDiffID(layerIndex, srcInfo, diffID); err != nil {
+					return types.BlobInfo{}, "", err
+				}
+			}
 		}
 
 		bar.mark100PercentComplete()
@@ -872,9 +1016,9 @@ func updatedBlobInfoFromReuse(inputInfo types.BlobInfo, reusedBlob private.Reuse
 	res := types.BlobInfo{
 		Digest:               reusedBlob.Digest,
 		Size:                 reusedBlob.Size,
-		URLs:                 nil,                   // This _must_ be cleared if Digest changes; clear it in other cases as well, to preserve previous behavior.
-		Annotations:          inputInfo.Annotations, // FIXME: This should remove zstd:chunked annotations (but those annotations being left with incorrect values should not break pulls)
-		MediaType:            inputInfo.MediaType,   // Mostly irrelevant, MediaType is updated based on Compression*/CryptoOperation.
+		URLs:                 nil, // This _must_ be cleared if Digest changes; clear it in other cases as well, to preserve previous behavior.
+		Annotations:          inputInfo.Annotations,
+		MediaType:            inputInfo.MediaType, // Mostly irrelevant, MediaType is updated based on Compression*/CryptoOperation.
 		CompressionOperation: reusedBlob.CompressionOperation,
 		CompressionAlgorithm: reusedBlob.CompressionAlgorithm,
 		CryptoOperation:      inputInfo.CryptoOperation, // Expected to be unset anyway.
@@ -885,10 +1029,38 @@ func updatedBlobInfoFromReuse(inputInfo types.BlobInfo, reusedBlob private.Reuse
 	if reusedBlob.Digest == inputInfo.Digest {
 		res.CompressionOperation = inputInfo.CompressionOperation
 		res.CompressionAlgorithm = inputInfo.CompressionAlgorithm
+	} else {
+		// The compression metadata of the original blob does not apply to the substituted one; use the metadata
+		// of the substituted blob, if we know it, so that it can be pulled partially as well.
+		res.Annotations = maps.Clone(inputInfo.Annotations)
+		maps.DeleteFunc(res.Annotations, func(key, _ string) bool {
+			return isCompressionAnnotation(key)
+		})
+		if len(reusedBlob.CompressionAnnotations) != 0 {
+			if res.Annotations == nil {
+				res.Annotations = map[string]string{}
+			}
+			maps.Copy(res.Annotations, reusedBlob.CompressionAnnotations)
+		}
 	}
 	return res
 }
 
+const (
+	// zstdChunkedAnnotationPrefix is the common prefix of annotations describing the zstd:chunked metadata of a layer,
+	// as defined in github.com/containers/storage/pkg/chunked/internal.
+	zstdChunkedAnnotationPrefix = "io.github.containers.zstd-chunked."
+	// estargzTOCDigestAnnotation is the annotation containing the digest of the eStargz TOC,
+	// as defined in github.com/containerd/stargz-snapshotter/estargz.
+	estargzTOCDigestAnnotation = "containerd.io/snapshot/stargz/toc.digest"
+)
+
+// isCompressionAnnotation returns true if key is a layer annotation which describes the specific compressed representation
+// of the layer (e.g. the zstd:chunked or eStargz TOC), and is not valid for other representations of the same data.
+func isCompressionAnnotation(key string) bool {
+	return strings.HasPrefix(key, zstdChunkedAnnotationPrefix) || key == estargzTOCDigestAnnotation
+}
+
 // copyLayerFromStream is an implementation detail of copyLayer; mostly providing a separate “defer” scope.
 // it copies a blob with srcInfo (with known Digest and Annotations and possibly known Size) from srcStream to dest,
 // perhaps (de/re/)compressing the stream,
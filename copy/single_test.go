@@ -75,6 +75,35 @@ func TestUpdatedBlobInfoFromReuse(t *testing.T) {
 		res := updatedBlobInfoFromReuse(srcInfo, c.reused)
 		assert.Equal(t, c.expected, res, fmt.Sprintf("%#v", c.reused))
 	}
+
+	// Compression annotations of the original blob are replaced by those of the substituted blob
+	chunkedSrcInfo := srcInfo
+	chunkedSrcInfo.Annotations = map[string]string{
+		"test-annotation-2": "two",
+		"io.github.containers.zstd-chunked.manifest-checksum": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		"io.github.containers.zstd-chunked.manifest-position": "1:2:3:4",
+	}
+	res := updatedBlobInfoFromReuse(chunkedSrcInfo, private.ReusedBlob{
+		Digest:               "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		Size:                 513543640,
+		CompressionOperation: types.Compress,
+		CompressionAlgorithm: &compression.Zstd,
+		CompressionAnnotations: map[string]string{
+			"io.github.containers.zstd-chunked.manifest-checksum": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		},
+	})
+	assert.Equal(t, map[string]string{
+		"test-annotation-2": "two",
+		"io.github.containers.zstd-chunked.manifest-checksum": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+	}, res.Annotations)
+	assert.Len(t, chunkedSrcInfo.Annotations, 3) // The input was not modified
+	// … or just dropped if the substituted blob has none
+	res = updatedBlobInfoFromReuse(chunkedSrcInfo, private.ReusedBlob{
+		Digest:               "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		Size:                 513543640,
+		CompressionOperation: types.Decompress,
+	})
+	assert.Equal(t, map[string]string{"test-annotation-2": "two"}, res.Annotations)
 }
 
 func goDiffIDComputationGoroutineWithTimeout(layerStream io.ReadCloser, decompressor compressiontypes.DecompressorFunc) *diffIDResult {
@@ -0,0 +1,90 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/containers/image/v5/internal/log"
+)
+
+// PhaseTimeout limits the duration of a phase of Image.
+type PhaseTimeout struct {
+	// If Soft > 0, a warning is logged when the phase takes longer than Soft.
+	Soft time.Duration
+	// If Hard > 0, the phase, and therefore the whole copy, fails with a PhaseTimeoutError when it takes longer than Hard.
+	Hard time.Duration
+}
+
+// PhaseTimeouts limits the duration of individual phases of Image, see Options.PhaseTimeouts.
+// The overall duration of the copy is only limited by the context passed to Image.
+type PhaseTimeouts struct {
+	ManifestResolution PhaseTimeout // Reading the top-level source manifest, to determine what to copy
+	PolicyEvaluation   PhaseTimeout // Evaluating the signature policy for each copied image
+	BlobTransfer       PhaseTimeout // Copying each single blob (layer or config), including reading, converting and writing it
+	ManifestPush       PhaseTimeout // Writing each manifest or manifest list to the destination
+	Signing            PhaseTimeout // Creating signatures for each written manifest
+}
+
+// validate returns an error if t is not valid.
+func (t *PhaseTimeouts) validate() error {
+	for _, pt := range []struct {
+		name    string
+		timeout PhaseTimeout
+	}{
+		{"ManifestResolution", t.ManifestResolution},
+		{"PolicyEvaluation", t.PolicyEvaluation},
+		{"BlobTransfer", t.BlobTransfer},
+		{"ManifestPush", t.ManifestPush},
+		{"Signing", t.Signing},
+	} {
+		if pt.timeout.Soft < 0 || pt.timeout.Hard < 0 {
+			return fmt.Errorf("invalid negative %s phase timeout", pt.name)
+		}
+	}
+	return nil
+}
+
+// PhaseTimeoutError is returned by Image, wrapping the error of the interrupted operation,
+// when a phase takes longer than its PhaseTimeout.Hard.
+type PhaseTimeoutError struct {
+	Phase   string // A human-readable description of the phase, e.g. "copying blob sha256:…"
+	Timeout time.Duration
+}
+
+func (e PhaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %v", e.Phase, e.Timeout)
+}
+
+// withPhaseTimeout returns a context to use for a phase described by description, limited by timeout,
+// and a function which must be called with the result of the phase when it ends; it returns the error to report.
+func withPhaseTimeout(ctx context.Context, timeout PhaseTimeout, description string) (context.Context, func(error) error) {
+	if timeout.Soft <= 0 && timeout.Hard <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+	var softTimer *time.Timer
+	if timeout.Soft > 0 {
+		softTimer = time.AfterFunc(timeout.Soft, func() {
+			log.WarnfContext(ctx, "%s is taking longer than %v", description, timeout.Soft)
+		})
+	}
+	phaseCtx, cancel := ctx, context.CancelFunc(func() {})
+	var timeoutErr error // nil if there is no hard timeout
+	if timeout.Hard > 0 {
+		timeoutErr = PhaseTimeoutError{Phase: description, Timeout: timeout.Hard}
+		phaseCtx, cancel = context.WithTimeoutCause(ctx, timeout.Hard, timeoutErr)
+	}
+	return phaseCtx, func(err error) error {
+		if softTimer != nil {
+			softTimer.Stop()
+		}
+		// Check before calling cancel(), which sets the cause to context.Canceled otherwise.
+		expired := timeoutErr != nil && context.Cause(phaseCtx) == timeoutErr
+		cancel()
+		if err != nil && expired && !errors.Is(err, timeoutErr) {
+			return fmt.Errorf("%w: %w", timeoutErr, err)
+		}
+		return err
+	}
+}
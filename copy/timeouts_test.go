@@ -0,0 +1,71 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhaseTimeoutsValidate(t *testing.T) {
+	for _, timeouts := range []PhaseTimeouts{
+		{},
+		{BlobTransfer: PhaseTimeout{Soft: time.Minute, Hard: time.Hour}},
+		{ManifestResolution: PhaseTimeout{Hard: time.Second}, Signing: PhaseTimeout{Soft: time.Second}},
+	} {
+		assert.NoError(t, timeouts.validate(), "%#v", timeouts)
+	}
+	for _, timeouts := range []PhaseTimeouts{
+		{ManifestResolution: PhaseTimeout{Soft: -1}},
+		{PolicyEvaluation: PhaseTimeout{Hard: -1}},
+		{BlobTransfer: PhaseTimeout{Soft: -time.Second}},
+		{ManifestPush: PhaseTimeout{Hard: -time.Second}},
+		{Signing: PhaseTimeout{Soft: -1, Hard: -1}},
+	} {
+		assert.Error(t, timeouts.validate(), "%#v", timeouts)
+	}
+}
+
+func TestWithPhaseTimeout(t *testing.T) {
+	operationErr := errors.New("operation failed")
+
+	// No timeout
+	ctx := context.Background()
+	phaseCtx, finish := withPhaseTimeout(ctx, PhaseTimeout{}, "phase")
+	assert.Equal(t, ctx, phaseCtx)
+	assert.NoError(t, finish(nil))
+	assert.Equal(t, operationErr, finish(operationErr))
+
+	// The phase ends in time
+	phaseCtx, finish = withPhaseTimeout(ctx, PhaseTimeout{Soft: time.Hour, Hard: time.Hour}, "phase")
+	assert.NoError(t, phaseCtx.Err())
+	assert.NoError(t, finish(nil))
+	assert.Equal(t, operationErr, finish(operationErr))
+	assert.Error(t, phaseCtx.Err()) // The context is canceled when the phase ends.
+
+	// The hard timeout expires
+	phaseCtx, finish = withPhaseTimeout(ctx, PhaseTimeout{Hard: time.Millisecond}, "slow phase")
+	<-phaseCtx.Done()
+	err := finish(phaseCtx.Err())
+	var timeoutErr PhaseTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, PhaseTimeoutError{Phase: "slow phase", Timeout: time.Millisecond}, timeoutErr)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "slow phase timed out after 1ms")
+	// … but a successful result is not turned into a failure.
+	phaseCtx, finish = withPhaseTimeout(ctx, PhaseTimeout{Hard: time.Millisecond}, "slow phase")
+	<-phaseCtx.Done()
+	assert.NoError(t, finish(nil))
+
+	// Cancellation of the parent context is not reported as a timeout.
+	parentCtx, cancel := context.WithCancel(ctx)
+	phaseCtx, finish = withPhaseTimeout(parentCtx, PhaseTimeout{Hard: time.Hour}, "phase")
+	cancel()
+	<-phaseCtx.Done()
+	err = finish(phaseCtx.Err())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, errors.As(err, &timeoutErr))
+}
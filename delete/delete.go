@@ -0,0 +1,111 @@
+// Package delete deletes images, independently of the transport they are stored in,
+// optionally together with the manifests attached to them (referrers and signatures).
+package delete
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Options allows supplying non-default configuration modifying the behavior of Image.
+type Options struct {
+	SystemContext *types.SystemContext
+	// If true, also delete the manifests attached to the image: its referrers (recursively), its sigstore signatures,
+	// and the “referrers tag schema” index. This is only supported with the docker: transport.
+	DeleteAttachments bool
+	ReportWriter      io.Writer // If not nil, a "Deleting …" line is written for every deleted image or attachment.
+}
+
+// Result describes what was removed by Image.
+type Result struct {
+	// The digest of the deleted image manifest, or "" if it could not be determined
+	// (it is always set if Options.DeleteAttachments is true).
+	ManifestDigest digest.Digest
+	// The deleted attachments (referenced by digest), in the order they were deleted.
+	// This is empty unless Options.DeleteAttachments is true.
+	Attachments []types.ImageReference
+}
+
+// Image deletes the image at ref, as ref.DeleteImage would, and if options.DeleteAttachments is set,
+// also the manifests attached to it. options may be nil.
+//
+// Attachments are deleted before the image, so that a failure never leaves attachments of a deleted image behind.
+// The image is then deleted using the manifest digest the attachments were listed for, not by ref, so that
+// if ref is a tag, and the tag is concurrently moved to a different manifest, that manifest is not deleted.
+// If deleting fails, the returned Result (which is never nil) describes the attachments deleted so far.
+//
+// With the docker: transport, registries only support deleting manifests, not tags; so deleting an image
+// also deletes any other tags referring to the same manifest digest in that repository.
+func Image(ctx context.Context, ref types.ImageReference, options *Options) (*Result, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	res := &Result{Attachments: []types.ImageReference{}}
+	reportWriter := io.Discard
+	if options.ReportWriter != nil {
+		reportWriter = options.ReportWriter
+	}
+
+	var attachments []types.ImageReference
+	target := ref // The reference to delete after the attachments
+	if options.DeleteAttachments {
+		if ref.Transport().Name() != docker.Transport.Name() {
+			return res, fmt.Errorf("deleting attachments of %s is not supported, only images using the %s: transport have attachments",
+				transports.ImageName(ref), docker.Transport.Name())
+		}
+		a, err := docker.ListAttachments(ctx, options.SystemContext, ref)
+		if err != nil {
+			return res, fmt.Errorf("listing attachments of %s: %w", transports.ImageName(ref), err)
+		}
+		res.ManifestDigest = a.ManifestDigest
+		attachments = a.Manifests
+		target = a.Image
+	} else {
+		d, err := manifestDigest(ctx, options.SystemContext, ref)
+		if err != nil {
+			log.DebugfContext(ctx, "Unable to determine the manifest digest of %s: %v", transports.ImageName(ref), err)
+		} else {
+			res.ManifestDigest = d
+		}
+	}
+
+	for _, attachment := range attachments {
+		fmt.Fprintf(reportWriter, "Deleting attachment %s\n", transports.ImageName(attachment))
+		if err := attachment.DeleteImage(ctx, options.SystemContext); err != nil {
+			return res, fmt.Errorf("deleting attachment %s of %s: %w", transports.ImageName(attachment), transports.ImageName(ref), err)
+		}
+		res.Attachments = append(res.Attachments, attachment)
+	}
+
+	fmt.Fprintf(reportWriter, "Deleting %s\n", transports.ImageName(target))
+	if err := target.DeleteImage(ctx, options.SystemContext); err != nil {
+		return res, fmt.Errorf("deleting %s: %w", transports.ImageName(target), err)
+	}
+	return res, nil
+}
+
+// manifestDigest returns the digest of the manifest of ref.
+func manifestDigest(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (_ digest.Digest, retErr error) {
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := src.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	m, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest(m)
+}
@@ -0,0 +1,81 @@
+package delete
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyFixture returns a temporary copy of the OCI layout in srcDir.
+func copyFixture(t *testing.T, srcDir string) string {
+	destDir := t.TempDir()
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(destDir, relPath)
+		if d.IsDir() {
+			return os.MkdirAll(destPath, 0o700)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(destPath, data, 0o600)
+	})
+	require.NoError(t, err)
+	return destDir
+}
+
+func TestImage(t *testing.T) {
+	const fixture = "../oci/layout/fixtures/delete_image_only_one_image"
+	const manifestDigest = digest.Digest("sha256:eaa95f3cfaac07c8a5153eb77c933269586ad0226c83405776be08547e4d2a18")
+
+	for _, options := range []*Options{nil, {SystemContext: &types.SystemContext{}}} {
+		ref, err := layout.NewReference(copyFixture(t, fixture), "latest")
+		require.NoError(t, err)
+		res, err := Image(context.Background(), ref, options)
+		require.NoError(t, err)
+		assert.Equal(t, &Result{ManifestDigest: manifestDigest, Attachments: []types.ImageReference{}}, res)
+		_, err = ref.NewImageSource(context.Background(), nil)
+		assert.Error(t, err)
+	}
+
+	// The report is written to ReportWriter
+	ref, err := layout.NewReference(copyFixture(t, fixture), "latest")
+	require.NoError(t, err)
+	var report bytes.Buffer
+	_, err = Image(context.Background(), ref, &Options{ReportWriter: &report})
+	require.NoError(t, err)
+	assert.Contains(t, report.String(), "Deleting oci:")
+
+	// Attachments are not supported outside of the docker: transport; the image is not deleted.
+	ref, err = layout.NewReference(copyFixture(t, fixture), "latest")
+	require.NoError(t, err)
+	res, err := Image(context.Background(), ref, &Options{DeleteAttachments: true})
+	assert.Error(t, err)
+	assert.NotNil(t, res)
+	src, err := ref.NewImageSource(context.Background(), nil)
+	require.NoError(t, err)
+	src.Close()
+
+	// Deleting a missing image fails
+	ref, err = layout.NewReference(copyFixture(t, fixture), "missing")
+	require.NoError(t, err)
+	res, err = Image(context.Background(), ref, nil)
+	assert.Error(t, err)
+	assert.Equal(t, digest.Digest(""), res.ManifestDigest)
+}
@@ -9,15 +9,17 @@ import (
 	"path/filepath"
 	"runtime"
 
+	"github.com/containers/image/v5/internal/blobfile"
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
 	"github.com/containers/image/v5/internal/imagedestination/stubs"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/putblobdigest"
 	"github.com/containers/image/v5/internal/signature"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/fileutils"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 const version = "Directory Transport Version: 1.1\n"
@@ -85,7 +87,7 @@ func newImageDestination(sys *types.SystemContext, ref dirReference) (private.Im
 			if err = removeDirContents(ref.resolvedPath); err != nil {
 				return nil, fmt.Errorf("erasing contents in %q: %w", ref.resolvedPath, err)
 			}
-			logrus.Debugf("overwriting existing container image directory %q", ref.resolvedPath)
+			log.Debugf("overwriting existing container image directory %q", ref.resolvedPath)
 		}
 	} else {
 		// create directory if it doesn't exist
@@ -152,7 +154,7 @@ func (d *dirImageDestination) PutBlobWithOptions(ctx context.Context, stream io.
 
 	digester, stream := putblobdigest.DigestIfCanonicalUnknown(stream, inputInfo)
 	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
-	size, err := io.Copy(blobFile, stream)
+	size, err := blobfile.Write(blobFile, stream, inputInfo.Size)
 	if err != nil {
 		return private.UploadedBlob{}, err
 	}
@@ -214,6 +216,14 @@ func (d *dirImageDestination) TryReusingBlobWithOptions(ctx context.Context, inf
 	return true, private.ReusedBlob{Digest: info.Digest, Size: finfo.Size()}, nil
 }
 
+// HasBlob returns true if a blob with info.Digest would not need to be uploaded, because it already exists at the destination.
+// info.Digest must not be empty.
+func (d *dirImageDestination) HasBlob(ctx context.Context, info types.BlobInfo, cache blobinfocache.BlobInfoCache2) (bool, error) {
+	// TryReusingBlobWithOptions only checks whether the blob exists, it does not modify the destination.
+	reused, _, err := d.TryReusingBlobWithOptions(ctx, info, private.TryReusingBlobOptions{Cache: cache})
+	return reused, err
+}
+
 // PutManifest writes manifest to the destination.
 // If instanceDigest is not nil, it contains a digest of the specific manifest instance to write the manifest for (when
 // the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/containers/image/v5/internal/blobfile"
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
 	"github.com/containers/image/v5/internal/manifest"
@@ -22,11 +23,12 @@ type dirImageSource struct {
 	stubs.NoGetBlobAtInitialize
 
 	ref dirReference
+	sys *types.SystemContext
 }
 
 // newImageSource returns an ImageSource reading from an existing directory.
 // The caller must call .Close() on the returned ImageSource.
-func newImageSource(ref dirReference) private.ImageSource {
+func newImageSource(sys *types.SystemContext, ref dirReference) private.ImageSource {
 	s := &dirImageSource{
 		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
 			HasThreadSafeGetBlob: false,
@@ -34,6 +36,7 @@ func newImageSource(ref dirReference) private.ImageSource {
 		NoGetBlobAtInitialize: stubs.NoGetBlobAt(ref),
 
 		ref: ref,
+		sys: sys,
 	}
 	s.Compat = impl.AddCompat(s)
 	return s
@@ -74,15 +77,7 @@ func (s *dirImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache
 	if err != nil {
 		return nil, -1, err
 	}
-	r, err := os.Open(path)
-	if err != nil {
-		return nil, -1, err
-	}
-	fi, err := r.Stat()
-	if err != nil {
-		return nil, -1, err
-	}
-	return r, fi.Size(), nil
+	return blobfile.Open(s.sys, path, info.Digest)
 }
 
 // GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
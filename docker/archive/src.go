@@ -20,7 +20,7 @@ func newImageSource(sys *types.SystemContext, ref archiveReference) (private.Ima
 		archive = ref.archiveReader
 		closeArchive = false
 	} else {
-		a, err := tarfile.NewReaderFromFile(sys, ref.path)
+		a, err := tarfile.NewReaderFromFileForSingleSource(sys, ref.path)
 		if err != nil {
 			return nil, err
 		}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -14,7 +15,7 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/containers/image/v5/internal/log"
 )
 
 const (
@@ -25,7 +26,9 @@ const (
 )
 
 // bodyReader is an io.ReadCloser returned by dockerImageSource.GetBlob,
-// which can transparently resume some (very limited) kinds of aborted connections.
+// which can transparently resume some kinds of aborted or stalled connections, by reconnecting with a Range request
+// starting at the current offset. The caller sees a single uninterrupted stream, so any digest it computes
+// over the data read so far remains valid.
 type bodyReader struct {
 	ctx                 context.Context
 	c                   *dockerClient
@@ -147,7 +150,7 @@ func (br *bodyReader) Read(p []byte) (int, error) {
 		br.lastSuccessTime = time.Now()
 		return n, err // Unlike the default: case, don’t log anything.
 
-	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET):
+	case br.ctx.Err() == nil && isResumableBodyError(err):
 		originalErr := err
 		redactedURL := br.logURL.Redacted()
 		if err := br.errorIfNotReconnecting(originalErr, redactedURL); err != nil {
@@ -155,10 +158,15 @@ func (br *bodyReader) Read(p []byte) (int, error) {
 		}
 
 		if err := br.body.Close(); err != nil {
-			logrus.Debugf("Error closing blob body: %v", err) // … and ignore err otherwise
+			log.Debugf("Error closing blob body: %v", err) // … and ignore err otherwise
 		}
 		br.body = nil
-		time.Sleep(1*time.Second + time.Duration(rand.Intn(100_000))*time.Microsecond) // Some jitter so that a failure blip doesn’t cause a deterministic stampede
+		delay := 1*time.Second + time.Duration(rand.Intn(100_000))*time.Microsecond // Some jitter so that a failure blip doesn’t cause a deterministic stampede
+		select {
+		case <-br.ctx.Done():
+			return n, fmt.Errorf("%w (while waiting to reconnect: %v)", originalErr, br.ctx.Err())
+		case <-time.After(delay):
+		}
 
 		headers := map[string][]string{
 			"Range": {fmt.Sprintf("bytes=%d-", br.offset)},
@@ -187,13 +195,16 @@ func (br *bodyReader) Read(p []byte) (int, error) {
 			}
 			// Continue below
 		case http.StatusOK:
-			return n, fmt.Errorf("%w (after reconnecting, server did not process a Range: header, status %d)", originalErr, http.StatusOK)
+			// If nothing has been read yet, the full blob is what we need.
+			if br.offset != 0 {
+				return n, fmt.Errorf("%w (after reconnecting, server did not process a Range: header, status %d)", originalErr, http.StatusOK)
+			}
 		default:
 			err := registryHTTPResponseToError(res)
 			return n, fmt.Errorf("%w (after reconnecting, fetching blob: %v)", originalErr, err)
 		}
 
-		logrus.Debugf("Successfully reconnected to %s", redactedURL)
+		log.Debugf("Successfully reconnected to %s", redactedURL)
 		consumedBody = true
 		br.body = res.Body
 		br.lastRetryOffset = br.offset
@@ -201,11 +212,25 @@ func (br *bodyReader) Read(p []byte) (int, error) {
 		return n, nil
 
 	default:
-		logrus.Debugf("Error reading blob body from %s: %#v", br.logURL.Redacted(), err)
+		log.Debugf("Error reading blob body from %s: %#v", br.logURL.Redacted(), err)
 		return n, err
 	}
 }
 
+// isResumableBodyError returns true if err, returned while reading a blob body, indicates a broken or stalled connection,
+// so that it makes sense to continue reading the blob using a new connection.
+func isResumableBodyError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.ETIMEDOUT) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // millisecondsSinceOptional is like currentTime.Sub(tm).Milliseconds, but it returns a floating-point value.
 // If tm is time.Time{}, it returns math.NaN()
 func millisecondsSinceOptional(currentTime time.Time, tm time.Time) float64 {
@@ -222,22 +247,22 @@ func (br *bodyReader) errorIfNotReconnecting(originalErr error, redactedURL stri
 	msSinceFirstConnection := millisecondsSinceOptional(currentTime, br.firstConnectionTime)
 	msSinceLastRetry := millisecondsSinceOptional(currentTime, br.lastRetryTime)
 	msSinceLastSuccess := millisecondsSinceOptional(currentTime, br.lastSuccessTime)
-	logrus.Debugf("Reading blob body from %s failed (%#v), decision inputs: total %d @%.3f ms, last retry %d @%.3f ms, last progress @%.3f ms",
+	log.Debugf("Reading blob body from %s failed (%#v), decision inputs: total %d @%.3f ms, last retry %d @%.3f ms, last progress @%.3f ms",
 		redactedURL, originalErr, br.offset, msSinceFirstConnection, br.lastRetryOffset, msSinceLastRetry, msSinceLastSuccess)
 	progress := br.offset - br.lastRetryOffset
 	if progress >= bodyReaderMinimumProgress {
-		logrus.Infof("Reading blob body from %s failed (%v), reconnecting after %d bytes…", redactedURL, originalErr, progress)
+		log.Infof("Reading blob body from %s failed (%v), reconnecting after %d bytes…", redactedURL, originalErr, progress)
 		return nil
 	}
 	if br.lastRetryTime == (time.Time{}) {
-		logrus.Infof("Reading blob body from %s failed (%v), reconnecting (first reconnection)…", redactedURL, originalErr)
+		log.Infof("Reading blob body from %s failed (%v), reconnecting (first reconnection)…", redactedURL, originalErr)
 		return nil
 	}
 	if msSinceLastRetry >= bodyReaderMSSinceLastRetry {
-		logrus.Infof("Reading blob body from %s failed (%v), reconnecting after %.3f ms…", redactedURL, originalErr, msSinceLastRetry)
+		log.Infof("Reading blob body from %s failed (%v), reconnecting after %.3f ms…", redactedURL, originalErr, msSinceLastRetry)
 		return nil
 	}
-	logrus.Debugf("Not reconnecting to %s: insufficient progress %d / time since last retry %.3f ms", redactedURL, progress, msSinceLastRetry)
+	log.Debugf("Not reconnecting to %s: insufficient progress %d / time since last retry %.3f ms", redactedURL, progress, msSinceLastRetry)
 	return fmt.Errorf("(heuristic tuning data: total %d @%.3f ms, last retry %d @%.3f ms, last progress @ %.3f ms): %w",
 		br.offset, msSinceFirstConnection, br.lastRetryOffset, msSinceLastRetry, msSinceLastSuccess, originalErr)
 }
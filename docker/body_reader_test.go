@@ -1,12 +1,25 @@
 package docker
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/containers/image/v5/pkg/faultinject"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -194,3 +207,117 @@ func TestBodyReaderErrorIfNotReconnecting(t *testing.T) {
 		}
 	}
 }
+
+func TestIsResumableBodyError(t *testing.T) {
+	for _, c := range []struct {
+		err      error
+		expected bool
+	}{
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{syscall.ETIMEDOUT, true},
+		{os.ErrDeadlineExceeded, true}, // A net.Error with Timeout() == true
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("some other error"), false},
+	} {
+		assert.Equal(t, c.expected, isResumableBodyError(c.err), c.err.Error())
+	}
+}
+
+func TestBodyReaderResume(t *testing.T) {
+	blob := bytes.Repeat([]byte("0123456789"), 100_000)
+	blobDigest := digest.FromBytes(blob)
+	const interruptAt = 300_000
+	var rangeHeaders []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/blob":
+			rangeHeader := r.Header.Get("Range")
+			rangeHeaders = append(rangeHeaders, rangeHeader)
+			if rangeHeader == "" {
+				// Send a part of the blob, then break the connection.
+				w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(blob[:interruptAt])
+				panic(http.ErrAbortHandler)
+			}
+			var first int
+			_, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &first)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, len(blob)-1, len(blob)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(blob[first:])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+	client, err := newDockerClient(&types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}, registry, registry)
+	require.NoError(t, err)
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+
+	res, err := client.makeRequest(context.Background(), http.MethodGet, "/blob", nil, nil, noAuth, nil)
+	require.NoError(t, err)
+	reader, err := newBodyReader(context.Background(), client, "/blob", res.Body)
+	require.NoError(t, err)
+	defer reader.Close()
+	// The digest is computed over the whole stream, across the reconnection.
+	digester := digest.Canonical.Digester()
+	_, err = io.Copy(digester.Hash(), reader)
+	require.NoError(t, err)
+	assert.Equal(t, blobDigest, digester.Digest())
+	require.Len(t, rangeHeaders, 2)
+	assert.Equal(t, "", rangeHeaders[0])
+	assert.Regexp(t, `^bytes=[0-9]+-$`, rangeHeaders[1])
+}
+
+func TestBodyReaderResumeInjectedTruncation(t *testing.T) {
+	blob := bytes.Repeat([]byte("0123456789"), 100_000)
+	blobDigest := digest.FromBytes(blob)
+	var rangeHeaders []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/blob":
+			rangeHeaders = append(rangeHeaders, r.Header.Get("Range"))
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+	client, err := newDockerClient(&types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		FaultInjector: faultinject.InjectorFunc(func(ctx context.Context, point faultinject.Point, target string) *faultinject.Fault {
+			if point == faultinject.RegistryRequest && strings.HasSuffix(target, "/blob") && len(rangeHeaders) == 0 {
+				return &faultinject.Fault{Truncate: true, TruncateAfter: 300_000}
+			}
+			return nil
+		}),
+	}, registry, registry)
+	require.NoError(t, err)
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+
+	res, err := client.makeRequest(context.Background(), http.MethodGet, "/blob", nil, nil, noAuth, nil)
+	require.NoError(t, err)
+	reader, err := newBodyReader(context.Background(), client, "/blob", res.Body)
+	require.NoError(t, err)
+	defer reader.Close()
+	digester := digest.Canonical.Digester()
+	_, err = io.Copy(digester.Hash(), reader)
+	require.NoError(t, err)
+	assert.Equal(t, blobDigest, digester.Digest())
+	assert.Equal(t, []string{"", "bytes=300000-"}, rangeHeaders)
+}
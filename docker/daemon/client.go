@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"github.com/containers/image/v5/types"
+	"github.com/docker/cli/cli/connhelper"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/docker/go-connections/tlsconfig"
 )
@@ -16,6 +17,28 @@ func newDockerClient(sys *types.SystemContext) (*dockerclient.Client, error) {
 		host = sys.DockerDaemonHost
 	}
 
+	// For ssh:// hosts, run "docker system dial-stdio" on the remote host over SSH,
+	// the same way the docker CLI does.
+	var sshFlags []string
+	if sys != nil {
+		sshFlags = sys.DockerDaemonSSHFlags
+	}
+	helper, err := connhelper.GetConnectionHelperWithSSHOpts(host, sshFlags)
+	if err != nil {
+		return nil, err
+	}
+	if helper != nil {
+		return dockerclient.NewClientWithOpts(
+			dockerclient.WithHTTPClient(&http.Client{
+				Transport:     &http.Transport{DialContext: helper.Dialer},
+				CheckRedirect: dockerclient.CheckRedirect,
+			}),
+			dockerclient.WithHost(helper.Host),
+			dockerclient.WithDialContext(helper.Dialer),
+			dockerclient.WithAPIVersionNegotiation(),
+		)
+	}
+
 	opts := []dockerclient.Opt{
 		dockerclient.WithHost(host),
 		dockerclient.WithAPIVersionNegotiation(),
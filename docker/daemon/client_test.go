@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"github.com/containers/image/v5/types"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDockerClientFromNilSystemContext(t *testing.T) {
@@ -99,6 +101,31 @@ func TestSpecifyPlainHTTPViaHostScheme(t *testing.T) {
 	assert.NoError(t, client.Close())
 }
 
+func TestDockerClientOverSSH(t *testing.T) {
+	// Replace ssh(1) with a script which records its arguments.
+	binDir := t.TempDir()
+	argsFile := filepath.Join(t.TempDir(), "args")
+	err := os.WriteFile(filepath.Join(binDir, "ssh"), []byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\n"), 0755)
+	require.NoError(t, err)
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	client, err := newDockerClient(&types.SystemContext{
+		DockerDaemonHost:     "ssh://user@remote.example.com:2222/run/user/1000/docker.sock",
+		DockerDaemonSSHFlags: []string{"-i", "/path/to/key"},
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Ping(context.Background())
+	assert.Error(t, err) // The fake ssh does not actually talk to a daemon.
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Equal(t, "-i /path/to/key -o ConnectTimeout=30 -l user -p 2222 -- remote.example.com docker --host unix:///run/user/1000/docker.sock system dial-stdio\n", string(args))
+
+	_, err = newDockerClient(&types.SystemContext{DockerDaemonHost: "ssh://remote.example.com?invalid=1"})
+	assert.Error(t, err)
+}
+
 func testDir(t *testing.T) string {
 	testDir, err := os.Getwd()
 	if err != nil {
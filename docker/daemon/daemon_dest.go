@@ -9,10 +9,10 @@ import (
 
 	"github.com/containers/image/v5/docker/internal/tarfile"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/types"
 	"github.com/docker/docker/client"
-	"github.com/sirupsen/logrus"
 )
 
 type daemonImageDestination struct {
@@ -73,7 +73,7 @@ func imageLoadGoroutine(ctx context.Context, c *client.Client, reader *io.PipeRe
 	defer c.Close()
 	err := errors.New("Internal error: unexpected panic in imageLoadGoroutine")
 	defer func() {
-		logrus.Debugf("docker-daemon: sending done, status %v", err)
+		log.DebugfContext(ctx, "docker-daemon: sending done, status %v", err)
 		statusChannel <- err
 	}()
 	defer func() {
@@ -81,7 +81,7 @@ func imageLoadGoroutine(ctx context.Context, c *client.Client, reader *io.PipeRe
 			reader.Close()
 		} else {
 			if err := reader.CloseWithError(err); err != nil {
-				logrus.Debugf("imageLoadGoroutine: Error during reader.CloseWithError: %v", err)
+				log.DebugfContext(ctx, "imageLoadGoroutine: Error during reader.CloseWithError: %v", err)
 			}
 		}
 	}()
@@ -135,7 +135,7 @@ func (d *daemonImageDestination) MustMatchRuntimeOS() bool {
 // Close removes resources associated with an initialized ImageDestination, if any.
 func (d *daemonImageDestination) Close() error {
 	if !d.committed {
-		logrus.Debugf("docker-daemon: Closing tar stream to abort loading")
+		log.Debugf("docker-daemon: Closing tar stream to abort loading")
 		// In principle, goroutineCancel() should abort the HTTP request and stop the process from continuing.
 		// In practice, though, various HTTP implementations used by client.Client.ImageLoad() (including
 		// https://github.com/golang/net/blob/master/context/ctxhttp/ctxhttp_pre17.go and the
@@ -167,7 +167,7 @@ func (d *daemonImageDestination) Reference() types.ImageReference {
 // - Uploaded data MAY be visible to others before Commit() is called
 // - Uploaded data MAY be removed or MAY remain around if Close() is called without Commit() (i.e. rollback is allowed but not guaranteed)
 func (d *daemonImageDestination) Commit(ctx context.Context, unparsedToplevel types.UnparsedImage) error {
-	logrus.Debugf("docker-daemon: Closing tar stream")
+	log.DebugfContext(ctx, "docker-daemon: Closing tar stream")
 	if err := d.archive.Close(); err != nil {
 		return err
 	}
@@ -176,7 +176,7 @@ func (d *daemonImageDestination) Commit(ctx context.Context, unparsedToplevel ty
 	}
 	d.committed = true // We may still fail, but we are done sending to imageLoadGoroutine.
 
-	logrus.Debugf("docker-daemon: Waiting for status")
+	log.DebugfContext(ctx, "docker-daemon: Waiting for status")
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
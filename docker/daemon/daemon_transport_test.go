@@ -217,6 +217,7 @@ func TestReferencePolicyConfigurationNamespaces(t *testing.T) {
 	ref, err = ParseReference("busybox:notlatest")
 	require.NoError(t, err)
 	assert.Equal(t, []string{
+		"docker.io/library/busybox:*",
 		"docker.io/library/busybox",
 		"docker.io/library",
 		"docker.io",
@@ -0,0 +1,148 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Attachments are the manifests attached to an image manifest in a registry repository, as returned by ListAttachments.
+type Attachments struct {
+	ManifestDigest digest.Digest // The digest of the image manifest
+	// A reference to the image manifest by ManifestDigest, which, unlike a tag, can’t move to a different manifest
+	// after the attachments were listed.
+	Image types.ImageReference
+	// References to the attached manifests, by digest: the referrers (recursively), the “referrers tag schema” indexes,
+	// and the sigstore signature manifest. Every manifest precedes the manifests it refers to,
+	// so deleting them in this order never leaves an attachment of a deleted manifest behind.
+	Manifests []types.ImageReference
+}
+
+// ListAttachments returns the manifests attached to the image manifest of ref, using the registry of ref
+// (and not any mirrors), so that they can be deleted together with the image.
+// The referrers API is used if the registry supports it, with a fallback to the “referrers tag schema”.
+func ListAttachments(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (*Attachments, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return nil, errors.New("ref must be a dockerReference")
+	}
+	if dr.isUnknownDigest {
+		return nil, errors.New("Docker reference without a tag or digest does not have attachments")
+	}
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDockerClientFromRef(ctx, sys, dr, registryConfig, false, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	tagOrDigest, err := dr.tagOrDigest()
+	if err != nil {
+		return nil, err
+	}
+	manifestBlob, _, err := client.fetchManifest(ctx, dr, tagOrDigest)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	if err != nil {
+		return nil, fmt.Errorf("computing manifest digest: %w", err)
+	}
+
+	w := referrersWalker{
+		maxDepth: math.MaxInt, // The total number of nodes is limited by maxReferrersTreeNodes.
+		list: func(ctx context.Context, subjectDigest digest.Digest) ([]imgspecv1.Descriptor, error) {
+			return client.listReferrers(ctx, dr, subjectDigest, "")
+		},
+		onPath: set.New[digest.Digest](),
+	}
+	root, err := w.walk(ctx, imgspecv1.Descriptor{Digest: manifestDigest}, 0)
+	if err != nil {
+		return nil, err
+	}
+	digests := []digest.Digest{}
+	seen := set.New[digest.Digest]()
+	add := func(d digest.Digest) {
+		if !seen.Contains(d) {
+			seen.Add(d)
+			digests = append(digests, d)
+		}
+	}
+	// addTag adds the manifest of tag, if it exists.
+	addTag := func(tag string) error {
+		d, err := client.fetchManifestDigest(ctx, dr, tag)
+		if err != nil {
+			if isManifestUnknownError(err) {
+				log.DebugfContext(ctx, "Attachment tag %s does not exist: %v", tag, err)
+				return nil
+			}
+			return err
+		}
+		add(d)
+		return nil
+	}
+	// addReferrers adds the referrers of node, recursively, and the “referrers tag schema” index of node.
+	var addReferrers func(node ReferrerNode) error
+	addReferrers = func(node ReferrerNode) error {
+		for _, child := range node.Referrers {
+			if err := addReferrers(child); err != nil {
+				return err
+			}
+			add(child.Descriptor.Digest)
+		}
+		fallbackTag, err := referrersFallbackTag(node.Descriptor.Digest)
+		if err != nil {
+			return err
+		}
+		return addTag(fallbackTag)
+	}
+	if err := addReferrers(root); err != nil {
+		return nil, err
+	}
+	sigstoreTag, err := sigstoreAttachmentTag(manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	if err := addTag(sigstoreTag); err != nil {
+		return nil, err
+	}
+
+	imageRef, err := dr.withDigest(manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	res := &Attachments{
+		ManifestDigest: manifestDigest,
+		Image:          imageRef,
+		Manifests:      []types.ImageReference{},
+	}
+	for _, d := range digests {
+		attachmentRef, err := dr.withDigest(d)
+		if err != nil {
+			return nil, err
+		}
+		res.Manifests = append(res.Manifests, attachmentRef)
+	}
+	return res, nil
+}
+
+// withDigest returns a reference to the manifest with digest d in the repository of ref.
+func (ref dockerReference) withDigest(d digest.Digest) (types.ImageReference, error) {
+	named, err := reference.WithDigest(reference.TrimNamed(ref.ref), d)
+	if err != nil {
+		return nil, err
+	}
+	return NewReference(named)
+}
@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAttachments(t *testing.T) {
+	const subjectManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`
+	const signatureManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},` +
+		`"layers":[],"annotations":{"signature":"yes"}}`
+	subjectDigest := digest.FromString(subjectManifest)
+	signatureDigest := digest.FromString(signatureManifest)
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+
+	// attachmentDigests returns the digests of refs.
+	attachmentDigests := func(refs []types.ImageReference) []digest.Digest {
+		res := []digest.Digest{}
+		for _, ref := range refs {
+			canonical, ok := ref.DockerReference().(reference.Canonical)
+			require.True(t, ok)
+			res = append(res, canonical.Digest())
+		}
+		return res
+	}
+
+	for _, supportsReferrers := range []bool{true, false} {
+		registry := newReferrersRegistryMock(t, supportsReferrers)
+		registry.manifests["tag"] = []byte(subjectManifest)
+		registry.manifests[subjectDigest.String()] = []byte(subjectManifest)
+		ref, err := ParseReference("//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag")
+		require.NoError(t, err)
+
+		// No attachments
+		attachments, err := ListAttachments(context.Background(), sys, ref)
+		require.NoError(t, err, supportsReferrers)
+		assert.Equal(t, subjectDigest, attachments.ManifestDigest, supportsReferrers)
+		assert.Empty(t, attachments.Manifests, supportsReferrers)
+
+		// subject ← sbom ← attestation, a sigstore signature, and (without the referrers API) the referrers tag schema indexes.
+		sbomDigest, err := AttachReferrer(context.Background(), sys, ref, subjectDigest,
+			ReferrerArtifact{ArtifactType: "application/spdx+json", Data: []byte(`{"spdxVersion":"SPDX-2.3"}`)})
+		require.NoError(t, err, supportsReferrers)
+		attestationDigest, err := AttachReferrer(context.Background(), sys, ref, sbomDigest,
+			ReferrerArtifact{ArtifactType: "application/vnd.in-toto+json", Data: []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)})
+		require.NoError(t, err, supportsReferrers)
+		sigstoreTag, err := sigstoreAttachmentTag(subjectDigest)
+		require.NoError(t, err)
+		registry.manifests[sigstoreTag] = []byte(signatureManifest)
+		registry.manifests[signatureDigest.String()] = []byte(signatureManifest)
+
+		// fallbackIndexDigests returns the digest of the referrers tag schema index of d, if the registry does not support the referrers API.
+		fallbackIndexDigests := func(d digest.Digest) []digest.Digest {
+			if supportsReferrers {
+				return nil
+			}
+			fallbackTag, err := referrersFallbackTag(d)
+			require.NoError(t, err)
+			index, ok := registry.manifests[fallbackTag]
+			require.True(t, ok)
+			return []digest.Digest{digest.FromBytes(index)}
+		}
+		expected := []digest.Digest{attestationDigest}
+		expected = append(expected, fallbackIndexDigests(sbomDigest)...)
+		expected = append(expected, sbomDigest)
+		expected = append(expected, fallbackIndexDigests(subjectDigest)...)
+		expected = append(expected, signatureDigest)
+		attachments, err = ListAttachments(context.Background(), sys, ref)
+		require.NoError(t, err, supportsReferrers)
+		assert.Equal(t, subjectDigest, attachments.ManifestDigest, supportsReferrers)
+		assert.Equal(t, expected, attachmentDigests(attachments.Manifests), supportsReferrers)
+		assert.Equal(t, []digest.Digest{subjectDigest}, attachmentDigests([]types.ImageReference{attachments.Image}), supportsReferrers)
+
+		// Deleting the attachments in order, and then the image, deletes everything.
+		for _, attachment := range attachments.Manifests {
+			err := attachment.DeleteImage(context.Background(), sys)
+			require.NoError(t, err, supportsReferrers)
+		}
+		err = attachments.Image.DeleteImage(context.Background(), sys)
+		require.NoError(t, err, supportsReferrers)
+		assert.Empty(t, registry.manifests, supportsReferrers)
+
+		_, err = ListAttachments(context.Background(), sys, ref)
+		assert.Error(t, err, supportsReferrers)
+	}
+}
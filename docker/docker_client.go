@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,11 +19,17 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/multierr"
 	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/internal/tracing"
 	"github.com/containers/image/v5/internal/useragent"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/pkg/faultinject"
+	"github.com/containers/image/v5/pkg/logging"
+	"github.com/containers/image/v5/pkg/metrics"
+	"github.com/containers/image/v5/pkg/registryfeatures"
 	"github.com/containers/image/v5/pkg/sysregistriesv2"
 	"github.com/containers/image/v5/pkg/tlsclientconfig"
 	"github.com/containers/image/v5/types"
@@ -33,7 +40,10 @@ import (
 	"github.com/docker/go-connections/tlsconfig"
 	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
 const (
@@ -100,16 +110,28 @@ type dockerClient struct {
 	sys       *types.SystemContext
 	registry  string
 	userAgent string
+	certDir   string // Contains TLS certificates used in addition to tlsClientConfig
+	// tokenService, if not "", overrides the service parameter of the registry’s bearer authentication challenge.
+	tokenService string
+	// additionalScopes are requested in every bearer token, in addition to scope and any extra scope of a request.
+	additionalScopes []authScope
 
 	// tlsClientConfig is setup by newDockerClient and will be used and updated
 	// by detectProperties(). Callers can edit tlsClientConfig.InsecureSkipVerify in the meantime.
+	// It does not contain the certificates from certDir, those are loaded (and reloaded on changes) by detectProperties().
 	tlsClientConfig *tls.Config
 	// The following members are not set by newDockerClient and must be set by callers if needed.
-	auth                   types.DockerAuthConfig
-	registryToken          string
-	signatureBase          lookasideStorageBase
-	useSigstoreAttachments bool
-	scope                  authScope
+	auth                       types.DockerAuthConfig
+	registryToken              string
+	signatureBase              lookasideStorageBase
+	useSigstoreAttachments     bool
+	writeSigstoreToLookaside   bool
+	useSigstoreBundleReferrers bool
+	scope                      authScope
+	peerAgent                  *peerAgent              // nil if no peer-to-peer distribution agent is configured
+	metrics                    metrics.Recorder        // never nil
+	faults                     faultinject.Injector    // nil if no faults are injected
+	features                   *registryfeatures.Cache // never nil
 
 	// The following members are detected registry properties:
 	// They are set after a successful detectProperties(), and never change afterwards.
@@ -157,7 +179,7 @@ func newBearerTokenFromJSONBlob(blob []byte) (*bearerToken, error) {
 	}
 	if token.ExpiresIn < minimumTokenLifetimeSeconds {
 		token.ExpiresIn = minimumTokenLifetimeSeconds
-		logrus.Debugf("Increasing token expiration to: %d seconds", token.ExpiresIn)
+		log.Debugf("Increasing token expiration to: %d seconds", token.ExpiresIn)
 	}
 	if token.IssuedAt.IsZero() {
 		token.IssuedAt = time.Now().UTC()
@@ -196,7 +218,7 @@ func dockerCertDir(sys *types.SystemContext, hostPort string) (string, error) {
 			continue
 		}
 		if os.IsPermission(err) {
-			logrus.Debugf("error accessing certs directory due to permissions: %v", err)
+			log.Debugf("error accessing certs directory due to permissions: %v", err)
 			continue
 		}
 		return "", err
@@ -208,8 +230,8 @@ func dockerCertDir(sys *types.SystemContext, hostPort string) (string, error) {
 // “write” specifies whether the client will be used for "write" access (in particular passed to lookaside.go:toplevelFromSection)
 // signatureBase is always set in the return value
 // The caller must call .Close() on the returned client when done.
-func newDockerClientFromRef(sys *types.SystemContext, ref dockerReference, registryConfig *registryConfiguration, write bool, actions string) (*dockerClient, error) {
-	auth, err := config.GetCredentialsForRef(sys, ref.ref)
+func newDockerClientFromRef(ctx context.Context, sys *types.SystemContext, ref dockerReference, registryConfig *registryConfiguration, write bool, actions string) (*dockerClient, error) {
+	auth, err := config.GetCredentialsForRefWithContext(ctx, sys, ref.ref)
 	if err != nil {
 		return nil, fmt.Errorf("getting username and password: %w", err)
 	}
@@ -230,6 +252,8 @@ func newDockerClientFromRef(sys *types.SystemContext, ref dockerReference, regis
 	}
 	client.signatureBase = sigBase
 	client.useSigstoreAttachments = registryConfig.useSigstoreAttachments(ref)
+	client.writeSigstoreToLookaside = registryConfig.writeSigstoreToLookaside(ref)
+	client.useSigstoreBundleReferrers = registryConfig.useSigstoreBundleReferrers(ref)
 	client.scope.resourceType = "repository"
 	client.scope.actions = actions
 	client.scope.remoteName = reference.Path(ref.ref)
@@ -244,6 +268,9 @@ func newDockerClientFromRef(sys *types.SystemContext, ref dockerReference, regis
 // (e.g., username and password); those must be set by callers if necessary.
 // The caller must call .Close() on the returned client when done.
 func newDockerClient(sys *types.SystemContext, registry, reference string) (*dockerClient, error) {
+	if err := checkRegistryAccess(sys, registry); err != nil {
+		return nil, err
+	}
 	hostName := registry
 	if registry == dockerHostname {
 		registry = dockerRegistry
@@ -261,7 +288,9 @@ func newDockerClient(sys *types.SystemContext, registry, reference string) (*doc
 	if err != nil {
 		return nil, err
 	}
-	if err := tlsclientconfig.SetupCertificates(certDir, tlsClientConfig); err != nil {
+	// Only validate the certificates here, so that configuration errors are reported early;
+	// detectProperties loads them into a transport which reloads them when they change.
+	if err := tlsclientconfig.SetupCertificates(certDir, tlsClientConfig.Clone()); err != nil {
 		return nil, err
 	}
 
@@ -285,11 +314,55 @@ func newDockerClient(sys *types.SystemContext, registry, reference string) (*doc
 		userAgent = sys.DockerRegistryUserAgent
 	}
 
+	peerAgent, err := newPeerAgent(sys)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := metrics.Discard
+	if sys != nil && sys.MetricsRecorder != nil {
+		recorder = sys.MetricsRecorder
+	}
+
+	var faults faultinject.Injector // = nil
+	if sys != nil {
+		faults = sys.FaultInjector
+	}
+
+	features := registryfeatures.New()
+	if sys != nil && sys.DockerRegistryFeatureCache != nil {
+		features = sys.DockerRegistryFeatureCache
+	}
+
+	tokenService := ""
+	var additionalScopes []authScope
+	if sys != nil {
+		tokenService = sys.DockerTokenService
+		for _, s := range sys.DockerTokenAdditionalScopes {
+			if s.ResourceType == "" || s.Name == "" || s.Actions == "" ||
+				strings.Contains(s.ResourceType, ":") || strings.Contains(s.Name, ":") {
+				return nil, fmt.Errorf("invalid additional token scope %q", fmt.Sprintf("%s:%s:%s", s.ResourceType, s.Name, s.Actions))
+			}
+			additionalScopes = append(additionalScopes, authScope{
+				resourceType: s.ResourceType,
+				remoteName:   s.Name,
+				actions:      s.Actions,
+			})
+		}
+	}
+
 	return &dockerClient{
 		sys:              sys,
 		registry:         registry,
 		userAgent:        userAgent,
+		certDir:          certDir,
 		tlsClientConfig:  tlsClientConfig,
+		peerAgent:        peerAgent,
+		metrics:          recorder,
+		faults:           faults,
+		features:         features,
+		tokenService:     tokenService,
+		additionalScopes: additionalScopes,
 		reportedWarnings: set.New[string](),
 	}, nil
 }
@@ -353,7 +426,7 @@ func SearchRegistry(ctx context.Context, sys *types.SystemContext, registry, ima
 
 	// Get credentials from authfile for the underlying hostname
 	// We can't use GetCredentialsForRef here because we want to search the whole registry.
-	auth, err := config.GetCredentials(sys, registry)
+	auth, err := config.GetCredentialsWithContext(ctx, sys, registry)
 	if err != nil {
 		return nil, fmt.Errorf("getting username and password: %w", err)
 	}
@@ -394,14 +467,14 @@ func SearchRegistry(ctx context.Context, sys *types.SystemContext, registry, ima
 		q.Set("n", strconv.Itoa(limit))
 		u.RawQuery = q.Encode()
 
-		logrus.Debugf("trying to talk to v1 search endpoint")
+		log.DebugfContext(ctx, "trying to talk to v1 search endpoint")
 		resp, err := client.makeRequest(ctx, http.MethodGet, u.String(), nil, nil, noAuth, nil)
 		if err != nil {
-			logrus.Debugf("error getting search results from v1 endpoint %q: %v", registry, err)
+			log.DebugfContext(ctx, "error getting search results from v1 endpoint %q: %v", registry, err)
 		} else {
 			defer resp.Body.Close()
 			if resp.StatusCode != http.StatusOK {
-				logrus.Debugf("error getting search results from v1 endpoint %q: %v", registry, httpResponseToError(resp, ""))
+				log.DebugfContext(ctx, "error getting search results from v1 endpoint %q: %v", registry, httpResponseToError(resp, ""))
 			} else {
 				if err := json.NewDecoder(resp.Body).Decode(v1Res); err != nil {
 					return nil, err
@@ -411,19 +484,19 @@ func SearchRegistry(ctx context.Context, sys *types.SystemContext, registry, ima
 		}
 	}
 
-	logrus.Debugf("trying to talk to v2 search endpoint")
+	log.DebugfContext(ctx, "trying to talk to v2 search endpoint")
 	searchRes := []SearchResult{}
 	path := "/v2/_catalog"
 	for len(searchRes) < limit {
 		resp, err := client.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
 		if err != nil {
-			logrus.Debugf("error getting search results from v2 endpoint %q: %v", registry, err)
+			log.DebugfContext(ctx, "error getting search results from v2 endpoint %q: %v", registry, err)
 			return nil, fmt.Errorf("couldn't search registry %q: %w", registry, err)
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode != http.StatusOK {
 			err := registryHTTPResponseToError(resp)
-			logrus.Errorf("error getting search results from v2 endpoint %q: %v", registry, err)
+			log.ErrorfContext(ctx, "error getting search results from v2 endpoint %q: %v", registry, err)
 			return nil, fmt.Errorf("couldn't search registry %q: %w", registry, err)
 		}
 		v2Res := &V2Results{}
@@ -509,11 +582,10 @@ func needsRetryWithUpdatedScope(res *http.Response) (bool, *authScope) {
 						if newScope, err := parseAuthScope(scope); err == nil {
 							return true, newScope
 						} else {
-							logrus.WithFields(logrus.Fields{
-								"error":     err,
-								"scope":     scope,
-								"challenge": challenge,
-							}).Error("Failed to parse the authentication scope from the given challenge")
+							logging.Default().Error("Failed to parse the authentication scope from the given challenge",
+								"error", err,
+								"scope", scope,
+								"challenge", challenge)
 						}
 					}
 				}
@@ -530,7 +602,7 @@ func parseRetryAfter(res *http.Response, fallbackDelay time.Duration) time.Durat
 	if after == "" {
 		return fallbackDelay
 	}
-	logrus.Debugf("Detected 'Retry-After' header %q", after)
+	log.Debugf("Detected 'Retry-After' header %q", after)
 	// First, check if we have a numerical value.
 	if num, err := strconv.ParseInt(after, 10, 64); err == nil {
 		return time.Duration(num) * time.Second
@@ -542,10 +614,10 @@ func parseRetryAfter(res *http.Response, fallbackDelay time.Duration) time.Durat
 		if delta > 0 {
 			return delta
 		}
-		logrus.Debugf("Retry-After date in the past, ignoring it")
+		log.Debugf("Retry-After date in the past, ignoring it")
 		return fallbackDelay
 	}
-	logrus.Debugf("Invalid Retry-After format, ignoring it")
+	log.Debugf("Invalid Retry-After format, ignoring it")
 	return fallbackDelay
 }
 
@@ -578,7 +650,8 @@ func (c *dockerClient) makeRequestToResolvedURL(ctx context.Context, method stri
 		// was already read
 		if attempts == 1 && stream == nil && auth != noAuth {
 			if retry, newScope := needsRetryWithUpdatedScope(res); retry {
-				logrus.Debug("Detected insufficient_scope error, will retry request with updated scope")
+				log.DebugfContext(ctx, "Detected insufficient_scope error, will retry request with updated scope")
+				c.metrics.AddCounter(metrics.RegistryRetriesTotal, 1, map[string]string{metrics.LabelReason: metrics.ReasonInsufficientScope})
 				res.Body.Close()
 				// Note: This retry ignores extraScope. That’s, strictly speaking, incorrect, but we don’t currently
 				// expect the insufficient_scope errors to happen for those callers. If that changes, we can add support
@@ -600,7 +673,8 @@ func (c *dockerClient) makeRequestToResolvedURL(ctx context.Context, method stri
 		res.Body.Close()
 
 		delay = min(parseRetryAfter(res, delay), backoffMaxDelay)
-		logrus.Debugf("Too many requests to %s: sleeping for %f seconds before next attempt", requestURL.Redacted(), delay.Seconds())
+		log.DebugfContext(ctx, "Too many requests to %s: sleeping for %f seconds before next attempt", requestURL.Redacted(), delay.Seconds())
+		c.metrics.AddCounter(metrics.RegistryRetriesTotal, 1, map[string]string{metrics.LabelReason: metrics.ReasonTooManyRequests})
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
@@ -615,7 +689,14 @@ func (c *dockerClient) makeRequestToResolvedURL(ctx context.Context, method stri
 // streamLen, if not -1, specifies the length of the data expected on stream.
 // makeRequest should generally be preferred.
 // Note that no exponential back off is performed when receiving an http 429 status code.
-func (c *dockerClient) makeRequestToResolvedURLOnce(ctx context.Context, method string, resolvedURL *url.URL, headers map[string][]string, stream io.Reader, streamLen int64, auth sendAuth, extraScope *authScope) (*http.Response, error) {
+func (c *dockerClient) makeRequestToResolvedURLOnce(ctx context.Context, method string, resolvedURL *url.URL, headers map[string][]string, stream io.Reader, streamLen int64, auth sendAuth, extraScope *authScope) (_ *http.Response, retErr error) {
+	// The span only covers the request and response headers, reading the body is up to the caller.
+	ctx, span := tracing.Start(ctx, "HTTP "+method,
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.URLFull(resolvedURL.Redacted()),
+		semconv.ServerAddress(resolvedURL.Host))
+	defer func() { tracing.End(span, retErr) }()
+
 	req, err := http.NewRequestWithContext(ctx, method, resolvedURL.String(), stream)
 	if err != nil {
 		return nil, err
@@ -635,17 +716,73 @@ func (c *dockerClient) makeRequestToResolvedURLOnce(ctx context.Context, method
 			return nil, err
 		}
 	}
-	logrus.Debugf("%s %s", method, resolvedURL.Redacted())
-	res, err := c.client.Do(req)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if c.sys != nil && c.sys.DockerRequestMutator != nil {
+		if err := c.sys.DockerRequestMutator(req); err != nil {
+			return nil, fmt.Errorf("preparing request to %s: %w", resolvedURL.Redacted(), err)
+		}
+	}
+	log.DebugfContext(ctx, "%s %s", method, resolvedURL.Redacted())
+	start := time.Now()
+	res, err := c.doRequest(req)
+	c.metrics.ObserveHistogram(metrics.RegistryRequestDurationSeconds, time.Since(start).Seconds(), map[string]string{metrics.LabelMethod: method})
+	statusCode := metrics.ResultError
+	if err == nil {
+		statusCode = strconv.Itoa(res.StatusCode)
+	}
+	c.metrics.AddCounter(metrics.RegistryRequestsTotal, 1, map[string]string{metrics.LabelMethod: method, metrics.LabelStatusCode: statusCode})
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		// 4xx responses are not treated as errors here: 401 and 404 are routinely used during authentication and blob existence checks.
+		span.SetStatus(codes.Error, res.Status)
+	}
 	if warnings := res.Header.Values("Warning"); len(warnings) != 0 {
 		c.logResponseWarnings(res, warnings)
 	}
 	return res, nil
 }
 
+// doRequest sends req using c.client, unless c.faults injects a fault instead.
+func (c *dockerClient) doRequest(req *http.Request) (*http.Response, error) {
+	target := req.Method + " " + req.URL.Redacted()
+	fault := faultinject.Lookup(req.Context(), c.faults, faultinject.RegistryRequest, target)
+	if fault == nil {
+		return c.client.Do(req)
+	}
+	if fault.Timeout {
+		return nil, &url.Error{Op: req.Method, URL: req.URL.Redacted(), Err: faultinject.TimeoutError{Point: faultinject.RegistryRequest, Target: target}}
+	}
+	var res *http.Response
+	if fault.StatusCode != 0 {
+		log.Debugf("Injecting HTTP status %d into %s", fault.StatusCode, target)
+		header := fault.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		res = &http.Response{
+			Status:     fmt.Sprintf("%d %s", fault.StatusCode, http.StatusText(fault.StatusCode)),
+			StatusCode: fault.StatusCode,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}
+	} else {
+		var err error
+		res, err = c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res.Body = fault.ReadCloser(res.Body, faultinject.RegistryRequest, target)
+	return res, nil
+}
+
 // logResponseWarnings logs warningHeaders from res, if any.
 func (c *dockerClient) logResponseWarnings(res *http.Response, warningHeaders []string) {
 	c.reportedWarningsLock.Lock()
@@ -654,16 +791,16 @@ func (c *dockerClient) logResponseWarnings(res *http.Response, warningHeaders []
 	for _, header := range warningHeaders {
 		warningString := parseRegistryWarningHeader(header)
 		if warningString == "" {
-			logrus.Debugf("Ignored Warning: header from registry: %q", header)
+			log.Debugf("Ignored Warning: header from registry: %q", header)
 		} else {
 			if !c.reportedWarnings.Contains(warningString) {
 				c.reportedWarnings.Add(warningString)
 				// Note that reportedWarnings is based only on warningString, so that we don’t
 				// repeat the same warning for every request - but the warning includes the URL;
 				// so it may not be specific to that URL.
-				logrus.Warnf("Warning from registry (first encountered at %q): %q", res.Request.URL.Redacted(), warningString)
+				log.Warnf("Warning from registry (first encountered at %q): %q", res.Request.URL.Redacted(), warningString)
 			} else {
-				logrus.Debugf("Repeated warning from registry at %q: %q", res.Request.URL.Redacted(), warningString)
+				log.Debugf("Repeated warning from registry at %q: %q", res.Request.URL.Redacted(), warningString)
 			}
 		}
 	}
@@ -736,8 +873,8 @@ func (c *dockerClient) setupRequestAuth(req *http.Request, extraScope *authScope
 			registryToken := c.registryToken
 			if registryToken == "" {
 				cacheKey := ""
-				scopes := []authScope{c.scope}
-				if extraScope != nil {
+				scopes := append([]authScope{c.scope}, c.additionalScopes...)
+				if extraScope != nil && !c.additionalScopesInclude(*extraScope) {
 					// Using ':' as a separator here is unambiguous because getBearerToken below
 					// uses the same separator when formatting a remote request (and because
 					// repository names that we create can't contain colons, and extraScope values
@@ -762,11 +899,18 @@ func (c *dockerClient) setupRequestAuth(req *http.Request, extraScope *authScope
 						t   *bearerToken
 						err error
 					)
-					if c.auth.IdentityToken != "" {
+					if c.sys != nil && c.sys.DockerBearerTokenProvider != nil {
+						t, err = c.getBearerTokenFromProvider(req.Context(), challenge, scopes)
+					} else if c.auth.IdentityToken != "" {
 						t, err = c.getBearerTokenOAuth2(req.Context(), challenge, scopes)
 					} else {
 						t, err = c.getBearerToken(req.Context(), challenge, scopes)
 					}
+					authResult := metrics.ResultSuccess
+					if err != nil {
+						authResult = metrics.ResultError
+					}
+					c.metrics.AddCounter(metrics.RegistryAuthRequestsTotal, 1, map[string]string{metrics.LabelResult: authResult})
 					if err != nil {
 						return err
 					}
@@ -779,13 +923,78 @@ func (c *dockerClient) setupRequestAuth(req *http.Request, extraScope *authScope
 			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", registryToken))
 			return nil
 		default:
-			logrus.Debugf("no handler for %s authentication", challenge.Scheme)
+			log.Debugf("no handler for %s authentication", challenge.Scheme)
 		}
 	}
-	logrus.Infof("None of the challenges sent by server (%s) are supported, trying an unauthenticated request anyway", strings.Join(schemeNames, ", "))
+	log.Infof("None of the challenges sent by server (%s) are supported, trying an unauthenticated request anyway", strings.Join(schemeNames, ", "))
 	return nil
 }
 
+// additionalScopesInclude returns true if c.additionalScopes allow all actions of scope,
+// so that scope does not need to be requested separately.
+func (c *dockerClient) additionalScopesInclude(scope authScope) bool {
+	for _, s := range c.additionalScopes {
+		if s.resourceType != scope.resourceType || s.remoteName != scope.remoteName {
+			continue
+		}
+		allowed := strings.Split(s.actions, ",")
+		if !slices.ContainsFunc(strings.Split(scope.actions, ","), func(action string) bool {
+			return !slices.Contains(allowed, action)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// challengeService returns the service parameter to use in bearer token requests for challenge, or "" if none.
+func (c *dockerClient) challengeService(challenge challenge) string {
+	if c.tokenService != "" {
+		return c.tokenService
+	}
+	return challenge.Parameters["service"]
+}
+
+// getBearerTokenFromProvider obtains a token for scopes from c.sys.DockerBearerTokenProvider.
+func (c *dockerClient) getBearerTokenFromProvider(ctx context.Context, challenge challenge,
+	scopes []authScope) (*bearerToken, error) {
+	request := types.DockerTokenRequest{
+		Registry: c.registry,
+		Realm:    challenge.Parameters["realm"],
+		Service:  c.challengeService(challenge),
+		Scopes:   []types.DockerTokenScope{},
+	}
+	for _, scope := range scopes {
+		if scope.resourceType != "" && scope.remoteName != "" && scope.actions != "" {
+			request.Scopes = append(request.Scopes, types.DockerTokenScope{
+				ResourceType: scope.resourceType,
+				Name:         scope.remoteName,
+				Actions:      scope.actions,
+			})
+		}
+	}
+	log.DebugfContext(ctx, "Requesting bearer token for %s from the token provider", c.registry)
+	res, err := c.sys.DockerBearerTokenProvider(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("obtaining bearer token for %s: %w", c.registry, err)
+	}
+	if res.Token == "" {
+		return nil, fmt.Errorf("obtaining bearer token for %s: token provider returned an empty token", c.registry)
+	}
+	now := time.Now().UTC()
+	expirationTime := res.ExpiresAt
+	if minimum := now.Add(minimumTokenLifetimeSeconds * time.Second); expirationTime.Before(minimum) {
+		log.Debugf("Increasing token expiration to: %d seconds", minimumTokenLifetimeSeconds)
+		expirationTime = minimum
+	}
+	return &bearerToken{
+		Token:          res.Token,
+		IssuedAt:       now,
+		ExpiresIn:      int(expirationTime.Sub(now) / time.Second),
+		expirationTime: expirationTime,
+	}, nil
+}
+
 func (c *dockerClient) getBearerTokenOAuth2(ctx context.Context, challenge challenge,
 	scopes []authScope) (*bearerToken, error) {
 	realm, ok := challenge.Parameters["realm"]
@@ -801,7 +1010,7 @@ func (c *dockerClient) getBearerTokenOAuth2(ctx context.Context, challenge chall
 	// Make the form data required against the oauth2 authentication
 	// More details here: https://docs.docker.com/registry/spec/auth/oauth/
 	params := authReq.URL.Query()
-	if service, ok := challenge.Parameters["service"]; ok && service != "" {
+	if service := c.challengeService(challenge); service != "" {
 		params.Add("service", service)
 	}
 
@@ -817,7 +1026,7 @@ func (c *dockerClient) getBearerTokenOAuth2(ctx context.Context, challenge chall
 	authReq.Body = io.NopCloser(strings.NewReader(params.Encode()))
 	authReq.Header.Add("User-Agent", c.userAgent)
 	authReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	logrus.Debugf("%s %s", authReq.Method, authReq.URL.Redacted())
+	log.DebugfContext(ctx, "%s %s", authReq.Method, authReq.URL.Redacted())
 	res, err := c.client.Do(authReq)
 	if err != nil {
 		return nil, err
@@ -852,7 +1061,7 @@ func (c *dockerClient) getBearerToken(ctx context.Context, challenge challenge,
 		params.Add("account", c.auth.Username)
 	}
 
-	if service, ok := challenge.Parameters["service"]; ok && service != "" {
+	if service := c.challengeService(challenge); service != "" {
 		params.Add("service", service)
 	}
 
@@ -869,7 +1078,7 @@ func (c *dockerClient) getBearerToken(ctx context.Context, challenge challenge,
 	}
 	authReq.Header.Add("User-Agent", c.userAgent)
 
-	logrus.Debugf("%s %s", authReq.Method, authReq.URL.Redacted())
+	log.DebugfContext(ctx, "%s %s", authReq.Method, authReq.URL.Redacted())
 	res, err := c.client.Do(authReq)
 	if err != nil {
 		return nil, err
@@ -894,9 +1103,11 @@ func (c *dockerClient) detectPropertiesHelper(ctx context.Context) error {
 	if c.sys != nil && c.sys.DockerInsecureSkipTLSVerify != types.OptionalBoolUndefined {
 		c.tlsClientConfig.InsecureSkipVerify = c.sys.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue
 	}
-	tr := tlsclientconfig.NewTransport()
-	tr.TLSClientConfig = c.tlsClientConfig
-	c.client = &http.Client{Transport: tr}
+	tr, err := tlsclientconfig.NewReloadingTransport(c.certDir, c.tlsClientConfig)
+	if err != nil {
+		return err
+	}
+	c.client = &http.Client{Transport: newRegistryAccessTransport(c.sys, tr)}
 
 	ping := func(scheme string) error {
 		pingURL, err := url.Parse(fmt.Sprintf(resolvedPingV2URL, scheme, c.registry))
@@ -905,11 +1116,11 @@ func (c *dockerClient) detectPropertiesHelper(ctx context.Context) error {
 		}
 		resp, err := c.makeRequestToResolvedURL(ctx, http.MethodGet, pingURL, nil, nil, -1, noAuth, nil)
 		if err != nil {
-			logrus.Debugf("Ping %s err %s (%#v)", pingURL.Redacted(), err.Error(), err)
+			log.DebugfContext(ctx, "Ping %s err %s (%#v)", pingURL.Redacted(), err.Error(), err)
 			return err
 		}
 		defer resp.Body.Close()
-		logrus.Debugf("Ping %s status %d", pingURL.Redacted(), resp.StatusCode)
+		log.DebugfContext(ctx, "Ping %s status %d", pingURL.Redacted(), resp.StatusCode)
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
 			return registryHTTPResponseToError(resp)
 		}
@@ -918,7 +1129,7 @@ func (c *dockerClient) detectPropertiesHelper(ctx context.Context) error {
 		c.supportsSignatures = resp.Header.Get("X-Registry-Supports-Signatures") == "1"
 		return nil
 	}
-	err := ping("https")
+	err = ping("https")
 	if err != nil && c.tlsClientConfig.InsecureSkipVerify {
 		err = ping("http")
 	}
@@ -935,11 +1146,11 @@ func (c *dockerClient) detectPropertiesHelper(ctx context.Context) error {
 			}
 			resp, err := c.makeRequestToResolvedURL(ctx, http.MethodGet, pingURL, nil, nil, -1, noAuth, nil)
 			if err != nil {
-				logrus.Debugf("Ping %s err %s (%#v)", pingURL.Redacted(), err.Error(), err)
+				log.DebugfContext(ctx, "Ping %s err %s (%#v)", pingURL.Redacted(), err.Error(), err)
 				return false
 			}
 			defer resp.Body.Close()
-			logrus.Debugf("Ping %s status %d", pingURL.Redacted(), resp.StatusCode)
+			log.DebugfContext(ctx, "Ping %s status %d", pingURL.Redacted(), resp.StatusCode)
 			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusUnauthorized {
 				return false
 			}
@@ -963,18 +1174,32 @@ func (c *dockerClient) detectProperties(ctx context.Context) error {
 	return c.detectPropertiesError
 }
 
+// manifestAcceptHeader returns the value of the Accept header to use when reading manifests.
+func (c *dockerClient) manifestAcceptHeader() []string {
+	if c.sys == nil || len(c.sys.DockerAdditionalManifestMIMETypes) == 0 {
+		return manifest.DefaultRequestedManifestMIMETypes
+	}
+	res := slices.Clone(manifest.DefaultRequestedManifestMIMETypes)
+	for _, mimeType := range c.sys.DockerAdditionalManifestMIMETypes {
+		if !slices.Contains(res, mimeType) {
+			res = append(res, mimeType)
+		}
+	}
+	return res
+}
+
 // fetchManifest fetches a manifest for (the repo of ref) + tagOrDigest.
 // The caller is responsible for ensuring tagOrDigest uses the expected format.
 func (c *dockerClient) fetchManifest(ctx context.Context, ref dockerReference, tagOrDigest string) ([]byte, string, error) {
 	path := fmt.Sprintf(manifestPath, reference.Path(ref.ref), tagOrDigest)
 	headers := map[string][]string{
-		"Accept": manifest.DefaultRequestedManifestMIMETypes,
+		"Accept": c.manifestAcceptHeader(),
 	}
 	res, err := c.makeRequest(ctx, http.MethodGet, path, headers, nil, v2Auth, nil)
 	if err != nil {
 		return nil, "", err
 	}
-	logrus.Debugf("Content-Type from manifest GET is %q", res.Header.Get("Content-Type"))
+	log.DebugfContext(ctx, "Content-Type from manifest GET is %q", res.Header.Get("Content-Type"))
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
 		return nil, "", fmt.Errorf("reading manifest %s in %s: %w", tagOrDigest, ref.ref.Name(), registryHTTPResponseToError(res))
@@ -987,19 +1212,49 @@ func (c *dockerClient) fetchManifest(ctx context.Context, ref dockerReference, t
 	return manblob, simplifyContentType(res.Header.Get("Content-Type")), nil
 }
 
-// getExternalBlob returns the reader of the first available blob URL from urls, which must not be empty.
-// This function can return nil reader when no url is supported by this function. In this case, the caller
-// should fallback to fetch the non-external blob (i.e. pull from the registry).
-func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string) (io.ReadCloser, int64, error) {
-	if len(urls) == 0 {
+// fetchManifestDigest returns the digest of the manifest for (the repo of) ref + tagOrDigest,
+// using a HEAD request, without downloading the manifest.
+// The caller is responsible for ensuring tagOrDigest uses the expected format.
+func (c *dockerClient) fetchManifestDigest(ctx context.Context, ref dockerReference, tagOrDigest string) (digest.Digest, error) {
+	path := fmt.Sprintf(manifestPath, reference.Path(ref.ref), tagOrDigest)
+	headers := map[string][]string{
+		"Accept": c.manifestAcceptHeader(),
+	}
+	res, err := c.makeRequest(ctx, http.MethodHead, path, headers, nil, v2Auth, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("reading digest %s in %s: %w", tagOrDigest, ref.ref.Name(), registryHTTPResponseToError(res))
+	}
+
+	dig, err := digest.Parse(res.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		return "", err
+	}
+	return dig, nil
+}
+
+// getExternalBlob returns the reader of the first available blob URL from info.URLs, which must not be empty.
+// This function can return nil reader when no url is supported by this function, or permitted by
+// c.sys.DockerForeignLayerURLAllowList. In this case, the caller should fallback to fetch the non-external blob
+// (i.e. pull from the registry).
+// The returned reader fails at EOF if the data does not match info.Digest, which must be valid.
+func (c *dockerClient) getExternalBlob(ctx context.Context, info types.BlobInfo) (io.ReadCloser, int64, error) {
+	if len(info.URLs) == 0 {
 		return nil, 0, errors.New("internal error: getExternalBlob called with no URLs")
 	}
 	var remoteErrors []error
-	for _, u := range urls {
+	for _, u := range info.URLs {
 		blobURL, err := url.Parse(u)
 		if err != nil || (blobURL.Scheme != "http" && blobURL.Scheme != "https") {
 			continue // unsupported url. skip this url.
 		}
+		if !c.externalBlobURLAllowed(blobURL) {
+			log.DebugfContext(ctx, "Skipping external blob URL %q, not permitted by the foreign layer URL allow list", u)
+			continue
+		}
 		// NOTE: we must not authenticate on additional URLs as those
 		//       can be abused to leak credentials or tokens.  Please
 		//       refer to CVE-2020-15157 for more information.
@@ -1011,11 +1266,18 @@ func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string) (io.R
 		if resp.StatusCode != http.StatusOK {
 			err := fmt.Errorf("error fetching external blob from %q: %d (%s)", u, resp.StatusCode, http.StatusText(resp.StatusCode))
 			remoteErrors = append(remoteErrors, err)
-			logrus.Debug(err)
+			log.DebugfContext(ctx, "%v", err)
 			resp.Body.Close()
 			continue
 		}
-		return resp.Body, getBlobSize(resp), nil
+		verifier := info.Digest.Verifier()
+		return &verifyingReadCloser{
+			source:   resp.Body,
+			tee:      io.TeeReader(resp.Body, verifier),
+			verifier: verifier,
+			digest:   info.Digest,
+			origin:   fmt.Sprintf("external URL %q", u),
+		}, getBlobSize(resp), nil
 	}
 	if remoteErrors == nil {
 		return nil, 0, nil // fallback to non-external blob
@@ -1023,6 +1285,20 @@ func (c *dockerClient) getExternalBlob(ctx context.Context, urls []string) (io.R
 	return nil, 0, fmt.Errorf("failed fetching external blob from all urls: %w", multierr.Format("", ", ", "", remoteErrors))
 }
 
+// externalBlobURLAllowed returns true if blobURL may be used to fetch a foreign layer,
+// as documented for types.SystemContext.DockerForeignLayerURLAllowList.
+func (c *dockerClient) externalBlobURLAllowed(blobURL *url.URL) bool {
+	if c.sys == nil || len(c.sys.DockerForeignLayerURLAllowList) == 0 {
+		return true
+	}
+	for _, pattern := range c.sys.DockerForeignLayerURLAllowList {
+		if registryHostMatches(pattern, blobURL.Host) {
+			return true
+		}
+	}
+	return false
+}
+
 func getBlobSize(resp *http.Response) int64 {
 	size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
 	if err != nil {
@@ -1035,8 +1311,11 @@ func getBlobSize(resp *http.Response) int64 {
 // The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
 // May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
 func (c *dockerClient) getBlob(ctx context.Context, ref dockerReference, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	if err := info.Digest.Validate(); err != nil { // Make sure info.Digest.String() does not contain any unexpected characters
+		return nil, 0, err
+	}
 	if len(info.URLs) != 0 {
-		r, s, err := c.getExternalBlob(ctx, info.URLs)
+		r, s, err := c.getExternalBlob(ctx, info)
 		if err != nil {
 			return nil, 0, err
 		} else if r != nil {
@@ -1044,11 +1323,13 @@ func (c *dockerClient) getBlob(ctx context.Context, ref dockerReference, info ty
 		}
 	}
 
-	if err := info.Digest.Validate(); err != nil { // Make sure info.Digest.String() does not contain any unexpected characters
-		return nil, 0, err
+	if c.peerAgent != nil {
+		if r, s := c.peerAgent.getBlob(ctx, ref, info); r != nil {
+			return r, s, nil
+		}
 	}
 	path := fmt.Sprintf(blobsPath, reference.Path(ref.ref), info.Digest.String())
-	logrus.Debugf("Downloading %s", path)
+	log.DebugfContext(ctx, "Downloading %s", path)
 	res, err := c.makeRequest(ctx, http.MethodGet, path, nil, nil, v2Auth, nil)
 	if err != nil {
 		return nil, 0, err
@@ -1123,17 +1404,17 @@ func (c *dockerClient) getSigstoreAttachmentManifest(ctx context.Context, ref do
 	if err != nil {
 		return nil, err
 	}
-	logrus.Debugf("Looking for sigstore attachments in %s", sigstoreRef.String())
+	log.DebugfContext(ctx, "Looking for sigstore attachments in %s", sigstoreRef.String())
 	manifestBlob, mimeType, err := c.fetchManifest(ctx, ref, tag)
 	if err != nil {
 		// FIXME: Are we going to need better heuristics??
 		// This alone is probably a good enough reason for sigstore to be opt-in only,
 		// otherwise we would just break ordinary copies.
 		if isManifestUnknownError(err) {
-			logrus.Debugf("Fetching sigstore attachment manifest failed, assuming it does not exist: %v", err)
+			log.DebugfContext(ctx, "Fetching sigstore attachment manifest failed, assuming it does not exist: %v", err)
 			return nil, nil
 		}
-		logrus.Debugf("Fetching sigstore attachment manifest failed: %v", err)
+		log.DebugfContext(ctx, "Fetching sigstore attachment manifest failed: %v", err)
 		return nil, err
 	}
 	if mimeType != imgspecv1.MediaTypeImageManifest {
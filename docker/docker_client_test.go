@@ -4,18 +4,36 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/containers/image/v5/internal/useragent"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/pkg/faultinject"
+	"github.com/containers/image/v5/pkg/metrics"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
 func TestDockerCertDir(t *testing.T) {
@@ -415,3 +433,514 @@ func TestIsManifestUnknownError(t *testing.T) {
 		assert.True(t, res, "%s: %#v", c.name, err)
 	}
 }
+
+func TestMakeRequestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	origProvider, origPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(origProvider)
+		otel.SetTextMapPropagator(origPropagator)
+	}()
+
+	var traceparent string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+	client, err := newDockerClient(&types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}, registry, registry)
+	require.NoError(t, err)
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	for _, c := range []struct {
+		path           string
+		expectedStatus int
+		expectedCode   codes.Code
+	}{
+		{"/v2/", http.StatusOK, codes.Unset},
+		{"/missing", http.StatusNotFound, codes.Unset}, // 4xx responses are routine and not recorded as errors
+		{"/fail", http.StatusServiceUnavailable, codes.Error},
+	} {
+		previouslyEnded := len(recorder.Ended())
+		res, err := client.makeRequestToResolvedURLOnce(ctx, http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: c.path}, nil, nil, -1, noAuth, nil)
+		require.NoError(t, err, c.path)
+		res.Body.Close()
+
+		spans := recorder.Ended()[previouslyEnded:]
+		require.Len(t, spans, 1, c.path)
+		span := spans[0]
+		assert.Equal(t, "HTTP GET", span.Name(), c.path)
+		assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID(), c.path)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), c.path)
+		assert.Contains(t, span.Attributes(), semconv.HTTPResponseStatusCode(c.expectedStatus), c.path)
+		assert.Equal(t, c.expectedCode, span.Status().Code, c.path)
+		// The request span is propagated to the server.
+		assert.Contains(t, traceparent, span.SpanContext().SpanID().String(), c.path)
+	}
+	parent.End()
+}
+
+// fakeMetricsRecorder is a metrics.Recorder which accumulates all values.
+type fakeMetricsRecorder struct {
+	mutex      sync.Mutex
+	counters   map[string]float64 // Indexed by name and labels
+	histograms map[string]int     // The number of observations, indexed by name and labels
+}
+
+func metricKey(name string, labels map[string]string) string {
+	return fmt.Sprintf("%s%v", name, labels)
+}
+
+func (r *fakeMetricsRecorder) AddCounter(name string, value float64, labels map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.counters[metricKey(name, labels)] += value
+}
+
+func (r *fakeMetricsRecorder) ObserveHistogram(name string, value float64, labels map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.histograms[metricKey(name, labels)]++
+}
+
+func TestMakeRequestMetrics(t *testing.T) {
+	var serverURL string
+	busyResponses := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			_, err := w.Write([]byte(`{"token":"the-token"}`))
+			assert.NoError(t, err)
+		case r.Header.Get("Authorization") != "Bearer the-token":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, serverURL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/busy" && busyResponses == 0:
+			busyResponses++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer s.Close()
+	serverURL = s.URL
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	recorder := &fakeMetricsRecorder{counters: map[string]float64{}, histograms: map[string]int{}}
+	client, err := newDockerClient(&types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		MetricsRecorder:             recorder,
+	}, registry, registry)
+	require.NoError(t, err)
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+
+	res, err := client.makeRequestToResolvedURL(context.Background(), http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: "/busy"}, nil, nil, -1, v2Auth, nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	for _, c := range []struct {
+		name   string
+		labels map[string]string
+		value  float64
+	}{
+		{metrics.RegistryRequestsTotal, map[string]string{metrics.LabelMethod: http.MethodGet, metrics.LabelStatusCode: "401"}, 1}, // detectProperties
+		{metrics.RegistryRequestsTotal, map[string]string{metrics.LabelMethod: http.MethodGet, metrics.LabelStatusCode: "429"}, 1},
+		{metrics.RegistryRequestsTotal, map[string]string{metrics.LabelMethod: http.MethodGet, metrics.LabelStatusCode: "200"}, 1},
+		{metrics.RegistryRetriesTotal, map[string]string{metrics.LabelReason: metrics.ReasonTooManyRequests}, 1},
+		{metrics.RegistryAuthRequestsTotal, map[string]string{metrics.LabelResult: metrics.ResultSuccess}, 1},
+	} {
+		key := metricKey(c.name, c.labels)
+		assert.Equal(t, c.value, recorder.counters[key], key)
+	}
+	assert.NotZero(t, recorder.histograms[metricKey(metrics.RegistryRequestDurationSeconds, map[string]string{metrics.LabelMethod: http.MethodGet})])
+}
+
+func TestDockerBearerTokenProvider(t *testing.T) {
+	var serverURL string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			assert.Fail(t, "unexpected token service request")
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Header.Get("Authorization") != "Bearer provided-token":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, serverURL))
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer s.Close()
+	serverURL = s.URL
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	var requests []types.DockerTokenRequest
+	provider := func(ctx context.Context, request types.DockerTokenRequest) (types.DockerToken, error) {
+		requests = append(requests, request)
+		if request.Scopes[0].Name == "failing" {
+			return types.DockerToken{}, errors.New("provider failure")
+		}
+		return types.DockerToken{Token: "provided-token", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	}
+	client, err := newDockerClient(&types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerBearerTokenProvider:   provider,
+	}, registry, registry)
+	require.NoError(t, err)
+	client.scope = authScope{resourceType: "repository", remoteName: "ns/repo", actions: "pull"}
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+
+	// The token is obtained from the provider once, and cached.
+	for i := 0; i < 2; i++ {
+		res, err := client.makeRequestToResolvedURL(context.Background(), http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: "/v2/ns/repo/tags/list"}, nil, nil, -1, v2Auth, nil)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+	assert.Equal(t, []types.DockerTokenRequest{{
+		Registry: registry,
+		Realm:    serverURL + "/token",
+		Service:  "test",
+		Scopes:   []types.DockerTokenScope{{ResourceType: "repository", Name: "ns/repo", Actions: "pull"}},
+	}}, requests)
+
+	// An extra scope is passed to the provider.
+	requests = nil
+	res, err := client.makeRequestToResolvedURL(context.Background(), http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: "/v2/ns/repo/blobs/uploads/"}, nil, nil, -1, v2Auth,
+		&authScope{resourceType: "repository", remoteName: "other/repo", actions: "pull"})
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Len(t, requests, 1)
+	assert.Equal(t, []types.DockerTokenScope{
+		{ResourceType: "repository", Name: "ns/repo", Actions: "pull"},
+		{ResourceType: "repository", Name: "other/repo", Actions: "pull"},
+	}, requests[0].Scopes)
+
+	// Provider failures are reported.
+	client.scope.remoteName = "failing"
+	client.tokenCache.Delete("")
+	_, err = client.makeRequestToResolvedURL(context.Background(), http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: "/v2/failing/tags/list"}, nil, nil, -1, v2Auth, nil)
+	assert.ErrorContains(t, err, "provider failure")
+}
+
+func TestDockerTokenServiceAndAdditionalScopes(t *testing.T) {
+	var serverURL string
+	var tokenRequests []url.Values
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenRequests = append(tokenRequests, r.URL.Query())
+			_, _ = w.Write([]byte(`{"token":"issued-token","expires_in":3600}`))
+		case r.Header.Get("Authorization") != "Bearer issued-token":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, serverURL))
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer s.Close()
+	serverURL = s.URL
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	client, err := newDockerClient(&types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerTokenService:          "custom-audience",
+		DockerTokenAdditionalScopes: []types.DockerTokenScope{
+			{ResourceType: "repository", Name: "other/repo", Actions: "pull"},
+			{ResourceType: "repository", Name: "third/repo", Actions: "pull,push"},
+		},
+	}, registry, registry)
+	require.NoError(t, err)
+	client.scope = authScope{resourceType: "repository", remoteName: "ns/repo", actions: "pull,push"}
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+
+	res, err := client.makeRequestToResolvedURL(context.Background(), http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: "/v2/ns/repo/tags/list"}, nil, nil, -1, v2Auth, nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, tokenRequests, 1)
+	assert.Equal(t, []string{"custom-audience"}, tokenRequests[0]["service"])
+	assert.Equal(t, []string{"repository:ns/repo:pull,push", "repository:other/repo:pull", "repository:third/repo:pull,push"}, tokenRequests[0]["scope"])
+
+	// Extra scopes included in the additional scopes don’t need another token.
+	for _, extraScope := range []authScope{
+		{resourceType: "repository", remoteName: "other/repo", actions: "pull"},
+		{resourceType: "repository", remoteName: "third/repo", actions: "push"},
+	} {
+		res, err := client.makeRequestToResolvedURL(context.Background(), http.MethodHead, &url.URL{Scheme: "http", Host: registry, Path: "/v2/ns/repo/blobs/uploads/"}, nil, nil, -1, v2Auth, &extraScope)
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+	assert.Len(t, tokenRequests, 1)
+
+	// Other extra scopes are requested as usual.
+	res, err = client.makeRequestToResolvedURL(context.Background(), http.MethodHead, &url.URL{Scheme: "http", Host: registry, Path: "/v2/ns/repo/blobs/uploads/"}, nil, nil, -1, v2Auth,
+		&authScope{resourceType: "repository", remoteName: "other/repo", actions: "pull,push"})
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Len(t, tokenRequests, 2)
+	assert.Equal(t, []string{"repository:ns/repo:pull,push", "repository:other/repo:pull", "repository:third/repo:pull,push", "repository:other/repo:pull,push"}, tokenRequests[1]["scope"])
+
+	// Invalid additional scopes are rejected.
+	for _, scope := range []types.DockerTokenScope{
+		{ResourceType: "", Name: "ns/repo", Actions: "pull"},
+		{ResourceType: "repository", Name: "", Actions: "pull"},
+		{ResourceType: "repository", Name: "ns/repo", Actions: ""},
+		{ResourceType: "repository", Name: "host:5000/repo", Actions: "pull"},
+	} {
+		_, err := newDockerClient(&types.SystemContext{DockerTokenAdditionalScopes: []types.DockerTokenScope{scope}}, registry, registry)
+		assert.Error(t, err, "%#v", scope)
+	}
+}
+
+func TestDockerClientFaultInjection(t *testing.T) {
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/resource" {
+			requests++
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	for _, c := range []struct {
+		fault            faultinject.Fault
+		expectedRequests int
+		expectedStatus   int // 0 if an error is expected
+	}{
+		{faultinject.Fault{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}}, 1, http.StatusOK}, // Retried
+		{faultinject.Fault{StatusCode: http.StatusServiceUnavailable}, 0, http.StatusServiceUnavailable},
+		{faultinject.Fault{Timeout: true}, 0, 0},
+	} {
+		requests = 0
+		injected := 0
+		client, err := newDockerClient(&types.SystemContext{
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+			FaultInjector: faultinject.InjectorFunc(func(ctx context.Context, point faultinject.Point, target string) *faultinject.Fault {
+				if point != faultinject.RegistryRequest || !strings.HasSuffix(target, "/resource") || injected > 0 {
+					return nil
+				}
+				injected++
+				return &c.fault
+			}),
+		}, registry, registry)
+		require.NoError(t, err)
+		err = client.detectProperties(context.Background())
+		require.NoError(t, err)
+
+		res, err := client.makeRequest(context.Background(), http.MethodGet, "/resource", nil, nil, noAuth, nil)
+		if c.expectedStatus == 0 {
+			var netErr net.Error
+			require.ErrorAs(t, err, &netErr)
+			assert.True(t, netErr.Timeout())
+		} else {
+			require.NoError(t, err)
+			res.Body.Close()
+			assert.Equal(t, c.expectedStatus, res.StatusCode)
+		}
+		assert.Equal(t, 1, injected)
+		assert.Equal(t, c.expectedRequests, requests)
+	}
+}
+
+func TestDockerRequestMutator(t *testing.T) {
+	var serverURL string
+	var signatures []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			assert.Empty(t, r.Header.Get("X-Signature"))
+			_, err := w.Write([]byte(`{"token":"the-token"}`))
+			assert.NoError(t, err)
+		case r.Header.Get("Authorization") != "Bearer the-token":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, serverURL))
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			signatures = append(signatures, r.Header.Get("X-Signature"))
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer s.Close()
+	serverURL = s.URL
+	registry := strings.TrimPrefix(s.URL, "http://")
+
+	fail := false
+	client, err := newDockerClient(&types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerRequestMutator: func(req *http.Request) error {
+			if fail {
+				return errors.New("signing failed")
+			}
+			// The mutator runs after authentication headers are set up.
+			req.Header.Set("X-Signature", req.Method+" "+req.URL.Path+" "+req.Header.Get("Authorization"))
+			return nil
+		},
+	}, registry, registry)
+	require.NoError(t, err)
+	err = client.detectProperties(context.Background())
+	require.NoError(t, err)
+
+	res, err := client.makeRequestToResolvedURL(context.Background(), http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: "/v2/ns/repo/tags/list"}, nil, nil, -1, v2Auth, nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, []string{"GET /v2/ns/repo/tags/list Bearer the-token"}, signatures)
+
+	fail = true
+	_, err = client.makeRequestToResolvedURL(context.Background(), http.MethodGet, &url.URL{Scheme: "http", Host: registry, Path: "/v2/ns/repo/tags/list"}, nil, nil, -1, v2Auth, nil)
+	assert.ErrorContains(t, err, "signing failed")
+	assert.Len(t, signatures, 1)
+}
+
+func TestManifestAcceptHeader(t *testing.T) {
+	const artifactType = "application/vnd.example.artifact.manifest.v1+json"
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/ns/artifact/manifests/latest" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		// Behave like a registry doing content negotiation.
+		if !slices.Contains(r.Header.Values("Accept"), artifactType) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", artifactType)
+		_, err := w.Write([]byte(`{"artifact":true}`))
+		assert.NoError(t, err)
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+	ref, err := ParseReference("//" + registry + "/ns/artifact:latest")
+	require.NoError(t, err)
+	dockerRef, ok := ref.(dockerReference)
+	require.True(t, ok)
+
+	for _, c := range []struct {
+		additional []string
+		expected   []string
+	}{
+		{nil, manifest.DefaultRequestedManifestMIMETypes},
+		{[]string{artifactType}, append(slices.Clone(manifest.DefaultRequestedManifestMIMETypes), artifactType)},
+		{ // Duplicates are ignored
+			[]string{imgspecv1.MediaTypeImageManifest, artifactType, artifactType},
+			append(slices.Clone(manifest.DefaultRequestedManifestMIMETypes), artifactType),
+		},
+	} {
+		client, err := newDockerClient(&types.SystemContext{
+			DockerInsecureSkipTLSVerify:       types.OptionalBoolTrue,
+			DockerAdditionalManifestMIMETypes: c.additional,
+		}, registry, registry)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, client.manifestAcceptHeader())
+
+		blob, mimeType, err := client.fetchManifest(context.Background(), dockerRef, "latest")
+		if !slices.Contains(c.additional, artifactType) {
+			assert.Error(t, err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, []byte(`{"artifact":true}`), blob)
+		assert.Equal(t, artifactType, mimeType)
+	}
+}
+
+func TestDockerClientGetExternalBlob(t *testing.T) {
+	const blob = "foreign layer contents"
+	blobDigest := digest.FromString(blob)
+
+	var registryRequests, externalRequests int
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/ns/repo/blobs/" + blobDigest.String():
+			registryRequests++
+			_, _ = io.WriteString(w, blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	registryHost := strings.TrimPrefix(registry.URL, "http://")
+
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		externalRequests++
+		assert.Empty(t, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/layer":
+			_, _ = io.WriteString(w, blob)
+		case "/corrupted":
+			_, _ = io.WriteString(w, blob+"!")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer external.Close()
+	externalHost := strings.TrimPrefix(external.URL, "http://")
+
+	ref, err := ParseReference("//" + registryHost + "/ns/repo:tag")
+	require.NoError(t, err)
+	dockerRef, ok := ref.(dockerReference)
+	require.True(t, ok)
+
+	for _, c := range []struct {
+		name                     string
+		urls                     []string
+		allowList                []string
+		expectedExternalRequests int
+		expectedRegistryRequests int
+		expectedGetError         bool
+		expectedReadError        bool
+	}{
+		{"external URL serves blob", []string{external.URL + "/layer"}, nil, 1, 0, false, false},
+		{"first URL fails", []string{external.URL + "/missing", external.URL + "/layer"}, nil, 2, 0, false, false},
+		{"all URLs fail", []string{external.URL + "/missing"}, nil, 1, 0, true, false},
+		{"unsupported URL scheme", []string{"ftp://" + externalHost + "/layer"}, nil, 0, 1, false, false},
+		{"URL allowed", []string{external.URL + "/layer"}, []string{"127.0.0.1"}, 1, 0, false, false},
+		{"URL not allowed", []string{external.URL + "/layer"}, []string{"*.example.com"}, 0, 1, false, false},
+		{"corrupted blob", []string{external.URL + "/corrupted"}, nil, 1, 0, false, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			externalRequests, registryRequests = 0, 0
+			sys := &types.SystemContext{
+				DockerInsecureSkipTLSVerify:    types.OptionalBoolTrue,
+				DockerForeignLayerURLAllowList: c.allowList,
+			}
+			client, err := newDockerClient(sys, registryHost, registryHost)
+			require.NoError(t, err)
+			err = client.detectProperties(context.Background())
+			require.NoError(t, err)
+			rc, _, err := client.getBlob(context.Background(), dockerRef, types.BlobInfo{Digest: blobDigest, Size: -1, URLs: c.urls}, memory.New())
+			if c.expectedGetError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				defer rc.Close()
+				data, err := io.ReadAll(rc)
+				if c.expectedReadError {
+					assert.Error(t, err)
+				} else {
+					require.NoError(t, err)
+					assert.Equal(t, blob, string(data))
+				}
+			}
+			assert.Equal(t, c.expectedExternalRequests, externalRequests)
+			assert.Equal(t, c.expectedRegistryRequests, registryRequests)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// DigestResolver resolves docker: references to manifest digests, using HEAD requests, without downloading the manifests.
+// Mirrors configured in registries.conf are used the same way as when pulling the image.
+//
+// Connections, credentials and registry properties are reused across calls, so resolving many references
+// (e.g. periodically pinning tags to digests) using a single DigestResolver is much cheaper than using GetDigest,
+// or creating an ImageSource, for each one.
+// A DigestResolver is safe for concurrent use by multiple goroutines.
+// The caller must call .Close() on the DigestResolver.
+type DigestResolver struct {
+	sys            *types.SystemContext
+	registryConfig *registryConfiguration
+
+	mutex   sync.Mutex // Protects clients
+	clients map[digestResolverClientKey]*dockerClient
+}
+
+// digestResolverClientKey identifies a dockerClient usable for a specific endpoint.
+type digestResolverClientKey struct {
+	repo                string // The physical repository
+	insecure            bool   // The value of sysregistriesv2.Endpoint.Insecure
+	credentialsStripped bool   // Whether sys.DockerAuthConfig was removed because the endpoint is a mirror on a different registry
+}
+
+// NewDigestResolver returns a DigestResolver using sys.
+func NewDigestResolver(sys *types.SystemContext) (*DigestResolver, error) {
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	return &DigestResolver{
+		sys:            sys,
+		registryConfig: registryConfig,
+		clients:        map[digestResolverClientKey]*dockerClient{},
+	}, nil
+}
+
+// Close releases resources associated with the DigestResolver.
+func (r *DigestResolver) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	errs := []error{}
+	for key, c := range r.clients {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(r.clients, key)
+	}
+	return errors.Join(errs...)
+}
+
+// Resolve returns the digest of the manifest referenced by ref, which must be a docker: reference.
+func (r *DigestResolver) Resolve(ctx context.Context, ref types.ImageReference) (digest.Digest, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return "", errors.New("ref must be a dockerReference")
+	}
+	if dr.isUnknownDigest {
+		return "", fmt.Errorf("docker: reference %q is for unknown digest case; cannot get digest", dr.StringWithinTransport())
+	}
+
+	// Try the endpoints in the same order as newImageSource.
+	pullSources, err := pullSourcesFromReference(r.sys, dr)
+	if err != nil {
+		return "", err
+	}
+	attempts := []pullSourceAttempt{}
+	for _, pullSource := range pullSources {
+		logPullSourceAttempt(ctx, r.sys, pullSource)
+		d, err := r.resolveAttempt(ctx, dr, pullSource)
+		if err == nil {
+			return d, nil
+		}
+		log.DebugfContext(ctx, "Accessing %q failed: %v", pullSource.Reference, err)
+		attempts = append(attempts, pullSourceAttempt{
+			ref: pullSource.Reference,
+			err: err,
+		})
+	}
+	return "", pullSourceAttemptsError(attempts)
+}
+
+// resolveAttempt returns the digest of the manifest referenced by logicalRef, as available at pullSource.
+func (r *DigestResolver) resolveAttempt(ctx context.Context, logicalRef dockerReference, pullSource sysregistriesv2.PullSource) (digest.Digest, error) {
+	physicalRef, err := newReference(pullSource.Reference, false)
+	if err != nil {
+		return "", err
+	}
+	tagOrDigest, err := physicalRef.tagOrDigest()
+	if err != nil {
+		return "", err
+	}
+	client, err := r.client(ctx, logicalRef, physicalRef, pullSource.Endpoint.Insecure)
+	if err != nil {
+		return "", err
+	}
+	return client.fetchManifestDigest(ctx, physicalRef, tagOrDigest)
+}
+
+// client returns a dockerClient for accessing physicalRef on behalf of logicalRef, reusing an existing one if possible.
+func (r *DigestResolver) client(ctx context.Context, logicalRef, physicalRef dockerReference, insecure bool) (*dockerClient, error) {
+	endpointSys := endpointSystemContext(r.sys, logicalRef, physicalRef)
+	key := digestResolverClientKey{
+		repo:                physicalRef.ref.Name(),
+		insecure:            insecure,
+		credentialsStripped: endpointSys != r.sys,
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if c, ok := r.clients[key]; ok {
+		return c, nil
+	}
+	c, err := newDockerClientFromRef(ctx, endpointSys, physicalRef, r.registryConfig, false, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	c.tlsClientConfig.InsecureSkipVerify = insecure
+	r.clients[key] = c
+	return c, nil
+}
@@ -0,0 +1,148 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestResolver(t *testing.T) {
+	// Manifests available at the server, by repository and tag
+	manifests := map[string]map[string]digest.Digest{
+		"/primary/repo":     {"tag1": digest.FromString("primary tag1"), "tag2": digest.FromString("primary tag2")},
+		"/mirror/repo":      {"tag1": digest.FromString("mirror tag1")},
+		"/primary/norepo":   {},
+		"/mirror/otherrepo": {},
+	}
+	var mutex sync.Mutex
+	pings := 0
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.URL.Path == "/v2/" {
+			pings++
+			rw.WriteHeader(http.StatusOK)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		repo, tag, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2"), "/manifests/")
+		if !ok || r.Method != http.MethodHead {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		d, ok := manifests[repo][tag]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Docker-Content-Digest", d.String())
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	registry := registryURL.Host
+
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err = os.WriteFile(registriesConf, []byte(strings.ReplaceAll(
+		`[[registry]]
+prefix = "with-mirror.example.com"
+location = "@REGISTRY@/primary"
+
+[[registry.mirror]]
+location = "@REGISTRY@/mirror"
+
+[[registry]]
+prefix = "no-mirror.example.com"
+location = "@REGISTRY@/primary"
+`, "@REGISTRY@", registry)), 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+
+	resolver, err := NewDigestResolver(sys)
+	require.NoError(t, err)
+	defer resolver.Close()
+	for _, c := range []struct {
+		input, expectedRequest string
+		expected               digest.Digest
+	}{
+		{"no-mirror.example.com/repo:tag1", "HEAD /v2/primary/repo/manifests/tag1", digest.FromString("primary tag1")},
+		{"no-mirror.example.com/repo:tag2", "HEAD /v2/primary/repo/manifests/tag2", digest.FromString("primary tag2")},
+		{"with-mirror.example.com/repo:tag1", "HEAD /v2/mirror/repo/manifests/tag1", digest.FromString("mirror tag1")},
+		{"no-mirror.example.com/repo@" + digest.FromString("primary tag1").String(),
+			"HEAD /v2/primary/repo/manifests/" + digest.FromString("primary tag1").String(), digest.FromString("primary tag1")},
+	} {
+		ref, err := ParseReference("//" + c.input)
+		require.NoError(t, err, c.input)
+		requests = nil
+		// The digest of a digested reference is not used directly, the manifest must exist.
+		if strings.Contains(c.input, "@") {
+			manifests["/primary/repo"][c.expected.String()] = c.expected
+		}
+		res, err := resolver.Resolve(context.Background(), ref)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, res, c.input)
+		assert.Equal(t, []string{c.expectedRequest}, requests, c.input)
+
+		// GetDigest behaves the same way.
+		res, err = GetDigest(context.Background(), sys, ref)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, c.expected, res, c.input)
+	}
+
+	// Connections and registry properties are reused for the same repository
+	pings = 0
+	for _, input := range []string{"no-mirror.example.com/repo:tag1", "no-mirror.example.com/repo:tag2"} {
+		ref, err := ParseReference("//" + input)
+		require.NoError(t, err, input)
+		_, err = resolver.Resolve(context.Background(), ref)
+		require.NoError(t, err, input)
+	}
+	assert.Equal(t, 0, pings)
+
+	// The mirror does not contain the tag, fall back to the primary location
+	ref, err := ParseReference("//with-mirror.example.com/repo:tag2")
+	require.NoError(t, err)
+	requests = nil
+	res, err := resolver.Resolve(context.Background(), ref)
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromString("primary tag2"), res)
+	assert.Equal(t, []string{"HEAD /v2/mirror/repo/manifests/tag2", "HEAD /v2/primary/repo/manifests/tag2"}, requests)
+	// Missing tag, both at the mirror and at the primary location
+	for _, input := range []string{"no-mirror.example.com/norepo:tag1", "with-mirror.example.com/otherrepo:tag1"} {
+		ref, err = ParseReference("//" + input)
+		require.NoError(t, err, input)
+		_, err = resolver.Resolve(context.Background(), ref)
+		assert.Error(t, err, input)
+	}
+
+	// Invalid references
+	named, err := reference.ParseNormalizedNamed("no-mirror.example.com/repo")
+	require.NoError(t, err)
+	unknownDigestRef, err := NewReferenceUnknownDigest(named)
+	require.NoError(t, err)
+	_, err = resolver.Resolve(context.Background(), unknownDigestRef)
+	assert.Error(t, err)
+
+	err = resolver.Close()
+	assert.NoError(t, err)
+}
@@ -11,10 +11,9 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/image"
-	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 // Image is a Docker-specific implementation of types.ImageCloser with a few extra methods
@@ -65,7 +64,7 @@ func GetRepositoryTags(ctx context.Context, sys *types.SystemContext, ref types.
 		return nil, err
 	}
 	path := fmt.Sprintf(tagsPath, reference.Path(dr.ref))
-	client, err := newDockerClientFromRef(sys, dr, registryConfig, false, "pull")
+	client, err := newDockerClientFromRef(ctx, sys, dr, registryConfig, false, "pull")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
@@ -96,7 +95,7 @@ func GetRepositoryTags(ctx context.Context, sys *types.SystemContext, ref types.
 				// https://github.com/opencontainers/distribution-spec/blob/8a871c8234977df058f1a14e299fe0a673853da2/spec.md?plain=1#L160 ,
 				// include digests in the list.
 				if _, err := digest.Parse(tag); err == nil {
-					logrus.Debugf("Ignoring invalid tag %q matching a digest format", tag)
+					log.DebugfContext(ctx, "Ignoring invalid tag %q matching a digest format", tag)
 					continue
 				}
 				return nil, fmt.Errorf("registry returned invalid tag %q: %w", tag, err)
@@ -104,77 +103,53 @@ func GetRepositoryTags(ctx context.Context, sys *types.SystemContext, ref types.
 			tags = append(tags, tag)
 		}
 
-		link := res.Header.Get("Link")
-		if link == "" {
-			break
-		}
-
-		linkURLPart, _, _ := strings.Cut(link, ";")
-		linkURL, err := url.Parse(strings.Trim(linkURLPart, "<>"))
+		path, err = nextPagePath(res)
 		if err != nil {
 			return tags, err
 		}
-
-		// can be relative or absolute, but we only want the path (and I
-		// guess we're in trouble if it forwards to a new place...)
-		path = linkURL.Path
-		if linkURL.RawQuery != "" {
-			path += "?"
-			path += linkURL.RawQuery
+		if path == "" {
+			break
 		}
 	}
 	return tags, nil
 }
 
-// GetDigest returns the image's digest
-// Use this to optimize and avoid use of an ImageSource based on the returned digest;
-// if you are going to use an ImageSource anyway, it’s more efficient to create it first
-// and compute the digest from the value returned by GetManifest.
-// NOTE: Implemented to avoid Docker Hub API limits, and mirror configuration may be
-// ignored (but may be implemented in the future)
-func GetDigest(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (digest.Digest, error) {
-	dr, ok := ref.(dockerReference)
-	if !ok {
-		return "", errors.New("ref must be a dockerReference")
-	}
-	if dr.isUnknownDigest {
-		return "", fmt.Errorf("docker: reference %q is for unknown digest case; cannot get digest", dr.StringWithinTransport())
-	}
-
-	tagOrDigest, err := dr.tagOrDigest()
-	if err != nil {
-		return "", err
-	}
-
-	registryConfig, err := loadRegistryConfiguration(sys)
-	if err != nil {
-		return "", err
+// nextPagePath returns the path of the next page of a paginated response res, based on its Link header,
+// or "" if there is no next page.
+func nextPagePath(res *http.Response) (string, error) {
+	link := res.Header.Get("Link")
+	if link == "" {
+		return "", nil
 	}
-	client, err := newDockerClientFromRef(sys, dr, registryConfig, false, "pull")
-	if err != nil {
-		return "", fmt.Errorf("failed to create client: %w", err)
-	}
-	defer client.Close()
 
-	path := fmt.Sprintf(manifestPath, reference.Path(dr.ref), tagOrDigest)
-	headers := map[string][]string{
-		"Accept": manifest.DefaultRequestedManifestMIMETypes,
-	}
-
-	res, err := client.makeRequest(ctx, http.MethodHead, path, headers, nil, v2Auth, nil)
+	linkURLPart, _, _ := strings.Cut(link, ";")
+	linkURL, err := url.Parse(strings.Trim(linkURLPart, "<>"))
 	if err != nil {
 		return "", err
 	}
 
-	defer res.Body.Close()
-	if res.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("reading digest %s in %s: %w", tagOrDigest, dr.ref.Name(), registryHTTPResponseToError(res))
+	// can be relative or absolute, but we only want the path (and I
+	// guess we're in trouble if it forwards to a new place...)
+	path := linkURL.Path
+	if linkURL.RawQuery != "" {
+		path += "?"
+		path += linkURL.RawQuery
 	}
+	return path, nil
+}
 
-	dig, err := digest.Parse(res.Header.Get("Docker-Content-Digest"))
+// GetDigest returns the image's digest
+// Use this to optimize and avoid use of an ImageSource based on the returned digest;
+// if you are going to use an ImageSource anyway, it’s more efficient to create it first
+// and compute the digest from the value returned by GetManifest.
+// Mirrors configured in registries.conf are used the same way as when pulling the image.
+// To resolve many references, use a DigestResolver instead, which reuses connections and credentials.
+// NOTE: Implemented to avoid Docker Hub API limits.
+func GetDigest(ctx context.Context, sys *types.SystemContext, ref types.ImageReference) (digest.Digest, error) {
+	resolver, err := NewDigestResolver(sys)
 	if err != nil {
 		return "", err
 	}
-
-	return dig, nil
+	defer resolver.Close()
+	return resolver.Resolve(ctx, ref)
 }
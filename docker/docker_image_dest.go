@@ -21,6 +21,7 @@ import (
 	"github.com/containers/image/v5/internal/imagedestination/impl"
 	"github.com/containers/image/v5/internal/imagedestination/stubs"
 	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/putblobdigest"
 	"github.com/containers/image/v5/internal/set"
@@ -35,7 +36,6 @@ import (
 	v2 "github.com/docker/distribution/registry/api/v2"
 	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 type dockerImageDestination struct {
@@ -47,15 +47,18 @@ type dockerImageDestination struct {
 	c   *dockerClient
 	// State
 	manifestDigest digest.Digest // or "" if not yet known.
+	// Manifest MIME types rejected by the registry; recorded in d.c.features only after another type is accepted,
+	// to distinguish rejections of a MIME type from rejections of the manifest contents.
+	rejectedManifestMIMETypes []string
 }
 
 // newImageDestination creates a new ImageDestination for the specified image reference.
-func newImageDestination(sys *types.SystemContext, ref dockerReference) (private.ImageDestination, error) {
+func newImageDestination(ctx context.Context, sys *types.SystemContext, ref dockerReference) (private.ImageDestination, error) {
 	registryConfig, err := loadRegistryConfiguration(sys)
 	if err != nil {
 		return nil, err
 	}
-	c, err := newDockerClientFromRef(sys, ref, registryConfig, true, "pull,push")
+	c, err := newDockerClientFromRef(ctx, sys, ref, registryConfig, true, "pull,push")
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +89,14 @@ func newImageDestination(sys *types.SystemContext, ref dockerReference) (private
 	return dest, nil
 }
 
+// SupportedManifestMIMETypes tells which manifest mime types the destination supports
+// If an empty slice or nil it's returned, then any mime type can be tried to upload
+func (d *dockerImageDestination) SupportedManifestMIMETypes() []string {
+	// Omit types the registry is known to reject, so that copy.Image converts the manifest directly
+	// instead of discovering the rejection by an upload attempt, for every image.
+	return d.c.features.FilterManifestMIMETypes(d.c.registry, d.PropertyMethodsInitialize.SupportedManifestMIMETypes())
+}
+
 // Reference returns the reference used to set up this destination.  Note that this should directly correspond to user's intent,
 // e.g. it should use the public hostname instead of the result of resolving CNAMEs or following redirects.
 func (d *dockerImageDestination) Reference() types.ImageReference {
@@ -139,7 +150,7 @@ func (d *dockerImageDestination) PutBlobWithOptions(ctx context.Context, stream
 	// This functionality is particularly useful when BlobInfoCache has not been populated with compressed digests,
 	// the source blob is uncompressed, and the destination blob is being compressed "on the fly".
 	if inputInfo.Digest == "" && d.c.sys != nil && d.c.sys.DockerRegistryPushPrecomputeDigests {
-		logrus.Debugf("Precomputing digest layer for %s", reference.Path(d.ref.ref))
+		log.DebugfContext(ctx, "Precomputing digest layer for %s", reference.Path(d.ref.ref))
 		streamCopy, cleanup, err := streamdigest.ComputeBlobInfo(d.c.sys, stream, &inputInfo)
 		if err != nil {
 			return private.UploadedBlob{}, err
@@ -162,14 +173,14 @@ func (d *dockerImageDestination) PutBlobWithOptions(ctx context.Context, stream
 
 	// FIXME? Chunked upload, progress reporting, etc.
 	uploadPath := fmt.Sprintf(blobUploadPath, reference.Path(d.ref.ref))
-	logrus.Debugf("Uploading %s", uploadPath)
+	log.DebugfContext(ctx, "Uploading %s", uploadPath)
 	res, err := d.c.makeRequest(ctx, http.MethodPost, uploadPath, nil, nil, v2Auth, nil)
 	if err != nil {
 		return private.UploadedBlob{}, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusAccepted {
-		logrus.Debugf("Error initiating layer upload, response %#v", *res)
+		log.DebugfContext(ctx, "Error initiating layer upload, response %#v", *res)
 		return private.UploadedBlob{}, fmt.Errorf("initiating layer upload to %s in %s: %w", uploadPath, d.c.registry, registryHTTPResponseToError(res))
 	}
 	uploadLocation, err := res.Location()
@@ -188,7 +199,7 @@ func (d *dockerImageDestination) PutBlobWithOptions(ctx context.Context, stream
 		defer uploadReader.Terminate(errors.New("Reading data from an already terminated upload"))
 		res, err = d.c.makeRequestToResolvedURL(ctx, http.MethodPatch, uploadLocation, map[string][]string{"Content-Type": {"application/octet-stream"}}, uploadReader, inputInfo.Size, v2Auth, nil)
 		if err != nil {
-			logrus.Debugf("Error uploading layer chunked %v", err)
+			log.DebugfContext(ctx, "Error uploading layer chunked %v", err)
 			return nil, err
 		}
 		defer res.Body.Close()
@@ -217,11 +228,11 @@ func (d *dockerImageDestination) PutBlobWithOptions(ctx context.Context, stream
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusCreated {
-		logrus.Debugf("Error uploading layer, response %#v", *res)
+		log.DebugfContext(ctx, "Error uploading layer, response %#v", *res)
 		return private.UploadedBlob{}, fmt.Errorf("uploading layer to %s: %w", uploadLocation, registryHTTPResponseToError(res))
 	}
 
-	logrus.Debugf("Upload of layer %s complete", blobDigest)
+	log.DebugfContext(ctx, "Upload of layer %s complete", blobDigest)
 	options.Cache.RecordKnownLocation(d.ref.Transport(), bicTransportScope(d.ref), blobDigest, newBICLocationReference(d.ref))
 	return private.UploadedBlob{Digest: blobDigest, Size: sizeCounter.size}, nil
 }
@@ -234,7 +245,7 @@ func (d *dockerImageDestination) blobExists(ctx context.Context, repo reference.
 		return false, -1, err
 	}
 	checkPath := fmt.Sprintf(blobsPath, reference.Path(repo), digest.String())
-	logrus.Debugf("Checking %s", checkPath)
+	log.DebugfContext(ctx, "Checking %s", checkPath)
 	res, err := d.c.makeRequest(ctx, http.MethodHead, checkPath, nil, nil, v2Auth, extraScope)
 	if err != nil {
 		return false, -1, err
@@ -242,13 +253,13 @@ func (d *dockerImageDestination) blobExists(ctx context.Context, repo reference.
 	defer res.Body.Close()
 	switch res.StatusCode {
 	case http.StatusOK:
-		logrus.Debugf("... already exists")
+		log.DebugfContext(ctx, "... already exists")
 		return true, getBlobSize(res), nil
 	case http.StatusUnauthorized:
-		logrus.Debugf("... not authorized")
+		log.DebugfContext(ctx, "... not authorized")
 		return false, -1, fmt.Errorf("checking whether a blob %s exists in %s: %w", digest, repo.Name(), registryHTTPResponseToError(res))
 	case http.StatusNotFound:
-		logrus.Debugf("... not present")
+		log.DebugfContext(ctx, "... not present")
 		return false, -1, nil
 	default:
 		return false, -1, fmt.Errorf("checking whether a blob %s exists in %s: %w", digest, repo.Name(), registryHTTPResponseToError(res))
@@ -264,7 +275,7 @@ func (d *dockerImageDestination) mountBlob(ctx context.Context, srcRepo referenc
 			"from":  {reference.Path(srcRepo)},
 		}.Encode(),
 	}
-	logrus.Debugf("Trying to mount %s", u.Redacted())
+	log.DebugfContext(ctx, "Trying to mount %s", u.Redacted())
 	res, err := d.c.makeRequest(ctx, http.MethodPost, u.String(), nil, nil, v2Auth, extraScope)
 	if err != nil {
 		return err
@@ -272,7 +283,7 @@ func (d *dockerImageDestination) mountBlob(ctx context.Context, srcRepo referenc
 	defer res.Body.Close()
 	switch res.StatusCode {
 	case http.StatusCreated:
-		logrus.Debugf("... mount OK")
+		log.DebugfContext(ctx, "... mount OK")
 		return nil
 	case http.StatusAccepted:
 		// Oops, the mount was ignored - either the registry does not support that yet, or the blob does not exist; the registry has started an ordinary upload process.
@@ -282,20 +293,20 @@ func (d *dockerImageDestination) mountBlob(ctx context.Context, srcRepo referenc
 		if err != nil {
 			return fmt.Errorf("determining upload URL after a mount attempt: %w", err)
 		}
-		logrus.Debugf("... started an upload instead of mounting, trying to cancel at %s", uploadLocation.Redacted())
+		log.DebugfContext(ctx, "... started an upload instead of mounting, trying to cancel at %s", uploadLocation.Redacted())
 		res2, err := d.c.makeRequestToResolvedURL(ctx, http.MethodDelete, uploadLocation, nil, nil, -1, v2Auth, extraScope)
 		if err != nil {
-			logrus.Debugf("Error trying to cancel an inadvertent upload: %s", err)
+			log.DebugfContext(ctx, "Error trying to cancel an inadvertent upload: %s", err)
 		} else {
 			defer res2.Body.Close()
 			if res2.StatusCode != http.StatusNoContent {
-				logrus.Debugf("Error trying to cancel an inadvertent upload, status %s", http.StatusText(res.StatusCode))
+				log.DebugfContext(ctx, "Error trying to cancel an inadvertent upload, status %s", http.StatusText(res.StatusCode))
 			}
 		}
 		// Anyway, if canceling the upload fails, ignore it and return the more important error:
 		return fmt.Errorf("Mounting %s from %s to %s started an upload instead", srcDigest, srcRepo.Name(), d.ref.ref.Name())
 	default:
-		logrus.Debugf("Error mounting, response %#v", *res)
+		log.DebugfContext(ctx, "Error mounting, response %#v", *res)
 		return fmt.Errorf("mounting %s from %s to %s: %w", srcDigest, srcRepo.Name(), d.ref.ref.Name(), registryHTTPResponseToError(res))
 	}
 }
@@ -315,6 +326,50 @@ func (d *dockerImageDestination) tryReusingExactBlob(ctx context.Context, info t
 	return false, private.ReusedBlob{}, nil
 }
 
+// HasBlob returns true if a blob with info.Digest would not need to be uploaded, because it already exists at the destination
+// or, per cache, it can be reused from another location within the destination.
+// info.Digest must not be empty.
+// Unlike TryReusingBlobWithOptions, HasBlob never modifies the destination; it may update cache.
+func (d *dockerImageDestination) HasBlob(ctx context.Context, info types.BlobInfo, cache blobinfocache.BlobInfoCache2) (bool, error) {
+	if info.Digest == "" {
+		return false, errors.New("Can not check for a blob with unknown digest")
+	}
+	exists, _, err := d.tryReusingExactBlob(ctx, info, cache)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return true, nil
+	}
+	// TryReusingBlobWithOptions would try to mount the blob from another repository on the same registry.
+	// Don’t check that the blob still exists there, this is only an estimate.
+	for _, candidate := range cache.CandidateLocations2(d.ref.Transport(), bicTransportScope(d.ref), info.Digest, blobinfocache.CandidateLocations2Options{}) {
+		if !candidate.UnknownLocation {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// appendExactDigestCandidates returns candidates, extended by the locations in exactCandidates not already included.
+// exactCandidates must only contain the digest being reused; unlike candidates, they don’t need to have a known compression
+// (e.g. locations recorded for copy.Options.BaseImages, from manifests we can’t trust to describe compression correctly),
+// because reusing exactly the same digest never changes the compression of the blob.
+func appendExactDigestCandidates(candidates []blobinfocache.BICReplacementCandidate2, exactCandidates []types.BICReplacementCandidate) []blobinfocache.BICReplacementCandidate2 {
+	for _, exact := range exactCandidates {
+		if !slices.ContainsFunc(candidates, func(c blobinfocache.BICReplacementCandidate2) bool {
+			return !c.UnknownLocation && c.Digest == exact.Digest && c.Location == exact.Location
+		}) {
+			candidates = append(candidates, blobinfocache.BICReplacementCandidate2{
+				Digest:               exact.Digest,
+				CompressionOperation: types.PreserveOriginal,
+				Location:             exact.Location,
+			})
+		}
+	}
+	return candidates
+}
+
 func optionalCompressionName(algo *compressiontypes.Algorithm) string {
 	if algo != nil {
 		return algo.Name()
@@ -342,7 +397,7 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 			return true, reusedInfo, nil
 		}
 	} else {
-		logrus.Debugf("Ignoring exact blob match, compression %s does not match required %s or MIME types %#v",
+		log.DebugfContext(ctx, "Ignoring exact blob match, compression %s does not match required %s or MIME types %#v",
 			optionalCompressionName(options.OriginalCompression), optionalCompressionName(options.RequiredCompression), options.PossibleManifestFormats)
 	}
 
@@ -352,21 +407,24 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 		PossibleManifestFormats: options.PossibleManifestFormats,
 		RequiredCompression:     options.RequiredCompression,
 	})
+	if impl.OriginalCandidateMatchesTryReusingBlobOptions(options) {
+		candidates = appendExactDigestCandidates(candidates, options.Cache.CandidateLocations(d.ref.Transport(), bicTransportScope(d.ref), info.Digest, false))
+	}
 	for _, candidate := range candidates {
 		var candidateRepo reference.Named
 		if !candidate.UnknownLocation {
 			var err error
 			candidateRepo, err = parseBICLocationReference(candidate.Location)
 			if err != nil {
-				logrus.Debugf("Error parsing BlobInfoCache location reference: %s", err)
+				log.DebugfContext(ctx, "Error parsing BlobInfoCache location reference: %s", err)
 				continue
 			}
 		}
 		if !candidate.UnknownLocation {
 			if candidate.CompressionAlgorithm != nil {
-				logrus.Debugf("Trying to reuse blob with cached digest %s compressed with %s in destination repo %s", candidate.Digest.String(), candidate.CompressionAlgorithm.Name(), candidateRepo.Name())
+				log.DebugfContext(ctx, "Trying to reuse blob with cached digest %s compressed with %s in destination repo %s", candidate.Digest.String(), candidate.CompressionAlgorithm.Name(), candidateRepo.Name())
 			} else {
-				logrus.Debugf("Trying to reuse blob with cached digest %s in destination repo %s", candidate.Digest.String(), candidateRepo.Name())
+				log.DebugfContext(ctx, "Trying to reuse blob with cached digest %s in destination repo %s", candidate.Digest.String(), candidateRepo.Name())
 			}
 			// Sanity checks:
 			if reference.Domain(candidateRepo) != reference.Domain(d.ref.ref) {
@@ -375,14 +433,14 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 				//
 				// OTOH that would mean we can’t do the “blobExists” check, and if there is no match
 				// we could get an upload request that we would have to cancel.
-				logrus.Debugf("... Internal error: domain %s does not match destination %s", reference.Domain(candidateRepo), reference.Domain(d.ref.ref))
+				log.DebugfContext(ctx, "... Internal error: domain %s does not match destination %s", reference.Domain(candidateRepo), reference.Domain(d.ref.ref))
 				continue
 			}
 		} else {
 			if candidate.CompressionAlgorithm != nil {
-				logrus.Debugf("Trying to reuse blob with cached digest %s compressed with %s with no location match, checking current repo", candidate.Digest.String(), candidate.CompressionAlgorithm.Name())
+				log.DebugfContext(ctx, "Trying to reuse blob with cached digest %s compressed with %s with no location match, checking current repo", candidate.Digest.String(), candidate.CompressionAlgorithm.Name())
 			} else {
-				logrus.Debugf("Trying to reuse blob with cached digest %s in destination repo with no location match, checking current repo", candidate.Digest.String())
+				log.DebugfContext(ctx, "Trying to reuse blob with cached digest %s in destination repo with no location match, checking current repo", candidate.Digest.String())
 			}
 			// This digest is a known variant of this blob but we don’t
 			// have a recorded location in this registry, let’s try looking
@@ -390,7 +448,7 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 			candidateRepo = reference.TrimNamed(d.ref.ref)
 		}
 		if candidateRepo.Name() == d.ref.ref.Name() && candidate.Digest == info.Digest {
-			logrus.Debug("... Already tried the primary destination")
+			log.DebugfContext(ctx, "... Already tried the primary destination")
 			continue
 		}
 
@@ -412,16 +470,16 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 		// so, be a nice client and don't create unnecessary upload sessions on the server.
 		exists, size, err := d.blobExists(ctx, candidateRepo, candidate.Digest, extraScope)
 		if err != nil {
-			logrus.Debugf("... Failed: %v", err)
+			log.DebugfContext(ctx, "... Failed: %v", err)
 			continue
 		}
 		if !exists {
 			// FIXME? Should we drop the blob from cache here (and elsewhere?)?
-			continue // logrus.Debug() already happened in blobExists
+			continue // log.DebugfContext() already happened in blobExists
 		}
 		if candidateRepo.Name() != d.ref.ref.Name() {
 			if err := d.mountBlob(ctx, candidateRepo, candidate.Digest, extraScope); err != nil {
-				logrus.Debugf("... Mount failed: %v", err)
+				log.DebugfContext(ctx, "... Mount failed: %v", err)
 				continue
 			}
 		}
@@ -429,10 +487,12 @@ func (d *dockerImageDestination) TryReusingBlobWithOptions(ctx context.Context,
 		options.Cache.RecordKnownLocation(d.ref.Transport(), bicTransportScope(d.ref), candidate.Digest, newBICLocationReference(d.ref))
 
 		return true, private.ReusedBlob{
-			Digest:               candidate.Digest,
-			Size:                 size,
-			CompressionOperation: candidate.CompressionOperation,
-			CompressionAlgorithm: candidate.CompressionAlgorithm}, nil
+			Digest:                 candidate.Digest,
+			Size:                   size,
+			CompressionOperation:   candidate.CompressionOperation,
+			CompressionAlgorithm:   candidate.CompressionAlgorithm,
+			CompressionAnnotations: candidate.CompressionAnnotations,
+		}, nil
 	}
 
 	return false, private.ReusedBlob{}, nil
@@ -487,32 +547,112 @@ func (d *dockerImageDestination) PutManifest(ctx context.Context, m []byte, inst
 		if err != nil {
 			return err
 		}
+		if _, isDigested := d.ref.ref.(reference.Canonical); !isDigested {
+			preconditions, err := d.checkTagPreconditions(ctx, refTail)
+			if err != nil {
+				return err
+			}
+			_, err = d.uploadManifest(ctx, m, refTail, preconditions)
+			return err
+		}
+	}
+
+	_, err := d.uploadManifest(ctx, m, refTail, nil)
+	return err
+}
+
+// checkTagPreconditions verifies that tag satisfies the preconditions for overwriting it set in d.c.sys, if any,
+// and returns HTTP headers to send with the manifest upload, so that registries which support them can enforce
+// the preconditions atomically.
+func (d *dockerImageDestination) checkTagPreconditions(ctx context.Context, tag string) (map[string][]string, error) {
+	if d.c.sys == nil || (d.c.sys.DockerManifestIfMatch == "" && !d.c.sys.DockerManifestIfNotExists) {
+		return nil, nil
+	}
+	expected := d.c.sys.DockerManifestIfMatch
+	if expected != "" && d.c.sys.DockerManifestIfNotExists {
+		return nil, errors.New("DockerManifestIfMatch and DockerManifestIfNotExists must not be set together")
+	}
+	if expected != "" {
+		if err := expected.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid DockerManifestIfMatch value %q: %w", expected, err)
+		}
+	}
+
+	current, err := d.currentTagDigest(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	if current != expected {
+		return nil, ManifestConflictError{Tag: tag, Expected: expected, Current: current}
+	}
+	if expected == "" {
+		return map[string][]string{"If-None-Match": {"*"}}, nil
 	}
+	// Registries use the quoted manifest digest as an ETag.
+	return map[string][]string{"If-Match": {`"` + expected.String() + `"`}}, nil
+}
 
-	return d.uploadManifest(ctx, m, refTail)
+// currentTagDigest returns the digest of the manifest tag refers to, or "" if the tag does not exist.
+func (d *dockerImageDestination) currentTagDigest(ctx context.Context, tag string) (digest.Digest, error) {
+	path := fmt.Sprintf(manifestPath, reference.Path(d.ref.ref), tag)
+	headers := map[string][]string{
+		"Accept": d.c.manifestAcceptHeader(),
+	}
+	res, err := d.c.makeRequest(ctx, http.MethodHead, path, headers, nil, v2Auth, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		current, err := digest.Parse(res.Header.Get("Docker-Content-Digest"))
+		if err != nil {
+			return "", fmt.Errorf("determining the current digest of tag %s in %s: %w", tag, d.ref.ref.Name(), err)
+		}
+		return current, nil
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("determining the current digest of tag %s in %s: %w", tag, d.ref.ref.Name(), registryHTTPResponseToError(res))
+	}
 }
 
-// uploadManifest writes manifest to tagOrDigest.
-func (d *dockerImageDestination) uploadManifest(ctx context.Context, m []byte, tagOrDigest string) error {
+// uploadManifest writes manifest to tagOrDigest, and returns the headers of the registry’s response.
+// preconditions, if not nil, are HTTP headers returned by checkTagPreconditions.
+func (d *dockerImageDestination) uploadManifest(ctx context.Context, m []byte, tagOrDigest string, preconditions map[string][]string) (http.Header, error) {
 	path := fmt.Sprintf(manifestPath, reference.Path(d.ref.ref), tagOrDigest)
 
-	headers := map[string][]string{}
+	headers := maps.Clone(preconditions)
+	if headers == nil {
+		headers = map[string][]string{}
+	}
 	mimeType := manifest.GuessMIMEType(m)
 	if mimeType != "" {
 		headers["Content-Type"] = []string{mimeType}
 	}
 	res, err := d.c.makeRequest(ctx, http.MethodPut, path, headers, bytes.NewReader(m), v2Auth, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusPreconditionFailed && preconditions != nil {
+		return nil, ManifestConflictError{Tag: tagOrDigest, Expected: d.c.sys.DockerManifestIfMatch}
+	}
 	if !successStatus(res.StatusCode) {
 		rawErr := registryHTTPResponseToError(res)
 		err := fmt.Errorf("uploading manifest %s to %s: %w", tagOrDigest, d.ref.ref.Name(), rawErr)
 		if isManifestInvalidError(rawErr) {
 			err = types.ManifestTypeRejectedError{Err: err}
+			if mimeType != "" && !slices.Contains(d.rejectedManifestMIMETypes, mimeType) {
+				d.rejectedManifestMIMETypes = append(d.rejectedManifestMIMETypes, mimeType)
+			}
 		}
-		return err
+		return nil, err
+	}
+	if len(d.rejectedManifestMIMETypes) > 0 && !slices.Contains(d.rejectedManifestMIMETypes, mimeType) {
+		log.DebugfContext(ctx, "Registry %s rejected manifest types %v but accepted %q, remembering that", d.c.registry, d.rejectedManifestMIMETypes, mimeType)
+		d.c.features.RecordRejectedManifestMIMETypes(d.c.registry, d.rejectedManifestMIMETypes)
+		d.rejectedManifestMIMETypes = nil
 	}
 	// A HTTP server may not be a registry at all, and just return 200 OK to everything
 	// (in particular that can fairly easily happen after tearing down a website and
@@ -524,9 +664,9 @@ func (d *dockerImageDestination) uploadManifest(ctx context.Context, m []byte, t
 	// https://github.com/opencontainers/distribution-spec/blob/ec90a2af85fe4d612cf801e1815b95bfa40ae72b/spec.md#legacy-docker-support-http-headers
 	// So, just note the missing header in a debug log.
 	if v := res.Header.Values("Docker-Content-Digest"); len(v) == 0 {
-		logrus.Debugf("Manifest upload response didn’t contain a Docker-Content-Digest header, it might not be a container registry")
+		log.DebugfContext(ctx, "Manifest upload response didn’t contain a Docker-Content-Digest header, it might not be a container registry")
 	}
-	return nil
+	return res.Header, nil
 }
 
 // successStatus returns true if the argument is a successful HTTP response
@@ -576,21 +716,42 @@ func (d *dockerImageDestination) PutSignaturesWithFormat(ctx context.Context, si
 
 	sigstoreSignatures := []signature.Sigstore{}
 	otherSignatures := []signature.Signature{}
+	sigstoreBundles := 0
 	for _, sig := range signatures {
 		if sigstoreSig, ok := sig.(signature.Sigstore); ok {
+			// Sigstore bundles are discovered as OCI referrers, not as sigstore attachments, and we don’t write referrers;
+			// so, bundles can only be written to the lookaside.
+			if signature.IsSigstoreBundleMIMEType(sigstoreSig.UntrustedMIMEType()) {
+				sigstoreBundles++
+				continue
+			}
 			sigstoreSignatures = append(sigstoreSignatures, sigstoreSig)
 		} else {
 			otherSignatures = append(otherSignatures, sig)
 		}
 	}
 
-	// Only write sigstores signatures to sigstores attachments. We _could_ store them to lookaside
-	// instead, but that would probably be rather surprising.
+	// By default, only write sigstores signatures to sigstores attachments. We _could_ store them to lookaside
+	// instead, but that would probably be rather surprising; so that is only done if explicitly configured,
+	// to support clients which only read signatures from the lookaside.
 	// FIXME: So should we enable sigstores in all cases? Or write in all cases, but opt-in to read?
 
 	if len(sigstoreSignatures) != 0 {
-		if err := d.putSignaturesToSigstoreAttachments(ctx, sigstoreSignatures, *instanceDigest); err != nil {
-			return err
+		// If sigstore signatures are to be written only to the lookaside, don’t fail just because attachments are disabled.
+		if d.c.useSigstoreAttachments || !d.c.writeSigstoreToLookaside {
+			if err := d.putSignaturesToSigstoreAttachments(ctx, sigstoreSignatures, *instanceDigest); err != nil {
+				return err
+			}
+		}
+	}
+	if len(sigstoreSignatures) != 0 || sigstoreBundles != 0 {
+		// If there are other signatures, all of the signatures, including these, are written to the lookaside below.
+		if d.c.writeSigstoreToLookaside && len(otherSignatures) == 0 {
+			if err := d.putSignaturesToLookaside(signatures, *instanceDigest); err != nil {
+				return err
+			}
+		} else if sigstoreBundles != 0 && len(otherSignatures) == 0 {
+			log.WarnfContext(ctx, "Not writing %d Sigstore bundles to %s, writing OCI referrers is not supported", sigstoreBundles, d.ref.ref.Name())
 		}
 	}
 
@@ -663,7 +824,7 @@ func (d *dockerImageDestination) putSignaturesToLookaside(signatures []signature
 func (d *dockerImageDestination) putOneSignature(sigURL *url.URL, sig signature.Signature) error {
 	switch sigURL.Scheme {
 	case "file":
-		logrus.Debugf("Writing to %s", sigURL.Path)
+		log.Debugf("Writing to %s", sigURL.Path)
 		err := os.MkdirAll(filepath.Dir(sigURL.Path), 0755)
 		if err != nil {
 			return err
@@ -703,7 +864,7 @@ func (d *dockerImageDestination) putSignaturesToSigstoreAttachments(ctx context.
 		}, nil)
 		ociConfig.RootFS.Type = "layers"
 	} else {
-		logrus.Debugf("Fetching sigstore attachment config %s", ociManifest.Config.Digest.String())
+		log.DebugfContext(ctx, "Fetching sigstore attachment config %s", ociManifest.Config.Digest.String())
 		// We don’t benefit from a real BlobInfoCache here because we never try to reuse/mount configs.
 		configBlob, err := d.c.getOCIDescriptorContents(ctx, d.ref, ociManifest.Config, iolimits.MaxConfigBodySize,
 			none.NoCache)
@@ -728,7 +889,7 @@ func (d *dockerImageDestination) putSignaturesToSigstoreAttachments(ctx context.
 		alreadyOnRegistry := false
 		for _, layer := range ociManifest.Layers {
 			if layerMatchesSigstoreSignature(layer, mimeType, payloadBlob, annotations) {
-				logrus.Debugf("Signature with digest %s already exists on the registry", layer.Digest.String())
+				log.DebugfContext(ctx, "Signature with digest %s already exists on the registry", layer.Digest.String())
 				alreadyOnRegistry = true
 				break
 			}
@@ -752,14 +913,14 @@ func (d *dockerImageDestination) putSignaturesToSigstoreAttachments(ctx context.
 		sigDesc.Annotations = annotations
 		ociManifest.Layers = append(ociManifest.Layers, sigDesc)
 		ociConfig.RootFS.DiffIDs = append(ociConfig.RootFS.DiffIDs, sigDesc.Digest)
-		logrus.Debugf("Adding new signature, digest %s", sigDesc.Digest.String())
+		log.DebugfContext(ctx, "Adding new signature, digest %s", sigDesc.Digest.String())
 	}
 
 	configBlob, err := json.Marshal(ociConfig)
 	if err != nil {
 		return err
 	}
-	logrus.Debugf("Uploading updated sigstore attachment config")
+	log.DebugfContext(ctx, "Uploading updated sigstore attachment config")
 	// We don’t benefit from a real BlobInfoCache here because we never try to reuse/mount configs.
 	configDesc, err := d.putBlobBytesAsOCI(ctx, configBlob, imgspecv1.MediaTypeImageConfig, private.PutBlobOptions{
 		Cache:      none.NoCache,
@@ -780,8 +941,9 @@ func (d *dockerImageDestination) putSignaturesToSigstoreAttachments(ctx context.
 	if err != nil {
 		return err
 	}
-	logrus.Debugf("Uploading sigstore attachment manifest")
-	return d.uploadManifest(ctx, manifestBlob, attachmentTag)
+	log.DebugfContext(ctx, "Uploading sigstore attachment manifest")
+	_, err = d.uploadManifest(ctx, manifestBlob, attachmentTag, nil)
+	return err
 }
 
 func layerMatchesSigstoreSignature(layer imgspecv1.Descriptor, mimeType string,
@@ -825,7 +987,7 @@ func (d *dockerImageDestination) putBlobBytesAsOCI(ctx context.Context, contents
 func (c *dockerClient) deleteOneSignature(sigURL *url.URL) (missing bool, err error) {
 	switch sigURL.Scheme {
 	case "file":
-		logrus.Debugf("Deleting %s", sigURL.Path)
+		log.Debugf("Deleting %s", sigURL.Path)
 		err := os.Remove(sigURL.Path)
 		if err != nil && os.IsNotExist(err) {
 			return true, nil
@@ -905,7 +1067,7 @@ func (d *dockerImageDestination) putSignaturesToAPIExtension(ctx context.Context
 		}
 		defer res.Body.Close()
 		if res.StatusCode != http.StatusCreated {
-			logrus.Debugf("Error uploading signature, status %d, %#v", res.StatusCode, res)
+			log.DebugfContext(ctx, "Error uploading signature, status %d, %#v", res.StatusCode, res)
 			return fmt.Errorf("uploading signature to %s in %s: %w", path, d.c.registry, registryHTTPResponseToError(res))
 		}
 	}
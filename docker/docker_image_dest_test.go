@@ -3,10 +3,25 @@ package docker
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/pkg/registryfeatures"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,3 +49,335 @@ func TestIsManifestInvalidError(t *testing.T) {
 	res := isManifestInvalidError(err)
 	assert.True(t, res, "%#v", err)
 }
+
+func TestPutSignaturesWithFormatSigstoreToLookaside(t *testing.T) {
+	ctx := context.Background()
+	ref := dockerRefFromString(t, "//example.com/repo:latest")
+	manifestDigest := digest.FromString("manifest")
+	sig := signature.SigstoreFromComponents("application/vnd.dev.cosign.simplesigning.v1+json", []byte("payload"),
+		map[string]string{"dev.cosignproject.cosign/signature": "sig"})
+
+	// Sigstore signatures are written only to attachments by default; with attachments disabled, that fails.
+	d := &dockerImageDestination{
+		ref:            ref,
+		c:              &dockerClient{signatureBase: &url.URL{Scheme: "file", Path: t.TempDir()}},
+		manifestDigest: manifestDigest,
+	}
+	err := d.PutSignaturesWithFormat(ctx, []signature.Signature{sig}, nil)
+	assert.Error(t, err)
+
+	// With writeSigstoreToLookaside, they are written to the lookaside even if attachments are disabled.
+	d.c.writeSigstoreToLookaside = true
+	err = d.PutSignaturesWithFormat(ctx, []signature.Signature{sig}, nil)
+	require.NoError(t, err)
+	sigURL, err := lookasideStorageURL(d.c.signatureBase, manifestDigest, 0)
+	require.NoError(t, err)
+	blob, err := os.ReadFile(sigURL.Path)
+	require.NoError(t, err)
+	written, err := signature.FromBlob(blob)
+	require.NoError(t, err)
+	assert.Equal(t, sig, written)
+}
+
+func TestPutSignaturesWithFormatSigstoreBundles(t *testing.T) {
+	ctx := context.Background()
+	ref := dockerRefFromString(t, "//example.com/repo:latest")
+	manifestDigest := digest.FromString("manifest")
+	bundle := signature.SigstoreFromComponents(signature.SigstoreBundleMIMEType,
+		[]byte(`{"mediaType":"`+signature.SigstoreBundleMIMEType+`"}`), nil)
+
+	// Bundles are never written as attachments; with attachments disabled, that does not fail.
+	d := &dockerImageDestination{
+		ref:            ref,
+		c:              &dockerClient{signatureBase: &url.URL{Scheme: "file", Path: t.TempDir()}},
+		manifestDigest: manifestDigest,
+	}
+	err := d.PutSignaturesWithFormat(ctx, []signature.Signature{bundle}, nil)
+	require.NoError(t, err)
+	sigURL, err := lookasideStorageURL(d.c.signatureBase, manifestDigest, 0)
+	require.NoError(t, err)
+	assert.NoFileExists(t, sigURL.Path)
+
+	// With writeSigstoreToLookaside, they are written to the lookaside.
+	d.c.writeSigstoreToLookaside = true
+	err = d.PutSignaturesWithFormat(ctx, []signature.Signature{bundle}, nil)
+	require.NoError(t, err)
+	blob, err := os.ReadFile(sigURL.Path)
+	require.NoError(t, err)
+	written, err := signature.FromBlob(blob)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, written)
+}
+
+// tagPreconditionsRegistryMock is a minimal registry serving manifests of a single tag, "ns/repo:tag", which enforces If-Match and If-None-Match.
+type tagPreconditionsRegistryMock struct {
+	server          *httptest.Server
+	current         []byte // The manifest the tag refers to, or nil
+	modifyAfterHead []byte // If not nil, the tag is updated to this manifest after a HEAD request, simulating a concurrent update
+	puts            int
+	putHeaders      http.Header
+}
+
+func (m *tagPreconditionsRegistryMock) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodHead && r.URL.Path == "/v2/ns/repo/manifests/tag":
+		if m.current == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(m.current).String())
+		w.WriteHeader(http.StatusOK)
+		if m.modifyAfterHead != nil {
+			m.current = m.modifyAfterHead
+		}
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v2/ns/repo/manifests/"):
+		m.puts++
+		m.putHeaders = r.Header.Clone()
+		etag := ""
+		if m.current != nil {
+			etag = `"` + digest.FromBytes(m.current).String() + `"`
+		}
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && m.current != nil {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/v2/ns/repo/manifests/tag" {
+			m.current = data
+		}
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPutManifestTagPreconditions(t *testing.T) {
+	oldManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	newManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[],"annotations":{"a":"b"}}`)
+	otherManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[],"annotations":{"c":"d"}}`)
+	oldDigest := digest.FromBytes(oldManifest)
+	otherDigest := digest.FromBytes(otherManifest)
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	newSys := func(ifMatch digest.Digest, ifNotExists bool) *types.SystemContext {
+		return &types.SystemContext{
+			RegistriesDirPath:           "/this/does/not/exist",
+			DockerPerHostCertDirPath:    "/this/does/not/exist",
+			SystemRegistriesConfPath:    registriesConf,
+			AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+			DockerManifestIfMatch:       ifMatch,
+			DockerManifestIfNotExists:   ifNotExists,
+		}
+	}
+
+	for _, c := range []struct {
+		name            string
+		current         []byte
+		modifyAfterHead []byte
+		ifMatch         digest.Digest
+		ifNotExists     bool
+		byDigest        bool
+		expectedHeaders map[string]string
+		conflict        *ManifestConflictError // nil if the write should succeed
+	}{
+		{name: "unconditional", current: oldManifest, expectedHeaders: map[string]string{"If-Match": "", "If-None-Match": ""}},
+		{name: "If-Match, matching", current: oldManifest, ifMatch: oldDigest, expectedHeaders: map[string]string{"If-Match": `"` + oldDigest.String() + `"`}},
+		{
+			name: "If-Match, not matching", current: otherManifest, ifMatch: oldDigest,
+			conflict: &ManifestConflictError{Tag: "tag", Expected: oldDigest, Current: otherDigest},
+		},
+		{
+			name: "If-Match, missing", ifMatch: oldDigest,
+			conflict: &ManifestConflictError{Tag: "tag", Expected: oldDigest},
+		},
+		{
+			name: "If-Match, concurrent update", current: oldManifest, modifyAfterHead: otherManifest, ifMatch: oldDigest,
+			conflict: &ManifestConflictError{Tag: "tag", Expected: oldDigest},
+		},
+		{name: "if not exists, missing", ifNotExists: true, expectedHeaders: map[string]string{"If-None-Match": "*"}},
+		{
+			name: "if not exists, existing", current: oldManifest, ifNotExists: true,
+			conflict: &ManifestConflictError{Tag: "tag", Current: oldDigest},
+		},
+		{name: "by digest", current: otherManifest, ifMatch: oldDigest, byDigest: true, expectedHeaders: map[string]string{"If-Match": ""}},
+	} {
+		registry := &tagPreconditionsRegistryMock{current: c.current, modifyAfterHead: c.modifyAfterHead}
+		registry.server = httptest.NewServer(http.HandlerFunc(registry.serveHTTP))
+		defer registry.server.Close()
+		refString := "//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag"
+		if c.byDigest {
+			refString = "//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo@" + digest.FromBytes(newManifest).String()
+		}
+		ref, err := ParseReference(refString)
+		require.NoError(t, err, c.name)
+		dest, err := ref.NewImageDestination(context.Background(), newSys(c.ifMatch, c.ifNotExists))
+		require.NoError(t, err, c.name)
+		defer dest.Close()
+
+		err = dest.PutManifest(context.Background(), newManifest, nil)
+		if c.conflict == nil {
+			require.NoError(t, err, c.name)
+			for name, value := range c.expectedHeaders {
+				assert.Equal(t, value, registry.putHeaders.Get(name), c.name)
+			}
+			if !c.byDigest {
+				assert.Equal(t, newManifest, registry.current, c.name)
+			}
+		} else {
+			var conflict ManifestConflictError
+			require.ErrorAs(t, err, &conflict, c.name)
+			assert.Equal(t, *c.conflict, conflict, c.name)
+			assert.NotEqual(t, newManifest, registry.current, c.name)
+		}
+	}
+
+	// DockerManifestIfMatch and DockerManifestIfNotExists are mutually exclusive
+	registry := &tagPreconditionsRegistryMock{}
+	registry.server = httptest.NewServer(http.HandlerFunc(registry.serveHTTP))
+	defer registry.server.Close()
+	ref, err := ParseReference("//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag")
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), newSys(oldDigest, true))
+	require.NoError(t, err)
+	defer dest.Close()
+	err = dest.PutManifest(context.Background(), newManifest, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 0, registry.puts)
+}
+
+func TestManifestConflictErrorError(t *testing.T) {
+	d1 := digest.FromString("1")
+	d2 := digest.FromString("2")
+	for _, e := range []ManifestConflictError{
+		{Tag: "tag", Expected: d1, Current: d2},
+		{Tag: "tag", Expected: d1},
+		{Tag: "tag", Current: d2},
+	} {
+		msg := e.Error()
+		assert.Contains(t, msg, `"tag"`)
+		if e.Expected != "" {
+			assert.Contains(t, msg, e.Expected.String())
+		}
+		if e.Current != "" {
+			assert.Contains(t, msg, e.Current.String())
+		}
+	}
+}
+
+func TestSupportedManifestMIMETypesRegistryFeatureCache(t *testing.T) {
+	dockerManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[]}`)
+	ociManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:                 "/this/does/not/exist",
+		DockerPerHostCertDirPath:          "/this/does/not/exist",
+		SystemRegistriesConfPath:          registriesConf,
+		AuthFilePath:                      filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify:       types.OptionalBoolTrue,
+		DockerDisableDestSchema1MIMETypes: true,
+		DockerRegistryFeatureCache:        registryfeatures.New(),
+	}
+	allTypes := []string{imgspecv1.MediaTypeImageManifest, manifest.DockerV2Schema2MediaType, imgspecv1.MediaTypeImageIndex, manifest.DockerV2ListMediaType}
+
+	registry := newReferrersRegistryMock(t, true)
+	registry.rejectedManifestMIMETypes = []string{manifest.DockerV2ListMediaType}
+	ref, err := ParseReference("//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag")
+	require.NoError(t, err)
+
+	dest, err := ref.NewImageDestination(context.Background(), sys)
+	require.NoError(t, err)
+	defer dest.Close()
+	assert.Equal(t, allTypes, dest.SupportedManifestMIMETypes())
+	err = dest.PutManifest(context.Background(), dockerManifest, nil)
+	assert.ErrorAs(t, err, &types.ManifestTypeRejectedError{})
+	// A rejection is not recorded until another type is accepted; the manifest contents might have been the problem.
+	assert.Equal(t, allTypes, dest.SupportedManifestMIMETypes())
+	err = dest.PutManifest(context.Background(), ociManifest, nil)
+	require.NoError(t, err)
+	expected := []string{imgspecv1.MediaTypeImageManifest, manifest.DockerV2Schema2MediaType, imgspecv1.MediaTypeImageIndex}
+	assert.Equal(t, expected, dest.SupportedManifestMIMETypes())
+
+	// The recorded rejection is used by other destinations using the same cache.
+	dest2, err := ref.NewImageDestination(context.Background(), sys)
+	require.NoError(t, err)
+	defer dest2.Close()
+	assert.Equal(t, expected, dest2.SupportedManifestMIMETypes())
+}
+
+func TestTryReusingBlobWithOptionsExactDigestLocation(t *testing.T) {
+	blobDigest := digest.FromString("layer")
+	mounted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead && r.URL.Path == "/v2/ns/base/blobs/"+blobDigest.String():
+			w.Header().Set("Content-Length", "5")
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/v2/ns/app/blobs/uploads/" &&
+			r.URL.Query().Get("mount") == blobDigest.String() && r.URL.Query().Get("from") == "ns/base":
+			mounted = true
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+	registry := strings.TrimPrefix(server.URL, "http://")
+	baseRef, err := ParseReference("//" + registry + "/ns/base:latest")
+	require.NoError(t, err)
+	ref, err := ParseReference("//" + registry + "/ns/app:latest")
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		name           string
+		recordLocation bool
+		reused         bool
+	}{
+		{"no location", false, false},
+		// The location is used even if the compression of the blob is not known.
+		{"location with unknown compression", true, true},
+	} {
+		mounted = false
+		cache := blobinfocache.FromBlobInfoCache(memory.New())
+		if c.recordLocation {
+			cache.RecordKnownLocation(Transport, bicTransportScope(baseRef.(dockerReference)), blobDigest, newBICLocationReference(baseRef.(dockerReference)))
+		}
+		dest, err := ref.NewImageDestination(context.Background(), sys)
+		require.NoError(t, err, c.name)
+		defer dest.Close()
+		reused, reusedBlob, err := dest.(private.ImageDestination).TryReusingBlobWithOptions(context.Background(),
+			types.BlobInfo{Digest: blobDigest, Size: -1}, private.TryReusingBlobOptions{Cache: cache})
+		require.NoError(t, err, c.name)
+		assert.Equal(t, c.reused, reused, c.name)
+		assert.Equal(t, c.reused, mounted, c.name)
+		if c.reused {
+			assert.Equal(t, private.ReusedBlob{Digest: blobDigest, Size: 5}, reusedBlob, c.name)
+		}
+	}
+}
@@ -18,24 +18,31 @@ import (
 	"sync"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/bufferpool"
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
 	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/internal/signature"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/registryfeatures"
 	"github.com/containers/image/v5/pkg/sysregistriesv2"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/regexp"
 	digest "github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 // maxLookasideSignatures is an arbitrary limit for the total number of signatures we would try to read from a lookaside server,
 // even if it were broken or malicious and it continued serving an enormous number of items.
 const maxLookasideSignatures = 128
 
+// maxParallelLookasideReads is the maximum number of signatures read from a lookaside server concurrently.
+const maxParallelLookasideReads = 8
+
 type dockerImageSource struct {
 	impl.Compat
 	impl.PropertyMethodsInitialize
@@ -57,61 +64,97 @@ func newImageSource(ctx context.Context, sys *types.SystemContext, ref dockerRef
 	if ref.isUnknownDigest {
 		return nil, fmt.Errorf("reading images from docker: reference %q without a tag or digest is not supported", ref.StringWithinTransport())
 	}
+	if err := checkImageBlocked(sys, ref.ref, ""); err != nil {
+		return nil, err
+	}
 
 	registryConfig, err := loadRegistryConfiguration(sys)
 	if err != nil {
 		return nil, err
 	}
-	registry, err := sysregistriesv2.FindRegistry(sys, ref.ref.Name())
-	if err != nil {
-		return nil, fmt.Errorf("loading registries configuration: %w", err)
-	}
-	if registry == nil {
-		// No configuration was found for the provided reference, so use the
-		// equivalent of a default configuration.
-		registry = &sysregistriesv2.Registry{
-			Endpoint: sysregistriesv2.Endpoint{
-				Location: ref.ref.String(),
-			},
-			Prefix: ref.ref.String(),
-		}
-	}
-
 	// Check all endpoints for the manifest availability. If we find one that does
 	// contain the image, it will be used for all future pull actions.  Always try the
 	// non-mirror original location last; this both transparently handles the case
 	// of no mirrors configured, and ensures we return the error encountered when
 	// accessing the upstream location if all endpoints fail.
-	pullSources, err := registry.PullSourcesFromReference(ref.ref)
+	pullSources, err := pullSourcesFromReference(sys, ref)
 	if err != nil {
 		return nil, err
 	}
-	type attempt struct {
-		ref reference.Named
-		err error
-	}
-	attempts := []attempt{}
+	attempts := []pullSourceAttempt{}
 	for _, pullSource := range pullSources {
-		if sys != nil && sys.DockerLogMirrorChoice {
-			logrus.Infof("Trying to access %q", pullSource.Reference)
-		} else {
-			logrus.Debugf("Trying to access %q", pullSource.Reference)
-		}
+		logPullSourceAttempt(ctx, sys, pullSource)
 		s, err := newImageSourceAttempt(ctx, sys, ref, pullSource, registryConfig)
 		if err == nil {
 			return s, nil
 		}
-		logrus.Debugf("Accessing %q failed: %v", pullSource.Reference, err)
-		attempts = append(attempts, attempt{
+		log.DebugfContext(ctx, "Accessing %q failed: %v", pullSource.Reference, err)
+		attempts = append(attempts, pullSourceAttempt{
 			ref: pullSource.Reference,
 			err: err,
 		})
 	}
+	return nil, pullSourceAttemptsError(attempts)
+}
+
+// checkImageBlocked returns an error if pulling ref is blocked by the registries configuration.
+// If manifestDigest is not "", it is the digest of a manifest pulled for ref, and it is checked as well.
+func checkImageBlocked(sys *types.SystemContext, ref reference.Named, manifestDigest digest.Digest) error {
+	blocked, err := sysregistriesv2.IsImageBlocked(sys, ref, manifestDigest)
+	if err != nil {
+		return fmt.Errorf("loading registries configuration: %w", err)
+	}
+	if blocked {
+		if manifestDigest != "" {
+			return fmt.Errorf("image %s with manifest digest %s is blocked in %s", ref.String(), manifestDigest.String(),
+				sysregistriesv2.ConfigurationSourceDescription(sys))
+		}
+		return fmt.Errorf("image %s is blocked in %s", ref.String(), sysregistriesv2.ConfigurationSourceDescription(sys))
+	}
+	return nil
+}
+
+// pullSourcesFromReference returns the endpoints to try, in order, when reading ref, based on the registries configuration.
+func pullSourcesFromReference(sys *types.SystemContext, ref dockerReference) ([]sysregistriesv2.PullSource, error) {
+	registry, err := sysregistriesv2.FindRegistry(sys, ref.ref.Name())
+	if err != nil {
+		return nil, fmt.Errorf("loading registries configuration: %w", err)
+	}
+	if registry == nil {
+		// No configuration was found for the provided reference, so use the
+		// equivalent of a default configuration.
+		registry = &sysregistriesv2.Registry{
+			Endpoint: sysregistriesv2.Endpoint{
+				Location: ref.ref.String(),
+			},
+			Prefix: ref.ref.String(),
+		}
+	}
+	return registry.PullSourcesFromReference(ref.ref)
+}
+
+// logPullSourceAttempt records that pullSource is about to be accessed.
+func logPullSourceAttempt(ctx context.Context, sys *types.SystemContext, pullSource sysregistriesv2.PullSource) {
+	if sys != nil && sys.DockerLogMirrorChoice {
+		log.InfofContext(ctx, "Trying to access %q", pullSource.Reference)
+	} else {
+		log.DebugfContext(ctx, "Trying to access %q", pullSource.Reference)
+	}
+}
+
+// pullSourceAttempt records a failed attempt to access a pull source.
+type pullSourceAttempt struct {
+	ref reference.Named
+	err error
+}
+
+// pullSourceAttemptsError returns an error summarizing attempts, which were made in order, the primary location last.
+func pullSourceAttemptsError(attempts []pullSourceAttempt) error {
 	switch len(attempts) {
 	case 0:
-		return nil, errors.New("Internal error: newImageSource returned without trying any endpoint")
+		return errors.New("Internal error: no endpoint was tried")
 	case 1:
-		return nil, attempts[0].err // If no mirrors are used, perfectly preserve the error type and add no noise.
+		return attempts[0].err // If no mirrors are used, perfectly preserve the error type and add no noise.
 	default:
 		// Don’t just build a string, try to preserve the typed error.
 		primary := &attempts[len(attempts)-1]
@@ -121,10 +164,22 @@ func newImageSource(ctx context.Context, sys *types.SystemContext, ref dockerRef
 			// The paired [] at least have some chance of being unambiguous.
 			extras = append(extras, fmt.Sprintf("[%s: %v]", attempts[i].ref.String(), attempts[i].err))
 		}
-		return nil, fmt.Errorf("(Mirrors also failed: %s): %s: %w", strings.Join(extras, "\n"), primary.ref.String(), primary.err)
+		return fmt.Errorf("(Mirrors also failed: %s): %s: %w", strings.Join(extras, "\n"), primary.ref.String(), primary.err)
 	}
 }
 
+// endpointSystemContext returns a SystemContext to use when accessing physicalRef on behalf of logicalRef.
+func endpointSystemContext(sys *types.SystemContext, logicalRef, physicalRef dockerReference) *types.SystemContext {
+	// sys.DockerAuthConfig does not explicitly specify a registry; we must not blindly send the credentials intended for the primary endpoint to mirrors.
+	if sys != nil && sys.DockerAuthConfig != nil && reference.Domain(physicalRef.ref) != reference.Domain(logicalRef.ref) {
+		copy := *sys
+		copy.DockerAuthConfig = nil
+		copy.DockerBearerRegistryToken = ""
+		return &copy
+	}
+	return sys
+}
+
 // newImageSourceAttempt is an internal helper for newImageSource. Everyone else must call newImageSource.
 // Given a logicalReference and a pullSource, return a dockerImageSource if it is reachable.
 // The caller must call .Close() on the returned ImageSource.
@@ -135,16 +190,9 @@ func newImageSourceAttempt(ctx context.Context, sys *types.SystemContext, logica
 		return nil, err
 	}
 
-	endpointSys := sys
-	// sys.DockerAuthConfig does not explicitly specify a registry; we must not blindly send the credentials intended for the primary endpoint to mirrors.
-	if endpointSys != nil && endpointSys.DockerAuthConfig != nil && reference.Domain(physicalRef.ref) != reference.Domain(logicalRef.ref) {
-		copy := *endpointSys
-		copy.DockerAuthConfig = nil
-		copy.DockerBearerRegistryToken = ""
-		endpointSys = &copy
-	}
+	endpointSys := endpointSystemContext(sys, logicalRef, physicalRef)
 
-	client, err := newDockerClientFromRef(endpointSys, physicalRef, registryConfig, false, "pull")
+	client, err := newDockerClientFromRef(ctx, endpointSys, physicalRef, registryConfig, false, "pull")
 	if err != nil {
 		return nil, err
 	}
@@ -180,7 +228,7 @@ func newImageSourceAttempt(ctx context.Context, sys *types.SystemContext, logica
 		}
 		acfD, err := json.Marshal(acf)
 		if err != nil {
-			logrus.Warnf("failed to marshal auth config: %v", err)
+			log.WarnfContext(ctx, "failed to marshal auth config: %v", err)
 		} else {
 			cmd := exec.Command(h)
 			cmd.Stdin = bytes.NewReader(acfD)
@@ -189,7 +237,7 @@ func newImageSourceAttempt(ctx context.Context, sys *types.SystemContext, logica
 				if ee, ok := err.(*exec.ExitError); ok {
 					stderr = string(ee.Stderr)
 				}
-				logrus.Warnf("Failed to call additional-layer-store-auth-helper (stderr:%s): %v", stderr, err)
+				log.WarnfContext(ctx, "Failed to call additional-layer-store-auth-helper (stderr:%s): %v", stderr, err)
 			}
 		}
 	}
@@ -229,6 +277,9 @@ func (s *dockerImageSource) GetManifest(ctx context.Context, instanceDigest *dig
 		if err := instanceDigest.Validate(); err != nil { // Make sure instanceDigest.String() does not contain any unexpected characters
 			return nil, "", err
 		}
+		if err := checkImageBlocked(s.c.sys, s.logicalRef.ref, *instanceDigest); err != nil {
+			return nil, "", err
+		}
 		return s.fetchManifest(ctx, instanceDigest.String())
 	}
 	err := s.ensureManifestIsLoaded(ctx)
@@ -256,16 +307,26 @@ func (s *dockerImageSource) ensureManifestIsLoaded(ctx context.Context) error {
 		return nil
 	}
 
-	reference, err := s.physicalRef.tagOrDigest()
+	tagOrDigest, err := s.physicalRef.tagOrDigest()
 	if err != nil {
 		return err
 	}
 
-	manblob, mt, err := s.fetchManifest(ctx, reference)
+	manblob, mt, err := s.fetchManifest(ctx, tagOrDigest)
 	if err != nil {
 		return err
 	}
 	// We might validate manblob against the Docker-Content-Digest header here to protect against transport errors.
+	if _, isDigested := s.logicalRef.ref.(reference.Canonical); !isDigested {
+		// The digest of a reference pulled by tag is only known now.
+		manifestDigest, err := manifest.Digest(manblob)
+		if err != nil {
+			return err
+		}
+		if err := checkImageBlocked(s.c.sys, s.logicalRef.ref, manifestDigest); err != nil {
+			return err
+		}
+	}
 	s.cachedManifest = manblob
 	s.cachedManifestMIMEType = mt
 	return nil
@@ -411,7 +472,7 @@ func (s *dockerImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo,
 		return nil, nil, err
 	}
 	path := fmt.Sprintf(blobsPath, reference.Path(s.physicalRef.ref), info.Digest.String())
-	logrus.Debugf("Downloading %s", path)
+	log.DebugfContext(ctx, "Downloading %s", path)
 	res, err := s.c.makeRequest(ctx, http.MethodGet, path, headers, nil, v2Auth, nil)
 	if err != nil {
 		return nil, nil, err
@@ -453,6 +514,15 @@ func (s *dockerImageSource) GetBlob(ctx context.Context, info types.BlobInfo, ca
 	return s.c.getBlob(ctx, s.physicalRef, info, cache)
 }
 
+// RecordBlobLocations records in cache that blobs with digests are available at the location of the source,
+// so that destinations which can reuse blobs from that location (e.g. by mounting them) try to do so.
+// It does not check that the blobs exist.
+func (s *dockerImageSource) RecordBlobLocations(ctx context.Context, digests []digest.Digest, cache blobinfocache.BlobInfoCache2) {
+	for _, d := range digests {
+		cache.RecordKnownLocation(s.physicalRef.Transport(), bicTransportScope(s.physicalRef), d, newBICLocationReference(s.physicalRef))
+	}
+}
+
 // GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
 // If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve signatures for
 // (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
@@ -483,7 +553,27 @@ func (s *dockerImageSource) GetSignaturesWithFormat(ctx context.Context, instanc
 	if err != nil {
 		return nil, err
 	}
-	res = append(res, sigstoreSigs...)
+	bundles, err := s.getSigstoreBundleReferrers(ctx, instanceDigest)
+	if err != nil {
+		return nil, err
+	}
+	sigstoreSigs = append(sigstoreSigs, bundles...)
+	if len(res) == 0 {
+		return sigstoreSigs, nil
+	}
+	// Sigstore signatures may have been written both to the lookaside and as attachments; don’t return duplicates.
+	known := set.New[string]()
+	for _, sig := range res {
+		if blob, err := signature.Blob(sig); err == nil {
+			known.Add(string(blob))
+		}
+	}
+	for _, sig := range sigstoreSigs {
+		if blob, err := signature.Blob(sig); err == nil && known.Contains(string(blob)) {
+			continue
+		}
+		res = append(res, sig)
+	}
 	return res, nil
 }
 
@@ -514,26 +604,43 @@ func (s *dockerImageSource) getSignaturesFromLookaside(ctx context.Context, inst
 	}
 
 	// NOTE: Keep this in sync with docs/signature-protocols.md!
+	// Signatures are read in batches of concurrent requests; most images have at most one signature,
+	// so the first batch is small, and later batches grow for heavily-signed images.
+	type lookasideResult struct {
+		signature signature.Signature
+		missing   bool
+		err       error
+	}
 	signatures := []signature.Signature{}
-	for i := 0; ; i++ {
-		if i >= maxLookasideSignatures {
-			return nil, fmt.Errorf("server provided %d signatures, assuming that's unreasonable and a server error", maxLookasideSignatures)
-		}
-
-		sigURL, err := lookasideStorageURL(s.c.signatureBase, manifestDigest, i)
-		if err != nil {
-			return nil, err
-		}
-		signature, missing, err := s.getOneSignature(ctx, sigURL)
-		if err != nil {
-			return nil, err
-		}
-		if missing {
-			break
+	batchSize := 2
+	for start := 0; start < maxLookasideSignatures; start, batchSize = start+batchSize, min(2*batchSize, maxParallelLookasideReads) {
+		results := make([]lookasideResult, min(batchSize, maxLookasideSignatures-start))
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				sigURL, err := lookasideStorageURL(s.c.signatureBase, manifestDigest, start+i)
+				if err != nil {
+					results[i].err = err
+					return
+				}
+				results[i].signature, results[i].missing, results[i].err = s.getOneSignature(ctx, sigURL)
+			}(i)
+		}
+		wg.Wait()
+		// Signatures after the first missing one are ignored, as are any failures to read them.
+		for _, res := range results {
+			if res.err != nil {
+				return nil, res.err
+			}
+			if res.missing {
+				return signatures, nil
+			}
+			signatures = append(signatures, res.signature)
 		}
-		signatures = append(signatures, signature)
 	}
-	return signatures, nil
+	return nil, fmt.Errorf("server provided %d signatures, assuming that's unreasonable and a server error", maxLookasideSignatures)
 }
 
 // getOneSignature downloads one signature from sigURL, and returns (signature, false, nil)
@@ -542,7 +649,7 @@ func (s *dockerImageSource) getSignaturesFromLookaside(ctx context.Context, inst
 func (s *dockerImageSource) getOneSignature(ctx context.Context, sigURL *url.URL) (signature.Signature, bool, error) {
 	switch sigURL.Scheme {
 	case "file":
-		logrus.Debugf("Reading %s", sigURL.Path)
+		log.DebugfContext(ctx, "Reading %s", sigURL.Path)
 		sigBlob, err := os.ReadFile(sigURL.Path)
 		if err != nil {
 			if os.IsNotExist(err) {
@@ -557,33 +664,9 @@ func (s *dockerImageSource) getOneSignature(ctx context.Context, sigURL *url.URL
 		return sig, false, nil
 
 	case "http", "https":
-		logrus.Debugf("GET %s", sigURL.Redacted())
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, sigURL.String(), nil)
-		if err != nil {
-			return nil, false, err
-		}
-		res, err := s.c.client.Do(req)
-		if err != nil {
-			return nil, false, err
-		}
-		defer res.Body.Close()
-		if res.StatusCode == http.StatusNotFound {
-			logrus.Debugf("... got status 404, as expected = end of signatures")
-			return nil, true, nil
-		} else if res.StatusCode != http.StatusOK {
-			return nil, false, fmt.Errorf("reading signature from %s: status %d (%s)", sigURL.Redacted(), res.StatusCode, http.StatusText(res.StatusCode))
-		}
-
-		contentType := res.Header.Get("Content-Type")
-		if mimeType := simplifyContentType(contentType); mimeType == "text/html" {
-			logrus.Warnf("Signature %q has Content-Type %q, unexpected for a signature", sigURL.Redacted(), contentType)
-			// Don’t immediately fail; the lookaside spec does not place any requirements on Content-Type.
-			// If the content really is HTML, it’s going to fail in signature.FromBlob.
-		}
-
-		sigBlob, err := iolimits.ReadAtMost(res.Body, iolimits.MaxSignatureBodySize)
-		if err != nil {
-			return nil, false, err
+		sigBlob, missing, err := getLookasideHTTPSignature(ctx, s.c.client, sigURL)
+		if err != nil || missing {
+			return nil, missing, err
 		}
 		sig, err := signature.FromBlob(sigBlob)
 		if err != nil {
@@ -619,7 +702,7 @@ func (s *dockerImageSource) getSignaturesFromAPIExtension(ctx context.Context, i
 
 func (s *dockerImageSource) getSignaturesFromSigstoreAttachments(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Signature, error) {
 	if !s.c.useSigstoreAttachments {
-		logrus.Debugf("Not looking for sigstore attachments: disabled by configuration")
+		log.DebugfContext(ctx, "Not looking for sigstore attachments: disabled by configuration")
 		return nil, nil
 	}
 
@@ -636,12 +719,12 @@ func (s *dockerImageSource) getSignaturesFromSigstoreAttachments(ctx context.Con
 		return nil, nil
 	}
 
-	logrus.Debugf("Found a sigstore attachment manifest with %d layers", len(ociManifest.Layers))
+	log.DebugfContext(ctx, "Found a sigstore attachment manifest with %d layers", len(ociManifest.Layers))
 	res := []signature.Signature{}
 	for layerIndex, layer := range ociManifest.Layers {
 		// Note that this copies all kinds of attachments: attestations, and whatever else is there,
 		// not just signatures. We leave the signature consumers to decide based on the MIME type.
-		logrus.Debugf("Fetching sigstore attachment %d/%d: %s", layerIndex+1, len(ociManifest.Layers), layer.Digest.String())
+		log.DebugfContext(ctx, "Fetching sigstore attachment %d/%d: %s", layerIndex+1, len(ociManifest.Layers), layer.Digest.String())
 		// We don’t benefit from a real BlobInfoCache here because we never try to reuse/mount attachment payloads.
 		// That might eventually need to change if payloads grow to be not just signatures, but something
 		// significantly large.
@@ -655,6 +738,41 @@ func (s *dockerImageSource) getSignaturesFromSigstoreAttachments(ctx context.Con
 	return res, nil
 }
 
+// getSigstoreBundleReferrers returns Sigstore bundles attached as referrers of the manifest, if enabled by configuration.
+// Failures to look up the referrers are not fatal, the image is then treated as if it had no bundles.
+func (s *dockerImageSource) getSigstoreBundleReferrers(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Signature, error) {
+	if !s.c.useSigstoreBundleReferrers {
+		log.DebugfContext(ctx, "Not looking for Sigstore bundle referrers: disabled by configuration")
+		return nil, nil
+	}
+
+	manifestDigest, err := s.manifestDigest(ctx, instanceDigest)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := s.c.getReferrerArtifacts(ctx, s.physicalRef, manifestDigest, signature.SigstoreBundleMIMEType)
+	if err != nil {
+		log.WarnfContext(ctx, "Error looking for Sigstore bundles attached to %s, ignoring: %v", manifestDigest.String(), err)
+		return nil, nil
+	}
+	if len(artifacts) != 0 {
+		log.DebugfContext(ctx, "Found %d Sigstore bundle referrers", len(artifacts))
+	}
+	res := []signature.Signature{}
+	for _, artifact := range artifacts {
+		// The bundle contains all of the verification material, so the annotations of the referrer are not used.
+		res = append(res, signature.SigstoreFromComponents(signature.SigstoreBundleMIMEType, artifact.Data, artifact.Annotations))
+	}
+	return res, nil
+}
+
+// recordManifestDeleteSupport records in c.features whether the registry supports deleting manifests.
+func (c *dockerClient) recordManifestDeleteSupport(support registryfeatures.Support) {
+	c.features.Update(c.registry, func(features *registryfeatures.Features) {
+		features.ManifestDelete = support
+	})
+}
+
 // deleteImage deletes the named image from the registry, if supported.
 func deleteImage(ctx context.Context, sys *types.SystemContext, ref dockerReference) error {
 	if ref.isUnknownDigest {
@@ -672,14 +790,17 @@ func deleteImage(ctx context.Context, sys *types.SystemContext, ref dockerRefere
 	// OpenShift ignores the action string (both the password and the token is an OpenShift API token identifying a user).
 	//
 	// We have to hard-code a single string, luckily both docker/distribution and quay.io support "*" to mean "everything".
-	c, err := newDockerClientFromRef(sys, ref, registryConfig, true, "*")
+	c, err := newDockerClientFromRef(ctx, sys, ref, registryConfig, true, "*")
 	if err != nil {
 		return err
 	}
 	defer c.Close()
+	if c.features.Lookup(c.registry).ManifestDelete == registryfeatures.Unsupported {
+		return fmt.Errorf("deleting %v: registry %s does not support deleting manifests", ref.ref, c.registry)
+	}
 
 	headers := map[string][]string{
-		"Accept": manifest.DefaultRequestedManifestMIMETypes,
+		"Accept": c.manifestAcceptHeader(),
 	}
 	refTail, err := ref.tagOrDigest()
 	if err != nil {
@@ -716,7 +837,13 @@ func deleteImage(ctx context.Context, sys *types.SystemContext, ref dockerRefere
 		return err
 	}
 	defer delete.Body.Close()
-	if delete.StatusCode != http.StatusAccepted {
+	switch delete.StatusCode {
+	case http.StatusAccepted:
+		c.recordManifestDeleteSupport(registryfeatures.Supported)
+	case http.StatusMethodNotAllowed: // This is what docker/distribution returns if deleting is disabled.
+		c.recordManifestDeleteSupport(registryfeatures.Unsupported)
+		return fmt.Errorf("deleting %v: %w", ref.ref, registryHTTPResponseToError(delete))
+	default:
 		return fmt.Errorf("deleting %v: %w", ref.ref, registryHTTPResponseToError(delete))
 	}
 
@@ -738,7 +865,7 @@ func deleteImage(ctx context.Context, sys *types.SystemContext, ref dockerRefere
 }
 
 type bufferedNetworkReaderBuffer struct {
-	data     []byte
+	data     *[]byte
 	len      int
 	consumed int
 	err      error
@@ -749,18 +876,21 @@ type bufferedNetworkReader struct {
 	emptyBuffer chan *bufferedNetworkReaderBuffer
 	readyBuffer chan *bufferedNetworkReaderBuffer
 	terminate   chan bool
+	done        chan struct{} // Closed when handleBufferedNetworkReader exits
 	current     *bufferedNetworkReaderBuffer
 	mutex       sync.Mutex
 	gotEOF      bool
+	pool        *bufferpool.Pool // The source of all data buffers
 }
 
 // handleBufferedNetworkReader runs in a goroutine
 func handleBufferedNetworkReader(br *bufferedNetworkReader) {
+	defer close(br.done)
 	defer close(br.readyBuffer)
 	for {
 		select {
 		case b := <-br.emptyBuffer:
-			b.len, b.err = br.stream.Read(b.data)
+			b.len, b.err = br.stream.Read(*b.data)
 			br.readyBuffer <- b
 			if b.err != nil {
 				return
@@ -773,13 +903,28 @@ func handleBufferedNetworkReader(br *bufferedNetworkReader) {
 
 func (n *bufferedNetworkReader) Close() error {
 	close(n.terminate)
+	err := n.stream.Close()
+	// Once handleBufferedNetworkReader exits, no other goroutine uses the buffers, so return them to the pool.
+	<-n.done
 	close(n.emptyBuffer)
-	return n.stream.Close()
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for b := range n.emptyBuffer {
+		n.pool.Put(b.data)
+	}
+	for b := range n.readyBuffer {
+		n.pool.Put(b.data)
+	}
+	if n.current != nil {
+		n.pool.Put(n.current.data)
+		n.current = nil
+	}
+	return err
 }
 
 func (n *bufferedNetworkReader) read(p []byte) (int, error) {
 	if n.current != nil {
-		copied := copy(p, n.current.data[n.current.consumed:n.current.len])
+		copied := copy(p, (*n.current.data)[n.current.consumed:n.current.len])
 		n.current.consumed += copied
 		if n.current.consumed == n.current.len {
 			n.emptyBuffer <- n.current
@@ -824,6 +969,8 @@ func makeBufferedNetworkReader(stream io.ReadCloser, nBuffers, bufferSize uint)
 		emptyBuffer: make(chan *bufferedNetworkReaderBuffer, nBuffers),
 		readyBuffer: make(chan *bufferedNetworkReaderBuffer, nBuffers),
 		terminate:   make(chan bool),
+		done:        make(chan struct{}),
+		pool:        bufferpool.ForSize(int(bufferSize)),
 	}
 
 	go func() {
@@ -832,7 +979,7 @@ func makeBufferedNetworkReader(stream io.ReadCloser, nBuffers, bufferSize uint)
 
 	for i := uint(0); i < nBuffers; i++ {
 		b := bufferedNetworkReaderBuffer{
-			data: make([]byte, bufferSize),
+			data: br.pool.Get(),
 		}
 		br.emptyBuffer <- &b
 	}
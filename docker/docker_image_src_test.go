@@ -12,13 +12,18 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 var _ private.ImageSource = (*dockerImageSource)(nil)
+var _ private.BlobLocationRecorder = (*dockerImageSource)(nil)
+var _ private.ReferrerArtifactSource = (*dockerImageSource)(nil)
 
 func TestDockerImageSourceReference(t *testing.T) {
 	manifestPathRegex := regexp.MustCompile("^/v2/.*/manifests/latest$")
@@ -84,6 +89,71 @@ location = "@REGISTRY@/with-mirror"
 	}
 }
 
+func TestDockerImageSourceBlockedImages(t *testing.T) {
+	manifestPathRegex := regexp.MustCompile("^/v2/.*/manifests/[^/]+$")
+	manifestBody := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json"}`)
+	manifestDigest := digest.FromBytes(manifestBody)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/":
+			rw.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && manifestPathRegex.MatchString(r.URL.Path):
+			rw.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			rw.WriteHeader(http.StatusOK)
+			_, err := rw.Write(manifestBody)
+			assert.NoError(t, err)
+		default:
+			require.FailNowf(t, "Unexpected request", "%v %v", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	registryURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	registry := registryURL.Host
+
+	blockConfiguration := strings.ReplaceAll(strings.ReplaceAll(
+		`[[registry]]
+location = "@REGISTRY@"
+blocked-tags = ["latest"]
+
+[[registry]]
+location = "@REGISTRY@/bad"
+blocked-digests = ["@DIGEST@"]
+`, "@REGISTRY@", registry), "@DIGEST@", manifestDigest.String())
+	registriesConf, err := os.CreateTemp("", "docker-image-src")
+	require.NoError(t, err)
+	defer registriesConf.Close()
+	defer os.Remove(registriesConf.Name())
+	err = os.WriteFile(registriesConf.Name(), []byte(blockConfiguration), 0600)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		input   string
+		blocked bool
+	}{
+		{registry + "/good/busybox:1", false},
+		{registry + "/good/busybox:latest", true},
+		{registry + "/bad/busybox:1", true},
+		{registry + "/bad/busybox@" + manifestDigest.String(), true},
+	} {
+		ref, err := ParseReference("//" + c.input)
+		require.NoError(t, err, c.input)
+		src, err := ref.NewImageSource(context.Background(), &types.SystemContext{
+			RegistriesDirPath:           "/this/does/not/exist",
+			DockerPerHostCertDirPath:    "/this/does/not/exist",
+			SystemRegistriesConfPath:    registriesConf.Name(),
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		})
+		if c.blocked {
+			assert.ErrorContains(t, err, "is blocked in", c.input)
+		} else {
+			require.NoError(t, err, c.input)
+			src.Close()
+		}
+	}
+}
+
 func TestSimplifyContentType(t *testing.T) {
 	for _, c := range []struct{ input, expected string }{
 		{"", ""},
@@ -147,6 +217,27 @@ func TestSplitHTTP200ResponseToPartial(t *testing.T) {
 	verifyGetBlobAtOutput(t, streams, errs, expected)
 }
 
+func TestBufferedNetworkReader(t *testing.T) {
+	input := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+
+	// Reading everything
+	reader := makeBufferedNetworkReader(io.NopCloser(bytes.NewReader(input)), 4, 100)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, input, data)
+	err = reader.Close()
+	require.NoError(t, err)
+
+	// Closing before reaching EOF
+	reader = makeBufferedNetworkReader(io.NopCloser(bytes.NewReader(input)), 4, 100)
+	buf := make([]byte, 150)
+	_, err = io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	assert.Equal(t, input[:150], buf)
+	err = reader.Close()
+	require.NoError(t, err)
+}
+
 func TestHandle206Response(t *testing.T) {
 	body := io.NopCloser(bytes.NewReader([]byte("--AAA\r\n\r\n23\r\n--AAA\r\n\r\n5\r\n--AAA--")))
 	defer body.Close()
@@ -211,3 +302,20 @@ func TestParseMediaType(t *testing.T) {
 	_, _, err = parseMediaType("multipart/byteranges; boundary=@")
 	require.Error(t, err)
 }
+
+func TestDockerImageSourceRecordBlobLocations(t *testing.T) {
+	ref, err := ParseReference("//registry.example/ns/base:latest")
+	require.NoError(t, err)
+	dockerRef, ok := ref.(dockerReference)
+	require.True(t, ok)
+	src := &dockerImageSource{physicalRef: dockerRef}
+	layers := []digest.Digest{digest.FromString("layer1"), digest.FromString("layer2")}
+
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	src.RecordBlobLocations(context.Background(), layers, cache)
+	for _, layer := range layers {
+		// The compression of the blobs is not known, so only CandidateLocations returns them.
+		candidates := cache.CandidateLocations(Transport, bicTransportScope(dockerRef), layer, false)
+		assert.Equal(t, []types.BICReplacementCandidate{{Digest: layer, Location: newBICLocationReference(dockerRef)}}, candidates)
+	}
+}
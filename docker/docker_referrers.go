@@ -0,0 +1,520 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/registryfeatures"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const referrersPath = "/v2/%s/referrers/%s"
+
+// maxReferrersTreeNodes is an arbitrary limit for the total number of referrers GetReferrersTree returns,
+// even if the registry were broken or malicious and it continued serving an enormous number of items.
+const maxReferrersTreeNodes = 10000
+
+// ReferrerArtifact is an artifact attached to an image manifest as an OCI referrer, i.e. stored in a manifest
+// with a "subject" field pointing to the image manifest.
+type ReferrerArtifact struct {
+	ArtifactType string            // The type of the artifact, e.g. "application/spdx+json". Mandatory.
+	MediaType    string            // The MIME type of Data; if empty, AttachReferrer uses "application/octet-stream".
+	Data         []byte            // The contents of the artifact.
+	Annotations  map[string]string // Annotations of the referrer manifest, may be nil.
+}
+
+// AttachReferrer stores artifact in the repository of ref, as a referrer of the manifest with subjectDigest,
+// and returns the digest of the created referrer manifest.
+// If the registry does not support the referrers API, the referrer is recorded in the index using
+// the “referrers tag schema” fallback instead; concurrent updates of that index may lose data.
+func AttachReferrer(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, subjectDigest digest.Digest, artifact ReferrerArtifact) (digest.Digest, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return "", errors.New("ref must be a dockerReference")
+	}
+	if artifact.ArtifactType == "" {
+		return "", errors.New("referrer artifact type must not be empty")
+	}
+	if err := subjectDigest.Validate(); err != nil { // Make sure subjectDigest.String() does not contain any unexpected characters
+		return "", err
+	}
+	mediaType := artifact.MediaType
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+
+	privateDest, err := newImageDestination(ctx, sys, dr)
+	if err != nil {
+		return "", err
+	}
+	defer privateDest.Close()
+	d, ok := privateDest.(*dockerImageDestination)
+	if !ok {
+		return "", fmt.Errorf("Internal error: unexpected destination type %T", privateDest)
+	}
+
+	subjectBlob, subjectMIMEType, err := d.c.fetchManifest(ctx, dr, subjectDigest.String())
+	if err != nil {
+		return "", err
+	}
+	matches, err := manifest.MatchesDigest(subjectBlob, subjectDigest)
+	if err != nil {
+		return "", fmt.Errorf("digesting manifest %s: %w", subjectDigest.String(), err)
+	}
+	if !matches {
+		return "", fmt.Errorf("manifest %s in %s does not match its digest", subjectDigest.String(), dr.ref.Name())
+	}
+
+	// We don’t benefit from a real BlobInfoCache here because we never try to reuse/mount artifacts.
+	dataDesc, err := d.putBlobBytesAsOCI(ctx, artifact.Data, mediaType, private.PutBlobOptions{
+		Cache:      none.NoCache,
+		IsConfig:   false,
+		EmptyLayer: false,
+		LayerIndex: nil,
+	})
+	if err != nil {
+		return "", err
+	}
+	configDesc, err := d.putBlobBytesAsOCI(ctx, imgspecv1.DescriptorEmptyJSON.Data, imgspecv1.MediaTypeEmptyJSON, private.PutBlobOptions{
+		Cache:      none.NoCache,
+		IsConfig:   true,
+		EmptyLayer: false,
+		LayerIndex: nil,
+	})
+	if err != nil {
+		return "", err
+	}
+	manifestBlob, err := json.Marshal(imgspecv1.Manifest{
+		Versioned:    imgspecs.Versioned{SchemaVersion: 2},
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: artifact.ArtifactType,
+		Config:       configDesc,
+		Layers:       []imgspecv1.Descriptor{dataDesc},
+		Subject: &imgspecv1.Descriptor{
+			MediaType: subjectMIMEType,
+			Digest:    subjectDigest,
+			Size:      int64(len(subjectBlob)),
+		},
+		Annotations: artifact.Annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+	manifestDigest := digest.FromBytes(manifestBlob)
+	log.DebugfContext(ctx, "Uploading referrer manifest %s", manifestDigest.String())
+	headers, err := d.uploadManifest(ctx, manifestBlob, manifestDigest.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if headers.Get("OCI-Subject") == subjectDigest.String() {
+		d.c.recordReferrersAPISupport(registryfeatures.Supported)
+		return manifestDigest, nil
+	}
+	d.c.recordReferrersAPISupport(registryfeatures.Unsupported)
+
+	log.DebugfContext(ctx, "Registry did not confirm processing the subject of %s, updating the referrers tag schema index", manifestDigest.String())
+	if err := d.addToReferrersFallbackIndex(ctx, subjectDigest, imgspecv1.Descriptor{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		Digest:       manifestDigest,
+		Size:         int64(len(manifestBlob)),
+		ArtifactType: artifact.ArtifactType,
+		Annotations:  artifact.Annotations,
+	}); err != nil {
+		return "", err
+	}
+	return manifestDigest, nil
+}
+
+// addToReferrersFallbackIndex adds desc to the referrers tag schema index for subjectDigest.
+func (d *dockerImageDestination) addToReferrersFallbackIndex(ctx context.Context, subjectDigest digest.Digest, desc imgspecv1.Descriptor) error {
+	index, err := d.c.getReferrersFallbackIndex(ctx, d.ref, subjectDigest)
+	if err != nil {
+		return err
+	}
+	if index == nil {
+		index = &imgspecv1.Index{
+			Versioned: imgspecs.Versioned{SchemaVersion: 2},
+			MediaType: imgspecv1.MediaTypeImageIndex,
+			Manifests: []imgspecv1.Descriptor{},
+		}
+	}
+	if slices.ContainsFunc(index.Manifests, func(m imgspecv1.Descriptor) bool { return m.Digest == desc.Digest }) {
+		log.DebugfContext(ctx, "Referrer %s is already recorded in the referrers tag schema index", desc.Digest.String())
+		return nil
+	}
+	index.Manifests = append(index.Manifests, desc)
+	indexBlob, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	tag, err := referrersFallbackTag(subjectDigest)
+	if err != nil {
+		return err
+	}
+	_, err = d.uploadManifest(ctx, indexBlob, tag, nil)
+	return err
+}
+
+// ListReferrers returns descriptors of the referrers of the manifest with subjectDigest in the repository of ref.
+// If artifactType is not "", only referrers with that artifact type are returned.
+// The referrers API is used if the registry supports it, with a fallback to the “referrers tag schema”.
+func ListReferrers(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, subjectDigest digest.Digest, artifactType string) ([]imgspecv1.Descriptor, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return nil, errors.New("ref must be a dockerReference")
+	}
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDockerClientFromRef(ctx, sys, dr, registryConfig, false, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	return client.listReferrers(ctx, dr, subjectDigest, artifactType)
+}
+
+// ReferrerNode is a node of a tree of referrers returned by GetReferrersTree.
+type ReferrerNode struct {
+	// The descriptor of the manifest; for the root of the tree, only Digest is set.
+	Descriptor imgspecv1.Descriptor
+	// The referrers of this manifest, or nil if they were not listed because the depth limit was reached.
+	Referrers []ReferrerNode
+}
+
+// GetReferrersTree returns a tree of the referrers of the manifest with subjectDigest in the repository of ref,
+// recursively including referrers of the referrers (e.g. signatures of attestations), up to maxDepth levels
+// below subjectDigest; maxDepth must be positive.
+// The referrers API is used if the registry supports it, with a fallback to the “referrers tag schema”.
+func GetReferrersTree(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, subjectDigest digest.Digest, maxDepth int) (*ReferrerNode, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return nil, errors.New("ref must be a dockerReference")
+	}
+	if maxDepth < 1 {
+		return nil, fmt.Errorf("invalid referrers tree depth %d", maxDepth)
+	}
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDockerClientFromRef(ctx, sys, dr, registryConfig, false, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	w := referrersWalker{
+		maxDepth: maxDepth,
+		list: func(ctx context.Context, subjectDigest digest.Digest) ([]imgspecv1.Descriptor, error) {
+			return client.listReferrers(ctx, dr, subjectDigest, "")
+		},
+		onPath: set.New[digest.Digest](),
+	}
+	root, err := w.walk(ctx, imgspecv1.Descriptor{Digest: subjectDigest}, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// referrersWalker is the state of GetReferrersTree.
+type referrersWalker struct {
+	maxDepth int
+	list     func(ctx context.Context, subjectDigest digest.Digest) ([]imgspecv1.Descriptor, error) // Lists referrers of subjectDigest
+	onPath   *set.Set[digest.Digest]                                                                // Digests of the ancestors of the node being walked
+	nodes    int                                                                                    // Number of nodes found so far
+}
+
+// walk returns the tree of referrers of desc, which is at depth in the tree.
+func (w *referrersWalker) walk(ctx context.Context, desc imgspecv1.Descriptor, depth int) (ReferrerNode, error) {
+	res := ReferrerNode{Descriptor: desc}
+	if depth >= w.maxDepth {
+		return res, nil
+	}
+	descs, err := w.list(ctx, desc.Digest)
+	if err != nil {
+		return ReferrerNode{}, fmt.Errorf("listing referrers of %s: %w", desc.Digest.String(), err)
+	}
+	w.onPath.Add(desc.Digest)
+	defer w.onPath.Delete(desc.Digest)
+	res.Referrers = []ReferrerNode{}
+	for _, referrer := range descs {
+		// A referrer can’t refer to its own descendant, because digests are computed over the subject field;
+		// but the referrers tag schema index is not protected that way.
+		if w.onPath.Contains(referrer.Digest) {
+			log.DebugfContext(ctx, "Ignoring referrer %s of %s which would create a cycle", referrer.Digest.String(), desc.Digest.String())
+			continue
+		}
+		w.nodes++
+		if w.nodes > maxReferrersTreeNodes {
+			return ReferrerNode{}, fmt.Errorf("too many referrers, more than %d", maxReferrersTreeNodes)
+		}
+		node, err := w.walk(ctx, referrer, depth+1)
+		if err != nil {
+			return ReferrerNode{}, err
+		}
+		res.Referrers = append(res.Referrers, node)
+	}
+	return res, nil
+}
+
+// GetReferrerArtifacts returns the artifacts of artifactType attached as referrers to the manifest with subjectDigest
+// in the repository of ref.
+// Only referrers containing a single blob are supported; if any referrer of artifactType contains more blobs, this fails.
+func GetReferrerArtifacts(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, subjectDigest digest.Digest, artifactType string) ([]ReferrerArtifact, error) {
+	dr, ok := ref.(dockerReference)
+	if !ok {
+		return nil, errors.New("ref must be a dockerReference")
+	}
+	if artifactType == "" {
+		return nil, errors.New("referrer artifact type must not be empty")
+	}
+	registryConfig, err := loadRegistryConfiguration(sys)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newDockerClientFromRef(ctx, sys, dr, registryConfig, false, "pull")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	return client.getReferrerArtifacts(ctx, dr, subjectDigest, artifactType)
+}
+
+// GetReferrerArtifacts returns the artifacts of artifactType attached as referrers to the manifest with subjectDigest.
+// The artifacts are not verified in any way, apart from matching their digests.
+func (s *dockerImageSource) GetReferrerArtifacts(ctx context.Context, subjectDigest digest.Digest, artifactType string) ([]private.ReferrerArtifact, error) {
+	artifacts, err := s.c.getReferrerArtifacts(ctx, s.physicalRef, subjectDigest, artifactType)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]private.ReferrerArtifact, 0, len(artifacts))
+	for _, a := range artifacts {
+		res = append(res, private.ReferrerArtifact{
+			ArtifactType: a.ArtifactType,
+			MediaType:    a.MediaType,
+			Data:         a.Data,
+			Annotations:  a.Annotations,
+		})
+	}
+	return res, nil
+}
+
+// getReferrerArtifacts returns the artifacts of artifactType attached as referrers to subjectDigest in (the repo of) ref.
+func (c *dockerClient) getReferrerArtifacts(ctx context.Context, ref dockerReference, subjectDigest digest.Digest, artifactType string) ([]ReferrerArtifact, error) {
+	descs, err := c.listReferrers(ctx, ref, subjectDigest, artifactType)
+	if err != nil {
+		return nil, err
+	}
+	res := []ReferrerArtifact{}
+	for _, desc := range descs {
+		artifact, err := c.getReferrerArtifact(ctx, ref, subjectDigest, desc)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *artifact)
+	}
+	return res, nil
+}
+
+// listReferrers returns descriptors of the referrers of subjectDigest in (the repo of) ref,
+// restricted to artifactType if it is not "".
+func (c *dockerClient) listReferrers(ctx context.Context, ref dockerReference, subjectDigest digest.Digest, artifactType string) ([]imgspecv1.Descriptor, error) {
+	if err := subjectDigest.Validate(); err != nil { // Make sure subjectDigest.String() does not contain any unexpected characters
+		return nil, err
+	}
+	var res []imgspecv1.Descriptor
+	supported := false
+	if c.features.Lookup(c.registry).ReferrersAPI != registryfeatures.Unsupported {
+		var err error
+		res, supported, err = c.listReferrersFromAPI(ctx, ref, subjectDigest, artifactType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !supported {
+		log.DebugfContext(ctx, "Referrers API is not supported, using the referrers tag schema")
+		index, err := c.getReferrersFallbackIndex(ctx, ref, subjectDigest)
+		if err != nil {
+			return nil, err
+		}
+		if index != nil {
+			res = index.Manifests
+		}
+	}
+	// Registries are not required to support filtering (and the fallback index is never filtered),
+	// so always filter the results ourselves.
+	if artifactType != "" {
+		res = slices.DeleteFunc(res, func(desc imgspecv1.Descriptor) bool { return desc.ArtifactType != artifactType })
+	}
+	return res, nil
+}
+
+// listReferrersFromAPI returns descriptors of the referrers of subjectDigest in (the repo of) ref, using the referrers API.
+// It returns (nil, false, nil) if the registry does not support the referrers API.
+func (c *dockerClient) listReferrersFromAPI(ctx context.Context, ref dockerReference, subjectDigest digest.Digest, artifactType string) ([]imgspecv1.Descriptor, bool, error) {
+	path := fmt.Sprintf(referrersPath, reference.Path(ref.ref), subjectDigest.String())
+	if artifactType != "" {
+		path += "?" + url.Values{"artifactType": {artifactType}}.Encode()
+	}
+	headers := map[string][]string{
+		"Accept": {imgspecv1.MediaTypeImageIndex},
+	}
+	descs := []imgspecv1.Descriptor{}
+	firstPage := true
+	for path != "" {
+		index, nextPath, err := func() (*imgspecv1.Index, string, error) { // A scope for defer
+			res, err := c.makeRequest(ctx, http.MethodGet, path, headers, nil, v2Auth, nil)
+			if err != nil {
+				return nil, "", err
+			}
+			defer res.Body.Close()
+			if firstPage && res.StatusCode == http.StatusNotFound {
+				c.recordReferrersAPISupport(registryfeatures.Unsupported)
+				return nil, "", nil
+			}
+			if res.StatusCode != http.StatusOK {
+				return nil, "", fmt.Errorf("fetching referrers of %s in %s: %w", subjectDigest.String(), ref.ref.Name(), registryHTTPResponseToError(res))
+			}
+			body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxManifestBodySize)
+			if err != nil {
+				return nil, "", err
+			}
+			var index imgspecv1.Index
+			if err := json.Unmarshal(body, &index); err != nil {
+				return nil, "", fmt.Errorf("parsing referrers of %s in %s: %w", subjectDigest.String(), ref.ref.Name(), err)
+			}
+			nextPath, err := nextPagePath(res)
+			if err != nil {
+				return nil, "", err
+			}
+			return &index, nextPath, nil
+		}()
+		if err != nil {
+			return nil, false, err
+		}
+		if index == nil {
+			return nil, false, nil
+		}
+		descs = append(descs, index.Manifests...)
+		path = nextPath
+		firstPage = false
+	}
+	c.recordReferrersAPISupport(registryfeatures.Supported)
+	return descs, true, nil
+}
+
+// recordReferrersAPISupport records in c.features whether the registry supports the referrers API.
+func (c *dockerClient) recordReferrersAPISupport(support registryfeatures.Support) {
+	c.features.Update(c.registry, func(features *registryfeatures.Features) {
+		features.ReferrersAPI = support
+	})
+}
+
+// getReferrersFallbackIndex loads and parses the referrers tag schema index for subjectDigest in ref.
+// It returns (nil, nil) if the index does not exist.
+func (c *dockerClient) getReferrersFallbackIndex(ctx context.Context, ref dockerReference, subjectDigest digest.Digest) (*imgspecv1.Index, error) {
+	tag, err := referrersFallbackTag(subjectDigest)
+	if err != nil {
+		return nil, err
+	}
+	indexBlob, mimeType, err := c.fetchManifest(ctx, ref, tag)
+	if err != nil {
+		if isManifestUnknownError(err) {
+			log.DebugfContext(ctx, "Fetching referrers tag schema index failed, assuming it does not exist: %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if mimeType != imgspecv1.MediaTypeImageIndex {
+		return nil, fmt.Errorf("unexpected MIME type for referrers tag schema index %s in %s: %q", tag, ref.ref.Name(), mimeType)
+	}
+	var index imgspecv1.Index
+	if err := json.Unmarshal(indexBlob, &index); err != nil {
+		return nil, fmt.Errorf("parsing referrers tag schema index %s in %s: %w", tag, ref.ref.Name(), err)
+	}
+	return &index, nil
+}
+
+// getReferrerArtifact returns the artifact stored in the referrer manifest desc of subjectDigest in (the repo of) ref.
+func (c *dockerClient) getReferrerArtifact(ctx context.Context, ref dockerReference, subjectDigest digest.Digest, desc imgspecv1.Descriptor) (*ReferrerArtifact, error) {
+	if err := desc.Digest.Validate(); err != nil { // Make sure desc.Digest.String() does not contain any unexpected characters
+		return nil, err
+	}
+	manifestBlob, mimeType, err := c.fetchManifest(ctx, ref, desc.Digest.String())
+	if err != nil {
+		return nil, err
+	}
+	matches, err := manifest.MatchesDigest(manifestBlob, desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("digesting manifest %s: %w", desc.Digest.String(), err)
+	}
+	if !matches {
+		return nil, fmt.Errorf("manifest %s in %s does not match its digest", desc.Digest.String(), ref.ref.Name())
+	}
+	if mimeType != imgspecv1.MediaTypeImageManifest {
+		return nil, fmt.Errorf("unexpected MIME type for referrer manifest %s in %s: %q", desc.Digest.String(), ref.ref.Name(), mimeType)
+	}
+	var m imgspecv1.Manifest
+	if err := json.Unmarshal(manifestBlob, &m); err != nil {
+		return nil, fmt.Errorf("parsing referrer manifest %s in %s: %w", desc.Digest.String(), ref.ref.Name(), err)
+	}
+	if m.Subject == nil || m.Subject.Digest != subjectDigest {
+		return nil, fmt.Errorf("manifest %s in %s is not a referrer of %s", desc.Digest.String(), ref.ref.Name(), subjectDigest.String())
+	}
+	if len(m.Layers) != 1 {
+		return nil, fmt.Errorf("referrer manifest %s in %s contains %d blobs, only a single one is supported", desc.Digest.String(), ref.ref.Name(), len(m.Layers))
+	}
+	blobDesc := m.Layers[0]
+	if err := blobDesc.Digest.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := c.getOCIDescriptorContents(ctx, ref, blobDesc, iolimits.MaxReferrerArtifactBodySize, none.NoCache)
+	if err != nil {
+		return nil, err
+	}
+	verifier := blobDesc.Digest.Verifier()
+	_, _ = verifier.Write(data) // hash.Hash.Write never fails
+	if !verifier.Verified() {
+		return nil, fmt.Errorf("blob %s in %s does not match its digest", blobDesc.Digest.String(), ref.ref.Name())
+	}
+	artifactType := m.ArtifactType
+	if artifactType == "" {
+		// Per the image-spec, the config media type is the artifact type in that case.
+		artifactType = m.Config.MediaType
+	}
+	return &ReferrerArtifact{
+		ArtifactType: artifactType,
+		MediaType:    blobDesc.MediaType,
+		Data:         data,
+		Annotations:  m.Annotations,
+	}, nil
+}
+
+// referrersFallbackTag returns the “referrers tag schema” tag for the specified digest.
+func referrersFallbackTag(d digest.Digest) (string, error) {
+	if err := d.Validate(); err != nil { // Make sure d.String() doesn’t contain any unexpected characters
+		return "", err
+	}
+	return strings.Replace(d.String(), ":", "-", 1), nil
+}
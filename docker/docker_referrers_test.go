@@ -0,0 +1,557 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/pkg/registryfeatures"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referrersRegistryMock is a minimal in-memory registry for a single repository, "ns/repo".
+type referrersRegistryMock struct {
+	server            *httptest.Server
+	supportsReferrers bool // Whether the referrers API is supported
+	pageSize          int  // If > 0, referrers API responses are paginated
+	deleteDisabled    bool // If true, deleting manifests fails like in docker/distribution with deletes disabled
+	// Manifest MIME types rejected with MANIFEST_INVALID
+	rejectedManifestMIMETypes []string
+
+	referrersRequests int // Number of referrers API requests
+	deleteRequests    int // Number of manifest DELETE requests
+
+	mutex     sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[string][]byte // Indexed by tag or digest
+	uploads   map[string][]byte
+}
+
+func newReferrersRegistryMock(t *testing.T, supportsReferrers bool) *referrersRegistryMock {
+	m := &referrersRegistryMock{
+		supportsReferrers: supportsReferrers,
+		blobs:             map[digest.Digest][]byte{},
+		manifests:         map[string][]byte{},
+		uploads:           map[string][]byte{},
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *referrersRegistryMock) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	const repoPrefix = "/v2/ns/repo/"
+	if strings.HasPrefix(r.URL.Path, repoPrefix+"referrers/") {
+		m.referrersRequests++
+	}
+	switch {
+	case r.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPost && r.URL.Path == repoPrefix+"blobs/uploads/":
+		id := strconv.Itoa(len(m.uploads))
+		m.uploads[id] = nil
+		w.Header().Set("Location", "/upload/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/upload/"):
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/upload/")
+		m.uploads[id] = append(m.uploads[id], data...)
+		w.Header().Set("Location", r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/upload/"):
+		data := m.uploads[strings.TrimPrefix(r.URL.Path, "/upload/")]
+		d := digest.Digest(r.URL.Query().Get("digest"))
+		if d != digest.FromBytes(data) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		m.blobs[d] = data
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.HasPrefix(r.URL.Path, repoPrefix+"blobs/"):
+		data, ok := m.blobs[digest.Digest(strings.TrimPrefix(r.URL.Path, repoPrefix+"blobs/"))]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, repoPrefix+"manifests/") &&
+		slices.Contains(m.rejectedManifestMIMETypes, r.Header.Get("Content-Type")):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid"}]}`))
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, repoPrefix+"manifests/"):
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		d := digest.FromBytes(data)
+		m.manifests[strings.TrimPrefix(r.URL.Path, repoPrefix+"manifests/")] = data
+		m.manifests[d.String()] = data
+		var parsed imgspecv1.Manifest
+		if m.supportsReferrers && json.Unmarshal(data, &parsed) == nil && parsed.Subject != nil {
+			w.Header().Set("OCI-Subject", parsed.Subject.Digest.String())
+		}
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.WriteHeader(http.StatusCreated)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && strings.HasPrefix(r.URL.Path, repoPrefix+"manifests/"):
+		data, ok := m.manifests[strings.TrimPrefix(r.URL.Path, repoPrefix+"manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var parsed struct {
+			MediaType string `json:"mediaType"`
+		}
+		_ = json.Unmarshal(data, &parsed)
+		w.Header().Set("Content-Type", parsed.MediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, repoPrefix+"manifests/"):
+		m.deleteRequests++
+		if m.deleteDisabled {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"UNSUPPORTED","message":"The operation is unsupported."}]}`))
+			return
+		}
+		// Like registries, delete the manifest with all tags referring to it.
+		d := digest.Digest(strings.TrimPrefix(r.URL.Path, repoPrefix+"manifests/"))
+		if _, ok := m.manifests[d.String()]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for key, data := range m.manifests {
+			if digest.FromBytes(data) == d {
+				delete(m.manifests, key)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, repoPrefix+"referrers/") && m.supportsReferrers:
+		subject := digest.Digest(strings.TrimPrefix(r.URL.Path, repoPrefix+"referrers/"))
+		referrers := []imgspecv1.Descriptor{}
+		for key, data := range m.manifests {
+			var parsed imgspecv1.Manifest
+			if key != digest.FromBytes(data).String() || json.Unmarshal(data, &parsed) != nil ||
+				parsed.Subject == nil || parsed.Subject.Digest != subject {
+				continue
+			}
+			referrers = append(referrers, imgspecv1.Descriptor{
+				MediaType:    parsed.MediaType,
+				Digest:       digest.FromBytes(data),
+				Size:         int64(len(data)),
+				ArtifactType: parsed.ArtifactType,
+				Annotations:  parsed.Annotations,
+			})
+		}
+		// Pagination requires a stable order.
+		slices.SortFunc(referrers, func(a, b imgspecv1.Descriptor) int { return strings.Compare(a.Digest.String(), b.Digest.String()) })
+		if m.pageSize > 0 {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			start := min(page*m.pageSize, len(referrers))
+			end := min(start+m.pageSize, len(referrers))
+			if end < len(referrers) {
+				w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d>; rel="next"`, r.URL.Path, page+1))
+			}
+			referrers = referrers[start:end]
+		}
+		data, err := json.Marshal(imgspecv1.Index{
+			MediaType: imgspecv1.MediaTypeImageIndex,
+			Manifests: referrers,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", imgspecv1.MediaTypeImageIndex)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestReferrers(t *testing.T) {
+	const subjectManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`
+	subjectDigest := digest.FromString(subjectManifest)
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+	sbom := ReferrerArtifact{
+		ArtifactType: "application/spdx+json",
+		MediaType:    "application/spdx+json",
+		Data:         []byte(`{"spdxVersion":"SPDX-2.3"}`),
+		Annotations:  map[string]string{"org.opencontainers.image.created": "2024-01-01T00:00:00Z"},
+	}
+	scan := ReferrerArtifact{
+		ArtifactType: "application/sarif+json",
+		Data:         []byte(`{"version":"2.1.0"}`),
+	}
+
+	for _, c := range []struct {
+		name              string
+		supportsReferrers bool
+		pageSize          int
+	}{
+		{"referrers API", true, 0},
+		{"paginated referrers API", true, 1},
+		{"referrers tag schema", false, 0},
+	} {
+		registry := newReferrersRegistryMock(t, c.supportsReferrers)
+		registry.pageSize = c.pageSize
+		registry.manifests[subjectDigest.String()] = []byte(subjectManifest)
+		ref, err := ParseReference("//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag")
+		require.NoError(t, err, c.name)
+
+		// No referrers yet
+		descs, err := ListReferrers(context.Background(), sys, ref, subjectDigest, "")
+		require.NoError(t, err, c.name)
+		assert.Empty(t, descs, c.name)
+
+		sbomDigest, err := AttachReferrer(context.Background(), sys, ref, subjectDigest, sbom)
+		require.NoError(t, err, c.name)
+		scanDigest, err := AttachReferrer(context.Background(), sys, ref, subjectDigest, scan)
+		require.NoError(t, err, c.name)
+		// Attaching the same artifact again does not create a duplicate entry
+		sbomDigest2, err := AttachReferrer(context.Background(), sys, ref, subjectDigest, sbom)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, sbomDigest, sbomDigest2, c.name)
+
+		fallbackTag, err := referrersFallbackTag(subjectDigest)
+		require.NoError(t, err, c.name)
+		_, hasFallbackIndex := registry.manifests[fallbackTag]
+		assert.Equal(t, !c.supportsReferrers, hasFallbackIndex, c.name)
+
+		referrerManifest := registry.manifests[sbomDigest.String()]
+		var parsed imgspecv1.Manifest
+		err = json.Unmarshal(referrerManifest, &parsed)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, sbom.ArtifactType, parsed.ArtifactType, c.name)
+		assert.Equal(t, imgspecv1.MediaTypeEmptyJSON, parsed.Config.MediaType, c.name)
+		assert.Equal(t, imgspecv1.DescriptorEmptyJSON.Digest, parsed.Config.Digest, c.name)
+		require.NotNil(t, parsed.Subject, c.name)
+		assert.Equal(t, imgspecv1.Descriptor{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Digest:    subjectDigest,
+			Size:      int64(len(subjectManifest)),
+		}, *parsed.Subject, c.name)
+
+		descs, err = ListReferrers(context.Background(), sys, ref, subjectDigest, "")
+		require.NoError(t, err, c.name)
+		digests := []digest.Digest{}
+		for _, desc := range descs {
+			digests = append(digests, desc.Digest)
+		}
+		assert.ElementsMatch(t, []digest.Digest{sbomDigest, scanDigest}, digests, c.name)
+
+		descs, err = ListReferrers(context.Background(), sys, ref, subjectDigest, scan.ArtifactType)
+		require.NoError(t, err, c.name)
+		require.Len(t, descs, 1, c.name)
+		assert.Equal(t, scanDigest, descs[0].Digest, c.name)
+		assert.Equal(t, scan.ArtifactType, descs[0].ArtifactType, c.name)
+
+		artifacts, err := GetReferrerArtifacts(context.Background(), sys, ref, subjectDigest, sbom.ArtifactType)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, []ReferrerArtifact{sbom}, artifacts, c.name)
+		artifacts, err = GetReferrerArtifacts(context.Background(), sys, ref, subjectDigest, scan.ArtifactType)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, []ReferrerArtifact{{
+			ArtifactType: scan.ArtifactType,
+			MediaType:    "application/octet-stream",
+			Data:         scan.Data,
+		}}, artifacts, c.name)
+		artifacts, err = GetReferrerArtifacts(context.Background(), sys, ref, subjectDigest, "application/unknown")
+		require.NoError(t, err, c.name)
+		assert.Empty(t, artifacts, c.name)
+
+		// A corrupted artifact blob is rejected
+		registry.blobs[parsed.Layers[0].Digest] = []byte("corrupted")
+		_, err = GetReferrerArtifacts(context.Background(), sys, ref, subjectDigest, sbom.ArtifactType)
+		assert.Error(t, err, c.name)
+	}
+
+	// Invalid parameters
+	registry := newReferrersRegistryMock(t, true)
+	registry.manifests[subjectDigest.String()] = []byte(subjectManifest)
+	ref, err := ParseReference("//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag")
+	require.NoError(t, err)
+	_, err = AttachReferrer(context.Background(), sys, ref, subjectDigest, ReferrerArtifact{Data: []byte("data")})
+	assert.Error(t, err)
+	_, err = AttachReferrer(context.Background(), sys, ref, "sha256:invalid", sbom)
+	assert.Error(t, err)
+	_, err = AttachReferrer(context.Background(), sys, ref, digest.FromString("unknown subject"), sbom)
+	assert.Error(t, err)
+	_, err = ListReferrers(context.Background(), sys, ref, "sha256:invalid", "")
+	assert.Error(t, err)
+	_, err = GetReferrerArtifacts(context.Background(), sys, ref, subjectDigest, "")
+	assert.Error(t, err)
+}
+
+func TestReferrersRegistryFeatureCache(t *testing.T) {
+	const subjectManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`
+	subjectDigest := digest.FromString(subjectManifest)
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerRegistryFeatureCache:  registryfeatures.New(),
+	}
+
+	registry := newReferrersRegistryMock(t, false)
+	registry.deleteDisabled = true
+	registry.manifests[subjectDigest.String()] = []byte(subjectManifest)
+	registry.manifests["tag"] = []byte(subjectManifest)
+	registryName := strings.TrimPrefix(registry.server.URL, "http://")
+	ref, err := ParseReference("//" + registryName + "/ns/repo:tag")
+	require.NoError(t, err)
+
+	// The referrers API is only tried once.
+	_, err = ListReferrers(context.Background(), sys, ref, subjectDigest, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, registry.referrersRequests)
+	assert.Equal(t, registryfeatures.Unsupported, sys.DockerRegistryFeatureCache.Lookup(registryName).ReferrersAPI)
+	_, err = AttachReferrer(context.Background(), sys, ref, subjectDigest, ReferrerArtifact{ArtifactType: "application/spdx+json", Data: []byte("{}")})
+	require.NoError(t, err)
+	descs, err := ListReferrers(context.Background(), sys, ref, subjectDigest, "")
+	require.NoError(t, err)
+	assert.Len(t, descs, 1)
+	assert.Equal(t, 1, registry.referrersRequests)
+
+	// Deleting is only tried once.
+	for i := 0; i < 2; i++ {
+		err = ref.DeleteImage(context.Background(), sys)
+		assert.ErrorContains(t, err, "support")
+		assert.Equal(t, 1, registry.deleteRequests)
+	}
+	assert.Equal(t, registryfeatures.Unsupported, sys.DockerRegistryFeatureCache.Lookup(registryName).ManifestDelete)
+}
+
+func TestGetReferrersTree(t *testing.T) {
+	const subjectManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`
+	subjectDigest := digest.FromString(subjectManifest)
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+
+	registry := newReferrersRegistryMock(t, true)
+	registry.manifests[subjectDigest.String()] = []byte(subjectManifest)
+	ref, err := ParseReference("//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag")
+	require.NoError(t, err)
+	// subject ← sbom ← attestation ← signature, and subject ← scan
+	sbomDigest, err := AttachReferrer(context.Background(), sys, ref, subjectDigest,
+		ReferrerArtifact{ArtifactType: "application/spdx+json", Data: []byte(`{"spdxVersion":"SPDX-2.3"}`)})
+	require.NoError(t, err)
+	scanDigest, err := AttachReferrer(context.Background(), sys, ref, subjectDigest,
+		ReferrerArtifact{ArtifactType: "application/sarif+json", Data: []byte(`{"version":"2.1.0"}`)})
+	require.NoError(t, err)
+	attestationDigest, err := AttachReferrer(context.Background(), sys, ref, sbomDigest,
+		ReferrerArtifact{ArtifactType: "application/vnd.in-toto+json", Data: []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)})
+	require.NoError(t, err)
+	signatureDigest, err := AttachReferrer(context.Background(), sys, ref, attestationDigest,
+		ReferrerArtifact{ArtifactType: "application/vnd.dev.sigstore.bundle.v0.3+json", Data: []byte(`{}`)})
+	require.NoError(t, err)
+
+	// treeDigests returns a description of the tree in node, for comparisons.
+	var treeDigests func(node ReferrerNode) any
+	treeDigests = func(node ReferrerNode) any {
+		if node.Referrers == nil {
+			return nil
+		}
+		res := map[digest.Digest]any{}
+		for _, child := range node.Referrers {
+			res[child.Descriptor.Digest] = treeDigests(child)
+		}
+		return res
+	}
+	for _, c := range []struct {
+		depth    int
+		expected map[digest.Digest]any
+	}{
+		{1, map[digest.Digest]any{sbomDigest: nil, scanDigest: nil}},
+		{2, map[digest.Digest]any{
+			sbomDigest: map[digest.Digest]any{attestationDigest: nil},
+			scanDigest: map[digest.Digest]any{},
+		}},
+		{10, map[digest.Digest]any{
+			sbomDigest: map[digest.Digest]any{attestationDigest: map[digest.Digest]any{signatureDigest: map[digest.Digest]any{}}},
+			scanDigest: map[digest.Digest]any{},
+		}},
+	} {
+		tree, err := GetReferrersTree(context.Background(), sys, ref, subjectDigest, c.depth)
+		require.NoError(t, err, c.depth)
+		assert.Equal(t, imgspecv1.Descriptor{Digest: subjectDigest}, tree.Descriptor, c.depth)
+		assert.Equal(t, c.expected, treeDigests(*tree), c.depth)
+		for _, child := range tree.Referrers {
+			if child.Descriptor.Digest == sbomDigest {
+				assert.Equal(t, "application/spdx+json", child.Descriptor.ArtifactType)
+			}
+		}
+	}
+
+	_, err = GetReferrersTree(context.Background(), sys, ref, subjectDigest, 0)
+	assert.Error(t, err)
+	_, err = GetReferrersTree(context.Background(), sys, ref, "sha256:invalid", 1)
+	assert.Error(t, err)
+}
+
+func TestReferrersWalkerCycle(t *testing.T) {
+	d1, d2 := digest.FromString("1"), digest.FromString("2")
+	graph := map[digest.Digest][]imgspecv1.Descriptor{
+		d1: {{Digest: d2}},
+		d2: {{Digest: d1}, {Digest: d2}},
+	}
+	w := referrersWalker{
+		maxDepth: 100,
+		list: func(ctx context.Context, subjectDigest digest.Digest) ([]imgspecv1.Descriptor, error) {
+			return graph[subjectDigest], nil
+		},
+		onPath: set.New[digest.Digest](),
+	}
+	tree, err := w.walk(context.Background(), imgspecv1.Descriptor{Digest: d1}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, ReferrerNode{
+		Descriptor: imgspecv1.Descriptor{Digest: d1},
+		Referrers: []ReferrerNode{{
+			Descriptor: imgspecv1.Descriptor{Digest: d2},
+			Referrers:  []ReferrerNode{},
+		}},
+	}, tree)
+}
+
+func TestGetSignaturesFromSigstoreBundleReferrers(t *testing.T) {
+	const subjectManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`
+	subjectDigest := digest.FromString(subjectManifest)
+	registriesDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(registriesDir, "default.yaml"), []byte("default-docker:\n  use-sigstore-bundle-referrers: true\n"), 0o600)
+	require.NoError(t, err)
+	disabledRegistriesDir := t.TempDir()
+	err = os.WriteFile(filepath.Join(disabledRegistriesDir, "default.yaml"), []byte("default-docker:\n  use-sigstore-attachments: true\n"), 0o600)
+	require.NoError(t, err)
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err = os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		RegistriesDirPath:           registriesDir,
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+	bundle := ReferrerArtifact{
+		ArtifactType: signature.SigstoreBundleMIMEType,
+		MediaType:    signature.SigstoreBundleMIMEType,
+		Data:         []byte(`{"mediaType":"` + signature.SigstoreBundleMIMEType + `"}`),
+	}
+
+	for _, supportsReferrers := range []bool{true, false} {
+		registry := newReferrersRegistryMock(t, supportsReferrers)
+		registry.manifests[subjectDigest.String()] = []byte(subjectManifest)
+		registry.manifests["tag"] = []byte(subjectManifest)
+		ref, err := ParseReference("//" + strings.TrimPrefix(registry.server.URL, "http://") + "/ns/repo:tag")
+		require.NoError(t, err)
+
+		// No signatures
+		src, err := ref.NewImageSource(context.Background(), sys)
+		require.NoError(t, err)
+		sigs, err := src.(*dockerImageSource).GetSignaturesWithFormat(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, sigs)
+		src.Close()
+
+		_, err = AttachReferrer(context.Background(), sys, ref, subjectDigest, bundle)
+		require.NoError(t, err)
+		_, err = AttachReferrer(context.Background(), sys, ref, subjectDigest, ReferrerArtifact{
+			ArtifactType: "application/spdx+json",
+			Data:         []byte(`{"spdxVersion":"SPDX-2.3"}`),
+		})
+		require.NoError(t, err)
+
+		src, err = ref.NewImageSource(context.Background(), sys)
+		require.NoError(t, err)
+		sigs, err = src.(*dockerImageSource).GetSignaturesWithFormat(context.Background(), nil)
+		require.NoError(t, err)
+		require.Len(t, sigs, 1)
+		sig, ok := sigs[0].(signature.Sigstore)
+		require.True(t, ok)
+		assert.Equal(t, signature.SigstoreBundleMIMEType, sig.UntrustedMIMEType())
+		assert.Equal(t, bundle.Data, sig.UntrustedPayload())
+		src.Close()
+
+		// Bundles are not looked up unless enabled
+		disabledSys := *sys
+		disabledSys.RegistriesDirPath = disabledRegistriesDir
+		src, err = ref.NewImageSource(context.Background(), &disabledSys)
+		require.NoError(t, err)
+		referrersRequests := registry.referrersRequests
+		sigs, err = src.(*dockerImageSource).GetSignaturesWithFormat(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, sigs)
+		assert.Equal(t, referrersRequests, registry.referrersRequests)
+		src.Close()
+
+		// Failures to read bundles are not fatal
+		registry.blobs[digest.FromBytes(bundle.Data)] = []byte("tampered")
+		src, err = ref.NewImageSource(context.Background(), sys)
+		require.NoError(t, err)
+		sigs, err = src.(*dockerImageSource).GetSignaturesWithFormat(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Empty(t, sigs)
+		src.Close()
+	}
+}
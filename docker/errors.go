@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/docker/distribution/registry/api/errcode"
-	"github.com/sirupsen/logrus"
+	digest "github.com/opencontainers/go-digest"
 )
 
 var (
@@ -26,6 +27,49 @@ func (e ErrUnauthorizedForCredentials) Error() string {
 	return fmt.Sprintf("unable to retrieve auth token: invalid username/password: %s", e.Err.Error())
 }
 
+// ErrRegistryNotAllowed is returned when accessing a registry which is not permitted by
+// types.SystemContext.DockerRegistryAllowList or DockerRegistryDenyList.
+type ErrRegistryNotAllowed struct {
+	Registry string // The host[:port] value of the registry
+	Denied   bool   // true if the registry matched DockerRegistryDenyList, false if it did not match DockerRegistryAllowList
+}
+
+func (e ErrRegistryNotAllowed) Error() string {
+	if e.Denied {
+		return fmt.Sprintf("access to registry %q is denied by the registry deny list", e.Registry)
+	}
+	return fmt.Sprintf("access to registry %q is not permitted by the registry allow list", e.Registry)
+}
+
+// ManifestConflictError is returned when writing a manifest to a tag is refused because the tag does not refer to the manifest
+// required by types.SystemContext.DockerManifestIfMatch or DockerManifestIfNotExists, typically because another client
+// has updated the tag concurrently. Callers can read the current state of the tag, and retry.
+type ManifestConflictError struct {
+	Tag string
+	// The digest required by DockerManifestIfMatch, or "" if DockerManifestIfNotExists was used.
+	Expected digest.Digest
+	// The digest the tag refers to, or "" if the tag does not exist, or if the registry has refused the write without reporting it.
+	Current digest.Digest
+}
+
+func (e ManifestConflictError) Error() string {
+	var expected, current string
+	if e.Expected == "" {
+		expected = "to not exist"
+	} else {
+		expected = "to refer to " + e.Expected.String()
+	}
+	switch {
+	case e.Current != "":
+		current = "it refers to " + e.Current.String()
+	case e.Expected != "":
+		current = "it does not exist or was modified concurrently"
+	default:
+		current = "it exists"
+	}
+	return fmt.Sprintf("conflicting update of tag %q: expected the tag %s, but %s", e.Tag, expected, current)
+}
+
 // httpResponseToError translates the https.Response into an error, possibly prefixing it with the supplied context. It returns
 // nil if the response is not considered an error.
 // NOTE: Almost all callers in this package should use registryHTTPResponseToError instead.
@@ -74,9 +118,9 @@ func registryHTTPResponseToError(res *http.Response) error {
 		// Also, docker/docker similarly only logs the other errors and returns the
 		// first one.
 		if len(errs) > 1 {
-			logrus.Debugf("Discarding non-primary errors:")
+			log.Debugf("Discarding non-primary errors:")
 			for _, err := range errs[1:] {
-				logrus.Debugf("  %s", err.Error())
+				log.Debugf("  %s", err.Error())
 			}
 		}
 		err = errs[0]
@@ -12,12 +12,12 @@ import (
 	"github.com/containers/image/v5/internal/imagedestination/impl"
 	"github.com/containers/image/v5/internal/imagedestination/stubs"
 	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/streamdigest"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 // Destination is a partial implementation of private.ImageDestination for writing to an io.Writer.
@@ -81,14 +81,14 @@ func (d *Destination) PutBlobWithOptions(ctx context.Context, stream io.Reader,
 	// Ouch, we need to stream the blob into a temporary file just to determine the size.
 	// When the layer is decompressed, we also have to generate the digest on uncompressed data.
 	if inputInfo.Size == -1 || inputInfo.Digest == "" {
-		logrus.Debugf("docker tarfile: input with unknown size, streaming to disk first ...")
+		log.DebugfContext(ctx, "docker tarfile: input with unknown size, streaming to disk first ...")
 		streamCopy, cleanup, err := streamdigest.ComputeBlobInfo(d.sysCtx, stream, &inputInfo)
 		if err != nil {
 			return private.UploadedBlob{}, err
 		}
 		defer cleanup()
 		stream = streamCopy
-		logrus.Debugf("... streaming done")
+		log.DebugfContext(ctx, "... streaming done")
 	}
 
 	if err := d.archive.lock(); err != nil {
@@ -2,6 +2,7 @@ package tarfile
 
 import (
 	"archive/tar"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,19 +21,39 @@ import (
 type Reader struct {
 	// None of the fields below are modified after the archive is created, until .Close();
 	// this allows concurrent readers of the same archive.
-	path          string         // "" if the archive has already been closed.
-	removeOnClose bool           // Remove file on close if true
-	Manifest      []ManifestItem // Guaranteed to exist after the archive is created.
+	path          string           // "" if the archive has already been closed, or if stream is set.
+	removeOnClose bool             // Remove file on close if true
+	stream        *streamedArchive // If not nil, the archive is read in a single pass from a non-seekable file; nil if closed.
+	Manifest      []ManifestItem   // Guaranteed to exist after the archive is created.
 }
 
 // NewReaderFromFile returns a Reader for the specified path.
 // The caller should call .Close() on the returned archive when done.
 func NewReaderFromFile(sys *types.SystemContext, path string) (*Reader, error) {
+	return newReaderFromFile(sys, path, false)
+}
+
+// NewReaderFromFileForSingleSource is like NewReaderFromFile, but if path is not seekable (e.g. a pipe),
+// and manifest.json and the image configs precede the layers in the archive, the archive is read
+// in a single pass, buffering only small components in memory, instead of being copied to a temporary file.
+// Such a Reader can only be used by a single Source, which reads layers sequentially in archive order.
+// The caller should call .Close() on the returned archive when done.
+func NewReaderFromFileForSingleSource(sys *types.SystemContext, path string) (*Reader, error) {
+	return newReaderFromFile(sys, path, true)
+}
+
+// newReaderFromFile is NewReaderFromFile or NewReaderFromFileForSingleSource, depending on allowStreaming.
+func newReaderFromFile(sys *types.SystemContext, path string, allowStreaming bool) (*Reader, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("opening file %q: %w", path, err)
 	}
-	defer file.Close()
+	closeFile := true
+	defer func() {
+		if closeFile {
+			file.Close()
+		}
+	}()
 
 	// If the file is seekable and already not compressed we can just return the file itself
 	// as a source. Otherwise we pass the stream to NewReaderFromStream.
@@ -47,6 +68,23 @@ func NewReaderFromFile(sys *types.SystemContext, path string) (*Reader, error) {
 		if !isCompressed {
 			return newReader(path, false)
 		}
+	} else if allowStreaming {
+		decompressed, _, err := compression.AutoDecompress(file)
+		if err != nil {
+			return nil, fmt.Errorf("detecting compression for file %q: %w", path, err)
+		}
+		r, prefix, err := newStreamingReader(file, decompressed)
+		if err != nil {
+			decompressed.Close()
+			return nil, err
+		}
+		if r != nil {
+			closeFile = false // Now owned by r
+			return r, nil
+		}
+		// The archive layout does not allow reading it in a single pass; copy it to a temporary file, after all.
+		defer decompressed.Close()
+		stream = io.MultiReader(bytes.NewReader(prefix), decompressed)
 	}
 	return NewReaderFromStream(sys, stream)
 }
@@ -126,6 +164,11 @@ func newReader(path string, removeOnClose bool) (*Reader, error) {
 
 // Close removes resources associated with an initialized Reader, if any.
 func (r *Reader) Close() error {
+	if r.stream != nil {
+		stream := r.stream
+		r.stream = nil // Mark the archive as closed
+		return stream.close()
+	}
 	path := r.path
 	r.path = "" // Mark the archive as closed
 	if r.removeOnClose {
@@ -187,9 +230,13 @@ func (t *tarReadCloser) Close() error {
 // openTarComponent returns a ReadCloser for the specific file within the archive.
 // This is linear scan; we assume that the tar file will have a fairly small amount of files (~layers),
 // and that filesystem caching will make the repeated seeking over the (uncompressed) tarPath cheap enough.
-// It is safe to call this method from multiple goroutines simultaneously.
+// It is safe to call this method from multiple goroutines simultaneously,
+// except for archives read in a single pass, where streamedArchive.openComponent restrictions apply.
 // The caller should call .Close() on the returned stream.
 func (r *Reader) openTarComponent(componentPath string) (io.ReadCloser, error) {
+	if r.stream != nil {
+		return r.stream.openComponent(componentPath)
+	}
 	// This is only a sanity check; if anyone did concurrently close ra, this access is technically
 	// racy against the write in .Close().
 	if r.path == "" {
@@ -58,7 +58,7 @@ type layerInfo struct {
 func NewSource(archive *Reader, closeArchive bool, transportName string, ref reference.NamedTagged, sourceIndex int) *Source {
 	s := &Source{
 		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
-			HasThreadSafeGetBlob: true,
+			HasThreadSafeGetBlob: archive.stream == nil, // Layers of a streamed archive must be read sequentially
 		}),
 		NoGetBlobAtInitialize: stubs.NoGetBlobAtRaw(transportName),
 
@@ -154,6 +154,12 @@ func (s *Source) prepareLayerData(tarManifest *ManifestItem, parsedConfig *manif
 		unknownLayerSizes[layerPath] = li
 	}
 
+	if s.archive.stream != nil {
+		// The layers have not been read yet, and a non-seekable archive can’t be scanned in advance; the sizes remain unknown,
+		// and missing layers are only detected when reading them.
+		return knownLayers, nil
+	}
+
 	// Scan the tar file to collect layer sizes.
 	file, err := os.Open(s.archive.path)
 	if err != nil {
@@ -0,0 +1,223 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/set"
+)
+
+const (
+	// maxStreamedComponentSize is the size of the largest tar component which is buffered in memory
+	// when reading a non-seekable archive; larger components (layers) are only read once, in archive order.
+	maxStreamedComponentSize = iolimits.MaxConfigBodySize
+	// maxStreamedBufferSize is the maximum total size of tar components buffered in memory when reading a non-seekable archive.
+	maxStreamedBufferSize = 16 * maxStreamedComponentSize
+)
+
+// streamedArchive reads a tar archive in a single pass from a non-seekable stream.
+// Small components are buffered in memory, larger ones can only be read once, in the order they appear in the archive.
+type streamedArchive struct {
+	file         *os.File      // The underlying file
+	decompressed io.ReadCloser // file, decompressed if necessary
+	tarReader    *tar.Reader
+
+	mutex        sync.Mutex // Protects the members below
+	buffered     map[string][]byte
+	bufferedSize int64
+	symlinks     map[string]string // Symlink targets, by path.Clean()ed path
+	passed       *set.Set[string]  // Large components which have been skipped over, and can not be read any more
+	reading      bool              // A large component returned by openComponent is being read from tarReader
+}
+
+// prefixRecorder is an io.Reader which records the data read so far, until recording is stopped by setting prefix to nil.
+type prefixRecorder struct {
+	reader io.Reader
+	prefix *bytes.Buffer // nil if no longer recording
+}
+
+func (r *prefixRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if r.prefix != nil {
+		r.prefix.Write(p[:n])
+	}
+	return n, err
+}
+
+// newStreamingReader tries to create a Reader for file, which is not seekable, and decompressed, its decompressed contents,
+// which reads the archive in a single pass.
+// That is only possible if manifest.json and the image configs it references precede any large components (layers) in the archive.
+// If that is not the case, it returns (nil, prefix, nil), where prefix is the data already consumed from decompressed;
+// the caller can fall back to reading prefix followed by the rest of decompressed.
+// On success, the returned Reader takes over responsibility for closing file and decompressed.
+func newStreamingReader(file *os.File, decompressed io.ReadCloser) (*Reader, []byte, error) {
+	recorder := &prefixRecorder{reader: decompressed, prefix: &bytes.Buffer{}}
+	a := &streamedArchive{
+		file:         file,
+		decompressed: decompressed,
+		tarReader:    tar.NewReader(recorder),
+		buffered:     map[string][]byte{},
+		symlinks:     map[string]string{},
+		passed:       set.New[string](),
+	}
+	var tarManifest []ManifestItem
+	for {
+		if tarManifest == nil {
+			if data, ok := a.buffered[a.resolve(manifestFileName)]; ok {
+				if err := json.Unmarshal(data, &tarManifest); err != nil {
+					return nil, nil, fmt.Errorf("decoding tar manifest.json: %w", err)
+				}
+				if tarManifest == nil {
+					tarManifest = []ManifestItem{}
+				}
+			}
+		}
+		if tarManifest != nil && a.allConfigsBuffered(tarManifest) {
+			break
+		}
+
+		_, large, err := a.next()
+		if err == io.EOF || (err == nil && large) {
+			return nil, recorder.prefix.Bytes(), nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	recorder.prefix = nil
+	return &Reader{
+		stream:   a,
+		Manifest: tarManifest,
+	}, nil, nil
+}
+
+// allConfigsBuffered returns true if configs of all items of tarManifest are buffered in a.
+// The caller must hold a.mutex, or have exclusive access to a.
+func (a *streamedArchive) allConfigsBuffered(tarManifest []ManifestItem) bool {
+	for _, item := range tarManifest {
+		if _, ok := a.buffered[a.resolve(item.Config)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns a path.Clean()ed version of componentPath, following at most one known symlink.
+// The caller must hold a.mutex, or have exclusive access to a.
+func (a *streamedArchive) resolve(componentPath string) string {
+	componentPath = path.Clean(componentPath)
+	if target, ok := a.symlinks[componentPath]; ok {
+		// We follow only one symlink; so no loops are possible.
+		// The new path could easily point "outside" the archive, but we only compare it to existing tar headers without extracting the archive,
+		// so we don't care.
+		return path.Join(path.Dir(componentPath), target)
+	}
+	return componentPath
+}
+
+// next reads the next tar header from the stream.  Small regular files are buffered, and symlinks are recorded.
+// For other regular files, it returns large = true; their contents can be read from a.tarReader until next is called again.
+// The caller must hold a.mutex, or have exclusive access to a.
+func (a *streamedArchive) next() (string, bool, error) {
+	h, err := a.tarReader.Next()
+	if err != nil {
+		return "", false, err
+	}
+	name := path.Clean(h.Name)
+	switch {
+	case h.Typeflag == tar.TypeSymlink:
+		a.symlinks[name] = h.Linkname
+	case h.FileInfo().Mode().IsRegular():
+		if h.Size > maxStreamedComponentSize || a.bufferedSize+h.Size > maxStreamedBufferSize {
+			return name, true, nil
+		}
+		data, err := iolimits.ReadAtMost(a.tarReader, maxStreamedComponentSize)
+		if err != nil {
+			return "", false, fmt.Errorf("reading tar component %q: %w", name, err)
+		}
+		a.buffered[name] = data
+		a.bufferedSize += int64(len(data))
+	}
+	return name, false, nil
+}
+
+// openComponent returns a ReadCloser for componentPath within the archive.
+// Large components must be requested in the order they appear in the archive, and each must be closed before requesting another one.
+// The caller should call .Close() on the returned stream.
+func (a *streamedArchive) openComponent(componentPath string) (io.ReadCloser, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for {
+		resolved := a.resolve(componentPath)
+		if data, ok := a.buffered[resolved]; ok {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		if a.passed.Contains(resolved) {
+			return nil, fmt.Errorf("tar component %q has already been skipped, and can not be read again from a non-seekable archive", resolved)
+		}
+		if a.reading {
+			return nil, errors.New("Internal error: reading a non-seekable archive while another component is being read")
+		}
+
+		name, large, err := a.next()
+		if err == io.EOF {
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+		if large {
+			if name == resolved {
+				a.reading = true
+				return &streamedComponent{archive: a, name: name}, nil
+			}
+			a.passed.Add(name)
+		}
+	}
+}
+
+// close releases resources associated with a.
+func (a *streamedArchive) close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.buffered = nil
+	err := a.decompressed.Close()
+	if err2 := a.file.Close(); err2 != nil && err == nil {
+		err = err2
+	}
+	return err
+}
+
+// streamedComponent is a large component of a streamedArchive, read directly from the archive’s tar.Reader.
+type streamedComponent struct {
+	archive *streamedArchive
+	name    string
+	closed  bool
+}
+
+func (c *streamedComponent) Read(p []byte) (int, error) {
+	if c.closed {
+		return 0, errors.New("Internal error: read from a closed tar component")
+	}
+	return c.archive.tarReader.Read(p)
+}
+
+func (c *streamedComponent) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.archive.mutex.Lock()
+		c.archive.passed.Add(c.name)
+		c.archive.reading = false
+		c.archive.mutex.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package tarfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTarEntry struct {
+	name string
+	data []byte
+}
+
+// testStreamArchive returns a tar archive containing entries, in that order.
+func testStreamArchive(t *testing.T, entries []testTarEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.data)), Typeflag: tar.TypeReg})
+		require.NoError(t, err)
+		_, err = tw.Write(e.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// testPipe returns the reading end of a pipe, which is fed archive.
+func testPipe(t *testing.T, archive []byte) *os.File {
+	pr, pw, err := os.Pipe()
+	require.NoError(t, err)
+	go func() {
+		_, _ = pw.Write(archive)
+		pw.Close()
+	}()
+	return pr
+}
+
+func TestStreamingReader(t *testing.T) {
+	ctx := context.Background()
+	cache := memory.New()
+
+	largeLayer := bytes.Repeat([]byte{1}, maxStreamedComponentSize+1)
+	largeLayer2 := bytes.Repeat([]byte{2}, maxStreamedComponentSize+1)
+	smallLayer := []byte("small layer")
+	layerDigests := []digest.Digest{digest.FromBytes(largeLayer), digest.FromBytes(smallLayer), digest.FromBytes(largeLayer2)}
+	config, err := json.Marshal(map[string]any{
+		"rootfs": map[string]any{"type": "layers", "diff_ids": layerDigests},
+	})
+	require.NoError(t, err)
+	tarManifest, err := json.Marshal([]ManifestItem{{
+		Config:   "config.json",
+		RepoTags: []string{"example.com/stream:latest"},
+		Layers:   []string{"l1.tar", "l2.tar", "l3.tar"},
+	}})
+	require.NoError(t, err)
+
+	// Metadata first: the archive can be read in a single pass.
+	archive := testStreamArchive(t, []testTarEntry{
+		{manifestFileName, tarManifest},
+		{"config.json", config},
+		{"l1.tar", largeLayer},
+		{"l2.tar", smallLayer},
+		{"l3.tar", largeLayer2},
+	})
+	pipe := testPipe(t, archive)
+	reader, prefix, err := newStreamingReader(pipe, io.NopCloser(pipe))
+	require.NoError(t, err)
+	require.NotNil(t, reader)
+	assert.Nil(t, prefix)
+	src := NewSource(reader, true, "transport name", nil, -1)
+	defer src.Close()
+	assert.False(t, src.HasThreadSafeGetBlob())
+
+	_, _, err = src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	readBlob := func(d digest.Digest) ([]byte, error) {
+		stream, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: d, Size: -1}, cache)
+		if err != nil {
+			return nil, err
+		}
+		defer stream.Close()
+		return io.ReadAll(stream)
+	}
+	data, err := readBlob(layerDigests[0])
+	require.NoError(t, err)
+	assert.Equal(t, largeLayer, data)
+	// Reading l3.tar skips over l2.tar, but that one is small enough to be buffered.
+	data, err = readBlob(layerDigests[2])
+	require.NoError(t, err)
+	assert.Equal(t, largeLayer2, data)
+	data, err = readBlob(layerDigests[1])
+	require.NoError(t, err)
+	assert.Equal(t, smallLayer, data)
+	// Large layers can't be read again.
+	_, err = readBlob(layerDigests[0])
+	assert.Error(t, err)
+
+	// Layers before the metadata: the caller gets the consumed data to fall back to copying the archive.
+	archive = testStreamArchive(t, []testTarEntry{
+		{"l1.tar", largeLayer},
+		{manifestFileName, tarManifest},
+		{"config.json", config},
+	})
+	pipe = testPipe(t, archive)
+	defer pipe.Close()
+	reader, prefix, err = newStreamingReader(pipe, io.NopCloser(pipe))
+	require.NoError(t, err)
+	assert.Nil(t, reader)
+	rest, err := io.ReadAll(pipe)
+	require.NoError(t, err)
+	assert.Equal(t, archive, append(prefix, rest...))
+}
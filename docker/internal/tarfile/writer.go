@@ -14,12 +14,13 @@ import (
 	"time"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/bufferpool"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 // Writer allows creating a (docker save)-formatted tar archive containing one or more images.
@@ -366,7 +367,7 @@ func (w *Writer) sendSymlinkLocked(path string, target string) error {
 	if err != nil {
 		return err
 	}
-	logrus.Debugf("Sending as tar link %s -> %s", path, target)
+	log.Debugf("Sending as tar link %s -> %s", path, target)
 	return w.tar.WriteHeader(hdr)
 }
 
@@ -383,12 +384,12 @@ func (w *Writer) sendFileLocked(path string, expectedSize int64, stream io.Reade
 	if err != nil {
 		return err
 	}
-	logrus.Debugf("Sending as tar file %s", path)
+	log.Debugf("Sending as tar file %s", path)
 	if err := w.tar.WriteHeader(hdr); err != nil {
 		return err
 	}
 	// TODO: This can take quite some time, and should ideally be cancellable using a context.Context.
-	size, err := io.Copy(w.tar, stream)
+	size, err := bufferpool.Copy(w.tar, stream)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,160 @@
+package docker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+)
+
+const (
+	// maxLookasideCacheEntries is an arbitrary limit for the number of signatures kept in lookasideCache.
+	maxLookasideCacheEntries = 1024
+	// maxLookasideCacheSize is an arbitrary limit for the total size of signatures kept in lookasideCache.
+	// Signatures are usually a few kilobytes, so this is unlikely to cause evictions in practice,
+	// but it limits the memory used by the cache regardless of what the servers return.
+	maxLookasideCacheSize = 4 * 1024 * 1024
+)
+
+// lookasideCacheEntry is a signature read from a HTTP lookaside server, with the validators needed to revalidate it.
+type lookasideCacheEntry struct {
+	etag         string // "" if not provided by the server
+	lastModified string // "" if not provided by the server
+	blob         []byte
+}
+
+// lookasideCache contains signatures recently read from HTTP lookaside servers, indexed by URL,
+// so that reading them again only requires a conditional request.
+// Cached signatures are never used without the server confirming they are current.
+var lookasideCache = struct {
+	mutex   sync.Mutex
+	entries map[string]lookasideCacheEntry
+	size    int // The total size of blobs in entries
+}{entries: map[string]lookasideCacheEntry{}}
+
+// lookupLookasideCache returns a cached signature for key, if any.
+func lookupLookasideCache(key string) (lookasideCacheEntry, bool) {
+	lookasideCache.mutex.Lock()
+	defer lookasideCache.mutex.Unlock()
+	entry, ok := lookasideCache.entries[key]
+	return entry, ok
+}
+
+// recordLookasideCache records entry for key, or forgets any entry for key if it can't be revalidated, or if it is too large.
+func recordLookasideCache(key string, entry lookasideCacheEntry) {
+	lookasideCache.mutex.Lock()
+	defer lookasideCache.mutex.Unlock()
+	deleteLookasideCacheEntry(key)
+	if (entry.etag == "" && entry.lastModified == "") || len(entry.blob) > maxLookasideCacheSize/4 {
+		return
+	}
+	for k := range lookasideCache.entries { // Evict arbitrary entries; this is only an optimization.
+		if len(lookasideCache.entries) < maxLookasideCacheEntries && lookasideCache.size+len(entry.blob) <= maxLookasideCacheSize {
+			break
+		}
+		deleteLookasideCacheEntry(k)
+	}
+	lookasideCache.entries[key] = entry
+	lookasideCache.size += len(entry.blob)
+}
+
+// forgetLookasideCache removes any cached signature for key.
+func forgetLookasideCache(key string) {
+	lookasideCache.mutex.Lock()
+	defer lookasideCache.mutex.Unlock()
+	deleteLookasideCacheEntry(key)
+}
+
+// deleteLookasideCacheEntry removes any cached signature for key.
+// The caller must hold lookasideCache.mutex.
+func deleteLookasideCacheEntry(key string) {
+	if entry, ok := lookasideCache.entries[key]; ok {
+		lookasideCache.size -= len(entry.blob)
+		delete(lookasideCache.entries, key)
+	}
+}
+
+// getLookasideHTTPSignature reads a signature blob from a HTTP/HTTPS lookaside sigURL using client,
+// and returns (blob, false, nil).
+// If it successfully determines that the signature does not exist, returns (nil, true, nil).
+// Previously read signatures are revalidated using a conditional request, and the signature can be transferred gzip-compressed.
+// NOTE: Keep this in sync with docs/signature-protocols.md!
+func getLookasideHTTPSignature(ctx context.Context, client *http.Client, sigURL *url.URL) ([]byte, bool, error) {
+	key := sigURL.String()
+	log.DebugfContext(ctx, "GET %s", sigURL.Redacted())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	// Setting Accept-Encoding disables the transparent decompression in net/http; we decompress ourselves,
+	// so that the size limit applies to the decompressed data.
+	req.Header.Set("Accept-Encoding", "gzip")
+	cached, haveCached := lookupLookasideCache(key)
+	if haveCached {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		if !haveCached { // Should never happen; we did not send any validators.
+			return nil, false, fmt.Errorf("reading signature from %s: unexpected status %d (%s)", sigURL.Redacted(), res.StatusCode, http.StatusText(res.StatusCode))
+		}
+		log.DebugfContext(ctx, "... not modified, using the cached signature")
+		return bytes.Clone(cached.blob), false, nil
+	case http.StatusNotFound:
+		log.DebugfContext(ctx, "... got status 404, as expected = end of signatures")
+		forgetLookasideCache(key)
+		return nil, true, nil
+	case http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("reading signature from %s: status %d (%s)", sigURL.Redacted(), res.StatusCode, http.StatusText(res.StatusCode))
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if mimeType := simplifyContentType(contentType); mimeType == "text/html" {
+		log.WarnfContext(ctx, "Signature %q has Content-Type %q, unexpected for a signature", sigURL.Redacted(), contentType)
+		// Don’t immediately fail; the lookaside spec does not place any requirements on Content-Type.
+		// If the content really is HTML, it’s going to fail in signature.FromBlob.
+	}
+
+	var body io.Reader = res.Body
+	switch encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(res.Body)
+		if err != nil {
+			return nil, false, fmt.Errorf("decompressing signature from %s: %w", sigURL.Redacted(), err)
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, false, fmt.Errorf("reading signature from %s: unsupported Content-Encoding %q", sigURL.Redacted(), encoding)
+	}
+	sigBlob, err := iolimits.ReadAtMost(body, iolimits.MaxSignatureBodySize)
+	if err != nil {
+		return nil, false, err
+	}
+	recordLookasideCache(key, lookasideCacheEntry{
+		etag:         res.Header.Get("ETag"),
+		lastModified: res.Header.Get("Last-Modified"),
+		blob:         sigBlob,
+	})
+	return sigBlob, false, nil
+}
@@ -0,0 +1,220 @@
+package docker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lookasideServer is a HTTP lookaside server serving count signatures of a single image,
+// which supports conditional requests and gzip compression.
+type lookasideServer struct {
+	mutex        sync.Mutex
+	count        int
+	requests     map[string]int // Requested signature index → number of requests
+	notModified  int
+	gzipResponse int
+}
+
+func (ls *lookasideServer) signatureBlob(index int) []byte {
+	return append([]byte{0xA3}, []byte("signature-"+strconv.Itoa(index))...)
+}
+
+func (ls *lookasideServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	indexString, ok := strings.CutPrefix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], "signature-")
+	index, err := strconv.Atoi(indexString)
+	if !ok || err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	ls.requests[indexString]++
+	if index > ls.count {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	etag := `"` + indexString + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		ls.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	blob := ls.signatureBlob(index)
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		ls.gzipResponse++
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		_, _ = gz.Write(blob)
+		_ = gz.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		blob = compressed.Bytes()
+	}
+	_, _ = w.Write(blob)
+}
+
+func TestGetSignaturesFromLookasideHTTP(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 5, 20} {
+		ls := &lookasideServer{count: count, requests: map[string]int{}}
+		server := httptest.NewServer(ls)
+		t.Cleanup(server.Close)
+		baseURL, err := url.Parse(server.URL + "/lookaside/" + strconv.Itoa(count))
+		require.NoError(t, err)
+		src := &dockerImageSource{c: &dockerClient{signatureBase: baseURL, client: server.Client()}}
+		manifestDigest := digest.FromString("manifest")
+
+		sigs, err := src.getSignaturesFromLookaside(context.Background(), &manifestDigest)
+		require.NoError(t, err, count)
+		require.Len(t, sigs, count)
+		for i, sig := range sigs {
+			simple, ok := sig.(signature.SimpleSigning)
+			require.True(t, ok)
+			assert.Equal(t, ls.signatureBlob(i+1), simple.UntrustedSignature())
+		}
+		assert.Equal(t, count, ls.gzipResponse)
+		for i := 1; i <= count+1; i++ {
+			assert.Equal(t, 1, ls.requests[strconv.Itoa(i)], "count %d, signature %d", count, i)
+		}
+
+		// Reading the signatures again only revalidates them.
+		sigs2, err := src.getSignaturesFromLookaside(context.Background(), &manifestDigest)
+		require.NoError(t, err)
+		assert.Equal(t, sigs, sigs2)
+		assert.Equal(t, count, ls.gzipResponse)
+		assert.Equal(t, count, ls.notModified)
+	}
+}
+
+func TestGetLookasideHTTPSignature(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		handler  http.HandlerFunc
+		missing  bool
+		blob     []byte
+		errorRef string
+	}{
+		{
+			name:    "missing",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			missing: true,
+		},
+		{
+			name:     "server error",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			errorRef: "status 500",
+		},
+		{
+			name: "unexpected 304",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotModified)
+			},
+			errorRef: "unexpected status 304",
+		},
+		{
+			name: "unsupported encoding",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				_, _ = w.Write([]byte("data"))
+			},
+			errorRef: "unsupported Content-Encoding",
+		},
+		{
+			name: "invalid gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				_, _ = w.Write([]byte("not gzip"))
+			},
+			errorRef: "decompressing signature",
+		},
+		{
+			name: "uncompressed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("data"))
+			},
+			blob: []byte("data"),
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(c.handler)
+			defer server.Close()
+			sigURL, err := url.Parse(server.URL + "/signature-1")
+			require.NoError(t, err)
+			blob, missing, err := getLookasideHTTPSignature(context.Background(), server.Client(), sigURL)
+			if c.errorRef != "" {
+				assert.ErrorContains(t, err, c.errorRef)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.missing, missing)
+			assert.Equal(t, c.blob, blob)
+		})
+	}
+}
+
+func TestRecordLookasideCache(t *testing.T) {
+	lookasideCache.mutex.Lock()
+	savedEntries, savedSize := lookasideCache.entries, lookasideCache.size
+	lookasideCache.entries, lookasideCache.size = map[string]lookasideCacheEntry{}, 0
+	lookasideCache.mutex.Unlock()
+	defer func() {
+		lookasideCache.mutex.Lock()
+		lookasideCache.entries, lookasideCache.size = savedEntries, savedSize
+		lookasideCache.mutex.Unlock()
+	}()
+	assertCacheConsistent := func() {
+		size := 0
+		for _, e := range lookasideCache.entries {
+			size += len(e.blob)
+		}
+		assert.Equal(t, size, lookasideCache.size)
+		assert.LessOrEqual(t, lookasideCache.size, maxLookasideCacheSize)
+		assert.LessOrEqual(t, len(lookasideCache.entries), maxLookasideCacheEntries)
+	}
+
+	// Entries without validators are not recorded, and replace older entries
+	recordLookasideCache("a", lookasideCacheEntry{etag: "1", blob: []byte("abc")})
+	_, ok := lookupLookasideCache("a")
+	assert.True(t, ok)
+	recordLookasideCache("a", lookasideCacheEntry{blob: []byte("abc")})
+	_, ok = lookupLookasideCache("a")
+	assert.False(t, ok)
+	assertCacheConsistent()
+
+	// Too large entries are not recorded
+	recordLookasideCache("large", lookasideCacheEntry{etag: "1", blob: make([]byte, maxLookasideCacheSize/4+1)})
+	_, ok = lookupLookasideCache("large")
+	assert.False(t, ok)
+	assertCacheConsistent()
+
+	// The total size is limited
+	for i := 0; i < 10; i++ {
+		recordLookasideCache(strconv.Itoa(i), lookasideCacheEntry{etag: "1", blob: make([]byte, maxLookasideCacheSize/4)})
+		assertCacheConsistent()
+	}
+	assert.Len(t, lookasideCache.entries, 4)
+	_, ok = lookupLookasideCache("9")
+	assert.True(t, ok)
+
+	// The number of entries is limited
+	for i := 0; i < maxLookasideCacheEntries+10; i++ {
+		recordLookasideCache("small-"+strconv.Itoa(i), lookasideCacheEntry{etag: "1", blob: []byte("x")})
+	}
+	assertCacheConsistent()
+	assert.Len(t, lookasideCache.entries, maxLookasideCacheEntries)
+
+	forgetLookasideCache("small-" + strconv.Itoa(maxLookasideCacheEntries+9))
+	assertCacheConsistent()
+}
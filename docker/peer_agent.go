@@ -0,0 +1,98 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// peerAgent is a client for a peer-to-peer distribution agent which serves blobs using the registry API,
+// identifying the origin registry using the "ns" query parameter (as containerd mirrors, and Spegel, do)
+// or the X-Dragonfly-Registry header (as Dragonfly does).
+type peerAgent struct {
+	baseURL *url.URL
+	client  *http.Client
+}
+
+// newPeerAgent returns a peerAgent for sys, or nil if no agent is configured.
+func newPeerAgent(sys *types.SystemContext) (*peerAgent, error) {
+	if sys == nil || sys.DockerPeerBlobAgentURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(sys.DockerPeerBlobAgentURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing peer blob agent URL %q: %w", sys.DockerPeerBlobAgentURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid peer blob agent URL %q, expected an http:// or https:// URL", sys.DockerPeerBlobAgentURL)
+	}
+	return &peerAgent{
+		baseURL: u,
+		client:  &http.Client{Transport: newRegistryAccessTransport(sys, tlsclientconfig.NewTransport())},
+	}, nil
+}
+
+// getBlob tries to fetch the blob described by info in ref from the agent.
+// It returns (nil, -1) if the agent does not provide the blob; the caller should then fall back to the registry.
+// The returned stream fails with an error at the end if the data does not match info.Digest.
+func (a *peerAgent) getBlob(ctx context.Context, ref dockerReference, info types.BlobInfo) (io.ReadCloser, int64) {
+	u := *a.baseURL
+	u.Path = u.Path + fmt.Sprintf(blobsPath, reference.Path(ref.ref), info.Digest.String())
+	registry := reference.Domain(ref.ref)
+	u.RawQuery = url.Values{"ns": {registry}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		log.DebugfContext(ctx, "Not using peer blob agent: %v", err)
+		return nil, -1
+	}
+	req.Header.Set("X-Dragonfly-Registry", "https://"+registry)
+	log.DebugfContext(ctx, "Downloading %s from peer blob agent", u.Redacted())
+	res, err := a.client.Do(req)
+	if err != nil {
+		log.DebugfContext(ctx, "Peer blob agent failed, falling back to the registry: %v", err)
+		return nil, -1
+	}
+	if res.StatusCode != http.StatusOK {
+		log.DebugfContext(ctx, "Peer blob agent returned status %s, falling back to the registry", res.Status)
+		res.Body.Close()
+		return nil, -1
+	}
+	verifier := info.Digest.Verifier()
+	return &verifyingReadCloser{
+		source:   res.Body,
+		tee:      io.TeeReader(res.Body, verifier),
+		verifier: verifier,
+		digest:   info.Digest,
+		origin:   "a peer blob agent",
+	}, getBlobSize(res)
+}
+
+// verifyingReadCloser reads from source, and fails at EOF if the data does not match digest.
+// Peers and external URLs are not trusted to the same degree as the registry, so we don’t rely on callers to verify the data.
+type verifyingReadCloser struct {
+	source   io.ReadCloser
+	tee      io.Reader
+	verifier digest.Verifier
+	digest   digest.Digest
+	origin   string // A description of where the data comes from, for error messages
+}
+
+func (r *verifyingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.tee.Read(p)
+	if err == io.EOF && !r.verifier.Verified() {
+		return n, fmt.Errorf("blob %s received from %s does not match its digest", r.digest, r.origin)
+	}
+	return n, err
+}
+
+func (r *verifyingReadCloser) Close() error {
+	return r.source.Close()
+}
@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPeerAgent(t *testing.T) {
+	for _, sys := range []*types.SystemContext{nil, {}} {
+		a, err := newPeerAgent(sys)
+		require.NoError(t, err)
+		assert.Nil(t, a)
+	}
+
+	a, err := newPeerAgent(&types.SystemContext{DockerPeerBlobAgentURL: "http://localhost:30020"})
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	assert.Equal(t, "http://localhost:30020", a.baseURL.String())
+
+	for _, url := range []string{
+		"localhost:30020",
+		"unix:///run/agent.sock",
+		"http://",
+		"http://[",
+	} {
+		_, err := newPeerAgent(&types.SystemContext{DockerPeerBlobAgentURL: url})
+		assert.Error(t, err, url)
+	}
+}
+
+func TestDockerClientGetBlobFromPeerAgent(t *testing.T) {
+	const blob = "peer-to-peer blob contents"
+	blobDigest := digest.FromString(blob)
+
+	var registryRequests, agentRequests int
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/ns/repo/blobs/" + blobDigest.String():
+			registryRequests++
+			_, _ = io.WriteString(w, blob)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	registryHost := strings.TrimPrefix(registry.URL, "http://")
+
+	var agentBody string
+	agentStatus := http.StatusOK
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agentRequests++
+		assert.Equal(t, "/v2/ns/repo/blobs/"+blobDigest.String(), r.URL.Path)
+		assert.Equal(t, registryHost, r.URL.Query().Get("ns"))
+		assert.Equal(t, "https://"+registryHost, r.Header.Get("X-Dragonfly-Registry"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.WriteHeader(agentStatus)
+		_, _ = io.WriteString(w, agentBody)
+	}))
+	defer agent.Close()
+
+	ref, err := ParseReference("//" + registryHost + "/ns/repo:tag")
+	require.NoError(t, err)
+	dockerRef, ok := ref.(dockerReference)
+	require.True(t, ok)
+
+	for _, c := range []struct {
+		name                    string
+		agentURL                string
+		agentStatus             int
+		agentBody               string
+		expectedAgentRequests   int
+		expectedRegistryRequest int
+		expectedError           bool
+	}{
+		{"agent serves blob", agent.URL, http.StatusOK, blob, 1, 0, false},
+		{"agent does not have blob", agent.URL, http.StatusNotFound, "", 1, 1, false},
+		{"agent unreachable", "http://127.0.0.1:1", 0, "", 0, 1, false},
+		{"agent returns corrupted blob", agent.URL, http.StatusOK, blob + "!", 1, 0, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			agentRequests, registryRequests = 0, 0
+			agentStatus, agentBody = c.agentStatus, c.agentBody
+
+			sys := &types.SystemContext{
+				DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+				DockerPeerBlobAgentURL:      c.agentURL,
+			}
+			client, err := newDockerClient(sys, registryHost, registryHost)
+			require.NoError(t, err)
+			rc, _, err := client.getBlob(context.Background(), dockerRef, types.BlobInfo{Digest: blobDigest, Size: -1}, memory.New())
+			require.NoError(t, err)
+			defer rc.Close()
+			data, err := io.ReadAll(rc)
+			if c.expectedError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, blob, string(data))
+			}
+			assert.Equal(t, c.expectedAgentRequests, agentRequests)
+			assert.Equal(t, c.expectedRegistryRequest, registryRequests)
+		})
+	}
+}
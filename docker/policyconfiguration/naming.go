@@ -8,10 +8,42 @@ import (
 	"github.com/containers/image/v5/docker/reference"
 )
 
+// Options modifies the policy configuration identities and namespaces of references;
+// the zero value uses the default values, as used by DockerReferenceIdentity and DockerReferenceNamespaces.
+type Options struct {
+	// TagSeparator separates the repository name from a tag; the default is ":".
+	TagSeparator string
+	// DigestSeparator separates the repository name from a digest; the default is "@".
+	DigestSeparator string
+	// OmitTagAndDigestNamespaces omits the tag-level and digest-level namespaces (see DockerReferenceNamespaces).
+	OmitTagAndDigestNamespaces bool
+}
+
+// AnyTagOrDigest is the value which follows TagSeparator or DigestSeparator in tag-level and digest-level namespaces.
+const AnyTagOrDigest = "*"
+
+// separators returns the tag and digest separators to use.
+func (o *Options) separators() (string, string) {
+	tagSeparator, digestSeparator := ":", "@"
+	if o.TagSeparator != "" {
+		tagSeparator = o.TagSeparator
+	}
+	if o.DigestSeparator != "" {
+		digestSeparator = o.DigestSeparator
+	}
+	return tagSeparator, digestSeparator
+}
+
 // DockerReferenceIdentity returns a string representation of the reference, suitable for policy lookup,
 // as a backend for ImageReference.PolicyConfigurationIdentity.
 // The reference must satisfy !reference.IsNameOnly().
 func DockerReferenceIdentity(ref reference.Named) (string, error) {
+	return DockerReferenceIdentityWithOptions(ref, Options{})
+}
+
+// DockerReferenceIdentityWithOptions is DockerReferenceIdentity, modified by options.
+func DockerReferenceIdentityWithOptions(ref reference.Named, options Options) (string, error) {
+	tagSeparator, digestSeparator := options.separators()
 	res := ref.Name()
 	tagged, isTagged := ref.(reference.NamedTagged)
 	digested, isDigested := ref.(reference.Canonical)
@@ -21,9 +53,9 @@ func DockerReferenceIdentity(ref reference.Named) (string, error) {
 	case !isTagged && !isDigested: // This should not happen, the caller is expected to ensure !reference.IsNameOnly()
 		return "", fmt.Errorf("Internal inconsistency: Docker reference %s with neither a tag nor a digest", reference.FamiliarString(ref))
 	case isTagged:
-		res = res + ":" + tagged.Tag()
+		res = res + tagSeparator + tagged.Tag()
 	case isDigested:
-		res = res + "@" + digested.Digest().String()
+		res = res + digestSeparator + digested.Digest().String()
 	default: // Coverage: The above was supposed to be exhaustive.
 		return "", errors.New("Internal inconsistency, unexpected default branch")
 	}
@@ -33,7 +65,30 @@ func DockerReferenceIdentity(ref reference.Named) (string, error) {
 // DockerReferenceNamespaces returns a list of other policy configuration namespaces to search,
 // as a backend for ImageReference.PolicyConfigurationIdentity.
 // The reference must satisfy !reference.IsNameOnly().
+//
+// The first namespace matches all references in the repository with a tag ("repo:*")
+// or all references with a digest ("repo@*"), as appropriate for ref; so, e.g., a policy can apply
+// a requirement to all tags of a repository, and a different one to "repo:latest".
 func DockerReferenceNamespaces(ref reference.Named) []string {
+	return DockerReferenceNamespacesWithOptions(ref, Options{})
+}
+
+// DockerReferenceNamespacesWithOptions is DockerReferenceNamespaces, modified by options.
+func DockerReferenceNamespacesWithOptions(ref reference.Named, options Options) []string {
+	res := []string{}
+	if !options.OmitTagAndDigestNamespaces {
+		tagSeparator, digestSeparator := options.separators()
+		_, isTagged := ref.(reference.NamedTagged)
+		_, isDigested := ref.(reference.Canonical)
+		switch {
+		case isTagged && isDigested: // DockerReferenceIdentity refuses such references; don’t match either namespace.
+		case isTagged:
+			res = append(res, ref.Name()+tagSeparator+AnyTagOrDigest)
+		case isDigested:
+			res = append(res, ref.Name()+digestSeparator+AnyTagOrDigest)
+		}
+	}
+
 	// Look for a match of the repository, and then of the possible parent
 	// namespaces. Note that this only happens on the expanded host names
 	// and repository names, i.e. "busybox" is looked up as "docker.io/library/busybox",
@@ -42,7 +97,6 @@ func DockerReferenceNamespaces(ref reference.Named) []string {
 	//
 	// ref.Name() == ref.Domain() + "/" + ref.Path(), so the last
 	// iteration matches the host name (for any namespace).
-	res := []string{}
 	name := ref.Name()
 	for {
 		res = append(res, name)
@@ -32,9 +32,9 @@ func TestDockerReference(t *testing.T) {
 		"repo":                                 {"docker.io/library/repo", "docker.io/library", "docker.io", "*.io"},
 		"yet.another.example.com:8443/ns/repo": {"yet.another.example.com:8443/ns/repo", "yet.another.example.com:8443/ns", "yet.another.example.com:8443", "*.another.example.com", "*.example.com", "*.com"},
 	} {
-		for inputSuffix, mappedSuffix := range map[string]string{
-			":tag":       ":tag",
-			sha256Digest: sha256Digest,
+		for inputSuffix, c := range map[string]struct{ mappedSuffix, anySuffix string }{
+			":tag":       {":tag", ":*"},
+			sha256Digest: {sha256Digest, "@*"},
 		} {
 			fullInput := inputName + inputSuffix
 			ref, err := reference.ParseNormalizedNamed(fullInput)
@@ -42,11 +42,14 @@ func TestDockerReference(t *testing.T) {
 
 			identity, err := DockerReferenceIdentity(ref)
 			require.NoError(t, err, fullInput)
-			assert.Equal(t, expectedNS[0]+mappedSuffix, identity, fullInput)
+			assert.Equal(t, expectedNS[0]+c.mappedSuffix, identity, fullInput)
 
 			ns := DockerReferenceNamespaces(ref)
 			require.NotNil(t, ns, fullInput)
-			require.Len(t, ns, len(expectedNS), fullInput)
+			require.Len(t, ns, len(expectedNS)+1, fullInput)
+			// The tag-level or digest-level namespace comes first
+			assert.Equal(t, expectedNS[0]+c.anySuffix, ns[0], fullInput)
+			ns = ns[1:]
 			moreSpecific := identity
 			for i := range expectedNS {
 				assert.Equal(t, ns[i], expectedNS[i], fmt.Sprintf("%s item %d", fullInput, i))
@@ -84,3 +87,30 @@ func TestDockerReferenceIdentity(t *testing.T) {
 	assert.Equal(t, "", id)
 	assert.Error(t, err)
 }
+
+func TestDockerReferenceWithOptions(t *testing.T) {
+	tagged, err := reference.ParseNormalizedNamed("example.com/ns/repo:tag")
+	require.NoError(t, err)
+	digested, err := reference.ParseNormalizedNamed("example.com/ns/repo@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	repoNS := []string{"example.com/ns/repo", "example.com/ns", "example.com", "*.com"}
+
+	options := Options{TagSeparator: "#", DigestSeparator: "!"}
+	id, err := DockerReferenceIdentityWithOptions(tagged, options)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/ns/repo#tag", id)
+	assert.Equal(t, append([]string{"example.com/ns/repo#*"}, repoNS...), DockerReferenceNamespacesWithOptions(tagged, options))
+	id, err = DockerReferenceIdentityWithOptions(digested, options)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/ns/repo!sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", id)
+	assert.Equal(t, append([]string{"example.com/ns/repo!*"}, repoNS...), DockerReferenceNamespacesWithOptions(digested, options))
+
+	options = Options{OmitTagAndDigestNamespaces: true}
+	assert.Equal(t, repoNS, DockerReferenceNamespacesWithOptions(tagged, options))
+	assert.Equal(t, repoNS, DockerReferenceNamespacesWithOptions(digested, options))
+
+	// Name-only references have no tag-level or digest-level namespace
+	nameOnly, err := reference.ParseNormalizedNamed("example.com/ns/repo")
+	require.NoError(t, err)
+	assert.Equal(t, repoNS, DockerReferenceNamespaces(nameOnly))
+}
@@ -10,12 +10,12 @@ import (
 	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/rootless"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/fileutils"
 	"github.com/containers/storage/pkg/homedir"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
@@ -52,6 +52,10 @@ type registryNamespace struct {
 	SigStore               string `yaml:"sigstore"`          // For compatibility, deprecated in favor of Lookaside.
 	SigStoreStaging        string `yaml:"sigstore-staging"`  // For compatibility, deprecated in favor of LookasideStaging.
 	UseSigstoreAttachments *bool  `yaml:"use-sigstore-attachments,omitempty"`
+	// If set, Sigstore bundles attached to images as OCI referrers are read along with the image.
+	UseSigstoreBundleReferrers *bool `yaml:"use-sigstore-bundle-referrers,omitempty"`
+	// If set, sigstore signatures are written to the lookaside location in addition to sigstore attachments (if those are enabled).
+	WriteSigstoreToLookaside *bool `yaml:"write-sigstore-to-lookaside,omitempty"`
 }
 
 // lookasideStorageBase is an "opaque" type representing a lookaside Docker signature storage.
@@ -78,7 +82,7 @@ func SignatureStorageBaseURL(sys *types.SystemContext, ref types.ImageReference,
 // loadRegistryConfiguration returns a registryConfiguration appropriate for sys.
 func loadRegistryConfiguration(sys *types.SystemContext) (*registryConfiguration, error) {
 	dirPath := registriesDirPath(sys)
-	logrus.Debugf(`Using registries.d directory %s`, dirPath)
+	log.Debugf(`Using registries.d directory %s`, dirPath)
 	return loadAndMergeConfig(dirPath)
 }
 
@@ -174,7 +178,7 @@ func (config *registryConfiguration) lookasideStorageBaseURL(dr dockerReference,
 	} else {
 		// returns default directory if no lookaside specified in configuration file
 		baseURL = builtinDefaultLookasideStorageDir(rootless.GetRootlessEUID())
-		logrus.Debugf(" No signature storage configuration found for %s, using built-in default %s", dr.PolicyConfigurationIdentity(), baseURL.Redacted())
+		log.Debugf(" No signature storage configuration found for %s, using built-in default %s", dr.PolicyConfigurationIdentity(), baseURL.Redacted())
 	}
 	// NOTE: Keep this in sync with docs/signature-protocols.md!
 	// FIXME? Restrict to explicitly supported schemes?
@@ -201,7 +205,7 @@ func (config *registryConfiguration) signatureTopLevel(ref dockerReference, writ
 		// Look for a full match.
 		identity := ref.PolicyConfigurationIdentity()
 		if ns, ok := config.Docker[identity]; ok {
-			logrus.Debugf(` Lookaside configuration: using "docker" namespace %s`, identity)
+			log.Debugf(` Lookaside configuration: using "docker" namespace %s`, identity)
 			if ret := ns.signatureTopLevel(write); ret != "" {
 				return ret
 			}
@@ -210,7 +214,7 @@ func (config *registryConfiguration) signatureTopLevel(ref dockerReference, writ
 		// Look for a match of the possible parent namespaces.
 		for _, name := range ref.PolicyConfigurationNamespaces() {
 			if ns, ok := config.Docker[name]; ok {
-				logrus.Debugf(` Lookaside configuration: using "docker" namespace %s`, name)
+				log.Debugf(` Lookaside configuration: using "docker" namespace %s`, name)
 				if ret := ns.signatureTopLevel(write); ret != "" {
 					return ret
 				}
@@ -219,7 +223,7 @@ func (config *registryConfiguration) signatureTopLevel(ref dockerReference, writ
 	}
 	// Look for a default location
 	if config.DefaultDocker != nil {
-		logrus.Debugf(` Lookaside configuration: using "default-docker" configuration`)
+		log.Debugf(` Lookaside configuration: using "default-docker" configuration`)
 		if ret := config.DefaultDocker.signatureTopLevel(write); ret != "" {
 			return ret
 		}
@@ -230,31 +234,53 @@ func (config *registryConfiguration) signatureTopLevel(ref dockerReference, writ
 // config.useSigstoreAttachments returns whether we should look for and write sigstore attachments.
 // for ref.
 func (config *registryConfiguration) useSigstoreAttachments(ref dockerReference) bool {
+	return config.namespaceBoolOption(ref, "Sigstore attachments", func(ns *registryNamespace) *bool {
+		return ns.UseSigstoreAttachments
+	})
+}
+
+// config.useSigstoreBundleReferrers returns whether we should look for Sigstore bundles attached as OCI referrers for ref.
+func (config *registryConfiguration) useSigstoreBundleReferrers(ref dockerReference) bool {
+	return config.namespaceBoolOption(ref, "Sigstore bundle referrers", func(ns *registryNamespace) *bool {
+		return ns.UseSigstoreBundleReferrers
+	})
+}
+
+// config.writeSigstoreToLookaside returns whether sigstore signatures written for ref should be also stored in the lookaside location.
+func (config *registryConfiguration) writeSigstoreToLookaside(ref dockerReference) bool {
+	return config.namespaceBoolOption(ref, "Writing sigstore signatures to lookaside", func(ns *registryNamespace) *bool {
+		return ns.WriteSigstoreToLookaside
+	})
+}
+
+// config.namespaceBoolOption returns the value of a boolean option, returned by field, from the most specific namespace for ref
+// which sets it, or false if no namespace does. description is used for logging.
+func (config *registryConfiguration) namespaceBoolOption(ref dockerReference, description string, field func(ns *registryNamespace) *bool) bool {
 	if config.Docker != nil {
 		// Look for a full match.
 		identity := ref.PolicyConfigurationIdentity()
 		if ns, ok := config.Docker[identity]; ok {
-			logrus.Debugf(` Sigstore attachments: using "docker" namespace %s`, identity)
-			if ns.UseSigstoreAttachments != nil {
-				return *ns.UseSigstoreAttachments
+			log.Debugf(` %s: using "docker" namespace %s`, description, identity)
+			if v := field(&ns); v != nil {
+				return *v
 			}
 		}
 
 		// Look for a match of the possible parent namespaces.
 		for _, name := range ref.PolicyConfigurationNamespaces() {
 			if ns, ok := config.Docker[name]; ok {
-				logrus.Debugf(` Sigstore attachments: using "docker" namespace %s`, name)
-				if ns.UseSigstoreAttachments != nil {
-					return *ns.UseSigstoreAttachments
+				log.Debugf(` %s: using "docker" namespace %s`, description, name)
+				if v := field(&ns); v != nil {
+					return *v
 				}
 			}
 		}
 	}
 	// Look for a default location
 	if config.DefaultDocker != nil {
-		logrus.Debugf(` Sigstore attachments: using "default-docker" configuration`)
-		if config.DefaultDocker.UseSigstoreAttachments != nil {
-			return *config.DefaultDocker.UseSigstoreAttachments
+		log.Debugf(` %s: using "default-docker" configuration`, description)
+		if v := field(config.DefaultDocker); v != nil {
+			return *v
 		}
 	}
 	return false
@@ -265,20 +291,20 @@ func (config *registryConfiguration) useSigstoreAttachments(ref dockerReference)
 func (ns registryNamespace) signatureTopLevel(write bool) string {
 	if write {
 		if ns.LookasideStaging != "" {
-			logrus.Debugf(`  Using "lookaside-staging" %s`, ns.LookasideStaging)
+			log.Debugf(`  Using "lookaside-staging" %s`, ns.LookasideStaging)
 			return ns.LookasideStaging
 		}
 		if ns.SigStoreStaging != "" {
-			logrus.Debugf(`  Using "sigstore-staging" %s`, ns.SigStoreStaging)
+			log.Debugf(`  Using "sigstore-staging" %s`, ns.SigStoreStaging)
 			return ns.SigStoreStaging
 		}
 	}
 	if ns.Lookaside != "" {
-		logrus.Debugf(`  Using "lookaside" %s`, ns.Lookaside)
+		log.Debugf(`  Using "lookaside" %s`, ns.Lookaside)
 		return ns.Lookaside
 	}
 	if ns.SigStore != "" {
-		logrus.Debugf(`  Using "sigstore" %s`, ns.SigStore)
+		log.Debugf(`  Using "sigstore" %s`, ns.SigStore)
 		return ns.SigStore
 	}
 	return ""
@@ -271,6 +271,34 @@ func TestRegistryConfigurationSignatureTopLevel(t *testing.T) {
 	assert.Equal(t, "", res)
 }
 
+func TestRegistryConfigurationNamespaceBoolOptions(t *testing.T) {
+	yes, no := true, false
+	config := registryConfiguration{
+		DefaultDocker: &registryNamespace{UseSigstoreAttachments: &yes},
+		Docker: map[string]registryNamespace{
+			"example.com":          {UseSigstoreAttachments: &no, WriteSigstoreToLookaside: &yes},
+			"example.com/ns":       {Lookaside: "https://lookaside.example.com"}, // Options not set, inherited from parent namespaces
+			"example.com/ns/other": {WriteSigstoreToLookaside: &no, UseSigstoreBundleReferrers: &yes},
+		},
+	}
+	for _, c := range []struct {
+		input                             string
+		attachments, toLookaside, bundles bool
+	}{
+		{"unknown.example.com/busybox", true, false, false},
+		{"example.com/busybox", false, true, false},
+		{"example.com/ns/repo", false, true, false},
+		{"example.com/ns/other", false, false, true},
+	} {
+		dr := dockerRefFromString(t, "//"+c.input)
+		assert.Equal(t, c.attachments, config.useSigstoreAttachments(dr), c.input)
+		assert.Equal(t, c.toLookaside, config.writeSigstoreToLookaside(dr), c.input)
+		assert.Equal(t, c.bundles, config.useSigstoreBundleReferrers(dr), c.input)
+	}
+
+	assert.False(t, (&registryConfiguration{}).writeSigstoreToLookaside(dockerRefFromString(t, "//example.com/busybox")))
+}
+
 func TestRegistryNamespaceSignatureTopLevel(t *testing.T) {
 	for _, c := range []struct {
 		ns         registryNamespace
@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// checkRegistryAccess returns an ErrRegistryNotAllowed if sys does not permit contacting registry (a host[:port] value).
+func checkRegistryAccess(sys *types.SystemContext, registry string) error {
+	if sys == nil || (len(sys.DockerRegistryAllowList) == 0 && len(sys.DockerRegistryDenyList) == 0) {
+		return nil
+	}
+	host := registry
+	if hostName, port, err := net.SplitHostPort(registry); err == nil && hostName != "" {
+		// Docker Hub is accessed using several host names; users refer to it as dockerHostname.
+		if hostName == dockerRegistry || hostName == dockerV1Hostname {
+			host = net.JoinHostPort(dockerHostname, port)
+		}
+	} else if registry == dockerRegistry || registry == dockerV1Hostname {
+		host = dockerHostname
+	}
+
+	for _, pattern := range sys.DockerRegistryDenyList {
+		if registryHostMatches(pattern, host) {
+			return ErrRegistryNotAllowed{Registry: registry, Denied: true}
+		}
+	}
+	if len(sys.DockerRegistryAllowList) != 0 {
+		for _, pattern := range sys.DockerRegistryAllowList {
+			if registryHostMatches(pattern, host) {
+				return nil
+			}
+		}
+		return ErrRegistryNotAllowed{Registry: registry, Denied: false}
+	}
+	return nil
+}
+
+// registryAccessTransport is a http.RoundTripper which refuses requests to hosts not permitted by sys,
+// as checked by checkRegistryAccess. Wrapping the transport of a http.Client covers every request it makes,
+// including redirects, authentication requests, and requests to hosts other than the registry.
+type registryAccessTransport struct {
+	sys       *types.SystemContext
+	transport http.RoundTripper
+}
+
+// newRegistryAccessTransport returns transport, wrapped to enforce the registry allow and deny lists in sys, if any.
+func newRegistryAccessTransport(sys *types.SystemContext, transport http.RoundTripper) http.RoundTripper {
+	if sys == nil || (len(sys.DockerRegistryAllowList) == 0 && len(sys.DockerRegistryDenyList) == 0) {
+		return transport
+	}
+	return &registryAccessTransport{sys: sys, transport: transport}
+}
+
+func (t *registryAccessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkRegistryAccess(t.sys, req.URL.Host); err != nil {
+		if req.Body != nil { // RoundTrip must always close the body.
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// CloseIdleConnections closes idle connections of the wrapped transport, as used by http.Client.CloseIdleConnections.
+func (t *registryAccessTransport) CloseIdleConnections() {
+	if closer, ok := t.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// registryHostMatches returns true if host, a host[:port] value, matches pattern,
+// as documented for types.SystemContext.DockerRegistryAllowList.
+func registryHostMatches(pattern, host string) bool {
+	if pattern == "" {
+		return false
+	}
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+	if _, _, err := net.SplitHostPort(pattern); err != nil { // The pattern has no port, so match all ports.
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "["), "]")
+		if hostName, _, err := net.SplitHostPort(host); err == nil {
+			host = hostName
+		} else {
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		}
+	}
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+	return host == pattern
+}
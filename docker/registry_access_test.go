@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryHostMatches(t *testing.T) {
+	for _, c := range []struct {
+		pattern, host string
+		expected      bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "EXAMPLE.com", true},
+		{"example.com", "example.com:5000", true},
+		{"example.com:5000", "example.com:5000", true},
+		{"example.com:5000", "example.com", false},
+		{"example.com:5000", "example.com:5001", false},
+		{"example.com", "example.org", false},
+		{"example.com", "sub.example.com", false},
+		{"*.example.com", "sub.example.com", true},
+		{"*.example.com", "a.sub.example.com:5000", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+		{"*.example.com:5000", "sub.example.com:5000", true},
+		{"*.example.com:5000", "sub.example.com", false},
+		{"[::1]", "[::1]:5000", true},
+		{"[::1]:5000", "[::1]:5000", true},
+		{"", "example.com", false},
+	} {
+		assert.Equal(t, c.expected, registryHostMatches(c.pattern, c.host), "%q %q", c.pattern, c.host)
+	}
+}
+
+func TestCheckRegistryAccess(t *testing.T) {
+	assert.NoError(t, checkRegistryAccess(nil, "example.com"))
+	assert.NoError(t, checkRegistryAccess(&types.SystemContext{}, "example.com"))
+
+	sys := &types.SystemContext{
+		DockerRegistryAllowList: []string{"*.example.com", "docker.io"},
+		DockerRegistryDenyList:  []string{"denied.example.com"},
+	}
+	for _, c := range []struct {
+		registry string
+		expected error
+	}{
+		{"registry.example.com", nil},
+		{"registry.example.com:5000", nil},
+		{"denied.example.com", ErrRegistryNotAllowed{Registry: "denied.example.com", Denied: true}},
+		{"example.org", ErrRegistryNotAllowed{Registry: "example.org", Denied: false}},
+		// All Docker Hub host names match docker.io
+		{dockerHostname, nil},
+		{dockerRegistry, nil},
+		{dockerV1Hostname, nil},
+	} {
+		err := checkRegistryAccess(sys, c.registry)
+		assert.Equal(t, c.expected, err, c.registry)
+	}
+
+	// A deny list without an allow list permits everything else.
+	sys = &types.SystemContext{DockerRegistryDenyList: []string{"docker.io"}}
+	assert.NoError(t, checkRegistryAccess(sys, "example.com"))
+	assert.Equal(t, ErrRegistryNotAllowed{Registry: dockerRegistry, Denied: true}, checkRegistryAccess(sys, dockerRegistry))
+}
+
+func TestRegistryAccessEnforcement(t *testing.T) {
+	// No network access happens for denied registries, so this test can use unreachable names.
+	sys := &types.SystemContext{DockerRegistryAllowList: []string{"allowed.invalid"}}
+	var notAllowed ErrRegistryNotAllowed
+
+	err := CheckAuth(context.Background(), sys, "user", "password", "denied.invalid")
+	assert.ErrorAs(t, err, &notAllowed)
+
+	_, err = SearchRegistry(context.Background(), sys, "denied.invalid", "busybox", 10)
+	assert.ErrorAs(t, err, &notAllowed)
+
+	ref, err := ParseReference("//denied.invalid/busybox:latest")
+	require.NoError(t, err)
+	_, err = ref.NewImageSource(context.Background(), &types.SystemContext{
+		DockerRegistryAllowList:  sys.DockerRegistryAllowList,
+		SystemRegistriesConfPath: "/dev/null",
+	})
+	assert.ErrorAs(t, err, &notAllowed)
+	_, err = ref.NewImageDestination(context.Background(), sys)
+	assert.ErrorAs(t, err, &notAllowed)
+}
+
+func TestRegistryAccessTransport(t *testing.T) {
+	// No lists: the transport is not wrapped.
+	assert.Equal(t, http.DefaultTransport, newRegistryAccessTransport(nil, http.DefaultTransport))
+	assert.Equal(t, http.DefaultTransport, newRegistryAccessTransport(&types.SystemContext{}, http.DefaultTransport))
+
+	otherHits := 0
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherHits++
+	}))
+	defer other.Close()
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, other.URL+"/blob", http.StatusTemporaryRedirect)
+		}
+	}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	require.NoError(t, err)
+
+	sys := &types.SystemContext{DockerRegistryAllowList: []string{registryURL.Host}}
+	client := &http.Client{Transport: newRegistryAccessTransport(sys, http.DefaultTransport)}
+	defer client.CloseIdleConnections()
+	res, err := client.Get(registry.URL + "/v2/")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Redirects to hosts which are not allowed are refused.
+	_, err = client.Get(registry.URL + "/redirect")
+	var notAllowed ErrRegistryNotAllowed
+	require.ErrorAs(t, err, &notAllowed)
+	assert.False(t, notAllowed.Denied)
+	// Other requests as well.
+	_, err = client.Get(other.URL + "/blob")
+	require.ErrorAs(t, err, &notAllowed)
+	assert.Equal(t, 0, otherHits)
+}
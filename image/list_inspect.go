@@ -0,0 +1,131 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ErrNotAManifestList is returned by InspectList (detected via errors.Is) if the image is a single image, not a manifest list.
+var ErrNotAManifestList = errors.New("not a manifest list")
+
+// InspectListOptions contains optional parameters for InspectList.
+type InspectListOptions struct {
+	// If set, the manifest and configuration of every instance is downloaded, and ListInstanceInspectInfo.Image is set.
+	// Otherwise, only the manifest list itself is downloaded.
+	InspectInstances bool
+}
+
+// ListInspectInfo describes a manifest list or an image index, as returned by InspectList.
+type ListInspectInfo struct {
+	MIMEType     string
+	Digest       digest.Digest     // Digest of the manifest list itself
+	ArtifactType string            // Only set for OCI image indexes
+	Annotations  map[string]string // Only set for OCI image indexes
+	Instances    []ListInstanceInspectInfo
+}
+
+// ListInstanceInspectInfo describes a single instance of a manifest list, as returned by InspectList.
+type ListInstanceInspectInfo struct {
+	Digest                    digest.Digest
+	Size                      int64
+	MIMEType                  string
+	ArtifactType              string              // Only set for OCI image indexes
+	Platform                  *imgspecv1.Platform // nil if not specified in the manifest list
+	Annotations               map[string]string   // Only set for OCI image indexes
+	CompressionAlgorithmNames []string
+	// Image is only set if InspectListOptions.InspectInstances, and the instance is an image, not some other kind of artifact.
+	Image *types.ImageInspectInfo
+}
+
+// InspectList returns information about the manifest list or image index ref refers to,
+// without downloading the individual instances unless requested in options.
+// If ref refers to a single image, InspectList returns an error wrapping ErrNotAManifestList.
+func InspectList(ctx context.Context, sys *types.SystemContext, ref types.ImageReference, options *InspectListOptions) (*ListInspectInfo, error) {
+	if options == nil {
+		options = &InspectListOptions{}
+	}
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	res, err := inspectListFromSource(ctx, sys, src, options)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting %s: %w", transports.ImageName(ref), err)
+	}
+	return res, nil
+}
+
+// inspectListFromSource is the implementation of InspectList, reading the primary manifest of src.
+func inspectListFromSource(ctx context.Context, sys *types.SystemContext, src types.ImageSource, options *InspectListOptions) (*ListInspectInfo, error) {
+	manifestBlob, manifestType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !manifest.MIMETypeIsMultiImage(manifestType) {
+		return nil, fmt.Errorf("manifest type %q: %w", manifestType, ErrNotAManifestList)
+	}
+	list, err := manifest.ListFromBlob(manifestBlob, manifestType)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest list: %w", err)
+	}
+	listDigest, err := manifest.Digest(manifestBlob)
+	if err != nil {
+		return nil, fmt.Errorf("computing manifest list digest: %w", err)
+	}
+
+	res := &ListInspectInfo{
+		MIMEType: list.MIMEType(),
+		Digest:   listDigest,
+	}
+	if res.MIMEType == imgspecv1.MediaTypeImageIndex {
+		// The ListPublic interface does not expose index-level fields.
+		index, err := manifest.OCI1IndexFromManifest(manifestBlob)
+		if err != nil {
+			return nil, fmt.Errorf("parsing image index: %w", err)
+		}
+		res.ArtifactType = index.ArtifactType
+		res.Annotations = index.Annotations
+	}
+	for _, instanceDigest := range list.Instances() {
+		instance, err := list.Instance(instanceDigest)
+		if err != nil {
+			return nil, err
+		}
+		info := ListInstanceInspectInfo{
+			Digest:                    instance.Digest,
+			Size:                      instance.Size,
+			MIMEType:                  instance.MediaType,
+			ArtifactType:              instance.ReadOnly.ArtifactType,
+			Platform:                  instance.ReadOnly.Platform,
+			Annotations:               instance.ReadOnly.Annotations,
+			CompressionAlgorithmNames: instance.ReadOnly.CompressionAlgorithmNames,
+		}
+		if options.InspectInstances {
+			img, err := image.FromUnparsedImage(ctx, sys, image.UnparsedInstance(src, &instanceDigest))
+			if err != nil {
+				return nil, fmt.Errorf("reading instance %s: %w", instanceDigest, err)
+			}
+			ii, err := img.Inspect(ctx)
+			var nonImageArtifactErr manifest.NonImageArtifactError
+			switch {
+			case err == nil:
+				info.Image = ii
+			case errors.As(err, &nonImageArtifactErr):
+				// Leave info.Image unset
+			default:
+				return nil, fmt.Errorf("inspecting instance %s: %w", instanceDigest, err)
+			}
+		}
+		res.Instances = append(res.Instances, info)
+	}
+	return res, nil
+}
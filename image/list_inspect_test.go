@@ -0,0 +1,169 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDirBlob writes data to a dir: image at dir as a blob, and returns its descriptor.
+func writeDirBlob(t *testing.T, dir string, mediaType string, data []byte) imgspecv1.Descriptor {
+	d := digest.FromBytes(data)
+	err := os.WriteFile(filepath.Join(dir, d.Encoded()), data, 0o644)
+	require.NoError(t, err)
+	return imgspecv1.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+}
+
+// writeDirInstance writes m as an instance manifest of a dir: image at dir, and returns its serialized form.
+func writeDirInstance(t *testing.T, dir string, m imgspecv1.Manifest) []byte {
+	blob, err := json.Marshal(m)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, digest.FromBytes(blob).Encoded()+".manifest.json"), blob, 0o644)
+	require.NoError(t, err)
+	return blob
+}
+
+func TestInspectList(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "version"), []byte("Directory Transport Version: 1.1\n"), 0o644)
+	require.NoError(t, err)
+
+	configBlob, err := json.Marshal(imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: "arm64", OS: "linux"},
+		Config:   imgspecv1.ImageConfig{User: "nobody"},
+		RootFS:   imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{}},
+	})
+	require.NoError(t, err)
+	imageManifest := writeDirInstance(t, dir, imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    writeDirBlob(t, dir, imgspecv1.MediaTypeImageConfig, configBlob),
+		Layers:    []imgspecv1.Descriptor{},
+	})
+	artifactManifest := writeDirInstance(t, dir, imgspecv1.Manifest{
+		Versioned:    imgspecs.Versioned{SchemaVersion: 2},
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.sbom",
+		Config:       writeDirBlob(t, dir, imgspecv1.MediaTypeEmptyJSON, []byte("{}")),
+		Layers:       []imgspecv1.Descriptor{writeDirBlob(t, dir, "application/json", []byte(`{"sbom":true}`))},
+	})
+	index := imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: []imgspecv1.Descriptor{
+			{
+				MediaType:   imgspecv1.MediaTypeImageManifest,
+				Digest:      digest.FromBytes(imageManifest),
+				Size:        int64(len(imageManifest)),
+				Platform:    &imgspecv1.Platform{Architecture: "arm64", OS: "linux"},
+				Annotations: map[string]string{"a": "b"},
+			},
+			{
+				MediaType:    imgspecv1.MediaTypeImageManifest,
+				ArtifactType: "application/vnd.example.sbom",
+				Digest:       digest.FromBytes(artifactManifest),
+				Size:         int64(len(artifactManifest)),
+			},
+		},
+		Annotations: map[string]string{"index": "annotation"},
+	}
+	indexBlob, err := json.Marshal(index)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "manifest.json"), indexBlob, 0o644)
+	require.NoError(t, err)
+	ref, err := directory.NewReference(dir)
+	require.NoError(t, err)
+
+	// Only the index
+	res, err := InspectList(context.Background(), nil, ref, nil)
+	require.NoError(t, err)
+	expected := &ListInspectInfo{
+		MIMEType:    imgspecv1.MediaTypeImageIndex,
+		Digest:      digest.FromBytes(indexBlob),
+		Annotations: map[string]string{"index": "annotation"},
+		Instances: []ListInstanceInspectInfo{
+			{
+				Digest:      digest.FromBytes(imageManifest),
+				Size:        int64(len(imageManifest)),
+				MIMEType:    imgspecv1.MediaTypeImageManifest,
+				Platform:    &imgspecv1.Platform{Architecture: "arm64", OS: "linux"},
+				Annotations: map[string]string{"a": "b"},
+				// The index does not say otherwise, so the instance is assumed to use gzip.
+				CompressionAlgorithmNames: []string{"gzip"},
+			},
+			{
+				Digest:                    digest.FromBytes(artifactManifest),
+				Size:                      int64(len(artifactManifest)),
+				MIMEType:                  imgspecv1.MediaTypeImageManifest,
+				ArtifactType:              "application/vnd.example.sbom",
+				CompressionAlgorithmNames: []string{"gzip"},
+			},
+		},
+	}
+	assert.Equal(t, expected, res)
+
+	// With instance details
+	res, err = InspectList(context.Background(), nil, ref, &InspectListOptions{InspectInstances: true})
+	require.NoError(t, err)
+	require.Len(t, res.Instances, 2)
+	require.NotNil(t, res.Instances[0].Image)
+	assert.Equal(t, "arm64", res.Instances[0].Image.Architecture)
+	assert.Nil(t, res.Instances[1].Image)
+	res.Instances[0].Image = nil
+	assert.Equal(t, expected, res)
+
+	// A single image
+	err = os.WriteFile(filepath.Join(dir, "manifest.json"), imageManifest, 0o644)
+	require.NoError(t, err)
+	_, err = InspectList(context.Background(), nil, ref, nil)
+	assert.ErrorIs(t, err, ErrNotAManifestList)
+}
+
+func TestInspectListDockerList(t *testing.T) {
+	list := manifest.Schema2ListFromComponents([]manifest.Schema2ManifestDescriptor{
+		{
+			Schema2Descriptor: manifest.Schema2Descriptor{
+				MediaType: manifest.DockerV2Schema2MediaType,
+				Digest:    "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				Size:      1234,
+			},
+			Platform: manifest.Schema2PlatformSpec{Architecture: "amd64", OS: "linux"},
+		},
+	})
+	listBlob, err := list.Serialize()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	err = os.WriteFile(filepath.Join(dir, "version"), []byte("Directory Transport Version: 1.1\n"), 0o644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "manifest.json"), listBlob, 0o644)
+	require.NoError(t, err)
+	ref, err := directory.NewReference(dir)
+	require.NoError(t, err)
+
+	res, err := InspectList(context.Background(), &types.SystemContext{}, ref, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &ListInspectInfo{
+		MIMEType: manifest.DockerV2ListMediaType,
+		Digest:   digest.FromBytes(listBlob),
+		Instances: []ListInstanceInspectInfo{
+			{
+				Digest:                    "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				Size:                      1234,
+				MIMEType:                  manifest.DockerV2Schema2MediaType,
+				Platform:                  &imgspecv1.Platform{Architecture: "amd64", OS: "linux"},
+				CompressionAlgorithmNames: []string{"gzip"},
+			},
+		},
+	}, res)
+}
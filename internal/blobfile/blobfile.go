@@ -0,0 +1,63 @@
+// Package blobfile reads and writes blob data in local files efficiently, for transports which store blobs as files.
+package blobfile
+
+import (
+	"io"
+	"os"
+
+	"github.com/containers/image/v5/internal/bufferpool"
+)
+
+// writeBufferSize is the size of writes to the destination file.
+// This is much larger than the reads of a typical copy pipeline, so that the file is written in few large writes.
+const writeBufferSize = 1024 * 1024
+
+// Write writes the contents of stream to file, which should be empty, and returns the number of bytes written.
+// If expectedSize is not -1, it is used to preallocate space for the file, where supported;
+// the file is not extended beyond the data actually written even if the stream is shorter.
+//
+// If stream is an *os.File, the data may be copied within the kernel (e.g. using copy_file_range).
+func Write(file *os.File, stream io.Reader, expectedSize int64) (int64, error) {
+	if expectedSize > 0 {
+		preallocate(file, expectedSize)
+	}
+
+	if _, ok := stream.(*os.File); ok {
+		return io.Copy(file, stream) // Uses file.ReadFrom, which can avoid copying through userspace.
+	}
+
+	pool := bufferpool.ForSize(writeBufferSize)
+	bufPtr := pool.Get()
+	defer pool.Put(bufPtr)
+	buf := *bufPtr
+	total := int64(0)
+	for {
+		n, readErr := fill(stream, buf)
+		if n > 0 {
+			if _, err := file.Write(buf[:n]); err != nil {
+				return total, err
+			}
+			total += int64(n)
+		}
+		if readErr == io.EOF {
+			return total, nil
+		}
+		if readErr != nil {
+			return total, readErr
+		}
+	}
+}
+
+// fill reads from stream until buf is full or an error (including io.EOF) occurs.
+// Unlike io.ReadFull, it returns errors from stream unmodified.
+func fill(stream io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := stream.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,71 @@
+package blobfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	input := make([]byte, 3*writeBufferSize+12345)
+	for i := range input {
+		input[i] = byte(i * 13)
+	}
+	sourcePath := filepath.Join(dir, "source")
+	err := os.WriteFile(sourcePath, input, 0o600)
+	require.NoError(t, err)
+
+	for i, c := range []struct {
+		data         []byte
+		expectedSize int64
+		fromFile     bool
+	}{
+		{[]byte{}, -1, false},
+		{[]byte{}, 0, false},
+		{[]byte("abc"), -1, false},
+		{[]byte("abc"), 3, false},
+		{input, -1, false},
+		{input, int64(len(input)), false},
+		{input, int64(len(input)) + 1000, false}, // The stream is shorter than expected; the file must not be extended.
+		{input, int64(len(input)), true},
+	} {
+		file, err := os.CreateTemp(dir, "dest")
+		require.NoError(t, err)
+		if c.fromFile {
+			source, err := os.Open(sourcePath)
+			require.NoError(t, err)
+			_, err = Write(file, source, c.expectedSize)
+			source.Close()
+			require.NoError(t, err, i)
+		} else {
+			n, err := Write(file, iotest.HalfReader(bytes.NewReader(c.data)), c.expectedSize)
+			require.NoError(t, err, i)
+			assert.Equal(t, int64(len(c.data)), n, i)
+		}
+		err = file.Close()
+		require.NoError(t, err)
+		written, err := os.ReadFile(file.Name())
+		require.NoError(t, err)
+		assert.Equal(t, c.data, written, i)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "dest")
+	require.NoError(t, err)
+	defer file.Close()
+	// An error from the stream is returned unmodified, even if it is io.ErrUnexpectedEOF.
+	_, err = Write(file, iotest.ErrReader(assert.AnError), -1)
+	assert.ErrorIs(t, err, assert.AnError)
+	_, err = Write(file, io.MultiReader(bytes.NewReader([]byte("abc")), iotest.ErrReader(io.ErrUnexpectedEOF)), -1)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = Write(file, iotest.TimeoutReader(bytes.NewReader([]byte("abcdef"))), 10)
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+}
@@ -0,0 +1,19 @@
+package blobfile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate allocates space for size bytes of file, without changing its size.
+// This is only an optimization, so failures (e.g. on file systems which don’t support it) are ignored.
+func preallocate(file *os.File, size int64) {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return
+	}
+	_ = conn.Control(func(fd uintptr) {
+		_ = unix.Fallocate(int(fd), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+	})
+}
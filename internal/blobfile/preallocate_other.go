@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package blobfile
+
+import "os"
+
+// preallocate does nothing on this platform.
+func preallocate(file *os.File, size int64) {
+}
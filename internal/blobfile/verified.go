@@ -0,0 +1,130 @@
+package blobfile
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// verificationXattr is the extended attribute recording that a blob file was verified to match its digest,
+// see types.SystemContext.LocalBlobDigestCache.
+const verificationXattr = "user.containers.image.verified-digest"
+
+// verificationCtimeSlack is the time allowed for recording a verification, see verificationRecord.CtimeLimit.
+const verificationCtimeSlack = 100 * time.Millisecond
+
+// verificationRecord is the value of verificationXattr.
+type verificationRecord struct {
+	Digest   digest.Digest `json:"digest"`
+	Size     int64         `json:"size"`
+	ModTime  int64         `json:"mtime"` // In Unix nanoseconds
+	Inode    uint64        `json:"inode"`
+	Verified int64         `json:"verified"` // The time of the verification, in Unix seconds
+	// The mtime can be set to an arbitrary value, so it does not reliably detect modifications; the ctime can’t be set that way,
+	// but it is updated by writing the record itself, so the record can’t contain the final ctime value.
+	// Instead, CtimeLimit is a bound on the ctime of the file when the record was written; any later modification
+	// of the file (or its extended attributes) moves the ctime past it. In Unix nanoseconds.
+	CtimeLimit int64 `json:"ctimeLimit"`
+}
+
+// Open opens the blob file at path, which is expected to contain a blob with digest d, and returns a reader and the size of the blob.
+// If sys.LocalBlobDigestCache is set, the reader implements private.VerifiedBlobReader.
+func Open(sys *types.SystemContext, path string, d digest.Digest) (io.ReadCloser, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, -1, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, -1, err
+	}
+	if sys == nil || !sys.LocalBlobDigestCache {
+		return file, fi.Size(), nil
+	}
+	res := &verifiedFile{file: file, digest: d, info: fi}
+	if record, ok := readVerification(file); ok {
+		res.verified = record.matches(d, fi) &&
+			(sys.LocalBlobDigestCacheMaxAge <= 0 || time.Since(time.Unix(record.Verified, 0)) <= sys.LocalBlobDigestCacheMaxAge)
+	}
+	return res, fi.Size(), nil
+}
+
+// matches returns true if r records a verification of the current contents of a file with info, against d.
+func (r *verificationRecord) matches(d digest.Digest, info os.FileInfo) bool {
+	ctime := fileCtime(info)
+	return r.Digest == d && r.Size == info.Size() && r.ModTime == info.ModTime().UnixNano() && r.Inode == fileInode(info) &&
+		ctime != 0 && ctime <= r.CtimeLimit
+}
+
+// sameFileVersion returns true if info1 and info2 describe the same, unmodified, file.
+func sameFileVersion(info1, info2 os.FileInfo) bool {
+	return info1.Size() == info2.Size() && info1.ModTime().Equal(info2.ModTime()) &&
+		fileInode(info1) == fileInode(info2) && fileCtime(info1) == fileCtime(info2)
+}
+
+// verifiedFile is a blob file which remembers whether it was verified to match its digest.
+type verifiedFile struct {
+	file     *os.File
+	digest   digest.Digest
+	info     os.FileInfo // As of opening file
+	verified bool
+}
+
+var _ private.VerifiedBlobReader = (*verifiedFile)(nil)
+
+func (f *verifiedFile) Read(p []byte) (int, error) {
+	return f.file.Read(p)
+}
+
+func (f *verifiedFile) Close() error {
+	return f.file.Close()
+}
+
+// DigestVerified returns true if the blob is known to match the requested digest, so that the caller does not need to verify it again.
+func (f *verifiedFile) DigestVerified() bool {
+	return f.verified
+}
+
+// RecordDigestVerified records that the caller has read the whole blob, and verified that it matches the requested digest.
+func (f *verifiedFile) RecordDigestVerified() {
+	if f.verified {
+		return
+	}
+	// If the file was modified while it was being read, we can’t tell which contents were verified.
+	fi, err := f.file.Stat()
+	if err != nil || !sameFileVersion(fi, f.info) {
+		return
+	}
+	now := time.Now()
+	ctimeLimit := now.Add(verificationCtimeSlack).UnixNano()
+	value, err := json.Marshal(verificationRecord{
+		Digest:     f.digest,
+		Size:       fi.Size(),
+		ModTime:    fi.ModTime().UnixNano(),
+		Inode:      fileInode(fi),
+		Verified:   now.Unix(),
+		CtimeLimit: ctimeLimit,
+	})
+	if err != nil {
+		return
+	}
+	// This is only an optimization, so failures (e.g. on file systems which don’t support extended attributes) are ignored.
+	if err := writeVerification(f.file, value); err != nil {
+		log.Debugf("Not recording verification of %s: %v", f.file.Name(), err)
+		return
+	}
+	// If writing the record took too long, the record will never match; that’s fine, but don’t claim success.
+	fi, err = f.file.Stat()
+	if err != nil || fileCtime(fi) == 0 || fileCtime(fi) > ctimeLimit {
+		log.Debugf("Recording verification of %s did not complete in time", f.file.Name())
+		return
+	}
+	f.verified = true
+}
@@ -0,0 +1,65 @@
+package blobfile
+
+import (
+	"encoding/json"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxVerificationRecordSize is the maximum size of a verificationXattr value we read.
+const maxVerificationRecordSize = 1024
+
+// readVerification returns the verification recorded for file, if any.
+func readVerification(file *os.File) (verificationRecord, bool) {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return verificationRecord{}, false
+	}
+	buf := make([]byte, maxVerificationRecordSize)
+	size := -1
+	if err := conn.Control(func(fd uintptr) {
+		if n, err := unix.Fgetxattr(int(fd), verificationXattr, buf); err == nil {
+			size = n
+		}
+	}); err != nil || size < 0 {
+		return verificationRecord{}, false
+	}
+	var record verificationRecord
+	if err := json.Unmarshal(buf[:size], &record); err != nil {
+		return verificationRecord{}, false
+	}
+	return record, true
+}
+
+// writeVerification records value as the verification of file.
+func writeVerification(file *os.File, value []byte) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var xattrErr error
+	if err := conn.Control(func(fd uintptr) {
+		xattrErr = unix.Fsetxattr(int(fd), verificationXattr, value, 0)
+	}); err != nil {
+		return err
+	}
+	return xattrErr
+}
+
+// fileInode returns the inode number of a file with info.
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
+
+// fileCtime returns the ctime of a file with info, in Unix nanoseconds.
+func fileCtime(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ctim.Nano()
+	}
+	return 0
+}
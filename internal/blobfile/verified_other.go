@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package blobfile
+
+import (
+	"errors"
+	"os"
+)
+
+// readVerification returns the verification recorded for file; this is not supported on this platform.
+func readVerification(file *os.File) (verificationRecord, bool) {
+	return verificationRecord{}, false
+}
+
+// writeVerification records value as the verification of file; this is not supported on this platform.
+func writeVerification(file *os.File, value []byte) error {
+	return errors.New("recording blob verifications is not supported on this platform")
+}
+
+// fileInode returns the inode number of a file with info; this is not supported on this platform.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
+
+// fileCtime returns the ctime of a file with info; this is not supported on this platform.
+func fileCtime(info os.FileInfo) int64 {
+	return 0
+}
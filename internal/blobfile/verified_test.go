@@ -0,0 +1,132 @@
+package blobfile
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openVerified calls Open, and returns the reader as a private.VerifiedBlobReader.
+func openVerified(t *testing.T, sys *types.SystemContext, path string, d digest.Digest) (private.VerifiedBlobReader, io.ReadCloser) {
+	reader, size, err := Open(sys, path, d)
+	require.NoError(t, err)
+	t.Cleanup(func() { reader.Close() })
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, fi.Size(), size)
+	verified, ok := reader.(private.VerifiedBlobReader)
+	require.True(t, ok)
+	return verified, reader
+}
+
+func TestOpen(t *testing.T) {
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	path := filepath.Join(t.TempDir(), "blob")
+	err := os.WriteFile(path, blob, 0o644)
+	require.NoError(t, err)
+
+	// Without LocalBlobDigestCache, a plain file is returned.
+	for _, sys := range []*types.SystemContext{nil, {}} {
+		reader, size, err := Open(sys, path, blobDigest)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(blob)), size)
+		_, ok := reader.(private.VerifiedBlobReader)
+		assert.False(t, ok)
+		contents, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, blob, contents)
+		reader.Close()
+	}
+
+	_, _, err = Open(&types.SystemContext{LocalBlobDigestCache: true}, filepath.Join(t.TempDir(), "missing"), blobDigest)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestVerifiedFile(t *testing.T) {
+	blob := []byte("blob contents")
+	blobDigest := digest.FromBytes(blob)
+	path := filepath.Join(t.TempDir(), "blob")
+	err := os.WriteFile(path, blob, 0o644)
+	require.NoError(t, err)
+	sys := &types.SystemContext{LocalBlobDigestCache: true}
+
+	verified, reader := openVerified(t, sys, path, blobDigest)
+	assert.False(t, verified.DigestVerified())
+	contents, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, blob, contents)
+	verified.RecordDigestVerified()
+	if !verified.DigestVerified() {
+		t.Skip("Extended attributes are not supported")
+	}
+
+	verified, _ = openVerified(t, sys, path, blobDigest)
+	assert.True(t, verified.DigestVerified())
+	// The verification is only valid for the same digest
+	verified, _ = openVerified(t, sys, path, digest.FromString("other"))
+	assert.False(t, verified.DigestVerified())
+	// The verification expires after LocalBlobDigestCacheMaxAge
+	verified, _ = openVerified(t, &types.SystemContext{LocalBlobDigestCache: true, LocalBlobDigestCacheMaxAge: time.Hour}, path, blobDigest)
+	assert.True(t, verified.DigestVerified())
+	record, ok := readVerificationFromPath(t, path)
+	require.True(t, ok)
+	record.Verified = time.Now().Add(-2 * time.Hour).Unix()
+	record.CtimeLimit = time.Now().Add(time.Hour).UnixNano() // Rewriting the record changes the ctime
+	writeVerificationToPath(t, path, record)
+	verified, _ = openVerified(t, &types.SystemContext{LocalBlobDigestCache: true, LocalBlobDigestCacheMaxAge: time.Hour}, path, blobDigest)
+	assert.False(t, verified.DigestVerified())
+	verified, _ = openVerified(t, sys, path, blobDigest)
+	assert.True(t, verified.DigestVerified())
+
+	// A record which does not cover the current ctime is ignored
+	record.CtimeLimit = time.Now().Add(-time.Hour).UnixNano()
+	writeVerificationToPath(t, path, record)
+	verified, _ = openVerified(t, sys, path, blobDigest)
+	assert.False(t, verified.DigestVerified())
+	verified, reader = openVerified(t, sys, path, blobDigest)
+	_, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	verified.RecordDigestVerified()
+	require.True(t, verified.DigestVerified())
+
+	// Modifying the file invalidates the verification, even if the mtime is restored
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	time.Sleep(2 * verificationCtimeSlack) // Make sure the ctime changes past the recorded limit
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = file.WriteAt([]byte("B"), 0)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	err = os.Chtimes(path, fi.ModTime(), fi.ModTime())
+	require.NoError(t, err)
+	verified, _ = openVerified(t, sys, path, blobDigest)
+	assert.False(t, verified.DigestVerified())
+}
+
+func readVerificationFromPath(t *testing.T, path string) (verificationRecord, bool) {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	return readVerification(file)
+}
+
+func writeVerificationToPath(t *testing.T, path string, record verificationRecord) {
+	value, err := json.Marshal(record)
+	require.NoError(t, err)
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	err = writeVerification(file, value)
+	require.NoError(t, err)
+}
@@ -33,6 +33,12 @@ type BlobInfoCache2 interface {
 	// otherwise the cache could be poisoned and cause us to make incorrect edits to type
 	// information in a manifest.
 	RecordDigestCompressorName(anyDigest digest.Digest, compressorName string)
+	// RecordDigestCompressionAnnotations records annotations which must be set on a layer using the blob
+	// with the specified digest to use its specific compression variant (e.g. the zstd:chunked TOC digest and position),
+	// or forgets them if annotations is empty.
+	// WARNING: Only call this with LOCALLY VERIFIED data, e.g. annotations we have generated when compressing the blob;
+	// don’t record annotations just because some remote author claims so (e.g. because a manifest says so).
+	RecordDigestCompressionAnnotations(anyDigest digest.Digest, annotations map[string]string)
 	// CandidateLocations2 returns a prioritized, limited, number of blobs and their locations (if known)
 	// that could possibly be reused within the specified (transport scope) (if they still
 	// exist, which is not guaranteed).
@@ -56,4 +62,7 @@ type BICReplacementCandidate2 struct {
 	CompressionAlgorithm *compressiontypes.Algorithm // An algorithm when the candidate is compressed, or nil when it is uncompressed
 	UnknownLocation      bool                        // is true when `Location` for this blob is not set
 	Location             types.BICLocationReference  // not set if UnknownLocation is set to `true`
+	// CompressionAnnotations are annotations required to use this specific compression variant of the blob, if any
+	// (as recorded by RecordDigestCompressionAnnotations).
+	CompressionAnnotations map[string]string
 }
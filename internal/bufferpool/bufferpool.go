@@ -0,0 +1,61 @@
+// Package bufferpool provides process-wide pools of byte buffers for streaming blob data,
+// so that copying many or large blobs does not allocate fresh buffers for every blob.
+package bufferpool
+
+import (
+	"io"
+	"sync"
+)
+
+// CopyBufferSize is the size of buffers used by Copy.
+const CopyBufferSize = 128 * 1024
+
+// Pool is a pool of buffers of a fixed size.  It is safe for concurrent use.
+type Pool struct {
+	size int
+	pool sync.Pool // of *[]byte
+}
+
+var pools sync.Map // int -> *Pool
+
+// ForSize returns the shared pool of buffers of the specified size.
+func ForSize(size int) *Pool {
+	if p, ok := pools.Load(size); ok {
+		return p.(*Pool)
+	}
+	p, _ := pools.LoadOrStore(size, &Pool{
+		size: size,
+		pool: sync.Pool{
+			New: func() any {
+				buf := make([]byte, size)
+				return &buf
+			},
+		},
+	})
+	return p.(*Pool)
+}
+
+// Get returns a buffer of the pool’s size.  Its contents are undefined.
+// The caller should return it using Put when it is no longer used.
+func (p *Pool) Get() *[]byte {
+	buf := p.pool.Get().(*[]byte)
+	*buf = (*buf)[:p.size]
+	return buf
+}
+
+// Put returns buf to the pool.  The caller must not use buf afterwards.
+// Buffers not obtained from this pool (with a different capacity) are silently dropped.
+func (p *Pool) Put(buf *[]byte) {
+	if buf == nil || cap(*buf) != p.size {
+		return
+	}
+	p.pool.Put(buf)
+}
+
+// Copy is equivalent to io.Copy, but if a buffer is needed, uses a pooled one instead of allocating a new one.
+func Copy(dst io.Writer, src io.Reader) (int64, error) {
+	pool := ForSize(CopyBufferSize)
+	buf := pool.Get()
+	defer pool.Put(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
@@ -0,0 +1,50 @@
+package bufferpool
+
+import (
+	"bytes"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForSize(t *testing.T) {
+	p1 := ForSize(1000)
+	assert.Same(t, p1, ForSize(1000))
+	p2 := ForSize(2000)
+	assert.NotSame(t, p1, p2)
+}
+
+func TestPool(t *testing.T) {
+	p := ForSize(1234)
+	buf := p.Get()
+	require.NotNil(t, buf)
+	assert.Len(t, *buf, 1234)
+	*buf = (*buf)[:10] // Shrinking the slice is fine, Get restores the length.
+	p.Put(buf)
+	buf = p.Get()
+	assert.Len(t, *buf, 1234)
+	p.Put(buf)
+
+	// Buffers of a different size, and nil, are ignored.
+	other := make([]byte, 99)
+	p.Put(&other)
+	p.Put(nil)
+	for i := 0; i < 10; i++ {
+		buf := p.Get()
+		assert.Len(t, *buf, 1234)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	input := bytes.Repeat([]byte("0123456789"), CopyBufferSize/5)
+	dest := bytes.Buffer{}
+	n, err := Copy(&dest, iotest.HalfReader(bytes.NewReader(input)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(input)), n)
+	assert.Equal(t, input, dest.Bytes())
+
+	_, err = Copy(&dest, iotest.ErrReader(assert.AnError))
+	assert.ErrorIs(t, err, assert.AnError)
+}
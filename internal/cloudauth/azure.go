@@ -0,0 +1,147 @@
+package cloudauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	// azureResource is the resource for which Microsoft Entra ID access tokens are requested; ACR accepts ARM tokens.
+	azureResource = "https://management.azure.com/"
+	// acrRegistryUsername is the user name used with ACR refresh tokens.
+	acrRegistryUsername = "00000000-0000-0000-0000-000000000000"
+)
+
+// acrRegistrySuffixes are domain suffixes of Azure Container Registry registries, in the public and sovereign clouds.
+var acrRegistrySuffixes = []string{".azurecr.io", ".azurecr.cn", ".azurecr.us"}
+
+// isACRRegistry returns true if host is the host name of an Azure Container Registry registry.
+func isACRRegistry(host string) bool {
+	for _, suffix := range acrRegistrySuffixes {
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// azureTokenResponse is a response of the Microsoft Entra ID token endpoint or the instance metadata service.
+type azureTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	// The two services use different formats: either a number (in JSON) or a string containing a number.
+	ExpiresIn json.Number `json:"expires_in"` // Seconds
+}
+
+// acrCredentials is a provider for Azure Container Registry, exchanging a Microsoft Entra ID access token for an ACR refresh token.
+func acrCredentials(ctx context.Context, env *environment, registry string) (credentials, error) {
+	token, tenant, err := azureAccessToken(ctx, env)
+	if err != nil {
+		return credentials{}, err
+	}
+	form := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {registry},
+		"access_token": {token.AccessToken},
+	}
+	if tenant != "" {
+		form.Set("tenant", tenant)
+	}
+	var res struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := postForm(ctx, env.client, env.acrEndpoint(registry)+"/oauth2/exchange", form, &res); err != nil {
+		return credentials{}, fmt.Errorf("exchanging an access token for an ACR refresh token: %w", err)
+	}
+	if res.RefreshToken == "" {
+		return credentials{}, errors.New("ACR token exchange returned no refresh token")
+	}
+	expires := env.now().Add(time.Hour) // A conservative default; ACR refresh tokens are valid for longer than that.
+	if seconds, err := token.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		expires = env.now().Add(time.Duration(seconds) * time.Second)
+	}
+	return credentials{
+		auth:    types.DockerAuthConfig{Username: acrRegistryUsername, Password: res.RefreshToken},
+		expires: expires,
+	}, nil
+}
+
+// azureAccessToken returns a Microsoft Entra ID access token, and the tenant ID if known, using a service principal secret
+// or a workload identity federated token from the environment variables, or a managed identity from the instance metadata service,
+// in that order.
+func azureAccessToken(ctx context.Context, env *environment) (azureTokenResponse, string, error) {
+	clientID, tenant := env.getenv("AZURE_CLIENT_ID"), env.getenv("AZURE_TENANT_ID")
+	var form url.Values
+	if secret := env.getenv("AZURE_CLIENT_SECRET"); clientID != "" && tenant != "" && secret != "" {
+		form = url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"scope":         {azureResource + ".default"},
+		}
+	} else if tokenFile := env.getenv("AZURE_FEDERATED_TOKEN_FILE"); clientID != "" && tenant != "" && tokenFile != "" {
+		assertion, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return azureTokenResponse{}, "", fmt.Errorf("reading federated token: %w", err)
+		}
+		form = url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_id":             {clientID},
+			"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+			"client_assertion":      {strings.TrimSpace(string(assertion))},
+			"scope":                 {azureResource + ".default"},
+		}
+	}
+	if form != nil {
+		var res azureTokenResponse
+		endpoint := env.azureLoginEndpoint + "/" + url.PathEscape(tenant) + "/oauth2/v2.0/token"
+		if err := postForm(ctx, env.client, endpoint, form, &res); err != nil {
+			return azureTokenResponse{}, "", fmt.Errorf("obtaining a Microsoft Entra ID access token: %w", err)
+		}
+		return res, tenant, nil
+	}
+
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {azureResource},
+	}
+	if clientID != "" { // Selects one of several user-assigned managed identities
+		query.Set("client_id", clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.azureIMDSEndpoint+"/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return azureTokenResponse{}, "", err
+	}
+	req.Header.Set("Metadata", "true")
+	res, err := env.metadata.Do(req)
+	if err != nil {
+		return azureTokenResponse{}, "", fmt.Errorf("%w: Azure instance metadata service not available: %v", errNoAmbientCredentials, err)
+	}
+	defer res.Body.Close()
+	body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxAuthTokenBodySize)
+	if err != nil {
+		return azureTokenResponse{}, "", err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound: // IMDS returns 400 if the instance has no managed identity.
+		return azureTokenResponse{}, "", fmt.Errorf("%w: the Azure instance has no managed identity", errNoAmbientCredentials)
+	default:
+		return azureTokenResponse{}, "", fmt.Errorf("obtaining a managed identity access token returned status %d (%s): %s",
+			res.StatusCode, http.StatusText(res.StatusCode), strings.TrimSpace(string(body)))
+	}
+	var token azureTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return azureTokenResponse{}, "", fmt.Errorf("decoding Azure instance metadata service response: %w", err)
+	}
+	return token, "", nil
+}
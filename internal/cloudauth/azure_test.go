@@ -0,0 +1,104 @@
+package cloudauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACRCredentials(t *testing.T) {
+	now := time.Now()
+	login := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/tenant/oauth2/v2.0/token", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {"client"},
+			"client_secret": {"secret"},
+			"scope":         {azureResource + ".default"},
+		}, r.PostForm)
+		fmt.Fprint(w, `{"token_type":"Bearer","expires_in":3599,"access_token":"entraAccess"}`)
+	}))
+	defer login.Close()
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/oauth2/exchange", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, url.Values{
+			"grant_type":   {"access_token"},
+			"service":      {"myregistry.azurecr.io"},
+			"access_token": {"entraAccess"},
+			"tenant":       {"tenant"},
+		}, r.PostForm)
+		fmt.Fprint(w, `{"refresh_token":"acrRefresh"}`)
+	}))
+	defer registry.Close()
+
+	env := newTestEnvironment(t, map[string]string{
+		"AZURE_CLIENT_ID":     "client",
+		"AZURE_TENANT_ID":     "tenant",
+		"AZURE_CLIENT_SECRET": "secret",
+	})
+	env.now = func() time.Time { return now }
+	env.azureLoginEndpoint = login.URL
+	env.acrEndpoint = func(registryName string) string {
+		assert.Equal(t, "myregistry.azurecr.io", registryName)
+		return registry.URL
+	}
+	creds, err := acrCredentials(context.Background(), env, "myregistry.azurecr.io")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: acrRegistryUsername, Password: "acrRefresh"}, creds.auth)
+	assert.True(t, creds.expires.Equal(now.Add(3599*time.Second)))
+}
+
+func TestAzureAccessTokenIMDS(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		clientID string
+		status   int
+		noCreds  bool
+	}{
+		{"system-assigned identity", "", http.StatusOK, false},
+		{"user-assigned identity", "identity", http.StatusOK, false},
+		{"no identity", "", http.StatusBadRequest, true},
+		{"server error", "", http.StatusInternalServerError, false},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "true", r.Header.Get("Metadata"))
+			assert.Equal(t, "/metadata/identity/oauth2/token", r.URL.Path)
+			assert.Equal(t, azureResource, r.URL.Query().Get("resource"))
+			assert.Equal(t, c.clientID, r.URL.Query().Get("client_id"))
+			w.WriteHeader(c.status)
+			fmt.Fprint(w, `{"access_token":"imdsAccess","expires_in":"86399","token_type":"Bearer"}`)
+		}))
+		env := newTestEnvironment(t, map[string]string{"AZURE_CLIENT_ID": c.clientID})
+		env.azureIMDSEndpoint = server.URL
+		token, tenant, err := azureAccessToken(context.Background(), env)
+		server.Close()
+		switch {
+		case c.noCreds:
+			assert.ErrorIs(t, err, errNoAmbientCredentials, c.name)
+		case c.status != http.StatusOK:
+			assert.Error(t, err, c.name)
+			assert.NotErrorIs(t, err, errNoAmbientCredentials, c.name)
+		default:
+			require.NoError(t, err, c.name)
+			assert.Equal(t, "imdsAccess", token.AccessToken, c.name)
+			seconds, err := token.ExpiresIn.Int64()
+			require.NoError(t, err, c.name)
+			assert.Equal(t, int64(86399), seconds, c.name)
+			assert.Equal(t, "", tenant, c.name)
+		}
+	}
+
+	// Unreachable metadata service
+	_, _, err := azureAccessToken(context.Background(), newTestEnvironment(t, nil))
+	assert.ErrorIs(t, err, errNoAmbientCredentials)
+}
@@ -0,0 +1,236 @@
+// Package cloudauth obtains credentials for the container registries of public cloud providers
+// (Amazon ECR, Google Artifact Registry and Container Registry, and Azure Container Registry),
+// using the ambient credentials of the environment, like the providers’ credential helpers do.
+package cloudauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	// requestTimeout limits the time of the whole credential lookup.
+	requestTimeout = 30 * time.Second
+	// metadataTimeout limits requests to metadata services, which usually don’t exist outside of the cloud; this is
+	// short so that users of the registries outside of the cloud don’t wait long for a connection which never succeeds.
+	metadataTimeout = 2 * time.Second
+	// expiryMargin is the time before the expiration of credentials when we stop using them from the cache.
+	expiryMargin = 5 * time.Minute
+	// noCredentialsCacheTime is the time we remember that the environment contains no credentials for a registry,
+	// so that we don’t wait for the metadata services again for every request.
+	noCredentialsCacheTime = 5 * time.Minute
+)
+
+// errNoAmbientCredentials is returned by providers if the environment does not contain credentials for the cloud.
+var errNoAmbientCredentials = errors.New("no ambient cloud credentials found")
+
+// credentials are registry credentials returned by a provider.
+// An entry with an empty auth in credentialsCache means that the environment contains no credentials for the registry.
+type credentials struct {
+	auth    types.DockerAuthConfig
+	expires time.Time
+}
+
+// provider returns registry credentials for registry, or errNoAmbientCredentials.
+type provider func(ctx context.Context, env *environment, registry string) (credentials, error)
+
+// environment contains the inputs of providers; it only exists to allow overriding them in tests.
+type environment struct {
+	getenv   func(string) string
+	homeDir  string
+	client   *http.Client
+	metadata *http.Client // For metadata services, with a short timeout
+	now      func() time.Time
+
+	awsIMDSEndpoint    string                             // Base URL of the EC2 instance metadata service
+	awsECSEndpoint     string                             // Base URL of the ECS container credentials service
+	ecrEndpoint        func(region, domain string) string // Base URL of the ECR API
+	gceMetadataHost    string                             // Host of the GCE metadata service
+	azureIMDSEndpoint  string                             // Base URL of the Azure instance metadata service
+	azureLoginEndpoint string                             // Base URL of the Microsoft Entra ID login service
+	acrEndpoint        func(registry string) string       // Base URL of the registry, for the ACR token exchange
+}
+
+// newEnvironment returns the environment of this process, with homeDir as the user’s home directory.
+func newEnvironment(homeDir string) *environment {
+	env := &environment{
+		getenv:            os.Getenv,
+		homeDir:           homeDir,
+		client:            &http.Client{},
+		metadata:          &http.Client{Timeout: metadataTimeout},
+		now:               time.Now,
+		awsIMDSEndpoint:   "http://169.254.169.254",
+		awsECSEndpoint:    "http://169.254.170.2",
+		ecrEndpoint:       func(region, domain string) string { return fmt.Sprintf("https://api.ecr.%s.%s", region, domain) },
+		gceMetadataHost:   "metadata.google.internal",
+		azureIMDSEndpoint: "http://169.254.169.254",
+		acrEndpoint:       func(registry string) string { return "https://" + registry },
+	}
+	if host := env.getenv("GCE_METADATA_HOST"); host != "" {
+		env.gceMetadataHost = host
+	}
+	env.azureLoginEndpoint = strings.TrimSuffix(env.getenv("AZURE_AUTHORITY_HOST"), "/")
+	if env.azureLoginEndpoint == "" {
+		env.azureLoginEndpoint = "https://login.microsoftonline.com"
+	}
+	return env
+}
+
+// providerForRegistry returns a provider for registry (a host[:port] value), or nil if it is not a known cloud registry.
+func providerForRegistry(registry string) provider {
+	host := registryHost(registry)
+	switch {
+	case ecrRegistryRegexp.MatchString(host):
+		return ecrCredentials
+	case host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev"):
+		return gcpCredentials
+	case isACRRegistry(host):
+		return acrCredentials
+	default:
+		return nil
+	}
+}
+
+// registryHost returns the lower-case host name of registry, a host[:port] value.
+func registryHost(registry string) string {
+	host := strings.ToLower(registry)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
+
+// credentialSourceVariables are the environment variables which affect which credentials providers use.
+var credentialSourceVariables = []string{
+	"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_SHARED_CREDENTIALS_FILE", "AWS_PROFILE",
+	"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+	"GOOGLE_APPLICATION_CREDENTIALS", "CLOUDSDK_CONFIG", "GCE_METADATA_HOST",
+	"AZURE_CLIENT_ID", "AZURE_TENANT_ID", "AZURE_CLIENT_SECRET", "AZURE_FEDERATED_TOKEN_FILE", "AZURE_AUTHORITY_HOST",
+}
+
+// credentialsCache contains credentials obtained by providers, by credentialsCacheKey.
+var credentialsCache = struct {
+	mutex   sync.Mutex
+	entries map[string]credentials
+}{entries: map[string]credentials{}}
+
+// credentialsCacheKey returns a credentialsCache key for credentials for registry obtained in env.
+// Credentials from different sources (e.g. a different home directory, AWS profile, or credential files)
+// are cached separately, so that callers using different sources don’t share credentials.
+func credentialsCacheKey(env *environment, registry string) string {
+	h := sha256.New()
+	writeField := func(value string) {
+		fmt.Fprintf(h, "%d:%s", len(value), value) // Length-prefixed, so that values can’t be shifted between fields
+	}
+	writeField(registry)
+	writeField(env.homeDir)
+	for _, name := range credentialSourceVariables {
+		writeField(env.getenv(name))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetCredentials returns credentials for registry (a host[:port] value) if it is a registry of a known cloud provider,
+// using the ambient credentials found in the environment (with homeDir as the user’s home directory).
+// It returns an empty types.DockerAuthConfig if registry is not recognized, or if the environment does not contain credentials for its cloud.
+func GetCredentials(ctx context.Context, registry, homeDir string) (types.DockerAuthConfig, error) {
+	p := providerForRegistry(registry)
+	if p == nil {
+		return types.DockerAuthConfig{}, nil
+	}
+	return getCredentials(ctx, newEnvironment(homeDir), p, registry)
+}
+
+// getCredentials implements GetCredentials for provider p, using and updating credentialsCache.
+func getCredentials(ctx context.Context, env *environment, p provider, registry string) (types.DockerAuthConfig, error) {
+	cacheKey := credentialsCacheKey(env, registry)
+	credentialsCache.mutex.Lock()
+	cached, ok := credentialsCache.entries[cacheKey]
+	credentialsCache.mutex.Unlock()
+	if ok && env.now().Add(expiryMargin).Before(cached.expires) {
+		return cached.auth, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	creds, err := p(ctx, env, registry)
+	if err != nil {
+		if errors.Is(err, errNoAmbientCredentials) {
+			log.Debugf("Not using cloud credentials for %s: %v", registry, err)
+			credentialsCache.mutex.Lock()
+			credentialsCache.entries[cacheKey] = credentials{expires: env.now().Add(expiryMargin + noCredentialsCacheTime)}
+			credentialsCache.mutex.Unlock()
+			return types.DockerAuthConfig{}, nil
+		}
+		return types.DockerAuthConfig{}, fmt.Errorf("obtaining cloud credentials for %s: %w", registry, err)
+	}
+	credentialsCache.mutex.Lock()
+	credentialsCache.entries[cacheKey] = creds
+	credentialsCache.mutex.Unlock()
+	return creds.auth, nil
+}
+
+// doJSON sends req using client, and decodes a successful JSON response into result.
+func doJSON(client *http.Client, req *http.Request, result any) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxAuthTokenBodySize)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned status %d (%s): %s", req.Method, req.URL.Redacted(), res.StatusCode,
+			http.StatusText(res.StatusCode), strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("decoding response of %s %s: %w", req.Method, req.URL.Redacted(), err)
+	}
+	return nil
+}
+
+// postForm sends form to endpoint using client, and decodes a successful JSON response into result.
+func postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doJSON(client, req, result)
+}
+
+// readOptionalFile returns the contents of path, or nil if it does not exist.
+func readOptionalFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// drainAndClose reads the rest of body, to allow reusing the connection, and closes it.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, iolimits.MaxErrorBodySize))
+	body.Close()
+}
@@ -0,0 +1,136 @@
+package cloudauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEnvironment returns an environment with vars as environment variables, and homeDir as the home directory,
+// which does not contact any real services.
+func newTestEnvironment(t *testing.T, vars map[string]string) *environment {
+	unreachable := "http://127.0.0.1:1" // Connections to this are refused
+	return &environment{
+		getenv:             func(name string) string { return vars[name] },
+		homeDir:            t.TempDir(),
+		client:             &http.Client{},
+		metadata:           &http.Client{Timeout: metadataTimeout},
+		now:                time.Now,
+		awsIMDSEndpoint:    unreachable,
+		awsECSEndpoint:     unreachable,
+		ecrEndpoint:        func(region, domain string) string { return unreachable },
+		gceMetadataHost:    "127.0.0.1:1",
+		azureIMDSEndpoint:  unreachable,
+		azureLoginEndpoint: unreachable,
+		acrEndpoint:        func(registry string) string { return unreachable },
+	}
+}
+
+func TestProviderForRegistry(t *testing.T) {
+	for _, c := range []struct {
+		registry string
+		expected provider
+	}{
+		{"123456789012.dkr.ecr.us-east-1.amazonaws.com", ecrCredentials},
+		{"123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com", ecrCredentials},
+		{"123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", ecrCredentials},
+		{"123456789012.DKR.ECR.us-east-1.amazonaws.com:443", ecrCredentials},
+		{"12345.dkr.ecr.us-east-1.amazonaws.com", nil},
+		{"public.ecr.aws", nil},
+		{"gcr.io", gcpCredentials},
+		{"eu.gcr.io", gcpCredentials},
+		{"europe-west1-docker.pkg.dev", gcpCredentials},
+		{"notgcr.io", nil},
+		{"example.pkg.dev", nil},
+		{"myregistry.azurecr.io", acrCredentials},
+		{"myregistry.azurecr.cn", acrCredentials},
+		{"azurecr.io", nil},
+		{".azurecr.io", nil},
+		{"docker.io", nil},
+		{"registry.example.com", nil},
+	} {
+		p := providerForRegistry(c.registry)
+		if c.expected == nil {
+			assert.Nil(t, p, c.registry)
+		} else {
+			require.NotNil(t, p, c.registry)
+			assert.Equal(t, reflect.ValueOf(c.expected).Pointer(), reflect.ValueOf(p).Pointer(), c.registry)
+		}
+	}
+}
+
+func TestGetCredentials(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnvironment(t, nil)
+
+	// No ambient credentials
+	calls := 0
+	noCredentials := func(ctx context.Context, env *environment, registry string) (credentials, error) {
+		calls++
+		return credentials{}, errNoAmbientCredentials
+	}
+	for i := 0; i < 2; i++ {
+		auth, err := getCredentials(ctx, env, noCredentials, "no-credentials.test")
+		require.NoError(t, err)
+		assert.Equal(t, types.DockerAuthConfig{}, auth)
+	}
+	assert.Equal(t, 1, calls) // The result is cached
+	savedNow := env.now
+	env.now = func() time.Time { return time.Now().Add(noCredentialsCacheTime + time.Minute) }
+	auth, err := getCredentials(ctx, env, noCredentials, "no-credentials.test")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+	assert.Equal(t, 2, calls)
+	env.now = savedNow
+
+	// Provider failure
+	failing := func(ctx context.Context, env *environment, registry string) (credentials, error) {
+		return credentials{}, errors.New("token exchange failed")
+	}
+	_, err = getCredentials(ctx, env, failing, "failing.test")
+	assert.ErrorContains(t, err, "token exchange failed")
+
+	// Credentials are cached until shortly before they expire
+	expected := types.DockerAuthConfig{Username: "user", Password: "pass"}
+	calls = 0
+	working := func(ctx context.Context, env *environment, registry string) (credentials, error) {
+		calls++
+		return credentials{auth: expected, expires: env.now().Add(time.Hour)}, nil
+	}
+	for i := 0; i < 2; i++ {
+		auth, err = getCredentials(ctx, env, working, "working.test")
+		require.NoError(t, err)
+		assert.Equal(t, expected, auth)
+	}
+	assert.Equal(t, 1, calls)
+	env.now = func() time.Time { return time.Now().Add(time.Hour - expiryMargin/2) }
+	auth, err = getCredentials(ctx, env, working, "working.test")
+	require.NoError(t, err)
+	assert.Equal(t, expected, auth)
+	assert.Equal(t, 2, calls)
+
+	// Credentials from a different source are not shared
+	env.now = time.Now
+	calls = 0
+	otherHome := newTestEnvironment(t, nil)
+	otherProfile := newTestEnvironment(t, map[string]string{"AWS_PROFILE": "other"})
+	otherProfile.homeDir = env.homeDir
+	for _, e := range []*environment{env, otherHome, otherProfile} {
+		auth, err = getCredentials(ctx, e, working, "sources.test")
+		require.NoError(t, err)
+		assert.Equal(t, expected, auth)
+	}
+	assert.Equal(t, 3, calls)
+
+	// Unrecognized registries are ignored
+	auth, err = GetCredentials(ctx, "registry.example.com", t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+}
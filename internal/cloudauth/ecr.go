@@ -0,0 +1,274 @@
+package cloudauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/types"
+)
+
+// ecrRegistryRegexp matches host names of Amazon ECR private registries; the submatches are the region and the domain.
+var ecrRegistryRegexp = regexp.MustCompile(`^[0-9]{12}\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.(amazonaws\.com(?:\.cn)?)$`)
+
+// awsCredentials are AWS credentials used to sign requests.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Optional, used with temporary credentials.
+}
+
+// ecrCredentials is a provider for Amazon ECR registries, calling the ECR GetAuthorizationToken API.
+func ecrCredentials(ctx context.Context, env *environment, registry string) (credentials, error) {
+	m := ecrRegistryRegexp.FindStringSubmatch(registryHost(registry))
+	if m == nil {
+		return credentials{}, fmt.Errorf("%q is not an ECR registry", registry)
+	}
+	region, domain := m[1], m[2]
+	awsCreds, err := findAWSCredentials(ctx, env)
+	if err != nil {
+		return credentials{}, err
+	}
+
+	body := []byte("{}")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, env.ecrEndpoint(region, domain)+"/", bytes.NewReader(body))
+	if err != nil {
+		return credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken")
+	signAWSRequest(req, awsCreds, region, "ecr", body, env.now())
+	var res struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"` // Seconds since the epoch
+		} `json:"authorizationData"`
+	}
+	if err := doJSON(env.client, req, &res); err != nil {
+		return credentials{}, fmt.Errorf("calling ECR GetAuthorizationToken: %w", err)
+	}
+	if len(res.AuthorizationData) == 0 {
+		return credentials{}, errors.New("ECR GetAuthorizationToken returned no authorization data")
+	}
+	token, err := base64.StdEncoding.DecodeString(res.AuthorizationData[0].AuthorizationToken)
+	if err != nil {
+		return credentials{}, fmt.Errorf("decoding ECR authorization token: %w", err)
+	}
+	username, password, ok := strings.Cut(string(token), ":")
+	if !ok {
+		return credentials{}, errors.New("invalid ECR authorization token, expected username:password")
+	}
+	return credentials{
+		auth:    types.DockerAuthConfig{Username: username, Password: password},
+		expires: time.Unix(int64(res.AuthorizationData[0].ExpiresAt), 0),
+	}, nil
+}
+
+// findAWSCredentials returns AWS credentials from the environment variables, the shared credentials file,
+// the ECS container credentials service, or the EC2 instance metadata service, in that order.
+func findAWSCredentials(ctx context.Context, env *environment) (awsCredentials, error) {
+	if keyID, secret := env.getenv("AWS_ACCESS_KEY_ID"), env.getenv("AWS_SECRET_ACCESS_KEY"); keyID != "" && secret != "" {
+		return awsCredentials{AccessKeyID: keyID, SecretAccessKey: secret, SessionToken: env.getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	path := env.getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		path = filepath.Join(env.homeDir, ".aws", "credentials")
+	}
+	profile := env.getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	data, err := readOptionalFile(path)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if data != nil {
+		values := parseINISection(data, profile)
+		if values["aws_access_key_id"] != "" && values["aws_secret_access_key"] != "" {
+			return awsCredentials{
+				AccessKeyID:     values["aws_access_key_id"],
+				SecretAccessKey: values["aws_secret_access_key"],
+				SessionToken:    values["aws_session_token"],
+			}, nil
+		}
+	}
+
+	if relativeURI := env.getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relativeURI != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.awsECSEndpoint+relativeURI, nil)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		return getAWSMetadataCredentials(env, req)
+	}
+
+	return getEC2InstanceCredentials(ctx, env)
+}
+
+// getEC2InstanceCredentials returns the credentials of the role of the EC2 instance, using IMDSv2.
+func getEC2InstanceCredentials(ctx context.Context, env *environment) (awsCredentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, env.awsIMDSEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	res, err := env.metadata.Do(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("%w: EC2 instance metadata service not available: %v", errNoAmbientCredentials, err)
+	}
+	defer res.Body.Close()
+	token, err := iolimits.ReadAtMost(res.Body, iolimits.MaxAuthTokenBodySize)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("%w: EC2 instance metadata service returned status %d", errNoAmbientCredentials, res.StatusCode)
+	}
+
+	metadataRequest := func(path string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.awsIMDSEndpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return req, nil
+	}
+	req, err = metadataRequest("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	res, err = env.metadata.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		drainAndClose(res.Body)
+		return awsCredentials{}, fmt.Errorf("%w: the EC2 instance has no IAM role", errNoAmbientCredentials)
+	}
+	roles, err := iolimits.ReadAtMost(res.Body, iolimits.MaxAuthTokenBodySize)
+	res.Body.Close()
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("listing EC2 instance IAM roles returned status %d", res.StatusCode)
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+	if role == "" {
+		return awsCredentials{}, fmt.Errorf("%w: the EC2 instance has no IAM role", errNoAmbientCredentials)
+	}
+	req, err = metadataRequest("/latest/meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	return getAWSMetadataCredentials(env, req)
+}
+
+// getAWSMetadataCredentials returns credentials from a response to req, in the format used by the ECS and EC2 metadata services.
+func getAWSMetadataCredentials(env *environment, req *http.Request) (awsCredentials, error) {
+	var res struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		Token           string `json:"Token"`
+	}
+	if err := doJSON(env.metadata, req, &res); err != nil {
+		return awsCredentials{}, fmt.Errorf("reading AWS credentials from metadata service: %w", err)
+	}
+	if res.AccessKeyID == "" || res.SecretAccessKey == "" {
+		return awsCredentials{}, errors.New("AWS metadata service returned incomplete credentials")
+	}
+	return awsCredentials{AccessKeyID: res.AccessKeyID, SecretAccessKey: res.SecretAccessKey, SessionToken: res.Token}, nil
+}
+
+// parseINISection returns the key = value pairs of section in an INI-formatted file, like the AWS shared credentials file.
+func parseINISection(data []byte, section string) map[string]string {
+	res := map[string]string{}
+	inSection := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+		case inSection:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				res[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return res
+}
+
+// signAWSRequest adds an AWS Signature Version 4 authorization for service in region to req, which has body and no query.
+// This is a minimal counterpart of the signing done in oci/s3, sufficient for the JSON APIs we call.
+func signAWSRequest(req *http.Request, creds awsCredentials, region, service string, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		"", // No query
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,150 @@
+package cloudauth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECRCredentials(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	expires := now.Add(12 * time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/20240506/eu-west-1/ecr/aws4_request,SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target,Signature="),
+			r.Header.Get("Authorization"))
+		token := base64.StdEncoding.EncodeToString([]byte("AWS:registry-password"))
+		fmt.Fprintf(w, `{"authorizationData":[{"authorizationToken":%q,"expiresAt":%d.5}]}`, token, expires.Unix())
+	}))
+	defer server.Close()
+
+	env := newTestEnvironment(t, map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKID",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_SESSION_TOKEN":     "session",
+	})
+	env.now = func() time.Time { return now }
+	env.ecrEndpoint = func(region, domain string) string {
+		assert.Equal(t, "eu-west-1", region)
+		assert.Equal(t, "amazonaws.com", domain)
+		return server.URL
+	}
+	creds, err := ecrCredentials(context.Background(), env, "123456789012.dkr.ecr.eu-west-1.amazonaws.com")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: "AWS", Password: "registry-password"}, creds.auth)
+	assert.True(t, creds.expires.Equal(expires))
+
+	// Without any credentials
+	env = newTestEnvironment(t, nil)
+	_, err = ecrCredentials(context.Background(), env, "123456789012.dkr.ecr.eu-west-1.amazonaws.com")
+	assert.ErrorIs(t, err, errNoAmbientCredentials)
+}
+
+func TestFindAWSCredentials(t *testing.T) {
+	// Environment variables
+	env := newTestEnvironment(t, map[string]string{"AWS_ACCESS_KEY_ID": "envKey", "AWS_SECRET_ACCESS_KEY": "envSecret"})
+	creds, err := findAWSCredentials(context.Background(), env)
+	require.NoError(t, err)
+	assert.Equal(t, awsCredentials{AccessKeyID: "envKey", SecretAccessKey: "envSecret"}, creds)
+
+	// Shared credentials file, default and explicit profile
+	env = newTestEnvironment(t, nil)
+	err = os.MkdirAll(filepath.Join(env.homeDir, ".aws"), 0o700)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(env.homeDir, ".aws", "credentials"), []byte(
+		"# comment\n[default]\naws_access_key_id = defaultKey\naws_secret_access_key=defaultSecret\n\n"+
+			"[other]\naws_access_key_id = otherKey\naws_secret_access_key = otherSecret\naws_session_token = otherToken\n"), 0o600)
+	require.NoError(t, err)
+	creds, err = findAWSCredentials(context.Background(), env)
+	require.NoError(t, err)
+	assert.Equal(t, awsCredentials{AccessKeyID: "defaultKey", SecretAccessKey: "defaultSecret"}, creds)
+	vars := map[string]string{"AWS_PROFILE": "other"}
+	env.getenv = func(name string) string { return vars[name] }
+	creds, err = findAWSCredentials(context.Background(), env)
+	require.NoError(t, err)
+	assert.Equal(t, awsCredentials{AccessKeyID: "otherKey", SecretAccessKey: "otherSecret", SessionToken: "otherToken"}, creds)
+
+	// ECS container credentials
+	ecs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/credentials/uuid", r.URL.Path)
+		fmt.Fprint(w, `{"AccessKeyId":"ecsKey","SecretAccessKey":"ecsSecret","Token":"ecsToken"}`)
+	}))
+	defer ecs.Close()
+	env = newTestEnvironment(t, map[string]string{"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/v2/credentials/uuid"})
+	env.awsECSEndpoint = ecs.URL
+	creds, err = findAWSCredentials(context.Background(), env)
+	require.NoError(t, err)
+	assert.Equal(t, awsCredentials{AccessKeyID: "ecsKey", SecretAccessKey: "ecsSecret", SessionToken: "ecsToken"}, creds)
+}
+
+func TestGetEC2InstanceCredentials(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		roles    string
+		expected awsCredentials
+		noCreds  bool
+	}{
+		{"role", "myrole\n", awsCredentials{AccessKeyID: "imdsKey", SecretAccessKey: "imdsSecret", SessionToken: "imdsToken"}, false},
+		{"no role", "", awsCredentials{}, true},
+	} {
+		imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/latest/api/token" {
+				assert.Equal(t, http.MethodPut, r.Method)
+				fmt.Fprint(w, "imds-token")
+				return
+			}
+			assert.Equal(t, "imds-token", r.Header.Get("X-aws-ec2-metadata-token"))
+			switch r.URL.Path {
+			case "/latest/meta-data/iam/security-credentials/":
+				if c.roles == "" {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprint(w, c.roles)
+			case "/latest/meta-data/iam/security-credentials/myrole":
+				fmt.Fprint(w, `{"Code":"Success","AccessKeyId":"imdsKey","SecretAccessKey":"imdsSecret","Token":"imdsToken"}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		env := newTestEnvironment(t, nil)
+		env.awsIMDSEndpoint = imds.URL
+		creds, err := getEC2InstanceCredentials(context.Background(), env)
+		imds.Close()
+		if c.noCreds {
+			assert.ErrorIs(t, err, errNoAmbientCredentials, c.name)
+		} else {
+			require.NoError(t, err, c.name)
+			assert.Equal(t, c.expected, creds, c.name)
+		}
+	}
+
+	// Unreachable metadata service
+	_, err := getEC2InstanceCredentials(context.Background(), newTestEnvironment(t, nil))
+	assert.ErrorIs(t, err, errNoAmbientCredentials)
+}
+
+func TestSignAWSRequest(t *testing.T) {
+	// The "get-vanilla" case of the AWS Signature Version 4 test suite.
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	signAWSRequest(req, awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		"us-east-1", "service", nil, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request,SignedHeaders=host;x-amz-date,"+
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", req.Header.Get("Authorization"))
+}
@@ -0,0 +1,213 @@
+package cloudauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	// gcpScope is the OAuth2 scope requested for registry access.
+	gcpScope = "https://www.googleapis.com/auth/cloud-platform"
+	// gcpDefaultTokenURI is the OAuth2 token endpoint, if not specified in the credentials file.
+	gcpDefaultTokenURI = "https://oauth2.googleapis.com/token"
+	// gcpRegistryUsername is the user name used with OAuth2 access tokens by Google registries.
+	gcpRegistryUsername = "oauth2accesstoken"
+)
+
+// gcpCredentialsFile is the subset of a Google application default credentials file we understand.
+type gcpCredentialsFile struct {
+	Type string `json:"type"`
+	// For "service_account"
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+	// For "authorized_user"
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// gcpTokenResponse is a response of the OAuth2 token endpoint or the metadata service.
+type gcpTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"` // Seconds
+}
+
+// gcpCredentials is a provider for Google Artifact Registry and Container Registry, using an OAuth2 access token
+// obtained from the application default credentials.
+func gcpCredentials(ctx context.Context, env *environment, registry string) (credentials, error) {
+	token, err := gcpAccessToken(ctx, env)
+	if err != nil {
+		return credentials{}, err
+	}
+	if token.AccessToken == "" {
+		return credentials{}, errors.New("no access token returned")
+	}
+	return credentials{
+		auth:    types.DockerAuthConfig{Username: gcpRegistryUsername, Password: token.AccessToken},
+		expires: env.now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// gcpAccessToken returns an access token using the file pointed to by $GOOGLE_APPLICATION_CREDENTIALS,
+// the gcloud application default credentials file, or the GCE metadata service, in that order.
+func gcpAccessToken(ctx context.Context, env *environment) (gcpTokenResponse, error) {
+	path := env.getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	explicit := path != ""
+	if !explicit {
+		configDir := env.getenv("CLOUDSDK_CONFIG")
+		if configDir == "" {
+			configDir = filepath.Join(env.homeDir, ".config", "gcloud")
+		}
+		path = filepath.Join(configDir, "application_default_credentials.json")
+	}
+	data, err := readOptionalFile(path)
+	if err != nil {
+		return gcpTokenResponse{}, err
+	}
+	if data == nil && explicit {
+		return gcpTokenResponse{}, fmt.Errorf("credentials file %q does not exist", path)
+	}
+	if data != nil {
+		var file gcpCredentialsFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return gcpTokenResponse{}, fmt.Errorf("parsing %q: %w", path, err)
+		}
+		return gcpFileAccessToken(ctx, env, &file)
+	}
+	return gcpMetadataAccessToken(ctx, env)
+}
+
+// gcpFileAccessToken returns an access token for the credentials in file.
+func gcpFileAccessToken(ctx context.Context, env *environment, file *gcpCredentialsFile) (gcpTokenResponse, error) {
+	tokenURI := file.TokenURI
+	if tokenURI == "" {
+		tokenURI = gcpDefaultTokenURI
+	}
+	var form url.Values
+	switch file.Type {
+	case "service_account":
+		assertion, err := gcpServiceAccountAssertion(file, tokenURI, env.now())
+		if err != nil {
+			return gcpTokenResponse{}, err
+		}
+		form = url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+	case "authorized_user":
+		form = url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {file.ClientID},
+			"client_secret": {file.ClientSecret},
+			"refresh_token": {file.RefreshToken},
+		}
+	default:
+		return gcpTokenResponse{}, fmt.Errorf("unsupported Google credentials type %q", file.Type)
+	}
+	var res gcpTokenResponse
+	if err := postForm(ctx, env.client, tokenURI, form, &res); err != nil {
+		return gcpTokenResponse{}, fmt.Errorf("obtaining a Google access token: %w", err)
+	}
+	return res, nil
+}
+
+// gcpServiceAccountAssertion returns a signed JWT asserting the identity of the service account in file, for use at tokenURI.
+func gcpServiceAccountAssertion(file *gcpCredentialsFile, tokenURI string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(file.PrivateKey))
+	if block == nil {
+		return "", errors.New("service account private key is not in PEM format")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		k, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("service account private key is not an RSA key")
+		}
+		key = k
+	} else {
+		k, err2 := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err2 != nil {
+			return "", fmt.Errorf("parsing service account private key: %w", err)
+		}
+		key = k
+	}
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if file.PrivateKeyID != "" {
+		header["kid"] = file.PrivateKeyID
+	}
+	claims := map[string]any{
+		"iss":   file.ClientEmail,
+		"scope": gcpScope,
+		"aud":   tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	hash := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("signing service account assertion: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// gcpMetadataAccessToken returns an access token of the default service account from the GCE metadata service.
+func gcpMetadataAccessToken(ctx context.Context, env *environment) (gcpTokenResponse, error) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   env.gceMetadataHost,
+		Path:   "/computeMetadata/v1/instance/service-accounts/default/token",
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return gcpTokenResponse{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := env.metadata.Do(req)
+	if err != nil {
+		return gcpTokenResponse{}, fmt.Errorf("%w: GCE metadata service not available: %v", errNoAmbientCredentials, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return gcpTokenResponse{}, fmt.Errorf("%w: the GCE instance has no service account", errNoAmbientCredentials)
+	}
+	if res.StatusCode != http.StatusOK || res.Header.Get("Metadata-Flavor") != "Google" {
+		return gcpTokenResponse{}, fmt.Errorf("%w: unexpected response of the GCE metadata service, status %d", errNoAmbientCredentials, res.StatusCode)
+	}
+	body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxAuthTokenBodySize)
+	if err != nil {
+		return gcpTokenResponse{}, err
+	}
+	var token gcpTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return gcpTokenResponse{}, fmt.Errorf("decoding GCE metadata service response: %w", err)
+	}
+	return token, nil
+}
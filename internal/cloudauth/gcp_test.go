@@ -0,0 +1,169 @@
+package cloudauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeGCPCredentialsFile writes file as a JSON credentials file in dir, and returns its path.
+func writeGCPCredentialsFile(t *testing.T, dir string, file gcpCredentialsFile) string {
+	data, err := json.Marshal(file)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "credentials.json")
+	err = os.WriteFile(path, data, 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestGCPCredentialsAuthorizedUser(t *testing.T) {
+	now := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {"client"},
+			"client_secret": {"clientSecret"},
+			"refresh_token": {"refresh"},
+		}, r.PostForm)
+		fmt.Fprint(w, `{"access_token":"access","expires_in":3599,"token_type":"Bearer"}`)
+	}))
+	defer server.Close()
+
+	env := newTestEnvironment(t, nil)
+	env.now = func() time.Time { return now }
+	configDir := filepath.Join(env.homeDir, ".config", "gcloud")
+	err := os.MkdirAll(configDir, 0o700)
+	require.NoError(t, err)
+	path := writeGCPCredentialsFile(t, configDir, gcpCredentialsFile{
+		Type:         "authorized_user",
+		ClientID:     "client",
+		ClientSecret: "clientSecret",
+		RefreshToken: "refresh",
+		TokenURI:     server.URL,
+	})
+	err = os.Rename(path, filepath.Join(configDir, "application_default_credentials.json"))
+	require.NoError(t, err)
+
+	creds, err := gcpCredentials(context.Background(), env, "gcr.io")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: gcpRegistryUsername, Password: "access"}, creds.auth)
+	assert.True(t, creds.expires.Equal(now.Add(3599*time.Second)))
+}
+
+func TestGCPCredentialsServiceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		require.Len(t, parts, 3)
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature))
+
+		var header map[string]string
+		headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(headerJSON, &header))
+		assert.Equal(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": "keyID"}, header)
+		var claims map[string]any
+		claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(claimsJSON, &claims))
+		assert.Equal(t, "sa@project.iam.gserviceaccount.com", claims["iss"])
+		assert.Equal(t, gcpScope, claims["scope"])
+		assert.Equal(t, server.URL, claims["aud"])
+
+		fmt.Fprint(w, `{"access_token":"saAccess","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	env := newTestEnvironment(t, nil)
+	path := writeGCPCredentialsFile(t, t.TempDir(), gcpCredentialsFile{
+		Type:         "service_account",
+		ClientEmail:  "sa@project.iam.gserviceaccount.com",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		PrivateKeyID: "keyID",
+		TokenURI:     server.URL,
+	})
+	env.getenv = func(name string) string {
+		if name == "GOOGLE_APPLICATION_CREDENTIALS" {
+			return path
+		}
+		return ""
+	}
+	creds, err := gcpCredentials(context.Background(), env, "us-docker.pkg.dev")
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{Username: gcpRegistryUsername, Password: "saAccess"}, creds.auth)
+
+	// An explicitly configured file which does not exist is an error
+	env = newTestEnvironment(t, map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": filepath.Join(t.TempDir(), "missing.json")})
+	_, err = gcpCredentials(context.Background(), env, "gcr.io")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errNoAmbientCredentials)
+}
+
+func TestGCPMetadataAccessToken(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		flavor  string
+		status  int
+		noCreds bool
+	}{
+		{"success", "Google", http.StatusOK, false},
+		{"no service account", "Google", http.StatusNotFound, true},
+		{"not a metadata server", "", http.StatusOK, true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			assert.Equal(t, "/computeMetadata/v1/instance/service-accounts/default/token", r.URL.Path)
+			if c.flavor != "" {
+				w.Header().Set("Metadata-Flavor", c.flavor)
+			}
+			w.WriteHeader(c.status)
+			fmt.Fprint(w, `{"access_token":"metadataAccess","expires_in":1800,"token_type":"Bearer"}`)
+		}))
+		u, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		env := newTestEnvironment(t, nil)
+		env.gceMetadataHost = u.Host
+		token, err := gcpMetadataAccessToken(context.Background(), env)
+		server.Close()
+		if c.noCreds {
+			assert.ErrorIs(t, err, errNoAmbientCredentials, c.name)
+		} else {
+			require.NoError(t, err, c.name)
+			assert.Equal(t, gcpTokenResponse{AccessToken: "metadataAccess", ExpiresIn: 1800}, token, c.name)
+		}
+	}
+
+	// Unreachable metadata service
+	_, err := gcpAccessToken(context.Background(), newTestEnvironment(t, nil))
+	assert.ErrorIs(t, err, errNoAmbientCredentials)
+}
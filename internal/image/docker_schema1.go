@@ -12,7 +12,8 @@ import (
 )
 
 type manifestSchema1 struct {
-	m *manifest.Schema1
+	m         *manifest.Schema1
+	configOCI *imgspecv1.Image // If set, the result of converting m to an OCI config; callers get a copy.
 }
 
 func manifestSchema1FromManifest(manifestBlob []byte) (genericManifest, error) {
@@ -56,11 +57,18 @@ func (m *manifestSchema1) ConfigBlob(context.Context) ([]byte, error) {
 // layers in the resulting configuration isn't guaranteed to be returned to due how
 // old image manifests work (docker v2s1 especially).
 func (m *manifestSchema1) OCIConfig(ctx context.Context) (*imgspecv1.Image, error) {
-	v2s2, err := m.convertToManifestSchema2(ctx, &types.ManifestUpdateOptions{})
-	if err != nil {
-		return nil, err
+	if m.configOCI == nil {
+		v2s2, err := m.convertToManifestSchema2(ctx, &types.ManifestUpdateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		configOCI, err := v2s2.OCIConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.configOCI = configOCI
 	}
-	return v2s2.OCIConfig(ctx)
+	return cloneOCIConfig(m.configOCI), nil
 }
 
 // LayerInfos returns a list of BlobInfos of layers referenced by this image, in order (the root layer first, and then successive layered layers).
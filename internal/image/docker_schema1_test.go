@@ -264,6 +264,11 @@ func TestManifestSchema1Inspect(t *testing.T) {
 		ii, err := m.Inspect(context.Background())
 		require.NoError(t, err)
 		created := time.Date(2018, 1, 25, 0, 37, 48, 268558000, time.UTC)
+		history0Created := time.Date(2017, 11, 21, 16, 47, 27, 755341705, time.UTC)
+		history1Created := time.Date(2017, 11, 21, 16, 49, 37, 292899000, time.UTC)
+		history2Created := time.Date(2018, 1, 24, 21, 40, 32, 494686000, time.UTC)
+		history3Created := time.Date(2018, 1, 24, 22, 0, 57, 807862000, time.UTC)
+		history4Created := time.Date(2018, 1, 24, 23, 8, 25, 300741000, time.UTC)
 		var emptyAnnotations map[string]string
 		assert.Equal(t, types.ImageInspectInfo{
 			Tag:           "latest",
@@ -345,6 +350,32 @@ func TestManifestSchema1Inspect(t *testing.T) {
 				"KOLLA_INSTALL_METATYPE=rhos",
 				"PS1=$(tput bold)($(printenv KOLLA_SERVICE_NAME))$(tput sgr0)[$(id -un)@$(hostname -s) $(pwd)]$ ",
 			},
+			History: []types.ImageInspectHistory{{
+				Created:    &history0Created,
+				Comment:    "Imported from -",
+				LayerIndex: 0,
+			}, {
+				Created:    &history1Created,
+				CreatedBy:  "/bin/sh -c rm -f '/etc/yum.repos.d/compose-rpms-1.repo'",
+				Author:     "Red Hat, Inc.",
+				LayerIndex: 1,
+			}, {
+				Created:    &history2Created,
+				CreatedBy:  "/bin/sh -c rm -f '/etc/yum.repos.d/rhel-7.4.repo' '/etc/yum.repos.d/rhos-optools-12.0.repo' '/etc/yum.repos.d/rhos-12.0-container-yum-need_images.repo'",
+				LayerIndex: 2,
+			}, {
+				Created:    &history3Created,
+				CreatedBy:  "/bin/sh -c rm -f '/etc/yum.repos.d/rhel-7.4.repo' '/etc/yum.repos.d/rhos-optools-12.0.repo' '/etc/yum.repos.d/rhos-12.0-container-yum-need_images.repo'",
+				LayerIndex: 3,
+			}, {
+				Created:    &history4Created,
+				CreatedBy:  "/bin/sh -c rm -f '/etc/yum.repos.d/rhel-7.4.repo' '/etc/yum.repos.d/rhos-optools-12.0.repo' '/etc/yum.repos.d/rhos-12.0-container-yum-need_images.repo'",
+				LayerIndex: 4,
+			}, {
+				Created:    &created,
+				CreatedBy:  "/bin/sh -c #(nop)  USER [nova]",
+				LayerIndex: 5,
+			}},
 		}, *ii)
 	}
 }
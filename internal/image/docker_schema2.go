@@ -12,12 +12,12 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/pkg/blobinfocache/none"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 // GzippedEmptyLayer is a gzip-compressed version of an empty tar file (1024 NULL bytes)
@@ -39,6 +39,7 @@ const GzippedEmptyLayerDigest = digest.Digest("sha256:a3ed95caeb02ffe68cdd9fd844
 type manifestSchema2 struct {
 	src        types.ImageSource // May be nil if configBlob is not nil
 	configBlob []byte            // If set, corresponds to contents of ConfigDescriptor.
+	configOCI  *imgspecv1.Image  // If set, configBlob parsed as an OCI config; callers get a copy.
 	m          *manifest.Schema2
 }
 
@@ -80,18 +81,21 @@ func (m *manifestSchema2) ConfigInfo() types.BlobInfo {
 // layers in the resulting configuration isn't guaranteed to be returned to due how
 // old image manifests work (docker v2s1 especially).
 func (m *manifestSchema2) OCIConfig(ctx context.Context) (*imgspecv1.Image, error) {
-	configBlob, err := m.ConfigBlob(ctx)
-	if err != nil {
-		return nil, err
-	}
-	// docker v2s2 and OCI v1 are mostly compatible but v2s2 contains more fields
-	// than OCI v1. This unmarshal makes sure we drop docker v2s2
-	// fields that aren't needed in OCI v1.
-	configOCI := &imgspecv1.Image{}
-	if err := json.Unmarshal(configBlob, configOCI); err != nil {
-		return nil, err
+	if m.configOCI == nil {
+		configBlob, err := m.ConfigBlob(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// docker v2s2 and OCI v1 are mostly compatible but v2s2 contains more fields
+		// than OCI v1. This unmarshal makes sure we drop docker v2s2
+		// fields that aren't needed in OCI v1.
+		configOCI := &imgspecv1.Image{}
+		if err := json.Unmarshal(configBlob, configOCI); err != nil {
+			return nil, err
+		}
+		m.configOCI = configOCI
 	}
-	return configOCI, nil
+	return cloneOCIConfig(m.configOCI), nil
 }
 
 // ConfigBlob returns the blob described by ConfigInfo, iff ConfigInfo().Digest != ""; nil otherwise.
@@ -288,7 +292,7 @@ func (m *manifestSchema2) convertToManifestSchema1(ctx context.Context, options
 			emptyLayerBlobInfo := types.BlobInfo{Digest: GzippedEmptyLayerDigest, Size: int64(len(GzippedEmptyLayer))}
 
 			if !haveGzippedEmptyLayer {
-				logrus.Debugf("Uploading empty layer during conversion to schema 1")
+				log.DebugfContext(ctx, "Uploading empty layer during conversion to schema 1")
 				// Ideally we should update the relevant BlobInfoCache about this layer, but that would require passing it down here,
 				// and anyway this blob is so small that it’s easier to just copy it than to worry about figuring out another location where to get it.
 				info, err := dest.PutBlob(ctx, bytes.NewReader(GzippedEmptyLayer), emptyLayerBlobInfo, none.NoCache, false)
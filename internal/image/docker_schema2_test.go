@@ -244,6 +244,30 @@ func TestManifestSchema2EmbeddedDockerReferenceConflicts(t *testing.T) {
 	}
 }
 
+// assertInspectHistoryLikeFixture verifies that history matches the history in fixtures/schema2-config.json
+// (and fixtures/oci1-config.json, which contains the same data).
+func assertInspectHistoryLikeFixture(t *testing.T, history []types.ImageInspectHistory) {
+	require.Len(t, history, 15)
+	layerIndexes := []int{}
+	for _, h := range history {
+		layerIndexes = append(layerIndexes, h.LayerIndex)
+		assert.Equal(t, h.LayerIndex == -1, h.EmptyLayer)
+		require.NotNil(t, h.Created)
+	}
+	assert.Equal(t, []int{0, -1, -1, -1, 1, -1, 2, -1, -1, -1, -1, 3, 4, -1, -1}, layerIndexes)
+	assert.Equal(t, types.ImageInspectHistory{
+		Created:    &[]time.Time{time.Date(2016, 9, 23, 18, 8, 50, 537223822, time.UTC)}[0],
+		CreatedBy:  "/bin/sh -c #(nop) ADD file:c6c23585ab140b0b320d4e99bc1b0eb544c9e96c24d90fec5e069a6d57d335ca in / ",
+		LayerIndex: 0,
+	}, history[0])
+	assert.Equal(t, types.ImageInspectHistory{
+		Created:    &[]time.Time{time.Date(2016, 9, 23, 23, 20, 45, 789764590, time.UTC)}[0],
+		CreatedBy:  `/bin/sh -c #(nop)  CMD ["httpd-foreground"]`,
+		EmptyLayer: true,
+		LayerIndex: -1,
+	}, history[14])
+}
+
 func TestManifestSchema2Inspect(t *testing.T) {
 	configJSON, err := os.ReadFile("fixtures/schema2-config.json")
 	require.NoError(t, err)
@@ -252,6 +276,8 @@ func TestManifestSchema2Inspect(t *testing.T) {
 	ii, err := m.Inspect(context.Background())
 	require.NoError(t, err)
 	created := time.Date(2016, 9, 23, 23, 20, 45, 789764590, time.UTC)
+	assertInspectHistoryLikeFixture(t, ii.History)
+	ii.History = nil // Verified above; spelling out all 15 entries would drown the rest of the comparison.
 
 	var emptyAnnotations map[string]string
 	assert.Equal(t, types.ImageInspectInfo{
@@ -269,30 +295,35 @@ func TestManifestSchema2Inspect(t *testing.T) {
 			"sha256:960e52ecf8200cbd84e70eb2ad8678f4367e50d14357021872c10fa3fc5935fa",
 		},
 		LayersData: []types.ImageInspectLayer{{
-			MIMEType:    "application/vnd.docker.image.rootfs.diff.tar.gzip",
-			Digest:      "sha256:6a5a5368e0c2d3e5909184fa28ddfd56072e7ff3ee9a945876f7eee5896ef5bb",
-			Size:        51354364,
-			Annotations: emptyAnnotations,
+			MIMEType:           "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:             "sha256:6a5a5368e0c2d3e5909184fa28ddfd56072e7ff3ee9a945876f7eee5896ef5bb",
+			Size:               51354364,
+			UncompressedDigest: "sha256:142a601d97936307e75220c35dde0348971a9584c21e7cb42e1f7004005432ab",
+			Annotations:        emptyAnnotations,
 		}, {
-			MIMEType:    "application/vnd.docker.image.rootfs.diff.tar.gzip",
-			Digest:      "sha256:1bbf5d58d24c47512e234a5623474acf65ae00d4d1414272a893204f44cc680c",
-			Size:        150,
-			Annotations: emptyAnnotations,
+			MIMEType:           "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:             "sha256:1bbf5d58d24c47512e234a5623474acf65ae00d4d1414272a893204f44cc680c",
+			Size:               150,
+			UncompressedDigest: "sha256:90fcc66ad3be9f1757f954b750deb37032f208428aa12599fcb02182b9065a9c",
+			Annotations:        emptyAnnotations,
 		}, {
-			MIMEType:    "application/vnd.docker.image.rootfs.diff.tar.gzip",
-			Digest:      "sha256:8f5dc8a4b12c307ac84de90cdd9a7f3915d1be04c9388868ca118831099c67a9",
-			Size:        11739507,
-			Annotations: emptyAnnotations,
+			MIMEType:           "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:             "sha256:8f5dc8a4b12c307ac84de90cdd9a7f3915d1be04c9388868ca118831099c67a9",
+			Size:               11739507,
+			UncompressedDigest: "sha256:5a8624bb7e76d1e6829f9c64c43185e02bc07f97a2189eb048609a8914e72c56",
+			Annotations:        emptyAnnotations,
 		}, {
-			MIMEType:    "application/vnd.docker.image.rootfs.diff.tar.gzip",
-			Digest:      "sha256:bbd6b22eb11afce63cc76f6bc41042d99f10d6024c96b655dafba930b8d25909",
-			Size:        8841833,
-			Annotations: emptyAnnotations,
+			MIMEType:           "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:             "sha256:bbd6b22eb11afce63cc76f6bc41042d99f10d6024c96b655dafba930b8d25909",
+			Size:               8841833,
+			UncompressedDigest: "sha256:d349ff6b3afc6a2800054768c82bfbf4289c9aa5da55c1290f802943dcd4d1e9",
+			Annotations:        emptyAnnotations,
 		}, {
-			MIMEType:    "application/vnd.docker.image.rootfs.diff.tar.gzip",
-			Digest:      "sha256:960e52ecf8200cbd84e70eb2ad8678f4367e50d14357021872c10fa3fc5935fa",
-			Size:        291,
-			Annotations: emptyAnnotations,
+			MIMEType:           "application/vnd.docker.image.rootfs.diff.tar.gzip",
+			Digest:             "sha256:960e52ecf8200cbd84e70eb2ad8678f4367e50d14357021872c10fa3fc5935fa",
+			Size:               291,
+			UncompressedDigest: "sha256:8c064bb1f60e84fa8cc6079b6d2e76e0423389fd6aeb7e497dfdae5e05b2b25b",
+			Annotations:        emptyAnnotations,
 		},
 		},
 		Author: "",
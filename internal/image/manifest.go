@@ -3,6 +3,9 @@ package image
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/manifest"
@@ -25,6 +28,7 @@ type genericManifest interface {
 	// OCIConfig returns the image configuration as per OCI v1 image-spec. Information about
 	// layers in the resulting configuration isn't guaranteed to be returned to due how
 	// old image manifests work (docker v2s1 especially).
+	// The result is cached; each call returns a separate copy, which the caller may modify.
 	OCIConfig(context.Context) (*imgspecv1.Image, error)
 	// LayerInfos returns a list of BlobInfos of layers referenced by this image, in order (the root layer first, and then successive layered layers).
 	// The Digest field is guaranteed to be provided; Size may be -1.
@@ -119,3 +123,32 @@ func convertManifestIfRequiredWithUpdate(ctx context.Context, options types.Mani
 	optionsCopy.ManifestMIMEType = ""
 	return convertedImage.UpdatedImage(ctx, optionsCopy)
 }
+
+// cloneOCIConfig returns a deep copy of config, so that callers of OCIConfig can freely modify the result
+// without affecting the value cached by the genericManifest implementation.
+func cloneOCIConfig(config *imgspecv1.Image) *imgspecv1.Image {
+	res := *config
+	res.Created = cloneTime(config.Created)
+	res.OSFeatures = slices.Clone(config.OSFeatures)
+	res.Config.ExposedPorts = maps.Clone(config.Config.ExposedPorts)
+	res.Config.Env = slices.Clone(config.Config.Env)
+	res.Config.Entrypoint = slices.Clone(config.Config.Entrypoint)
+	res.Config.Cmd = slices.Clone(config.Config.Cmd)
+	res.Config.Volumes = maps.Clone(config.Config.Volumes)
+	res.Config.Labels = maps.Clone(config.Config.Labels)
+	res.RootFS.DiffIDs = slices.Clone(config.RootFS.DiffIDs)
+	res.History = slices.Clone(config.History)
+	for i := range res.History {
+		res.History[i].Created = cloneTime(res.History[i].Created)
+	}
+	return &res
+}
+
+// cloneTime returns a copy of t, or nil if t is nil.
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	res := *t
+	return &res
+}
@@ -2,9 +2,12 @@ package image
 
 import (
 	"testing"
+	"time"
 
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -69,3 +72,47 @@ func TestManifestLayerInfosToBlobInfos(t *testing.T) {
 		},
 	}, blobs)
 }
+
+func TestCloneOCIConfig(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	newConfig := func() *imgspecv1.Image {
+		created := created
+		historyCreated := created
+		return &imgspecv1.Image{
+			Created:  &created,
+			Platform: imgspecv1.Platform{OSFeatures: []string{"f1"}},
+			Config: imgspecv1.ImageConfig{
+				User:         "user",
+				ExposedPorts: map[string]struct{}{"80/tcp": {}},
+				Env:          []string{"A=B"},
+				Entrypoint:   []string{"/entrypoint"},
+				Cmd:          []string{"cmd"},
+				Volumes:      map[string]struct{}{"/data": {}},
+				Labels:       map[string]string{"l": "v"},
+				StopSignal:   "SIGTERM",
+			},
+			RootFS:  imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{"sha256:aaaa"}},
+			History: []imgspecv1.History{{Created: &historyCreated, CreatedBy: "step"}},
+		}
+	}
+
+	original := newConfig()
+	clone := cloneOCIConfig(original)
+	assert.Equal(t, original, clone)
+
+	*clone.Created = time.Time{}
+	clone.OSFeatures[0] = "modified"
+	clone.Config.ExposedPorts["443/tcp"] = struct{}{}
+	clone.Config.Env[0] = "modified"
+	clone.Config.Entrypoint[0] = "modified"
+	clone.Config.Cmd[0] = "modified"
+	clone.Config.Volumes["/other"] = struct{}{}
+	clone.Config.Labels["l"] = "modified"
+	clone.RootFS.DiffIDs[0] = "sha256:bbbb"
+	*clone.History[0].Created = time.Time{}
+	clone.History[0].CreatedBy = "modified"
+	assert.Equal(t, newConfig(), original)
+
+	// nil and empty values are preserved
+	assert.Equal(t, &imgspecv1.Image{}, cloneOCIConfig(&imgspecv1.Image{}))
+}
@@ -21,6 +21,7 @@ import (
 type manifestOCI1 struct {
 	src        types.ImageSource // May be nil if configBlob is not nil
 	configBlob []byte            // If set, corresponds to contents of m.Config.
+	configOCI  *imgspecv1.Image  // If set, the parsed configBlob; callers get a copy.
 	m          *manifest.OCI1
 }
 
@@ -91,15 +92,18 @@ func (m *manifestOCI1) OCIConfig(ctx context.Context) (*imgspecv1.Image, error)
 		return nil, internalManifest.NewNonImageArtifactError(&m.m.Manifest)
 	}
 
-	cb, err := m.ConfigBlob(ctx)
-	if err != nil {
-		return nil, err
-	}
-	configOCI := &imgspecv1.Image{}
-	if err := json.Unmarshal(cb, configOCI); err != nil {
-		return nil, err
+	if m.configOCI == nil {
+		cb, err := m.ConfigBlob(ctx)
+		if err != nil {
+			return nil, err
+		}
+		configOCI := &imgspecv1.Image{}
+		if err := json.Unmarshal(cb, configOCI); err != nil {
+			return nil, err
+		}
+		m.configOCI = configOCI
 	}
-	return configOCI, nil
+	return cloneOCIConfig(m.configOCI), nil
 }
 
 // LayerInfos returns a list of BlobInfos of layers referenced by this image, in order (the root layer first, and then successive layered layers).
@@ -220,6 +220,14 @@ func TestManifestOCI1OCIConfig(t *testing.T) {
 		config, err := m.OCIConfig(context.Background())
 		require.NoError(t, err)
 		assert.Equal(t, &expectedConfig, config)
+
+		// The parsed config is cached, but modifying the returned value does not affect later callers.
+		config.Config.Env[0] = "modified"
+		config.Config.Labels = map[string]string{"modified": "true"}
+		config.History[0].CreatedBy = "modified"
+		config, err = m.OCIConfig(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, &expectedConfig, config)
 	}
 
 	// “Any extra fields in the Image JSON struct are considered implementation specific
@@ -312,6 +320,8 @@ func TestManifestOCI1Inspect(t *testing.T) {
 	} {
 		ii, err := m.Inspect(context.Background())
 		require.NoError(t, err)
+		assertInspectHistoryLikeFixture(t, ii.History)
+		ii.History = nil // Verified above
 		assert.Equal(t, types.ImageInspectInfo{
 			Tag:           "",
 			Created:       &created,
@@ -327,30 +337,35 @@ func TestManifestOCI1Inspect(t *testing.T) {
 				"sha256:960e52ecf8200cbd84e70eb2ad8678f4367e50d14357021872c10fa3fc5935fa",
 			},
 			LayersData: []types.ImageInspectLayer{{
-				MIMEType:    "application/vnd.oci.image.layer.v1.tar+gzip",
-				Digest:      "sha256:6a5a5368e0c2d3e5909184fa28ddfd56072e7ff3ee9a945876f7eee5896ef5bb",
-				Size:        51354364,
-				Annotations: emptyAnnotations,
+				MIMEType:           "application/vnd.oci.image.layer.v1.tar+gzip",
+				Digest:             "sha256:6a5a5368e0c2d3e5909184fa28ddfd56072e7ff3ee9a945876f7eee5896ef5bb",
+				Size:               51354364,
+				UncompressedDigest: "sha256:142a601d97936307e75220c35dde0348971a9584c21e7cb42e1f7004005432ab",
+				Annotations:        emptyAnnotations,
 			}, {
-				MIMEType:    "application/vnd.oci.image.layer.v1.tar+gzip",
-				Digest:      "sha256:1bbf5d58d24c47512e234a5623474acf65ae00d4d1414272a893204f44cc680c",
-				Size:        150,
-				Annotations: emptyAnnotations,
+				MIMEType:           "application/vnd.oci.image.layer.v1.tar+gzip",
+				Digest:             "sha256:1bbf5d58d24c47512e234a5623474acf65ae00d4d1414272a893204f44cc680c",
+				Size:               150,
+				UncompressedDigest: "sha256:90fcc66ad3be9f1757f954b750deb37032f208428aa12599fcb02182b9065a9c",
+				Annotations:        emptyAnnotations,
 			}, {
-				MIMEType:    "application/vnd.oci.image.layer.v1.tar+gzip",
-				Digest:      "sha256:8f5dc8a4b12c307ac84de90cdd9a7f3915d1be04c9388868ca118831099c67a9",
-				Size:        11739507,
-				Annotations: emptyAnnotations,
+				MIMEType:           "application/vnd.oci.image.layer.v1.tar+gzip",
+				Digest:             "sha256:8f5dc8a4b12c307ac84de90cdd9a7f3915d1be04c9388868ca118831099c67a9",
+				Size:               11739507,
+				UncompressedDigest: "sha256:5a8624bb7e76d1e6829f9c64c43185e02bc07f97a2189eb048609a8914e72c56",
+				Annotations:        emptyAnnotations,
 			}, {
-				MIMEType:    "application/vnd.oci.image.layer.v1.tar+gzip",
-				Digest:      "sha256:bbd6b22eb11afce63cc76f6bc41042d99f10d6024c96b655dafba930b8d25909",
-				Size:        8841833,
-				Annotations: map[string]string{"test-annotation-2": "two"},
+				MIMEType:           "application/vnd.oci.image.layer.v1.tar+gzip",
+				Digest:             "sha256:bbd6b22eb11afce63cc76f6bc41042d99f10d6024c96b655dafba930b8d25909",
+				Size:               8841833,
+				UncompressedDigest: "sha256:d349ff6b3afc6a2800054768c82bfbf4289c9aa5da55c1290f802943dcd4d1e9",
+				Annotations:        map[string]string{"test-annotation-2": "two"},
 			}, {
-				MIMEType:    "application/vnd.oci.image.layer.v1.tar+gzip",
-				Digest:      "sha256:960e52ecf8200cbd84e70eb2ad8678f4367e50d14357021872c10fa3fc5935fa",
-				Size:        291,
-				Annotations: emptyAnnotations,
+				MIMEType:           "application/vnd.oci.image.layer.v1.tar+gzip",
+				Digest:             "sha256:960e52ecf8200cbd84e70eb2ad8678f4367e50d14357021872c10fa3fc5935fa",
+				Size:               291,
+				UncompressedDigest: "sha256:8c064bb1f60e84fa8cc6079b6d2e76e0423389fd6aeb7e497dfdae5e05b2b25b",
+				Annotations:        emptyAnnotations,
 			},
 			},
 			Author: "",
@@ -25,8 +25,13 @@ type UnparsedImage struct {
 	// Valid iff cachedManifest is not nil.
 	cachedManifestMIMEType string
 	cachedSignatures       []signature.Signature // A private cache for Signatures(); nil if not yet known.
+	cachedTopLevel         *UnparsedImage        // A private cache for TopLevel(); nil if not yet known.
 }
 
+// Compile-time check that UnparsedImage implements private.UnparsedImageWithTopLevel and private.UnparsedImageWithReferrers.
+var _ private.UnparsedImageWithTopLevel = (*UnparsedImage)(nil)
+var _ private.UnparsedImageWithReferrers = (*UnparsedImage)(nil)
+
 // UnparsedInstance returns a types.UnparsedImage implementation for (source, instanceDigest).
 // If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list).
 //
@@ -117,3 +122,33 @@ func (i *UnparsedImage) UntrustedSignatures(ctx context.Context) ([]signature.Si
 	}
 	return i.cachedSignatures, nil
 }
+
+// TopLevel returns the top-level image (typically a manifest list) of the same source if i is an instance of a
+// multi-platform image (i.e. it was created with a non-nil instanceDigest), or nil if i is a top-level image.
+func (i *UnparsedImage) TopLevel() private.UnparsedImage {
+	if i.instanceDigest == nil {
+		return nil
+	}
+	if i.cachedTopLevel == nil {
+		i.cachedTopLevel = &UnparsedImage{src: i.src}
+	}
+	return i.cachedTopLevel
+}
+
+// UntrustedReferrerArtifacts returns the artifacts of artifactType attached as referrers to the manifest of i.
+// It fails if the transport does not support reading referrers.
+func (i *UnparsedImage) UntrustedReferrerArtifacts(ctx context.Context, artifactType string) ([]private.ReferrerArtifact, error) {
+	referrerSource, ok := i.src.(private.ReferrerArtifactSource)
+	if !ok {
+		return nil, fmt.Errorf("reading referrers is not supported by the %q transport", i.src.Reference().Transport().Name())
+	}
+	m, _, err := i.Manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(m)
+	if err != nil {
+		return nil, fmt.Errorf("computing manifest digest: %w", err)
+	}
+	return referrerSource.GetReferrerArtifacts(ctx, manifestDigest, artifactType)
+}
@@ -2,6 +2,7 @@ package imagedestination
 
 import (
 	"context"
+	"fmt"
 	"io"
 
 	"github.com/containers/image/v5/internal/imagedestination/stubs"
@@ -33,11 +34,51 @@ func FromPublic(dest types.ImageDestination) private.ImageDestination {
 	if dest2, ok := dest.(private.ImageDestination); ok {
 		return dest2
 	}
-	return &wrapped{
+	w := &wrapped{
 		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(dest.Reference()),
 
 		ImageDestination: dest,
 	}
+	if partial, ok := dest.(types.PartialBlobDestination); ok {
+		return &wrappedPartial{
+			wrapped: w,
+			partial: partial,
+		}
+	}
+	return w
+}
+
+// wrappedPartial is a wrapped for a destination which also implements types.PartialBlobDestination.
+type wrappedPartial struct {
+	*wrapped
+	partial types.PartialBlobDestination
+}
+
+// SupportsPutBlobPartial returns true if PutBlobPartial is supported.
+func (w *wrappedPartial) SupportsPutBlobPartial() bool {
+	return w.partial.SupportsPutBlobPartial()
+}
+
+// PutBlobPartial attempts to create a blob using the data that is already present
+// at the destination. chunkAccessor is accessed in a non-sequential way to retrieve the missing chunks.
+// It is available only if SupportsPutBlobPartial().
+// Even if SupportsPutBlobPartial() returns true, the call can fail, in which case the caller
+// should fall back to PutBlobWithOptions.
+func (w *wrappedPartial) PutBlobPartial(ctx context.Context, chunkAccessor private.BlobChunkAccessor, srcInfo types.BlobInfo, options private.PutBlobPartialOptions) (private.UploadedBlob, error) {
+	res, err := w.partial.PutBlobPartial(ctx, chunkAccessor, srcInfo, types.PutBlobPartialOptions{
+		Cache:      options.Cache,
+		LayerIndex: options.LayerIndex,
+	})
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	if res.Digest == "" {
+		return private.UploadedBlob{}, fmt.Errorf("internal error: PutBlobPartial of %s did not return a digest", srcInfo.Digest)
+	}
+	return private.UploadedBlob{
+		Digest: res.Digest,
+		Size:   res.Size,
+	}, nil
 }
 
 // PutBlobWithOptions writes contents of stream and returns data representing the result.
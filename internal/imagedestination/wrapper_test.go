@@ -0,0 +1,97 @@
+package imagedestination
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct{ types.ImageTransport }
+
+func (testTransport) Name() string { return "test" }
+
+type testReference struct{ types.ImageReference }
+
+func (testReference) Transport() types.ImageTransport { return testTransport{} }
+
+// publicDest is a types.ImageDestination; only Reference is implemented.
+type publicDest struct{ types.ImageDestination }
+
+func (publicDest) Reference() types.ImageReference { return testReference{} }
+
+// partialDest is a publicDest which also implements types.PartialBlobDestination.
+type partialDest struct {
+	publicDest
+	supported   bool
+	result      types.BlobInfo
+	err         error
+	gotSrcInfo  types.BlobInfo
+	gotOptions  types.PutBlobPartialOptions
+	gotAccessor types.BlobChunkAccessor
+}
+
+func (d *partialDest) SupportsPutBlobPartial() bool { return d.supported }
+
+func (d *partialDest) PutBlobPartial(ctx context.Context, chunkAccessor types.BlobChunkAccessor, srcInfo types.BlobInfo, options types.PutBlobPartialOptions) (types.BlobInfo, error) {
+	d.gotAccessor = chunkAccessor
+	d.gotSrcInfo = srcInfo
+	d.gotOptions = options
+	return d.result, d.err
+}
+
+type nullChunkAccessor struct{}
+
+func (nullChunkAccessor) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	return nil, nil, errors.New("not implemented")
+}
+
+func TestFromPublicPutBlobPartial(t *testing.T) {
+	ctx := context.Background()
+	srcInfo := types.BlobInfo{Digest: digest.Digest("sha256:" + "1111111111111111111111111111111111111111111111111111111111111111"), Size: 10}
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	accessor := nullChunkAccessor{}
+
+	// A destination implementing only types.ImageDestination does not support partial blobs.
+	dest := FromPublic(publicDest{})
+	assert.False(t, dest.SupportsPutBlobPartial())
+	_, err := dest.PutBlobPartial(ctx, accessor, srcInfo, private.PutBlobPartialOptions{Cache: cache})
+	assert.Error(t, err)
+
+	// A types.PartialBlobDestination is used
+	partial := &partialDest{
+		supported: true,
+		result:    types.BlobInfo{Digest: srcInfo.Digest, Size: 20},
+	}
+	dest = FromPublic(partial)
+	assert.True(t, dest.SupportsPutBlobPartial())
+	res, err := dest.PutBlobPartial(ctx, accessor, srcInfo, private.PutBlobPartialOptions{Cache: cache, LayerIndex: 3})
+	require.NoError(t, err)
+	assert.Equal(t, private.UploadedBlob{Digest: srcInfo.Digest, Size: 20}, res)
+	assert.Equal(t, srcInfo, partial.gotSrcInfo)
+	assert.Equal(t, accessor, partial.gotAccessor)
+	assert.Equal(t, 3, partial.gotOptions.LayerIndex)
+	assert.NotNil(t, partial.gotOptions.Cache)
+
+	partial.supported = false
+	assert.False(t, dest.SupportsPutBlobPartial())
+
+	// Errors are propagated
+	partial.err = errors.New("partial failure")
+	_, err = dest.PutBlobPartial(ctx, accessor, srcInfo, private.PutBlobPartialOptions{Cache: cache})
+	assert.ErrorIs(t, err, partial.err)
+
+	// A result without a digest is rejected
+	partial.err = nil
+	partial.result = types.BlobInfo{Size: 20}
+	_, err = dest.PutBlobPartial(ctx, accessor, srcInfo, private.PutBlobPartialOptions{Cache: cache})
+	assert.Error(t, err)
+}
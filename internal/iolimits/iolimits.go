@@ -36,9 +36,15 @@ const (
 	// MaxOpenShiftStatusBody is the maximum allowed size of an OpenShift status body.
 	// The limit of 4 MB is considered to be greatly sufficient.
 	MaxOpenShiftStatusBody = 4 * megaByte
+	// MaxReferrerArtifactBodySize is the maximum allowed size of a referrer artifact read into memory.
+	// The limit of 64 MB allows for SBOMs of large images.
+	MaxReferrerArtifactBodySize = 64 * megaByte
 	// MaxTarFileManifestSize is the maximum allowed size of a (docker save)-like manifest (which may contain multiple images)
 	// The limit of 1 MB is considered to be greatly sufficient.
 	MaxTarFileManifestSize = megaByte
+	// MaxExternalEvaluatorOutputSize is the maximum allowed size of the output of an external policy evaluator.
+	// The limit of 1 MB is considered to be greatly sufficient.
+	MaxExternalEvaluatorOutputSize = megaByte
 )
 
 // ReadAtMost reads from reader and errors out if the specified limit (in bytes) is exceeded.
@@ -0,0 +1,67 @@
+// Package log provides printf-style logging functions for use within this library,
+// writing to the logger selected by the pkg/logging package.
+//
+// Use the …Context variants whenever a context.Context for the current operation is available,
+// so that loggers set using logging.WithLogger are respected.
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/containers/image/v5/pkg/logging"
+)
+
+// logf formats a message and logs it at level to logger, attributing it to the caller of the exported function.
+func logf(ctx context.Context, logger *slog.Logger, level slog.Level, format string, args []any) {
+	if !logger.Enabled(ctx, level) { // Don’t format the message if it would be discarded anyway.
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip runtime.Callers, logf, and the exported function calling logf.
+	record := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, args...), pcs[0])
+	_ = logger.Handler().Handle(ctx, record)
+}
+
+// Debugf logs a debug-level message to the default logger.
+func Debugf(format string, args ...any) {
+	logf(context.Background(), logging.Default(), slog.LevelDebug, format, args)
+}
+
+// Infof logs an info-level message to the default logger.
+func Infof(format string, args ...any) {
+	logf(context.Background(), logging.Default(), slog.LevelInfo, format, args)
+}
+
+// Warnf logs a warning-level message to the default logger.
+func Warnf(format string, args ...any) {
+	logf(context.Background(), logging.Default(), slog.LevelWarn, format, args)
+}
+
+// Errorf logs an error-level message to the default logger.
+func Errorf(format string, args ...any) {
+	logf(context.Background(), logging.Default(), slog.LevelError, format, args)
+}
+
+// DebugfContext logs a debug-level message to the logger for ctx.
+func DebugfContext(ctx context.Context, format string, args ...any) {
+	logf(ctx, logging.FromContext(ctx), slog.LevelDebug, format, args)
+}
+
+// InfofContext logs an info-level message to the logger for ctx.
+func InfofContext(ctx context.Context, format string, args ...any) {
+	logf(ctx, logging.FromContext(ctx), slog.LevelInfo, format, args)
+}
+
+// WarnfContext logs a warning-level message to the logger for ctx.
+func WarnfContext(ctx context.Context, format string, args ...any) {
+	logf(ctx, logging.FromContext(ctx), slog.LevelWarn, format, args)
+}
+
+// ErrorfContext logs an error-level message to the logger for ctx.
+func ErrorfContext(ctx context.Context, format string, args ...any) {
+	logf(ctx, logging.FromContext(ctx), slog.LevelError, format, args)
+}
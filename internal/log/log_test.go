@@ -0,0 +1,100 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler is a slog.Handler which records all records at or above minLevel.
+type recordingHandler struct {
+	minLevel slog.Level
+	mutex    sync.Mutex
+	records  []slog.Record
+}
+
+func (h *recordingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.minLevel
+}
+
+func (h *recordingHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler { panic("unexpected WithAttrs") }
+func (h *recordingHandler) WithGroup(name string) slog.Handler       { panic("unexpected WithGroup") }
+
+// recordFunction returns the name of the function which created record.
+func recordFunction(record slog.Record) string {
+	frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+	return frame.Function
+}
+
+func TestContextFunctions(t *testing.T) {
+	handler := &recordingHandler{minLevel: slog.LevelDebug}
+	ctx := logging.WithLogger(context.Background(), slog.New(handler))
+
+	for _, c := range []struct {
+		fn    func(ctx context.Context, format string, args ...any)
+		level slog.Level
+	}{
+		{DebugfContext, slog.LevelDebug},
+		{InfofContext, slog.LevelInfo},
+		{WarnfContext, slog.LevelWarn},
+		{ErrorfContext, slog.LevelError},
+	} {
+		handler.records = nil
+		c.fn(ctx, "value %d, %q", 1, "s")
+		require.Len(t, handler.records, 1, c.level.String())
+		record := handler.records[0]
+		assert.Equal(t, c.level, record.Level)
+		assert.Equal(t, `value 1, "s"`, record.Message)
+		assert.Equal(t, "github.com/containers/image/v5/internal/log.TestContextFunctions", recordFunction(record))
+	}
+
+	// Disabled levels are not logged
+	handler.minLevel = slog.LevelWarn
+	handler.records = nil
+	DebugfContext(ctx, "discarded")
+	InfofContext(ctx, "discarded")
+	assert.Empty(t, handler.records)
+}
+
+func TestDefaultFunctions(t *testing.T) {
+	handler := &recordingHandler{minLevel: slog.LevelDebug}
+	logging.SetDefault(slog.New(handler))
+	defer logging.SetDefault(nil)
+
+	for _, c := range []struct {
+		fn    func(format string, args ...any)
+		level slog.Level
+	}{
+		{Debugf, slog.LevelDebug},
+		{Infof, slog.LevelInfo},
+		{Warnf, slog.LevelWarn},
+		{Errorf, slog.LevelError},
+	} {
+		handler.records = nil
+		c.fn("value %d", 2)
+		require.Len(t, handler.records, 1, c.level.String())
+		record := handler.records[0]
+		assert.Equal(t, c.level, record.Level)
+		assert.Equal(t, "value 2", record.Message)
+		assert.Equal(t, "github.com/containers/image/v5/internal/log.TestDefaultFunctions", recordFunction(record))
+	}
+
+	// The …Context functions use the default logger if the context does not specify one.
+	handler.records = nil
+	InfofContext(context.Background(), "fallback")
+	require.Len(t, handler.records, 1)
+	assert.Equal(t, "fallback", handler.records[0].Message)
+}
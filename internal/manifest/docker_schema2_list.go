@@ -128,6 +128,19 @@ func (index *Schema2ListPublic) editInstances(editInstances []ListEdit) error {
 				},
 				schema2PlatformSpecFromOCIPlatform(*editInstance.AddPlatform),
 			})
+		case ListOpRemove:
+			if err := editInstance.RemoveDigest.Validate(); err != nil {
+				return fmt.Errorf("Schema2List.EditInstances: Attempting to remove %s which is an invalid digest: %w", editInstance.RemoveDigest, err)
+			}
+			if !slices.ContainsFunc(index.Manifests, func(m Schema2ManifestDescriptor) bool {
+				return m.Digest == editInstance.RemoveDigest
+			}) {
+				return fmt.Errorf("Schema2List.EditInstances: digest %s not found", editInstance.RemoveDigest)
+			}
+			// slices.DeleteFunc modifies the backing array in place; clone it first, as with additions below.
+			index.Manifests = slices.DeleteFunc(slices.Clone(index.Manifests), func(m Schema2ManifestDescriptor) bool {
+				return m.Digest == editInstance.RemoveDigest
+			})
 		default:
 			return fmt.Errorf("internal error: invalid operation: %d", editInstance.ListOperation)
 		}
@@ -156,13 +169,20 @@ func (list *Schema2ListPublic) ChooseInstance(ctx *types.SystemContext) (digest.
 	if err != nil {
 		return "", fmt.Errorf("getting platform information %#v: %w", ctx, err)
 	}
+	osVersions := platform.NewOSVersionMatcher(ctx)
 	for _, wantedPlatform := range wantedPlatforms {
-		for _, d := range list.Manifests {
+		var bestMatch *Schema2ManifestDescriptor
+		for i := range list.Manifests {
+			d := &list.Manifests[i]
 			imagePlatform := ociPlatformFromSchema2PlatformSpec(d.Platform)
-			if platform.MatchesPlatform(imagePlatform, wantedPlatform) {
-				return d.Digest, nil
+			if platform.MatchesPlatform(imagePlatform, wantedPlatform) && osVersions.Matches(d.Platform.OSVersion) &&
+				(bestMatch == nil || osVersions.Compare(d.Platform.OSVersion, bestMatch.Platform.OSVersion) < 0) {
+				bestMatch = d
 			}
 		}
+		if bestMatch != nil {
+			return bestMatch.Digest, nil
+		}
 	}
 	return "", fmt.Errorf("no image found in manifest list for architecture %q, variant %q, OS %q", wantedPlatforms[0].Architecture, wantedPlatforms[0].Variant, wantedPlatforms[0].OS)
 }
@@ -87,6 +87,19 @@ func TestSchema2ListEditInstances(t *testing.T) {
 		digest.Digest("sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
 		digest.Digest("sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"),
 	), list.Instances())
+
+	// Remove an instance
+	list, err = ListFromBlob(validManifest, GuessMIMEType(validManifest))
+	require.NoError(t, err)
+	originalInstances := list.Instances()
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: originalInstances[0]}})
+	require.NoError(t, err)
+	assert.Equal(t, originalInstances[1:], list.Instances())
+	// Removing a missing instance, or an invalid digest, fails
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: originalInstances[0]}})
+	assert.Error(t, err)
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: "sha256:../.."}})
+	assert.Error(t, err)
 }
 
 func TestSchema2ListFromManifest(t *testing.T) {
@@ -83,6 +83,7 @@ const (
 	listOpInvalid ListOp = iota
 	ListOpAdd
 	ListOpUpdate
+	ListOpRemove
 )
 
 // ListEdit includes the fields which a List's EditInstances() method will modify.
@@ -106,6 +107,9 @@ type ListEdit struct {
 	AddPlatform              *imgspecv1.Platform
 	AddAnnotations           map[string]string
 	AddCompressionAlgorithms []compression.Algorithm
+
+	// If Op = ListOpRemove. All instances with RemoveDigest are removed.
+	RemoveDigest digest.Digest
 }
 
 // ListPublicFromBlob parses a list of manifests.
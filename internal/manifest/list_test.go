@@ -159,3 +159,81 @@ func TestChooseInstance(t *testing.T) {
 		}
 	}
 }
+
+func TestChooseInstanceOSVersion(t *testing.T) {
+	versions := []string{"10.0.17763.5000", "10.0.20348.100", "10.0.20348.2000", ""}
+	components := []imgspecv1.Descriptor{}
+	for _, version := range versions {
+		components = append(components, imgspecv1.Descriptor{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Digest:    digest.FromString(version),
+			Size:      1,
+			Platform:  &imgspecv1.Platform{Architecture: "amd64", OS: "windows", OSVersion: version},
+		})
+	}
+	ociBlob, err := OCI1IndexPublicFromComponents(components, nil).Serialize()
+	require.NoError(t, err)
+	ociList, err := ListFromBlob(ociBlob, imgspecv1.MediaTypeImageIndex)
+	require.NoError(t, err)
+	schema2List, err := ociList.ConvertToMIMEType(DockerV2ListMediaType)
+	require.NoError(t, err)
+	// The same, without the instance with no os.version.
+	ociBlob, err = OCI1IndexPublicFromComponents(components[:3], nil).Serialize()
+	require.NoError(t, err)
+	ociListWithVersions, err := ListFromBlob(ociBlob, imgspecv1.MediaTypeImageIndex)
+	require.NoError(t, err)
+	schema2ListWithVersions, err := ociListWithVersions.ConvertToMIMEType(DockerV2ListMediaType)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		name     string
+		wanted   string
+		policy   types.OSVersionMatchPolicy
+		matcher  func(instanceVersion, wantedVersion string) bool
+		expected string // "-" if no instance should be matched when all instances have an os.version
+	}{
+		{"no os.version", "", types.OSVersionMatchExact, nil, versions[0]},
+		{"build, exact", "10.0.20348.2000", types.OSVersionMatchBuild, nil, "10.0.20348.2000"},
+		{"build, newest revision", "10.0.20348.2500", types.OSVersionMatchBuild, nil, "10.0.20348.2000"},
+		{"build, no match", "10.0.19041.1", types.OSVersionMatchBuild, nil, "-"},
+		{"build, invalid", "invalid", types.OSVersionMatchBuild, nil, "-"},
+		{"exact", "10.0.20348.100", types.OSVersionMatchExact, nil, "10.0.20348.100"},
+		{"exact, no match", "10.0.20348.2500", types.OSVersionMatchExact, nil, "-"},
+		{"Hyper-V, newest older build", "10.0.22631.1", types.OSVersionMatchHyperV, nil, "10.0.20348.2000"},
+		{"Hyper-V, only older build", "10.0.19041.1", types.OSVersionMatchHyperV, nil, "10.0.17763.5000"},
+		{"Hyper-V, no match", "10.0.17000.1", types.OSVersionMatchHyperV, nil, "-"},
+		{"Hyper-V, different major version", "11.0.30000.1", types.OSVersionMatchHyperV, nil, "-"},
+		{"custom", "anything", types.OSVersionMatchExact, func(instanceVersion, wantedVersion string) bool {
+			return instanceVersion == "10.0.20348.100" && wantedVersion == "anything"
+		}, "10.0.20348.100"},
+		{"custom, exact preferred", "10.0.20348.2000", types.OSVersionMatchExact, func(_, _ string) bool {
+			return true
+		}, "10.0.20348.2000"},
+	} {
+		sys := &types.SystemContext{
+			ArchitectureChoice:   "amd64",
+			OSChoice:             "windows",
+			OSVersionChoice:      c.wanted,
+			OSVersionMatchPolicy: c.policy,
+			OSVersionMatcher:     c.matcher,
+		}
+		for _, list := range []ListPublic{ociList, schema2List} {
+			res, err := list.ChooseInstance(sys)
+			require.NoError(t, err, c.name)
+			if c.expected == "-" {
+				assert.Equal(t, digest.FromString(""), res, c.name)
+			} else {
+				assert.Equal(t, digest.FromString(c.expected), res, c.name)
+			}
+		}
+		for _, list := range []ListPublic{ociListWithVersions, schema2ListWithVersions} {
+			res, err := list.ChooseInstance(sys)
+			if c.expected == "-" {
+				assert.Error(t, err, c.name)
+			} else {
+				require.NoError(t, err, c.name)
+				assert.Equal(t, digest.FromString(c.expected), res, c.name)
+			}
+		}
+	}
+}
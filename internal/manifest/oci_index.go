@@ -165,6 +165,19 @@ func (index *OCI1IndexPublic) editInstances(editInstances []ListEdit) error {
 				Platform:     editInstance.AddPlatform,
 				Annotations:  annotations,
 			})
+		case ListOpRemove:
+			if err := editInstance.RemoveDigest.Validate(); err != nil {
+				return fmt.Errorf("OCI1Index.EditInstances: Attempting to remove %s which is an invalid digest: %w", editInstance.RemoveDigest, err)
+			}
+			if !slices.ContainsFunc(index.Manifests, func(m imgspecv1.Descriptor) bool {
+				return m.Digest == editInstance.RemoveDigest
+			}) {
+				return fmt.Errorf("OCI1Index.EditInstances: digest %s not found", editInstance.RemoveDigest)
+			}
+			// slices.DeleteFunc modifies the backing array in place; clone it first, as with additions below.
+			index.Manifests = slices.DeleteFunc(slices.Clone(index.Manifests), func(m imgspecv1.Descriptor) bool {
+				return m.Digest == editInstance.RemoveDigest
+			})
 		default:
 			return fmt.Errorf("internal error: invalid operation: %d", editInstance.ListOperation)
 		}
@@ -207,15 +220,18 @@ func instanceIsZstd(manifest imgspecv1.Descriptor) bool {
 
 type instanceCandidate struct {
 	platformIndex    int           // Index of the candidate in platform.WantedPlatforms: lower numbers are preferred; or math.maxInt if the candidate doesn’t have a platform
+	osVersion        string        // The os.version value of the candidate's platform, if any
 	isZstd           bool          // tells if particular instance if zstd instance
 	manifestPosition int           // A zero-based index of the instance in the manifest list
 	digest           digest.Digest // Instance digest
 }
 
-func (ic instanceCandidate) isPreferredOver(other *instanceCandidate, preferGzip bool) bool {
+func (ic instanceCandidate) isPreferredOver(other *instanceCandidate, osVersions *platform.OSVersionMatcher, preferGzip bool) bool {
 	switch {
 	case ic.platformIndex != other.platformIndex:
 		return ic.platformIndex < other.platformIndex
+	case osVersions.Compare(ic.osVersion, other.osVersion) != 0:
+		return osVersions.Compare(ic.osVersion, other.osVersion) < 0
 	case ic.isZstd != other.isZstd:
 		if !preferGzip {
 			return ic.isZstd
@@ -239,6 +255,7 @@ func (index *OCI1IndexPublic) chooseInstance(ctx *types.SystemContext, preferGzi
 	if err != nil {
 		return "", fmt.Errorf("getting platform information %#v: %w", ctx, err)
 	}
+	osVersions := platform.NewOSVersionMatcher(ctx)
 	var bestMatch *instanceCandidate
 	bestMatch = nil
 	for manifestIndex, d := range index.Manifests {
@@ -248,12 +265,13 @@ func (index *OCI1IndexPublic) chooseInstance(ctx *types.SystemContext, preferGzi
 			platformIndex := slices.IndexFunc(wantedPlatforms, func(wantedPlatform imgspecv1.Platform) bool {
 				return platform.MatchesPlatform(imagePlatform, wantedPlatform)
 			})
-			if platformIndex == -1 {
+			if platformIndex == -1 || !osVersions.Matches(imagePlatform.OSVersion) {
 				continue
 			}
 			candidate.platformIndex = platformIndex
+			candidate.osVersion = imagePlatform.OSVersion
 		}
-		if bestMatch == nil || candidate.isPreferredOver(bestMatch, didPreferGzip) {
+		if bestMatch == nil || candidate.isPreferredOver(bestMatch, osVersions, didPreferGzip) {
 			bestMatch = &candidate
 		}
 	}
@@ -188,6 +188,19 @@ func TestOCI1EditInstances(t *testing.T) {
 	instance, err = list.Instance(digest.Digest("sha256:ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"))
 	require.NoError(t, err)
 	assert.Equal(t, "application/x-tar", instance.ReadOnly.ArtifactType)
+
+	// Remove an instance
+	list, err = ListFromBlob(validManifest, GuessMIMEType(validManifest))
+	require.NoError(t, err)
+	originalInstances := list.Instances()
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: originalInstances[0]}})
+	require.NoError(t, err)
+	assert.Equal(t, originalInstances[1:], list.Instances())
+	// Removing a missing instance, or an invalid digest, fails
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: originalInstances[0]}})
+	assert.Error(t, err)
+	err = list.EditInstances([]ListEdit{{ListOperation: ListOpRemove, RemoveDigest: "sha256:../.."}})
+	assert.Error(t, err)
 }
 
 func TestOCI1IndexChooseInstanceByCompression(t *testing.T) {
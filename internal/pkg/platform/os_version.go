@@ -0,0 +1,96 @@
+package platform
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// OSVersionMatcher matches os.version values of instances of multi-platform images against types.SystemContext.OSVersionChoice.
+// A nil *OSVersionMatcher accepts all instances, and has no preferences.
+type OSVersionMatcher struct {
+	wanted  string
+	policy  types.OSVersionMatchPolicy
+	matches func(instanceVersion, wantedVersion string) bool // types.SystemContext.OSVersionMatcher, or nil
+}
+
+// NewOSVersionMatcher returns an OSVersionMatcher for ctx, or nil if ctx does not ask for matching os.version values.
+func NewOSVersionMatcher(ctx *types.SystemContext) *OSVersionMatcher {
+	if ctx == nil || ctx.OSVersionChoice == "" {
+		return nil
+	}
+	return &OSVersionMatcher{
+		wanted:  ctx.OSVersionChoice,
+		policy:  ctx.OSVersionMatchPolicy,
+		matches: ctx.OSVersionMatcher,
+	}
+}
+
+// Matches returns true if an instance with os.version instanceVersion can be chosen.
+// Instances without an os.version value are always accepted; Compare prefers any other acceptable instance over them.
+func (m *OSVersionMatcher) Matches(instanceVersion string) bool {
+	if m == nil || instanceVersion == "" || instanceVersion == m.wanted {
+		return true
+	}
+	if m.matches != nil {
+		return m.matches(instanceVersion, m.wanted)
+	}
+	if m.policy == types.OSVersionMatchExact {
+		return false
+	}
+	instance, ok1 := parseWindowsOSVersion(instanceVersion)
+	wanted, ok2 := parseWindowsOSVersion(m.wanted)
+	if !ok1 || !ok2 {
+		return false
+	}
+	switch m.policy {
+	case types.OSVersionMatchBuild:
+		return slices.Equal(instance[:3], wanted[:3])
+	case types.OSVersionMatchHyperV:
+		return slices.Equal(instance[:2], wanted[:2]) && instance[2] <= wanted[2]
+	default:
+		return false
+	}
+}
+
+// Compare returns a negative number if an instance with os.version a should be preferred over an instance with b,
+// a positive number if b should be preferred over a, and 0 if neither is preferred.
+// Both a and b should be accepted by Matches.
+func (m *OSVersionMatcher) Compare(a, b string) int {
+	switch {
+	case m == nil || a == b:
+		return 0
+	case a == m.wanted || b == "":
+		return -1
+	case b == m.wanted || a == "":
+		return 1
+	case m.matches != nil:
+		return 0 // We don’t know anything about the semantics of the values.
+	}
+	// Prefer the newest build or revision; with OSVersionMatchHyperV, none of them are newer than m.wanted.
+	va, ok1 := parseWindowsOSVersion(a)
+	vb, ok2 := parseWindowsOSVersion(b)
+	if !ok1 || !ok2 {
+		return 0
+	}
+	return slices.Compare(vb, va)
+}
+
+// parseWindowsOSVersion parses a major.minor.build[.revision] os.version value, as used by Windows.
+func parseWindowsOSVersion(version string) ([]uint64, bool) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, false
+	}
+	res := make([]uint64, 4) // A missing revision is treated as 0.
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		res[i] = v
+	}
+	return res, true
+}
@@ -0,0 +1,42 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOSVersionMatcher(t *testing.T) {
+	for _, sys := range []*types.SystemContext{nil, {}, {OSVersionMatchPolicy: types.OSVersionMatchExact}} {
+		m := NewOSVersionMatcher(sys)
+		assert.Nil(t, m)
+		assert.True(t, m.Matches("10.0.20348.1"))
+		assert.Equal(t, 0, m.Compare("10.0.20348.1", ""))
+	}
+	m := NewOSVersionMatcher(&types.SystemContext{OSVersionChoice: "10.0.20348.1"})
+	assert.NotNil(t, m)
+}
+
+func TestParseWindowsOSVersion(t *testing.T) {
+	for _, c := range []struct {
+		input    string
+		expected []uint64
+	}{
+		{"10.0.20348.2113", []uint64{10, 0, 20348, 2113}},
+		{"10.0.20348", []uint64{10, 0, 20348, 0}},
+		{"", nil},
+		{"10.0", nil},
+		{"10.0.20348.2113.1", nil},
+		{"10.0.x.1", nil},
+		{"10.0.-1.1", nil},
+	} {
+		res, ok := parseWindowsOSVersion(c.input)
+		if c.expected == nil {
+			assert.False(t, ok, c.input)
+		} else {
+			assert.True(t, ok, c.input)
+			assert.Equal(t, c.expected, res, c.input)
+		}
+	}
+}
@@ -24,9 +24,9 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/types"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 // For Linux, the kernel has already detected the ABI, ISA and Features.
@@ -83,7 +83,7 @@ func getCPUVariantDarwinWindows(arch string) string {
 func getCPUVariantArm() string {
 	variant, err := getCPUInfo("Cpu architecture")
 	if err != nil {
-		logrus.Errorf("Couldn't get cpu architecture: %v", err)
+		log.Errorf("Couldn't get cpu architecture: %v", err)
 		return ""
 	}
 
@@ -99,7 +99,7 @@ func getCPUVariantArm() string {
 		// https://github.com/moby/moby/pull/36121#issuecomment-398328286
 		model, err := getCPUInfo("model name")
 		if err != nil {
-			logrus.Errorf("Couldn't get cpu model name, it may be the corner case where variant is 6: %v", err)
+			log.Errorf("Couldn't get cpu model name, it may be the corner case where variant is 6: %v", err)
 			return ""
 		}
 		// model name is NOT a value provided by the CPU; it is another outcome of Linux CPU detection,
@@ -112,7 +112,7 @@ func getCPUVariantArm() string {
 		// So, the "armv6-compatible" check basically checks for a "v6 or v7 CPU, but not one found listed as a known v7 one in the .proc.info.init tables of
 		// https://github.com/torvalds/linux/blob/190bf7b14b0cf3df19c059061be032bd8994a597/arch/arm/mm/proc-v7.S .
 		if strings.HasPrefix(strings.ToLower(model), "armv6-compatible") {
-			logrus.Debugf("Detected corner case, setting cpu variant to v6")
+			log.Debugf("Detected corner case, setting cpu variant to v6")
 			variant = "v6"
 		} else {
 			variant = "v7"
@@ -156,7 +156,7 @@ var compatibility = map[string][]string{
 func WantedPlatforms(ctx *types.SystemContext) ([]imgspecv1.Platform, error) {
 	// Note that this does not use Platform.OSFeatures and Platform.OSVersion at all.
 	// The fields are not specified by the OCI specification, as of version 1.1, usefully enough
-	// to be interoperable, anyway.
+	// to be interoperable, anyway; callers can match OSVersion using OSVersionMatcher, if the user asks for that.
 
 	wantedArch := runtime.GOARCH
 	wantedVariant := ""
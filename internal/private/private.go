@@ -3,6 +3,7 @@ package private
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/blobinfocache"
@@ -78,6 +79,69 @@ type ImageDestination interface {
 	ImageDestinationInternalOnly
 }
 
+// BlobPresenceChecker is an optional interface of ImageDestination implementations,
+// which allows estimating the amount of data a copy would upload, without modifying the destination.
+type BlobPresenceChecker interface {
+	// HasBlob returns true if a blob with info.Digest would not need to be uploaded, because it already exists at the destination
+	// or, per cache, it can be reused from another location within the destination.
+	// info.Digest must not be empty.
+	// Unlike TryReusingBlobWithOptions, HasBlob never modifies the destination; it may update cache.
+	HasBlob(ctx context.Context, info types.BlobInfo, cache blobinfocache.BlobInfoCache2) (bool, error)
+}
+
+// BlobLocationRecorder is an optional interface of ImageSource implementations,
+// which allows other operations to reuse blobs of the source image without reading them.
+type BlobLocationRecorder interface {
+	// RecordBlobLocations records in cache that blobs with digests are available at the location of the source,
+	// so that destinations which can reuse blobs from that location (e.g. by mounting them) try to do so.
+	// It does not check that the blobs exist.
+	RecordBlobLocations(ctx context.Context, digests []digest.Digest, cache blobinfocache.BlobInfoCache2)
+}
+
+// ReferrerArtifact is an artifact attached to a manifest as an OCI referrer, e.g. an attestation.
+type ReferrerArtifact struct {
+	ArtifactType string
+	MediaType    string // The MIME type of Data
+	Data         []byte
+	Annotations  map[string]string // Annotations of the referrer manifest, may be nil.
+}
+
+// ReferrerArtifactSource is an optional interface of ImageSource implementations,
+// which can read artifacts attached to manifests as OCI referrers.
+type ReferrerArtifactSource interface {
+	// GetReferrerArtifacts returns the artifacts of artifactType attached as referrers to the manifest with subjectDigest.
+	// The artifacts are not verified in any way, apart from matching their digests.
+	GetReferrerArtifacts(ctx context.Context, subjectDigest digest.Digest, artifactType string) ([]ReferrerArtifact, error)
+}
+
+// StagingSpaceChecker is an optional interface of ImageDestination implementations which store blobs
+// in a directory for big temporary files (see types.SystemContext.BigFilesTemporaryDir) before committing them.
+type StagingSpaceChecker interface {
+	// CheckStagingSpace returns a types.InsufficientSpaceError if free space checks were requested by
+	// types.SystemContext.BigFilesTemporaryDirCheckFreeSpace, and the destination can determine that
+	// it does not have space to store blobs with a total size of size bytes.
+	CheckStagingSpace(ctx context.Context, size int64) error
+}
+
+// LayerApplyProgressReporter is an optional interface of ImageDestination implementations
+// which may spend significant time applying layers after their blob data has been received.
+type LayerApplyProgressReporter interface {
+	// SetLayerApplyProgress asks the destination to report progress of applying layers,
+	// using types.ProgressEventApply* events, by calling report; ProgressEventApplyRead events are sent about once per interval.
+	// report may be called from any of the destination’s methods, including Commit, until the destination is closed.
+	// It must be called before any blobs are written to the destination.
+	SetLayerApplyProgress(report func(types.ProgressProperties), interval time.Duration)
+}
+
+// VerifiedBlobReader is an optional interface of readers returned by ImageSource.GetBlob, for sources which can remember
+// that a blob has already been verified to match the digest it was requested with.
+type VerifiedBlobReader interface {
+	// DigestVerified returns true if the blob is known to match the requested digest, so that the caller does not need to verify it again.
+	DigestVerified() bool
+	// RecordDigestVerified records that the caller has read the whole blob, and verified that it matches the requested digest.
+	RecordDigestVerified()
+}
+
 // UploadedBlob is information about a blob written to a destination.
 // It is the subset of types.BlobInfo fields the transport is responsible for setting; all fields must be provided.
 type UploadedBlob struct {
@@ -136,32 +200,19 @@ type ReusedBlob struct {
 	// a differently-compressed blob.
 	CompressionOperation types.LayerCompression // Compress/Decompress, matching the reused blob; PreserveOriginal if N/A
 	CompressionAlgorithm *compression.Algorithm // Algorithm if compressed, nil if decompressed or N/A
+	// CompressionAnnotations are annotations required to use the specific compression variant of a substituted blob
+	// (e.g. the zstd:chunked TOC); they replace compression-related annotations of the original blob.
+	CompressionAnnotations map[string]string
 
 	MatchedByTOCDigest bool // Whether the layer was reused/matched by TOC digest. Used only for UI purposes.
 }
 
 // ImageSourceChunk is a portion of a blob.
-// This API is experimental and can be changed without bumping the major version number.
-type ImageSourceChunk struct {
-	// Offset specifies the starting position of the chunk within the source blob.
-	Offset uint64
-
-	// Length specifies the size of the chunk.  If it is set to math.MaxUint64,
-	// then it refers to all the data from Offset to the end of the blob.
-	Length uint64
-}
+// It is an alias, so that chunks can be passed to public types.PartialBlobDestination implementations.
+type ImageSourceChunk = types.ImageSourceChunk
 
 // BlobChunkAccessor allows fetching discontiguous chunks of a blob.
-type BlobChunkAccessor interface {
-	// GetBlobAt returns a sequential channel of readers that contain data for the requested
-	// blob chunks, and a channel that might get a single error value.
-	// The specified chunks must be not overlapping and sorted by their offset.
-	// The readers must be fully consumed, in the order they are returned, before blocking
-	// to read the next chunk.
-	// If the Length for the last chunk is set to math.MaxUint64, then it
-	// fully fetches the remaining data from the offset to the end of the blob.
-	GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error)
-}
+type BlobChunkAccessor = types.BlobChunkAccessor
 
 // BadPartialRequestError is returned by BlobChunkAccessor.GetBlobAt on an invalid request.
 type BadPartialRequestError struct {
@@ -178,3 +229,19 @@ type UnparsedImage interface {
 	// UntrustedSignatures is like ImageSource.GetSignaturesWithFormat, but the result is cached; it is OK to call this however often you need.
 	UntrustedSignatures(ctx context.Context) ([]signature.Signature, error)
 }
+
+// UnparsedImageWithTopLevel is implemented by UnparsedImage values which know whether they are an instance of a multi-platform image.
+type UnparsedImageWithTopLevel interface {
+	UnparsedImage
+	// TopLevel returns the top-level image (typically a manifest list) of the same source, if this is an instance of a
+	// multi-platform image; it returns nil if this is itself a top-level image.
+	TopLevel() UnparsedImage
+}
+
+// UnparsedImageWithReferrers is implemented by UnparsedImage values which can read artifacts attached to the image as OCI referrers.
+type UnparsedImageWithReferrers interface {
+	UnparsedImage
+	// UntrustedReferrerArtifacts returns the artifacts of artifactType attached as referrers to the manifest of this image.
+	// It fails if the transport does not support reading referrers.
+	UntrustedReferrerArtifacts(ctx context.Context, artifactType string) ([]ReferrerArtifact, error)
+}
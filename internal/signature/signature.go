@@ -2,6 +2,7 @@ package signature
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 )
@@ -84,6 +85,16 @@ func FromBlob(blob []byte) (Signature, error) {
 			return nil, fmt.Errorf("unrecognized signature format %q", string(formatBytes))
 		}
 
+	// A Sigstore bundle, e.g. as written by cosign --bundle
+	case '{':
+		var bundle struct {
+			MediaType string `json:"mediaType"`
+		}
+		if err := json.Unmarshal(blob, &bundle); err != nil || !IsSigstoreBundleMIMEType(bundle.MediaType) {
+			return nil, errors.New("unrecognized signature format, a JSON document which is not a Sigstore bundle")
+		}
+		return SigstoreFromComponents(bundle.MediaType, blob, nil), nil
+
 	default:
 		return nil, fmt.Errorf("unrecognized signature format, starting with binary %#x", blob[0])
 	}
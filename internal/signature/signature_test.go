@@ -51,6 +51,19 @@ func TestBlobSigstore(t *testing.T) {
 	assert.Equal(t, sigstoreSig.UntrustedAnnotations(), fromBlobSigstore.UntrustedAnnotations())
 }
 
+func TestFromBlobSigstoreBundle(t *testing.T) {
+	for _, mimeType := range []string{SigstoreBundleMIMEType, "application/vnd.dev.sigstore.bundle+json;version=0.3"} {
+		bundle := []byte(`{"mediaType":"` + mimeType + `","verificationMaterial":{}}`)
+		fromBlob, err := FromBlob(bundle)
+		require.NoError(t, err)
+		fromBlobSigstore, ok := fromBlob.(Sigstore)
+		require.True(t, ok)
+		assert.Equal(t, mimeType, fromBlobSigstore.UntrustedMIMEType())
+		assert.Equal(t, bundle, fromBlobSigstore.UntrustedPayload())
+		assert.Empty(t, fromBlobSigstore.UntrustedAnnotations())
+	}
+}
+
 func TestFromBlobInvalid(t *testing.T) {
 	// Round-tripping valid data has been tested in TestBlobSimpleSigning and TestBlobSigstore above.
 	for _, c := range []string{
@@ -59,6 +72,9 @@ func TestFromBlobInvalid(t *testing.T) {
 		"\x00simple-signing",        // No newline
 		"\x00format\xFFname\ndata",  // Non-ASCII format value
 		"\x00unknown-format\ndata",  // Unknown format
+		"{",                         // Invalid JSON
+		`{"mediaType":"application/vnd.dev.sigstore.bundle+json;version=0.1"}`, // Unsupported Sigstore bundle version
+		`{"a":"b"}`, // JSON which is not a Sigstore bundle
 	} {
 		_, err := FromBlob([]byte(c))
 		assert.Error(t, err, fmt.Sprintf("%#v", c))
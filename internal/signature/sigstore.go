@@ -4,11 +4,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"maps"
+	"strings"
 )
 
 const (
 	// from sigstore/cosign/pkg/types.SimpleSigningMediaType
 	SigstoreSignatureMIMEType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	// from sigstore/cosign/pkg/types.DssePayloadType; the payload is a DSSE envelope, and the cryptographic signature is inside it.
+	SigstoreDSSEEnvelopeMIMEType = "application/vnd.dsse.envelope.v1+json"
+	// from sigstore/sigstore-go/pkg/bundle; the payload is a Sigstore bundle (v0.3), containing the cryptographic signature
+	// and all verification material.
+	SigstoreBundleMIMEType = "application/vnd.dev.sigstore.bundle.v0.3+json"
 	// from sigstore/cosign/pkg/oci/static.SignatureAnnotationKey
 	SigstoreSignatureAnnotationKey = "dev.cosignproject.cosign/signature"
 	// from sigstore/cosign/pkg/oci/static.BundleAnnotationKey
@@ -17,8 +23,22 @@ const (
 	SigstoreCertificateAnnotationKey = "dev.sigstore.cosign/certificate"
 	// from sigstore/cosign/pkg/oci/static.ChainAnnotationKey
 	SigstoreIntermediateCertificateChainAnnotationKey = "dev.sigstore.cosign/chain"
+	// from sigstore/cosign/pkg/oci/static.RFC3161TimestampAnnotationKey
+	SigstoreRFC3161TimestampAnnotationKey = "dev.sigstore.cosign/rfc3161timestamp"
 )
 
+// sigstoreBundleLegacyMIMETypePrefix is the prefix of the older spelling of Sigstore bundle MIME types, followed by a version parameter.
+const sigstoreBundleLegacyMIMETypePrefix = "application/vnd.dev.sigstore.bundle+json;version="
+
+// IsSigstoreBundleMIMEType returns true if mimeType identifies a Sigstore bundle in a version we support (v0.3).
+func IsSigstoreBundleMIMEType(mimeType string) bool {
+	if mimeType == SigstoreBundleMIMEType {
+		return true
+	}
+	version, ok := strings.CutPrefix(mimeType, sigstoreBundleLegacyMIMETypePrefix)
+	return ok && version == "0.3"
+}
+
 // Sigstore is a github.com/cosign/cosign signature.
 // For the persistent-storage format used for blobChunk(), we want
 // a degree of forward compatibility against unexpected field changes
@@ -8,6 +8,22 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestIsSigstoreBundleMIMEType(t *testing.T) {
+	for _, c := range []struct {
+		mimeType string
+		expected bool
+	}{
+		{SigstoreBundleMIMEType, true},
+		{"application/vnd.dev.sigstore.bundle+json;version=0.3", true},
+		{"application/vnd.dev.sigstore.bundle+json;version=0.2", false},
+		{"application/vnd.dev.sigstore.bundle.v0.4+json", false},
+		{SigstoreSignatureMIMEType, false},
+		{"", false},
+	} {
+		assert.Equal(t, c.expected, IsSigstoreBundleMIMEType(c.mimeType), c.mimeType)
+	}
+}
+
 func TestSigstoreFromComponents(t *testing.T) {
 	const mimeType = "mime-type"
 	payload := []byte("payload")
@@ -36,6 +36,19 @@ func SignImageManifest(ctx context.Context, signer *Signer, manifest []byte, doc
 	return signer.implementation.SignImageManifest(ctx, manifest, dockerReference)
 }
 
+// SignImageManifestWithAllKeys invokes a SignerImplementation, creating one signature for each key the signer was configured with.
+// This is a function, not a method, so that it can only be called by code that is allowed to import this internal subpackage.
+func SignImageManifestWithAllKeys(ctx context.Context, signer *Signer, manifest []byte, dockerReference reference.Named) ([]signature.Signature, error) {
+	if multi, ok := signer.implementation.(MultipleKeysSignerImplementation); ok {
+		return multi.SignImageManifestWithAllKeys(ctx, manifest, dockerReference)
+	}
+	sig, err := signer.implementation.SignImageManifest(ctx, manifest, dockerReference)
+	if err != nil {
+		return nil, err
+	}
+	return []signature.Signature{sig}, nil
+}
+
 // SignerImplementation is an object, possibly carrying state, that can be used by copy.Image to sign one or more container images.
 // This interface is distinct from Signer so that implementations can be created outside of this package.
 type SignerImplementation interface {
@@ -45,3 +58,11 @@ type SignerImplementation interface {
 	SignImageManifest(ctx context.Context, m []byte, dockerReference reference.Named) (signature.Signature, error)
 	Close() error
 }
+
+// MultipleKeysSignerImplementation is a SignerImplementation which can be configured with more than one key.
+type MultipleKeysSignerImplementation interface {
+	SignerImplementation
+	// SignImageManifestWithAllKeys creates new signatures for manifest m as dockerReference, one for each configured key.
+	// SignImageManifest only uses the first of the configured keys.
+	SignImageManifestWithAllKeys(ctx context.Context, m []byte, dockerReference reference.Named) ([]signature.Signature, error)
+}
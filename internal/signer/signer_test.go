@@ -85,3 +85,62 @@ func TestSignImageManifest(t *testing.T) {
 	assert.Equal(t, testSig, sig)
 	assert.Equal(t, testErr, err)
 }
+
+// mockMultipleKeysSignerImplementation is a MultipleKeysSignerImplementation used only for tests.
+type mockMultipleKeysSignerImplementation struct {
+	mockSignerImplementation
+	signImageManifestWithAllKeys func(ctx context.Context, m []byte, dockerReference reference.Named) ([]signature.Signature, error)
+}
+
+func (ms *mockMultipleKeysSignerImplementation) SignImageManifestWithAllKeys(ctx context.Context, m []byte, dockerReference reference.Named) ([]signature.Signature, error) {
+	return ms.signImageManifestWithAllKeys(ctx, m, dockerReference)
+}
+
+func TestSignImageManifestWithAllKeys(t *testing.T) {
+	testManifest := []byte("some manifest")
+	testDR, err := reference.ParseNormalizedNamed("busybox")
+	require.NoError(t, err)
+	testContext := context.WithValue(context.Background(), struct{}{}, "make this context unique")
+	testSig1 := signature.SigstoreFromComponents(signature.SigstoreSignatureMIMEType, []byte("payload1"), nil)
+	testSig2 := signature.SigstoreFromComponents(signature.SigstoreSignatureMIMEType, []byte("payload2"), nil)
+	testErr := errors.New("some unique error")
+
+	// A single-key implementation
+	si := mockSignerImplementation{
+		// Other functions are nil, so this ensures they are not called.
+		close: func() error { return nil },
+	}
+	s := NewSigner(&si)
+	defer s.Close()
+	si.signImageManifest = func(ctx context.Context, m []byte, dockerReference reference.Named) (signature.Signature, error) {
+		assert.Equal(t, testContext, ctx)
+		assert.Equal(t, testManifest, m)
+		assert.Equal(t, testDR, dockerReference)
+		return testSig1, nil
+	}
+	sigs, err := SignImageManifestWithAllKeys(testContext, s, testManifest, testDR)
+	require.NoError(t, err)
+	assert.Equal(t, []signature.Signature{testSig1}, sigs)
+	si.signImageManifest = func(ctx context.Context, m []byte, dockerReference reference.Named) (signature.Signature, error) {
+		return nil, testErr
+	}
+	_, err = SignImageManifestWithAllKeys(testContext, s, testManifest, testDR)
+	assert.Equal(t, testErr, err)
+
+	// A multiple-key implementation
+	msi := mockMultipleKeysSignerImplementation{
+		// Other functions are nil, so this ensures they are not called.
+		mockSignerImplementation: mockSignerImplementation{close: func() error { return nil }},
+	}
+	ms := NewSigner(&msi)
+	defer ms.Close()
+	msi.signImageManifestWithAllKeys = func(ctx context.Context, m []byte, dockerReference reference.Named) ([]signature.Signature, error) {
+		assert.Equal(t, testContext, ctx)
+		assert.Equal(t, testManifest, m)
+		assert.Equal(t, testDR, dockerReference)
+		return []signature.Signature{testSig1, testSig2}, testErr
+	}
+	sigs, err = SignImageManifestWithAllKeys(testContext, ms, testManifest, testDR)
+	assert.Equal(t, []signature.Signature{testSig1, testSig2}, sigs)
+	assert.Equal(t, testErr, err)
+}
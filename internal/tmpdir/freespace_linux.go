@@ -0,0 +1,15 @@
+package tmpdir
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// availableSpace returns the number of bytes available to unprivileged users in the file system containing path,
+// and true; or false if it can not be determined.
+func availableSpace(path string) (int64, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, false
+	}
+	return int64(st.Bavail) * int64(st.Bsize), true //nolint:unconvert // The field types differ across architectures.
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package tmpdir
+
+// availableSpace returns false, the available space can not be determined on this platform.
+func availableSpace(path string) (int64, bool) {
+	return 0, false
+}
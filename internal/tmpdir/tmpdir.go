@@ -42,3 +42,20 @@ func CreateBigFileTemp(sys *types.SystemContext, name string) (*os.File, error)
 func MkDirBigFileTemp(sys *types.SystemContext, name string) (string, error) {
 	return os.MkdirTemp(temporaryDirectoryForBigFiles(sys), prefix+name)
 }
+
+// CheckFreeSpace returns a types.InsufficientSpaceError if sys.BigFilesTemporaryDirCheckFreeSpace is set, and
+// dir, a directory for big files, does not have space for at least required more bytes.
+// If the available space can not be determined, the check is skipped.
+func CheckFreeSpace(sys *types.SystemContext, dir string, required int64) error {
+	if sys == nil || !sys.BigFilesTemporaryDirCheckFreeSpace || required <= 0 {
+		return nil
+	}
+	available, ok := availableSpace(dir)
+	if !ok {
+		return nil
+	}
+	if available < required {
+		return types.InsufficientSpaceError{Path: dir, Required: required, Available: available}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/containers/image/v5/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateBigFileTemp(t *testing.T) {
@@ -52,3 +53,27 @@ func TestMkDirBigFileTemp(t *testing.T) {
 	_, err = MkDirBigFileTemp(&sys, "foobar1")
 	assert.Error(t, err)
 }
+
+func TestCheckFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	const huge = int64(1) << 62
+
+	// Not requested
+	err := CheckFreeSpace(nil, dir, huge)
+	assert.NoError(t, err)
+	err = CheckFreeSpace(&types.SystemContext{}, dir, huge)
+	assert.NoError(t, err)
+
+	sys := &types.SystemContext{BigFilesTemporaryDirCheckFreeSpace: true}
+	err = CheckFreeSpace(sys, dir, 1)
+	assert.NoError(t, err)
+	if _, ok := availableSpace(dir); !ok {
+		t.Skip("Available space can not be determined")
+	}
+	err = CheckFreeSpace(sys, dir, huge)
+	var spaceErr types.InsufficientSpaceError
+	require.ErrorAs(t, err, &spaceErr)
+	assert.Equal(t, dir, spaceErr.Path)
+	assert.Equal(t, huge, spaceErr.Required)
+	assert.Less(t, spaceErr.Available, huge)
+}
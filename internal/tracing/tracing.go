@@ -0,0 +1,32 @@
+// Package tracing creates OpenTelemetry spans for operations performed by this library.
+//
+// Spans are created using the global TracerProvider (see go.opentelemetry.io/otel.SetTracerProvider);
+// unless the application configures one, all operations here are no-ops.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this library as the source of the spans.
+const instrumentationName = "github.com/containers/image/v5"
+
+// Start creates a span called name, as a child of any span in ctx, and returns a context containing the new span.
+// The caller must call End on the returned span.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err (if any) in span, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -3,9 +3,10 @@ package manifest
 import (
 	"fmt"
 
+	"github.com/containers/image/v5/internal/log"
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
-	"github.com/sirupsen/logrus"
+	digest "github.com/opencontainers/go-digest"
 )
 
 // layerInfosToStrings converts a list of layer infos, presumably obtained from a Manifest.LayerInfos()
@@ -107,7 +108,7 @@ func updatedMIMEType(variantTable []compressionMIMETypeSet, mimeType string, upd
 
 	case types.Compress:
 		if updated.CompressionAlgorithm == nil {
-			logrus.Debugf("Error preparing updated manifest: blob %q was compressed but does not specify by which algorithm: falling back to use the original blob", updated.Digest)
+			log.Debugf("Error preparing updated manifest: blob %q was compressed but does not specify by which algorithm: falling back to use the original blob", updated.Digest)
 			return mimeType, nil
 		}
 		return compressionVariantMIMEType(variantTable, mimeType, updated.CompressionAlgorithm)
@@ -147,6 +148,37 @@ func imgInspectLayersFromLayerInfos(infos []LayerInfo) []types.ImageInspectLayer
 		layers[i].Digest = info.Digest
 		layers[i].Size = info.Size
 		layers[i].Annotations = info.Annotations
+		layers[i].EmptyLayer = info.EmptyLayer
 	}
 	return layers
 }
+
+// setImgInspectLayersDiffIDs sets UncompressedDigest in layers from diffIDs, if they correspond one to one.
+func setImgInspectLayersDiffIDs(layers []types.ImageInspectLayer, diffIDs []digest.Digest) {
+	if len(diffIDs) != len(layers) {
+		return
+	}
+	for i := range layers {
+		layers[i].UncompressedDigest = diffIDs[i]
+	}
+}
+
+// setImgInspectHistoryLayerIndexes sets LayerIndex in history, assuming that each step which is not an EmptyLayer
+// created the next one of numLayers layers; if that is inconsistent with the number of layers, all LayerIndex values are set to -1.
+func setImgInspectHistoryLayerIndexes(history []types.ImageInspectHistory, numLayers int) {
+	nonEmpty := 0
+	for _, h := range history {
+		if !h.EmptyLayer {
+			nonEmpty++
+		}
+	}
+	layerIndex := 0
+	for i := range history {
+		if nonEmpty != numLayers || history[i].EmptyLayer {
+			history[i].LayerIndex = -1
+		} else {
+			history[i].LayerIndex = layerIndex
+			layerIndex++
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/containers/image/v5/pkg/compression"
 	compressiontypes "github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -326,3 +327,39 @@ func TestUpdatedMIMEType(t *testing.T) {
 
 	require.Equalf(t, len(preserve), len(compressZstdSuccess)+len(compressZstdFailure), "missing some zstd compression tests")
 }
+
+func TestSetImgInspectLayersDiffIDs(t *testing.T) {
+	layers := []types.ImageInspectLayer{{Digest: "sha256:aaaa"}, {Digest: "sha256:bbbb"}}
+	setImgInspectLayersDiffIDs(layers, []digest.Digest{"sha256:cccc"}) // Count mismatch, ignored
+	assert.Equal(t, []types.ImageInspectLayer{{Digest: "sha256:aaaa"}, {Digest: "sha256:bbbb"}}, layers)
+	setImgInspectLayersDiffIDs(layers, []digest.Digest{"sha256:cccc", "sha256:dddd"})
+	assert.Equal(t, []types.ImageInspectLayer{
+		{Digest: "sha256:aaaa", UncompressedDigest: "sha256:cccc"},
+		{Digest: "sha256:bbbb", UncompressedDigest: "sha256:dddd"},
+	}, layers)
+}
+
+func TestSetImgInspectHistoryLayerIndexes(t *testing.T) {
+	for _, c := range []struct {
+		emptyLayers []bool
+		numLayers   int
+		expected    []int
+	}{
+		{[]bool{}, 0, []int{}},
+		{[]bool{false, true, false, true}, 2, []int{0, -1, 1, -1}},
+		{[]bool{true, false, false}, 2, []int{-1, 0, 1}},
+		{[]bool{false, true, false}, 3, []int{-1, -1, -1}},  // Inconsistent
+		{[]bool{false, false, false}, 2, []int{-1, -1, -1}}, // Inconsistent
+	} {
+		history := []types.ImageInspectHistory{}
+		for _, empty := range c.emptyLayers {
+			history = append(history, types.ImageInspectHistory{EmptyLayer: empty})
+		}
+		setImgInspectHistoryLayerIndexes(history, c.numLayers)
+		res := []int{}
+		for _, h := range history {
+			res = append(res, h.LayerIndex)
+		}
+		assert.Equal(t, c.expected, res, fmt.Sprintf("%#v, %d", c.emptyLayers, c.numLayers))
+	}
+}
@@ -258,6 +258,20 @@ func (m *Schema1) Inspect(_ func(types.BlobInfo) ([]byte, error)) (*types.ImageI
 		i.Labels = s1.Config.Labels
 		i.Env = s1.Config.Env
 	}
+	// Each history entry corresponds to a layer, including "throwaway" ones; both are listed with the newest one first.
+	i.History = make([]types.ImageInspectHistory, len(m.ExtractedV1Compatibility))
+	for j, compat := range m.ExtractedV1Compatibility {
+		index := len(m.ExtractedV1Compatibility) - 1 - j
+		created := compat.Created
+		i.History[index] = types.ImageInspectHistory{
+			Created:    &created,
+			CreatedBy:  strings.Join(compat.ContainerConfig.Cmd, " "),
+			Author:     compat.Author,
+			Comment:    compat.Comment,
+			EmptyLayer: compat.ThrowAway,
+			LayerIndex: index,
+		}
+	}
 	return i, nil
 }
 
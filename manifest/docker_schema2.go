@@ -287,6 +287,25 @@ func (m *Schema2) Inspect(configGetter func(types.BlobInfo) ([]byte, error)) (*t
 		i.Labels = s2.Config.Labels
 		i.Env = s2.Config.Env
 	}
+	if s2.RootFS != nil {
+		setImgInspectLayersDiffIDs(i.LayersData, s2.RootFS.DiffIDs)
+	}
+	if len(s2.History) != 0 {
+		i.History = make([]types.ImageInspectHistory, len(s2.History))
+		for j, h := range s2.History {
+			i.History[j] = types.ImageInspectHistory{
+				CreatedBy:  h.CreatedBy,
+				Author:     h.Author,
+				Comment:    h.Comment,
+				EmptyLayer: h.EmptyLayer,
+			}
+			if !h.Created.IsZero() {
+				created := h.Created
+				i.History[j].Created = &created
+			}
+		}
+		setImgInspectHistoryLayerIndexes(i.History, len(i.LayersData))
+	}
 	return i, nil
 }
 
@@ -232,6 +232,20 @@ func (m *OCI1) Inspect(configGetter func(types.BlobInfo) ([]byte, error)) (*type
 		Env:           v1.Config.Env,
 		Author:        v1.Author,
 	}
+	setImgInspectLayersDiffIDs(i.LayersData, v1.RootFS.DiffIDs)
+	if len(v1.History) != 0 {
+		i.History = make([]types.ImageInspectHistory, len(v1.History))
+		for j, h := range v1.History {
+			i.History[j] = types.ImageInspectHistory{
+				Created:    h.Created,
+				CreatedBy:  h.CreatedBy,
+				Author:     h.Author,
+				Comment:    h.Comment,
+				EmptyLayer: h.EmptyLayer,
+			}
+		}
+		setImgInspectHistoryLayerIndexes(i.History, len(i.LayersData))
+	}
 	return i, nil
 }
 
@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putTestImage stores a single-layer image as name, and returns it.
+func putTestImage(t *testing.T, name string) Image {
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	layer := []byte("this is not really a layer")
+	img := Image{
+		Manifest: []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `",` +
+			`"config":{"mediaType":"` + imgspecv1.MediaTypeImageConfig + `","digest":"` + digest.FromBytes(config).String() + `","size":` + strconv.Itoa(len(config)) + `},` +
+			`"layers":[{"mediaType":"` + imgspecv1.MediaTypeImageLayerGzip + `","digest":"` + digest.FromBytes(layer).String() + `","size":` + strconv.Itoa(len(layer)) + `}]}`),
+		Blobs: map[digest.Digest][]byte{
+			digest.FromBytes(config): config,
+			digest.FromBytes(layer):  layer,
+		},
+	}
+	err := Put(name, img)
+	require.NoError(t, err)
+	return img
+}
+
+func TestCopyImage(t *testing.T) {
+	t.Cleanup(Reset)
+	src := putTestImage(t, "source")
+
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
+	})
+	require.NoError(t, err)
+	defer func() { _ = policyContext.Destroy() }()
+
+	srcRef, err := Transport.ParseReference("source")
+	require.NoError(t, err)
+	destRef, err := Transport.ParseReference("destination")
+	require.NoError(t, err)
+	manifestBlob, err := copy.Image(context.Background(), policyContext, destRef, srcRef, nil)
+	require.NoError(t, err)
+
+	dest, ok := Lookup("destination")
+	require.True(t, ok)
+	assert.Equal(t, src.Manifest, dest.Manifest)
+	assert.Equal(t, manifestBlob, dest.Manifest)
+	assert.Equal(t, src.Blobs, dest.Blobs)
+	assert.Equal(t, []string{"destination", "source"}, Names())
+
+	// Lookup returns a copy.
+	dest.Manifest[0] = 'x'
+	dest2, ok := Lookup("destination")
+	require.True(t, ok)
+	assert.Equal(t, src.Manifest, dest2.Manifest)
+}
+
+func TestImageDestinationCommit(t *testing.T) {
+	t.Cleanup(Reset)
+	src := putTestImage(t, "source")
+
+	ref, err := NewReference("destination")
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	privateDest, ok := dest.(private.ImageDestination)
+	require.True(t, ok)
+
+	// Blobs of other images are reused.
+	for d, blob := range src.Blobs {
+		reused, info, err := privateDest.TryReusingBlobWithOptions(context.Background(), types.BlobInfo{Digest: d, Size: -1},
+			private.TryReusingBlobOptions{Cache: none.NoCache})
+		require.NoError(t, err)
+		assert.True(t, reused)
+		assert.Equal(t, int64(len(blob)), info.Size)
+	}
+	reused, _, err := privateDest.TryReusingBlobWithOptions(context.Background(), types.BlobInfo{Digest: digest.FromString("missing"), Size: -1},
+		private.TryReusingBlobOptions{Cache: none.NoCache})
+	require.NoError(t, err)
+	assert.False(t, reused)
+
+	// A size mismatch is rejected.
+	_, err = privateDest.PutBlobWithOptions(context.Background(), bytes.NewReader([]byte("blob")), types.BlobInfo{Size: 10}, private.PutBlobOptions{Cache: none.NoCache})
+	assert.Error(t, err)
+
+	err = dest.PutManifest(context.Background(), src.Manifest, nil)
+	require.NoError(t, err)
+	// Nothing is visible before Commit.
+	_, ok = Lookup("destination")
+	assert.False(t, ok)
+	err = dest.Commit(context.Background(), nil)
+	require.NoError(t, err)
+	img, ok := Lookup("destination")
+	require.True(t, ok)
+	assert.Equal(t, src.Manifest, img.Manifest)
+	assert.Equal(t, src.Blobs, img.Blobs)
+
+	// Commit without a manifest fails.
+	dest2, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest2.Close()
+	err = dest2.Commit(context.Background(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+type memoryImageSource struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	impl.DoesNotAffectLayerInfosForCopy
+	stubs.NoGetBlobAtInitialize
+
+	ref   memoryReference
+	image *Image // The image as it was stored when the source was created; must not be modified
+}
+
+// newImageSource returns an ImageSource reading from the image stored for ref.
+// The caller must call .Close() on the returned ImageSource.
+func newImageSource(ref memoryReference) (private.ImageSource, error) {
+	img := globalStore.lookup(ref.name)
+	if img == nil {
+		return nil, fmt.Errorf("image %q not found in memory: transport", ref.name)
+	}
+	s := &memoryImageSource{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			HasThreadSafeGetBlob: true,
+		}),
+		NoGetBlobAtInitialize: stubs.NoGetBlobAt(ref),
+
+		ref:   ref,
+		image: img,
+	}
+	s.Compat = impl.AddCompat(s)
+	return s, nil
+}
+
+// Reference returns the reference used to set up this source, _as specified by the user_
+// (not as the image itself, or its underlying storage, claims).  This can be used e.g. to determine which public keys are trusted for this image.
+func (s *memoryImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+// Close removes resources associated with an initialized ImageSource, if any.
+func (s *memoryImageSource) Close() error {
+	return nil
+}
+
+// GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
+// It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
+// this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
+func (s *memoryImageSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	m := s.image.Manifest
+	if instanceDigest != nil {
+		instance, ok := s.image.Instances[*instanceDigest]
+		if !ok {
+			return nil, "", fmt.Errorf("manifest %s not found in image %q", instanceDigest.String(), s.ref.name)
+		}
+		m = instance
+	}
+	return bytes.Clone(m), manifest.GuessMIMEType(m), nil
+}
+
+// GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
+// The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
+// May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
+func (s *memoryImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	blob, ok := s.image.Blobs[info.Digest]
+	if !ok {
+		return nil, -1, fmt.Errorf("blob %s not found in image %q", info.Digest.String(), s.ref.name)
+	}
+	return io.NopCloser(bytes.NewReader(blob)), int64(len(blob)), nil
+}
+
+// GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve signatures for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (s *memoryImageSource) GetSignaturesWithFormat(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Signature, error) {
+	blobs := s.image.Signatures
+	if instanceDigest != nil {
+		blobs = s.image.InstanceSignatures[*instanceDigest]
+	}
+	signatures := []signature.Signature{}
+	for i, blob := range blobs {
+		sig, err := signature.FromBlob(blob)
+		if err != nil {
+			return nil, fmt.Errorf("parsing signature %d: %w", i+1, err)
+		}
+		signatures = append(signatures, sig)
+	}
+	return signatures, nil
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// Image is the contents of an image stored in the memory: transport.
+type Image struct {
+	Manifest  []byte                   // The top-level manifest, possibly a manifest list
+	Instances map[digest.Digest][]byte // Manifests of per-platform instances, if Manifest is a manifest list
+	// Signatures are the signatures of the top-level manifest, and InstanceSignatures the signatures of instances,
+	// in the format used by the dir: transport.
+	Signatures         [][]byte
+	InstanceSignatures map[digest.Digest][][]byte
+	Blobs              map[digest.Digest][]byte // All blobs (layers and configs) of the image
+}
+
+// store contains all images of the memory: transport.
+// Stored *Image values are never modified, they are replaced instead; so an *Image obtained
+// while holding mutex can be read after releasing it.
+type store struct {
+	mutex  sync.Mutex
+	images map[string]*Image
+}
+
+var globalStore = store{images: map[string]*Image{}}
+
+// clone returns a deep copy of img, with all maps non-nil.
+func (img *Image) clone() *Image {
+	res := &Image{
+		Manifest:           slices.Clone(img.Manifest),
+		Instances:          map[digest.Digest][]byte{},
+		Signatures:         cloneSignatures(img.Signatures),
+		InstanceSignatures: map[digest.Digest][][]byte{},
+		Blobs:              map[digest.Digest][]byte{},
+	}
+	for d, m := range img.Instances {
+		res.Instances[d] = slices.Clone(m)
+	}
+	for d, sigs := range img.InstanceSignatures {
+		res.InstanceSignatures[d] = cloneSignatures(sigs)
+	}
+	for d, blob := range img.Blobs {
+		res.Blobs[d] = slices.Clone(blob)
+	}
+	return res
+}
+
+// cloneSignatures returns a deep copy of sigs.
+func cloneSignatures(sigs [][]byte) [][]byte {
+	res := make([][]byte, 0, len(sigs))
+	for _, sig := range sigs {
+		res = append(res, slices.Clone(sig))
+	}
+	return res
+}
+
+// lookup returns the stored image called name, or nil if it does not exist.
+// The caller must not modify the returned value.
+func (s *store) lookup(name string) *Image {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.images[name]
+}
+
+// findBlob returns a blob with digest from any stored image, if it exists.
+func (s *store) findBlob(digest digest.Digest) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, img := range s.images {
+		if blob, ok := img.Blobs[digest]; ok {
+			return blob, true
+		}
+	}
+	return nil, false
+}
+
+// replace stores img as name; the caller must not modify img afterwards.
+func (s *store) replace(name string, img *Image) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.images[name] = img
+}
+
+// Lookup returns a copy of the image called name, and true, or false if the image does not exist.
+func Lookup(name string) (Image, bool) {
+	img := globalStore.lookup(name)
+	if img == nil {
+		return Image{}, false
+	}
+	return *img.clone(), true
+}
+
+// Put stores a copy of image as name, replacing any image with that name; it can be used to prepare source images.
+// The blobs are not verified to match their digests.
+func Put(name string, image Image) error {
+	if name == "" {
+		return errors.New("memory: image name must not be empty")
+	}
+	if image.Manifest == nil {
+		return fmt.Errorf("image %q has no manifest", name)
+	}
+	globalStore.replace(name, image.clone())
+	return nil
+}
+
+// Names returns the names of all stored images, sorted.
+func Names() []string {
+	globalStore.mutex.Lock()
+	defer globalStore.mutex.Unlock()
+	res := make([]string, 0, len(globalStore.images))
+	for name := range globalStore.images {
+		res = append(res, name)
+	}
+	slices.Sort(res)
+	return res
+}
+
+// Reset removes all stored images.
+func Reset() {
+	globalStore.mutex.Lock()
+	defer globalStore.mutex.Unlock()
+	globalStore.images = map[string]*Image{}
+}
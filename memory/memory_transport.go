@@ -0,0 +1,133 @@
+// Package memory provides the memory: transport, which stores images in maps within the current process.
+//
+// It is primarily intended for unit tests of applications using the copy package: images can be copied
+// to and from the memory: transport without temporary directories or a registry, and the test can then
+// inspect what was written using Lookup, or prepare source images using Put.
+//
+// Images are identified by arbitrary non-empty names, e.g. "memory:example/app:v1".
+// All images are discarded when the process exits, or when Reset is called.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+)
+
+func init() {
+	transports.Register(Transport)
+}
+
+// Transport is an ImageTransport for images stored in memory.
+var Transport = memoryTransport{}
+
+type memoryTransport struct{}
+
+func (t memoryTransport) Name() string {
+	return "memory"
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an ImageReference.
+func (t memoryTransport) ParseReference(reference string) (types.ImageReference, error) {
+	return NewReference(reference)
+}
+
+// ValidatePolicyConfigurationScope checks that scope is a valid name for a signature.PolicyTransportScopes keys
+// (i.e. a valid PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() return value).
+// It is acceptable to allow an invalid value which will never be matched, it can "only" cause user confusion.
+// scope passed to this function will not be "", that value is always allowed.
+func (t memoryTransport) ValidatePolicyConfigurationScope(scope string) error {
+	// Any image name is a valid scope.
+	return nil
+}
+
+// memoryReference is an ImageReference for images stored in memory.
+type memoryReference struct {
+	name string
+}
+
+// NewReference returns a memory: reference for an image called name.
+func NewReference(name string) (types.ImageReference, error) {
+	if name == "" {
+		return nil, errors.New("memory: image name must not be empty")
+	}
+	return memoryReference{name: name}, nil
+}
+
+func (ref memoryReference) Transport() types.ImageTransport {
+	return Transport
+}
+
+// StringWithinTransport returns a string representation of the reference, which MUST be such that
+// reference.Transport().ParseReference(reference.StringWithinTransport()) returns an equivalent reference.
+// NOTE: The returned string is not promised to be equal to the original input to ParseReference;
+// e.g. default attribute values omitted by the user may be filled in the return value, or vice versa.
+// WARNING: Do not use the return value in the UI to describe an image, it does not contain the Transport().Name() prefix.
+func (ref memoryReference) StringWithinTransport() string {
+	return ref.name
+}
+
+// DockerReference returns a Docker reference associated with this reference
+// (fully explicit, i.e. !reference.IsNameOnly, but reflecting user intent,
+// not e.g. after redirect or alias processing), or nil if unknown/not applicable.
+func (ref memoryReference) DockerReference() reference.Named {
+	return nil
+}
+
+// PolicyConfigurationIdentity returns a string representation of the reference, suitable for policy lookup.
+// This MUST reflect user intent, not e.g. after processing of third-party redirects or aliases;
+// The value SHOULD be fully explicit about its semantics, with no hidden defaults, AND canonical
+// (i.e. various references with exactly the same semantics should return the same configuration identity)
+// It is fine for the return value to be equal to StringWithinTransport(), and it is desirable but
+// not required/guaranteed that it will be a valid input to Transport().ParseReference().
+// Returns "" if configuration identities for these references are not supported.
+func (ref memoryReference) PolicyConfigurationIdentity() string {
+	return ref.name
+}
+
+// PolicyConfigurationNamespaces returns a list of other policy configuration namespaces to search
+// for if explicit configuration for PolicyConfigurationIdentity() is not set.  The list will be processed
+// in order, terminating on first match, and an implicit "" is always checked at the end.
+// It is STRONGLY recommended for the first element, if any, to be a prefix of PolicyConfigurationIdentity(),
+// and each following element to be a prefix of the element preceding it.
+func (ref memoryReference) PolicyConfigurationNamespaces() []string {
+	// Image names have no structure, so there are no namespaces.
+	return []string{}
+}
+
+// NewImage returns a types.ImageCloser for this reference, possibly specialized for this ImageTransport.
+// The caller must call .Close() on the returned ImageCloser.
+// NOTE: If any kind of signature verification should happen, build an UnparsedImage from the value returned by NewImageSource,
+// verify that UnparsedImage, and convert it into a real Image via image.FromUnparsedImage.
+// WARNING: This may not do the right thing for a manifest list, see image.FromSource for details.
+func (ref memoryReference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	return image.FromReference(ctx, sys, ref)
+}
+
+// NewImageSource returns a types.ImageSource for this reference.
+// The caller must call .Close() on the returned ImageSource.
+func (ref memoryReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	return newImageSource(ref)
+}
+
+// NewImageDestination returns a types.ImageDestination for this reference.
+// The caller must call .Close() on the returned ImageDestination.
+func (ref memoryReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	return newImageDestination(ref), nil
+}
+
+// DeleteImage deletes the named image from the registry, if supported.
+func (ref memoryReference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
+	globalStore.mutex.Lock()
+	defer globalStore.mutex.Unlock()
+	if _, ok := globalStore.images[ref.name]; !ok {
+		return fmt.Errorf("image %q not found in memory: transport", ref.name)
+	}
+	delete(globalStore.images, ref.name)
+	return nil
+}
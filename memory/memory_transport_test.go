@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportName(t *testing.T) {
+	assert.Equal(t, "memory", Transport.Name())
+}
+
+func TestTransportParseReference(t *testing.T) {
+	for _, input := range []string{"app", "example.com/ns/app:v1", "with spaces@and:colons"} {
+		ref, err := Transport.ParseReference(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, input, ref.StringWithinTransport(), input)
+		assert.Equal(t, input, ref.PolicyConfigurationIdentity(), input)
+		assert.Equal(t, []string{}, ref.PolicyConfigurationNamespaces(), input)
+		assert.Nil(t, ref.DockerReference(), input)
+	}
+
+	_, err := Transport.ParseReference("")
+	assert.Error(t, err)
+}
+
+func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
+	for _, scope := range []string{"app", "example.com/ns/app:v1"} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.NoError(t, err, scope)
+	}
+}
+
+func TestReferenceDeleteImage(t *testing.T) {
+	t.Cleanup(Reset)
+	ref, err := NewReference("deleted")
+	require.NoError(t, err)
+
+	err = ref.DeleteImage(context.Background(), nil)
+	assert.Error(t, err)
+
+	err = Put("deleted", Image{Manifest: []byte("{}")})
+	require.NoError(t, err)
+	err = ref.DeleteImage(context.Background(), nil)
+	require.NoError(t, err)
+	_, ok := Lookup("deleted")
+	assert.False(t, ok)
+	_, err = ref.NewImageSource(context.Background(), nil)
+	assert.Error(t, err)
+}
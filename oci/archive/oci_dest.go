@@ -1,20 +1,27 @@
 package archive
 
 import (
+	"archive/tar"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/containers/image/v5/internal/imagedestination"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
-	"github.com/containers/storage/pkg/archive"
-	"github.com/containers/storage/pkg/idtools"
 	digest "github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 type ociArchiveImageDestination struct {
@@ -57,7 +64,7 @@ func (d *ociArchiveImageDestination) Reference() types.ImageReference {
 func (d *ociArchiveImageDestination) Close() error {
 	defer func() {
 		err := d.tempDirRef.deleteTempDir()
-		logrus.Debugf("Error deleting temporary directory: %v", err)
+		log.Debugf("Error deleting temporary directory: %v", err)
 	}()
 	return d.unpackedDest.Close()
 }
@@ -166,21 +173,18 @@ func (d *ociArchiveImageDestination) Commit(ctx context.Context, unparsedTopleve
 	return tarDirectory(src, dst)
 }
 
-// tar converts the directory at src and saves it to dst
+// tarDirectory converts the OCI layout directory at src to a tar archive, and saves it to dst.
+// The archive is written sequentially, so dst can be a non-seekable file like /dev/stdout.
+// The metadata (oci-layout, index.json, manifests and configs) is written before the layers,
+// so that consumers can process the archive in a single pass as well.
 func tarDirectory(src, dst string) error {
-	// input is a stream of bytes from the archive of the directory at path
-	input, err := archive.TarWithOptions(src, &archive.TarOptions{
-		Compression: archive.Uncompressed,
-		// Don’t include the data about the user account this code is running under.
-		ChownOpts: &idtools.IDPair{UID: 0, GID: 0},
-	})
+	entries, err := layoutArchiveOrder(src)
 	if err != nil {
-		return fmt.Errorf("retrieving stream of bytes from %q: %w", src, err)
+		return err
 	}
-	defer input.Close()
 
-	// creates the tar file
-	outFile, err := os.Create(dst)
+	// creates the tar file; O_RDWR, as used by os.Create, is not appropriate for pipes.
+	outFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
 	if err != nil {
 		return fmt.Errorf("creating tar file %q: %w", dst, err)
 	}
@@ -188,7 +192,153 @@ func tarDirectory(src, dst string) error {
 
 	// copies the contents of the directory to the tar file
 	// TODO: This can take quite some time, and should ideally be cancellable using a context.Context.
-	_, err = io.Copy(outFile, input)
+	tw := tar.NewWriter(outFile)
+	for _, entry := range entries {
+		if err := addTarEntry(tw, src, entry); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("writing tar file %q: %w", dst, err)
+	}
+	return outFile.Close()
+}
+
+// layoutArchiveOrder returns paths of all items in the OCI layout directory at src, relative to src,
+// in the order they should be written to an archive: directories, oci-layout, index.json,
+// manifests and configs referenced from index.json, and finally everything else (primarily layers).
+func layoutArchiveOrder(src string) ([]string, error) {
+	dirs := []string{}
+	files := []string{}
+	if err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == src {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, rel)
+		} else {
+			files = append(files, rel)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading directory %q: %w", src, err)
+	}
 
-	return err
+	first := []string{imgspecv1.ImageLayoutFile, imgspecv1.ImageIndexFile}
+	first = append(first, layoutMetadataBlobs(src)...)
+	res := slices.Clone(dirs)
+	written := set.New[string]()
+	for _, f := range first {
+		if !written.Contains(f) && slices.Contains(files, f) {
+			res = append(res, f)
+			written.Add(f)
+		}
+	}
+	for _, f := range files {
+		if !written.Contains(f) {
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+// layoutMetadataBlobs returns relative paths of manifests, indexes and configs reachable from index.json in the OCI layout at src.
+// This is only used for ordering the archive, so any unexpected data is ignored.
+func layoutMetadataBlobs(src string) []string {
+	res := []string{}
+	blobPath := func(d digest.Digest) (string, bool) {
+		if d.Validate() != nil {
+			return "", false
+		}
+		return filepath.Join(imgspecv1.ImageBlobsDir, d.Algorithm().String(), d.Encoded()), true
+	}
+
+	var index imgspecv1.Index
+	indexJSON, err := os.ReadFile(filepath.Join(src, imgspecv1.ImageIndexFile))
+	if err != nil || json.Unmarshal(indexJSON, &index) != nil {
+		return res
+	}
+	queue := slices.Clone(index.Manifests)
+	seen := set.New[digest.Digest]()
+	for len(queue) > 0 {
+		desc := queue[0]
+		queue = queue[1:]
+		if seen.Contains(desc.Digest) {
+			continue
+		}
+		seen.Add(desc.Digest)
+		path, ok := blobPath(desc.Digest)
+		if !ok {
+			continue
+		}
+		res = append(res, path)
+		blob, err := os.ReadFile(filepath.Join(src, path))
+		if err != nil {
+			continue
+		}
+		switch {
+		case manifest.MIMETypeIsMultiImage(desc.MediaType):
+			var child imgspecv1.Index
+			if json.Unmarshal(blob, &child) == nil {
+				queue = append(queue, child.Manifests...)
+			}
+		default:
+			var m imgspecv1.Manifest
+			if json.Unmarshal(blob, &m) == nil {
+				if configPath, ok := blobPath(m.Config.Digest); ok {
+					res = append(res, configPath)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// addTarEntry adds the item at rel within src to tw.
+func addTarEntry(tw *tar.Writer, src, rel string) error {
+	fullPath := filepath.Join(src, rel)
+	fi, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(fullPath); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return fmt.Errorf("creating tar header for %q: %w", fullPath, err)
+	}
+	hdr.Name = filepath.ToSlash(rel)
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	// Don’t include the data about the user account this code is running under.
+	hdr.Uid, hdr.Gid = 0, 0
+	hdr.Uname, hdr.Gname = "", ""
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing tar header for %q: %w", fullPath, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("writing %q to tar file: %w", fullPath, err)
+	}
+	return nil
 }
@@ -2,12 +2,15 @@ package archive
 
 import (
 	"archive/tar"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/containers/image/v5/internal/private"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,3 +46,58 @@ func TestTarDirectory(t *testing.T) {
 	}
 	assert.Equal(t, 1, numItems)
 }
+
+func TestTarDirectoryOrder(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("writing to /proc/self/fd is only supported on Linux")
+	}
+
+	srcDir := t.TempDir()
+	blobsDir := filepath.Join(srcDir, "blobs", "sha256")
+	err := os.MkdirAll(blobsDir, 0o755)
+	require.NoError(t, err)
+	writeBlob := func(contents string) digest.Digest {
+		d := digest.FromString(contents)
+		err := os.WriteFile(filepath.Join(blobsDir, d.Encoded()), []byte(contents), 0o644)
+		require.NoError(t, err)
+		return d
+	}
+	layer := writeBlob("layer1")
+	config := writeBlob(`{}`)
+	manifest := writeBlob(`{"schemaVersion":2,"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` + config.String() + `","size":2},` +
+		`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"` + layer.String() + `","size":6}]}`)
+	err = os.WriteFile(filepath.Join(srcDir, "index.json"), []byte(`{"schemaVersion":2,"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"`+manifest.String()+`","size":1}]}`), 0o644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(srcDir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644)
+	require.NoError(t, err)
+
+	// Write to a pipe, to make sure no seeking is needed.
+	pr, pw, err := os.Pipe()
+	require.NoError(t, err)
+	defer pr.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- tarDirectory(srcDir, fmt.Sprintf("/proc/self/fd/%d", pw.Fd()))
+		pw.Close()
+	}()
+	names := []string{}
+	reader := tar.NewReader(pr)
+	for {
+		hdr, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	require.NoError(t, <-errCh)
+	assert.Equal(t, []string{
+		"blobs/",
+		"blobs/sha256/",
+		"oci-layout",
+		"index.json",
+		"blobs/sha256/" + manifest.Encoded(),
+		"blobs/sha256/" + config.Encoded(),
+		"blobs/sha256/" + layer.Encoded(),
+	}, names)
+}
@@ -8,13 +8,13 @@ import (
 
 	"github.com/containers/image/v5/internal/imagesource"
 	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/signature"
 	ocilayout "github.com/containers/image/v5/oci/layout"
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 // ImageNotFoundError is used when the OCI structure, in principle, exists and seems valid enough,
@@ -94,7 +94,7 @@ func LoadManifestDescriptorWithContext(sys *types.SystemContext, imgRef types.Im
 	}
 	defer func() {
 		err := tempDirRef.deleteTempDir()
-		logrus.Debugf("Error deleting temporary directory: %v", err)
+		log.Debugf("Error deleting temporary directory: %v", err)
 	}()
 
 	descriptor, err := ocilayout.LoadManifestDescriptor(tempDirRef.ociRefExtracted)
@@ -114,7 +114,7 @@ func (s *ociArchiveImageSource) Reference() types.ImageReference {
 func (s *ociArchiveImageSource) Close() error {
 	defer func() {
 		err := s.tempDirRef.deleteTempDir()
-		logrus.Debugf("error deleting tmp dir: %v", err)
+		log.Debugf("error deleting tmp dir: %v", err)
 	}()
 	return s.unpackedSrc.Close()
 }
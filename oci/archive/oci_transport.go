@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/containers/image/v5/directory/explicitfilepath"
@@ -62,7 +63,12 @@ func ParseReference(reference string) (types.ImageReference, error) {
 func NewReference(file, image string) (types.ImageReference, error) {
 	resolved, err := explicitfilepath.ResolvePathToFullyExplicit(file)
 	if err != nil {
-		return nil, err
+		// Paths like /dev/stdout may refer to a pipe or a socket, which has no resolvable path; use such streams as they are.
+		fi, statErr := os.Stat(file)
+		if statErr != nil || !filepath.IsAbs(file) || fi.Mode().IsRegular() || fi.IsDir() {
+			return nil, err
+		}
+		resolved = filepath.Clean(file)
 	}
 
 	if err := internal.ValidateOCIPath(file); err != nil {
@@ -189,6 +195,15 @@ func createUntarTempDir(sys *types.SystemContext, ref ociArchiveReference) (temp
 	}
 	dst := tempDirRef.tempDirectory
 
+	if fi, err := arch.Stat(); err == nil {
+		if err := tmpdir.CheckFreeSpace(sys, dst, fi.Size()); err != nil {
+			if err2 := tempDirRef.deleteTempDir(); err2 != nil {
+				return tempDirOCIRef{}, fmt.Errorf("deleting temp directory %q: %w", tempDirRef.tempDirectory, err2)
+			}
+			return tempDirOCIRef{}, err
+		}
+	}
+
 	// TODO: This can take quite some time, and should ideally be cancellable using a context.Context.
 	if err := archive.NewDefaultArchiver().Untar(arch, dst, &archive.TarOptions{NoLchown: true}); err != nil {
 		if err := tempDirRef.deleteTempDir(); err != nil {
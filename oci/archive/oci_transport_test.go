@@ -2,8 +2,10 @@ package archive
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	_ "github.com/containers/image/v5/internal/testing/explicitfilepath-tmpdir"
@@ -107,6 +109,20 @@ func TestNewReference(t *testing.T) {
 
 	_, err = NewReference(tmpDir+"/has:colon", imageValue)
 	assert.Error(t, err)
+
+	// A stream without a resolvable path, like /dev/stdout pointing at a pipe, is used as is.
+	if runtime.GOOS == "linux" {
+		pr, pw, err := os.Pipe()
+		require.NoError(t, err)
+		defer pr.Close()
+		defer pw.Close()
+		pipePath := fmt.Sprintf("/proc/self/fd/%d", pw.Fd())
+		ref, err = NewReference(pipePath, imageValue)
+		require.NoError(t, err)
+		ociArchRef, ok = ref.(ociArchiveReference)
+		require.True(t, ok)
+		assert.Equal(t, pipePath, ociArchRef.resolvedFile)
+	}
 }
 
 // refToTempOCI creates a temporary directory and returns an reference to it.
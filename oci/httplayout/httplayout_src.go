@@ -0,0 +1,258 @@
+package httplayout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+
+	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type httpLayoutImageSource struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	impl.NoSignatures
+	impl.DoesNotAffectLayerInfosForCopy
+	stubs.ImplementsGetBlobAt
+
+	ref        httpLayoutReference
+	client     *http.Client
+	index      *imgspecv1.Index
+	descriptor imgspecv1.Descriptor
+}
+
+// newImageSource returns an ImageSource for reading from an OCI layout served over HTTP(S).
+func newImageSource(ctx context.Context, sys *types.SystemContext, ref httpLayoutReference) (private.ImageSource, error) {
+	tr := tlsclientconfig.NewTransport()
+	tr.TLSClientConfig = tlsconfig.ClientDefault()
+	s := &httpLayoutImageSource{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			HasThreadSafeGetBlob: true,
+		}),
+
+		ref:    ref,
+		client: &http.Client{Transport: tr},
+	}
+	s.Compat = impl.AddCompat(s)
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			s.client.CloseIdleConnections()
+		}
+	}()
+
+	indexJSON, err := s.getSmallFile(ctx, ref.indexURL())
+	if err != nil {
+		return nil, err
+	}
+	index := imgspecv1.Index{}
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ref.indexURL().Redacted(), err)
+	}
+	descriptor, err := ref.getManifestDescriptor(&index)
+	if err != nil {
+		return nil, err
+	}
+	s.index = &index
+	s.descriptor = descriptor
+	succeeded = true
+	return s, nil
+}
+
+// Reference returns the reference used to set up this source.
+func (s *httpLayoutImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+// Close removes resources associated with an initialized ImageSource, if any.
+func (s *httpLayoutImageSource) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// get sends a GET request for u, with an optional Range header value, and returns the response if it indicates success.
+func (s *httpLayoutImageSource) get(ctx context.Context, u *url.URL, rangeValue string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeValue != "" {
+		req.Header.Set("Range", rangeValue)
+	}
+	log.DebugfContext(ctx, "GET %s", u.Redacted())
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		res.Body.Close()
+		return nil, fmt.Errorf("fetching %s: HTTP status %s", u.Redacted(), res.Status)
+	}
+	return res, nil
+}
+
+// getSmallFile returns the contents of u, which is expected to be small enough to fit into memory.
+func (s *httpLayoutImageSource) getSmallFile(ctx context.Context, u *url.URL) ([]byte, error) {
+	res, err := s.get(ctx, u, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return iolimits.ReadAtMost(res.Body, iolimits.MaxManifestBodySize)
+}
+
+// GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
+// It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
+// this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
+func (s *httpLayoutImageSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	var dig digest.Digest
+	var mimeType string
+	if instanceDigest == nil {
+		dig = s.descriptor.Digest
+		mimeType = s.descriptor.MediaType
+	} else {
+		dig = *instanceDigest
+		for _, md := range s.index.Manifests {
+			if md.Digest == dig {
+				mimeType = md.MediaType
+				break
+			}
+		}
+	}
+
+	u, err := s.ref.blobURL(dig)
+	if err != nil {
+		return nil, "", err
+	}
+	m, err := s.getSmallFile(ctx, u)
+	if err != nil {
+		return nil, "", err
+	}
+	if mimeType == "" {
+		mimeType = manifest.GuessMIMEType(m)
+	}
+	return m, mimeType, nil
+}
+
+// GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
+// The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
+// May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
+func (s *httpLayoutImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	u, err := s.ref.blobURL(info.Digest)
+	if err != nil {
+		return nil, -1, err
+	}
+	res, err := s.get(ctx, u, "")
+	if err != nil {
+		return nil, -1, err
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+// GetBlobAt returns a sequential channel of readers that contain data for the requested
+// blob chunks, and a channel that might get a single error value.
+// The specified chunks must be not overlapping and sorted by their offset.
+// The readers must be fully consumed, in the order they are returned, before blocking
+// to read the next chunk.
+// If the Length for the last chunk is set to math.MaxUint64, then it
+// fully fetches the remaining data from the offset to the end of the blob.
+func (s *httpLayoutImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	u, err := s.blobURLForRanges(info, chunks)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chunks) == 0 {
+		streams := make(chan io.ReadCloser)
+		errs := make(chan error)
+		close(streams)
+		close(errs)
+		return streams, errs, nil
+	}
+	// Many static web servers and CDNs don’t support multiple ranges in a single request, so fetch the chunks one by one.
+	// Fetch the first one synchronously to detect servers that don’t support ranges at all, so that the caller can fall back.
+	first, err := s.getRange(ctx, u, chunks[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+	go func() {
+		defer close(streams)
+		defer close(errs)
+		stream := &signalCloseReader{closed: make(chan struct{}), stream: first}
+		streams <- stream
+		<-stream.closed
+		for _, c := range chunks[1:] {
+			body, err := s.getRange(ctx, u, c)
+			if err != nil {
+				errs <- err
+				return
+			}
+			stream := &signalCloseReader{closed: make(chan struct{}), stream: body}
+			streams <- stream
+			// Wait until the stream is closed before fetching the next chunk.
+			<-stream.closed
+		}
+	}()
+	return streams, errs, nil
+}
+
+// blobURLForRanges validates the GetBlobAt inputs, and returns the URL of the blob.
+func (s *httpLayoutImageSource) blobURLForRanges(info types.BlobInfo, chunks []private.ImageSourceChunk) (*url.URL, error) {
+	for i, c := range chunks {
+		if c.Length == math.MaxUint64 && i != len(chunks)-1 {
+			return nil, fmt.Errorf("internal error: another chunk requested after an util-EOF chunk")
+		}
+	}
+	return s.ref.blobURL(info.Digest)
+}
+
+// getRange returns the contents of a chunk of u.
+// It returns private.BadPartialRequestError if the server does not support range requests.
+func (s *httpLayoutImageSource) getRange(ctx context.Context, u *url.URL, c private.ImageSourceChunk) (io.ReadCloser, error) {
+	var rangeValue string
+	if c.Length == math.MaxUint64 {
+		rangeValue = fmt.Sprintf("bytes=%d-", c.Offset)
+	} else {
+		rangeValue = fmt.Sprintf("bytes=%d-%d", c.Offset, c.Offset+c.Length-1)
+	}
+	res, err := s.get(ctx, u, rangeValue)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusPartialContent {
+		res.Body.Close()
+		return nil, private.BadPartialRequestError{Status: res.Status}
+	}
+	return res.Body, nil
+}
+
+// signalCloseReader is a ReadCloser that signals, by closing a channel, when it is closed.
+type signalCloseReader struct {
+	closed chan struct{}
+	stream io.ReadCloser
+}
+
+func (s *signalCloseReader) Read(p []byte) (int, error) {
+	return s.stream.Read(p)
+}
+
+func (s *signalCloseReader) Close() error {
+	defer close(s.closed)
+	return s.stream.Close()
+}
@@ -0,0 +1,156 @@
+package httplayout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ private.ImageSource = (*httpLayoutImageSource)(nil)
+
+// writeBlob writes data into the blobs directory of an OCI layout at dir, and returns its digest.
+func writeBlob(t *testing.T, dir string, data []byte) digest.Digest {
+	d := digest.FromBytes(data)
+	blobDir := filepath.Join(dir, "blobs", d.Algorithm().String())
+	err := os.MkdirAll(blobDir, 0o755)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(blobDir, d.Encoded()), data, 0o644)
+	require.NoError(t, err)
+	return d
+}
+
+// newTestServer returns a server for an OCI layout containing a single image named "image",
+// with the manifest and a blob.
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, []byte, []byte) {
+	dir := t.TempDir()
+	blob := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	man := []byte(`{"schemaVersion":2,"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` +
+		digest.FromBytes(blob).String() + `","size":36},"layers":[]}`)
+	writeBlob(t, dir, blob)
+	manDigest := writeBlob(t, dir, man)
+	index, err := json.Marshal(imgspecv1.Index{
+		Versioned: imgspec.Versioned{SchemaVersion: 2},
+		Manifests: []imgspecv1.Descriptor{{
+			MediaType:   imgspecv1.MediaTypeImageManifest,
+			Digest:      manDigest,
+			Size:        int64(len(man)),
+			Annotations: map[string]string{imgspecv1.AnnotationRefName: "image"},
+		}},
+	})
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "index.json"), index, 0o644)
+	require.NoError(t, err)
+
+	var handler http.Handler = http.StripPrefix("/layout", http.FileServer(http.Dir(dir)))
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, man, blob
+}
+
+func TestImageSource(t *testing.T) {
+	server, man, blob := newTestServer(t, nil)
+	ctx := context.Background()
+
+	for _, image := range []string{"", "image"} {
+		ref, err := NewReference(server.URL+"/layout", image)
+		require.NoError(t, err)
+		src, err := ref.NewImageSource(ctx, nil)
+		require.NoError(t, err, image)
+		defer src.Close()
+
+		m, mimeType, err := src.GetManifest(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, man, m)
+		assert.Equal(t, imgspecv1.MediaTypeImageManifest, mimeType)
+
+		rc, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, memory.New())
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		assert.Equal(t, blob, data)
+		assert.Equal(t, int64(len(blob)), size)
+
+		_, _, err = src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromBytes([]byte("missing")), Size: -1}, memory.New())
+		assert.Error(t, err)
+	}
+
+	ref, err := NewReference(server.URL+"/layout", "missing")
+	require.NoError(t, err)
+	_, err = ref.NewImageSource(ctx, nil)
+	assert.ErrorAs(t, err, &ImageNotFoundError{})
+
+	ref, err = NewReference(server.URL+"/missing", "")
+	require.NoError(t, err)
+	_, err = ref.NewImageSource(ctx, nil)
+	assert.Error(t, err)
+}
+
+func TestGetBlobAt(t *testing.T) {
+	chunks := []private.ImageSourceChunk{
+		{Offset: 0, Length: 2},
+		{Offset: 10, Length: 3},
+		{Offset: 33, Length: math.MaxUint64},
+	}
+
+	server, _, blob := newTestServer(t, nil)
+	ctx := context.Background()
+	ref, err := NewReference(server.URL+"/layout", "")
+	require.NoError(t, err)
+	src, err := ref.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+	privateSrc, ok := src.(private.ImageSource)
+	require.True(t, ok)
+
+	streams, errs, err := privateSrc.GetBlobAt(ctx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, chunks)
+	require.NoError(t, err)
+	res := []string{}
+	for s := range streams {
+		data, err := io.ReadAll(s)
+		s.Close()
+		require.NoError(t, err)
+		res = append(res, string(data))
+	}
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"01", "abc", "xyz"}, res)
+
+	// A server which ignores Range headers
+	server, _, blob = newTestServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Del("Range")
+			h.ServeHTTP(w, r)
+		})
+	})
+	ref, err = NewReference(server.URL+"/layout", "")
+	require.NoError(t, err)
+	src, err = ref.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+	privateSrc, ok = src.(private.ImageSource)
+	require.True(t, ok)
+	_, _, err = privateSrc.GetBlobAt(ctx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, chunks)
+	var e private.BadPartialRequestError
+	assert.True(t, errors.As(err, &e))
+}
@@ -0,0 +1,258 @@
+package httplayout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/oci/internal"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func init() {
+	transports.Register(Transport)
+}
+
+var (
+	// Transport is an ImageTransport for OCI image layouts served by plain HTTP(S) servers.
+	Transport = httpLayoutTransport{}
+
+	// ErrMoreThanOneImage is an error returned when the index includes
+	// more than one image and the user should choose which one to use.
+	ErrMoreThanOneImage = errors.New("more than one image in the OCI layout, choose an image")
+)
+
+type httpLayoutTransport struct{}
+
+func (t httpLayoutTransport) Name() string {
+	return "oci-http"
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an ImageReference.
+func (t httpLayoutTransport) ParseReference(reference string) (types.ImageReference, error) {
+	return ParseReference(reference)
+}
+
+// ValidatePolicyConfigurationScope checks that scope is a valid name for a signature.PolicyTransportScopes keys
+// (i.e. a valid PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() return value).
+// It is acceptable to allow an invalid value which will never be matched, it can "only" cause user confusion.
+// scope passed to this function will not be "", that value is always allowed.
+func (t httpLayoutTransport) ValidatePolicyConfigurationScope(scope string) error {
+	if _, err := parseLayoutURL(scope); err != nil {
+		return fmt.Errorf("Invalid scope %s: %w", scope, err)
+	}
+	return nil
+}
+
+// httpLayoutReference is an ImageReference for OCI image layouts served over HTTP(S).
+type httpLayoutReference struct {
+	layoutURL *url.URL // The URL of the directory containing index.json; the path has no trailing slash.
+	// If image=="", it means the "only image" in the index.json is used.
+	image string
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an oci-http ImageReference.
+// The expected format is http[s]://host[:port][/path][:image].
+func ParseReference(reference string) (types.ImageReference, error) {
+	scheme, rest, ok := strings.Cut(reference, "://")
+	if !ok {
+		return nil, fmt.Errorf("invalid reference %q, expected an http:// or https:// URL", reference)
+	}
+	// Colons in the host part separate a port number; the image name starts at the first colon in the path.
+	image := ""
+	if slash := strings.Index(rest, "/"); slash != -1 {
+		var path string
+		path, image, _ = strings.Cut(rest[slash:], ":")
+		if path == "/" {
+			path = ""
+		}
+		rest = rest[:slash] + path
+	}
+	return NewReference(scheme+"://"+rest, image)
+}
+
+// NewReference returns an oci-http reference for an OCI layout at layoutURL (the URL of the directory containing index.json), and an image.
+func NewReference(layoutURL string, image string) (types.ImageReference, error) {
+	u, err := parseLayoutURL(layoutURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := internal.ValidateImageName(image); err != nil {
+		return nil, err
+	}
+	return httpLayoutReference{layoutURL: u, image: image}, nil
+}
+
+// parseLayoutURL parses and validates an URL of an OCI layout, which must be in a canonical form.
+func parseLayoutURL(layoutURL string) (*url.URL, error) {
+	u, err := url.Parse(layoutURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout URL %q: %w", layoutURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid layout URL %q, expected an http:// or https:// URL", layoutURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid layout URL %q: missing host", layoutURL)
+	}
+	if u.User != nil || u.RawQuery != "" || u.ForceQuery || u.Fragment != "" || u.Opaque != "" {
+		return nil, fmt.Errorf("invalid layout URL %q: user information, queries and fragments are not supported", layoutURL)
+	}
+	if u.RawPath != "" {
+		return nil, fmt.Errorf("invalid layout URL %q: unexpected escaping in the path", layoutURL)
+	}
+	if strings.Contains(u.Path, ":") {
+		return nil, fmt.Errorf("invalid layout URL %q: the path contains a colon", layoutURL)
+	}
+	if u.Path != "" {
+		if cleaned := path.Clean(u.Path); cleaned != u.Path || cleaned == "/" {
+			return nil, fmt.Errorf("layout URL %q uses a non-canonical path", layoutURL)
+		}
+	}
+	if u.String() != layoutURL {
+		return nil, fmt.Errorf("layout URL %q uses a non-canonical format, perhaps try %q", layoutURL, u.String())
+	}
+	return u, nil
+}
+
+func (ref httpLayoutReference) Transport() types.ImageTransport {
+	return Transport
+}
+
+// StringWithinTransport returns a string representation of the reference, which MUST be such that
+// reference.Transport().ParseReference(reference.StringWithinTransport()) returns an equivalent reference.
+// NOTE: The returned string is not promised to be equal to the original input to ParseReference;
+// e.g. default attribute values omitted by the user may be filled in the return value, or vice versa.
+// WARNING: Do not use the return value in the UI to describe an image, it does not contain the Transport().Name() prefix.
+func (ref httpLayoutReference) StringWithinTransport() string {
+	if ref.layoutURL.Path == "" {
+		// Without a path, a colon would be interpreted as a port separator.
+		if ref.image == "" {
+			return ref.layoutURL.String()
+		}
+		return fmt.Sprintf("%s/:%s", ref.layoutURL.String(), ref.image)
+	}
+	return fmt.Sprintf("%s:%s", ref.layoutURL.String(), ref.image)
+}
+
+// DockerReference returns a Docker reference associated with this reference
+// (fully explicit, i.e. !reference.IsNameOnly, but reflecting user intent,
+// not e.g. after redirect or alias processing), or nil if unknown/not applicable.
+func (ref httpLayoutReference) DockerReference() reference.Named {
+	return nil
+}
+
+// PolicyConfigurationIdentity returns a string representation of the reference, suitable for policy lookup.
+// This MUST reflect user intent, not e.g. after processing of third-party redirects or aliases;
+// The value SHOULD be fully explicit about its semantics, with no hidden defaults, AND canonical
+// (i.e. various references with exactly the same semantics should return the same configuration identity)
+// It is fine for the return value to be equal to StringWithinTransport(), and it is desirable but
+// not required/guaranteed that it will be a valid input to Transport().ParseReference().
+// Returns "" if configuration identities for these references are not supported.
+func (ref httpLayoutReference) PolicyConfigurationIdentity() string {
+	// NOTE: ref.image is not a part of the image identity, for the same reasons as in the oci: transport.
+	return ref.layoutURL.String()
+}
+
+// PolicyConfigurationNamespaces returns a list of other policy configuration namespaces to search
+// for if explicit configuration for PolicyConfigurationIdentity() is not set.  The list will be processed
+// in order, terminating on first match, and an implicit "" is always checked at the end.
+// It is STRONGLY recommended for the first element, if any, to be a prefix of PolicyConfigurationIdentity(),
+// and each following element to be a prefix of the element preceding it.
+func (ref httpLayoutReference) PolicyConfigurationNamespaces() []string {
+	res := []string{}
+	u := *ref.layoutURL
+	for u.Path != "" {
+		u.Path = path.Dir(u.Path)
+		if u.Path == "/" {
+			u.Path = ""
+		}
+		res = append(res, u.String())
+	}
+	return res
+}
+
+// NewImage returns a types.ImageCloser for this reference, possibly specialized for this ImageTransport.
+// The caller must call .Close() on the returned ImageCloser.
+// NOTE: If any kind of signature verification should happen, build an UnparsedImage from the value returned by NewImageSource,
+// verify that UnparsedImage, and convert it into a real Image via image.FromUnparsedImage.
+// WARNING: This may not do the right thing for a manifest list, see image.FromSource for details.
+func (ref httpLayoutReference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	return image.FromReference(ctx, sys, ref)
+}
+
+// NewImageSource returns a types.ImageSource for this reference.
+// The caller must call .Close() on the returned ImageSource.
+func (ref httpLayoutReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	return newImageSource(ctx, sys, ref)
+}
+
+// NewImageDestination returns a types.ImageDestination for this reference.
+// The caller must call .Close() on the returned ImageDestination.
+func (ref httpLayoutReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	return nil, errors.New(`The "oci-http" transport is read-only`)
+}
+
+// DeleteImage deletes the named image from the registry, if supported.
+func (ref httpLayoutReference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
+	return errors.New(`Deleting images not supported by the "oci-http" transport`)
+}
+
+// fileURL returns the URL of name (a path relative to the root of the layout).
+func (ref httpLayoutReference) fileURL(name string) *url.URL {
+	u := *ref.layoutURL
+	u.Path = u.Path + "/" + name
+	return &u
+}
+
+// indexURL returns the URL of the index.json file.
+func (ref httpLayoutReference) indexURL() *url.URL {
+	return ref.fileURL(imgspecv1.ImageIndexFile)
+}
+
+// blobURL returns the URL of a blob using OCI image-layout conventions.
+func (ref httpLayoutReference) blobURL(digest digest.Digest) (*url.URL, error) {
+	if err := digest.Validate(); err != nil { // digest.Digest.Encoded() panics on failure, and could possibly result in a path with ../, so validate explicitly.
+		return nil, fmt.Errorf("unexpected digest reference %s: %w", digest, err)
+	}
+	return ref.fileURL(path.Join(imgspecv1.ImageBlobsDir, digest.Algorithm().String(), digest.Encoded())), nil
+}
+
+// getManifestDescriptor returns the descriptor in index selected by ref.image.
+func (ref httpLayoutReference) getManifestDescriptor(index *imgspecv1.Index) (imgspecv1.Descriptor, error) {
+	if ref.image == "" {
+		// return manifest if only one image is in the layout
+		if len(index.Manifests) != 1 {
+			// ask user to choose image when more than one image in the layout
+			return imgspecv1.Descriptor{}, ErrMoreThanOneImage
+		}
+		return index.Manifests[0], nil
+	}
+	md, i, err := internal.FindManifestDescriptorByName(index, ref.image)
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	if i == -1 {
+		return imgspecv1.Descriptor{}, ImageNotFoundError{ref}
+	}
+	return md, nil
+}
+
+// ImageNotFoundError is used when the OCI layout, in principle, exists and seems valid enough,
+// but nothing matches the “image” part of the provided reference.
+type ImageNotFoundError struct {
+	ref httpLayoutReference
+	// We may make members public, or add methods, in the future.
+}
+
+func (e ImageNotFoundError) Error() string {
+	return fmt.Sprintf("no descriptor found for reference %q", e.ref.image)
+}
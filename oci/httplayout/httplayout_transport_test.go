@@ -0,0 +1,151 @@
+package httplayout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportName(t *testing.T) {
+	assert.Equal(t, "oci-http", Transport.Name())
+}
+
+func TestTransportParseReference(t *testing.T) {
+	testParseReference(t, Transport.ParseReference)
+}
+
+func TestParseReference(t *testing.T) {
+	testParseReference(t, ParseReference)
+}
+
+// testParseReference is a test shared for Transport.ParseReference and ParseReference.
+func testParseReference(t *testing.T, fn func(string) (types.ImageReference, error)) {
+	for _, c := range []struct {
+		input, layoutURL, image string // layoutURL == "" if an error is expected
+	}{
+		{"https://example.com/layout", "https://example.com/layout", ""},
+		{"https://example.com/layout:", "https://example.com/layout", ""},
+		{"https://example.com/a/b:image", "https://example.com/a/b", "image"},
+		{"https://example.com/a/b:image:tag", "https://example.com/a/b", "image:tag"},
+		{"http://example.com:8080/layout:image", "http://example.com:8080/layout", "image"},
+		{"https://example.com", "https://example.com", ""},
+		{"https://example.com:8443", "https://example.com:8443", ""},
+		{"https://example.com/", "https://example.com", ""},
+		{"https://example.com/:image", "https://example.com", "image"},
+		{"example.com/layout", "", ""},                  // No scheme
+		{"ftp://example.com/layout", "", ""},            // Unsupported scheme
+		{"https:///layout", "", ""},                     // No host
+		{"https://example.com/layout/", "", ""},         // Trailing slash
+		{"https://example.com/a/../b", "", ""},          // Non-canonical path
+		{"https://example.com/layout?query=1", "", ""},  // Query
+		{"https://user@example.com/layout", "", ""},     // User information
+		{"https://example.com/layout:@invalid", "", ""}, // Invalid image name
+		{"https://example.com/layout#fragment", "", ""}, // Fragment
+		{"https://example.com/a%2Fb", "", ""},           // Non-canonical encoding
+	} {
+		ref, err := fn(c.input)
+		if c.layoutURL == "" {
+			assert.Error(t, err, c.input)
+			continue
+		}
+		require.NoError(t, err, c.input)
+		httpRef, ok := ref.(httpLayoutReference)
+		require.True(t, ok, c.input)
+		assert.Equal(t, c.layoutURL, httpRef.layoutURL.String(), c.input)
+		assert.Equal(t, c.image, httpRef.image, c.input)
+	}
+}
+
+func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
+	for _, scope := range []string{
+		"https://example.com",
+		"https://example.com/layout",
+		"http://example.com:8080/a/b",
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.NoError(t, err, scope)
+	}
+
+	for _, scope := range []string{
+		"example.com",
+		"https://example.com/",
+		"https://example.com/layout:image",
+		"https://example.com/a/../b",
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.Error(t, err, scope)
+	}
+}
+
+func TestReferenceTransport(t *testing.T) {
+	ref, err := NewReference("https://example.com/layout", "")
+	require.NoError(t, err)
+	assert.Equal(t, Transport, ref.Transport())
+}
+
+func TestReferenceStringWithinTransport(t *testing.T) {
+	for _, c := range []struct{ input, result string }{
+		{"https://example.com/layout", "https://example.com/layout:"},
+		{"https://example.com/layout:image", "https://example.com/layout:image"},
+		{"https://example.com", "https://example.com"},
+		{"https://example.com:8443/:image", "https://example.com:8443/:image"},
+	} {
+		ref, err := ParseReference(c.input)
+		require.NoError(t, err, c.input)
+		stringRef := ref.StringWithinTransport()
+		assert.Equal(t, c.result, stringRef, c.input)
+		// Do one more round to verify that the output can be parsed, to an equal value.
+		ref2, err := Transport.ParseReference(stringRef)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, ref, ref2, c.input)
+	}
+}
+
+func TestReferenceDockerReference(t *testing.T) {
+	ref, err := ParseReference("https://example.com/layout:image")
+	require.NoError(t, err)
+	assert.Nil(t, ref.DockerReference())
+}
+
+func TestReferencePolicyConfigurationIdentity(t *testing.T) {
+	ref, err := ParseReference("https://example.com/a/b:image")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a/b", ref.PolicyConfigurationIdentity())
+}
+
+func TestReferencePolicyConfigurationNamespaces(t *testing.T) {
+	for _, c := range []struct {
+		input    string
+		expected []string
+	}{
+		{"https://example.com", []string{}},
+		{"https://example.com/a:image", []string{"https://example.com"}},
+		{"https://example.com/a/b/c", []string{"https://example.com/a/b", "https://example.com/a", "https://example.com"}},
+	} {
+		ref, err := ParseReference(c.input)
+		require.NoError(t, err, c.input)
+		ns := ref.PolicyConfigurationNamespaces()
+		assert.Equal(t, c.expected, ns, c.input)
+		for _, n := range ns {
+			err := Transport.ValidatePolicyConfigurationScope(n)
+			assert.NoError(t, err, n)
+		}
+	}
+}
+
+func TestReferenceNewImageDestination(t *testing.T) {
+	ref, err := ParseReference("https://example.com/layout")
+	require.NoError(t, err)
+	_, err = ref.NewImageDestination(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+func TestReferenceDeleteImage(t *testing.T) {
+	ref, err := ParseReference("https://example.com/layout")
+	require.NoError(t, err)
+	err = ref.DeleteImage(context.Background(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/containers/image/v5/docker/reference"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// containerdImageNameAnnotation is the annotation used by containerd (and tools exporting in its format, like BuildKit)
+// to record the full image name, when org.opencontainers.image.ref.name only contains the tag.
+const containerdImageNameAnnotation = "io.containerd.image.name"
+
+// tagOnlyRegexp matches values that can only be interpreted as a tag, not as a repository name.
+var tagOnlyRegexp = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+// refNameMatchStage is one step of looking up an image by name in an index.
+type refNameMatchStage struct {
+	description   string                             // For error messages
+	matches       func(md imgspecv1.Descriptor) bool // Returns true if md matches the image name.
+	requireUnique bool                               // Refuse to choose if several distinct manifests match.
+}
+
+// FindManifestDescriptorByName returns the descriptor in index named by image (the “image” part of an OCI reference),
+// and its position in index.
+//
+// Tools differ in the values they record in the org.opencontainers.image.ref.name annotation
+// (a plain name, a tag only, or a full Docker reference), so image is matched in these steps,
+// using the first step which matches anything:
+//   - the annotation equals image exactly; if there are several such entries, the first one is used;
+//   - image and the annotation (or the io.containerd.image.name annotation) are equal as Docker references,
+//     after adding the default docker.io/library/ and :latest (e.g. "busybox" matches "docker.io/library/busybox:latest");
+//   - image is a reference with a tag, and the annotation only contains that tag (e.g. "quay.io/ns/app:v1" matches "v1"),
+//     unless io.containerd.image.name names a different image;
+//   - image is only a tag, and the annotation is a reference with that tag (e.g. "v1" matches "quay.io/ns/app:v1").
+//
+// In all steps except the first, an error is returned if more than one manifest matches.
+// If nothing matches, FindManifestDescriptorByName returns (imgspecv1.Descriptor{}, -1, nil);
+// callers should report that using their transport’s ImageNotFoundError.
+func FindManifestDescriptorByName(index *imgspecv1.Index, image string) (imgspecv1.Descriptor, int, error) {
+	stages := []refNameMatchStage{{
+		description: "exact name",
+		matches: func(md imgspecv1.Descriptor) bool {
+			refName, ok := md.Annotations[imgspecv1.AnnotationRefName]
+			return ok && refName == image
+		},
+		requireUnique: false, // Compatibility with earlier versions, which just used the first match.
+	}}
+	if named, err := reference.ParseNormalizedNamed(image); err == nil {
+		normalized := reference.TagNameOnly(named).String()
+		stages = append(stages, refNameMatchStage{
+			description: "normalized reference",
+			matches: func(md imgspecv1.Descriptor) bool {
+				for _, annotation := range []string{imgspecv1.AnnotationRefName, containerdImageNameAnnotation} {
+					if n, ok := normalizedAnnotationReference(md, annotation); ok && n.String() == normalized {
+						return true
+					}
+				}
+				return false
+			},
+			requireUnique: true,
+		})
+		if tagged, ok := named.(reference.NamedTagged); ok {
+			stages = append(stages, refNameMatchStage{
+				description: "tag",
+				matches: func(md imgspecv1.Descriptor) bool {
+					if md.Annotations[imgspecv1.AnnotationRefName] != tagged.Tag() {
+						return false
+					}
+					if n, ok := normalizedAnnotationReference(md, containerdImageNameAnnotation); ok && n.String() != normalized {
+						return false
+					}
+					return true
+				},
+				requireUnique: true,
+			})
+		}
+	}
+	if tagOnlyRegexp.MatchString(image) {
+		stages = append(stages, refNameMatchStage{
+			description: "tag of a full reference",
+			matches: func(md imgspecv1.Descriptor) bool {
+				n, ok := normalizedAnnotationReference(md, imgspecv1.AnnotationRefName)
+				if !ok {
+					return false
+				}
+				tagged, ok := n.(reference.NamedTagged)
+				return ok && tagged.Tag() == image && md.Annotations[imgspecv1.AnnotationRefName] != tagged.Tag()
+			},
+			requireUnique: true,
+		})
+	}
+
+	for _, stage := range stages {
+		var unsupportedMIMETypes []string
+		matchIndex := -1
+		for i, md := range index.Manifests {
+			if !stage.matches(md) {
+				continue
+			}
+			if md.MediaType != imgspecv1.MediaTypeImageManifest && md.MediaType != imgspecv1.MediaTypeImageIndex {
+				unsupportedMIMETypes = append(unsupportedMIMETypes, md.MediaType)
+				continue
+			}
+			if matchIndex == -1 {
+				matchIndex = i
+				if !stage.requireUnique {
+					break
+				}
+			} else if index.Manifests[matchIndex].Digest != md.Digest {
+				return imgspecv1.Descriptor{}, -1, fmt.Errorf("reference %q is ambiguous, it matches (by %s) both %q and %q",
+					image, stage.description, index.Manifests[matchIndex].Annotations[imgspecv1.AnnotationRefName], md.Annotations[imgspecv1.AnnotationRefName])
+			}
+		}
+		if matchIndex != -1 {
+			return index.Manifests[matchIndex], matchIndex, nil
+		}
+		if len(unsupportedMIMETypes) != 0 {
+			return imgspecv1.Descriptor{}, -1, fmt.Errorf("reference %q matches unsupported manifest MIME types %q", image, unsupportedMIMETypes)
+		}
+	}
+	return imgspecv1.Descriptor{}, -1, nil
+}
+
+// normalizedAnnotationReference returns the value of annotation in md, if it is a Docker reference without a digest,
+// normalized to always include a tag.
+func normalizedAnnotationReference(md imgspecv1.Descriptor, annotation string) (reference.Named, bool) {
+	value, ok := md.Annotations[annotation]
+	if !ok {
+		return nil, false
+	}
+	named, err := reference.ParseNormalizedNamed(value)
+	if err != nil {
+		return nil, false
+	}
+	if _, isDigested := named.(reference.Digested); isDigested {
+		return nil, false
+	}
+	return reference.TagNameOnly(named), true
+}
+
+// ImageNames returns the values of the org.opencontainers.image.ref.name annotation in index,
+// in the order they appear, without duplicates.
+func ImageNames(index *imgspecv1.Index) []string {
+	res := []string{}
+	seen := map[string]struct{}{}
+	for _, md := range index.Manifests {
+		refName, ok := md.Annotations[imgspecv1.AnnotationRefName]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[refName]; ok {
+			continue
+		}
+		seen[refName] = struct{}{}
+		res = append(res, refName)
+	}
+	return res
+}
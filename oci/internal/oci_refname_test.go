@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindManifestDescriptorByName(t *testing.T) {
+	entry := func(hex, mediaType string, annotations ...string) imgspecv1.Descriptor {
+		res := imgspecv1.Descriptor{
+			MediaType:   mediaType,
+			Digest:      digest.Digest("sha256:" + hex),
+			Annotations: map[string]string{},
+		}
+		for i := 0; i < len(annotations); i += 2 {
+			res.Annotations[annotations[i]] = annotations[i+1]
+		}
+		return res
+	}
+	const (
+		manifest = imgspecv1.MediaTypeImageManifest
+		refName  = imgspecv1.AnnotationRefName
+	)
+	index := &imgspecv1.Index{Manifests: []imgspecv1.Descriptor{
+		entry("01", manifest, refName, "plain"),
+		entry("02", manifest, refName, "docker.io/library/busybox:latest"),
+		entry("03", manifest, refName, "quay.io/ns/app:v1"),
+		entry("04", manifest, refName, "v2"),
+		entry("05", manifest, refName, "v3", containerdImageNameAnnotation, "quay.io/ns/app:v3"),
+		entry("06", manifest, refName, "v3", containerdImageNameAnnotation, "quay.io/ns/other:v3"),
+		entry("07", manifest, refName, "quay.io/a/x:dup"),
+		entry("08", manifest, refName, "quay.io/b/y:dup"),
+		entry("09", imgspecv1.MediaTypeImageIndex, refName, "quay.io/ns/app:v4"),
+		entry("0a", imgspecv1.MediaTypeImageIndex, refName, "quay.io/ns/app:v4-copy"),
+		entry("0a", imgspecv1.MediaTypeImageIndex, refName, "v4-copy"),
+		entry("0b", "x-completely-unknown", refName, "quay.io/ns/unsupported:v5"),
+		entry("0c", manifest, refName, "plain"),
+	}}
+
+	for _, c := range []struct {
+		image         string
+		expectedIndex int // -1 if not found
+	}{
+		{"plain", 0},                            // Exact match, the first one is used
+		{"docker.io/library/busybox:latest", 1}, // Exact match
+		{"busybox", 1},                          // Normalized reference
+		{"busybox:latest", 1},                   // Normalized reference
+		{"v1", 2},                               // Tag of a full reference
+		{"quay.io/ns/app:v2", 3},                // Tag-only annotation
+		{"quay.io/ns/app:v3", 4},                // Tag-only annotation, disambiguated by the containerd annotation
+		{"quay.io/ns/other:v3", 5},              // Normalized reference via the containerd annotation
+		{"v3", 4},                               // Exact match of two entries, the first one is used
+		{"quay.io/ns/app:v4", 8},                // Exact match of an index
+		{"v4-copy", 10},                         // Exact match preferred over a tag of a full reference
+		{"quay.io/ns/app:v4-copy", 9},           // Exact match; the tag-only entry points at the same manifest anyway
+		{"quay.io/ns/app:v6", -1},
+		{"v6", -1},
+		{"this-does-not-exist", -1},
+	} {
+		res, i, err := FindManifestDescriptorByName(index, c.image)
+		require.NoError(t, err, c.image)
+		assert.Equal(t, c.expectedIndex, i, c.image)
+		if c.expectedIndex == -1 {
+			assert.Equal(t, imgspecv1.Descriptor{}, res, c.image)
+		} else {
+			assert.Equal(t, index.Manifests[c.expectedIndex], res, c.image)
+		}
+	}
+
+	for _, image := range []string{
+		"dup",                       // Tag of two different full references
+		"quay.io/ns/unsupported:v5", // Unsupported MIME type
+		"v5",                        // Unsupported MIME type, via a tag of a full reference
+	} {
+		_, _, err := FindManifestDescriptorByName(index, image)
+		assert.Error(t, err, image)
+	}
+}
+
+func TestImageNames(t *testing.T) {
+	index := &imgspecv1.Index{Manifests: []imgspecv1.Descriptor{
+		{Annotations: map[string]string{imgspecv1.AnnotationRefName: "b"}},
+		{Annotations: map[string]string{"other": "x"}},
+		{Annotations: map[string]string{imgspecv1.AnnotationRefName: "a"}},
+		{},
+		{Annotations: map[string]string{imgspecv1.AnnotationRefName: "b"}},
+	}}
+	assert.Equal(t, []string{"b", "a"}, ImageNames(index))
+	assert.Equal(t, []string{}, ImageNames(&imgspecv1.Index{}))
+}
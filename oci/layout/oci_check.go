@@ -0,0 +1,391 @@
+package layout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// CheckOptions allows supplying non-default configuration modifying the behavior of CheckLayout.
+type CheckOptions struct {
+	// If true, entries of index.json which refer to damaged images are removed from index.json.
+	RemoveDamagedEntries bool
+	// If true, blobs whose contents do not match their digest are deleted.
+	// As with DeleteImage, blobs are only deleted from the layout’s own blobs directory, never from types.SystemContext.OCISharedBlobDirPath.
+	RemoveCorruptBlobs bool
+}
+
+// DamageKind is the kind of a problem found by CheckLayout.
+type DamageKind int
+
+const (
+	// DamageMissingBlob means a blob referenced by a descriptor does not exist.
+	DamageMissingBlob DamageKind = iota
+	// DamageSizeMismatch means the size of a blob does not match the size in a descriptor referencing it.
+	DamageSizeMismatch
+	// DamageDigestMismatch means the contents of a blob do not match its digest.
+	DamageDigestMismatch
+	// DamageInvalidManifest means a manifest or an index can not be parsed, or it contains an invalid descriptor.
+	DamageInvalidManifest
+	// DamageInvalidBlobName means a file in the blobs directory is not named after a valid digest.
+	DamageInvalidBlobName
+)
+
+// String returns a human-readable description of k.
+func (k DamageKind) String() string {
+	switch k {
+	case DamageMissingBlob:
+		return "missing blob"
+	case DamageSizeMismatch:
+		return "size mismatch"
+	case DamageDigestMismatch:
+		return "digest mismatch"
+	case DamageInvalidManifest:
+		return "invalid manifest"
+	case DamageInvalidBlobName:
+		return "invalid blob name"
+	default:
+		return fmt.Sprintf("DamageKind(%d)", int(k))
+	}
+}
+
+// Damage is a single problem found by CheckLayout.
+type Damage struct {
+	Kind   DamageKind
+	Digest digest.Digest // The digest of the affected blob; empty for DamageInvalidBlobName
+	Path   string        // The path of the affected blob
+	Detail string        // A human-readable description of the problem
+}
+
+// CheckReport is the outcome of CheckLayout.
+type CheckReport struct {
+	Damage []Damage
+	// The index.json entries which refer to damaged images, i.e. images where a manifest, index, config or layer
+	// (transitively) referenced by the entry is damaged.
+	DamagedEntries []imgspecv1.Descriptor
+	// Blobs in the layout’s blobs directory which are not referenced by any image in index.json.
+	// This is not a problem in itself; it is reported only if types.SystemContext.OCISharedBlobDirPath is not set.
+	UnreferencedBlobs []digest.Digest
+	// Changes made because of CheckOptions.
+	RemovedEntries []imgspecv1.Descriptor
+	RemovedBlobs   []digest.Digest
+}
+
+// OK returns true if r does not report any damage.
+func (r *CheckReport) OK() bool {
+	return len(r.Damage) == 0
+}
+
+// CheckLayout verifies the integrity of the OCI layout in dir: that all blobs referenced, directly or indirectly,
+// by index.json exist, match the size in the referencing descriptors, and match their digests, that all manifests
+// and indexes can be parsed, and that all other files in the blobs directory are named after their digest as well.
+// Blobs of non-distributable layers which have URLs are allowed to be missing.
+//
+// Problems with individual blobs are returned in the report; an error is returned only if the layout can not
+// be checked at all (e.g. if index.json is missing or invalid), or if changes requested by options fail.
+// options may be nil.
+func CheckLayout(ctx context.Context, sys *types.SystemContext, dir string, options *CheckOptions) (*CheckReport, error) {
+	if options == nil {
+		options = &CheckOptions{}
+	}
+	r, err := NewReference(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	ref := r.(ociReference)
+	layout, err := parseJSON[imgspecv1.ImageLayout](ref.ociLayoutPath())
+	if err != nil {
+		return nil, fmt.Errorf("reading OCI layout version: %w", err)
+	}
+	if layout.Version != imgspecv1.ImageLayoutVersion {
+		return nil, fmt.Errorf("unsupported OCI layout version %q", layout.Version)
+	}
+	index, err := ref.getIndex()
+	if err != nil {
+		return nil, fmt.Errorf("reading OCI layout index: %w", err)
+	}
+
+	c := layoutChecker{
+		ref:      ref,
+		report:   &CheckReport{},
+		blobs:    map[digest.Digest]*checkedBlob{},
+		subtrees: map[digest.Digest]bool{},
+	}
+	if sys != nil {
+		c.sharedBlobDir = sys.OCISharedBlobDirPath
+	}
+	intactEntries := []imgspecv1.Descriptor{}
+	for _, desc := range index.Manifests {
+		intact, err := c.checkDescriptor(ctx, desc, 0)
+		if err != nil {
+			return nil, err
+		}
+		if intact {
+			intactEntries = append(intactEntries, desc)
+		} else {
+			c.report.DamagedEntries = append(c.report.DamagedEntries, desc)
+		}
+	}
+	if c.sharedBlobDir == "" {
+		if err := c.checkUnreferencedBlobs(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.RemoveCorruptBlobs {
+		for _, damage := range c.report.Damage {
+			if damage.Kind != DamageDigestMismatch || c.sharedBlobDir != "" {
+				continue
+			}
+			if err := deleteBlob(damage.Path); err != nil {
+				return nil, err
+			}
+			c.report.RemovedBlobs = append(c.report.RemovedBlobs, damage.Digest)
+		}
+	}
+	if options.RemoveDamagedEntries && len(c.report.DamagedEntries) != 0 {
+		index.Manifests = intactEntries
+		if err := saveJSON(ref.indexPath(), index); err != nil {
+			return nil, err
+		}
+		c.report.RemovedEntries = c.report.DamagedEntries
+	}
+	return c.report, nil
+}
+
+// maxCheckDepth is the maximum nesting of indexes CheckLayout follows.
+const maxCheckDepth = 16
+
+// checkedBlob records the outcome of checking a single blob.
+type checkedBlob struct {
+	path     string
+	invalid  bool // The digest is invalid or unsupported; this has already been reported
+	exists   bool
+	reported bool // The blob is missing, and this has already been reported
+	size     int64
+	intact   bool   // The blob exists, and matches its digest
+	data     []byte // The contents of the blob, if it was read as a manifest or index which is not too large
+	tooLarge bool   // The blob was read as a manifest or index, but it is too large
+	// Sizes expected by descriptors, which do not match size, and have already been reported
+	reportedSizes *set.Set[int64]
+}
+
+// layoutChecker is the state of a single CheckLayout call.
+type layoutChecker struct {
+	ref           ociReference
+	sharedBlobDir string
+	report        *CheckReport
+	blobs         map[digest.Digest]*checkedBlob
+	subtrees      map[digest.Digest]bool // Blobs which are manifests or indexes, and whether everything they reference is intact
+}
+
+// addDamage records a problem with the blob at path.
+func (c *layoutChecker) addDamage(kind DamageKind, d digest.Digest, path, detail string) {
+	c.report.Damage = append(c.report.Damage, Damage{Kind: kind, Digest: d, Path: path, Detail: detail})
+}
+
+// checkDescriptor checks the blob referenced by desc, and everything it references, and returns true if it is all intact.
+func (c *layoutChecker) checkDescriptor(ctx context.Context, desc imgspecv1.Descriptor, depth int) (bool, error) {
+	isManifest := isManifestMediaType(desc.MediaType)
+	blob, err := c.checkBlob(ctx, desc.Digest, isManifest)
+	if err != nil {
+		return false, err
+	}
+	if blob.invalid {
+		return false, nil
+	}
+	if !blob.exists {
+		if len(desc.URLs) != 0 {
+			return true, nil // A non-distributable layer, which is not expected to be present
+		}
+		if !blob.reported {
+			c.addDamage(DamageMissingBlob, desc.Digest, blob.path, fmt.Sprintf("blob %s is missing", desc.Digest))
+			blob.reported = true
+		}
+		return false, nil
+	}
+	intact := blob.intact
+	if blob.size != desc.Size {
+		intact = false
+		// If the blob does not match its digest, a size mismatch is expected, and not worth reporting separately.
+		if blob.intact && !blob.reportedSizes.Contains(desc.Size) {
+			c.addDamage(DamageSizeMismatch, desc.Digest, blob.path, fmt.Sprintf("blob %s has size %d, but a descriptor expects %d", desc.Digest, blob.size, desc.Size))
+			blob.reportedSizes.Add(desc.Size)
+		}
+	}
+	if !isManifest || !blob.intact {
+		return intact, nil
+	}
+
+	subtreeIntact, ok := c.subtrees[desc.Digest]
+	if !ok {
+		subtreeIntact, err = c.checkManifestReferences(ctx, desc, blob, depth)
+		if err != nil {
+			return false, err
+		}
+		c.subtrees[desc.Digest] = subtreeIntact
+	}
+	return intact && subtreeIntact, nil
+}
+
+// checkManifestReferences parses the manifest or index in blob, referenced by desc, and checks all blobs it references.
+func (c *layoutChecker) checkManifestReferences(ctx context.Context, desc imgspecv1.Descriptor, blob *checkedBlob, depth int) (bool, error) {
+	if blob.tooLarge {
+		c.addDamage(DamageInvalidManifest, desc.Digest, blob.path, fmt.Sprintf("manifest %s is too large", desc.Digest))
+		return false, nil
+	}
+	var children []imgspecv1.Descriptor
+	switch desc.MediaType {
+	case imgspecv1.MediaTypeImageIndex, manifest.DockerV2ListMediaType:
+		if depth >= maxCheckDepth {
+			c.addDamage(DamageInvalidManifest, desc.Digest, blob.path, fmt.Sprintf("index %s is nested too deeply", desc.Digest))
+			return false, nil
+		}
+		var index imgspecv1.Index
+		if err := json.Unmarshal(blob.data, &index); err != nil {
+			c.addDamage(DamageInvalidManifest, desc.Digest, blob.path, fmt.Sprintf("parsing index %s: %v", desc.Digest, err))
+			return false, nil
+		}
+		children = index.Manifests
+	default:
+		var m imgspecv1.Manifest
+		if err := json.Unmarshal(blob.data, &m); err != nil {
+			c.addDamage(DamageInvalidManifest, desc.Digest, blob.path, fmt.Sprintf("parsing manifest %s: %v", desc.Digest, err))
+			return false, nil
+		}
+		children = append([]imgspecv1.Descriptor{m.Config}, m.Layers...)
+	}
+
+	res := true
+	for _, child := range children {
+		intact, err := c.checkDescriptor(ctx, child, depth+1)
+		if err != nil {
+			return false, err
+		}
+		res = res && intact
+	}
+	return res, nil
+}
+
+// checkBlob checks the blob with digest d, and reads its contents if isManifest.
+// Each blob is only checked once.
+func (c *layoutChecker) checkBlob(ctx context.Context, d digest.Digest, isManifest bool) (*checkedBlob, error) {
+	if blob, ok := c.blobs[d]; ok && (!isManifest || !blob.intact || blob.data != nil || blob.tooLarge) {
+		return blob, nil
+	}
+	// If we get here with an existing entry, the blob is intact, and it is only being read again to obtain its contents.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	blob := &checkedBlob{reportedSizes: set.New[int64]()}
+	c.blobs[d] = blob
+	path, err := c.ref.blobPath(d, c.sharedBlobDir)
+	if err != nil { // Invalid digest
+		c.addDamage(DamageInvalidManifest, d, "", err.Error())
+		blob.invalid = true
+		return blob, nil
+	}
+	blob.path = path
+	if !d.Algorithm().Available() {
+		c.addDamage(DamageInvalidManifest, d, path, fmt.Sprintf("unsupported digest algorithm %q", d.Algorithm()))
+		blob.invalid = true
+		return blob, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return blob, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	blob.exists = true
+	digester := d.Algorithm().Digester()
+	var reader io.Reader = io.TeeReader(f, digester.Hash())
+	if isManifest {
+		blob.data, err = io.ReadAll(io.LimitReader(reader, iolimits.MaxManifestBodySize+1))
+		blob.size = int64(len(blob.data))
+		if err == nil && blob.size > iolimits.MaxManifestBodySize {
+			blob.data = nil
+			blob.tooLarge = true
+		}
+	}
+	if err == nil {
+		var n int64
+		n, err = io.Copy(io.Discard, reader)
+		blob.size += n
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", d, err)
+	}
+	blob.intact = digester.Digest() == d
+	if !blob.intact {
+		c.addDamage(DamageDigestMismatch, d, path, fmt.Sprintf("blob %s has contents with digest %s", d, digester.Digest()))
+	}
+	return blob, nil
+}
+
+// checkUnreferencedBlobs checks the blobs in the layout’s blobs directory which were not referenced by any image.
+func (c *layoutChecker) checkUnreferencedBlobs(ctx context.Context) error {
+	blobsDir := filepath.Join(c.ref.dir, imgspecv1.ImageBlobsDir)
+	algorithms, err := os.ReadDir(blobsDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, algorithm := range algorithms {
+		algorithmDir := filepath.Join(blobsDir, algorithm.Name())
+		if !algorithm.IsDir() {
+			c.addDamage(DamageInvalidBlobName, "", algorithmDir, fmt.Sprintf("unexpected file %q in blobs directory", algorithm.Name()))
+			continue
+		}
+		entries, err := os.ReadDir(algorithmDir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(algorithmDir, entry.Name())
+			d := digest.NewDigestFromEncoded(digest.Algorithm(algorithm.Name()), entry.Name())
+			if entry.IsDir() || d.Validate() != nil {
+				c.addDamage(DamageInvalidBlobName, "", path, fmt.Sprintf("%q is not a valid blob name", filepath.Join(algorithm.Name(), entry.Name())))
+				continue
+			}
+			if _, ok := c.blobs[d]; ok {
+				continue
+			}
+			if _, err := c.checkBlob(ctx, d, false); err != nil {
+				return err
+			}
+			c.report.UnreferencedBlobs = append(c.report.UnreferencedBlobs, d)
+		}
+	}
+	slices.Sort(c.report.UnreferencedBlobs)
+	return nil
+}
+
+// isManifestMediaType returns true if mediaType is a manifest or index type which CheckLayout parses.
+func isManifestMediaType(mediaType string) bool {
+	switch mediaType {
+	case imgspecv1.MediaTypeImageManifest, imgspecv1.MediaTypeImageIndex,
+		manifest.DockerV2Schema2MediaType, manifest.DockerV2ListMediaType:
+		return true
+	default:
+		return false
+	}
+}
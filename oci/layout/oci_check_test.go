@@ -0,0 +1,217 @@
+package layout
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkTestImage describes an image created by createCheckTestImage.
+type checkTestImage struct {
+	manifest, config, layer digest.Digest
+}
+
+// createCheckTestImage writes an image named name, with a layer with the specified contents, to the OCI layout in dir.
+func createCheckTestImage(t *testing.T, dir, name string, layer []byte) checkTestImage {
+	ref, err := NewReference(dir, name)
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	for _, blob := range [][]byte{config, layer} {
+		_, err := dest.PutBlob(context.Background(), bytes.NewReader(blob), types.BlobInfo{Size: -1}, memory.New(), false)
+		require.NoError(t, err)
+	}
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `",` +
+		`"config":{"mediaType":"` + imgspecv1.MediaTypeImageConfig + `","digest":"` + digest.FromBytes(config).String() + `","size":` + strconv.Itoa(len(config)) + `},` +
+		`"layers":[{"mediaType":"` + imgspecv1.MediaTypeImageLayer + `","digest":"` + digest.FromBytes(layer).String() + `","size":` + strconv.Itoa(len(layer)) + `}]}`)
+	err = dest.PutManifest(context.Background(), manifest, nil)
+	require.NoError(t, err)
+	err = dest.Commit(context.Background(), nil)
+	require.NoError(t, err)
+	return checkTestImage{manifest: digest.FromBytes(manifest), config: digest.FromBytes(config), layer: digest.FromBytes(layer)}
+}
+
+func checkTestBlobPath(dir string, d digest.Digest) string {
+	return filepath.Join(dir, "blobs", d.Algorithm().String(), d.Encoded())
+}
+
+func TestCheckLayoutIntact(t *testing.T) {
+	dir := t.TempDir()
+	createCheckTestImage(t, dir, "a", []byte("layer a"))
+	createCheckTestImage(t, dir, "b", []byte("layer b"))
+	unreferenced := []byte("unreferenced")
+	err := os.WriteFile(checkTestBlobPath(dir, digest.FromBytes(unreferenced)), unreferenced, 0o644)
+	require.NoError(t, err)
+
+	report, err := CheckLayout(context.Background(), nil, dir, nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.Empty(t, report.Damage)
+	assert.Empty(t, report.DamagedEntries)
+	assert.Equal(t, []digest.Digest{digest.FromBytes(unreferenced)}, report.UnreferencedBlobs)
+}
+
+func TestCheckLayoutDamage(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		damage   func(t *testing.T, dir string, img checkTestImage)
+		expected DamageKind
+	}{
+		{
+			name: "missing layer",
+			damage: func(t *testing.T, dir string, img checkTestImage) {
+				require.NoError(t, os.Remove(checkTestBlobPath(dir, img.layer)))
+			},
+			expected: DamageMissingBlob,
+		},
+		{
+			name: "corrupt layer, same size",
+			damage: func(t *testing.T, dir string, img checkTestImage) {
+				require.NoError(t, os.WriteFile(checkTestBlobPath(dir, img.layer), []byte("LAYER a"), 0o644))
+			},
+			expected: DamageDigestMismatch,
+		},
+		{
+			name: "truncated layer",
+			damage: func(t *testing.T, dir string, img checkTestImage) {
+				require.NoError(t, os.Truncate(checkTestBlobPath(dir, img.layer), 3))
+			},
+			expected: DamageDigestMismatch,
+		},
+		{
+			name: "size mismatch",
+			damage: func(t *testing.T, dir string, img checkTestImage) {
+				index, err := parseIndex(filepath.Join(dir, "index.json"))
+				require.NoError(t, err)
+				for i := range index.Manifests {
+					if index.Manifests[i].Digest == img.manifest {
+						index.Manifests[i].Size++
+					}
+				}
+				require.NoError(t, saveJSON(filepath.Join(dir, "index.json"), index))
+			},
+			expected: DamageSizeMismatch,
+		},
+		{
+			name: "invalid manifest",
+			damage: func(t *testing.T, dir string, img checkTestImage) {
+				// Rewrite the manifest with invalid contents, and update the index, so that only parsing fails.
+				invalid := []byte("this is not JSON")
+				require.NoError(t, os.WriteFile(checkTestBlobPath(dir, digest.FromBytes(invalid)), invalid, 0o644))
+				index, err := parseIndex(filepath.Join(dir, "index.json"))
+				require.NoError(t, err)
+				for i := range index.Manifests {
+					if index.Manifests[i].Digest == img.manifest {
+						index.Manifests[i].Digest = digest.FromBytes(invalid)
+						index.Manifests[i].Size = int64(len(invalid))
+					}
+				}
+				require.NoError(t, saveJSON(filepath.Join(dir, "index.json"), index))
+			},
+			expected: DamageInvalidManifest,
+		},
+		{
+			name: "invalid blob name",
+			damage: func(t *testing.T, dir string, img checkTestImage) {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "blobs", "sha256", "not-a-digest"), []byte{}, 0o644))
+			},
+			expected: DamageInvalidBlobName,
+		},
+	} {
+		dir := t.TempDir()
+		damaged := createCheckTestImage(t, dir, "damaged", []byte("layer a"))
+		createCheckTestImage(t, dir, "intact", []byte("layer b"))
+		c.damage(t, dir, damaged)
+
+		report, err := CheckLayout(context.Background(), nil, dir, nil)
+		require.NoError(t, err, c.name)
+		assert.False(t, report.OK(), c.name)
+		require.Len(t, report.Damage, 1, c.name)
+		assert.Equal(t, c.expected, report.Damage[0].Kind, c.name)
+		assert.NotEmpty(t, report.Damage[0].Detail, c.name)
+		if c.expected == DamageInvalidBlobName {
+			assert.Empty(t, report.DamagedEntries, c.name)
+		} else {
+			require.Len(t, report.DamagedEntries, 1, c.name)
+			assert.Equal(t, "damaged", report.DamagedEntries[0].Annotations[imgspecv1.AnnotationRefName], c.name)
+		}
+		assert.Empty(t, report.RemovedEntries, c.name)
+		assert.Empty(t, report.RemovedBlobs, c.name)
+	}
+}
+
+func TestCheckLayoutRemove(t *testing.T) {
+	dir := t.TempDir()
+	damaged := createCheckTestImage(t, dir, "damaged", []byte("layer a"))
+	intact := createCheckTestImage(t, dir, "intact", []byte("layer b"))
+	err := os.WriteFile(checkTestBlobPath(dir, damaged.layer), []byte("LAYER a"), 0o644)
+	require.NoError(t, err)
+
+	report, err := CheckLayout(context.Background(), nil, dir, &CheckOptions{RemoveDamagedEntries: true, RemoveCorruptBlobs: true})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, report.DamagedEntries, report.RemovedEntries)
+	assert.Equal(t, []digest.Digest{damaged.layer}, report.RemovedBlobs)
+	_, err = os.Stat(checkTestBlobPath(dir, damaged.layer))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	names, err := ListImageNames(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"intact"}, names)
+
+	// The remaining layout is intact; the blobs of the removed image are left for garbage collection.
+	report, err = CheckLayout(context.Background(), nil, dir, nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+	assert.ElementsMatch(t, []digest.Digest{damaged.manifest}, report.UnreferencedBlobs)
+	assert.NotContains(t, report.UnreferencedBlobs, intact.manifest)
+}
+
+func TestCheckLayoutNonDistributable(t *testing.T) {
+	dir := t.TempDir()
+	img := createCheckTestImage(t, dir, "a", []byte("layer a"))
+	require.NoError(t, os.Remove(checkTestBlobPath(dir, img.layer)))
+	// Rewrite the manifest so that the layer has URLs.
+	manifestPath := checkTestBlobPath(dir, img.manifest)
+	manifest, err := parseJSON[imgspecv1.Manifest](manifestPath)
+	require.NoError(t, err)
+	manifest.Layers[0].URLs = []string{"https://example.com/layer"}
+	require.NoError(t, saveJSON(manifestPath, manifest))
+	manifestBytes, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	newDigest := digest.FromBytes(manifestBytes)
+	require.NoError(t, os.Rename(manifestPath, checkTestBlobPath(dir, newDigest)))
+	index, err := parseIndex(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	index.Manifests[0].Digest = newDigest
+	index.Manifests[0].Size = int64(len(manifestBytes))
+	require.NoError(t, saveJSON(filepath.Join(dir, "index.json"), index))
+
+	report, err := CheckLayout(context.Background(), nil, dir, nil)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Damage)
+}
+
+func TestCheckLayoutInvalidLayout(t *testing.T) {
+	// Missing layout
+	_, err := CheckLayout(context.Background(), nil, t.TempDir(), nil)
+	assert.Error(t, err)
+
+	// Invalid index.json
+	dir := t.TempDir()
+	createCheckTestImage(t, dir, "a", []byte("layer a"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), []byte("invalid"), 0o644))
+	_, err = CheckLayout(context.Background(), nil, dir, nil)
+	assert.Error(t, err)
+}
@@ -8,11 +8,11 @@ import (
 	"os"
 	"slices"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 // DeleteImage deletes the named image from the directory, if supported.
@@ -181,7 +181,7 @@ func (ref ociReference) deleteBlobs(blobsToDelete *set.Set[digest.Digest]) error
 }
 
 func deleteBlob(blobPath string) error {
-	logrus.Debug(fmt.Sprintf("Deleting blob at %q", blobPath))
+	log.Debugf("Deleting blob at %q", blobPath)
 
 	err := os.Remove(blobPath)
 	if err != nil && !os.IsNotExist(err) {
@@ -12,6 +12,8 @@ import (
 	"runtime"
 	"slices"
 
+	"github.com/containers/image/v5/internal/blobfile"
+	"github.com/containers/image/v5/internal/blobinfocache"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
 	"github.com/containers/image/v5/internal/imagedestination/stubs"
 	"github.com/containers/image/v5/internal/manifest"
@@ -129,7 +131,7 @@ func (d *ociImageDestination) PutBlobWithOptions(ctx context.Context, stream io.
 
 	digester, stream := putblobdigest.DigestIfCanonicalUnknown(stream, inputInfo)
 	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
-	size, err := io.Copy(blobFile, stream)
+	size, err := blobfile.Write(blobFile, stream, inputInfo.Size)
 	if err != nil {
 		return private.UploadedBlob{}, err
 	}
@@ -196,6 +198,14 @@ func (d *ociImageDestination) TryReusingBlobWithOptions(ctx context.Context, inf
 	return true, private.ReusedBlob{Digest: info.Digest, Size: finfo.Size()}, nil
 }
 
+// HasBlob returns true if a blob with info.Digest would not need to be uploaded, because it already exists at the destination.
+// info.Digest must not be empty.
+func (d *ociImageDestination) HasBlob(ctx context.Context, info types.BlobInfo, cache blobinfocache.BlobInfoCache2) (bool, error) {
+	// TryReusingBlobWithOptions only checks whether the blob exists, it does not modify the destination.
+	reused, _, err := d.TryReusingBlobWithOptions(ctx, info, private.TryReusingBlobOptions{Cache: cache})
+	return reused, err
+}
+
 // PutManifest writes a manifest to the destination.  Per our list of supported manifest MIME types,
 // this should be either an OCI manifest (possibly converted to this format by the caller) or index,
 // neither of which we'll need to modify further.
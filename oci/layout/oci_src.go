@@ -10,6 +10,7 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/containers/image/v5/internal/blobfile"
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
 	"github.com/containers/image/v5/internal/manifest"
@@ -44,6 +45,7 @@ type ociImageSource struct {
 	descriptor    imgspecv1.Descriptor
 	client        *http.Client
 	sharedBlobDir string
+	sys           *types.SystemContext
 }
 
 // newImageSource returns an ImageSource for reading from an existing directory.
@@ -78,6 +80,7 @@ func newImageSource(sys *types.SystemContext, ref ociReference) (private.ImageSo
 		index:      index,
 		descriptor: descriptor,
 		client:     client,
+		sys:        sys,
 	}
 	if sys != nil {
 		// TODO(jonboulle): check dir existence?
@@ -154,15 +157,7 @@ func (s *ociImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache
 		return nil, 0, err
 	}
 
-	r, err := os.Open(path)
-	if err != nil {
-		return nil, 0, err
-	}
-	fi, err := r.Stat()
-	if err != nil {
-		return nil, 0, err
-	}
-	return r, fi.Size(), nil
+	return blobfile.Open(s.sys, path, info.Digest)
 }
 
 // getExternalBlob returns the reader of the first available blob URL from urls, which must not be empty.
@@ -194,22 +194,30 @@ func (ref ociReference) getManifestDescriptor() (imgspecv1.Descriptor, int, erro
 			return imgspecv1.Descriptor{}, -1, ErrMoreThanOneImage
 		}
 		return index.Manifests[0], 0, nil
-	} else {
-		// if image specified, look through all manifests for a match
-		var unsupportedMIMETypes []string
-		for i, md := range index.Manifests {
-			if refName, ok := md.Annotations[imgspecv1.AnnotationRefName]; ok && refName == ref.image {
-				if md.MediaType == imgspecv1.MediaTypeImageManifest || md.MediaType == imgspecv1.MediaTypeImageIndex {
-					return md, i, nil
-				}
-				unsupportedMIMETypes = append(unsupportedMIMETypes, md.MediaType)
-			}
-		}
-		if len(unsupportedMIMETypes) != 0 {
-			return imgspecv1.Descriptor{}, -1, fmt.Errorf("reference %q matches unsupported manifest MIME types %q", ref.image, unsupportedMIMETypes)
-		}
 	}
-	return imgspecv1.Descriptor{}, -1, ImageNotFoundError{ref}
+	md, i, err := internal.FindManifestDescriptorByName(index, ref.image)
+	if err != nil {
+		return imgspecv1.Descriptor{}, -1, err
+	}
+	if i == -1 {
+		return imgspecv1.Descriptor{}, -1, ImageNotFoundError{ref}
+	}
+	return md, i, nil
+}
+
+// ListImageNames returns the names of images in the OCI layout at dir, i.e. the values of
+// the org.opencontainers.image.ref.name annotation in its index, in the order they appear.
+// Note that not all values are necessarily usable in an oci: reference.
+func ListImageNames(dir string) ([]string, error) {
+	ref, err := NewReference(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	index, err := ref.(ociReference).getIndex()
+	if err != nil {
+		return nil, err
+	}
+	return internal.ImageNames(index), nil
 }
 
 // LoadManifestDescriptor loads the manifest descriptor to be used to retrieve the image name
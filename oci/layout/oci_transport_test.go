@@ -103,6 +103,19 @@ func TestGetManifestDescriptor(t *testing.T) {
 	}
 }
 
+func TestListImageNames(t *testing.T) {
+	names, err := ListImageNames("fixtures/name_lookups")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "invalid-mime"}, names)
+
+	names, err = ListImageNames("fixtures/manifest")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v0.1.1"}, names)
+
+	_, err = ListImageNames(t.TempDir())
+	assert.Error(t, err)
+}
+
 func TestTransportName(t *testing.T) {
 	assert.Equal(t, "oci", Transport.Name())
 }
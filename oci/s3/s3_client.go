@@ -0,0 +1,439 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+const (
+	defaultRegion = "us-east-1"
+	// emptyPayloadHash is the SHA-256 of an empty payload, used when signing bodyless requests.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// objectStorageError is an error returned by the object storage service.
+type objectStorageError struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e objectStorageError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("object storage request failed with status %d (%s)", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("object storage request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// isNotFound returns true if err indicates that an object does not exist.
+func isNotFound(err error) bool {
+	var e objectStorageError
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// client is a minimal client for the subset of the S3 REST API we need.
+type client struct {
+	httpClient  *http.Client
+	endpoint    *url.URL
+	virtualHost bool // Use virtual-hosted-style (bucket.endpoint) instead of path-style (endpoint/bucket) URLs.
+	region      string
+	credentials *types.ObjectStorageCredentials // nil for anonymous access
+	bucket      string
+	now         func() time.Time // Only to allow overriding in tests
+}
+
+// newClient returns a client for accessing bucket, configured using sys.
+func newClient(sys *types.SystemContext, bucket string) (*client, error) {
+	region := ""
+	endpoint := ""
+	var credentials *types.ObjectStorageCredentials
+	tr := tlsclientconfig.NewTransport()
+	tr.TLSClientConfig = tlsconfig.ClientDefault()
+	if sys != nil {
+		region = sys.ObjectStorageRegion
+		endpoint = sys.ObjectStorageEndpoint
+		credentials = sys.ObjectStorageCredentials
+		if sys.ObjectStorageCertPath != "" {
+			if err := tlsclientconfig.SetupCertificates(sys.ObjectStorageCertPath, tr.TLSClientConfig); err != nil {
+				return nil, err
+			}
+		}
+		tr.TLSClientConfig.InsecureSkipVerify = sys.ObjectStorageInsecureSkipTLSVerify
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = defaultRegion
+	}
+	if credentials == nil {
+		if keyID, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); keyID != "" && secret != "" {
+			credentials = &types.ObjectStorageCredentials{
+				AccessKeyID:     keyID,
+				SecretAccessKey: secret,
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			}
+		}
+	}
+	virtualHost := false
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		// Bucket names containing dots don’t match the wildcard TLS certificate of the virtual-hosted endpoint.
+		virtualHost = !strings.Contains(bucket, ".")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing object storage endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported object storage endpoint %q, expected a http:// or https:// URL", endpoint)
+	}
+	return &client{
+		httpClient:  &http.Client{Transport: tr},
+		endpoint:    u,
+		virtualHost: virtualHost,
+		region:      region,
+		credentials: credentials,
+		bucket:      bucket,
+		now:         time.Now,
+	}, nil
+}
+
+// close releases resources associated with c.
+func (c *client) close() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// objectURL returns an URL for accessing key in c.bucket.
+func (c *client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if c.virtualHost {
+		u.Host = c.bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+	} else {
+		u.Path = basePath + "/" + c.bucket + "/" + key
+	}
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do sends a request for key, with an optional in-memory body, and returns the response if it indicates success.
+func (c *client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query).String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = int64(len(body))
+	}
+	payloadHash := emptyPayloadHash
+	if len(body) != 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	c.signRequest(req, payloadHash)
+
+	log.DebugfContext(ctx, "%s %s", method, req.URL.Redacted())
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		return nil, responseToError(res)
+	}
+	return res, nil
+}
+
+// responseToError converts an unsuccessful response to an error.
+func responseToError(res *http.Response) error {
+	e := objectStorageError{StatusCode: res.StatusCode}
+	body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxErrorBodySize)
+	if err == nil && len(body) != 0 {
+		_ = xml.Unmarshal(body, &e) // If the body is not a valid error document, fall back to only reporting the status code.
+		e.StatusCode = res.StatusCode
+	}
+	return e
+}
+
+// getObject returns the contents of key, starting at offset and containing length bytes (or all remaining data if length is math.MaxUint64),
+// and the size of the returned data, or -1 if unknown.
+func (c *client) getObject(ctx context.Context, key string, offset, length uint64) (io.ReadCloser, int64, error) {
+	header := http.Header{}
+	switch {
+	case length == 0:
+		return io.NopCloser(bytes.NewReader(nil)), 0, nil
+	case offset == 0 && length == math.MaxUint64:
+		// Fetch the whole object
+	case length == math.MaxUint64:
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	default:
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
+	res, err := c.do(ctx, http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	if header.Get("Range") != "" && res.StatusCode != http.StatusPartialContent {
+		res.Body.Close()
+		return nil, -1, fmt.Errorf("object storage returned status %d to a range request for %q", res.StatusCode, key)
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+// getSmallObject returns the contents of key, which is expected to be small enough to fit into memory.
+func (c *client) getSmallObject(ctx context.Context, key string, maxSize int) ([]byte, error) {
+	body, _, err := c.getObject(ctx, key, 0, math.MaxUint64)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return iolimits.ReadAtMost(body, maxSize)
+}
+
+// headObject returns the size of key, or (false, -1, nil) if it does not exist.
+func (c *client) headObject(ctx context.Context, key string) (bool, int64, error) {
+	res, err := c.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return false, -1, nil
+		}
+		return false, -1, err
+	}
+	res.Body.Close()
+	return true, res.ContentLength, nil
+}
+
+// putObject stores data as key.
+func (c *client) putObject(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	res, err := c.do(ctx, http.MethodPut, key, nil, header, data)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// createMultipartUpload starts a multipart upload of key, and returns its upload ID.
+func (c *client) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	res, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := iolimits.ReadAtMost(res.Body, iolimits.MaxErrorBodySize)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("parsing response to a multipart upload creation: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("object storage did not return an upload ID")
+	}
+	return result.UploadID, nil
+}
+
+// uploadPart uploads data as part partNumber (1-based) of a multipart upload, and returns its ETag.
+func (c *client) uploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (string, error) {
+	res, err := c.do(ctx, http.MethodPut, key, url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}, nil, data)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		return "", fmt.Errorf("object storage did not return an ETag for part %d", partNumber)
+	}
+	return etag, nil
+}
+
+// completedPart is an element of a CompleteMultipartUpload request.
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// completeMultipartUpload finishes a multipart upload consisting of parts with etags.
+func (c *client) completeMultipartUpload(ctx context.Context, key, uploadID string, etags []string) error {
+	request := struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{}
+	for i, etag := range etags {
+		request.Parts = append(request.Parts, completedPart{PartNumber: i + 1, ETag: etag})
+	}
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return err
+	}
+	res, err := c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// The service can report a failure inside a 200 response body.
+	resBody, err := iolimits.ReadAtMost(res.Body, iolimits.MaxErrorBodySize)
+	if err != nil {
+		return err
+	}
+	var e objectStorageError
+	if xml.Unmarshal(resBody, &e) == nil && e.Code != "" {
+		e.StatusCode = res.StatusCode
+		return e
+	}
+	return nil
+}
+
+// abortMultipartUpload cancels a multipart upload, discarding the uploaded parts.
+func (c *client) abortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	res, err := c.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// signRequest adds an AWS Signature Version 4 authorization to req, if c has credentials.
+func (c *client) signRequest(req *http.Request, payloadHash string) {
+	if c.credentials == nil {
+		return
+	}
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURIPath(req.URL.Path),
+		canonicalQueryString(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		c.credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode encodes s per the SigV4 rules (RFC 3986 unreserved characters are kept, everything else is percent-encoded).
+func uriEncode(s string, encodeSlash bool) string {
+	res := strings.Builder{}
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '.' || b == '_' || b == '~' || (b == '/' && !encodeSlash) {
+			res.WriteByte(b)
+		} else {
+			fmt.Fprintf(&res, "%%%02X", b)
+		}
+	}
+	return res.String()
+}
+
+// canonicalURIPath returns the SigV4 canonical form of an URL path.
+func canonicalURIPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+// canonicalQueryString returns the SigV4 canonical form of query.
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,336 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/containers/image/v5/internal/imagedestination/impl"
+	"github.com/containers/image/v5/internal/imagedestination/stubs"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/putblobdigest"
+	"github.com/containers/image/v5/internal/streamdigest"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// multipartPartSize is the size of parts of multipart uploads; blobs smaller than this are uploaded using a single request.
+// Parts must be at least 5 MiB, and there can be at most 10000 parts, so this allows blobs up to ~156 GiB.
+// This is a variable only to allow tests to use smaller parts.
+var multipartPartSize = 16 * 1024 * 1024
+
+type s3ImageDestination struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	stubs.NoPutBlobPartialInitialize
+	stubs.NoSignaturesInitialize
+
+	ref   s3Reference
+	sys   *types.SystemContext
+	c     *client
+	index imgspecv1.Index
+}
+
+// newImageDestination returns an ImageDestination for writing to an OCI layout in a bucket.
+func newImageDestination(ctx context.Context, sys *types.SystemContext, ref s3Reference) (private.ImageDestination, error) {
+	c, err := newClient(sys, ref.bucket)
+	if err != nil {
+		return nil, err
+	}
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			c.close()
+		}
+	}()
+
+	var index *imgspecv1.Index
+	exists, _, err := c.headObject(ctx, ref.indexKey())
+	if err != nil {
+		return nil, fmt.Errorf("checking for %s in bucket %q: %w", imgspecv1.ImageIndexFile, ref.bucket, err)
+	}
+	if exists {
+		index, err = getIndex(ctx, c, ref)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		index = &imgspecv1.Index{
+			Versioned: imgspec.Versioned{
+				SchemaVersion: 2,
+			},
+			Annotations: make(map[string]string),
+		}
+	}
+
+	desiredLayerCompression := types.Compress
+	if sys != nil && sys.OCIAcceptUncompressedLayers {
+		desiredLayerCompression = types.PreserveOriginal
+	}
+
+	d := &s3ImageDestination{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			SupportedManifestMIMETypes: []string{
+				imgspecv1.MediaTypeImageManifest,
+				imgspecv1.MediaTypeImageIndex,
+			},
+			DesiredLayerCompression:        desiredLayerCompression,
+			AcceptsForeignLayerURLs:        true,
+			MustMatchRuntimeOS:             false,
+			IgnoresEmbeddedDockerReference: false, // N/A, DockerReference() returns nil.
+			HasThreadSafePutBlob:           true,
+		}),
+		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(ref),
+		NoSignaturesInitialize:     stubs.NoSignatures("Pushing signatures to oci-s3: images is not supported"),
+
+		ref:   ref,
+		sys:   sys,
+		c:     c,
+		index: *index,
+	}
+	d.Compat = impl.AddCompat(d)
+	succeeded = true
+	return d, nil
+}
+
+// Reference returns the reference used to set up this destination.  Note that this should directly correspond to user's intent,
+// e.g. it should use the public hostname instead of the result of resolving CNAMEs or following redirects.
+func (d *s3ImageDestination) Reference() types.ImageReference {
+	return d.ref
+}
+
+// Close removes resources associated with an initialized ImageDestination, if any.
+func (d *s3ImageDestination) Close() error {
+	d.c.close()
+	return nil
+}
+
+// PutBlobWithOptions writes contents of stream and returns data representing the result.
+// inputInfo.Digest can be optionally provided if known; if provided, and stream is read to the end without error, the digest MUST match the stream contents.
+// inputInfo.Size is the expected length of stream, if known.
+// inputInfo.MediaType describes the blob format, if known.
+// WARNING: The contents of stream are being verified on the fly.  Until stream.Read() returns io.EOF, the contents of the data SHOULD NOT be available
+// to any other readers for download using the supplied digest.
+// If stream.Read() at any time, ESPECIALLY at end of input, returns an error, PutBlobWithOptions MUST 1) fail, and 2) delete any data stored so far.
+func (d *s3ImageDestination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	// The object key depends on the digest, so if we don’t know it, we must compute it before starting the upload.
+	if inputInfo.Digest == "" || inputInfo.Digest.Algorithm() != digest.Canonical {
+		log.DebugfContext(ctx, "Computing digest of blob before uploading to oci-s3:%s", d.ref.StringWithinTransport())
+		streamCopy, cleanup, err := streamdigest.ComputeBlobInfo(d.sys, stream, &inputInfo)
+		if err != nil {
+			return private.UploadedBlob{}, err
+		}
+		defer cleanup()
+		stream = streamCopy
+	}
+	digester, stream := putblobdigest.DigestIfCanonicalUnknown(stream, inputInfo) // Never computes a digest now, but it is cheap to be safe.
+	blobDigest := digester.Digest()
+	key, err := d.ref.blobKey(blobDigest)
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+
+	// Don’t rely on the caller to verify the digest: the object must not be created at key with any other contents.
+	verifier := blobDigest.Verifier()
+	stream = io.TeeReader(stream, verifier)
+	verify := func(size int64) error {
+		if inputInfo.Size != -1 && size != inputInfo.Size {
+			return fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", blobDigest, inputInfo.Size, size)
+		}
+		if !verifier.Verified() {
+			return fmt.Errorf("Digest mismatch when copying %s", blobDigest)
+		}
+		return nil
+	}
+	size, err := d.uploadObject(ctx, key, stream, verify)
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	return private.UploadedBlob{Digest: blobDigest, Size: size}, nil
+}
+
+// uploadObject reads all of stream and uploads it as key, using a multipart upload if the data is large.
+// The object is only created if stream is successfully read to the end, and verify, called with the size of the data
+// after reading all of it, succeeds.
+func (d *s3ImageDestination) uploadObject(ctx context.Context, key string, stream io.Reader, verify func(size int64) error) (int64, error) {
+	buf := make([]byte, multipartPartSize)
+	n, err := io.ReadFull(stream, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return -1, err
+	}
+	if err != nil { // The whole blob fits into a single part
+		if err := verify(int64(n)); err != nil {
+			return -1, err
+		}
+		if err := d.c.putObject(ctx, key, buf[:n], ""); err != nil {
+			return -1, fmt.Errorf("uploading %q: %w", key, err)
+		}
+		return int64(n), nil
+	}
+
+	uploadID, err := d.c.createMultipartUpload(ctx, key)
+	if err != nil {
+		return -1, fmt.Errorf("starting upload of %q: %w", key, err)
+	}
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			// Use a separate context, ctx may have been canceled.
+			if err := d.c.abortMultipartUpload(context.Background(), key, uploadID); err != nil {
+				log.DebugfContext(ctx, "Error aborting upload of %q: %v", key, err)
+			}
+		}
+	}()
+	etags := []string{}
+	size := int64(0)
+	for n > 0 {
+		etag, err := d.c.uploadPart(ctx, key, uploadID, len(etags)+1, buf[:n])
+		if err != nil {
+			return -1, fmt.Errorf("uploading part %d of %q: %w", len(etags)+1, key, err)
+		}
+		etags = append(etags, etag)
+		size += int64(n)
+
+		n, err = io.ReadFull(stream, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return -1, err
+		}
+	}
+	if err := verify(size); err != nil {
+		return -1, err
+	}
+	if err := d.c.completeMultipartUpload(ctx, key, uploadID, etags); err != nil {
+		return -1, fmt.Errorf("completing upload of %q: %w", key, err)
+	}
+	succeeded = true
+	return size, nil
+}
+
+// TryReusingBlobWithOptions checks whether the transport already contains, or can efficiently reuse, a blob, and if so, applies it to the current destination
+// (e.g. if the blob is a filesystem layer, this signifies that the changes it describes need to be applied again when composing a filesystem tree).
+// info.Digest must not be empty.
+// If the blob has been successfully reused, returns (true, info, nil).
+// If the transport can not reuse the requested blob, TryReusingBlob returns (false, {}, nil); it returns a non-nil error only on an unexpected failure.
+func (d *s3ImageDestination) TryReusingBlobWithOptions(ctx context.Context, info types.BlobInfo, options private.TryReusingBlobOptions) (bool, private.ReusedBlob, error) {
+	if !impl.OriginalCandidateMatchesTryReusingBlobOptions(options) {
+		return false, private.ReusedBlob{}, nil
+	}
+	if info.Digest == "" {
+		return false, private.ReusedBlob{}, errors.New("Can not check for a blob with unknown digest")
+	}
+	key, err := d.ref.blobKey(info.Digest)
+	if err != nil {
+		return false, private.ReusedBlob{}, err
+	}
+	exists, size, err := d.c.headObject(ctx, key)
+	if err != nil {
+		return false, private.ReusedBlob{}, err
+	}
+	if !exists {
+		return false, private.ReusedBlob{}, nil
+	}
+	return true, private.ReusedBlob{Digest: info.Digest, Size: size}, nil
+}
+
+// PutManifest writes a manifest to the destination.  Per our list of supported manifest MIME types,
+// this should be either an OCI manifest (possibly converted to this format by the caller) or index,
+// neither of which we'll need to modify further.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to overwrite the manifest for (when
+// the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
+// It is expected but not enforced that the instanceDigest, when specified, matches the digest of `manifest` as generated
+// by `manifest.Digest()`.
+// FIXME? This should also receive a MIME type if known, to differentiate between schema versions.
+// If the destination is in principle available, refuses this manifest type (e.g. it does not recognize the schema),
+// but may accept a different manifest type, the returned error must be an ManifestTypeRejectedError.
+func (d *s3ImageDestination) PutManifest(ctx context.Context, m []byte, instanceDigest *digest.Digest) error {
+	var digest digest.Digest
+	var err error
+	if instanceDigest != nil {
+		digest = *instanceDigest
+	} else {
+		digest, err = manifest.Digest(m)
+		if err != nil {
+			return err
+		}
+	}
+
+	mimeType := manifest.GuessMIMEType(m)
+	key, err := d.ref.blobKey(digest)
+	if err != nil {
+		return err
+	}
+	if err := d.c.putObject(ctx, key, m, mimeType); err != nil {
+		return fmt.Errorf("uploading manifest %s: %w", digest, err)
+	}
+
+	if instanceDigest != nil {
+		return nil
+	}
+
+	desc := imgspecv1.Descriptor{
+		MediaType: mimeType,
+		Digest:    digest,
+		Size:      int64(len(m)),
+	}
+	if d.ref.image != "" {
+		desc.Annotations = map[string]string{imgspecv1.AnnotationRefName: d.ref.image}
+	}
+	d.addManifest(&desc)
+	return nil
+}
+
+// addManifest adds desc to d.index, replacing an existing entry with the same name or digest, if any.
+func (d *s3ImageDestination) addManifest(desc *imgspecv1.Descriptor) {
+	// If the new entry has a name, remove any conflicting names which we already have.
+	if desc.Annotations != nil && desc.Annotations[imgspecv1.AnnotationRefName] != "" {
+		for i, manifest := range d.index.Manifests {
+			if manifest.Annotations[imgspecv1.AnnotationRefName] == desc.Annotations[imgspecv1.AnnotationRefName] {
+				delete(d.index.Manifests[i].Annotations, imgspecv1.AnnotationRefName)
+				break
+			}
+		}
+	}
+	// If it has the same digest as another unnamed entry in the index, just pick up the other information.
+	for i, manifest := range d.index.Manifests {
+		if manifest.Digest == desc.Digest && manifest.Annotations[imgspecv1.AnnotationRefName] == "" {
+			d.index.Manifests[i] = *desc
+			return
+		}
+	}
+	d.index.Manifests = append(slices.Clone(d.index.Manifests), *desc)
+}
+
+// Commit marks the process of storing the image as successful and asks for the image to be persisted.
+// unparsedToplevel contains data about the top-level manifest of the source (which may be a single-arch image or a manifest list
+// if PutManifest was only called for the single-arch image with instanceDigest == nil), primarily to allow lookups by the
+// original manifest list digest, if desired.
+// WARNING: This does not have any transactional semantics:
+// - Uploaded data MAY be visible to others before Commit() is called
+// - Uploaded data MAY be removed or MAY remain around if Close() is called without Commit() (i.e. rollback is allowed but not guaranteed)
+func (d *s3ImageDestination) Commit(ctx context.Context, unparsedToplevel types.UnparsedImage) error {
+	// NOTE: Object storage has no locking, so concurrent writers to the same layout can lose index entries.
+	layoutBytes, err := json.Marshal(imgspecv1.ImageLayout{
+		Version: imgspecv1.ImageLayoutVersion,
+	})
+	if err != nil {
+		return err
+	}
+	if err := d.c.putObject(ctx, d.ref.ociLayoutKey(), layoutBytes, "application/json"); err != nil {
+		return fmt.Errorf("writing %s: %w", imgspecv1.ImageLayoutFile, err)
+	}
+	indexJSON, err := json.Marshal(d.index)
+	if err != nil {
+		return err
+	}
+	if err := d.c.putObject(ctx, d.ref.indexKey(), indexJSON, imgspecv1.MediaTypeImageIndex); err != nil {
+		return fmt.Errorf("writing %s: %w", imgspecv1.ImageIndexFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ private.ImageDestination = (*s3ImageDestination)(nil)
+var _ private.ImageSource = (*s3ImageSource)(nil)
+
+// fakeObjectStorage is a minimal in-memory implementation of the S3 API, as used by this package.
+type fakeObjectStorage struct {
+	mutex   sync.Mutex
+	objects map[string][]byte         // Keyed by "bucket/key"
+	uploads map[string]map[int][]byte // Upload ID → part number → data
+}
+
+func newFakeObjectStorage(t *testing.T) (*fakeObjectStorage, *httptest.Server) {
+	f := &fakeObjectStorage{
+		objects: map[string][]byte{},
+		uploads: map[string]map[int][]byte{},
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeObjectStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		partNumber, err := strconv.Atoi(query.Get("partNumber"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts[partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, partNumber))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchUpload</Code></Error>", http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data := []byte{}
+		for i, p := range req.Parts {
+			if p.PartNumber != i+1 || p.ETag != fmt.Sprintf(`"%d"`, i+1) {
+				http.Error(w, "<Error><Code>InvalidPart</Code></Error>", http.StatusBadRequest)
+				return
+			}
+			data = append(data, parts[p.PartNumber]...)
+		}
+		f.objects[path] = data
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[path] = body
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestPutBlobAndGetBlobRoundTrip(t *testing.T) {
+	origPartSize := multipartPartSize
+	multipartPartSize = 10
+	t.Cleanup(func() { multipartPartSize = origPartSize })
+
+	f, server := newFakeObjectStorage(t)
+	sys := &types.SystemContext{
+		ObjectStorageEndpoint:    server.URL,
+		ObjectStorageCredentials: &types.ObjectStorageCredentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+	}
+	ref, err := NewReference("bucket", "layout", "tag")
+	require.NoError(t, err)
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+
+	dest, err := ref.NewImageDestination(ctx, sys)
+	require.NoError(t, err)
+	defer dest.Close()
+	privateDest, ok := dest.(private.ImageDestination)
+	require.True(t, ok)
+
+	blobs := [][]byte{
+		[]byte("small"), // A single PUT
+		[]byte("this blob uses a multipart upload"), // Multiple parts
+		[]byte("0123456789"),                        // Exactly one part
+	}
+	for _, blob := range blobs {
+		res, err := privateDest.PutBlobWithOptions(ctx, bytes.NewReader(blob), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache})
+		require.NoError(t, err)
+		assert.Equal(t, digest.FromBytes(blob), res.Digest)
+		assert.Equal(t, int64(len(blob)), res.Size)
+	}
+	assert.Empty(t, f.uploads)
+
+	// Reuse of existing blobs
+	reused, info, err := privateDest.TryReusingBlobWithOptions(ctx, types.BlobInfo{Digest: digest.FromBytes(blobs[1]), Size: -1},
+		private.TryReusingBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, int64(len(blobs[1])), info.Size)
+	reused, _, err = privateDest.TryReusingBlobWithOptions(ctx, types.BlobInfo{Digest: digest.FromBytes([]byte("missing")), Size: -1},
+		private.TryReusingBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.False(t, reused)
+
+	// A size or digest mismatch is rejected, and the object is not created
+	for _, blob := range [][]byte{[]byte("mismatch"), []byte("a mismatched blob using a multipart upload")} {
+		for _, inputInfo := range []types.BlobInfo{
+			{Digest: digest.FromBytes(blob), Size: 1},
+			{Digest: digest.FromString("other data"), Size: -1},
+		} {
+			_, err = privateDest.PutBlobWithOptions(ctx, bytes.NewReader(blob), inputInfo, private.PutBlobOptions{Cache: cache})
+			assert.Error(t, err)
+			key, err := ref.(s3Reference).blobKey(inputInfo.Digest)
+			require.NoError(t, err)
+			assert.NotContains(t, f.objects, "bucket/"+key)
+			assert.Empty(t, f.uploads)
+		}
+	}
+
+	man := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"` +
+		digest.FromBytes(blobs[0]).String() + `","size":5},"layers":[]}`)
+	err = dest.PutManifest(ctx, man, nil)
+	require.NoError(t, err)
+	err = dest.Commit(ctx, nil) // nil unparsedToplevel is invalid, we don’t currently use the value
+	require.NoError(t, err)
+	assert.Contains(t, f.objects, "bucket/layout/oci-layout")
+	assert.Contains(t, f.objects, "bucket/layout/index.json")
+
+	src, err := ref.NewImageSource(ctx, sys)
+	require.NoError(t, err)
+	defer src.Close()
+
+	m, mimeType, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, man, m)
+	assert.Equal(t, imgspecv1.MediaTypeImageManifest, mimeType)
+
+	for _, blob := range blobs {
+		rc, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, cache)
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		assert.Equal(t, blob, data)
+		assert.Equal(t, int64(len(blob)), size)
+	}
+
+	privateSrc, ok := src.(private.ImageSource)
+	require.True(t, ok)
+	streams, errs, err := privateSrc.GetBlobAt(ctx, types.BlobInfo{Digest: digest.FromBytes(blobs[1]), Size: -1}, []private.ImageSourceChunk{
+		{Offset: 0, Length: 4},
+		{Offset: 5, Length: 4},
+		{Offset: 27, Length: math.MaxUint64},
+	})
+	require.NoError(t, err)
+	chunks := []string{}
+	for s := range streams {
+		data, err := io.ReadAll(s)
+		s.Close()
+		require.NoError(t, err)
+		chunks = append(chunks, string(data))
+	}
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"this", "blob", "upload"}, chunks)
+
+	// A missing image name
+	ref2, err := NewReference("bucket", "layout", "other")
+	require.NoError(t, err)
+	_, err = ref2.NewImageSource(ctx, sys)
+	assert.ErrorAs(t, err, &ImageNotFoundError{})
+	// A missing layout
+	ref3, err := NewReference("bucket", "missing", "")
+	require.NoError(t, err)
+	_, err = ref3.NewImageSource(ctx, sys)
+	assert.Error(t, err)
+}
+
+func TestPutManifestUpdatesIndex(t *testing.T) {
+	_, server := newFakeObjectStorage(t)
+	sys := &types.SystemContext{ObjectStorageEndpoint: server.URL}
+	ctx := context.Background()
+
+	manifests := map[string][]byte{}
+	for _, name := range []string{"a", "b", "a"} {
+		ref, err := NewReference("bucket", "", name)
+		require.NoError(t, err)
+		dest, err := ref.NewImageDestination(ctx, sys)
+		require.NoError(t, err)
+		m := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"n":"%s%d"}}`, name, len(manifests)))
+		manifests[name] = m
+		err = dest.PutManifest(ctx, m, nil)
+		require.NoError(t, err)
+		err = dest.Commit(ctx, nil)
+		require.NoError(t, err)
+		dest.Close()
+	}
+
+	for name, expected := range manifests {
+		ref, err := NewReference("bucket", "", name)
+		require.NoError(t, err)
+		src, err := ref.NewImageSource(ctx, sys)
+		require.NoError(t, err)
+		m, _, err := src.GetManifest(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, m, name)
+		src.Close()
+	}
+
+	// There are 3 entries in the index, so an unnamed reference is ambiguous.
+	ref, err := NewReference("bucket", "", "")
+	require.NoError(t, err)
+	_, err = ref.NewImageSource(ctx, sys)
+	assert.ErrorIs(t, err, ErrMoreThanOneImage)
+}
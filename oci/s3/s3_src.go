@@ -0,0 +1,189 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/manifest"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type s3ImageSource struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	impl.NoSignatures
+	impl.DoesNotAffectLayerInfosForCopy
+	stubs.ImplementsGetBlobAt
+
+	ref        s3Reference
+	c          *client
+	index      *imgspecv1.Index
+	descriptor imgspecv1.Descriptor
+}
+
+// newImageSource returns an ImageSource for reading from an existing OCI layout in a bucket.
+func newImageSource(ctx context.Context, sys *types.SystemContext, ref s3Reference) (private.ImageSource, error) {
+	c, err := newClient(sys, ref.bucket)
+	if err != nil {
+		return nil, err
+	}
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			c.close()
+		}
+	}()
+
+	index, err := getIndex(ctx, c, ref)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, _, err := ref.getManifestDescriptor(index)
+	if err != nil {
+		return nil, err
+	}
+	s := &s3ImageSource{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			HasThreadSafeGetBlob: true,
+		}),
+
+		ref:        ref,
+		c:          c,
+		index:      index,
+		descriptor: descriptor,
+	}
+	s.Compat = impl.AddCompat(s)
+	succeeded = true
+	return s, nil
+}
+
+// getIndex reads the index.json of the layout referenced by ref, using c.
+func getIndex(ctx context.Context, c *client, ref s3Reference) (*imgspecv1.Index, error) {
+	data, err := c.getSmallObject(ctx, ref.indexKey(), iolimits.MaxManifestBodySize)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from bucket %q: %w", imgspecv1.ImageIndexFile, ref.bucket, err)
+	}
+	index := imgspecv1.Index{}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing %s from bucket %q: %w", imgspecv1.ImageIndexFile, ref.bucket, err)
+	}
+	return &index, nil
+}
+
+// Reference returns the reference used to set up this source.
+func (s *s3ImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+// Close removes resources associated with an initialized ImageSource, if any.
+func (s *s3ImageSource) Close() error {
+	s.c.close()
+	return nil
+}
+
+// GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
+// It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
+// this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
+func (s *s3ImageSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	var dig digest.Digest
+	var mimeType string
+	if instanceDigest == nil {
+		dig = s.descriptor.Digest
+		mimeType = s.descriptor.MediaType
+	} else {
+		dig = *instanceDigest
+		for _, md := range s.index.Manifests {
+			if md.Digest == dig {
+				mimeType = md.MediaType
+				break
+			}
+		}
+	}
+
+	key, err := s.ref.blobKey(dig)
+	if err != nil {
+		return nil, "", err
+	}
+	m, err := s.c.getSmallObject(ctx, key, iolimits.MaxManifestBodySize)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading manifest %s: %w", dig, err)
+	}
+	if mimeType == "" {
+		mimeType = manifest.GuessMIMEType(m)
+	}
+	return m, mimeType, nil
+}
+
+// GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
+// The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
+// May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
+func (s *s3ImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	key, err := s.ref.blobKey(info.Digest)
+	if err != nil {
+		return nil, -1, err
+	}
+	return s.c.getObject(ctx, key, 0, math.MaxUint64)
+}
+
+// GetBlobAt returns a sequential channel of readers that contain data for the requested
+// blob chunks, and a channel that might get a single error value.
+// The specified chunks must be not overlapping and sorted by their offset.
+// The readers must be fully consumed, in the order they are returned, before blocking
+// to read the next chunk.
+// If the Length for the last chunk is set to math.MaxUint64, then it
+// fully fetches the remaining data from the offset to the end of the blob.
+func (s *s3ImageSource) GetBlobAt(ctx context.Context, info types.BlobInfo, chunks []private.ImageSourceChunk) (chan io.ReadCloser, chan error, error) {
+	key, err := s.ref.blobKey(info.Digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, c := range chunks {
+		if c.Length == math.MaxUint64 && i != len(chunks)-1 {
+			return nil, nil, fmt.Errorf("internal error: another chunk requested after an util-EOF chunk")
+		}
+	}
+	// S3 does not support multiple ranges in a single request, so read the chunks one by one.
+	streams := make(chan io.ReadCloser)
+	errs := make(chan error)
+	go func() {
+		defer close(streams)
+		defer close(errs)
+		for _, c := range chunks {
+			body, _, err := s.c.getObject(ctx, key, c.Offset, c.Length)
+			if err != nil {
+				errs <- err
+				return
+			}
+			stream := &signalCloseReader{closed: make(chan struct{}), stream: body}
+			streams <- stream
+			// Wait until the stream is closed before fetching the next chunk.
+			<-stream.closed
+		}
+	}()
+	return streams, errs, nil
+}
+
+// signalCloseReader is a ReadCloser that signals, by closing a channel, when it is closed.
+type signalCloseReader struct {
+	closed chan struct{}
+	stream io.ReadCloser
+}
+
+func (s *signalCloseReader) Read(p []byte) (int, error) {
+	return s.stream.Read(p)
+}
+
+func (s *signalCloseReader) Close() error {
+	defer close(s.closed)
+	return s.stream.Close()
+}
@@ -0,0 +1,253 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/oci/internal"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func init() {
+	transports.Register(Transport)
+}
+
+var (
+	// Transport is an ImageTransport for OCI image layouts stored in S3-compatible object storage buckets.
+	Transport = s3Transport{}
+
+	// ErrMoreThanOneImage is an error returned when the index includes
+	// more than one image and the user should choose which one to use.
+	ErrMoreThanOneImage = errors.New("more than one image in the OCI layout, choose an image")
+)
+
+// bucketNameRegexp matches valid S3 bucket names.
+// This is a bit more permissive than the AWS rules (which e.g. forbid names formatted like IP addresses),
+// but those are rejected by the service anyway, and other S3-compatible services have laxer rules.
+var bucketNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+type s3Transport struct{}
+
+func (t s3Transport) Name() string {
+	return "oci-s3"
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an ImageReference.
+func (t s3Transport) ParseReference(reference string) (types.ImageReference, error) {
+	return ParseReference(reference)
+}
+
+// ValidatePolicyConfigurationScope checks that scope is a valid name for a signature.PolicyTransportScopes keys
+// (i.e. a valid PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() return value).
+// It is acceptable to allow an invalid value which will never be matched, it can "only" cause user confusion.
+// scope passed to this function will not be "", that value is always allowed.
+func (t s3Transport) ValidatePolicyConfigurationScope(scope string) error {
+	bucket, prefix, hasPrefix := strings.Cut(scope, "/")
+	if hasPrefix && prefix == "" {
+		return fmt.Errorf("Invalid scope %s: empty key prefix", scope)
+	}
+	if err := validateBucketAndPrefix(bucket, prefix); err != nil {
+		return fmt.Errorf("Invalid scope %s: %w", scope, err)
+	}
+	return nil
+}
+
+// s3Reference is an ImageReference for OCI image layouts in object storage buckets.
+type s3Reference struct {
+	bucket string
+	prefix string // A key prefix within bucket, without a leading or a trailing slash; "" for the root of the bucket.
+	// If image=="", it means the "only image" in the index.json is used in the case it is a source;
+	// for destinations, the image name annotation "image.ref.name" is not added to the index.json
+	image string
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an oci-s3 ImageReference.
+// The expected format is bucket[/prefix][:image].
+func ParseReference(reference string) (types.ImageReference, error) {
+	location, image, _ := strings.Cut(reference, ":") // image is set to "" if there is no ":"
+	bucket, prefix, hasPrefix := strings.Cut(location, "/")
+	if hasPrefix && prefix == "" {
+		return nil, fmt.Errorf("invalid reference %q: empty key prefix", reference)
+	}
+	return NewReference(bucket, prefix, image)
+}
+
+// NewReference returns an oci-s3 reference for an OCI layout stored at prefix in bucket, and an image.
+func NewReference(bucket, prefix, image string) (types.ImageReference, error) {
+	if err := validateBucketAndPrefix(bucket, prefix); err != nil {
+		return nil, err
+	}
+	if err := internal.ValidateImageName(image); err != nil {
+		return nil, err
+	}
+	return s3Reference{bucket: bucket, prefix: prefix, image: image}, nil
+}
+
+// validateBucketAndPrefix returns an error if bucket or prefix are not valid, or prefix is not in a canonical form.
+func validateBucketAndPrefix(bucket, prefix string) error {
+	if !bucketNameRegexp.MatchString(bucket) {
+		return fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	if prefix == "" {
+		return nil
+	}
+	if strings.Contains(prefix, ":") {
+		return fmt.Errorf("invalid key prefix %q: contains a colon", prefix)
+	}
+	if cleaned := strings.TrimPrefix(path.Clean("/"+prefix), "/"); cleaned != prefix {
+		return fmt.Errorf("key prefix %q uses a non-canonical format, perhaps try %q", prefix, cleaned)
+	}
+	return nil
+}
+
+func (ref s3Reference) Transport() types.ImageTransport {
+	return Transport
+}
+
+// location returns the bucket and prefix of ref, in the bucket[/prefix] format.
+func (ref s3Reference) location() string {
+	if ref.prefix == "" {
+		return ref.bucket
+	}
+	return ref.bucket + "/" + ref.prefix
+}
+
+// StringWithinTransport returns a string representation of the reference, which MUST be such that
+// reference.Transport().ParseReference(reference.StringWithinTransport()) returns an equivalent reference.
+// NOTE: The returned string is not promised to be equal to the original input to ParseReference;
+// e.g. default attribute values omitted by the user may be filled in the return value, or vice versa.
+// WARNING: Do not use the return value in the UI to describe an image, it does not contain the Transport().Name() prefix.
+func (ref s3Reference) StringWithinTransport() string {
+	return fmt.Sprintf("%s:%s", ref.location(), ref.image)
+}
+
+// DockerReference returns a Docker reference associated with this reference
+// (fully explicit, i.e. !reference.IsNameOnly, but reflecting user intent,
+// not e.g. after redirect or alias processing), or nil if unknown/not applicable.
+func (ref s3Reference) DockerReference() reference.Named {
+	return nil
+}
+
+// PolicyConfigurationIdentity returns a string representation of the reference, suitable for policy lookup.
+// This MUST reflect user intent, not e.g. after processing of third-party redirects or aliases;
+// The value SHOULD be fully explicit about its semantics, with no hidden defaults, AND canonical
+// (i.e. various references with exactly the same semantics should return the same configuration identity)
+// It is fine for the return value to be equal to StringWithinTransport(), and it is desirable but
+// not required/guaranteed that it will be a valid input to Transport().ParseReference().
+// Returns "" if configuration identities for these references are not supported.
+func (ref s3Reference) PolicyConfigurationIdentity() string {
+	// NOTE: ref.image is not a part of the image identity, for the same reasons as in the oci: transport.
+	// The object storage endpoint is not a part of the identity either, it is not known to the reference.
+	return ref.location()
+}
+
+// PolicyConfigurationNamespaces returns a list of other policy configuration namespaces to search
+// for if explicit configuration for PolicyConfigurationIdentity() is not set.  The list will be processed
+// in order, terminating on first match, and an implicit "" is always checked at the end.
+// It is STRONGLY recommended for the first element, if any, to be a prefix of PolicyConfigurationIdentity(),
+// and each following element to be a prefix of the element preceding it.
+func (ref s3Reference) PolicyConfigurationNamespaces() []string {
+	res := []string{}
+	location := ref.location()
+	for {
+		lastSlash := strings.LastIndex(location, "/")
+		if lastSlash == -1 {
+			break
+		}
+		location = location[:lastSlash]
+		res = append(res, location)
+	}
+	return res
+}
+
+// NewImage returns a types.ImageCloser for this reference, possibly specialized for this ImageTransport.
+// The caller must call .Close() on the returned ImageCloser.
+// NOTE: If any kind of signature verification should happen, build an UnparsedImage from the value returned by NewImageSource,
+// verify that UnparsedImage, and convert it into a real Image via image.FromUnparsedImage.
+// WARNING: This may not do the right thing for a manifest list, see image.FromSource for details.
+func (ref s3Reference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	return image.FromReference(ctx, sys, ref)
+}
+
+// NewImageSource returns a types.ImageSource for this reference.
+// The caller must call .Close() on the returned ImageSource.
+func (ref s3Reference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	return newImageSource(ctx, sys, ref)
+}
+
+// NewImageDestination returns a types.ImageDestination for this reference.
+// The caller must call .Close() on the returned ImageDestination.
+func (ref s3Reference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	return newImageDestination(ctx, sys, ref)
+}
+
+// DeleteImage deletes the named image from the registry, if supported.
+func (ref s3Reference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
+	return errors.New("Deleting images not implemented for oci-s3: images")
+}
+
+// objectKey returns the object key of name (a path relative to the root of the layout) within the bucket.
+func (ref s3Reference) objectKey(name string) string {
+	if ref.prefix == "" {
+		return name
+	}
+	return ref.prefix + "/" + name
+}
+
+// ociLayoutKey returns the object key of the oci-layout file.
+func (ref s3Reference) ociLayoutKey() string {
+	return ref.objectKey(imgspecv1.ImageLayoutFile)
+}
+
+// indexKey returns the object key of the index.json file.
+func (ref s3Reference) indexKey() string {
+	return ref.objectKey(imgspecv1.ImageIndexFile)
+}
+
+// blobKey returns the object key of a blob using OCI image-layout conventions.
+func (ref s3Reference) blobKey(digest digest.Digest) (string, error) {
+	if err := digest.Validate(); err != nil { // digest.Digest.Encoded() panics on failure, and could possibly result in a path with ../, so validate explicitly.
+		return "", fmt.Errorf("unexpected digest reference %s: %w", digest, err)
+	}
+	return ref.objectKey(path.Join(imgspecv1.ImageBlobsDir, digest.Algorithm().String(), digest.Encoded())), nil
+}
+
+// getManifestDescriptor returns the descriptor in index selected by ref.image, and its position in index.
+func (ref s3Reference) getManifestDescriptor(index *imgspecv1.Index) (imgspecv1.Descriptor, int, error) {
+	if ref.image == "" {
+		// return manifest if only one image is in the layout
+		if len(index.Manifests) != 1 {
+			// ask user to choose image when more than one image in the layout
+			return imgspecv1.Descriptor{}, -1, ErrMoreThanOneImage
+		}
+		return index.Manifests[0], 0, nil
+	}
+	md, i, err := internal.FindManifestDescriptorByName(index, ref.image)
+	if err != nil {
+		return imgspecv1.Descriptor{}, -1, err
+	}
+	if i == -1 {
+		return imgspecv1.Descriptor{}, -1, ImageNotFoundError{ref}
+	}
+	return md, i, nil
+}
+
+// ImageNotFoundError is used when the OCI layout, in principle, exists and seems valid enough,
+// but nothing matches the “image” part of the provided reference.
+type ImageNotFoundError struct {
+	ref s3Reference
+	// We may make members public, or add methods, in the future.
+}
+
+func (e ImageNotFoundError) Error() string {
+	return fmt.Sprintf("no descriptor found for reference %q", e.ref.image)
+}
@@ -0,0 +1,161 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportName(t *testing.T) {
+	assert.Equal(t, "oci-s3", Transport.Name())
+}
+
+func TestTransportParseReference(t *testing.T) {
+	testParseReference(t, Transport.ParseReference)
+}
+
+func TestParseReference(t *testing.T) {
+	testParseReference(t, ParseReference)
+}
+
+// testParseReference is a test shared for Transport.ParseReference and ParseReference.
+func testParseReference(t *testing.T, fn func(string) (types.ImageReference, error)) {
+	for _, c := range []struct {
+		input                 string
+		bucket, prefix, image string // bucket == "" if an error is expected
+	}{
+		{"bucket", "bucket", "", ""},
+		{"bucket:", "bucket", "", ""},
+		{"bucket:notlatest", "bucket", "", "notlatest"},
+		{"my.bucket-1/some/prefix:notlatest", "my.bucket-1", "some/prefix", "notlatest"},
+		{"bucket/prefix", "bucket", "prefix", ""},
+		{"bucket/prefix:@invalid", "", "", ""}, // Invalid image name
+		{"", "", "", ""},                       // Empty bucket name
+		{"b", "", "", ""},                      // Bucket name too short
+		{"Bucket", "", "", ""},                 // Uppercase bucket name
+		{"-bucket", "", "", ""},                // Invalid first character
+		{"bucket/", "", "", ""},                // Trailing slash
+		{"bucket//prefix", "", "", ""},         // Non-canonical prefix
+		{"bucket/prefix/../other", "", "", ""}, // Non-canonical prefix
+	} {
+		ref, err := fn(c.input)
+		if c.bucket == "" {
+			assert.Error(t, err, c.input)
+			continue
+		}
+		require.NoError(t, err, c.input)
+		s3Ref, ok := ref.(s3Reference)
+		require.True(t, ok, c.input)
+		assert.Equal(t, c.bucket, s3Ref.bucket, c.input)
+		assert.Equal(t, c.prefix, s3Ref.prefix, c.input)
+		assert.Equal(t, c.image, s3Ref.image, c.input)
+	}
+}
+
+func TestTransportValidatePolicyConfigurationScope(t *testing.T) {
+	for _, scope := range []string{
+		"bucket",
+		"bucket/prefix",
+		"bucket/some/nested/prefix",
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.NoError(t, err, scope)
+	}
+
+	for _, scope := range []string{
+		"Bucket",
+		"bucket/",
+		"bucket/prefix:tag",
+		"bucket/a/../b",
+	} {
+		err := Transport.ValidatePolicyConfigurationScope(scope)
+		assert.Error(t, err, scope)
+	}
+}
+
+func TestReferenceTransport(t *testing.T) {
+	ref, err := NewReference("bucket", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, Transport, ref.Transport())
+}
+
+func TestReferenceStringWithinTransport(t *testing.T) {
+	for _, c := range []struct{ input, result string }{
+		{"bucket", "bucket:"},
+		{"bucket:image", "bucket:image"},
+		{"bucket/a/b:image", "bucket/a/b:image"},
+	} {
+		ref, err := ParseReference(c.input)
+		require.NoError(t, err, c.input)
+		stringRef := ref.StringWithinTransport()
+		assert.Equal(t, c.result, stringRef, c.input)
+		// Do one more round to verify that the output can be parsed, to an equal value.
+		ref2, err := Transport.ParseReference(stringRef)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, ref, ref2, c.input)
+	}
+}
+
+func TestReferenceDockerReference(t *testing.T) {
+	ref, err := ParseReference("bucket/prefix:image")
+	require.NoError(t, err)
+	assert.Nil(t, ref.DockerReference())
+}
+
+func TestReferencePolicyConfigurationIdentity(t *testing.T) {
+	ref, err := ParseReference("bucket/a/b:image")
+	require.NoError(t, err)
+	assert.Equal(t, "bucket/a/b", ref.PolicyConfigurationIdentity())
+}
+
+func TestReferencePolicyConfigurationNamespaces(t *testing.T) {
+	for _, c := range []struct {
+		input    string
+		expected []string
+	}{
+		{"bucket:image", []string{}},
+		{"bucket/a:image", []string{"bucket"}},
+		{"bucket/a/b/c", []string{"bucket/a/b", "bucket/a", "bucket"}},
+	} {
+		ref, err := ParseReference(c.input)
+		require.NoError(t, err, c.input)
+		ns := ref.PolicyConfigurationNamespaces()
+		assert.Equal(t, c.expected, ns, c.input)
+		for _, n := range ns {
+			err := Transport.ValidatePolicyConfigurationScope(n)
+			assert.NoError(t, err, n)
+		}
+	}
+}
+
+func TestReferenceDeleteImage(t *testing.T) {
+	ref, err := ParseReference("bucket:image")
+	require.NoError(t, err)
+	err = ref.DeleteImage(context.Background(), nil)
+	assert.Error(t, err)
+}
+
+func TestReferenceBlobKey(t *testing.T) {
+	const hex = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	ref, err := NewReference("bucket", "", "")
+	require.NoError(t, err)
+	key, err := ref.(s3Reference).blobKey(digest.Digest("sha256:" + hex))
+	require.NoError(t, err)
+	assert.Equal(t, "blobs/sha256/"+hex, key)
+
+	ref, err = NewReference("bucket", "a/b", "")
+	require.NoError(t, err)
+	key, err = ref.(s3Reference).blobKey(digest.Digest("sha256:" + hex))
+	require.NoError(t, err)
+	assert.Equal(t, "a/b/blobs/sha256/"+hex, key)
+	assert.Equal(t, "a/b/index.json", ref.(s3Reference).indexKey())
+	assert.Equal(t, "a/b/oci-layout", ref.(s3Reference).ociLayoutKey())
+
+	_, err = ref.(s3Reference).blobKey(digest.Digest("sha256:../../../etc/passwd"))
+	assert.Error(t, err)
+}
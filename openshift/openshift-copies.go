@@ -19,9 +19,9 @@ import (
 	"time"
 
 	"dario.cat/mergo"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/multierr"
 	"github.com/containers/storage/pkg/homedir"
-	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
@@ -317,7 +317,7 @@ func (config *directClientConfig) getContext() clientcmdContext {
 	var mergedContext clientcmdContext
 	if configContext, exists := contexts[contextName]; exists {
 		if err := mergo.MergeWithOverwrite(&mergedContext, configContext); err != nil {
-			logrus.Debugf("Can't merge configContext: %v", err)
+			log.Debugf("Can't merge configContext: %v", err)
 		}
 	}
 	// REMOVED: overrides support
@@ -336,7 +336,7 @@ func validateFileIsReadable(name string) error {
 	answer, err := os.Open(name)
 	defer func() {
 		if err := answer.Close(); err != nil {
-			logrus.Debugf("Error closing %v: %v", name, err)
+			log.Debugf("Error closing %v: %v", name, err)
 		}
 	}()
 	return err
@@ -430,7 +430,7 @@ func (config *directClientConfig) getAuthInfo() clientcmdAuthInfo {
 	var mergedAuthInfo clientcmdAuthInfo
 	if configAuthInfo, exists := authInfos[authInfoName]; exists {
 		if err := mergo.MergeWithOverwrite(&mergedAuthInfo, configAuthInfo); err != nil {
-			logrus.Debugf("Can't merge configAuthInfo: %v", err)
+			log.Debugf("Can't merge configAuthInfo: %v", err)
 		}
 	}
 	// REMOVED: overrides support
@@ -445,14 +445,14 @@ func (config *directClientConfig) getCluster() clientcmdCluster {
 
 	var mergedClusterInfo clientcmdCluster
 	if err := mergo.MergeWithOverwrite(&mergedClusterInfo, defaultCluster); err != nil {
-		logrus.Debugf("Can't merge defaultCluster: %v", err)
+		log.Debugf("Can't merge defaultCluster: %v", err)
 	}
 	if err := mergo.MergeWithOverwrite(&mergedClusterInfo, envVarCluster); err != nil {
-		logrus.Debugf("Can't merge envVarCluster: %v", err)
+		log.Debugf("Can't merge envVarCluster: %v", err)
 	}
 	if configClusterInfo, exists := clusterInfos[clusterInfoName]; exists {
 		if err := mergo.MergeWithOverwrite(&mergedClusterInfo, configClusterInfo); err != nil {
-			logrus.Debugf("Can't merge configClusterInfo: %v", err)
+			log.Debugf("Can't merge configClusterInfo: %v", err)
 		}
 	}
 	// REMOVED: overrides support
@@ -13,8 +13,8 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/version"
-	"github.com/sirupsen/logrus"
 )
 
 // openshiftClient is configuration for dealing with a single image stream, for reading or writing.
@@ -38,18 +38,18 @@ func newOpenshiftClient(ref openshiftReference) (*openshiftClient, error) {
 
 	// Overall, this is modelled on openshift/origin/pkg/cmd/util/clientcmd.New().ClientConfig() and openshift/origin/pkg/client.
 	cmdConfig := defaultClientConfig()
-	logrus.Debugf("cmdConfig: %#v", cmdConfig)
+	log.Debugf("cmdConfig: %#v", cmdConfig)
 	restConfig, err := cmdConfig.ClientConfig()
 	if err != nil {
 		return nil, err
 	}
 	// REMOVED: SetOpenShiftDefaults (values are not overridable in config files, so hard-coded these defaults.)
-	logrus.Debugf("restConfig: %#v", restConfig)
+	log.Debugf("restConfig: %#v", restConfig)
 	baseURL, httpClient, err := restClientFor(restConfig)
 	if err != nil {
 		return nil, err
 	}
-	logrus.Debugf("URL: %#v", *baseURL)
+	log.Debugf("URL: %#v", *baseURL)
 
 	if httpClient == nil {
 		httpClient = http.DefaultClient
@@ -75,7 +75,7 @@ func (c *openshiftClient) doRequest(ctx context.Context, method, path string, re
 	requestURL.Path = path
 	var requestBodyReader io.Reader
 	if requestBody != nil {
-		logrus.Debugf("Will send body: %s", requestBody)
+		log.DebugfContext(ctx, "Will send body: %s", requestBody)
 		requestBodyReader = bytes.NewReader(requestBody)
 	}
 	req, err := http.NewRequestWithContext(ctx, method, requestURL.String(), requestBodyReader)
@@ -94,7 +94,7 @@ func (c *openshiftClient) doRequest(ctx context.Context, method, path string, re
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	logrus.Debugf("%s %s", method, requestURL.Redacted())
+	log.DebugfContext(ctx, "%s %s", method, requestURL.Redacted())
 	res, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -104,9 +104,9 @@ func (c *openshiftClient) doRequest(ctx context.Context, method, path string, re
 	if err != nil {
 		return nil, err
 	}
-	logrus.Debugf("Got body: %s", body)
+	log.DebugfContext(ctx, "Got body: %s", body)
 	// FIXME: Just throwing this useful information away only to try to guess later...
-	logrus.Debugf("Got content-type: %s", res.Header.Get("Content-Type"))
+	log.DebugfContext(ctx, "Got content-type: %s", res.Header.Get("Content-Type"))
 
 	var status status
 	statusValid := false
@@ -11,11 +11,11 @@ import (
 	"github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/signature"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 type openshiftImageSource struct {
@@ -157,12 +157,12 @@ func (s *openshiftImageSource) ensureImageIsResolved(ctx context.Context) error
 	if te == nil {
 		return errors.New("No matching tag found")
 	}
-	logrus.Debugf("tag event %#v", te)
+	log.DebugfContext(ctx, "tag event %#v", te)
 	dockerRefString, err := s.client.convertDockerImageReference(te.DockerImageReference)
 	if err != nil {
 		return err
 	}
-	logrus.Debugf("Resolved reference %#v", dockerRefString)
+	log.DebugfContext(ctx, "Resolved reference %#v", dockerRefString)
 	dockerRef, err := docker.ParseReference("//" + dockerRefString)
 	if err != nil {
 		return err
@@ -111,6 +111,7 @@ func TestReferencePolicyConfigurationNamespaces(t *testing.T) {
 	ref, err := ParseReference("registry.example.com:8443/ns/stream:notlatest")
 	require.NoError(t, err)
 	assert.Equal(t, []string{
+		"registry.example.com:8443/ns/stream:*",
 		"registry.example.com:8443/ns/stream",
 		"registry.example.com:8443/ns",
 		"registry.example.com:8443",
@@ -78,6 +78,19 @@ func (s *ostreeImageSource) Close() error {
 	return nil
 }
 
+// ensureRepoOpen opens s.repo, if it has not been opened yet.
+func (s *ostreeImageSource) ensureRepoOpen() error {
+	if s.repo != nil {
+		return nil
+	}
+	repo, err := openRepo(s.ref.repo)
+	if err != nil {
+		return err
+	}
+	s.repo = repo
+	return nil
+}
+
 func (s *ostreeImageSource) getBlobUncompressedSize(blob string, isCompressed bool) (int64, error) {
 	var metadataKey string
 	if isCompressed {
@@ -87,9 +100,12 @@ func (s *ostreeImageSource) getBlobUncompressedSize(blob string, isCompressed bo
 	}
 	b := fmt.Sprintf("ociimage/%s", blob)
 	found, data, err := readMetadata(s.repo, b, metadataKey)
-	if err != nil || !found {
+	if err != nil {
 		return 0, err
 	}
+	if !found {
+		return 0, fmt.Errorf("blob %s not found in ostree repository %s", blob, s.ref.repo)
+	}
 	return strconv.ParseInt(data, 10, 64)
 }
 
@@ -123,12 +139,8 @@ func (s *ostreeImageSource) GetManifest(ctx context.Context, instanceDigest *dig
 	if instanceDigest != nil {
 		return nil, "", errors.New(`Manifest lists are not supported by "ostree:"`)
 	}
-	if s.repo == nil {
-		repo, err := openRepo(s.ref.repo)
-		if err != nil {
-			return nil, "", err
-		}
-		s.repo = repo
+	if err := s.ensureRepoOpen(); err != nil {
+		return nil, "", err
 	}
 
 	b := fmt.Sprintf("ociimage/%s", s.ref.branchName)
@@ -137,7 +149,7 @@ func (s *ostreeImageSource) GetManifest(ctx context.Context, instanceDigest *dig
 		return nil, "", err
 	}
 	if !found {
-		return nil, "", errors.New("manifest not found")
+		return nil, "", fmt.Errorf("manifest for %s not found in ostree repository %s", s.ref.branchName, s.ref.repo)
 	}
 	m := []byte(out)
 	return m, manifest.GuessMIMEType(m), nil
@@ -305,12 +317,8 @@ func (s *ostreeImageSource) GetBlob(ctx context.Context, info types.BlobInfo, ca
 	}
 	branch := fmt.Sprintf("ociimage/%s", blob)
 
-	if s.repo == nil {
-		repo, err := openRepo(s.ref.repo)
-		if err != nil {
-			return nil, 0, err
-		}
-		s.repo = repo
+	if err := s.ensureRepoOpen(); err != nil {
+		return nil, 0, err
 	}
 
 	layerSize, err := s.getBlobUncompressedSize(blob, isCompressed)
@@ -332,6 +340,18 @@ func (s *ostreeImageSource) GetBlob(ctx context.Context, info types.BlobInfo, ca
 		return file, layerSize, nil
 	}
 
+	// Layers are reconstructed uncompressed; a layer stored compressed can only be read using the uncompressed digest
+	// returned by LayerInfosForCopy, otherwise the data would not match the requested digest.
+	if !isCompressed {
+		found, uncompressedDigest, err := readMetadata(s.repo, branch, "docker.uncompressed_digest")
+		if err != nil {
+			return nil, 0, err
+		}
+		if found && uncompressedDigest != info.Digest.String() {
+			return nil, 0, fmt.Errorf("layer %s can only be read uncompressed, as %s", info.Digest, uncompressedDigest)
+		}
+	}
+
 	mf := bytes.NewReader(tarsplit)
 	mfz, err := pgzip.NewReader(mf)
 	if err != nil {
@@ -363,20 +383,16 @@ func (s *ostreeImageSource) GetSignaturesWithFormat(ctx context.Context, instanc
 	if instanceDigest != nil {
 		return nil, errors.New(`Manifest lists are not supported by "ostree:"`)
 	}
+	// The repository must be open before reading the signature count, GetSignaturesWithFormat may be called before GetManifest.
+	if err := s.ensureRepoOpen(); err != nil {
+		return nil, err
+	}
 	lenSignatures, err := s.getLenSignatures()
 	if err != nil {
 		return nil, err
 	}
 	branch := fmt.Sprintf("ociimage/%s", s.ref.branchName)
 
-	if s.repo == nil {
-		repo, err := openRepo(s.ref.repo)
-		if err != nil {
-			return nil, err
-		}
-		s.repo = repo
-	}
-
 	signatures := []signature.Signature{}
 	for i := int64(1); i <= lenSignatures; i++ {
 		path := fmt.Sprintf("/signature-%d", i)
@@ -384,9 +400,8 @@ func (s *ostreeImageSource) GetSignaturesWithFormat(ctx context.Context, instanc
 		if err != nil {
 			return nil, err
 		}
-		defer sigReader.Close()
-
 		sigBlob, err := io.ReadAll(sigReader)
+		sigReader.Close()
 		if err != nil {
 			return nil, err
 		}
@@ -418,22 +433,34 @@ func (s *ostreeImageSource) LayerInfosForCopy(ctx context.Context, instanceDiges
 	}
 
 	man, err := manifest.FromBlob(manifestBlob, manifestType)
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
 
-	s.compressed = make(map[digest.Digest]digest.Digest)
+	compressed := make(map[digest.Digest]digest.Digest)
 
 	layerBlobs := man.LayerInfos()
 
 	for _, layerBlob := range layerBlobs {
+		if err := layerBlob.Digest.Validate(); err != nil { // digest.Digest.Encoded() panics on failure, so validate explicitly.
+			return nil, err
+		}
 		branch := fmt.Sprintf("ociimage/%s", layerBlob.Digest.Encoded())
 		found, uncompressedDigestStr, err := readMetadata(s.repo, branch, "docker.uncompressed_digest")
-		if err != nil || !found {
+		if err != nil {
 			return nil, err
 		}
+		if !found {
+			return nil, fmt.Errorf("layer %s not found in ostree repository %s", layerBlob.Digest, s.ref.repo)
+		}
 
 		found, uncompressedSizeStr, err := readMetadata(s.repo, branch, "docker.uncompressed_size")
-		if err != nil || !found {
+		if err != nil {
 			return nil, err
 		}
+		if !found {
+			return nil, fmt.Errorf("size of layer %s not recorded in ostree repository %s", layerBlob.Digest, s.ref.repo)
+		}
 
 		uncompressedSize, err := strconv.ParseInt(uncompressedSizeStr, 10, 64)
 		if err != nil {
@@ -448,8 +475,10 @@ func (s *ostreeImageSource) LayerInfosForCopy(ctx context.Context, instanceDiges
 			Size:      uncompressedSize,
 			MediaType: layerBlob.MediaType,
 		}
-		s.compressed[uncompressedDigest] = layerBlob.Digest
+		compressed[uncompressedDigest] = layerBlob.Digest
 		updatedBlobInfos = append(updatedBlobInfos, blobInfo)
 	}
+	// Only set s.compressed once it is complete, GetBlob relies on it being either nil or fully populated.
+	s.compressed = compressed
 	return updatedBlobInfos, nil
 }
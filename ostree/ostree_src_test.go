@@ -3,6 +3,133 @@
 
 package ostree
 
-import "github.com/containers/image/v5/internal/private"
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/internal/imagedestination"
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/ostreedev/ostree-go/pkg/otbuiltin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
 
 var _ private.ImageSource = (*ostreeImageSource)(nil)
+
+// testLayer returns an uncompressed layer tarball, and its gzip-compressed version.
+func testLayer(t *testing.T) ([]byte, []byte) {
+	uncompressed := bytes.Buffer{}
+	tw := tar.NewWriter(&uncompressed)
+	for _, f := range []struct {
+		name, contents string
+	}{
+		{"etc/hostname", "ostree-test\n"},
+		{"usr/bin/hello", "#!/bin/sh\necho hello\n"},
+	} {
+		err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: 0o644, Size: int64(len(f.contents))})
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(f.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	compressed := bytes.Buffer{}
+	gzw := gzip.NewWriter(&compressed)
+	_, err := gzw.Write(uncompressed.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, gzw.Close())
+	return uncompressed.Bytes(), compressed.Bytes()
+}
+
+func TestImageSourceRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	initOptions := otbuiltin.NewInitOptions()
+	if os.Getuid() != 0 {
+		initOptions.Mode = "bare-user" // Files are committed with the ownership of the current user, see importBlob.
+	}
+	_, err := otbuiltin.Init(repoPath, initOptions)
+	require.NoError(t, err)
+	ref, err := NewReference("busybox:latest", repoPath)
+	require.NoError(t, err)
+	sys := &types.SystemContext{OSTreeTmpDirPath: t.TempDir()}
+
+	uncompressedLayer, compressedLayer := testLayer(t)
+	uncompressedDigest := digest.FromBytes(uncompressedLayer)
+	compressedDigest := digest.FromBytes(compressedLayer)
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":["` + uncompressedDigest.String() + `"]}}`)
+	configDigest := digest.FromBytes(config)
+	man, err := json.Marshal(manifest.Schema2FromComponents(
+		manifest.Schema2Descriptor{MediaType: manifest.DockerV2Schema2ConfigMediaType, Size: int64(len(config)), Digest: configDigest},
+		[]manifest.Schema2Descriptor{{MediaType: manifest.DockerV2Schema2LayerMediaType, Size: int64(len(compressedLayer)), Digest: compressedDigest}},
+	))
+	require.NoError(t, err)
+
+	// Store the image
+	publicDest, err := ref.NewImageDestination(ctx, sys)
+	require.NoError(t, err)
+	dest := imagedestination.FromPublic(publicDest)
+	defer dest.Close()
+	for _, blob := range [][]byte{compressedLayer, config} {
+		_, err := dest.PutBlobWithOptions(ctx, bytes.NewReader(blob), types.BlobInfo{Digest: digest.FromBytes(blob), Size: int64(len(blob))},
+			private.PutBlobOptions{Cache: none.NoCache})
+		require.NoError(t, err)
+	}
+	err = dest.PutManifest(ctx, man, nil)
+	require.NoError(t, err)
+	err = dest.Commit(ctx, nil)
+	require.NoError(t, err)
+
+	// Read it back
+	publicSrc, err := ref.NewImageSource(ctx, sys)
+	require.NoError(t, err)
+	src := imagesource.FromPublic(publicSrc)
+	defer src.Close()
+
+	readManifest, mimeType, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, man, readManifest)
+	assert.Equal(t, manifest.DockerV2Schema2MediaType, mimeType)
+
+	sigs, err := src.GetSignaturesWithFormat(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, sigs)
+
+	layerInfos, err := src.LayerInfosForCopy(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, layerInfos, 1)
+	assert.Equal(t, uncompressedDigest, layerInfos[0].Digest)
+	assert.Equal(t, int64(len(uncompressedLayer)), layerInfos[0].Size)
+
+	for _, c := range []struct {
+		digest   digest.Digest
+		expected []byte
+	}{
+		{uncompressedDigest, uncompressedLayer}, // The layer is reconstructed, uncompressed, from the checked-in files
+		{configDigest, config},
+	} {
+		reader, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: c.digest, Size: -1}, none.NoCache)
+		require.NoError(t, err, c.digest.String())
+		contents, err := io.ReadAll(reader)
+		reader.Close()
+		require.NoError(t, err, c.digest.String())
+		assert.Equal(t, c.expected, contents, c.digest.String())
+		assert.Equal(t, int64(len(c.expected)), size, c.digest.String())
+	}
+
+	// The compressed layer can't be reconstructed
+	_, _, err = src.GetBlob(ctx, types.BlobInfo{Digest: compressedDigest, Size: -1}, none.NoCache)
+	assert.Error(t, err)
+}
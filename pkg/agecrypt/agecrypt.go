@@ -0,0 +1,195 @@
+// Package agecrypt integrates age (https://age-encryption.org) recipients and identities with ocicrypt layer encryption.
+//
+// Importing this package registers an ocicrypt key wrapper for the "age" scheme; the c/image/copy package does so.
+// Layers are then encrypted for all age recipients in the RecipientsParameter parameter of an ocicrypt EncryptConfig,
+// and decrypted using the identities in the IdentitiesParameter parameter of a DecryptConfig;
+// EncryptConfig and DecryptConfig build such configurations, suitable for copy.Options.OciEncryptConfig and OciDecryptConfig.
+package agecrypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/ocicrypt"
+	encconfig "github.com/containers/ocicrypt/config"
+	"github.com/containers/ocicrypt/keywrap"
+)
+
+const (
+	// RecipientsParameter is the ocicrypt EncryptConfig parameter containing age recipients, one per value:
+	// native X25519 recipients ("age1…"), or SSH public keys in the authorized_keys format ("ssh-ed25519 …", "ssh-rsa …").
+	RecipientsParameter = "age-recipients"
+	// IdentitiesParameter is the ocicrypt DecryptConfig parameter containing age identities, one per value:
+	// the contents of an age identity file (one or more "AGE-SECRET-KEY-1…" lines), or an unencrypted SSH private key in PEM format.
+	IdentitiesParameter = "age-identities"
+
+	// scheme is the name of the key wrapper registered with ocicrypt.
+	scheme = "age"
+	// annotationID is the annotation containing age-wrapped layer keys.
+	annotationID = "org.opencontainers.image.enc.keys.age"
+	// maxWrappedKeySize is the largest wrapped key data we are willing to decrypt.
+	maxWrappedKeySize = 1 << 20
+)
+
+func init() {
+	ocicrypt.RegisterKeyWrapper(scheme, NewKeyWrapper())
+}
+
+// EncryptConfig returns an ocicrypt configuration which encrypts layers for recipients.
+// See RecipientsParameter for the accepted recipient formats.
+func EncryptConfig(recipients []string) (*encconfig.EncryptConfig, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no age recipients specified")
+	}
+	values := make([][]byte, 0, len(recipients))
+	for _, r := range recipients {
+		if _, err := parseRecipient(r); err != nil {
+			return nil, err
+		}
+		values = append(values, []byte(r))
+	}
+	return &encconfig.EncryptConfig{
+		Parameters: map[string][][]byte{RecipientsParameter: values},
+		DecryptConfig: encconfig.DecryptConfig{
+			Parameters: map[string][][]byte{},
+		},
+	}, nil
+}
+
+// DecryptConfig returns an ocicrypt configuration which decrypts layers using identities.
+// See IdentitiesParameter for the accepted identity formats.
+func DecryptConfig(identities [][]byte) (*encconfig.DecryptConfig, error) {
+	if len(identities) == 0 {
+		return nil, errors.New("no age identities specified")
+	}
+	for _, i := range identities {
+		if _, err := parseIdentities(i); err != nil {
+			return nil, err
+		}
+	}
+	return &encconfig.DecryptConfig{
+		Parameters: map[string][][]byte{IdentitiesParameter: identities},
+	}, nil
+}
+
+// parseRecipient parses a single age recipient.
+func parseRecipient(recipient string) (age.Recipient, error) {
+	recipient = strings.TrimSpace(recipient)
+	if strings.HasPrefix(recipient, "age1") {
+		r, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("parsing age recipient: %w", err)
+		}
+		return r, nil
+	}
+	r, err := agessh.ParseRecipient(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("parsing age SSH recipient: %w", err)
+	}
+	return r, nil
+}
+
+// parseIdentities parses a single value of IdentitiesParameter.
+func parseIdentities(data []byte) ([]age.Identity, error) {
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		i, err := agessh.ParseIdentity(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing age SSH identity: %w", err)
+		}
+		return []age.Identity{i}, nil
+	}
+	ids, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing age identities: %w", err)
+	}
+	return ids, nil
+}
+
+type ageKeyWrapper struct{}
+
+// NewKeyWrapper returns an ocicrypt key wrapper using age.
+// Most callers should not need to use it directly, it is registered with ocicrypt when this package is imported.
+func NewKeyWrapper() keywrap.KeyWrapper {
+	return &ageKeyWrapper{}
+}
+
+func (kw *ageKeyWrapper) GetAnnotationID() string {
+	return annotationID
+}
+
+// WrapKeys encrypts optsData, which describes the symmetric key used for encrypting the layer, for all age recipients in ec.
+func (kw *ageKeyWrapper) WrapKeys(ec *encconfig.EncryptConfig, optsData []byte) ([]byte, error) {
+	recipients := []age.Recipient{}
+	for _, value := range ec.Parameters[RecipientsParameter] {
+		r, err := parseRecipient(string(value))
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+	// As with other key wrappers, no recipients is not an error…
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+
+	res := bytes.Buffer{}
+	w, err := age.Encrypt(&res, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("age encryption: %w", err)
+	}
+	if _, err := w.Write(optsData); err != nil {
+		return nil, fmt.Errorf("age encryption: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("age encryption: %w", err)
+	}
+	return res.Bytes(), nil
+}
+
+// UnwrapKey decrypts wrappedKey using the age identities in dc.
+func (kw *ageKeyWrapper) UnwrapKey(dc *encconfig.DecryptConfig, wrappedKey []byte) ([]byte, error) {
+	identities := []age.Identity{}
+	for _, value := range kw.GetPrivateKeys(dc.Parameters) {
+		ids, err := parseIdentities(value)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, ids...)
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("no age identities found for decryption")
+	}
+
+	r, err := age.Decrypt(io.LimitReader(bytes.NewReader(wrappedKey), maxWrappedKeySize), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("age decryption: %w", err)
+	}
+	res, err := iolimits.ReadAtMost(r, maxWrappedKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("age decryption: %w", err)
+	}
+	return res, nil
+}
+
+func (kw *ageKeyWrapper) NoPossibleKeys(dcparameters map[string][][]byte) bool {
+	return len(kw.GetPrivateKeys(dcparameters)) == 0
+}
+
+func (kw *ageKeyWrapper) GetPrivateKeys(dcparameters map[string][][]byte) [][]byte {
+	return dcparameters[IdentitiesParameter]
+}
+
+func (kw *ageKeyWrapper) GetKeyIdsFromPacket(packet string) ([]uint64, error) {
+	return nil, nil
+}
+
+func (kw *ageKeyWrapper) GetRecipients(packet string) ([]string, error) {
+	// age headers intentionally don’t identify the recipients.
+	return []string{"[age]"}, nil
+}
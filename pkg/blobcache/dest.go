@@ -9,8 +9,10 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/containers/image/v5/internal/bufferpool"
 	"github.com/containers/image/v5/internal/imagedestination"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/signature"
 	"github.com/containers/image/v5/manifest"
@@ -19,7 +21,6 @@ import (
 	"github.com/containers/storage/pkg/archive"
 	"github.com/containers/storage/pkg/ioutils"
 	digest "github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 type blobCacheDestination struct {
@@ -34,7 +35,7 @@ func (b *BlobCache) NewImageDestination(ctx context.Context, sys *types.SystemCo
 	if err != nil {
 		return nil, fmt.Errorf("error creating new image destination %q: %w", transports.ImageName(b.reference), err)
 	}
-	logrus.Debugf("starting to write to image %q using blob cache in %q", transports.ImageName(b.reference), b.directory)
+	log.DebugfContext(ctx, "starting to write to image %q using blob cache in %q", transports.ImageName(b.reference), b.directory)
 	d := &blobCacheDestination{reference: b, destination: imagedestination.FromPublic(dest)}
 	d.Compat = impl.AddCompat(d)
 	return d, nil
@@ -45,7 +46,7 @@ func (d *blobCacheDestination) Reference() types.ImageReference {
 }
 
 func (d *blobCacheDestination) Close() error {
-	logrus.Debugf("finished writing to image %q using blob cache", transports.ImageName(d.reference))
+	log.Debugf("finished writing to image %q using blob cache", transports.ImageName(d.reference))
 	return d.destination.Close()
 }
 
@@ -86,7 +87,7 @@ func (d *blobCacheDestination) saveStream(wg *sync.WaitGroup, decompressReader i
 		if !succeeded {
 			// Remove the temporary file.
 			if err := os.Remove(tempFile.Name()); err != nil {
-				logrus.Debugf("error cleaning up temporary file %q for decompressed copy of blob %q: %v", tempFile.Name(), compressedDigest.String(), err)
+				log.Debugf("error cleaning up temporary file %q for decompressed copy of blob %q: %v", tempFile.Name(), compressedDigest.String(), err)
 			}
 		}
 	}()
@@ -100,14 +101,14 @@ func (d *blobCacheDestination) saveStream(wg *sync.WaitGroup, decompressReader i
 		if err != nil {
 			// Drain the pipe to keep from stalling the PutBlob() thread.
 			if _, err2 := io.Copy(io.Discard, decompressReader); err2 != nil {
-				logrus.Debugf("error draining the pipe: %v", err2)
+				log.Debugf("error draining the pipe: %v", err2)
 			}
 			return err
 		}
 		defer decompressed.Close()
 		// Read the decompressed data through the filter over the pipe, blocking until the
 		// writing end is closed.
-		_, err = io.Copy(io.MultiWriter(tempFile, digester.Hash()), decompressed)
+		_, err = bufferpool.Copy(io.MultiWriter(tempFile, digester.Hash()), decompressed)
 		return err
 	}(); err != nil {
 		return
@@ -120,17 +121,17 @@ func (d *blobCacheDestination) saveStream(wg *sync.WaitGroup, decompressReader i
 	}
 	// Rename the temporary file.
 	if err := os.Rename(tempFile.Name(), decompressedFilename); err != nil {
-		logrus.Debugf("error renaming new decompressed copy of blob %q into place at %q: %v", digester.Digest().String(), decompressedFilename, err)
+		log.Debugf("error renaming new decompressed copy of blob %q into place at %q: %v", digester.Digest().String(), decompressedFilename, err)
 		return
 	}
 	succeeded = true
 	*alternateDigest = digester.Digest()
 	// Note the relationship between the two files.
 	if err := ioutils.AtomicWriteFile(decompressedFilename+compressedNote, []byte(compressedDigest.String()), 0600); err != nil {
-		logrus.Debugf("error noting that the compressed version of %q is %q: %v", digester.Digest().String(), compressedDigest.String(), err)
+		log.Debugf("error noting that the compressed version of %q is %q: %v", digester.Digest().String(), compressedDigest.String(), err)
 	}
 	if err := ioutils.AtomicWriteFile(compressedFilename+decompressedNote, []byte(digester.Digest().String()), 0600); err != nil {
-		logrus.Debugf("error noting that the decompressed version of %q is %q: %v", compressedDigest.String(), digester.Digest().String(), err)
+		log.Debugf("error noting that the decompressed version of %q is %q: %v", compressedDigest.String(), digester.Digest().String(), err)
 	}
 }
 
@@ -166,19 +167,19 @@ func (d *blobCacheDestination) PutBlobWithOptions(ctx context.Context, stream io
 				if err == nil {
 					if err = os.Rename(tempfile.Name(), filename); err != nil {
 						if err2 := os.Remove(tempfile.Name()); err2 != nil {
-							logrus.Debugf("error cleaning up temporary file %q for blob %q: %v", tempfile.Name(), inputInfo.Digest.String(), err2)
+							log.DebugfContext(ctx, "error cleaning up temporary file %q for blob %q: %v", tempfile.Name(), inputInfo.Digest.String(), err2)
 						}
 						err = fmt.Errorf("error renaming new layer for blob %q into place at %q: %w", inputInfo.Digest.String(), filename, err)
 					}
 				} else {
 					if err2 := os.Remove(tempfile.Name()); err2 != nil {
-						logrus.Debugf("error cleaning up temporary file %q for blob %q: %v", tempfile.Name(), inputInfo.Digest.String(), err2)
+						log.DebugfContext(ctx, "error cleaning up temporary file %q for blob %q: %v", tempfile.Name(), inputInfo.Digest.String(), err2)
 					}
 				}
 				tempfile.Close()
 			}()
 		} else {
-			logrus.Debugf("error while creating a temporary file under %q to hold blob %q: %v", filepath.Dir(filename), inputInfo.Digest.String(), err)
+			log.DebugfContext(ctx, "error while creating a temporary file under %q to hold blob %q: %v", filepath.Dir(filename), inputInfo.Digest.String(), err)
 		}
 		if !options.IsConfig {
 			initial := make([]byte, 8)
@@ -195,7 +196,7 @@ func (d *blobCacheDestination) PutBlobWithOptions(ctx context.Context, stream io
 					// use to store a decompressed copy.
 					decompressedTemp, err2 := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename))
 					if err2 != nil {
-						logrus.Debugf("error while creating a temporary file under %q to hold decompressed blob %q: %v", filepath.Dir(filename), inputInfo.Digest.String(), err2)
+						log.DebugfContext(ctx, "error while creating a temporary file under %q to hold decompressed blob %q: %v", filepath.Dir(filename), inputInfo.Digest.String(), err2)
 					} else {
 						// Write a copy of the compressed data to a pipe,
 						// closing the writing end of the pipe after
@@ -223,9 +224,9 @@ func (d *blobCacheDestination) PutBlobWithOptions(ctx context.Context, stream io
 		return newBlobInfo, fmt.Errorf("error storing blob to image destination for cache %q: %w", transports.ImageName(d.reference), err)
 	}
 	if alternateDigest.Validate() == nil {
-		logrus.Debugf("added blob %q (also %q) to the cache at %q", inputInfo.Digest.String(), alternateDigest.String(), d.reference.directory)
+		log.DebugfContext(ctx, "added blob %q (also %q) to the cache at %q", inputInfo.Digest.String(), alternateDigest.String(), d.reference.directory)
 	} else {
-		logrus.Debugf("added blob %q to the cache at %q", inputInfo.Digest.String(), d.reference.directory)
+		log.DebugfContext(ctx, "added blob %q to the cache at %q", inputInfo.Digest.String(), d.reference.directory)
 	}
 	return newBlobInfo, nil
 }
@@ -285,14 +286,14 @@ func (d *blobCacheDestination) TryReusingBlobWithOptions(ctx context.Context, in
 func (d *blobCacheDestination) PutManifest(ctx context.Context, manifestBytes []byte, instanceDigest *digest.Digest) error {
 	manifestDigest, err := manifest.Digest(manifestBytes)
 	if err != nil {
-		logrus.Warnf("error digesting manifest %q: %v", string(manifestBytes), err)
+		log.WarnfContext(ctx, "error digesting manifest %q: %v", string(manifestBytes), err)
 	} else {
 		filename, err := d.reference.blobPath(manifestDigest, false)
 		if err != nil {
 			return err
 		}
 		if err = ioutils.AtomicWriteFile(filename, manifestBytes, 0600); err != nil {
-			logrus.Warnf("error saving manifest as %q: %v", filename, err)
+			log.WarnfContext(ctx, "error saving manifest as %q: %v", filename, err)
 		}
 	}
 	return d.destination.PutManifest(ctx, manifestBytes, instanceDigest)
@@ -11,6 +11,7 @@ import (
 	"github.com/containers/image/v5/internal/image"
 	"github.com/containers/image/v5/internal/imagesource"
 	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/manifest"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/signature"
@@ -19,7 +20,6 @@ import (
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 type blobCacheSource struct {
@@ -40,7 +40,7 @@ func (b *BlobCache) NewImageSource(ctx context.Context, sys *types.SystemContext
 	if err != nil {
 		return nil, fmt.Errorf("error creating new image source %q: %w", transports.ImageName(b.reference), err)
 	}
-	logrus.Debugf("starting to read from image %q using blob cache in %q (compression=%v)", transports.ImageName(b.reference), b.directory, b.compress)
+	log.DebugfContext(ctx, "starting to read from image %q using blob cache in %q (compression=%v)", transports.ImageName(b.reference), b.directory, b.compress)
 	s := &blobCacheSource{reference: b, source: imagesource.FromPublic(src), sys: *sys}
 	s.Compat = impl.AddCompat(s)
 	return s, nil
@@ -51,7 +51,7 @@ func (s *blobCacheSource) Reference() types.ImageReference {
 }
 
 func (s *blobCacheSource) Close() error {
-	logrus.Debugf("finished reading from image %q using blob cache: cache had %d hits, %d misses, %d errors", transports.ImageName(s.reference), s.cacheHits, s.cacheMisses, s.cacheErrors)
+	log.Debugf("finished reading from image %q using blob cache: cache had %d hits, %d misses, %d errors", transports.ImageName(s.reference), s.cacheHits, s.cacheMisses, s.cacheErrors)
 	return s.source.Close()
 }
 
@@ -166,11 +166,11 @@ func (s *blobCacheSource) layerInfoForCopy(info types.BlobInfo) (types.BlobInfo,
 			return info, nil
 		}
 	}
-	logrus.Debugf("suggesting cached blob with digest %q, type %q, and compression %v in place of blob with digest %q", replaceDigest.String(), info.MediaType, s.reference.compress, info.Digest.String())
+	log.Debugf("suggesting cached blob with digest %q, type %q, and compression %v in place of blob with digest %q", replaceDigest.String(), info.MediaType, s.reference.compress, info.Digest.String())
 	info.CompressionOperation = s.reference.compress
 	info.Digest = replaceDigest
 	info.Size = fileInfo.Size()
-	logrus.Debugf("info = %#v", info)
+	log.Debugf("info = %#v", info)
 	return info, nil
 }
 
@@ -2,6 +2,7 @@
 package boltdb
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -9,11 +10,11 @@ import (
 	"time"
 
 	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/pkg/blobinfocache/internal/prioritize"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/fileutils"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
 )
 
@@ -28,6 +29,10 @@ var (
 	// digestCompressorBucket stores a mapping from any digest to a compressor, or blobinfocache.Uncompressed (not blobinfocache.UnknownCompression).
 	// It may not exist in caches created by older versions, even if uncompressedDigestBucket is present.
 	digestCompressorBucket = []byte("digestCompressor")
+	// digestCompressionAnnotationsBucket stores a mapping from any digest to a JSON-encoded map of annotations
+	// required to use its compression variant.
+	// It may not exist in caches created by older versions, even if digestCompressorBucket is present.
+	digestCompressionAnnotationsBucket = []byte("digestCompressionAnnotations")
 	// digestByUncompressedBucket stores a bucket per uncompressed digest, with the bucket containing a set of digests for that uncompressed digest
 	// (as a set of key=digest, value="" pairs)
 	digestByUncompressedBucket = []byte("digestByUncompressed")
@@ -221,7 +226,7 @@ func (bdc *cache) RecordDigestUncompressedPair(anyDigest digest.Digest, uncompre
 				return err
 			}
 			if previous != uncompressed {
-				logrus.Warnf("Uncompressed digest for blob %s previously recorded as %s, now %s", anyDigest, previous, uncompressed)
+				log.Warnf("Uncompressed digest for blob %s previously recorded as %s, now %s", anyDigest, previous, uncompressed)
 			}
 		}
 		if err := b.Put(key, []byte(uncompressed.String())); err != nil {
@@ -257,7 +262,7 @@ func (bdc *cache) RecordDigestCompressorName(anyDigest digest.Digest, compressor
 		key := []byte(anyDigest.String())
 		if previousBytes := b.Get(key); previousBytes != nil {
 			if string(previousBytes) != compressorName {
-				logrus.Warnf("Compressor for blob with digest %s previously recorded as %s, now %s", anyDigest, string(previousBytes), compressorName)
+				log.Warnf("Compressor for blob with digest %s previously recorded as %s, now %s", anyDigest, string(previousBytes), compressorName)
 			}
 		}
 		if compressorName == blobinfocache.UnknownCompression {
@@ -267,6 +272,27 @@ func (bdc *cache) RecordDigestCompressorName(anyDigest digest.Digest, compressor
 	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
 }
 
+// RecordDigestCompressionAnnotations records annotations which must be set on a layer using the blob with digest anyDigest
+// to use its specific compression variant, or forgets them if annotations is empty.
+// WARNING: Only call this for LOCALLY VERIFIED data; don’t record annotations just because some remote author claims so.
+func (bdc *cache) RecordDigestCompressionAnnotations(anyDigest digest.Digest, annotations map[string]string) {
+	_ = bdc.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(digestCompressionAnnotationsBucket)
+		if err != nil {
+			return err
+		}
+		key := []byte(anyDigest.String())
+		if len(annotations) == 0 {
+			return b.Delete(key)
+		}
+		value, err := json.Marshal(annotations)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, value)
+	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
+}
+
 // RecordKnownLocation records that a blob with the specified digest exists within the specified (transport, scope) scope,
 // and can be reused given the opaque location data.
 func (bdc *cache) RecordKnownLocation(transport types.ImageTransport, scope types.BICTransportScope, blobDigest digest.Digest, location types.BICLocationReference) {
@@ -300,11 +326,11 @@ func (bdc *cache) RecordKnownLocation(transport types.ImageTransport, scope type
 
 // appendReplacementCandidates creates prioritize.CandidateWithTime values for digest in scopeBucket
 // (which might be nil) with corresponding compression
-// info from compressionBucket (which might be nil), and returns the result of appending them
+// info from compressionBucket and annotationsBucket (which might be nil), and returns the result of appending them
 // to candidates.
 // v2Options is not nil if the caller is CandidateLocations2: this allows including candidates with unknown location, and filters out candidates
 // with unknown compression.
-func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateWithTime, scopeBucket, compressionBucket *bolt.Bucket, digest digest.Digest,
+func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateWithTime, scopeBucket, compressionBucket, annotationsBucket *bolt.Bucket, digest digest.Digest,
 	v2Options *blobinfocache.CandidateLocations2Options) []prioritize.CandidateWithTime {
 	digestKey := []byte(digest.String())
 	compressorName := blobinfocache.UnknownCompression
@@ -320,6 +346,16 @@ func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 		return candidates
 	}
 
+	var annotations map[string]string // = nil
+	if v2Options != nil && annotationsBucket != nil {
+		if annotationsValue := annotationsBucket.Get(digestKey); len(annotationsValue) > 0 {
+			if err := json.Unmarshal(annotationsValue, &annotations); err != nil {
+				log.Debugf("Ignoring invalid BlobInfoCache compression annotations for digest %q: %v", digest.String(), err)
+				annotations = nil
+			}
+		}
+	}
+
 	var b *bolt.Bucket
 	if scopeBucket != nil {
 		b = scopeBucket.Bucket(digestKey)
@@ -332,10 +368,11 @@ func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 			}
 			candidates = append(candidates, prioritize.CandidateWithTime{
 				Candidate: blobinfocache.BICReplacementCandidate2{
-					Digest:               digest,
-					CompressionOperation: compressionOp,
-					CompressionAlgorithm: compressionAlgo,
-					Location:             types.BICLocationReference{Opaque: string(k)},
+					Digest:                 digest,
+					CompressionOperation:   compressionOp,
+					CompressionAlgorithm:   compressionAlgo,
+					Location:               types.BICLocationReference{Opaque: string(k)},
+					CompressionAnnotations: annotations,
 				},
 				LastSeen: t,
 			})
@@ -344,11 +381,12 @@ func (bdc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 	} else if v2Options != nil {
 		candidates = append(candidates, prioritize.CandidateWithTime{
 			Candidate: blobinfocache.BICReplacementCandidate2{
-				Digest:               digest,
-				CompressionOperation: compressionOp,
-				CompressionAlgorithm: compressionAlgo,
-				UnknownLocation:      true,
-				Location:             types.BICLocationReference{Opaque: ""},
+				Digest:                 digest,
+				CompressionOperation:   compressionOp,
+				CompressionAlgorithm:   compressionAlgo,
+				UnknownLocation:        true,
+				Location:               types.BICLocationReference{Opaque: ""},
+				CompressionAnnotations: annotations,
 			},
 			LastSeen: time.Time{},
 		})
@@ -380,8 +418,9 @@ func (bdc *cache) candidateLocations(transport types.ImageTransport, scope types
 		// compressionBucket won't have been created if previous writers never recorded info about compression,
 		// and we don't want to fail just because of that
 		compressionBucket := tx.Bucket(digestCompressorBucket)
+		annotationsBucket := tx.Bucket(digestCompressionAnnotationsBucket) // Similarly, this might not exist.
 
-		res = bdc.appendReplacementCandidates(res, scopeBucket, compressionBucket, primaryDigest, v2Options)
+		res = bdc.appendReplacementCandidates(res, scopeBucket, compressionBucket, annotationsBucket, primaryDigest, v2Options)
 		if canSubstitute {
 			if uncompressedDigestValue = bdc.uncompressedDigest(tx, primaryDigest); uncompressedDigestValue != "" {
 				b := tx.Bucket(digestByUncompressedBucket)
@@ -394,7 +433,7 @@ func (bdc *cache) candidateLocations(transport types.ImageTransport, scope types
 								return err
 							}
 							if d != primaryDigest && d != uncompressedDigestValue {
-								res = bdc.appendReplacementCandidates(res, scopeBucket, compressionBucket, d, v2Options)
+								res = bdc.appendReplacementCandidates(res, scopeBucket, compressionBucket, annotationsBucket, d, v2Options)
 							}
 							return nil
 						}); err != nil {
@@ -403,7 +442,7 @@ func (bdc *cache) candidateLocations(transport types.ImageTransport, scope types
 					}
 				}
 				if uncompressedDigestValue != primaryDigest {
-					res = bdc.appendReplacementCandidates(res, scopeBucket, compressionBucket, uncompressedDigestValue, v2Options)
+					res = bdc.appendReplacementCandidates(res, scopeBucket, compressionBucket, annotationsBucket, uncompressedDigestValue, v2Options)
 				}
 			}
 		}
@@ -5,11 +5,11 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/rootless"
 	"github.com/containers/image/v5/pkg/blobinfocache/memory"
 	"github.com/containers/image/v5/pkg/blobinfocache/sqlite"
 	"github.com/containers/image/v5/types"
-	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -52,12 +52,12 @@ func blobInfoCacheDir(sys *types.SystemContext, euid int) (string, error) {
 func DefaultCache(sys *types.SystemContext) types.BlobInfoCache {
 	dir, err := blobInfoCacheDir(sys, rootless.GetRootlessEUID())
 	if err != nil {
-		logrus.Debugf("Error determining a location for %s, using a memory-only cache", blobInfoCacheFilename)
+		log.Debugf("Error determining a location for %s, using a memory-only cache", blobInfoCacheFilename)
 		return memory.New()
 	}
 	path := filepath.Join(dir, blobInfoCacheFilename)
 	if err := os.MkdirAll(dir, 0700); err != nil {
-		logrus.Debugf("Error creating parent directories for %s, using a memory-only cache: %v", path, err)
+		log.Debugf("Error creating parent directories for %s, using a memory-only cache: %v", path, err)
 		return memory.New()
 	}
 
@@ -68,10 +68,10 @@ func DefaultCache(sys *types.SystemContext) types.BlobInfoCache {
 
 	cache, err := sqlite.New(path)
 	if err != nil {
-		logrus.Debugf("Error creating a SQLite blob info cache at %s, using a memory-only cache: %v", path, err)
+		log.Debugf("Error creating a SQLite blob info cache at %s, using a memory-only cache: %v", path, err)
 		return memory.New()
 	}
-	logrus.Debugf("Using SQLite blob info cache at %s", path)
+	log.Debugf("Using SQLite blob info cache at %s", path)
 	return cache
 }
 
@@ -8,11 +8,11 @@ import (
 	"time"
 
 	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/manifest"
 	"github.com/containers/image/v5/pkg/compression"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 // replacementAttempts is the number of blob replacement candidates with known location returned by destructivelyPrioritizeReplacementCandidates,
@@ -44,13 +44,13 @@ func CandidateCompression(v2Options *blobinfocache.CandidateLocations2Options, d
 		op = types.Decompress
 		algo = nil
 	case blobinfocache.UnknownCompression:
-		logrus.Debugf("Ignoring BlobInfoCache record of digest %q with unknown compression", digest.String())
+		log.Debugf("Ignoring BlobInfoCache record of digest %q with unknown compression", digest.String())
 		return false, types.PreserveOriginal, nil // Not allowed with CandidateLocations2
 	default:
 		op = types.Compress
 		algo_, err := compression.AlgorithmByName(compressorName)
 		if err != nil {
-			logrus.Debugf("Ignoring BlobInfoCache record of digest %q with unrecognized compression %q: %v",
+			log.Debugf("Ignoring BlobInfoCache record of digest %q with unrecognized compression %q: %v",
 				digest.String(), compressorName, err)
 			return false, types.PreserveOriginal, nil // The BICReplacementCandidate2.CompressionAlgorithm field is required
 		}
@@ -64,7 +64,7 @@ func CandidateCompression(v2Options *blobinfocache.CandidateLocations2Options, d
 		if v2Options.RequiredCompression != nil {
 			requiredCompresssion = v2Options.RequiredCompression.Name()
 		}
-		logrus.Debugf("Ignoring BlobInfoCache record of digest %q, compression %q does not match required %s or MIME types %#v",
+		log.Debugf("Ignoring BlobInfoCache record of digest %q, compression %q does not match required %s or MIME types %#v",
 			digest.String(), compressorName, requiredCompresssion, v2Options.PossibleManifestFormats)
 		return false, types.PreserveOriginal, nil
 	}
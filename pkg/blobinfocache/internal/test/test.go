@@ -46,6 +46,7 @@ func GenericCache(t *testing.T, newTestCache func(t *testing.T) blobinfocache.Bl
 		{"RecordKnownLocations", testGenericRecordKnownLocations},
 		{"CandidateLocations", testGenericCandidateLocations},
 		{"CandidateLocations2", testGenericCandidateLocations2},
+		{"CompressionAnnotations", testGenericCompressionAnnotations},
 	}
 
 	// Without Open()/Close()
@@ -536,3 +537,42 @@ func testGenericCandidateLocations2(t *testing.T, cache blobinfocache.BlobInfoCa
 		}, res)
 	}
 }
+
+func testGenericCompressionAnnotations(t *testing.T, cache blobinfocache.BlobInfoCache2) {
+	transport := mocks.NameImageTransport("==BlobInfocache transport mock")
+	scope := types.BICTransportScope{Opaque: "A"}
+	annotations := map[string]string{
+		"io.github.containers.zstd-chunked.manifest-checksum": "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		"io.github.containers.zstd-chunked.manifest-position": "1:2:3:4",
+	}
+	cache.RecordDigestUncompressedPair(digestCompressedA, digestUncompressed)
+	cache.RecordDigestUncompressedPair(digestCompressedB, digestUncompressed)
+	cache.RecordDigestCompressorName(digestCompressedA, compressorNameA)
+	cache.RecordDigestCompressorName(digestCompressedB, compressorNameB)
+	cache.RecordDigestCompressionAnnotations(digestCompressedB, annotations)
+	cache.RecordKnownLocation(transport, scope, digestCompressedA, types.BICLocationReference{Opaque: "A"})
+	cache.RecordKnownLocation(transport, scope, digestCompressedB, types.BICLocationReference{Opaque: "B"})
+
+	res := cache.CandidateLocations2(transport, scope, digestCompressedA, blobinfocache.CandidateLocations2Options{CanSubstitute: true})
+	require.Len(t, res, 2)
+	assert.Equal(t, digestCompressedA, res[0].Digest)
+	assert.Nil(t, res[0].CompressionAnnotations)
+	assert.Equal(t, digestCompressedB, res[1].Digest)
+	assert.Equal(t, annotations, res[1].CompressionAnnotations)
+
+	// The v1 API does not return annotations, but it must not break either.
+	assertCandidatesMatch(t, "", []candidate{{d: digestCompressedA, lr: "A"}, {d: digestCompressedB, lr: "B"}},
+		cache.CandidateLocations(transport, scope, digestCompressedA, true))
+
+	// Annotations are also returned for candidates with an unknown location.
+	res = cache.CandidateLocations2(transport, types.BICTransportScope{Opaque: "other"}, digestCompressedB, blobinfocache.CandidateLocations2Options{})
+	require.Len(t, res, 1)
+	assert.True(t, res[0].UnknownLocation)
+	assert.Equal(t, annotations, res[0].CompressionAnnotations)
+
+	// Recording empty annotations forgets them.
+	cache.RecordDigestCompressionAnnotations(digestCompressedB, nil)
+	res = cache.CandidateLocations2(transport, scope, digestCompressedB, blobinfocache.CandidateLocations2Options{})
+	require.Len(t, res, 1)
+	assert.Nil(t, res[0].CompressionAnnotations)
+}
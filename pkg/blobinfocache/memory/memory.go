@@ -2,15 +2,16 @@
 package memory
 
 import (
+	"maps"
 	"sync"
 	"time"
 
 	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/pkg/blobinfocache/internal/prioritize"
 	"github.com/containers/image/v5/types"
 	digest "github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 // locationKey only exists to make lookup in knownLocations easier.
@@ -24,10 +25,11 @@ type locationKey struct {
 type cache struct {
 	mutex sync.Mutex
 	// The following fields can only be accessed with mutex held.
-	uncompressedDigests   map[digest.Digest]digest.Digest
-	digestsByUncompressed map[digest.Digest]*set.Set[digest.Digest]                // stores a set of digests for each uncompressed digest
-	knownLocations        map[locationKey]map[types.BICLocationReference]time.Time // stores last known existence time for each location reference
-	compressors           map[digest.Digest]string                                 // stores a compressor name, or blobinfocache.Unknown (not blobinfocache.UnknownCompression), for each digest
+	uncompressedDigests    map[digest.Digest]digest.Digest
+	digestsByUncompressed  map[digest.Digest]*set.Set[digest.Digest]                // stores a set of digests for each uncompressed digest
+	knownLocations         map[locationKey]map[types.BICLocationReference]time.Time // stores last known existence time for each location reference
+	compressors            map[digest.Digest]string                                 // stores a compressor name, or blobinfocache.Unknown (not blobinfocache.UnknownCompression), for each digest
+	compressionAnnotations map[digest.Digest]map[string]string                      // stores annotations required to use the compression variant of each digest, if any
 }
 
 // New returns a BlobInfoCache implementation which is in-memory only.
@@ -44,10 +46,11 @@ func New() types.BlobInfoCache {
 
 func new2() *cache {
 	return &cache{
-		uncompressedDigests:   map[digest.Digest]digest.Digest{},
-		digestsByUncompressed: map[digest.Digest]*set.Set[digest.Digest]{},
-		knownLocations:        map[locationKey]map[types.BICLocationReference]time.Time{},
-		compressors:           map[digest.Digest]string{},
+		uncompressedDigests:    map[digest.Digest]digest.Digest{},
+		digestsByUncompressed:  map[digest.Digest]*set.Set[digest.Digest]{},
+		knownLocations:         map[locationKey]map[types.BICLocationReference]time.Time{},
+		compressors:            map[digest.Digest]string{},
+		compressionAnnotations: map[digest.Digest]map[string]string{},
 	}
 }
 
@@ -92,7 +95,7 @@ func (mem *cache) RecordDigestUncompressedPair(anyDigest digest.Digest, uncompre
 	mem.mutex.Lock()
 	defer mem.mutex.Unlock()
 	if previous, ok := mem.uncompressedDigests[anyDigest]; ok && previous != uncompressed {
-		logrus.Warnf("Uncompressed digest for blob %s previously recorded as %s, now %s", anyDigest, previous, uncompressed)
+		log.Warnf("Uncompressed digest for blob %s previously recorded as %s, now %s", anyDigest, previous, uncompressed)
 	}
 	mem.uncompressedDigests[anyDigest] = uncompressed
 
@@ -124,7 +127,7 @@ func (mem *cache) RecordDigestCompressorName(blobDigest digest.Digest, compresso
 	mem.mutex.Lock()
 	defer mem.mutex.Unlock()
 	if previous, ok := mem.compressors[blobDigest]; ok && previous != compressorName {
-		logrus.Warnf("Compressor for blob with digest %s previously recorded as %s, now %s", blobDigest, previous, compressorName)
+		log.Warnf("Compressor for blob with digest %s previously recorded as %s, now %s", blobDigest, previous, compressorName)
 	}
 	if compressorName == blobinfocache.UnknownCompression {
 		delete(mem.compressors, blobDigest)
@@ -133,6 +136,18 @@ func (mem *cache) RecordDigestCompressorName(blobDigest digest.Digest, compresso
 	mem.compressors[blobDigest] = compressorName
 }
 
+// RecordDigestCompressionAnnotations records annotations which must be set on a layer using the blob with the specified digest
+// to use its specific compression variant, or forgets them if annotations is empty.
+func (mem *cache) RecordDigestCompressionAnnotations(blobDigest digest.Digest, annotations map[string]string) {
+	mem.mutex.Lock()
+	defer mem.mutex.Unlock()
+	if len(annotations) == 0 {
+		delete(mem.compressionAnnotations, blobDigest)
+		return
+	}
+	mem.compressionAnnotations[blobDigest] = maps.Clone(annotations)
+}
+
 // appendReplacementCandidates creates prioritize.CandidateWithTime values for digest in memory
 // with corresponding compression info from mem.compressors, and returns the result of appending
 // them to candidates.
@@ -148,15 +163,20 @@ func (mem *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 	if !ok {
 		return candidates
 	}
+	var annotations map[string]string // = nil
+	if v2Options != nil {
+		annotations = maps.Clone(mem.compressionAnnotations[digest]) // nil if not present
+	}
 	locations := mem.knownLocations[locationKey{transport: transport.Name(), scope: scope, blobDigest: digest}] // nil if not present
 	if len(locations) > 0 {
 		for l, t := range locations {
 			candidates = append(candidates, prioritize.CandidateWithTime{
 				Candidate: blobinfocache.BICReplacementCandidate2{
-					Digest:               digest,
-					CompressionOperation: compressionOp,
-					CompressionAlgorithm: compressionAlgo,
-					Location:             l,
+					Digest:                 digest,
+					CompressionOperation:   compressionOp,
+					CompressionAlgorithm:   compressionAlgo,
+					Location:               l,
+					CompressionAnnotations: annotations,
 				},
 				LastSeen: t,
 			})
@@ -164,11 +184,12 @@ func (mem *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 	} else if v2Options != nil {
 		candidates = append(candidates, prioritize.CandidateWithTime{
 			Candidate: blobinfocache.BICReplacementCandidate2{
-				Digest:               digest,
-				CompressionOperation: compressionOp,
-				CompressionAlgorithm: compressionAlgo,
-				UnknownLocation:      true,
-				Location:             types.BICLocationReference{Opaque: ""},
+				Digest:                 digest,
+				CompressionOperation:   compressionOp,
+				CompressionAlgorithm:   compressionAlgo,
+				UnknownLocation:        true,
+				Location:               types.BICLocationReference{Opaque: ""},
+				CompressionAnnotations: annotations,
 			},
 			LastSeen: time.Time{},
 		})
@@ -3,17 +3,18 @@ package sqlite
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/pkg/blobinfocache/internal/prioritize"
 	"github.com/containers/image/v5/types"
 	_ "github.com/mattn/go-sqlite3" // Registers the "sqlite3" backend backend for database/sql
 	"github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -117,7 +118,7 @@ func (sqc *cache) Open() {
 	if sqc.refCount == 0 {
 		db, err := rawOpen(sqc.path)
 		if err != nil {
-			logrus.Warnf("Error opening (previously-successfully-opened) blob info cache at %q: %v", sqc.path, err)
+			log.Warnf("Error opening (previously-successfully-opened) blob info cache at %q: %v", sqc.path, err)
 			db = nil // But still increase sqc.refCount, because a .Close() will happen
 		}
 		sqc.db = db
@@ -132,7 +133,7 @@ func (sqc *cache) Close() {
 
 	switch sqc.refCount {
 	case 0:
-		logrus.Errorf("internal error using pkg/blobinfocache/sqlite.cache: Close() without a matching Open()")
+		log.Errorf("internal error using pkg/blobinfocache/sqlite.cache: Close() without a matching Open()")
 		return
 	case 1:
 		if sqc.db != nil {
@@ -183,7 +184,7 @@ func dbTransaction[T any](db *sql.DB, fn func(tx *sql.Tx) (T, error)) (T, error)
 	defer func() {
 		if !succeeded {
 			if err := tx.Rollback(); err != nil {
-				logrus.Errorf("Rolling back transaction: %v", err)
+				log.Errorf("Rolling back transaction: %v", err)
 			}
 		}
 	}()
@@ -295,6 +296,17 @@ func ensureDBHasCurrentSchema(db *sql.DB) error {
 				`PRIMARY KEY (transport, scope, digest, location)
 			)`,
 		},
+		{
+			// Added after the items above; existing databases don’t have it, so it must remain the last item,
+			// for the “last item exists” check below to create it.
+			"DigestCompressionAnnotations",
+			`CREATE TABLE IF NOT EXISTS DigestCompressionAnnotations(` +
+				// index implied by PRIMARY KEY
+				`digest			TEXT PRIMARY KEY NOT NULL,` +
+				// A JSON-encoded map[string]string.
+				`annotations	TEXT NOT NULL
+			)`,
+		},
 	}
 
 	_, err := dbTransaction(db, func(tx *sql.Tx) (void, error) {
@@ -305,7 +317,8 @@ func ensureDBHasCurrentSchema(db *sql.DB) error {
 			return void{}, fmt.Errorf("checking if SQLite schema item %q exists: %w", lastItemName, err)
 		}
 		if !found {
-			// Item does not exist, assuming a fresh database.
+			// Item does not exist, assuming a fresh database, or one created before that item was added.
+			// All commands use IF NOT EXISTS, so re-running them for the existing items is harmless.
 			for _, i := range items {
 				if _, err := tx.Exec(i.command); err != nil {
 					return void{}, fmt.Errorf("creating item %s: %w", i.itemName, err)
@@ -374,7 +387,7 @@ func (sqc *cache) RecordDigestUncompressedPair(anyDigest digest.Digest, uncompre
 				return void{}, err
 			}
 			if previous != uncompressed {
-				logrus.Warnf("Uncompressed digest for blob %s previously recorded as %s, now %s", anyDigest, previous, uncompressed)
+				log.Warnf("Uncompressed digest for blob %s previously recorded as %s, now %s", anyDigest, previous, uncompressed)
 			}
 		}
 		if _, err := tx.Exec("INSERT OR REPLACE INTO DigestUncompressedPairs(anyDigest, uncompressedDigest) VALUES (?, ?)",
@@ -411,7 +424,7 @@ func (sqc *cache) RecordDigestCompressorName(anyDigest digest.Digest, compressor
 			return void{}, fmt.Errorf("looking for compressor of for %q", anyDigest)
 		}
 		if gotPrevious && previous != compressorName {
-			logrus.Warnf("Compressor for blob with digest %s previously recorded as %s, now %s", anyDigest, previous, compressorName)
+			log.Warnf("Compressor for blob with digest %s previously recorded as %s, now %s", anyDigest, previous, compressorName)
 		}
 		if compressorName == blobinfocache.UnknownCompression {
 			if _, err := tx.Exec("DELETE FROM DigestCompressors WHERE digest = ?", anyDigest.String()); err != nil {
@@ -427,6 +440,29 @@ func (sqc *cache) RecordDigestCompressorName(anyDigest digest.Digest, compressor
 	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
 }
 
+// RecordDigestCompressionAnnotations records annotations which must be set on a layer using the blob with the specified digest
+// to use its specific compression variant, or forgets them if annotations is empty.
+// WARNING: Only call this with LOCALLY VERIFIED data; don’t record annotations just because some remote author claims so.
+func (sqc *cache) RecordDigestCompressionAnnotations(anyDigest digest.Digest, annotations map[string]string) {
+	_, _ = transaction(sqc, func(tx *sql.Tx) (void, error) {
+		if len(annotations) == 0 {
+			if _, err := tx.Exec("DELETE FROM DigestCompressionAnnotations WHERE digest = ?", anyDigest.String()); err != nil {
+				return void{}, fmt.Errorf("deleting compression annotations for digest %q: %w", anyDigest, err)
+			}
+			return void{}, nil
+		}
+		value, err := json.Marshal(annotations)
+		if err != nil {
+			return void{}, fmt.Errorf("encoding compression annotations for %q: %w", anyDigest, err)
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO DigestCompressionAnnotations(digest, annotations) VALUES (?, ?)",
+			anyDigest.String(), string(value)); err != nil {
+			return void{}, fmt.Errorf("recording compression annotations for %q: %w", anyDigest, err)
+		}
+		return void{}, nil
+	}) // FIXME? Log error (but throttle the log volume on repeated accesses)?
+}
+
 // appendReplacementCandidates creates prioritize.CandidateWithTime values for (transport, scope, digest),
 // and returns the result of appending them to candidates.
 // v2Options is not nil if the caller is CandidateLocations2: this allows including candidates with unknown location, and filters out candidates
@@ -448,6 +484,20 @@ func (sqc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 		return candidates, nil
 	}
 
+	var annotations map[string]string // = nil
+	if v2Options != nil {
+		value, found, err := querySingleValue[string](tx, "SELECT annotations FROM DigestCompressionAnnotations WHERE digest = ?", digest.String())
+		if err != nil {
+			return nil, fmt.Errorf("scanning compression annotations: %w", err)
+		}
+		if found {
+			if err := json.Unmarshal([]byte(value), &annotations); err != nil {
+				log.Debugf("Ignoring invalid BlobInfoCache compression annotations for digest %q: %v", digest.String(), err)
+				annotations = nil
+			}
+		}
+	}
+
 	rows, err := tx.Query("SELECT location, time FROM KnownLocations "+
 		"WHERE transport = ? AND scope = ? AND KnownLocations.digest = ?",
 		transport.Name(), scope.Opaque, digest.String())
@@ -465,10 +515,11 @@ func (sqc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 		}
 		candidates = append(candidates, prioritize.CandidateWithTime{
 			Candidate: blobinfocache.BICReplacementCandidate2{
-				Digest:               digest,
-				CompressionOperation: compressionOp,
-				CompressionAlgorithm: compressionAlgo,
-				Location:             types.BICLocationReference{Opaque: location},
+				Digest:                 digest,
+				CompressionOperation:   compressionOp,
+				CompressionAlgorithm:   compressionAlgo,
+				Location:               types.BICLocationReference{Opaque: location},
+				CompressionAnnotations: annotations,
 			},
 			LastSeen: time,
 		})
@@ -481,11 +532,12 @@ func (sqc *cache) appendReplacementCandidates(candidates []prioritize.CandidateW
 	if !rowAdded && v2Options != nil {
 		candidates = append(candidates, prioritize.CandidateWithTime{
 			Candidate: blobinfocache.BICReplacementCandidate2{
-				Digest:               digest,
-				CompressionOperation: compressionOp,
-				CompressionAlgorithm: compressionAlgo,
-				UnknownLocation:      true,
-				Location:             types.BICLocationReference{Opaque: ""},
+				Digest:                 digest,
+				CompressionOperation:   compressionOp,
+				CompressionAlgorithm:   compressionAlgo,
+				UnknownLocation:        true,
+				Location:               types.BICLocationReference{Opaque: ""},
+				CompressionAnnotations: annotations,
 			},
 			LastSeen: time.Time{},
 		})
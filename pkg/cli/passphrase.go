@@ -8,7 +8,7 @@ import (
 	"os"
 	"strings"
 
-	"github.com/sirupsen/logrus"
+	"github.com/containers/image/v5/internal/log"
 )
 
 // ReadPassphraseFile returns the first line of the specified path.
@@ -18,7 +18,7 @@ func ReadPassphraseFile(path string) (string, error) {
 		return "", nil
 	}
 
-	logrus.Debugf("Reading user-specified passphrase for signing from %s", path)
+	log.Debugf("Reading user-specified passphrase for signing from %s", path)
 
 	ppf, err := os.Open(path)
 	if err != nil {
@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/pkg/compression/internal"
 	"github.com/containers/image/v5/pkg/compression/types"
 	"github.com/containers/storage/pkg/chunked/compressor"
 	"github.com/klauspost/pgzip"
-	"github.com/sirupsen/logrus"
 	"github.com/ulikunitz/xz"
 )
 
@@ -123,14 +123,14 @@ func DetectCompressionFormat(input io.Reader) (Algorithm, DecompressorFunc, io.R
 	for _, algo := range compressionAlgorithms {
 		prefix := internal.AlgorithmPrefix(algo)
 		if len(prefix) > 0 && bytes.HasPrefix(buffer[:n], prefix) {
-			logrus.Debugf("Detected compression format %s", algo.Name())
+			log.Debugf("Detected compression format %s", algo.Name())
 			retAlgo = algo
 			decompressor = internal.AlgorithmDecompressor(algo)
 			break
 		}
 	}
 	if decompressor == nil {
-		logrus.Debugf("No compression detected")
+		log.Debugf("No compression detected")
 	}
 
 	return retAlgo, decompressor, io.MultiReader(bytes.NewReader(buffer[:n]), input), nil
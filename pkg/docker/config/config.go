@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -10,9 +11,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/cloudauth"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/multierr"
 	"github.com/containers/image/v5/internal/set"
 	"github.com/containers/image/v5/pkg/sysregistriesv2"
@@ -22,7 +26,6 @@ import (
 	"github.com/containers/storage/pkg/ioutils"
 	helperclient "github.com/docker/docker-credential-helpers/client"
 	"github.com/docker/docker-credential-helpers/credentials"
-	"github.com/sirupsen/logrus"
 )
 
 type dockerAuthConfig struct {
@@ -56,6 +59,7 @@ var (
 type authPath struct {
 	path         string
 	legacyFormat bool
+	envVar       string // The environment variable which determined path, if any; only set by getAuthFilePaths
 }
 
 // newAuthPathDefault constructs an authPath in non-legacy format.
@@ -66,6 +70,97 @@ func newAuthPathDefault(path string) authPath {
 // GetAllCredentials returns the registry credentials for all registries stored
 // in any of the configured credential helpers.
 func GetAllCredentials(sys *types.SystemContext) (map[string]types.DockerAuthConfig, error) {
+	allKeys, err := allCredentialKeys(sys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Now use `GetCredentials` to the specific auth configs for each
+	// previously listed registry.
+	allCreds := make(map[string]types.DockerAuthConfig)
+	for _, key := range allKeys {
+		creds, err := GetCredentials(sys, key)
+		if err != nil {
+			// Note: we rely on the logging in `GetCredentials`.
+			return nil, err
+		}
+		if creds != (types.DockerAuthConfig{}) {
+			allCreds[key] = creds
+		}
+	}
+
+	return allCreds, nil
+}
+
+// CredentialSourceKind identifies the kind of location credentials were found in.
+type CredentialSourceKind string
+
+const (
+	// CredentialSourceSystemContext means the credentials were set in types.SystemContext.DockerAuthConfig.
+	CredentialSourceSystemContext CredentialSourceKind = "system-context"
+	// CredentialSourceAuthFile means the credentials are stored in an auth file (auth.json or Docker’s config.json).
+	CredentialSourceAuthFile CredentialSourceKind = "auth-file"
+	// CredentialSourceHelper means the credentials were returned by a credential helper.
+	CredentialSourceHelper CredentialSourceKind = "credential-helper"
+	// CredentialSourceCloud means the credentials are ambient cloud credentials (see types.SystemContext.DockerEnableCloudCredentials).
+	CredentialSourceCloud CredentialSourceKind = "cloud"
+)
+
+// CredentialSource describes where credentials were found.
+type CredentialSource struct {
+	Kind CredentialSourceKind
+	// Path is the auth file containing the credentials, for CredentialSourceAuthFile.
+	// For CredentialSourceHelper, it is the auth file with a "credHelpers" entry which selected Helper,
+	// or "" if Helper is configured in registries.conf.
+	Path string
+	// EnvironmentVariable is the environment variable which determined Path (e.g. "DOCKER_CONFIG"), if any.
+	EnvironmentVariable string
+	Helper              string // The name of the credential helper, for CredentialSourceHelper
+}
+
+// RegistryCredentialSource describes the credentials for a single key, as returned by ListCredentialSources.
+type RegistryCredentialSource struct {
+	Key      string // A repository, a namespace within a registry, or a registry hostname, as accepted by GetCredentials
+	Username string // "" if the credentials only consist of an identity token
+	Source   CredentialSource
+}
+
+// ListCredentialSources returns every key for which credentials are stored in any of the configured credential helpers
+// (i.e. the keys returned by GetAllCredentials), sorted by key, along with where GetCredentials finds the credentials for that key.
+// This is intended for tools reporting the login status and debugging credential precedence; secrets are not returned.
+func ListCredentialSources(sys *types.SystemContext) ([]RegistryCredentialSource, error) {
+	return listCredentialSourcesWithHomeDir(sys, homedir.Get())
+}
+
+// listCredentialSourcesWithHomeDir is an internal implementation detail of ListCredentialSources.
+// It exists only to allow testing it with an artificial home directory.
+func listCredentialSourcesWithHomeDir(sys *types.SystemContext, homeDir string) ([]RegistryCredentialSource, error) {
+	allKeys, err := allCredentialKeysWithHomeDir(sys, homeDir)
+	if err != nil {
+		return nil, err
+	}
+	res := []RegistryCredentialSource{}
+	for _, key := range allKeys {
+		creds, source, err := getCredentialsAndSourceWithHomeDir(context.Background(), sys, key, homeDir)
+		if err != nil {
+			return nil, err
+		}
+		if creds != (types.DockerAuthConfig{}) {
+			res = append(res, RegistryCredentialSource{Key: key, Username: creds.Username, Source: source})
+		}
+	}
+	slices.SortFunc(res, func(a, b RegistryCredentialSource) int { return strings.Compare(a.Key, b.Key) })
+	return res, nil
+}
+
+// allCredentialKeys returns all keys for which credentials are stored in any of the configured credential helpers.
+func allCredentialKeys(sys *types.SystemContext) ([]string, error) {
+	return allCredentialKeysWithHomeDir(sys, homedir.Get())
+}
+
+// allCredentialKeysWithHomeDir is an internal implementation detail of allCredentialKeys.
+// It exists only to allow testing it with an artificial home directory.
+func allCredentialKeysWithHomeDir(sys *types.SystemContext, homeDir string) ([]string, error) {
 	// To keep things simple, let's first extract all registries from all
 	// possible sources, and then call `GetCredentials` on them.  That
 	// prevents us from having to reverse engineer the logic in
@@ -84,7 +179,7 @@ func GetAllCredentials(sys *types.SystemContext) (map[string]types.DockerAuthCon
 		switch helper {
 		// Special-case the built-in helper for auth files.
 		case sysregistriesv2.AuthenticationFileHelper:
-			for _, path := range getAuthFilePaths(sys, homedir.Get()) {
+			for _, path := range getAuthFilePaths(sys, homeDir) {
 				// parse returns an empty map in case the path doesn't exist.
 				fileContents, err := path.parse()
 				if err != nil {
@@ -108,7 +203,7 @@ func GetAllCredentials(sys *types.SystemContext) (map[string]types.DockerAuthCon
 		default:
 			creds, err := listCredsInCredHelper(helper)
 			if err != nil {
-				logrus.Debugf("Error listing credentials stored in credential helper %s: %v", helper, err)
+				log.Debugf("Error listing credentials stored in credential helper %s: %v", helper, err)
 				if errors.Is(err, exec.ErrNotFound) {
 					creds = nil // It's okay if the helper doesn't exist.
 				} else {
@@ -120,22 +215,7 @@ func GetAllCredentials(sys *types.SystemContext) (map[string]types.DockerAuthCon
 			}
 		}
 	}
-
-	// Now use `GetCredentials` to the specific auth configs for each
-	// previously listed registry.
-	allCreds := make(map[string]types.DockerAuthConfig)
-	for _, key := range allKeys.Values() {
-		creds, err := GetCredentials(sys, key)
-		if err != nil {
-			// Note: we rely on the logging in `GetCredentials`.
-			return nil, err
-		}
-		if creds != (types.DockerAuthConfig{}) {
-			allCreds[key] = creds
-		}
-	}
-
-	return allCreds, nil
+	return allKeys.Values(), nil
 }
 
 // getAuthFilePaths returns a slice of authPaths based on the system context
@@ -146,21 +226,27 @@ func getAuthFilePaths(sys *types.SystemContext, homeDir string) []authPath {
 	paths := []authPath{}
 	pathToAuth, userSpecifiedPath, err := getPathToAuth(sys)
 	if err == nil {
+		if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); !userSpecifiedPath && runtimeDir != "" &&
+			pathToAuth.path == filepath.Join(runtimeDir, xdgRuntimeDirPath) {
+			pathToAuth.envVar = "XDG_RUNTIME_DIR"
+		}
 		paths = append(paths, pathToAuth)
 	} else {
 		// Error means that the path set for XDG_RUNTIME_DIR does not exist
 		// but we don't want to completely fail in the case that the user is pulling a public image
 		// Logging the error as a warning instead and moving on to pulling the image
-		logrus.Warnf("%v: Trying to pull image in the event that it is a public image.", err)
+		log.Warnf("%v: Trying to pull image in the event that it is a public image.", err)
 	}
 	if !userSpecifiedPath {
 		xdgCfgHome := os.Getenv("XDG_CONFIG_HOME")
+		xdgCfgHomeEnvVar := "XDG_CONFIG_HOME"
 		if xdgCfgHome == "" {
 			xdgCfgHome = filepath.Join(homeDir, ".config")
+			xdgCfgHomeEnvVar = ""
 		}
-		paths = append(paths, newAuthPathDefault(filepath.Join(xdgCfgHome, xdgConfigHomePath)))
+		paths = append(paths, authPath{path: filepath.Join(xdgCfgHome, xdgConfigHomePath), envVar: xdgCfgHomeEnvVar})
 		if dockerConfig := os.Getenv("DOCKER_CONFIG"); dockerConfig != "" {
-			paths = append(paths, newAuthPathDefault(filepath.Join(dockerConfig, "config.json")))
+			paths = append(paths, authPath{path: filepath.Join(dockerConfig, "config.json"), envVar: "DOCKER_CONFIG"})
 		} else {
 			paths = append(paths,
 				newAuthPathDefault(filepath.Join(homeDir, dockerHomePath)),
@@ -180,7 +266,13 @@ func getAuthFilePaths(sys *types.SystemContext, homeDir string) []authPath {
 //
 // GetCredentialsForRef should almost always be used in favor of this API.
 func GetCredentials(sys *types.SystemContext, key string) (types.DockerAuthConfig, error) {
-	return getCredentialsWithHomeDir(sys, key, homedir.Get())
+	return getCredentialsWithHomeDir(context.Background(), sys, key, homedir.Get())
+}
+
+// GetCredentialsWithContext is GetCredentials, using ctx for network requests
+// (used if sys.DockerEnableCloudCredentials is set).
+func GetCredentialsWithContext(ctx context.Context, sys *types.SystemContext, key string) (types.DockerAuthConfig, error) {
+	return getCredentialsWithHomeDir(ctx, sys, key, homedir.Get())
 }
 
 // GetCredentialsForRef returns the registry credentials necessary for
@@ -188,21 +280,34 @@ func GetCredentials(sys *types.SystemContext, key string) (types.DockerAuthConfi
 // appropriate for sys and the users’ configuration.
 // If an entry is not found, an empty struct is returned.
 func GetCredentialsForRef(sys *types.SystemContext, ref reference.Named) (types.DockerAuthConfig, error) {
-	return getCredentialsWithHomeDir(sys, ref.Name(), homedir.Get())
+	return getCredentialsWithHomeDir(context.Background(), sys, ref.Name(), homedir.Get())
+}
+
+// GetCredentialsForRefWithContext is GetCredentialsForRef, using ctx for network requests
+// (used if sys.DockerEnableCloudCredentials is set).
+func GetCredentialsForRefWithContext(ctx context.Context, sys *types.SystemContext, ref reference.Named) (types.DockerAuthConfig, error) {
+	return getCredentialsWithHomeDir(ctx, sys, ref.Name(), homedir.Get())
 }
 
 // getCredentialsWithHomeDir is an internal implementation detail of
 // GetCredentialsForRef and GetCredentials. It exists only to allow testing it
 // with an artificial home directory.
-func getCredentialsWithHomeDir(sys *types.SystemContext, key, homeDir string) (types.DockerAuthConfig, error) {
+func getCredentialsWithHomeDir(ctx context.Context, sys *types.SystemContext, key, homeDir string) (types.DockerAuthConfig, error) {
+	creds, _, err := getCredentialsAndSourceWithHomeDir(ctx, sys, key, homeDir)
+	return creds, err
+}
+
+// getCredentialsAndSourceWithHomeDir is getCredentialsWithHomeDir, also returning where the credentials were found.
+// The returned CredentialSource is meaningless if no credentials were found.
+func getCredentialsAndSourceWithHomeDir(ctx context.Context, sys *types.SystemContext, key, homeDir string) (types.DockerAuthConfig, CredentialSource, error) {
 	_, err := validateKey(key)
 	if err != nil {
-		return types.DockerAuthConfig{}, err
+		return types.DockerAuthConfig{}, CredentialSource{}, err
 	}
 
 	if sys != nil && sys.DockerAuthConfig != nil {
-		logrus.Debugf("Returning credentials for %s from DockerAuthConfig", key)
-		return *sys.DockerAuthConfig, nil
+		log.Debugf("Returning credentials for %s from DockerAuthConfig", key)
+		return *sys.DockerAuthConfig, CredentialSource{Kind: CredentialSourceSystemContext}, nil
 	}
 
 	var registry string // We compute this once because it is used in several places.
@@ -213,65 +318,81 @@ func getCredentialsWithHomeDir(sys *types.SystemContext, key, homeDir string) (t
 	}
 
 	// Anonymous function to query credentials from auth files.
-	getCredentialsFromAuthFiles := func() (types.DockerAuthConfig, string, error) {
+	getCredentialsFromAuthFiles := func() (types.DockerAuthConfig, CredentialSource, error) {
 		for _, path := range getAuthFilePaths(sys, homeDir) {
-			creds, err := findCredentialsInFile(key, registry, path)
+			creds, fileHelper, err := findCredentialsInFile(key, registry, path)
 			if err != nil {
-				return types.DockerAuthConfig{}, "", err
+				return types.DockerAuthConfig{}, CredentialSource{}, err
 			}
 
 			if creds != (types.DockerAuthConfig{}) {
-				return creds, path.path, nil
+				source := CredentialSource{Kind: CredentialSourceAuthFile, Path: path.path, EnvironmentVariable: path.envVar}
+				if fileHelper != "" {
+					source.Kind = CredentialSourceHelper
+					source.Helper = fileHelper
+				}
+				return creds, source, nil
 			}
 		}
-		return types.DockerAuthConfig{}, "", nil
+		return types.DockerAuthConfig{}, CredentialSource{}, nil
 	}
 
 	helpers, err := sysregistriesv2.CredentialHelpers(sys)
 	if err != nil {
-		return types.DockerAuthConfig{}, err
+		return types.DockerAuthConfig{}, CredentialSource{}, err
 	}
 
 	var multiErr []error
 	for _, helper := range helpers {
 		var (
-			creds          types.DockerAuthConfig
-			helperKey      string
-			credHelperPath string
-			err            error
+			creds     types.DockerAuthConfig
+			helperKey string
+			source    CredentialSource
+			err       error
 		)
 		switch helper {
 		// Special-case the built-in helper for auth files.
 		case sysregistriesv2.AuthenticationFileHelper:
 			helperKey = key
-			creds, credHelperPath, err = getCredentialsFromAuthFiles()
+			creds, source, err = getCredentialsFromAuthFiles()
 		// External helpers.
 		default:
 			// This intentionally uses "registry", not "key"; we don't support namespaced
 			// credentials in helpers, but a "registry" is a valid parent of "key".
 			helperKey = registry
 			creds, err = getCredsFromCredHelper(helper, registry)
+			source = CredentialSource{Kind: CredentialSourceHelper, Helper: helper}
 		}
 		if err != nil {
-			logrus.Debugf("Error looking up credentials for %s in credential helper %s: %v", helperKey, helper, err)
+			log.Debugf("Error looking up credentials for %s in credential helper %s: %v", helperKey, helper, err)
 			multiErr = append(multiErr, err)
 			continue
 		}
 		if creds != (types.DockerAuthConfig{}) {
 			msg := fmt.Sprintf("Found credentials for %s in credential helper %s", helperKey, helper)
-			if credHelperPath != "" {
-				msg = fmt.Sprintf("%s in file %s", msg, credHelperPath)
+			if source.Path != "" {
+				msg = fmt.Sprintf("%s in file %s", msg, source.Path)
 			}
-			logrus.Debug(msg)
-			return creds, nil
+			log.Debugf("%s", msg)
+			return creds, source, nil
+		}
+	}
+	if sys != nil && sys.DockerEnableCloudCredentials {
+		creds, err := cloudauth.GetCredentials(ctx, registry, homeDir)
+		if err != nil {
+			log.Debugf("Error obtaining cloud credentials for %s: %v", registry, err)
+			multiErr = append(multiErr, err)
+		} else if creds != (types.DockerAuthConfig{}) {
+			log.Debugf("Using ambient cloud credentials for %s", registry)
+			return creds, CredentialSource{Kind: CredentialSourceCloud}, nil
 		}
 	}
 	if multiErr != nil {
-		return types.DockerAuthConfig{}, multierr.Format("errors looking up credentials:\n\t* ", "\nt* ", "\n", multiErr)
+		return types.DockerAuthConfig{}, CredentialSource{}, multierr.Format("errors looking up credentials:\n\t* ", "\nt* ", "\n", multiErr)
 	}
 
-	logrus.Debugf("No credentials for %s found", key)
-	return types.DockerAuthConfig{}, nil
+	log.Debugf("No credentials for %s found", key)
+	return types.DockerAuthConfig{}, CredentialSource{}, nil
 }
 
 // GetAuthentication returns the registry credentials matching key, appropriate for
@@ -290,7 +411,7 @@ func GetAuthentication(sys *types.SystemContext, key string) (string, string, er
 // getAuthenticationWithHomeDir is an internal implementation detail of GetAuthentication,
 // it exists only to allow testing it with an artificial home directory.
 func getAuthenticationWithHomeDir(sys *types.SystemContext, key, homeDir string) (string, string, error) {
-	creds, err := getCredentialsWithHomeDir(sys, key, homeDir)
+	creds, err := getCredentialsWithHomeDir(context.Background(), sys, key, homeDir)
 	if err != nil {
 		return "", "", err
 	}
@@ -347,10 +468,10 @@ func SetCredentials(sys *types.SystemContext, key, username, password string) (s
 		}
 		if err != nil {
 			multiErr = append(multiErr, err)
-			logrus.Debugf("Error storing credentials for %s in credential helper %s: %v", key, helper, err)
+			log.Debugf("Error storing credentials for %s in credential helper %s: %v", key, helper, err)
 			continue
 		}
-		logrus.Debugf("Stored credentials for %s in credential helper %s", key, helper)
+		log.Debugf("Stored credentials for %s in credential helper %s", key, helper)
 		return desc, nil
 	}
 	return "", multierr.Format("Errors storing credentials\n\t* ", "\n\t* ", "\n", multiErr)
@@ -381,17 +502,17 @@ func RemoveAuthentication(sys *types.SystemContext, key string) error {
 
 	removeFromCredHelper := func(helper string) error {
 		if isNamespaced {
-			logrus.Debugf("Not removing credentials because namespaced keys are not supported for the credential helper: %s", helper)
+			log.Debugf("Not removing credentials because namespaced keys are not supported for the credential helper: %s", helper)
 			return nil
 		}
 		err := deleteCredsFromCredHelper(helper, key)
 		if err == nil {
-			logrus.Debugf("Credentials for %q were deleted from credential helper %s", key, helper)
+			log.Debugf("Credentials for %q were deleted from credential helper %s", key, helper)
 			isLoggedIn = true
 			return nil
 		}
 		if credentials.IsErrCredentialsNotFoundMessage(err.Error()) {
-			logrus.Debugf("Not logged in to %s with credential helper %s", key, helper)
+			log.Debugf("Not logged in to %s with credential helper %s", key, helper)
 			return nil
 		}
 		return fmt.Errorf("removing credentials for %s from credential helper %s: %w", key, helper, err)
@@ -482,11 +603,11 @@ func RemoveAllAuthentication(sys *types.SystemContext) error {
 			}
 		}
 		if err != nil {
-			logrus.Debugf("Error removing credentials from credential helper %s: %v", helper, err)
+			log.Debugf("Error removing credentials from credential helper %s: %v", helper, err)
 			multiErr = append(multiErr, err)
 			continue
 		}
-		logrus.Debugf("All credentials removed from credential helper %s", helper)
+		log.Debugf("All credentials removed from credential helper %s", helper)
 	}
 
 	if multiErr != nil {
@@ -758,7 +879,7 @@ func getCredsFromCredHelper(credHelper, registry string) (types.DockerAuthConfig
 	creds, err := helperclient.Get(p, registry)
 	if err != nil {
 		if credentials.IsErrCredentialsNotFoundMessage(err.Error()) {
-			logrus.Debugf("Not logged in to %s with credential helper %s", registry, credHelper)
+			log.Debugf("Not logged in to %s with credential helper %s", registry, credHelper)
 			err = nil
 		}
 		return types.DockerAuthConfig{}, err
@@ -801,18 +922,20 @@ func deleteCredsFromCredHelper(credHelper, registry string) error {
 
 // findCredentialsInFile looks for credentials matching "key"
 // (which is "registry" or a namespace in "registry") in "path".
-func findCredentialsInFile(key, registry string, path authPath) (types.DockerAuthConfig, error) {
+// If a "credHelpers" entry in path applies, it also returns the name of that credential helper.
+func findCredentialsInFile(key, registry string, path authPath) (types.DockerAuthConfig, string, error) {
 	fileContents, err := path.parse()
 	if err != nil {
-		return types.DockerAuthConfig{}, fmt.Errorf("reading JSON file %q: %w", path.path, err)
+		return types.DockerAuthConfig{}, "", fmt.Errorf("reading JSON file %q: %w", path.path, err)
 	}
 
 	// First try cred helpers. They should always be normalized.
 	// This intentionally uses "registry", not "key"; we don't support namespaced
 	// credentials in helpers.
 	if ch, exists := fileContents.CredHelpers[registry]; exists {
-		logrus.Debugf("Looking up in credential helper %s based on credHelpers entry in %s", ch, path.path)
-		return getCredsFromCredHelper(ch, registry)
+		log.Debugf("Looking up in credential helper %s based on credHelpers entry in %s", ch, path.path)
+		creds, err := getCredsFromCredHelper(ch, registry)
+		return creds, ch, err
 	}
 
 	// Support sub-registry namespaces in auth.
@@ -829,7 +952,8 @@ func findCredentialsInFile(key, registry string, path authPath) (types.DockerAut
 	// keys we prefer exact matches as well.
 	for _, key := range keys {
 		if val, exists := fileContents.AuthConfigs[key]; exists {
-			return decodeDockerAuth(path.path, key, val)
+			creds, err := decodeDockerAuth(path.path, key, val)
+			return creds, "", err
 		}
 	}
 
@@ -844,14 +968,15 @@ func findCredentialsInFile(key, registry string, path authPath) (types.DockerAut
 	registry = normalizeRegistry(registry)
 	for k, v := range fileContents.AuthConfigs {
 		if normalizeAuthFileKey(k, path.legacyFormat) == registry {
-			return decodeDockerAuth(path.path, k, v)
+			creds, err := decodeDockerAuth(path.path, k, v)
+			return creds, "", err
 		}
 	}
 
 	// Only log this if we found nothing; getCredentialsWithHomeDir logs the
 	// source of found data.
-	logrus.Debugf("No credentials matching %s found in %s", key, path.path)
-	return types.DockerAuthConfig{}, nil
+	log.Debugf("No credentials matching %s found in %s", key, path.path)
+	return types.DockerAuthConfig{}, "", nil
 }
 
 // authKeysForKey returns the keys matching a provided auth file key, in order
@@ -887,9 +1012,9 @@ func decodeDockerAuth(path, key string, conf dockerAuthConfig) (types.DockerAuth
 	if !valid {
 		// if it's invalid just skip, as docker does
 		if len(decoded) > 0 { // Docker writes "auths": { "$host": {} } entries if a credential helper is used, don’t warn about those
-			logrus.Warnf(`Error parsing the "auth" field of a credential entry %q in %q, missing semicolon`, key, path) // Don’t include the text of decoded, because that might put secrets into a log.
+			log.Warnf(`Error parsing the "auth" field of a credential entry %q in %q, missing semicolon`, key, path) // Don’t include the text of decoded, because that might put secrets into a log.
 		} else {
-			logrus.Debugf("Found an empty credential entry %q in %q (an unhandled credential helper marker?), moving on", key, path)
+			log.Debugf("Found an empty credential entry %q in %q (an unhandled credential helper marker?), moving on", key, path)
 		}
 		return types.DockerAuthConfig{}, nil
 	}
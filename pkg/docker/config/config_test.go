@@ -1,6 +1,8 @@
 package config
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -296,7 +298,7 @@ func TestGetAuth(t *testing.T) {
 					sys = tc.sys
 				}
 
-				auth, err := getCredentialsWithHomeDir(sys, tc.key, tmpHomeDir)
+				auth, err := getCredentialsWithHomeDir(context.Background(), sys, tc.key, tmpHomeDir)
 				require.NoError(t, err)
 				assert.Equal(t, tc.expected, auth)
 
@@ -353,7 +355,7 @@ func TestGetAuthFromLegacyFile(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			auth, err := getCredentialsWithHomeDir(nil, tc.hostname, tmpDir)
+			auth, err := getCredentialsWithHomeDir(context.Background(), nil, tc.hostname, tmpDir)
 			require.NoError(t, err)
 			assert.Equal(t, tc.expected, auth)
 
@@ -398,12 +400,40 @@ func TestGetAuthPreferNewConfig(t *testing.T) {
 		}
 	}
 
-	auth, err := getCredentialsWithHomeDir(nil, "docker.io", tmpDir)
+	auth, err := getCredentialsWithHomeDir(context.Background(), nil, "docker.io", tmpDir)
 	assert.NoError(t, err)
 	assert.Equal(t, "docker", auth.Username)
 	assert.Equal(t, "io", auth.Password)
 }
 
+func TestGetCredentialsCloud(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	const registry = "123456789012.dkr.ecr.us-east-1.amazonaws.com"
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		SystemRegistriesConfPath:    registriesConf,
+		SystemRegistriesConfDirPath: filepath.Join("testdata", "IdoNotExist"),
+	}
+	// Use a canceled context, so that any attempt to contact the cloud provider fails.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Ambient cloud credentials are not used by default
+	auth, err := getCredentialsWithHomeDir(ctx, sys, registry, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, types.DockerAuthConfig{}, auth)
+
+	// If enabled, the caller’s context is used to obtain them
+	sys.DockerEnableCloudCredentials = true
+	_, err = getCredentialsWithHomeDir(ctx, sys, registry, t.TempDir())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
 func TestGetAuthFailsOnBadInput(t *testing.T) {
 	tmpXDGRuntimeDir := t.TempDir()
 	t.Logf("using temporary XDG_RUNTIME_DIR directory: %q", tmpXDGRuntimeDir)
@@ -422,7 +452,7 @@ func TestGetAuthFailsOnBadInput(t *testing.T) {
 	configPath := filepath.Join(configDir, "auth.json")
 
 	// no config file present
-	auth, err := getCredentialsWithHomeDir(nil, "index.docker.io", tmpHomeDir)
+	auth, err := getCredentialsWithHomeDir(context.Background(), nil, "index.docker.io", tmpHomeDir)
 	if err != nil {
 		t.Fatalf("got unexpected error: %#+v", err)
 	}
@@ -431,13 +461,13 @@ func TestGetAuthFailsOnBadInput(t *testing.T) {
 	if err := os.WriteFile(configPath, []byte("Json rocks! Unless it doesn't."), 0640); err != nil {
 		t.Fatalf("failed to write file %q: %v", configPath, err)
 	}
-	_, err = getCredentialsWithHomeDir(nil, "index.docker.io", tmpHomeDir)
+	_, err = getCredentialsWithHomeDir(context.Background(), nil, "index.docker.io", tmpHomeDir)
 	assert.ErrorContains(t, err, "unmarshaling JSON")
 
 	// remove the invalid config file
 	os.RemoveAll(configPath)
 	// no config file present
-	auth, err = getCredentialsWithHomeDir(nil, "index.docker.io", tmpHomeDir)
+	auth, err = getCredentialsWithHomeDir(context.Background(), nil, "index.docker.io", tmpHomeDir)
 	if err != nil {
 		t.Fatalf("got unexpected error: %#+v", err)
 	}
@@ -447,7 +477,7 @@ func TestGetAuthFailsOnBadInput(t *testing.T) {
 	if err := os.WriteFile(configPath, []byte("I'm certainly not a json string."), 0640); err != nil {
 		t.Fatalf("failed to write file %q: %v", configPath, err)
 	}
-	_, err = getCredentialsWithHomeDir(nil, "index.docker.io", tmpHomeDir)
+	_, err = getCredentialsWithHomeDir(context.Background(), nil, "index.docker.io", tmpHomeDir)
 	assert.ErrorContains(t, err, "unmarshaling JSON")
 }
 
@@ -629,6 +659,86 @@ func TestGetAllCredentials(t *testing.T) {
 	}
 }
 
+func TestListCredentialSources(t *testing.T) {
+	// override PATH for executing credHelper
+	path, err := os.Getwd()
+	require.NoError(t, err)
+	t.Setenv("PATH", fmt.Sprintf("%s:%s", filepath.Join(path, "testdata"), os.Getenv("PATH")))
+	err = os.Chmod(filepath.Join(path, "testdata", "docker-credential-helper-registry"), os.ModePerm)
+	require.NoError(t, err)
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	os.Unsetenv("XDG_CONFIG_HOME")
+	homeDir := t.TempDir()
+	exampleAuth := base64.StdEncoding.EncodeToString([]byte("example-user:example-password"))
+
+	// An explicitly specified auth file, with a credHelpers entry, and a helper configured in registries.conf.
+	authFilePath := filepath.Join(t.TempDir(), "auth.json")
+	err = os.WriteFile(authFilePath, []byte(`{"auths":{"example.org":{"auth":"`+exampleAuth+`"}},`+
+		`"credHelpers":{"registry-b.com":"helper-registry"}}`), 0o600)
+	require.NoError(t, err)
+	sys := &types.SystemContext{
+		AuthFilePath:                authFilePath,
+		SystemRegistriesConfPath:    filepath.Join("testdata", "cred-helper-with-auth-files.conf"),
+		SystemRegistriesConfDirPath: filepath.Join("testdata", "IdoNotExist"),
+	}
+	sources, err := listCredentialSourcesWithHomeDir(sys, homeDir)
+	require.NoError(t, err)
+	assert.Equal(t, []RegistryCredentialSource{
+		{
+			Key:      "example.org",
+			Username: "example-user",
+			Source:   CredentialSource{Kind: CredentialSourceAuthFile, Path: authFilePath},
+		},
+		{
+			Key:      "registry-a.com",
+			Username: "foo",
+			Source:   CredentialSource{Kind: CredentialSourceHelper, Helper: "helper-registry"},
+		},
+		{
+			Key:    "registry-b.com",
+			Source: CredentialSource{Kind: CredentialSourceHelper, Path: authFilePath, Helper: "helper-registry"},
+		},
+	}, sources)
+
+	// An auth file found using $DOCKER_CONFIG
+	dockerConfigDir := t.TempDir()
+	err = os.WriteFile(filepath.Join(dockerConfigDir, "config.json"), []byte(`{"auths":{"example.org":{"auth":"`+exampleAuth+`"}}}`), 0o600)
+	require.NoError(t, err)
+	t.Setenv("DOCKER_CONFIG", dockerConfigDir)
+	sys = &types.SystemContext{
+		SystemRegistriesConfPath:    filepath.Join("testdata", "cred-helper-with-auth-files.conf"),
+		SystemRegistriesConfDirPath: filepath.Join("testdata", "IdoNotExist"),
+	}
+	sources, err = listCredentialSourcesWithHomeDir(sys, homeDir)
+	require.NoError(t, err)
+	assert.Equal(t, []RegistryCredentialSource{
+		{
+			Key:      "example.org",
+			Username: "example-user",
+			Source: CredentialSource{
+				Kind:                CredentialSourceAuthFile,
+				Path:                filepath.Join(dockerConfigDir, "config.json"),
+				EnvironmentVariable: "DOCKER_CONFIG",
+			},
+		},
+		{
+			Key:      "registry-a.com",
+			Username: "foo",
+			Source:   CredentialSource{Kind: CredentialSourceHelper, Helper: "helper-registry"},
+		},
+	}, sources)
+
+	// DockerAuthConfig overrides everything
+	sys.DockerAuthConfig = &types.DockerAuthConfig{Username: "override", Password: "password"}
+	sources, err = listCredentialSourcesWithHomeDir(sys, homeDir)
+	require.NoError(t, err)
+	assert.Equal(t, []RegistryCredentialSource{
+		{Key: "example.org", Username: "override", Source: CredentialSource{Kind: CredentialSourceSystemContext}},
+		{Key: "registry-a.com", Username: "override", Source: CredentialSource{Kind: CredentialSourceSystemContext}},
+	}, sources)
+}
+
 func TestAuthKeysForKey(t *testing.T) {
 	for _, tc := range []struct {
 		name, input string
@@ -1045,7 +1155,7 @@ func TestSetGetCredentials(t *testing.T) {
 		require.NoError(t, err)
 
 		// Try to authenticate against them
-		auth, err := getCredentialsWithHomeDir(sys, tc.get, tmpDir)
+		auth, err := getCredentialsWithHomeDir(context.Background(), sys, tc.get, tmpDir)
 		require.NoError(t, err)
 
 		if tc.shouldAuth {
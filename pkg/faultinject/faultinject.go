@@ -0,0 +1,156 @@
+// Package faultinject allows tests to inject failures into network and blob operations, so that retry and resume
+// logic can be tested deterministically.
+//
+// Faults are injected by setting types.SystemContext.FaultInjector. This is only intended for testing;
+// production code should never set that field.
+package faultinject
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Point identifies where in the library a fault can be injected.
+type Point string
+
+const (
+	// RegistryRequest is an HTTP request made by the docker: transport to a registry (other than authentication token requests).
+	// The target is the request method and URL, e.g. "GET https://registry.example/v2/ns/repo/blobs/sha256:…".
+	// All kinds of faults are supported; Truncate and Corrupt apply to the response body.
+	RegistryRequest Point = "registry-request"
+	// CopyBlob is the data of a blob read from the source by the copy package. The target is the blob digest.
+	// Timeout, Truncate and Corrupt are supported.
+	CopyBlob Point = "copy-blob"
+)
+
+// Fault describes a failure to inject.
+type Fault struct {
+	// Timeout makes the operation fail with an error reporting a timeout (a net.Error with Timeout() == true).
+	Timeout bool
+	// StatusCode, if not 0, makes a registry request return a response with this HTTP status (e.g. 429 or 503)
+	// and Header, without contacting the registry.
+	StatusCode int
+	Header     http.Header
+	// Truncate makes the data end after TruncateAfter bytes, with io.ErrUnexpectedEOF.
+	Truncate      bool
+	TruncateAfter int64
+	// Corrupt modifies the first byte of the data, so that it no longer matches its digest.
+	Corrupt bool
+}
+
+// Injector decides which faults to inject.
+type Injector interface {
+	// Fault returns the fault to inject into the operation at point on target, or nil to let it proceed normally.
+	// It may be called concurrently.
+	Fault(ctx context.Context, point Point, target string) *Fault
+}
+
+// InjectorFunc is an Injector implemented by a function.
+type InjectorFunc func(ctx context.Context, point Point, target string) *Fault
+
+// Fault returns the fault to inject into the operation at point on target, or nil to let it proceed normally.
+func (f InjectorFunc) Fault(ctx context.Context, point Point, target string) *Fault {
+	return f(ctx, point, target)
+}
+
+// Times returns an Injector which injects fault into the first n operations at point, and lets all other operations proceed.
+func Times(n int, point Point, fault Fault) Injector {
+	var mutex sync.Mutex
+	remaining := n
+	return InjectorFunc(func(ctx context.Context, p Point, target string) *Fault {
+		if p != point {
+			return nil
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if remaining <= 0 {
+			return nil
+		}
+		remaining--
+		f := fault
+		return &f
+	})
+}
+
+// Lookup returns the fault chosen by injector for point and target, or nil if injector is nil or it does not inject a fault.
+func Lookup(ctx context.Context, injector Injector, point Point, target string) *Fault {
+	if injector == nil {
+		return nil
+	}
+	return injector.Fault(ctx, point, target)
+}
+
+// TimeoutError is the error returned by operations failed by a Fault with Timeout set.
+// It implements net.Error.
+type TimeoutError struct {
+	Point  Point
+	Target string
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("injected timeout in %s %s", e.Point, e.Target)
+}
+
+// Timeout returns true, as required by net.Error.
+func (e TimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary returns true, as required by net.Error.
+func (e TimeoutError) Temporary() bool {
+	return true
+}
+
+// Reader returns a reader which reads data from r, modified according to f; if f is nil, it returns r.
+// point and target are used in error messages.
+func (f *Fault) Reader(r io.Reader, point Point, target string) io.Reader {
+	if f == nil || (!f.Timeout && !f.Truncate && !f.Corrupt) {
+		return r
+	}
+	return &faultyReader{reader: r, fault: *f, point: point, target: target}
+}
+
+// ReadCloser is Reader for io.ReadCloser values.
+func (f *Fault) ReadCloser(rc io.ReadCloser, point Point, target string) io.ReadCloser {
+	r := f.Reader(rc, point, target)
+	if r == io.Reader(rc) {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, rc}
+}
+
+// faultyReader injects a Fault into data read from reader.
+type faultyReader struct {
+	reader io.Reader
+	fault  Fault
+	point  Point
+	target string
+	offset int64
+}
+
+func (r *faultyReader) Read(p []byte) (int, error) {
+	if r.fault.Timeout {
+		return 0, TimeoutError{Point: r.point, Target: r.target}
+	}
+	if r.fault.Truncate {
+		remaining := r.fault.TruncateAfter - r.offset
+		if remaining <= 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := r.reader.Read(p)
+	if r.fault.Corrupt && r.offset == 0 && n > 0 {
+		p[0] ^= 0xff
+	}
+	r.offset += int64(n)
+	return n, err
+}
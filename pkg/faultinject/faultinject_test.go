@@ -0,0 +1,79 @@
+package faultinject
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimes(t *testing.T) {
+	injector := Times(2, CopyBlob, Fault{Corrupt: true})
+	assert.Nil(t, injector.Fault(context.Background(), RegistryRequest, "GET /"))
+	for i := 0; i < 2; i++ {
+		f := injector.Fault(context.Background(), CopyBlob, "sha256:0")
+		require.NotNil(t, f)
+		assert.True(t, f.Corrupt)
+	}
+	assert.Nil(t, injector.Fault(context.Background(), CopyBlob, "sha256:0"))
+}
+
+func TestLookup(t *testing.T) {
+	assert.Nil(t, Lookup(context.Background(), nil, CopyBlob, "sha256:0"))
+	injector := InjectorFunc(func(ctx context.Context, point Point, target string) *Fault {
+		if target == "match" {
+			return &Fault{Timeout: true}
+		}
+		return nil
+	})
+	assert.Nil(t, Lookup(context.Background(), injector, CopyBlob, "other"))
+	assert.Equal(t, &Fault{Timeout: true}, Lookup(context.Background(), injector, CopyBlob, "match"))
+}
+
+func TestFaultReader(t *testing.T) {
+	data := []byte("0123456789")
+
+	// No fault
+	var f *Fault
+	r := bytes.NewReader(data)
+	assert.Equal(t, io.Reader(r), f.Reader(r, CopyBlob, "target"))
+	f = &Fault{StatusCode: 429} // Does not affect the data
+	assert.Equal(t, io.Reader(r), f.Reader(r, CopyBlob, "target"))
+
+	// Corrupt
+	f = &Fault{Corrupt: true}
+	res, err := io.ReadAll(f.Reader(bytes.NewReader(data), CopyBlob, "target"))
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{'0' ^ 0xff}, data[1:]...), res)
+
+	// Truncate
+	for _, n := range []int64{0, 4} {
+		f = &Fault{Truncate: true, TruncateAfter: n}
+		res, err = io.ReadAll(f.Reader(bytes.NewReader(data), CopyBlob, "target"))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, data[:n], res)
+	}
+
+	// Timeout
+	f = &Fault{Timeout: true}
+	_, err = io.ReadAll(f.Reader(bytes.NewReader(data), CopyBlob, "target"))
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+	assert.Equal(t, TimeoutError{Point: CopyBlob, Target: "target"}, err)
+
+	// ReadCloser
+	rc := io.NopCloser(bytes.NewReader(data))
+	assert.Equal(t, rc, (*Fault)(nil).ReadCloser(rc, CopyBlob, "target"))
+	f = &Fault{Corrupt: true}
+	wrapped := f.ReadCloser(io.NopCloser(bytes.NewReader(data)), CopyBlob, "target")
+	res, err = io.ReadAll(wrapped)
+	require.NoError(t, err)
+	assert.NotEqual(t, data, res)
+	assert.NoError(t, wrapped.Close())
+}
@@ -0,0 +1,216 @@
+// Package kmscrypt allows wrapping ocicrypt layer encryption keys with keys held by a key management service (KMS),
+// e.g. AWS KMS, Google Cloud KMS or Azure Key Vault, so that encrypted images can only be decrypted by workloads with access to the KMS key.
+//
+// This package does not depend on any cloud provider SDK. Callers register a KeyManagementService implementation
+// for a key URI scheme (conventionally "awskms", "gcpkms" or "azurekms") using Register, typically a thin adapter
+// around the provider’s client; then layers can be encrypted and decrypted using configurations built by EncryptConfig
+// and DecryptConfig, passed in copy.Options.OciEncryptConfig and OciDecryptConfig.
+//
+// Importing this package registers an ocicrypt key wrapper for the "kms" scheme; the c/image/copy package does so.
+package kmscrypt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/containers/ocicrypt"
+	encconfig "github.com/containers/ocicrypt/config"
+	"github.com/containers/ocicrypt/keywrap"
+)
+
+// KeysParameter is the ocicrypt EncryptConfig and DecryptConfig parameter containing KMS key URIs, one per value.
+// When encrypting, the layer key is wrapped by every one of the keys; when decrypting, only the listed keys are used.
+const KeysParameter = "kms-keys"
+
+const (
+	// scheme is the name of the key wrapper registered with ocicrypt.
+	scheme = "kms"
+	// annotationID is the annotation containing KMS-wrapped layer keys.
+	annotationID = "org.opencontainers.image.enc.keys.kms"
+)
+
+// KeyManagementService encrypts and decrypts small amounts of data (layer key options) using keys held by a KMS.
+type KeyManagementService interface {
+	// Encrypt encrypts plaintext using the key identified by keyURI.
+	Encrypt(ctx context.Context, keyURI string, plaintext []byte) ([]byte, error)
+	// Decrypt decrypts ciphertext, created by Encrypt, using the key identified by keyURI.
+	Decrypt(ctx context.Context, keyURI string, ciphertext []byte) ([]byte, error)
+}
+
+var (
+	servicesLock sync.RWMutex
+	services     = map[string]KeyManagementService{} // key URI scheme -> service
+)
+
+// Register makes service available for key URIs using uriScheme (e.g. "awskms" for "awskms:///arn:aws:kms:…").
+// Registering a service for an already registered scheme replaces the previous service.
+func Register(uriScheme string, service KeyManagementService) {
+	servicesLock.Lock()
+	defer servicesLock.Unlock()
+	services[uriScheme] = service
+}
+
+// serviceForKey returns the KeyManagementService responsible for keyURI.
+func serviceForKey(keyURI string) (KeyManagementService, error) {
+	u, err := url.Parse(keyURI)
+	if err != nil {
+		return nil, fmt.Errorf("parsing KMS key URI %q: %w", keyURI, err)
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("KMS key URI %q does not specify a scheme", keyURI)
+	}
+	servicesLock.RLock()
+	defer servicesLock.RUnlock()
+	service, ok := services[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("no KMS registered for key URI scheme %q", u.Scheme)
+	}
+	return service, nil
+}
+
+// keysParameter validates keyURIs, and returns them in the form used in KeysParameter.
+func keysParameter(keyURIs []string) ([][]byte, error) {
+	if len(keyURIs) == 0 {
+		return nil, errors.New("no KMS keys specified")
+	}
+	res := make([][]byte, 0, len(keyURIs))
+	for _, keyURI := range keyURIs {
+		if _, err := serviceForKey(keyURI); err != nil {
+			return nil, err
+		}
+		res = append(res, []byte(keyURI))
+	}
+	return res, nil
+}
+
+// EncryptConfig returns an ocicrypt configuration which wraps layer keys using all of keyURIs.
+// The schemes of keyURIs must have been registered using Register.
+func EncryptConfig(keyURIs []string) (*encconfig.EncryptConfig, error) {
+	keys, err := keysParameter(keyURIs)
+	if err != nil {
+		return nil, err
+	}
+	return &encconfig.EncryptConfig{
+		Parameters: map[string][][]byte{KeysParameter: keys},
+		DecryptConfig: encconfig.DecryptConfig{
+			Parameters: map[string][][]byte{},
+		},
+	}, nil
+}
+
+// DecryptConfig returns an ocicrypt configuration which unwraps layer keys using any of keyURIs.
+// The schemes of keyURIs must have been registered using Register.
+func DecryptConfig(keyURIs []string) (*encconfig.DecryptConfig, error) {
+	keys, err := keysParameter(keyURIs)
+	if err != nil {
+		return nil, err
+	}
+	return &encconfig.DecryptConfig{
+		Parameters: map[string][][]byte{KeysParameter: keys},
+	}, nil
+}
+
+func init() {
+	ocicrypt.RegisterKeyWrapper(scheme, NewKeyWrapper())
+}
+
+// wrappedKey is a layer key wrapped by a single KMS key, as stored in the annotation.
+type wrappedKey struct {
+	KeyURI     string `json:"key"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type kmsKeyWrapper struct{}
+
+// NewKeyWrapper returns an ocicrypt key wrapper using registered KMS services.
+// Most callers should not need to use it directly, it is registered with ocicrypt when this package is imported.
+func NewKeyWrapper() keywrap.KeyWrapper {
+	return &kmsKeyWrapper{}
+}
+
+func (kw *kmsKeyWrapper) GetAnnotationID() string {
+	return annotationID
+}
+
+// WrapKeys encrypts optsData, which describes the symmetric key used for encrypting the layer, using every KMS key in ec.
+func (kw *kmsKeyWrapper) WrapKeys(ec *encconfig.EncryptConfig, optsData []byte) ([]byte, error) {
+	keys := ec.Parameters[KeysParameter]
+	// As with other key wrappers, no keys is not an error…
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	ctx := context.Background() // ocicrypt does not provide a context.
+	wrapped := make([]wrappedKey, 0, len(keys))
+	for _, key := range keys {
+		keyURI := string(key)
+		service, err := serviceForKey(keyURI)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := service.Encrypt(ctx, keyURI, optsData)
+		if err != nil {
+			return nil, fmt.Errorf("wrapping layer key using KMS key %q: %w", keyURI, err)
+		}
+		wrapped = append(wrapped, wrappedKey{KeyURI: keyURI, Ciphertext: ciphertext})
+	}
+	return json.Marshal(wrapped)
+}
+
+// UnwrapKey decrypts annotation using any of the KMS keys in dc.
+func (kw *kmsKeyWrapper) UnwrapKey(dc *encconfig.DecryptConfig, annotation []byte) ([]byte, error) {
+	var wrapped []wrappedKey
+	if err := json.Unmarshal(annotation, &wrapped); err != nil {
+		return nil, fmt.Errorf("parsing KMS-wrapped layer keys: %w", err)
+	}
+	allowed := map[string]struct{}{}
+	for _, key := range kw.GetPrivateKeys(dc.Parameters) {
+		allowed[string(key)] = struct{}{}
+	}
+	if len(allowed) == 0 {
+		return nil, errors.New("no KMS keys found for decryption")
+	}
+
+	ctx := context.Background() // ocicrypt does not provide a context.
+	errs := []error{}
+	for _, w := range wrapped {
+		if _, ok := allowed[w.KeyURI]; !ok {
+			continue
+		}
+		service, err := serviceForKey(w.KeyURI)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res, err := service.Decrypt(ctx, w.KeyURI, w.Ciphertext)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unwrapping layer key using KMS key %q: %w", w.KeyURI, err))
+			continue
+		}
+		return res, nil
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("the layer key is not wrapped by any of the available KMS keys")
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (kw *kmsKeyWrapper) NoPossibleKeys(dcparameters map[string][][]byte) bool {
+	return len(kw.GetPrivateKeys(dcparameters)) == 0
+}
+
+func (kw *kmsKeyWrapper) GetPrivateKeys(dcparameters map[string][][]byte) [][]byte {
+	// KMS keys can’t be exported; return the key URIs, so that ocicrypt knows that some keys were provided.
+	return dcparameters[KeysParameter]
+}
+
+func (kw *kmsKeyWrapper) GetKeyIdsFromPacket(packet string) ([]uint64, error) {
+	return nil, nil
+}
+
+func (kw *kmsKeyWrapper) GetRecipients(packet string) ([]string, error) {
+	return []string{"[kms]"}, nil
+}
@@ -0,0 +1,162 @@
+package kmscrypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containers/ocicrypt"
+	encconfig "github.com/containers/ocicrypt/config"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKMS is a KeyManagementService which holds an AES key for each known key URI.
+type fakeKMS struct {
+	keys map[string][]byte
+}
+
+func newFakeKMS(t *testing.T, keyURIs ...string) *fakeKMS {
+	res := &fakeKMS{keys: map[string][]byte{}}
+	for _, keyURI := range keyURIs {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		res.keys[keyURI] = key
+	}
+	return res
+}
+
+func (k *fakeKMS) aead(keyURI string) (cipher.AEAD, error) {
+	key, ok := k.keys[keyURI]
+	if !ok {
+		return nil, errors.New("access denied")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *fakeKMS) Encrypt(ctx context.Context, keyURI string, plaintext []byte) ([]byte, error) {
+	aead, err := k.aead(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (k *fakeKMS) Decrypt(ctx context.Context, keyURI string, ciphertext []byte) ([]byte, error) {
+	aead, err := k.aead(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
+
+func TestKeyWrapperRoundTrip(t *testing.T) {
+	kms := newFakeKMS(t, "testkms://key1", "testkms://key2")
+	Register("testkms", kms)
+	optsData := []byte("symmetric key options")
+
+	kw := NewKeyWrapper()
+	ec, err := EncryptConfig([]string{"testkms://key1", "testkms://key2"})
+	require.NoError(t, err)
+	wrapped, err := kw.WrapKeys(ec, optsData)
+	require.NoError(t, err)
+
+	for _, key := range []string{"testkms://key1", "testkms://key2"} {
+		dc, err := DecryptConfig([]string{key})
+		require.NoError(t, err)
+		assert.False(t, kw.NoPossibleKeys(dc.Parameters))
+		res, err := kw.UnwrapKey(dc, wrapped)
+		require.NoError(t, err, key)
+		assert.Equal(t, optsData, res, key)
+	}
+
+	// A key which was not used for wrapping
+	kms.keys["testkms://key3"] = kms.keys["testkms://key1"]
+	dc, err := DecryptConfig([]string{"testkms://key3"})
+	require.NoError(t, err)
+	_, err = kw.UnwrapKey(dc, wrapped)
+	assert.Error(t, err)
+
+	// The KMS refuses access
+	delete(kms.keys, "testkms://key1")
+	dc, err = DecryptConfig([]string{"testkms://key1"})
+	require.NoError(t, err)
+	_, err = kw.UnwrapKey(dc, wrapped)
+	assert.ErrorContains(t, err, "access denied")
+
+	// No keys
+	assert.True(t, kw.NoPossibleKeys(map[string][][]byte{}))
+	_, err = kw.UnwrapKey(&encconfig.DecryptConfig{Parameters: map[string][][]byte{}}, wrapped)
+	assert.Error(t, err)
+	res, err := kw.WrapKeys(&encconfig.EncryptConfig{Parameters: map[string][][]byte{}}, optsData)
+	require.NoError(t, err)
+	assert.Nil(t, res)
+
+	// Invalid annotation
+	_, err = kw.UnwrapKey(dc, []byte("invalid"))
+	assert.Error(t, err)
+}
+
+func TestConfigValidation(t *testing.T) {
+	Register("testkms", newFakeKMS(t))
+	for _, keys := range [][]string{
+		nil,
+		{"no-scheme"},
+		{"unknownkms://key"},
+		{"testkms://key", "unknownkms://key"},
+		{"%"},
+	} {
+		_, err := EncryptConfig(keys)
+		assert.Error(t, err, "%#v", keys)
+		_, err = DecryptConfig(keys)
+		assert.Error(t, err, "%#v", keys)
+	}
+}
+
+func TestOCICryptIntegration(t *testing.T) {
+	Register("testkms", newFakeKMS(t, "testkms://key"))
+	ec, err := EncryptConfig([]string{"testkms://key"})
+	require.NoError(t, err)
+	dc, err := DecryptConfig([]string{"testkms://key"})
+	require.NoError(t, err)
+
+	layer := bytes.Repeat([]byte("layer data"), 1000)
+	desc := imgspecv1.Descriptor{
+		MediaType: imgspecv1.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(layer),
+		Size:      int64(len(layer)),
+	}
+	encrypted, finalizer, err := ocicrypt.EncryptLayer(ec, bytes.NewReader(layer), desc)
+	require.NoError(t, err)
+	encryptedData, err := io.ReadAll(encrypted)
+	require.NoError(t, err)
+	annotations, err := finalizer()
+	require.NoError(t, err)
+	assert.Contains(t, annotations, annotationID)
+
+	desc.Annotations = annotations
+	decrypted, _, err := ocicrypt.DecryptLayer(dc, bytes.NewReader(encryptedData), desc, false)
+	require.NoError(t, err)
+	decryptedData, err := io.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, layer, decryptedData)
+}
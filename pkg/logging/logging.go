@@ -0,0 +1,75 @@
+// Package logging allows applications to control where log output of this library goes.
+//
+// By default, all log output is forwarded to the standard logrus logger (github.com/sirupsen/logrus.StandardLogger()),
+// respecting its configured level.  Applications can instead provide a *slog.Logger, either globally using SetDefault,
+// or for individual operations using WithLogger.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// OperationIDKey is the attribute key used for operation IDs in log records, see WithOperationID.
+const OperationIDKey = "operation_id"
+
+// loggerContextKey is the context.Context key of a logger set by WithLogger.
+type loggerContextKey struct{}
+
+// operationIDContextKey is the context.Context key of an operation ID set by WithOperationID.
+type operationIDContextKey struct{}
+
+// defaultLogger, if not nil, is the logger set by SetDefault.
+var defaultLogger atomic.Pointer[slog.Logger]
+
+// logrusLogger forwards to the standard logrus logger.
+var logrusLogger = slog.New(newLogrusHandler())
+
+// WithLogger returns a copy of ctx which causes operations using it to log to logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger to use for operations using ctx:
+// a logger set by WithLogger, if any, otherwise Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	return Default()
+}
+
+// SetDefault sets the logger used when no logger has been set for an operation using WithLogger.
+// If logger is nil, log output is forwarded to the standard logrus logger again.
+func SetDefault(logger *slog.Logger) {
+	defaultLogger.Store(logger)
+}
+
+// Default returns the logger used when no logger has been set for an operation using WithLogger:
+// the logger set by SetDefault, or a logger which forwards to the standard logrus logger.
+func Default() *slog.Logger {
+	if logger := defaultLogger.Load(); logger != nil {
+		return logger
+	}
+	return logrusLogger
+}
+
+// WithOperationID returns a copy of ctx which causes operations using it to add an OperationIDKey attribute
+// with value id to all log records, and from which OperationID returns id.
+// It is used e.g. by copy.Image, so that log output of concurrent copies can be told apart.
+func WithOperationID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, operationIDContextKey{}, id)
+	return WithLogger(ctx, FromContext(ctx).With(OperationIDKey, id))
+}
+
+// OperationID returns the operation ID set in ctx by WithOperationID, or "" if there is none.
+func OperationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(operationIDContextKey{}).(string)
+	return id
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	assert.Same(t, Default(), FromContext(context.Background()))
+	assert.Same(t, Default(), FromContext(nil)) //nolint:staticcheck // Testing the nil case on purpose.
+	assert.Same(t, logger, FromContext(WithLogger(context.Background(), logger)))
+	assert.Same(t, Default(), FromContext(WithLogger(context.Background(), nil)))
+}
+
+func TestSetDefault(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	assert.Same(t, logrusLogger, Default())
+	SetDefault(logger)
+	assert.Same(t, logger, Default())
+	assert.Same(t, logger, FromContext(context.Background()))
+	SetDefault(nil)
+	assert.Same(t, logrusLogger, Default())
+}
+
+func TestLogrusHandler(t *testing.T) {
+	hook := test.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+	origLevel := logrus.GetLevel()
+	defer logrus.SetLevel(origLevel)
+	logrus.SetLevel(logrus.InfoLevel)
+	logger := slog.New(newLogrusHandler())
+
+	// Levels below the logrus level are discarded
+	logger.Debug("discarded")
+	assert.Empty(t, hook.AllEntries())
+
+	for _, c := range []struct {
+		level    slog.Level
+		expected logrus.Level
+	}{
+		{slog.LevelInfo, logrus.InfoLevel},
+		{slog.LevelWarn, logrus.WarnLevel},
+		{slog.LevelError, logrus.ErrorLevel},
+		{slog.LevelError + 4, logrus.ErrorLevel},
+	} {
+		hook.Reset()
+		logger.Log(context.Background(), c.level, "message")
+		entry := hook.LastEntry()
+		require.NotNil(t, entry, c.level.String())
+		assert.Equal(t, c.expected, entry.Level, c.level.String())
+		assert.Equal(t, "message", entry.Message, c.level.String())
+		assert.Empty(t, entry.Data, c.level.String())
+	}
+
+	// Attributes, including groups
+	hook.Reset()
+	logger.With("a", 1).WithGroup("g").With("b", "x").Info("attrs",
+		"c", true, slog.Group("h", "d", 2), slog.Group("", "e", 3), slog.Attr{})
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, "attrs", entry.Message)
+	assert.Equal(t, logrus.Fields{
+		"a":     int64(1),
+		"g.b":   "x",
+		"g.c":   true,
+		"g.h.d": int64(2),
+		"g.e":   int64(3),
+	}, entry.Data)
+}
+
+func TestWithOperationID(t *testing.T) {
+	assert.Equal(t, "", OperationID(context.Background()))
+	assert.Equal(t, "", OperationID(nil)) //nolint:staticcheck // Testing the nil case on purpose.
+
+	buf := bytes.Buffer{}
+	ctx := WithLogger(context.Background(), slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	ctx = WithOperationID(ctx, "op1")
+	assert.Equal(t, "op1", OperationID(ctx))
+	FromContext(ctx).Info("message")
+	assert.Equal(t, "level=INFO msg=message operation_id=op1\n", buf.String())
+}
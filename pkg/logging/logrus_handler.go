@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logrusHandler is a slog.Handler which forwards records to the standard logrus logger.
+type logrusHandler struct {
+	attrs  []prefixedAttr // Attributes added using WithAttrs
+	prefix string         // Key prefix for groups added using WithGroup, "" or ending with "."
+}
+
+// prefixedAttr is an attribute added using WithAttrs, with the key prefix that was current at the time.
+type prefixedAttr struct {
+	prefix string
+	attr   slog.Attr
+}
+
+func newLogrusHandler() *logrusHandler {
+	return &logrusHandler{}
+}
+
+// logrusLevel returns the logrus level corresponding to level.
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return logrus.TraceLevel
+	case level < slog.LevelInfo:
+		return logrus.DebugLevel
+	case level < slog.LevelWarn:
+		return logrus.InfoLevel
+	case level < slog.LevelError:
+		return logrus.WarnLevel
+	default:
+		return logrus.ErrorLevel
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *logrusHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return logrus.IsLevelEnabled(logrusLevel(level))
+}
+
+// addFields adds attr, with keys prefixed by prefix, to fields.
+func addFields(fields logrus.Fields, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" { // Attributes of groups with an empty key are inlined.
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, a := range value.Group() {
+			addFields(fields, groupPrefix, a)
+		}
+		return
+	}
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	fields[prefix+attr.Key] = value.Any()
+}
+
+// Handle forwards record to logrus.
+func (h *logrusHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := logrus.Fields{}
+	for _, a := range h.attrs {
+		addFields(fields, a.prefix, a.attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addFields(fields, h.prefix, attr)
+		return true
+	})
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if len(fields) != 0 {
+		entry = entry.WithFields(fields)
+	}
+	entry.Log(logrusLevel(record.Level), record.Message)
+	return nil
+}
+
+// WithAttrs returns a handler which includes attrs in all records.
+func (h *logrusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	res := &logrusHandler{
+		attrs:  make([]prefixedAttr, 0, len(h.attrs)+len(attrs)),
+		prefix: h.prefix,
+	}
+	res.attrs = append(res.attrs, h.attrs...)
+	for _, attr := range attrs {
+		res.attrs = append(res.attrs, prefixedAttr{prefix: h.prefix, attr: attr})
+	}
+	return res
+}
+
+// WithGroup returns a handler which qualifies keys of all following attributes by name.
+func (h *logrusHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &logrusHandler{
+		attrs:  h.attrs,
+		prefix: h.prefix + name + ".",
+	}
+}
@@ -0,0 +1,75 @@
+// Package metrics defines an interface applications can implement to collect metrics
+// about image transfers and registry calls, e.g. to export them to Prometheus.
+package metrics
+
+// Recorder receives metric values.  Metrics are identified by name (one of the constants
+// defined in this package) and a set of labels; the label names used by each metric are
+// documented with the metric name.
+//
+// Implementations must be safe for concurrent use, and should not block.
+type Recorder interface {
+	// AddCounter adds value, which is never negative, to a counter.
+	AddCounter(name string, value float64, labels map[string]string)
+	// ObserveHistogram records value in a histogram.
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+// Metrics recorded by copy.Image.
+const (
+	// BlobBytesTotal is a counter of the bytes read from an image source while copying blobs.
+	// Labels: LabelBlobKind.
+	BlobBytesTotal = "containers_image_blob_bytes_total"
+	// BlobTransferDurationSeconds is a histogram of the time taken to copy a blob.
+	// Labels: LabelBlobKind.
+	BlobTransferDurationSeconds = "containers_image_blob_transfer_duration_seconds"
+	// BlobReuseTotal is a counter of attempts to avoid copying a layer because the destination already contains it, or can reuse another copy.
+	// Labels: LabelResult (ResultHit or ResultMiss).
+	BlobReuseTotal = "containers_image_blob_reuse_total"
+)
+
+// Metrics recorded by the docker: transport.
+const (
+	// RegistryRequestsTotal is a counter of HTTP requests to registries.
+	// Labels: LabelMethod, LabelStatusCode (set to "error" if no response was received).
+	RegistryRequestsTotal = "containers_image_registry_requests_total"
+	// RegistryRequestDurationSeconds is a histogram of the time taken to receive response headers from a registry.
+	// Labels: LabelMethod.
+	RegistryRequestDurationSeconds = "containers_image_registry_request_duration_seconds"
+	// RegistryRetriesTotal is a counter of registry requests repeated after an unsuccessful response.
+	// Labels: LabelReason (ReasonTooManyRequests or ReasonInsufficientScope).
+	RegistryRetriesTotal = "containers_image_registry_retries_total"
+	// RegistryAuthRequestsTotal is a counter of requests to obtain a bearer token from an authentication server.
+	// Labels: LabelResult (ResultSuccess or ResultError).
+	RegistryAuthRequestsTotal = "containers_image_registry_auth_requests_total"
+)
+
+// Label names.
+const (
+	LabelBlobKind   = "kind"   // BlobKindLayer or BlobKindConfig
+	LabelResult     = "result" // Depends on the metric
+	LabelMethod     = "method" // An HTTP method
+	LabelStatusCode = "code"   // An HTTP status code, or "error"
+	LabelReason     = "reason" // Depends on the metric
+)
+
+// Label values.
+const (
+	BlobKindLayer  = "layer"
+	BlobKindConfig = "config"
+
+	ResultHit     = "hit"
+	ResultMiss    = "miss"
+	ResultSuccess = "success"
+	ResultError   = "error"
+
+	ReasonTooManyRequests   = "too_many_requests"
+	ReasonInsufficientScope = "insufficient_scope"
+)
+
+// Discard is a Recorder which ignores all metrics.
+var Discard Recorder = discard{}
+
+type discard struct{}
+
+func (discard) AddCounter(name string, value float64, labels map[string]string)       {}
+func (discard) ObserveHistogram(name string, value float64, labels map[string]string) {}
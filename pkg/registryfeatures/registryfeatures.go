@@ -0,0 +1,101 @@
+// Package registryfeatures records optional features of container registries, as learned by the docker: transport,
+// so that later operations (e.g. further copy.Image calls, or further instances of a multi-platform image)
+// can choose a working strategy directly, instead of rediscovering the same limitations through failed requests.
+//
+// A Cache is used by setting types.SystemContext.DockerRegistryFeatureCache; sharing the same Cache across
+// operations is what makes it useful.
+package registryfeatures
+
+import (
+	"slices"
+	"sync"
+)
+
+// Support is the known state of support of a feature.
+type Support int
+
+const (
+	// Unknown means the feature was not used yet, or its support could not be determined.
+	Unknown Support = iota
+	// Supported means the registry has been seen supporting the feature.
+	Supported
+	// Unsupported means the registry has been seen rejecting the feature.
+	Unsupported
+)
+
+// Features describes what is known about the features of a single registry.
+type Features struct {
+	// ReferrersAPI is the support for the OCI referrers API (GET /v2/…/referrers/…, and processing "subject" in uploaded manifests).
+	// If Unsupported, the “referrers tag schema” is used directly.
+	ReferrersAPI Support
+	// ManifestDelete is the support for deleting manifests. If Unsupported, deleting images fails without contacting the registry.
+	ManifestDelete Support
+	// RejectedManifestMIMETypes are manifest MIME types the registry has rejected while accepting another type
+	// of the same image. They are not offered as destination MIME types to copy.Image, so that it converts manifests
+	// to an accepted type directly.
+	RejectedManifestMIMETypes []string
+}
+
+// Cache contains Features of registries, indexed by the registry host name (with a port, if any).
+// It is safe for concurrent use. All methods can be called on a nil *Cache, which records nothing.
+type Cache struct {
+	mutex      sync.Mutex
+	registries map[string]Features
+}
+
+// New returns an empty Cache.
+func New() *Cache {
+	return &Cache{registries: map[string]Features{}}
+}
+
+// Lookup returns the recorded features of registry.
+func (c *Cache) Lookup(registry string) Features {
+	if c == nil {
+		return Features{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	res := c.registries[registry]
+	res.RejectedManifestMIMETypes = slices.Clone(res.RejectedManifestMIMETypes)
+	return res
+}
+
+// Update calls update to modify the recorded features of registry.
+// update is called with an internal lock held; it must not call other methods of c.
+func (c *Cache) Update(registry string, update func(features *Features)) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	features := c.registries[registry]
+	update(&features)
+	c.registries[registry] = features
+}
+
+// RecordRejectedManifestMIMETypes adds mimeTypes to the manifest MIME types rejected by registry.
+func (c *Cache) RecordRejectedManifestMIMETypes(registry string, mimeTypes []string) {
+	c.Update(registry, func(features *Features) {
+		for _, mimeType := range mimeTypes {
+			if !slices.Contains(features.RejectedManifestMIMETypes, mimeType) {
+				features.RejectedManifestMIMETypes = append(features.RejectedManifestMIMETypes, mimeType)
+			}
+		}
+	})
+}
+
+// FilterManifestMIMETypes returns candidates without the types rejected by registry.
+// If registry has rejected all of them, the recorded rejections are probably not applicable, and candidates is returned unmodified.
+func (c *Cache) FilterManifestMIMETypes(registry string, candidates []string) []string {
+	rejected := c.Lookup(registry).RejectedManifestMIMETypes
+	if len(rejected) == 0 {
+		return candidates
+	}
+	res := slices.DeleteFunc(slices.Clone(candidates), func(mimeType string) bool {
+		return slices.Contains(rejected, mimeType)
+	})
+	if len(res) == 0 {
+		return candidates
+	}
+	return res
+}
@@ -0,0 +1,48 @@
+package registryfeatures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := New()
+	assert.Equal(t, Features{}, c.Lookup("registry.example"))
+
+	c.Update("registry.example", func(features *Features) {
+		features.ReferrersAPI = Unsupported
+	})
+	c.RecordRejectedManifestMIMETypes("registry.example", []string{"a", "b"})
+	c.RecordRejectedManifestMIMETypes("registry.example", []string{"b", "c"})
+	assert.Equal(t, Features{
+		ReferrersAPI:              Unsupported,
+		RejectedManifestMIMETypes: []string{"a", "b", "c"},
+	}, c.Lookup("registry.example"))
+	assert.Equal(t, Features{}, c.Lookup("other.example"))
+
+	// Lookup returns a copy
+	features := c.Lookup("registry.example")
+	features.RejectedManifestMIMETypes[0] = "modified"
+	assert.Equal(t, []string{"a", "b", "c"}, c.Lookup("registry.example").RejectedManifestMIMETypes)
+
+	// A nil cache records nothing
+	var nilCache *Cache
+	nilCache.Update("registry.example", func(features *Features) {
+		features.ReferrersAPI = Supported
+	})
+	nilCache.RecordRejectedManifestMIMETypes("registry.example", []string{"a"})
+	assert.Equal(t, Features{}, nilCache.Lookup("registry.example"))
+	assert.Equal(t, []string{"a", "d"}, nilCache.FilterManifestMIMETypes("registry.example", []string{"a", "d"}))
+}
+
+func TestCacheFilterManifestMIMETypes(t *testing.T) {
+	c := New()
+	c.RecordRejectedManifestMIMETypes("registry.example", []string{"a", "c"})
+	candidates := []string{"a", "b", "c", "d"}
+	assert.Equal(t, []string{"b", "d"}, c.FilterManifestMIMETypes("registry.example", candidates))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, candidates) // Not modified
+	assert.Equal(t, candidates, c.FilterManifestMIMETypes("other.example", candidates))
+	// If all candidates were rejected, they are all returned.
+	assert.Equal(t, []string{"a", "c"}, c.FilterManifestMIMETypes("registry.example", []string{"a", "c"}))
+}
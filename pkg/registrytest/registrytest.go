@@ -0,0 +1,502 @@
+// Package registrytest provides a minimal in-process registry implementing the OCI distribution API, for use in tests.
+//
+// The registry supports pulling and pushing manifests and blobs (including cross-repository blob mounts),
+// listing and deleting tags, optionally token authentication and the referrers API, and it can inject faults
+// into requests. It keeps all data in memory, and it does not enforce access control scopes.
+//
+// An example:
+//
+//	server := registrytest.NewServer(registrytest.Options{})
+//	defer server.Close()
+//	ref, err := alltransports.ParseImageName("docker://" + server.Host + "/ns/app:latest")
+//	…
+//	sys := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+//
+// The server uses plain HTTP, so clients must allow insecure access to server.Host.
+package registrytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// TokenService is the "service" value used in token authentication challenges.
+const TokenService = "registrytest"
+
+// Options configure a Server.
+type Options struct {
+	// If true, the registry requires bearer tokens, issued by the server’s /token endpoint.
+	TokenAuth bool
+	// If Username is not "", the token endpoint requires HTTP basic authentication with Username and Password.
+	Username string
+	Password string
+	// If true, the referrers API is supported.
+	Referrers bool
+	// If > 0, referrers API responses are paginated with up to ReferrersPageSize entries per page.
+	ReferrersPageSize int
+	// If not nil, called for every request before it is processed. If it returns a non-zero HTTP status code,
+	// the request fails with that status code without being processed.
+	InjectFault func(r *http.Request) int
+}
+
+// Server is an in-process registry.
+type Server struct {
+	URL  string // The base URL of the server, e.g. "http://127.0.0.1:12345"
+	Host string // The host and port of the server, for use in image references, e.g. "127.0.0.1:12345"
+
+	options Options
+	server  *httptest.Server
+	token   string
+
+	mutex        sync.Mutex
+	blobs        map[digest.Digest][]byte
+	repositories map[string]*repository
+	uploads      map[string][]byte
+	requests     int
+}
+
+// repository is the state of a single repository of Server.
+type repository struct {
+	manifests map[digest.Digest]storedManifest
+	tags      map[string]digest.Digest
+}
+
+// storedManifest is a manifest stored in a repository.
+type storedManifest struct {
+	data      []byte
+	mediaType string
+}
+
+// NewServer starts a registry configured by options. The caller must call Close on the returned server.
+func NewServer(options Options) *Server {
+	s := &Server{
+		options:      options,
+		token:        "registrytest-token",
+		blobs:        map[digest.Digest][]byte{},
+		repositories: map[string]*repository{},
+		uploads:      map[string][]byte{},
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL
+	s.Host = strings.TrimPrefix(s.server.URL, "http://")
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Requests returns the number of requests the server has received, including failed ones.
+func (s *Server) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+// PutBlob stores data as a blob, available in all repositories, and returns its digest.
+func (s *Server) PutBlob(data []byte) digest.Digest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	d := digest.FromBytes(data)
+	s.blobs[d] = slices.Clone(data)
+	return d
+}
+
+// Blob returns the blob with digest d, if it exists.
+func (s *Server) Blob(d digest.Digest) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, ok := s.blobs[d]
+	return slices.Clone(data), ok
+}
+
+// PutManifest stores data, of mediaType, in repo, tagged with tag if it is not "", and returns its digest.
+func (s *Server) PutManifest(repo, tag string, data []byte, mediaType string) digest.Digest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.putManifest(repo, tag, data, mediaType)
+}
+
+// Manifest returns the manifest in repo referred to by tagOrDigest, and its media type, if it exists.
+func (s *Server) Manifest(repo, tagOrDigest string) ([]byte, string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.lookupManifest(repo, tagOrDigest)
+	return slices.Clone(m.data), m.mediaType, ok
+}
+
+// Tags returns the tags in repo, sorted.
+func (s *Server) Tags(repo string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sortedTags(repo)
+}
+
+// putManifest is PutManifest, with s.mutex held.
+func (s *Server) putManifest(repo, tag string, data []byte, mediaType string) digest.Digest {
+	r, ok := s.repositories[repo]
+	if !ok {
+		r = &repository{manifests: map[digest.Digest]storedManifest{}, tags: map[string]digest.Digest{}}
+		s.repositories[repo] = r
+	}
+	d := digest.FromBytes(data)
+	r.manifests[d] = storedManifest{data: slices.Clone(data), mediaType: mediaType}
+	if tag != "" {
+		r.tags[tag] = d
+	}
+	return d
+}
+
+// lookupManifest returns the manifest in repo referred to by tagOrDigest, with s.mutex held.
+func (s *Server) lookupManifest(repo, tagOrDigest string) (storedManifest, bool) {
+	r, ok := s.repositories[repo]
+	if !ok {
+		return storedManifest{}, false
+	}
+	d, ok := r.tags[tagOrDigest]
+	if !ok {
+		d = digest.Digest(tagOrDigest)
+	}
+	m, ok := r.manifests[d]
+	return m, ok
+}
+
+// sortedTags returns the tags in repo, sorted, with s.mutex held.
+func (s *Server) sortedTags(repo string) []string {
+	res := []string{}
+	if r, ok := s.repositories[repo]; ok {
+		for tag := range r.tags {
+			res = append(res, tag)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// writeError writes an error response in the format defined by the distribution spec.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	data, err := json.Marshal(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// writeJSON writes a JSON response of mediaType.
+func writeJSON(w http.ResponseWriter, mediaType string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests++
+	s.mutex.Unlock()
+	if s.options.InjectFault != nil {
+		if status := s.options.InjectFault(r); status != 0 {
+			writeError(w, status, "UNKNOWN", "injected fault")
+			return
+		}
+	}
+
+	if r.URL.Path == "/token" {
+		s.serveToken(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/v2/") {
+		writeError(w, http.StatusNotFound, "UNSUPPORTED", "not found")
+		return
+	}
+	if s.options.TokenAuth && r.Header.Get("Authorization") != "Bearer "+s.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service=%q`, s.URL, TokenService))
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if path == "" {
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	for _, route := range []struct {
+		separator string
+		handler   func(w http.ResponseWriter, r *http.Request, repo, rest string)
+	}{
+		{"/blobs/uploads/", s.serveUpload},
+		{"/blobs/", s.serveBlob},
+		{"/manifests/", s.serveManifest},
+		{"/referrers/", s.serveReferrers},
+		{"/tags/list", s.serveTags},
+	} {
+		if i := strings.LastIndex(path, route.separator); i > 0 {
+			route.handler(w, r, path[:i], path[i+len(route.separator):])
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "UNSUPPORTED", "not found")
+}
+
+// serveToken issues bearer tokens.
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if s.options.Username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != s.options.Username || password != s.options.Password {
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid credentials")
+			return
+		}
+	}
+	writeJSON(w, "application/json", map[string]any{"token": s.token, "expires_in": 300})
+}
+
+// serveUpload handles blob uploads and cross-repository blob mounts.
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request, repo, id string) {
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		if mount := digest.Digest(r.URL.Query().Get("mount")); mount != "" {
+			if _, ok := s.blobs[mount]; ok {
+				w.Header().Set("Location", "/v2/"+repo+"/blobs/"+mount.String())
+				w.Header().Set("Docker-Content-Digest", mount.String())
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		if d := r.URL.Query().Get("digest"); d != "" { // Monolithic upload
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+				return
+			}
+			s.finishUpload(w, repo, data, digest.Digest(d))
+			return
+		}
+		id := strconv.Itoa(len(s.uploads))
+		s.uploads[id] = []byte{}
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPatch || r.Method == http.MethodPut:
+		upload, ok := s.uploads[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload not found")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+			return
+		}
+		upload = append(upload, data...)
+		if r.Method == http.MethodPatch {
+			s.uploads[id] = upload
+			w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+			w.Header().Set("Range", fmt.Sprintf("0-%d", max(len(upload)-1, 0)))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		delete(s.uploads, id)
+		s.finishUpload(w, repo, upload, digest.Digest(r.URL.Query().Get("digest")))
+	case r.Method == http.MethodDelete:
+		delete(s.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+	}
+}
+
+// finishUpload stores data as a blob if it matches expected.
+func (s *Server) finishUpload(w http.ResponseWriter, repo string, data []byte, expected digest.Digest) {
+	d := digest.FromBytes(data)
+	if expected != d {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", fmt.Sprintf("digest %s does not match the uploaded data", expected))
+		return
+	}
+	s.blobs[d] = data
+	w.Header().Set("Location", "/v2/"+repo+"/blobs/"+d.String())
+	w.Header().Set("Docker-Content-Digest", d.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveBlob handles reading and deleting blobs.
+func (s *Server) serveBlob(w http.ResponseWriter, r *http.Request, repo, rest string) {
+	d := digest.Digest(rest)
+	data, ok := s.blobs[d]
+	if !ok {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(s.blobs, d)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+	}
+}
+
+// serveManifest handles reading, writing and deleting manifests.
+func (s *Server) serveManifest(w http.ResponseWriter, r *http.Request, repo, reference string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		m, ok := s.lookupManifest(repo, reference)
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(m.data)))
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(m.data).String())
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(m.data)
+		}
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+			return
+		}
+		tag := reference
+		if d, err := digest.Parse(reference); err == nil {
+			if d != digest.FromBytes(data) {
+				writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "manifest digest does not match the reference")
+				return
+			}
+			tag = ""
+		}
+		d := s.putManifest(repo, tag, data, r.Header.Get("Content-Type"))
+		var parsed imgspecv1.Manifest
+		if s.options.Referrers && json.Unmarshal(data, &parsed) == nil && parsed.Subject != nil {
+			w.Header().Set("OCI-Subject", parsed.Subject.Digest.String())
+		}
+		w.Header().Set("Location", "/v2/"+repo+"/manifests/"+d.String())
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		rep, ok := s.repositories[repo]
+		if !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		if _, ok := rep.tags[reference]; ok {
+			delete(rep.tags, reference)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		d := digest.Digest(reference)
+		if _, ok := rep.manifests[d]; !ok {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown")
+			return
+		}
+		// Like registries, delete the manifest with all tags referring to it.
+		delete(rep.manifests, d)
+		for tag, tagDigest := range rep.tags {
+			if tagDigest == d {
+				delete(rep.tags, tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+	}
+}
+
+// serveReferrers implements the referrers API, if enabled.
+func (s *Server) serveReferrers(w http.ResponseWriter, r *http.Request, repo, rest string) {
+	if !s.options.Referrers || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "UNSUPPORTED", "not found")
+		return
+	}
+	subject := digest.Digest(rest)
+	artifactType := r.URL.Query().Get("artifactType")
+	referrers := []imgspecv1.Descriptor{}
+	if rep, ok := s.repositories[repo]; ok {
+		for d, m := range rep.manifests {
+			var parsed imgspecv1.Manifest
+			if json.Unmarshal(m.data, &parsed) != nil || parsed.Subject == nil || parsed.Subject.Digest != subject {
+				continue
+			}
+			descArtifactType := parsed.ArtifactType
+			if descArtifactType == "" {
+				descArtifactType = parsed.Config.MediaType
+			}
+			if artifactType != "" && descArtifactType != artifactType {
+				continue
+			}
+			referrers = append(referrers, imgspecv1.Descriptor{
+				MediaType:    m.mediaType,
+				Digest:       d,
+				Size:         int64(len(m.data)),
+				ArtifactType: descArtifactType,
+				Annotations:  parsed.Annotations,
+			})
+		}
+	}
+	if artifactType != "" {
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	// Pagination requires a stable order.
+	slices.SortFunc(referrers, func(a, b imgspecv1.Descriptor) int { return strings.Compare(a.Digest.String(), b.Digest.String()) })
+	if pageSize := s.options.ReferrersPageSize; pageSize > 0 {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start := min(page*pageSize, len(referrers))
+		end := min(start+pageSize, len(referrers))
+		if end < len(referrers) {
+			next := r.URL.Query()
+			next.Set("page", strconv.Itoa(page+1))
+			w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+		}
+		referrers = referrers[start:end]
+	}
+	writeJSON(w, imgspecv1.MediaTypeImageIndex, imgspecv1.Index{
+		Versioned: imgspec.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: referrers,
+	})
+}
+
+// serveTags lists tags in a repository.
+func (s *Server) serveTags(w http.ResponseWriter, r *http.Request, repo, rest string) {
+	if rest != "" || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "UNSUPPORTED", "not found")
+		return
+	}
+	if _, ok := s.repositories[repo]; !ok {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository name not known to registry")
+		return
+	}
+	writeJSON(w, "application/json", map[string]any{"name": repo, "tags": s.sortedTags(repo)})
+}
@@ -0,0 +1,160 @@
+package registrytest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/memory"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSystemContext returns a SystemContext for accessing server, independent of the host’s configuration.
+func testSystemContext(t *testing.T, server *Server) *types.SystemContext {
+	registriesConf := filepath.Join(t.TempDir(), "registries.conf")
+	err := os.WriteFile(registriesConf, []byte{}, 0o600)
+	require.NoError(t, err)
+	return &types.SystemContext{
+		RegistriesDirPath:           "/this/does/not/exist",
+		DockerPerHostCertDirPath:    "/this/does/not/exist",
+		SystemRegistriesConfPath:    registriesConf,
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		DockerAuthConfig:            &types.DockerAuthConfig{Username: "user", Password: "pass"},
+	}
+}
+
+// putMemoryImage stores a single-layer image in the memory: transport as name.
+func putMemoryImage(t *testing.T, name string) memory.Image {
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	var layerBuffer bytes.Buffer
+	gz := gzip.NewWriter(&layerBuffer)
+	_, err := gz.Write([]byte("this is not really a layer"))
+	require.NoError(t, err)
+	err = gz.Close()
+	require.NoError(t, err)
+	layer := layerBuffer.Bytes()
+	img := memory.Image{
+		Manifest: []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `",` +
+			`"config":{"mediaType":"` + imgspecv1.MediaTypeImageConfig + `","digest":"` + digest.FromBytes(config).String() + `","size":` + strconv.Itoa(len(config)) + `},` +
+			`"layers":[{"mediaType":"` + imgspecv1.MediaTypeImageLayerGzip + `","digest":"` + digest.FromBytes(layer).String() + `","size":` + strconv.Itoa(len(layer)) + `}]}`),
+		Blobs: map[digest.Digest][]byte{
+			digest.FromBytes(config): config,
+			digest.FromBytes(layer):  layer,
+		},
+	}
+	err = memory.Put(name, img)
+	require.NoError(t, err)
+	t.Cleanup(memory.Reset)
+	return img
+}
+
+func TestServerCopy(t *testing.T) {
+	server := NewServer(Options{TokenAuth: true, Username: "user", Password: "pass"})
+	defer server.Close()
+	sys := testSystemContext(t, server)
+	img := putMemoryImage(t, "source")
+
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
+	})
+	require.NoError(t, err)
+	defer func() { _ = policyContext.Destroy() }()
+
+	srcRef, err := memory.NewReference("source")
+	require.NoError(t, err)
+	registryRef, err := docker.ParseReference("//" + server.Host + "/ns/app:v1")
+	require.NoError(t, err)
+	_, err = copy.Image(context.Background(), policyContext, registryRef, srcRef, &copy.Options{DestinationCtx: sys})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"v1"}, server.Tags("ns/app"))
+	m, mediaType, ok := server.Manifest("ns/app", "v1")
+	require.True(t, ok)
+	assert.Equal(t, img.Manifest, m)
+	assert.Equal(t, imgspecv1.MediaTypeImageManifest, mediaType)
+	for d, data := range img.Blobs {
+		blob, ok := server.Blob(d)
+		require.True(t, ok)
+		assert.Equal(t, data, blob)
+	}
+
+	destRef, err := memory.NewReference("destination")
+	require.NoError(t, err)
+	_, err = copy.Image(context.Background(), policyContext, destRef, registryRef, &copy.Options{SourceCtx: sys})
+	require.NoError(t, err)
+	copied, ok := memory.Lookup("destination")
+	require.True(t, ok)
+	assert.Equal(t, img.Manifest, copied.Manifest)
+	assert.Equal(t, img.Blobs, copied.Blobs)
+
+	// Invalid credentials are rejected.
+	sys.DockerAuthConfig = &types.DockerAuthConfig{Username: "user", Password: "wrong"}
+	_, err = copy.Image(context.Background(), policyContext, destRef, registryRef, &copy.Options{SourceCtx: sys})
+	assert.Error(t, err)
+}
+
+func TestServerInjectFault(t *testing.T) {
+	server := NewServer(Options{
+		InjectFault: func(r *http.Request) int {
+			if r.Method == http.MethodGet && filepath.Base(filepath.Dir(r.URL.Path)) == "manifests" {
+				return http.StatusServiceUnavailable
+			}
+			return 0
+		},
+	})
+	defer server.Close()
+	server.PutManifest("ns/app", "v1", []byte("{}"), imgspecv1.MediaTypeImageManifest)
+
+	res, err := http.Get(server.URL + "/v2/ns/app/manifests/v1")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	res, err = http.Head(server.URL + "/v2/ns/app/manifests/v1")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 2, server.Requests())
+}
+
+func TestServerReferrers(t *testing.T) {
+	subject := []byte(`{"schemaVersion":2}`)
+	referrer := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `","artifactType":"application/example",` +
+		`"config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},` +
+		`"subject":{"mediaType":"` + imgspecv1.MediaTypeImageManifest + `","digest":"` + digest.FromBytes(subject).String() + `","size":` + strconv.Itoa(len(subject)) + `}}`)
+
+	for _, enabled := range []bool{true, false} {
+		server := NewServer(Options{Referrers: enabled})
+		defer server.Close()
+		server.PutManifest("ns/app", "v1", subject, imgspecv1.MediaTypeImageManifest)
+		server.PutManifest("ns/app", "", referrer, imgspecv1.MediaTypeImageManifest)
+
+		res, err := http.Get(server.URL + "/v2/ns/app/referrers/" + digest.FromBytes(subject).String())
+		require.NoError(t, err)
+		defer res.Body.Close()
+		if !enabled {
+			assert.Equal(t, http.StatusNotFound, res.StatusCode)
+			continue
+		}
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var index imgspecv1.Index
+		err = json.NewDecoder(res.Body).Decode(&index)
+		require.NoError(t, err)
+		require.Len(t, index.Manifests, 1)
+		assert.Equal(t, digest.FromBytes(referrer), index.Manifests[0].Digest)
+		assert.Equal(t, "application/example", index.Manifests[0].ArtifactType)
+	}
+}
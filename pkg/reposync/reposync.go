@@ -0,0 +1,311 @@
+// Package reposync synchronizes repositories between container registries, copying all (or selected) tags
+// of a source repository to a destination repository, like "skopeo sync" does.
+package reposync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/multierr"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// Options allows supplying non-default configuration modifying the behavior of Repository.
+type Options struct {
+	// Options used for each copy.Image call; may be nil, in which case copy.CopyAllImages is used.
+	// CopyOptions.SourceCtx is also used to list and resolve tags in the source repository,
+	// and CopyOptions.DestinationCtx to resolve tags in the destination repository.
+	CopyOptions *copy.Options
+	// If not nil, only tags for which TagFilter returns true are synchronized.
+	TagFilter func(tag string) bool
+	// The maximum number of images synchronized concurrently; values < 1 mean 1.
+	Concurrency int
+	// The number of times synchronizing an image is retried after a failure, waiting RetryDelay between attempts.
+	MaxRetries int
+	RetryDelay time.Duration
+	// If true, images are copied even if the destination already contains a manifest with the same digest as the source.
+	Force bool
+	// If not nil, called after each image is processed.  Calls are serialized, but may happen from different goroutines.
+	Progress func(ImageResult)
+}
+
+// ImageStatus is the outcome of synchronizing a single image.
+type ImageStatus int
+
+const (
+	// ImageCopied means the image was copied to the destination.
+	ImageCopied ImageStatus = iota
+	// ImageUpToDate means the destination already contained an image with the same manifest digest, so nothing was copied.
+	ImageUpToDate
+	// ImageFailed means the image could not be synchronized; see ImageResult.Err.
+	ImageFailed
+)
+
+// String returns a human-readable description of s.
+func (s ImageStatus) String() string {
+	switch s {
+	case ImageCopied:
+		return "copied"
+	case ImageUpToDate:
+		return "up to date"
+	case ImageFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("ImageStatus(%d)", int(s))
+	}
+}
+
+// ImageResult describes the outcome of synchronizing a single tag.
+type ImageResult struct {
+	Tag         string
+	Source      types.ImageReference
+	Destination types.ImageReference
+	Status      ImageStatus
+	// The digest of the manifest written to the destination for ImageCopied, or of the source manifest for ImageUpToDate;
+	// may be empty for ImageFailed.
+	Digest   digest.Digest
+	Attempts int   // The number of attempts made
+	Err      error // Set only for ImageFailed
+}
+
+// Report summarizes the outcome of Repository.
+type Report struct {
+	// Results, in the order of the synchronized tags in the source repository.
+	Results []ImageResult
+}
+
+// Count returns the number of images in r with status.
+func (r *Report) Count(status ImageStatus) int {
+	res := 0
+	for i := range r.Results {
+		if r.Results[i].Status == status {
+			res++
+		}
+	}
+	return res
+}
+
+// Repository synchronizes tags from srcRepo to destRepo, both repositories in container registries accessed
+// using the docker: transport, checking each image against policy.
+//
+// If srcRepo contains a tag, only that tag is synchronized; otherwise all tags in srcRepo accepted by options.TagFilter are.
+// destRepo must not contain a tag or a digest; images are copied to the same tags in destRepo.
+// Unless options.Force is set, images whose manifest digest in destRepo matches the one in srcRepo are not copied;
+// note that if options.CopyOptions causes copy.Image to modify manifests (e.g. to select a single platform or
+// to convert formats), the digests never match, and images are always copied.
+//
+// Failures to synchronize individual images do not stop the synchronization of other images; they are recorded
+// in the returned Report, and summarized in the returned error.
+// The returned Report is nil only if the synchronization could not be started at all.
+func Repository(ctx context.Context, policy *signature.Policy, destRepo, srcRepo reference.Named, options *Options) (*Report, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	copyOptions := options.CopyOptions
+	if copyOptions == nil {
+		copyOptions = &copy.Options{ImageListSelection: copy.CopyAllImages}
+	}
+	if _, isDigested := srcRepo.(reference.Canonical); isDigested {
+		return nil, fmt.Errorf("synchronizing %s: digest references are not supported", srcRepo.String())
+	}
+	if !reference.IsNameOnly(destRepo) {
+		return nil, fmt.Errorf("synchronizing to %s: destination must be a repository, without a tag or digest", destRepo.String())
+	}
+
+	tags, err := sourceTags(ctx, copyOptions.SourceCtx, srcRepo, options.TagFilter)
+	if err != nil {
+		return nil, err
+	}
+	srcResolver, err := docker.NewDigestResolver(copyOptions.SourceCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer srcResolver.Close()
+	destResolver, err := docker.NewDigestResolver(copyOptions.DestinationCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer destResolver.Close()
+
+	s := &syncer{
+		policy:       policy,
+		copyOptions:  copyOptions,
+		options:      options,
+		srcResolver:  srcResolver,
+		destResolver: destResolver,
+	}
+	report := s.run(ctx, tags, func(ctx context.Context, policyContext *signature.PolicyContext, tag string) ImageResult {
+		return s.syncTag(ctx, policyContext, destRepo, srcRepo, tag)
+	})
+	if failed := report.Count(ImageFailed); failed != 0 {
+		errs := []error{}
+		for _, r := range report.Results {
+			if r.Status == ImageFailed {
+				errs = append(errs, fmt.Errorf("%s: %w", r.Tag, r.Err))
+			}
+		}
+		return report, multierr.Format(fmt.Sprintf("synchronizing %d of %d images from %s failed:\n* ", failed, len(report.Results), srcRepo.Name()),
+			"\n* ", "", errs)
+	}
+	return report, nil
+}
+
+// sourceTags returns the tags to synchronize from srcRepo.
+func sourceTags(ctx context.Context, sys *types.SystemContext, srcRepo reference.Named, filter func(string) bool) ([]string, error) {
+	if tagged, ok := srcRepo.(reference.NamedTagged); ok {
+		return []string{tagged.Tag()}, nil
+	}
+	ref, err := docker.NewReference(reference.TagNameOnly(srcRepo))
+	if err != nil {
+		return nil, err
+	}
+	allTags, err := docker.GetRepositoryTags(ctx, sys, ref)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags of %s: %w", srcRepo.Name(), err)
+	}
+	tags := []string{}
+	for _, tag := range allTags {
+		if filter == nil || filter(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	log.DebugfContext(ctx, "Synchronizing %d of %d tags of %s", len(tags), len(allTags), srcRepo.Name())
+	return tags, nil
+}
+
+// syncer holds the state of a single Repository call.
+type syncer struct {
+	policy       *signature.Policy
+	copyOptions  *copy.Options
+	options      *Options
+	srcResolver  *docker.DigestResolver
+	destResolver *docker.DigestResolver
+}
+
+// run calls process for each of tags, with the configured concurrency and retries, and returns a report of the results.
+// Each worker uses its own PolicyContext, because a PolicyContext can not be used concurrently.
+func (s *syncer) run(ctx context.Context, tags []string, process func(ctx context.Context, policyContext *signature.PolicyContext, tag string) ImageResult) *Report {
+	report := &Report{Results: make([]ImageResult, len(tags))}
+	workers := min(max(s.options.Concurrency, 1), len(tags))
+
+	indices := make(chan int)
+	progressMutex := sync.Mutex{} // Serializes calls to s.options.Progress
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			policyContext, err := signature.NewPolicyContext(s.policy)
+			if err == nil {
+				defer func() {
+					if err := policyContext.Destroy(); err != nil {
+						log.DebugfContext(ctx, "Error destroying policy context: %v", err)
+					}
+				}()
+			}
+			for index := range indices {
+				var res ImageResult
+				if err != nil {
+					res = ImageResult{Tag: tags[index], Status: ImageFailed, Err: fmt.Errorf("creating policy context: %w", err)}
+				} else {
+					res = s.withRetries(ctx, func(ctx context.Context) ImageResult {
+						return process(ctx, policyContext, tags[index])
+					})
+				}
+				report.Results[index] = res
+				if s.options.Progress != nil {
+					progressMutex.Lock()
+					s.options.Progress(res)
+					progressMutex.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range tags {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return report
+}
+
+// withRetries calls attempt until it succeeds, up to 1+s.options.MaxRetries times, and returns its last result.
+func (s *syncer) withRetries(ctx context.Context, attempt func(ctx context.Context) ImageResult) ImageResult {
+	var res ImageResult
+	for attempts := 1; ; attempts++ {
+		res = attempt(ctx)
+		res.Attempts = attempts
+		if res.Status != ImageFailed || attempts > s.options.MaxRetries || ctx.Err() != nil {
+			return res
+		}
+		log.DebugfContext(ctx, "Synchronizing tag %s failed (attempt %d of %d), retrying: %v", res.Tag, attempts, s.options.MaxRetries+1, res.Err)
+		select {
+		case <-time.After(s.options.RetryDelay):
+		case <-ctx.Done():
+			return res
+		}
+	}
+}
+
+// syncTag synchronizes a single tag from srcRepo to destRepo.
+func (s *syncer) syncTag(ctx context.Context, policyContext *signature.PolicyContext, destRepo, srcRepo reference.Named, tag string) ImageResult {
+	res := ImageResult{Tag: tag, Status: ImageFailed}
+	fail := func(err error) ImageResult {
+		res.Err = err
+		return res
+	}
+
+	srcTagged, err := reference.WithTag(reference.TrimNamed(srcRepo), tag)
+	if err != nil {
+		return fail(err)
+	}
+	destTagged, err := reference.WithTag(destRepo, tag)
+	if err != nil {
+		return fail(err)
+	}
+	res.Source, err = docker.NewReference(srcTagged)
+	if err != nil {
+		return fail(err)
+	}
+	res.Destination, err = docker.NewReference(destTagged)
+	if err != nil {
+		return fail(err)
+	}
+
+	if !s.options.Force {
+		srcDigest, err := s.srcResolver.Resolve(ctx, res.Source)
+		if err != nil {
+			return fail(fmt.Errorf("resolving %s: %w", srcTagged.String(), err))
+		}
+		destDigest, err := s.destResolver.Resolve(ctx, res.Destination)
+		switch {
+		case err != nil:
+			// Most likely the image does not exist yet; if the registry is unreachable, the copy will fail as well.
+			log.DebugfContext(ctx, "Resolving %s failed, copying the image: %v", destTagged.String(), err)
+		case destDigest == srcDigest:
+			res.Status = ImageUpToDate
+			res.Digest = srcDigest
+			return res
+		}
+	}
+
+	copiedManifest, err := copy.Image(ctx, policyContext, res.Destination, res.Source, s.copyOptions)
+	if err != nil {
+		return fail(fmt.Errorf("copying %s to %s: %w", srcTagged.String(), destTagged.String(), err))
+	}
+	res.Digest, err = manifest.Digest(copiedManifest)
+	if err != nil {
+		return fail(err)
+	}
+	res.Status = ImageCopied
+	return res
+}
@@ -0,0 +1,143 @@
+package reposync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryInvalidReferences(t *testing.T) {
+	policy := &signature.Policy{Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()}}
+	for _, c := range []struct{ dest, src string }{
+		{"registry.example.com/dest", "registry.example.com/src@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{"registry.example.com/dest:tag", "registry.example.com/src"},
+		{"registry.example.com/dest@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "registry.example.com/src"},
+	} {
+		dest, err := reference.ParseNormalizedNamed(c.dest)
+		require.NoError(t, err)
+		src, err := reference.ParseNormalizedNamed(c.src)
+		require.NoError(t, err)
+		report, err := Repository(context.Background(), policy, dest, src, nil)
+		assert.Error(t, err, c.dest)
+		assert.Nil(t, report, c.dest)
+	}
+}
+
+func TestSourceTags(t *testing.T) {
+	// A tagged reference does not need to contact the registry, and is not filtered.
+	src, err := reference.ParseNormalizedNamed("registry.example.com/src:v1")
+	require.NoError(t, err)
+	tags, err := sourceTags(context.Background(), nil, src, func(string) bool { return false })
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+}
+
+func TestSyncerRun(t *testing.T) {
+	policy := &signature.Policy{Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()}}
+	tags := []string{"a", "b", "c", "d", "e", "f"}
+
+	for _, concurrency := range []int{0, 1, 3, 100} {
+		var running, maxRunning atomic.Int32
+		progress := []string{}
+		s := &syncer{
+			policy: policy,
+			options: &Options{
+				Concurrency: concurrency,
+				Progress:    func(res ImageResult) { progress = append(progress, res.Tag) }, // Calls are serialized
+			},
+		}
+		report := s.run(context.Background(), tags, func(ctx context.Context, policyContext *signature.PolicyContext, tag string) ImageResult {
+			assert.NotNil(t, policyContext)
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if tag == "c" {
+				return ImageResult{Tag: tag, Status: ImageFailed, Err: errors.New("failed")}
+			}
+			return ImageResult{Tag: tag, Status: ImageCopied}
+		})
+		require.Len(t, report.Results, len(tags))
+		for i, tag := range tags {
+			assert.Equal(t, tag, report.Results[i].Tag)
+		}
+		assert.Equal(t, 5, report.Count(ImageCopied))
+		assert.Equal(t, 1, report.Count(ImageFailed))
+		assert.Equal(t, 0, report.Count(ImageUpToDate))
+		assert.ElementsMatch(t, tags, progress)
+		assert.LessOrEqual(t, maxRunning.Load(), int32(max(concurrency, 1)), fmt.Sprintf("concurrency %d", concurrency))
+	}
+
+	// No tags
+	s := &syncer{policy: policy, options: &Options{Concurrency: 4}}
+	report := s.run(context.Background(), nil, func(ctx context.Context, policyContext *signature.PolicyContext, tag string) ImageResult {
+		require.FailNow(t, "unexpected call")
+		return ImageResult{}
+	})
+	assert.Empty(t, report.Results)
+}
+
+func TestSyncerWithRetries(t *testing.T) {
+	for _, c := range []struct {
+		maxRetries, failures, expectedAttempts int
+		expectedStatus                         ImageStatus
+	}{
+		{0, 0, 1, ImageCopied},
+		{0, 1, 1, ImageFailed},
+		{2, 1, 2, ImageCopied},
+		{2, 2, 3, ImageCopied},
+		{2, 5, 3, ImageFailed},
+	} {
+		s := &syncer{options: &Options{MaxRetries: c.maxRetries, RetryDelay: time.Millisecond}}
+		calls := 0
+		res := s.withRetries(context.Background(), func(ctx context.Context) ImageResult {
+			calls++
+			if calls <= c.failures {
+				return ImageResult{Tag: "tag", Status: ImageFailed, Err: errors.New("failed")}
+			}
+			return ImageResult{Tag: "tag", Status: ImageCopied}
+		})
+		assert.Equal(t, c.expectedStatus, res.Status, "%#v", c)
+		assert.Equal(t, c.expectedAttempts, res.Attempts, "%#v", c)
+		assert.Equal(t, c.expectedAttempts, calls, "%#v", c)
+	}
+
+	// Retries stop when the context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &syncer{options: &Options{MaxRetries: 10, RetryDelay: time.Hour}}
+	calls := 0
+	res := s.withRetries(ctx, func(ctx context.Context) ImageResult {
+		calls++
+		cancel()
+		return ImageResult{Tag: "tag", Status: ImageFailed, Err: errors.New("failed")}
+	})
+	assert.Equal(t, ImageFailed, res.Status)
+	assert.Equal(t, 1, calls)
+}
+
+func TestImageStatusString(t *testing.T) {
+	for _, c := range []struct {
+		status   ImageStatus
+		expected string
+	}{
+		{ImageCopied, "copied"},
+		{ImageUpToDate, "up to date"},
+		{ImageFailed, "failed"},
+		{ImageStatus(42), "ImageStatus(42)"},
+	} {
+		assert.Equal(t, c.expected, c.status.String())
+	}
+}
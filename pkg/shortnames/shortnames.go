@@ -79,9 +79,11 @@ func splitUserInput(named reference.Named) (isTagged bool, isDigested bool, norm
 	return
 }
 
-// Add records the specified name-value pair as a new short-name alias to the
-// user-specific aliases.conf.  It may override an existing alias for `name`.
-func Add(ctx *types.SystemContext, name string, value reference.Named) error {
+// validateAliasName returns an error if name can’t be used as the name of a short-name alias.
+func validateAliasName(name string) error {
+	if strings.Contains(name, "*") {
+		return nil // An alias pattern; validated by sysregistriesv2.
+	}
 	isShort, _, err := parseUnnormalizedShortName(name)
 	if err != nil {
 		return err
@@ -89,21 +91,28 @@ func Add(ctx *types.SystemContext, name string, value reference.Named) error {
 	if !isShort {
 		return fmt.Errorf("%q is not a short name", name)
 	}
+	return nil
+}
+
+// Add records the specified name-value pair as a new short-name alias to the
+// user-specific aliases.conf.  It may override an existing alias for `name`.
+// `name` may be an alias pattern, where "*" path components match any single
+// path component (e.g. "*/golang"); exact aliases have precedence over patterns.
+func Add(ctx *types.SystemContext, name string, value reference.Named) error {
+	if err := validateAliasName(name); err != nil {
+		return err
+	}
 	return sysregistriesv2.AddShortNameAlias(ctx, name, value.String())
 }
 
-// Remove clears the short-name alias for the specified name.  It throws an
+// Remove clears the short-name alias, or alias pattern, for the specified name.  It throws an
 // error in case name does not exist in the machine-generated
 // short-name-alias.conf.  In such case, the alias must be specified in one of
 // the registries.conf files, which is the users' responsibility.
 func Remove(ctx *types.SystemContext, name string) error {
-	isShort, _, err := parseUnnormalizedShortName(name)
-	if err != nil {
+	if err := validateAliasName(name); err != nil {
 		return err
 	}
-	if !isShort {
-		return fmt.Errorf("%q is not a short name", name)
-	}
 	return sysregistriesv2.RemoveShortNameAlias(ctx, name)
 }
 
@@ -259,6 +259,36 @@ func removeAlias(t *testing.T, sys *types.SystemContext, name string, mustFail b
 	}
 }
 
+func TestResolveWithAliasPatterns(t *testing.T) {
+	tmp, err := os.CreateTemp("", "aliases.conf")
+	require.NoError(t, err)
+	defer os.Remove(tmp.Name())
+
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    "testdata/aliases.conf",
+		SystemRegistriesConfDirPath: "testdata/this-does-not-exist",
+		UserShortNameAliasConfPath:  tmp.Name(),
+	}
+	_, err = sysregistriesv2.TryUpdatingCache(sys)
+	require.NoError(t, err)
+
+	addAlias(t, sys, "*/golang", "docker.io/library/golang", false)
+	addAlias(t, sys, "go*", "docker.io/library/golang", true)
+	for _, c := range []struct{ input, value string }{
+		{"library/golang", "docker.io/library/golang:latest"},
+		{"someone/golang:1.21", "docker.io/library/golang:1.21"},
+	} {
+		resolved, err := Resolve(sys, c.input)
+		require.NoError(t, err, c.input)
+		require.Len(t, resolved.PullCandidates, 1, c.input)
+		assert.Equal(t, c.value, resolved.PullCandidates[0].Value.String(), c.input)
+		assert.Equal(t, rationaleAlias, resolved.rationale, c.input)
+	}
+
+	require.NoError(t, Remove(sys, "*/golang"))
+	require.Error(t, Remove(sys, "*/golang"))
+}
+
 func TestResolveWithDropInConfigs(t *testing.T) {
 	tmp, err := os.CreateTemp("", "aliases.conf")
 	require.NoError(t, err)
@@ -0,0 +1,199 @@
+// Package staging implements a two-phase copy of images: an image is first copied to a staging location
+// (e.g. a separate tag in the destination repository, or a separate name in the destination OCI layout),
+// where it is not visible under its final name and can be checked out of band, and it is then either
+// promoted to its final destination in a cheap second step, or discarded.
+package staging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// imageState is the lifecycle state of an Image.
+type imageState int
+
+const (
+	stateStaged imageState = iota
+	statePromoted
+	stateAborted
+)
+
+// Image is an image copied to a staging location by Stage, and not yet promoted or aborted.
+// An Image can not be used concurrently.
+type Image struct {
+	Staging     types.ImageReference // The staging location
+	Destination types.ImageReference // The final destination
+	// The manifest written to Staging, and its digest; promotion fails if the staged manifest has changed since.
+	Manifest       []byte
+	ManifestDigest digest.Digest
+
+	sys          *types.SystemContext // Used to access both Staging and Destination
+	reportWriter io.Writer
+	state        imageState
+}
+
+// Stage copies srcRef to stagingRef, checking it against policyContext, in preparation for promoting it to destRef.
+// The copy uses options (which may be nil) as copy.Image would; options.DestinationCtx is also used for the later
+// promotion and cleanup.
+//
+// stagingRef and destRef must use the same transport, and should refer to the same storage location
+// (e.g. to two tags in the same registry repository), so that promotion does not need to copy any blobs.
+// The caller is expected to inspect the staged image, and then call exactly one of Image.Promote or Image.Abort.
+func Stage(ctx context.Context, policyContext *signature.PolicyContext, destRef, stagingRef, srcRef types.ImageReference, options *copy.Options) (*Image, error) {
+	if stagingRef.Transport().Name() != destRef.Transport().Name() {
+		return nil, fmt.Errorf("staging location %s and destination %s must use the same transport",
+			transports.ImageName(stagingRef), transports.ImageName(destRef))
+	}
+	if stagingRef.StringWithinTransport() == destRef.StringWithinTransport() {
+		return nil, fmt.Errorf("staging location and destination must differ, both are %s", transports.ImageName(destRef))
+	}
+	if options == nil {
+		options = &copy.Options{}
+	}
+
+	copiedManifest, err := copy.Image(ctx, policyContext, stagingRef, srcRef, options)
+	if err != nil {
+		return nil, fmt.Errorf("staging %s at %s: %w", transports.ImageName(srcRef), transports.ImageName(stagingRef), err)
+	}
+	manifestDigest, err := manifest.Digest(copiedManifest)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		Staging:        stagingRef,
+		Destination:    destRef,
+		Manifest:       copiedManifest,
+		ManifestDigest: manifestDigest,
+		sys:            options.DestinationCtx,
+		reportWriter:   options.ReportWriter,
+		state:          stateStaged,
+	}, nil
+}
+
+// Promote copies the staged image to its destination, without modifying it (so it does not need to be checked
+// against a signature policy again), and removes the staged copy, if the transport allows doing that safely.
+//
+// Promote fails if the manifest at the staging location has changed since Stage.
+// With the docker: transport, the staging tag is not removed: registries only support deleting manifests, not tags,
+// so deleting the staged manifest would delete the promoted image as well.
+func (i *Image) Promote(ctx context.Context) error {
+	if err := i.checkStaged(); err != nil {
+		return err
+	}
+	if err := i.verifyStagedManifest(ctx); err != nil {
+		return err
+	}
+
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()}})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := policyContext.Destroy(); err != nil {
+			log.DebugfContext(ctx, "Error destroying policy context: %v", err)
+		}
+	}()
+	promotedManifest, err := copy.Image(ctx, policyContext, i.Destination, i.Staging, &copy.Options{
+		SourceCtx:          i.sys,
+		DestinationCtx:     i.sys,
+		ReportWriter:       i.reportWriter,
+		ImageListSelection: copy.CopyAllImages,
+		PreserveDigests:    true,
+	})
+	if err != nil {
+		return fmt.Errorf("promoting %s to %s: %w", transports.ImageName(i.Staging), transports.ImageName(i.Destination), err)
+	}
+	// The staged image could have been modified after verifyStagedManifest.
+	promotedDigest, err := manifest.Digest(promotedManifest)
+	if err != nil {
+		return err
+	}
+	if promotedDigest != i.ManifestDigest {
+		return fmt.Errorf("promoting %s: the staged image was modified during promotion, %s now has manifest %s instead of %s",
+			transports.ImageName(i.Staging), transports.ImageName(i.Destination), promotedDigest, i.ManifestDigest)
+	}
+	i.state = statePromoted
+
+	if i.Staging.Transport().Name() == docker.Transport.Name() {
+		log.DebugfContext(ctx, "Not removing staging tag %s, deleting it would delete the promoted image as well", transports.ImageName(i.Staging))
+		return nil
+	}
+	if err := i.Staging.DeleteImage(ctx, i.sys); err != nil {
+		return fmt.Errorf("image promoted to %s, but removing the staged copy at %s failed: %w",
+			transports.ImageName(i.Destination), transports.ImageName(i.Staging), err)
+	}
+	return nil
+}
+
+// Abort removes the staged image, without promoting it.
+//
+// With the docker: transport, registries only support deleting manifests, not tags; so deleting the staged manifest
+// also deletes any other tags referring to the same manifest digest in that repository. Abort refuses to delete the
+// manifest if the destination tag refers to it.
+func (i *Image) Abort(ctx context.Context) error {
+	if err := i.checkStaged(); err != nil {
+		return err
+	}
+	if i.Staging.Transport().Name() == docker.Transport.Name() {
+		destDigest, err := docker.GetDigest(ctx, i.sys, i.Destination)
+		if err == nil && destDigest == i.ManifestDigest {
+			return fmt.Errorf("not deleting staged image %s, deleting it would also delete %s, which refers to the same manifest %s",
+				transports.ImageName(i.Staging), transports.ImageName(i.Destination), i.ManifestDigest)
+		}
+	}
+	if err := i.Staging.DeleteImage(ctx, i.sys); err != nil {
+		return fmt.Errorf("removing staged image %s: %w", transports.ImageName(i.Staging), err)
+	}
+	i.state = stateAborted
+	return nil
+}
+
+// checkStaged returns an error if i has already been promoted or aborted.
+func (i *Image) checkStaged() error {
+	switch i.state {
+	case stateStaged:
+		return nil
+	case statePromoted:
+		return errors.New("staged image has already been promoted")
+	case stateAborted:
+		return errors.New("staged image has already been aborted")
+	default: // This should never happen
+		return fmt.Errorf("Internal error: unexpected staged image state %d", i.state)
+	}
+}
+
+// verifyStagedManifest returns an error if the manifest at i.Staging is not the one written by Stage.
+func (i *Image) verifyStagedManifest(ctx context.Context) (retErr error) {
+	src, err := i.Staging.NewImageSource(ctx, i.sys)
+	if err != nil {
+		return fmt.Errorf("reading staged image %s: %w", transports.ImageName(i.Staging), err)
+	}
+	defer func() {
+		if err := src.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	m, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("reading manifest of staged image %s: %w", transports.ImageName(i.Staging), err)
+	}
+	matches, err := manifest.MatchesDigest(m, i.ManifestDigest)
+	if err != nil {
+		return err
+	}
+	if !matches {
+		return fmt.Errorf("staged image %s has been modified, its manifest no longer matches %s", transports.ImageName(i.Staging), i.ManifestDigest)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package staging
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stagingImageName = "latest-staging"
+
+var (
+	testConfig = []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	testLayer  = []byte("layer contents")
+)
+
+// createSourceImage creates a dir: image with testConfig and testLayer, and returns a reference to it.
+func createSourceImage(t *testing.T) types.ImageReference {
+	ref, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	for _, blob := range [][]byte{testConfig, testLayer} {
+		_, err := dest.PutBlob(context.Background(), bytes.NewReader(blob), types.BlobInfo{Size: -1}, memory.New(), false)
+		require.NoError(t, err)
+	}
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"` + imgspecv1.MediaTypeImageManifest + `",` +
+		`"config":{"mediaType":"` + imgspecv1.MediaTypeImageConfig + `","digest":"` + digest.FromBytes(testConfig).String() + `","size":` + strconv.Itoa(len(testConfig)) + `},` +
+		`"layers":[{"mediaType":"` + imgspecv1.MediaTypeImageLayer + `","digest":"` + digest.FromBytes(testLayer).String() + `","size":` + strconv.Itoa(len(testLayer)) + `}]}`)
+	err = dest.PutManifest(context.Background(), manifest, nil)
+	require.NoError(t, err)
+	err = dest.Commit(context.Background(), nil)
+	require.NoError(t, err)
+	return ref
+}
+
+func testPolicyContext(t *testing.T) *signature.PolicyContext {
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = policyContext.Destroy() })
+	return policyContext
+}
+
+// stageTestImage stages the test image in a new OCI layout, and returns the staged image and the layout directory.
+func stageTestImage(t *testing.T) (*Image, string) {
+	srcRef := createSourceImage(t)
+	dir := t.TempDir()
+	destRef, err := layout.NewReference(dir, "latest")
+	require.NoError(t, err)
+	stagingRef, err := layout.NewReference(dir, stagingImageName)
+	require.NoError(t, err)
+	img, err := Stage(context.Background(), testPolicyContext(t), destRef, stagingRef, srcRef, &copy.Options{
+		DestinationCtx: &types.SystemContext{BlobInfoCacheDir: t.TempDir()},
+	})
+	require.NoError(t, err)
+	return img, dir
+}
+
+// layoutImageNames returns the image names in the OCI layout in dir.
+func layoutImageNames(t *testing.T, dir string) []string {
+	names, err := layout.ListImageNames(dir)
+	require.NoError(t, err)
+	return names
+}
+
+func TestStage(t *testing.T) {
+	img, dir := stageTestImage(t)
+	assert.Equal(t, digest.FromBytes(img.Manifest), img.ManifestDigest)
+	assert.Equal(t, []string{stagingImageName}, layoutImageNames(t, dir))
+
+	srcRef := createSourceImage(t)
+	otherDir := t.TempDir()
+	ociRef, err := layout.NewReference(otherDir, "latest")
+	require.NoError(t, err)
+	dirRef, err := directory.NewReference(t.TempDir())
+	require.NoError(t, err)
+	for _, c := range []struct {
+		name                string
+		destRef, stagingRef types.ImageReference
+	}{
+		{"different transports", ociRef, dirRef},
+		{"same reference", ociRef, ociRef},
+	} {
+		_, err := Stage(context.Background(), testPolicyContext(t), c.destRef, c.stagingRef, srcRef, nil)
+		assert.Error(t, err, c.name)
+	}
+	_, err = os.Stat(filepath.Join(otherDir, "index.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestImagePromote(t *testing.T) {
+	img, dir := stageTestImage(t)
+	err := img.Promote(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"latest"}, layoutImageNames(t, dir))
+	desc, err := layout.LoadManifestDescriptor(img.Destination)
+	require.NoError(t, err)
+	assert.Equal(t, img.ManifestDigest, desc.Digest)
+	// The blobs used by the promoted image are not removed together with the staged copy.
+	for _, blob := range []digest.Digest{img.ManifestDigest, digest.FromBytes(testConfig)} {
+		_, err := os.Stat(filepath.Join(dir, "blobs", blob.Algorithm().String(), blob.Encoded()))
+		assert.NoError(t, err, blob)
+	}
+
+	err = img.Promote(context.Background())
+	assert.Error(t, err)
+	err = img.Abort(context.Background())
+	assert.Error(t, err)
+
+	// The staged image has been modified
+	img, dir = stageTestImage(t)
+	img.ManifestDigest = digest.FromString("modified")
+	err = img.Promote(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, []string{stagingImageName}, layoutImageNames(t, dir))
+}
+
+func TestImageAbort(t *testing.T) {
+	img, dir := stageTestImage(t)
+	err := img.Abort(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, layoutImageNames(t, dir))
+	for _, blob := range []digest.Digest{img.ManifestDigest, digest.FromBytes(testConfig)} {
+		_, err := os.Stat(filepath.Join(dir, "blobs", blob.Algorithm().String(), blob.Encoded()))
+		assert.ErrorIs(t, err, os.ErrNotExist, blob)
+	}
+
+	err = img.Abort(context.Background())
+	assert.Error(t, err)
+	err = img.Promote(context.Background())
+	assert.Error(t, err)
+}
@@ -10,12 +10,12 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/multierr"
 	"github.com/containers/image/v5/internal/rootless"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/homedir"
 	"github.com/containers/storage/pkg/lockfile"
-	"github.com/sirupsen/logrus"
 )
 
 // defaultShortNameMode is the default mode of registries.conf files if the
@@ -82,11 +82,64 @@ type shortNameAliasCache struct {
 	// Note that an alias value may be nil iff it's set as an empty string
 	// in the config.
 	namedAliases map[string]alias
+	// patternAliases contains aliases with wildcards (e.g. "*/golang"), indexed by the pattern.
+	// As with namedAliases, a value may be nil.
+	patternAliases map[string]alias
+}
+
+// aliasPatternWildcard is a path component of an alias pattern which matches any single path component.
+const aliasPatternWildcard = "*"
+
+// isShortNameAliasPattern returns true if name is an alias pattern, i.e. contains wildcards.
+func isShortNameAliasPattern(name string) bool {
+	return strings.Contains(name, aliasPatternWildcard)
+}
+
+// shortNameAliasPatternMatches returns true if name matches the alias pattern.
+func shortNameAliasPatternMatches(pattern, name string) bool {
+	patternComponents := strings.Split(pattern, "/")
+	nameComponents := strings.Split(name, "/")
+	if len(patternComponents) != len(nameComponents) {
+		return false
+	}
+	for i, c := range patternComponents {
+		if c != aliasPatternWildcard && c != nameComponents[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupPattern returns the alias matching name using the most specific matching pattern, if any.
+// A pattern with fewer wildcards is more specific; if several patterns are equally specific, the
+// lexicographically first one is used, to make the choice deterministic.
+func (c *shortNameAliasCache) lookupPattern(name string) (alias, bool) {
+	bestPattern := ""
+	bestWildcards := 0
+	for pattern := range c.patternAliases {
+		if !shortNameAliasPatternMatches(pattern, name) {
+			continue
+		}
+		wildcards := strings.Count(pattern, aliasPatternWildcard)
+		if bestPattern == "" || wildcards < bestWildcards || (wildcards == bestWildcards && pattern < bestPattern) {
+			bestPattern = pattern
+			bestWildcards = wildcards
+		}
+	}
+	if bestPattern == "" {
+		return alias{}, false
+	}
+	return c.patternAliases[bestPattern], true
 }
 
 // ResolveShortNameAlias performs an alias resolution of the specified name.
-// The user-specific short-name-aliases.conf has precedence over aliases in the
-// assembled registries.conf.  It returns the possibly resolved alias or nil, a
+// Aliases are looked up in this order, using the first match:
+//  1. An exact alias in the user-specific short-name-aliases.conf
+//  2. An exact alias in the assembled registries.conf
+//  3. An alias pattern (e.g. "*/golang") in the user-specific short-name-aliases.conf
+//  4. An alias pattern in the assembled registries.conf
+//
+// It returns the possibly resolved alias or nil, a
 // human-readable description of the config where the alias is specified, and
 // an error. The origin of the config file is crucial for an improved user
 // experience such that users are able to resolve potential pull errors.
@@ -128,6 +181,12 @@ func ResolveShortNameAlias(ctx *types.SystemContext, name string) (reference.Nam
 	if resolved {
 		return alias.value, alias.configOrigin, nil
 	}
+
+	for _, cache := range []*shortNameAliasCache{aliasCache, config.aliasCache} {
+		if alias, resolved := cache.lookupPattern(name); resolved {
+			return alias.value, alias.configOrigin, nil
+		}
+	}
 	return nil, "", nil
 }
 
@@ -135,7 +194,7 @@ func ResolveShortNameAlias(ctx *types.SystemContext, name string) (reference.Nam
 // set, it adds the name-value pair as a new alias. Otherwise, it will remove
 // name from the config.
 func editShortNameAlias(ctx *types.SystemContext, name string, value *string) error {
-	if err := validateShortName(name); err != nil {
+	if err := validateShortNameOrPattern(name); err != nil {
 		return err
 	}
 	if value != nil {
@@ -186,6 +245,8 @@ func editShortNameAlias(ctx *types.SystemContext, name string, value *string) er
 
 // AddShortNameAlias adds the specified name-value pair as a new alias to the
 // user-specific aliases.conf.  It may override an existing alias for `name`.
+// `name` may be an alias pattern, where "*" path components match any single
+// path component (e.g. "*/golang").  The file is locked while it is being updated.
 //
 // Note that it’s the caller’s responsibility to pass only a repository
 // (reference.IsNameOnly) as the short name.
@@ -197,6 +258,7 @@ func AddShortNameAlias(ctx *types.SystemContext, name string, value string) erro
 // error in case name does not exist in the machine-generated
 // short-name-alias.conf.  In such case, the alias must be specified in one of
 // the registries.conf files, which is the users' responsibility.
+// `name` may be an alias pattern, as in AddShortNameAlias.
 //
 // Note that it’s the caller’s responsibility to pass only a repository
 // (reference.IsNameOnly) as the short name.
@@ -267,24 +329,57 @@ func validateShortName(name string) error {
 	return nil
 }
 
+// validateShortNameOrPattern is validateShortName, also accepting alias patterns.
+// In a pattern, wildcards must be complete path components, and at least one
+// path component must not be a wildcard.
+func validateShortNameOrPattern(name string) error {
+	if !isShortNameAliasPattern(name) {
+		return validateShortName(name)
+	}
+	components := strings.Split(name, "/")
+	literal := false
+	for i, c := range components {
+		switch {
+		case c == aliasPatternWildcard:
+			components[i] = "wildcard" // Any valid path component, to validate the rest of the pattern.
+		case strings.Contains(c, aliasPatternWildcard):
+			return fmt.Errorf("invalid short-name alias pattern %q: wildcards must be complete path components", name)
+		default:
+			literal = true
+		}
+	}
+	if !literal {
+		return fmt.Errorf("invalid short-name alias pattern %q: must contain a path component which is not a wildcard", name)
+	}
+	if err := validateShortName(strings.Join(components, "/")); err != nil {
+		return fmt.Errorf("invalid short-name alias pattern %q: %w", name, err)
+	}
+	return nil
+}
+
 // newShortNameAliasCache parses shortNameAliasConf and returns the corresponding internal
 // representation.
 func newShortNameAliasCache(path string, conf *shortNameAliasConf) (*shortNameAliasCache, error) {
 	res := shortNameAliasCache{
-		namedAliases: make(map[string]alias),
+		namedAliases:   make(map[string]alias),
+		patternAliases: make(map[string]alias),
 	}
 	errs := []error{}
 	for name, value := range conf.Aliases {
-		if err := validateShortName(name); err != nil {
+		if err := validateShortNameOrPattern(name); err != nil {
 			errs = append(errs, err)
 		}
+		aliases := res.namedAliases
+		if isShortNameAliasPattern(name) {
+			aliases = res.patternAliases
+		}
 
 		// Empty right-hand side values in config files allow to reset
 		// an alias in a previously loaded config. This way, drop-in
 		// config files from registries.conf.d can reset potentially
 		// malconfigured aliases.
 		if value == "" {
-			res.namedAliases[name] = alias{nil, path}
+			aliases[name] = alias{nil, path}
 			continue
 		}
 
@@ -294,7 +389,7 @@ func newShortNameAliasCache(path string, conf *shortNameAliasConf) (*shortNameAl
 			// whack-a-mole for the user.
 			errs = append(errs, err)
 		} else {
-			res.namedAliases[name] = alias{named, path}
+			aliases[name] = alias{named, path}
 		}
 	}
 	if len(errs) > 0 {
@@ -307,6 +402,7 @@ func newShortNameAliasCache(path string, conf *shortNameAliasConf) (*shortNameAl
 // In case of conflict, updates is preferred.
 func (c *shortNameAliasCache) updateWithConfigurationFrom(updates *shortNameAliasCache) {
 	maps.Copy(c.namedAliases, updates.namedAliases)
+	maps.Copy(c.patternAliases, updates.patternAliases)
 }
 
 func loadShortNameAliasConf(confPath string) (*shortNameAliasConf, *shortNameAliasCache, error) {
@@ -318,7 +414,7 @@ func loadShortNameAliasConf(confPath string) (*shortNameAliasConf, *shortNameAli
 		return nil, nil, fmt.Errorf("loading short-name aliases config file %q: %w", confPath, err)
 	}
 	if keys := meta.Undecoded(); len(keys) > 0 {
-		logrus.Debugf("Failed to decode keys %q from %q", keys, confPath)
+		log.Debugf("Failed to decode keys %q from %q", keys, confPath)
 	}
 
 	// Even if we don’t always need the cache, doing so validates the machine-generated config.  The
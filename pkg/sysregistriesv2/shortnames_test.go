@@ -160,6 +160,98 @@ func TestResolveShortNameAlias(t *testing.T) {
 	assert.Equal(t, "testdata/aliases.conf", path)
 }
 
+func TestValidateShortNameOrPattern(t *testing.T) {
+	for _, c := range []struct {
+		input string
+		valid bool
+	}{
+		{"fedora", true},
+		{"library/fedora", true},
+		{"*/golang", true},
+		{"library/*", true},
+		{"*/foo/*", true},
+		{"*", false},
+		{"*/*", false},
+		{"go*", false},
+		{"*/go*", false},
+		{"*/golang:latest", false},
+		{"*/Golang", false},
+		{"docker.io/*/golang", false},
+	} {
+		err := validateShortNameOrPattern(c.input)
+		if c.valid {
+			assert.NoError(t, err, c.input)
+		} else {
+			assert.Error(t, err, c.input)
+		}
+	}
+}
+
+func TestResolveShortNameAliasPatterns(t *testing.T) {
+	tmp, err := os.CreateTemp("", "aliases.conf")
+	require.NoError(t, err)
+	defer os.Remove(tmp.Name())
+
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    "testdata/alias-patterns.conf",
+		SystemRegistriesConfDirPath: "testdata/this-does-not-exist",
+		UserShortNameAliasConfPath:  tmp.Name(),
+	}
+
+	InvalidateCache()
+	conf, err := tryUpdatingCache(sys, newConfigWrapper(sys))
+	require.NoError(t, err)
+	assert.Len(t, conf.aliasCache.namedAliases, 2)
+	assert.Len(t, conf.aliasCache.patternAliases, 4)
+
+	for _, c := range []struct{ name, value, config string }{
+		{"library/golang", "docker.io/library/golang", "testdata/alias-patterns.conf"},
+		{"other/golang", "docker.io/library/golang", "testdata/alias-patterns.conf"},
+		{"a/b/golang", "quay.io/nested/golang", "testdata/alias-patterns.conf"},
+		{"a/foo/golang", "quay.io/foo/golang", "testdata/alias-patterns.conf"}, // The most specific pattern wins
+		{"exact/golang", "example.com/exact/golang", "testdata/alias-patterns.conf"},
+		{"reset/golang", "", "testdata/alias-patterns.conf"}, // An exact alias set to "" disables patterns
+		{"golang", "", ""},
+		{"other/golang2", "", ""},
+	} {
+		value, path, err := ResolveShortNameAlias(sys, c.name)
+		require.NoError(t, err, c.name)
+		if c.value == "" {
+			assert.Nil(t, value, c.name)
+		} else {
+			require.NotNil(t, value, c.name)
+			assert.Equal(t, c.value, value.String(), c.name)
+		}
+		assert.Equal(t, c.config, path, c.name)
+	}
+
+	// Aliases in the user-specific file have precedence over registries.conf for the same kind of alias,
+	// but exact aliases always have precedence over patterns.
+	require.NoError(t, AddShortNameAlias(sys, "*/golang", "user.example.com/golang"))
+	require.NoError(t, AddShortNameAlias(sys, "*/exact", "user.example.com/exact"))
+	for _, c := range []struct{ name, value, config string }{
+		{"other/golang", "user.example.com/golang", tmp.Name()},
+		{"a/foo/golang", "quay.io/foo/golang", "testdata/alias-patterns.conf"},
+		{"exact/golang", "example.com/exact/golang", "testdata/alias-patterns.conf"},
+		{"other/exact", "user.example.com/exact", tmp.Name()},
+	} {
+		value, path, err := ResolveShortNameAlias(sys, c.name)
+		require.NoError(t, err, c.name)
+		require.NotNil(t, value, c.name)
+		assert.Equal(t, c.value, value.String(), c.name)
+		assert.Equal(t, c.config, path, c.name)
+	}
+
+	require.NoError(t, RemoveShortNameAlias(sys, "*/golang"))
+	value, path, err := ResolveShortNameAlias(sys, "other/golang")
+	require.NoError(t, err)
+	require.NotNil(t, value)
+	assert.Equal(t, "docker.io/library/golang", value.String())
+	assert.Equal(t, "testdata/alias-patterns.conf", path)
+	assert.Error(t, RemoveShortNameAlias(sys, "*/golang"))
+	assert.Error(t, AddShortNameAlias(sys, "*", "user.example.com/everything"))
+}
+
 func TestAliasesWithDropInConfigs(t *testing.T) {
 	tmp, err := os.CreateTemp("", "aliases.conf")
 	require.NoError(t, err)
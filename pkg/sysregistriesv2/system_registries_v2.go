@@ -4,19 +4,22 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage/pkg/fileutils"
 	"github.com/containers/storage/pkg/homedir"
 	"github.com/containers/storage/pkg/regexp"
-	"github.com/sirupsen/logrus"
+	digest "github.com/opencontainers/go-digest"
 	"golang.org/x/exp/maps"
 )
 
@@ -129,6 +132,11 @@ type Registry struct {
 	Mirrors []Endpoint `toml:"mirror,omitempty"`
 	// If true, pulling from the registry will be blocked.
 	Blocked bool `toml:"blocked,omitempty"`
+	// Pulling images with a tag matching one of these patterns (using the syntax of path.Match, e.g. "latest" or "*-rc*")
+	// from the registry will be blocked.
+	BlockedTags []string `toml:"blocked-tags,omitempty"`
+	// Pulling images with one of these manifest digests from the registry will be blocked.
+	BlockedDigests []string `toml:"blocked-digests,omitempty"`
 	// If true, mirrors will only be used for digest pulls. Pulling images by
 	// tag can potentially yield different images, depending on which endpoint
 	// we pull from.  Restricting mirrors to pulls by digest avoids that issue.
@@ -253,6 +261,10 @@ type V2RegistriesConf struct {
 	// registry authentication. These credentials are only collected when pulling (not pushing).
 	AdditionalLayerStoreAuthHelper string `toml:"additional-layer-store-auth-helper"`
 
+	// An array of manifest digests of images which must not be pulled from any registry,
+	// in addition to the blocked-digests of individual registries.
+	BlockedDigests []string `toml:"blocked-digests"`
+
 	shortNameAliasConf
 
 	// If you add any field, make sure to update Nonempty() below.
@@ -270,6 +282,9 @@ func (config *V2RegistriesConf) Nonempty() bool {
 	if copy.CredentialHelpers != nil && len(copy.CredentialHelpers) == 0 {
 		copy.CredentialHelpers = nil
 	}
+	if copy.BlockedDigests != nil && len(copy.BlockedDigests) == 0 {
+		copy.BlockedDigests = nil
+	}
 	if !copy.shortNameAliasConf.nonempty() {
 		copy.shortNameAliasConf = shortNameAliasConf{}
 	}
@@ -450,6 +465,14 @@ func (config *V2RegistriesConf) postProcessRegistries() error {
 				return &InvalidRegistries{s: fmt.Sprintf("unsupported pull-from-mirror value %q for mirror %q", mir.PullFromMirror, mir.Location)}
 			}
 		}
+		for _, pattern := range reg.BlockedTags {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return &InvalidRegistries{s: fmt.Sprintf("invalid blocked-tags pattern %q for registry %q: %v", pattern, reg.Prefix, err)}
+			}
+		}
+		if err := validateBlockedDigests(reg.BlockedDigests); err != nil {
+			return err
+		}
 		if reg.Location == "" {
 			regMap[reg.Prefix] = append(regMap[reg.Prefix], reg)
 		} else {
@@ -498,6 +521,10 @@ func (config *V2RegistriesConf) postProcessRegistries() error {
 		config.UnqualifiedSearchRegistries[i] = registry
 	}
 
+	if err := validateBlockedDigests(config.BlockedDigests); err != nil {
+		return err
+	}
+
 	// Registries are ordered and the first longest prefix always wins,
 	// rendering later items with the same prefix non-existent. We cannot error
 	// out anymore as this might break existing users, so let's just ignore them
@@ -525,6 +552,16 @@ func (config *V2RegistriesConf) postProcessRegistries() error {
 	return nil
 }
 
+// validateBlockedDigests returns an error if digests, a blocked-digests value, contains an invalid digest.
+func validateBlockedDigests(digests []string) error {
+	for _, d := range digests {
+		if _, err := digest.Parse(d); err != nil {
+			return &InvalidRegistries{s: fmt.Sprintf("invalid blocked-digests entry %q: %v", d, err)}
+		}
+	}
+	return nil
+}
+
 // ConfigPath returns the path to the system-wide registry configuration file.
 // Deprecated: This API implies configuration is read from files, and that there is only one.
 // Please use ConfigurationSourceDescription to obtain a string usable for error messages.
@@ -936,10 +973,51 @@ func findRegistryWithParsedConfig(config *parsedConfig, ref string) (*Registry,
 	return nil, nil
 }
 
+// IsImageBlocked returns true if pulling ref is blocked by the blocked-tags or blocked-digests settings
+// of the registry with the longest prefix for ref, or by the top-level blocked-digests setting.
+// If manifestDigest is not "", it is the digest of a manifest pulled for ref (e.g. when ref only contains a tag),
+// and it is checked against the blocked digests as well.
+// Note that this does not consider Registry.Blocked, which blocks all access to the registry.
+func IsImageBlocked(ctx *types.SystemContext, ref reference.Named, manifestDigest digest.Digest) (bool, error) {
+	config, err := getConfig(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	digests := []digest.Digest{}
+	if canonical, ok := ref.(reference.Canonical); ok {
+		digests = append(digests, canonical.Digest())
+	}
+	if manifestDigest != "" {
+		digests = append(digests, manifestDigest)
+	}
+	blockedDigests := config.partialV2.BlockedDigests
+	reg, err := findRegistryWithParsedConfig(config, ref.Name())
+	if err != nil {
+		return false, err
+	}
+	if reg != nil {
+		if tagged, ok := ref.(reference.NamedTagged); ok {
+			for _, pattern := range reg.BlockedTags {
+				if matched, _ := path.Match(pattern, tagged.Tag()); matched { // Patterns were validated in postProcessRegistries
+					return true, nil
+				}
+			}
+		}
+		blockedDigests = append(slices.Clone(blockedDigests), reg.BlockedDigests...)
+	}
+	for _, d := range digests {
+		if slices.Contains(blockedDigests, d.String()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // loadConfigFile loads and unmarshals a single config file.
 // Use forceV2 if the config must in the v2 format.
 func loadConfigFile(path string, forceV2 bool) (*parsedConfig, error) {
-	logrus.Debugf("Loading registries configuration %q", path)
+	log.Debugf("Loading registries configuration %q", path)
 
 	// tomlConfig allows us to unmarshal either V1 or V2 simultaneously.
 	type tomlConfig struct {
@@ -954,7 +1032,7 @@ func loadConfigFile(path string, forceV2 bool) (*parsedConfig, error) {
 		return nil, err
 	}
 	if keys := meta.Undecoded(); len(keys) > 0 {
-		logrus.Debugf("Failed to decode keys %q from %q", keys, path)
+		log.Debugf("Failed to decode keys %q from %q", keys, path)
 	}
 
 	if combinedTOML.V1RegistriesConf.hasSetField() {
@@ -1066,6 +1144,11 @@ func (c *parsedConfig) updateWithConfigurationFrom(updates *parsedConfig) {
 		c.shortNameMode = updates.shortNameMode
 	}
 
+	// == Merge BlockedDigests:
+	if updates.partialV2.BlockedDigests != nil {
+		c.partialV2.BlockedDigests = updates.partialV2.BlockedDigests
+	}
+
 	// == Merge AdditionalLayerStoreAuthHelper:
 	if updates.partialV2.AdditionalLayerStoreAuthHelper != "" {
 		c.partialV2.AdditionalLayerStoreAuthHelper = updates.partialV2.AdditionalLayerStoreAuthHelper
@@ -8,6 +8,7 @@ import (
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -51,6 +52,7 @@ var v2RegistriesConfEmptyTestData = []struct {
 	{nonempty: false, hasSetField: true, v: V2RegistriesConf{Registries: []Registry{}}},
 	{nonempty: false, hasSetField: true, v: V2RegistriesConf{UnqualifiedSearchRegistries: []string{}}},
 	{nonempty: false, hasSetField: true, v: V2RegistriesConf{CredentialHelpers: []string{}}},
+	{nonempty: false, hasSetField: true, v: V2RegistriesConf{BlockedDigests: []string{}}},
 	{nonempty: false, hasSetField: true, v: V2RegistriesConf{shortNameAliasConf: shortNameAliasConf{Aliases: map[string]string{}}}},
 	{nonempty: true, hasSetField: true, v: V2RegistriesConf{Registries: []Registry{{Prefix: "example.com"}}}},
 	{nonempty: true, hasSetField: true, v: V2RegistriesConf{UnqualifiedSearchRegistries: []string{"example.com"}}},
@@ -58,6 +60,7 @@ var v2RegistriesConfEmptyTestData = []struct {
 	{nonempty: true, hasSetField: true, v: V2RegistriesConf{ShortNameMode: "enforcing"}},
 	{nonempty: true, hasSetField: true, v: V2RegistriesConf{shortNameAliasConf: shortNameAliasConf{Aliases: map[string]string{"a": "example.com/b"}}}},
 	{nonempty: true, hasSetField: true, v: V2RegistriesConf{AdditionalLayerStoreAuthHelper: "example"}},
+	{nonempty: true, hasSetField: true, v: V2RegistriesConf{BlockedDigests: []string{"sha256:1111111111111111111111111111111111111111111111111111111111111111"}}},
 }
 
 func TestV2RegistriesConfNonempty(t *testing.T) {
@@ -434,11 +437,55 @@ func TestFindUnqualifiedSearchRegistries(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestIsImageBlocked(t *testing.T) {
+	sys := &types.SystemContext{
+		SystemRegistriesConfPath:    "testdata/blocked-images.conf",
+		SystemRegistriesConfDirPath: "testdata/this-does-not-exist",
+	}
+	const (
+		globallyBlocked = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		busyboxBlocked  = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+		otherDigest     = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	)
+	for _, c := range []struct {
+		ref            string
+		manifestDigest digest.Digest
+		blocked        bool
+	}{
+		{"docker.io/library/alpine:3.19", "", false},
+		{"docker.io/library/alpine:latest", "", true},
+		{"docker.io/library/alpine:3.20-rc1", "", true},
+		{"docker.io/library/alpine@" + otherDigest, "", false},
+		{"docker.io/library/alpine@" + globallyBlocked, "", true},
+		{"docker.io/library/alpine:3.19", globallyBlocked, true},
+		{"docker.io/library/alpine@" + busyboxBlocked, "", false},
+		// The most specific [[registry]] table is used; it does not block tags, but it blocks a digest.
+		{"docker.io/library/busybox:latest", "", false},
+		{"docker.io/library/busybox@" + busyboxBlocked, "", true},
+		{"docker.io/library/busybox:1", busyboxBlocked, true},
+		{"docker.io/library/busybox:1", otherDigest, false},
+		{"docker.io/library/busybox@" + globallyBlocked, "", true},
+		{"registry.com/repo:latest", "", false},
+		{"registry.com/repo:1", globallyBlocked, true},
+		// No matching [[registry]] table
+		{"unconfigured.example.com/repo:latest", "", false},
+		{"unconfigured.example.com/repo@" + globallyBlocked, "", true},
+	} {
+		ref, err := reference.ParseNormalizedNamed(c.ref)
+		require.NoError(t, err, c.ref)
+		blocked, err := IsImageBlocked(sys, ref, c.manifestDigest)
+		require.NoError(t, err, c.ref)
+		assert.Equal(t, c.blocked, blocked, "%s %s", c.ref, c.manifestDigest)
+	}
+}
+
 func TestInvalidV2Configs(t *testing.T) {
 	for _, c := range []struct{ path, errorSubstring string }{
 		{"testdata/insecure-conflicts.conf", "registry 'registry.com' is defined multiple times with conflicting 'insecure' setting"},
 		{"testdata/blocked-conflicts.conf", "registry 'registry.com' is defined multiple times with conflicting 'blocked' setting"},
 		{"testdata/missing-mirror-location.conf", "invalid condition: mirror location is unset"},
+		{"testdata/invalid-blocked-tags.conf", "invalid blocked-tags pattern"},
+		{"testdata/invalid-blocked-digests.conf", "invalid blocked-digests entry"},
 		{"testdata/invalid-prefix.conf", "invalid location"},
 		{"testdata/this-does-not-exist.conf", "no such file or directory"},
 	} {
@@ -0,0 +1,137 @@
+package tlsclientconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/internal/log"
+)
+
+// defaultReloadCheckInterval is the minimum time between checks whether the certificate directory has changed.
+const defaultReloadCheckInterval = 10 * time.Second
+
+// ReloadingTransport is a http.RoundTripper using TLS certificates loaded from a directory by SetupCertificates.
+// When the contents of the directory change (e.g. a rotated CA bundle or client certificate is installed),
+// the certificates are reloaded, and subsequent requests use a new transport with the updated configuration.
+// This allows long-running processes to pick up rotated certificates without restarting.
+type ReloadingTransport struct {
+	dir           string
+	tlsc          *tls.Config
+	checkInterval time.Duration
+
+	mutex       sync.Mutex
+	transport   *http.Transport
+	fingerprint string    // Of dir, when transport was created.
+	lastCheck   time.Time // When fingerprint was last checked.
+}
+
+// NewReloadingTransport returns a ReloadingTransport, using transports created by NewTransport with a TLS configuration
+// based on tlsc, with certificates from dir added by SetupCertificates.
+// tlsc should not already contain the certificates from dir; otherwise, removed certificates would continue to be used.
+// tlsc is read again every time the certificates are reloaded, it must not be modified after the first request is made.
+func NewReloadingTransport(dir string, tlsc *tls.Config) (*ReloadingTransport, error) {
+	res := &ReloadingTransport{
+		dir:           dir,
+		tlsc:          tlsc,
+		checkInterval: defaultReloadCheckInterval,
+	}
+	fingerprint, err := certificateDirFingerprint(dir)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := res.newTransport()
+	if err != nil {
+		return nil, err
+	}
+	res.transport = transport
+	res.fingerprint = fingerprint
+	res.lastCheck = time.Now()
+	return res, nil
+}
+
+// newTransport returns a new transport using the current contents of r.dir.
+func (r *ReloadingTransport) newTransport() (*http.Transport, error) {
+	tlsc := r.tlsc.Clone()
+	if err := SetupCertificates(r.dir, tlsc); err != nil {
+		return nil, err
+	}
+	tr := NewTransport()
+	tr.TLSClientConfig = tlsc
+	return tr, nil
+}
+
+// currentTransport returns the transport to use for a new request, reloading the certificates if necessary.
+func (r *ReloadingTransport) currentTransport() *http.Transport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastCheck) < r.checkInterval {
+		return r.transport
+	}
+	r.lastCheck = now
+	fingerprint, err := certificateDirFingerprint(r.dir)
+	if err != nil {
+		log.Warnf("Error checking TLS certificates in %s for changes, continuing to use the previously loaded ones: %v", r.dir, err)
+		return r.transport
+	}
+	if fingerprint == r.fingerprint {
+		return r.transport
+	}
+	// Don’t retry loading the same contents on every check if they are broken; wait for the next change instead.
+	r.fingerprint = fingerprint
+	log.Debugf("TLS certificates in %s have changed, reloading", r.dir)
+	transport, err := r.newTransport()
+	if err != nil {
+		log.Warnf("Error reloading TLS certificates from %s, continuing to use the previously loaded ones: %v", r.dir, err)
+		return r.transport
+	}
+	// Requests already using the old transport can finish; it will not be used for new connections.
+	r.transport.CloseIdleConnections()
+	r.transport = transport
+	return r.transport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *ReloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.currentTransport().RoundTrip(req)
+}
+
+// CloseIdleConnections closes idle connections of the current transport, as used by http.Client.CloseIdleConnections.
+func (r *ReloadingTransport) CloseIdleConnections() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.transport.CloseIdleConnections()
+}
+
+// certificateDirFingerprint returns a value which changes when files in dir used by SetupCertificates change.
+func certificateDirFingerprint(dir string) (string, error) {
+	fs, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) { // SetupCertificates ignores these.
+			return "", nil
+		}
+		return "", err
+	}
+	var res strings.Builder
+	for _, f := range fs {
+		if !strings.HasSuffix(f.Name(), ".crt") && !strings.HasSuffix(f.Name(), ".cert") && !strings.HasSuffix(f.Name(), ".key") {
+			continue
+		}
+		// Use os.Stat, not f.Info(), to follow symbolic links: certificates are often installed
+		// as links that are atomically re-pointed on rotation (e.g. Kubernetes secret volumes).
+		fi, err := os.Stat(filepath.Join(dir, f.Name()))
+		if err != nil {
+			fmt.Fprintf(&res, "%s:%v\n", f.Name(), err)
+			continue
+		}
+		fmt.Fprintf(&res, "%s:%d:%d\n", f.Name(), fi.Size(), fi.ModTime().UnixNano())
+	}
+	return res.String(), nil
+}
@@ -0,0 +1,108 @@
+package tlsclientconfig
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyTestFile copies testdata/full/name into dir, with modification time mtime.
+func copyTestFile(t *testing.T, dir, name string, mtime time.Time) {
+	data, err := os.ReadFile(filepath.Join("testdata/full", name))
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	err = os.WriteFile(path, data, 0o600)
+	require.NoError(t, err)
+	err = os.Chtimes(path, mtime, mtime)
+	require.NoError(t, err)
+}
+
+func TestReloadingTransportReload(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	copyTestFile(t, dir, "client-cert-1.cert", mtime)
+	copyTestFile(t, dir, "client-cert-1.key", mtime)
+
+	tlsc := &tls.Config{ServerName: "example.com"}
+	r, err := NewReloadingTransport(dir, tlsc)
+	require.NoError(t, err)
+	r.checkInterval = 0
+	tr1 := r.currentTransport()
+	assert.Len(t, tr1.TLSClientConfig.Certificates, 1)
+	assert.Equal(t, "example.com", tr1.TLSClientConfig.ServerName)
+	assert.Empty(t, tlsc.Certificates) // The original is not modified
+
+	// No change
+	assert.Same(t, tr1, r.currentTransport())
+
+	// A certificate is added
+	copyTestFile(t, dir, "client-cert-2.cert", mtime)
+	copyTestFile(t, dir, "client-cert-2.key", mtime)
+	tr2 := r.currentTransport()
+	assert.NotSame(t, tr1, tr2)
+	assert.Len(t, tr2.TLSClientConfig.Certificates, 2)
+
+	// A certificate is replaced
+	copyTestFile(t, dir, "client-cert-1.cert", mtime.Add(time.Minute))
+	tr3 := r.currentTransport()
+	assert.NotSame(t, tr2, tr3)
+	assert.Len(t, tr3.TLSClientConfig.Certificates, 2)
+
+	// Invalid contents: continue using the previous transport
+	err = os.Remove(filepath.Join(dir, "client-cert-2.key"))
+	require.NoError(t, err)
+	assert.Same(t, tr3, r.currentTransport())
+
+	// Unrelated files are ignored
+	err = os.WriteFile(filepath.Join(dir, "README"), []byte("unrelated"), 0o600)
+	require.NoError(t, err)
+	assert.Same(t, tr3, r.currentTransport())
+
+	// Changes are not checked more often than checkInterval
+	r.checkInterval = time.Hour
+	err = os.Remove(filepath.Join(dir, "client-cert-2.cert"))
+	require.NoError(t, err)
+	assert.Same(t, tr3, r.currentTransport())
+	r.checkInterval = 0
+	tr4 := r.currentTransport()
+	assert.NotSame(t, tr3, tr4)
+	assert.Len(t, tr4.TLSClientConfig.Certificates, 1)
+
+	// Invalid initial contents
+	_, err = NewReloadingTransport("testdata/missing-key", &tls.Config{})
+	assert.Error(t, err)
+	// A missing directory is not an error
+	_, err = NewReloadingTransport(filepath.Join(dir, "this-does-not-exist"), &tls.Config{})
+	assert.NoError(t, err)
+}
+
+func TestReloadingTransportRoundTrip(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	r, err := NewReloadingTransport(dir, &tls.Config{})
+	require.NoError(t, err)
+	r.checkInterval = 0
+	client := &http.Client{Transport: r}
+	defer client.CloseIdleConnections()
+
+	_, err = client.Get(server.URL)
+	assert.Error(t, err) // The server’s CA is not trusted yet
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	err = os.WriteFile(filepath.Join(dir, "ca.crt"), caPEM, 0o600)
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
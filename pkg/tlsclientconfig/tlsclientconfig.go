@@ -12,19 +12,19 @@ import (
 	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/containers/image/v5/internal/log"
 )
 
 // SetupCertificates opens all .crt, .cert, and .key files in dir and appends / loads certs and key pairs as appropriate to tlsc
 func SetupCertificates(dir string, tlsc *tls.Config) error {
-	logrus.Debugf("Looking for TLS certificates and private keys in %s", dir)
+	log.Debugf("Looking for TLS certificates and private keys in %s", dir)
 	fs, err := os.ReadDir(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
 		if os.IsPermission(err) {
-			logrus.Debugf("Skipping scan of %s due to permission error: %v", dir, err)
+			log.Debugf("Skipping scan of %s due to permission error: %v", dir, err)
 			return nil
 		}
 		return err
@@ -33,7 +33,7 @@ func SetupCertificates(dir string, tlsc *tls.Config) error {
 	for _, f := range fs {
 		fullPath := filepath.Join(dir, f.Name())
 		if strings.HasSuffix(f.Name(), ".crt") {
-			logrus.Debugf(" crt: %s", fullPath)
+			log.Debugf(" crt: %s", fullPath)
 			data, err := os.ReadFile(fullPath)
 			if err != nil {
 				if os.IsNotExist(err) {
@@ -41,7 +41,7 @@ func SetupCertificates(dir string, tlsc *tls.Config) error {
 					// Race with someone who deleted the
 					// file after we read the directory's
 					// list of contents?
-					logrus.Warnf("error reading certificate %q: %v", fullPath, err)
+					log.Warnf("error reading certificate %q: %v", fullPath, err)
 					continue
 				}
 				return err
@@ -58,7 +58,7 @@ func SetupCertificates(dir string, tlsc *tls.Config) error {
 		if base, ok := strings.CutSuffix(f.Name(), ".cert"); ok {
 			certName := f.Name()
 			keyName := base + ".key"
-			logrus.Debugf(" cert: %s", fullPath)
+			log.Debugf(" cert: %s", fullPath)
 			if !hasFile(fs, keyName) {
 				return fmt.Errorf("missing key %s for client certificate %s. Note that CA certificates should use the extension .crt", keyName, certName)
 			}
@@ -71,7 +71,7 @@ func SetupCertificates(dir string, tlsc *tls.Config) error {
 		if base, ok := strings.CutSuffix(f.Name(), ".key"); ok {
 			keyName := f.Name()
 			certName := base + ".cert"
-			logrus.Debugf(" key: %s", fullPath)
+			log.Debugf(" key: %s", fullPath)
 			if !hasFile(fs, certName) {
 				return fmt.Errorf("missing client certificate %s for key %s", certName, keyName)
 			}
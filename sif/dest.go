@@ -0,0 +1,246 @@
+package sif
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/containers/image/v5/internal/imagedestination/impl"
+	"github.com/containers/image/v5/internal/imagedestination/stubs"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/putblobdigest"
+	"github.com/containers/image/v5/internal/tmpdir"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type sifImageDestination struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	stubs.NoPutBlobPartialInitialize
+	stubs.NoSignaturesInitialize
+
+	ref      sifReference
+	workDir  string
+	manifest []byte
+}
+
+// newImageDestination returns an ImageDestination for writing a SIF file.
+// Blobs are collected in a temporary directory, and the SIF file is only created on Commit.
+func newImageDestination(sys *types.SystemContext, ref sifReference) (private.ImageDestination, error) {
+	workDir, err := tmpdir.MkDirBigFileTemp(sys, "sif")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp directory: %w", err)
+	}
+	d := &sifImageDestination{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			SupportedManifestMIMETypes: []string{imgspecv1.MediaTypeImageManifest, manifest.DockerV2Schema2MediaType},
+			// We need to extract the layer anyway, so ask for it uncompressed to avoid a pointless compression step.
+			DesiredLayerCompression:        types.Decompress,
+			AcceptsForeignLayerURLs:        false,
+			MustMatchRuntimeOS:             false,
+			IgnoresEmbeddedDockerReference: true, // SIF files don’t contain image names.
+			HasThreadSafePutBlob:           true,
+		}),
+		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(ref),
+		NoSignaturesInitialize:     stubs.NoSignatures("SIF files do not support signatures"),
+
+		ref:     ref,
+		workDir: workDir,
+	}
+	d.Compat = impl.AddCompat(d)
+	return d, nil
+}
+
+// Reference returns the reference used to set up this destination.  Note that this should directly correspond to user's intent,
+// e.g. it should use the public hostname instead of the result of resolving CNAMEs or following redirects.
+func (d *sifImageDestination) Reference() types.ImageReference {
+	return d.ref
+}
+
+// Close removes resources associated with an initialized ImageDestination, if any.
+func (d *sifImageDestination) Close() error {
+	return os.RemoveAll(d.workDir)
+}
+
+// blobPath returns a path for storing a blob with blobDigest in d.workDir.
+func (d *sifImageDestination) blobPath(blobDigest digest.Digest) (string, error) {
+	if err := blobDigest.Validate(); err != nil { // Make sure blobDigest.Encoded() is safe to use as a file name
+		return "", err
+	}
+	return filepath.Join(d.workDir, "blob-"+blobDigest.Algorithm().String()+"-"+blobDigest.Encoded()), nil
+}
+
+// PutBlobWithOptions writes contents of stream and returns data representing the result.
+// inputInfo.Digest can be optionally provided if known; if provided, and stream is read to the end without error, the digest MUST match the stream contents.
+// inputInfo.Size is the expected length of stream, if known.
+// inputInfo.MediaType describes the blob format, if known.
+// WARNING: The contents of stream are being verified on the fly.  Until stream.Read() returns io.EOF, the contents of the data SHOULD NOT be available
+// to any other readers for download using the supplied digest.
+// If stream.Read() at any time, ESPECIALLY at end of input, returns an error, PutBlobWithOptions MUST 1) fail, and 2) delete any data stored so far.
+func (d *sifImageDestination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	blobFile, err := os.CreateTemp(d.workDir, "put-blob")
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	succeeded := false
+	explicitClosed := false
+	defer func() {
+		if !explicitClosed {
+			blobFile.Close()
+		}
+		if !succeeded {
+			os.Remove(blobFile.Name())
+		}
+	}()
+
+	digester, stream := putblobdigest.DigestIfCanonicalUnknown(stream, inputInfo)
+	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
+	size, err := io.Copy(blobFile, stream)
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	blobDigest := digester.Digest()
+	if inputInfo.Size != -1 && size != inputInfo.Size {
+		return private.UploadedBlob{}, fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", blobDigest, inputInfo.Size, size)
+	}
+	blobPath, err := d.blobPath(blobDigest)
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	blobFile.Close()
+	explicitClosed = true
+	if err := os.Rename(blobFile.Name(), blobPath); err != nil {
+		return private.UploadedBlob{}, err
+	}
+	succeeded = true
+	return private.UploadedBlob{Digest: blobDigest, Size: size}, nil
+}
+
+// TryReusingBlobWithOptions checks whether the transport already contains, or can efficiently reuse, a blob, and if so, applies it to the current destination
+// (e.g. if the blob is a filesystem layer, this signifies that the changes it describes need to be applied again when composing a filesystem tree).
+// info.Digest must not be empty.
+// If the blob has been successfully reused, returns (true, info, nil).
+// If the transport can not reuse the requested blob, TryReusingBlob returns (false, {}, nil); it returns a non-nil error only on an unexpected failure.
+func (d *sifImageDestination) TryReusingBlobWithOptions(ctx context.Context, info types.BlobInfo, options private.TryReusingBlobOptions) (bool, private.ReusedBlob, error) {
+	if !impl.OriginalCandidateMatchesTryReusingBlobOptions(options) {
+		return false, private.ReusedBlob{}, nil
+	}
+	if info.Digest == "" {
+		return false, private.ReusedBlob{}, errors.New("Can not check for a blob with unknown digest")
+	}
+	blobPath, err := d.blobPath(info.Digest)
+	if err != nil {
+		return false, private.ReusedBlob{}, err
+	}
+	finfo, err := os.Stat(blobPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, private.ReusedBlob{}, nil
+		}
+		return false, private.ReusedBlob{}, err
+	}
+	return true, private.ReusedBlob{Digest: info.Digest, Size: finfo.Size()}, nil
+}
+
+// PutManifest writes manifest to the destination.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to write the manifest for
+// (when the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
+// It is expected but not enforced that the instanceDigest, when specified, matches the digest of `manifest` as generated
+// by `manifest.Digest()`.
+// FIXME? This should also receive a MIME type if known, to differentiate between schema versions.
+// If the destination is in principle available, refuses this manifest type (e.g. it does not recognize the schema),
+// but may accept a different manifest type, the returned error must be an ManifestTypeRejectedError.
+func (d *sifImageDestination) PutManifest(ctx context.Context, m []byte, instanceDigest *digest.Digest) error {
+	if instanceDigest != nil {
+		return errors.New("manifest lists are not supported by the sif transport")
+	}
+	mimeType := manifest.GuessMIMEType(m)
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		return errors.New("manifest lists are not supported by the sif transport")
+	}
+	if mimeType != imgspecv1.MediaTypeImageManifest && mimeType != manifest.DockerV2Schema2MediaType {
+		return types.ManifestTypeRejectedError{Err: fmt.Errorf("manifest type %q is not supported by the sif transport", mimeType)}
+	}
+	d.manifest = m
+	return nil
+}
+
+// Commit marks the process of storing the image as successful and asks for the image to be persisted.
+// unparsedToplevel contains data about the top-level manifest of the source (which may be a single-arch image or a manifest list
+// if PutManifest was only called for the single-arch image with instanceDigest == nil), primarily to allow lookups by the
+// original manifest list digest, if desired.
+// WARNING: This does not have any transactional semantics:
+// - Uploaded data MAY be visible to others before Commit() is called
+// - Uploaded data MAY be removed or MAY remain around if Close() is called without Commit() (i.e. rollback is allowed but not guaranteed)
+func (d *sifImageDestination) Commit(ctx context.Context, unparsedToplevel types.UnparsedImage) error {
+	if d.manifest == nil {
+		return errors.New("no manifest was written to the sif destination")
+	}
+	m, err := manifest.FromBlob(d.manifest, manifest.GuessMIMEType(d.manifest))
+	if err != nil {
+		return fmt.Errorf("parsing manifest: %w", err)
+	}
+	layers := m.LayerInfos()
+	nonEmptyLayers := 0
+	var layer manifest.LayerInfo
+	for _, l := range layers {
+		if !l.EmptyLayer {
+			nonEmptyLayers++
+			layer = l
+		}
+	}
+	if nonEmptyLayers != 1 {
+		return fmt.Errorf("SIF files can only contain single-layer images, this image has %d layers; consider squashing the image first", nonEmptyLayers)
+	}
+
+	configPath, err := d.blobPath(m.ConfigInfo().Digest)
+	if err != nil {
+		return err
+	}
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("reading image config: %w", err)
+	}
+	var config imgspecv1.Image // Docker schema2 configs use the same field names for the data we need.
+	if err := json.Unmarshal(configBytes, &config); err != nil {
+		return fmt.Errorf("parsing image config: %w", err)
+	}
+	layerPath, err := d.blobPath(layer.Digest)
+	if err != nil {
+		return err
+	}
+
+	// Create the file in the destination directory, so that it can be atomically renamed into place.
+	tmpFile, err := os.CreateTemp(filepath.Dir(d.ref.resolvedFile), ".sif-commit")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			os.Remove(tmpPath)
+		}
+	}()
+	conversionDir := filepath.Join(d.workDir, "commit")
+	if err := os.Mkdir(conversionDir, 0700); err != nil {
+		return err
+	}
+	defer os.RemoveAll(conversionDir)
+	if err := convertElementsToSIF(ctx, conversionDir, tmpPath, layerPath, &config); err != nil {
+		return fmt.Errorf("converting image to SIF: %w", err)
+	}
+	if err := os.Rename(tmpPath, d.ref.resolvedFile); err != nil {
+		return err
+	}
+	succeeded = true
+	return nil
+}
@@ -0,0 +1,239 @@
+package sif
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sylabs/sif/v2/pkg/sif"
+)
+
+var _ private.ImageDestination = (*sifImageDestination)(nil)
+
+func TestShellQuote(t *testing.T) {
+	for _, c := range []struct{ input, expected string }{
+		{"", "''"},
+		{"abc", "'abc'"},
+		{"a b", "'a b'"},
+		{`it's`, `'it'\''s'`},
+		{`$HOME "x"`, `'$HOME "x"'`},
+	} {
+		assert.Equal(t, c.expected, shellQuote(c.input), c.input)
+	}
+}
+
+func TestEnvironmentFromConfig(t *testing.T) {
+	res := environmentFromConfig(&imgspecv1.ImageConfig{
+		Env: []string{"PATH=/usr/bin:/bin", "EMPTY=", "QUOTED=it's", "=invalid", "invalid",
+			"A B=space", "X;touch /tmp/pwned;Y=injection", "$(id)=substitution", "1ST=digit", "DASH-ED=dash", "_UNDERSCORE1=ok"},
+	})
+	assert.Equal(t, []string{
+		"export PATH='/usr/bin:/bin'",
+		"export EMPTY=''",
+		`export QUOTED='it'\''s'`,
+		"export _UNDERSCORE1='ok'",
+	}, res)
+}
+
+func TestRunscriptFromConfig(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		config   imgspecv1.ImageConfig
+		expected []string
+	}{
+		{
+			name:     "Nothing",
+			config:   imgspecv1.ImageConfig{},
+			expected: []string{"if [ $# -eq 0 ]; then", "\tset -- '/bin/sh'", "fi", `exec "$@"`},
+		},
+		{
+			name:     "Cmd",
+			config:   imgspecv1.ImageConfig{Cmd: []string{"echo", "hello world"}},
+			expected: []string{"if [ $# -eq 0 ]; then", "\tset -- 'echo' 'hello world'", "fi", `exec "$@"`},
+		},
+		{
+			name:     "Entrypoint",
+			config:   imgspecv1.ImageConfig{Entrypoint: []string{"/entrypoint"}},
+			expected: []string{`exec '/entrypoint' "$@"`},
+		},
+		{
+			name: "Everything",
+			config: imgspecv1.ImageConfig{
+				WorkingDir: "/work dir",
+				Entrypoint: []string{"/entrypoint", "-v"},
+				Cmd:        []string{"run"},
+			},
+			expected: []string{"cd '/work dir'", "if [ $# -eq 0 ]; then", "\tset -- 'run'", "fi", `exec '/entrypoint' '-v' "$@"`},
+		},
+	} {
+		assert.Equal(t, c.expected, runscriptFromConfig(&c.config), c.name)
+	}
+}
+
+func TestGenerateDefFile(t *testing.T) {
+	environment := []string{"export A='1'", "export B='2'"}
+	runscript := []string{"if [ $# -eq 0 ]; then", "\tset -- 'run'", "fi", `exec '/entrypoint' "$@"`}
+	def := generateDefFile(environment, runscript)
+
+	// The generated file must be understood by the sif: image source.
+	parsedEnv, parsedRunscript, err := parseDefFile(bytes.NewReader(def))
+	require.NoError(t, err)
+	assert.Equal(t, environment, parsedEnv)
+	assert.Equal(t, []string{"if [ $# -eq 0 ]; then", "set -- 'run'", "fi", `exec '/entrypoint' "$@"`}, parsedRunscript)
+}
+
+// newTestDestination returns a sifImageDestination writing to a file in a temporary directory.
+func newTestDestination(t *testing.T) (*sifImageDestination, string) {
+	path := filepath.Join(t.TempDir(), "image.sif")
+	ref, err := NewReference(path)
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { dest.Close() })
+	d, ok := dest.(*sifImageDestination)
+	require.True(t, ok)
+	return d, path
+}
+
+func TestSIFDestinationBlobs(t *testing.T) {
+	d, _ := newTestDestination(t)
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+
+	blob := []byte("some blob contents")
+	blobDigest := digest.FromBytes(blob)
+
+	reused, _, err := d.TryReusingBlobWithOptions(ctx, types.BlobInfo{Digest: blobDigest, Size: -1}, private.TryReusingBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.False(t, reused)
+
+	_, err = d.PutBlobWithOptions(ctx, bytes.NewReader(blob), types.BlobInfo{Digest: blobDigest, Size: 5}, private.PutBlobOptions{Cache: cache})
+	assert.Error(t, err)
+
+	uploaded, err := d.PutBlobWithOptions(ctx, bytes.NewReader(blob), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.Equal(t, blobDigest, uploaded.Digest)
+	assert.Equal(t, int64(len(blob)), uploaded.Size)
+
+	reused, reusedInfo, err := d.TryReusingBlobWithOptions(ctx, types.BlobInfo{Digest: blobDigest, Size: -1}, private.TryReusingBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, private.ReusedBlob{Digest: blobDigest, Size: int64(len(blob))}, reusedInfo)
+}
+
+func TestSIFDestinationPutManifest(t *testing.T) {
+	d, _ := newTestDestination(t)
+	ctx := context.Background()
+
+	index, err := json.Marshal(imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+	})
+	require.NoError(t, err)
+	err = d.PutManifest(ctx, index, nil)
+	assert.Error(t, err)
+
+	m := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	instanceDigest := digest.FromBytes(m)
+	err = d.PutManifest(ctx, m, &instanceDigest)
+	assert.Error(t, err)
+
+	err = d.PutManifest(ctx, m, nil)
+	assert.NoError(t, err)
+}
+
+// putTestImage writes an image with the specified layers and config into d.
+func putTestImage(t *testing.T, d *sifImageDestination, layers [][]byte, config imgspecv1.Image) {
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+
+	configBytes, err := json.Marshal(config)
+	require.NoError(t, err)
+	configInfo, err := d.PutBlobWithOptions(ctx, bytes.NewReader(configBytes), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache, IsConfig: true})
+	require.NoError(t, err)
+	m := imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageConfig, Digest: configInfo.Digest, Size: configInfo.Size},
+	}
+	for _, layer := range layers {
+		layerInfo, err := d.PutBlobWithOptions(ctx, bytes.NewReader(layer), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache})
+		require.NoError(t, err)
+		m.Layers = append(m.Layers, imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageLayer, Digest: layerInfo.Digest, Size: layerInfo.Size})
+	}
+	manifestBytes, err := json.Marshal(m)
+	require.NoError(t, err)
+	err = d.PutManifest(ctx, manifestBytes, nil)
+	require.NoError(t, err)
+}
+
+// testLayer returns a tar layer containing a single file.
+func testLayer(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	contents := []byte("hello\n")
+	err := tw.WriteHeader(&tar.Header{Name: "hello.txt", Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+	require.NoError(t, err)
+	_, err = tw.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestSIFDestinationCommitRejectsMultipleLayers(t *testing.T) {
+	d, path := newTestDestination(t)
+	layer := testLayer(t)
+	putTestImage(t, d, [][]byte{layer, append(layer, 0)}, imgspecv1.Image{Platform: imgspecv1.Platform{Architecture: "amd64", OS: "linux"}})
+	err := d.Commit(context.Background(), nil)
+	assert.Error(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSIFDestinationCommit(t *testing.T) {
+	// Creating the SIF file requires external tools; we don't want to require every developer of c/image to have them around.
+	for _, tool := range []string{"fakeroot", "tar", "mksquashfs"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available: %v", tool, err)
+		}
+	}
+
+	d, path := newTestDestination(t)
+	putTestImage(t, d, [][]byte{testLayer(t)}, imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: "arm64", OS: "linux"},
+		Config: imgspecv1.ImageConfig{
+			Env: []string{"FOO=bar"},
+			Cmd: []string{"cat", "/hello.txt"},
+		},
+	})
+	err := d.Commit(context.Background(), nil)
+	require.NoError(t, err)
+
+	sifImg, err := sif.LoadContainerFromPath(path, sif.OptLoadWithFlag(os.O_RDONLY))
+	require.NoError(t, err)
+	defer func() {
+		_ = sifImg.UnloadContainer()
+	}()
+	assert.Equal(t, "arm64", sifImg.PrimaryArch())
+	_, err = sifImg.GetDescriptor(sif.WithPartitionType(sif.PartPrimSys))
+	assert.NoError(t, err)
+	command, script, err := processDefFile(sifImg)
+	require.NoError(t, err)
+	assert.Equal(t, injectedScriptTargetPath, command)
+	assert.Contains(t, string(script), "export FOO='bar'")
+	assert.Contains(t, string(script), "set -- 'cat' '/hello.txt'")
+}
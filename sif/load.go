@@ -10,7 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/sirupsen/logrus"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/sylabs/sif/v2/pkg/sif"
 )
 
@@ -130,13 +130,13 @@ func createTarFromSIFInputs(ctx context.Context, tarPath, squashFSPath string, i
 		return err
 	}
 
-	logrus.Debugf("Converting squashfs to tar, command: %s ...", conversionCommand)
+	log.DebugfContext(ctx, "Converting squashfs to tar, command: %s ...", conversionCommand)
 	cmd := exec.CommandContext(ctx, "fakeroot", "--", scriptPath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("converting image: %w, output: %s", err, string(output))
 	}
-	logrus.Debugf("... finished converting squashfs to tar")
+	log.DebugfContext(ctx, "... finished converting squashfs to tar")
 	return nil
 }
 
@@ -185,7 +185,7 @@ func convertSIFToElements(ctx context.Context, sifImage *sif.FileImage, tempDir
 	// TODO: We'd prefer not to make a full copy of the file here; unsquashfs ≥ 4.4
 	// has an -o option that allows extracting a squashfs from the SIF file directly,
 	// but that version is not currently available in RHEL 8.
-	logrus.Debugf("Creating a temporary squashfs image %s ...", squashFSPath)
+	log.DebugfContext(ctx, "Creating a temporary squashfs image %s ...", squashFSPath)
 	if err := func() error { // A scope for defer
 		f, err := os.Create(squashFSPath)
 		if err != nil {
@@ -200,7 +200,7 @@ func convertSIFToElements(ctx context.Context, sifImage *sif.FileImage, tempDir
 	}(); err != nil {
 		return "", nil, err
 	}
-	logrus.Debugf("... finished creating a temporary squashfs image")
+	log.DebugfContext(ctx, "... finished creating a temporary squashfs image")
 
 	if err := createTarFromSIFInputs(ctx, tarPath, squashFSPath, injectedScript, extractedRootPath, scriptPath); err != nil {
 		return "", nil, err
@@ -0,0 +1,180 @@
+package sif
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/containers/image/v5/internal/log"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/sif/v2/pkg/sif"
+)
+
+// sifLaunchScript is the launch script Apptainer and SingularityCE use in the SIF header.
+const sifLaunchScript = "#!/usr/bin/env run-singularity\n"
+
+// shellQuote returns s quoted for use as a single word in a POSIX shell script.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellQuoteAll returns words quoted for use in a POSIX shell script, separated by spaces.
+func shellQuoteAll(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted = append(quoted, shellQuote(w))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// envNameRegexp matches environment variable names which can be safely used in a shell script.
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// environmentFromConfig returns shell commands which set up the environment of config.
+// Variables with names which are not valid shell identifiers are ignored.
+func environmentFromConfig(config *imgspecv1.ImageConfig) []string {
+	res := []string{}
+	for _, env := range config.Env {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || !envNameRegexp.MatchString(name) {
+			log.Debugf("Ignoring invalid environment variable %q", env)
+			continue
+		}
+		res = append(res, fmt.Sprintf("export %s=%s", name, shellQuote(value)))
+	}
+	return res
+}
+
+// runscriptFromConfig returns lines of a shell script which runs the entrypoint and command of config,
+// allowing the command to be overridden by arguments to the script, as Apptainer does for images converted from OCI.
+func runscriptFromConfig(config *imgspecv1.ImageConfig) []string {
+	res := []string{}
+	if config.WorkingDir != "" {
+		res = append(res, "cd "+shellQuote(config.WorkingDir))
+	}
+	cmd := config.Cmd
+	if len(config.Entrypoint) == 0 && len(cmd) == 0 {
+		cmd = []string{"/bin/sh"}
+	}
+	if len(cmd) != 0 {
+		res = append(res, "if [ $# -eq 0 ]; then", "\tset -- "+shellQuoteAll(cmd), "fi")
+	}
+	if len(config.Entrypoint) != 0 {
+		res = append(res, "exec "+shellQuoteAll(config.Entrypoint)+` "$@"`)
+	} else {
+		res = append(res, `exec "$@"`)
+	}
+	return res
+}
+
+// generateDefFile returns a SIF definition file recording environment and runscript,
+// in the format parsed by parseDefFile.
+func generateDefFile(environment []string, runscript []string) []byte {
+	def := "bootstrap: scratch\n\n" +
+		"%environment\n" + joinLines(environment) + "\n" +
+		"%runscript\n" + joinLines(runscript)
+	return []byte(def)
+}
+
+// joinLines returns lines, each terminated by a newline.
+func joinLines(lines []string) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		sb.WriteString(l)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// createSquashFSFromLayer creates a squashfs image at squashFSPath from the contents of layerPath,
+// adding the Apptainer metadata files for environment and runscript.
+// The rest of the paths, inside workDir, are allocated for its exclusive use.
+func createSquashFSFromLayer(ctx context.Context, workDir, squashFSPath, layerPath string, environment, runscript []string) error {
+	extractedRootPath := filepath.Join(workDir, "rootfs")
+	scriptPath := filepath.Join(workDir, "script")
+	envPath := filepath.Join(workDir, "environment")
+	runscriptPath := filepath.Join(workDir, "runscript")
+	defer os.RemoveAll(extractedRootPath)
+	defer os.Remove(scriptPath)
+	defer os.Remove(envPath)
+	defer os.Remove(runscriptPath)
+
+	if err := os.WriteFile(envPath, []byte("#!/bin/sh\n"+joinLines(environment)), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(runscriptPath, []byte("#!/bin/sh\n"+joinLines(runscript)), 0755); err != nil {
+		return err
+	}
+	// The paths are passed as arguments, so that they don’t need to be quoted.
+	script := `#!/bin/sh
+set -e
+mkdir "$1"
+tar --acls --xattrs -C "$1" -xpf "$2"
+mkdir -p "$1/.singularity.d/env"
+cp "$3" "$1/.singularity.d/env/10-docker2singularity.sh"
+cp "$4" "$1/.singularity.d/runscript"
+mksquashfs "$1" "$5" -noappend -quiet
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		return err
+	}
+
+	log.DebugfContext(ctx, "Converting layer %s to squashfs %s ...", layerPath, squashFSPath)
+	cmd := exec.CommandContext(ctx, "fakeroot", "--", scriptPath, extractedRootPath, layerPath, envPath, runscriptPath, squashFSPath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("converting image: %w, output: %s", err, string(output))
+	}
+	log.DebugfContext(ctx, "... finished converting layer to squashfs")
+	return nil
+}
+
+// convertElementsToSIF creates a SIF file at sifPath from the layer at layerPath and config.
+// workDir can be assumed to be empty at start, and is exclusively used by the current process.
+func convertElementsToSIF(ctx context.Context, workDir, sifPath, layerPath string, config *imgspecv1.Image) error {
+	environment := environmentFromConfig(&config.Config)
+	runscript := runscriptFromConfig(&config.Config)
+
+	squashFSPath := filepath.Join(workDir, "rootfs.squashfs")
+	defer os.Remove(squashFSPath)
+	if err := createSquashFSFromLayer(ctx, workDir, squashFSPath, layerPath, environment, runscript); err != nil {
+		return err
+	}
+	squashFS, err := os.Open(squashFSPath)
+	if err != nil {
+		return err
+	}
+	defer squashFS.Close()
+
+	defFile, err := sif.NewDescriptorInput(sif.DataDeffile, bytes.NewReader(generateDefFile(environment, runscript)))
+	if err != nil {
+		return err
+	}
+	rootFS, err := sif.NewDescriptorInput(sif.DataPartition, squashFS,
+		sif.OptPartitionMetadata(sif.FsSquash, sif.PartPrimSys, config.Architecture))
+	if err != nil {
+		return fmt.Errorf("creating SIF rootfs partition for architecture %q: %w", config.Architecture, err)
+	}
+	opts := []sif.CreateOpt{
+		sif.OptCreateWithLaunchScript(sifLaunchScript),
+		sif.OptCreateWithDescriptors(defFile, rootFS),
+	}
+	if config.Created != nil {
+		opts = append(opts, sif.OptCreateWithTime(*config.Created))
+	}
+	log.DebugfContext(ctx, "Creating SIF file %s ...", sifPath)
+	sifImage, err := sif.CreateContainerAtPath(sifPath, opts...)
+	if err != nil {
+		return fmt.Errorf("creating SIF file: %w", err)
+	}
+	if err := sifImage.UnloadContainer(); err != nil {
+		return fmt.Errorf("writing SIF file: %w", err)
+	}
+	log.DebugfContext(ctx, "... finished creating SIF file")
+	return nil
+}
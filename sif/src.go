@@ -11,13 +11,13 @@ import (
 
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/tmpdir"
 	"github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
 	imgspecs "github.com/opencontainers/image-spec/specs-go"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 	"github.com/sylabs/sif/v2/pkg/sif"
 )
 
@@ -49,7 +49,7 @@ func getBlobInfo(path string) (digest.Digest, int64, error) {
 	// TODO: Instead of writing the tar file to disk, and reading
 	// it here again, stream the tar file to a pipe and
 	// compute the digest while writing it to disk.
-	logrus.Debugf("Computing a digest of the SIF conversion output...")
+	log.Debugf("Computing a digest of the SIF conversion output...")
 	digester := digest.Canonical.Digester()
 	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
 	size, err := io.Copy(digester.Hash(), f)
@@ -57,7 +57,7 @@ func getBlobInfo(path string) (digest.Digest, int64, error) {
 		return "", -1, fmt.Errorf("reading %q: %w", path, err)
 	}
 	digest := digester.Digest()
-	logrus.Debugf("... finished computing the digest of the SIF conversion output")
+	log.Debugf("... finished computing the digest of the SIF conversion output")
 
 	return digest, size, nil
 }
@@ -163,8 +163,10 @@ func TestReferenceNewImageSource(t *testing.T) {
 func TestReferenceNewImageDestination(t *testing.T) {
 	ref, tmpFile := refToTempFile(t)
 	defer os.Remove(tmpFile)
-	_, err := ref.NewImageDestination(context.Background(), nil)
-	assert.Error(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	assert.Equal(t, ref, dest.Reference())
 }
 
 func TestReferenceDeleteImage(t *testing.T) {
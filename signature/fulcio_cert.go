@@ -5,12 +5,13 @@ package signature
 
 import (
 	"crypto"
-	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/asn1"
 	"errors"
 	"fmt"
+	"regexp"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/containers/image/v5/signature/internal"
@@ -22,16 +23,51 @@ import (
 // Users should call validate() on the policy before using it.
 type fulcioTrustRoot struct {
 	caCertificates *x509.CertPool
-	oidcIssuer     string
-	subjectEmail   string
+	// certificateAuthorities, if not empty, is used instead of caCertificates;
+	// each CA is only trusted for certificates verified at a time within its validity period.
+	certificateAuthorities []trustedRootCertificateAuthority
+	// ctLogPublicKeys, if not empty, requires the certificate to contain a signed certificate timestamp from one of these logs.
+	ctLogPublicKeys []internal.CTLogPublicKey
+	// Exactly one of oidcIssuer and oidcIssuerRegex must be set.
+	oidcIssuer      string
+	oidcIssuerRegex *regexp.Regexp // Must match the whole issuer.
+	// Exactly one of subjectEmail, subjectEmailRegex, subjectURI, subjectURIPrefix and subjectURIRegex must be set.
+	subjectEmail      string
+	subjectEmailRegex *regexp.Regexp // Must match the whole email address.
+	subjectURI        string
+	subjectURIPrefix  string         // Matches any URI subject starting with this value.
+	subjectURIRegex   *regexp.Regexp // Must match the whole URI.
+	// githubWorkflowTrigger and githubWorkflowSHA, if not empty, are the required event which triggered a GitHub Actions workflow,
+	// and the commit it ran on, as recorded by Fulcio in certificate extensions.
+	githubWorkflowTrigger string
+	githubWorkflowSHA     string
+	// ignoreRelevantTime, if set, causes the certificate chain to be verified at the time the certificate was issued,
+	// instead of the relevantTime passed to verifyFulcioCertificateAtTime.
+	ignoreRelevantTime bool
+	// clockSkew is the maximum amount by which relevantTime may fall outside of the certificate’s validity period.
+	clockSkew time.Duration
+	// issuedAfter and issuedBefore, if not zero, restrict the accepted times of issuance (NotBefore) of the certificate.
+	issuedAfter  time.Time
+	issuedBefore time.Time
 }
 
 func (f *fulcioTrustRoot) validate() error {
-	if f.oidcIssuer == "" {
-		return errors.New("Internal inconsistency: Fulcio use set up without OIDC issuer")
+	if (f.oidcIssuer == "") == (f.oidcIssuerRegex == nil) {
+		return errors.New("Internal inconsistency: Fulcio use set up without exactly one expected OIDC issuer")
 	}
-	if f.subjectEmail == "" {
-		return errors.New("Internal inconsistency: Fulcio use set up without subject email")
+	subjects := 0
+	for _, s := range []string{f.subjectEmail, f.subjectURI, f.subjectURIPrefix} {
+		if s != "" {
+			subjects++
+		}
+	}
+	for _, re := range []*regexp.Regexp{f.subjectEmailRegex, f.subjectURIRegex} {
+		if re != nil {
+			subjects++
+		}
+	}
+	if subjects != 1 {
+		return errors.New("Internal inconsistency: Fulcio use set up without exactly one expected subject")
 	}
 	return nil
 }
@@ -39,55 +75,91 @@ func (f *fulcioTrustRoot) validate() error {
 // fulcioIssuerInCertificate returns the OIDC issuer recorded by Fulcio in unutrustedCertificate;
 // it fails if the extension is not present in the certificate, or on any inconsistency.
 func fulcioIssuerInCertificate(untrustedCertificate *x509.Certificate) (string, error) {
-	// == Validate the recorded OIDC issuer
-	gotOIDCIssuer1 := false
-	gotOIDCIssuer2 := false
-	var oidcIssuer1, oidcIssuer2 string
+	oidcIssuer, ok, err := fulcioExtensionInCertificate(untrustedCertificate, "OIDC issuer",
+		certificate.OIDIssuer, //nolint:staticcheck // This is deprecated, but we must continue to accept it.
+		certificate.OIDIssuerV2)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", internal.NewInvalidSignatureError("Fulcio certificate is missing the issuer extension")
+	}
+	return oidcIssuer, nil
+}
+
+// fulcioExtensionInCertificate returns the value of a Fulcio extension, described as name, in unutrustedCertificate,
+// either in a v1 extension with oidV1 (containing the raw value) or in a v2 extension with oidV2 (containing a DER-encoded string).
+// It returns false if neither extension is present; it fails on any inconsistency.
+func fulcioExtensionInCertificate(untrustedCertificate *x509.Certificate, name string, oidV1, oidV2 asn1.ObjectIdentifier) (string, bool, error) {
+	got1 := false
+	got2 := false
+	var value1, value2 string
 	// certificate.ParseExtensions doesn’t reject duplicate extensions, and doesn’t detect inconsistencies
-	// between certificate.OIDIssuer and certificate.OIDIssuerV2.
+	// between the v1 and v2 extensions.
 	// Go 1.19 rejects duplicate extensions universally; but until we can require Go 1.19,
 	// reject duplicates manually.
 	for _, untrustedExt := range untrustedCertificate.Extensions {
-		if untrustedExt.Id.Equal(certificate.OIDIssuer) { //nolint:staticcheck // This is deprecated, but we must continue to accept it.
-			if gotOIDCIssuer1 {
+		if untrustedExt.Id.Equal(oidV1) {
+			if got1 {
 				// Coverage: This is unreachable in Go ≥1.19, which rejects certificates with duplicate extensions
 				// already in ParseCertificate.
-				return "", internal.NewInvalidSignatureError("Fulcio certificate has a duplicate OIDC issuer v1 extension")
+				return "", false, internal.NewInvalidSignatureError(fmt.Sprintf("Fulcio certificate has a duplicate %s v1 extension", name))
 			}
-			oidcIssuer1 = string(untrustedExt.Value)
-			gotOIDCIssuer1 = true
-		} else if untrustedExt.Id.Equal(certificate.OIDIssuerV2) {
-			if gotOIDCIssuer2 {
+			value1 = string(untrustedExt.Value)
+			got1 = true
+		} else if untrustedExt.Id.Equal(oidV2) {
+			if got2 {
 				// Coverage: This is unreachable in Go ≥1.19, which rejects certificates with duplicate extensions
 				// already in ParseCertificate.
-				return "", internal.NewInvalidSignatureError("Fulcio certificate has a duplicate OIDC issuer v2 extension")
+				return "", false, internal.NewInvalidSignatureError(fmt.Sprintf("Fulcio certificate has a duplicate %s v2 extension", name))
 			}
-			rest, err := asn1.Unmarshal(untrustedExt.Value, &oidcIssuer2)
+			rest, err := asn1.Unmarshal(untrustedExt.Value, &value2)
 			if err != nil {
-				return "", internal.NewInvalidSignatureError(fmt.Sprintf("invalid ASN.1 in OIDC issuer v2 extension: %v", err))
+				return "", false, internal.NewInvalidSignatureError(fmt.Sprintf("invalid ASN.1 in %s v2 extension: %v", name, err))
 			}
 			if len(rest) != 0 {
-				return "", internal.NewInvalidSignatureError("invalid ASN.1 in OIDC issuer v2 extension, trailing data")
+				return "", false, internal.NewInvalidSignatureError(fmt.Sprintf("invalid ASN.1 in %s v2 extension, trailing data", name))
 			}
-			gotOIDCIssuer2 = true
+			got2 = true
 		}
 	}
 	switch {
-	case gotOIDCIssuer1 && gotOIDCIssuer2:
-		if oidcIssuer1 != oidcIssuer2 {
-			return "", internal.NewInvalidSignatureError(fmt.Sprintf("inconsistent OIDC issuer extension values: v1 %#v, v2 %#v",
-				oidcIssuer1, oidcIssuer2))
-		}
-		return oidcIssuer1, nil
-	case gotOIDCIssuer1:
-		return oidcIssuer1, nil
-	case gotOIDCIssuer2:
-		return oidcIssuer2, nil
+	case got1 && got2:
+		if value1 != value2 {
+			return "", false, internal.NewInvalidSignatureError(fmt.Sprintf("inconsistent %s extension values: v1 %#v, v2 %#v",
+				name, value1, value2))
+		}
+		return value1, true, nil
+	case got1:
+		return value1, true, nil
+	case got2:
+		return value2, true, nil
 	default:
-		return "", internal.NewInvalidSignatureError("Fulcio certificate is missing the issuer extension")
+		return "", false, nil
 	}
 }
 
+// fulcioGitHubWorkflowExtensions lists the Fulcio extensions describing a GitHub Actions workflow run
+// which can be required by fulcioTrustRoot.
+var fulcioGitHubWorkflowExtensions = []struct {
+	name         string
+	oidV1, oidV2 asn1.ObjectIdentifier
+	value        func(f *fulcioTrustRoot) string
+}{
+	{
+		name:  "build trigger",
+		oidV1: certificate.OIDGitHubWorkflowTrigger, //nolint:staticcheck // This is deprecated, but we must continue to accept it.
+		oidV2: certificate.OIDBuildTrigger,
+		value: func(f *fulcioTrustRoot) string { return f.githubWorkflowTrigger },
+	},
+	{
+		name:  "source repository digest",
+		oidV1: certificate.OIDGitHubWorkflowSHA, //nolint:staticcheck // This is deprecated, but we must continue to accept it.
+		oidV2: certificate.OIDSourceRepositoryDigest,
+		value: func(f *fulcioTrustRoot) string { return f.githubWorkflowSHA },
+	},
+}
+
 func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time, untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte) (crypto.PublicKey, error) {
 	// == Verify the certificate is correctly signed
 	var untrustedIntermediatePool *x509.CertPool // = nil
@@ -122,6 +194,17 @@ func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time,
 	}
 	untrustedCertificate := untrustedLeafCerts[0]
 
+	// relevantTime is usually the Rekor integrated time, i.e. it comes from a different clock than the certificate validity.
+	verificationTime := relevantTime
+	switch {
+	case f.ignoreRelevantTime:
+		verificationTime = untrustedCertificate.NotBefore
+	case relevantTime.Before(untrustedCertificate.NotBefore) && !relevantTime.Add(f.clockSkew).Before(untrustedCertificate.NotBefore):
+		verificationTime = untrustedCertificate.NotBefore
+	case relevantTime.After(untrustedCertificate.NotAfter) && !relevantTime.Add(-f.clockSkew).After(untrustedCertificate.NotAfter):
+		verificationTime = untrustedCertificate.NotAfter
+	}
+
 	// Go rejects Subject Alternative Name that has no DNSNames, EmailAddresses, IPAddresses and URIs;
 	// we match SAN ourselves, so override that.
 	if len(untrustedCertificate.UnhandledCriticalExtensions) > 0 {
@@ -134,22 +217,62 @@ func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time,
 		untrustedCertificate.UnhandledCriticalExtensions = remaining
 	}
 
-	if _, err := untrustedCertificate.Verify(x509.VerifyOptions{
+	roots := f.caCertificates
+	if len(f.certificateAuthorities) > 0 {
+		roots = x509.NewCertPool()
+		for _, ca := range f.certificateAuthorities {
+			if !ca.validAt(verificationTime) {
+				continue
+			}
+			roots.AddCert(ca.root)
+			if len(ca.intermediates) > 0 && untrustedIntermediatePool == nil {
+				untrustedIntermediatePool = x509.NewCertPool()
+			}
+			for _, intermediate := range ca.intermediates {
+				// These are trusted, but they are only used to build a chain to roots, so adding them to the pool is fine.
+				untrustedIntermediatePool.AddCert(intermediate)
+			}
+		}
+	}
+
+	chains, err := untrustedCertificate.Verify(x509.VerifyOptions{
 		Intermediates: untrustedIntermediatePool,
-		Roots:         f.caCertificates,
+		Roots:         roots,
 		// NOTE: Cosign uses untrustedCertificate.NotBefore here (i.e. uses _that_ time for intermediate certificate validation),
 		// and validates the leaf certificate against relevantTime manually.
-		// We verify the full certificate chain against relevantTime instead.
+		// We verify the full certificate chain against relevantTime (adjusted for clock skew) instead.
 		// Assuming the certificate is fulcio-generated and very short-lived, that should make little difference.
-		CurrentTime: relevantTime,
+		CurrentTime: verificationTime,
 		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
-	}); err != nil {
+	})
+	if err != nil {
 		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("veryfing leaf certificate failed: %v", err))
 	}
 
+	// == Validate the time of issuance
+	if !f.issuedAfter.IsZero() && untrustedCertificate.NotBefore.Before(f.issuedAfter) {
+		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Fulcio certificate was issued at %s, before the required %s",
+			untrustedCertificate.NotBefore.UTC().Format(time.RFC3339), f.issuedAfter.UTC().Format(time.RFC3339)))
+	}
+	if !f.issuedBefore.IsZero() && !untrustedCertificate.NotBefore.Before(f.issuedBefore) {
+		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Fulcio certificate was issued at %s, not before the required %s",
+			untrustedCertificate.NotBefore.UTC().Format(time.RFC3339), f.issuedBefore.UTC().Format(time.RFC3339)))
+	}
+
+	// == Validate the SCT, if the trust root includes certificate transparency logs
+	if len(f.ctLogPublicKeys) > 0 {
+		if len(chains[0]) < 2 {
+			return nil, internal.NewInvalidSignatureError("Fulcio certificate is self-signed")
+		}
+		if err := internal.VerifyEmbeddedSCT(f.ctLogPublicKeys, untrustedCertificate, chains[0][1]); err != nil {
+			return nil, err
+		}
+	}
+
 	// Cosign verifies a SCT of the certificate (either embedded, or even, probably irrelevant, externally-supplied).
 	//
-	// We don’t currently do that.
+	// We only do that if the trusted root lists certificate transparency logs; with CA certificates configured
+	// directly, there is no way to configure the logs.
 	//
 	// At the very least, with Fulcio we require Rekor SETs to prove Rekor contains a log of the signature, and that
 	// already contains the full certificate; so a SCT of the certificate is superfluous (assuming Rekor allowed searching by
@@ -172,36 +295,101 @@ func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time,
 	if err != nil {
 		return nil, err
 	}
-	if oidcIssuer != f.oidcIssuer {
+	if f.oidcIssuerRegex != nil {
+		if !f.oidcIssuerRegex.MatchString(oidcIssuer) {
+			return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Unexpected Fulcio OIDC issuer %q, not matching %q", oidcIssuer, f.oidcIssuerRegex.String()))
+		}
+	} else if oidcIssuer != f.oidcIssuer {
 		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Unexpected Fulcio OIDC issuer %q", oidcIssuer))
 	}
 
 	// == Validate the OIDC subject
-	if !slices.Contains(untrustedCertificate.EmailAddresses, f.subjectEmail) {
-		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Required email %q not found (got %q)",
-			f.subjectEmail,
-			untrustedCertificate.EmailAddresses))
+	switch {
+	case f.subjectEmail != "":
+		if !slices.Contains(untrustedCertificate.EmailAddresses, f.subjectEmail) {
+			return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Required email %q not found (got %q)",
+				f.subjectEmail,
+				untrustedCertificate.EmailAddresses))
+		}
+	case f.subjectEmailRegex != nil:
+		if !slices.ContainsFunc(untrustedCertificate.EmailAddresses, f.subjectEmailRegex.MatchString) {
+			return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Required email matching %q not found (got %q)",
+				f.subjectEmailRegex.String(),
+				untrustedCertificate.EmailAddresses))
+		}
+	default:
+		untrustedURIs := make([]string, 0, len(untrustedCertificate.URIs))
+		for _, u := range untrustedCertificate.URIs {
+			untrustedURIs = append(untrustedURIs, u.String())
+		}
+		if f.subjectURIRegex != nil {
+			if !slices.ContainsFunc(untrustedURIs, f.subjectURIRegex.MatchString) {
+				return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Required URI matching %q not found (got %q)",
+					f.subjectURIRegex.String(), untrustedURIs))
+			}
+			break
+		}
+		if !slices.ContainsFunc(untrustedURIs, func(u string) bool {
+			if f.subjectURI != "" {
+				return u == f.subjectURI
+			}
+			return strings.HasPrefix(u, f.subjectURIPrefix)
+		}) {
+			expected := f.subjectURI
+			if expected == "" {
+				expected = f.subjectURIPrefix + "*"
+			}
+			return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Required URI %q not found (got %q)",
+				expected, untrustedURIs))
+		}
+	}
+	// == Validate the recorded GitHub Actions workflow details
+	for _, ext := range fulcioGitHubWorkflowExtensions {
+		expected := ext.value(f)
+		if expected == "" {
+			continue
+		}
+		value, ok, err := fulcioExtensionInCertificate(untrustedCertificate, ext.name, ext.oidV1, ext.oidV2)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Fulcio certificate is missing the %s extension", ext.name))
+		}
+		if value != expected {
+			return nil, internal.NewInvalidSignatureError(fmt.Sprintf("Unexpected Fulcio %s %q, expected %q", ext.name, value, expected))
+		}
 	}
+
 	// FIXME: Match more subject types? Cosign does:
 	// - .DNSNames (can’t be issued by Fulcio)
 	// - .IPAddresses (can’t be issued by Fulcio)
-	// - .URIs (CAN be issued by Fulcio)
 	// - OtherName values in SAN (CAN be issued by Fulcio)
-	// - Various values about GitHub workflows (CAN be issued by Fulcio)
+	// - Various values about GitHub workflows (CAN be issued by Fulcio; we only match the trigger and SHA)
 	// What does it… mean to get an OAuth2 identity for an IP address?
 	// FIXME: How far into Turing-completeness for the issuer/subject do we need to get? Simultaneously accepted alternatives, for
-	// issuers and/or subjects and/or combinations? Regexps? More?
+	// issuers and/or subjects and/or combinations? More?
 
 	return untrustedCertificate.PublicKey, nil
 }
 
-func verifyRekorFulcio(rekorPublicKey *ecdsa.PublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
+func verifyRekorFulcio(rekorPublicKeys []internal.RekorPublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
 	untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedBase64Signature string,
 	untrustedPayloadBytes []byte) (crypto.PublicKey, error) {
-	rekorSETTime, err := internal.VerifyRekorSET(rekorPublicKey, untrustedRekorSET, untrustedCertificateBytes,
+	rekorSETTime, err := internal.VerifyRekorSET(rekorPublicKeys, untrustedRekorSET, untrustedCertificateBytes,
 		untrustedBase64Signature, untrustedPayloadBytes)
 	if err != nil {
-		return nil, err
+		return nil, rekorVerificationError{err: err}
+	}
+	return fulcioTrustRoot.verifyFulcioCertificateAtTime(rekorSETTime, untrustedCertificateBytes, untrustedIntermediateChainBytes)
+}
+
+// verifyRekorFulcioDSSE is verifyRekorFulcio for a DSSE envelope, which is recorded in Rekor as a "dsse" entry.
+func verifyRekorFulcioDSSE(rekorPublicKeys []internal.RekorPublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
+	untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedEnvelope []byte) (crypto.PublicKey, error) {
+	rekorSETTime, err := internal.VerifyRekorSETForDSSE(rekorPublicKeys, untrustedRekorSET, untrustedCertificateBytes, untrustedEnvelope)
+	if err != nil {
+		return nil, rekorVerificationError{err: err}
 	}
 	return fulcioTrustRoot.verifyFulcioCertificateAtTime(rekorSETTime, untrustedCertificateBytes, untrustedIntermediateChainBytes)
 }
@@ -5,24 +5,53 @@ package signature
 
 import (
 	"crypto"
-	"crypto/ecdsa"
 	"crypto/x509"
 	"errors"
+	"regexp"
+	"time"
+
+	"github.com/containers/image/v5/signature/internal"
 )
 
 type fulcioTrustRoot struct {
-	caCertificates *x509.CertPool
-	oidcIssuer     string
-	subjectEmail   string
+	caCertificates         *x509.CertPool
+	certificateAuthorities []trustedRootCertificateAuthority
+	ctLogPublicKeys        []internal.CTLogPublicKey
+	oidcIssuer             string
+	oidcIssuerRegex        *regexp.Regexp
+	subjectEmail           string
+	subjectEmailRegex      *regexp.Regexp
+	subjectURI             string
+	subjectURIPrefix       string
+	subjectURIRegex        *regexp.Regexp
+	githubWorkflowTrigger  string
+	githubWorkflowSHA      string
+	ignoreRelevantTime     bool
+	clockSkew              time.Duration
+	issuedAfter            time.Time
+	issuedBefore           time.Time
 }
 
 func (f *fulcioTrustRoot) validate() error {
 	return errors.New("fulcio disabled at compile-time")
 }
 
-func verifyRekorFulcio(rekorPublicKey *ecdsa.PublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
+func (f *fulcioTrustRoot) verifyFulcioCertificateAtTime(relevantTime time.Time, untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte) (crypto.PublicKey, error) {
+	return nil, errors.New("fulcio disabled at compile-time")
+}
+
+func fulcioIssuerInCertificate(untrustedCertificate *x509.Certificate) (string, error) {
+	return "", errors.New("fulcio disabled at compile-time")
+}
+
+func verifyRekorFulcio(rekorPublicKeys []internal.RekorPublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
 	untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedBase64Signature string,
 	untrustedPayloadBytes []byte) (crypto.PublicKey, error) {
 	return nil, errors.New("fulcio disabled at compile-time")
 
 }
+
+func verifyRekorFulcioDSSE(rekorPublicKeys []internal.RekorPublicKey, fulcioTrustRoot *fulcioTrustRoot, untrustedRekorSET []byte,
+	untrustedCertificateBytes []byte, untrustedIntermediateChainBytes []byte, untrustedEnvelope []byte) (crypto.PublicKey, error) {
+	return nil, errors.New("fulcio disabled at compile-time")
+}
@@ -13,10 +13,13 @@ import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/pem"
+	"net/url"
 	"os"
+	"regexp"
 	"testing"
 	"time"
 
+	"github.com/containers/image/v5/signature/internal"
 	"github.com/sigstore/fulcio/pkg/certificate"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/stretchr/testify/assert"
@@ -50,6 +53,42 @@ func TestFulcioTrustRootValidate(t *testing.T) {
 			oidcIssuer:     "issuer",
 			subjectEmail:   "",
 		},
+		{
+			caCertificates: certs,
+			oidcIssuer:     "issuer",
+			subjectEmail:   "email",
+			subjectURI:     "https://example.com",
+		},
+		{
+			caCertificates:   certs,
+			oidcIssuer:       "issuer",
+			subjectURI:       "https://example.com",
+			subjectURIPrefix: "https://example.com",
+		},
+		{
+			caCertificates:  certs,
+			oidcIssuer:      "issuer",
+			oidcIssuerRegex: regexp.MustCompile("^issuer$"),
+			subjectEmail:    "email",
+		},
+		{
+			caCertificates:    certs,
+			oidcIssuer:        "issuer",
+			subjectEmail:      "email",
+			subjectEmailRegex: regexp.MustCompile("^email$"),
+		},
+		{
+			caCertificates:  certs,
+			oidcIssuer:      "issuer",
+			subjectURI:      "https://example.com",
+			subjectURIRegex: regexp.MustCompile("^https://example\\.com$"),
+		},
+		{
+			caCertificates:    certs,
+			oidcIssuer:        "issuer",
+			subjectEmailRegex: regexp.MustCompile("^email$"),
+			subjectURIRegex:   regexp.MustCompile("^https://example\\.com$"),
+		},
 	} {
 		err := tr.validate()
 		assert.Error(t, err)
@@ -62,6 +101,30 @@ func TestFulcioTrustRootValidate(t *testing.T) {
 	}
 	err := tr.validate()
 	assert.NoError(t, err)
+
+	tr = fulcioTrustRoot{
+		caCertificates:   certs,
+		oidcIssuer:       "issuer",
+		subjectURIPrefix: "https://example.com/",
+	}
+	err = tr.validate()
+	assert.NoError(t, err)
+
+	tr = fulcioTrustRoot{
+		caCertificates:    certs,
+		oidcIssuerRegex:   regexp.MustCompile("^issuer$"),
+		subjectEmailRegex: regexp.MustCompile("^email$"),
+	}
+	err = tr.validate()
+	assert.NoError(t, err)
+
+	tr = fulcioTrustRoot{
+		caCertificates:  certs,
+		oidcIssuer:      "issuer",
+		subjectURIRegex: regexp.MustCompile("^spiffe://example\\.com/.*$"),
+	}
+	err = tr.validate()
+	assert.NoError(t, err)
 }
 
 // oidIssuerV1Ext creates an certificate.OIDIssuer extension
@@ -87,6 +150,14 @@ func oidIssuerV2Ext(t *testing.T, value string) pkix.Extension {
 	}
 }
 
+// githubWorkflowV2Exts creates certificate.OIDBuildTrigger and certificate.OIDSourceRepositoryDigest extensions
+func githubWorkflowV2Exts(t *testing.T, trigger, sha string) []pkix.Extension {
+	return []pkix.Extension{
+		{Id: certificate.OIDBuildTrigger, Value: asn1MarshalTest(t, trigger, "utf8")},
+		{Id: certificate.OIDSourceRepositoryDigest, Value: asn1MarshalTest(t, sha, "utf8")},
+	}
+}
+
 func TestFulcioIssuerInCertificate(t *testing.T) {
 	referenceTime := time.Now()
 	fulcioExtensions, err := certificate.Extensions{Issuer: "https://github.com/login/oauth"}.Render()
@@ -276,6 +347,60 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 		assert.Error(t, err)
 		assert.Nil(t, pk)
 	}
+	// … which is accepted with a sufficient clock skew tolerance, or if relevantTime is ignored
+	for _, tm := range []time.Time{
+		time.Date(2022, time.December, 12, 18, 48, 17, 0, time.UTC),
+		time.Date(2022, time.December, 12, 18, 58, 19, 0, time.UTC),
+	} {
+		trWithSkew := tr
+		trWithSkew.clockSkew = 5 * time.Second
+		pk, err := trWithSkew.verifyFulcioCertificateAtTime(tm, fulcioCertBytes, fulcioChainBytes)
+		require.NoError(t, err)
+		assertPublicKeyMatchesCert(t, fulcioCertBytes, pk)
+
+		trIgnoringTime := tr
+		trIgnoringTime.ignoreRelevantTime = true
+		pk, err = trIgnoringTime.verifyFulcioCertificateAtTime(tm, fulcioCertBytes, fulcioChainBytes)
+		require.NoError(t, err)
+		assertPublicKeyMatchesCert(t, fulcioCertBytes, pk)
+	}
+	for _, tm := range []time.Time{
+		time.Date(2022, time.December, 12, 18, 48, 10, 0, time.UTC),
+		time.Date(2022, time.December, 12, 18, 58, 25, 0, time.UTC),
+	} {
+		trWithSkew := tr
+		trWithSkew.clockSkew = 5 * time.Second
+		pk, err := trWithSkew.verifyFulcioCertificateAtTime(tm, fulcioCertBytes, fulcioChainBytes)
+		assert.Error(t, err)
+		assert.Nil(t, pk)
+	}
+
+	// Time of issuance constraints
+	beforeIssuance := time.Date(2022, time.December, 12, 18, 0, 0, 0, time.UTC)
+	afterIssuance := time.Date(2022, time.December, 12, 19, 0, 0, 0, time.UTC)
+	for _, c := range []struct{ issuedAfter, issuedBefore time.Time }{
+		{issuedAfter: beforeIssuance},
+		{issuedBefore: afterIssuance},
+		{issuedAfter: beforeIssuance, issuedBefore: afterIssuance},
+	} {
+		trWithWindow := tr
+		trWithWindow.issuedAfter = c.issuedAfter
+		trWithWindow.issuedBefore = c.issuedBefore
+		pk, err := trWithWindow.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, fulcioChainBytes)
+		require.NoError(t, err, "%#v", c)
+		assertPublicKeyMatchesCert(t, fulcioCertBytes, pk)
+	}
+	for _, c := range []struct{ issuedAfter, issuedBefore time.Time }{
+		{issuedAfter: afterIssuance},
+		{issuedBefore: beforeIssuance},
+	} {
+		trWithWindow := tr
+		trWithWindow.issuedAfter = c.issuedAfter
+		trWithWindow.issuedBefore = c.issuedBefore
+		pk, err := trWithWindow.verifyFulcioCertificateAtTime(time.Unix(1670870899, 0), fulcioCertBytes, fulcioChainBytes)
+		assert.ErrorContains(t, err, "Fulcio certificate was issued at", "%#v", c)
+		assert.Nil(t, pk)
+	}
 
 	referenceTime := time.Now()
 	testCAKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
@@ -298,9 +423,15 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 	testCACertPool := x509.NewCertPool()
 	testCACertPool.AddCert(testCACert)
 
+	testURI, err := url.Parse("https://github.com/containers/image/.github/workflows/release.yml@refs/heads/main")
+	require.NoError(t, err)
+	testSPIFFEID, err := url.Parse("spiffe://example.com/ns/default/sa/builder")
+	require.NoError(t, err)
+	const testSHA = "0123456789abcdef0123456789abcdef01234567"
 	for _, c := range []struct {
 		name          string
 		fn            func(cert *x509.Certificate)
+		trFn          func(tr *fulcioTrustRoot) // If not nil, modifies the trust root
 		errorFragment string
 	}{
 		{
@@ -390,6 +521,226 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 			},
 			errorFragment: `Required email "test-user@example.com" not found`,
 		},
+		{
+			name: "Issuer regex matches",
+			fn:   func(cert *x509.Certificate) {},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.oidcIssuer = ""
+				tr.oidcIssuerRegex = regexp.MustCompile(`^(?:https://github\.com/.*)$`)
+			},
+			errorFragment: "",
+		},
+		{
+			name: "Issuer regex mismatch",
+			fn:   func(cert *x509.Certificate) {},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.oidcIssuer = ""
+				tr.oidcIssuerRegex = regexp.MustCompile(`^(?:https://gitlab\.com/.*)$`)
+			},
+			errorFragment: "Unexpected Fulcio OIDC issuer",
+		},
+		{
+			name: "Email regex matches",
+			fn: func(cert *x509.Certificate) {
+				cert.EmailAddresses = []string{"a@example.org", "test-user@example.com"}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectEmailRegex = regexp.MustCompile(`^(?:.*@example\.com)$`)
+			},
+			errorFragment: "",
+		},
+		{
+			name: "Email regex mismatch",
+			fn: func(cert *x509.Certificate) {
+				cert.EmailAddresses = []string{"test-user@example.com.evil.example"}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectEmailRegex = regexp.MustCompile(`^(?:.*@example\.com)$`)
+			},
+			errorFragment: `Required email matching "^(?:.*@example\\.com)$" not found`,
+		},
+		{
+			name: "URI matches",
+			fn: func(cert *x509.Certificate) {
+				cert.URIs = []*url.URL{testURI}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURI = testURI.String()
+			},
+			errorFragment: "",
+		},
+		{
+			name: "URI mismatch",
+			fn: func(cert *x509.Certificate) {
+				cert.URIs = []*url.URL{testURI}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURI = "https://github.com/containers/image/.github/workflows/release.yml@refs/heads/other"
+			},
+			errorFragment: `Required URI "https://github.com/containers/image/.github/workflows/release.yml@refs/heads/other" not found`,
+		},
+		{
+			name: "Missing URI",
+			fn:   func(cert *x509.Certificate) {},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURI = testURI.String()
+			},
+			errorFragment: "Required URI",
+		},
+		{
+			name: "SPIFFE ID matches",
+			fn: func(cert *x509.Certificate) {
+				cert.URIs = []*url.URL{testURI, testSPIFFEID}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURI = testSPIFFEID.String()
+			},
+			errorFragment: "",
+		},
+		{
+			name: "URI regex matches",
+			fn: func(cert *x509.Certificate) {
+				cert.URIs = []*url.URL{testURI, testSPIFFEID}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURIRegex = regexp.MustCompile(`^(?:spiffe://example\.com/ns/[^/]+/sa/builder)$`)
+			},
+			errorFragment: "",
+		},
+		{
+			name: "URI regex mismatch",
+			fn: func(cert *x509.Certificate) {
+				cert.URIs = []*url.URL{testURI}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURIRegex = regexp.MustCompile(`^(?:spiffe://example\.com/ns/[^/]+/sa/builder)$`)
+			},
+			errorFragment: "Required URI matching",
+		},
+		{
+			name: "URI regex does not match emails",
+			fn:   func(cert *x509.Certificate) {},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURIRegex = regexp.MustCompile(`^(?:.*)$`)
+			},
+			errorFragment: "Required URI matching",
+		},
+		{
+			name: "URI prefix matches",
+			fn: func(cert *x509.Certificate) {
+				cert.URIs = []*url.URL{testURI}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURIPrefix = "https://github.com/containers/image/.github/workflows/release.yml@"
+			},
+			errorFragment: "",
+		},
+		{
+			name: "URI prefix mismatch",
+			fn: func(cert *x509.Certificate) {
+				cert.URIs = []*url.URL{testURI}
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.subjectEmail = ""
+				tr.subjectURIPrefix = "https://github.com/containers/image/.github/workflows/other.yml@"
+			},
+			errorFragment: `Required URI "https://github.com/containers/image/.github/workflows/other.yml@*" not found`,
+		},
+		{
+			name: "GitHub workflow v2 extensions match",
+			fn: func(cert *x509.Certificate) {
+				cert.ExtraExtensions = append(cert.ExtraExtensions, githubWorkflowV2Exts(t, "push", testSHA)...)
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.githubWorkflowTrigger = "push"
+				tr.githubWorkflowSHA = testSHA
+			},
+			errorFragment: "",
+		},
+		{
+			name: "GitHub workflow v1 extensions match",
+			fn: func(cert *x509.Certificate) {
+				cert.ExtraExtensions = append(cert.ExtraExtensions,
+					pkix.Extension{Id: certificate.OIDGitHubWorkflowTrigger, Value: []byte("push")}, //nolint:staticcheck // This is deprecated, but we must continue to accept it.
+					pkix.Extension{Id: certificate.OIDGitHubWorkflowSHA, Value: []byte(testSHA)},    //nolint:staticcheck // This is deprecated, but we must continue to accept it.
+				)
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.githubWorkflowTrigger = "push"
+				tr.githubWorkflowSHA = testSHA
+			},
+			errorFragment: "",
+		},
+		{
+			name: "GitHub workflow extensions not required",
+			fn: func(cert *x509.Certificate) {
+				cert.ExtraExtensions = append(cert.ExtraExtensions, githubWorkflowV2Exts(t, "pull_request", testSHA)...)
+			},
+			errorFragment: "",
+		},
+		{
+			name: "GitHub workflow trigger mismatch",
+			fn: func(cert *x509.Certificate) {
+				cert.ExtraExtensions = append(cert.ExtraExtensions, githubWorkflowV2Exts(t, "pull_request", testSHA)...)
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.githubWorkflowTrigger = "push"
+			},
+			errorFragment: `Unexpected Fulcio build trigger "pull_request", expected "push"`,
+		},
+		{
+			name: "GitHub workflow SHA mismatch",
+			fn: func(cert *x509.Certificate) {
+				cert.ExtraExtensions = append(cert.ExtraExtensions, githubWorkflowV2Exts(t, "push", testSHA)...)
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.githubWorkflowSHA = "fedcba9876543210fedcba9876543210fedcba98"
+			},
+			errorFragment: "Unexpected Fulcio source repository digest",
+		},
+		{
+			name: "Missing GitHub workflow extension",
+			fn:   func(cert *x509.Certificate) {},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.githubWorkflowSHA = testSHA
+			},
+			errorFragment: "Fulcio certificate is missing the source repository digest extension",
+		},
+		{
+			name: "Inconsistent GitHub workflow v1 and v2 extensions",
+			fn: func(cert *x509.Certificate) {
+				cert.ExtraExtensions = append(cert.ExtraExtensions, githubWorkflowV2Exts(t, "push", testSHA)...)
+				cert.ExtraExtensions = append(cert.ExtraExtensions,
+					pkix.Extension{Id: certificate.OIDGitHubWorkflowTrigger, Value: []byte("pull_request")}) //nolint:staticcheck // This is deprecated, but we must continue to accept it.
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.githubWorkflowTrigger = "push"
+			},
+			errorFragment: "inconsistent build trigger extension values",
+		},
+		{
+			name: "Invalid GitHub workflow v2 extension",
+			fn: func(cert *x509.Certificate) {
+				cert.ExtraExtensions = append(cert.ExtraExtensions, pkix.Extension{
+					Id:    certificate.OIDBuildTrigger,
+					Value: asn1MarshalTest(t, 1, ""), // not a string type
+				})
+			},
+			trFn: func(tr *fulcioTrustRoot) {
+				tr.githubWorkflowTrigger = "push"
+			},
+			errorFragment: "invalid ASN.1 in build trigger v2 extension",
+		},
 	} {
 		testLeafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 		require.NoError(t, err, c.name)
@@ -411,6 +762,9 @@ func TestFulcioTrustRootVerifyFulcioCertificateAtTime(t *testing.T) {
 			oidcIssuer:     "https://github.com/login/oauth",
 			subjectEmail:   "test-user@example.com",
 		}
+		if c.trFn != nil {
+			c.trFn(&tr)
+		}
 		testLeafPEM := pem.EncodeToMemory(&pem.Block{
 			Type:  "CERTIFICATE",
 			Bytes: testLeafCert,
@@ -450,7 +804,7 @@ func TestVerifyRekorFulcio(t *testing.T) {
 	require.NoError(t, err)
 
 	// Success
-	pk, err := verifyRekorFulcio(rekorKeyECDSA, &fulcioTrustRoot{
+	pk, err := verifyRekorFulcio([]internal.RekorPublicKey{{Key: rekorKeyECDSA}}, &fulcioTrustRoot{
 		caCertificates: caCertificates,
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "mitr@redhat.com",
@@ -459,7 +813,7 @@ func TestVerifyRekorFulcio(t *testing.T) {
 	assertPublicKeyMatchesCert(t, certBytes, pk)
 
 	// Rekor failure
-	pk, err = verifyRekorFulcio(rekorKeyECDSA, &fulcioTrustRoot{
+	pk, err = verifyRekorFulcio([]internal.RekorPublicKey{{Key: rekorKeyECDSA}}, &fulcioTrustRoot{
 		caCertificates: caCertificates,
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "mitr@redhat.com",
@@ -468,7 +822,7 @@ func TestVerifyRekorFulcio(t *testing.T) {
 	assert.Nil(t, pk)
 
 	// Fulcio failure
-	pk, err = verifyRekorFulcio(rekorKeyECDSA, &fulcioTrustRoot{
+	pk, err = verifyRekorFulcio([]internal.RekorPublicKey{{Key: rekorKeyECDSA}}, &fulcioTrustRoot{
 		caCertificates: caCertificates,
 		oidcIssuer:     "https://github.com/login/oauth",
 		subjectEmail:   "this-does-not-match@example.com",
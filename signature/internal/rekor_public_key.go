@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"time"
+)
+
+// RekorPublicKey is a public key of a Rekor log (shard).
+type RekorPublicKey struct {
+	Key *ecdsa.PublicKey
+	// ValidFrom and ValidUntil, if not zero, restrict the integration times of log entries which can be signed by Key.
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// validAt returns true if k may be used to sign a log entry integrated at t.
+func (k RekorPublicKey) validAt(t time.Time) bool {
+	if !k.ValidFrom.IsZero() && t.Before(k.ValidFrom) {
+		return false
+	}
+	if !k.ValidUntil.IsZero() && t.After(k.ValidUntil) {
+		return false
+	}
+	return true
+}
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -22,6 +23,9 @@ import (
 // We could alternatively use github.com/sigstore/rekor/pkg/types/hashedrekord.APIVERSION, but that subpackage adds too many dependencies.
 const HashedRekordV001APIVersion = "0.0.1"
 
+// This is the github.com/sigstore/rekor/pkg/generated/models.DSSE.APIVersion for github.com/sigstore/rekor/pkg/generated/models.DSSEV001Schema.
+const DSSEV001APIVersion = "0.0.1"
+
 // UntrustedRekorSET is a parsed content of the sigstore-signature Rekor SET
 // (note that this a signature-specific format, not a format directly used by the Rekor API).
 // This corresponds to github.com/sigstore/cosign/bundle.RekorBundle, but we impose a stricter decoder.
@@ -37,6 +41,27 @@ type UntrustedRekorPayload struct {
 	LogID          string
 }
 
+// rekorShardKey returns the key in publicKeys of the log shard identified by the "logID" field of untrustedSETPayload.
+func rekorShardKey(publicKeys []RekorPublicKey, untrustedSETPayload []byte) (RekorPublicKey, error) {
+	// The payload is not verified yet, so we only use the log ID to choose a key; the signature is verified by the caller.
+	var untrustedLogID struct {
+		LogID string `json:"logID"`
+	}
+	if err := json.Unmarshal(untrustedSETPayload, &untrustedLogID); err != nil {
+		return RekorPublicKey{}, NewInvalidSignatureError(fmt.Sprintf("parsing Rekor SET payload: %v", err.Error()))
+	}
+	for _, pk := range publicKeys {
+		logID, err := RekorLogID(pk.Key)
+		if err != nil {
+			return RekorPublicKey{}, fmt.Errorf("computing Rekor log ID: %w", err)
+		}
+		if logID == untrustedLogID.LogID {
+			return pk, nil
+		}
+	}
+	return RekorPublicKey{}, NewInvalidSignatureError(fmt.Sprintf("Rekor SET is from an unknown log %q", untrustedLogID.LogID))
+}
+
 // A compile-time check that UntrustedRekorSET implements json.Unmarshaler
 var _ json.Unmarshaler = (*UntrustedRekorSET)(nil)
 
@@ -111,27 +136,49 @@ func (p UntrustedRekorPayload) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// VerifyRekorSET verifies that unverifiedRekorSET is correctly signed by publicKey and matches the rest of the data.
-// Returns bundle upload time on success.
-func VerifyRekorSET(publicKey *ecdsa.PublicKey, unverifiedRekorSET []byte, unverifiedKeyOrCertBytes []byte, unverifiedBase64Signature string, unverifiedPayloadBytes []byte) (time.Time, error) {
-	// FIXME: Should the publicKey parameter hard-code ecdsa?
+// RekorLogID returns the ID of a Rekor log (shard) using publicKey, as recorded in the "logID" field of Rekor SETs.
+func RekorLogID(publicKey *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// verifyRekorSETSignature verifies that unverifiedRekorSET is correctly signed by one of publicKeys, and returns the used key
+// and the parsed SET payload.
+// If there is a single public key, it is used regardless of the log ID in the SET; if there are more,
+// each is treated as a separate log shard, and the SET must be signed by the key of the shard matching its log ID.
+func verifyRekorSETSignature(publicKeys []RekorPublicKey, unverifiedRekorSET []byte) (RekorPublicKey, *UntrustedRekorPayload, error) {
+	// FIXME: Should the publicKeys parameter hard-code ecdsa?
+	if len(publicKeys) == 0 {
+		return RekorPublicKey{}, nil, NewInvalidSignatureError("no Rekor public keys provided")
+	}
 
 	// == Parse SET bytes
 	var untrustedSET UntrustedRekorSET
 	// Sadly. we need to parse and transform untrusted data before verifying a cryptographic signature...
 	if err := json.Unmarshal(unverifiedRekorSET, &untrustedSET); err != nil {
-		return time.Time{}, NewInvalidSignatureError(err.Error())
+		return RekorPublicKey{}, nil, NewInvalidSignatureError(err.Error())
 	}
 	// == Verify SET signature
 	// Cosign unmarshals and re-marshals UntrustedPayload; that seems unnecessary,
 	// assuming jsoncanonicalizer is designed to operate on untrusted data.
 	untrustedSETPayloadCanonicalBytes, err := jsoncanonicalizer.Transform(untrustedSET.UntrustedPayload)
 	if err != nil {
-		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("canonicalizing Rekor SET JSON: %v", err))
+		return RekorPublicKey{}, nil, NewInvalidSignatureError(fmt.Sprintf("canonicalizing Rekor SET JSON: %v", err))
+	}
+	publicKey := publicKeys[0]
+	if len(publicKeys) > 1 {
+		publicKey, err = rekorShardKey(publicKeys, untrustedSETPayloadCanonicalBytes)
+		if err != nil {
+			return RekorPublicKey{}, nil, err
+		}
 	}
 	untrustedSETPayloadHash := sha256.Sum256(untrustedSETPayloadCanonicalBytes)
-	if !ecdsa.VerifyASN1(publicKey, untrustedSETPayloadHash[:], untrustedSET.UntrustedSignedEntryTimestamp) {
-		return time.Time{}, NewInvalidSignatureError("cryptographic signature verification of Rekor SET failed")
+	if !ecdsa.VerifyASN1(publicKey.Key, untrustedSETPayloadHash[:], untrustedSET.UntrustedSignedEntryTimestamp) {
+		return RekorPublicKey{}, nil, NewInvalidSignatureError("cryptographic signature verification of Rekor SET failed")
 	}
 
 	// == Parse SET payload
@@ -141,7 +188,57 @@ func VerifyRekorSET(publicKey *ecdsa.PublicKey, unverifiedRekorSET []byte, unver
 	// of the SET payload.
 	var rekorPayload UntrustedRekorPayload
 	if err := json.Unmarshal(untrustedSETPayloadCanonicalBytes, &rekorPayload); err != nil {
-		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("parsing Rekor SET payload: %v", err.Error()))
+		return RekorPublicKey{}, nil, NewInvalidSignatureError(fmt.Sprintf("parsing Rekor SET payload: %v", err.Error()))
+	}
+	return publicKey, &rekorPayload, nil
+}
+
+// matchRekorKeyOrCert verifies that rekorKeyOrCertPEMBytes, the public key or certificate recorded in a Rekor entry,
+// matches unverifiedKeyOrCertBytes.
+func matchRekorKeyOrCert(rekorKeyOrCertPEMBytes []byte, unverifiedKeyOrCertBytes []byte) error {
+	rekorKeyOrCertPEM, rest := pem.Decode(rekorKeyOrCertPEMBytes)
+	if rekorKeyOrCertPEM == nil {
+		return NewInvalidSignatureError("publicKey in Rekor SET is not in PEM format")
+	}
+	if len(rest) != 0 {
+		return NewInvalidSignatureError("publicKey in Rekor SET has trailing data")
+	}
+	// FIXME: For public keys, let the caller provide the DER-formatted blob instead
+	// of round-tripping through PEM.
+	unverifiedKeyOrCertPEM, rest := pem.Decode(unverifiedKeyOrCertBytes)
+	if unverifiedKeyOrCertPEM == nil {
+		return NewInvalidSignatureError("public key or cert to be matched against publicKey in Rekor SET is not in PEM format")
+	}
+	if len(rest) != 0 {
+		return NewInvalidSignatureError("public key or cert to be matched against publicKey in Rekor SET has trailing data")
+	}
+	// NOTE: This compares the PEM payload, but not the object type or headers.
+	if !bytes.Equal(rekorKeyOrCertPEM.Bytes, unverifiedKeyOrCertPEM.Bytes) {
+		return NewInvalidSignatureError("publicKey in Rekor SET does not match")
+	}
+	return nil
+}
+
+// rekorIntegratedTime returns the integration time recorded in rekorPayload,
+// after verifying that it is within the validity period of publicKey.
+func rekorIntegratedTime(publicKey RekorPublicKey, rekorPayload *UntrustedRekorPayload) (time.Time, error) {
+	integratedTime := time.Unix(rekorPayload.IntegratedTime, 0)
+	if !publicKey.validAt(integratedTime) {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("Rekor SET integration time %v is outside of the validity period of the Rekor public key",
+			integratedTime.UTC()))
+	}
+	return integratedTime, nil
+}
+
+// VerifyRekorSET verifies that unverifiedRekorSET is correctly signed by one of publicKeys and matches the rest of the data.
+// If there is a single public key, it is used regardless of the log ID in the SET; if there are more,
+// each is treated as a separate log shard, and the SET must be signed by the key of the shard matching its log ID.
+// The integration time recorded in the SET must be within the validity period of the used key, if any.
+// Returns bundle upload time on success.
+func VerifyRekorSET(publicKeys []RekorPublicKey, unverifiedRekorSET []byte, unverifiedKeyOrCertBytes []byte, unverifiedBase64Signature string, unverifiedPayloadBytes []byte) (time.Time, error) {
+	publicKey, rekorPayload, err := verifyRekorSETSignature(publicKeys, unverifiedRekorSET)
+	if err != nil {
+		return time.Time{}, err
 	}
 	// FIXME: Use a different decoder implementation? The Swagger-generated code is kinda ridiculous, with the need to re-marshal
 	// hashedRekor.Spec and so on.
@@ -179,25 +276,8 @@ func VerifyRekorSET(publicKey *ecdsa.PublicKey, unverifiedRekorSET []byte, unver
 		return time.Time{}, NewInvalidSignatureError(`Missing "signature.publicKey" field in hashedrekord`)
 
 	}
-	rekorKeyOrCertPEM, rest := pem.Decode(hashedRekordV001.Signature.PublicKey.Content)
-	if rekorKeyOrCertPEM == nil {
-		return time.Time{}, NewInvalidSignatureError("publicKey in Rekor SET is not in PEM format")
-	}
-	if len(rest) != 0 {
-		return time.Time{}, NewInvalidSignatureError("publicKey in Rekor SET has trailing data")
-	}
-	// FIXME: For public keys, let the caller provide the DER-formatted blob instead
-	// of round-tripping through PEM.
-	unverifiedKeyOrCertPEM, rest := pem.Decode(unverifiedKeyOrCertBytes)
-	if unverifiedKeyOrCertPEM == nil {
-		return time.Time{}, NewInvalidSignatureError("public key or cert to be matched against publicKey in Rekor SET is not in PEM format")
-	}
-	if len(rest) != 0 {
-		return time.Time{}, NewInvalidSignatureError("public key or cert to be matched against publicKey in Rekor SET has trailing data")
-	}
-	// NOTE: This compares the PEM payload, but not the object type or headers.
-	if !bytes.Equal(rekorKeyOrCertPEM.Bytes, unverifiedKeyOrCertPEM.Bytes) {
-		return time.Time{}, NewInvalidSignatureError("publicKey in Rekor SET does not match")
+	if err := matchRekorKeyOrCert(hashedRekordV001.Signature.PublicKey.Content, unverifiedKeyOrCertBytes); err != nil {
+		return time.Time{}, err
 	}
 	// == Match unverifiedSignatureBytes
 	unverifiedSignatureBytes, err := base64.StdEncoding.DecodeString(unverifiedBase64Signature)
@@ -240,5 +320,93 @@ func VerifyRekorSET(publicKey *ecdsa.PublicKey, unverifiedRekorSET []byte, unver
 	}
 
 	// == All OK; return the relevant time.
-	return time.Unix(rekorPayload.IntegratedTime, 0), nil
+	return rekorIntegratedTime(publicKey, rekorPayload)
+}
+
+// VerifyRekorSETForDSSE verifies that unverifiedRekorSET is correctly signed by one of publicKeys and records unverifiedEnvelope,
+// a DSSE envelope, signed by unverifiedKeyOrCertBytes, as a "dsse" Rekor entry.
+// Public keys are used as in VerifyRekorSET.
+// Returns bundle upload time on success.
+func VerifyRekorSETForDSSE(publicKeys []RekorPublicKey, unverifiedRekorSET []byte, unverifiedKeyOrCertBytes []byte, unverifiedEnvelope []byte) (time.Time, error) {
+	publicKey, rekorPayload, err := verifyRekorSETSignature(publicKeys, unverifiedRekorSET)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var dsse models.DSSE
+	if err := json.Unmarshal(rekorPayload.Body, &dsse); err != nil {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("decoding the body of a Rekor SET payload: %v", err))
+	}
+	// The decode of models.DSSE validates the "kind": "dsse" field, which is otherwise invisible to us.
+	if dsse.APIVersion == nil {
+		return time.Time{}, NewInvalidSignatureError("missing Rekor SET Payload API version")
+	}
+	if *dsse.APIVersion != DSSEV001APIVersion {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("unsupported Rekor SET Payload dsse version %#v", *dsse.APIVersion))
+	}
+	dsseV001Bytes, err := json.Marshal(dsse.Spec)
+	if err != nil {
+		// Coverage: dsse.Spec is an any that was just unmarshaled,
+		// so this should never fail.
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("re-creating dsse spec: %v", err))
+	}
+	var dsseV001 models.DSSEV001Schema
+	if err := json.Unmarshal(dsseV001Bytes, &dsseV001); err != nil {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("decoding dsse spec: %v", err))
+	}
+
+	untrustedEnvelope, err := parseUntrustedDSSEEnvelope(unverifiedEnvelope)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// == Match the envelope payload
+	// The envelope hash depends on the exact JSON representation of the envelope, which is not preserved in
+	// all formats; the payload hash and the signatures are sufficient to identify the signed data.
+	if dsseV001.PayloadHash == nil {
+		return time.Time{}, NewInvalidSignatureError(`Missing "payloadHash" field in dsse`)
+	}
+	if dsseV001.PayloadHash.Algorithm == nil {
+		return time.Time{}, NewInvalidSignatureError(`Missing "payloadHash.algorithm" field in dsse`)
+	}
+	if *dsseV001.PayloadHash.Algorithm != models.DSSEV001SchemaPayloadHashAlgorithmSha256 {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf(`Unexpected "payloadHash.algorithm" value %#v`, *dsseV001.PayloadHash.Algorithm))
+	}
+	if dsseV001.PayloadHash.Value == nil {
+		return time.Time{}, NewInvalidSignatureError(`Missing "payloadHash.value" field in dsse`)
+	}
+	rekorPayloadHash, err := hex.DecodeString(*dsseV001.PayloadHash.Value)
+	if err != nil {
+		return time.Time{}, NewInvalidSignatureError(fmt.Sprintf(`Invalid "payloadHash.value" field in dsse: %v`, err))
+	}
+	unverifiedPayloadHash := sha256.Sum256(untrustedEnvelope.untrustedPayload)
+	if !bytes.Equal(rekorPayloadHash, unverifiedPayloadHash[:]) {
+		return time.Time{}, NewInvalidSignatureError("payload in Rekor SET does not match")
+	}
+
+	// == Match a signature and unverifiedKeyOrCertBytes
+	if len(dsseV001.Signatures) == 0 {
+		return time.Time{}, NewInvalidSignatureError(`Missing "signatures" field in dsse`)
+	}
+	var lastErr error
+	for _, rekorSig := range dsseV001.Signatures {
+		if rekorSig == nil || rekorSig.Signature == nil || rekorSig.Verifier == nil {
+			return time.Time{}, NewInvalidSignatureError(`Invalid "signatures" entry in dsse`)
+		}
+		rekorSignatureBytes, err := base64.StdEncoding.DecodeString(*rekorSig.Signature)
+		if err != nil {
+			return time.Time{}, NewInvalidSignatureError(fmt.Sprintf("decoding signature base64: %v", err))
+		}
+		if err := matchRekorKeyOrCert(*rekorSig.Verifier, unverifiedKeyOrCertBytes); err != nil {
+			lastErr = err
+			continue
+		}
+		for _, unverifiedSignature := range untrustedEnvelope.untrustedSignatures {
+			if bytes.Equal(rekorSignatureBytes, unverifiedSignature) {
+				// == All OK; return the relevant time.
+				return rekorIntegratedTime(publicKey, rekorPayload)
+			}
+		}
+		lastErr = NewInvalidSignatureError("signature in Rekor SET does not match")
+	}
+	return time.Time{}, lastErr
 }
@@ -8,8 +8,19 @@ import (
 	"time"
 )
 
-// VerifyRekorSET verifies that unverifiedRekorSET is correctly signed by publicKey and matches the rest of the data.
+// RekorLogID returns the ID of a Rekor log (shard) using publicKey, as recorded in the "logID" field of Rekor SETs.
+func RekorLogID(publicKey *ecdsa.PublicKey) (string, error) {
+	return "", NewInvalidSignatureError("rekor disabled at compile-time")
+}
+
+// VerifyRekorSET verifies that unverifiedRekorSET is correctly signed by one of publicKeys and matches the rest of the data.
+// Returns bundle upload time on success.
+func VerifyRekorSET(publicKeys []RekorPublicKey, unverifiedRekorSET []byte, unverifiedKeyOrCertBytes []byte, unverifiedBase64Signature string, unverifiedPayloadBytes []byte) (time.Time, error) {
+	return time.Time{}, NewInvalidSignatureError("rekor disabled at compile-time")
+}
+
+// VerifyRekorSETForDSSE verifies that unverifiedRekorSET is correctly signed by one of publicKeys and records unverifiedEnvelope.
 // Returns bundle upload time on success.
-func VerifyRekorSET(publicKey *ecdsa.PublicKey, unverifiedRekorSET []byte, unverifiedKeyOrCertBytes []byte, unverifiedBase64Signature string, unverifiedPayloadBytes []byte) (time.Time, error) {
+func VerifyRekorSETForDSSE(publicKeys []RekorPublicKey, unverifiedRekorSET []byte, unverifiedKeyOrCertBytes []byte, unverifiedEnvelope []byte) (time.Time, error) {
 	return time.Time{}, NewInvalidSignatureError("rekor disabled at compile-time")
 }
@@ -18,6 +18,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/containers/image/v5/internal/signature"
 	"github.com/go-openapi/strfmt"
 	"github.com/sigstore/rekor/pkg/generated/models"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
@@ -178,6 +179,19 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+func TestRekorLogID(t *testing.T) {
+	cosignRekorKeyPEM, err := os.ReadFile("testdata/rekor.pub")
+	require.NoError(t, err)
+	cosignRekorKey, err := cryptoutils.UnmarshalPEMToPublicKey(cosignRekorKeyPEM)
+	require.NoError(t, err)
+	cosignRekorKeyECDSA, ok := cosignRekorKey.(*ecdsa.PublicKey)
+	require.True(t, ok)
+	logID, err := RekorLogID(cosignRekorKeyECDSA)
+	require.NoError(t, err)
+	// The log ID recorded in testdata/rekor-set
+	assert.Equal(t, "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d", logID)
+}
+
 func TestVerifyRekorSET(t *testing.T) {
 	cosignRekorKeyPEM, err := os.ReadFile("testdata/rekor.pub")
 	require.NoError(t, err)
@@ -195,18 +209,63 @@ func TestVerifyRekorSET(t *testing.T) {
 	require.NoError(t, err)
 
 	// Successful verification
-	tm, err := VerifyRekorSET(cosignRekorKeyECDSA, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	tm, err := VerifyRekorSET([]RekorPublicKey{{Key: cosignRekorKeyECDSA}}, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
 	require.NoError(t, err)
 	assert.Equal(t, time.Unix(1670870899, 0), tm)
 
+	// Successful verification, with the matching log shard among several keys
+	otherShardKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	for _, keys := range [][]RekorPublicKey{
+		{{Key: &otherShardKey.PublicKey}, {Key: cosignRekorKeyECDSA}},
+		{{Key: cosignRekorKeyECDSA}, {Key: &otherShardKey.PublicKey}},
+	} {
+		tm, err = VerifyRekorSET(keys, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+		require.NoError(t, err)
+		assert.Equal(t, time.Unix(1670870899, 0), tm)
+	}
+
+	// Successful verification, within the validity period of the key
+	for _, key := range []RekorPublicKey{
+		{Key: cosignRekorKeyECDSA, ValidFrom: time.Unix(1670870899, 0)},
+		{Key: cosignRekorKeyECDSA, ValidUntil: time.Unix(1670870899, 0)},
+		{Key: cosignRekorKeyECDSA, ValidFrom: time.Unix(1600000000, 0), ValidUntil: time.Unix(1700000000, 0)},
+	} {
+		tm, err = VerifyRekorSET([]RekorPublicKey{key}, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+		require.NoError(t, err)
+		assert.Equal(t, time.Unix(1670870899, 0), tm)
+	}
+
 	// For extra paranoia, test that we return a zero time on error.
 
+	// No public keys
+	tm, err = VerifyRekorSET(nil, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	assert.Error(t, err)
+	assert.Zero(t, tm)
+
+	// The integration time is outside of the validity period of the key
+	for _, key := range []RekorPublicKey{
+		{Key: cosignRekorKeyECDSA, ValidFrom: time.Unix(1670870900, 0)},
+		{Key: cosignRekorKeyECDSA, ValidUntil: time.Unix(1670870898, 0)},
+	} {
+		tm, err = VerifyRekorSET([]RekorPublicKey{key}, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+		assert.Error(t, err)
+		assert.Zero(t, tm)
+	}
+
+	// None of several log shards matches the log ID
+	anotherShardKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tm, err = VerifyRekorSET([]RekorPublicKey{{Key: &otherShardKey.PublicKey}, {Key: &anotherShardKey.PublicKey}}, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	assert.Error(t, err)
+	assert.Zero(t, tm)
+
 	// A completely invalid SET.
-	tm, err = VerifyRekorSET(cosignRekorKeyECDSA, []byte{}, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	tm, err = VerifyRekorSET([]RekorPublicKey{{Key: cosignRekorKeyECDSA}}, []byte{}, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
 	assert.Error(t, err)
 	assert.Zero(t, tm)
 
-	tm, err = VerifyRekorSET(cosignRekorKeyECDSA, []byte("invalid signature"), cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	tm, err = VerifyRekorSET([]RekorPublicKey{{Key: cosignRekorKeyECDSA}}, []byte("invalid signature"), cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
 	assert.Error(t, err)
 	assert.Zero(t, tm)
 
@@ -227,12 +286,12 @@ func TestVerifyRekorSET(t *testing.T) {
 		UntrustedPayload:              json.RawMessage(invalidPayload),
 	})
 	require.NoError(t, err)
-	tm, err = VerifyRekorSET(&testKey.PublicKey, invalidSET, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	tm, err = VerifyRekorSET([]RekorPublicKey{{Key: &testKey.PublicKey}}, invalidSET, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
 	assert.Error(t, err)
 	assert.Zero(t, tm)
 
 	// Cryptographic verification fails (a mismatched public key)
-	tm, err = VerifyRekorSET(&testKey.PublicKey, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	tm, err = VerifyRekorSET([]RekorPublicKey{{Key: &testKey.PublicKey}}, cosignSETBytes, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
 	assert.Error(t, err)
 	assert.Zero(t, tm)
 
@@ -245,7 +304,7 @@ func TestVerifyRekorSET(t *testing.T) {
 		UntrustedPayload:              json.RawMessage(invalidPayload),
 	})
 	require.NoError(t, err)
-	tm, err = VerifyRekorSET(&testKey.PublicKey, invalidSET, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+	tm, err = VerifyRekorSET([]RekorPublicKey{{Key: &testKey.PublicKey}}, invalidSET, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
 	assert.Error(t, err)
 	assert.Zero(t, tm)
 
@@ -379,7 +438,7 @@ func TestVerifyRekorSET(t *testing.T) {
 			UntrustedPayload:              json.RawMessage(testPayload),
 		})
 		require.NoError(t, err)
-		tm, err = VerifyRekorSET(&testKey.PublicKey, testSET, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
+		tm, err = VerifyRekorSET([]RekorPublicKey{{Key: &testKey.PublicKey}}, testSET, cosignCertBytes, string(cosignSigBase64), cosignPayloadBytes)
 		assert.Error(t, err)
 		assert.Zero(t, tm)
 	}
@@ -387,7 +446,7 @@ func TestVerifyRekorSET(t *testing.T) {
 	// Invalid unverifiedBase64Signature parameter
 	truncatedBase64 := cosignSigBase64
 	truncatedBase64 = truncatedBase64[:len(truncatedBase64)-1]
-	tm, err = VerifyRekorSET(cosignRekorKeyECDSA, cosignSETBytes, cosignCertBytes,
+	tm, err = VerifyRekorSET([]RekorPublicKey{{Key: cosignRekorKeyECDSA}}, cosignSETBytes, cosignCertBytes,
 		string(truncatedBase64), cosignPayloadBytes)
 	assert.Error(t, err)
 	assert.Zero(t, tm)
@@ -399,9 +458,182 @@ func TestVerifyRekorSET(t *testing.T) {
 		[]byte("this is not PEM"),
 		bytes.Repeat(cosignCertBytes, 2),
 	} {
-		tm, err = VerifyRekorSET(cosignRekorKeyECDSA, cosignSETBytes, c,
+		tm, err = VerifyRekorSET([]RekorPublicKey{{Key: cosignRekorKeyECDSA}}, cosignSETBytes, c,
 			string(cosignSigBase64), cosignPayloadBytes)
 		assert.Error(t, err)
 		assert.Zero(t, tm)
 	}
 }
+
+// dsseRekorSET returns a Rekor SET for a "dsse" entry with body, signed by rekorKey.
+func dsseRekorSET(t *testing.T, rekorKey *ecdsa.PrivateKey, body []byte, integratedTime int64) []byte {
+	logID, err := RekorLogID(&rekorKey.PublicKey)
+	require.NoError(t, err)
+	payload, err := json.Marshal(UntrustedRekorPayload{
+		Body:           body,
+		IntegratedTime: integratedTime,
+		LogIndex:       1,
+		LogID:          logID,
+	})
+	require.NoError(t, err)
+	signer, err := sigstoreSignature.LoadECDSASigner(rekorKey, crypto.SHA256)
+	require.NoError(t, err)
+	sig, err := signer.SignMessage(bytes.NewReader(payload))
+	require.NoError(t, err)
+	set, err := json.Marshal(UntrustedRekorSET{
+		UntrustedSignedEntryTimestamp: sig,
+		UntrustedPayload:              json.RawMessage(payload),
+	})
+	require.NoError(t, err)
+	return set
+}
+
+// dsseRekorBody returns the body of a "dsse" Rekor entry recording envelope, signed by a key with keyPEM.
+func dsseRekorBody(t *testing.T, envelope []byte, keyPEM []byte) []byte {
+	parsed, err := parseUntrustedDSSEEnvelope(envelope)
+	require.NoError(t, err)
+	payloadHash := sha256.Sum256(parsed.untrustedPayload)
+	envelopeHash := sha256.Sum256(envelope)
+	verifier := strfmt.Base64(keyPEM)
+	spec := models.DSSEV001Schema{
+		EnvelopeHash: &models.DSSEV001SchemaEnvelopeHash{
+			Algorithm: stringPtr(models.DSSEV001SchemaEnvelopeHashAlgorithmSha256),
+			Value:     stringPtr(hex.EncodeToString(envelopeHash[:])),
+		},
+		PayloadHash: &models.DSSEV001SchemaPayloadHash{
+			Algorithm: stringPtr(models.DSSEV001SchemaPayloadHashAlgorithmSha256),
+			Value:     stringPtr(hex.EncodeToString(payloadHash[:])),
+		},
+	}
+	for _, sig := range parsed.untrustedSignatures {
+		spec.Signatures = append(spec.Signatures, &models.DSSEV001SchemaSignaturesItems0{
+			Signature: stringPtr(base64.StdEncoding.EncodeToString(sig)),
+			Verifier:  &verifier,
+		})
+	}
+	body, err := json.Marshal(models.DSSE{
+		APIVersion: stringPtr(DSSEV001APIVersion),
+		Spec:       spec,
+	})
+	require.NoError(t, err)
+	return body
+}
+
+func TestVerifyRekorSETForDSSE(t *testing.T) {
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signingKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(&signingKey.PublicKey)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(&otherKey.PublicKey)
+	require.NoError(t, err)
+	envelope := dsseEnvelope(t, inTotoPayloadType, []byte(`{"_type":"https://in-toto.io/Statement/v1"}`), signingKey)
+	validBody := dsseRekorBody(t, envelope, signingKeyPEM)
+
+	// Successful verification
+	tm, err := VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &rekorKey.PublicKey}}, dsseRekorSET(t, rekorKey, validBody, 1700000000),
+		signingKeyPEM, envelope)
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000000, 0), tm)
+
+	// A SET converted from a Sigstore bundle
+	rekorLogID, err := RekorLogID(&rekorKey.PublicKey)
+	require.NoError(t, err)
+	rekorLogIDBytes, err := hex.DecodeString(rekorLogID)
+	require.NoError(t, err)
+	var setPayload UntrustedRekorPayload
+	var set UntrustedRekorSET
+	err = json.Unmarshal(dsseRekorSET(t, rekorKey, validBody, 1700000000), &set)
+	require.NoError(t, err)
+	err = json.Unmarshal(set.UntrustedPayload, &setPayload)
+	require.NoError(t, err)
+	bundleEnvelope, annotations, err := UntrustedSigstoreBundleComponents(sigstoreBundle(t, signature.SigstoreBundleMIMEType, envelope, mSA{
+		"publicKey": mSA{"hint": ""},
+		"tlogEntries": []any{mSA{
+			"logIndex":          "1",
+			"logId":             mSA{"keyId": base64.StdEncoding.EncodeToString(rekorLogIDBytes)},
+			"kindVersion":       mSA{"kind": "dsse", "version": "0.0.1"},
+			"integratedTime":    "1700000000",
+			"inclusionPromise":  mSA{"signedEntryTimestamp": base64.StdEncoding.EncodeToString(set.UntrustedSignedEntryTimestamp)},
+			"canonicalizedBody": base64.StdEncoding.EncodeToString(setPayload.Body),
+		}},
+	}))
+	require.NoError(t, err)
+	tm, err = VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &rekorKey.PublicKey}}, []byte(annotations[signature.SigstoreSETAnnotationKey]),
+		signingKeyPEM, bundleEnvelope)
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000000, 0), tm)
+
+	// The envelope hash is not used, so a different representation of the envelope is accepted
+	var reformatted bytes.Buffer
+	err = json.Indent(&reformatted, envelope, "", "  ")
+	require.NoError(t, err)
+	tm, err = VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &rekorKey.PublicKey}}, dsseRekorSET(t, rekorKey, validBody, 1700000000),
+		signingKeyPEM, reformatted.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000000, 0), tm)
+
+	// SET verification failures
+	tm, err = VerifyRekorSETForDSSE(nil, dsseRekorSET(t, rekorKey, validBody, 1700000000), signingKeyPEM, envelope)
+	assert.Error(t, err)
+	assert.Zero(t, tm)
+	tm, err = VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &otherKey.PublicKey}}, dsseRekorSET(t, rekorKey, validBody, 1700000000),
+		signingKeyPEM, envelope)
+	assert.Error(t, err)
+	assert.Zero(t, tm)
+	tm, err = VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &rekorKey.PublicKey, ValidUntil: time.Unix(1600000000, 0)}},
+		dsseRekorSET(t, rekorKey, validBody, 1700000000), signingKeyPEM, envelope)
+	assert.Error(t, err)
+	assert.Zero(t, tm)
+
+	// A key or certificate which does not match the entry
+	tm, err = VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &rekorKey.PublicKey}}, dsseRekorSET(t, rekorKey, validBody, 1700000000),
+		otherKeyPEM, envelope)
+	assert.Error(t, err)
+	assert.Zero(t, tm)
+
+	// An envelope which does not match the entry
+	for _, e := range [][]byte{
+		[]byte("not an envelope"),
+		dsseEnvelope(t, inTotoPayloadType, []byte(`{"_type":"https://in-toto.io/Statement/v1"}`), otherKey),
+		dsseEnvelope(t, inTotoPayloadType, []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`), signingKey),
+	} {
+		tm, err = VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &rekorKey.PublicKey}}, dsseRekorSET(t, rekorKey, validBody, 1700000000),
+			signingKeyPEM, e)
+		assert.Error(t, err)
+		assert.Zero(t, tm)
+	}
+
+	// A correctly signed entry is invalid
+	for _, fn := range []func(mSA){
+		func(v mSA) { delete(v, "apiVersion") },
+		func(v mSA) { v["apiVersion"] = "99.0.99" },
+		func(v mSA) { v["kind"] = "hashedrekord" },
+		func(v mSA) { delete(v, "spec") },
+		func(v mSA) { delete(x(v, "spec"), "payloadHash") },
+		func(v mSA) { delete(x(v, "spec", "payloadHash"), "algorithm") },
+		func(v mSA) { x(v, "spec", "payloadHash")["algorithm"] = "sha384" },
+		func(v mSA) { delete(x(v, "spec", "payloadHash"), "value") },
+		func(v mSA) { x(v, "spec", "payloadHash")["value"] = "x" },
+		func(v mSA) {
+			x(v, "spec", "payloadHash")["value"] = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		},
+		func(v mSA) { x(v, "spec")["signatures"] = []any{} },
+		func(v mSA) {
+			x(v, "spec")["signatures"] = []any{mSA{"signature": base64.StdEncoding.EncodeToString([]byte("does not match")),
+				"verifier": base64.StdEncoding.EncodeToString(signingKeyPEM)}}
+		},
+		func(v mSA) {
+			x(v, "spec")["signatures"] = []any{mSA{"signature": "+", "verifier": base64.StdEncoding.EncodeToString(signingKeyPEM)}}
+		},
+	} {
+		body := modifiedJSON(t, validBody, fn)
+		tm, err = VerifyRekorSETForDSSE([]RekorPublicKey{{Key: &rekorKey.PublicKey}}, dsseRekorSET(t, rekorKey, body, 1700000000),
+			signingKeyPEM, envelope)
+		assert.Error(t, err, string(body))
+		assert.Zero(t, tm)
+	}
+}
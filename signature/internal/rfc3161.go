@@ -0,0 +1,435 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	oidSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidRSASSAPSS              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	// oidAttributeSigningCertificate(V2) are the ESS signing certificate attributes of RFC 2634 and RFC 5035.
+	oidAttributeSigningCertificate   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 12}
+	oidAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+
+	// rfc3161HashAlgorithms are the hash algorithms we accept in timestamps.
+	rfc3161HashAlgorithms = map[string]crypto.Hash{
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}.String(): crypto.SHA256,
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}.String(): crypto.SHA384,
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}.String(): crypto.SHA512,
+	}
+)
+
+// UntrustedRFC3161Timestamp is a parsed content of the sigstore-signature RFC 3161 timestamp annotation.
+// This corresponds to github.com/sigstore/cosign/bundle.RFC3161Timestamp, but we impose a stricter decoder.
+type UntrustedRFC3161Timestamp struct {
+	UntrustedSignedRFC3161Timestamp []byte // A DER-encoded RFC 3161 TimeStampToken
+}
+
+// A compile-time check that UntrustedRFC3161Timestamp implements json.Unmarshaler
+var _ json.Unmarshaler = (*UntrustedRFC3161Timestamp)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (t *UntrustedRFC3161Timestamp) UnmarshalJSON(data []byte) error {
+	err := ParanoidUnmarshalJSONObjectExactFields(data, map[string]any{
+		"SignedRFC3161Timestamp": &t.UntrustedSignedRFC3161Timestamp,
+	})
+	if err != nil {
+		if formatErr, ok := err.(JSONFormatError); ok {
+			err = NewInvalidSignatureError(formatErr.Error())
+		}
+	}
+	return err
+}
+
+// A compile-time check that UntrustedRFC3161Timestamp and *UntrustedRFC3161Timestamp implements json.Marshaler
+var _ json.Marshaler = UntrustedRFC3161Timestamp{}
+var _ json.Marshaler = (*UntrustedRFC3161Timestamp)(nil)
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t UntrustedRFC3161Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"SignedRFC3161Timestamp": t.UntrustedSignedRFC3161Timestamp,
+	})
+}
+
+// The ASN.1 structures of RFC 5652 (CMS) and RFC 3161, restricted to what we use.
+
+type rfc3161ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type rfc3161SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+		EContent     []byte `asn1:"explicit,tag:0"`
+	}
+	Certificates asn1.RawValue       `asn1:"optional,tag:0"`
+	CRLs         asn1.RawValue       `asn1:"optional,tag:1"`
+	SignerInfos  []rfc3161SignerInfo `asn1:"set"`
+}
+
+type rfc3161SignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type rfc3161IssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type rfc3161Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// rfc3161IssuerSerial is an IssuerSerial of RFC 5035.
+type rfc3161IssuerSerial struct {
+	Issuer       []asn1.RawValue // GeneralNames
+	SerialNumber *big.Int
+}
+
+// rfc3161SigningCertificate is a SigningCertificate of RFC 2634; the optional policies are not used, and not parsed.
+type rfc3161SigningCertificate struct {
+	Certs []struct {
+		CertHash     []byte
+		IssuerSerial asn1.RawValue `asn1:"optional"`
+	}
+}
+
+// rfc3161SigningCertificateV2 is a SigningCertificateV2 of RFC 5035; the optional policies are not used, and not parsed.
+type rfc3161SigningCertificateV2 struct {
+	Certs []struct {
+		HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"` // DEFAULT id-sha256
+		CertHash      []byte
+		IssuerSerial  asn1.RawValue `asn1:"optional"`
+	}
+}
+
+// rfc3161TSTInfo is a TSTInfo; the optional fields following GenTime are not used, and not parsed.
+type rfc3161TSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		HashedMessage []byte
+	}
+	SerialNumber *big.Int
+	GenTime      time.Time `asn1:"generalized"`
+}
+
+// rfc3161Hash returns the hash algorithm identified by algorithm, if it is accepted.
+func rfc3161Hash(algorithm pkix.AlgorithmIdentifier) (crypto.Hash, error) {
+	hash, ok := rfc3161HashAlgorithms[algorithm.Algorithm.String()]
+	if !ok {
+		return 0, NewInvalidSignatureError(fmt.Sprintf("unsupported hash algorithm %s in RFC 3161 timestamp", algorithm.Algorithm.String()))
+	}
+	return hash, nil
+}
+
+// rfc3161HasAttribute returns true if attrs contain an attribute attrType.
+func rfc3161HasAttribute(attrs []rfc3161Attribute, attrType asn1.ObjectIdentifier) bool {
+	for _, attr := range attrs {
+		if attr.Type.Equal(attrType) {
+			return true
+		}
+	}
+	return false
+}
+
+// rfc3161SingleAttributeValue returns the single value of attribute attrType in attrs.
+func rfc3161SingleAttributeValue(attrs []rfc3161Attribute, attrType asn1.ObjectIdentifier) (asn1.RawValue, error) {
+	var res *asn1.RawValue
+	for _, attr := range attrs {
+		if attr.Type.Equal(attrType) {
+			if res != nil || len(attr.Values) != 1 {
+				return asn1.RawValue{}, NewInvalidSignatureError(fmt.Sprintf("RFC 3161 timestamp attribute %s does not have a single value", attrType.String()))
+			}
+			res = &attr.Values[0]
+		}
+	}
+	if res == nil {
+		return asn1.RawValue{}, NewInvalidSignatureError(fmt.Sprintf("RFC 3161 timestamp attribute %s is missing", attrType.String()))
+	}
+	return *res, nil
+}
+
+// rfc3161SignerCertificate returns the certificate matching sid among candidates.
+func rfc3161SignerCertificate(sid asn1.RawValue, candidates []*x509.Certificate) (*x509.Certificate, error) {
+	switch {
+	case sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence:
+		var ias rfc3161IssuerAndSerialNumber
+		if rest, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil || len(rest) != 0 {
+			return nil, NewInvalidSignatureError("invalid signer identifier in RFC 3161 timestamp")
+		}
+		for _, cert := range candidates {
+			if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+				return cert, nil
+			}
+		}
+	case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0: // subjectKeyIdentifier
+		for _, cert := range candidates {
+			if len(cert.SubjectKeyId) != 0 && bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+	default:
+		return nil, NewInvalidSignatureError("invalid signer identifier in RFC 3161 timestamp")
+	}
+	return nil, NewInvalidSignatureError("the certificate of the RFC 3161 timestamp signer was not found")
+}
+
+// verifyRFC3161SigningCertificate verifies that the ESS signing certificate attribute in attrs identifies cert,
+// as required by RFC 3161 (and RFC 5816 for the V2 variant).
+func verifyRFC3161SigningCertificate(attrs []rfc3161Attribute, cert *x509.Certificate) error {
+	var hash crypto.Hash
+	var certHash []byte
+	var issuerSerial asn1.RawValue
+	if rfc3161HasAttribute(attrs, oidAttributeSigningCertificateV2) {
+		value, err := rfc3161SingleAttributeValue(attrs, oidAttributeSigningCertificateV2)
+		if err != nil {
+			return err
+		}
+		var sc rfc3161SigningCertificateV2
+		if _, err := asn1.Unmarshal(value.FullBytes, &sc); err != nil || len(sc.Certs) == 0 {
+			return NewInvalidSignatureError("invalid RFC 3161 timestamp signing certificate attribute")
+		}
+		// The first entry identifies the signer; the other ones are not relevant for us.
+		hash = crypto.SHA256
+		if sc.Certs[0].HashAlgorithm.Algorithm != nil {
+			h, err := rfc3161Hash(sc.Certs[0].HashAlgorithm)
+			if err != nil {
+				return err
+			}
+			hash = h
+		}
+		certHash, issuerSerial = sc.Certs[0].CertHash, sc.Certs[0].IssuerSerial
+	} else {
+		value, err := rfc3161SingleAttributeValue(attrs, oidAttributeSigningCertificate)
+		if err != nil {
+			return err
+		}
+		var sc rfc3161SigningCertificate
+		if _, err := asn1.Unmarshal(value.FullBytes, &sc); err != nil || len(sc.Certs) == 0 {
+			return NewInvalidSignatureError("invalid RFC 3161 timestamp signing certificate attribute")
+		}
+		hash = crypto.SHA1
+		certHash, issuerSerial = sc.Certs[0].CertHash, sc.Certs[0].IssuerSerial
+	}
+
+	var expectedHash []byte
+	if hash == crypto.SHA1 {
+		h := sha1.Sum(cert.Raw)
+		expectedHash = h[:]
+	} else {
+		h := hash.New()
+		h.Write(cert.Raw)
+		expectedHash = h.Sum(nil)
+	}
+	if !bytes.Equal(certHash, expectedHash) {
+		return NewInvalidSignatureError("RFC 3161 timestamp signing certificate attribute does not match the signer certificate")
+	}
+	if len(issuerSerial.FullBytes) != 0 {
+		var is rfc3161IssuerSerial
+		if rest, err := asn1.Unmarshal(issuerSerial.FullBytes, &is); err != nil || len(rest) != 0 {
+			return NewInvalidSignatureError("invalid issuer and serial number in RFC 3161 timestamp signing certificate attribute")
+		}
+		issuerMatches := false
+		for _, name := range is.Issuer {
+			// directoryName [4] Name; the tag is EXPLICIT because Name is a CHOICE.
+			if name.Class == asn1.ClassContextSpecific && name.Tag == 4 && bytes.Equal(name.Bytes, cert.RawIssuer) {
+				issuerMatches = true
+			}
+		}
+		if !issuerMatches || is.SerialNumber == nil || is.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			return NewInvalidSignatureError("RFC 3161 timestamp signing certificate attribute does not match the signer certificate")
+		}
+	}
+	return nil
+}
+
+// verifyRFC3161Signature verifies that signedAttrs (in DER) were signed by cert according to signer.
+func verifyRFC3161Signature(cert *x509.Certificate, signer *rfc3161SignerInfo, hash crypto.Hash, signedAttrs []byte) error {
+	h := hash.New()
+	h.Write(signedAttrs)
+	digest := h.Sum(nil)
+	valid := false
+	switch pk := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(pk, digest, signer.Signature)
+	case *rsa.PublicKey:
+		if signer.SignatureAlgorithm.Algorithm.Equal(oidRSASSAPSS) {
+			valid = rsa.VerifyPSS(pk, hash, digest, signer.Signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+		} else {
+			valid = rsa.VerifyPKCS1v15(pk, hash, digest, signer.Signature) == nil
+		}
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(pk, signedAttrs, signer.Signature)
+	default:
+		return NewInvalidSignatureError(fmt.Sprintf("unsupported public key type %T of the RFC 3161 timestamp signer", cert.PublicKey))
+	}
+	if !valid {
+		return NewInvalidSignatureError("cryptographic signature verification of RFC 3161 timestamp failed")
+	}
+	return nil
+}
+
+// VerifyRFC3161Timestamp verifies that unverifiedTimestamp, an UntrustedRFC3161Timestamp, was issued by a timestamp authority
+// with one of trustedCertificates (or a certificate issued by them) for one of unverifiedTimestampedData,
+// and returns the time recorded in the timestamp, and the index of the element of unverifiedTimestampedData it refers to.
+func VerifyRFC3161Timestamp(trustedCertificates []*x509.Certificate, unverifiedTimestamp []byte, unverifiedTimestampedData [][]byte) (time.Time, int, error) {
+	if len(trustedCertificates) == 0 {
+		return time.Time{}, -1, NewInvalidSignatureError("no timestamp authority certificates provided")
+	}
+	var untrustedTimestamp UntrustedRFC3161Timestamp
+	if err := json.Unmarshal(unverifiedTimestamp, &untrustedTimestamp); err != nil {
+		return time.Time{}, -1, NewInvalidSignatureError(err.Error())
+	}
+
+	// == Parse the CMS structure
+	var contentInfo rfc3161ContentInfo
+	if rest, err := asn1.Unmarshal(untrustedTimestamp.UntrustedSignedRFC3161Timestamp, &contentInfo); err != nil {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("parsing RFC 3161 timestamp: %v", err))
+	} else if len(rest) != 0 {
+		return time.Time{}, -1, NewInvalidSignatureError("RFC 3161 timestamp has trailing data")
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("unexpected RFC 3161 timestamp content type %s", contentInfo.ContentType.String()))
+	}
+	var signedData rfc3161SignedData
+	if rest, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("parsing RFC 3161 timestamp signed data: %v", err))
+	} else if len(rest) != 0 {
+		return time.Time{}, -1, NewInvalidSignatureError("RFC 3161 timestamp signed data has trailing data")
+	}
+	if !signedData.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("unexpected RFC 3161 timestamp signed content type %s",
+			signedData.EncapContentInfo.EContentType.String()))
+	}
+	if len(signedData.SignerInfos) != 1 {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("RFC 3161 timestamp has %d signers, expected 1", len(signedData.SignerInfos)))
+	}
+	signer := &signedData.SignerInfos[0]
+	var embeddedCertificates []*x509.Certificate
+	if len(signedData.Certificates.Bytes) != 0 {
+		certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+		if err != nil {
+			return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("parsing RFC 3161 timestamp certificates: %v", err))
+		}
+		embeddedCertificates = certs
+	}
+
+	// == Verify the signature
+	hash, err := rfc3161Hash(signer.DigestAlgorithm)
+	if err != nil {
+		return time.Time{}, -1, err
+	}
+	// RFC 3161 requires signed attributes, which include the ESSCertID of the signer.
+	if len(signer.SignedAttrs.FullBytes) == 0 {
+		return time.Time{}, -1, NewInvalidSignatureError("RFC 3161 timestamp does not contain signed attributes")
+	}
+	// The signature covers the DER encoding of the attributes as a SET OF, not using the IMPLICIT [0] tag of SignedAttrs.
+	signedAttrs := bytes.Clone(signer.SignedAttrs.FullBytes)
+	signedAttrs[0] = 0x31 // SET, constructed
+	var attrs []rfc3161Attribute
+	if rest, err := asn1.UnmarshalWithParams(signedAttrs, &attrs, "set"); err != nil {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("parsing RFC 3161 timestamp signed attributes: %v", err))
+	} else if len(rest) != 0 {
+		return time.Time{}, -1, NewInvalidSignatureError("RFC 3161 timestamp signed attributes have trailing data")
+	}
+	signerCert, err := rfc3161SignerCertificate(signer.SID, append(embeddedCertificates, trustedCertificates...))
+	if err != nil {
+		return time.Time{}, -1, err
+	}
+	if err := verifyRFC3161Signature(signerCert, signer, hash, signedAttrs); err != nil {
+		return time.Time{}, -1, err
+	}
+	if err := verifyRFC3161SigningCertificate(attrs, signerCert); err != nil {
+		return time.Time{}, -1, err
+	}
+
+	// == Verify that the signed attributes match the signed content
+	contentTypeValue, err := rfc3161SingleAttributeValue(attrs, oidAttributeContentType)
+	if err != nil {
+		return time.Time{}, -1, err
+	}
+	var contentType asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(contentTypeValue.FullBytes, &contentType); err != nil || !contentType.Equal(oidTSTInfo) {
+		return time.Time{}, -1, NewInvalidSignatureError("RFC 3161 timestamp content type attribute does not match")
+	}
+	messageDigestValue, err := rfc3161SingleAttributeValue(attrs, oidAttributeMessageDigest)
+	if err != nil {
+		return time.Time{}, -1, err
+	}
+	var messageDigest []byte
+	if _, err := asn1.Unmarshal(messageDigestValue.FullBytes, &messageDigest); err != nil {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("parsing RFC 3161 timestamp message digest: %v", err))
+	}
+	h := hash.New()
+	h.Write(signedData.EncapContentInfo.EContent)
+	if !bytes.Equal(messageDigest, h.Sum(nil)) {
+		return time.Time{}, -1, NewInvalidSignatureError("RFC 3161 timestamp message digest does not match")
+	}
+
+	// == Parse the signed TSTInfo
+	var tstInfo rfc3161TSTInfo
+	if _, err := asn1.Unmarshal(signedData.EncapContentInfo.EContent, &tstInfo); err != nil {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("parsing RFC 3161 TSTInfo: %v", err))
+	}
+
+	// == Verify the certificate of the signer
+	roots := x509.NewCertPool()
+	for _, cert := range trustedCertificates {
+		roots.AddCert(cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range embeddedCertificates {
+		intermediates.AddCert(cert)
+	}
+	if _, err := signerCert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   tstInfo.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}); err != nil {
+		return time.Time{}, -1, NewInvalidSignatureError(fmt.Sprintf("verifying the certificate of the RFC 3161 timestamp signer: %v", err))
+	}
+
+	// == Match unverifiedTimestampedData
+	imprintHash, err := rfc3161Hash(tstInfo.MessageImprint.HashAlgorithm)
+	if err != nil {
+		return time.Time{}, -1, err
+	}
+	for i, data := range unverifiedTimestampedData {
+		h := imprintHash.New()
+		h.Write(data)
+		if bytes.Equal(tstInfo.MessageImprint.HashedMessage, h.Sum(nil)) {
+			// == All OK; return the relevant time.
+			return tstInfo.GenTime, i, nil
+		}
+	}
+	return time.Time{}, -1, NewInvalidSignatureError("RFC 3161 timestamp does not match the signature")
+}
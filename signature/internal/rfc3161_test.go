@@ -0,0 +1,376 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	_ "crypto/sha512" // For crypto.SHA384
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+)
+
+// testTSA is a timestamp authority for tests.
+type testTSA struct {
+	root    *x509.Certificate
+	leaf    *x509.Certificate
+	leafKey *ecdsa.PrivateKey
+}
+
+// newTestCertificate returns a certificate created from template, signed by parent/parentKey (or self-signed if parent is nil), and its private key.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// newTestTSA returns a new testTSA, with a leaf certificate with extKeyUsage, issued by a root CA.
+func newTestTSA(t *testing.T, extKeyUsage []x509.ExtKeyUsage) *testTSA {
+	notBefore := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+	root, rootKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test TSA root"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	leaf, leafKey := newTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  extKeyUsage,
+	}, root, rootKey)
+	return &testTSA{root: root, leaf: leaf, leafKey: leafKey}
+}
+
+// testRFC3161Token describes a TimeStampToken to be created by testTSA.rfc3161Timestamp.
+type testRFC3161Token struct {
+	eContentType         asn1.ObjectIdentifier
+	imprintAlgorithm     asn1.ObjectIdentifier
+	imprintData          []byte
+	genTime              time.Time
+	signer               crypto.Signer
+	certificates         []*x509.Certificate
+	omitSignedAttrs      bool
+	corruptMessageDigest bool
+	essCertIDVersion     int               // 0: no signing certificate attribute, 1: SigningCertificate, 2: SigningCertificateV2
+	essCertIDHash        crypto.Hash       // For essCertIDVersion == 2; 0 to use the default SHA-256 without specifying it
+	essCertIDCert        *x509.Certificate // The certificate identified in the signing certificate attribute
+	essCertIDSerial      *big.Int          // If not nil, an IssuerSerial with essCertIDCert’s issuer and this serial number is included
+}
+
+// defaultToken returns a testRFC3161Token for data, at genTime.
+func (tsa *testTSA) defaultToken(data []byte, genTime time.Time) *testRFC3161Token {
+	return &testRFC3161Token{
+		eContentType:     oidTSTInfo,
+		imprintAlgorithm: oidSHA256,
+		imprintData:      data,
+		genTime:          genTime,
+		signer:           tsa.leafKey,
+		certificates:     []*x509.Certificate{tsa.leaf},
+		essCertIDVersion: 2,
+		essCertIDCert:    tsa.leaf,
+		essCertIDSerial:  tsa.leaf.SerialNumber,
+	}
+}
+
+// signingCertificateAttribute returns the ESS signing certificate attribute described by token, if any.
+func (token *testRFC3161Token) signingCertificateAttribute(t *testing.T) []rfc3161Attribute {
+	issuerSerial := asn1.RawValue{}
+	if token.essCertIDSerial != nil {
+		der, err := asn1.Marshal(rfc3161IssuerSerial{
+			Issuer:       []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: token.essCertIDCert.RawIssuer}},
+			SerialNumber: token.essCertIDSerial,
+		})
+		require.NoError(t, err)
+		issuerSerial.FullBytes = der
+	}
+	var attrType asn1.ObjectIdentifier
+	var value []byte
+	var err error
+	switch token.essCertIDVersion {
+	case 0:
+		return nil
+	case 1:
+		certHash := sha1.Sum(token.essCertIDCert.Raw)
+		attrType = oidAttributeSigningCertificate
+		value, err = asn1.Marshal(struct {
+			Certs []struct {
+				CertHash     []byte
+				IssuerSerial asn1.RawValue `asn1:"optional"`
+			}
+		}{Certs: []struct {
+			CertHash     []byte
+			IssuerSerial asn1.RawValue `asn1:"optional"`
+		}{{CertHash: certHash[:], IssuerSerial: issuerSerial}}})
+	case 2:
+		hash, algorithm := crypto.SHA256, pkix.AlgorithmIdentifier{}
+		if token.essCertIDHash == crypto.SHA384 {
+			hash, algorithm = crypto.SHA384, pkix.AlgorithmIdentifier{Algorithm: oidSHA384}
+		}
+		h := hash.New()
+		h.Write(token.essCertIDCert.Raw)
+		attrType = oidAttributeSigningCertificateV2
+		value, err = asn1.Marshal(struct {
+			Certs []struct {
+				HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+				CertHash      []byte
+				IssuerSerial  asn1.RawValue `asn1:"optional"`
+			}
+		}{Certs: []struct {
+			HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+			CertHash      []byte
+			IssuerSerial  asn1.RawValue `asn1:"optional"`
+		}{{HashAlgorithm: algorithm, CertHash: h.Sum(nil), IssuerSerial: issuerSerial}}})
+	}
+	require.NoError(t, err)
+	return []rfc3161Attribute{{Type: attrType, Values: []asn1.RawValue{{FullBytes: value}}}}
+}
+
+// rfc3161Timestamp returns a DER-encoded TimeStampToken described by token.
+func (tsa *testTSA) rfc3161Timestamp(t *testing.T, token *testRFC3161Token) []byte {
+	imprint := sha256.Sum256(token.imprintData)
+	tstInfo, err := asn1.Marshal(struct {
+		Version        int
+		Policy         asn1.ObjectIdentifier
+		MessageImprint struct {
+			HashAlgorithm pkix.AlgorithmIdentifier
+			HashedMessage []byte
+		}
+		SerialNumber *big.Int
+		GenTime      time.Time `asn1:"generalized"`
+		Ordering     bool
+		Nonce        *big.Int
+	}{
+		Version: 1,
+		Policy:  asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: struct {
+			HashAlgorithm pkix.AlgorithmIdentifier
+			HashedMessage []byte
+		}{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: token.imprintAlgorithm},
+			HashedMessage: imprint[:],
+		},
+		SerialNumber: big.NewInt(42),
+		GenTime:      token.genTime.UTC(),
+		Ordering:     false,
+		Nonce:        big.NewInt(1234),
+	})
+	require.NoError(t, err)
+
+	contentTypeAttr, err := asn1.Marshal(token.eContentType)
+	require.NoError(t, err)
+	tstInfoDigest := sha256.Sum256(tstInfo)
+	if token.corruptMessageDigest {
+		tstInfoDigest[0] ^= 0xff
+	}
+	messageDigestAttr, err := asn1.Marshal(tstInfoDigest[:])
+	require.NoError(t, err)
+	signedAttrs, err := asn1.MarshalWithParams(append([]rfc3161Attribute{
+		{Type: oidAttributeContentType, Values: []asn1.RawValue{{FullBytes: contentTypeAttr}}},
+		{Type: oidAttributeMessageDigest, Values: []asn1.RawValue{{FullBytes: messageDigestAttr}}},
+	}, token.signingCertificateAttribute(t)...), "set")
+	require.NoError(t, err)
+	signedAttrsDigest := sha256.Sum256(signedAttrs)
+	signature, err := token.signer.Sign(rand.Reader, signedAttrsDigest[:], crypto.SHA256)
+	require.NoError(t, err)
+	taggedSignedAttrs := asn1.RawValue{}
+	if !token.omitSignedAttrs {
+		taggedSignedAttrs.FullBytes = append([]byte{0xa0}, signedAttrs[1:]...) // IMPLICIT [0] instead of SET
+	}
+
+	sid, err := asn1.Marshal(rfc3161IssuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: tsa.leaf.RawIssuer},
+		SerialNumber: tsa.leaf.SerialNumber,
+	})
+	require.NoError(t, err)
+	certificates := asn1.RawValue{}
+	if len(token.certificates) != 0 {
+		certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true}
+		for _, cert := range token.certificates {
+			certificates.Bytes = append(certificates.Bytes, cert.Raw...)
+		}
+	}
+	signedData := rfc3161SignedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		Certificates:     certificates,
+		SignerInfos: []rfc3161SignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        taggedSignedAttrs,
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}, // ecdsa-with-SHA256
+			Signature:          signature,
+		}},
+	}
+	signedData.EncapContentInfo.EContentType = token.eContentType
+	signedData.EncapContentInfo.EContent = tstInfo
+	signedDataBytes, err := asn1.Marshal(signedData)
+	require.NoError(t, err)
+	res, err := asn1.Marshal(rfc3161ContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedDataBytes},
+	})
+	require.NoError(t, err)
+	return res
+}
+
+// rfc3161TimestampAnnotation returns an UntrustedRFC3161Timestamp containing timestamp, as JSON.
+func rfc3161TimestampAnnotation(t *testing.T, timestamp []byte) []byte {
+	res, err := json.Marshal(UntrustedRFC3161Timestamp{UntrustedSignedRFC3161Timestamp: timestamp})
+	require.NoError(t, err)
+	return res
+}
+
+func TestUntrustedRFC3161TimestampMarshalJSON(t *testing.T) {
+	// Success
+	// Note that the encoding is _not_ exactly what we generate, the input is base64-encoded.
+	var ts UntrustedRFC3161Timestamp
+	err := json.Unmarshal([]byte(`{"SignedRFC3161Timestamp":"dGltZXN0YW1w"}`), &ts)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("timestamp"), ts.UntrustedSignedRFC3161Timestamp)
+
+	data, err := json.Marshal(ts)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"SignedRFC3161Timestamp":"dGltZXN0YW1w"}`, string(data))
+
+	// Various ways to corrupt the JSON
+	for _, input := range []string{
+		"this is invalid",
+		"1",
+		"{}",
+		`{"SignedRFC3161Timestamp":"dGltZXN0YW1w","extra":1}`,
+		`{"SignedRFC3161Timestamp":1}`,
+		`{"SignedRFC3161Timestamp":"this is not base64"}`,
+	} {
+		var ts UntrustedRFC3161Timestamp
+		err := json.Unmarshal([]byte(input), &ts)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestVerifyRFC3161Timestamp(t *testing.T) {
+	tsa := newTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	data := []byte("timestamped data")
+	genTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	trusted := []*x509.Certificate{tsa.root}
+
+	// Success
+	for _, c := range []struct {
+		name    string
+		trusted []*x509.Certificate
+		modify  func(token *testRFC3161Token)
+		data    [][]byte
+		index   int
+	}{
+		{name: "single data", trusted: trusted, data: [][]byte{data}},
+		{name: "one of several data", trusted: trusted, data: [][]byte{[]byte("other"), data}, index: 1},
+		{name: "trusted leaf, not embedded", trusted: []*x509.Certificate{tsa.leaf},
+			modify: func(token *testRFC3161Token) { token.certificates = nil }, data: [][]byte{data}},
+		{name: "SigningCertificate", trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.essCertIDVersion = 1 }, data: [][]byte{data}},
+		{name: "SigningCertificateV2 with an explicit hash algorithm", trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.essCertIDHash = crypto.SHA384 }, data: [][]byte{data}},
+		{name: "SigningCertificateV2 without IssuerSerial", trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.essCertIDSerial = nil }, data: [][]byte{data}},
+	} {
+		token := tsa.defaultToken(data, genTime)
+		if c.modify != nil {
+			c.modify(token)
+		}
+		res, index, err := VerifyRFC3161Timestamp(c.trusted, rfc3161TimestampAnnotation(t, tsa.rfc3161Timestamp(t, token)), c.data)
+		require.NoError(t, err, c.name)
+		assert.True(t, genTime.Equal(res), c.name)
+		assert.Equal(t, c.index, index, c.name)
+	}
+
+	// Invalid timestamp annotations
+	for _, input := range [][]byte{
+		[]byte("this is invalid"),
+		[]byte(`{"SignedRFC3161Timestamp":1}`),
+		rfc3161TimestampAnnotation(t, []byte("not ASN.1")),
+		rfc3161TimestampAnnotation(t, append(tsa.rfc3161Timestamp(t, tsa.defaultToken(data, genTime)), 0)),
+	} {
+		_, _, err := VerifyRFC3161Timestamp(trusted, input, [][]byte{data})
+		assert.Error(t, err, string(input))
+		assert.IsType(t, InvalidSignatureError{}, err, string(input))
+	}
+
+	// Verification failures
+	otherTSA := newTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	noEKUTSA := newTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning})
+	for _, c := range []struct {
+		name    string
+		tsa     *testTSA
+		trusted []*x509.Certificate
+		modify  func(token *testRFC3161Token)
+		data    [][]byte
+	}{
+		{name: "no trusted certificates", tsa: tsa, trusted: nil, data: [][]byte{data}},
+		{name: "untrusted TSA", tsa: otherTSA, trusted: trusted, data: [][]byte{data}},
+		{name: "signer certificate not found", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.certificates = nil }, data: [][]byte{data}},
+		{name: "TSA certificate without the timestamping EKU", tsa: noEKUTSA, trusted: []*x509.Certificate{noEKUTSA.root}, data: [][]byte{data}},
+		{name: "timestamp outside of the certificate validity", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.genTime = time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC) }, data: [][]byte{data}},
+		{name: "signed by a different key", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.signer = otherTSA.leafKey }, data: [][]byte{data}},
+		{name: "no signed attributes", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.omitSignedAttrs = true }, data: [][]byte{data}},
+		{name: "no signing certificate attribute", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.essCertIDVersion = 0 }, data: [][]byte{data}},
+		{name: "SigningCertificate of a different certificate", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) {
+				token.essCertIDVersion = 1
+				token.essCertIDCert = tsa.root
+			}, data: [][]byte{data}},
+		{name: "SigningCertificateV2 of a different certificate", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.essCertIDCert = tsa.root }, data: [][]byte{data}},
+		{name: "SigningCertificateV2 with a different serial number", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.essCertIDSerial = big.NewInt(3) }, data: [][]byte{data}},
+		{name: "message digest mismatch", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.corruptMessageDigest = true }, data: [][]byte{data}},
+		{name: "not a TSTInfo", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.eContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1} }, data: [][]byte{data}},
+		{name: "unsupported imprint hash algorithm", tsa: tsa, trusted: trusted,
+			modify: func(token *testRFC3161Token) { token.imprintAlgorithm = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26} }, data: [][]byte{data}},
+		{name: "different data", tsa: tsa, trusted: trusted, data: [][]byte{[]byte("other data")}},
+		{name: "no data", tsa: tsa, trusted: trusted, data: nil},
+	} {
+		token := c.tsa.defaultToken(data, genTime)
+		if c.modify != nil {
+			c.modify(token)
+		}
+		_, _, err := VerifyRFC3161Timestamp(c.trusted, rfc3161TimestampAnnotation(t, c.tsa.rfc3161Timestamp(t, token)), c.data)
+		assert.Error(t, err, c.name)
+		assert.IsType(t, InvalidSignatureError{}, err, c.name)
+	}
+}
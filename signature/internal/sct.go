@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// oidSCTList is the X.509 extension containing embedded signed certificate timestamps, per RFC 6962.
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// CTLogPublicKey is a public key of a certificate transparency log.
+type CTLogPublicKey struct {
+	Key crypto.PublicKey // *ecdsa.PublicKey or *rsa.PublicKey
+	// ValidFrom and ValidUntil, if not zero, restrict the times of signed certificate timestamps which can be signed by Key.
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// validAt returns true if k may be used to sign a certificate timestamp at t.
+func (k CTLogPublicKey) validAt(t time.Time) bool {
+	if !k.ValidFrom.IsZero() && t.Before(k.ValidFrom) {
+		return false
+	}
+	if !k.ValidUntil.IsZero() && t.After(k.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// ctLogID returns the log ID of a log using publicKey, as defined by RFC 6962.
+func ctLogID(publicKey crypto.PublicKey) ([sha256.Size]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(der), nil
+}
+
+// untrustedSCT is a parsed SignedCertificateTimestamp of RFC 6962.
+type untrustedSCT struct {
+	logID      []byte
+	timestamp  uint64
+	extensions []byte
+	hashAlg    uint8
+	sigAlg     uint8
+	signature  []byte
+}
+
+// parseSCTList parses the value of the oidSCTList extension.
+// SCTs with an unknown version are ignored.
+func parseSCTList(extValue []byte) ([]untrustedSCT, error) {
+	var list []byte
+	if rest, err := asn1.Unmarshal(extValue, &list); err != nil || len(rest) != 0 {
+		return nil, NewInvalidSignatureError("invalid signed certificate timestamp list")
+	}
+	input := cryptobyte.String(list)
+	var scts cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&scts) || !input.Empty() {
+		return nil, NewInvalidSignatureError("invalid signed certificate timestamp list")
+	}
+	res := []untrustedSCT{}
+	for !scts.Empty() {
+		var sctData cryptobyte.String
+		var version uint8
+		if !scts.ReadUint16LengthPrefixed(&sctData) || !sctData.ReadUint8(&version) {
+			return nil, NewInvalidSignatureError("invalid signed certificate timestamp")
+		}
+		if version != 0 { // v1
+			continue
+		}
+		var sct untrustedSCT
+		var extensions, signature cryptobyte.String
+		if !sctData.ReadBytes(&sct.logID, sha256.Size) || !sctData.ReadUint64(&sct.timestamp) ||
+			!sctData.ReadUint16LengthPrefixed(&extensions) ||
+			!sctData.ReadUint8(&sct.hashAlg) || !sctData.ReadUint8(&sct.sigAlg) ||
+			!sctData.ReadUint16LengthPrefixed(&signature) || !sctData.Empty() {
+			return nil, NewInvalidSignatureError("invalid signed certificate timestamp")
+		}
+		sct.extensions = extensions
+		sct.signature = signature
+		res = append(res, sct)
+	}
+	return res, nil
+}
+
+// precertificateTBS returns the DER encoding of the TBSCertificate of cert, without the oidSCTList extension,
+// i.e. the TBSCertificate of the precertificate which was submitted to the log.
+func precertificateTBS(cert *x509.Certificate) ([]byte, error) {
+	var tbs asn1.RawValue
+	if _, err := asn1.Unmarshal(cert.RawTBSCertificate, &tbs); err != nil {
+		return nil, err
+	}
+	var fields []byte
+	for rest := tbs.Bytes; len(rest) != 0; {
+		var field asn1.RawValue
+		var err error
+		rest, err = asn1.Unmarshal(rest, &field)
+		if err != nil {
+			return nil, err
+		}
+		if field.Class != asn1.ClassContextSpecific || field.Tag != 3 { // Not extensions [3]
+			fields = append(fields, field.FullBytes...)
+			continue
+		}
+		var extensionsSeq asn1.RawValue
+		if _, err := asn1.Unmarshal(field.Bytes, &extensionsSeq); err != nil {
+			return nil, err
+		}
+		var extensions []byte
+		for extRest := extensionsSeq.Bytes; len(extRest) != 0; {
+			var ext asn1.RawValue
+			extRest, err = asn1.Unmarshal(extRest, &ext)
+			if err != nil {
+				return nil, err
+			}
+			var parsed pkix.Extension
+			if _, err := asn1.Unmarshal(ext.FullBytes, &parsed); err != nil {
+				return nil, err
+			}
+			if !parsed.Id.Equal(oidSCTList) {
+				extensions = append(extensions, ext.FullBytes...)
+			}
+		}
+		extensionsSeqDER, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: extensions})
+		if err != nil {
+			return nil, err
+		}
+		fieldDER, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: extensionsSeqDER})
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fieldDER...)
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: fields})
+}
+
+// VerifyEmbeddedSCT verifies that cert, issued by issuer, contains a signed certificate timestamp (RFC 6962)
+// from one of trustedLogs.
+func VerifyEmbeddedSCT(trustedLogs []CTLogPublicKey, cert, issuer *x509.Certificate) error {
+	var extValue []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			extValue = ext.Value
+			break
+		}
+	}
+	if extValue == nil {
+		return NewInvalidSignatureError("certificate does not contain a signed certificate timestamp")
+	}
+	untrustedSCTs, err := parseSCTList(extValue)
+	if err != nil {
+		return err
+	}
+	tbs, err := precertificateTBS(cert)
+	if err != nil {
+		return NewInvalidSignatureError(fmt.Sprintf("parsing certificate for signed certificate timestamp verification: %v", err))
+	}
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+
+	for _, sct := range untrustedSCTs {
+		if sct.hashAlg != 4 { // sha256
+			continue
+		}
+		timestamp := time.UnixMilli(int64(sct.timestamp))
+		for _, log := range trustedLogs {
+			logID, err := ctLogID(log.Key)
+			if err != nil || !bytes.Equal(logID[:], sct.logID) || !log.validAt(timestamp) {
+				continue
+			}
+			// The digitally-signed data of a SignedCertificateTimestamp for a precert_entry, RFC 6962 section 3.2.
+			var b cryptobyte.Builder
+			b.AddUint8(0) // sct_version = v1
+			b.AddUint8(0) // signature_type = certificate_timestamp
+			b.AddUint64(sct.timestamp)
+			b.AddUint16(1) // entry_type = precert_entry
+			b.AddBytes(issuerKeyHash[:])
+			b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct.extensions) })
+			signed, err := b.Bytes()
+			if err != nil {
+				return err
+			}
+			digest := sha256.Sum256(signed)
+			switch pk := log.Key.(type) {
+			case *ecdsa.PublicKey:
+				if sct.sigAlg == 3 && ecdsa.VerifyASN1(pk, digest[:], sct.signature) {
+					return nil
+				}
+			case *rsa.PublicKey:
+				if sct.sigAlg == 1 && rsa.VerifyPKCS1v15(pk, crypto.SHA256, digest[:], sct.signature) == nil {
+					return nil
+				}
+			}
+		}
+	}
+	return NewInvalidSignatureError("certificate does not contain a valid signed certificate timestamp from a trusted certificate transparency log")
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyEmbeddedSCT(t *testing.T) {
+	certPEM, err := os.ReadFile("testdata/rekor-cert")
+	require.NoError(t, err)
+	certs, err := cryptoutils.UnmarshalCertificatesFromPEM(certPEM)
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	cert := certs[0]
+	chainPEM, err := os.ReadFile("testdata/fulcio-chain")
+	require.NoError(t, err)
+	chain, err := cryptoutils.UnmarshalCertificatesFromPEM(chainPEM)
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+	ctKeyPEM, err := os.ReadFile("testdata/ctfe-2022.pub")
+	require.NoError(t, err)
+	ctKey, err := cryptoutils.UnmarshalPEMToPublicKey(ctKeyPEM)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	// The SCT in testdata/rekor-cert
+	sctTime := time.Date(2022, 12, 12, 18, 48, 18, 437000000, time.UTC)
+
+	// Success
+	for _, logs := range [][]CTLogPublicKey{
+		{{Key: ctKey}},
+		{{Key: &otherKey.PublicKey}, {Key: ctKey, ValidFrom: sctTime, ValidUntil: sctTime}},
+	} {
+		err := VerifyEmbeddedSCT(logs, cert, chain[0])
+		assert.NoError(t, err)
+	}
+
+	// Failures
+	certWithoutSCT := *cert
+	certWithoutSCT.Extensions = nil
+	certWithCorruptSCT := *cert
+	certWithCorruptSCT.Extensions = nil
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}) {
+			ext.Value = append(ext.Value[:len(ext.Value):len(ext.Value)], 0)
+		}
+		certWithCorruptSCT.Extensions = append(certWithCorruptSCT.Extensions, ext)
+	}
+	for _, c := range []struct {
+		name   string
+		logs   []CTLogPublicKey
+		cert   *x509.Certificate
+		issuer *x509.Certificate
+	}{
+		{name: "no logs", logs: nil, cert: cert, issuer: chain[0]},
+		{name: "unknown log", logs: []CTLogPublicKey{{Key: &otherKey.PublicKey}}, cert: cert, issuer: chain[0]},
+		{name: "SCT before the log validity", logs: []CTLogPublicKey{{Key: ctKey, ValidFrom: sctTime.Add(time.Millisecond)}}, cert: cert, issuer: chain[0]},
+		{name: "SCT after the log validity", logs: []CTLogPublicKey{{Key: ctKey, ValidUntil: sctTime.Add(-time.Millisecond)}}, cert: cert, issuer: chain[0]},
+		{name: "different issuer", logs: []CTLogPublicKey{{Key: ctKey}}, cert: cert, issuer: chain[1]},
+		{name: "no SCT", logs: []CTLogPublicKey{{Key: ctKey}}, cert: &certWithoutSCT, issuer: chain[0]},
+		{name: "invalid SCT list", logs: []CTLogPublicKey{{Key: ctKey}}, cert: &certWithCorruptSCT, issuer: chain[0]},
+	} {
+		err := VerifyEmbeddedSCT(c.logs, c.cert, c.issuer)
+		assert.Error(t, err, c.name)
+		assert.IsType(t, InvalidSignatureError{}, err, c.name)
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+
+	"github.com/containers/image/v5/internal/signature"
+)
+
+// untrustedProtoInt64 is an int64 in the protobuf JSON mapping, which represents it as a string, but also accepts a number.
+type untrustedProtoInt64 int64
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (i *untrustedProtoInt64) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(data) != 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 value %q: %w", s, err)
+	}
+	*i = untrustedProtoInt64(v)
+	return nil
+}
+
+// untrustedSigstoreBundle is the subset of a Sigstore bundle (dev.sigstore.bundle.v1.Bundle, in the protobuf JSON mapping) we use.
+type untrustedSigstoreBundle struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		Certificate *struct {
+			RawBytes []byte `json:"rawBytes"`
+		} `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []struct {
+				RawBytes []byte `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		TlogEntries []struct {
+			LogIndex untrustedProtoInt64 `json:"logIndex"`
+			LogID    struct {
+				KeyID []byte `json:"keyId"`
+			} `json:"logId"`
+			IntegratedTime   untrustedProtoInt64 `json:"integratedTime"`
+			InclusionPromise *struct {
+				SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+			} `json:"inclusionPromise"`
+			CanonicalizedBody []byte `json:"canonicalizedBody"`
+		} `json:"tlogEntries"`
+		TimestampVerificationData *struct {
+			RFC3161Timestamps []struct {
+				SignedTimestamp []byte `json:"signedTimestamp"`
+			} `json:"rfc3161Timestamps"`
+		} `json:"timestampVerificationData"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope     json.RawMessage `json:"dsseEnvelope"`
+	MessageSignature json.RawMessage `json:"messageSignature"`
+}
+
+// UntrustedSigstoreBundleComponents parses unverifiedBundle, a Sigstore bundle, WITHOUT doing any cryptographic verification,
+// and returns the DSSE envelope it contains, and annotations equivalent to those of a sigstore signature attachment
+// (the signing certificate and its intermediate chain, a Rekor SET, and an RFC 3161 timestamp), so that the bundle can be verified
+// the same way as a DSSE envelope attachment.
+//
+// Bundles with a "messageSignature" do not include the signed payload, and are not supported.
+// The Rekor SET is built from the inclusion promise of a transparency log entry, inclusion proofs are not verified.
+// Only the first RFC 3161 timestamp is used.
+func UntrustedSigstoreBundleComponents(unverifiedBundle []byte) ([]byte, map[string]string, error) {
+	var bundle untrustedSigstoreBundle
+	if err := json.Unmarshal(unverifiedBundle, &bundle); err != nil {
+		return nil, nil, NewInvalidSignatureError(fmt.Sprintf("parsing Sigstore bundle: %v", err))
+	}
+	if !signature.IsSigstoreBundleMIMEType(bundle.MediaType) {
+		return nil, nil, NewInvalidSignatureError(fmt.Sprintf("unsupported Sigstore bundle media type %q", bundle.MediaType))
+	}
+	if len(bundle.DSSEEnvelope) == 0 || bytes.Equal(bundle.DSSEEnvelope, []byte("null")) {
+		if len(bundle.MessageSignature) != 0 {
+			return nil, nil, NewInvalidSignatureError("Sigstore bundles with a message signature are not supported")
+		}
+		return nil, nil, NewInvalidSignatureError("Sigstore bundle does not contain a DSSE envelope")
+	}
+
+	annotations := map[string]string{}
+	var certs [][]byte
+	switch {
+	case bundle.VerificationMaterial.Certificate != nil:
+		certs = [][]byte{bundle.VerificationMaterial.Certificate.RawBytes}
+	case bundle.VerificationMaterial.X509CertificateChain != nil:
+		for _, cert := range bundle.VerificationMaterial.X509CertificateChain.Certificates {
+			certs = append(certs, cert.RawBytes)
+		}
+		if len(certs) == 0 {
+			return nil, nil, NewInvalidSignatureError("Sigstore bundle contains an empty certificate chain")
+		}
+	}
+	if len(certs) != 0 {
+		annotations[signature.SigstoreCertificateAnnotationKey] = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[0]}))
+		if len(certs) > 1 {
+			chain := []byte{}
+			for _, cert := range certs[1:] {
+				chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})...)
+			}
+			annotations[signature.SigstoreIntermediateCertificateChainAnnotationKey] = string(chain)
+		}
+	}
+
+	for _, entry := range bundle.VerificationMaterial.TlogEntries {
+		if entry.InclusionPromise == nil {
+			continue
+		}
+		// This mirrors UntrustedRekorSET and UntrustedRekorPayload, which are not available with containers_image_rekor_stub.
+		setPayload, err := json.Marshal(map[string]any{
+			"body":           entry.CanonicalizedBody,
+			"integratedTime": int64(entry.IntegratedTime),
+			"logIndex":       int64(entry.LogIndex),
+			"logID":          hex.EncodeToString(entry.LogID.KeyID),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating a Rekor SET payload: %w", err)
+		}
+		set, err := json.Marshal(map[string]any{
+			"SignedEntryTimestamp": entry.InclusionPromise.SignedEntryTimestamp,
+			"Payload":              json.RawMessage(setPayload),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating a Rekor SET: %w", err)
+		}
+		annotations[signature.SigstoreSETAnnotationKey] = string(set)
+		break
+	}
+
+	if tvd := bundle.VerificationMaterial.TimestampVerificationData; tvd != nil && len(tvd.RFC3161Timestamps) != 0 {
+		timestamp, err := json.Marshal(UntrustedRFC3161Timestamp{
+			UntrustedSignedRFC3161Timestamp: tvd.RFC3161Timestamps[0].SignedTimestamp,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating an RFC 3161 timestamp: %w", err)
+		}
+		annotations[signature.SigstoreRFC3161TimestampAnnotationKey] = string(timestamp)
+	}
+	return bundle.DSSEEnvelope, annotations, nil
+}
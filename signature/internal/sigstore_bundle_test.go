@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sigstoreBundle returns a Sigstore bundle containing envelope, with verificationMaterial.
+func sigstoreBundle(t *testing.T, mediaType string, envelope []byte, verificationMaterial mSA) []byte {
+	res, err := json.Marshal(mSA{
+		"mediaType":            mediaType,
+		"verificationMaterial": verificationMaterial,
+		"dsseEnvelope":         json.RawMessage(envelope),
+	})
+	require.NoError(t, err)
+	return res
+}
+
+func TestUntrustedProtoInt64UnmarshalJSON(t *testing.T) {
+	for _, c := range []struct {
+		input    string
+		expected int64
+	}{
+		{`"1700000000"`, 1700000000},
+		{`1700000000`, 1700000000},
+		{`"-1"`, -1},
+	} {
+		var v untrustedProtoInt64
+		err := json.Unmarshal([]byte(c.input), &v)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, untrustedProtoInt64(c.expected), v, c.input)
+	}
+	for _, input := range []string{`""`, `"x"`, `1.5`, `"99999999999999999999"`, `true`} {
+		var v untrustedProtoInt64
+		err := json.Unmarshal([]byte(input), &v)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestUntrustedSigstoreBundleComponents(t *testing.T) {
+	envelope := []byte(`{"payload":"cGF5bG9hZA==","payloadType":"application/vnd.in-toto+json","signatures":[{"sig":"c2ln"}]}`)
+	leaf, intermediate, root := []byte("leaf"), []byte("intermediate"), []byte("root")
+	leafPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf}))
+	chainPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root}))
+	tlogEntry := mSA{
+		"logIndex":          "42",
+		"logId":             mSA{"keyId": base64.StdEncoding.EncodeToString([]byte{0x01, 0x02, 0xab})},
+		"kindVersion":       mSA{"kind": "dsse", "version": "0.0.1"},
+		"integratedTime":    "1700000000",
+		"inclusionPromise":  mSA{"signedEntryTimestamp": base64.StdEncoding.EncodeToString([]byte("set signature"))},
+		"inclusionProof":    mSA{"logIndex": "42"},
+		"canonicalizedBody": base64.StdEncoding.EncodeToString([]byte(`{"kind":"dsse"}`)),
+	}
+	expectedSET, err := json.Marshal(mSA{
+		"SignedEntryTimestamp": []byte("set signature"),
+		"Payload": mSA{
+			"body":           []byte(`{"kind":"dsse"}`),
+			"integratedTime": 1700000000,
+			"logIndex":       42,
+			"logID":          "0102ab",
+		},
+	})
+	require.NoError(t, err)
+
+	// A v0.3 bundle with a single certificate
+	for _, mediaType := range []string{signature.SigstoreBundleMIMEType, "application/vnd.dev.sigstore.bundle+json;version=0.3"} {
+		resEnvelope, annotations, err := UntrustedSigstoreBundleComponents(sigstoreBundle(t, mediaType, envelope, mSA{
+			"certificate": mSA{"rawBytes": base64.StdEncoding.EncodeToString(leaf)},
+			"tlogEntries": []any{tlogEntry},
+		}))
+		require.NoError(t, err, mediaType)
+		assert.JSONEq(t, string(envelope), string(resEnvelope), mediaType)
+		assert.Equal(t, map[string]string{
+			signature.SigstoreCertificateAnnotationKey: leafPEM,
+			signature.SigstoreSETAnnotationKey:         string(expectedSET),
+		}, annotations, mediaType)
+	}
+
+	// A certificate chain
+	_, annotations, err := UntrustedSigstoreBundleComponents(sigstoreBundle(t, signature.SigstoreBundleMIMEType, envelope, mSA{
+		"x509CertificateChain": mSA{"certificates": []any{
+			mSA{"rawBytes": base64.StdEncoding.EncodeToString(leaf)},
+			mSA{"rawBytes": base64.StdEncoding.EncodeToString(intermediate)},
+			mSA{"rawBytes": base64.StdEncoding.EncodeToString(root)},
+		}},
+		"tlogEntries": []any{tlogEntry},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		signature.SigstoreCertificateAnnotationKey:                  leafPEM,
+		signature.SigstoreIntermediateCertificateChainAnnotationKey: chainPEM,
+		signature.SigstoreSETAnnotationKey:                          string(expectedSET),
+	}, annotations)
+
+	// RFC 3161 timestamps
+	_, annotations, err = UntrustedSigstoreBundleComponents(sigstoreBundle(t, signature.SigstoreBundleMIMEType, envelope, mSA{
+		"certificate": mSA{"rawBytes": base64.StdEncoding.EncodeToString(leaf)},
+		"timestampVerificationData": mSA{"rfc3161Timestamps": []any{
+			mSA{"signedTimestamp": base64.StdEncoding.EncodeToString([]byte("timestamp 1"))},
+			mSA{"signedTimestamp": base64.StdEncoding.EncodeToString([]byte("timestamp 2"))},
+		}},
+	}))
+	require.NoError(t, err)
+	expectedTimestamp, err := json.Marshal(mSA{"SignedRFC3161Timestamp": []byte("timestamp 1")})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		signature.SigstoreCertificateAnnotationKey:      leafPEM,
+		signature.SigstoreRFC3161TimestampAnnotationKey: string(expectedTimestamp),
+	}, annotations)
+
+	// A public key, without a tlog entry with an inclusion promise
+	entryWithoutPromise := mSA{}
+	for k, v := range tlogEntry {
+		if k != "inclusionPromise" {
+			entryWithoutPromise[k] = v
+		}
+	}
+	_, annotations, err = UntrustedSigstoreBundleComponents(sigstoreBundle(t, signature.SigstoreBundleMIMEType, envelope, mSA{
+		"publicKey":   mSA{"hint": "key hint"},
+		"tlogEntries": []any{entryWithoutPromise},
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, annotations)
+
+	// Invalid bundles
+	for _, bundle := range [][]byte{
+		[]byte("not JSON"),
+		[]byte(`{"mediaType":1}`),
+		sigstoreBundle(t, signature.SigstoreDSSEEnvelopeMIMEType, envelope, mSA{}),
+		sigstoreBundle(t, "application/vnd.dev.sigstore.bundle+json;version=0.1", envelope, mSA{}),
+		sigstoreBundle(t, signature.SigstoreBundleMIMEType, []byte("null"), mSA{}),
+		[]byte(`{"mediaType":"` + signature.SigstoreBundleMIMEType + `","messageSignature":{"signature":"c2ln"}}`),
+		sigstoreBundle(t, signature.SigstoreBundleMIMEType, envelope, mSA{"x509CertificateChain": mSA{"certificates": []any{}}}),
+		sigstoreBundle(t, signature.SigstoreBundleMIMEType, envelope, mSA{"tlogEntries": []any{mSA{"logIndex": "x"}}}),
+	} {
+		_, _, err := UntrustedSigstoreBundleComponents(bundle)
+		assert.Error(t, err, string(bundle))
+	}
+}
@@ -0,0 +1,239 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/containers/image/v5/internal/multierr"
+	"github.com/containers/image/v5/internal/signature"
+	digest "github.com/opencontainers/go-digest"
+	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
+)
+
+const (
+	// inTotoPayloadType is the DSSE payload type of in-toto statements.
+	inTotoPayloadType = "application/vnd.in-toto+json"
+	// inTotoStatementTypePrefix is the common prefix of the "_type" values of all in-toto statement versions.
+	inTotoStatementTypePrefix = "https://in-toto.io/Statement/"
+)
+
+// untrustedDSSEEnvelope is a parsed DSSE envelope (https://github.com/secure-systems-lab/dsse/blob/master/envelope.md).
+type untrustedDSSEEnvelope struct {
+	untrustedPayloadType string
+	untrustedPayload     []byte
+	untrustedSignatures  [][]byte
+}
+
+// parseUntrustedDSSEEnvelope parses unverifiedEnvelope WITHOUT doing any cryptographic verification.
+func parseUntrustedDSSEEnvelope(unverifiedEnvelope []byte) (*untrustedDSSEEnvelope, error) {
+	var payloadType string
+	var payload []byte
+	var rawSignatures []json.RawMessage
+	if err := ParanoidUnmarshalJSONObjectExactFields(unverifiedEnvelope, map[string]any{
+		"payloadType": &payloadType,
+		"payload":     &payload,
+		"signatures":  &rawSignatures,
+	}); err != nil {
+		return nil, NewInvalidSignatureError(fmt.Sprintf("parsing DSSE envelope: %v", err))
+	}
+	res := untrustedDSSEEnvelope{
+		untrustedPayloadType: payloadType,
+		untrustedPayload:     payload,
+	}
+	for _, rawSig := range rawSignatures {
+		var sig []byte
+		gotSig := false
+		if err := ParanoidUnmarshalJSONObject(rawSig, func(key string) any {
+			switch key {
+			case "sig":
+				gotSig = true
+				return &sig
+			case "keyid":
+				var ignore string
+				return &ignore
+			default:
+				return nil
+			}
+		}); err != nil {
+			return nil, NewInvalidSignatureError(fmt.Sprintf("parsing DSSE envelope signature: %v", err))
+		}
+		if !gotSig {
+			return nil, NewInvalidSignatureError(`DSSE envelope signature is missing the "sig" field`)
+		}
+		res.untrustedSignatures = append(res.untrustedSignatures, sig)
+	}
+	if len(res.untrustedSignatures) == 0 {
+		return nil, NewInvalidSignatureError("DSSE envelope contains no signatures")
+	}
+	return &res, nil
+}
+
+// DSSETimestampedData returns the data an RFC 3161 timestamp of unverifiedEnvelope may refer to, WITHOUT doing any
+// cryptographic verification: the envelope itself (as timestamped by cosign), and each of its signatures
+// (as timestamped in Sigstore bundles), in that order.
+// If a timestamp refers to a signature, only that signature may be used to verify the envelope;
+// pass it as the requiredSignature parameter of VerifySigstoreDSSEEnvelope.
+func DSSETimestampedData(unverifiedEnvelope []byte) ([][]byte, error) {
+	untrustedEnvelope, err := parseUntrustedDSSEEnvelope(unverifiedEnvelope)
+	if err != nil {
+		return nil, err
+	}
+	return append([][]byte{unverifiedEnvelope}, untrustedEnvelope.untrustedSignatures...), nil
+}
+
+// dssePAE returns the DSSE “pre-authentication encoding” of payloadType and payload, i.e. the data actually signed.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// untrustedInTotoStatement is the subset of an in-toto statement we use.
+type untrustedInTotoStatement struct {
+	Type    string `json:"_type"`
+	Subject []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// verifyInTotoStatement verifies that unverifiedStatement has a predicate type accepted by rules,
+// and that the digest of one of its subjects is accepted by rules, and returns that digest.
+// The subject names are not a signed docker reference (they are arbitrary strings chosen by the producer),
+// so they are ignored, and rules.ValidateUnidentifiedPayload decides whether the lack of an identity is acceptable.
+func verifyInTotoStatement(unverifiedStatement []byte, rules SigstorePayloadAcceptanceRules) (*UntrustedSigstorePayload, error) {
+	var statement untrustedInTotoStatement
+	if err := json.Unmarshal(unverifiedStatement, &statement); err != nil {
+		return nil, NewInvalidSignatureError(fmt.Sprintf("parsing in-toto statement: %v", err))
+	}
+	if !strings.HasPrefix(statement.Type, inTotoStatementTypePrefix) {
+		return nil, NewInvalidSignatureError(fmt.Sprintf("Unrecognized in-toto statement type %q", statement.Type))
+	}
+	if !slices.Contains(rules.InTotoPredicateTypes, statement.PredicateType) {
+		return nil, NewInvalidSignatureError(fmt.Sprintf("in-toto statement predicate type %q is not accepted", statement.PredicateType))
+	}
+	if rules.ValidateUnidentifiedPayload == nil {
+		return nil, NewInvalidSignatureError("in-toto statements do not identify the image, and are not accepted")
+	}
+	if err := rules.ValidateUnidentifiedPayload(); err != nil {
+		return nil, err
+	}
+	if len(statement.Subject) == 0 {
+		return nil, NewInvalidSignatureError("in-toto statement has no subjects")
+	}
+	errs := []error{}
+	for _, subject := range statement.Subject {
+		hexDigest, ok := subject.Digest[digest.SHA256.String()]
+		if !ok {
+			errs = append(errs, NewInvalidSignatureError(fmt.Sprintf("in-toto statement subject %q has no sha256 digest", subject.Name)))
+			continue
+		}
+		subjectDigest := digest.NewDigestFromEncoded(digest.SHA256, hexDigest)
+		if err := subjectDigest.Validate(); err != nil {
+			errs = append(errs, NewInvalidSignatureError(fmt.Sprintf("invalid digest of in-toto statement subject %q: %v", subject.Name, err)))
+			continue
+		}
+		if err := rules.ValidateSignedDockerManifestDigest(subjectDigest); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// SigstorePayloadAcceptanceRules have accepted this subject.
+		return &UntrustedSigstorePayload{
+			untrustedDockerManifestDigest: subjectDigest,
+		}, nil
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, multierr.Format("no in-toto statement subject was accepted: ", "; ", "", errs)
+}
+
+// VerifySigstoreDSSEEnvelope verifies that unverifiedEnvelope, a DSSE envelope, was correctly signed by publicKey,
+// and that the principal components of its payload match expected values, both as specified by rules, and returns them.
+// The payload may be either a sigstore (“simple signing”) payload, or an in-toto statement; for an in-toto statement,
+// its predicate type and the digest of one of its subjects must be accepted by rules.
+// If requiredSignature is not nil, only that signature of the envelope is accepted.
+// We return an *UntrustedSigstorePayload, although nothing actually uses it,
+// just to double-check against stupid typos.
+func VerifySigstoreDSSEEnvelope(publicKey crypto.PublicKey, unverifiedEnvelope []byte, requiredSignature []byte, rules SigstorePayloadAcceptanceRules) (*UntrustedSigstorePayload, error) {
+	untrustedEnvelope, err := verifyDSSEEnvelopeSignature(publicKey, unverifiedEnvelope, requiredSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	// The payload type is covered by the signature, so it is now trusted; the payload is verified but not yet accepted.
+	switch untrustedEnvelope.untrustedPayloadType {
+	case signature.SigstoreSignatureMIMEType:
+		unmatchedPayload, err := ParseUntrustedSigstorePayload(untrustedEnvelope.untrustedPayload)
+		if err != nil {
+			return nil, err
+		}
+		if err := rules.ValidateSignedDockerManifestDigest(unmatchedPayload.untrustedDockerManifestDigest); err != nil {
+			return nil, err
+		}
+		if err := rules.ValidateSignedDockerReference(unmatchedPayload.untrustedDockerReference); err != nil {
+			return nil, err
+		}
+		// SigstorePayloadAcceptanceRules have accepted this value.
+		return unmatchedPayload, nil
+	case inTotoPayloadType:
+		return verifyInTotoStatement(untrustedEnvelope.untrustedPayload, rules)
+	default:
+		return nil, NewInvalidSignatureError(fmt.Sprintf("unsupported DSSE payload type %q", untrustedEnvelope.untrustedPayloadType))
+	}
+}
+
+// verifyDSSEEnvelopeSignature verifies that unverifiedEnvelope, a DSSE envelope, was correctly signed by publicKey,
+// and returns the envelope; its payload is verified, but not yet accepted.
+// If requiredSignature is not nil, only that signature of the envelope is accepted.
+func verifyDSSEEnvelopeSignature(publicKey crypto.PublicKey, unverifiedEnvelope []byte, requiredSignature []byte) (*untrustedDSSEEnvelope, error) {
+	verifier, err := sigstoreSignature.LoadVerifier(publicKey, sigstoreHarcodedHashAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("creating verifier: %w", err)
+	}
+
+	untrustedEnvelope, err := parseUntrustedDSSEEnvelope(unverifiedEnvelope)
+	if err != nil {
+		return nil, err
+	}
+	pae := dssePAE(untrustedEnvelope.untrustedPayloadType, untrustedEnvelope.untrustedPayload)
+	for _, unverifiedSignature := range untrustedEnvelope.untrustedSignatures {
+		if requiredSignature != nil && !bytes.Equal(unverifiedSignature, requiredSignature) {
+			continue
+		}
+		if err := verifier.VerifySignature(bytes.NewReader(unverifiedSignature), bytes.NewReader(pae)); err == nil {
+			return untrustedEnvelope, nil
+		}
+	}
+	return nil, NewInvalidSignatureError("cryptographic signature verification of DSSE envelope failed")
+}
+
+// VerifyInTotoAttestation verifies that unverifiedEnvelope, a DSSE envelope containing an in-toto statement, was correctly signed
+// by publicKey, and that one of the subjects of the statement is manifestDigest; it returns the predicate type and the predicate.
+// The predicate is not otherwise validated.
+func VerifyInTotoAttestation(publicKey crypto.PublicKey, unverifiedEnvelope []byte, manifestDigest digest.Digest) (string, json.RawMessage, error) {
+	envelope, err := verifyDSSEEnvelopeSignature(publicKey, unverifiedEnvelope, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if envelope.untrustedPayloadType != inTotoPayloadType {
+		return "", nil, NewInvalidSignatureError(fmt.Sprintf("unexpected DSSE payload type %q, expected an in-toto statement", envelope.untrustedPayloadType))
+	}
+	var statement untrustedInTotoStatement
+	if err := json.Unmarshal(envelope.untrustedPayload, &statement); err != nil {
+		return "", nil, NewInvalidSignatureError(fmt.Sprintf("parsing in-toto statement: %v", err))
+	}
+	if !strings.HasPrefix(statement.Type, inTotoStatementTypePrefix) {
+		return "", nil, NewInvalidSignatureError(fmt.Sprintf("Unrecognized in-toto statement type %q", statement.Type))
+	}
+	for _, subject := range statement.Subject {
+		if hexDigest, ok := subject.Digest[manifestDigest.Algorithm().String()]; ok && hexDigest == manifestDigest.Encoded() {
+			return statement.PredicateType, statement.Predicate, nil
+		}
+	}
+	return "", nil, NewInvalidSignatureError(fmt.Sprintf("in-toto statement does not refer to manifest %s", manifestDigest))
+}
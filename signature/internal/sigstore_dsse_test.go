@@ -0,0 +1,361 @@
+package internal
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/signature"
+	digest "github.com/opencontainers/go-digest"
+	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dsseEnvelope returns a DSSE envelope containing payloadType and payload, signed by all of signers.
+func dsseEnvelope(t *testing.T, payloadType string, payload []byte, signers ...crypto.Signer) []byte {
+	signatures := []any{}
+	for _, signer := range signers {
+		s, err := sigstoreSignature.LoadSigner(signer, sigstoreHarcodedHashAlgorithm)
+		require.NoError(t, err)
+		sig, err := s.SignMessage(bytes.NewReader(dssePAE(payloadType, payload)))
+		require.NoError(t, err)
+		signatures = append(signatures, map[string]any{"keyid": "", "sig": sig})
+	}
+	res, err := json.Marshal(map[string]any{
+		"payloadType": payloadType,
+		"payload":     payload,
+		"signatures":  signatures,
+	})
+	require.NoError(t, err)
+	return res
+}
+
+func TestDSSEPAE(t *testing.T) {
+	// The example from the DSSE specification.
+	assert.Equal(t, []byte("DSSEv1 29 http://example.com/HelloWorld 11 hello world"),
+		dssePAE("http://example.com/HelloWorld", []byte("hello world")))
+}
+
+func TestParseUntrustedDSSEEnvelope(t *testing.T) {
+	res, err := parseUntrustedDSSEEnvelope([]byte(`{"payloadType":"t","payload":"cGF5bG9hZA==",` +
+		`"signatures":[{"keyid":"k","sig":"c2ln"},{"sig":"c2lnMg=="}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, &untrustedDSSEEnvelope{
+		untrustedPayloadType: "t",
+		untrustedPayload:     []byte("payload"),
+		untrustedSignatures:  [][]byte{[]byte("sig"), []byte("sig2")},
+	}, res)
+
+	for _, input := range []string{
+		"this is invalid",
+		`{"payload":"cGF5bG9hZA==","signatures":[{"sig":"c2ln"}]}`,                          // Missing payloadType
+		`{"payloadType":"t","signatures":[{"sig":"c2ln"}]}`,                                 // Missing payload
+		`{"payloadType":"t","payload":"cGF5bG9hZA=="}`,                                      // Missing signatures
+		`{"payloadType":"t","payload":"cGF5bG9hZA==","signatures":[]}`,                      // No signatures
+		`{"payloadType":"t","payload":"cGF5bG9hZA==","signatures":[{"keyid":"k"}]}`,         // Missing sig
+		`{"payloadType":"t","payload":"cGF5bG9hZA==","signatures":[{"sig":"c2ln","x":1}]}`,  // Unknown signature field
+		`{"payloadType":"t","payload":"cGF5bG9hZA==","signatures":[{"sig":"c2ln"}],"x":1}`,  // Unknown envelope field
+		`{"payloadType":"t","payload":"not base64!","signatures":[{"sig":"c2ln"}]}`,         // Invalid payload
+		`{"payloadType":"t","payload":"cGF5bG9hZA==","signatures":[{"sig":"not base64!"}]}`, // Invalid sig
+	} {
+		_, err := parseUntrustedDSSEEnvelope([]byte(input))
+		assert.Error(t, err, input)
+		var invalidSigErr InvalidSignatureError
+		assert.ErrorAs(t, err, &invalidSigErr, input)
+	}
+}
+
+func TestDSSETimestampedData(t *testing.T) {
+	envelope := []byte(`{"payloadType":"t","payload":"cGF5bG9hZA==","signatures":[{"keyid":"k","sig":"c2ln"},{"sig":"c2lnMg=="}]}`)
+	res, err := DSSETimestampedData(envelope)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{envelope, []byte("sig"), []byte("sig2")}, res)
+
+	_, err = DSSETimestampedData([]byte("this is invalid"))
+	assert.Error(t, err)
+}
+
+func TestVerifySigstoreDSSEEnvelope(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey := privateKey.Public()
+
+	var recordedReference string
+	var recordedDigest digest.Digest
+	var recordedUnidentified bool
+	rules := SigstorePayloadAcceptanceRules{
+		ValidateSignedDockerReference: func(signedDockerReference string) error {
+			recordedReference = signedDockerReference
+			if signedDockerReference != TestSigstoreSignatureReference {
+				return errors.New("signedDockerReference mismatch")
+			}
+			return nil
+		},
+		ValidateSignedDockerManifestDigest: func(signedDockerManifestDigest digest.Digest) error {
+			recordedDigest = signedDockerManifestDigest
+			if signedDockerManifestDigest != TestSigstoreManifestDigest {
+				return errors.New("signedDockerManifestDigest mismatch")
+			}
+			return nil
+		},
+		InTotoPredicateTypes: []string{"https://example.com/other-predicate", "https://example.com/predicate"},
+		ValidateUnidentifiedPayload: func() error {
+			recordedUnidentified = true
+			return nil
+		},
+	}
+
+	sigBlob, err := os.ReadFile("./testdata/valid.signature")
+	require.NoError(t, err)
+	genericSig, err := signature.FromBlob(sigBlob)
+	require.NoError(t, err)
+	sigstoreSig, ok := genericSig.(signature.Sigstore)
+	require.True(t, ok)
+	simpleSigningPayload := sigstoreSig.UntrustedPayload()
+
+	inTotoStatementWithPredicateType := func(predicateType string, subjects ...any) []byte {
+		res, err := json.Marshal(map[string]any{
+			"_type":         "https://in-toto.io/Statement/v1",
+			"predicateType": predicateType,
+			"subject":       subjects,
+			"predicate":     map[string]any{},
+		})
+		require.NoError(t, err)
+		return res
+	}
+	inTotoStatement := func(subjects ...any) []byte {
+		return inTotoStatementWithPredicateType("https://example.com/predicate", subjects...)
+	}
+	subject := func(name string, d digest.Digest) any {
+		return map[string]any{"name": name, "digest": map[string]any{d.Algorithm().String(): d.Encoded()}}
+	}
+	otherDigest := digest.FromString("other")
+
+	// Successful verification
+	for _, c := range []struct {
+		payloadType  string
+		payload      []byte
+		reference    string
+		unidentified bool
+	}{
+		{signature.SigstoreSignatureMIMEType, simpleSigningPayload, TestSigstoreSignatureReference, false},
+		{inTotoPayloadType, inTotoStatement(subject(TestSigstoreSignatureReference, TestSigstoreManifestDigest)), "", true},
+		// One of several subjects matches
+		{inTotoPayloadType, inTotoStatement(subject("example.com/other", otherDigest),
+			subject(TestSigstoreSignatureReference, TestSigstoreManifestDigest)), "", true},
+		// Subject names are not used as an identity
+		{inTotoPayloadType, inTotoStatement(subject("example.com/other", TestSigstoreManifestDigest)), "", true},
+	} {
+		recordedReference, recordedDigest, recordedUnidentified = "", "", false
+		res, err := VerifySigstoreDSSEEnvelope(publicKey, dsseEnvelope(t, c.payloadType, c.payload, privateKey), nil, rules)
+		require.NoError(t, err, c.payloadType)
+		assert.Equal(t, c.reference, res.untrustedDockerReference)
+		assert.Equal(t, TestSigstoreManifestDigest, res.untrustedDockerManifestDigest)
+		assert.Equal(t, c.reference, recordedReference)
+		assert.Equal(t, TestSigstoreManifestDigest, recordedDigest)
+		assert.Equal(t, c.unidentified, recordedUnidentified)
+	}
+	// One of several signatures is valid
+	res, err := VerifySigstoreDSSEEnvelope(publicKey,
+		dsseEnvelope(t, signature.SigstoreSignatureMIMEType, simpleSigningPayload, otherPrivateKey, privateKey), nil, rules)
+	require.NoError(t, err)
+	assert.Equal(t, TestSigstoreSignatureReference, res.untrustedDockerReference)
+
+	// Invalid verifier
+	invalidPublicKey := struct{}{} // crypto.PublicKey is, for some reason, just an any, so this is acceptable.
+	res, err = VerifySigstoreDSSEEnvelope(invalidPublicKey,
+		dsseEnvelope(t, signature.SigstoreSignatureMIMEType, simpleSigningPayload, privateKey), nil, rules)
+	assert.Error(t, err)
+	assert.Nil(t, res)
+
+	// Failures
+	for _, envelope := range [][]byte{
+		// Invalid envelope
+		[]byte("this is invalid"),
+		// Signed by a different key
+		dsseEnvelope(t, signature.SigstoreSignatureMIMEType, simpleSigningPayload, otherPrivateKey),
+		// Unsupported payload type
+		dsseEnvelope(t, "text/plain", simpleSigningPayload, privateKey),
+		// Invalid simple signing payload
+		dsseEnvelope(t, signature.SigstoreSignatureMIMEType, []byte("invalid"), privateKey),
+		// Simple signing payload rejected by rules
+		dsseEnvelope(t, signature.SigstoreSignatureMIMEType,
+			[]byte(`{"critical":{"identity":{"docker-reference":"example.com/other"},"image":{"docker-manifest-digest":"`+
+				TestSigstoreManifestDigest.String()+`"},"type":"cosign container image signature"},"optional":{}}`), privateKey),
+		// Invalid in-toto statement
+		dsseEnvelope(t, inTotoPayloadType, []byte("invalid"), privateKey),
+		// Unrecognized in-toto statement type
+		dsseEnvelope(t, inTotoPayloadType, []byte(`{"_type":"https://example.com/Statement","subject":[{"name":"`+
+			TestSigstoreSignatureReference+`","digest":{"sha256":"`+TestSigstoreManifestDigest.Encoded()+`"}}]}`), privateKey),
+		// No subjects
+		dsseEnvelope(t, inTotoPayloadType, inTotoStatement(), privateKey),
+		// Subject without a sha256 digest
+		dsseEnvelope(t, inTotoPayloadType, inTotoStatement(map[string]any{
+			"name": TestSigstoreSignatureReference, "digest": map[string]any{"sha512": "abcd"},
+		}), privateKey),
+		// Subject with an invalid digest
+		dsseEnvelope(t, inTotoPayloadType, inTotoStatement(map[string]any{
+			"name": TestSigstoreSignatureReference, "digest": map[string]any{"sha256": "invalid"},
+		}), privateKey),
+		// Subjects rejected by rules
+		dsseEnvelope(t, inTotoPayloadType, inTotoStatement(subject(TestSigstoreSignatureReference, otherDigest)), privateKey),
+		dsseEnvelope(t, inTotoPayloadType, inTotoStatement(subject(TestSigstoreSignatureReference, otherDigest),
+			subject("example.com/other", otherDigest)), privateKey),
+		// Predicate type not accepted by rules
+		dsseEnvelope(t, inTotoPayloadType, inTotoStatementWithPredicateType("https://example.com/unknown",
+			subject(TestSigstoreSignatureReference, TestSigstoreManifestDigest)), privateKey),
+		dsseEnvelope(t, inTotoPayloadType, inTotoStatementWithPredicateType("",
+			subject(TestSigstoreSignatureReference, TestSigstoreManifestDigest)), privateKey),
+	} {
+		res, err := VerifySigstoreDSSEEnvelope(publicKey, envelope, nil, rules)
+		assert.Error(t, err, string(envelope))
+		assert.Nil(t, res, string(envelope))
+	}
+
+	// In-toto statements rejected by rules
+	validInToto := dsseEnvelope(t, inTotoPayloadType, inTotoStatement(subject(TestSigstoreSignatureReference, TestSigstoreManifestDigest)), privateKey)
+	for _, modify := range []func(rules *SigstorePayloadAcceptanceRules){
+		func(rules *SigstorePayloadAcceptanceRules) { rules.InTotoPredicateTypes = nil },
+		func(rules *SigstorePayloadAcceptanceRules) { rules.ValidateUnidentifiedPayload = nil },
+		func(rules *SigstorePayloadAcceptanceRules) {
+			rules.ValidateUnidentifiedPayload = func() error { return errors.New("unidentified payload rejected") }
+		},
+	} {
+		modifiedRules := rules
+		modify(&modifiedRules)
+		res, err := VerifySigstoreDSSEEnvelope(publicKey, validInToto, nil, modifiedRules)
+		assert.Error(t, err)
+		assert.Nil(t, res)
+	}
+}
+
+func TestVerifySigstoreDSSEEnvelopeTimestampedSignature(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey := privateKey.Public()
+	rules := SigstorePayloadAcceptanceRules{
+		ValidateSignedDockerReference:      func(string) error { return nil },
+		ValidateSignedDockerManifestDigest: func(digest.Digest) error { return nil },
+	}
+	sigBlob, err := os.ReadFile("./testdata/valid.signature")
+	require.NoError(t, err)
+	genericSig, err := signature.FromBlob(sigBlob)
+	require.NoError(t, err)
+	sigstoreSig, ok := genericSig.(signature.Sigstore)
+	require.True(t, ok)
+	newPayload := sigstoreSig.UntrustedPayload()
+
+	// An old, timestamped, signature made by the same key, e.g. an ephemeral key of a Fulcio certificate which has since expired…
+	oldEnvelope, err := parseUntrustedDSSEEnvelope(dsseEnvelope(t, signature.SigstoreSignatureMIMEType, []byte("old payload"), privateKey))
+	require.NoError(t, err)
+	oldSignature := oldEnvelope.untrustedSignatures[0]
+	// … replayed next to a new, untimestamped, signature of a different payload.
+	newEnvelope, err := parseUntrustedDSSEEnvelope(dsseEnvelope(t, signature.SigstoreSignatureMIMEType, newPayload, privateKey))
+	require.NoError(t, err)
+	newSignature := newEnvelope.untrustedSignatures[0]
+	envelope, err := json.Marshal(map[string]any{
+		"payloadType": signature.SigstoreSignatureMIMEType,
+		"payload":     newPayload,
+		"signatures":  []any{map[string]any{"sig": oldSignature}, map[string]any{"sig": newSignature}},
+	})
+	require.NoError(t, err)
+
+	tsa := newTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	genTime := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	timestampedData, err := DSSETimestampedData(envelope)
+	require.NoError(t, err)
+	for _, c := range []struct {
+		name        string
+		timestamped []byte
+		success     bool
+	}{
+		{name: "replayed signature", timestamped: oldSignature, success: false},
+		{name: "new signature", timestamped: newSignature, success: true},
+		{name: "whole envelope", timestamped: envelope, success: true},
+	} {
+		timestamp := rfc3161TimestampAnnotation(t, tsa.rfc3161Timestamp(t, tsa.defaultToken(c.timestamped, genTime)))
+		_, index, err := VerifyRFC3161Timestamp([]*x509.Certificate{tsa.root}, timestamp, timestampedData)
+		require.NoError(t, err, c.name)
+		var requiredSignature []byte
+		if index > 0 {
+			requiredSignature = timestampedData[index]
+		}
+		res, err := VerifySigstoreDSSEEnvelope(publicKey, envelope, requiredSignature, rules)
+		if c.success {
+			require.NoError(t, err, c.name)
+			assert.NotNil(t, res, c.name)
+		} else {
+			assert.Error(t, err, c.name)
+			assert.Nil(t, res, c.name)
+		}
+	}
+}
+
+func TestVerifyInTotoAttestation(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey := privateKey.Public()
+	manifestDigest := digest.FromString("manifest")
+	otherDigest := digest.FromString("other")
+
+	statement := func(statementType string, subjectDigests ...digest.Digest) []byte {
+		subjects := []any{}
+		for _, d := range subjectDigests {
+			subjects = append(subjects, map[string]any{"name": "example.com/repo", "digest": map[string]any{d.Algorithm().String(): d.Encoded()}})
+		}
+		res, err := json.Marshal(map[string]any{
+			"_type":         statementType,
+			"predicateType": "https://example.com/predicate",
+			"subject":       subjects,
+			"predicate":     map[string]any{"key": "value"},
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	// Successful verification
+	for _, subjects := range [][]digest.Digest{
+		{manifestDigest},
+		{otherDigest, manifestDigest},
+	} {
+		predicateType, predicate, err := VerifyInTotoAttestation(publicKey,
+			dsseEnvelope(t, inTotoPayloadType, statement("https://in-toto.io/Statement/v1", subjects...), privateKey), manifestDigest)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/predicate", predicateType)
+		assert.JSONEq(t, `{"key":"value"}`, string(predicate))
+	}
+
+	// Failures
+	for _, envelope := range [][]byte{
+		// Invalid envelope
+		[]byte("this is invalid"),
+		// Signed by a different key
+		dsseEnvelope(t, inTotoPayloadType, statement("https://in-toto.io/Statement/v1", manifestDigest), otherPrivateKey),
+		// Not an in-toto statement
+		dsseEnvelope(t, signature.SigstoreSignatureMIMEType, statement("https://in-toto.io/Statement/v1", manifestDigest), privateKey),
+		// Invalid in-toto statement
+		dsseEnvelope(t, inTotoPayloadType, []byte("invalid"), privateKey),
+		// Unrecognized in-toto statement type
+		dsseEnvelope(t, inTotoPayloadType, statement("https://example.com/Statement", manifestDigest), privateKey),
+		// No subjects
+		dsseEnvelope(t, inTotoPayloadType, statement("https://in-toto.io/Statement/v1"), privateKey),
+		// A different subject
+		dsseEnvelope(t, inTotoPayloadType, statement("https://in-toto.io/Statement/v1", otherDigest), privateKey),
+	} {
+		_, _, err := VerifyInTotoAttestation(publicKey, envelope, manifestDigest)
+		assert.Error(t, err, string(envelope))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"time"
 
 	"github.com/containers/image/v5/version"
@@ -29,6 +30,8 @@ type UntrustedSigstorePayload struct {
 	// So, this is explicitly an int64, and we reject fractional values. If we did need more precise timestamps eventually,
 	// we would add another field, UntrustedTimestampNS int64.
 	untrustedTimestamp *int64
+	// untrustedAnnotations are the unrecognized fields of "optional", typically user-specified annotations; nil if there are none.
+	untrustedAnnotations map[string]any
 }
 
 // NewUntrustedSigstorePayload returns an UntrustedSigstorePayload object with
@@ -67,6 +70,11 @@ func (s UntrustedSigstorePayload) MarshalJSON() ([]byte, error) {
 	if s.untrustedTimestamp != nil {
 		optional["timestamp"] = *s.untrustedTimestamp
 	}
+	for k, v := range s.untrustedAnnotations {
+		if _, ok := optional[k]; !ok {
+			optional[k] = v
+		}
+	}
 	signature := map[string]any{
 		"critical": critical,
 		"optional": optional,
@@ -119,6 +127,16 @@ func (s *UntrustedSigstorePayload) strictUnmarshalJSON(data []byte) error {
 		}); err != nil {
 			return err
 		}
+		// Everything else in "optional" are annotations, typically specified by the user when signing.
+		var annotations map[string]any
+		if err := json.Unmarshal(optional, &annotations); err != nil {
+			return JSONFormatError(err.Error())
+		}
+		delete(annotations, "creator")
+		delete(annotations, "timestamp")
+		if len(annotations) != 0 {
+			s.untrustedAnnotations = annotations
+		}
 	}
 	if gotCreatorID {
 		s.untrustedCreatorID = &creatorID
@@ -161,6 +179,44 @@ func (s *UntrustedSigstorePayload) strictUnmarshalJSON(data []byte) error {
 	})
 }
 
+// ParseUntrustedSigstorePayload parses unverifiedPayload WITHOUT doing any cryptographic verification.
+func ParseUntrustedSigstorePayload(unverifiedPayload []byte) (*UntrustedSigstorePayload, error) {
+	var res UntrustedSigstorePayload
+	if err := json.Unmarshal(unverifiedPayload, &res); err != nil {
+		return nil, NewInvalidSignatureError(err.Error())
+	}
+	return &res, nil
+}
+
+// UntrustedDockerManifestDigest returns the manifest digest claimed by the payload.
+func (s UntrustedSigstorePayload) UntrustedDockerManifestDigest() digest.Digest {
+	return s.untrustedDockerManifestDigest
+}
+
+// UntrustedDockerReference returns the Docker reference claimed by the payload.
+func (s UntrustedSigstorePayload) UntrustedDockerReference() string {
+	return s.untrustedDockerReference
+}
+
+// UntrustedCreatorID returns the creator recorded in the payload, or nil if not present.
+func (s UntrustedSigstorePayload) UntrustedCreatorID() *string {
+	return s.untrustedCreatorID
+}
+
+// UntrustedTimestamp returns the timestamp recorded in the payload, or nil if not present.
+func (s UntrustedSigstorePayload) UntrustedTimestamp() *time.Time {
+	if s.untrustedTimestamp == nil {
+		return nil
+	}
+	ts := time.Unix(*s.untrustedTimestamp, 0)
+	return &ts
+}
+
+// UntrustedAnnotations returns the annotations recorded in the "optional" section of the payload, or nil if there are none.
+func (s UntrustedSigstorePayload) UntrustedAnnotations() map[string]any {
+	return maps.Clone(s.untrustedAnnotations)
+}
+
 // SigstorePayloadAcceptanceRules specifies how to decide whether an untrusted payload is acceptable.
 // We centralize the actual parsing and data extraction in VerifySigstorePayload; this supplies
 // the policy.  We use an object instead of supplying func parameters to verifyAndExtractSignature
@@ -169,6 +225,12 @@ func (s *UntrustedSigstorePayload) strictUnmarshalJSON(data []byte) error {
 type SigstorePayloadAcceptanceRules struct {
 	ValidateSignedDockerReference      func(string) error
 	ValidateSignedDockerManifestDigest func(digest.Digest) error
+	// InTotoPredicateTypes lists the predicate types of in-toto statements which are accepted as image signatures.
+	// If empty, in-toto statements are rejected.
+	InTotoPredicateTypes []string
+	// ValidateUnidentifiedPayload is called instead of ValidateSignedDockerReference for payloads which don’t contain
+	// a signed docker reference (in-toto statements). If nil, such payloads are rejected.
+	ValidateUnidentifiedPayload func() error
 }
 
 // VerifySigstorePayload verifies unverifiedBase64Signature of unverifiedPayload was correctly created by publicKey, and that its principal components
@@ -191,9 +253,9 @@ func VerifySigstorePayload(publicKey crypto.PublicKey, unverifiedPayload []byte,
 		return nil, NewInvalidSignatureError(fmt.Sprintf("cryptographic signature verification failed: %v", err))
 	}
 
-	var unmatchedPayload UntrustedSigstorePayload
-	if err := json.Unmarshal(unverifiedPayload, &unmatchedPayload); err != nil {
-		return nil, NewInvalidSignatureError(err.Error())
+	unmatchedPayload, err := ParseUntrustedSigstorePayload(unverifiedPayload)
+	if err != nil {
+		return nil, err
 	}
 	if err := rules.ValidateSignedDockerManifestDigest(unmatchedPayload.untrustedDockerManifestDigest); err != nil {
 		return nil, err
@@ -202,5 +264,5 @@ func VerifySigstorePayload(publicKey crypto.PublicKey, unverifiedPayload []byte,
 		return nil, err
 	}
 	// SigstorePayloadAcceptanceRules have accepted this value.
-	return &unmatchedPayload, nil
+	return unmatchedPayload, nil
 }
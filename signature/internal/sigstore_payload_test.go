@@ -77,6 +77,15 @@ func TestUntrustedSigstorePayloadMarshalJSON(t *testing.T) {
 			},
 			"{\"critical\":{\"identity\":{\"docker-reference\":\"reference#@!\"},\"image\":{\"docker-manifest-digest\":\"" + testDigest + "\"},\"type\":\"cosign container image signature\"},\"optional\":{}}",
 		},
+		{
+			UntrustedSigstorePayload{
+				untrustedDockerManifestDigest: testDigest,
+				untrustedDockerReference:      "reference#@!",
+				untrustedTimestamp:            &timestamp,
+				untrustedAnnotations:          map[string]any{"key": "value", "timestamp": "ignored"},
+			},
+			"{\"critical\":{\"identity\":{\"docker-reference\":\"reference#@!\"},\"image\":{\"docker-manifest-digest\":\"" + testDigest + "\"},\"type\":\"cosign container image signature\"},\"optional\":{\"key\":\"value\",\"timestamp\":1484683104}}",
+		},
 	} {
 		marshaled, err := c.input.MarshalJSON()
 		require.NoError(t, err)
@@ -188,7 +197,9 @@ func TestUntrustedSigstorePayloadUnmarshalJSON(t *testing.T) {
 	for _, fn := range allowedModificationFns {
 		testJSON := modifiedJSON(t, validJSON, fn)
 		s := successfullyUnmarshalUntrustedSigstorePayload(t, testJSON)
-		assert.Equal(t, validSig, s)
+		expected := validSig
+		expected.untrustedAnnotations = map[string]any{"unexpected": 1.0}
+		assert.Equal(t, expected, s)
 	}
 
 	// Optional fields can be missing
@@ -204,6 +215,34 @@ func TestUntrustedSigstorePayloadUnmarshalJSON(t *testing.T) {
 	assert.Equal(t, validSig, s)
 }
 
+func TestParseUntrustedSigstorePayload(t *testing.T) {
+	const testDigest = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	res, err := ParseUntrustedSigstorePayload([]byte(`{"critical":{"identity":{"docker-reference":"reference#@!"},"image":{"docker-manifest-digest":"` + testDigest + `"},"type":"cosign container image signature"},` +
+		`"optional":{"creator":"CREATOR","timestamp":1484683104,"key":"value"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, digest.Digest(testDigest), res.UntrustedDockerManifestDigest())
+	assert.Equal(t, "reference#@!", res.UntrustedDockerReference())
+	require.NotNil(t, res.UntrustedCreatorID())
+	assert.Equal(t, "CREATOR", *res.UntrustedCreatorID())
+	require.NotNil(t, res.UntrustedTimestamp())
+	assert.Equal(t, time.Unix(1484683104, 0), *res.UntrustedTimestamp())
+	assert.Equal(t, map[string]any{"key": "value"}, res.UntrustedAnnotations())
+
+	// No optional fields
+	res, err = ParseUntrustedSigstorePayload([]byte(`{"critical":{"identity":{"docker-reference":"reference#@!"},"image":{"docker-manifest-digest":"` + testDigest + `"},"type":"cosign container image signature"},"optional":null}`))
+	require.NoError(t, err)
+	assert.Nil(t, res.UntrustedCreatorID())
+	assert.Nil(t, res.UntrustedTimestamp())
+	assert.Nil(t, res.UntrustedAnnotations())
+
+	// Invalid payload
+	_, err = ParseUntrustedSigstorePayload([]byte("&"))
+	assert.Error(t, err)
+	var invalidSigErr InvalidSignatureError
+	assert.ErrorAs(t, err, &invalidSigErr)
+}
+
 func TestVerifySigstorePayload(t *testing.T) {
 	publicKeyPEM, err := os.ReadFile("./testdata/cosign.pub")
 	require.NoError(t, err)
@@ -17,8 +17,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/signature/internal"
@@ -75,19 +78,106 @@ func defaultPolicyPathWithHomeDir(sys *types.SystemContext, homeDir string) stri
 	return systemDefaultPolicyPath
 }
 
-// NewPolicyFromFile returns a policy configured in the specified file.
+// NewPolicyFromFile returns a policy configured in the specified file,
+// merged with policy fragments in its drop-in directory, if any (see policyDropInDirPath).
+//
+// Fragments are *.json files with the same format as the policy file, except that "default" is optional.
+// They are applied in lexical order of their file names, after the policy file itself:
+// a "default" value replaces the previous one, and requirements for a transport scope replace any
+// previous requirements for that exact scope. The policy file may be missing if the drop-in directory
+// contains fragments; the resulting policy must have a "default" value.
 func NewPolicyFromFile(fileName string) (*Policy, error) {
-	contents, err := os.ReadFile(fileName)
+	dropIns, err := policyDropInFiles(fileName)
 	if err != nil {
 		return nil, err
 	}
-	policy, err := NewPolicyFromBytes(contents)
-	if err != nil {
-		return nil, fmt.Errorf("invalid policy in %q: %w", fileName, err)
+	contents, err := os.ReadFile(fileName)
+	if len(dropIns) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		policy, err := NewPolicyFromBytes(contents)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy in %q: %w", fileName, err)
+		}
+		return policy, nil
+	}
+
+	policy := &Policy{Transports: map[string]PolicyTransportScopes{}}
+	switch {
+	case err == nil:
+		if err := policy.mergeFragment(contents); err != nil {
+			return nil, fmt.Errorf("invalid policy in %q: %w", fileName, err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		// The whole policy can be provided by the drop-in files.
+	default:
+		return nil, err
+	}
+	for _, dropIn := range dropIns {
+		contents, err := os.ReadFile(dropIn)
+		if err != nil {
+			return nil, err
+		}
+		if err := policy.mergeFragment(contents); err != nil {
+			return nil, fmt.Errorf("invalid policy fragment in %q: %w", dropIn, err)
+		}
+	}
+	if policy.Default == nil {
+		return nil, fmt.Errorf("invalid policy in %q and %q: %w", fileName, policyDropInDirPath(fileName), InvalidPolicyFormatError("Default policy is missing"))
 	}
 	return policy, nil
 }
 
+// policyDropInDirPath returns the path of the drop-in directory for the policy in fileName:
+// fileName with its extension replaced by ".d", e.g. /etc/containers/policy.d for /etc/containers/policy.json.
+func policyDropInDirPath(fileName string) string {
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".d"
+}
+
+// policyDropInFiles returns the paths of *.json files in the drop-in directory for fileName, in the order they should be applied.
+// It returns nil if the directory does not exist.
+func policyDropInFiles(fileName string) ([]string, error) {
+	dirPath := policyDropInDirPath(fileName)
+	entries, err := os.ReadDir(dirPath) // Sorted by file name
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading policy drop-in directory %q: %w", dirPath, err)
+	}
+	res := []string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		res = append(res, filepath.Join(dirPath, e.Name()))
+	}
+	return res, nil
+}
+
+// mergeFragment parses data as a policy fragment, and merges it into p, as documented in NewPolicyFromFile.
+func (p *Policy) mergeFragment(data []byte) error {
+	fragment := Policy{}
+	if err := fragment.unmarshalFragmentJSON(data); err != nil {
+		return err
+	}
+	if fragment.Default != nil {
+		p.Default = fragment.Default
+	}
+	for transport, scopes := range fragment.Transports {
+		dest, ok := p.Transports[transport]
+		if !ok {
+			dest = PolicyTransportScopes{}
+			p.Transports[transport] = dest
+		}
+		for scope, requirements := range scopes {
+			dest[scope] = requirements
+		}
+	}
+	return nil
+}
+
 // NewPolicyFromBytes returns a policy parsed from the specified blob.
 // Use this function instead of calling json.Unmarshal directly.
 func NewPolicyFromBytes(data []byte) (*Policy, error) {
@@ -103,6 +193,17 @@ var _ json.Unmarshaler = (*Policy)(nil)
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
 func (p *Policy) UnmarshalJSON(data []byte) error {
+	if err := p.unmarshalFragmentJSON(data); err != nil {
+		return err
+	}
+	if p.Default == nil {
+		return InvalidPolicyFormatError("Default policy is missing")
+	}
+	return nil
+}
+
+// unmarshalFragmentJSON is like UnmarshalJSON, but it does not require the default policy to be set.
+func (p *Policy) unmarshalFragmentJSON(data []byte) error {
 	*p = Policy{}
 	transports := policyTransportsMap{}
 	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
@@ -118,9 +219,6 @@ func (p *Policy) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	if p.Default == nil {
-		return InvalidPolicyFormatError("Default policy is missing")
-	}
 	p.Transports = map[string]PolicyTransportScopes(transports)
 	return nil
 }
@@ -246,6 +344,10 @@ func newPolicyRequirementFromJSON(data []byte) (PolicyRequirement, error) {
 		res = &prSignedBaseLayer{}
 	case prTypeSigstoreSigned:
 		res = &prSigstoreSigned{}
+	case prTypeExternalEvaluator:
+		res = &prExternalEvaluator{}
+	case prTypeVulnerabilityAttestation:
+		res = &prVulnerabilityAttestation{}
 	default:
 		return nil, InvalidPolicyFormatError(fmt.Sprintf("Unknown policy requirement type %q", typeField.Type))
 	}
@@ -376,6 +478,46 @@ func NewPRSignedByKeyData(keyType sbKeyType, keyData []byte, signedIdentity Poli
 	return newPRSignedByKeyData(keyType, keyData, signedIdentity)
 }
 
+// newPRSignedByKeyFingerprint is NewPRSignedByKeyFingerprintFromWKD / NewPRSignedByKeyFingerprintFromKeyserver,
+// except it returns the private type.
+func newPRSignedByKeyFingerprint(keyType sbKeyType, keyFingerprint string, keySource *prSignedByKeySource, signedIdentity PolicyReferenceMatch) (*prSignedBy, error) {
+	if !keyType.IsValid() {
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid keyType %q", keyType))
+	}
+	if !keyFingerprintRegexp.MatchString(keyFingerprint) {
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid keyFingerprint %q", keyFingerprint))
+	}
+	if keySource == nil {
+		return nil, InvalidPolicyFormatError("keySource not specified")
+	}
+	if err := keySource.validate(); err != nil {
+		return nil, err
+	}
+	if signedIdentity == nil {
+		return nil, InvalidPolicyFormatError("signedIdentity not specified")
+	}
+	sourceCopy := *keySource
+	return &prSignedBy{
+		prCommon:       prCommon{Type: prTypeSignedBy},
+		KeyType:        keyType,
+		KeyFingerprint: strings.ToUpper(keyFingerprint),
+		KeySource:      &sourceCopy,
+		SignedIdentity: signedIdentity,
+	}, nil
+}
+
+// NewPRSignedByKeyFingerprintFromWKD returns a new "signedBy" PolicyRequirement trusting the key with keyFingerprint,
+// fetched from the OpenPGP Web Key Directory for email.
+func NewPRSignedByKeyFingerprintFromWKD(keyType sbKeyType, keyFingerprint, email string, signedIdentity PolicyReferenceMatch) (PolicyRequirement, error) {
+	return newPRSignedByKeyFingerprint(keyType, keyFingerprint, &prSignedByKeySource{WKD: email}, signedIdentity)
+}
+
+// NewPRSignedByKeyFingerprintFromKeyserver returns a new "signedBy" PolicyRequirement trusting the key with keyFingerprint,
+// fetched from the HKP keyserver at keyserverURL.
+func NewPRSignedByKeyFingerprintFromKeyserver(keyType sbKeyType, keyFingerprint, keyserverURL string, signedIdentity PolicyReferenceMatch) (PolicyRequirement, error) {
+	return newPRSignedByKeyFingerprint(keyType, keyFingerprint, &prSignedByKeySource{Keyserver: keyserverURL}, signedIdentity)
+}
+
 // Compile-time check that prSignedBy implements json.Unmarshaler.
 var _ json.Unmarshaler = (*prSignedBy)(nil)
 
@@ -383,7 +525,7 @@ var _ json.Unmarshaler = (*prSignedBy)(nil)
 func (pr *prSignedBy) UnmarshalJSON(data []byte) error {
 	*pr = prSignedBy{}
 	var tmp prSignedBy
-	var gotKeyPath, gotKeyPaths, gotKeyData = false, false, false
+	var gotKeyPath, gotKeyPaths, gotKeyData, gotKeyFingerprint = false, false, false, false
 	var signedIdentity json.RawMessage
 	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
 		switch key {
@@ -400,8 +542,15 @@ func (pr *prSignedBy) UnmarshalJSON(data []byte) error {
 		case "keyData":
 			gotKeyData = true
 			return &tmp.KeyData
+		case "keyFingerprint":
+			gotKeyFingerprint = true
+			return &tmp.KeyFingerprint
+		case "keySource":
+			return &tmp.KeySource
 		case "signedIdentity":
 			return &signedIdentity
+		case "signedDigest":
+			return &tmp.SignedDigest
 		default:
 			return nil
 		}
@@ -424,26 +573,85 @@ func (pr *prSignedBy) UnmarshalJSON(data []byte) error {
 
 	var res *prSignedBy
 	var err error
+	if tmp.KeySource != nil && !gotKeyFingerprint {
+		return InvalidPolicyFormatError("keySource specified without keyFingerprint")
+	}
 	switch {
-	case gotKeyPath && !gotKeyPaths && !gotKeyData:
+	case gotKeyPath && !gotKeyPaths && !gotKeyData && !gotKeyFingerprint:
 		res, err = newPRSignedByKeyPath(tmp.KeyType, tmp.KeyPath, tmp.SignedIdentity)
-	case !gotKeyPath && gotKeyPaths && !gotKeyData:
+	case !gotKeyPath && gotKeyPaths && !gotKeyData && !gotKeyFingerprint:
 		res, err = newPRSignedByKeyPaths(tmp.KeyType, tmp.KeyPaths, tmp.SignedIdentity)
-	case !gotKeyPath && !gotKeyPaths && gotKeyData:
+	case !gotKeyPath && !gotKeyPaths && gotKeyData && !gotKeyFingerprint:
 		res, err = newPRSignedByKeyData(tmp.KeyType, tmp.KeyData, tmp.SignedIdentity)
-	case !gotKeyPath && !gotKeyPaths && !gotKeyData:
-		return InvalidPolicyFormatError("Exactly one of keyPath, keyPaths and keyData must be specified, none of them present")
+	case !gotKeyPath && !gotKeyPaths && !gotKeyData && gotKeyFingerprint:
+		res, err = newPRSignedByKeyFingerprint(tmp.KeyType, tmp.KeyFingerprint, tmp.KeySource, tmp.SignedIdentity)
+	case !gotKeyPath && !gotKeyPaths && !gotKeyData && !gotKeyFingerprint:
+		return InvalidPolicyFormatError("Exactly one of keyPath, keyPaths, keyData and keyFingerprint must be specified, none of them present")
 	default:
-		return fmt.Errorf("Exactly one of keyPath, keyPaths and keyData must be specified, more than one present")
+		return fmt.Errorf("Exactly one of keyPath, keyPaths, keyData and keyFingerprint must be specified, more than one present")
 	}
 	if err != nil {
 		return err
 	}
+	res.SignedDigest = tmp.SignedDigest // Already validated by signedDigestScope.UnmarshalJSON
 	*pr = *res
 
 	return nil
 }
 
+// keyFingerprintRegexp matches OpenPGP v4 (40 hexadecimal digits) and v5/v6 (64 hexadecimal digits) key fingerprints.
+var keyFingerprintRegexp = regexp.Delayed(`^([0-9a-fA-F]{40}|[0-9a-fA-F]{64})$`)
+
+// validate returns an error if src is not a valid prSignedByKeySource.
+func (src *prSignedByKeySource) validate() error {
+	switch {
+	case src.WKD != "" && src.Keyserver != "":
+		return InvalidPolicyFormatError("exactly one of wkd and keyserver must be specified, both present")
+	case src.WKD != "":
+		local, domain, ok := strings.Cut(src.WKD, "@")
+		if !ok || local == "" || domain == "" || strings.ContainsAny(domain, "@/?#") {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid wkd email address %q", src.WKD))
+		}
+	case src.Keyserver != "":
+		u, err := url.Parse(src.Keyserver)
+		if err != nil {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid keyserver URL %q: %v", src.Keyserver, err))
+		}
+		if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid keyserver URL %q: an http or https URL is required", src.Keyserver))
+		}
+	default:
+		return InvalidPolicyFormatError("exactly one of wkd and keyserver must be specified, none of them present")
+	}
+	return nil
+}
+
+// Compile-time check that prSignedByKeySource implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prSignedByKeySource)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (src *prSignedByKeySource) UnmarshalJSON(data []byte) error {
+	*src = prSignedByKeySource{}
+	var tmp prSignedByKeySource
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "wkd":
+			return &tmp.WKD
+		case "keyserver":
+			return &tmp.Keyserver
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+	if err := tmp.validate(); err != nil {
+		return err
+	}
+	*src = tmp
+	return nil
+}
+
 // IsValid returns true iff kt is a recognized value
 func (kt sbKeyType) IsValid() bool {
 	switch kt {
@@ -472,6 +680,33 @@ func (kt *sbKeyType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// IsValid returns true if s is a known signed digest scope.
+func (s signedDigestScope) IsValid() bool {
+	switch s {
+	case SignedDigestInstance, SignedDigestIndex, SignedDigestEither:
+		return true
+	default:
+		return false
+	}
+}
+
+// Compile-time check that signedDigestScope implements json.Unmarshaler.
+var _ json.Unmarshaler = (*signedDigestScope)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *signedDigestScope) UnmarshalJSON(data []byte) error {
+	*s = signedDigestScope("")
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	if !signedDigestScope(str).IsValid() {
+		return InvalidPolicyFormatError(fmt.Sprintf("Unrecognized signedDigest value %q", str))
+	}
+	*s = signedDigestScope(str)
+	return nil
+}
+
 // newPRSignedBaseLayer is NewPRSignedBaseLayer, except it returns the private type.
 func newPRSignedBaseLayer(baseLayerIdentity PolicyReferenceMatch) (*prSignedBaseLayer, error) {
 	if baseLayerIdentity == nil {
@@ -519,6 +754,202 @@ func (pr *prSignedBaseLayer) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// newPRExternalEvaluator is NewPRExternalEvaluator, except it returns the private type.
+func newPRExternalEvaluator(command []string, timeoutSeconds int) (*prExternalEvaluator, error) {
+	if len(command) == 0 {
+		return nil, InvalidPolicyFormatError("command not specified")
+	}
+	if !filepath.IsAbs(command[0]) {
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("command %q is not an absolute path", command[0]))
+	}
+	if timeoutSeconds < 0 {
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid timeoutSeconds value %d", timeoutSeconds))
+	}
+	return &prExternalEvaluator{
+		prCommon:       prCommon{Type: prTypeExternalEvaluator},
+		Command:        slices.Clone(command),
+		TimeoutSeconds: timeoutSeconds,
+	}, nil
+}
+
+// NewPRExternalEvaluator returns a new "externalEvaluator" PolicyRequirement.
+// command[0] must be an absolute path to the evaluator executable; timeoutSeconds == 0 means the default timeout.
+func NewPRExternalEvaluator(command []string, timeoutSeconds int) (PolicyRequirement, error) {
+	return newPRExternalEvaluator(command, timeoutSeconds)
+}
+
+// Compile-time check that prExternalEvaluator implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prExternalEvaluator)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pr *prExternalEvaluator) UnmarshalJSON(data []byte) error {
+	*pr = prExternalEvaluator{}
+	var tmp prExternalEvaluator
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "type":
+			return &tmp.Type
+		case "command":
+			return &tmp.Command
+		case "timeoutSeconds":
+			return &tmp.TimeoutSeconds
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+
+	if tmp.Type != prTypeExternalEvaluator {
+		return InvalidPolicyFormatError(fmt.Sprintf("Unexpected policy requirement type %q", tmp.Type))
+	}
+	res, err := newPRExternalEvaluator(tmp.Command, tmp.TimeoutSeconds)
+	if err != nil {
+		return err
+	}
+	*pr = *res
+	return nil
+}
+
+// newPRVulnerabilityAttestation is NewPRVulnerabilityAttestationKeyPath / KeyPaths / KeyData, except it returns the private type.
+func newPRVulnerabilityAttestation(keyPath string, keyPaths []string, keyData []byte, maxSeverity vulnerabilitySeverity, maxScanAgeSeconds int) (*prVulnerabilityAttestation, error) {
+	keySources := 0
+	if keyPath != "" {
+		keySources++
+	}
+	if keyPaths != nil {
+		keySources++
+	}
+	if keyData != nil {
+		keySources++
+	}
+	if keySources != 1 {
+		return nil, InvalidPolicyFormatError("exactly one of keyPath, keyPaths and keyData must be specified")
+	}
+	if keyPaths != nil && len(keyPaths) == 0 {
+		return nil, InvalidPolicyFormatError("keyPaths must not be empty")
+	}
+	if !maxSeverity.IsValid() {
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid maxSeverity %q", maxSeverity))
+	}
+	if maxScanAgeSeconds < 0 {
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid maxScanAgeSeconds value %d", maxScanAgeSeconds))
+	}
+	return &prVulnerabilityAttestation{
+		prCommon:          prCommon{Type: prTypeVulnerabilityAttestation},
+		KeyPath:           keyPath,
+		KeyPaths:          slices.Clone(keyPaths),
+		KeyData:           keyData,
+		MaxSeverity:       maxSeverity,
+		MaxScanAgeSeconds: maxScanAgeSeconds,
+	}, nil
+}
+
+// NewPRVulnerabilityAttestationKeyPath returns a new "vulnerabilityAttestation" PolicyRequirement using a KeyPath.
+// maxScanAgeSeconds == 0 means that the age of the scan is not limited.
+func NewPRVulnerabilityAttestationKeyPath(keyPath string, maxSeverity vulnerabilitySeverity, maxScanAgeSeconds int) (PolicyRequirement, error) {
+	return newPRVulnerabilityAttestation(keyPath, nil, nil, maxSeverity, maxScanAgeSeconds)
+}
+
+// NewPRVulnerabilityAttestationKeyPaths returns a new "vulnerabilityAttestation" PolicyRequirement using KeyPaths.
+// maxScanAgeSeconds == 0 means that the age of the scan is not limited.
+func NewPRVulnerabilityAttestationKeyPaths(keyPaths []string, maxSeverity vulnerabilitySeverity, maxScanAgeSeconds int) (PolicyRequirement, error) {
+	return newPRVulnerabilityAttestation("", keyPaths, nil, maxSeverity, maxScanAgeSeconds)
+}
+
+// NewPRVulnerabilityAttestationKeyData returns a new "vulnerabilityAttestation" PolicyRequirement using a KeyData.
+// maxScanAgeSeconds == 0 means that the age of the scan is not limited.
+func NewPRVulnerabilityAttestationKeyData(keyData []byte, maxSeverity vulnerabilitySeverity, maxScanAgeSeconds int) (PolicyRequirement, error) {
+	return newPRVulnerabilityAttestation("", nil, keyData, maxSeverity, maxScanAgeSeconds)
+}
+
+// Compile-time check that prVulnerabilityAttestation implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prVulnerabilityAttestation)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pr *prVulnerabilityAttestation) UnmarshalJSON(data []byte) error {
+	*pr = prVulnerabilityAttestation{}
+	var tmp prVulnerabilityAttestation
+	var gotKeyPath, gotKeyPaths, gotKeyData = false, false, false
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "type":
+			return &tmp.Type
+		case "keyPath":
+			gotKeyPath = true
+			return &tmp.KeyPath
+		case "keyPaths":
+			gotKeyPaths = true
+			return &tmp.KeyPaths
+		case "keyData":
+			gotKeyData = true
+			return &tmp.KeyData
+		case "maxSeverity":
+			return &tmp.MaxSeverity
+		case "maxScanAgeSeconds":
+			return &tmp.MaxScanAgeSeconds
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+
+	if tmp.Type != prTypeVulnerabilityAttestation {
+		return InvalidPolicyFormatError(fmt.Sprintf("Unexpected policy requirement type %q", tmp.Type))
+	}
+	if tmp.MaxSeverity == "" {
+		return InvalidPolicyFormatError("maxSeverity not specified")
+	}
+	var res *prVulnerabilityAttestation
+	var err error
+	switch {
+	case gotKeyPath && !gotKeyPaths && !gotKeyData:
+		res, err = newPRVulnerabilityAttestation(tmp.KeyPath, nil, nil, tmp.MaxSeverity, tmp.MaxScanAgeSeconds)
+	case !gotKeyPath && gotKeyPaths && !gotKeyData:
+		res, err = newPRVulnerabilityAttestation("", tmp.KeyPaths, nil, tmp.MaxSeverity, tmp.MaxScanAgeSeconds)
+	case !gotKeyPath && !gotKeyPaths && gotKeyData:
+		res, err = newPRVulnerabilityAttestation("", nil, tmp.KeyData, tmp.MaxSeverity, tmp.MaxScanAgeSeconds)
+	case !gotKeyPath && !gotKeyPaths && !gotKeyData:
+		return InvalidPolicyFormatError("Exactly one of keyPath, keyPaths and keyData must be specified, none of them present")
+	default:
+		return InvalidPolicyFormatError("Exactly one of keyPath, keyPaths and keyData must be specified, more than one present")
+	}
+	if err != nil {
+		return err
+	}
+	*pr = *res
+	return nil
+}
+
+// IsValid returns true iff s is a recognized value
+func (s vulnerabilitySeverity) IsValid() bool {
+	switch s {
+	case VulnerabilitySeverityNone, VulnerabilitySeverityLow, VulnerabilitySeverityMedium,
+		VulnerabilitySeverityHigh, VulnerabilitySeverityCritical:
+		return true
+	default:
+		return false
+	}
+}
+
+// Compile-time check that vulnerabilitySeverity implements json.Unmarshaler.
+var _ json.Unmarshaler = (*vulnerabilitySeverity)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *vulnerabilitySeverity) UnmarshalJSON(data []byte) error {
+	*s = vulnerabilitySeverity("")
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	if !vulnerabilitySeverity(str).IsValid() {
+		return InvalidPolicyFormatError(fmt.Sprintf("Unrecognized maxSeverity value %q", str))
+	}
+	*s = vulnerabilitySeverity(str)
+	return nil
+}
+
 // newPolicyReferenceMatchFromJSON parses JSON data into a PolicyReferenceMatch implementation.
 func newPolicyReferenceMatchFromJSON(data []byte) (PolicyReferenceMatch, error) {
 	var typeField prmCommon
@@ -539,6 +970,8 @@ func newPolicyReferenceMatchFromJSON(data []byte) (PolicyReferenceMatch, error)
 		res = &prmExactRepository{}
 	case prmTypeRemapIdentity:
 		res = &prmRemapIdentity{}
+	case prmTypeMatchArtifact:
+		res = &prmMatchArtifact{}
 	default:
 		return nil, InvalidPolicyFormatError(fmt.Sprintf("Unknown policy reference match type %q", typeField.Type))
 	}
@@ -798,3 +1231,59 @@ func (prm *prmRemapIdentity) UnmarshalJSON(data []byte) error {
 	*prm = *res
 	return nil
 }
+
+// newPRMMatchArtifact is NewPRMMatchArtifact, except it returns the private type.
+func newPRMMatchArtifact(dockerRepository string) (*prmMatchArtifact, error) {
+	if dockerRepository != "" {
+		ref, err := reference.ParseNormalizedNamed(dockerRepository)
+		if err != nil {
+			return nil, InvalidPolicyFormatError(fmt.Sprintf("Invalid format of dockerRepository %q: %s", dockerRepository, err.Error()))
+		}
+		if !reference.IsNameOnly(ref) {
+			return nil, InvalidPolicyFormatError(fmt.Sprintf("dockerRepository %q contains a tag or digest", dockerRepository))
+		}
+	}
+	return &prmMatchArtifact{
+		prmCommon:        prmCommon{Type: prmTypeMatchArtifact},
+		DockerRepository: dockerRepository,
+	}, nil
+}
+
+// NewPRMMatchArtifact returns a new "matchArtifact" PolicyReferenceMatch.
+// dockerRepository, if not "", is the repository signatures must claim for images without a Docker reference identity,
+// e.g. artifacts referenced using a transport which does not record one.
+func NewPRMMatchArtifact(dockerRepository string) (PolicyReferenceMatch, error) {
+	return newPRMMatchArtifact(dockerRepository)
+}
+
+// Compile-time check that prmMatchArtifact implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prmMatchArtifact)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (prm *prmMatchArtifact) UnmarshalJSON(data []byte) error {
+	*prm = prmMatchArtifact{}
+	var tmp prmMatchArtifact
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "type":
+			return &tmp.Type
+		case "dockerRepository":
+			return &tmp.DockerRepository
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+
+	if tmp.Type != prmTypeMatchArtifact {
+		return InvalidPolicyFormatError(fmt.Sprintf("Unexpected policy requirement type %q", tmp.Type))
+	}
+
+	res, err := newPRMMatchArtifact(tmp.DockerRepository)
+	if err != nil {
+		return err
+	}
+	*prm = *res
+	return nil
+}
@@ -4,6 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/containers/image/v5/signature/internal"
 )
@@ -44,6 +49,20 @@ func PRSigstoreSignedWithFulcio(fulcio PRSigstoreSignedFulcio) PRSigstoreSignedO
 	}
 }
 
+// PRSigstoreSignedWithFulcios specifies a value for the "fulcios" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithFulcios(fulcios []PRSigstoreSignedFulcio) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.Fulcios != nil {
+			return errors.New(`"fulcios" already specified`)
+		}
+		if len(fulcios) == 0 {
+			return errors.New(`"fulcios" contains no entries`)
+		}
+		pr.Fulcios = fulcios
+		return nil
+	}
+}
+
 // PRSigstoreSignedWithRekorPublicKeyPath specifies a value for the "rekorPublicKeyPath" field when calling NewPRSigstoreSigned.
 func PRSigstoreSignedWithRekorPublicKeyPath(rekorPublicKeyPath string) PRSigstoreSignedOption {
 	return func(pr *prSigstoreSigned) error {
@@ -66,6 +85,89 @@ func PRSigstoreSignedWithRekorPublicKeyData(rekorPublicKeyData []byte) PRSigstor
 	}
 }
 
+// PRSigstoreSignedWithRekorPublicKeyPaths specifies a value for the "rekorPublicKeyPaths" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithRekorPublicKeyPaths(rekorPublicKeyPaths []string) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.RekorPublicKeyPaths != nil {
+			return errors.New(`"rekorPublicKeyPaths" already specified`)
+		}
+		if len(rekorPublicKeyPaths) == 0 {
+			return errors.New(`"rekorPublicKeyPaths" contains no entries`)
+		}
+		pr.RekorPublicKeyPaths = rekorPublicKeyPaths
+		return nil
+	}
+}
+
+// PRSigstoreSignedWithRekorPublicKeyDatas specifies a value for the "rekorPublicKeyDatas" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithRekorPublicKeyDatas(rekorPublicKeyDatas [][]byte) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.RekorPublicKeyDatas != nil {
+			return errors.New(`"rekorPublicKeyDatas" already specified`)
+		}
+		if len(rekorPublicKeyDatas) == 0 {
+			return errors.New(`"rekorPublicKeyDatas" contains no entries`)
+		}
+		pr.RekorPublicKeyDatas = rekorPublicKeyDatas
+		return nil
+	}
+}
+
+// PRSigstoreSignedWithTrustedRootPath specifies a value for the "trustedRootPath" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithTrustedRootPath(trustedRootPath string) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.TrustedRootPath != "" {
+			return errors.New(`"trustedRootPath" already specified`)
+		}
+		pr.TrustedRootPath = trustedRootPath
+		return nil
+	}
+}
+
+// PRSigstoreSignedWithTrustedRootData specifies a value for the "trustedRootData" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithTrustedRootData(trustedRootData []byte) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.TrustedRootData != nil {
+			return errors.New(`"trustedRootData" already specified`)
+		}
+		pr.TrustedRootData = trustedRootData
+		return nil
+	}
+}
+
+// PRSigstoreSignedWithTrustRoot specifies a value for the "trustRoot" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithTrustRoot(trustRoot PRSigstoreSignedTrustRoot) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.TrustRoot != nil {
+			return errors.New(`"trustRoot" already specified`)
+		}
+		pr.TrustRoot = trustRoot
+		return nil
+	}
+}
+
+// PRSigstoreSignedWithTSACertPath specifies a value for the "tsaCertPath" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithTSACertPath(tsaCertPath string) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.TSACertPath != "" {
+			return errors.New(`"tsaCertPath" already specified`)
+		}
+		pr.TSACertPath = tsaCertPath
+		return nil
+	}
+}
+
+// PRSigstoreSignedWithTSACertData specifies a value for the "tsaCertData" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithTSACertData(tsaCertData []byte) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.TSACertData != nil {
+			return errors.New(`"tsaCertData" already specified`)
+		}
+		pr.TSACertData = tsaCertData
+		return nil
+	}
+}
+
 // PRSigstoreSignedWithSignedIdentity specifies a value for the "signedIdentity" field when calling NewPRSigstoreSigned.
 func PRSigstoreSignedWithSignedIdentity(signedIdentity PolicyReferenceMatch) PRSigstoreSignedOption {
 	return func(pr *prSigstoreSigned) error {
@@ -77,6 +179,37 @@ func PRSigstoreSignedWithSignedIdentity(signedIdentity PolicyReferenceMatch) PRS
 	}
 }
 
+// PRSigstoreSignedWithSignedDigest specifies a value for the "signedDigest" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithSignedDigest(signedDigest signedDigestScope) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.SignedDigest != "" {
+			return errors.New(`"signedDigest" already specified`)
+		}
+		if !signedDigest.IsValid() {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid signedDigest %q", signedDigest))
+		}
+		pr.SignedDigest = signedDigest
+		return nil
+	}
+}
+
+// PRSigstoreSignedWithInTotoPredicateTypes specifies a value for the "inTotoPredicateTypes" field when calling NewPRSigstoreSigned.
+func PRSigstoreSignedWithInTotoPredicateTypes(inTotoPredicateTypes []string) PRSigstoreSignedOption {
+	return func(pr *prSigstoreSigned) error {
+		if pr.InTotoPredicateTypes != nil {
+			return errors.New(`"inTotoPredicateTypes" already specified`)
+		}
+		if len(inTotoPredicateTypes) == 0 {
+			return errors.New(`"inTotoPredicateTypes" contains no entries`)
+		}
+		if slices.Contains(inTotoPredicateTypes, "") {
+			return InvalidPolicyFormatError(`"inTotoPredicateTypes" contains an empty entry`)
+		}
+		pr.InTotoPredicateTypes = inTotoPredicateTypes
+		return nil
+	}
+}
+
 // newPRSigstoreSigned is NewPRSigstoreSigned, except it returns the private type.
 func newPRSigstoreSigned(options ...PRSigstoreSignedOption) (*prSigstoreSigned, error) {
 	res := prSigstoreSigned{
@@ -98,27 +231,91 @@ func newPRSigstoreSigned(options ...PRSigstoreSignedOption) (*prSigstoreSigned,
 	if res.Fulcio != nil {
 		keySources++
 	}
+	if res.Fulcios != nil {
+		keySources++
+	}
 
 	keySources += len(res.KeyPaths)
 
 	if keySources != 1 {
-		return nil, InvalidPolicyFormatError("exactly one of keyPath, keyData and fulcio must be specified")
+		return nil, InvalidPolicyFormatError("exactly one of keyPath, keyData, fulcio and fulcios must be specified")
 	}
 
-	if res.RekorPublicKeyPath != "" && res.RekorPublicKeyData != nil {
-		return nil, InvalidPolicyFormatError("rekorPublickeyType and rekorPublickeyData cannot be used simultaneously")
+	rekorSources := 0
+	if res.RekorPublicKeyPath != "" {
+		rekorSources++
+	}
+	if res.RekorPublicKeyData != nil {
+		rekorSources++
+	}
+	if res.RekorPublicKeyPaths != nil {
+		rekorSources++
+	}
+	if res.RekorPublicKeyDatas != nil {
+		rekorSources++
+	}
+	if res.TrustedRootPath != "" && res.TrustedRootData != nil {
+		return nil, InvalidPolicyFormatError("trustedRootPath and trustedRootData cannot be used simultaneously")
+	}
+	if res.TrustRoot != nil && (res.TrustedRootPath != "" || res.TrustedRootData != nil) {
+		return nil, InvalidPolicyFormatError("trustRoot cannot be used together with trustedRootPath or trustedRootData")
 	}
-	if res.Fulcio != nil && res.RekorPublicKeyPath == "" && res.RekorPublicKeyData == nil {
-		return nil, InvalidPolicyFormatError("At least one of RekorPublickeyPath and RekorPublickeyData must be specified if fulcio is used")
+	usesTrustedRoot := res.TrustedRootPath != "" || res.TrustedRootData != nil || res.TrustRoot != nil
+	if usesTrustedRoot {
+		rekorSources++
+	}
+	if rekorSources > 1 {
+		return nil, InvalidPolicyFormatError("at most one of rekorPublicKeyPath, rekorPublicKeyData, rekorPublicKeyPaths, rekorPublicKeyDatas and a trusted root can be used")
+	}
+	if res.TSACertPath != "" && res.TSACertData != nil {
+		return nil, InvalidPolicyFormatError("tsaCertPath and tsaCertData cannot be used simultaneously")
+	}
+	usesTSA := res.TSACertPath != "" || res.TSACertData != nil
+	if fulcios := res.fulcioRoots(); len(fulcios) != 0 {
+		if rekorSources == 0 && !usesTSA {
+			return nil, InvalidPolicyFormatError("One of rekorPublicKeyPath, rekorPublicKeyData, rekorPublicKeyPaths, rekorPublicKeyDatas, trustedRootPath, trustedRootData, trustRoot, tsaCertPath and tsaCertData must be specified if fulcio is used")
+		}
+		for i, fulcio := range fulcios {
+			if fulcio == nil {
+				return nil, InvalidPolicyFormatError(fmt.Sprintf("%s is not set", res.fulcioRootName(i)))
+			}
+			switch {
+			case usesTrustedRoot && fulcio.specifiesCA():
+				return nil, InvalidPolicyFormatError(fmt.Sprintf("%s caPath and caData cannot be used together with a trusted root", res.fulcioRootName(i)))
+			case !usesTrustedRoot && !fulcio.specifiesCA():
+				return nil, InvalidPolicyFormatError(fmt.Sprintf("One of %s caPath and caData must be specified if a trusted root is not used", res.fulcioRootName(i)))
+			}
+		}
 	}
 
 	if res.SignedIdentity == nil {
 		return nil, InvalidPolicyFormatError("signedIdentity not specified")
 	}
+	if res.InTotoPredicateTypes != nil {
+		if _, ok := res.SignedIdentity.(*prmMatchRepoDigestOrExact); !ok {
+			return nil, InvalidPolicyFormatError("inTotoPredicateTypes can only be used with the matchRepoDigestOrExact signedIdentity")
+		}
+	}
 
 	return &res, nil
 }
 
+// fulcioRoots returns the Fulcio configurations of pr, from either Fulcio or Fulcios.
+func (pr *prSigstoreSigned) fulcioRoots() []PRSigstoreSignedFulcio {
+	if pr.Fulcio != nil {
+		return []PRSigstoreSignedFulcio{pr.Fulcio}
+	}
+	return pr.Fulcios
+}
+
+// fulcioRootName returns a name of the i-th element of pr.fulcioRoots(), for use in error messages.
+func (pr *prSigstoreSigned) fulcioRootName(i int) string {
+	if pr.Fulcio != nil {
+		return "fulcio"
+	}
+	return fmt.Sprintf("fulcios[%d]", i)
+}
+
 // NewPRSigstoreSigned returns a new "sigstoreSigned" PolicyRequirement based on options.
 func NewPRSigstoreSigned(options ...PRSigstoreSignedOption) (PolicyRequirement, error) {
 	return newPRSigstoreSigned(options...)
@@ -147,8 +344,11 @@ var _ json.Unmarshaler = (*prSigstoreSigned)(nil)
 func (pr *prSigstoreSigned) UnmarshalJSON(data []byte) error {
 	*pr = prSigstoreSigned{}
 	var tmp prSigstoreSigned
-	var gotKeyPath, gotKeyData, gotFulcio, gotRekorPublicKeyPath, gotRekorPublicKeyData bool
+	var gotKeyPath, gotKeyData, gotFulcio, gotFulcios, gotRekorPublicKeyPath, gotRekorPublicKeyData, gotRekorPublicKeyPaths, gotRekorPublicKeyDatas bool
+	var gotTrustedRootPath, gotTrustedRootData, gotTrustRoot, gotTSACertPath, gotTSACertData, gotSignedDigest, gotInTotoPredicateTypes bool
 	var fulcio prSigstoreSignedFulcio
+	var fulcios []*prSigstoreSignedFulcio
+	var trustRoot prSigstoreSignedTrustRoot
 	var signedIdentity json.RawMessage
 	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
 		switch key {
@@ -163,14 +363,44 @@ func (pr *prSigstoreSigned) UnmarshalJSON(data []byte) error {
 		case "fulcio":
 			gotFulcio = true
 			return &fulcio
+		case "fulcios":
+			gotFulcios = true
+			return &fulcios
 		case "rekorPublicKeyPath":
 			gotRekorPublicKeyPath = true
 			return &tmp.RekorPublicKeyPath
 		case "rekorPublicKeyData":
 			gotRekorPublicKeyData = true
 			return &tmp.RekorPublicKeyData
+		case "rekorPublicKeyPaths":
+			gotRekorPublicKeyPaths = true
+			return &tmp.RekorPublicKeyPaths
+		case "rekorPublicKeyDatas":
+			gotRekorPublicKeyDatas = true
+			return &tmp.RekorPublicKeyDatas
+		case "trustedRootPath":
+			gotTrustedRootPath = true
+			return &tmp.TrustedRootPath
+		case "trustedRootData":
+			gotTrustedRootData = true
+			return &tmp.TrustedRootData
+		case "trustRoot":
+			gotTrustRoot = true
+			return &trustRoot
+		case "tsaCertPath":
+			gotTSACertPath = true
+			return &tmp.TSACertPath
+		case "tsaCertData":
+			gotTSACertData = true
+			return &tmp.TSACertData
 		case "signedIdentity":
 			return &signedIdentity
+		case "signedDigest":
+			gotSignedDigest = true
+			return &tmp.SignedDigest
+		case "inTotoPredicateTypes":
+			gotInTotoPredicateTypes = true
+			return &tmp.InTotoPredicateTypes
 		default:
 			return nil
 		}
@@ -201,13 +431,50 @@ func (pr *prSigstoreSigned) UnmarshalJSON(data []byte) error {
 	if gotFulcio {
 		opts = append(opts, PRSigstoreSignedWithFulcio(&fulcio))
 	}
+	if gotFulcios {
+		var roots []PRSigstoreSignedFulcio
+		for i, f := range fulcios {
+			if f == nil {
+				return InvalidPolicyFormatError(fmt.Sprintf("fulcios[%d] must not be null", i))
+			}
+			roots = append(roots, f)
+		}
+		opts = append(opts, PRSigstoreSignedWithFulcios(roots))
+	}
 	if gotRekorPublicKeyPath {
 		opts = append(opts, PRSigstoreSignedWithRekorPublicKeyPath(tmp.RekorPublicKeyPath))
 	}
 	if gotRekorPublicKeyData {
 		opts = append(opts, PRSigstoreSignedWithRekorPublicKeyData(tmp.RekorPublicKeyData))
 	}
+	if gotRekorPublicKeyPaths {
+		opts = append(opts, PRSigstoreSignedWithRekorPublicKeyPaths(tmp.RekorPublicKeyPaths))
+	}
+	if gotRekorPublicKeyDatas {
+		opts = append(opts, PRSigstoreSignedWithRekorPublicKeyDatas(tmp.RekorPublicKeyDatas))
+	}
+	if gotTrustedRootPath {
+		opts = append(opts, PRSigstoreSignedWithTrustedRootPath(tmp.TrustedRootPath))
+	}
+	if gotTrustedRootData {
+		opts = append(opts, PRSigstoreSignedWithTrustedRootData(tmp.TrustedRootData))
+	}
+	if gotTrustRoot {
+		opts = append(opts, PRSigstoreSignedWithTrustRoot(&trustRoot))
+	}
+	if gotTSACertPath {
+		opts = append(opts, PRSigstoreSignedWithTSACertPath(tmp.TSACertPath))
+	}
+	if gotTSACertData {
+		opts = append(opts, PRSigstoreSignedWithTSACertData(tmp.TSACertData))
+	}
 	opts = append(opts, PRSigstoreSignedWithSignedIdentity(tmp.SignedIdentity))
+	if gotSignedDigest {
+		opts = append(opts, PRSigstoreSignedWithSignedDigest(tmp.SignedDigest))
+	}
+	if gotInTotoPredicateTypes {
+		opts = append(opts, PRSigstoreSignedWithInTotoPredicateTypes(tmp.InTotoPredicateTypes))
+	}
 
 	res, err := newPRSigstoreSigned(opts...)
 	if err != nil {
@@ -253,6 +520,17 @@ func PRSigstoreSignedFulcioWithOIDCIssuer(oidcIssuer string) PRSigstoreSignedFul
 	}
 }
 
+// PRSigstoreSignedFulcioWithOIDCIssuerRegex specifies a value for the "oidcIssuerRegex" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithOIDCIssuerRegex(oidcIssuerRegex string) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.OIDCIssuerRegex != "" {
+			return errors.New(`"oidcIssuerRegex" already specified`)
+		}
+		f.OIDCIssuerRegex = oidcIssuerRegex
+		return nil
+	}
+}
+
 // PRSigstoreSignedFulcioWithSubjectEmail specifies a value for the "subjectEmail" field when calling NewPRSigstoreSignedFulcio
 func PRSigstoreSignedFulcioWithSubjectEmail(subjectEmail string) PRSigstoreSignedFulcioOption {
 	return func(f *prSigstoreSignedFulcio) error {
@@ -264,6 +542,161 @@ func PRSigstoreSignedFulcioWithSubjectEmail(subjectEmail string) PRSigstoreSigne
 	}
 }
 
+// PRSigstoreSignedFulcioWithSubjectEmailRegex specifies a value for the "subjectEmailRegex" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithSubjectEmailRegex(subjectEmailRegex string) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.SubjectEmailRegex != "" {
+			return errors.New(`"subjectEmailRegex" already specified`)
+		}
+		f.SubjectEmailRegex = subjectEmailRegex
+		return nil
+	}
+}
+
+// PRSigstoreSignedFulcioWithSubjectURI specifies a value for the "subjectURI" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithSubjectURI(subjectURI string) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.SubjectURI != "" {
+			return errors.New(`"subjectURI" already specified`)
+		}
+		f.SubjectURI = subjectURI
+		return nil
+	}
+}
+
+// PRSigstoreSignedFulcioWithSubjectURIRegex specifies a value for the "subjectURIRegex" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithSubjectURIRegex(subjectURIRegex string) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.SubjectURIRegex != "" {
+			return errors.New(`"subjectURIRegex" already specified`)
+		}
+		f.SubjectURIRegex = subjectURIRegex
+		return nil
+	}
+}
+
+// PRSigstoreSignedFulcioWithGitHubActionsIdentity specifies a "ciIdentity" value requiring a build by a GitHub Actions workflow
+// when calling NewPRSigstoreSignedFulcio.
+// repository is "owner/repository", workflow is the path of the workflow file within the repository
+// (e.g. ".github/workflows/release.yml"), and ref, if not "", is the Git reference the workflow ran from (e.g. "refs/heads/main").
+func PRSigstoreSignedFulcioWithGitHubActionsIdentity(repository, workflow, ref string) PRSigstoreSignedFulcioOption {
+	return prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{
+		Provider:   FulcioCIProviderGitHubActions,
+		Repository: repository,
+		Workflow:   workflow,
+		Ref:        ref,
+	})
+}
+
+// PRSigstoreSignedFulcioGitHubActionsIdentity describes a GitHub Actions workflow run,
+// for PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity.
+type PRSigstoreSignedFulcioGitHubActionsIdentity struct {
+	// Repository is "owner/repository"; it must be set.
+	Repository string
+	// Workflow is the path of the workflow file within the repository (e.g. ".github/workflows/release.yml"); it must be set.
+	Workflow string
+	// Ref, if not "", is the Git reference the workflow ran from (e.g. "refs/heads/main").
+	Ref string
+	// RefPrefix, if not "", is a prefix of the Git reference the workflow ran from (e.g. "refs/tags/"). It can’t be combined with Ref.
+	RefPrefix string
+	// Trigger, if not "", is the event which triggered the workflow (e.g. "push" or "release").
+	Trigger string
+	// SHA, if not "", is the Git commit the workflow ran on.
+	SHA string
+}
+
+// PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity specifies a "ciIdentity" value requiring a build by the GitHub Actions
+// workflow run described by id when calling NewPRSigstoreSignedFulcio.
+// Unlike PRSigstoreSignedFulcioWithGitHubActionsIdentity, this can also restrict the trigger and commit of the workflow run.
+func PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(id PRSigstoreSignedFulcioGitHubActionsIdentity) PRSigstoreSignedFulcioOption {
+	return prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{
+		Provider:   FulcioCIProviderGitHubActions,
+		Repository: id.Repository,
+		Workflow:   id.Workflow,
+		Ref:        id.Ref,
+		RefPrefix:  id.RefPrefix,
+		Trigger:    id.Trigger,
+		SHA:        id.SHA,
+	})
+}
+
+// PRSigstoreSignedFulcioWithGitLabCIIdentity specifies a "ciIdentity" value requiring a build by a GitLab CI pipeline on gitlab.com
+// when calling NewPRSigstoreSignedFulcio.
+// project is the project path (e.g. "group/project"), ciConfigPath is the path of the CI configuration file
+// (".gitlab-ci.yml" if ""), and ref, if not "", is the Git reference the pipeline ran from (e.g. "refs/heads/main").
+func PRSigstoreSignedFulcioWithGitLabCIIdentity(project, ciConfigPath, ref string) PRSigstoreSignedFulcioOption {
+	return prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{
+		Provider:   FulcioCIProviderGitLabCI,
+		Repository: project,
+		Workflow:   ciConfigPath,
+		Ref:        ref,
+	})
+}
+
+// PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity specifies a "ciIdentity" value requiring a build by Google Cloud Build
+// in the project with projectNumber, using the default Cloud Build service account, when calling NewPRSigstoreSignedFulcio.
+func PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity(projectNumber string) PRSigstoreSignedFulcioOption {
+	return prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{
+		Provider:      FulcioCIProviderGoogleCloudBuild,
+		ProjectNumber: projectNumber,
+	})
+}
+
+// prSigstoreSignedFulcioWithCIIdentity specifies a value for the "ciIdentity" field when calling NewPRSigstoreSignedFulcio
+func prSigstoreSignedFulcioWithCIIdentity(ciIdentity prSigstoreSignedFulcioCIIdentity) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.CIIdentity != nil {
+			return errors.New(`"ciIdentity" already specified`)
+		}
+		f.CIIdentity = &ciIdentity
+		return nil
+	}
+}
+
+// PRSigstoreSignedFulcioWithIntegratedTimeCheck specifies a value for the "integratedTimeCheck" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithIntegratedTimeCheck(check string) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.IntegratedTimeCheck != "" {
+			return errors.New(`"integratedTimeCheck" already specified`)
+		}
+		f.IntegratedTimeCheck = check
+		return nil
+	}
+}
+
+// PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds specifies a value for the "integratedTimeClockSkewSeconds" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(seconds int64) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.IntegratedTimeClockSkewSeconds != 0 {
+			return errors.New(`"integratedTimeClockSkewSeconds" already specified`)
+		}
+		f.IntegratedTimeClockSkewSeconds = seconds
+		return nil
+	}
+}
+
+// PRSigstoreSignedFulcioWithIssuedAfter specifies a value for the "issuedAfter" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithIssuedAfter(issuedAfter time.Time) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.IssuedAfter != nil {
+			return errors.New(`"issuedAfter" already specified`)
+		}
+		f.IssuedAfter = &issuedAfter
+		return nil
+	}
+}
+
+// PRSigstoreSignedFulcioWithIssuedBefore specifies a value for the "issuedBefore" field when calling NewPRSigstoreSignedFulcio
+func PRSigstoreSignedFulcioWithIssuedBefore(issuedBefore time.Time) PRSigstoreSignedFulcioOption {
+	return func(f *prSigstoreSignedFulcio) error {
+		if f.IssuedBefore != nil {
+			return errors.New(`"issuedBefore" already specified`)
+		}
+		f.IssuedBefore = &issuedBefore
+		return nil
+	}
+}
+
 // newPRSigstoreSignedFulcio is NewPRSigstoreSignedFulcio, except it returns the private type
 func newPRSigstoreSignedFulcio(options ...PRSigstoreSignedFulcioOption) (*prSigstoreSignedFulcio, error) {
 	res := prSigstoreSignedFulcio{}
@@ -276,19 +709,80 @@ func newPRSigstoreSignedFulcio(options ...PRSigstoreSignedFulcioOption) (*prSigs
 	if res.CAPath != "" && res.CAData != nil {
 		return nil, InvalidPolicyFormatError("caPath and caData cannot be used simultaneously")
 	}
-	if res.CAPath == "" && res.CAData == nil {
-		return nil, InvalidPolicyFormatError("At least one of caPath and caData must be specified")
+	// Whether caPath or caData is required depends on the use of a trusted root; that is checked in newPRSigstoreSigned.
+	subjects := 0
+	for _, s := range []string{res.SubjectEmail, res.SubjectEmailRegex, res.SubjectURI, res.SubjectURIRegex} {
+		if s != "" {
+			subjects++
+		}
 	}
-	if res.OIDCIssuer == "" {
-		return nil, InvalidPolicyFormatError("oidcIssuer not specified")
+	if res.CIIdentity != nil {
+		if res.OIDCIssuer != "" || res.OIDCIssuerRegex != "" || subjects != 0 {
+			return nil, InvalidPolicyFormatError("ciIdentity cannot be used together with oidcIssuer, oidcIssuerRegex or the subject fields")
+		}
+		if err := res.CIIdentity.validate(); err != nil {
+			return nil, err
+		}
+	} else {
+		switch {
+		case res.OIDCIssuer != "" && res.OIDCIssuerRegex != "":
+			return nil, InvalidPolicyFormatError("oidcIssuer and oidcIssuerRegex cannot be used simultaneously")
+		case res.OIDCIssuer == "" && res.OIDCIssuerRegex == "":
+			return nil, InvalidPolicyFormatError("oidcIssuer not specified")
+		}
+		switch {
+		case subjects > 1:
+			return nil, InvalidPolicyFormatError("at most one of subjectEmail, subjectEmailRegex, subjectURI and subjectURIRegex can be used")
+		case subjects == 0:
+			return nil, InvalidPolicyFormatError("one of subjectEmail, subjectEmailRegex, subjectURI and subjectURIRegex must be specified")
+		}
+		if res.SubjectURI != "" {
+			if u, err := url.Parse(res.SubjectURI); err != nil || !u.IsAbs() {
+				return nil, InvalidPolicyFormatError(fmt.Sprintf("subjectURI %q is not an absolute URI", res.SubjectURI))
+			}
+		}
+		if _, err := compileFulcioIdentityRegex("oidcIssuerRegex", res.OIDCIssuerRegex); err != nil {
+			return nil, err
+		}
+		if _, err := compileFulcioIdentityRegex("subjectEmailRegex", res.SubjectEmailRegex); err != nil {
+			return nil, err
+		}
+		if _, err := compileFulcioIdentityRegex("subjectURIRegex", res.SubjectURIRegex); err != nil {
+			return nil, err
+		}
+	}
+	switch res.IntegratedTimeCheck {
+	case "", IntegratedTimeCheckWithinValidity:
+	case IntegratedTimeCheckIgnore:
+		if res.IntegratedTimeClockSkewSeconds != 0 {
+			return nil, InvalidPolicyFormatError(fmt.Sprintf("integratedTimeClockSkewSeconds can not be used with integratedTimeCheck %q", res.IntegratedTimeCheck))
+		}
+	default:
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("unknown integratedTimeCheck value %q", res.IntegratedTimeCheck))
 	}
-	if res.SubjectEmail == "" {
-		return nil, InvalidPolicyFormatError("subjectEmail not specified")
+	if res.IntegratedTimeClockSkewSeconds < 0 {
+		return nil, InvalidPolicyFormatError("integratedTimeClockSkewSeconds must not be negative")
+	}
+	if res.IssuedAfter != nil && res.IssuedBefore != nil && !res.IssuedAfter.Before(*res.IssuedBefore) {
+		return nil, InvalidPolicyFormatError("issuedAfter must be before issuedBefore")
 	}
 
 	return &res, nil
 }
 
+// compileFulcioIdentityRegex compiles expr, the value of field, so that it must match the whole input.
+// It returns nil if expr is "".
+func compileFulcioIdentityRegex(field, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid %s %q: %v", field, expr, err))
+	}
+	return re, nil
+}
+
 // NewPRSigstoreSignedFulcio returns a PRSigstoreSignedFulcio based on options.
 func NewPRSigstoreSignedFulcio(options ...PRSigstoreSignedFulcioOption) (PRSigstoreSignedFulcio, error) {
 	return newPRSigstoreSignedFulcio(options...)
@@ -300,7 +794,7 @@ var _ json.Unmarshaler = (*prSigstoreSignedFulcio)(nil)
 func (f *prSigstoreSignedFulcio) UnmarshalJSON(data []byte) error {
 	*f = prSigstoreSignedFulcio{}
 	var tmp prSigstoreSignedFulcio
-	var gotCAPath, gotCAData, gotOIDCIssuer, gotSubjectEmail bool // = false...
+	var gotCAPath, gotCAData, gotOIDCIssuer, gotOIDCIssuerRegex, gotSubjectEmail, gotSubjectEmailRegex, gotSubjectURI, gotSubjectURIRegex, gotCIIdentity, gotIntegratedTimeCheck, gotIntegratedTimeClockSkewSeconds, gotIssuedAfter, gotIssuedBefore bool // = false...
 	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
 		switch key {
 		case "caPath":
@@ -312,9 +806,36 @@ func (f *prSigstoreSignedFulcio) UnmarshalJSON(data []byte) error {
 		case "oidcIssuer":
 			gotOIDCIssuer = true
 			return &tmp.OIDCIssuer
+		case "oidcIssuerRegex":
+			gotOIDCIssuerRegex = true
+			return &tmp.OIDCIssuerRegex
 		case "subjectEmail":
 			gotSubjectEmail = true
 			return &tmp.SubjectEmail
+		case "subjectEmailRegex":
+			gotSubjectEmailRegex = true
+			return &tmp.SubjectEmailRegex
+		case "subjectURI":
+			gotSubjectURI = true
+			return &tmp.SubjectURI
+		case "subjectURIRegex":
+			gotSubjectURIRegex = true
+			return &tmp.SubjectURIRegex
+		case "ciIdentity":
+			gotCIIdentity = true
+			return &tmp.CIIdentity
+		case "integratedTimeCheck":
+			gotIntegratedTimeCheck = true
+			return &tmp.IntegratedTimeCheck
+		case "integratedTimeClockSkewSeconds":
+			gotIntegratedTimeClockSkewSeconds = true
+			return &tmp.IntegratedTimeClockSkewSeconds
+		case "issuedAfter":
+			gotIssuedAfter = true
+			return &tmp.IssuedAfter
+		case "issuedBefore":
+			gotIssuedBefore = true
+			return &tmp.IssuedBefore
 		default:
 			return nil
 		}
@@ -332,9 +853,45 @@ func (f *prSigstoreSignedFulcio) UnmarshalJSON(data []byte) error {
 	if gotOIDCIssuer {
 		opts = append(opts, PRSigstoreSignedFulcioWithOIDCIssuer(tmp.OIDCIssuer))
 	}
+	if gotOIDCIssuerRegex {
+		opts = append(opts, PRSigstoreSignedFulcioWithOIDCIssuerRegex(tmp.OIDCIssuerRegex))
+	}
 	if gotSubjectEmail {
 		opts = append(opts, PRSigstoreSignedFulcioWithSubjectEmail(tmp.SubjectEmail))
 	}
+	if gotSubjectEmailRegex {
+		opts = append(opts, PRSigstoreSignedFulcioWithSubjectEmailRegex(tmp.SubjectEmailRegex))
+	}
+	if gotSubjectURI {
+		opts = append(opts, PRSigstoreSignedFulcioWithSubjectURI(tmp.SubjectURI))
+	}
+	if gotSubjectURIRegex {
+		opts = append(opts, PRSigstoreSignedFulcioWithSubjectURIRegex(tmp.SubjectURIRegex))
+	}
+	if gotCIIdentity {
+		if tmp.CIIdentity == nil {
+			return InvalidPolicyFormatError("ciIdentity must not be null")
+		}
+		opts = append(opts, prSigstoreSignedFulcioWithCIIdentity(*tmp.CIIdentity))
+	}
+	if gotIntegratedTimeCheck {
+		opts = append(opts, PRSigstoreSignedFulcioWithIntegratedTimeCheck(tmp.IntegratedTimeCheck))
+	}
+	if gotIntegratedTimeClockSkewSeconds {
+		opts = append(opts, PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(tmp.IntegratedTimeClockSkewSeconds))
+	}
+	if gotIssuedAfter {
+		if tmp.IssuedAfter == nil {
+			return InvalidPolicyFormatError("issuedAfter must not be null")
+		}
+		opts = append(opts, PRSigstoreSignedFulcioWithIssuedAfter(*tmp.IssuedAfter))
+	}
+	if gotIssuedBefore {
+		if tmp.IssuedBefore == nil {
+			return InvalidPolicyFormatError("issuedBefore must not be null")
+		}
+		opts = append(opts, PRSigstoreSignedFulcioWithIssuedBefore(*tmp.IssuedBefore))
+	}
 
 	res, err := newPRSigstoreSignedFulcio(opts...)
 	if err != nil {
@@ -344,3 +901,269 @@ func (f *prSigstoreSignedFulcio) UnmarshalJSON(data []byte) error {
 	*f = *res
 	return nil
 }
+
+// Compile-time check that prSigstoreSignedFulcioCIIdentity implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prSigstoreSignedFulcioCIIdentity)(nil)
+
+func (id *prSigstoreSignedFulcioCIIdentity) UnmarshalJSON(data []byte) error {
+	*id = prSigstoreSignedFulcioCIIdentity{}
+	var tmp prSigstoreSignedFulcioCIIdentity
+	var gotProvider bool // = false
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "provider":
+			gotProvider = true
+			return &tmp.Provider
+		case "repository":
+			return &tmp.Repository
+		case "workflow":
+			return &tmp.Workflow
+		case "ref":
+			return &tmp.Ref
+		case "refPrefix":
+			return &tmp.RefPrefix
+		case "trigger":
+			return &tmp.Trigger
+		case "sha":
+			return &tmp.SHA
+		case "projectNumber":
+			return &tmp.ProjectNumber
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+	if !gotProvider {
+		return InvalidPolicyFormatError("provider not specified in ciIdentity")
+	}
+	*id = tmp
+	return nil
+}
+
+// validate returns an error if id is not a valid ciIdentity value.
+func (id *prSigstoreSignedFulcioCIIdentity) validate() error {
+	// The values are used to build the expected subject; reject anything which could make it ambiguous.
+	if strings.ContainsAny(id.Workflow, "@") || strings.HasPrefix(id.Workflow, "/") || strings.ContainsAny(id.Ref, "@") ||
+		strings.ContainsAny(id.RefPrefix, "@") {
+		return InvalidPolicyFormatError(fmt.Sprintf("invalid ciIdentity workflow %q, ref %q or refPrefix %q", id.Workflow, id.Ref, id.RefPrefix))
+	}
+	if id.Ref != "" && id.RefPrefix != "" {
+		return InvalidPolicyFormatError("ref and refPrefix cannot be used together in ciIdentity")
+	}
+	if id.Provider != FulcioCIProviderGitHubActions && (id.Trigger != "" || id.SHA != "") {
+		return InvalidPolicyFormatError(fmt.Sprintf("trigger and sha can not be used with ciIdentity provider %q", id.Provider))
+	}
+	switch id.Provider {
+	case FulcioCIProviderGitHubActions:
+		if owner, repo, ok := strings.Cut(id.Repository, "/"); !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			return InvalidPolicyFormatError(fmt.Sprintf(`ciIdentity repository %q is not of the form "owner/repository"`, id.Repository))
+		}
+		if id.Workflow == "" {
+			return InvalidPolicyFormatError("workflow not specified in ciIdentity")
+		}
+		if id.SHA != "" && ((len(id.SHA) != 40 && len(id.SHA) != 64) || strings.Trim(id.SHA, "0123456789abcdef") != "") {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid ciIdentity sha %q", id.SHA))
+		}
+		if id.ProjectNumber != "" {
+			return InvalidPolicyFormatError(fmt.Sprintf("projectNumber can not be used with ciIdentity provider %q", id.Provider))
+		}
+	case FulcioCIProviderGitLabCI:
+		if group, project, ok := strings.Cut(id.Repository, "/"); !ok || group == "" || project == "" || strings.Contains(id.Repository, "//") ||
+			strings.HasSuffix(id.Repository, "/") {
+			return InvalidPolicyFormatError(fmt.Sprintf(`ciIdentity repository %q is not of the form "group/project"`, id.Repository))
+		}
+		if id.ProjectNumber != "" {
+			return InvalidPolicyFormatError(fmt.Sprintf("projectNumber can not be used with ciIdentity provider %q", id.Provider))
+		}
+	case FulcioCIProviderGoogleCloudBuild:
+		if id.ProjectNumber == "" || strings.Trim(id.ProjectNumber, "0123456789") != "" {
+			return InvalidPolicyFormatError(fmt.Sprintf("invalid ciIdentity projectNumber %q", id.ProjectNumber))
+		}
+		if id.Repository != "" || id.Workflow != "" || id.Ref != "" || id.RefPrefix != "" {
+			return InvalidPolicyFormatError(fmt.Sprintf("repository, workflow, ref and refPrefix can not be used with ciIdentity provider %q", id.Provider))
+		}
+	default:
+		return InvalidPolicyFormatError(fmt.Sprintf("unknown ciIdentity provider %q", id.Provider))
+	}
+	return nil
+}
+
+// fulcioCIIdentity is the OIDC issuer, subject and other values recorded by Fulcio for a prSigstoreSignedFulcioCIIdentity.
+// Exactly one of subjectEmail, subjectURI and subjectURIPrefix is set.
+type fulcioCIIdentity struct {
+	oidcIssuer            string
+	subjectEmail          string
+	subjectURI            string
+	subjectURIPrefix      string
+	githubWorkflowTrigger string
+	githubWorkflowSHA     string
+}
+
+// expectedIdentity returns the OIDC issuer and subject recorded by Fulcio in certificates issued to builds matching id.
+// id must have been validated.
+func (id *prSigstoreSignedFulcioCIIdentity) expectedIdentity() (fulcioCIIdentity, error) {
+	var res fulcioCIIdentity
+	var uri string
+	switch id.Provider {
+	case FulcioCIProviderGitHubActions:
+		// The subject is the job_workflow_ref claim.
+		res.oidcIssuer = "https://token.actions.githubusercontent.com"
+		uri = "https://github.com/" + id.Repository + "/" + id.Workflow + "@"
+		res.githubWorkflowTrigger = id.Trigger
+		res.githubWorkflowSHA = id.SHA
+	case FulcioCIProviderGitLabCI:
+		// The subject is the ci_config_ref_uri claim.
+		res.oidcIssuer = "https://gitlab.com"
+		workflow := id.Workflow
+		if workflow == "" {
+			workflow = ".gitlab-ci.yml"
+		}
+		uri = "https://gitlab.com/" + id.Repository + "//" + workflow + "@"
+	case FulcioCIProviderGoogleCloudBuild:
+		res.oidcIssuer = "https://accounts.google.com"
+		res.subjectEmail = id.ProjectNumber + "@cloudbuild.gserviceaccount.com"
+		return res, nil
+	default: // This should have been rejected by validate.
+		return fulcioCIIdentity{}, fmt.Errorf("Internal inconsistency: unknown ciIdentity provider %q", id.Provider)
+	}
+	if id.Ref != "" {
+		res.subjectURI = uri + id.Ref
+	} else {
+		res.subjectURIPrefix = uri + id.RefPrefix
+	}
+	return res, nil
+}
+
+// PRSigstoreSignedTrustRootOption is a way to pass values to NewPRSigstoreSignedTrustRoot
+type PRSigstoreSignedTrustRootOption func(*prSigstoreSignedTrustRoot) error
+
+// PRSigstoreSignedTrustRootWithMirror specifies a value for the "mirror" field when calling NewPRSigstoreSignedTrustRoot
+func PRSigstoreSignedTrustRootWithMirror(mirror string) PRSigstoreSignedTrustRootOption {
+	return func(tr *prSigstoreSignedTrustRoot) error {
+		if tr.Mirror != "" {
+			return errors.New(`"mirror" already specified`)
+		}
+		tr.Mirror = mirror
+		return nil
+	}
+}
+
+// PRSigstoreSignedTrustRootWithRootPath specifies a value for the "rootPath" field when calling NewPRSigstoreSignedTrustRoot
+func PRSigstoreSignedTrustRootWithRootPath(rootPath string) PRSigstoreSignedTrustRootOption {
+	return func(tr *prSigstoreSignedTrustRoot) error {
+		if tr.RootPath != "" {
+			return errors.New(`"rootPath" already specified`)
+		}
+		tr.RootPath = rootPath
+		return nil
+	}
+}
+
+// PRSigstoreSignedTrustRootWithRootData specifies a value for the "rootData" field when calling NewPRSigstoreSignedTrustRoot
+func PRSigstoreSignedTrustRootWithRootData(rootData []byte) PRSigstoreSignedTrustRootOption {
+	return func(tr *prSigstoreSignedTrustRoot) error {
+		if tr.RootData != nil {
+			return errors.New(`"rootData" already specified`)
+		}
+		tr.RootData = rootData
+		return nil
+	}
+}
+
+// PRSigstoreSignedTrustRootWithCachePath specifies a value for the "cachePath" field when calling NewPRSigstoreSignedTrustRoot
+func PRSigstoreSignedTrustRootWithCachePath(cachePath string) PRSigstoreSignedTrustRootOption {
+	return func(tr *prSigstoreSignedTrustRoot) error {
+		if tr.CachePath != "" {
+			return errors.New(`"cachePath" already specified`)
+		}
+		tr.CachePath = cachePath
+		return nil
+	}
+}
+
+// newPRSigstoreSignedTrustRoot is NewPRSigstoreSignedTrustRoot, except it returns the private type
+func newPRSigstoreSignedTrustRoot(options ...PRSigstoreSignedTrustRootOption) (*prSigstoreSignedTrustRoot, error) {
+	res := prSigstoreSignedTrustRoot{}
+	for _, o := range options {
+		if err := o(&res); err != nil {
+			return nil, err
+		}
+	}
+
+	if res.RootPath != "" && res.RootData != nil {
+		return nil, InvalidPolicyFormatError("rootPath and rootData cannot be used simultaneously")
+	}
+	if res.Mirror != "" {
+		u, err := url.Parse(res.Mirror)
+		if err != nil {
+			return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid mirror URL %q: %v", res.Mirror, err))
+		}
+		if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, InvalidPolicyFormatError(fmt.Sprintf("invalid mirror URL %q: an http or https URL is required", res.Mirror))
+		}
+	} else if res.CachePath == "" {
+		return nil, InvalidPolicyFormatError("At least one of mirror and cachePath must be specified")
+	}
+	if res.RootPath == "" && res.RootData == nil {
+		return nil, InvalidPolicyFormatError("One of rootPath and rootData must be specified")
+	}
+
+	return &res, nil
+}
+
+// NewPRSigstoreSignedTrustRoot returns a PRSigstoreSignedTrustRoot based on options.
+func NewPRSigstoreSignedTrustRoot(options ...PRSigstoreSignedTrustRootOption) (PRSigstoreSignedTrustRoot, error) {
+	return newPRSigstoreSignedTrustRoot(options...)
+}
+
+// Compile-time check that prSigstoreSignedTrustRoot implements json.Unmarshaler.
+var _ json.Unmarshaler = (*prSigstoreSignedTrustRoot)(nil)
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (tr *prSigstoreSignedTrustRoot) UnmarshalJSON(data []byte) error {
+	*tr = prSigstoreSignedTrustRoot{}
+	var tmp prSigstoreSignedTrustRoot
+	var gotMirror, gotRootPath, gotRootData, gotCachePath bool // = false...
+	if err := internal.ParanoidUnmarshalJSONObject(data, func(key string) any {
+		switch key {
+		case "mirror":
+			gotMirror = true
+			return &tmp.Mirror
+		case "rootPath":
+			gotRootPath = true
+			return &tmp.RootPath
+		case "rootData":
+			gotRootData = true
+			return &tmp.RootData
+		case "cachePath":
+			gotCachePath = true
+			return &tmp.CachePath
+		default:
+			return nil
+		}
+	}); err != nil {
+		return err
+	}
+
+	var opts []PRSigstoreSignedTrustRootOption
+	if gotMirror {
+		opts = append(opts, PRSigstoreSignedTrustRootWithMirror(tmp.Mirror))
+	}
+	if gotRootPath {
+		opts = append(opts, PRSigstoreSignedTrustRootWithRootPath(tmp.RootPath))
+	}
+	if gotRootData {
+		opts = append(opts, PRSigstoreSignedTrustRootWithRootData(tmp.RootData))
+	}
+	if gotCachePath {
+		opts = append(opts, PRSigstoreSignedTrustRootWithCachePath(tmp.CachePath))
+	}
+
+	res, err := newPRSigstoreSignedTrustRoot(opts...)
+	if err != nil {
+		return err
+	}
+	*tr = *res
+	return nil
+}
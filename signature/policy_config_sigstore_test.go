@@ -3,6 +3,7 @@ package signature
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -102,6 +103,22 @@ func TestNewPRSigstoreSigned(t *testing.T) {
 					RekorPublicKeyData: testRekorKeyData,
 				},
 			},
+			{
+				rekorOptions: []PRSigstoreSignedOption{
+					PRSigstoreSignedWithRekorPublicKeyPaths([]string{testRekorKeyPath, testRekorKeyPath + "1"}),
+				},
+				rekorExpected: prSigstoreSigned{
+					RekorPublicKeyPaths: []string{testRekorKeyPath, testRekorKeyPath + "1"},
+				},
+			},
+			{
+				rekorOptions: []PRSigstoreSignedOption{
+					PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{testRekorKeyData, []byte("ghi")}),
+				},
+				rekorExpected: prSigstoreSigned{
+					RekorPublicKeyDatas: [][]byte{testRekorKeyData, []byte("ghi")},
+				},
+			},
 		} {
 			// Special-case this rejected combination:
 			if c.requiresRekor && len(c2.rekorOptions) == 0 {
@@ -112,16 +129,137 @@ func TestNewPRSigstoreSigned(t *testing.T) {
 			expected := c.expected // A shallow copy
 			expected.RekorPublicKeyPath = c2.rekorExpected.RekorPublicKeyPath
 			expected.RekorPublicKeyData = c2.rekorExpected.RekorPublicKeyData
+			expected.RekorPublicKeyPaths = c2.rekorExpected.RekorPublicKeyPaths
+			expected.RekorPublicKeyDatas = c2.rekorExpected.RekorPublicKeyDatas
 			assert.Equal(t, &expected, pr)
 		}
 	}
 
+	// Success: trusted roots
+	testTrustedRootFulcio, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		PRSigstoreSignedFulcioWithSubjectEmail("mitr@redhat.com"),
+	)
+	require.NoError(t, err)
+	const testTrustedRootPath = "/foo/trusted_root.json"
+	testTrustedRootData := []byte("ghi")
+	testTrustRoot, err := NewPRSigstoreSignedTrustRoot(
+		PRSigstoreSignedTrustRootWithMirror("https://tuf.example.com"),
+		PRSigstoreSignedTrustRootWithRootPath("/foo/root.json"),
+	)
+	require.NoError(t, err)
+	const testTSACertPath = "/foo/tsa.pem"
+	testTSACertData := []byte("mno")
+	for _, c := range []struct {
+		options  []PRSigstoreSignedOption
+		expected prSigstoreSigned
+	}{
+		{
+			options: []PRSigstoreSignedOption{
+				PRSigstoreSignedWithKeyPath(testKeyPath),
+				PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+				PRSigstoreSignedWithSignedIdentity(testIdentity),
+			},
+			expected: prSigstoreSigned{
+				prCommon:        prCommon{prTypeSigstoreSigned},
+				KeyPath:         testKeyPath,
+				TrustedRootPath: testTrustedRootPath,
+				SignedIdentity:  testIdentity,
+			},
+		},
+		{
+			options: []PRSigstoreSignedOption{
+				PRSigstoreSignedWithFulcio(testTrustedRootFulcio),
+				PRSigstoreSignedWithTrustedRootData(testTrustedRootData),
+				PRSigstoreSignedWithSignedIdentity(testIdentity),
+			},
+			expected: prSigstoreSigned{
+				prCommon:        prCommon{prTypeSigstoreSigned},
+				Fulcio:          testTrustedRootFulcio,
+				TrustedRootData: testTrustedRootData,
+				SignedIdentity:  testIdentity,
+			},
+		},
+		{
+			options: []PRSigstoreSignedOption{
+				PRSigstoreSignedWithFulcio(testTrustedRootFulcio),
+				PRSigstoreSignedWithTrustRoot(testTrustRoot),
+				PRSigstoreSignedWithSignedIdentity(testIdentity),
+			},
+			expected: prSigstoreSigned{
+				prCommon:       prCommon{prTypeSigstoreSigned},
+				Fulcio:         testTrustedRootFulcio,
+				TrustRoot:      testTrustRoot,
+				SignedIdentity: testIdentity,
+			},
+		},
+		{
+			options: []PRSigstoreSignedOption{
+				PRSigstoreSignedWithKeyPath(testKeyPath),
+				PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+				PRSigstoreSignedWithTSACertPath(testTSACertPath),
+				PRSigstoreSignedWithSignedIdentity(testIdentity),
+			},
+			expected: prSigstoreSigned{
+				prCommon:           prCommon{prTypeSigstoreSigned},
+				KeyPath:            testKeyPath,
+				RekorPublicKeyPath: testRekorKeyPath,
+				TSACertPath:        testTSACertPath,
+				SignedIdentity:     testIdentity,
+			},
+		},
+		{ // Fulcio with a TSA but without Rekor
+			options: []PRSigstoreSignedOption{
+				PRSigstoreSignedWithFulcio(testFulcio),
+				PRSigstoreSignedWithTSACertData(testTSACertData),
+				PRSigstoreSignedWithSignedIdentity(testIdentity),
+			},
+			expected: prSigstoreSigned{
+				prCommon:       prCommon{prTypeSigstoreSigned},
+				Fulcio:         testFulcio,
+				TSACertData:    testTSACertData,
+				SignedIdentity: testIdentity,
+			},
+		},
+		{ // inTotoPredicateTypes
+			options: []PRSigstoreSignedOption{
+				PRSigstoreSignedWithKeyPath(testKeyPath),
+				PRSigstoreSignedWithSignedIdentity(testIdentity),
+				PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://slsa.dev/provenance/v1"}),
+			},
+			expected: prSigstoreSigned{
+				prCommon:             prCommon{prTypeSigstoreSigned},
+				KeyPath:              testKeyPath,
+				SignedIdentity:       testIdentity,
+				InTotoPredicateTypes: []string{"https://slsa.dev/provenance/v1"},
+			},
+		},
+	} {
+		pr, err := newPRSigstoreSigned(c.options...)
+		require.NoError(t, err)
+		assert.Equal(t, &c.expected, pr)
+	}
+
 	testFulcio2, err := NewPRSigstoreSignedFulcio(
 		PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
 		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
 		PRSigstoreSignedFulcioWithSubjectEmail("test-user@example.com"),
 	)
 	require.NoError(t, err)
+	// Success: multiple Fulcio roots
+	pr, err := newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio, testFulcio2}),
+		PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+		PRSigstoreSignedWithSignedIdentity(testIdentity),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, &prSigstoreSigned{
+		prCommon:           prCommon{prTypeSigstoreSigned},
+		Fulcios:            []PRSigstoreSignedFulcio{testFulcio, testFulcio2},
+		RekorPublicKeyPath: testRekorKeyPath,
+		SignedIdentity:     testIdentity,
+	}, pr)
+
 	for _, c := range [][]PRSigstoreSignedOption{
 		{}, // None of keyPath nor keyData, fulcio specified
 		{ // Both keyPath and keyData specified
@@ -161,6 +299,32 @@ func TestNewPRSigstoreSigned(t *testing.T) {
 			PRSigstoreSignedWithFulcio(testFulcio),
 			PRSigstoreSignedWithSignedIdentity(testIdentity),
 		},
+		{ // Both fulcio and fulcios specified
+			PRSigstoreSignedWithFulcio(testFulcio),
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio2}),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate fulcios
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio}),
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio2}),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Empty fulcios
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{}),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // nil element in fulcios
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio, nil}),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // fulcios without Rekor
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio, testFulcio2}),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
 		{ // Both rekorKeyPath and rekorKeyData specified
 			PRSigstoreSignedWithKeyPath(testKeyPath),
 			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
@@ -179,6 +343,120 @@ func TestNewPRSigstoreSigned(t *testing.T) {
 			PRSigstoreSignedWithRekorPublicKeyData([]byte("def")),
 			PRSigstoreSignedWithSignedIdentity(testIdentity),
 		},
+		{ // Both rekorKeyPath and rekorKeyPaths specified
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyPaths([]string{testRekorKeyPath}),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Both rekorKeyPaths and rekorKeyDatas specified
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyPaths([]string{testRekorKeyPath}),
+			PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{testRekorKeyData}),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate rekorKeyPaths
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyPaths([]string{testRekorKeyPath}),
+			PRSigstoreSignedWithRekorPublicKeyPaths([]string{testRekorKeyPath + "1"}),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate rekorKeyDatas
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{testRekorKeyData}),
+			PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{[]byte("ghi")}),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Empty rekorKeyPaths
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyPaths([]string{}),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Empty rekorKeyDatas
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{}),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Both trustedRootPath and trustedRootData specified
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+			PRSigstoreSignedWithTrustedRootData(testTrustedRootData),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate trustedRootPath
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath + "1"),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate trustedRootData
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTrustedRootData(testTrustedRootData),
+			PRSigstoreSignedWithTrustedRootData([]byte("jkl")),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Both trustRoot and trustedRootPath specified
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTrustRoot(testTrustRoot),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate trustRoot
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTrustRoot(testTrustRoot),
+			PRSigstoreSignedWithTrustRoot(testTrustRoot),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Both tsaCertPath and tsaCertData specified
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTSACertPath(testTSACertPath),
+			PRSigstoreSignedWithTSACertData(testTSACertData),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate tsaCertPath
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTSACertPath(testTSACertPath),
+			PRSigstoreSignedWithTSACertPath(testTSACertPath + "1"),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Duplicate tsaCertData
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithTSACertData(testTSACertData),
+			PRSigstoreSignedWithTSACertData([]byte("pqr")),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Both a Rekor public key and a TUF trust root specified
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyData(testRekorKeyData),
+			PRSigstoreSignedWithTrustRoot(testTrustRoot),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Both a Rekor public key and a trusted root specified
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Both a Fulcio CA and a trusted root specified
+			PRSigstoreSignedWithFulcio(testFulcio),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // Fulcio without a CA, and without a trusted root
+			PRSigstoreSignedWithFulcio(testTrustedRootFulcio),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // One of fulcios with a CA, together with a trusted root
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testTrustedRootFulcio, testFulcio}),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
+		{ // One of fulcios without a CA, and without a trusted root
+			PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio, testTrustedRootFulcio}),
+			PRSigstoreSignedWithRekorPublicKeyPath(testRekorKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+		},
 		{ // Missing signedIdentity
 			PRSigstoreSignedWithKeyPath(testKeyPath),
 		},
@@ -187,6 +465,38 @@ func TestNewPRSigstoreSigned(t *testing.T) {
 			PRSigstoreSignedWithSignedIdentity(testIdentity),
 			PRSigstoreSignedWithSignedIdentity(newPRMMatchRepository()),
 		},
+		{ // Invalid signedDigest
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+			PRSigstoreSignedWithSignedDigest("this is invalid"),
+		},
+		{ // Duplicate signedDigest
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+			PRSigstoreSignedWithSignedDigest(SignedDigestIndex),
+			PRSigstoreSignedWithSignedDigest(SignedDigestEither),
+		},
+		{ // Empty inTotoPredicateTypes
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+			PRSigstoreSignedWithInTotoPredicateTypes([]string{}),
+		},
+		{ // Empty element of inTotoPredicateTypes
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+			PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://slsa.dev/provenance/v1", ""}),
+		},
+		{ // Duplicate inTotoPredicateTypes
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithSignedIdentity(testIdentity),
+			PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://slsa.dev/provenance/v1"}),
+			PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://example.com/other"}),
+		},
+		{ // inTotoPredicateTypes with a signedIdentity other than matchRepoDigestOrExact
+			PRSigstoreSignedWithKeyPath(testKeyPath),
+			PRSigstoreSignedWithSignedIdentity(newPRMMatchRepository()),
+			PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://slsa.dev/provenance/v1"}),
+		},
 	} {
 		_, err = newPRSigstoreSigned(c...)
 		assert.Error(t, err)
@@ -280,6 +590,34 @@ func TestPRSigstoreSignedUnmarshalJSON(t *testing.T) {
 			// Invalid "rekorPublicKeyData" field
 			func(v mSA) { v["rekorPublicKeyData"] = 1 },
 			func(v mSA) { v["rekorPublicKeyData"] = "this is invalid base64" },
+			// Both "rekorPublicKeyPath" and "rekorPublicKeyPaths" is present
+			func(v mSA) {
+				v["rekorPublicKeyPath"] = "/foo/baz"
+				v["rekorPublicKeyPaths"] = []any{"/foo/baz"}
+			},
+			// Invalid "rekorPublicKeyPaths" field
+			func(v mSA) { v["rekorPublicKeyPaths"] = 1 },
+			func(v mSA) { v["rekorPublicKeyPaths"] = []any{1} },
+			func(v mSA) { v["rekorPublicKeyPaths"] = []any{} },
+			// Invalid "rekorPublicKeyDatas" field
+			func(v mSA) { v["rekorPublicKeyDatas"] = 1 },
+			func(v mSA) { v["rekorPublicKeyDatas"] = []any{"this is invalid base64"} },
+			func(v mSA) { v["rekorPublicKeyDatas"] = []any{} },
+			// Both "trustedRootPath" and "trustedRootData" is present
+			func(v mSA) {
+				v["trustedRootPath"] = "/foo/trusted_root.json"
+				v["trustedRootData"] = ""
+			},
+			// Both "rekorPublicKeyPath" and "trustedRootPath" is present
+			func(v mSA) {
+				v["rekorPublicKeyPath"] = "/foo/baz"
+				v["trustedRootPath"] = "/foo/trusted_root.json"
+			},
+			// Invalid "trustedRootPath" field
+			func(v mSA) { v["trustedRootPath"] = 1 },
+			// Invalid "trustedRootData" field
+			func(v mSA) { v["trustedRootData"] = 1 },
+			func(v mSA) { v["trustedRootData"] = "this is invalid base64" },
 			// Invalid "signedIdentity" field
 			func(v mSA) { v["signedIdentity"] = "this is invalid" },
 			// "signedIdentity" an explicit nil
@@ -329,70 +667,441 @@ func TestPRSigstoreSignedUnmarshalJSON(t *testing.T) {
 		otherJSONParser: newPolicyRequirementFromJSON,
 		duplicateFields: []string{"type", "keyPath", "rekorPublicKeyData", "signedIdentity"},
 	}.run(t)
-
-	var pr prSigstoreSigned
-
-	// Start with a valid JSON.
-	_, validJSON := keyDataTests.validObjectAndJSON(t)
-
-	// Various allowed modifications to the requirement
-	allowedModificationFns := []func(mSA){
-		// Delete the signedIdentity field
-		func(v mSA) { delete(v, "signedIdentity") },
-	}
-	for _, fn := range allowedModificationFns {
-		err := tryUnmarshalModifiedSigstoreSigned(t, &pr, validJSON, fn)
-		require.NoError(t, err)
-	}
-
-	// Various ways to set signedIdentity to the default value
-	signedIdentityDefaultFns := []func(mSA){
-		// Set signedIdentity to the default explicitly
-		func(v mSA) { v["signedIdentity"] = NewPRMMatchRepoDigestOrExact() },
-		// Delete the signedIdentity field
-		func(v mSA) { delete(v, "signedIdentity") },
-	}
-	for _, fn := range signedIdentityDefaultFns {
-		err := tryUnmarshalModifiedSigstoreSigned(t, &pr, validJSON, fn)
-		require.NoError(t, err)
-		assert.Equal(t, NewPRMMatchRepoDigestOrExact(), pr.SignedIdentity)
-	}
-}
-
-func TestNewPRSigstoreSignedFulcio(t *testing.T) {
-	const testCAPath = "/foo/bar"
-	testCAData := []byte("abc")
-	const testOIDCIssuer = "https://example.com"
-	const testSubjectEmail = "test@example.com"
-
-	// Success:
-	for _, c := range []struct {
-		options  []PRSigstoreSignedFulcioOption
-		expected prSigstoreSignedFulcio
-	}{
-		{
-			options: []PRSigstoreSignedFulcioOption{
-				PRSigstoreSignedFulcioWithCAPath(testCAPath),
-				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
-				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
-			},
-			expected: prSigstoreSignedFulcio{
-				CAPath:       testCAPath,
-				OIDCIssuer:   testOIDCIssuer,
-				SubjectEmail: testSubjectEmail,
-			},
-		},
-		{
-			options: []PRSigstoreSignedFulcioOption{
-				PRSigstoreSignedFulcioWithCAData(testCAData),
-				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
-				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
-			},
-			expected: prSigstoreSignedFulcio{
-				CAData:       testCAData,
-				OIDCIssuer:   testOIDCIssuer,
-				SubjectEmail: testSubjectEmail,
-			},
+	// Test trustedRootPath duplicate fields
+	testTrustedRootFulcio, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		PRSigstoreSignedFulcioWithSubjectEmail("mitr@redhat.com"),
+	)
+	require.NoError(t, err)
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithFulcio(testTrustedRootFulcio),
+				PRSigstoreSignedWithTrustedRootPath("/foo/trusted_root.json"),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// "fulcio" without a CA, and without a trusted root
+			func(v mSA) { delete(v, "trustedRootPath"); v["rekorPublicKeyPath"] = "/foo/rekor" },
+		},
+		duplicateFields: []string{"type", "fulcio", "trustedRootPath", "signedIdentity"},
+	}.run(t)
+	// Test fulcios specifics
+	testFulcio2, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+		PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", ".github/workflows/release.yml", ""),
+	)
+	require.NoError(t, err)
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio, testFulcio2}),
+				PRSigstoreSignedWithRekorPublicKeyPath("/foo/rekor"),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// Invalid "fulcios" field
+			func(v mSA) { v["fulcios"] = 1 },
+			func(v mSA) { v["fulcios"] = []any{} },
+			func(v mSA) { v["fulcios"] = []any{nil} },
+			func(v mSA) { v["fulcios"] = []any{1} },
+			func(v mSA) { v["fulcios"] = []any{mSA{}} },
+			// Both "fulcio" and "fulcios"
+			func(v mSA) { v["fulcio"] = v["fulcios"].([]any)[0] },
+			// "fulcios" without Rekor
+			func(v mSA) { delete(v, "rekorPublicKeyPath") },
+		},
+		duplicateFields: []string{"type", "fulcios", "rekorPublicKeyPath", "signedIdentity"},
+	}.run(t)
+	// Test trustedRootData duplicate fields
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithKeyPath("/foo/bar"),
+				PRSigstoreSignedWithTrustedRootData([]byte("foo")),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		duplicateFields: []string{"type", "keyPath", "trustedRootData", "signedIdentity"},
+	}.run(t)
+	// Test trustRoot duplicate fields
+	testTrustRoot, err := NewPRSigstoreSignedTrustRoot(
+		PRSigstoreSignedTrustRootWithMirror("https://tuf.example.com"),
+		PRSigstoreSignedTrustRootWithRootPath("/foo/root.json"),
+	)
+	require.NoError(t, err)
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithFulcio(testTrustedRootFulcio),
+				PRSigstoreSignedWithTrustRoot(testTrustRoot),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// Invalid "trustRoot" field
+			func(v mSA) { v["trustRoot"] = 1 },
+			func(v mSA) { v["trustRoot"] = mSA{} },
+			// Both "trustRoot" and "trustedRootPath" is present
+			func(v mSA) { v["trustedRootPath"] = "/foo/trusted_root.json" },
+			// Both "trustRoot" and "rekorPublicKeyPath" is present
+			func(v mSA) { v["rekorPublicKeyPath"] = "/foo/rekor" },
+		},
+		duplicateFields: []string{"type", "fulcio", "trustRoot", "signedIdentity"},
+	}.run(t)
+	// Test tsaCertPath duplicate fields
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithFulcio(testFulcio),
+				PRSigstoreSignedWithTSACertPath("/foo/tsa.pem"),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// Invalid "tsaCertPath" field
+			func(v mSA) { v["tsaCertPath"] = 1 },
+			// Both "tsaCertPath" and "tsaCertData" is present
+			func(v mSA) { v["tsaCertData"] = "YWJj" },
+			// "fulcio" without a TSA, and without Rekor
+			func(v mSA) { delete(v, "tsaCertPath") },
+		},
+		duplicateFields: []string{"type", "fulcio", "tsaCertPath", "signedIdentity"},
+	}.run(t)
+	// Test tsaCertData duplicate fields
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithKeyPath("/foo/bar"),
+				PRSigstoreSignedWithTSACertData([]byte("foo")),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// Invalid "tsaCertData" field
+			func(v mSA) { v["tsaCertData"] = 1 },
+			func(v mSA) { v["tsaCertData"] = "this is invalid base64" },
+		},
+		duplicateFields: []string{"type", "keyPath", "tsaCertData", "signedIdentity"},
+	}.run(t)
+	// Test rekorPublicKeyPaths duplicate fields
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithFulcio(testFulcio),
+				PRSigstoreSignedWithRekorPublicKeyPaths([]string{"/foo/rekor1", "/foo/rekor2"}),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		duplicateFields: []string{"type", "fulcio", "rekorPublicKeyPaths", "signedIdentity"},
+	}.run(t)
+	// Test rekorPublicKeyDatas duplicate fields
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithKeyPath("/foo/bar"),
+				PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{[]byte("foo"), []byte("bar")}),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		duplicateFields: []string{"type", "keyPath", "rekorPublicKeyDatas", "signedIdentity"},
+	}.run(t)
+	// Test signedDigest-specific aspects
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithKeyPath("/foo/bar"),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+				PRSigstoreSignedWithSignedDigest(SignedDigestEither),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// Invalid "signedDigest" field
+			func(v mSA) { v["signedDigest"] = 1 },
+			func(v mSA) { v["signedDigest"] = "this is invalid" },
+			func(v mSA) { v["signedDigest"] = "" },
+		},
+		duplicateFields: []string{"type", "keyPath", "signedIdentity", "signedDigest"},
+	}.run(t)
+	// Test inTotoPredicateTypes-specific aspects
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSigned{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSigstoreSigned(
+				PRSigstoreSignedWithKeyPath("/foo/bar"),
+				PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+				PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://slsa.dev/provenance/v1", "https://cosign.sigstore.dev/attestation/v1"}),
+			)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// Invalid "inTotoPredicateTypes" field
+			func(v mSA) { v["inTotoPredicateTypes"] = 1 },
+			func(v mSA) { v["inTotoPredicateTypes"] = []string{} },
+			func(v mSA) { v["inTotoPredicateTypes"] = []string{""} },
+			// signedIdentity other than matchRepoDigestOrExact
+			func(v mSA) { v["signedIdentity"] = mSA{"type": "matchRepository"} },
+		},
+		duplicateFields: []string{"type", "keyPath", "signedIdentity", "inTotoPredicateTypes"},
+	}.run(t)
+
+	var pr prSigstoreSigned
+
+	// Start with a valid JSON.
+	_, validJSON := keyDataTests.validObjectAndJSON(t)
+
+	// Various allowed modifications to the requirement
+	allowedModificationFns := []func(mSA){
+		// Delete the signedIdentity field
+		func(v mSA) { delete(v, "signedIdentity") },
+	}
+	for _, fn := range allowedModificationFns {
+		err := tryUnmarshalModifiedSigstoreSigned(t, &pr, validJSON, fn)
+		require.NoError(t, err)
+	}
+
+	// Various ways to set signedIdentity to the default value
+	signedIdentityDefaultFns := []func(mSA){
+		// Set signedIdentity to the default explicitly
+		func(v mSA) { v["signedIdentity"] = NewPRMMatchRepoDigestOrExact() },
+		// Delete the signedIdentity field
+		func(v mSA) { delete(v, "signedIdentity") },
+	}
+	for _, fn := range signedIdentityDefaultFns {
+		err := tryUnmarshalModifiedSigstoreSigned(t, &pr, validJSON, fn)
+		require.NoError(t, err)
+		assert.Equal(t, NewPRMMatchRepoDigestOrExact(), pr.SignedIdentity)
+	}
+}
+
+func TestNewPRSigstoreSignedFulcio(t *testing.T) {
+	const testCAPath = "/foo/bar"
+	testCAData := []byte("abc")
+	const testOIDCIssuer = "https://example.com"
+	const testSubjectEmail = "test@example.com"
+	const testOIDCIssuerRegex = `https://example\.com/tenant/.*`
+	const testSubjectEmailRegex = `.*@example\.com`
+	const testSubjectURI = "spiffe://example.com/ns/default/sa/builder"
+	const testSubjectURIRegex = `spiffe://example\.com/ns/[^/]+/sa/builder`
+	testIssuedAfter := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	testIssuedBefore := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	// Success:
+	for _, c := range []struct {
+		options  []PRSigstoreSignedFulcioOption
+		expected prSigstoreSignedFulcio
+	}{
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:       testCAPath,
+				OIDCIssuer:   testOIDCIssuer,
+				SubjectEmail: testSubjectEmail,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAData(testCAData),
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAData:       testCAData,
+				OIDCIssuer:   testOIDCIssuer,
+				SubjectEmail: testSubjectEmail,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+				PRSigstoreSignedFulcioWithIntegratedTimeCheck(IntegratedTimeCheckWithinValidity),
+				PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(30),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:                         testCAPath,
+				OIDCIssuer:                     testOIDCIssuer,
+				SubjectEmail:                   testSubjectEmail,
+				IntegratedTimeCheck:            IntegratedTimeCheckWithinValidity,
+				IntegratedTimeClockSkewSeconds: 30,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+				PRSigstoreSignedFulcioWithIntegratedTimeCheck(IntegratedTimeCheckIgnore),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:              testCAPath,
+				OIDCIssuer:          testOIDCIssuer,
+				SubjectEmail:        testSubjectEmail,
+				IntegratedTimeCheck: IntegratedTimeCheckIgnore,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+				PRSigstoreSignedFulcioWithIssuedAfter(testIssuedAfter),
+				PRSigstoreSignedFulcioWithIssuedBefore(testIssuedBefore),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:       testCAPath,
+				OIDCIssuer:   testOIDCIssuer,
+				SubjectEmail: testSubjectEmail,
+				IssuedAfter:  &testIssuedAfter,
+				IssuedBefore: &testIssuedBefore,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuerRegex(testOIDCIssuerRegex),
+				PRSigstoreSignedFulcioWithSubjectEmailRegex(testSubjectEmailRegex),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:            testCAPath,
+				OIDCIssuerRegex:   testOIDCIssuerRegex,
+				SubjectEmailRegex: testSubjectEmailRegex,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectEmailRegex(testSubjectEmailRegex),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:            testCAPath,
+				OIDCIssuer:        testOIDCIssuer,
+				SubjectEmailRegex: testSubjectEmailRegex,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectURI(testSubjectURI),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:     testCAPath,
+				OIDCIssuer: testOIDCIssuer,
+				SubjectURI: testSubjectURI,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithOIDCIssuerRegex(testOIDCIssuerRegex),
+				PRSigstoreSignedFulcioWithSubjectURIRegex(testSubjectURIRegex),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath:          testCAPath,
+				OIDCIssuerRegex: testOIDCIssuerRegex,
+				SubjectURIRegex: testSubjectURIRegex,
+			},
+		},
+		{ // Neither caPath nor caData specified; this is only usable with a trusted root, which newPRSigstoreSigned checks.
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+				PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			},
+			expected: prSigstoreSignedFulcio{
+				OIDCIssuer:   testOIDCIssuer,
+				SubjectEmail: testSubjectEmail,
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", ".github/workflows/release.yml", "refs/heads/main"),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath: testCAPath,
+				CIIdentity: &prSigstoreSignedFulcioCIIdentity{
+					Provider:   FulcioCIProviderGitHubActions,
+					Repository: "containers/image",
+					Workflow:   ".github/workflows/release.yml",
+					Ref:        "refs/heads/main",
+				},
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+					Repository: "containers/image",
+					Workflow:   ".github/workflows/release.yml",
+					RefPrefix:  "refs/tags/",
+					Trigger:    "push",
+					SHA:        "0123456789abcdef0123456789abcdef01234567",
+				}),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath: testCAPath,
+				CIIdentity: &prSigstoreSignedFulcioCIIdentity{
+					Provider:   FulcioCIProviderGitHubActions,
+					Repository: "containers/image",
+					Workflow:   ".github/workflows/release.yml",
+					RefPrefix:  "refs/tags/",
+					Trigger:    "push",
+					SHA:        "0123456789abcdef0123456789abcdef01234567",
+				},
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithGitLabCIIdentity("group/subgroup/project", "", ""),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath: testCAPath,
+				CIIdentity: &prSigstoreSignedFulcioCIIdentity{
+					Provider:   FulcioCIProviderGitLabCI,
+					Repository: "group/subgroup/project",
+				},
+			},
+		},
+		{
+			options: []PRSigstoreSignedFulcioOption{
+				PRSigstoreSignedFulcioWithCAPath(testCAPath),
+				PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("123456789012"),
+			},
+			expected: prSigstoreSignedFulcio{
+				CAPath: testCAPath,
+				CIIdentity: &prSigstoreSignedFulcioCIIdentity{
+					Provider:      FulcioCIProviderGoogleCloudBuild,
+					ProjectNumber: "123456789012",
+				},
+			},
 		},
 	} {
 		pr, err := newPRSigstoreSignedFulcio(c.options...)
@@ -401,10 +1110,6 @@ func TestNewPRSigstoreSignedFulcio(t *testing.T) {
 	}
 
 	for _, c := range [][]PRSigstoreSignedFulcioOption{
-		{ // Neither caPath nor caData specified
-			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
-			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
-		},
 		{ // Both caPath and caData specified
 			PRSigstoreSignedFulcioWithCAPath(testCAPath),
 			PRSigstoreSignedFulcioWithCAData(testCAData),
@@ -433,16 +1138,261 @@ func TestNewPRSigstoreSignedFulcio(t *testing.T) {
 			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer + "1"),
 			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
 		},
+		{ // Both oidcIssuer and oidcIssuerRegex specified
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithOIDCIssuerRegex(testOIDCIssuerRegex),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+		},
+		{ // Duplicate oidcIssuerRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuerRegex(testOIDCIssuerRegex),
+			PRSigstoreSignedFulcioWithOIDCIssuerRegex(testOIDCIssuerRegex + "1"),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+		},
+		{ // Invalid oidcIssuerRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuerRegex("https://example.com/(unterminated"),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+		},
 		{ // Missing subjectEmail
 			PRSigstoreSignedFulcioWithCAPath(testCAPath),
 			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
 		},
+		{ // Both subjectEmail and subjectEmailRegex specified
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithSubjectEmailRegex(testSubjectEmailRegex),
+		},
+		{ // Duplicate subjectEmailRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmailRegex(testSubjectEmailRegex),
+			PRSigstoreSignedFulcioWithSubjectEmailRegex("1" + testSubjectEmailRegex),
+		},
+		{ // Invalid subjectEmailRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmailRegex("[a-z+@example.com"),
+		},
+		{ // Both subjectEmail and subjectURI specified
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithSubjectURI(testSubjectURI),
+		},
+		{ // Both subjectEmailRegex and subjectURIRegex specified
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmailRegex(testSubjectEmailRegex),
+			PRSigstoreSignedFulcioWithSubjectURIRegex(testSubjectURIRegex),
+		},
+		{ // Both subjectURI and subjectURIRegex specified
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectURI(testSubjectURI),
+			PRSigstoreSignedFulcioWithSubjectURIRegex(testSubjectURIRegex),
+		},
+		{ // Duplicate subjectURI
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectURI(testSubjectURI),
+			PRSigstoreSignedFulcioWithSubjectURI(testSubjectURI + "1"),
+		},
+		{ // Relative subjectURI
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectURI("ns/default/sa/builder"),
+		},
+		{ // Invalid subjectURI
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectURI("https://example.com/%"),
+		},
+		{ // Duplicate subjectURIRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectURIRegex(testSubjectURIRegex),
+			PRSigstoreSignedFulcioWithSubjectURIRegex(testSubjectURIRegex + "1"),
+		},
+		{ // Invalid subjectURIRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectURIRegex("spiffe://(unterminated"),
+		},
 		{ // Duplicate subjectEmail
 			PRSigstoreSignedFulcioWithCAPath(testCAPath),
 			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
 			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
 			PRSigstoreSignedFulcioWithSubjectEmail("1" + testSubjectEmail),
 		},
+		{ // Duplicate integratedTimeCheck
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIntegratedTimeCheck(IntegratedTimeCheckWithinValidity),
+			PRSigstoreSignedFulcioWithIntegratedTimeCheck(IntegratedTimeCheckIgnore),
+		},
+		{ // Invalid integratedTimeCheck
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIntegratedTimeCheck("this is invalid"),
+		},
+		{ // Duplicate integratedTimeClockSkewSeconds
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(1),
+			PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(2),
+		},
+		{ // Negative integratedTimeClockSkewSeconds
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(-1),
+		},
+		{ // integratedTimeClockSkewSeconds with integratedTimeCheck = ignore
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIntegratedTimeCheck(IntegratedTimeCheckIgnore),
+			PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(1),
+		},
+		{ // Duplicate issuedAfter
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIssuedAfter(testIssuedAfter),
+			PRSigstoreSignedFulcioWithIssuedAfter(testIssuedBefore),
+		},
+		{ // Duplicate issuedBefore
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIssuedBefore(testIssuedAfter),
+			PRSigstoreSignedFulcioWithIssuedBefore(testIssuedBefore),
+		},
+		{ // issuedAfter not before issuedBefore
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithIssuedAfter(testIssuedBefore),
+			PRSigstoreSignedFulcioWithIssuedBefore(testIssuedAfter),
+		},
+		{ // Duplicate ciIdentity
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("1"),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("2"),
+		},
+		{ // ciIdentity with oidcIssuer
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("1"),
+		},
+		{ // ciIdentity with subjectEmail
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("1"),
+		},
+		{ // ciIdentity with oidcIssuerRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuerRegex(testOIDCIssuerRegex),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("1"),
+		},
+		{ // ciIdentity with subjectEmailRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithSubjectEmailRegex(testSubjectEmailRegex),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("1"),
+		},
+		{ // ciIdentity with subjectURI
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithSubjectURI(testSubjectURI),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("1"),
+		},
+		{ // ciIdentity with subjectURIRegex
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithSubjectURIRegex(testSubjectURIRegex),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("1"),
+		},
+		{ // Invalid GitHub repository
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitHubActionsIdentity("image", ".github/workflows/release.yml", ""),
+		},
+		{ // Missing GitHub workflow
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", "", ""),
+		},
+		{ // Invalid GitHub workflow
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", ".github/workflows/release.yml@refs/heads/main", ""),
+		},
+		{ // Both ref and refPrefix
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+				Repository: "containers/image",
+				Workflow:   ".github/workflows/release.yml",
+				Ref:        "refs/tags/v1.0",
+				RefPrefix:  "refs/tags/",
+			}),
+		},
+		{ // Invalid refPrefix
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+				Repository: "containers/image",
+				Workflow:   ".github/workflows/release.yml",
+				RefPrefix:  "refs/tags/@",
+			}),
+		},
+		{ // Invalid sha
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+				Repository: "containers/image",
+				Workflow:   ".github/workflows/release.yml",
+				SHA:        "0123456789ABCDEF0123456789ABCDEF01234567",
+			}),
+		},
+		{ // Truncated sha
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+				Repository: "containers/image",
+				Workflow:   ".github/workflows/release.yml",
+				SHA:        "0123456",
+			}),
+		},
+		{ // trigger with GitLab
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{
+				Provider:   FulcioCIProviderGitLabCI,
+				Repository: "group/project",
+				Trigger:    "push",
+			}),
+		},
+		{ // refPrefix with Cloud Build
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{
+				Provider:      FulcioCIProviderGoogleCloudBuild,
+				ProjectNumber: "123456789012",
+				RefPrefix:     "refs/tags/",
+			}),
+		},
+		{ // Invalid GitLab project
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitLabCIIdentity("project", "", ""),
+		},
+		{ // Invalid ref
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGitLabCIIdentity("group/project", "", "refs/heads/main@x"),
+		},
+		{ // Invalid Cloud Build project number
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("my-project"),
+		},
+		{ // Unknown CI provider
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{Provider: "this is invalid"}),
+		},
 	} {
 		_, err := newPRSigstoreSignedFulcio(c...)
 		logrus.Errorf("%#v", err)
@@ -458,14 +1408,32 @@ func TestPRSigstoreSignedFulcioUnmarshalJSON(t *testing.T) {
 				PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
 				PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
 				PRSigstoreSignedFulcioWithSubjectEmail("mitr@redhat.com"),
+				PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(30),
+				PRSigstoreSignedFulcioWithIssuedAfter(time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)),
+				PRSigstoreSignedFulcioWithIssuedBefore(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)),
 			)
 		},
 		otherJSONParser: nil,
 		breakFns: []func(mSA){
+			// Invalid "issuedAfter" field
+			func(v mSA) { v["issuedAfter"] = 1 },
+			func(v mSA) { v["issuedAfter"] = "this is invalid" },
+			func(v mSA) { v["issuedAfter"] = nil },
+			// Invalid "issuedBefore" field
+			func(v mSA) { v["issuedBefore"] = 1 },
+			func(v mSA) { v["issuedBefore"] = nil },
+			// "issuedAfter" not before "issuedBefore"
+			func(v mSA) { v["issuedAfter"] = "2025-01-01T00:00:00Z" },
+			// Invalid "integratedTimeCheck" field
+			func(v mSA) { v["integratedTimeCheck"] = 1 },
+			func(v mSA) { v["integratedTimeCheck"] = "this is invalid" },
+			// "integratedTimeClockSkewSeconds" with "integratedTimeCheck": "ignore"
+			func(v mSA) { v["integratedTimeCheck"] = IntegratedTimeCheckIgnore },
+			// Invalid "integratedTimeClockSkewSeconds" field
+			func(v mSA) { v["integratedTimeClockSkewSeconds"] = "30" },
+			func(v mSA) { v["integratedTimeClockSkewSeconds"] = -1 },
 			// Extra top-level sub-object
 			func(v mSA) { v["unexpected"] = 1 },
-			// Both of "caPath" and "caData" are missing
-			func(v mSA) { delete(v, "caPath") },
 			// Both "caPath" and "caData" is present
 			func(v mSA) { v["caData"] = "" },
 			// Invalid "caPath" field
@@ -479,7 +1447,7 @@ func TestPRSigstoreSignedFulcioUnmarshalJSON(t *testing.T) {
 			// "subjectEmail" is missing
 			func(v mSA) { delete(v, "subjectEmail") },
 		},
-		duplicateFields: []string{"caPath", "oidcIssuer", "subjectEmail"},
+		duplicateFields: []string{"caPath", "oidcIssuer", "subjectEmail", "integratedTimeClockSkewSeconds", "issuedAfter", "issuedBefore"},
 	}.run(t)
 	// Test caData specifics
 	policyJSONUmarshallerTests[PRSigstoreSignedFulcio]{
@@ -499,4 +1467,353 @@ func TestPRSigstoreSignedFulcioUnmarshalJSON(t *testing.T) {
 		},
 		duplicateFields: []string{"caData", "oidcIssuer", "subjectEmail"},
 	}.run(t)
+	// Test oidcIssuerRegex and subjectEmailRegex specifics
+	policyJSONUmarshallerTests[PRSigstoreSignedFulcio]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedFulcio{} },
+		newValidObject: func() (PRSigstoreSignedFulcio, error) {
+			return NewPRSigstoreSignedFulcio(
+				PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+				PRSigstoreSignedFulcioWithOIDCIssuerRegex(`https://github\.com/.*`),
+				PRSigstoreSignedFulcioWithSubjectEmailRegex(`.*@redhat\.com`),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Invalid "oidcIssuerRegex" field
+			func(v mSA) { v["oidcIssuerRegex"] = 1 },
+			func(v mSA) { v["oidcIssuerRegex"] = "(unterminated" },
+			// Both "oidcIssuer" and "oidcIssuerRegex" is present
+			func(v mSA) { v["oidcIssuer"] = "https://github.com/login/oauth" },
+			// Invalid "subjectEmailRegex" field
+			func(v mSA) { v["subjectEmailRegex"] = 1 },
+			func(v mSA) { v["subjectEmailRegex"] = "(unterminated" },
+			// Both "subjectEmail" and "subjectEmailRegex" is present
+			func(v mSA) { v["subjectEmail"] = "mitr@redhat.com" },
+		},
+		duplicateFields: []string{"caPath", "oidcIssuerRegex", "subjectEmailRegex"},
+	}.run(t)
+	// Test subjectURI specifics
+	policyJSONUmarshallerTests[PRSigstoreSignedFulcio]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedFulcio{} },
+		newValidObject: func() (PRSigstoreSignedFulcio, error) {
+			return NewPRSigstoreSignedFulcio(
+				PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+				PRSigstoreSignedFulcioWithOIDCIssuer("https://token.actions.githubusercontent.com"),
+				PRSigstoreSignedFulcioWithSubjectURI("https://github.com/containers/image/.github/workflows/release.yml@refs/heads/main"),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Invalid "subjectURI" field
+			func(v mSA) { v["subjectURI"] = 1 },
+			func(v mSA) { v["subjectURI"] = "not/an/absolute/URI" },
+			// Both "subjectURI" and "subjectEmail" is present
+			func(v mSA) { v["subjectEmail"] = "mitr@redhat.com" },
+			// Both "subjectURI" and "subjectURIRegex" is present
+			func(v mSA) { v["subjectURIRegex"] = "https://github\\.com/.*" },
+			// "subjectURI" is missing
+			func(v mSA) { delete(v, "subjectURI") },
+		},
+		duplicateFields: []string{"caPath", "oidcIssuer", "subjectURI"},
+	}.run(t)
+	// Test subjectURIRegex specifics
+	policyJSONUmarshallerTests[PRSigstoreSignedFulcio]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedFulcio{} },
+		newValidObject: func() (PRSigstoreSignedFulcio, error) {
+			return NewPRSigstoreSignedFulcio(
+				PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+				PRSigstoreSignedFulcioWithOIDCIssuer("https://token.actions.githubusercontent.com"),
+				PRSigstoreSignedFulcioWithSubjectURIRegex(`https://github\.com/containers/.*`),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Invalid "subjectURIRegex" field
+			func(v mSA) { v["subjectURIRegex"] = 1 },
+			func(v mSA) { v["subjectURIRegex"] = "(unterminated" },
+			// Both "subjectURIRegex" and "subjectEmailRegex" is present
+			func(v mSA) { v["subjectEmailRegex"] = ".*" },
+		},
+		duplicateFields: []string{"caPath", "oidcIssuer", "subjectURIRegex"},
+	}.run(t)
+	// Test ciIdentity specifics
+	policyJSONUmarshallerTests[PRSigstoreSignedFulcio]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedFulcio{} },
+		newValidObject: func() (PRSigstoreSignedFulcio, error) {
+			return NewPRSigstoreSignedFulcio(
+				PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+				PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", ".github/workflows/release.yml", "refs/heads/main"),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Invalid "ciIdentity" field
+			func(v mSA) { v["ciIdentity"] = 1 },
+			func(v mSA) { v["ciIdentity"] = nil },
+			// "ciIdentity" with "oidcIssuer" or "subjectEmail"
+			func(v mSA) { v["oidcIssuer"] = "https://token.actions.githubusercontent.com" },
+			func(v mSA) { v["subjectEmail"] = "mitr@redhat.com" },
+			// Extra "ciIdentity" field
+			func(v mSA) { x(v, "ciIdentity")["unexpected"] = 1 },
+			// Missing or invalid "provider"
+			func(v mSA) { delete(x(v, "ciIdentity"), "provider") },
+			func(v mSA) { x(v, "ciIdentity")["provider"] = 1 },
+			func(v mSA) { x(v, "ciIdentity")["provider"] = "this is invalid" },
+			// Invalid "repository"
+			func(v mSA) { x(v, "ciIdentity")["repository"] = "image" },
+			// Invalid "workflow"
+			func(v mSA) { delete(x(v, "ciIdentity"), "workflow") },
+			func(v mSA) { x(v, "ciIdentity")["workflow"] = "/.github/workflows/release.yml" },
+			// "projectNumber" with GitHub Actions
+			func(v mSA) { x(v, "ciIdentity")["projectNumber"] = "1" },
+		},
+		duplicateFields: []string{"caPath", "ciIdentity"},
+	}.run(t)
+	// Test ciIdentity GitHub Actions workflow details
+	policyJSONUmarshallerTests[PRSigstoreSignedFulcio]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedFulcio{} },
+		newValidObject: func() (PRSigstoreSignedFulcio, error) {
+			return NewPRSigstoreSignedFulcio(
+				PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+				PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+					Repository: "containers/image",
+					Workflow:   ".github/workflows/release.yml",
+					RefPrefix:  "refs/tags/",
+					Trigger:    "push",
+					SHA:        "0123456789abcdef0123456789abcdef01234567",
+				}),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Invalid "refPrefix"
+			func(v mSA) { x(v, "ciIdentity")["refPrefix"] = 1 },
+			func(v mSA) { x(v, "ciIdentity")["refPrefix"] = "refs/tags/@" },
+			// Both "ref" and "refPrefix"
+			func(v mSA) { x(v, "ciIdentity")["ref"] = "refs/tags/v1.0" },
+			// Invalid "trigger"
+			func(v mSA) { x(v, "ciIdentity")["trigger"] = 1 },
+			// Invalid "sha"
+			func(v mSA) { x(v, "ciIdentity")["sha"] = 1 },
+			func(v mSA) { x(v, "ciIdentity")["sha"] = "not a commit" },
+			// "trigger" and "sha" with another provider
+			func(v mSA) { x(v, "ciIdentity")["provider"] = FulcioCIProviderGitLabCI },
+		},
+		duplicateFields: []string{"caPath", "ciIdentity"},
+	}.run(t)
+}
+
+func TestNewPRSigstoreSignedTrustRoot(t *testing.T) {
+	const testMirror = "https://tuf.example.com"
+	const testRootPath = "/foo/root.json"
+	testRootData := []byte("abc")
+	const testCachePath = "/var/cache/tuf"
+
+	// Success
+	for _, c := range []struct {
+		options  []PRSigstoreSignedTrustRootOption
+		expected prSigstoreSignedTrustRoot
+	}{
+		{
+			options: []PRSigstoreSignedTrustRootOption{
+				PRSigstoreSignedTrustRootWithMirror(testMirror),
+				PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+			},
+			expected: prSigstoreSignedTrustRoot{Mirror: testMirror, RootPath: testRootPath},
+		},
+		{
+			options: []PRSigstoreSignedTrustRootOption{
+				PRSigstoreSignedTrustRootWithMirror(testMirror),
+				PRSigstoreSignedTrustRootWithRootData(testRootData),
+				PRSigstoreSignedTrustRootWithCachePath(testCachePath),
+			},
+			expected: prSigstoreSignedTrustRoot{Mirror: testMirror, RootData: testRootData, CachePath: testCachePath},
+		},
+		{
+			options: []PRSigstoreSignedTrustRootOption{
+				PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+				PRSigstoreSignedTrustRootWithCachePath(testCachePath),
+			},
+			expected: prSigstoreSignedTrustRoot{RootPath: testRootPath, CachePath: testCachePath},
+		},
+	} {
+		tr, err := newPRSigstoreSignedTrustRoot(c.options...)
+		require.NoError(t, err)
+		assert.Equal(t, &c.expected, tr)
+	}
+
+	for _, c := range [][]PRSigstoreSignedTrustRootOption{
+		{}, // Neither mirror nor cachePath
+		{ // Mirror without a root
+			PRSigstoreSignedTrustRootWithMirror(testMirror),
+			PRSigstoreSignedTrustRootWithCachePath(testCachePath),
+		},
+		{ // cachePath without a root
+			PRSigstoreSignedTrustRootWithCachePath(testCachePath),
+		},
+		{ // Both rootPath and rootData
+			PRSigstoreSignedTrustRootWithMirror(testMirror),
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+			PRSigstoreSignedTrustRootWithRootData(testRootData),
+		},
+		{ // Invalid mirror URLs
+			PRSigstoreSignedTrustRootWithMirror("tuf.example.com"),
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+		},
+		{
+			PRSigstoreSignedTrustRootWithMirror("ftp://tuf.example.com"),
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+		},
+		{
+			PRSigstoreSignedTrustRootWithMirror("https://tuf.example.com/%"),
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+		},
+		{ // Duplicate mirror
+			PRSigstoreSignedTrustRootWithMirror(testMirror),
+			PRSigstoreSignedTrustRootWithMirror(testMirror + "1"),
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+		},
+		{ // Duplicate rootPath
+			PRSigstoreSignedTrustRootWithMirror(testMirror),
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath + "1"),
+		},
+		{ // Duplicate rootData
+			PRSigstoreSignedTrustRootWithMirror(testMirror),
+			PRSigstoreSignedTrustRootWithRootData(testRootData),
+			PRSigstoreSignedTrustRootWithRootData([]byte("def")),
+		},
+		{ // Duplicate cachePath
+			PRSigstoreSignedTrustRootWithRootPath(testRootPath),
+			PRSigstoreSignedTrustRootWithCachePath(testCachePath),
+			PRSigstoreSignedTrustRootWithCachePath(testCachePath + "1"),
+		},
+	} {
+		_, err := newPRSigstoreSignedTrustRoot(c...)
+		assert.Error(t, err)
+	}
+}
+
+func TestPRSigstoreSignedTrustRootUnmarshalJSON(t *testing.T) {
+	policyJSONUmarshallerTests[PRSigstoreSignedTrustRoot]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedTrustRoot{} },
+		newValidObject: func() (PRSigstoreSignedTrustRoot, error) {
+			return NewPRSigstoreSignedTrustRoot(
+				PRSigstoreSignedTrustRootWithMirror("https://tuf.example.com"),
+				PRSigstoreSignedTrustRootWithRootPath("/foo/root.json"),
+				PRSigstoreSignedTrustRootWithCachePath("/var/cache/tuf"),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Extra top-level sub-object
+			func(v mSA) { v["unexpected"] = 1 },
+			// Invalid "mirror" field
+			func(v mSA) { v["mirror"] = 1 },
+			func(v mSA) { v["mirror"] = "this is invalid" },
+			// Invalid "rootPath" field
+			func(v mSA) { v["rootPath"] = 1 },
+			// "rootPath" is missing
+			func(v mSA) { delete(v, "rootPath") },
+			// Both "rootPath" and "rootData" is present
+			func(v mSA) { v["rootData"] = "" },
+			// Invalid "cachePath" field
+			func(v mSA) { v["cachePath"] = 1 },
+			// Neither "mirror" nor "cachePath" is present
+			func(v mSA) { delete(v, "mirror"); delete(v, "cachePath") },
+		},
+		duplicateFields: []string{"mirror", "rootPath", "cachePath"},
+	}.run(t)
+	// Test rootData specifics
+	policyJSONUmarshallerTests[PRSigstoreSignedTrustRoot]{
+		newDest: func() json.Unmarshaler { return &prSigstoreSignedTrustRoot{} },
+		newValidObject: func() (PRSigstoreSignedTrustRoot, error) {
+			return NewPRSigstoreSignedTrustRoot(
+				PRSigstoreSignedTrustRootWithMirror("https://tuf.example.com"),
+				PRSigstoreSignedTrustRootWithRootData([]byte("abc")),
+			)
+		},
+		otherJSONParser: nil,
+		breakFns: []func(mSA){
+			// Invalid "rootData" field
+			func(v mSA) { v["rootData"] = 1 },
+			func(v mSA) { v["rootData"] = "this is invalid base64" },
+		},
+		duplicateFields: []string{"mirror", "rootData"},
+	}.run(t)
+}
+
+func TestPRSigstoreSignedFulcioCIIdentityExpectedIdentity(t *testing.T) {
+	for _, c := range []struct {
+		option   PRSigstoreSignedFulcioOption
+		expected fulcioCIIdentity
+	}{
+		{
+			option: PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", ".github/workflows/release.yml", "refs/tags/v1.0"),
+			expected: fulcioCIIdentity{
+				oidcIssuer: "https://token.actions.githubusercontent.com",
+				subjectURI: "https://github.com/containers/image/.github/workflows/release.yml@refs/tags/v1.0",
+			},
+		},
+		{
+			option: PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", ".github/workflows/release.yml", ""),
+			expected: fulcioCIIdentity{
+				oidcIssuer:       "https://token.actions.githubusercontent.com",
+				subjectURIPrefix: "https://github.com/containers/image/.github/workflows/release.yml@",
+			},
+		},
+		{
+			option: PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+				Repository: "containers/image",
+				Workflow:   ".github/workflows/release.yml",
+				RefPrefix:  "refs/tags/",
+				Trigger:    "push",
+				SHA:        "0123456789abcdef0123456789abcdef01234567",
+			}),
+			expected: fulcioCIIdentity{
+				oidcIssuer:            "https://token.actions.githubusercontent.com",
+				subjectURIPrefix:      "https://github.com/containers/image/.github/workflows/release.yml@refs/tags/",
+				githubWorkflowTrigger: "push",
+				githubWorkflowSHA:     "0123456789abcdef0123456789abcdef01234567",
+			},
+		},
+		{
+			option: PRSigstoreSignedFulcioWithGitLabCIIdentity("group/project", "", "refs/heads/main"),
+			expected: fulcioCIIdentity{
+				oidcIssuer: "https://gitlab.com",
+				subjectURI: "https://gitlab.com/group/project//.gitlab-ci.yml@refs/heads/main",
+			},
+		},
+		{
+			option: prSigstoreSignedFulcioWithCIIdentity(prSigstoreSignedFulcioCIIdentity{
+				Provider:   FulcioCIProviderGitLabCI,
+				Repository: "group/project",
+				RefPrefix:  "refs/tags/",
+			}),
+			expected: fulcioCIIdentity{
+				oidcIssuer:       "https://gitlab.com",
+				subjectURIPrefix: "https://gitlab.com/group/project//.gitlab-ci.yml@refs/tags/",
+			},
+		},
+		{
+			option: PRSigstoreSignedFulcioWithGitLabCIIdentity("group/project", "ci/release.yml", ""),
+			expected: fulcioCIIdentity{
+				oidcIssuer:       "https://gitlab.com",
+				subjectURIPrefix: "https://gitlab.com/group/project//ci/release.yml@",
+			},
+		},
+		{
+			option: PRSigstoreSignedFulcioWithGoogleCloudBuildIdentity("123456789012"),
+			expected: fulcioCIIdentity{
+				oidcIssuer:   "https://accounts.google.com",
+				subjectEmail: "123456789012@cloudbuild.gserviceaccount.com",
+			},
+		},
+	} {
+		pr, err := newPRSigstoreSignedFulcio(PRSigstoreSignedFulcioWithCAPath("/foo/bar"), c.option)
+		require.NoError(t, err)
+		res, err := pr.CIIdentity.expectedIdentity()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, res)
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/containers/image/v5/directory"
@@ -115,6 +116,12 @@ var policyFixtureContents = &Policy{
 					PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepository()),
 				),
 			},
+			"example.com/external-evaluator-example": {
+				xNewPRExternalEvaluator([]string{"/usr/libexec/policy-evaluator", "--config", "/etc/policy-evaluator.json"}, 10),
+			},
+			"example.com/vulnerability-attestation-example": {
+				xNewPRVulnerabilityAttestationKeyPath("/keys/attestation-key.pub", VulnerabilitySeverityMedium, 604800),
+			},
 		},
 	},
 }
@@ -242,6 +249,64 @@ func TestNewPolicyFromFile(t *testing.T) {
 	assert.ErrorAs(t, err, &formatError)
 }
 
+func TestNewPolicyFromFileWithDropIns(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "policy.json")
+	dropInDir := filepath.Join(dir, "policy.d")
+	writeFile := func(path, contents string) {
+		err := os.WriteFile(path, []byte(contents), 0o644)
+		require.NoError(t, err)
+	}
+
+	// Only drop-in files, no policy.json; fragments are applied in lexical order.
+	err := os.Mkdir(dropInDir, 0o755)
+	require.NoError(t, err)
+	writeFile(filepath.Join(dropInDir, "10-default.json"), `{"default":[{"type":"reject"}]}`)
+	writeFile(filepath.Join(dropInDir, "20-example.json"),
+		`{"transports":{"docker":{"example.com":[{"type":"reject"}],"example.com/app":[{"type":"reject"}]}}}`)
+	writeFile(filepath.Join(dropInDir, "30-override.json"),
+		`{"transports":{"docker":{"example.com/app":[{"type":"insecureAcceptAnything"}]},"atomic":{"":[{"type":"reject"}]}}}`)
+	writeFile(filepath.Join(dropInDir, "ignored.txt"), `this is not JSON`)
+	err = os.Mkdir(filepath.Join(dropInDir, "ignored.json"), 0o755)
+	require.NoError(t, err)
+	policy, err := NewPolicyFromFile(policyPath)
+	require.NoError(t, err)
+	assert.Equal(t, &Policy{
+		Default: PolicyRequirements{NewPRReject()},
+		Transports: map[string]PolicyTransportScopes{
+			"docker": {
+				"example.com":     PolicyRequirements{NewPRReject()},
+				"example.com/app": PolicyRequirements{NewPRInsecureAcceptAnything()},
+			},
+			"atomic": {
+				"": PolicyRequirements{NewPRReject()},
+			},
+		},
+	}, policy)
+
+	// policy.json is applied before the drop-in files, which may override its default.
+	writeFile(policyPath, `{"default":[{"type":"insecureAcceptAnything"}],"transports":{"docker":{"other.example":[{"type":"reject"}]}}}`)
+	policy, err = NewPolicyFromFile(policyPath)
+	require.NoError(t, err)
+	assert.Equal(t, PolicyRequirements{NewPRReject()}, policy.Default)
+	assert.Equal(t, PolicyRequirements{NewPRReject()}, policy.Transports["docker"]["other.example"])
+	assert.Equal(t, PolicyRequirements{NewPRInsecureAcceptAnything()}, policy.Transports["docker"]["example.com/app"])
+
+	// No default anywhere
+	err = os.Remove(filepath.Join(dropInDir, "10-default.json"))
+	require.NoError(t, err)
+	err = os.Remove(policyPath)
+	require.NoError(t, err)
+	_, err = NewPolicyFromFile(policyPath)
+	var formatError InvalidPolicyFormatError
+	assert.ErrorAs(t, err, &formatError)
+
+	// An invalid fragment
+	writeFile(filepath.Join(dropInDir, "40-invalid.json"), `{"default":[{"type":"reject"}],"unknown":1}`)
+	_, err = NewPolicyFromFile(policyPath)
+	assert.ErrorContains(t, err, "40-invalid.json")
+}
+
 func TestNewPolicyFromBytes(t *testing.T) {
 	// Success
 	bytes, err := os.ReadFile("./fixtures/policy.json")
@@ -807,6 +872,60 @@ func TestNewPRSignedByKeyData(t *testing.T) {
 	// Failure cases tested in TestNewPRSignedBy.
 }
 
+func TestNewPRSignedByKeyFingerprint(t *testing.T) {
+	testIdentity := NewPRMMatchRepoDigestOrExact()
+
+	// Success
+	_pr, err := NewPRSignedByKeyFingerprintFromWKD(SBKeyTypeGPGKeys, strings.ToLower(TestKeyFingerprint), "user@example.com", testIdentity)
+	require.NoError(t, err)
+	pr, ok := _pr.(*prSignedBy)
+	require.True(t, ok)
+	assert.Equal(t, &prSignedBy{
+		prCommon:       prCommon{prTypeSignedBy},
+		KeyType:        SBKeyTypeGPGKeys,
+		KeyFingerprint: TestKeyFingerprint,
+		KeySource:      &prSignedByKeySource{WKD: "user@example.com"},
+		SignedIdentity: testIdentity,
+	}, pr)
+	_pr, err = NewPRSignedByKeyFingerprintFromKeyserver(SBKeyTypeGPGKeys, TestKeyFingerprint, "https://keys.example.com", testIdentity)
+	require.NoError(t, err)
+	pr, ok = _pr.(*prSignedBy)
+	require.True(t, ok)
+	assert.Equal(t, &prSignedBy{
+		prCommon:       prCommon{prTypeSignedBy},
+		KeyType:        SBKeyTypeGPGKeys,
+		KeyFingerprint: TestKeyFingerprint,
+		KeySource:      &prSignedByKeySource{Keyserver: "https://keys.example.com"},
+		SignedIdentity: testIdentity,
+	}, pr)
+
+	// Invalid keyType
+	_, err = NewPRSignedByKeyFingerprintFromWKD(sbKeyType("this is invalid"), TestKeyFingerprint, "user@example.com", testIdentity)
+	assert.Error(t, err)
+	// Invalid keyFingerprint
+	for _, fp := range []string{"", "1D8230F6", TestKeyFingerprint + "00", "0x" + TestKeyFingerprint, strings.Repeat("G", 40)} {
+		_, err = NewPRSignedByKeyFingerprintFromWKD(SBKeyTypeGPGKeys, fp, "user@example.com", testIdentity)
+		assert.Error(t, err, fp)
+	}
+	// Invalid key sources
+	for _, email := range []string{"", "user", "@example.com", "user@", "user@example.com/path"} {
+		_, err = NewPRSignedByKeyFingerprintFromWKD(SBKeyTypeGPGKeys, TestKeyFingerprint, email, testIdentity)
+		assert.Error(t, err, email)
+	}
+	for _, u := range []string{"", "keys.example.com", "ftp://keys.example.com", "https://", "https://%"} {
+		_, err = NewPRSignedByKeyFingerprintFromKeyserver(SBKeyTypeGPGKeys, TestKeyFingerprint, u, testIdentity)
+		assert.Error(t, err, u)
+	}
+	_, err = newPRSignedByKeyFingerprint(SBKeyTypeGPGKeys, TestKeyFingerprint, nil, testIdentity)
+	assert.Error(t, err)
+	_, err = newPRSignedByKeyFingerprint(SBKeyTypeGPGKeys, TestKeyFingerprint,
+		&prSignedByKeySource{WKD: "user@example.com", Keyserver: "https://keys.example.com"}, testIdentity)
+	assert.Error(t, err)
+	// Invalid signedIdentity
+	_, err = NewPRSignedByKeyFingerprintFromWKD(SBKeyTypeGPGKeys, TestKeyFingerprint, "user@example.com", nil)
+	assert.Error(t, err)
+}
+
 // Return the result of modifying validJSON with fn and unmarshaling it into *pr
 func tryUnmarshalModifiedSignedBy(t *testing.T, pr *prSignedBy, validJSON []byte, modifyFn func(mSA)) error {
 	var tmp mSA
@@ -858,6 +977,9 @@ func TestPRSignedByUnmarshalJSON(t *testing.T) {
 			func(v mSA) { v["signedIdentity"] = "this is invalid" },
 			// "signedIdentity" an explicit nil
 			func(v mSA) { v["signedIdentity"] = nil },
+			// Invalid "signedDigest" field
+			func(v mSA) { v["signedDigest"] = 1 },
+			func(v mSA) { v["signedDigest"] = "this is invalid" },
 		},
 		duplicateFields: []string{"type", "keyType", "keyData", "signedIdentity"},
 	}
@@ -880,6 +1002,44 @@ func TestPRSignedByUnmarshalJSON(t *testing.T) {
 		otherJSONParser: newPolicyRequirementFromJSON,
 		duplicateFields: []string{"type", "keyType", "keyPaths", "signedIdentity"},
 	}.run(t)
+	// Test the keyFingerprint-specific aspects
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSignedBy{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSignedByKeyFingerprintFromKeyserver(SBKeyTypeGPGKeys, TestKeyFingerprint, "https://keys.example.com", NewPRMMatchRepoDigestOrExact())
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// "keyFingerprint" together with another key source
+			func(v mSA) { v["keyPath"] = "/foo/bar" },
+			func(v mSA) { v["keyData"] = "" },
+			// Invalid "keyFingerprint" field
+			func(v mSA) { v["keyFingerprint"] = 1 },
+			func(v mSA) { v["keyFingerprint"] = "this is invalid" },
+			// The "keySource" field is missing
+			func(v mSA) { delete(v, "keySource") },
+			// "keySource" without "keyFingerprint"
+			func(v mSA) { delete(v, "keyFingerprint"); v["keyPath"] = "/foo/bar" },
+			// Invalid "keySource" field
+			func(v mSA) { v["keySource"] = 1 },
+			func(v mSA) { v["keySource"] = nil },
+			func(v mSA) { v["keySource"] = mSA{} },
+			func(v mSA) { v["keySource"] = mSA{"unexpected": 1} },
+			func(v mSA) { v["keySource"] = mSA{"wkd": "user@example.com", "keyserver": "https://keys.example.com"} },
+			func(v mSA) { v["keySource"] = mSA{"wkd": "this is invalid"} },
+			func(v mSA) { v["keySource"] = mSA{"keyserver": "this is invalid"} },
+			func(v mSA) { v["keySource"] = mSA{"keyserver": 1} },
+		},
+		duplicateFields: []string{"type", "keyType", "keyFingerprint", "keySource", "signedIdentity"},
+	}.run(t)
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prSignedBy{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRSignedByKeyFingerprintFromWKD(SBKeyTypeGPGKeys, TestKeyFingerprint, "user@example.com", NewPRMMatchRepoDigestOrExact())
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		duplicateFields: []string{"type", "keyType", "keyFingerprint", "keySource", "signedIdentity"},
+	}.run(t)
 
 	var pr prSignedBy
 
@@ -890,12 +1050,19 @@ func TestPRSignedByUnmarshalJSON(t *testing.T) {
 	allowedModificationFns := []func(mSA){
 		// Delete the signedIdentity field
 		func(v mSA) { delete(v, "signedIdentity") },
+		// Set the signedDigest field
+		func(v mSA) { v["signedDigest"] = "either" },
 	}
 	for _, fn := range allowedModificationFns {
 		err := tryUnmarshalModifiedSignedBy(t, &pr, validJSON, fn)
 		require.NoError(t, err)
 	}
 
+	// signedDigest is preserved
+	err := tryUnmarshalModifiedSignedBy(t, &pr, validJSON, func(v mSA) { v["signedDigest"] = "index" })
+	require.NoError(t, err)
+	assert.Equal(t, SignedDigestIndex, pr.SignedDigest)
+
 	// Various ways to set signedIdentity to the default value
 	signedIdentityDefaultFns := []func(mSA){
 		// Set signedIdentity to the default explicitly
@@ -954,6 +1121,47 @@ func TestSBKeyTypeUnmarshalJSON(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSignedDigestScopeIsValid(t *testing.T) {
+	// Valid values
+	for _, s := range []signedDigestScope{
+		SignedDigestInstance,
+		SignedDigestIndex,
+		SignedDigestEither,
+	} {
+		assert.True(t, s.IsValid())
+	}
+
+	// Invalid values
+	for _, s := range []string{"", "this is invalid"} {
+		assert.False(t, signedDigestScope(s).IsValid())
+	}
+}
+
+func TestSignedDigestScopeUnmarshalJSON(t *testing.T) {
+	var s signedDigestScope
+
+	testInvalidJSONInput(t, &s)
+
+	// Valid values.
+	for _, v := range []signedDigestScope{
+		SignedDigestInstance,
+		SignedDigestIndex,
+		SignedDigestEither,
+	} {
+		s = signedDigestScope("")
+		err := json.Unmarshal([]byte(`"`+string(v)+`"`), &s)
+		assert.NoError(t, err)
+		assert.Equal(t, v, s)
+	}
+
+	// Invalid values
+	for _, v := range []string{`""`, `"this is invalid"`} {
+		s = signedDigestScope("")
+		err := json.Unmarshal([]byte(v), &s)
+		assert.Error(t, err)
+	}
+}
+
 // NewPRSignedBaseLayer is like NewPRSignedBaseLayer, except it must not fail.
 func xNewPRSignedBaseLayer(baseLayerIdentity PolicyReferenceMatch) PolicyRequirement {
 	pr, err := NewPRSignedBaseLayer(baseLayerIdentity)
@@ -1009,6 +1217,220 @@ func TestPRSignedBaseLayerUnmarshalJSON(t *testing.T) {
 	}.run(t)
 }
 
+// xNewPRExternalEvaluator is like NewPRExternalEvaluator, except it must not fail.
+func xNewPRExternalEvaluator(command []string, timeoutSeconds int) PolicyRequirement {
+	pr, err := NewPRExternalEvaluator(command, timeoutSeconds)
+	if err != nil {
+		panic("xNewPRExternalEvaluator failed")
+	}
+	return pr
+}
+
+func TestNewPRExternalEvaluator(t *testing.T) {
+	// Success
+	for _, c := range []struct {
+		command        []string
+		timeoutSeconds int
+	}{
+		{[]string{"/usr/bin/evaluator"}, 0},
+		{[]string{"/usr/bin/evaluator", "--flag", "value"}, 5},
+	} {
+		_pr, err := NewPRExternalEvaluator(c.command, c.timeoutSeconds)
+		require.NoError(t, err)
+		pr, ok := _pr.(*prExternalEvaluator)
+		require.True(t, ok)
+		assert.Equal(t, &prExternalEvaluator{
+			prCommon:       prCommon{prTypeExternalEvaluator},
+			Command:        c.command,
+			TimeoutSeconds: c.timeoutSeconds,
+		}, pr)
+	}
+
+	// Invalid command or timeoutSeconds
+	for _, c := range []struct {
+		command        []string
+		timeoutSeconds int
+	}{
+		{nil, 0},
+		{[]string{}, 0},
+		{[]string{"evaluator"}, 0},
+		{[]string{""}, 0},
+		{[]string{"/usr/bin/evaluator"}, -1},
+	} {
+		_, err := NewPRExternalEvaluator(c.command, c.timeoutSeconds)
+		assert.Error(t, err, "%#v", c)
+	}
+}
+
+func TestPRExternalEvaluatorUnmarshalJSON(t *testing.T) {
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prExternalEvaluator{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRExternalEvaluator([]string{"/usr/bin/evaluator", "--flag"}, 5)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// The "type" field is missing
+			func(v mSA) { delete(v, "type") },
+			// Wrong "type" field
+			func(v mSA) { v["type"] = 1 },
+			func(v mSA) { v["type"] = "this is invalid" },
+			// Extra top-level sub-object
+			func(v mSA) { v["unexpected"] = 1 },
+			// The "command" field is missing
+			func(v mSA) { delete(v, "command") },
+			// Invalid "command" field
+			func(v mSA) { v["command"] = "/usr/bin/evaluator" },
+			func(v mSA) { v["command"] = []any{} },
+			func(v mSA) { v["command"] = []any{"relative"} },
+			func(v mSA) { v["command"] = []any{1} },
+			// Invalid "timeoutSeconds" field
+			func(v mSA) { v["timeoutSeconds"] = "5" },
+			func(v mSA) { v["timeoutSeconds"] = -1 },
+		},
+		duplicateFields: []string{"type", "command", "timeoutSeconds"},
+	}.run(t)
+
+	// The "timeoutSeconds" field is optional
+	var pr prExternalEvaluator
+	err := json.Unmarshal([]byte(`{"type":"externalEvaluator","command":["/usr/bin/evaluator"]}`), &pr)
+	require.NoError(t, err)
+	assert.Equal(t, prExternalEvaluator{
+		prCommon: prCommon{prTypeExternalEvaluator},
+		Command:  []string{"/usr/bin/evaluator"},
+	}, pr)
+}
+
+// xNewPRVulnerabilityAttestationKeyPath is like NewPRVulnerabilityAttestationKeyPath, except it must not fail.
+func xNewPRVulnerabilityAttestationKeyPath(keyPath string, maxSeverity vulnerabilitySeverity, maxScanAgeSeconds int) PolicyRequirement {
+	pr, err := NewPRVulnerabilityAttestationKeyPath(keyPath, maxSeverity, maxScanAgeSeconds)
+	if err != nil {
+		panic("xNewPRVulnerabilityAttestationKeyPath failed")
+	}
+	return pr
+}
+
+func TestNewPRVulnerabilityAttestation(t *testing.T) {
+	const testPath = "/foo/bar"
+	testPaths := []string{"/path/1", "/path/2"}
+	testData := []byte("abc")
+
+	// Success
+	pr, err := newPRVulnerabilityAttestation(testPath, nil, nil, VulnerabilitySeverityHigh, 3600)
+	require.NoError(t, err)
+	assert.Equal(t, &prVulnerabilityAttestation{
+		prCommon:          prCommon{prTypeVulnerabilityAttestation},
+		KeyPath:           testPath,
+		MaxSeverity:       VulnerabilitySeverityHigh,
+		MaxScanAgeSeconds: 3600,
+	}, pr)
+	pr, err = newPRVulnerabilityAttestation("", testPaths, nil, VulnerabilitySeverityNone, 0)
+	require.NoError(t, err)
+	assert.Equal(t, &prVulnerabilityAttestation{
+		prCommon:    prCommon{prTypeVulnerabilityAttestation},
+		KeyPaths:    testPaths,
+		MaxSeverity: VulnerabilitySeverityNone,
+	}, pr)
+	pr, err = newPRVulnerabilityAttestation("", nil, testData, VulnerabilitySeverityCritical, 0)
+	require.NoError(t, err)
+	assert.Equal(t, &prVulnerabilityAttestation{
+		prCommon:    prCommon{prTypeVulnerabilityAttestation},
+		KeyData:     testData,
+		MaxSeverity: VulnerabilitySeverityCritical,
+	}, pr)
+
+	// Invalid values
+	for _, c := range []struct {
+		keyPath           string
+		keyPaths          []string
+		keyData           []byte
+		maxSeverity       vulnerabilitySeverity
+		maxScanAgeSeconds int
+	}{
+		// No key source
+		{"", nil, nil, VulnerabilitySeverityLow, 0},
+		// More than one key source
+		{testPath, testPaths, nil, VulnerabilitySeverityLow, 0},
+		{testPath, nil, testData, VulnerabilitySeverityLow, 0},
+		{"", testPaths, testData, VulnerabilitySeverityLow, 0},
+		// Empty keyPaths
+		{"", []string{}, nil, VulnerabilitySeverityLow, 0},
+		// Invalid maxSeverity
+		{testPath, nil, nil, "", 0},
+		{testPath, nil, nil, "this is invalid", 0},
+		// Invalid maxScanAgeSeconds
+		{testPath, nil, nil, VulnerabilitySeverityLow, -1},
+	} {
+		_, err := newPRVulnerabilityAttestation(c.keyPath, c.keyPaths, c.keyData, c.maxSeverity, c.maxScanAgeSeconds)
+		assert.Error(t, err, "%#v", c)
+	}
+
+	// Public constructors
+	_pr, err := NewPRVulnerabilityAttestationKeyPath(testPath, VulnerabilitySeverityLow, 0)
+	require.NoError(t, err)
+	assert.Equal(t, testPath, _pr.(*prVulnerabilityAttestation).KeyPath)
+	_pr, err = NewPRVulnerabilityAttestationKeyPaths(testPaths, VulnerabilitySeverityLow, 0)
+	require.NoError(t, err)
+	assert.Equal(t, testPaths, _pr.(*prVulnerabilityAttestation).KeyPaths)
+	_pr, err = NewPRVulnerabilityAttestationKeyData(testData, VulnerabilitySeverityLow, 0)
+	require.NoError(t, err)
+	assert.Equal(t, testData, _pr.(*prVulnerabilityAttestation).KeyData)
+}
+
+func TestPRVulnerabilityAttestationUnmarshalJSON(t *testing.T) {
+	keyDataTests := policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prVulnerabilityAttestation{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRVulnerabilityAttestationKeyData([]byte("abc"), VulnerabilitySeverityMedium, 3600)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		breakFns: []func(mSA){
+			// The "type" field is missing
+			func(v mSA) { delete(v, "type") },
+			// Wrong "type" field
+			func(v mSA) { v["type"] = 1 },
+			func(v mSA) { v["type"] = "this is invalid" },
+			// Extra top-level sub-object
+			func(v mSA) { v["unexpected"] = 1 },
+			// No key source
+			func(v mSA) { delete(v, "keyData") },
+			// More than one key source
+			func(v mSA) { v["keyPath"] = "/foo/bar" },
+			func(v mSA) { v["keyPaths"] = []string{"/foo/bar"} },
+			// Invalid "keyData" field
+			func(v mSA) { v["keyData"] = 1 },
+			func(v mSA) { v["keyData"] = "this is invalid base64" },
+			// The "maxSeverity" field is missing
+			func(v mSA) { delete(v, "maxSeverity") },
+			// Invalid "maxSeverity" field
+			func(v mSA) { v["maxSeverity"] = 1 },
+			func(v mSA) { v["maxSeverity"] = "this is invalid" },
+			// Invalid "maxScanAgeSeconds" field
+			func(v mSA) { v["maxScanAgeSeconds"] = "5" },
+			func(v mSA) { v["maxScanAgeSeconds"] = -1 },
+		},
+		duplicateFields: []string{"type", "keyData", "maxSeverity", "maxScanAgeSeconds"},
+	}
+	keyDataTests.run(t)
+	// Test keyPath and keyPaths-specific duplicate fields
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prVulnerabilityAttestation{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRVulnerabilityAttestationKeyPath("/foo/bar", VulnerabilitySeverityLow, 0)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		duplicateFields: []string{"type", "keyPath", "maxSeverity"},
+	}.run(t)
+	policyJSONUmarshallerTests[PolicyRequirement]{
+		newDest: func() json.Unmarshaler { return &prVulnerabilityAttestation{} },
+		newValidObject: func() (PolicyRequirement, error) {
+			return NewPRVulnerabilityAttestationKeyPaths([]string{"/path/1", "/path/2"}, VulnerabilitySeverityLow, 0)
+		},
+		otherJSONParser: newPolicyRequirementFromJSON,
+		duplicateFields: []string{"type", "keyPaths", "maxSeverity"},
+	}.run(t)
+}
+
 func TestNewPolicyReferenceMatchFromJSON(t *testing.T) {
 	// Sample success. Others tested in the individual PolicyReferenceMatch.UnmarshalJSON implementations.
 	validPRM := NewPRMMatchRepoDigestOrExact()
@@ -1338,3 +1760,55 @@ func TestPRMRemapIdentityUnmarshalJSON(t *testing.T) {
 		duplicateFields: []string{"type", "prefix", "signedPrefix"},
 	}.run(t)
 }
+
+func TestNewPRMMatchArtifact(t *testing.T) {
+	for _, repo := range []string{"", "quay.io/charts/mychart"} {
+		_prm, err := NewPRMMatchArtifact(repo)
+		require.NoError(t, err, repo)
+		prm, ok := _prm.(*prmMatchArtifact)
+		require.True(t, ok)
+		assert.Equal(t, &prmMatchArtifact{
+			prmCommon:        prmCommon{prmTypeMatchArtifact},
+			DockerRepository: repo,
+		}, prm)
+	}
+
+	for _, repo := range []string{
+		"UPPERCASEISINVALID",
+		"quay.io/charts/mychart:1.0",
+		"quay.io/charts/mychart@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	} {
+		_, err := NewPRMMatchArtifact(repo)
+		assert.Error(t, err, repo)
+	}
+}
+
+func TestPRMMatchArtifactUnmarshalJSON(t *testing.T) {
+	policyJSONUmarshallerTests[PolicyReferenceMatch]{
+		newDest: func() json.Unmarshaler { return &prmMatchArtifact{} },
+		newValidObject: func() (PolicyReferenceMatch, error) {
+			return NewPRMMatchArtifact("quay.io/charts/mychart")
+		},
+		otherJSONParser: newPolicyReferenceMatchFromJSON,
+		breakFns: []func(mSA){
+			// The "type" field is missing
+			func(v mSA) { delete(v, "type") },
+			// Wrong "type" field
+			func(v mSA) { v["type"] = 1 },
+			func(v mSA) { v["type"] = "this is invalid" },
+			// Extra top-level sub-object
+			func(v mSA) { v["unexpected"] = 1 },
+			// Invalid "dockerRepository" field
+			func(v mSA) { v["dockerRepository"] = 1 },
+			func(v mSA) { v["dockerRepository"] = "UPPERCASEISINVALID" },
+			func(v mSA) { v["dockerRepository"] = "quay.io/charts/mychart:1.0" },
+		},
+		duplicateFields: []string{"type", "dockerRepository"},
+	}.run(t)
+
+	// "dockerRepository" is optional
+	var prm prmMatchArtifact
+	err := json.Unmarshal([]byte(`{"type":"matchArtifact"}`), &prm)
+	require.NoError(t, err)
+	assert.Equal(t, prmMatchArtifact{prmCommon: prmCommon{prmTypeMatchArtifact}}, prm)
+}
@@ -9,10 +9,10 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/unparsedimage"
 	"github.com/containers/image/v5/types"
-	"github.com/sirupsen/logrus"
 )
 
 // PolicyRequirementError is an explanatory text for rejecting a signature or an image.
@@ -79,8 +79,9 @@ type PolicyReferenceMatch interface {
 // PolicyContext encapsulates a policy and possible cached state
 // for speeding up its evaluation.
 type PolicyContext struct {
-	Policy *Policy
-	state  policyContextState // Internal consistency checking
+	Policy  *Policy
+	state   policyContextState // Internal consistency checking
+	metrics policyContextMetrics
 }
 
 // policyContextState is used internally to verify the users are not misusing a PolicyContext.
@@ -105,6 +106,7 @@ func (pc *PolicyContext) changeState(expected, new policyContextState) error {
 
 // NewPolicyContext sets up and initializes a context for the specified policy.
 // The policy must not be modified while the context exists. FIXME: make a deep copy?
+// Sigstore public keys and trust roots referenced by the policy are read once per context, when they are first used.
 // If this function succeeds, the caller should call PolicyContext.Destroy() when done.
 func NewPolicyContext(policy *Policy) (*PolicyContext, error) {
 	pc := &PolicyContext{Policy: policy, state: pcInitializing}
@@ -140,26 +142,26 @@ func (pc *PolicyContext) requirementsForImageRef(ref types.ImageReference) Polic
 		// Look for a full match.
 		identity := ref.PolicyConfigurationIdentity()
 		if req, ok := transportScopes[identity]; ok {
-			logrus.Debugf(` Using transport %q policy section %q`, transportName, identity)
+			log.Debugf(` Using transport %q policy section %q`, transportName, identity)
 			return req
 		}
 
 		// Look for a match of the possible parent namespaces.
 		for _, name := range ref.PolicyConfigurationNamespaces() {
 			if req, ok := transportScopes[name]; ok {
-				logrus.Debugf(` Using transport %q specific policy section %q`, transportName, name)
+				log.Debugf(` Using transport %q specific policy section %q`, transportName, name)
 				return req
 			}
 		}
 
 		// Look for a default match for the transport.
 		if req, ok := transportScopes[""]; ok {
-			logrus.Debugf(` Using transport %q policy section ""`, transportName)
+			log.Debugf(` Using transport %q policy section ""`, transportName)
 			return req
 		}
 	}
 
-	logrus.Debugf(" Using default policy section")
+	log.Debugf(" Using default policy section")
 	return pc.Policy.Default
 }
 
@@ -187,9 +189,10 @@ func (pc *PolicyContext) GetSignaturesWithAcceptedAuthor(ctx context.Context, pu
 		}
 	}()
 
+	ctx = pc.startEvaluation(ctx)
 	image := unparsedimage.FromPublic(publicImage)
 
-	logrus.Debugf("GetSignaturesWithAcceptedAuthor for image %s", policyIdentityLogName(image.Reference()))
+	log.DebugfContext(ctx, "GetSignaturesWithAcceptedAuthor for image %s", policyIdentityLogName(image.Reference()))
 	reqs := pc.requirementsForImageRef(image.Reference())
 
 	// FIXME: Use image.UntrustedSignatures, use that to improve error messages (needs tests!)
@@ -203,51 +206,53 @@ func (pc *PolicyContext) GetSignaturesWithAcceptedAuthor(ctx context.Context, pu
 		var acceptedSig *Signature // non-nil if accepted
 		rejected := false
 		// FIXME? Say more about the contents of the signature, i.e. parse it even before verification?!
-		logrus.Debugf("Evaluating signature %d:", sigNumber)
+		log.DebugfContext(ctx, "Evaluating signature %d:", sigNumber)
 	interpretingReqs:
 		for reqNumber, req := range reqs {
 			// FIXME: Log the requirement itself? For now, we use just the number.
 			// FIXME: supply state
 			switch res, as, err := req.isSignatureAuthorAccepted(ctx, image, sig); res {
 			case sarAccepted:
+				pc.recordDecision(req, true)
 				if as == nil { // Coverage: this should never happen
-					logrus.Debugf(" Requirement %d: internal inconsistency: sarAccepted but no parsed contents", reqNumber)
+					log.DebugfContext(ctx, " Requirement %d: internal inconsistency: sarAccepted but no parsed contents", reqNumber)
 					rejected = true
 					break interpretingReqs
 				}
-				logrus.Debugf(" Requirement %d: signature accepted", reqNumber)
+				log.DebugfContext(ctx, " Requirement %d: signature accepted", reqNumber)
 				if acceptedSig == nil {
 					acceptedSig = as
 				} else if *as != *acceptedSig { // Coverage: this should never happen
 					// Huh?! Two ways of verifying the same signature blob resulted in two different parses of its already accepted contents?
-					logrus.Debugf(" Requirement %d: internal inconsistency: sarAccepted but different parsed contents", reqNumber)
+					log.DebugfContext(ctx, " Requirement %d: internal inconsistency: sarAccepted but different parsed contents", reqNumber)
 					rejected = true
 					acceptedSig = nil
 					break interpretingReqs
 				}
 			case sarRejected:
-				logrus.Debugf(" Requirement %d: signature rejected: %s", reqNumber, err.Error())
+				pc.recordDecision(req, false)
+				log.DebugfContext(ctx, " Requirement %d: signature rejected: %s", reqNumber, err.Error())
 				rejected = true
 				break interpretingReqs
 			case sarUnknown:
 				if err != nil { // Coverage: this should never happen
-					logrus.Debugf(" Requirement %d: internal inconsistency: sarUnknown but an error message %s", reqNumber, err.Error())
+					log.DebugfContext(ctx, " Requirement %d: internal inconsistency: sarUnknown but an error message %s", reqNumber, err.Error())
 					rejected = true
 					break interpretingReqs
 				}
-				logrus.Debugf(" Requirement %d: signature state unknown, continuing", reqNumber)
+				log.DebugfContext(ctx, " Requirement %d: signature state unknown, continuing", reqNumber)
 			default: // Coverage: this should never happen
-				logrus.Debugf(" Requirement %d: internal inconsistency: unknown result %#v", reqNumber, string(res))
+				log.DebugfContext(ctx, " Requirement %d: internal inconsistency: unknown result %#v", reqNumber, string(res))
 				rejected = true
 				break interpretingReqs
 			}
 		}
 		// This also handles the (invalid) case of empty reqs, by rejecting the signature.
 		if acceptedSig != nil && !rejected {
-			logrus.Debugf(" Overall: OK, signature accepted")
+			log.DebugfContext(ctx, " Overall: OK, signature accepted")
 			res = append(res, acceptedSig)
 		} else {
-			logrus.Debugf(" Overall: Signature not accepted")
+			log.DebugfContext(ctx, " Overall: Signature not accepted")
 		}
 	}
 	return res, nil
@@ -258,36 +263,62 @@ func (pc *PolicyContext) GetSignaturesWithAcceptedAuthor(ctx context.Context, pu
 // succeeded but the result was rejection.
 // WARNING: This validates signatures and the manifest, but does not download or validate the
 // layers. Users must validate that the layers match their expected digests.
-func (pc *PolicyContext) IsRunningImageAllowed(ctx context.Context, publicImage types.UnparsedImage) (res bool, finalErr error) {
+func (pc *PolicyContext) IsRunningImageAllowed(ctx context.Context, publicImage types.UnparsedImage) (bool, error) {
+	res, _, err := pc.IsRunningImageAllowedWithRejection(ctx, publicImage)
+	return res, err
+}
+
+// IsRunningImageAllowedWithRejection is IsRunningImageAllowed, which also returns a description of the rejected signatures
+// if a signature-verifying requirement rejected the image because none of its signatures were accepted;
+// rejection is nil otherwise, including when the image was rejected for other reasons.
+// WARNING: This validates signatures and the manifest, but does not download or validate the
+// layers. Users must validate that the layers match their expected digests.
+func (pc *PolicyContext) IsRunningImageAllowedWithRejection(ctx context.Context, publicImage types.UnparsedImage) (res bool, rejection *PolicyRejection, finalErr error) {
 	if err := pc.changeState(pcReady, pcInUse); err != nil {
-		return false, err
+		return false, nil, err
 	}
 	defer func() {
 		if err := pc.changeState(pcInUse, pcReady); err != nil {
 			res = false
+			rejection = nil
 			finalErr = err
 		}
 	}()
 
+	ctx = pc.startEvaluation(ctx)
 	image := unparsedimage.FromPublic(publicImage)
 
-	logrus.Debugf("IsRunningImageAllowed for image %s", policyIdentityLogName(image.Reference()))
+	log.DebugfContext(ctx, "IsRunningImageAllowed for image %s", policyIdentityLogName(image.Reference()))
 	reqs := pc.requirementsForImageRef(image.Reference())
 
 	if len(reqs) == 0 {
-		return false, PolicyRequirementError("List of verification policy requirements must not be empty")
+		return false, nil, PolicyRequirementError("List of verification policy requirements must not be empty")
 	}
 
 	for reqNumber, req := range reqs {
 		// FIXME: supply state
-		allowed, err := req.isRunningImageAllowed(ctx, image)
+		var allowed bool
+		var reqRejection *PolicyRejection
+		var err error
+		if sr, ok := req.(signatureRejectingRequirement); ok {
+			allowed, reqRejection, err = sr.evaluateSignatures(ctx, image)
+		} else {
+			allowed, err = req.isRunningImageAllowed(ctx, image)
+		}
+		pc.recordDecision(req, allowed)
 		if !allowed {
-			logrus.Debugf("Requirement %d: denied, done", reqNumber)
-			return false, err
+			log.DebugfContext(ctx, "Requirement %d: denied, done", reqNumber)
+			if reqRejection != nil {
+				reqRejection.RequirementIndex = reqNumber
+				for i := range reqRejection.Rejections {
+					reqRejection.Rejections[i].RequirementIndex = reqNumber
+				}
+			}
+			return false, reqRejection, err
 		}
-		logrus.Debugf(" Requirement %d: allowed", reqNumber)
+		log.DebugfContext(ctx, " Requirement %d: allowed", reqNumber)
 	}
 	// We have tested that len(reqs) != 0, so at least one req must have explicitly allowed this image.
-	logrus.Debugf("Overall: allowed")
-	return true, nil
+	log.DebugfContext(ctx, "Overall: allowed")
+	return true, nil, nil
 }
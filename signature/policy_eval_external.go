@@ -0,0 +1,175 @@
+// Policy evaluation for prExternalEvaluator.
+
+package signature
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/transports"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// defaultExternalEvaluatorTimeout is the time an external evaluator may run if TimeoutSeconds is not specified.
+const defaultExternalEvaluatorTimeout = 30 * time.Second
+
+// externalEvaluatorRequest is the JSON object written to the standard input of an external evaluator.
+type externalEvaluatorRequest struct {
+	ImageReference   string                       `json:"imageReference"`
+	DockerReference  string                       `json:"dockerReference,omitempty"`
+	ManifestMIMEType string                       `json:"manifestMIMEType"`
+	Manifest         []byte                       `json:"manifest"`
+	ManifestDigest   digest.Digest                `json:"manifestDigest"`
+	Signatures       []externalEvaluatorSignature `json:"signatures"`
+}
+
+// externalEvaluatorSignature is a single, unverified, signature in externalEvaluatorRequest.
+type externalEvaluatorSignature struct {
+	Format string `json:"format"` // A signature.FormatID value
+	// Set for simple signing signatures:
+	Signature []byte `json:"signature,omitempty"`
+	// Set for sigstore signatures:
+	MIMEType    string            `json:"mimeType,omitempty"`
+	Payload     []byte            `json:"payload,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// externalEvaluatorResponse is the JSON object an external evaluator must write to its standard output.
+type externalEvaluatorResponse struct {
+	Allowed *bool  `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+func (pr *prExternalEvaluator) isSignatureAuthorAccepted(ctx context.Context, image private.UnparsedImage, sig []byte) (signatureAcceptanceResult, *Signature, error) {
+	// The evaluator decides about whole images, not about individual signatures.
+	return sarUnknown, nil, nil
+}
+
+func (pr *prExternalEvaluator) isRunningImageAllowed(ctx context.Context, image private.UnparsedImage) (bool, error) {
+	request, err := pr.prepareRequest(ctx, image)
+	if err != nil {
+		return false, err
+	}
+	response, err := pr.run(ctx, request)
+	if err != nil {
+		return false, err
+	}
+	if !*response.Allowed {
+		reason := response.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return false, PolicyRequirementError(fmt.Sprintf("Image rejected by external evaluator %q: %s", pr.Command[0], reason))
+	}
+	return true, nil
+}
+
+// prepareRequest collects the data about image passed to the evaluator.
+func (pr *prExternalEvaluator) prepareRequest(ctx context.Context, image private.UnparsedImage) (*externalEvaluatorRequest, error) {
+	m, mimeType, err := image.Manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(m)
+	if err != nil {
+		return nil, err
+	}
+	sigs, err := image.UntrustedSignatures(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := externalEvaluatorRequest{
+		ImageReference:   transports.ImageName(image.Reference()),
+		ManifestMIMEType: mimeType,
+		Manifest:         m,
+		ManifestDigest:   manifestDigest,
+		Signatures:       []externalEvaluatorSignature{},
+	}
+	if dockerRef := image.Reference().DockerReference(); dockerRef != nil {
+		res.DockerReference = dockerRef.String()
+	}
+	for _, sig := range sigs {
+		s := externalEvaluatorSignature{Format: string(sig.FormatID())}
+		switch sig := sig.(type) {
+		case signature.SimpleSigning:
+			s.Signature = sig.UntrustedSignature()
+		case signature.Sigstore:
+			s.MIMEType = sig.UntrustedMIMEType()
+			s.Payload = sig.UntrustedPayload()
+			s.Annotations = sig.UntrustedAnnotations()
+		default:
+			// Pass the format along anyway; the evaluator may still want to know that such a signature exists.
+		}
+		res.Signatures = append(res.Signatures, s)
+	}
+	return &res, nil
+}
+
+// run runs the evaluator with request, and returns its response.
+func (pr *prExternalEvaluator) run(ctx context.Context, request *externalEvaluatorRequest) (*externalEvaluatorResponse, error) {
+	if len(pr.Command) == 0 { // newPRExternalEvaluator rejects such values.
+		return nil, errors.New(`Internal inconsistency: "command" not specified`)
+	}
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultExternalEvaluatorTimeout
+	if pr.TimeoutSeconds != 0 {
+		timeout = time.Duration(pr.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, pr.Command[0], pr.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	// Don’t wait indefinitely for any child processes of a killed evaluator to close stdout/stderr.
+	cmd.WaitDelay = time.Second
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("running external policy evaluator %q: %w", pr.Command[0], err)
+	}
+	stdout, readErr := iolimits.ReadAtMost(stdoutPipe, iolimits.MaxExternalEvaluatorOutputSize)
+	if readErr != nil {
+		cancel() // Terminate the evaluator instead of waiting for it to exit on its own.
+	}
+	err = cmd.Wait()
+	if readErr != nil {
+		return nil, fmt.Errorf("reading output of external policy evaluator %q: %w", pr.Command[0], readErr)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running external policy evaluator %q: %w: %s", pr.Command[0], err, msg)
+		}
+		return nil, fmt.Errorf("running external policy evaluator %q: %w", pr.Command[0], err)
+	}
+
+	var response externalEvaluatorResponse
+	if err := json.Unmarshal(stdout, &response); err != nil {
+		return nil, fmt.Errorf("parsing response of external policy evaluator %q: %w", pr.Command[0], err)
+	}
+	if response.Allowed == nil {
+		return nil, fmt.Errorf(`response of external policy evaluator %q does not contain "allowed"`, pr.Command[0])
+	}
+	return &response, nil
+}
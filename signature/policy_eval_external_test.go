@@ -0,0 +1,139 @@
+package signature
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedRefImageReferenceMock is like refImageReferenceMock, but it also implements StringWithinTransport.
+type namedRefImageReferenceMock struct {
+	refImageReferenceMock
+}
+
+func (ref namedRefImageReferenceMock) StringWithinTransport() string {
+	return ref.ref.String()
+}
+
+// writeEvaluatorScript creates an external evaluator shell script with the specified body, and returns its path.
+func writeEvaluatorScript(t *testing.T, body string) string {
+	path := filepath.Join(t.TempDir(), "evaluator")
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755)
+	require.NoError(t, err)
+	return path
+}
+
+func TestPRExternalEvaluatorIsSignatureAuthorAccepted(t *testing.T) {
+	pr, err := NewPRExternalEvaluator([]string{"/bin/false"}, 0)
+	require.NoError(t, err)
+	// Pass nil pointers to, kind of, test that the return value does not depend on the parameters.
+	sar, parsedSig, err := pr.isSignatureAuthorAccepted(context.Background(), nil, nil)
+	assertSARUnknown(t, sar, parsedSig, err)
+}
+
+func TestPRExternalEvaluatorIsRunningImageAllowed(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh is not available")
+	}
+
+	ref, err := reference.ParseNormalizedNamed("testing/manifest:latest")
+	require.NoError(t, err)
+	image := dirImageMockWithRef(t, "fixtures/dir-img-valid", namedRefImageReferenceMock{refImageReferenceMock{ref: ref}})
+
+	// Accepted; also check the contents of the request
+	requestPath := filepath.Join(t.TempDir(), "request.json")
+	script := writeEvaluatorScript(t, `cat > "$1"; echo '{"allowed":true}'`)
+	pr, err := NewPRExternalEvaluator([]string{script, requestPath}, 0)
+	require.NoError(t, err)
+	res, err := pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningAllowed(t, res, err)
+
+	requestBytes, err := os.ReadFile(requestPath)
+	require.NoError(t, err)
+	var request externalEvaluatorRequest
+	err = json.Unmarshal(requestBytes, &request)
+	require.NoError(t, err)
+	manifestBytes, err := os.ReadFile("fixtures/dir-img-valid/manifest.json")
+	require.NoError(t, err)
+	manifestDigest, err := manifest.Digest(manifestBytes)
+	require.NoError(t, err)
+	sigBytes, err := os.ReadFile("fixtures/dir-img-valid/signature-1")
+	require.NoError(t, err)
+	assert.Equal(t, externalEvaluatorRequest{
+		ImageReference:   "== Transport mock:docker.io/testing/manifest:latest",
+		DockerReference:  "docker.io/testing/manifest:latest",
+		ManifestMIMEType: manifest.GuessMIMEType(manifestBytes),
+		Manifest:         manifestBytes,
+		ManifestDigest:   manifestDigest,
+		Signatures: []externalEvaluatorSignature{
+			{Format: string(signature.SimpleSigningFormat), Signature: sigBytes},
+		},
+	}, request)
+
+	// An image without a Docker reference
+	dirRef, err := directory.NewReference("fixtures/dir-img-unsigned")
+	require.NoError(t, err)
+	unsignedImage := dirImageMockWithRef(t, "fixtures/dir-img-unsigned", dirRef)
+	res, err = pr.isRunningImageAllowed(context.Background(), unsignedImage)
+	assertRunningAllowed(t, res, err)
+	requestBytes, err = os.ReadFile(requestPath)
+	require.NoError(t, err)
+	request = externalEvaluatorRequest{}
+	err = json.Unmarshal(requestBytes, &request)
+	require.NoError(t, err)
+	assert.Equal(t, "dir:"+dirRef.StringWithinTransport(), request.ImageReference)
+	assert.Equal(t, "", request.DockerReference)
+	assert.Empty(t, request.Signatures)
+
+	// Rejected by the evaluator
+	for _, c := range []struct{ response, expectedReason string }{
+		{`{"allowed":false,"reason":"not on the allow list"}`, "not on the allow list"},
+		{`{"allowed":false}`, "no reason given"},
+	} {
+		script := writeEvaluatorScript(t, "cat > /dev/null; echo '"+c.response+"'")
+		pr, err := NewPRExternalEvaluator([]string{script}, 0)
+		require.NoError(t, err)
+		res, err := pr.isRunningImageAllowed(context.Background(), image)
+		assertRunningRejectedPolicyRequirement(t, res, err)
+		assert.ErrorContains(t, err, c.expectedReason)
+	}
+
+	// Evaluator failures
+	for _, body := range []string{
+		`echo "evaluator crashed" >&2; exit 1`, // Non-zero exit status
+		`echo 'this is invalid'`,               // Invalid JSON
+		`echo '{"reason":"missing allowed"}'`,  // Missing "allowed"
+		`sleep 10`,                             // Timeout
+		`yes '{"allowed":true}'`,               // Unbounded output
+	} {
+		script := writeEvaluatorScript(t, body)
+		pr, err := NewPRExternalEvaluator([]string{script}, 1)
+		require.NoError(t, err)
+		res, err := pr.isRunningImageAllowed(context.Background(), image)
+		assertRunningRejected(t, res, err)
+		_, isPolicyRequirementError := err.(PolicyRequirementError)
+		assert.False(t, isPolicyRequirementError, body)
+	}
+
+	// The evaluator does not exist
+	pr, err = NewPRExternalEvaluator([]string{"/this/does/not/exist"}, 0)
+	require.NoError(t, err)
+	res, err = pr.isRunningImageAllowed(context.Background(), image)
+	assertRunningRejected(t, res, err)
+
+	// Error reading the manifest
+	script = writeEvaluatorScript(t, `echo '{"allowed":true}'`)
+	pr, err = NewPRExternalEvaluator([]string{script}, 0)
+	require.NoError(t, err)
+	res, err = pr.isRunningImageAllowed(context.Background(), dirImageMock(t, "fixtures/dir-img-no-manifest", "testing/manifest:latest"))
+	assertRunningRejected(t, res, err)
+}
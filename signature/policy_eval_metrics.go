@@ -0,0 +1,162 @@
+// Counters collected by a PolicyContext, and per-context cached state.
+
+package signature
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// PolicyContextMetrics is a snapshot of the counters collected by a PolicyContext, as returned by PolicyContext.Metrics.
+type PolicyContextMetrics struct {
+	// Evaluations is the number of calls of IsRunningImageAllowed and GetSignaturesWithAcceptedAuthor.
+	Evaluations uint64
+	// Accepted and Rejected count, per requirement type (the "type" value in policy.json), the decisions of individual
+	// requirements: in IsRunningImageAllowed, whether a requirement allowed running the image; in
+	// GetSignaturesWithAcceptedAuthor, whether a requirement accepted or rejected a signature.
+	// Requirements which do not deal with signatures in GetSignaturesWithAcceptedAuthor are not counted.
+	Accepted map[string]uint64
+	Rejected map[string]uint64
+	// TrustRootCacheHits and TrustRootCacheMisses count uses of sigstore trust roots (public keys, Fulcio and Rekor
+	// configuration) which were, or were not, already loaded earlier by the same PolicyContext.
+	TrustRootCacheHits   uint64
+	TrustRootCacheMisses uint64
+	// GPGVerifications and GPGVerificationDuration are the number of, and total time taken by, verifications
+	// of simple signing signatures, including importing the trusted keys.
+	GPGVerifications        uint64
+	GPGVerificationDuration time.Duration
+	// SigstoreVerifications and SigstoreVerificationDuration are the number of, and total time taken by,
+	// verifications of sigstore signatures.
+	SigstoreVerifications        uint64
+	SigstoreVerificationDuration time.Duration
+}
+
+// policyContextMetrics is the mutable state of PolicyContext.Metrics, and of the per-context caches.
+// Metrics can be read while an evaluation is in progress, so all fields are protected by mutex.
+type policyContextMetrics struct {
+	mutex      sync.Mutex
+	values     PolicyContextMetrics
+	trustRoots map[*prSigstoreSigned]*sigstoreSignedTrustRoot // Sigstore trust roots prepared in this context
+}
+
+// Metrics returns a snapshot of the counters collected by pc since it was created.
+// It is safe to call Metrics concurrently with an evaluation using pc.
+func (pc *PolicyContext) Metrics() PolicyContextMetrics {
+	pc.metrics.mutex.Lock()
+	defer pc.metrics.mutex.Unlock()
+	res := pc.metrics.values
+	res.Accepted = maps.Clone(res.Accepted)
+	res.Rejected = maps.Clone(res.Rejected)
+	if res.Accepted == nil {
+		res.Accepted = map[string]uint64{}
+	}
+	if res.Rejected == nil {
+		res.Rejected = map[string]uint64{}
+	}
+	return res
+}
+
+// policyContextKey is the context.Context key used to make the evaluating PolicyContext available to PolicyRequirements.
+type policyContextKey struct{}
+
+// startEvaluation records an evaluation by pc, and returns a context which makes pc available
+// to the PolicyRequirement implementations.
+func (pc *PolicyContext) startEvaluation(ctx context.Context) context.Context {
+	pc.metrics.mutex.Lock()
+	defer pc.metrics.mutex.Unlock()
+	pc.metrics.values.Evaluations++
+	return context.WithValue(ctx, policyContextKey{}, pc)
+}
+
+// evaluatingPolicyContext returns the PolicyContext evaluating a policy within ctx, or nil if the requirements
+// are evaluated directly (which only happens in tests).
+func evaluatingPolicyContext(ctx context.Context) *PolicyContext {
+	pc, _ := ctx.Value(policyContextKey{}).(*PolicyContext)
+	return pc
+}
+
+// recordDecision records a decision of req.
+func (pc *PolicyContext) recordDecision(req PolicyRequirement, accepted bool) {
+	typeName := requirementTypeName(req)
+	pc.metrics.mutex.Lock()
+	defer pc.metrics.mutex.Unlock()
+	if accepted {
+		if pc.metrics.values.Accepted == nil {
+			pc.metrics.values.Accepted = map[string]uint64{}
+		}
+		pc.metrics.values.Accepted[typeName]++
+	} else {
+		if pc.metrics.values.Rejected == nil {
+			pc.metrics.values.Rejected = map[string]uint64{}
+		}
+		pc.metrics.values.Rejected[typeName]++
+	}
+}
+
+// requirementTypeName returns the policy.json "type" value of req.
+func requirementTypeName(req PolicyRequirement) string {
+	if r, ok := req.(interface{ typeIdentifier() prTypeIdentifier }); ok && r.typeIdentifier() != "" {
+		return string(r.typeIdentifier())
+	}
+	return "unknown"
+}
+
+// typeIdentifier returns the type of the PolicyRequirement embedding c.
+func (c prCommon) typeIdentifier() prTypeIdentifier {
+	return c.Type
+}
+
+// recordGPGVerification records a simple signing signature verification, started at start, if ctx is within a PolicyContext evaluation.
+func recordGPGVerification(ctx context.Context, start time.Time) {
+	if pc := evaluatingPolicyContext(ctx); pc != nil {
+		duration := time.Since(start)
+		pc.metrics.mutex.Lock()
+		defer pc.metrics.mutex.Unlock()
+		pc.metrics.values.GPGVerifications++
+		pc.metrics.values.GPGVerificationDuration += duration
+	}
+}
+
+// recordSigstoreVerification records a sigstore signature verification, started at start, if ctx is within a PolicyContext evaluation.
+func recordSigstoreVerification(ctx context.Context, start time.Time) {
+	if pc := evaluatingPolicyContext(ctx); pc != nil {
+		duration := time.Since(start)
+		pc.metrics.mutex.Lock()
+		defer pc.metrics.mutex.Unlock()
+		pc.metrics.values.SigstoreVerifications++
+		pc.metrics.values.SigstoreVerificationDuration += duration
+	}
+}
+
+// sigstoreTrustRoot returns the trust root of pr, prepared once per PolicyContext if ctx is within a PolicyContext evaluation.
+func (pr *prSigstoreSigned) sigstoreTrustRoot(ctx context.Context) (*sigstoreSignedTrustRoot, error) {
+	pc := evaluatingPolicyContext(ctx)
+	if pc == nil {
+		return pr.prepareTrustRoot(ctx)
+	}
+	pc.metrics.mutex.Lock()
+	trustRoot, ok := pc.metrics.trustRoots[pr]
+	if ok {
+		pc.metrics.values.TrustRootCacheHits++
+	} else {
+		pc.metrics.values.TrustRootCacheMisses++
+	}
+	pc.metrics.mutex.Unlock()
+	if ok {
+		return trustRoot, nil
+	}
+
+	trustRoot, err := pr.prepareTrustRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc.metrics.mutex.Lock()
+	defer pc.metrics.mutex.Unlock()
+	if pc.metrics.trustRoots == nil {
+		pc.metrics.trustRoots = map[*prSigstoreSigned]*sigstoreSignedTrustRoot{}
+	}
+	pc.metrics.trustRoots[pr] = trustRoot
+	return trustRoot, nil
+}
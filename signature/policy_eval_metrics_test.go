@@ -0,0 +1,85 @@
+package signature
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyContextMetrics(t *testing.T) {
+	sigstoreRequirement, err := NewPRSigstoreSigned(
+		PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
+		PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepository()),
+	)
+	require.NoError(t, err)
+	pc, err := NewPolicyContext(&Policy{
+		Default: PolicyRequirements{NewPRReject()},
+		Transports: map[string]PolicyTransportScopes{
+			"docker": {
+				"docker.io/testing/manifest:latest": {
+					xNewPRSignedByKeyPath(SBKeyTypeGPGKeys, "fixtures/public-key.gpg", NewPRMMatchExact()),
+				},
+				"docker.io/testing/manifest:acceptAnything": {
+					NewPRInsecureAcceptAnything(),
+				},
+				"192.168.64.2:5000/cosign-signed-single-sample": {
+					sigstoreRequirement,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		err := pc.Destroy()
+		require.NoError(t, err)
+	}()
+
+	m := pc.Metrics()
+	assert.Equal(t, PolicyContextMetrics{Accepted: map[string]uint64{}, Rejected: map[string]uint64{}}, m)
+
+	img := pcImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	res, err := pc.IsRunningImageAllowed(context.Background(), img)
+	assertRunningAllowed(t, res, err)
+	img = pcImageMock(t, "fixtures/dir-img-unsigned", "testing/manifest:latest")
+	res, err = pc.IsRunningImageAllowed(context.Background(), img)
+	assertRunningRejectedPolicyRequirement(t, res, err)
+	img = pcImageMock(t, "fixtures/dir-img-valid", "testing/manifest:acceptAnything")
+	res, err = pc.IsRunningImageAllowed(context.Background(), img)
+	assertRunningAllowed(t, res, err)
+	img = pcImageMock(t, "fixtures/dir-img-valid", "testing/manifest:notlatest")
+	res, err = pc.IsRunningImageAllowed(context.Background(), img)
+	assertRunningRejectedPolicyRequirement(t, res, err)
+	sigs, err := pc.GetSignaturesWithAcceptedAuthor(context.Background(), pcImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest"))
+	require.NoError(t, err)
+	assert.Len(t, sigs, 1)
+	// Two sigstore evaluations share a trust root.
+	for i := 0; i < 2; i++ {
+		img = pcImageMock(t, "fixtures/dir-img-cosign-valid", "192.168.64.2:5000/cosign-signed-single-sample:latest")
+		res, err = pc.IsRunningImageAllowed(context.Background(), img)
+		assertRunningAllowed(t, res, err)
+	}
+
+	m = pc.Metrics()
+	assert.Equal(t, uint64(7), m.Evaluations)
+	assert.Equal(t, map[string]uint64{
+		string(prTypeSignedBy):               2,
+		string(prTypeInsecureAcceptAnything): 1,
+		string(prTypeSigstoreSigned):         2,
+	}, m.Accepted)
+	assert.Equal(t, map[string]uint64{
+		string(prTypeSignedBy): 1,
+		string(prTypeReject):   1,
+	}, m.Rejected)
+	assert.Equal(t, uint64(1), m.TrustRootCacheHits)
+	assert.Equal(t, uint64(1), m.TrustRootCacheMisses)
+	assert.Equal(t, uint64(2), m.GPGVerifications)
+	assert.NotZero(t, m.GPGVerificationDuration)
+	assert.Equal(t, uint64(2), m.SigstoreVerifications)
+	assert.NotZero(t, m.SigstoreVerificationDuration)
+
+	// The returned maps are a snapshot.
+	m.Accepted[string(prTypeReject)] = 1
+	assert.NotContains(t, pc.Metrics().Accepted, string(prTypeReject))
+}
@@ -0,0 +1,95 @@
+// Structured descriptions of signature rejections.
+
+package signature
+
+import (
+	"context"
+
+	"github.com/containers/image/v5/internal/private"
+)
+
+// RejectionClass classifies why a signature was rejected.
+type RejectionClass string
+
+const (
+	// RejectionKeyMismatch means the signature was not made by a trusted key (or certificate),
+	// or it is not a valid cryptographic signature.
+	RejectionKeyMismatch RejectionClass = "key-mismatch"
+	// RejectionIdentityMismatch means the signature claims an image identity which is not accepted by the policy.
+	RejectionIdentityMismatch RejectionClass = "identity-mismatch"
+	// RejectionDigestMismatch means the signature claims a manifest digest which does not match the image.
+	RejectionDigestMismatch RejectionClass = "digest-mismatch"
+	// RejectionRekorFailure means the Rekor transparency log inclusion proof of the signature could not be verified.
+	RejectionRekorFailure RejectionClass = "rekor-failure"
+	// RejectionTimestampFailure means the RFC 3161 timestamp of the signature could not be verified.
+	RejectionTimestampFailure RejectionClass = "timestamp-failure"
+	// RejectionOther is used for all other failures, e.g. malformed signatures or errors reading keys.
+	RejectionOther RejectionClass = "other"
+)
+
+// SignatureRejection describes why a single signature was rejected by a policy requirement.
+type SignatureRejection struct {
+	// RequirementIndex is the index of the rejecting requirement in the PolicyRequirements applied to the image.
+	RequirementIndex int
+	// SignatureIndex is the index of the signature in the image’s signatures. If the requirement also considers
+	// signatures of other manifests (see "signedDigest" in policy.json), their signatures are numbered
+	// after those of the image, in the order the manifests were considered.
+	SignatureIndex int
+	Class          RejectionClass
+	Err            error // The reason for the rejection of this signature
+}
+
+// PolicyRejection describes why a signature-verifying requirement rejected an image, see
+// PolicyContext.IsRunningImageAllowedWithRejection.
+type PolicyRejection struct {
+	RequirementIndex int    // The index of the rejecting requirement in the PolicyRequirements applied to the image
+	RequirementType  string // The "type" value of the rejecting requirement in policy.json
+	// Rejections lists the individual rejected signatures; it is empty if the image has no applicable signatures.
+	Rejections []SignatureRejection
+}
+
+// signatureRejectingRequirement is implemented by PolicyRequirements which verify signatures,
+// and can describe why they rejected an image.
+type signatureRejectingRequirement interface {
+	// evaluateSignatures is isRunningImageAllowed, which also returns a description of the rejected signatures
+	// if the image is not allowed because none of its signatures were accepted.
+	// The RequirementIndex fields of the returned value are not set.
+	evaluateSignatures(ctx context.Context, image private.UnparsedImage) (bool, *PolicyRejection, error)
+}
+
+// signatureRejections collects SignatureRejection values while a requirement evaluates signatures.
+type signatureRejections struct {
+	rejections []SignatureRejection
+	errs       []error // The Err values of rejections, for building the summary error
+}
+
+// add records a rejection of the signature at index.
+func (r *signatureRejections) add(index int, class RejectionClass, err error) {
+	r.rejections = append(r.rejections, SignatureRejection{SignatureIndex: index, Class: class, Err: err})
+	r.errs = append(r.errs, err)
+}
+
+// policyRejection returns a PolicyRejection for the collected rejections, as rejected by a requirement of reqType.
+func (r *signatureRejections) policyRejection(reqType prTypeIdentifier) *PolicyRejection {
+	rejections := r.rejections
+	if rejections == nil {
+		rejections = []SignatureRejection{}
+	}
+	return &PolicyRejection{
+		RequirementType: string(reqType),
+		Rejections:      rejections,
+	}
+}
+
+// rekorVerificationError is a failure to verify a Rekor SET, wrapped so that it can be classified as RejectionRekorFailure.
+type rekorVerificationError struct {
+	err error
+}
+
+func (e rekorVerificationError) Error() string {
+	return e.err.Error()
+}
+
+func (e rekorVerificationError) Unwrap() error {
+	return e.err
+}
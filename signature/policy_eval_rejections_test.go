@@ -0,0 +1,149 @@
+package signature
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertRejections verifies that rejection describes a rejection by a requirement of reqType at reqIndex,
+// with the expected rejection classes.
+func assertRejections(t *testing.T, rejection *PolicyRejection, reqIndex int, reqType prTypeIdentifier, expected []RejectionClass) {
+	require.NotNil(t, rejection)
+	assert.Equal(t, reqIndex, rejection.RequirementIndex)
+	assert.Equal(t, string(reqType), rejection.RequirementType)
+	classes := []RejectionClass{}
+	for i, r := range rejection.Rejections {
+		assert.Equal(t, reqIndex, r.RequirementIndex)
+		assert.Equal(t, i, r.SignatureIndex)
+		assert.Error(t, r.Err)
+		classes = append(classes, r.Class)
+	}
+	assert.Equal(t, expected, classes)
+}
+
+func TestPolicyContextRejections(t *testing.T) {
+	pc, err := NewPolicyContext(&Policy{
+		Default: PolicyRequirements{NewPRReject()},
+		Transports: map[string]PolicyTransportScopes{
+			"docker": {
+				"docker.io/testing/manifest": {
+					NewPRInsecureAcceptAnything(),
+					xNewPRSignedByKeyPath(SBKeyTypeGPGKeys, "fixtures/public-key.gpg", NewPRMMatchExact()),
+				},
+				"docker.io/testing/otherkey": {
+					xNewPRSignedByKeyPath(SBKeyTypeGPGKeys, "fixtures/public-key-2.gpg", NewPRMMatchRepository()),
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		err := pc.Destroy()
+		require.NoError(t, err)
+	}()
+
+	for _, c := range []struct {
+		dir, ref string
+		reqIndex int
+		expected []RejectionClass
+	}{
+		{"fixtures/dir-img-unsigned", "testing/manifest:latest", 1, []RejectionClass{}},
+		{"fixtures/dir-img-modified-manifest", "testing/manifest:latest", 1, []RejectionClass{RejectionDigestMismatch}},
+		{"fixtures/dir-img-valid", "testing/manifest:notlatest", 1, []RejectionClass{RejectionIdentityMismatch}},
+		{"fixtures/dir-img-valid-2", "testing/manifest:notlatest", 1, []RejectionClass{RejectionIdentityMismatch, RejectionIdentityMismatch}},
+		{"fixtures/dir-img-valid", "testing/otherkey:latest", 0, []RejectionClass{RejectionKeyMismatch}},
+	} {
+		img := pcImageMock(t, c.dir, c.ref)
+		res, rejection, err := pc.IsRunningImageAllowedWithRejection(context.Background(), img)
+		assertRunningRejected(t, res, err)
+		assertRejections(t, rejection, c.reqIndex, prTypeSignedBy, c.expected)
+
+		// IsRunningImageAllowed returns the same error.
+		img = pcImageMock(t, c.dir, c.ref)
+		res2, err2 := pc.IsRunningImageAllowed(context.Background(), img)
+		assertRunningRejected(t, res2, err2)
+		assert.Equal(t, err, err2)
+	}
+
+	// A rejection not based on signatures is not described.
+	img := pcImageMock(t, "fixtures/dir-img-valid", "testing/unknown:latest")
+	res, rejection, err := pc.IsRunningImageAllowedWithRejection(context.Background(), img)
+	assertRunningRejectedPolicyRequirement(t, res, err)
+	assert.Nil(t, rejection)
+
+	// An allowed image is not described.
+	img = pcImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	res, rejection, err = pc.IsRunningImageAllowedWithRejection(context.Background(), img)
+	assertRunningAllowed(t, res, err)
+	assert.Nil(t, rejection)
+}
+
+func TestPRSigstoreSignedRejections(t *testing.T) {
+	fulcio, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		PRSigstoreSignedFulcioWithSubjectEmail("mitr@redhat.com"),
+	)
+	require.NoError(t, err)
+	otherFulcio, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath("fixtures/fulcio_v1.crt.pem"),
+		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		PRSigstoreSignedFulcioWithSubjectEmail("this-does-not-match@example.com"),
+	)
+	require.NoError(t, err)
+	for _, c := range []struct {
+		dir, ref string
+		options  []PRSigstoreSignedOption
+		expected []RejectionClass
+	}{
+		{
+			"fixtures/dir-img-cosign-valid", "192.168.64.2:5000/cosign-signed-single-sample",
+			[]PRSigstoreSignedOption{PRSigstoreSignedWithKeyPath("fixtures/cosign2.pub")},
+			[]RejectionClass{RejectionKeyMismatch},
+		},
+		{
+			"fixtures/dir-img-cosign-valid", "192.168.64.2:5000/cosign-signed-single-sample",
+			[]PRSigstoreSignedOption{
+				PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
+				PRSigstoreSignedWithRekorPublicKeyPath("fixtures/rekor.pub"),
+			},
+			[]RejectionClass{RejectionRekorFailure},
+		},
+		{
+			"fixtures/dir-img-cosign-fulcio-rekor-valid", "192.168.64.2:5000/cosign-signed/fulcio-rekor-1",
+			[]PRSigstoreSignedOption{
+				PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{otherFulcio, otherFulcio}),
+				PRSigstoreSignedWithRekorPublicKeyPath("fixtures/rekor.pub"),
+			},
+			[]RejectionClass{RejectionKeyMismatch},
+		},
+		{
+			"fixtures/dir-img-cosign-fulcio-rekor-valid", "192.168.64.2:5000/cosign-signed/fulcio-rekor-1",
+			[]PRSigstoreSignedOption{
+				PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{otherFulcio, fulcio}),
+				PRSigstoreSignedWithRekorPublicKeyPath("fixtures/cosign.pub"), // not rekor.pub = a key mismatch
+			},
+			[]RejectionClass{RejectionRekorFailure},
+		},
+		{
+			"fixtures/dir-img-cosign-valid", "testing/manifest:latest",
+			[]PRSigstoreSignedOption{PRSigstoreSignedWithKeyPath("fixtures/cosign.pub")},
+			[]RejectionClass{RejectionIdentityMismatch},
+		},
+		{
+			"fixtures/dir-img-cosign-modified-manifest", "192.168.64.2:5000/cosign-signed-single-sample",
+			[]PRSigstoreSignedOption{PRSigstoreSignedWithKeyPath("fixtures/cosign.pub")},
+			[]RejectionClass{RejectionDigestMismatch},
+		},
+	} {
+		pr, err := NewPRSigstoreSigned(append(c.options, PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepository()))...)
+		require.NoError(t, err)
+		image := dirImageMock(t, c.dir, c.ref)
+		allowed, rejection, err := pr.(*prSigstoreSigned).evaluateSignatures(context.Background(), image)
+		assertRunningRejected(t, allowed, err)
+		assertRejections(t, rejection, 0, prTypeSigstoreSigned, c.expected)
+	}
+}
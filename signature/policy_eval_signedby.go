@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"time"
 
 	"github.com/containers/image/v5/internal/multierr"
 	"github.com/containers/image/v5/internal/private"
@@ -16,16 +17,23 @@ import (
 )
 
 func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image private.UnparsedImage, sig []byte) (signatureAcceptanceResult, *Signature, error) {
+	res, signature, _, err := pr.verifySignature(ctx, image, sig)
+	return res, signature, err
+}
+
+// verifySignature is isSignatureAuthorAccepted, which also returns a RejectionClass if the result is sarRejected.
+func (pr *prSignedBy) verifySignature(ctx context.Context, image private.UnparsedImage, sig []byte) (signatureAcceptanceResult, *Signature, RejectionClass, error) {
 	switch pr.KeyType {
 	case SBKeyTypeGPGKeys:
 	case SBKeyTypeSignedByGPGKeys, SBKeyTypeX509Certificates, SBKeyTypeSignedByX509CAs:
 		// FIXME? Reject this at policy parsing time already?
-		return sarRejected, nil, fmt.Errorf(`Unimplemented "keyType" value %q`, string(pr.KeyType))
+		return sarRejected, nil, RejectionOther, fmt.Errorf(`Unimplemented "keyType" value %q`, string(pr.KeyType))
 	default:
 		// This should never happen, newPRSignedBy ensures KeyType.IsValid()
-		return sarRejected, nil, fmt.Errorf(`Unknown "keyType" value %q`, string(pr.KeyType))
+		return sarRejected, nil, RejectionOther, fmt.Errorf(`Unknown "keyType" value %q`, string(pr.KeyType))
 	}
 
+	defer recordGPGVerification(ctx, time.Now())
 	// FIXME: move this to per-context initialization
 	var data [][]byte
 	keySources := 0
@@ -33,7 +41,7 @@ func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image priva
 		keySources++
 		d, err := os.ReadFile(pr.KeyPath)
 		if err != nil {
-			return sarRejected, nil, err
+			return sarRejected, nil, RejectionOther, err
 		}
 		data = [][]byte{d}
 	}
@@ -43,7 +51,7 @@ func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image priva
 		for _, path := range pr.KeyPaths {
 			d, err := os.ReadFile(path)
 			if err != nil {
-				return sarRejected, nil, err
+				return sarRejected, nil, RejectionOther, err
 			}
 			data = append(data, d)
 		}
@@ -52,23 +60,45 @@ func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image priva
 		keySources++
 		data = [][]byte{pr.KeyData}
 	}
+	if pr.KeyFingerprint != "" {
+		keySources++
+		if pr.KeySource == nil {
+			return sarRejected, nil, RejectionOther, errors.New(`Internal inconsistency: "keyFingerprint" specified without "keySource"`)
+		}
+		d, err := pr.KeySource.loadKey(ctx, pr.KeyFingerprint)
+		if err != nil {
+			return sarRejected, nil, RejectionOther, err
+		}
+		data = [][]byte{d}
+	}
 	if keySources != 1 {
-		return sarRejected, nil, errors.New(`Internal inconsistency: not exactly one of "keyPath", "keyPaths" and "keyData" specified`)
+		return sarRejected, nil, RejectionOther, errors.New(`Internal inconsistency: not exactly one of "keyPath", "keyPaths", "keyData" and "keyFingerprint" specified`)
 	}
 
 	// FIXME: move this to per-context initialization
 	mech, trustedIdentities, err := newEphemeralGPGSigningMechanism(data)
 	if err != nil {
-		return sarRejected, nil, err
+		return sarRejected, nil, RejectionOther, err
 	}
 	defer mech.Close()
 	if len(trustedIdentities) == 0 {
-		return sarRejected, nil, PolicyRequirementError("No public keys imported")
+		return sarRejected, nil, RejectionOther, PolicyRequirementError("No public keys imported")
+	}
+	if pr.KeyFingerprint != "" {
+		// The fetched data may contain other keys; trust only the one specified in the policy.
+		if !slices.Contains(trustedIdentities, pr.KeyFingerprint) {
+			return sarRejected, nil, RejectionOther, PolicyRequirementError(fmt.Sprintf("Key %s not found", pr.KeyFingerprint))
+		}
+		trustedIdentities = []string{pr.KeyFingerprint}
 	}
 
+	// The cryptographic verification happens before validateKeyIdentity is called; if it fails,
+	// the signature was not made by any of the trusted keys, or it is invalid.
+	class := RejectionKeyMismatch
 	signature, err := verifyAndExtractSignature(mech, sig, signatureAcceptanceRules{
 		validateKeyIdentity: func(keyIdentity string) error {
 			if slices.Contains(trustedIdentities, keyIdentity) {
+				class = RejectionOther
 				return nil
 			}
 			// Coverage: We use a private GPG home directory and only import trusted keys, so this should
@@ -77,6 +107,7 @@ func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image priva
 		},
 		validateSignedDockerReference: func(ref string) error {
 			if !pr.SignedIdentity.matchesDockerReference(image, ref) {
+				class = RejectionIdentityMismatch
 				return PolicyRequirementError(fmt.Sprintf("Signature for identity %q is not accepted", ref))
 			}
 			return nil
@@ -91,50 +122,68 @@ func (pr *prSignedBy) isSignatureAuthorAccepted(ctx context.Context, image priva
 				return err
 			}
 			if !digestMatches {
+				class = RejectionDigestMismatch
 				return PolicyRequirementError(fmt.Sprintf("Signature for digest %s does not match", digest))
 			}
 			return nil
 		},
 	})
 	if err != nil {
-		return sarRejected, nil, err
+		return sarRejected, nil, class, err
 	}
 
-	return sarAccepted, signature, nil
+	return sarAccepted, signature, "", nil
 }
 
 func (pr *prSignedBy) isRunningImageAllowed(ctx context.Context, image private.UnparsedImage) (bool, error) {
-	// FIXME: Use image.UntrustedSignatures, use that to improve error messages
-	// (needs tests!)
-	sigs, err := image.Signatures(ctx)
+	res, _, err := pr.evaluateSignatures(ctx, image)
+	return res, err
+}
+
+// evaluateSignatures is isRunningImageAllowed, which also returns a description of the rejected signatures if the image is not allowed.
+func (pr *prSignedBy) evaluateSignatures(ctx context.Context, image private.UnparsedImage) (bool, *PolicyRejection, error) {
+	subjects, err := signatureSubjects(ctx, image, pr.SignedDigest)
 	if err != nil {
-		return false, err
+		return false, nil, err
 	}
-	var rejections []error
-	for _, s := range sigs {
-		var reason error
-		switch res, _, err := pr.isSignatureAuthorAccepted(ctx, image, s); res {
-		case sarAccepted:
-			// One accepted signature is enough.
-			return true, nil
-		case sarRejected:
-			reason = err
-		case sarUnknown:
-			// Huh?! This should not happen at all; treat it as any other invalid value.
-			fallthrough
-		default:
-			reason = fmt.Errorf(`Internal error: Unexpected signature verification result %q`, string(res))
+	var rejections signatureRejections
+	sigIndex := 0
+	for _, subject := range subjects {
+		// FIXME: Use subject.UntrustedSignatures, use that to improve error messages
+		// (needs tests!)
+		sigs, err := subject.Signatures(ctx)
+		if err != nil {
+			return false, nil, err
+		}
+		for _, s := range sigs {
+			var reason error
+			var class RejectionClass
+			switch res, _, resClass, err := pr.verifySignature(ctx, subject, s); res {
+			case sarAccepted:
+				// One accepted signature is enough.
+				return true, nil, nil
+			case sarRejected:
+				reason = err
+				class = resClass
+			case sarUnknown:
+				// Huh?! This should not happen at all; treat it as any other invalid value.
+				fallthrough
+			default:
+				reason = fmt.Errorf(`Internal error: Unexpected signature verification result %q`, string(res))
+				class = RejectionOther
+			}
+			rejections.add(sigIndex, class, reason)
+			sigIndex++
 		}
-		rejections = append(rejections, reason)
 	}
 	var summary error
-	switch len(rejections) {
+	switch len(rejections.errs) {
 	case 0:
 		summary = PolicyRequirementError("A signature was required, but no signature exists")
 	case 1:
-		summary = rejections[0]
+		summary = rejections.errs[0]
 	default:
-		summary = PolicyRequirementError(multierr.Format("None of the signatures were accepted, reasons: ", "; ", "", rejections).Error())
+		summary = PolicyRequirementError(multierr.Format("None of the signatures were accepted, reasons: ", "; ", "", rejections.errs).Error())
 	}
-	return false, summary
+	return false, rejections.policyRejection(prTypeSignedBy), summary
 }
@@ -0,0 +1,194 @@
+// Fetching of prSignedBy keys identified by a fingerprint.
+
+package signature
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/rootless"
+	"github.com/containers/storage/pkg/ioutils"
+)
+
+const (
+	// systemCacheDir is the directory containing cached data for root-running processes.
+	systemCacheDir = "/var/lib/containers/cache"
+	// keyCacheMaxAge is the age after which a cached key is fetched again.
+	// An older cached key is still used if fetching fails.
+	keyCacheMaxAge = 24 * time.Hour
+	// maxFetchedKeySize is the maximum allowed size of a fetched key.
+	maxFetchedKeySize = 1 << 20
+)
+
+// keySourceHTTPClient is used to fetch keys. Tests can replace it.
+var keySourceHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// keyCacheDir returns the directory used to cache fetched keys, appropriate for euid.
+// This is a variable so that tests can replace it.
+var keyCacheDir = func(euid int) (string, error) {
+	return cacheDir(euid, "signature-keys")
+}
+
+// cacheDir returns the directory used to cache data of the named kind, appropriate for euid.
+func cacheDir(euid int, name string) (string, error) {
+	if euid == 0 {
+		return filepath.Join(systemCacheDir, name), nil
+	}
+	// This mirrors the blob info cache location in pkg/blobinfocache.
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", errors.New("neither XDG_DATA_HOME nor HOME was set non-empty")
+		}
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataDir, "containers", "cache", name), nil
+}
+
+// zbase32Encoding is the z-base-32 encoding used by the OpenPGP Web Key Directory.
+var zbase32Encoding = base32.NewEncoding("ybndrfg8ejkmcpqxot1uwisza345h769").WithPadding(base32.NoPadding)
+
+// urls returns the URLs to try, in order, to fetch the key with fingerprint from src.
+func (src *prSignedByKeySource) urls(fingerprint string) ([]string, error) {
+	switch {
+	case src.WKD != "" && src.Keyserver == "":
+		local, domain, ok := strings.Cut(src.WKD, "@")
+		if !ok {
+			return nil, fmt.Errorf("Internal inconsistency: invalid wkd email address %q", src.WKD)
+		}
+		domain = strings.ToLower(domain)
+		hash := sha1.Sum([]byte(strings.ToLower(local)))
+		hu := zbase32Encoding.EncodeToString(hash[:])
+		query := url.Values{"l": {local}}.Encode()
+		return []string{
+			// The “advanced method”, falling back to the “direct method”.
+			fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s?%s", domain, domain, hu, query),
+			fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s?%s", domain, hu, query),
+		}, nil
+	case src.WKD == "" && src.Keyserver != "":
+		u, err := url.Parse(src.Keyserver)
+		if err != nil {
+			return nil, err
+		}
+		u = u.JoinPath("pks", "lookup")
+		u.RawQuery = url.Values{
+			"op":      {"get"},
+			"options": {"mr"},
+			"search":  {"0x" + fingerprint},
+		}.Encode()
+		return []string{u.String()}, nil
+	default: // newPRSignedByKeyFingerprint rejects such values.
+		return nil, errors.New("Internal inconsistency: not exactly one of wkd and keyserver specified")
+	}
+}
+
+// fetchKey fetches the key data containing fingerprint from src, trying all of urls.
+func (src *prSignedByKeySource) fetchKey(ctx context.Context, fingerprint string, urls []string) ([]byte, error) {
+	errs := []error{}
+	for _, u := range urls {
+		data, err := fetchKeyFromURL(ctx, u)
+		if err == nil {
+			err = keyDataContainsFingerprint(data, fingerprint)
+		}
+		if err == nil {
+			return data, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// keyDataContainsFingerprint returns an error if data does not contain a public key with fingerprint.
+func keyDataContainsFingerprint(data []byte, fingerprint string) error {
+	mech, keyIdentities, err := newEphemeralGPGSigningMechanism([][]byte{data})
+	if err != nil {
+		return err
+	}
+	defer mech.Close()
+	if !slices.Contains(keyIdentities, fingerprint) {
+		return fmt.Errorf("key %s not found in fetched data", fingerprint)
+	}
+	return nil
+}
+
+// fetchKeyFromURL fetches key data from a single URL.
+func fetchKeyFromURL(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := keySourceHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching key from %s: %s", u, http.StatusText(res.StatusCode))
+	}
+	data, err := iolimits.ReadAtMost(res.Body, maxFetchedKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("fetching key from %s: %w", u, err)
+	}
+	return data, nil
+}
+
+// loadKey returns key data for fingerprint, from the cache or fetched from src.
+// If the cached data is stale and fetching fails, the stale data is used, so that hosts without network access
+// can continue to verify signatures.
+// The returned data may contain other keys as well.
+func (src *prSignedByKeySource) loadKey(ctx context.Context, fingerprint string) ([]byte, error) {
+	var cachePath string
+	dir, err := keyCacheDir(rootless.GetRootlessEUID())
+	if err != nil {
+		log.Debugf("Error determining a location for the signature key cache, not caching keys: %v", err)
+	} else {
+		cachePath = filepath.Join(dir, fingerprint+".gpg")
+	}
+
+	var cached []byte
+	if cachePath != "" {
+		if fi, err := os.Stat(cachePath); err == nil {
+			cached, err = os.ReadFile(cachePath)
+			if err != nil {
+				log.Debugf("Error reading cached key %s: %v", cachePath, err)
+				cached = nil
+			} else if time.Since(fi.ModTime()) < keyCacheMaxAge {
+				return cached, nil
+			}
+		}
+	}
+
+	urls, err := src.urls(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	data, err := src.fetchKey(ctx, fingerprint, urls)
+	if err != nil {
+		if cached != nil {
+			log.Debugf("Error fetching key %s, using a cached copy: %v", fingerprint, err)
+			return cached, nil
+		}
+		return nil, fmt.Errorf("fetching key %s: %w", fingerprint, err)
+	}
+	if cachePath != "" {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o700); err != nil {
+			log.Debugf("Error creating key cache directory: %v", err)
+		} else if err := ioutils.AtomicWriteFile(cachePath, data, 0o600); err != nil {
+			log.Debugf("Error caching key %s: %v", fingerprint, err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,211 @@
+package signature
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyServerMock is a HTTP server returning the contents of keyPath for all requests, or 404 if keyPath is "".
+type keyServerMock struct {
+	server   *httptest.Server
+	keyPath  string
+	requests []*url.URL
+}
+
+func newKeyServerMock(t *testing.T, keyPath string) *keyServerMock {
+	res := &keyServerMock{keyPath: keyPath}
+	res.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res.requests = append(res.requests, r.URL)
+		if res.keyPath == "" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, res.keyPath)
+	}))
+	t.Cleanup(res.server.Close)
+	return res
+}
+
+// useTemporaryKeyCache makes fetched keys cached in a temporary directory for the duration of the test, and returns the directory.
+func useTemporaryKeyCache(t *testing.T) string {
+	dir := t.TempDir()
+	origKeyCacheDir := keyCacheDir
+	keyCacheDir = func(euid int) (string, error) { return dir, nil }
+	t.Cleanup(func() { keyCacheDir = origKeyCacheDir })
+	return dir
+}
+
+// rewriteHostTransport is a http.RoundTripper which sends all requests to target.
+type rewriteHostTransport struct {
+	target *url.URL
+	hosts  []string // Hosts of the original requests
+}
+
+func (t *rewriteHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.hosts = append(t.hosts, req.URL.Host)
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPRSignedByKeySourceURLs(t *testing.T) {
+	// The example from the Web Key Directory specification.
+	src := prSignedByKeySource{WKD: "Joe.Doe@Example.ORG"}
+	urls, err := src.urls(TestKeyFingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+		"https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe",
+	}, urls)
+
+	for _, keyserver := range []string{"https://keys.example.com", "https://keys.example.com/"} {
+		src = prSignedByKeySource{Keyserver: keyserver}
+		urls, err = src.urls(TestKeyFingerprint)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://keys.example.com/pks/lookup?op=get&options=mr&search=0x" + TestKeyFingerprint}, urls)
+	}
+
+	// Invalid values, normally rejected by newPRSignedByKeyFingerprint
+	for _, src := range []prSignedByKeySource{
+		{},
+		{WKD: "user@example.com", Keyserver: "https://keys.example.com"},
+		{WKD: "user"},
+		{Keyserver: "https://%"},
+	} {
+		_, err := src.urls(TestKeyFingerprint)
+		assert.Error(t, err, "%#v", src)
+	}
+}
+
+func TestPRSignedByKeySourceLoadKey(t *testing.T) {
+	cacheDir := useTemporaryKeyCache(t)
+	cachePath := filepath.Join(cacheDir, TestKeyFingerprint+".gpg")
+	keyData, err := os.ReadFile("fixtures/public-key.gpg")
+	require.NoError(t, err)
+	server := newKeyServerMock(t, "fixtures/public-key.gpg")
+	src := prSignedByKeySource{Keyserver: server.server.URL}
+
+	// Fetched, and cached
+	data, err := src.loadKey(context.Background(), TestKeyFingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, keyData, data)
+	require.Len(t, server.requests, 1)
+	assert.Equal(t, "/pks/lookup", server.requests[0].Path)
+	assert.Equal(t, "0x"+TestKeyFingerprint, server.requests[0].Query().Get("search"))
+	cached, err := os.ReadFile(cachePath)
+	require.NoError(t, err)
+	assert.Equal(t, keyData, cached)
+
+	// A fresh cached copy is used without contacting the server
+	server.keyPath = ""
+	data, err = src.loadKey(context.Background(), TestKeyFingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, keyData, data)
+	assert.Len(t, server.requests, 1)
+
+	// A stale cached copy is used if fetching fails
+	staleTime := time.Now().Add(-2 * keyCacheMaxAge)
+	err = os.Chtimes(cachePath, staleTime, staleTime)
+	require.NoError(t, err)
+	data, err = src.loadKey(context.Background(), TestKeyFingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, keyData, data)
+	assert.Len(t, server.requests, 2)
+
+	// Fetched data which does not contain the key is rejected, and does not replace the cached copy
+	server.keyPath = "fixtures/public-key-2.gpg"
+	data, err = src.loadKey(context.Background(), TestKeyFingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, keyData, data)
+	cached, err = os.ReadFile(cachePath)
+	require.NoError(t, err)
+	assert.Equal(t, keyData, cached)
+
+	// No cached copy, fetching fails
+	err = os.Remove(cachePath)
+	require.NoError(t, err)
+	for _, keyPath := range []string{"", "fixtures/public-key-2.gpg"} {
+		server.keyPath = keyPath
+		_, err = src.loadKey(context.Background(), TestKeyFingerprint)
+		assert.Error(t, err, keyPath)
+		_, err = os.Stat(cachePath)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	}
+
+	// WKD, falling back to the direct method
+	server.keyPath = "fixtures/public-key.gpg"
+	server.requests = nil
+	target, err := url.Parse(server.server.URL)
+	require.NoError(t, err)
+	transport := &rewriteHostTransport{target: target}
+	origHTTPClient := keySourceHTTPClient
+	keySourceHTTPClient = &http.Client{Transport: transport}
+	defer func() { keySourceHTTPClient = origHTTPClient }()
+	server.server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.requests = append(server.requests, r.URL)
+		if len(server.requests) == 1 { // The advanced method fails
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, server.keyPath)
+	})
+	src = prSignedByKeySource{WKD: "user@example.com"}
+	data, err = src.loadKey(context.Background(), TestKeyFingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, keyData, data)
+	assert.Equal(t, []string{"openpgpkey.example.com", "example.com"}, transport.hosts)
+	require.Len(t, server.requests, 2)
+	assert.Equal(t, "/.well-known/openpgpkey/example.com/hu/", path.Dir(server.requests[0].Path)+"/")
+	assert.Equal(t, "/.well-known/openpgpkey/hu/", path.Dir(server.requests[1].Path)+"/")
+	assert.Equal(t, "user", server.requests[1].Query().Get("l"))
+}
+
+func TestPRSignedByIsSignatureAuthorAcceptedWithKeyFingerprint(t *testing.T) {
+	useTemporaryKeyCache(t)
+	prm := NewPRMMatchExact()
+	testImage := dirImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	testImageSig, err := os.ReadFile("fixtures/dir-img-valid/signature-1")
+	require.NoError(t, err)
+	server := newKeyServerMock(t, "fixtures/pubring.gpg")
+
+	// Success
+	pr, err := NewPRSignedByKeyFingerprintFromKeyserver(SBKeyTypeGPGKeys, TestKeyFingerprint, server.server.URL, prm)
+	require.NoError(t, err)
+	sar, parsedSig, err := pr.isSignatureAuthorAccepted(context.Background(), testImage, testImageSig)
+	assertSARAccepted(t, sar, parsedSig, err, Signature{
+		DockerManifestDigest: TestImageManifestDigest,
+		DockerReference:      "testing/manifest:latest",
+	})
+
+	// The signing key is included in the fetched data, but it is not the one specified in the policy
+	pr, err = NewPRSignedByKeyFingerprintFromKeyserver(SBKeyTypeGPGKeys, TestKeyFingerprintWithPassphrase, server.server.URL, prm)
+	require.NoError(t, err)
+	sar, parsedSig, err = pr.isSignatureAuthorAccepted(context.Background(), testImage, testImageSig)
+	assertSARRejected(t, sar, parsedSig, err)
+
+	// The key can't be fetched
+	server.keyPath = ""
+	pr, err = NewPRSignedByKeyFingerprintFromKeyserver(SBKeyTypeGPGKeys, TestOtherFingerprint1, server.server.URL, prm)
+	require.NoError(t, err)
+	sar, parsedSig, err = pr.isSignatureAuthorAccepted(context.Background(), testImage, testImageSig)
+	assertSARRejected(t, sar, parsedSig, err)
+
+	// keyFingerprint without keySource, normally rejected by newPRSignedByKeyFingerprint
+	sar, parsedSig, err = (&prSignedBy{
+		KeyType:        SBKeyTypeGPGKeys,
+		KeyFingerprint: TestKeyFingerprint,
+		SignedIdentity: prm,
+	}).isSignatureAuthorAccepted(context.Background(), testImage, testImageSig)
+	assertSARRejected(t, sar, parsedSig, err)
+}
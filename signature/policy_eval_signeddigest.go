@@ -0,0 +1,76 @@
+// Selection of the manifests whose signatures can be accepted, for prSignedBy.SignedDigest and prSigstoreSigned.SignedDigest.
+
+package signature
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/manifest"
+)
+
+// signatureSubjects returns the images whose signatures can be accepted for image, in the order they should be tried,
+// according to scope.
+// The returned images share the Reference() of image, so signed identities can be matched against any of them;
+// but each signature must be claiming the manifest digest of the image it was read from.
+func signatureSubjects(ctx context.Context, image private.UnparsedImage, scope signedDigestScope) ([]private.UnparsedImage, error) {
+	switch scope {
+	case "", SignedDigestInstance:
+		return []private.UnparsedImage{image}, nil
+	case SignedDigestIndex, SignedDigestEither:
+	default: // This should never happen, the policy parser ensures scope.IsValid().
+		return nil, fmt.Errorf(`Unknown "signedDigest" value %q`, string(scope))
+	}
+
+	var topLevel private.UnparsedImage
+	if withTopLevel, ok := image.(private.UnparsedImageWithTopLevel); ok {
+		topLevel = withTopLevel.TopLevel()
+	}
+	if topLevel == nil {
+		// image is not (known to be) an instance of a multi-platform image, so the instance and the index are the same manifest.
+		return []private.UnparsedImage{image}, nil
+	}
+	if err := verifyInstanceOfTopLevel(ctx, image, topLevel); err != nil {
+		if scope == SignedDigestEither {
+			log.DebugfContext(ctx, "Not using signatures of the top-level image: %v", err)
+			return []private.UnparsedImage{image}, nil
+		}
+		return nil, err
+	}
+	if scope == SignedDigestIndex {
+		return []private.UnparsedImage{topLevel}, nil
+	}
+	return []private.UnparsedImage{image, topLevel}, nil
+}
+
+// verifyInstanceOfTopLevel returns nil if the manifest of image is an instance of the manifest list in topLevel,
+// so that a signature of topLevel vouches for image.
+func verifyInstanceOfTopLevel(ctx context.Context, image, topLevel private.UnparsedImage) error {
+	topLevelManifest, topLevelMIMEType, err := topLevel.Manifest(ctx)
+	if err != nil {
+		return err
+	}
+	if !manifest.MIMETypeIsMultiImage(topLevelMIMEType) {
+		return PolicyRequirementError(fmt.Sprintf("The top-level manifest is not a manifest list, but %q", topLevelMIMEType))
+	}
+	list, err := manifest.ListFromBlob(topLevelManifest, topLevelMIMEType)
+	if err != nil {
+		return fmt.Errorf("parsing the top-level manifest list: %w", err)
+	}
+	instanceManifest, _, err := image.Manifest(ctx)
+	if err != nil {
+		return err
+	}
+	instanceDigest, err := manifest.Digest(instanceManifest)
+	if err != nil {
+		return err
+	}
+	for _, d := range list.Instances() {
+		if d == instanceDigest {
+			return nil
+		}
+	}
+	return PolicyRequirementError(fmt.Sprintf("Image %s is not an instance of the top-level manifest list", instanceDigest))
+}
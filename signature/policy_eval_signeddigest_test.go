@@ -0,0 +1,298 @@
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature/internal"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const signedDigestTestReference = "example.com/multi-arch:latest"
+
+// multiImageMock is a mock of private.UnparsedImageWithTopLevel, with static contents.
+type multiImageMock struct {
+	ref      reference.Named
+	manifest []byte
+	mimeType string
+	sigs     []signature.Signature
+	topLevel *multiImageMock // nil if this is a top-level image
+}
+
+func (m *multiImageMock) Reference() types.ImageReference {
+	return refImageReferenceMock{ref: m.ref}
+}
+
+func (m *multiImageMock) Manifest(ctx context.Context) ([]byte, string, error) {
+	return m.manifest, m.mimeType, nil
+}
+
+func (m *multiImageMock) Signatures(ctx context.Context) ([][]byte, error) {
+	res := [][]byte{}
+	for _, sig := range m.sigs {
+		if sig, ok := sig.(signature.SimpleSigning); ok {
+			res = append(res, sig.UntrustedSignature())
+		}
+	}
+	return res, nil
+}
+
+func (m *multiImageMock) UntrustedSignatures(ctx context.Context) ([]signature.Signature, error) {
+	return m.sigs, nil
+}
+
+func (m *multiImageMock) TopLevel() private.UnparsedImage {
+	if m.topLevel == nil {
+		return nil
+	}
+	return m.topLevel
+}
+
+// multiImageTestManifests returns an OCI index, an instance manifest listed in the index, and an instance manifest not listed there.
+func multiImageTestManifests(t *testing.T) (index []byte, instance1, instance2 []byte) {
+	instanceManifest := func(arch string) []byte {
+		m, err := json.Marshal(imgspecv1.Manifest{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Config: imgspecv1.Descriptor{
+				MediaType: imgspecv1.MediaTypeImageConfig,
+				Digest:    digest.Digest("sha256:" + strings.Repeat("a", 64)),
+				Size:      int64(len(arch)),
+			},
+			Layers: []imgspecv1.Descriptor{},
+		})
+		require.NoError(t, err)
+		return m
+	}
+	instance1 = instanceManifest("amd64")
+	instance2 = instanceManifest("arm64-with-a-different-size")
+	return multiImageIndex(t, instance1), instance1, instance2
+}
+
+// multiImageIndex returns an OCI index containing instances.
+func multiImageIndex(t *testing.T, instances ...[]byte) []byte {
+	descriptors := []imgspecv1.Descriptor{}
+	for _, m := range instances {
+		descriptors = append(descriptors, imgspecv1.Descriptor{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Digest:    digestOf(t, m),
+			Size:      int64(len(m)),
+			Platform:  &imgspecv1.Platform{OS: "linux", Architecture: "amd64"},
+		})
+	}
+	index, err := manifest.OCI1IndexFromComponents(descriptors, nil).Serialize()
+	require.NoError(t, err)
+	return index
+}
+
+func digestOf(t *testing.T, m []byte) digest.Digest {
+	d, err := manifest.Digest(m)
+	require.NoError(t, err)
+	return d
+}
+
+// multiImageMocks returns a mock of the index, and of the instance with manifest instanceManifest, using the specified signatures.
+func multiImageMocks(t *testing.T, index, instanceManifest []byte, indexSigs, instanceSigs []signature.Signature) (topLevel, instance *multiImageMock) {
+	ref, err := reference.ParseNormalizedNamed(signedDigestTestReference)
+	require.NoError(t, err)
+	topLevel = &multiImageMock{ref: ref, manifest: index, mimeType: imgspecv1.MediaTypeImageIndex, sigs: indexSigs}
+	instance = &multiImageMock{ref: ref, manifest: instanceManifest, mimeType: imgspecv1.MediaTypeImageManifest, sigs: instanceSigs,
+		topLevel: topLevel}
+	return topLevel, instance
+}
+
+func TestSignatureSubjects(t *testing.T) {
+	ctx := context.Background()
+	index, instance1, instance2 := multiImageTestManifests(t)
+	topLevel, instance := multiImageMocks(t, index, instance1, nil, nil)
+	_, otherInstance := multiImageMocks(t, index, instance2, nil, nil)
+	otherInstance.topLevel = topLevel
+
+	for _, c := range []struct {
+		image    private.UnparsedImage
+		scope    signedDigestScope
+		expected []private.UnparsedImage
+	}{
+		{instance, "", []private.UnparsedImage{instance}},
+		{instance, SignedDigestInstance, []private.UnparsedImage{instance}},
+		{instance, SignedDigestIndex, []private.UnparsedImage{topLevel}},
+		{instance, SignedDigestEither, []private.UnparsedImage{instance, topLevel}},
+		// A top-level image is both the instance and the index
+		{topLevel, SignedDigestIndex, []private.UnparsedImage{topLevel}},
+		{topLevel, SignedDigestEither, []private.UnparsedImage{topLevel}},
+		// An instance not listed in the index
+		{otherInstance, SignedDigestInstance, []private.UnparsedImage{otherInstance}},
+		{otherInstance, SignedDigestIndex, nil},
+		{otherInstance, SignedDigestEither, []private.UnparsedImage{otherInstance}},
+	} {
+		res, err := signatureSubjects(ctx, c.image, c.scope)
+		if c.expected == nil {
+			assert.ErrorAs(t, err, new(PolicyRequirementError), c.scope)
+		} else {
+			require.NoError(t, err, c.scope)
+			assert.Equal(t, c.expected, res, c.scope)
+		}
+	}
+
+	// Images which don’t implement private.UnparsedImageWithTopLevel are treated as top-level images
+	image := dirImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest")
+	res, err := signatureSubjects(ctx, image, SignedDigestIndex)
+	require.NoError(t, err)
+	assert.Equal(t, []private.UnparsedImage{image}, res)
+
+	// The top-level manifest is not a manifest list
+	_, notAList := multiImageMocks(t, instance2, instance1, nil, nil)
+	notAList.topLevel.mimeType = imgspecv1.MediaTypeImageManifest
+	_, err = signatureSubjects(ctx, notAList, SignedDigestIndex)
+	assert.ErrorAs(t, err, new(PolicyRequirementError))
+
+	// Invalid scope
+	_, err = signatureSubjects(ctx, instance, signedDigestScope("this is invalid"))
+	assert.Error(t, err)
+}
+
+// sigstoreSignatureForManifest returns a sigstore signature of m, made by signer.
+func sigstoreSignatureForManifest(t *testing.T, signer crypto.Signer, m []byte) signature.Signature {
+	payload, err := json.Marshal(internal.NewUntrustedSigstorePayload(digestOf(t, m), signedDigestTestReference))
+	require.NoError(t, err)
+	s, err := sigstoreSignature.LoadSigner(signer, crypto.SHA256)
+	require.NoError(t, err)
+	sig, err := s.SignMessage(strings.NewReader(string(payload)))
+	require.NoError(t, err)
+	return signature.SigstoreFromComponents(signature.SigstoreSignatureMIMEType, payload, map[string]string{
+		signature.SigstoreSignatureAnnotationKey: base64.StdEncoding.EncodeToString(sig),
+	})
+}
+
+func TestPRSigstoreSignedSignedDigest(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyPEM, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
+	require.NoError(t, err)
+	index, instance1, _ := multiImageTestManifests(t)
+	indexSig := sigstoreSignatureForManifest(t, key, index)
+	instanceSig := sigstoreSignatureForManifest(t, key, instance1)
+
+	for _, c := range []struct {
+		scope                  signedDigestScope
+		instanceSignedAccepted bool
+		indexSignedAccepted    bool
+	}{
+		{"", true, false},
+		{SignedDigestInstance, true, false},
+		{SignedDigestIndex, false, true},
+		{SignedDigestEither, true, true},
+	} {
+		opts := []PRSigstoreSignedOption{
+			PRSigstoreSignedWithKeyData(keyPEM),
+			PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+		}
+		if c.scope != "" {
+			opts = append(opts, PRSigstoreSignedWithSignedDigest(c.scope))
+		}
+		pr, err := newPRSigstoreSigned(opts...)
+		require.NoError(t, err)
+
+		_, instance := multiImageMocks(t, index, instance1, nil, []signature.Signature{instanceSig})
+		allowed, err := pr.isRunningImageAllowed(context.Background(), instance)
+		if c.instanceSignedAccepted {
+			assertRunningAllowed(t, allowed, err)
+		} else {
+			assertRunningRejectedPolicyRequirement(t, allowed, err)
+		}
+
+		_, instance = multiImageMocks(t, index, instance1, []signature.Signature{indexSig}, nil)
+		allowed, err = pr.isRunningImageAllowed(context.Background(), instance)
+		if c.indexSignedAccepted {
+			assertRunningAllowed(t, allowed, err)
+		} else {
+			assertRunningRejectedPolicyRequirement(t, allowed, err)
+		}
+
+		// A signature of the index attached to the instance is never accepted.
+		_, instance = multiImageMocks(t, index, instance1, nil, []signature.Signature{indexSig})
+		allowed, err = pr.isRunningImageAllowed(context.Background(), instance)
+		assertRunningRejectedPolicyRequirement(t, allowed, err)
+	}
+}
+
+func TestPRSignedBySignedDigest(t *testing.T) {
+	// fixtures/image.signature is a signature of fixtures/image.manifest.json; use it as an instance.
+	instanceManifest, err := os.ReadFile("fixtures/image.manifest.json")
+	require.NoError(t, err)
+	instanceSigBlob, err := os.ReadFile("fixtures/image.signature")
+	require.NoError(t, err)
+	instanceSig := signature.SimpleSigningFromBlob(instanceSigBlob)
+	index := multiImageIndex(t, instanceManifest)
+	var indexSig signature.Signature // Only available if signing is supported
+	mech, err := newGPGSigningMechanismInDirectory(testGPGHomeDirectory)
+	require.NoError(t, err)
+	defer mech.Close()
+	if err := mech.SupportsSigning(); err == nil {
+		sig, err := SignDockerManifest(index, TestImageSignatureReference, mech, TestKeyFingerprint)
+		require.NoError(t, err)
+		indexSig = signature.SimpleSigningFromBlob(sig)
+	}
+
+	for _, c := range []struct {
+		scope                  string
+		instanceSignedAccepted bool
+		indexSignedAccepted    bool
+	}{
+		{"", true, false},
+		{string(SignedDigestInstance), true, false},
+		{string(SignedDigestIndex), false, true},
+		{string(SignedDigestEither), true, true},
+	} {
+		policy := `{"type":"signedBy","keyType":"GPGKeys","keyPath":"fixtures/public-key.gpg",` +
+			`"signedIdentity":{"type":"exactRepository","dockerRepository":"` + TestImageSignatureReference + `"}`
+		if c.scope != "" {
+			policy += fmt.Sprintf(`,"signedDigest":%q`, c.scope)
+		}
+		policy += "}"
+		var pr prSignedBy
+		err := json.Unmarshal([]byte(policy), &pr)
+		require.NoError(t, err)
+
+		_, instance := multiImageMocks(t, index, instanceManifest, nil, []signature.Signature{instanceSig})
+		allowed, err := pr.isRunningImageAllowed(context.Background(), instance)
+		if c.instanceSignedAccepted {
+			assertRunningAllowed(t, allowed, err)
+		} else {
+			assertRunningRejectedPolicyRequirement(t, allowed, err)
+		}
+
+		// A signature of the instance attached to the index is never accepted.
+		_, instance = multiImageMocks(t, index, instanceManifest, []signature.Signature{instanceSig}, nil)
+		allowed, err = pr.isRunningImageAllowed(context.Background(), instance)
+		assertRunningRejectedPolicyRequirement(t, allowed, err)
+
+		if indexSig != nil {
+			_, instance = multiImageMocks(t, index, instanceManifest, []signature.Signature{indexSig}, nil)
+			allowed, err = pr.isRunningImageAllowed(context.Background(), instance)
+			if c.indexSignedAccepted {
+				assertRunningAllowed(t, allowed, err)
+			} else {
+				assertRunningRejectedPolicyRequirement(t, allowed, err)
+			}
+		}
+	}
+}
@@ -7,10 +7,13 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/multierr"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/signature"
@@ -39,24 +42,70 @@ func loadBytesFromDataOrPath(prefix string, data []byte, path string) ([]byte, e
 	}
 }
 
-// prepareTrustRoot creates a fulcioTrustRoot from the input data.
+// prepareTrustRoot creates a fulcioTrustRoot from the input data, using CA certificates from trustedRoot if it is not nil.
 // (This also prevents external implementations of this interface, ensuring that prSigstoreSignedFulcio is the only one.)
-func (f *prSigstoreSignedFulcio) prepareTrustRoot() (*fulcioTrustRoot, error) {
-	caCertBytes, err := loadBytesFromDataOrPath("fulcioCA", f.CAData, f.CAPath)
+func (f *prSigstoreSignedFulcio) prepareTrustRoot(trustedRoot *sigstoreTrustedRoot) (*fulcioTrustRoot, error) {
+	fulcio := fulcioTrustRoot{
+		oidcIssuer:         f.OIDCIssuer,
+		subjectEmail:       f.SubjectEmail,
+		subjectURI:         f.SubjectURI,
+		ignoreRelevantTime: f.IntegratedTimeCheck == IntegratedTimeCheckIgnore,
+		clockSkew:          time.Duration(f.IntegratedTimeClockSkewSeconds) * time.Second,
+	}
+	oidcIssuerRegex, err := compileFulcioIdentityRegex("oidcIssuerRegex", f.OIDCIssuerRegex)
+	if err != nil {
+		return nil, err
+	}
+	fulcio.oidcIssuerRegex = oidcIssuerRegex
+	subjectEmailRegex, err := compileFulcioIdentityRegex("subjectEmailRegex", f.SubjectEmailRegex)
+	if err != nil {
+		return nil, err
+	}
+	fulcio.subjectEmailRegex = subjectEmailRegex
+	subjectURIRegex, err := compileFulcioIdentityRegex("subjectURIRegex", f.SubjectURIRegex)
 	if err != nil {
 		return nil, err
 	}
-	if caCertBytes == nil {
-		return nil, errors.New(`Internal inconsistency: Fulcio specified with neither "caPath" nor "caData"`)
+	fulcio.subjectURIRegex = subjectURIRegex
+	if f.CIIdentity != nil {
+		identity, err := f.CIIdentity.expectedIdentity()
+		if err != nil {
+			return nil, err
+		}
+		fulcio.oidcIssuer = identity.oidcIssuer
+		fulcio.subjectEmail = identity.subjectEmail
+		fulcio.subjectURI = identity.subjectURI
+		fulcio.subjectURIPrefix = identity.subjectURIPrefix
+		fulcio.githubWorkflowTrigger = identity.githubWorkflowTrigger
+		fulcio.githubWorkflowSHA = identity.githubWorkflowSHA
 	}
-	certs := x509.NewCertPool()
-	if ok := certs.AppendCertsFromPEM(caCertBytes); !ok {
-		return nil, errors.New("error loading Fulcio CA certificates")
+	if f.IssuedAfter != nil {
+		fulcio.issuedAfter = *f.IssuedAfter
 	}
-	fulcio := fulcioTrustRoot{
-		caCertificates: certs,
-		oidcIssuer:     f.OIDCIssuer,
-		subjectEmail:   f.SubjectEmail,
+	if f.IssuedBefore != nil {
+		fulcio.issuedBefore = *f.IssuedBefore
+	}
+	caCertBytes, err := loadBytesFromDataOrPath("fulcioCA", f.CAData, f.CAPath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case caCertBytes != nil && trustedRoot != nil: // newPRSigstoreSigned rejects such combinations.
+		return nil, errors.New(`Internal inconsistency: Fulcio specified with both "caPath"/"caData" and a trusted root`)
+	case caCertBytes != nil:
+		certs := x509.NewCertPool()
+		if ok := certs.AppendCertsFromPEM(caCertBytes); !ok {
+			return nil, errors.New("error loading Fulcio CA certificates")
+		}
+		fulcio.caCertificates = certs
+	case trustedRoot != nil:
+		if len(trustedRoot.certificateAuthorities) == 0 {
+			return nil, errors.New("Sigstore trusted root does not contain any Fulcio certificate authorities")
+		}
+		fulcio.certificateAuthorities = trustedRoot.certificateAuthorities
+		fulcio.ctLogPublicKeys = trustedRoot.ctLogPublicKeys
+	default: // newPRSigstoreSigned rejects such combinations.
+		return nil, errors.New(`Internal inconsistency: Fulcio specified with neither "caPath" nor "caData", nor a trusted root`)
 	}
 	if err := fulcio.validate(); err != nil {
 		return nil, err
@@ -64,14 +113,21 @@ func (f *prSigstoreSignedFulcio) prepareTrustRoot() (*fulcioTrustRoot, error) {
 	return &fulcio, nil
 }
 
+// specifiesCA returns true if the CA certificates are specified directly, instead of using a trusted root.
+func (f *prSigstoreSignedFulcio) specifiesCA() bool {
+	return f.CAPath != "" || f.CAData != nil
+}
+
 // sigstoreSignedTrustRoot contains an already parsed version of the prSigstoreSigned policy
 type sigstoreSignedTrustRoot struct {
-	publicKey      []crypto.PublicKey
-	fulcio         *fulcioTrustRoot
-	rekorPublicKey *ecdsa.PublicKey
+	publicKey       []crypto.PublicKey
+	fulcio          []*fulcioTrustRoot        // Empty if Fulcio is not used; signatures accepted by any of them are accepted.
+	fulcioNames     []string                  // Names of the fulcio elements, for use in error messages.
+	rekorPublicKeys []internal.RekorPublicKey // Empty if no Rekor public keys are configured; more than one for a sharded log.
+	tsaCertificates []*x509.Certificate       // Empty if no timestamp authority is configured.
 }
 
-func (pr *prSigstoreSigned) prepareTrustRoot() (*sigstoreSignedTrustRoot, error) {
+func (pr *prSigstoreSigned) prepareTrustRoot(ctx context.Context) (*sigstoreSignedTrustRoot, error) {
 	res := sigstoreSignedTrustRoot{}
 
 	pks := []crypto.PublicKey{}
@@ -133,20 +189,57 @@ func (pr *prSigstoreSigned) prepareTrustRoot() (*sigstoreSignedTrustRoot, error)
 
 	res.publicKey = pks
 
-	if pr.Fulcio != nil {
-		f, err := pr.Fulcio.prepareTrustRoot()
+	var trustedRoot *sigstoreTrustedRoot
+	trustedRootBytes, err := loadBytesFromDataOrPath("trustedRoot", pr.TrustedRootData, pr.TrustedRootPath)
+	if err != nil {
+		return nil, err
+	}
+	if pr.TrustRoot != nil {
+		if trustedRootBytes != nil { // newPRSigstoreSigned rejects such combinations.
+			return nil, errors.New("Internal inconsistency: Both a TUF trust root and a trusted root specified")
+		}
+		trustedRootBytes, err = pr.TrustRoot.loadTrustedRoot(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loading Sigstore trusted root using TUF: %w", err)
+		}
+	}
+	if trustedRootBytes != nil {
+		trustedRoot, err = parseSigstoreTrustedRoot(trustedRootBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, fulcio := range pr.fulcioRoots() {
+		f, err := fulcio.prepareTrustRoot(trustedRoot)
 		if err != nil {
+			if pr.Fulcios != nil {
+				return nil, fmt.Errorf("%s: %w", pr.fulcioRootName(i), err)
+			}
 			return nil, err
 		}
-		res.fulcio = f
+		res.fulcio = append(res.fulcio, f)
+		res.fulcioNames = append(res.fulcioNames, pr.fulcioRootName(i))
 	}
 
+	var rekorPublicKeyPEMs [][]byte
 	rekorPublicKeyPEM, err := loadBytesFromDataOrPath("rekorPublicKey", pr.RekorPublicKeyData, pr.RekorPublicKeyPath)
 	if err != nil {
 		return nil, err
 	}
 	if rekorPublicKeyPEM != nil {
-		pk, err := cryptoutils.UnmarshalPEMToPublicKey(rekorPublicKeyPEM)
+		rekorPublicKeyPEMs = append(rekorPublicKeyPEMs, rekorPublicKeyPEM)
+	}
+	for _, path := range pr.RekorPublicKeyPaths {
+		keyPEM, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rekorPublicKeyPEMs = append(rekorPublicKeyPEMs, keyPEM)
+	}
+	rekorPublicKeyPEMs = append(rekorPublicKeyPEMs, pr.RekorPublicKeyDatas...)
+	for _, keyPEM := range rekorPublicKeyPEMs {
+		pk, err := cryptoutils.UnmarshalPEMToPublicKey(keyPEM)
 		if err != nil {
 			return nil, fmt.Errorf("parsing Rekor public key: %w", err)
 		}
@@ -155,7 +248,28 @@ func (pr *prSigstoreSigned) prepareTrustRoot() (*sigstoreSignedTrustRoot, error)
 			return nil, fmt.Errorf("Rekor public key is not using ECDSA")
 
 		}
-		res.rekorPublicKey = pkECDSA
+		res.rekorPublicKeys = append(res.rekorPublicKeys, internal.RekorPublicKey{Key: pkECDSA})
+	}
+	if trustedRoot != nil {
+		if len(res.rekorPublicKeys) != 0 { // newPRSigstoreSigned rejects such combinations.
+			return nil, errors.New("Internal inconsistency: Both Rekor public keys and a trusted root specified")
+		}
+		res.rekorPublicKeys = trustedRoot.rekorPublicKeys
+	}
+
+	tsaCertPEM, err := loadBytesFromDataOrPath("tsaCert", pr.TSACertData, pr.TSACertPath)
+	if err != nil {
+		return nil, err
+	}
+	if tsaCertPEM != nil {
+		certs, err := cryptoutils.UnmarshalCertificatesFromPEM(tsaCertPEM)
+		if err != nil {
+			return nil, fmt.Errorf("parsing TSA certificates: %w", err)
+		}
+		if len(certs) == 0 {
+			return nil, errors.New("no TSA certificates found")
+		}
+		res.tsaCertificates = certs
 	}
 
 	return &res, nil
@@ -168,31 +282,80 @@ func (pr *prSigstoreSigned) isSignatureAuthorAccepted(ctx context.Context, image
 }
 
 func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image private.UnparsedImage, sig signature.Sigstore) (signatureAcceptanceResult, error) {
-	// FIXME: move this to per-context initialization
-	trustRoot, err := pr.prepareTrustRoot()
+	res, _, err := pr.verifySignature(ctx, image, sig)
+	return res, err
+}
+
+// verifySignature is isSignatureAccepted, which also returns a RejectionClass if the result is sarRejected.
+func (pr *prSigstoreSigned) verifySignature(ctx context.Context, image private.UnparsedImage, sig signature.Sigstore) (signatureAcceptanceResult, RejectionClass, error) {
+	defer recordSigstoreVerification(ctx, time.Now())
+	trustRoot, err := pr.sigstoreTrustRoot(ctx)
 	if err != nil {
-		return sarRejected, err
+		return sarRejected, RejectionOther, err
 	}
 
 	untrustedAnnotations := sig.UntrustedAnnotations()
+	untrustedPayload := sig.UntrustedPayload()
+	// With a DSSE envelope, the cryptographic signature is a part of the payload, and the annotation is usually empty.
+	isDSSE := sig.UntrustedMIMEType() == signature.SigstoreDSSEEnvelopeMIMEType
+	if signature.IsSigstoreBundleMIMEType(sig.UntrustedMIMEType()) {
+		// A bundle is self-contained; it is verified as the DSSE envelope it contains, with annotations derived from the bundle
+		// (ignoring any annotations of the attachment).
+		untrustedPayload, untrustedAnnotations, err = internal.UntrustedSigstoreBundleComponents(untrustedPayload)
+		if err != nil {
+			return sarRejected, RejectionOther, err
+		}
+		isDSSE = true
+	}
 	untrustedBase64Signature, ok := untrustedAnnotations[signature.SigstoreSignatureAnnotationKey]
-	if !ok {
-		return sarRejected, fmt.Errorf("missing %s annotation", signature.SigstoreSignatureAnnotationKey)
+	if !ok && !isDSSE {
+		return sarRejected, RejectionOther, fmt.Errorf("missing %s annotation", signature.SigstoreSignatureAnnotationKey)
+	}
+
+	var timestampTime time.Time // Only set if trustRoot.tsaCertificates is not empty.
+	// timestampedDSSESignature, if not nil, is the only signature of a DSSE envelope which may be used:
+	// the timestamp refers to it, not to the rest of the envelope.
+	var timestampedDSSESignature []byte
+	if len(trustRoot.tsaCertificates) > 0 {
+		untrustedTimestamp, ok := untrustedAnnotations[signature.SigstoreRFC3161TimestampAnnotationKey]
+		if !ok {
+			return sarRejected, RejectionTimestampFailure, fmt.Errorf("missing %s annotation", signature.SigstoreRFC3161TimestampAnnotationKey)
+		}
+		var untrustedTimestampedData [][]byte
+		if isDSSE {
+			untrustedTimestampedData, err = internal.DSSETimestampedData(untrustedPayload)
+			if err != nil {
+				return sarRejected, RejectionOther, err
+			}
+		} else {
+			untrustedSignature, err := base64.StdEncoding.DecodeString(untrustedBase64Signature)
+			if err != nil {
+				return sarRejected, RejectionOther, internal.NewInvalidSignatureError(fmt.Sprintf("decoding signature base64: %v", err))
+			}
+			untrustedTimestampedData = [][]byte{untrustedSignature}
+		}
+		var timestampedIndex int
+		timestampTime, timestampedIndex, err = internal.VerifyRFC3161Timestamp(trustRoot.tsaCertificates, []byte(untrustedTimestamp), untrustedTimestampedData)
+		if err != nil {
+			return sarRejected, RejectionTimestampFailure, err
+		}
+		if isDSSE && timestampedIndex > 0 { // Index 0 is the whole envelope, which covers all of its signatures.
+			timestampedDSSESignature = untrustedTimestampedData[timestampedIndex]
+		}
 	}
-	untrustedPayload := sig.UntrustedPayload()
 
 	var publicKeys []crypto.PublicKey
 	switch {
-	case len(trustRoot.publicKey) > 0 && trustRoot.fulcio != nil: // newPRSigstoreSigned rejects such combinations.
-		return sarRejected, errors.New("Internal inconsistency: Both a public key and Fulcio CA specified")
-	case len(trustRoot.publicKey) == 0 && trustRoot.fulcio == nil: // newPRSigstoreSigned rejects such combinations.
-		return sarRejected, errors.New("Internal inconsistency: Neither a public key nor a Fulcio CA specified")
+	case len(trustRoot.publicKey) > 0 && len(trustRoot.fulcio) > 0: // newPRSigstoreSigned rejects such combinations.
+		return sarRejected, RejectionOther, errors.New("Internal inconsistency: Both a public key and Fulcio CA specified")
+	case len(trustRoot.publicKey) == 0 && len(trustRoot.fulcio) == 0: // newPRSigstoreSigned rejects such combinations.
+		return sarRejected, RejectionOther, errors.New("Internal inconsistency: Neither a public key nor a Fulcio CA specified")
 
 	case len(trustRoot.publicKey) > 0:
-		if trustRoot.rekorPublicKey != nil {
+		if len(trustRoot.rekorPublicKeys) > 0 {
 			untrustedSET, ok := untrustedAnnotations[signature.SigstoreSETAnnotationKey]
 			if !ok { // For user convenience; passing an empty []byte to VerifyRekorSet should work.
-				return sarRejected, fmt.Errorf("missing %s annotation", signature.SigstoreSETAnnotationKey)
+				return sarRejected, RejectionRekorFailure, fmt.Errorf("missing %s annotation", signature.SigstoreSETAnnotationKey)
 			}
 
 			for i := range trustRoot.publicKey {
@@ -202,54 +365,109 @@ func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image priva
 				if err != nil {
 					// Coverage: The key was loaded from a PEM format, so it’s unclear how this could fail.
 					// (PEM is not essential, MarshalPublicKeyToPEM can only fail if marshaling to ASN1.DER fails.)
-					return sarRejected, fmt.Errorf("re-marshaling public key to PEM: %w", err)
+					return sarRejected, RejectionOther, fmt.Errorf("re-marshaling public key to PEM: %w", err)
 
 				}
 				// We don’t care about the Rekor timestamp, just about log presence.
-				if _, err := internal.VerifyRekorSET(trustRoot.rekorPublicKey, []byte(untrustedSET), recreatedPublicKeyPEM, untrustedBase64Signature, untrustedPayload); err != nil {
-					return sarRejected, err
+				if isDSSE {
+					// Rekor records DSSE envelopes as "dsse" entries, referring to the envelope instead of a separate signature.
+					_, err = internal.VerifyRekorSETForDSSE(trustRoot.rekorPublicKeys, []byte(untrustedSET), recreatedPublicKeyPEM, untrustedPayload)
+				} else {
+					_, err = internal.VerifyRekorSET(trustRoot.rekorPublicKeys, []byte(untrustedSET), recreatedPublicKeyPEM, untrustedBase64Signature, untrustedPayload)
+				}
+				if err != nil {
+					return sarRejected, RejectionRekorFailure, err
 				}
 			}
 		}
 		publicKeys = trustRoot.publicKey
 
-	case trustRoot.fulcio != nil:
-		if trustRoot.rekorPublicKey == nil { // newPRSigstoreSigned rejects such combinations.
-			return sarRejected, errors.New("Internal inconsistency: Fulcio CA specified without a Rekor public key")
-		}
-		untrustedSET, ok := untrustedAnnotations[signature.SigstoreSETAnnotationKey]
-		if !ok { // For user convenience; passing an empty []byte to VerifyRekorSet should correctly reject it anyway.
-			return sarRejected, fmt.Errorf("missing %s annotation", signature.SigstoreSETAnnotationKey)
+	case len(trustRoot.fulcio) > 0:
+		if len(trustRoot.rekorPublicKeys) == 0 && len(trustRoot.tsaCertificates) == 0 { // newPRSigstoreSigned rejects such combinations.
+			return sarRejected, RejectionOther, errors.New("Internal inconsistency: Fulcio CA specified without a Rekor public key or a TSA certificate")
 		}
 		untrustedCert, ok := untrustedAnnotations[signature.SigstoreCertificateAnnotationKey]
 		if !ok { // For user convenience; passing an empty []byte to VerifyRekorSet should correctly reject it anyway.
-			return sarRejected, fmt.Errorf("missing %s annotation", signature.SigstoreCertificateAnnotationKey)
+			return sarRejected, RejectionOther, fmt.Errorf("missing %s annotation", signature.SigstoreCertificateAnnotationKey)
 		}
 		var untrustedIntermediateChainBytes []byte
 		if untrustedIntermediateChain, ok := untrustedAnnotations[signature.SigstoreIntermediateCertificateChainAnnotationKey]; ok {
 			untrustedIntermediateChainBytes = []byte(untrustedIntermediateChain)
 		}
-		pk, err := verifyRekorFulcio(trustRoot.rekorPublicKey, trustRoot.fulcio,
-			[]byte(untrustedSET), []byte(untrustedCert), untrustedIntermediateChainBytes, untrustedBase64Signature, untrustedPayload)
-		if err != nil {
-			return sarRejected, err
+		var untrustedSET string
+		if len(trustRoot.rekorPublicKeys) > 0 {
+			untrustedSET, ok = untrustedAnnotations[signature.SigstoreSETAnnotationKey]
+			if !ok { // For user convenience; passing an empty []byte to VerifyRekorSet should correctly reject it anyway.
+				return sarRejected, RejectionRekorFailure, fmt.Errorf("missing %s annotation", signature.SigstoreSETAnnotationKey)
+			}
+		}
+		verifyFulcio := func(fulcio *fulcioTrustRoot) (crypto.PublicKey, RejectionClass, error) {
+			var pk crypto.PublicKey
+			var err error
+			if len(trustRoot.rekorPublicKeys) > 0 {
+				if isDSSE {
+					pk, err = verifyRekorFulcioDSSE(trustRoot.rekorPublicKeys, fulcio,
+						[]byte(untrustedSET), []byte(untrustedCert), untrustedIntermediateChainBytes, untrustedPayload)
+				} else {
+					pk, err = verifyRekorFulcio(trustRoot.rekorPublicKeys, fulcio,
+						[]byte(untrustedSET), []byte(untrustedCert), untrustedIntermediateChainBytes, untrustedBase64Signature, untrustedPayload)
+				}
+				if err != nil {
+					class := RejectionKeyMismatch // The certificate was not issued by a trusted Fulcio CA, or for a trusted identity
+					if errors.As(err, new(rekorVerificationError)) {
+						class = RejectionRekorFailure
+					}
+					return nil, class, err
+				}
+			}
+			if len(trustRoot.tsaCertificates) > 0 {
+				// The certificate must also be valid at the time recorded by the timestamp authority.
+				pk, err = fulcio.verifyFulcioCertificateAtTime(timestampTime, []byte(untrustedCert), untrustedIntermediateChainBytes)
+				if err != nil {
+					return nil, RejectionKeyMismatch, err
+				}
+			}
+			return pk, "", nil
+		}
+		// With several Fulcio roots, the certificate must be accepted by any one of them.
+		fulcioErrs := []error{}
+		class := RejectionKeyMismatch
+		for i, fulcio := range trustRoot.fulcio {
+			pk, c, err := verifyFulcio(fulcio)
+			if err != nil {
+				if len(trustRoot.fulcio) == 1 {
+					return sarRejected, c, err
+				}
+				if c == RejectionRekorFailure { // The Rekor SET does not depend on the Fulcio root.
+					class = c
+				}
+				fulcioErrs = append(fulcioErrs, fmt.Errorf("%s: %w", trustRoot.fulcioNames[i], err))
+				continue
+			}
+			publicKeys = []crypto.PublicKey{pk}
+			break
+		}
+		if len(publicKeys) == 0 {
+			return sarRejected, class, multierr.Format("The certificate was not accepted by any Fulcio root: ", "; ", "", fulcioErrs)
 		}
-		publicKeys = []crypto.PublicKey{pk}
 	}
 
 	if len(publicKeys) == 0 {
 		// Coverage: This should never happen, we have already excluded the possibility in the switch above.
-		return sarRejected, fmt.Errorf("Internal inconsistency: publicKey not set before verifying sigstore payload")
+		return sarRejected, RejectionOther, fmt.Errorf("Internal inconsistency: publicKey not set before verifying sigstore payload")
 	}
 
 	errs := make([]error, len(publicKeys))
 	hasPolicyRequirementError := false
+	// If no public key succeeds in verifying the cryptographic signature, the signature was not made by a trusted key.
+	class := RejectionKeyMismatch
 
 	for _, publicKey := range publicKeys {
-		signature, err := internal.VerifySigstorePayload(publicKey, untrustedPayload, untrustedBase64Signature, internal.SigstorePayloadAcceptanceRules{
+		rules := internal.SigstorePayloadAcceptanceRules{
 			ValidateSignedDockerReference: func(ref string) error {
 				if !pr.SignedIdentity.matchesDockerReference(image, ref) {
 					hasPolicyRequirementError = true
+					class = RejectionIdentityMismatch
 					return PolicyRequirementError(fmt.Sprintf("Signature for identity %q is not accepted", ref))
 				}
 				return nil
@@ -265,11 +483,30 @@ func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image priva
 				}
 				if !digestMatches {
 					hasPolicyRequirementError = true
+					class = RejectionDigestMismatch
 					return PolicyRequirementError(fmt.Sprintf("Signature for digest %s does not match", digest))
 				}
 				return nil
 			},
-		})
+			InTotoPredicateTypes: pr.InTotoPredicateTypes,
+			ValidateUnidentifiedPayload: func() error {
+				// newPRSigstoreSigned only allows in-toto statements with prmMatchRepoDigestOrExact; without a signed identity,
+				// only the digest, which is verified by ValidateSignedDockerManifestDigest, can match the image reference.
+				if _, ok := image.Reference().DockerReference().(reference.Canonical); !ok {
+					hasPolicyRequirementError = true
+					class = RejectionIdentityMismatch
+					return PolicyRequirementError("Signature does not identify the image, which is not referenced by digest")
+				}
+				return nil
+			},
+		}
+		var signature *internal.UntrustedSigstorePayload
+		var err error
+		if isDSSE {
+			signature, err = internal.VerifySigstoreDSSEEnvelope(publicKey, untrustedPayload, timestampedDSSESignature, rules)
+		} else {
+			signature, err = internal.VerifySigstorePayload(publicKey, untrustedPayload, untrustedBase64Signature, rules)
+		}
 
 		if err != nil {
 			errs = append(errs, err)
@@ -280,7 +517,7 @@ func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image priva
 			continue
 		}
 
-		return sarAccepted, nil
+		return sarAccepted, "", nil
 	}
 
 	errString := fmt.Sprintf("None of the specified public keys matched, %+v", errs)
@@ -290,45 +527,64 @@ func (pr *prSigstoreSigned) isSignatureAccepted(ctx context.Context, image priva
 	} else {
 		finalErr = fmt.Errorf(errString)
 	}
-	return sarRejected, finalErr
+	return sarRejected, class, finalErr
 }
 
 func (pr *prSigstoreSigned) isRunningImageAllowed(ctx context.Context, image private.UnparsedImage) (bool, error) {
-	sigs, err := image.UntrustedSignatures(ctx)
+	res, _, err := pr.evaluateSignatures(ctx, image)
+	return res, err
+}
+
+// evaluateSignatures is isRunningImageAllowed, which also returns a description of the rejected signatures if the image is not allowed.
+func (pr *prSigstoreSigned) evaluateSignatures(ctx context.Context, image private.UnparsedImage) (bool, *PolicyRejection, error) {
+	subjects, err := signatureSubjects(ctx, image, pr.SignedDigest)
 	if err != nil {
-		return false, err
+		return false, nil, err
 	}
-	var rejections []error
+	var rejections signatureRejections
+	sigIndex := 0
 	foundNonSigstoreSignatures := 0
 	foundSigstoreNonAttachments := 0
-	for _, s := range sigs {
-		sigstoreSig, ok := s.(signature.Sigstore)
-		if !ok {
-			foundNonSigstoreSignatures++
-			continue
-		}
-		if sigstoreSig.UntrustedMIMEType() != signature.SigstoreSignatureMIMEType {
-			foundSigstoreNonAttachments++
-			continue
+	for _, subject := range subjects {
+		sigs, err := subject.UntrustedSignatures(ctx)
+		if err != nil {
+			return false, nil, err
 		}
+		for i, s := range sigs {
+			index := sigIndex + i
+			sigstoreSig, ok := s.(signature.Sigstore)
+			if !ok {
+				foundNonSigstoreSignatures++
+				continue
+			}
+			if mimeType := sigstoreSig.UntrustedMIMEType(); mimeType != signature.SigstoreSignatureMIMEType &&
+				mimeType != signature.SigstoreDSSEEnvelopeMIMEType && !signature.IsSigstoreBundleMIMEType(mimeType) {
+				foundSigstoreNonAttachments++
+				continue
+			}
 
-		var reason error
-		switch res, err := pr.isSignatureAccepted(ctx, image, sigstoreSig); res {
-		case sarAccepted:
-			// One accepted signature is enough.
-			return true, nil
-		case sarRejected:
-			reason = err
-		case sarUnknown:
-			// Huh?! This should not happen at all; treat it as any other invalid value.
-			fallthrough
-		default:
-			reason = fmt.Errorf(`Internal error: Unexpected signature verification result %q`, string(res))
+			var reason error
+			var class RejectionClass
+			switch res, resClass, err := pr.verifySignature(ctx, subject, sigstoreSig); res {
+			case sarAccepted:
+				// One accepted signature is enough.
+				return true, nil, nil
+			case sarRejected:
+				reason = err
+				class = resClass
+			case sarUnknown:
+				// Huh?! This should not happen at all; treat it as any other invalid value.
+				fallthrough
+			default:
+				reason = fmt.Errorf(`Internal error: Unexpected signature verification result %q`, string(res))
+				class = RejectionOther
+			}
+			rejections.add(index, class, reason)
 		}
-		rejections = append(rejections, reason)
+		sigIndex += len(sigs)
 	}
 	var summary error
-	switch len(rejections) {
+	switch len(rejections.errs) {
 	case 0:
 		if foundNonSigstoreSignatures == 0 && foundSigstoreNonAttachments == 0 {
 			// A nice message for the most common case.
@@ -338,9 +594,9 @@ func (pr *prSigstoreSigned) isRunningImageAllowed(ctx context.Context, image pri
 				foundNonSigstoreSignatures, foundSigstoreNonAttachments))
 		}
 	case 1:
-		summary = rejections[0]
+		summary = rejections.errs[0]
 	default:
-		summary = PolicyRequirementError(multierr.Format("None of the signatures were accepted, reasons: ", "; ", "", rejections).Error())
+		summary = PolicyRequirementError(multierr.Format("None of the signatures were accepted, reasons: ", "; ", "", rejections.errs).Error())
 	}
-	return false, summary
+	return false, rejections.policyRejection(prTypeSigstoreSigned), summary
 }
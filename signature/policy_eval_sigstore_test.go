@@ -7,11 +7,25 @@ package signature
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,12 +53,135 @@ func TestPRSigstoreSignedFulcioPrepareTrustRoot(t *testing.T) {
 	} {
 		f, err := newPRSigstoreSignedFulcio(c...)
 		require.NoError(t, err)
-		res, err := f.prepareTrustRoot()
+		res, err := f.prepareTrustRoot(nil)
 		require.NoError(t, err)
 		assert.NotNil(t, res.caCertificates) // Doing a better test seems hard; we would need to compare .Subjects with a DER encoding.
+		assert.Empty(t, res.certificateAuthorities)
 		assert.Equal(t, testOIDCIssuer, res.oidcIssuer)
 		assert.Equal(t, testSubjectEmail, res.subjectEmail)
+		assert.False(t, res.ignoreRelevantTime)
+		assert.Zero(t, res.clockSkew)
 	}
+	// Integrated time checks
+	for _, c := range []struct {
+		options            []PRSigstoreSignedFulcioOption
+		ignoreRelevantTime bool
+		clockSkew          time.Duration
+	}{
+		{[]PRSigstoreSignedFulcioOption{PRSigstoreSignedFulcioWithIntegratedTimeClockSkewSeconds(30)}, false, 30 * time.Second},
+		{[]PRSigstoreSignedFulcioOption{PRSigstoreSignedFulcioWithIntegratedTimeCheck(IntegratedTimeCheckIgnore)}, true, 0},
+	} {
+		f, err := newPRSigstoreSignedFulcio(append([]PRSigstoreSignedFulcioOption{
+			PRSigstoreSignedFulcioWithCAPath(testCAPath),
+			PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+			PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+		}, c.options...)...)
+		require.NoError(t, err)
+		res, err := f.prepareTrustRoot(nil)
+		require.NoError(t, err)
+		assert.Equal(t, c.ignoreRelevantTime, res.ignoreRelevantTime)
+		assert.Equal(t, c.clockSkew, res.clockSkew)
+	}
+	// CI identities
+	f, err := newPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath(testCAPath),
+		PRSigstoreSignedFulcioWithGitHubActionsIdentity("containers/image", ".github/workflows/release.yml", ""),
+	)
+	require.NoError(t, err)
+	res, err := f.prepareTrustRoot(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://token.actions.githubusercontent.com", res.oidcIssuer)
+	assert.Equal(t, "", res.subjectEmail)
+	assert.Equal(t, "", res.subjectURI)
+	assert.Equal(t, "https://github.com/containers/image/.github/workflows/release.yml@", res.subjectURIPrefix)
+	assert.NoError(t, res.validate())
+	f, err = newPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath(testCAPath),
+		PRSigstoreSignedFulcioWithGitHubActionsWorkflowIdentity(PRSigstoreSignedFulcioGitHubActionsIdentity{
+			Repository: "containers/image",
+			Workflow:   ".github/workflows/release.yml",
+			RefPrefix:  "refs/tags/",
+			Trigger:    "push",
+			SHA:        "0123456789abcdef0123456789abcdef01234567",
+		}),
+	)
+	require.NoError(t, err)
+	res, err = f.prepareTrustRoot(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/containers/image/.github/workflows/release.yml@refs/tags/", res.subjectURIPrefix)
+	assert.Equal(t, "push", res.githubWorkflowTrigger)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", res.githubWorkflowSHA)
+	assert.NoError(t, res.validate())
+	// Regular expressions match the whole value
+	f, err = newPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath(testCAPath),
+		PRSigstoreSignedFulcioWithOIDCIssuerRegex(`https://example\.com/tenant/.*|https://example\.org`),
+		PRSigstoreSignedFulcioWithSubjectEmailRegex(`.*@example\.com`),
+	)
+	require.NoError(t, err)
+	res, err = f.prepareTrustRoot(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", res.oidcIssuer)
+	assert.Equal(t, "", res.subjectEmail)
+	require.NotNil(t, res.oidcIssuerRegex)
+	for _, c := range []struct {
+		issuer string
+		match  bool
+	}{
+		{"https://example.com/tenant/1", true},
+		{"https://example.org", true},
+		{"https://example.com/other", false},
+		{"https://evil.example/https://example.com/tenant/1", false},
+		{"https://example.org.evil.example", false},
+	} {
+		assert.Equal(t, c.match, res.oidcIssuerRegex.MatchString(c.issuer), c.issuer)
+	}
+	require.NotNil(t, res.subjectEmailRegex)
+	assert.True(t, res.subjectEmailRegex.MatchString("test@example.com"))
+	assert.False(t, res.subjectEmailRegex.MatchString("test@example.com.evil.example"))
+	// Subject URIs
+	f, err = newPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath(testCAPath),
+		PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+		PRSigstoreSignedFulcioWithSubjectURI("spiffe://example.com/ns/default/sa/builder"),
+	)
+	require.NoError(t, err)
+	res, err = f.prepareTrustRoot(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://example.com/ns/default/sa/builder", res.subjectURI)
+	assert.Nil(t, res.subjectURIRegex)
+	assert.NoError(t, res.validate())
+	f, err = newPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath(testCAPath),
+		PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+		PRSigstoreSignedFulcioWithSubjectURIRegex(`spiffe://example\.com/ns/[^/]+/sa/builder`),
+	)
+	require.NoError(t, err)
+	res, err = f.prepareTrustRoot(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", res.subjectURI)
+	require.NotNil(t, res.subjectURIRegex)
+	assert.True(t, res.subjectURIRegex.MatchString("spiffe://example.com/ns/prod/sa/builder"))
+	assert.False(t, res.subjectURIRegex.MatchString("spiffe://example.com/ns/prod/sa/builder/extra"))
+	assert.NoError(t, res.validate())
+
+	// Success with a trusted root
+	trustedRootBytes, err := os.ReadFile("fixtures/trusted_root.json")
+	require.NoError(t, err)
+	trustedRoot, err := parseSigstoreTrustedRoot(trustedRootBytes)
+	require.NoError(t, err)
+	f, err = newPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithOIDCIssuer(testOIDCIssuer),
+		PRSigstoreSignedFulcioWithSubjectEmail(testSubjectEmail),
+	)
+	require.NoError(t, err)
+	res, err = f.prepareTrustRoot(trustedRoot)
+	require.NoError(t, err)
+	assert.Nil(t, res.caCertificates)
+	assert.Equal(t, trustedRoot.certificateAuthorities, res.certificateAuthorities)
+	assert.Equal(t, trustedRoot.ctLogPublicKeys, res.ctLogPublicKeys)
+	assert.Equal(t, testOIDCIssuer, res.oidcIssuer)
+	assert.Equal(t, testSubjectEmail, res.subjectEmail)
 
 	// Failure
 	for _, f := range []prSigstoreSignedFulcio{ // Use a prSigstoreSignedFulcio because these configurations should be rejected by NewPRSigstoreSignedFulcio.
@@ -82,7 +219,31 @@ func TestPRSigstoreSignedFulcioPrepareTrustRoot(t *testing.T) {
 			OIDCIssuer: testOIDCIssuer,
 		},
 	} {
-		_, err := f.prepareTrustRoot()
+		_, err := f.prepareTrustRoot(nil)
+		assert.Error(t, err)
+	}
+	// Failure with a trusted root
+	for _, c := range []struct {
+		f           prSigstoreSignedFulcio
+		trustedRoot *sigstoreTrustedRoot
+	}{
+		{ // Both CAPath and a trusted root specified
+			f: prSigstoreSignedFulcio{
+				CAPath:       testCAPath,
+				OIDCIssuer:   testOIDCIssuer,
+				SubjectEmail: testSubjectEmail,
+			},
+			trustedRoot: trustedRoot,
+		},
+		{ // No certificate authorities in the trusted root
+			f: prSigstoreSignedFulcio{
+				OIDCIssuer:   testOIDCIssuer,
+				SubjectEmail: testSubjectEmail,
+			},
+			trustedRoot: &sigstoreTrustedRoot{rekorPublicKeys: trustedRoot.rekorPublicKeys},
+		},
+	} {
+		_, err := c.f.prepareTrustRoot(c.trustedRoot)
 		assert.Error(t, err)
 	}
 }
@@ -116,11 +277,11 @@ func TestPRSigstoreSignedPrepareTrustRoot(t *testing.T) {
 	} {
 		pr, err := newPRSigstoreSigned(c...)
 		require.NoError(t, err)
-		res, err := pr.prepareTrustRoot()
+		res, err := pr.prepareTrustRoot(context.Background())
 		require.NoError(t, err)
 		assert.NotNil(t, res.publicKey)
 		assert.Nil(t, res.fulcio)
-		assert.Nil(t, res.rekorPublicKey)
+		assert.Empty(t, res.rekorPublicKeys)
 	}
 	// Success with Fulcio
 	pr, err := newPRSigstoreSigned(
@@ -129,11 +290,37 @@ func TestPRSigstoreSignedPrepareTrustRoot(t *testing.T) {
 		testIdentityOption,
 	)
 	require.NoError(t, err)
-	res, err := pr.prepareTrustRoot()
+	res, err := pr.prepareTrustRoot(context.Background())
 	require.NoError(t, err)
 	assert.Len(t, res.publicKey, 0)
 	assert.NotNil(t, res.fulcio)
-	assert.NotNil(t, res.rekorPublicKey)
+	assert.Len(t, res.rekorPublicKeys, 1)
+	// Success with several Fulcio roots
+	pr, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio, testFulcio}),
+		PRSigstoreSignedWithRekorPublicKeyData(testRekorPublicKeyData),
+		testIdentityOption,
+	)
+	require.NoError(t, err)
+	res, err = pr.prepareTrustRoot(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, res.fulcio, 2)
+	assert.Equal(t, []string{"fulcios[0]", "fulcios[1]"}, res.fulcioNames)
+	// Failure preparing one of several Fulcio roots names the root
+	invalidFulcio, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithCAPath("/dev/null/this/does/not/exist"),
+		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		PRSigstoreSignedFulcioWithSubjectEmail("mitr@redhat.com"),
+	)
+	require.NoError(t, err)
+	pr, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{testFulcio, invalidFulcio}),
+		PRSigstoreSignedWithRekorPublicKeyData(testRekorPublicKeyData),
+		testIdentityOption,
+	)
+	require.NoError(t, err)
+	_, err = pr.prepareTrustRoot(context.Background())
+	assert.ErrorContains(t, err, "fulcios[1]: ")
 	// Success with Rekor public key
 	for _, c := range [][]PRSigstoreSignedOption{
 		{
@@ -149,11 +336,96 @@ func TestPRSigstoreSignedPrepareTrustRoot(t *testing.T) {
 	} {
 		pr, err := newPRSigstoreSigned(c...)
 		require.NoError(t, err)
-		res, err := pr.prepareTrustRoot()
+		res, err := pr.prepareTrustRoot(context.Background())
 		require.NoError(t, err)
 		assert.NotNil(t, res.publicKey)
 		assert.Nil(t, res.fulcio)
-		assert.NotNil(t, res.rekorPublicKey)
+		assert.Len(t, res.rekorPublicKeys, 1)
+	}
+	// Success with a trusted root
+	const testTrustedRootPath = "fixtures/trusted_root.json"
+	testTrustedRootData, err := os.ReadFile(testTrustedRootPath)
+	require.NoError(t, err)
+	testTrustedRootFulcio, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		PRSigstoreSignedFulcioWithSubjectEmail("mitr@redhat.com"),
+	)
+	require.NoError(t, err)
+	for _, c := range [][]PRSigstoreSignedOption{
+		{
+			PRSigstoreSignedWithFulcio(testTrustedRootFulcio),
+			PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+			testIdentityOption,
+		},
+		{
+			PRSigstoreSignedWithFulcio(testTrustedRootFulcio),
+			PRSigstoreSignedWithTrustedRootData(testTrustedRootData),
+			testIdentityOption,
+		},
+	} {
+		pr, err := newPRSigstoreSigned(c...)
+		require.NoError(t, err)
+		res, err := pr.prepareTrustRoot(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, res.publicKey, 0)
+		require.Len(t, res.fulcio, 1)
+		assert.Len(t, res.fulcio[0].certificateAuthorities, 1)
+		assert.Len(t, res.rekorPublicKeys, 1)
+	}
+	pr, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyData(testKeyData),
+		PRSigstoreSignedWithTrustedRootPath(testTrustedRootPath),
+		testIdentityOption,
+	)
+	require.NoError(t, err)
+	res, err = pr.prepareTrustRoot(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, res.publicKey)
+	assert.Nil(t, res.fulcio)
+	assert.Len(t, res.rekorPublicKeys, 1)
+	// Success with a TSA
+	const testTSACertPath = "fixtures/tsa.crt.pem"
+	testTSACertData, err := os.ReadFile(testTSACertPath)
+	require.NoError(t, err)
+	for _, c := range [][]PRSigstoreSignedOption{
+		{
+			PRSigstoreSignedWithKeyData(testKeyData),
+			PRSigstoreSignedWithTSACertPath(testTSACertPath),
+			testIdentityOption,
+		},
+		{
+			PRSigstoreSignedWithFulcio(testFulcio),
+			PRSigstoreSignedWithTSACertData(testTSACertData),
+			testIdentityOption,
+		},
+	} {
+		pr, err := newPRSigstoreSigned(c...)
+		require.NoError(t, err)
+		res, err := pr.prepareTrustRoot(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, res.rekorPublicKeys)
+		assert.Len(t, res.tsaCertificates, 1)
+	}
+	// Success with Rekor log shards
+	for _, c := range [][]PRSigstoreSignedOption{
+		{
+			PRSigstoreSignedWithKeyData(testKeyData),
+			PRSigstoreSignedWithRekorPublicKeyPaths([]string{testRekorPublicKeyPath, testRekorPublicKeyPath}),
+			testIdentityOption,
+		},
+		{
+			PRSigstoreSignedWithKeyData(testKeyData),
+			PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{testRekorPublicKeyData, testRekorPublicKeyData}),
+			testIdentityOption,
+		},
+	} {
+		pr, err := newPRSigstoreSigned(c...)
+		require.NoError(t, err)
+		res, err := pr.prepareTrustRoot(context.Background())
+		require.NoError(t, err)
+		assert.NotNil(t, res.publicKey)
+		assert.Nil(t, res.fulcio)
+		assert.Len(t, res.rekorPublicKeys, 2)
 	}
 
 	// Failure
@@ -196,13 +468,71 @@ func TestPRSigstoreSignedPrepareTrustRoot(t *testing.T) {
 			RekorPublicKeyData: []byte("this is invalid"),
 			SignedIdentity:     testIdentity,
 		},
+		{ // Unusable Rekor public key paths
+			KeyData:             testKeyData,
+			RekorPublicKeyPaths: []string{testRekorPublicKeyPath, "fixtures/this/does/not/exist"},
+			SignedIdentity:      testIdentity,
+		},
+		{ // Invalid Rekor public key datas
+			KeyData:             testKeyData,
+			RekorPublicKeyDatas: [][]byte{testRekorPublicKeyData, []byte("this is invalid")},
+			SignedIdentity:      testIdentity,
+		},
 		{ // Rekor public key is not ECDSA
 			KeyData:            testKeyData,
 			RekorPublicKeyPath: "fixtures/some-rsa-key.pub",
 			SignedIdentity:     testIdentity,
 		},
+		{ // Both TrustedRootPath and TrustedRootData specified
+			KeyData:         testKeyData,
+			TrustedRootPath: testTrustedRootPath,
+			TrustedRootData: testTrustedRootData,
+			SignedIdentity:  testIdentity,
+		},
+		{ // Unusable trusted root path
+			KeyData:         testKeyData,
+			TrustedRootPath: "fixtures/this/does/not/exist",
+			SignedIdentity:  testIdentity,
+		},
+		{ // Invalid trusted root data
+			KeyData:         testKeyData,
+			TrustedRootData: []byte("this is invalid"),
+			SignedIdentity:  testIdentity,
+		},
+		{ // Both a Rekor public key and a trusted root specified
+			KeyData:            testKeyData,
+			RekorPublicKeyData: testRekorPublicKeyData,
+			TrustedRootData:    testTrustedRootData,
+			SignedIdentity:     testIdentity,
+		},
+		{ // Both a Fulcio CA and a trusted root specified
+			Fulcio:          testFulcio,
+			TrustedRootData: testTrustedRootData,
+			SignedIdentity:  testIdentity,
+		},
+		{ // Both TSACertPath and TSACertData specified
+			KeyData:        testKeyData,
+			TSACertPath:    testTSACertPath,
+			TSACertData:    testTSACertData,
+			SignedIdentity: testIdentity,
+		},
+		{ // Unusable TSA certificate path
+			KeyData:        testKeyData,
+			TSACertPath:    "fixtures/this/does/not/exist",
+			SignedIdentity: testIdentity,
+		},
+		{ // Invalid TSA certificate data
+			KeyData:        testKeyData,
+			TSACertData:    []byte("this is invalid"),
+			SignedIdentity: testIdentity,
+		},
+		{ // TSA certificate data contains no certificates
+			KeyData:        testKeyData,
+			TSACertData:    testKeyData,
+			SignedIdentity: testIdentity,
+		},
 	} {
-		_, err = pr.prepareTrustRoot()
+		_, err = pr.prepareTrustRoot(context.Background())
 		assert.Error(t, err)
 	}
 }
@@ -252,6 +582,82 @@ func sigstoreSignatureWithModifiedAnnotation(template signature.Sigstore, annota
 	return signature.SigstoreFromComponents(template.UntrustedMIMEType(), template.UntrustedPayload(), annotations)
 }
 
+// dsseSigstoreSignature returns a signature.Sigstore containing a DSSE envelope with payloadType and payload, signed by signer.
+func dsseSigstoreSignature(t *testing.T, signer crypto.Signer, payloadType string, payload []byte) signature.Sigstore {
+	s, err := sigstoreSignature.LoadSigner(signer, crypto.SHA256)
+	require.NoError(t, err)
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+	sig, err := s.SignMessage(strings.NewReader(pae))
+	require.NoError(t, err)
+	envelope, err := json.Marshal(map[string]any{
+		"payloadType": payloadType,
+		"payload":     payload,
+		"signatures":  []any{map[string]any{"keyid": "", "sig": sig}},
+	})
+	require.NoError(t, err)
+	return signature.SigstoreFromComponents(signature.SigstoreDSSEEnvelopeMIMEType, envelope, map[string]string{})
+}
+
+// sigstoreBundleSignature returns a signature.Sigstore containing a Sigstore bundle with a DSSE envelope with payloadType and payload,
+// signed by signer, and a "dsse" transparency log entry with an inclusion promise signed by rekorKey.
+func sigstoreBundleSignature(t *testing.T, signer *ecdsa.PrivateKey, rekorKey *ecdsa.PrivateKey, payloadType string, payload []byte) signature.Sigstore {
+	envelope := dsseSigstoreSignature(t, signer, payloadType, payload).UntrustedPayload()
+	var parsedEnvelope struct {
+		Signatures []struct {
+			Sig []byte `json:"sig"`
+		} `json:"signatures"`
+	}
+	err := json.Unmarshal(envelope, &parsedEnvelope)
+	require.NoError(t, err)
+	signerPEM, err := cryptoutils.MarshalPublicKeyToPEM(signer.Public())
+	require.NoError(t, err)
+	payloadHash := sha256.Sum256(payload)
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]any{
+			"payloadHash": map[string]any{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])},
+			"signatures": []any{map[string]any{
+				"signature": base64.StdEncoding.EncodeToString(parsedEnvelope.Signatures[0].Sig),
+				"verifier":  signerPEM,
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	rekorKeyDER, err := x509.MarshalPKIXPublicKey(rekorKey.Public())
+	require.NoError(t, err)
+	logID := sha256.Sum256(rekorKeyDER)
+	setPayload, err := json.Marshal(map[string]any{
+		"body":           body,
+		"integratedTime": 1700000000,
+		"logIndex":       1,
+		"logID":          hex.EncodeToString(logID[:]),
+	})
+	require.NoError(t, err)
+	setPayloadHash := sha256.Sum256(setPayload)
+	set, err := ecdsa.SignASN1(rand.Reader, rekorKey, setPayloadHash[:])
+	require.NoError(t, err)
+
+	bundle, err := json.Marshal(map[string]any{
+		"mediaType": signature.SigstoreBundleMIMEType,
+		"verificationMaterial": map[string]any{
+			"publicKey": map[string]any{"hint": ""},
+			"tlogEntries": []any{map[string]any{
+				"logIndex":          "1",
+				"logId":             map[string]any{"keyId": logID[:]},
+				"kindVersion":       map[string]any{"kind": "dsse", "version": "0.0.1"},
+				"integratedTime":    "1700000000",
+				"inclusionPromise":  map[string]any{"signedEntryTimestamp": set},
+				"canonicalizedBody": body,
+			}},
+		},
+		"dsseEnvelope": json.RawMessage(envelope),
+	})
+	require.NoError(t, err)
+	return signature.SigstoreFromComponents(signature.SigstoreBundleMIMEType, bundle, nil)
+}
+
 func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 	assertAccepted := func(sar signatureAcceptanceResult, err error) {
 		assert.Equal(t, sarAccepted, sar)
@@ -329,6 +735,16 @@ func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 	require.NoError(t, err)
 	assertAccepted(sar, err)
 
+	// Successful key+Rekor use, with the Rekor key among several log shards
+	pr2, err := newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyPath("fixtures/cosign2.pub"),
+		PRSigstoreSignedWithRekorPublicKeyPaths([]string{"fixtures/cosign.pub", "fixtures/rekor.pub"}),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), testKeyRekorImage, testKeyRekorImageSig)
+	assertAccepted(sar, err)
+
 	// key+Rekor, missing Rekor SET annotation
 	sar, err = pr.isSignatureAccepted(context.Background(), nil,
 		sigstoreSignatureWithoutAnnotation(t, testKeyRekorImageSig, signature.SigstoreSETAnnotationKey))
@@ -341,7 +757,7 @@ func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 			"this is not a valid SET"))
 	assertRejected(sar, err)
 	// Fulcio: A Rekor SET which we don’t accept (one of many reasons)
-	pr2, err := newPRSigstoreSigned(
+	pr2, err = newPRSigstoreSigned(
 		PRSigstoreSignedWithKeyPath("fixtures/cosign2.pub"),
 		PRSigstoreSignedWithRekorPublicKeyPath("fixtures/cosign.pub"), // not rekor.pub = a key mismatch
 		PRSigstoreSignedWithSignedIdentity(prm),
@@ -350,6 +766,16 @@ func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
 	sar, err = pr2.isSignatureAccepted(context.Background(), nil, testKeyRekorImageSig)
 	assertRejected(sar, err)
+	// key+Rekor: none of the Rekor log shards matches the SET
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyPath("fixtures/cosign2.pub"),
+		PRSigstoreSignedWithRekorPublicKeyPaths([]string{"fixtures/cosign.pub", "fixtures/cosign.pub"}),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
+	sar, err = pr2.isSignatureAccepted(context.Background(), nil, testKeyRekorImageSig)
+	assertRejected(sar, err)
 
 	// Successful Fulcio certificate use
 	fulcio, err = NewPRSigstoreSignedFulcio(
@@ -368,6 +794,77 @@ func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 		testFulcioRekorImageSig)
 	require.NoError(t, err)
 	assertAccepted(sar, err)
+	// … also with several Rekor log shards
+	rekorKeyData, err := os.ReadFile("fixtures/rekor.pub")
+	require.NoError(t, err)
+	cosignKeyData, err := os.ReadFile("fixtures/cosign.pub")
+	require.NoError(t, err)
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcio(fulcio),
+		PRSigstoreSignedWithRekorPublicKeyDatas([][]byte{rekorKeyData, cosignKeyData}),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), testFulcioRekorImage,
+		testFulcioRekorImageSig)
+	assertAccepted(sar, err)
+	// … also with a trusted root
+	trustedRootFulcio, err := NewPRSigstoreSignedFulcio(
+		PRSigstoreSignedFulcioWithOIDCIssuer("https://github.com/login/oauth"),
+		PRSigstoreSignedFulcioWithSubjectEmail("mitr@redhat.com"),
+	)
+	require.NoError(t, err)
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcio(trustedRootFulcio),
+		PRSigstoreSignedWithTrustedRootPath("fixtures/trusted_root.json"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), testFulcioRekorImage,
+		testFulcioRekorImageSig)
+	assertAccepted(sar, err)
+	// … and the trusted root intermediates are used even if the signature does not include them
+	sar, err = pr2.isSignatureAccepted(context.Background(), testFulcioRekorImage,
+		sigstoreSignatureWithoutAnnotation(t, testFulcioRekorImageSig, signature.SigstoreIntermediateCertificateChainAnnotationKey))
+	assertAccepted(sar, err)
+	// … and without any certificate transparency logs in the trusted root
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcio(trustedRootFulcio),
+		PRSigstoreSignedWithTrustedRootData(modifiedTrustedRoot(t, func(v mSA) { delete(v, "ctlogs") })),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), testFulcioRekorImage,
+		testFulcioRekorImageSig)
+	assertAccepted(sar, err)
+	// Trusted root, the signature is outside of the validity period of the Fulcio CA or the Rekor key,
+	// or the certificate was not logged in a certificate transparency log of the trusted root
+	for _, modify := range []func(v mSA){
+		func(v mSA) {
+			v["certificateAuthorities"].([]any)[0].(map[string]any)["validFor"] = mSA{"start": "2024-01-01T00:00:00Z"}
+		},
+		func(v mSA) {
+			v["tlogs"].([]any)[0].(map[string]any)["publicKey"].(map[string]any)["validFor"] = mSA{
+				"start": "2021-01-12T11:53:27Z",
+				"end":   "2022-01-01T00:00:00Z",
+			}
+		},
+		func(v mSA) { v["ctlogs"] = v["ctlogs"].([]any)[:1] },
+		func(v mSA) {
+			v["ctlogs"].([]any)[1].(map[string]any)["publicKey"].(map[string]any)["validFor"] = mSA{"start": "2024-01-01T00:00:00Z"}
+		},
+	} {
+		trustedRootData := modifiedTrustedRoot(t, modify)
+		pr2, err = newPRSigstoreSigned(
+			PRSigstoreSignedWithFulcio(trustedRootFulcio),
+			PRSigstoreSignedWithTrustedRootData(trustedRootData),
+			PRSigstoreSignedWithSignedIdentity(prm),
+		)
+		require.NoError(t, err)
+		// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
+		sar, err = pr2.isSignatureAccepted(context.Background(), nil, testFulcioRekorImageSig)
+		assertRejected(sar, err)
+	}
 
 	// Fulcio, no Rekor requirement
 	pr2 = &prSigstoreSigned{
@@ -434,6 +931,27 @@ func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
 	sar, err = pr2.isSignatureAccepted(context.Background(), nil, testFulcioRekorImageSig)
 	assertRejected(sar, err)
+	// Several Fulcio roots: the certificate is accepted if any of them accepts it
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{fulcio2, fulcio}),
+		PRSigstoreSignedWithRekorPublicKeyPath("fixtures/rekor.pub"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), testFulcioRekorImage, testFulcioRekorImageSig)
+	assertAccepted(sar, err)
+	// Several Fulcio roots: rejected if none of them accepts the certificate, naming each root
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcios([]PRSigstoreSignedFulcio{fulcio2, fulcio2}),
+		PRSigstoreSignedWithRekorPublicKeyPath("fixtures/rekor.pub"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
+	sar, err = pr2.isSignatureAccepted(context.Background(), nil, testFulcioRekorImageSig)
+	assertRejected(sar, err)
+	assert.ErrorContains(t, err, `fulcios[0]: Required email "this-does-not-match@example.com" not found`)
+	assert.ErrorContains(t, err, `fulcios[1]: Required email "this-does-not-match@example.com" not found`)
 	// Fulcio: Invalid Rekor SET
 	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
 	sar, err = pr.isSignatureAccepted(context.Background(), nil,
@@ -451,6 +969,74 @@ func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 	sar, err = pr2.isSignatureAccepted(context.Background(), nil, testFulcioRekorImageSig)
 	assertRejected(sar, err)
 
+	// RFC 3161 timestamps
+	testKeyImageTimestamp, err := os.ReadFile("fixtures/rfc3161-timestamp-key")
+	require.NoError(t, err)
+	testFulcioRekorImageTimestamp, err := os.ReadFile("fixtures/rfc3161-timestamp-fulcio-rekor")
+	require.NoError(t, err)
+	// - Successful key+TSA use
+	pr, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
+		PRSigstoreSignedWithTSACertPath("fixtures/tsa.crt.pem"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr.isSignatureAccepted(context.Background(), testKeyImage,
+		sigstoreSignatureWithModifiedAnnotation(testKeyImageSig, signature.SigstoreRFC3161TimestampAnnotationKey, string(testKeyImageTimestamp)))
+	assertAccepted(sar, err)
+	// - key+TSA, missing timestamp annotation
+	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
+	sar, err = pr.isSignatureAccepted(context.Background(), nil, testKeyImageSig)
+	assertRejected(sar, err)
+	// - key+TSA, a timestamp of a different signature
+	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
+	sar, err = pr.isSignatureAccepted(context.Background(), nil,
+		sigstoreSignatureWithModifiedAnnotation(testKeyImageSig, signature.SigstoreRFC3161TimestampAnnotationKey, string(testFulcioRekorImageTimestamp)))
+	assertRejected(sar, err)
+	// - key+TSA, a timestamp issued by an untrusted authority
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
+		PRSigstoreSignedWithTSACertPath("fixtures/fulcio_v1.crt.pem"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
+	sar, err = pr2.isSignatureAccepted(context.Background(), nil,
+		sigstoreSignatureWithModifiedAnnotation(testKeyImageSig, signature.SigstoreRFC3161TimestampAnnotationKey, string(testKeyImageTimestamp)))
+	assertRejected(sar, err)
+	// - Successful Fulcio+TSA use, without Rekor
+	testFulcioTSAImageSig := sigstoreSignatureWithModifiedAnnotation(
+		sigstoreSignatureWithoutAnnotation(t, testFulcioRekorImageSig, signature.SigstoreSETAnnotationKey),
+		signature.SigstoreRFC3161TimestampAnnotationKey, string(testFulcioRekorImageTimestamp))
+	pr, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcio(fulcio),
+		PRSigstoreSignedWithTSACertPath("fixtures/tsa.crt.pem"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr.isSignatureAccepted(context.Background(), testFulcioRekorImage, testFulcioTSAImageSig)
+	assertAccepted(sar, err)
+	// - … also with Rekor
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithFulcio(fulcio),
+		PRSigstoreSignedWithRekorPublicKeyPath("fixtures/rekor.pub"),
+		PRSigstoreSignedWithTSACertPath("fixtures/tsa.crt.pem"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), testFulcioRekorImage,
+		sigstoreSignatureWithModifiedAnnotation(testFulcioRekorImageSig, signature.SigstoreRFC3161TimestampAnnotationKey, string(testFulcioRekorImageTimestamp)))
+	assertAccepted(sar, err)
+	// - Fulcio+TSA, the certificate was not valid at the time of the timestamp
+	// Pass a nil pointer to, kind of, test that the return value does not depend on the image.
+	// (testKeyImageTimestamp is valid for testKeyImageSig, and predates the certificate.)
+	sar, err = pr.isSignatureAccepted(context.Background(), nil,
+		sigstoreSignatureWithModifiedAnnotation(
+			sigstoreSignatureWithModifiedAnnotation(testFulcioTSAImageSig, signature.SigstoreSignatureAnnotationKey,
+				testKeyImageSig.UntrustedAnnotations()[signature.SigstoreSignatureAnnotationKey]),
+			signature.SigstoreRFC3161TimestampAnnotationKey, string(testKeyImageTimestamp)))
+	assertRejected(sar, err)
+
 	// Successful validation, with KeyData and KeyPath
 	pr, err = newPRSigstoreSigned(
 		PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
@@ -532,6 +1118,125 @@ func TestPRrSigstoreSignedIsSignatureAccepted(t *testing.T) {
 	sar, err = pr.isSignatureAccepted(context.Background(), image, sigstoreSignatureFromFile(t, "fixtures/dir-img-cosign-modified-manifest/signature-1"))
 	assertRejected(sar, err)
 
+	// DSSE envelopes
+	dsseKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	dsseKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(dsseKey.Public())
+	require.NoError(t, err)
+	manifestBytes, err := os.ReadFile("fixtures/dir-img-cosign-valid/manifest.json")
+	require.NoError(t, err)
+	manifestDigest, err := manifest.Digest(manifestBytes)
+	require.NoError(t, err)
+	inTotoStatementWithPredicateType := func(predicateType, name string) []byte {
+		res, err := json.Marshal(map[string]any{
+			"_type":         "https://in-toto.io/Statement/v1",
+			"predicateType": predicateType,
+			"subject": []any{map[string]any{
+				"name":   name,
+				"digest": map[string]any{"sha256": manifestDigest.Encoded()},
+			}},
+			"predicate": map[string]any{},
+		})
+		require.NoError(t, err)
+		return res
+	}
+	inTotoStatement := func(name string) []byte {
+		return inTotoStatementWithPredicateType("https://slsa.dev/provenance/v1", name)
+	}
+	digestImage := dirImageMock(t, "fixtures/dir-img-cosign-valid", "192.168.64.2:5000/cosign-signed-single-sample@"+manifestDigest.String())
+	pr, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyData(dsseKeyPEM),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	prInToto, err := newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyData(dsseKeyPEM),
+		PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+		PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://slsa.dev/provenance/v1"}),
+	)
+	require.NoError(t, err)
+	// - A sigstore payload
+	sar, err = pr.isSignatureAccepted(context.Background(), testKeyImage,
+		dsseSigstoreSignature(t, dsseKey, signature.SigstoreSignatureMIMEType, testKeyImageSig.UntrustedPayload()))
+	assertAccepted(sar, err)
+	// - An in-toto statement, with an accepted predicate type, for an image referenced by digest
+	sar, err = prInToto.isSignatureAccepted(context.Background(), digestImage,
+		dsseSigstoreSignature(t, dsseKey, "application/vnd.in-toto+json", inTotoStatement("192.168.64.2:5000/cosign-signed-single-sample")))
+	assertAccepted(sar, err)
+	// - The subject name is not used as an identity
+	sar, err = prInToto.isSignatureAccepted(context.Background(), digestImage,
+		dsseSigstoreSignature(t, dsseKey, "application/vnd.in-toto+json", inTotoStatement("192.168.64.2:5000/other")))
+	assertAccepted(sar, err)
+	// - An in-toto statement, if in-toto statements are not accepted
+	sar, err = pr.isSignatureAccepted(context.Background(), testKeyImage,
+		dsseSigstoreSignature(t, dsseKey, "application/vnd.in-toto+json", inTotoStatement("192.168.64.2:5000/cosign-signed-single-sample")))
+	assertRejected(sar, err)
+	// - An in-toto statement with a predicate type which is not accepted
+	sar, err = prInToto.isSignatureAccepted(context.Background(), digestImage,
+		dsseSigstoreSignature(t, dsseKey, "application/vnd.in-toto+json",
+			inTotoStatementWithPredicateType("https://example.com/other", "192.168.64.2:5000/cosign-signed-single-sample")))
+	assertRejected(sar, err)
+	// - An in-toto statement for an image not referenced by digest
+	sar, err = prInToto.isSignatureAccepted(context.Background(), testKeyImage,
+		dsseSigstoreSignature(t, dsseKey, "application/vnd.in-toto+json", inTotoStatement("192.168.64.2:5000/cosign-signed-single-sample")))
+	assertRejected(sar, err)
+	// - An envelope signed by a different key
+	otherDSSEKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	sar, err = pr.isSignatureAccepted(context.Background(), testKeyImage,
+		dsseSigstoreSignature(t, otherDSSEKey, signature.SigstoreSignatureMIMEType, testKeyImageSig.UntrustedPayload()))
+	assertRejected(sar, err)
+	// - Rekor verification of a DSSE envelope without a SET
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyData(dsseKeyPEM),
+		PRSigstoreSignedWithRekorPublicKeyPath("fixtures/rekor.pub"),
+		PRSigstoreSignedWithSignedIdentity(prm),
+	)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), testKeyImage,
+		dsseSigstoreSignature(t, dsseKey, signature.SigstoreSignatureMIMEType, testKeyImageSig.UntrustedPayload()))
+	assertRejected(sar, err)
+
+	// Sigstore bundles
+	bundleRekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	bundleRekorKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(bundleRekorKey.Public())
+	require.NoError(t, err)
+	pr2, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyData(dsseKeyPEM),
+		PRSigstoreSignedWithRekorPublicKeyData(bundleRekorKeyPEM),
+		PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+		PRSigstoreSignedWithInTotoPredicateTypes([]string{"https://slsa.dev/provenance/v1"}),
+	)
+	require.NoError(t, err)
+	// - A bundle with an in-toto statement, recorded in Rekor
+	sar, err = pr2.isSignatureAccepted(context.Background(), digestImage,
+		sigstoreBundleSignature(t, dsseKey, bundleRekorKey, "application/vnd.in-toto+json", inTotoStatement("192.168.64.2:5000/cosign-signed-single-sample")))
+	assertAccepted(sar, err)
+	// - Without Rekor, the bundle is verified as the DSSE envelope
+	sar, err = pr.isSignatureAccepted(context.Background(), testKeyImage,
+		sigstoreBundleSignature(t, dsseKey, bundleRekorKey, signature.SigstoreSignatureMIMEType, testKeyImageSig.UntrustedPayload()))
+	assertAccepted(sar, err)
+	// - A bundle with a predicate type which is not accepted
+	sar, err = pr2.isSignatureAccepted(context.Background(), digestImage,
+		sigstoreBundleSignature(t, dsseKey, bundleRekorKey, "application/vnd.in-toto+json",
+			inTotoStatementWithPredicateType("https://example.com/other", "192.168.64.2:5000/cosign-signed-single-sample")))
+	assertRejected(sar, err)
+	// - A bundle signed by a different key
+	sar, err = pr2.isSignatureAccepted(context.Background(), digestImage,
+		sigstoreBundleSignature(t, otherDSSEKey, bundleRekorKey, "application/vnd.in-toto+json", inTotoStatement("192.168.64.2:5000/cosign-signed-single-sample")))
+	assertRejected(sar, err)
+	// - A bundle recorded in a different Rekor log
+	otherRekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	sar, err = pr2.isSignatureAccepted(context.Background(), digestImage,
+		sigstoreBundleSignature(t, dsseKey, otherRekorKey, "application/vnd.in-toto+json", inTotoStatement("192.168.64.2:5000/cosign-signed-single-sample")))
+	assertRejected(sar, err)
+	// - An invalid bundle
+	sar, err = pr2.isSignatureAccepted(context.Background(), testKeyImage,
+		signature.SigstoreFromComponents(signature.SigstoreBundleMIMEType, []byte(`{"mediaType":"`+signature.SigstoreBundleMIMEType+`"}`), nil))
+	assertRejected(sar, err)
+
 	// Minimally check that the prmMatchExact also works as expected:
 	// - Signatures with a matching tag work
 	image = dirImageMock(t, "fixtures/dir-img-cosign-valid-with-tag", "192.168.64.2:5000/skopeo-signed:tag")
@@ -683,4 +1388,29 @@ func TestPRSigstoreSignedIsRunningImageAllowed(t *testing.T) {
 	require.NoError(t, err)
 	allowed, err = pr.isRunningImageAllowed(context.Background(), image)
 	assertRunningRejectedPolicyRequirement(t, allowed, err)
+
+	// prmMatchArtifact accepts images without a Docker reference identity if the signature claims the configured repository
+	for _, c := range []struct {
+		repo    string
+		allowed bool
+	}{
+		{"192.168.64.2:5000/cosign-signed-single-sample", true},
+		{"192.168.64.2:5000/other", false},
+		{"", false},
+	} {
+		image = dirImageMockWithRef(t, "fixtures/dir-img-cosign-valid", refImageReferenceMock{ref: nil})
+		prmArtifact, err := NewPRMMatchArtifact(c.repo)
+		require.NoError(t, err)
+		pr, err = NewPRSigstoreSigned(
+			PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
+			PRSigstoreSignedWithSignedIdentity(prmArtifact),
+		)
+		require.NoError(t, err)
+		allowed, err = pr.isRunningImageAllowed(context.Background(), image)
+		if c.allowed {
+			assertRunningAllowed(t, allowed, err)
+		} else {
+			assertRunningRejectedPolicyRequirement(t, allowed, err)
+		}
+	}
 }
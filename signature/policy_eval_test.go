@@ -151,6 +151,9 @@ func TestPolicyContextRequirementsForImageRef(t *testing.T) {
 		{"docker", "deep.com/n1/n2/n3"},
 		{"docker", "deep.com/n1/n2/n3/repo"},
 		{"docker", "deep.com/n1/n2/n3/repo:tag2"},
+		{"docker", "deep.com/n1/n2/n3/tags:*"},
+		{"docker", "deep.com/n1/n2/n3/tags:latest"},
+		{"docker", "deep.com/n1/n2/n3/digests@*"},
 		{"atomic", "unmatched"},
 	} {
 		if _, ok := policy.Transports[t.transport]; !ok {
@@ -170,6 +173,12 @@ func TestPolicyContextRequirementsForImageRef(t *testing.T) {
 		{"docker", "deep.com/n1/n2/n3/notrepo:tag2", "docker", "deep.com/n1/n2/n3"},
 		{"docker", "deep.com/n1/n2/notn3/repo:tag2", "docker", "deep.com/n1/n2"},
 		{"docker", "deep.com/n1/notn2/n3/repo:tag2", "docker", "deep.com/n1"},
+		// Tag-level and digest-level namespace matches
+		{"docker", "deep.com/n1/n2/n3/tags:v1", "docker", "deep.com/n1/n2/n3/tags:*"},
+		{"docker", "deep.com/n1/n2/n3/tags:latest", "docker", "deep.com/n1/n2/n3/tags:latest"},
+		{"docker", "deep.com/n1/n2/n3/tags@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "docker", "deep.com/n1/n2/n3"},
+		{"docker", "deep.com/n1/n2/n3/digests@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "docker", "deep.com/n1/n2/n3/digests@*"},
+		{"docker", "deep.com/n1/n2/n3/digests:v1", "docker", "deep.com/n1/n2/n3"},
 		// Host name match
 		{"docker", "deep.com/notn1/n2/n3/repo:tag2", "docker", "deep.com"},
 		// Sub domain match
@@ -0,0 +1,230 @@
+// Policy evaluation for prVulnerabilityAttestation.
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature/internal"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+const (
+	// vulnerabilityScanPredicateType is the in-toto predicate type of vulnerability scan attestations, as created by cosign.
+	vulnerabilityScanPredicateType = "https://cosign.sigstore.dev/attestation/vuln/v1"
+	// openVEXPredicateTypePrefix is the common prefix of in-toto predicate types of OpenVEX documents, which include a version.
+	openVEXPredicateTypePrefix = "https://openvex.dev/ns"
+	// maxReportedVulnerabilities is the maximum number of vulnerabilities listed in a rejection.
+	maxReportedVulnerabilities = 5
+)
+
+// vulnerabilitySeverityRanks orders the known severities; unknown severities are treated as critical.
+var vulnerabilitySeverityRanks = map[vulnerabilitySeverity]int{
+	VulnerabilitySeverityNone:     0,
+	VulnerabilitySeverityLow:      1,
+	"negligible":                  1,
+	VulnerabilitySeverityMedium:   2,
+	VulnerabilitySeverityHigh:     3,
+	VulnerabilitySeverityCritical: 4,
+}
+
+// vulnerabilitySeverityRank returns the rank of severity, which is matched case-insensitively.
+func vulnerabilitySeverityRank(severity string) int {
+	if rank, ok := vulnerabilitySeverityRanks[vulnerabilitySeverity(strings.ToLower(severity))]; ok {
+		return rank
+	}
+	return vulnerabilitySeverityRanks[VulnerabilitySeverityCritical]
+}
+
+// untrustedVulnerabilityScan is the subset of a vulnerabilityScanPredicateType predicate we use.
+// The scanner result is expected to use the format of the Trivy JSON report.
+type untrustedVulnerabilityScan struct {
+	Scanner struct {
+		Result struct {
+			Results []struct {
+				Vulnerabilities []struct {
+					VulnerabilityID string `json:"VulnerabilityID"`
+					Severity        string `json:"Severity"`
+				} `json:"Vulnerabilities"`
+			} `json:"Results"`
+		} `json:"result"`
+	} `json:"scanner"`
+	Metadata struct {
+		ScanFinishedOn *time.Time `json:"scanFinishedOn"`
+	} `json:"metadata"`
+}
+
+// untrustedOpenVEXDocument is the subset of an OpenVEX document we use.
+type untrustedOpenVEXDocument struct {
+	Statements []struct {
+		// A vulnerability name in OpenVEX before 0.2.0, an object with a "name" field since then.
+		Vulnerability json.RawMessage `json:"vulnerability"`
+		Status        string          `json:"status"`
+	} `json:"statements"`
+}
+
+// notAffectingVulnerabilities returns names of vulnerabilities the document declares as not affecting the image, or as fixed.
+func (doc *untrustedOpenVEXDocument) notAffectingVulnerabilities() *set.Set[string] {
+	res := set.New[string]()
+	for _, statement := range doc.Statements {
+		if statement.Status != "not_affected" && statement.Status != "fixed" {
+			continue
+		}
+		var name string
+		if err := json.Unmarshal(statement.Vulnerability, &name); err != nil {
+			var v struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(statement.Vulnerability, &v); err != nil {
+				continue
+			}
+			name = v.Name
+		}
+		if name != "" {
+			res.Add(name)
+		}
+	}
+	return res
+}
+
+func (pr *prVulnerabilityAttestation) isSignatureAuthorAccepted(ctx context.Context, image private.UnparsedImage, sig []byte) (signatureAcceptanceResult, *Signature, error) {
+	// Attestations are not signatures of the image.
+	return sarUnknown, nil, nil
+}
+
+// publicKeys returns the trusted public keys of pr.
+func (pr *prVulnerabilityAttestation) publicKeys() ([]crypto.PublicKey, error) {
+	var keyPEMs [][]byte
+	switch {
+	case pr.KeyPath != "" || pr.KeyData != nil:
+		keyPEM, err := loadBytesFromDataOrPath("key", pr.KeyData, pr.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		keyPEMs = append(keyPEMs, keyPEM)
+	default:
+		for _, path := range pr.KeyPaths {
+			keyPEM, err := loadBytesFromDataOrPath("key", nil, path)
+			if err != nil {
+				return nil, err
+			}
+			keyPEMs = append(keyPEMs, keyPEM)
+		}
+	}
+	res := []crypto.PublicKey{}
+	for _, keyPEM := range keyPEMs {
+		pk, err := cryptoutils.UnmarshalPEMToPublicKey(keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key: %w", err)
+		}
+		res = append(res, pk)
+	}
+	return res, nil
+}
+
+func (pr *prVulnerabilityAttestation) isRunningImageAllowed(ctx context.Context, image private.UnparsedImage) (bool, error) {
+	withReferrers, ok := image.(private.UnparsedImageWithReferrers)
+	if !ok {
+		return false, errors.New("reading attestations of the image is not supported")
+	}
+	publicKeys, err := pr.publicKeys()
+	if err != nil {
+		return false, err
+	}
+	m, _, err := image.Manifest(ctx)
+	if err != nil {
+		return false, err
+	}
+	manifestDigest, err := manifest.Digest(m)
+	if err != nil {
+		return false, err
+	}
+	artifacts, err := withReferrers.UntrustedReferrerArtifacts(ctx, signature.SigstoreDSSEEnvelopeMIMEType)
+	if err != nil {
+		return false, fmt.Errorf("reading attestations: %w", err)
+	}
+
+	var scan *untrustedVulnerabilityScan // The most recent accepted scan
+	notAffecting := set.New[string]()
+	untrusted := 0
+	for _, artifact := range artifacts {
+		var predicateType string
+		var predicate json.RawMessage
+		verified := false
+		for _, pk := range publicKeys {
+			predicateType, predicate, err = internal.VerifyInTotoAttestation(pk, artifact.Data, manifestDigest)
+			if err == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			untrusted++
+			continue
+		}
+		switch {
+		case predicateType == vulnerabilityScanPredicateType:
+			var s untrustedVulnerabilityScan
+			if err := json.Unmarshal(predicate, &s); err != nil {
+				return false, PolicyRequirementError(fmt.Sprintf("Invalid vulnerability scan attestation: %v", err))
+			}
+			if s.Metadata.ScanFinishedOn == nil {
+				return false, PolicyRequirementError("Vulnerability scan attestation does not record when the scan finished")
+			}
+			if scan == nil || s.Metadata.ScanFinishedOn.After(*scan.Metadata.ScanFinishedOn) {
+				scan = &s
+			}
+		case strings.HasPrefix(predicateType, openVEXPredicateTypePrefix):
+			var doc untrustedOpenVEXDocument
+			if err := json.Unmarshal(predicate, &doc); err != nil {
+				return false, PolicyRequirementError(fmt.Sprintf("Invalid VEX attestation: %v", err))
+			}
+			notAffecting.AddSlice(doc.notAffectingVulnerabilities().Values())
+		}
+	}
+	if scan == nil {
+		if untrusted != 0 {
+			return false, PolicyRequirementError(fmt.Sprintf("A trusted vulnerability scan attestation was required, but none exists (%d attestations could not be verified)", untrusted))
+		}
+		return false, PolicyRequirementError("A trusted vulnerability scan attestation was required, but none exists")
+	}
+
+	if pr.MaxScanAgeSeconds != 0 {
+		maxAge := time.Duration(pr.MaxScanAgeSeconds) * time.Second
+		if age := time.Since(*scan.Metadata.ScanFinishedOn); age > maxAge {
+			return false, PolicyRequirementError(fmt.Sprintf("The most recent vulnerability scan finished at %s, more than %v ago",
+				scan.Metadata.ScanFinishedOn.Format(time.RFC3339), maxAge))
+		}
+	}
+
+	maxRank := vulnerabilitySeverityRanks[pr.MaxSeverity]
+	rejected := []string{}
+	seen := set.New[string]()
+	for _, result := range scan.Scanner.Result.Results {
+		for _, v := range result.Vulnerabilities {
+			if vulnerabilitySeverityRank(v.Severity) <= maxRank || notAffecting.Contains(v.VulnerabilityID) || seen.Contains(v.VulnerabilityID) {
+				continue
+			}
+			seen.Add(v.VulnerabilityID)
+			rejected = append(rejected, fmt.Sprintf("%s (%s)", v.VulnerabilityID, v.Severity))
+		}
+	}
+	if len(rejected) != 0 {
+		details := strings.Join(rejected[:min(len(rejected), maxReportedVulnerabilities)], ", ")
+		if len(rejected) > maxReportedVulnerabilities {
+			details += fmt.Sprintf(", and %d more", len(rejected)-maxReportedVulnerabilities)
+		}
+		return false, PolicyRequirementError(fmt.Sprintf("The image has vulnerabilities more severe than %q: %s", pr.MaxSeverity, details))
+	}
+	return true, nil
+}
@@ -0,0 +1,277 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/directory"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/internal/imagesource"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/manifest"
+	"github.com/opencontainers/go-digest"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referrersImageSourceMock is an image source which returns fixed referrer artifacts.
+type referrersImageSourceMock struct {
+	private.ImageSource
+	artifacts []private.ReferrerArtifact
+}
+
+func (s *referrersImageSourceMock) GetReferrerArtifacts(ctx context.Context, subjectDigest digest.Digest, artifactType string) ([]private.ReferrerArtifact, error) {
+	m, _, err := s.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	manifestDigest, err := manifest.Digest(m)
+	if err != nil {
+		return nil, err
+	}
+	if subjectDigest != manifestDigest {
+		return nil, fmt.Errorf("unexpected subject digest %s", subjectDigest)
+	}
+	res := []private.ReferrerArtifact{}
+	for _, a := range s.artifacts {
+		if a.ArtifactType == artifactType {
+			res = append(res, a)
+		}
+	}
+	return res, nil
+}
+
+// referrersImageMock returns a private.UnparsedImage for fixtures/dir-img-valid, with attached artifacts.
+func referrersImageMock(t *testing.T, artifacts []private.ReferrerArtifact) private.UnparsedImage {
+	ref, err := reference.ParseNormalizedNamed("testing/manifest:latest")
+	require.NoError(t, err)
+	srcRef, err := directory.NewReference("fixtures/dir-img-valid")
+	require.NoError(t, err)
+	src, err := srcRef.NewImageSource(context.Background(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := src.Close()
+		require.NoError(t, err)
+	})
+	return image.UnparsedInstance(&referrersImageSourceMock{
+		ImageSource: &dirImageSourceMock{
+			ImageSource: imagesource.FromPublic(src),
+			ref:         refImageReferenceMock{ref: ref},
+		},
+		artifacts: artifacts,
+	}, nil)
+}
+
+// attestationArtifact returns a referrer artifact containing an in-toto attestation with predicateType and predicate
+// about subjectDigest, signed by signer.
+func attestationArtifact(t *testing.T, signer *ecdsa.PrivateKey, subjectDigest digest.Digest, predicateType string, predicate any) private.ReferrerArtifact {
+	statement, err := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v1",
+		"subject":       []any{map[string]any{"name": "example.com/repo", "digest": map[string]any{"sha256": subjectDigest.Encoded()}}},
+		"predicateType": predicateType,
+		"predicate":     predicate,
+	})
+	require.NoError(t, err)
+	const payloadType = "application/vnd.in-toto+json"
+	s, err := sigstoreSignature.LoadSigner(signer, crypto.SHA256)
+	require.NoError(t, err)
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(statement), statement)
+	sig, err := s.SignMessage(bytes.NewReader([]byte(pae)))
+	require.NoError(t, err)
+	envelope, err := json.Marshal(map[string]any{
+		"payloadType": payloadType,
+		"payload":     statement,
+		"signatures":  []any{map[string]any{"keyid": "", "sig": sig}},
+	})
+	require.NoError(t, err)
+	return private.ReferrerArtifact{
+		ArtifactType: signature.SigstoreDSSEEnvelopeMIMEType,
+		MediaType:    signature.SigstoreDSSEEnvelopeMIMEType,
+		Data:         envelope,
+	}
+}
+
+// vulnerabilityScanPredicate returns a vulnerability scan predicate finished at scanFinishedOn, reporting vulnerabilities
+// (a map of vulnerability IDs to severities).
+func vulnerabilityScanPredicate(scanFinishedOn time.Time, vulnerabilities map[string]string) any {
+	vulns := []any{}
+	for id, severity := range vulnerabilities {
+		vulns = append(vulns, map[string]any{"VulnerabilityID": id, "Severity": severity})
+	}
+	return map[string]any{
+		"scanner": map[string]any{
+			"uri":     "pkg:github/aquasecurity/trivy",
+			"version": "0.50.0",
+			"result":  map[string]any{"Results": []any{map[string]any{"Vulnerabilities": vulns}}},
+		},
+		"metadata": map[string]any{
+			"scanStartedOn":  scanFinishedOn.Add(-time.Minute).Format(time.RFC3339),
+			"scanFinishedOn": scanFinishedOn.Format(time.RFC3339),
+		},
+	}
+}
+
+func TestVulnerabilitySeverityRank(t *testing.T) {
+	for _, c := range []struct {
+		severity string
+		expected int
+	}{
+		{"none", 0},
+		{"LOW", 1},
+		{"Negligible", 1},
+		{"medium", 2},
+		{"HIGH", 3},
+		{"critical", 4},
+		{"UNKNOWN", 4},
+		{"", 4},
+	} {
+		assert.Equal(t, c.expected, vulnerabilitySeverityRank(c.severity), c.severity)
+	}
+}
+
+func TestPRVulnerabilityAttestationIsRunningImageAllowed(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(privateKey.Public())
+	require.NoError(t, err)
+	otherPublicKeyPEM, err := cryptoutils.MarshalPublicKeyToPEM(otherPrivateKey.Public())
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "key.pub")
+	err = os.WriteFile(keyPath, publicKeyPEM, 0o600)
+	require.NoError(t, err)
+	otherKeyPath := filepath.Join(t.TempDir(), "other.pub")
+	err = os.WriteFile(otherKeyPath, otherPublicKeyPEM, 0o600)
+	require.NoError(t, err)
+
+	manifestBlob, err := os.ReadFile("fixtures/dir-img-valid/manifest.json")
+	require.NoError(t, err)
+	manifestDigest, err := manifest.Digest(manifestBlob)
+	require.NoError(t, err)
+	now := time.Now()
+	scan := func(signer *ecdsa.PrivateKey, finished time.Time, vulnerabilities map[string]string) private.ReferrerArtifact {
+		return attestationArtifact(t, signer, manifestDigest, vulnerabilityScanPredicateType, vulnerabilityScanPredicate(finished, vulnerabilities))
+	}
+	vex := func(signer *ecdsa.PrivateKey, statements ...any) private.ReferrerArtifact {
+		return attestationArtifact(t, signer, manifestDigest, "https://openvex.dev/ns/v0.2.0", map[string]any{"statements": statements})
+	}
+
+	medium, err := NewPRVulnerabilityAttestationKeyData(publicKeyPEM, VulnerabilitySeverityMedium, 0)
+	require.NoError(t, err)
+	mediumRecent, err := NewPRVulnerabilityAttestationKeyPath(keyPath, VulnerabilitySeverityMedium, 3600)
+	require.NoError(t, err)
+	noneEitherKey, err := NewPRVulnerabilityAttestationKeyPaths([]string{otherKeyPath, keyPath}, VulnerabilitySeverityNone, 0)
+	require.NoError(t, err)
+
+	// Accepted images
+	for _, c := range []struct {
+		name      string
+		pr        PolicyRequirement
+		artifacts []private.ReferrerArtifact
+	}{
+		{"no vulnerabilities", noneEitherKey, []private.ReferrerArtifact{scan(privateKey, now, nil)}},
+		{"allowed severities", medium, []private.ReferrerArtifact{scan(privateKey, now, map[string]string{"CVE-1": "LOW", "CVE-2": "MEDIUM"})}},
+		{"recent scan", mediumRecent, []private.ReferrerArtifact{scan(privateKey, now.Add(-time.Minute), map[string]string{"CVE-1": "LOW"})}},
+		{"the most recent scan is used", mediumRecent, []private.ReferrerArtifact{
+			scan(privateKey, now.Add(-2*time.Hour), map[string]string{"CVE-1": "CRITICAL"}),
+			scan(privateKey, now.Add(-time.Minute), map[string]string{"CVE-1": "LOW"}),
+		}},
+		{"untrusted attestations are ignored", medium, []private.ReferrerArtifact{
+			scan(otherPrivateKey, now, map[string]string{"CVE-1": "CRITICAL"}),
+			scan(privateKey, now.Add(-time.Hour), nil),
+		}},
+		{"VEX, OpenVEX 0.2.0", medium, []private.ReferrerArtifact{
+			scan(privateKey, now, map[string]string{"CVE-1": "CRITICAL", "CVE-2": "HIGH"}),
+			vex(privateKey,
+				map[string]any{"vulnerability": map[string]any{"name": "CVE-1"}, "status": "not_affected"},
+				map[string]any{"vulnerability": map[string]any{"name": "CVE-2"}, "status": "fixed"}),
+		}},
+		{"VEX, older OpenVEX", medium, []private.ReferrerArtifact{
+			scan(privateKey, now, map[string]string{"CVE-1": "CRITICAL"}),
+			vex(privateKey, map[string]any{"vulnerability": "CVE-1", "status": "not_affected"}),
+		}},
+		{"other attestations are ignored", medium, []private.ReferrerArtifact{
+			scan(privateKey, now, nil),
+			attestationArtifact(t, privateKey, manifestDigest, "https://slsa.dev/provenance/v1", map[string]any{}),
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			allowed, err := c.pr.isRunningImageAllowed(context.Background(), referrersImageMock(t, c.artifacts))
+			assertRunningAllowed(t, allowed, err)
+		})
+	}
+
+	// Rejected images
+	for _, c := range []struct {
+		name      string
+		pr        PolicyRequirement
+		artifacts []private.ReferrerArtifact
+	}{
+		{"no attestations", medium, nil},
+		{"only untrusted attestations", medium, []private.ReferrerArtifact{scan(otherPrivateKey, now, nil)}},
+		{"attestation of a different image", medium, []private.ReferrerArtifact{
+			attestationArtifact(t, privateKey, digest.FromString("other"), vulnerabilityScanPredicateType, vulnerabilityScanPredicate(now, nil)),
+		}},
+		{"only VEX", medium, []private.ReferrerArtifact{vex(privateKey)}},
+		{"severe vulnerabilities", medium, []private.ReferrerArtifact{scan(privateKey, now, map[string]string{"CVE-1": "LOW", "CVE-2": "HIGH"})}},
+		{"unknown severity", medium, []private.ReferrerArtifact{scan(privateKey, now, map[string]string{"CVE-1": "UNKNOWN"})}},
+		{"any vulnerability", noneEitherKey, []private.ReferrerArtifact{scan(privateKey, now, map[string]string{"CVE-1": "LOW"})}},
+		{"old scan", mediumRecent, []private.ReferrerArtifact{scan(privateKey, now.Add(-2*time.Hour), nil)}},
+		{"VEX not covering the vulnerability", medium, []private.ReferrerArtifact{
+			scan(privateKey, now, map[string]string{"CVE-1": "CRITICAL"}),
+			vex(privateKey,
+				map[string]any{"vulnerability": map[string]any{"name": "CVE-1"}, "status": "affected"},
+				map[string]any{"vulnerability": map[string]any{"name": "CVE-2"}, "status": "not_affected"}),
+		}},
+		{"untrusted VEX", medium, []private.ReferrerArtifact{
+			scan(privateKey, now, map[string]string{"CVE-1": "CRITICAL"}),
+			vex(otherPrivateKey, map[string]any{"vulnerability": map[string]any{"name": "CVE-1"}, "status": "not_affected"}),
+		}},
+		{"scan without a finish time", medium, []private.ReferrerArtifact{
+			attestationArtifact(t, privateKey, manifestDigest, vulnerabilityScanPredicateType, map[string]any{"scanner": map[string]any{}}),
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			allowed, err := c.pr.isRunningImageAllowed(context.Background(), referrersImageMock(t, c.artifacts))
+			assertRunningRejectedPolicyRequirement(t, allowed, err)
+		})
+	}
+
+	// Many vulnerabilities are summarized
+	vulnerabilities := map[string]string{}
+	for i := 0; i < 10; i++ {
+		vulnerabilities[fmt.Sprintf("CVE-%d", i)] = "HIGH"
+	}
+	allowed, err := medium.isRunningImageAllowed(context.Background(), referrersImageMock(t, []private.ReferrerArtifact{scan(privateKey, now, vulnerabilities)}))
+	assertRunningRejectedPolicyRequirement(t, allowed, err)
+	assert.ErrorContains(t, err, "and 5 more")
+
+	// The transport does not support referrers
+	allowed, err = medium.isRunningImageAllowed(context.Background(), dirImageMock(t, "fixtures/dir-img-valid", "testing/manifest:latest"))
+	assertRunningRejected(t, allowed, err)
+
+	// Invalid key
+	invalidKey, err := NewPRVulnerabilityAttestationKeyData([]byte("this is invalid"), VulnerabilitySeverityMedium, 0)
+	require.NoError(t, err)
+	allowed, err = invalidKey.isRunningImageAllowed(context.Background(), referrersImageMock(t, []private.ReferrerArtifact{scan(privateKey, now, nil)}))
+	assertRunningRejected(t, allowed, err)
+
+	// isSignatureAuthorAccepted is not applicable
+	sar, parsedSig, err := medium.isSignatureAuthorAccepted(context.Background(), referrersImageMock(t, nil), nil)
+	assertSARUnknown(t, sar, parsedSig, err)
+}
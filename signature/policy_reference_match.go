@@ -152,3 +152,26 @@ func (prm *prmRemapIdentity) matchesDockerReference(image private.UnparsedImage,
 	}
 	return matchRepoDigestOrExactReferenceValues(intended, signature)
 }
+
+func (prm *prmMatchArtifact) matchesDockerReference(image private.UnparsedImage, signatureDockerReference string) bool {
+	// Artifacts are commonly signed by tools which only record the repository, so the signature may not contain a tag
+	// (and the tag would not protect against substitution anyway, the manifest digest is what identifies the artifact).
+	signature, err := reference.ParseNormalizedNamed(signatureDockerReference)
+	if err != nil {
+		return false
+	}
+	var intendedName string
+	if intended := image.Reference().DockerReference(); intended != nil {
+		intendedName = intended.Name()
+	} else {
+		if prm.DockerRepository == "" {
+			return false
+		}
+		intended, err := reference.ParseNormalizedNamed(prm.DockerRepository)
+		if err != nil {
+			return false
+		}
+		intendedName = intended.Name()
+	}
+	return signature.Name() == intendedName
+}
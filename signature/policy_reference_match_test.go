@@ -308,6 +308,43 @@ func TestPRMMatchRepositoryMatchesDockerReference(t *testing.T) {
 	assert.False(t, res, `unidentified vs. ""`)
 }
 
+func TestPRMMatchArtifactMatchesDockerReference(t *testing.T) {
+	prm, err := NewPRMMatchArtifact("")
+	require.NoError(t, err)
+	// With a Docker reference identity, this behaves like prmMatchRepository, …
+	for _, test := range prmRepositoryMatchTestTable {
+		testPossiblyInvalidImageAndSig(t, prm, test.refA, test.refB, test.result)
+		testPossiblyInvalidImageAndSig(t, prm, test.refB, test.refA, test.result)
+	}
+	// … and the configured repository is ignored.
+	prmWithRepo, err := NewPRMMatchArtifact("quay.io/charts/mychart")
+	require.NoError(t, err)
+	testImageAndSig(t, prmWithRepo, "example.com/charts/other:1.0", "quay.io/charts/mychart", false)
+	testImageAndSig(t, prmWithRepo, "example.com/charts/other:1.0", "example.com/charts/other", true)
+
+	// Unidentified images are rejected without a configured repository, …
+	res := prm.matchesDockerReference(refImageMock{ref: nil}, "")
+	assert.False(t, res, `unidentified vs. ""`)
+	res = prm.matchesDockerReference(refImageMock{ref: nil}, "quay.io/charts/mychart")
+	assert.False(t, res, `unidentified vs. "quay.io/charts/mychart"`)
+	// … and matched against the configured repository otherwise.
+	for _, c := range []struct {
+		sigRef string
+		result bool
+	}{
+		{"quay.io/charts/mychart", true},
+		{"quay.io/charts/mychart:1.0", true},
+		{"quay.io/charts/mychart" + digestSuffix, true},
+		{"quay.io/charts/other", false},
+		{"quay.io/charts/mychart/sub", false},
+		{"", false},
+		{"UPPERCASEISINVALID", false},
+	} {
+		res := prmWithRepo.matchesDockerReference(refImageMock{ref: nil}, c.sigRef)
+		assert.Equal(t, c.result, res, c.sigRef)
+	}
+}
+
 func TestParseDockerReferences(t *testing.T) {
 	const (
 		ok1  = "busybox"
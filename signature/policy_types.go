@@ -6,6 +6,11 @@
 
 package signature
 
+import (
+	"context"
+	"time"
+)
+
 // NOTE: Keep this in sync with docs/containers-policy.json.5.md!
 
 // Policy defines requirements for considering a signature, or an image, valid.
@@ -42,11 +47,13 @@ type prCommon struct {
 type prTypeIdentifier string
 
 const (
-	prTypeInsecureAcceptAnything prTypeIdentifier = "insecureAcceptAnything"
-	prTypeReject                 prTypeIdentifier = "reject"
-	prTypeSignedBy               prTypeIdentifier = "signedBy"
-	prTypeSignedBaseLayer        prTypeIdentifier = "signedBaseLayer"
-	prTypeSigstoreSigned         prTypeIdentifier = "sigstoreSigned"
+	prTypeInsecureAcceptAnything   prTypeIdentifier = "insecureAcceptAnything"
+	prTypeReject                   prTypeIdentifier = "reject"
+	prTypeSignedBy                 prTypeIdentifier = "signedBy"
+	prTypeSignedBaseLayer          prTypeIdentifier = "signedBaseLayer"
+	prTypeSigstoreSigned           prTypeIdentifier = "sigstoreSigned"
+	prTypeExternalEvaluator        prTypeIdentifier = "externalEvaluator"
+	prTypeVulnerabilityAttestation prTypeIdentifier = "vulnerabilityAttestation"
 )
 
 // prInsecureAcceptAnything is a PolicyRequirement with type = prTypeInsecureAcceptAnything:
@@ -78,10 +85,28 @@ type prSignedBy struct {
 	KeyPaths []string `json:"keyPaths,omitempty"`
 	// KeyData contains the trusted key(s), base64-encoded. Exactly one of KeyPath, KeyPaths and KeyData must be specified.
 	KeyData []byte `json:"keyData,omitempty"`
+	// KeyFingerprint is the fingerprint of the trusted key, which is fetched from KeySource.
+	// If KeyFingerprint is specified, KeyPath, KeyPaths and KeyData must not be.
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
+	// KeySource specifies where the key identified by KeyFingerprint is fetched from. It must be specified iff KeyFingerprint is.
+	KeySource *prSignedByKeySource `json:"keySource,omitempty"`
 
 	// SignedIdentity specifies what image identity the signature must be claiming about the image.
 	// Defaults to "matchRepoDigestOrExact" if not specified.
 	SignedIdentity PolicyReferenceMatch `json:"signedIdentity"`
+
+	// SignedDigest specifies which manifest digest, for an instance of a multi-platform image, the signature must be claiming.
+	// Defaults to SignedDigestInstance if not specified.
+	SignedDigest signedDigestScope `json:"signedDigest,omitempty"`
+}
+
+// prSignedByKeySource specifies where a prSignedBy key identified by a fingerprint is fetched from.
+// Exactly one of the fields must be specified.
+type prSignedByKeySource struct {
+	// WKD is an email address, the key is fetched using the OpenPGP Web Key Directory of its domain.
+	WKD string `json:"wkd,omitempty"`
+	// Keyserver is an URL of a HKP keyserver, e.g. "https://keys.openpgp.org".
+	Keyserver string `json:"keyserver,omitempty"`
 }
 
 // sbKeyType are the allowed values for prSignedBy.KeyType
@@ -100,6 +125,20 @@ const (
 	SBKeyTypeSignedByX509CAs sbKeyType = "signedByX509CAs"
 )
 
+// signedDigestScope are the allowed values for prSignedBy.SignedDigest and prSigstoreSigned.SignedDigest
+type signedDigestScope string
+
+const (
+	// SignedDigestInstance requires signatures of a per-platform instance of a multi-platform image to be over the instance digest.
+	SignedDigestInstance signedDigestScope = "instance"
+	// SignedDigestIndex requires signatures of a per-platform instance of a multi-platform image to be over the digest
+	// of the top-level manifest list / index, which must contain the instance.
+	SignedDigestIndex signedDigestScope = "index"
+	// SignedDigestEither accepts signatures of a per-platform instance of a multi-platform image over either
+	// the instance digest, or the digest of the top-level manifest list / index containing the instance.
+	SignedDigestEither signedDigestScope = "either"
+)
+
 // prSignedBaseLayer is a PolicyRequirement with type = prSignedBaseLayer: the image has a specified, correctly signed, base image.
 type prSignedBaseLayer struct {
 	prCommon
@@ -119,9 +158,14 @@ type prSigstoreSigned struct {
 	// KeyPaths if a set of pathnames to local files containing the trusted key(s). Exactly one of KeyPath, KeyPaths, KeyData or Fulcio must be specified.
 	KeyPaths []string `json:"keyPaths,omitempty"`
 
-	// Fulcio specifies which Fulcio-generated certificates are accepted. Exactly one of KeyPath, KeyData, Fulcio must be specified.
-	// If Fulcio is specified, one of RekorPublicKeyPath or RekorPublicKeyData must be specified as well.
+	// Fulcio specifies which Fulcio-generated certificates are accepted. Exactly one of KeyPath, KeyData, Fulcio, Fulcios must be specified.
+	// If Fulcio is specified, one of RekorPublicKeyPath, RekorPublicKeyData, RekorPublicKeyPaths, RekorPublicKeyDatas, TrustedRootPath,
+	// TrustedRootData, TSACertPath or TSACertData must be specified as well.
 	Fulcio PRSigstoreSignedFulcio `json:"fulcio,omitempty"`
+	// Fulcios is a set of Fulcio configurations, each with its own CA and identity constraints; certificates accepted by any of them
+	// are accepted (e.g. to trust both the public Fulcio instance and a private one during a migration).
+	// Otherwise it is used the same way as Fulcio.
+	Fulcios []PRSigstoreSignedFulcio `json:"fulcios,omitempty"`
 
 	// RekorPublicKeyPath is a pathname to local file containing a public key of a Rekor server which must record acceptable signatures.
 	// If Fulcio is used, one of RekorPublicKeyPath or RekorPublicKeyData must be specified as well; otherwise it is optional
@@ -131,33 +175,218 @@ type prSigstoreSigned struct {
 	// If Fulcio is used, one of RekorPublicKeyPath or RekorPublicKeyData must be specified as well; otherwise it is optional
 	// (and Rekor inclusion is not required if a Rekor public key is not specified).
 	RekorPublicKeyData []byte `json:"rekorPublicKeyData,omitempty"`
+	// RekorPublicKeyPaths is a set of pathnames to local files containing public keys of Rekor log shards, one of which must record acceptable signatures.
+	// The shard is chosen by matching the log ID in the signature’s Rekor SET. At most one of RekorPublicKeyPath, RekorPublicKeyData,
+	// RekorPublicKeyPaths and RekorPublicKeyDatas can be specified.
+	RekorPublicKeyPaths []string `json:"rekorPublicKeyPaths,omitempty"`
+	// RekorPublicKeyDatas is a set of base64-encoded public keys of Rekor log shards, one of which must record acceptable signatures.
+	// The shard is chosen by matching the log ID in the signature’s Rekor SET. At most one of RekorPublicKeyPath, RekorPublicKeyData,
+	// RekorPublicKeyPaths and RekorPublicKeyDatas can be specified.
+	RekorPublicKeyDatas [][]byte `json:"rekorPublicKeyDatas,omitempty"`
+
+	// TrustedRootPath is a pathname to a local Sigstore trusted_root.json file, providing the Rekor public keys
+	// and, if Fulcio is used, the Fulcio CA certificates (so that Fulcio must not specify CAPath or CAData).
+	// At most one of TrustedRootPath and TrustedRootData can be specified, and they can’t be combined with the Rekor public key fields.
+	TrustedRootPath string `json:"trustedRootPath,omitempty"`
+	// TrustedRootData contains the contents of a Sigstore trusted_root.json file, base64-encoded. See TrustedRootPath.
+	TrustedRootData []byte `json:"trustedRootData,omitempty"`
+	// TrustRoot specifies a TUF repository distributing a Sigstore trusted_root.json file, which is then used like TrustedRootPath;
+	// this allows following key rotations. It can’t be combined with TrustedRootPath, TrustedRootData or the Rekor public key fields.
+	TrustRoot PRSigstoreSignedTrustRoot `json:"trustRoot,omitempty"`
+
+	// TSACertPath is a pathname to a local file containing the certificates of a trusted RFC 3161 timestamp authority, in PEM format.
+	// If it is specified, acceptable signatures must carry a timestamp issued by the authority, in addition to any Rekor SET.
+	// If Fulcio is used, the signing certificate must be valid at the time of the timestamp.
+	// At most one of TSACertPath and TSACertData can be specified.
+	TSACertPath string `json:"tsaCertPath,omitempty"`
+	// TSACertData contains the certificates of a trusted RFC 3161 timestamp authority, in PEM format, base64-encoded. See TSACertPath.
+	TSACertData []byte `json:"tsaCertData,omitempty"`
 
 	// SignedIdentity specifies what image identity the signature must be claiming about the image.
 	// Defaults to "matchRepoDigestOrExact" if not specified.
 	// Note that /usr/bin/cosign interoperability might require using repo-only matching.
 	SignedIdentity PolicyReferenceMatch `json:"signedIdentity"`
+
+	// SignedDigest specifies which manifest digest, for an instance of a multi-platform image, the signature must be claiming.
+	// Defaults to SignedDigestInstance if not specified.
+	SignedDigest signedDigestScope `json:"signedDigest,omitempty"`
+
+	// InTotoPredicateTypes lists the predicate types of in-toto statements (in DSSE envelopes or Sigstore bundles) which are accepted
+	// as signatures of the image. If empty, in-toto statements are not accepted.
+	// In-toto statements don’t contain a signed image identity, so this requires SignedIdentity to be "matchRepoDigestOrExact",
+	// and statements are only accepted for images referenced by digest.
+	InTotoPredicateTypes []string `json:"inTotoPredicateTypes,omitempty"`
 }
 
+// prExternalEvaluator is a PolicyRequirement with type = prTypeExternalEvaluator: an external program decides whether the image is accepted,
+// based on the image reference, manifest and signatures.
+type prExternalEvaluator struct {
+	prCommon
+
+	// Command is the evaluator to run: an absolute path to an executable, followed by its arguments.
+	Command []string `json:"command"`
+	// TimeoutSeconds limits how long the evaluator may run. Defaults to defaultExternalEvaluatorTimeout if not specified.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// prVulnerabilityAttestation is a PolicyRequirement with type = prTypeVulnerabilityAttestation: the image has a recent enough
+// vulnerability scan attestation, signed by trusted keys, which does not report vulnerabilities more severe than allowed,
+// except for vulnerabilities which VEX attestations, also signed by trusted keys, declare as not affecting the image.
+type prVulnerabilityAttestation struct {
+	prCommon
+
+	// KeyPath is a pathname to a local file containing the trusted key. Exactly one of KeyPath, KeyPaths and KeyData must be specified.
+	KeyPath string `json:"keyPath,omitempty"`
+	// KeyPaths is a set of pathnames to local files containing the trusted keys. Exactly one of KeyPath, KeyPaths and KeyData must be specified.
+	KeyPaths []string `json:"keyPaths,omitempty"`
+	// KeyData contains the trusted key, base64-encoded. Exactly one of KeyPath, KeyPaths and KeyData must be specified.
+	KeyData []byte `json:"keyData,omitempty"`
+
+	// MaxSeverity is the most severe level of vulnerabilities allowed in the image.
+	MaxSeverity vulnerabilitySeverity `json:"maxSeverity"`
+	// MaxScanAgeSeconds, if not 0, is the maximum age of the vulnerability scan, measured from the end of the scan.
+	MaxScanAgeSeconds int `json:"maxScanAgeSeconds,omitempty"`
+}
+
+// vulnerabilitySeverity are the allowed values for prVulnerabilityAttestation.MaxSeverity
+type vulnerabilitySeverity string
+
+const (
+	// VulnerabilitySeverityNone allows no vulnerabilities at all.
+	VulnerabilitySeverityNone vulnerabilitySeverity = "none"
+	// VulnerabilitySeverityLow allows vulnerabilities of low severity.
+	VulnerabilitySeverityLow vulnerabilitySeverity = "low"
+	// VulnerabilitySeverityMedium allows vulnerabilities of low and medium severity.
+	VulnerabilitySeverityMedium vulnerabilitySeverity = "medium"
+	// VulnerabilitySeverityHigh allows vulnerabilities of low, medium and high severity.
+	VulnerabilitySeverityHigh vulnerabilitySeverity = "high"
+	// VulnerabilitySeverityCritical allows vulnerabilities of any known severity.
+	VulnerabilitySeverityCritical vulnerabilitySeverity = "critical"
+)
+
 // PRSigstoreSignedFulcio contains Fulcio configuration options for a "sigstoreSigned" PolicyRequirement.
 // This is a public type with a single private implementation.
 type PRSigstoreSignedFulcio interface {
-	// toFulcioTrustRoot creates a fulcioTrustRoot from the input data.
+	// toFulcioTrustRoot creates a fulcioTrustRoot from the input data, using CA certificates from trustedRoot if it is not nil.
 	// (This also prevents external implementations of this interface, ensuring that prSigstoreSignedFulcio is the only one.)
-	prepareTrustRoot() (*fulcioTrustRoot, error)
+	prepareTrustRoot(trustedRoot *sigstoreTrustedRoot) (*fulcioTrustRoot, error)
+	// specifiesCA returns true if the CA certificates are specified directly, instead of using a trusted root.
+	specifiesCA() bool
 }
 
 // prSigstoreSignedFulcio collects Fulcio configuration options for prSigstoreSigned
 type prSigstoreSignedFulcio struct {
-	// CAPath a path to a file containing accepted CA root certificates, in PEM format. Exactly one of CAPath and CAData must be specified.
+	// CAPath a path to a file containing accepted CA root certificates, in PEM format.
+	// Exactly one of CAPath and CAData must be specified, unless prSigstoreSigned uses a trusted root.
 	CAPath string `json:"caPath,omitempty"`
-	// CAData contains accepted CA root certificates in PEM format, all of that base64-encoded. Exactly one of CAPath and CAData must be specified.
+	// CAData contains accepted CA root certificates in PEM format, all of that base64-encoded.
+	// Exactly one of CAPath and CAData must be specified, unless prSigstoreSigned uses a trusted root.
 	CAData []byte `json:"caData,omitempty"`
 	// OIDCIssuer specifies the expected OIDC issuer, recorded by Fulcio into the generated certificates.
+	// Exactly one of (OIDCIssuer or OIDCIssuerRegex, and one subject field) and CIIdentity must be specified.
 	OIDCIssuer string `json:"oidcIssuer,omitempty"`
+	// OIDCIssuerRegex is a regular expression (in Go RE2 syntax) which must match the whole OIDC issuer
+	// recorded by Fulcio into the generated certificates. It can’t be combined with OIDCIssuer.
+	OIDCIssuerRegex string `json:"oidcIssuerRegex,omitempty"`
 	// SubjectEmail specifies the expected email address of the authenticated OIDC identity, recorded by Fulcio into the generated certificates.
+	// Unless CIIdentity is used, exactly one of the subject fields (SubjectEmail, SubjectEmailRegex, SubjectURI and SubjectURIRegex) must be specified.
 	SubjectEmail string `json:"subjectEmail,omitempty"`
+	// SubjectEmailRegex is a regular expression (in Go RE2 syntax) which must match the whole email address
+	// of the authenticated OIDC identity, recorded by Fulcio into the generated certificates.
+	SubjectEmailRegex string `json:"subjectEmailRegex,omitempty"`
+	// SubjectURI specifies the expected URI of the authenticated OIDC identity (e.g. a CI workflow URI or a SPIFFE ID),
+	// recorded by Fulcio into the generated certificates as a URI subject alternative name.
+	SubjectURI string `json:"subjectURI,omitempty"`
+	// SubjectURIRegex is a regular expression (in Go RE2 syntax) which must match the whole URI
+	// of the authenticated OIDC identity, recorded by Fulcio into the generated certificates.
+	SubjectURIRegex string `json:"subjectURIRegex,omitempty"`
+	// CIIdentity specifies the expected build in a well-known CI system, which implies the expected OIDC issuer and identity.
+	// Exactly one of (OIDCIssuer and SubjectEmail) and CIIdentity must be specified.
+	CIIdentity *prSigstoreSignedFulcioCIIdentity `json:"ciIdentity,omitempty"`
+	// IntegratedTimeCheck specifies how the time the signature was recorded in the Rekor log is checked against
+	// the validity period of the certificate (chain); one of the IntegratedTimeCheck* constants. "" means IntegratedTimeCheckWithinValidity.
+	IntegratedTimeCheck string `json:"integratedTimeCheck,omitempty"`
+	// IntegratedTimeClockSkewSeconds, if IntegratedTimeCheck is IntegratedTimeCheckWithinValidity, is the number of seconds
+	// the recorded time may fall outside of the validity period, to tolerate skewed clocks.
+	IntegratedTimeClockSkewSeconds int64 `json:"integratedTimeClockSkewSeconds,omitempty"`
+	// IssuedAfter, if set, requires the certificate to have been issued (i.e. to have NotBefore) at or after this time,
+	// e.g. to reject certificates issued before a CA re-keying.
+	IssuedAfter *time.Time `json:"issuedAfter,omitempty"`
+	// IssuedBefore, if set, requires the certificate to have been issued (i.e. to have NotBefore) before this time.
+	IssuedBefore *time.Time `json:"issuedBefore,omitempty"`
 }
 
+// PRSigstoreSignedTrustRoot contains TUF repository options for a "sigstoreSigned" PolicyRequirement.
+// This is a public type with a single private implementation.
+type PRSigstoreSignedTrustRoot interface {
+	// loadTrustedRoot returns the contents of a Sigstore trusted_root.json file, verified using TUF.
+	// (This also prevents external implementations of this interface, ensuring that prSigstoreSignedTrustRoot is the only one.)
+	loadTrustedRoot(ctx context.Context) ([]byte, error)
+}
+
+// prSigstoreSignedTrustRoot collects TUF repository options for prSigstoreSigned.
+type prSigstoreSignedTrustRoot struct {
+	// Mirror is the base URL of the TUF repository, e.g. "https://tuf-repo-cdn.sigstore.dev".
+	// If it is not specified, only the TUF metadata in CachePath is used.
+	Mirror string `json:"mirror,omitempty"`
+	// RootPath is a pathname to a local file containing trusted TUF root metadata (root.json) of the repository.
+	// If Mirror is specified, exactly one of RootPath and RootData must be specified; otherwise they are optional.
+	RootPath string `json:"rootPath,omitempty"`
+	// RootData contains trusted TUF root metadata of the repository, base64-encoded. See RootPath.
+	RootData []byte `json:"rootData,omitempty"`
+	// CachePath is a pathname to a directory caching the TUF metadata and the trusted root.
+	// It must be specified if Mirror is not; otherwise it defaults to a per-user location.
+	CachePath string `json:"cachePath,omitempty"`
+}
+
+// prSigstoreSignedFulcioCIIdentity describes a build in a well-known CI system, identified by the OIDC issuer
+// and subject recorded by Fulcio in certificates issued to builds in that system.
+type prSigstoreSignedFulcioCIIdentity struct {
+	// Provider is the CI system, one of the FulcioCIProvider* constants.
+	Provider string `json:"provider"`
+	// Repository is the repository ("owner/repository") for FulcioCIProviderGitHubActions,
+	// or the project path ("group/project", possibly with subgroups) for FulcioCIProviderGitLabCI.
+	Repository string `json:"repository,omitempty"`
+	// Workflow is the path of the workflow file within the repository (e.g. ".github/workflows/release.yml") for FulcioCIProviderGitHubActions,
+	// or of the CI configuration file for FulcioCIProviderGitLabCI (".gitlab-ci.yml" if not specified).
+	Workflow string `json:"workflow,omitempty"`
+	// Ref, if set, is the Git reference of the workflow which ran the build (e.g. "refs/heads/main" or "refs/tags/v1.0"),
+	// for FulcioCIProviderGitHubActions and FulcioCIProviderGitLabCI. If neither Ref nor RefPrefix is set, any reference is accepted.
+	Ref string `json:"ref,omitempty"`
+	// RefPrefix, if set, is a prefix of the Git reference of the workflow which ran the build (e.g. "refs/tags/" to accept any tag),
+	// for FulcioCIProviderGitHubActions and FulcioCIProviderGitLabCI. It can’t be combined with Ref.
+	RefPrefix string `json:"refPrefix,omitempty"`
+	// Trigger, if set, is the event which triggered the workflow (e.g. "push" or "release") for FulcioCIProviderGitHubActions,
+	// as recorded by Fulcio in a certificate extension.
+	Trigger string `json:"trigger,omitempty"`
+	// SHA, if set, is the Git commit the workflow ran on (as a lowercase hexadecimal object ID) for FulcioCIProviderGitHubActions,
+	// as recorded by Fulcio in a certificate extension.
+	SHA string `json:"sha,omitempty"`
+	// ProjectNumber is the number of the Google Cloud project for FulcioCIProviderGoogleCloudBuild;
+	// builds are identified by the project’s default Cloud Build service account.
+	ProjectNumber string `json:"projectNumber,omitempty"`
+}
+
+const (
+	// FulcioCIProviderGitHubActions identifies GitHub Actions workflows on github.com.
+	FulcioCIProviderGitHubActions = "githubActions"
+	// FulcioCIProviderGitLabCI identifies GitLab CI pipelines on gitlab.com.
+	FulcioCIProviderGitLabCI = "gitlabCI"
+	// FulcioCIProviderGoogleCloudBuild identifies Google Cloud Build builds using the default Cloud Build service account.
+	FulcioCIProviderGoogleCloudBuild = "googleCloudBuild"
+)
+
+const (
+	// IntegratedTimeCheckWithinValidity requires the time the signature was recorded in the Rekor log
+	// to be within the validity period of the certificate (chain). This is the default.
+	IntegratedTimeCheckWithinValidity = "withinValidity"
+	// IntegratedTimeCheckIgnore does not compare the time the signature was recorded in the Rekor log
+	// with the validity period of the certificate; the certificate chain is verified at the time the certificate was issued.
+	// The Rekor log record is still required. This is weaker than the default: an expired or stolen certificate
+	// can be used to create signatures after the end of its validity period.
+	IntegratedTimeCheckIgnore = "ignore"
+)
+
 // PolicyReferenceMatch specifies a set of image identities accepted in PolicyRequirement.
 // The type is public, but its implementation is private.
 
@@ -176,6 +405,7 @@ const (
 	prmTypeExactReference         prmTypeIdentifier = "exactReference"
 	prmTypeExactRepository        prmTypeIdentifier = "exactRepository"
 	prmTypeRemapIdentity          prmTypeIdentifier = "remapIdentity"
+	prmTypeMatchArtifact          prmTypeIdentifier = "matchArtifact"
 )
 
 // prmMatchExact is a PolicyReferenceMatch with type = prmMatchExact: the two references must match exactly.
@@ -215,3 +445,13 @@ type prmRemapIdentity struct {
 	// Possibly let the users make a choice for tag/digest matching behavior
 	// similar to prmMatchExact/prmMatchRepository?
 }
+
+// prmMatchArtifact is a PolicyReferenceMatch with type = prmMatchArtifact: like prmMatchRepository,
+// except that images without a Docker reference identity (e.g. artifacts referenced using a transport which does not record one)
+// are matched against DockerRepository.
+type prmMatchArtifact struct {
+	prmCommon
+	// DockerRepository, if not "", is the repository the signature must claim for images without a Docker reference identity.
+	// If "", such images are rejected.
+	DockerRepository string `json:"dockerRepository,omitempty"`
+}
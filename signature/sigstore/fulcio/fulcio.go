@@ -14,13 +14,13 @@ import (
 	"io"
 	"net/url"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/useragent"
 	"github.com/containers/image/v5/signature/sigstore/internal"
 	"github.com/sigstore/fulcio/pkg/api"
 	"github.com/sigstore/sigstore/pkg/oauth"
 	"github.com/sigstore/sigstore/pkg/oauthflow"
 	sigstoreSignature "github.com/sigstore/sigstore/pkg/signature"
-	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
 
@@ -41,7 +41,7 @@ func setupSignerWithFulcio(s *internal.SigstoreSigner, fulcioURL *url.URL, oidcI
 	}
 	s.PrivateKey = signer
 
-	logrus.Debugf("Requesting a certificate from Fulcio at %s", fulcioURL.Redacted())
+	log.Debugf("Requesting a certificate from Fulcio at %s", fulcioURL.Redacted())
 	fulcioClient := api.NewClient(fulcioURL, api.WithUserAgent(useragent.DefaultUserAgent))
 	// Sign the email address as part of the request
 	h := sha256.Sum256([]byte(oidcIDToken.Subject))
@@ -89,7 +89,7 @@ func WithFulcioAndPreexistingOIDCIDToken(fulcioURL *url.URL, oidcIDToken string)
 		// Are there any widely used tools to manually obtain an ID token? Why would there be?
 		// For long-term usage, users provisioning a static OIDC credential might just as well provision an already-generated certificate
 		// or something like that.
-		logrus.Debugf("Using a statically-provided OIDC token")
+		log.Debugf("Using a statically-provided OIDC token")
 		staticTokenGetter := oauthflow.StaticTokenGetter{RawToken: oidcIDToken}
 		oidcIDToken, err := staticTokenGetter.GetIDToken(nil, oauth2.Config{})
 		if err != nil {
@@ -111,7 +111,7 @@ func WithFulcioAndDeviceAuthorizationGrantOIDC(fulcioURL *url.URL, oidcIssuerURL
 			return fmt.Errorf("multiple private key sources specified when preparing to create sigstore signatures")
 		}
 
-		logrus.Debugf("Starting OIDC device flow for issuer %s", oidcIssuerURL.Redacted())
+		log.Debugf("Starting OIDC device flow for issuer %s", oidcIssuerURL.Redacted())
 		tokenGetter := oauthflow.NewDeviceFlowTokenGetterForIssuer(oidcIssuerURL.String())
 		tokenGetter.MessagePrinter = func(s string) {
 			fmt.Fprintln(interactiveOutput, s)
@@ -141,7 +141,7 @@ func WithFulcioAndInteractiveOIDC(fulcioURL *url.URL, oidcIssuerURL *url.URL, oi
 			return fmt.Errorf("multiple private key sources specified when preparing to create sigstore signatures")
 		}
 
-		logrus.Debugf("Starting interactive OIDC authentication for issuer %s", oidcIssuerURL.Redacted())
+		log.Debugf("Starting interactive OIDC authentication for issuer %s", oidcIssuerURL.Redacted())
 		// This is intended to match oauthflow.DefaultIDTokenGetter (incl. the update in init()), overriding only input/output
 		htmlPage, err := oauth.GetInteractiveSuccessHTML(false, 10)
 		if err != nil {
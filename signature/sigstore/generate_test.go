@@ -2,6 +2,7 @@ package sigstore
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -62,3 +63,57 @@ func TestGenerateKeyPair(t *testing.T) {
 
 	// The failure paths are not obviously easy to reach.
 }
+
+func TestSignWithMultipleKeys(t *testing.T) {
+	testManifest := []byte("{}")
+	testDockerReference, err := reference.ParseNormalizedNamed("example.com/foo:notlatest")
+	require.NoError(t, err)
+
+	passphrase := []byte("some passphrase")
+	tmpDir := t.TempDir()
+	opts := []Option{}
+	publicKeys := [][]byte{}
+	for i := 0; i < 2; i++ {
+		keyPair, err := GenerateKeyPair(passphrase)
+		require.NoError(t, err)
+		privateKeyFile := filepath.Join(tmpDir, fmt.Sprintf("private%d.key", i))
+		err = os.WriteFile(privateKeyFile, keyPair.PrivateKey, 0600)
+		require.NoError(t, err)
+		opts = append(opts, WithPrivateKeyFile(privateKeyFile, passphrase))
+		publicKeys = append(publicKeys, keyPair.PublicKey)
+	}
+
+	signer, err := NewSigner(opts...)
+	require.NoError(t, err)
+	sigs, err := internalSigner.SignImageManifestWithAllKeys(context.Background(), signer, testManifest, testDockerReference)
+	require.NoError(t, err)
+	require.Len(t, sigs, len(publicKeys))
+	for i, publicKeyPEM := range publicKeys {
+		sig, ok := sigs[i].(signature.Sigstore)
+		require.True(t, ok)
+		publicKey, err := cryptoutils.UnmarshalPEMToPublicKey(publicKeyPEM)
+		require.NoError(t, err)
+		_, err = internal.VerifySigstorePayload(publicKey, sig.UntrustedPayload(),
+			sig.UntrustedAnnotations()[signature.SigstoreSignatureAnnotationKey],
+			internal.SigstorePayloadAcceptanceRules{
+				ValidateSignedDockerReference:      func(ref string) error { return nil },
+				ValidateSignedDockerManifestDigest: func(digest digest.Digest) error { return nil },
+			})
+		assert.NoError(t, err, i)
+	}
+
+	// SignImageManifest only uses the first key
+	sig0, err := internalSigner.SignImageManifest(context.Background(), signer, testManifest, testDockerReference)
+	require.NoError(t, err)
+	sig, ok := sig0.(signature.Sigstore)
+	require.True(t, ok)
+	publicKey, err := cryptoutils.UnmarshalPEMToPublicKey(publicKeys[0])
+	require.NoError(t, err)
+	_, err = internal.VerifySigstorePayload(publicKey, sig.UntrustedPayload(),
+		sig.UntrustedAnnotations()[signature.SigstoreSignatureAnnotationKey],
+		internal.SigstorePayloadAcceptanceRules{
+			ValidateSignedDockerReference:      func(ref string) error { return nil },
+			ValidateSignedDockerManifestDigest: func(digest digest.Digest) error { return nil },
+		})
+	assert.NoError(t, err)
+}
@@ -24,6 +24,9 @@ type SigstoreSigner struct {
 	PrivateKey       sigstoreSignature.Signer // May be nil during initialization
 	SigningKeyOrCert []byte                   // For possible Rekor upload; always initialized together with PrivateKey
 
+	// Further private keys, each used to create a separate signature; not used together with Fulcio.
+	AdditionalKeys []SigstoreSigningKey
+
 	// Fulcio results to include
 	FulcioGeneratedCertificate      []byte // Or nil
 	FulcioGeneratedCertificateChain []byte // Or nil
@@ -32,17 +35,55 @@ type SigstoreSigner struct {
 	RekorUploader func(ctx context.Context, keyOrCertBytes []byte, signatureBytes []byte, payloadBytes []byte) ([]byte, error) // Or nil
 }
 
+// SigstoreSigningKey is a private key, and the corresponding data to upload to Rekor.
+type SigstoreSigningKey struct {
+	PrivateKey       sigstoreSignature.Signer
+	SigningKeyOrCert []byte
+}
+
 // ProgressMessage returns a human-readable sentence that makes sense to write before starting to create a single signature.
 func (s *SigstoreSigner) ProgressMessage() string {
 	return "Signing image using a sigstore signature"
 }
 
-// SignImageManifest creates a new signature for manifest m as dockerReference.
+// SignImageManifest creates a new signature for manifest m as dockerReference, using the primary key.
 func (s *SigstoreSigner) SignImageManifest(ctx context.Context, m []byte, dockerReference reference.Named) (signature.Signature, error) {
 	if s.PrivateKey == nil {
 		return nil, errors.New("internal error: nothing to sign with, should have been detected in NewSigner")
 	}
+	payloadBytes, err := signaturePayload(m, dockerReference)
+	if err != nil {
+		return nil, err
+	}
+	return s.signPayload(ctx, payloadBytes, SigstoreSigningKey{PrivateKey: s.PrivateKey, SigningKeyOrCert: s.SigningKeyOrCert})
+}
+
+// SignImageManifestWithAllKeys creates new signatures for manifest m as dockerReference, one for each configured key.
+func (s *SigstoreSigner) SignImageManifestWithAllKeys(ctx context.Context, m []byte, dockerReference reference.Named) ([]signature.Signature, error) {
+	if s.PrivateKey == nil {
+		return nil, errors.New("internal error: nothing to sign with, should have been detected in NewSigner")
+	}
+	payloadBytes, err := signaturePayload(m, dockerReference)
+	if err != nil {
+		return nil, err
+	}
+	keys := append([]SigstoreSigningKey{{PrivateKey: s.PrivateKey, SigningKeyOrCert: s.SigningKeyOrCert}}, s.AdditionalKeys...)
+	res := make([]signature.Signature, 0, len(keys))
+	for i, key := range keys {
+		sig, err := s.signPayload(ctx, payloadBytes, key)
+		if err != nil {
+			if len(keys) == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("signing with key %d: %w", i+1, err)
+		}
+		res = append(res, sig)
+	}
+	return res, nil
+}
 
+// signaturePayload returns the signed payload for manifest m as dockerReference.
+func signaturePayload(m []byte, dockerReference reference.Named) ([]byte, error) {
 	if reference.IsNameOnly(dockerReference) {
 		return nil, fmt.Errorf("reference %s can’t be signed, it has neither a tag nor a digest", dockerReference.String())
 	}
@@ -54,21 +95,21 @@ func (s *SigstoreSigner) SignImageManifest(ctx context.Context, m []byte, docker
 	// They record the repo (but NOT THE TAG) in the value; without the tag we can’t detect version rollbacks.
 	// So, just do what simple signing does, and cosign won’t mind.
 	payloadData := internal.NewUntrustedSigstorePayload(manifestDigest, dockerReference.String())
-	payloadBytes, err := json.Marshal(payloadData)
-	if err != nil {
-		return nil, err
-	}
+	return json.Marshal(payloadData)
+}
 
+// signPayload creates a signature of payloadBytes using key.
+func (s *SigstoreSigner) signPayload(ctx context.Context, payloadBytes []byte, key SigstoreSigningKey) (signature.Signature, error) {
 	// github.com/sigstore/cosign/internal/pkg/cosign.payloadSigner uses signatureoptions.WithContext(),
 	// which seems to be not used by anything. So we don’t bother.
-	signatureBytes, err := s.PrivateKey.SignMessage(bytes.NewReader(payloadBytes))
+	signatureBytes, err := key.PrivateKey.SignMessage(bytes.NewReader(payloadBytes))
 	if err != nil {
 		return nil, fmt.Errorf("creating signature: %w", err)
 	}
 	base64Signature := base64.StdEncoding.EncodeToString(signatureBytes)
 	var rekorSETBytes []byte // = nil
 	if s.RekorUploader != nil {
-		set, err := s.RekorUploader(ctx, s.SigningKeyOrCert, signatureBytes, payloadBytes)
+		set, err := s.RekorUploader(ctx, key.SigningKeyOrCert, signatureBytes, payloadBytes)
 		if err != nil {
 			return nil, err
 		}
@@ -14,6 +14,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/signature/internal"
 	signerInternal "github.com/containers/image/v5/signature/sigstore/internal"
 	"github.com/go-openapi/strfmt"
@@ -21,16 +22,15 @@ import (
 	"github.com/sigstore/rekor/pkg/generated/client"
 	"github.com/sigstore/rekor/pkg/generated/client/entries"
 	"github.com/sigstore/rekor/pkg/generated/models"
-	"github.com/sirupsen/logrus"
 )
 
 // WithRekor asks the generated signature to be uploaded to the specified Rekor server,
 // and to include a log inclusion proof in the signature.
 func WithRekor(rekorURL *url.URL) signerInternal.Option {
 	return func(s *signerInternal.SigstoreSigner) error {
-		logrus.Debugf("Using Rekor server at %s", rekorURL.Redacted())
+		log.Debugf("Using Rekor server at %s", rekorURL.Redacted())
 		client, err := rekor.GetRekorClient(rekorURL.String(),
-			rekor.WithLogger(leveledLoggerForLogrus(logrus.StandardLogger())))
+			rekor.WithLogger(newLeveledLogger()))
 		if err != nil {
 			return fmt.Errorf("creating Rekor client: %w", err)
 		}
@@ -83,7 +83,7 @@ func rekorEntryToSET(entry *models.LogEntryAnon) (internal.UntrustedRekorSET, er
 func (u *uploader) uploadEntry(ctx context.Context, proposedEntry models.ProposedEntry) (models.LogEntry, error) {
 	params := entries.NewCreateLogEntryParamsWithContext(ctx)
 	params.SetProposedEntry(proposedEntry)
-	logrus.Debugf("Calling Rekor's CreateLogEntry")
+	log.DebugfContext(ctx, "Calling Rekor's CreateLogEntry")
 	resp, err := u.client.Entries.CreateLogEntry(params)
 	if err != nil {
 		// In ordinary operation, we should not get duplicate entries, because our payload contains a timestamp,
@@ -92,13 +92,13 @@ func (u *uploader) uploadEntry(ctx context.Context, proposedEntry models.Propose
 		var conflictErr *entries.CreateLogEntryConflict
 		if errors.As(err, &conflictErr) && conflictErr.Location != "" {
 			location := conflictErr.Location.String()
-			logrus.Debugf("CreateLogEntry reported a conflict, location = %s", location)
+			log.DebugfContext(ctx, "CreateLogEntry reported a conflict, location = %s", location)
 			// We might be able to just GET the returned Location, but let’s use the generated API client.
 			// OTOH that requires us to hard-code the URI structure…
 			uuidDelimiter := strings.LastIndexByte(location, '/')
 			if uuidDelimiter != -1 { // Otherwise the URI is unexpected, and fall through to the bottom
 				uuid := location[uuidDelimiter+1:]
-				logrus.Debugf("Calling Rekor's NewGetLogEntryByUUIDParamsWithContext")
+				log.DebugfContext(ctx, "Calling Rekor's NewGetLogEntryByUUIDParamsWithContext")
 				params2 := entries.NewGetLogEntryByUUIDParamsWithContext(ctx)
 				params2.SetEntryUUID(uuid)
 				resp2, err := u.client.Entries.GetLogEntryByUUID(params2)
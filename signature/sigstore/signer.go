@@ -13,9 +13,11 @@ import (
 
 type Option = internal.Option
 
+// WithPrivateKeyFile returns an Option for NewSigner, specifying a private key file to sign with, protected by passphrase.
+// The option can be used more than once; copy.Image then creates one signature for each of the keys.
 func WithPrivateKeyFile(file string, passphrase []byte) Option {
 	return func(s *internal.SigstoreSigner) error {
-		if s.PrivateKey != nil {
+		if s.FulcioGeneratedCertificate != nil {
 			return fmt.Errorf("multiple private key sources specified when preparing to create sigstore signatures")
 		}
 
@@ -39,6 +41,13 @@ func WithPrivateKeyFile(file string, passphrase []byte) Option {
 		if err != nil {
 			return fmt.Errorf("converting public key to PEM: %w", err)
 		}
+		if s.PrivateKey != nil {
+			s.AdditionalKeys = append(s.AdditionalKeys, internal.SigstoreSigningKey{
+				PrivateKey:       signerVerifier,
+				SigningKeyOrCert: publicKeyPEM,
+			})
+			return nil
+		}
 		s.PrivateKey = signerVerifier
 		s.SigningKeyOrCert = publicKeyPEM
 		return nil
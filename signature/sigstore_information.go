@@ -0,0 +1,120 @@
+package signature
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/signature/internal"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// UntrustedSigstoreSignatureInformation is information available in an untrusted sigstore signature.
+// This may be useful for inventory tooling, e.g. to list which images a signature claims to apply to, and who claims to have signed it.
+//
+// WARNING: Do not use the contents of this for ANY security decisions,
+// and be VERY CAREFUL about showing this information to humans in any way which suggest that these values “are probably” reliable.
+// There is NO REASON to expect the values to be correct, or not intentionally misleading
+// (including things like “✅ Verified by $authority”)
+type UntrustedSigstoreSignatureInformation struct {
+	UntrustedDockerManifestDigest digest.Digest
+	UntrustedDockerReference      string // FIXME: more precise type?
+	UntrustedCreatorID            *string
+	UntrustedTimestamp            *time.Time
+	// UntrustedPayloadAnnotations are the annotations in the "optional" section of the signed payload,
+	// typically specified by the user when signing; nil if there are none.
+	UntrustedPayloadAnnotations map[string]any
+	// UntrustedCertificate is set if the signature includes a (typically Fulcio-issued) certificate; otherwise it is nil.
+	UntrustedCertificate *UntrustedSigstoreCertificateInformation
+	// UntrustedHasRekorSET is true if the signature includes a Rekor SET.
+	UntrustedHasRekorSET bool
+}
+
+// UntrustedSigstoreCertificateInformation is information available in an untrusted certificate included in a sigstore signature.
+//
+// WARNING: Do not use the contents of this for ANY security decisions; see UntrustedSigstoreSignatureInformation.
+type UntrustedSigstoreCertificateInformation struct {
+	UntrustedSubject        string // The subject distinguished name, usually empty for Fulcio-issued certificates
+	UntrustedIssuer         string // The issuer (CA) distinguished name
+	UntrustedEmailAddresses []string
+	UntrustedURIs           []string
+	UntrustedOIDCIssuer     string // The OIDC issuer recorded by Fulcio, or "" if not present
+	UntrustedNotBefore      time.Time
+	UntrustedNotAfter       time.Time
+}
+
+// GetUntrustedSigstoreSignatureInformationWithoutVerifying accepts the components of a sigstore signature attachment
+// (the MIME type and contents of the layer, and the layer’s annotations), and returns information available in it,
+// WITHOUT doing any cryptographic verification.
+// This may be useful when debugging signature verification failures,
+// or when managing a set of signatures on a single image.
+//
+// WARNING: Do not use the contents of this for ANY security decisions,
+// and be VERY CAREFUL about showing this information to humans in any way which suggest that these values “are probably” reliable.
+// There is NO REASON to expect the values to be correct, or not intentionally misleading
+// (including things like “✅ Verified by $authority”)
+func GetUntrustedSigstoreSignatureInformationWithoutVerifying(untrustedMIMEType string, untrustedPayload []byte, untrustedAnnotations map[string]string) (*UntrustedSigstoreSignatureInformation, error) {
+	if untrustedMIMEType != signature.SigstoreSignatureMIMEType {
+		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("unexpected sigstore signature MIME type %q", untrustedMIMEType))
+	}
+	payload, err := internal.ParseUntrustedSigstorePayload(untrustedPayload)
+	if err != nil {
+		return nil, err
+	}
+	res := UntrustedSigstoreSignatureInformation{
+		UntrustedDockerManifestDigest: payload.UntrustedDockerManifestDigest(),
+		UntrustedDockerReference:      payload.UntrustedDockerReference(),
+		UntrustedCreatorID:            payload.UntrustedCreatorID(),
+		UntrustedTimestamp:            payload.UntrustedTimestamp(),
+		UntrustedPayloadAnnotations:   payload.UntrustedAnnotations(),
+	}
+	if _, ok := untrustedAnnotations[signature.SigstoreSETAnnotationKey]; ok {
+		res.UntrustedHasRekorSET = true
+	}
+	if untrustedCertificatePEM, ok := untrustedAnnotations[signature.SigstoreCertificateAnnotationKey]; ok {
+		cert, err := untrustedCertificateInformation([]byte(untrustedCertificatePEM))
+		if err != nil {
+			return nil, err
+		}
+		res.UntrustedCertificate = cert
+	}
+	return &res, nil
+}
+
+// untrustedCertificateInformation returns information about the single certificate in untrustedCertificatePEM.
+func untrustedCertificateInformation(untrustedCertificatePEM []byte) (*UntrustedSigstoreCertificateInformation, error) {
+	untrustedCerts, err := cryptoutils.UnmarshalCertificatesFromPEM(untrustedCertificatePEM)
+	if err != nil {
+		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("parsing certificate: %v", err))
+	}
+	if len(untrustedCerts) != 1 {
+		return nil, internal.NewInvalidSignatureError(fmt.Sprintf("expected a single certificate in signature certificate data, got %d", len(untrustedCerts)))
+	}
+	untrustedCert := untrustedCerts[0]
+
+	uris := make([]string, 0, len(untrustedCert.URIs))
+	for _, u := range untrustedCert.URIs {
+		uris = append(uris, u.String())
+	}
+	return &UntrustedSigstoreCertificateInformation{
+		UntrustedSubject:        untrustedCert.Subject.String(),
+		UntrustedIssuer:         untrustedCert.Issuer.String(),
+		UntrustedEmailAddresses: untrustedCert.EmailAddresses,
+		UntrustedURIs:           uris,
+		UntrustedOIDCIssuer:     untrustedOIDCIssuer(untrustedCert),
+		UntrustedNotBefore:      untrustedCert.NotBefore,
+		UntrustedNotAfter:       untrustedCert.NotAfter,
+	}, nil
+}
+
+// untrustedOIDCIssuer returns the OIDC issuer recorded in untrustedCert, or "" if it is not available.
+func untrustedOIDCIssuer(untrustedCert *x509.Certificate) string {
+	// Not all certificates are Fulcio-issued, so a missing (or invalid) extension is not an error here.
+	issuer, err := fulcioIssuerInCertificate(untrustedCert)
+	if err != nil {
+		return ""
+	}
+	return issuer
+}
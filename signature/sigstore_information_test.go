@@ -0,0 +1,86 @@
+//go:build !containers_image_fulcio_stub
+// +build !containers_image_fulcio_stub
+
+package signature
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadSigstoreSignature returns a sigstore signature loaded from path.
+func loadSigstoreSignature(t *testing.T, path string) signature.Sigstore {
+	blob, err := os.ReadFile(path)
+	require.NoError(t, err)
+	sig, err := signature.FromBlob(blob)
+	require.NoError(t, err)
+	sigstoreSig, ok := sig.(signature.Sigstore)
+	require.True(t, ok)
+	return sigstoreSig
+}
+
+func TestGetUntrustedSigstoreSignatureInformationWithoutVerifying(t *testing.T) {
+	// A key-signed signature
+	sig := loadSigstoreSignature(t, "fixtures/dir-img-cosign-valid/signature-1")
+	info, err := GetUntrustedSigstoreSignatureInformationWithoutVerifying(sig.UntrustedMIMEType(), sig.UntrustedPayload(), sig.UntrustedAnnotations())
+	require.NoError(t, err)
+	assert.Equal(t, &UntrustedSigstoreSignatureInformation{
+		UntrustedDockerManifestDigest: "sha256:634a8f35b5f16dcf4aaa0822adc0b1964bb786fca12f6831de8ddc45e5986a00",
+		UntrustedDockerReference:      "192.168.64.2:5000/cosign-signed-single-sample",
+	}, info)
+
+	// A Fulcio-signed signature with a Rekor SET
+	sig = loadSigstoreSignature(t, "fixtures/dir-img-cosign-fulcio-rekor-valid/signature-1")
+	info, err = GetUntrustedSigstoreSignatureInformationWithoutVerifying(sig.UntrustedMIMEType(), sig.UntrustedPayload(), sig.UntrustedAnnotations())
+	require.NoError(t, err)
+	assert.Equal(t, &UntrustedSigstoreSignatureInformation{
+		UntrustedDockerManifestDigest: "sha256:0489474da8ea22426ece86ace6c1c0026ab2fd3cdfbbd62b7e94650266c37d9a",
+		UntrustedDockerReference:      "192.168.64.2:5000/cosign-signed/fulcio-rekor-1",
+		UntrustedCertificate: &UntrustedSigstoreCertificateInformation{
+			UntrustedSubject:        "",
+			UntrustedIssuer:         "CN=sigstore-intermediate,O=sigstore.dev",
+			UntrustedEmailAddresses: []string{"mitr@redhat.com"},
+			UntrustedURIs:           []string{},
+			UntrustedOIDCIssuer:     "https://github.com/login/oauth",
+			UntrustedNotBefore:      time.Date(2023, time.January, 20, 20, 51, 31, 0, time.UTC),
+			UntrustedNotAfter:       time.Date(2023, time.January, 20, 21, 1, 31, 0, time.UTC),
+		},
+		UntrustedHasRekorSET: true,
+	}, info)
+
+	// Payload annotations, creator and timestamp are returned
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"example.com/ns/repo"},"image":{"docker-manifest-digest":"sha256:634a8f35b5f16dcf4aaa0822adc0b1964bb786fca12f6831de8ddc45e5986a00"},"type":"cosign container image signature"},` +
+		`"optional":{"creator":"some creator","timestamp":1484683104,"owner":"team-a"}}`)
+	info, err = GetUntrustedSigstoreSignatureInformationWithoutVerifying(signature.SigstoreSignatureMIMEType, payload, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/ns/repo", info.UntrustedDockerReference)
+	require.NotNil(t, info.UntrustedCreatorID)
+	assert.Equal(t, "some creator", *info.UntrustedCreatorID)
+	require.NotNil(t, info.UntrustedTimestamp)
+	assert.Equal(t, time.Unix(1484683104, 0), *info.UntrustedTimestamp)
+	assert.Equal(t, map[string]any{"owner": "team-a"}, info.UntrustedPayloadAnnotations)
+	assert.Nil(t, info.UntrustedCertificate)
+
+	// Unexpected MIME type
+	_, err = GetUntrustedSigstoreSignatureInformationWithoutVerifying("application/octet-stream", payload, nil)
+	assert.Error(t, err)
+
+	// Invalid payload
+	_, err = GetUntrustedSigstoreSignatureInformationWithoutVerifying(signature.SigstoreSignatureMIMEType, []byte("{}"), nil)
+	assert.Error(t, err)
+
+	// Invalid certificate
+	for _, cert := range []string{
+		"not a certificate",
+		sig.UntrustedAnnotations()[signature.SigstoreCertificateAnnotationKey] + sig.UntrustedAnnotations()[signature.SigstoreCertificateAnnotationKey],
+	} {
+		_, err = GetUntrustedSigstoreSignatureInformationWithoutVerifying(signature.SigstoreSignatureMIMEType, payload,
+			map[string]string{signature.SigstoreCertificateAnnotationKey: cert})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,176 @@
+// Parsing of Sigstore trusted_root.json files.
+
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/signature/internal"
+)
+
+// sigstoreTrustedRootMediaTypePrefix is the prefix of the "mediaType" values of supported trusted root files;
+// the rest of the value is a version parameter.
+const sigstoreTrustedRootMediaTypePrefix = "application/vnd.dev.sigstore.trustedroot+json;"
+
+// trustedRootJSON is the JSON representation of a dev.sigstore.trustedroot.v1.TrustedRoot
+// (https://github.com/sigstore/protobuf-specs/blob/main/protos/sigstore_trustroot.proto),
+// restricted to the fields we use.
+type trustedRootJSON struct {
+	MediaType              string                         `json:"mediaType"`
+	Tlogs                  []trustedRootTlogJSON          `json:"tlogs"`
+	CertificateAuthorities []trustedRootCertAuthorityJSON `json:"certificateAuthorities"`
+	Ctlogs                 []trustedRootTlogJSON          `json:"ctlogs"`
+	// "timestampAuthorities" are not used: TSA certificates are configured separately.
+}
+
+// trustedRootTlogJSON is the JSON representation of a dev.sigstore.trustedroot.v1.TransparencyLogInstance,
+// used both for Rekor logs and for certificate transparency logs.
+type trustedRootTlogJSON struct {
+	BaseURL   string `json:"baseUrl"`
+	PublicKey struct {
+		RawBytes []byte                `json:"rawBytes"` // DER-encoded PKIX public key
+		ValidFor *trustedRootRangeJSON `json:"validFor"`
+	} `json:"publicKey"`
+}
+
+// trustedRootCertAuthorityJSON is the JSON representation of a dev.sigstore.trustedroot.v1.CertificateAuthority.
+type trustedRootCertAuthorityJSON struct {
+	URI       string `json:"uri"`
+	CertChain struct {
+		Certificates []struct {
+			RawBytes []byte `json:"rawBytes"` // DER-encoded certificate
+		} `json:"certificates"`
+	} `json:"certChain"`
+	ValidFor *trustedRootRangeJSON `json:"validFor"`
+}
+
+// trustedRootRangeJSON is the JSON representation of a dev.sigstore.common.v1.TimeRange.
+type trustedRootRangeJSON struct {
+	Start *time.Time `json:"start"`
+	End   *time.Time `json:"end"`
+}
+
+// bounds returns the start and end of r, with a zero value for unbounded ends.
+func (r *trustedRootRangeJSON) bounds() (time.Time, time.Time) {
+	var start, end time.Time
+	if r != nil {
+		if r.Start != nil {
+			start = *r.Start
+		}
+		if r.End != nil {
+			end = *r.End
+		}
+	}
+	return start, end
+}
+
+// trustedRootCertificateAuthority is a Fulcio CA from a Sigstore trusted root.
+type trustedRootCertificateAuthority struct {
+	root          *x509.Certificate
+	intermediates []*x509.Certificate
+	// validFrom and validUntil, if not zero, restrict the times at which certificates issued by this CA are accepted.
+	validFrom  time.Time
+	validUntil time.Time
+}
+
+// validAt returns true if ca can be used to verify certificates at t.
+func (ca *trustedRootCertificateAuthority) validAt(t time.Time) bool {
+	if !ca.validFrom.IsZero() && t.Before(ca.validFrom) {
+		return false
+	}
+	if !ca.validUntil.IsZero() && t.After(ca.validUntil) {
+		return false
+	}
+	return true
+}
+
+// sigstoreTrustedRoot contains the parts of a Sigstore trusted root we use.
+type sigstoreTrustedRoot struct {
+	rekorPublicKeys        []internal.RekorPublicKey
+	certificateAuthorities []trustedRootCertificateAuthority
+	// ctLogPublicKeys, if not empty, are the certificate transparency logs which must have logged Fulcio certificates.
+	ctLogPublicKeys []internal.CTLogPublicKey
+}
+
+// parseSigstoreTrustedRoot parses the contents of a Sigstore trusted_root.json file.
+func parseSigstoreTrustedRoot(data []byte) (*sigstoreTrustedRoot, error) {
+	var root trustedRootJSON
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parsing Sigstore trusted root: %w", err)
+	}
+	if !strings.HasPrefix(root.MediaType, sigstoreTrustedRootMediaTypePrefix) {
+		return nil, fmt.Errorf("unsupported Sigstore trusted root media type %q", root.MediaType)
+	}
+
+	res := sigstoreTrustedRoot{}
+	for _, tlog := range root.Tlogs {
+		pk, err := x509.ParsePKIXPublicKey(tlog.PublicKey.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key of Rekor log %q in Sigstore trusted root: %w", tlog.BaseURL, err)
+		}
+		pkECDSA, ok := pk.(*ecdsa.PublicKey)
+		if !ok {
+			// Newer logs may use other key types; ignore them instead of refusing to use the trusted root at all.
+			log.Debugf("Ignoring Rekor log %q in Sigstore trusted root, its public key is not using ECDSA", tlog.BaseURL)
+			continue
+		}
+		validFrom, validUntil := tlog.PublicKey.ValidFor.bounds()
+		res.rekorPublicKeys = append(res.rekorPublicKeys, internal.RekorPublicKey{
+			Key:        pkECDSA,
+			ValidFrom:  validFrom,
+			ValidUntil: validUntil,
+		})
+	}
+	for _, ctlog := range root.Ctlogs {
+		pk, err := x509.ParsePKIXPublicKey(ctlog.PublicKey.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key of certificate transparency log %q in Sigstore trusted root: %w", ctlog.BaseURL, err)
+		}
+		switch pk.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey: // RFC 6962 only allows these.
+		default:
+			log.Debugf("Ignoring certificate transparency log %q in Sigstore trusted root, its public key is of an unsupported type %T", ctlog.BaseURL, pk)
+			continue
+		}
+		validFrom, validUntil := ctlog.PublicKey.ValidFor.bounds()
+		res.ctLogPublicKeys = append(res.ctLogPublicKeys, internal.CTLogPublicKey{
+			Key:        pk,
+			ValidFrom:  validFrom,
+			ValidUntil: validUntil,
+		})
+	}
+	for _, ca := range root.CertificateAuthorities {
+		certs := ca.CertChain.Certificates
+		if len(certs) == 0 {
+			return nil, fmt.Errorf("certificate authority %q in Sigstore trusted root has no certificates", ca.URI)
+		}
+		// The chain is ordered from the issuing CA to the root.
+		chain := make([]*x509.Certificate, 0, len(certs))
+		for _, c := range certs {
+			cert, err := x509.ParseCertificate(c.RawBytes)
+			if err != nil {
+				return nil, fmt.Errorf("parsing certificate of certificate authority %q in Sigstore trusted root: %w", ca.URI, err)
+			}
+			chain = append(chain, cert)
+		}
+		validFrom, validUntil := ca.ValidFor.bounds()
+		res.certificateAuthorities = append(res.certificateAuthorities, trustedRootCertificateAuthority{
+			root:          chain[len(chain)-1],
+			intermediates: chain[:len(chain)-1],
+			validFrom:     validFrom,
+			validUntil:    validUntil,
+		})
+	}
+	if len(res.rekorPublicKeys) == 0 {
+		return nil, errors.New("Sigstore trusted root does not contain any usable Rekor public keys")
+	}
+	return &res, nil
+}
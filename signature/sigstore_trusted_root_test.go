@@ -0,0 +1,133 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modifiedTrustedRoot returns the contents of fixtures/trusted_root.json, modified by modifyFn.
+func modifiedTrustedRoot(t *testing.T, modifyFn func(mSA)) []byte {
+	data, err := os.ReadFile("fixtures/trusted_root.json")
+	require.NoError(t, err)
+	var tmp mSA
+	err = json.Unmarshal(data, &tmp)
+	require.NoError(t, err)
+	modifyFn(tmp)
+	res, err := json.Marshal(tmp)
+	require.NoError(t, err)
+	return res
+}
+
+func TestParseSigstoreTrustedRoot(t *testing.T) {
+	rekorKeyPEM, err := os.ReadFile("fixtures/rekor.pub")
+	require.NoError(t, err)
+	rekorKey, err := cryptoutils.UnmarshalPEMToPublicKey(rekorKeyPEM)
+	require.NoError(t, err)
+	chainPEM, err := os.ReadFile("fixtures/fulcio-chain")
+	require.NoError(t, err)
+	chain, err := cryptoutils.UnmarshalCertificatesFromPEM(chainPEM)
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+
+	data, err := os.ReadFile("fixtures/trusted_root.json")
+	require.NoError(t, err)
+	res, err := parseSigstoreTrustedRoot(data)
+	require.NoError(t, err)
+	require.Len(t, res.rekorPublicKeys, 1)
+	assert.True(t, res.rekorPublicKeys[0].Key.Equal(rekorKey))
+	assert.Equal(t, time.Date(2021, 1, 12, 11, 53, 27, 0, time.UTC), res.rekorPublicKeys[0].ValidFrom.UTC())
+	assert.True(t, res.rekorPublicKeys[0].ValidUntil.IsZero())
+	require.Len(t, res.certificateAuthorities, 1)
+	ca := res.certificateAuthorities[0]
+	assert.True(t, ca.root.Equal(chain[1]))
+	assert.Equal(t, []*x509.Certificate{chain[0]}, ca.intermediates)
+	assert.Equal(t, time.Date(2022, 4, 13, 20, 6, 15, 0, time.UTC), ca.validFrom.UTC())
+	assert.True(t, ca.validUntil.IsZero())
+	require.Len(t, res.ctLogPublicKeys, 2)
+	assert.Equal(t, time.Date(2022, 10, 20, 0, 0, 0, 0, time.UTC), res.ctLogPublicKeys[1].ValidFrom.UTC())
+	assert.True(t, res.ctLogPublicKeys[1].ValidUntil.IsZero())
+
+	// Validity periods
+	assert.False(t, ca.validAt(time.Date(2022, 4, 13, 20, 6, 14, 0, time.UTC)))
+	assert.True(t, ca.validAt(time.Date(2022, 4, 13, 20, 6, 15, 0, time.UTC)))
+	assert.True(t, ca.validAt(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)))
+	ca.validUntil = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, ca.validAt(ca.validUntil))
+	assert.False(t, ca.validAt(ca.validUntil.Add(time.Second)))
+
+	// Log keys which are not ECDSA are ignored
+	rsaKeyPEM, err := os.ReadFile("fixtures/some-rsa-key.pub")
+	require.NoError(t, err)
+	rsaKey, err := cryptoutils.UnmarshalPEMToPublicKey(rsaKeyPEM)
+	require.NoError(t, err)
+	rsaKeyDER, err := x509.MarshalPKIXPublicKey(rsaKey)
+	require.NoError(t, err)
+	data = modifiedTrustedRoot(t, func(v mSA) {
+		tlogs := v["tlogs"].([]any)
+		v["tlogs"] = append(tlogs, mSA{
+			"baseUrl":   "https://rekor.example.com",
+			"publicKey": mSA{"rawBytes": rsaKeyDER},
+		})
+	})
+	res, err = parseSigstoreTrustedRoot(data)
+	require.NoError(t, err)
+	assert.Len(t, res.rekorPublicKeys, 1)
+	// … but RSA keys of certificate transparency logs are used, and other key types are ignored
+	ed25519KeyDER, err := x509.MarshalPKIXPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	require.NoError(t, err)
+	data = modifiedTrustedRoot(t, func(v mSA) {
+		ctlogs := v["ctlogs"].([]any)
+		v["ctlogs"] = append(ctlogs,
+			mSA{"baseUrl": "https://ct.example.com/rsa", "publicKey": mSA{"rawBytes": rsaKeyDER}},
+			mSA{"baseUrl": "https://ct.example.com/ed25519", "publicKey": mSA{"rawBytes": ed25519KeyDER}},
+		)
+	})
+	res, err = parseSigstoreTrustedRoot(data)
+	require.NoError(t, err)
+	require.Len(t, res.ctLogPublicKeys, 3)
+	assert.True(t, res.ctLogPublicKeys[2].Key.(*rsa.PublicKey).Equal(rsaKey))
+
+	// Failures
+	for _, modify := range []func(mSA){
+		// Missing or unexpected media type
+		func(v mSA) { delete(v, "mediaType") },
+		func(v mSA) { v["mediaType"] = "application/json" },
+		// Invalid Rekor log key
+		func(v mSA) { v["tlogs"].([]any)[0].(map[string]any)["publicKey"] = mSA{"rawBytes": []byte("invalid")} },
+		// No usable Rekor log keys
+		func(v mSA) { v["tlogs"] = []any{} },
+		func(v mSA) {
+			v["tlogs"] = []any{mSA{"publicKey": mSA{"rawBytes": rsaKeyDER}}}
+		},
+		// Invalid certificate transparency log key
+		func(v mSA) { v["ctlogs"].([]any)[0].(map[string]any)["publicKey"] = mSA{"rawBytes": []byte("invalid")} },
+		// Invalid CA certificate
+		func(v mSA) {
+			v["certificateAuthorities"].([]any)[0].(map[string]any)["certChain"] = mSA{
+				"certificates": []any{mSA{"rawBytes": []byte("invalid")}},
+			}
+		},
+		// CA without certificates
+		func(v mSA) {
+			v["certificateAuthorities"].([]any)[0].(map[string]any)["certChain"] = mSA{"certificates": []any{}}
+		},
+		// Invalid validity period
+		func(v mSA) {
+			v["certificateAuthorities"].([]any)[0].(map[string]any)["validFor"] = mSA{"start": "yesterday"}
+		},
+	} {
+		_, err := parseSigstoreTrustedRoot(modifiedTrustedRoot(t, modify))
+		assert.Error(t, err)
+	}
+	_, err = parseSigstoreTrustedRoot([]byte("this is invalid"))
+	assert.Error(t, err)
+}
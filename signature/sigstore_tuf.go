@@ -0,0 +1,648 @@
+// Fetching of Sigstore trusted roots from TUF repositories.
+
+package signature
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+	"github.com/containers/image/v5/internal/rootless"
+	"github.com/containers/image/v5/internal/set"
+	"github.com/containers/storage/pkg/ioutils"
+	"github.com/secure-systems-lab/go-securesystemslib/cjson"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+const (
+	// tufTrustedRootTarget is the name of the TUF target containing the Sigstore trusted root.
+	tufTrustedRootTarget = "trusted_root.json"
+	// tufCacheMaxAge is the age after which cached TUF metadata is refreshed from the mirror.
+	// Older cached metadata is still used if refreshing fails, as long as it has not expired.
+	tufCacheMaxAge = 24 * time.Hour
+	// maxTUFRootRotations is the maximum number of root metadata versions applied in a single update.
+	maxTUFRootRotations = 256
+	// maxTUFRootSize is the maximum allowed size of root metadata.
+	maxTUFRootSize = 512 * 1024
+	// maxTUFTimestampSize is the maximum allowed size of timestamp metadata.
+	maxTUFTimestampSize = 16 * 1024
+	// maxTUFFileSize is the maximum allowed size of other TUF files, if the referencing metadata does not specify a length.
+	maxTUFFileSize = 4 << 20
+)
+
+// tufHTTPClient is used to fetch TUF metadata and targets. Tests can replace it.
+var tufHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// tufCacheDir returns the directory used to cache data from TUF repositories, appropriate for euid.
+// This is a variable so that tests can replace it.
+var tufCacheDir = func(euid int) (string, error) {
+	return cacheDir(euid, "sigstore-tuf")
+}
+
+// errTUFFileNotFound is returned by tufRepository.fetch if the file does not exist on the mirror.
+var errTUFFileNotFound = errors.New("file not found")
+
+// tufEnvelopeJSON is the JSON representation of a signed TUF metadata file.
+type tufEnvelopeJSON struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []struct {
+		KeyID     string `json:"keyid"`
+		Signature string `json:"sig"`
+	} `json:"signatures"`
+}
+
+// tufCommonJSON contains the fields common to all TUF metadata types.
+type tufCommonJSON struct {
+	Type    string    `json:"_type"`
+	Version int64     `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+// common returns c; this allows accessing the common fields of any TUF metadata type.
+func (c *tufCommonJSON) common() *tufCommonJSON {
+	return c
+}
+
+// tufMetadata is implemented by all TUF metadata types.
+type tufMetadata interface {
+	common() *tufCommonJSON
+}
+
+// tufRootJSON is the JSON representation of TUF root metadata.
+type tufRootJSON struct {
+	tufCommonJSON
+	ConsistentSnapshot bool                   `json:"consistent_snapshot"`
+	Keys               map[string]tufKeyJSON  `json:"keys"`
+	Roles              map[string]tufRoleJSON `json:"roles"`
+}
+
+// tufKeyJSON is the JSON representation of a public key in TUF root metadata.
+type tufKeyJSON struct {
+	KeyType string `json:"keytype"`
+	Scheme  string `json:"scheme"`
+	KeyVal  struct {
+		Public string `json:"public"`
+	} `json:"keyval"`
+}
+
+// tufRoleJSON is the JSON representation of a role in TUF root metadata.
+type tufRoleJSON struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+// tufFileInfoJSON describes a metadata or target file referenced from other TUF metadata.
+type tufFileInfoJSON struct {
+	Version int64             `json:"version"` // Only for metadata files
+	Length  int64             `json:"length"`
+	Hashes  map[string]string `json:"hashes"`
+}
+
+// tufSnapshotJSON is the JSON representation of TUF timestamp or snapshot metadata.
+type tufSnapshotJSON struct {
+	tufCommonJSON
+	Meta map[string]tufFileInfoJSON `json:"meta"`
+}
+
+// tufTargetsJSON is the JSON representation of top-level TUF targets metadata.
+// Delegations are not supported.
+type tufTargetsJSON struct {
+	tufCommonJSON
+	Targets map[string]tufFileInfoJSON `json:"targets"`
+}
+
+// verify returns nil if signature is a valid signature of data by k.
+func (k *tufKeyJSON) verify(data, signature []byte) error {
+	switch k.KeyType {
+	case "ed25519":
+		pk, err := hex.DecodeString(k.KeyVal.Public)
+		if err != nil || len(pk) != ed25519.PublicKeySize {
+			return errors.New("invalid ed25519 public key")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(pk), data, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case "ecdsa", "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384":
+		pk, err := cryptoutils.UnmarshalPEMToPublicKey([]byte(k.KeyVal.Public))
+		if err != nil {
+			return fmt.Errorf("parsing public key: %w", err)
+		}
+		pkECDSA, ok := pk.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("public key is not using ECDSA")
+		}
+		var digest []byte
+		switch k.Scheme {
+		case "ecdsa-sha2-nistp256":
+			d := sha256.Sum256(data)
+			digest = d[:]
+		case "ecdsa-sha2-nistp384":
+			d := sha512.Sum384(data)
+			digest = d[:]
+		default:
+			return fmt.Errorf("unsupported signature scheme %q", k.Scheme)
+		}
+		if !ecdsa.VerifyASN1(pkECDSA, digest, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case "rsa":
+		if k.Scheme != "rsassa-pss-sha256" {
+			return fmt.Errorf("unsupported signature scheme %q", k.Scheme)
+		}
+		pk, err := cryptoutils.UnmarshalPEMToPublicKey([]byte(k.KeyVal.Public))
+		if err != nil {
+			return fmt.Errorf("parsing public key: %w", err)
+		}
+		pkRSA, ok := pk.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("public key is not using RSA")
+		}
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPSS(pkRSA, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	default:
+		return fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+// verifyTUFMetadata verifies that data is metadata of roleName signed by a threshold of keys trusted by root for that role,
+// and parses it into dest.
+// It does not check the version or expiration of the metadata.
+func verifyTUFMetadata(root *tufRootJSON, roleName string, data []byte, dest tufMetadata) error {
+	var envelope tufEnvelopeJSON
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("parsing TUF %s metadata: %w", roleName, err)
+	}
+	role, ok := root.Roles[roleName]
+	if !ok || role.Threshold < 1 {
+		return fmt.Errorf("TUF root metadata does not define a valid %s role", roleName)
+	}
+	canonical, err := cjson.EncodeCanonical(envelope.Signed)
+	if err != nil {
+		return fmt.Errorf("canonicalizing TUF %s metadata: %w", roleName, err)
+	}
+	// Count keys, not key IDs, so that a key listed under several IDs can’t be used to reach the threshold.
+	validKeys := set.New[string]()
+	for _, sig := range envelope.Signatures {
+		if !slices.Contains(role.KeyIDs, sig.KeyID) {
+			continue
+		}
+		key, ok := root.Keys[sig.KeyID]
+		if !ok {
+			continue
+		}
+		sigBytes, err := hex.DecodeString(sig.Signature)
+		if err != nil {
+			continue
+		}
+		if err := key.verify(canonical, sigBytes); err != nil {
+			log.Debugf("TUF %s metadata signature by key %s is not valid: %v", roleName, sig.KeyID, err)
+			continue
+		}
+		validKeys.Add(key.KeyType + "\x00" + key.KeyVal.Public)
+	}
+	if n := len(validKeys.Values()); n < role.Threshold {
+		return fmt.Errorf("TUF %s metadata has %d valid signatures, %d required", roleName, n, role.Threshold)
+	}
+
+	if err := json.Unmarshal(envelope.Signed, dest); err != nil {
+		return fmt.Errorf("parsing TUF %s metadata: %w", roleName, err)
+	}
+	if t := dest.common().Type; t != roleName {
+		return fmt.Errorf("TUF %s metadata has unexpected type %q", roleName, t)
+	}
+	return nil
+}
+
+// checkTUFMetadataExpiration returns an error if metadata of roleName has expired at now.
+func checkTUFMetadataExpiration(roleName string, metadata tufMetadata, now time.Time) error {
+	if expires := metadata.common().Expires; !now.Before(expires) {
+		return fmt.Errorf("TUF %s metadata expired at %s", roleName, expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// verifyTUFFileInfo returns an error if data does not match the length and hashes in info.
+// If requireHashes, info must contain at least one supported hash.
+func verifyTUFFileInfo(name string, data []byte, info tufFileInfoJSON, requireHashes bool) error {
+	if info.Length != 0 && int64(len(data)) != info.Length {
+		return fmt.Errorf("TUF file %s has length %d, expected %d", name, len(data), info.Length)
+	}
+	verified := false
+	for algorithm, newHash := range map[string]func() hash.Hash{"sha256": sha256.New, "sha512": sha512.New} {
+		expected, ok := info.Hashes[algorithm]
+		if !ok {
+			continue
+		}
+		h := newHash()
+		h.Write(data)
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(expected) {
+			return fmt.Errorf("TUF file %s has %s digest %s, expected %s", name, algorithm, actual, expected)
+		}
+		verified = true
+	}
+	if requireHashes && !verified {
+		return fmt.Errorf("TUF metadata does not contain a supported digest of %s", name)
+	}
+	return nil
+}
+
+// tufRepository reads a Sigstore trusted root from a TUF repository, and/or a local cache of its contents.
+// The cache uses the layout of go-tuf clients (e.g. cosign): metadata files in the top-level directory,
+// and target files in a "targets" subdirectory.
+type tufRepository struct {
+	mirror      string // Base URL of the remote repository, or "" to only use the cache.
+	initialRoot []byte // Trusted root metadata configured in the policy, or nil.
+	cacheDir    string // "" if the cache is not used.
+	now         time.Time
+}
+
+// newTUFRepository returns a tufRepository for tr.
+func (tr *prSigstoreSignedTrustRoot) newTUFRepository() (*tufRepository, error) {
+	initialRoot, err := loadBytesFromDataOrPath("root", tr.RootData, tr.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	res := &tufRepository{
+		mirror:      tr.Mirror,
+		initialRoot: initialRoot,
+		cacheDir:    tr.CachePath,
+		now:         time.Now(),
+	}
+	if res.cacheDir == "" { // Only possible if tr.Mirror is set
+		dir, err := tufCacheDir(rootless.GetRootlessEUID())
+		if err != nil {
+			log.Debugf("Error determining a location for the TUF cache, not caching TUF metadata: %v", err)
+		} else {
+			u, err := url.Parse(tr.Mirror)
+			if err != nil {
+				return nil, fmt.Errorf("parsing TUF mirror URL %q: %w", tr.Mirror, err)
+			}
+			res.cacheDir = filepath.Join(dir, url.PathEscape(u.Host+u.Path))
+		}
+	}
+	return res, nil
+}
+
+// loadTrustedRoot implements PRSigstoreSignedTrustRoot.
+func (tr *prSigstoreSignedTrustRoot) loadTrustedRoot(ctx context.Context) ([]byte, error) {
+	repo, err := tr.newTUFRepository()
+	if err != nil {
+		return nil, err
+	}
+	if repo.mirror == "" {
+		return repo.load(ctx, false)
+	}
+
+	if repo.cacheIsFresh() {
+		data, err := repo.load(ctx, false)
+		if err == nil {
+			return data, nil
+		}
+		log.Debugf("Error using cached TUF metadata, updating from %s: %v", repo.mirror, err)
+	}
+	data, err := repo.load(ctx, true)
+	if err != nil {
+		if cached, cacheErr := repo.load(ctx, false); cacheErr == nil {
+			log.Debugf("Error updating TUF metadata from %s, using cached metadata: %v", repo.mirror, err)
+			return cached, nil
+		}
+		return nil, fmt.Errorf("updating TUF metadata from %s: %w", repo.mirror, err)
+	}
+	return data, nil
+}
+
+// cacheIsFresh returns true if the cached TUF metadata was updated recently enough not to contact the mirror.
+func (r *tufRepository) cacheIsFresh() bool {
+	if r.cacheDir == "" {
+		return false
+	}
+	fi, err := os.Stat(filepath.Join(r.cacheDir, "timestamp.json"))
+	return err == nil && r.now.Sub(fi.ModTime()) < tufCacheMaxAge
+}
+
+// readCached returns the contents of the cached file at path (relative to the cache), or nil if it does not exist.
+func (r *tufRepository) readCached(path string) ([]byte, error) {
+	if r.cacheDir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(r.cacheDir, filepath.FromSlash(path)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeCached updates the cached files with the contents of files, a map from paths (relative to the cache) to data.
+// Errors are only logged, the cache is an optimization.
+func (r *tufRepository) writeCached(files map[string][]byte) {
+	if r.cacheDir == "" {
+		return
+	}
+	for path, data := range files {
+		fullPath := filepath.Join(r.cacheDir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o700); err != nil {
+			log.Debugf("Error creating TUF cache directory: %v", err)
+			return
+		}
+		if err := ioutils.AtomicWriteFile(fullPath, data, 0o600); err != nil {
+			log.Debugf("Error caching TUF file %s: %v", path, err)
+		}
+	}
+}
+
+// fetch fetches path (relative to the mirror URL), allowing at most maxSize bytes.
+// It returns errTUFFileNotFound if the file does not exist.
+func (r *tufRepository) fetch(ctx context.Context, path string, maxSize int64) ([]byte, error) {
+	u, err := url.JoinPath(r.mirror, path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tufHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden: // Some storage services return 403 for missing files.
+		return nil, fmt.Errorf("fetching %s: %w", u, errTUFFileNotFound)
+	default:
+		return nil, fmt.Errorf("fetching %s: %s", u, http.StatusText(res.StatusCode))
+	}
+	data, err := iolimits.ReadAtMost(res.Body, int(maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", u, err)
+	}
+	return data, nil
+}
+
+// trustedRootMetadata returns the root metadata configured in the policy, updated with any newer versions
+// in the cache which are signed according to the previous version, along with its raw contents.
+// The cached root.json is never used directly, so that whoever can write to the cache can’t replace the trust anchor.
+func (r *tufRepository) trustedRootMetadata() (*tufRootJSON, []byte, error) {
+	if r.initialRoot == nil {
+		return nil, nil, errors.New("no trusted TUF root metadata available")
+	}
+	// This metadata is trusted by configuration, not by a signature; at least ensure it is consistently self-signed.
+	var envelope tufEnvelopeJSON
+	if err := json.Unmarshal(r.initialRoot, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("parsing TUF root metadata: %w", err)
+	}
+	var unverified tufRootJSON
+	if err := json.Unmarshal(envelope.Signed, &unverified); err != nil {
+		return nil, nil, fmt.Errorf("parsing TUF root metadata: %w", err)
+	}
+	var root tufRootJSON
+	if err := verifyTUFMetadata(&unverified, "root", r.initialRoot, &root); err != nil {
+		return nil, nil, err
+	}
+	rootData := r.initialRoot
+	for i := 0; i < maxTUFRootRotations; i++ {
+		data, err := r.readCached(fmt.Sprintf("%d.root.json", root.Version+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if data == nil {
+			break
+		}
+		newRoot, err := verifyNextTUFRoot(&root, data)
+		if err != nil {
+			// Don’t fail, the mirror might provide a valid version.
+			log.Debugf("Ignoring cached TUF root metadata: %v", err)
+			break
+		}
+		root, rootData = *newRoot, data
+	}
+	return &root, rootData, nil
+}
+
+// verifyNextTUFRoot verifies that data is root metadata of version root.Version+1, signed according to both root and itself,
+// and returns the parsed metadata.
+func verifyNextTUFRoot(root *tufRootJSON, data []byte) (*tufRootJSON, error) {
+	nextVersion := root.Version + 1
+	// The new root metadata must be signed by both the old and the new keys.
+	var newRoot tufRootJSON
+	if err := verifyTUFMetadata(root, "root", data, &newRoot); err != nil {
+		return nil, fmt.Errorf("verifying TUF root metadata version %d: %w", nextVersion, err)
+	}
+	if err := verifyTUFMetadata(&newRoot, "root", data, &tufRootJSON{}); err != nil {
+		return nil, fmt.Errorf("verifying TUF root metadata version %d: %w", nextVersion, err)
+	}
+	if newRoot.Version != nextVersion {
+		return nil, fmt.Errorf("TUF root metadata version %d claims to be version %d", nextVersion, newRoot.Version)
+	}
+	return &newRoot, nil
+}
+
+// updateRoot applies root metadata updates from the mirror to root, and returns the new root metadata and its raw contents,
+// and a map from cache paths to contents of all root metadata versions that were applied.
+func (r *tufRepository) updateRoot(ctx context.Context, root *tufRootJSON, rootData []byte) (*tufRootJSON, []byte, map[string][]byte, error) {
+	updates := map[string][]byte{}
+	for i := 0; i < maxTUFRootRotations; i++ {
+		name := fmt.Sprintf("%d.root.json", root.Version+1)
+		data, err := r.fetch(ctx, name, maxTUFRootSize)
+		if err != nil {
+			if errors.Is(err, errTUFFileNotFound) {
+				return root, rootData, updates, nil
+			}
+			return nil, nil, nil, err
+		}
+		newRoot, err := verifyNextTUFRoot(root, data)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		root, rootData = newRoot, data
+		updates[name] = data
+	}
+	return nil, nil, nil, fmt.Errorf("more than %d TUF root metadata updates available", maxTUFRootRotations)
+}
+
+// load verifies the TUF metadata and returns the contents of the trusted root target.
+// If update, the metadata and the target are fetched from the mirror, and the cache is updated;
+// otherwise, only the cache is used.
+func (r *tufRepository) load(ctx context.Context, update bool) ([]byte, error) {
+	root, rootData, err := r.trustedRootMetadata()
+	if err != nil {
+		return nil, err
+	}
+	// get returns the contents of the metadata file for roleName, named by nameWithVersion on the mirror if using consistent snapshots.
+	get := func(roleName, nameWithVersion string, maxSize int64) ([]byte, error) {
+		name := roleName + ".json"
+		if !update {
+			data, err := r.readCached(name)
+			if err == nil && data == nil {
+				err = fmt.Errorf("no cached TUF %s metadata", roleName)
+			}
+			return data, err
+		}
+		if root.ConsistentSnapshot && nameWithVersion != "" {
+			name = nameWithVersion
+		}
+		return r.fetch(ctx, name, maxSize)
+	}
+	// checkRollback returns an error if the cached metadata for roleName has a newer version than the verified metadata.
+	checkRollback := func(roleName string, metadata tufMetadata) error {
+		if !update {
+			return nil
+		}
+		cachedData, err := r.readCached(roleName + ".json")
+		if err != nil || cachedData == nil {
+			return nil // Nothing to compare with
+		}
+		var cached tufCommonJSON
+		if err := verifyTUFMetadata(root, roleName, cachedData, &cached); err != nil {
+			return nil // The keys might have been rotated; don’t trust the cached version.
+		}
+		if v := metadata.common().Version; v < cached.Version {
+			return fmt.Errorf("TUF %s metadata version %d is older than the previously seen version %d", roleName, v, cached.Version)
+		}
+		return nil
+	}
+	// metadataFile returns the verified and parsed metadata for roleName, referenced by info, into dest, and its raw contents.
+	metadataFile := func(roleName string, info *tufFileInfoJSON, maxSize int64, dest tufMetadata) ([]byte, error) {
+		nameWithVersion := ""
+		if info != nil {
+			nameWithVersion = fmt.Sprintf("%d.%s.json", info.Version, roleName)
+			if info.Length != 0 {
+				maxSize = info.Length
+			}
+		}
+		data, err := get(roleName, nameWithVersion, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			if err := verifyTUFFileInfo(roleName+".json", data, *info, false); err != nil {
+				return nil, err
+			}
+		}
+		if err := verifyTUFMetadata(root, roleName, data, dest); err != nil {
+			return nil, err
+		}
+		if info != nil && dest.common().Version != info.Version {
+			return nil, fmt.Errorf("TUF %s metadata has version %d, expected %d", roleName, dest.common().Version, info.Version)
+		}
+		if err := checkRollback(roleName, dest); err != nil {
+			return nil, err
+		}
+		if err := checkTUFMetadataExpiration(roleName, dest, r.now); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	var rootUpdates map[string][]byte
+	if update {
+		root, rootData, rootUpdates, err = r.updateRoot(ctx, root, rootData)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := checkTUFMetadataExpiration("root", root, r.now); err != nil {
+		return nil, err
+	}
+	var timestamp tufSnapshotJSON
+	timestampData, err := metadataFile("timestamp", nil, maxTUFTimestampSize, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+	snapshotInfo, ok := timestamp.Meta["snapshot.json"]
+	if !ok {
+		return nil, errors.New("TUF timestamp metadata does not reference snapshot metadata")
+	}
+	var snapshot tufSnapshotJSON
+	snapshotData, err := metadataFile("snapshot", &snapshotInfo, maxTUFFileSize, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+	targetsInfo, ok := snapshot.Meta["targets.json"]
+	if !ok {
+		return nil, errors.New("TUF snapshot metadata does not reference targets metadata")
+	}
+	var targets tufTargetsJSON
+	targetsData, err := metadataFile("targets", &targetsInfo, maxTUFFileSize, &targets)
+	if err != nil {
+		return nil, err
+	}
+
+	targetInfo, ok := targets.Targets[tufTrustedRootTarget]
+	if !ok {
+		return nil, fmt.Errorf("TUF target %s not found", tufTrustedRootTarget)
+	}
+	targetPath := "targets/" + tufTrustedRootTarget
+	target, err := r.readCached(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil || verifyTUFFileInfo(tufTrustedRootTarget, target, targetInfo, true) != nil {
+		if !update {
+			return nil, fmt.Errorf("no valid cached TUF target %s", tufTrustedRootTarget)
+		}
+		name := tufTrustedRootTarget
+		if root.ConsistentSnapshot {
+			digest, ok := targetInfo.Hashes["sha256"]
+			if !ok {
+				digest, ok = targetInfo.Hashes["sha512"]
+			}
+			if !ok {
+				return nil, fmt.Errorf("TUF metadata does not contain a supported digest of %s", tufTrustedRootTarget)
+			}
+			name = digest + "." + tufTrustedRootTarget
+		}
+		maxSize := int64(maxTUFFileSize)
+		if targetInfo.Length != 0 {
+			maxSize = targetInfo.Length
+		}
+		target, err = r.fetch(ctx, "targets/"+name, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyTUFFileInfo(tufTrustedRootTarget, target, targetInfo, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if update {
+		// Only the versioned root metadata files are used to update the trusted root; root.json is written for other TUF clients.
+		files := map[string][]byte{
+			"root.json":      rootData,
+			"timestamp.json": timestampData,
+			"snapshot.json":  snapshotData,
+			"targets.json":   targetsData,
+			targetPath:       target,
+		}
+		for path, data := range rootUpdates {
+			files[path] = data
+		}
+		r.writeCached(files)
+	}
+	return target, nil
+}
@@ -0,0 +1,419 @@
+package signature
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/cjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tufTestKey is a TUF signing key.
+type tufTestKey struct {
+	id      string
+	public  tufKeyJSON
+	private ed25519.PrivateKey
+}
+
+func newTUFTestKey(t *testing.T) tufTestKey {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	public := tufKeyJSON{KeyType: "ed25519", Scheme: "ed25519"}
+	public.KeyVal.Public = hex.EncodeToString(pub)
+	id := sha256.Sum256(pub)
+	return tufTestKey{id: hex.EncodeToString(id[:]), public: public, private: priv}
+}
+
+// signTUFTestMetadata returns a TUF metadata file containing signed, signed by keys.
+func signTUFTestMetadata(t *testing.T, signed any, keys ...tufTestKey) []byte {
+	signedJSON, err := json.Marshal(signed)
+	require.NoError(t, err)
+	canonical, err := cjson.EncodeCanonical(json.RawMessage(signedJSON))
+	require.NoError(t, err)
+	signatures := []mSA{}
+	for _, k := range keys {
+		signatures = append(signatures, mSA{
+			"keyid": k.id,
+			"sig":   hex.EncodeToString(ed25519.Sign(k.private, canonical)),
+		})
+	}
+	res, err := json.Marshal(mSA{"signed": json.RawMessage(signedJSON), "signatures": signatures})
+	require.NoError(t, err)
+	return res
+}
+
+// tufTestFileInfo returns a tufFileInfoJSON describing data.
+func tufTestFileInfo(version int64, data []byte) tufFileInfoJSON {
+	sha256Digest := sha256.Sum256(data)
+	sha512Digest := sha512.Sum512(data)
+	return tufFileInfoJSON{
+		Version: version,
+		Length:  int64(len(data)),
+		Hashes: map[string]string{
+			"sha256": hex.EncodeToString(sha256Digest[:]),
+			"sha512": hex.EncodeToString(sha512Digest[:]),
+		},
+	}
+}
+
+// tufTestRepository is a TUF repository served over HTTP.
+type tufTestRepository struct {
+	t                                   *testing.T
+	rootKey, timestampKey, snapshotKey  tufTestKey
+	targetsKey                          tufTestKey
+	initialRoot                         []byte
+	files                               map[string][]byte // Paths on the mirror → contents
+	server                              *httptest.Server
+	rootVersion, version                int64
+	timestampExpires, otherRolesExpires time.Time
+}
+
+func newTUFTestRepository(t *testing.T, target []byte) *tufTestRepository {
+	r := &tufTestRepository{
+		t:                 t,
+		rootKey:           newTUFTestKey(t),
+		timestampKey:      newTUFTestKey(t),
+		snapshotKey:       newTUFTestKey(t),
+		targetsKey:        newTUFTestKey(t),
+		files:             map[string][]byte{},
+		timestampExpires:  time.Now().Add(24 * time.Hour),
+		otherRolesExpires: time.Now().Add(365 * 24 * time.Hour),
+	}
+	r.initialRoot = r.publishRoot(r.rootKey)
+	r.publish(target)
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, ok := r.files[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		_, err := w.Write(data)
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// rootMetadata returns root metadata using rootKey.
+func (r *tufTestRepository) rootMetadata(rootKey tufTestKey) tufRootJSON {
+	root := tufRootJSON{
+		tufCommonJSON: tufCommonJSON{
+			Type:    "root",
+			Version: r.rootVersion,
+			Expires: r.otherRolesExpires,
+		},
+		ConsistentSnapshot: true,
+		Keys:               map[string]tufKeyJSON{},
+		Roles:              map[string]tufRoleJSON{},
+	}
+	for role, key := range map[string]tufTestKey{
+		"root":      rootKey,
+		"timestamp": r.timestampKey,
+		"snapshot":  r.snapshotKey,
+		"targets":   r.targetsKey,
+	} {
+		root.Keys[key.id] = key.public
+		root.Roles[role] = tufRoleJSON{KeyIDs: []string{key.id}, Threshold: 1}
+	}
+	return root
+}
+
+// publishRoot publishes a new version of root metadata using rootKey, signed by signingKeys (or rootKey if not specified),
+// and returns its contents.
+func (r *tufTestRepository) publishRoot(rootKey tufTestKey, signingKeys ...tufTestKey) []byte {
+	if len(signingKeys) == 0 {
+		signingKeys = []tufTestKey{rootKey}
+	}
+	r.rootVersion++
+	data := signTUFTestMetadata(r.t, r.rootMetadata(rootKey), signingKeys...)
+	r.files[fmt.Sprintf("/%d.root.json", r.rootVersion)] = data
+	return data
+}
+
+// publish publishes a new version of the trusted root target, and of the targets, snapshot and timestamp metadata.
+func (r *tufTestRepository) publish(target []byte) {
+	r.version++
+	targetInfo := tufTestFileInfo(0, target)
+	r.files["/targets/"+targetInfo.Hashes["sha256"]+"."+tufTrustedRootTarget] = target
+	targets := signTUFTestMetadata(r.t, tufTargetsJSON{
+		tufCommonJSON: tufCommonJSON{Type: "targets", Version: r.version, Expires: r.otherRolesExpires},
+		Targets:       map[string]tufFileInfoJSON{tufTrustedRootTarget: targetInfo},
+	}, r.targetsKey)
+	r.files[fmt.Sprintf("/%d.targets.json", r.version)] = targets
+	snapshot := signTUFTestMetadata(r.t, tufSnapshotJSON{
+		tufCommonJSON: tufCommonJSON{Type: "snapshot", Version: r.version, Expires: r.otherRolesExpires},
+		Meta:          map[string]tufFileInfoJSON{"targets.json": {Version: r.version}},
+	}, r.snapshotKey)
+	r.files[fmt.Sprintf("/%d.snapshot.json", r.version)] = snapshot
+	r.files["/timestamp.json"] = signTUFTestMetadata(r.t, tufSnapshotJSON{
+		tufCommonJSON: tufCommonJSON{Type: "timestamp", Version: r.version, Expires: r.timestampExpires},
+		Meta:          map[string]tufFileInfoJSON{"snapshot.json": tufTestFileInfo(r.version, snapshot)},
+	}, r.timestampKey)
+}
+
+// trustRoot returns a prSigstoreSignedTrustRoot using r and cacheDir.
+func (r *tufTestRepository) trustRoot(cacheDir string) *prSigstoreSignedTrustRoot {
+	return &prSigstoreSignedTrustRoot{Mirror: r.server.URL, RootData: r.initialRoot, CachePath: cacheDir}
+}
+
+// makeTUFCacheStale marks the TUF metadata cached in cacheDir as old enough to be refreshed.
+func makeTUFCacheStale(t *testing.T, cacheDir string) {
+	old := time.Now().Add(-2 * tufCacheMaxAge)
+	err := os.Chtimes(filepath.Join(cacheDir, "timestamp.json"), old, old)
+	require.NoError(t, err)
+}
+
+func TestPRSigstoreSignedTrustRootLoadTrustedRoot(t *testing.T) {
+	ctx := context.Background()
+	target1 := []byte("trusted root 1")
+	target2 := []byte("trusted root 2")
+	repo := newTUFTestRepository(t, target1)
+	cacheDir := t.TempDir()
+
+	// Initial load, populating the cache
+	data, err := repo.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target1, data)
+	for _, file := range []string{"root.json", "timestamp.json", "snapshot.json", "targets.json", "targets/" + tufTrustedRootTarget} {
+		assert.FileExists(t, filepath.Join(cacheDir, file))
+	}
+
+	// Only the local cache
+	data, err = (&prSigstoreSignedTrustRoot{RootData: repo.initialRoot, CachePath: cacheDir}).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target1, data)
+	_, err = (&prSigstoreSignedTrustRoot{RootData: repo.initialRoot, CachePath: t.TempDir()}).loadTrustedRoot(ctx)
+	assert.Error(t, err)
+
+	// A fresh cache is used without contacting the mirror
+	repo.publish(target2)
+	data, err = repo.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target1, data)
+
+	// A stale cache is refreshed
+	makeTUFCacheStale(t, cacheDir)
+	data, err = repo.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target2, data)
+
+	// If the mirror is not available, a stale cache is used
+	makeTUFCacheStale(t, cacheDir)
+	repo.server.Close()
+	data, err = repo.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target2, data)
+	_, err = repo.trustRoot(t.TempDir()).loadTrustedRoot(ctx)
+	assert.Error(t, err)
+}
+
+func TestPRSigstoreSignedTrustRootDefaultCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	origTUFCacheDir := tufCacheDir
+	tufCacheDir = func(euid int) (string, error) { return dir, nil }
+	t.Cleanup(func() { tufCacheDir = origTUFCacheDir })
+
+	target := []byte("trusted root")
+	repo := newTUFTestRepository(t, target)
+	data, err := repo.trustRoot("").loadTrustedRoot(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, target, data)
+	u, err := url.Parse(repo.server.URL)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, url.PathEscape(u.Host), "targets", tufTrustedRootTarget))
+}
+
+func TestTUFRepositoryRootRotation(t *testing.T) {
+	ctx := context.Background()
+	target := []byte("trusted root")
+
+	// Valid rotation
+	repo := newTUFTestRepository(t, target)
+	newRootKey := newTUFTestKey(t)
+	repo.publishRoot(newRootKey, repo.rootKey, newRootKey)
+	repo.publishRoot(newRootKey)
+	cacheDir := t.TempDir()
+	data, err := repo.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target, data)
+	cachedRoot, err := os.ReadFile(filepath.Join(cacheDir, "root.json"))
+	require.NoError(t, err)
+	assert.Equal(t, repo.files["/3.root.json"], cachedRoot)
+	for _, file := range []string{"2.root.json", "3.root.json"} {
+		assert.FileExists(t, filepath.Join(cacheDir, file))
+	}
+	// The cached root metadata versions are applied when only using the cache
+	tufRepo, err := repo.trustRoot(cacheDir).newTUFRepository()
+	require.NoError(t, err)
+	root, _, err := tufRepo.trustedRootMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), root.Version)
+	data, err = (&prSigstoreSignedTrustRoot{RootData: repo.initialRoot, CachePath: cacheDir}).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target, data)
+
+	// New root not signed by the old key
+	repo = newTUFTestRepository(t, target)
+	repo.publishRoot(newRootKey)
+	tufRepo, err = repo.trustRoot(t.TempDir()).newTUFRepository()
+	require.NoError(t, err)
+	_, err = tufRepo.load(ctx, true)
+	assert.Error(t, err)
+
+	// New root not signed by the new key
+	repo = newTUFTestRepository(t, target)
+	repo.publishRoot(newRootKey, repo.rootKey)
+	tufRepo, err = repo.trustRoot(t.TempDir()).newTUFRepository()
+	require.NoError(t, err)
+	_, err = tufRepo.load(ctx, true)
+	assert.Error(t, err)
+}
+
+func TestTUFRepositoryUntrustedCachedRoot(t *testing.T) {
+	ctx := context.Background()
+	target := []byte("trusted root")
+	repo := newTUFTestRepository(t, target)
+
+	// Populate the cache with a different repository, with a newer self-signed root metadata version.
+	attackerTarget := []byte("attacker trusted root")
+	attacker := newTUFTestRepository(t, attackerTarget)
+	attacker.publishRoot(attacker.rootKey)
+	attacker.publishRoot(attacker.rootKey)
+	cacheDir := t.TempDir()
+	data, err := attacker.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, attackerTarget, data)
+
+	// The cached root metadata is not trusted instead of the configured one
+	tufRepo, err := repo.trustRoot(cacheDir).newTUFRepository()
+	require.NoError(t, err)
+	root, rootData, err := tufRepo.trustedRootMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), root.Version)
+	assert.Equal(t, repo.initialRoot, rootData)
+	_, err = (&prSigstoreSignedTrustRoot{RootData: repo.initialRoot, CachePath: cacheDir}).loadTrustedRoot(ctx)
+	assert.Error(t, err)
+
+	// Updating from the mirror replaces the cached data
+	makeTUFCacheStale(t, cacheDir)
+	data, err = repo.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, target, data)
+}
+
+func TestTUFRepositoryLoadFailures(t *testing.T) {
+	ctx := context.Background()
+	target := []byte("trusted root")
+
+	for _, c := range []struct {
+		name   string
+		modify func(repo *tufTestRepository)
+	}{
+		{
+			name: "tampered target",
+			modify: func(repo *tufTestRepository) {
+				for path := range repo.files {
+					if filepath.Dir(path) == "/targets" {
+						repo.files[path] = []byte("tampered")
+					}
+				}
+			},
+		},
+		{
+			name:   "missing timestamp",
+			modify: func(repo *tufTestRepository) { delete(repo.files, "/timestamp.json") },
+		},
+		{
+			name: "expired timestamp",
+			modify: func(repo *tufTestRepository) {
+				repo.timestampExpires = time.Now().Add(-time.Hour)
+				repo.publish(target)
+			},
+		},
+		{
+			name: "timestamp signed by a wrong key",
+			modify: func(repo *tufTestRepository) {
+				repo.timestampKey = repo.snapshotKey
+				repo.publish(target)
+			},
+		},
+		{
+			name: "tampered snapshot",
+			modify: func(repo *tufTestRepository) {
+				path := fmt.Sprintf("/%d.snapshot.json", repo.version)
+				repo.files[path] = append(repo.files[path], ' ')
+			},
+		},
+		{
+			name: "target missing from targets metadata",
+			modify: func(repo *tufTestRepository) {
+				repo.files[fmt.Sprintf("/%d.targets.json", repo.version)] = signTUFTestMetadata(t, tufTargetsJSON{
+					tufCommonJSON: tufCommonJSON{Type: "targets", Version: repo.version, Expires: repo.otherRolesExpires},
+				}, repo.targetsKey)
+			},
+		},
+	} {
+		repo := newTUFTestRepository(t, target)
+		c.modify(repo)
+		tufRepo, err := repo.trustRoot(t.TempDir()).newTUFRepository()
+		require.NoError(t, err, c.name)
+		_, err = tufRepo.load(ctx, true)
+		assert.Error(t, err, c.name)
+	}
+
+	// Rollback of the timestamp
+	repo := newTUFTestRepository(t, target)
+	cacheDir := t.TempDir()
+	oldTimestamp := repo.files["/timestamp.json"]
+	repo.publish(target)
+	_, err := repo.trustRoot(cacheDir).loadTrustedRoot(ctx)
+	require.NoError(t, err)
+	repo.files["/timestamp.json"] = oldTimestamp
+	tufRepo, err := repo.trustRoot(cacheDir).newTUFRepository()
+	require.NoError(t, err)
+	_, err = tufRepo.load(ctx, true)
+	assert.ErrorContains(t, err, "older than the previously seen version")
+}
+
+func TestPRSigstoreSignedPrepareTrustRootUsingTUF(t *testing.T) {
+	trustedRootData, err := os.ReadFile("fixtures/trusted_root.json")
+	require.NoError(t, err)
+	expected, err := parseSigstoreTrustedRoot(trustedRootData)
+	require.NoError(t, err)
+
+	repo := newTUFTestRepository(t, trustedRootData)
+	pr, err := newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
+		PRSigstoreSignedWithTrustRoot(repo.trustRoot(t.TempDir())),
+		PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+	)
+	require.NoError(t, err)
+	res, err := pr.prepareTrustRoot(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected.rekorPublicKeys, res.rekorPublicKeys)
+
+	// Failures to load the trusted root are reported
+	repo.server.Close()
+	pr, err = newPRSigstoreSigned(
+		PRSigstoreSignedWithKeyPath("fixtures/cosign.pub"),
+		PRSigstoreSignedWithTrustRoot(repo.trustRoot(t.TempDir())),
+		PRSigstoreSignedWithSignedIdentity(NewPRMMatchRepoDigestOrExact()),
+	)
+	require.NoError(t, err)
+	_, err = pr.prepareTrustRoot(context.Background())
+	assert.Error(t, err)
+}
@@ -15,22 +15,24 @@ import (
 
 // simpleSigner is a signer.SignerImplementation implementation for simple signing signatures.
 type simpleSigner struct {
-	mech           signature.SigningMechanism
-	keyFingerprint string
-	passphrase     string // "" if not provided.
+	mech            signature.SigningMechanism
+	keyFingerprints []string
+	passphrase      string // "" if not provided.
 }
 
 type Option func(*simpleSigner) error
 
 // WithKeyFingerprint returns an Option for NewSigner, specifying a key to sign with, using the provided GPG key fingerprint.
+// The option can be used more than once; copy.Image then creates one signature for each of the keys.
 func WithKeyFingerprint(keyFingerprint string) Option {
 	return func(s *simpleSigner) error {
-		s.keyFingerprint = keyFingerprint
+		s.keyFingerprints = append(s.keyFingerprints, keyFingerprint)
 		return nil
 	}
 }
 
 // WithPassphrase returns an Option for NewSigner, specifying a passphrase for the private key.
+// If more than one key is used, the passphrase is used for all of them.
 // If this is not specified, the system may interactively prompt using a gpg-agent / pinentry.
 func WithPassphrase(passphrase string) Option {
 	return func(s *simpleSigner) error {
@@ -46,7 +48,7 @@ func WithPassphrase(passphrase string) Option {
 // NewSigner returns a signature.Signer which creates “simple signing” signatures using the user’s default
 // GPG configuration ($GNUPGHOME / ~/.gnupg).
 //
-// The set of options must identify a key to sign with, probably using a WithKeyFingerprint.
+// The set of options must identify at least one key to sign with, probably using a WithKeyFingerprint.
 //
 // The caller must call Close() on the returned Signer.
 func NewSigner(opts ...Option) (*signer.Signer, error) {
@@ -72,9 +74,14 @@ func NewSigner(opts ...Option) (*signer.Signer, error) {
 			return nil, err
 		}
 	}
-	if s.keyFingerprint == "" {
+	if len(s.keyFingerprints) == 0 {
 		return nil, errors.New("no key identity provided for simple signing")
 	}
+	for _, fingerprint := range s.keyFingerprints {
+		if fingerprint == "" {
+			return nil, errors.New("empty key identity provided for simple signing")
+		}
+	}
 	// Ideally, we should look up (and unlock?) the key at this point already, but our current SigningMechanism API does not allow that.
 
 	succeeded = true
@@ -86,12 +93,33 @@ func (s *simpleSigner) ProgressMessage() string {
 	return "Signing image using simple signing"
 }
 
-// SignImageManifest creates a new signature for manifest m as dockerReference.
+// SignImageManifest creates a new signature for manifest m as dockerReference, using the first configured key.
 func (s *simpleSigner) SignImageManifest(ctx context.Context, m []byte, dockerReference reference.Named) (internalSig.Signature, error) {
+	return s.signImageManifestWithKey(m, dockerReference, s.keyFingerprints[0])
+}
+
+// SignImageManifestWithAllKeys creates new signatures for manifest m as dockerReference, one for each configured key.
+func (s *simpleSigner) SignImageManifestWithAllKeys(ctx context.Context, m []byte, dockerReference reference.Named) ([]internalSig.Signature, error) {
+	res := make([]internalSig.Signature, 0, len(s.keyFingerprints))
+	for _, keyFingerprint := range s.keyFingerprints {
+		sig, err := s.signImageManifestWithKey(m, dockerReference, keyFingerprint)
+		if err != nil {
+			if len(s.keyFingerprints) == 1 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("signing with key %s: %w", keyFingerprint, err)
+		}
+		res = append(res, sig)
+	}
+	return res, nil
+}
+
+// signImageManifestWithKey creates a new signature for manifest m as dockerReference, using keyFingerprint.
+func (s *simpleSigner) signImageManifestWithKey(m []byte, dockerReference reference.Named, keyFingerprint string) (internalSig.Signature, error) {
 	if reference.IsNameOnly(dockerReference) {
 		return nil, fmt.Errorf("reference %s can’t be signed, it has neither a tag nor a digest", dockerReference.String())
 	}
-	simpleSig, err := signature.SignDockerManifestWithOptions(m, dockerReference.String(), s.mech, s.keyFingerprint, &signature.SignOptions{
+	simpleSig, err := signature.SignDockerManifestWithOptions(m, dockerReference.String(), s.mech, keyFingerprint, &signature.SignOptions{
 		Passphrase: s.passphrase,
 	})
 	if err != nil {
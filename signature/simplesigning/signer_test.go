@@ -233,3 +233,42 @@ func TestSimpleSignerSignImageManifest(t *testing.T) {
 		testFailure(c)
 	}
 }
+
+func TestSimpleSignerSignImageManifestWithAllKeys(t *testing.T) {
+	t.Setenv("GNUPGHOME", testGPGHomeDirectory)
+
+	mech, err := signature.NewGPGSigningMechanism()
+	require.NoError(t, err)
+	defer mech.Close()
+	if err := mech.SupportsSigning(); err != nil {
+		t.Skipf("Signing not supported: %v", err)
+	}
+
+	manifest, err := os.ReadFile("../fixtures/image.manifest.json")
+	require.NoError(t, err)
+	testImageSignatureReference, err := reference.ParseNormalizedNamed("example.com/testing/manifest:notlatest")
+	require.NoError(t, err)
+
+	// Empty key fingerprint
+	_, err = NewSigner(WithKeyFingerprint(testKeyFingerprint), WithKeyFingerprint(""))
+	assert.Error(t, err)
+
+	s, err := NewSigner(WithKeyFingerprint(testKeyFingerprint), WithKeyFingerprint(testKeyFingerprintWithPassphrase),
+		WithPassphrase(testPassphrase))
+	require.NoError(t, err)
+	defer s.Close()
+	sigs, err := internalSigner.SignImageManifestWithAllKeys(context.Background(), s, manifest, testImageSignatureReference)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	for _, sig := range sigs {
+		_, ok := sig.(internalSig.SimpleSigning)
+		assert.True(t, ok)
+	}
+
+	// One of the keys fails
+	s2, err := NewSigner(WithKeyFingerprint(testKeyFingerprint), WithKeyFingerprint("this fingerprint doesn't exist"))
+	require.NoError(t, err)
+	defer s2.Close()
+	_, err = internalSigner.SignImageManifestWithAllKeys(context.Background(), s2, manifest, testImageSignatureReference)
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+//go:build !containers_image_storage_stub
+// +build !containers_image_storage_stub
+
+package storage
+
+import (
+	"io"
+	"time"
+
+	"github.com/containers/image/v5/types"
+)
+
+// SetLayerApplyProgress asks the destination to report progress of applying layers,
+// using types.ProgressEventApply* events, by calling report; ProgressEventApplyRead events are sent about once per interval.
+// report may be called from any of the destination’s methods, including Commit, until the destination is closed.
+// It must be called before any blobs are written to the destination.
+func (s *storageImageDestination) SetLayerApplyProgress(report func(types.ProgressProperties), interval time.Duration) {
+	s.applyProgress = report
+	s.applyProgressInterval = interval
+}
+
+// layerApplyProgress reports progress of applying a single layer.
+// All of its methods do nothing if progress reporting was not requested by SetLayerApplyProgress.
+type layerApplyProgress struct {
+	report       func(types.ProgressProperties) // nil if progress is not being reported
+	interval     time.Duration
+	artifact     types.BlobInfo
+	lastUpdate   time.Time
+	offset       uint64
+	offsetUpdate uint64
+}
+
+// startLayerApply reports that applying the layer blob has started, and returns an object for reporting further progress.
+func (s *storageImageDestination) startLayerApply(blob types.BlobInfo) *layerApplyProgress {
+	p := &layerApplyProgress{
+		report:     s.applyProgress,
+		interval:   s.applyProgressInterval,
+		artifact:   blob,
+		lastUpdate: time.Now(),
+	}
+	p.send(types.ProgressEventApplyStarted)
+	return p
+}
+
+// send reports event with the current offset.
+func (p *layerApplyProgress) send(event types.ProgressEvent) {
+	if p.report == nil {
+		return
+	}
+	p.report(types.ProgressProperties{
+		Event:        event,
+		Artifact:     p.artifact,
+		Offset:       p.offset,
+		OffsetUpdate: p.offsetUpdate,
+	})
+}
+
+// done reports that the layer has been applied.
+func (p *layerApplyProgress) done() {
+	p.send(types.ProgressEventApplyDone)
+}
+
+// reader returns a reader for source which reports the amount of data consumed.
+func (p *layerApplyProgress) reader(source io.Reader) io.Reader {
+	if p.report == nil {
+		return source
+	}
+	return &layerApplyProgressReader{source: source, progress: p}
+}
+
+// layerApplyProgressReader is an io.Reader which reports data consumed from source as ProgressEventApplyRead events.
+type layerApplyProgressReader struct {
+	source   io.Reader
+	progress *layerApplyProgress
+}
+
+func (r *layerApplyProgressReader) Read(b []byte) (int, error) {
+	n, err := r.source.Read(b)
+	p := r.progress
+	p.offset += uint64(n)
+	p.offsetUpdate += uint64(n)
+	if time.Since(p.lastUpdate) > p.interval {
+		p.send(types.ProgressEventApplyRead)
+		p.lastUpdate = time.Now()
+		p.offsetUpdate = 0
+	}
+	return n, err
+}
@@ -15,10 +15,12 @@ import (
 	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/internal/imagedestination/impl"
 	"github.com/containers/image/v5/internal/imagedestination/stubs"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/private"
 	"github.com/containers/image/v5/internal/putblobdigest"
 	"github.com/containers/image/v5/internal/set"
@@ -34,7 +36,6 @@ import (
 	"github.com/containers/storage/pkg/ioutils"
 	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -55,6 +56,7 @@ type storageImageDestination struct {
 	stubs.AlwaysSupportsSignatures
 
 	imageRef              storageReference
+	sys                   *types.SystemContext
 	directory             string                   // Temporary directory where we store blobs until Commit() time
 	nextTempFileID        atomic.Int32             // A counter that we use for computing filenames to assign to blobs
 	manifest              []byte                   // Manifest contents, temporary
@@ -71,6 +73,10 @@ type storageImageDestination struct {
 	// guarantee is implemented.
 	indexToStorageID map[int]string
 
+	// Set by SetLayerApplyProgress before any concurrent use, read-only afterwards.
+	applyProgress         func(types.ProgressProperties) // nil if layer apply progress is not reported
+	applyProgressInterval time.Duration
+
 	// A storage destination may be used concurrently, due to HasThreadSafePutBlob.
 	lock          sync.Mutex // Protects lockProtected
 	lockProtected storageImageDestinationLockProtected
@@ -137,6 +143,7 @@ func newImageDestination(sys *types.SystemContext, imageRef storageReference) (*
 		}),
 
 		imageRef:     imageRef,
+		sys:          sys,
 		directory:    directory,
 		signatureses: make(map[digest.Digest][]byte),
 		metadata: storageImageMetadata{
@@ -176,6 +183,12 @@ func (s *storageImageDestination) Close() error {
 	return os.RemoveAll(s.directory)
 }
 
+// CheckStagingSpace returns a types.InsufficientSpaceError if s.sys requests free space checks, and the temporary directory
+// does not have space for storing blobs with a total size of size bytes until Commit.
+func (s *storageImageDestination) CheckStagingSpace(ctx context.Context, size int64) error {
+	return tmpdir.CheckFreeSpace(s.sys, s.directory, size)
+}
+
 func (s *storageImageDestination) computeNextBlobCacheFile() string {
 	return filepath.Join(s.directory, fmt.Sprintf("%d", s.nextTempFileID.Add(1)))
 }
@@ -532,7 +545,7 @@ func (s *storageImageDestination) computeID(m manifest.Manifest) string {
 			diffID, ok := s.lockProtected.blobDiffIDs[blobSum]
 			if !ok {
 				// this can, in principle, legitimately happen when a layer is reused by TOC.
-				logrus.Infof("error looking up diffID for layer %q", blobSum.String())
+				log.Infof("error looking up diffID for layer %q", blobSum.String())
 				return ""
 			}
 			diffIDs = append([]digest.Digest{diffID}, diffIDs...)
@@ -580,7 +593,7 @@ func (s *storageImageDestination) computeID(m manifest.Manifest) string {
 	// ordinaryImageID is a digest of a config, which is a JSON value.
 	// To avoid the risk of collisions, start the input with @ so that the input is not a valid JSON.
 	tocImageID := digest.FromString("@With TOC:" + tocIDInput).Encoded()
-	logrus.Debugf("Ordinary storage image ID %s; a layer was looked up by TOC, so using image ID %s", ordinaryImageID, tocImageID)
+	log.Debugf("Ordinary storage image ID %s; a layer was looked up by TOC, so using image ID %s", ordinaryImageID, tocImageID)
 	return tocImageID
 }
 
@@ -724,7 +737,7 @@ func (s *storageImageDestination) commitLayer(index int, info addedLayerInfo, si
 		// We are also ignoring lookups by TOC, and other non-trivial situations.
 		// Those can only happen using the c/image/internal/private API,
 		// so those internal callers should be fixed to follow the API instead of expanding this fallback.
-		logrus.Debugf("looking for diffID for blob=%+v", info.digest)
+		log.Debugf("looking for diffID for blob=%+v", info.digest)
 
 		// Use tryReusingBlobAsPending, not the top-level TryReusingBlobWithOptions, to prevent recursion via queueOrCommit.
 		has, _, err := s.tryReusingBlobAsPending(info.digest, size, &private.TryReusingBlobOptions{
@@ -779,7 +792,7 @@ func (s *storageImageDestination) createNewLayer(index int, layerDigest digest.D
 				return nil, err
 			}
 			if d == "" {
-				logrus.Debugf("Skipping commit for layer %q, manifest not yet available", newLayerID)
+				log.Debugf("Skipping commit for layer %q, manifest not yet available", newLayerID)
 				return nil, nil
 			}
 
@@ -794,9 +807,10 @@ func (s *storageImageDestination) createNewLayer(index int, layerDigest digest.D
 		flags := make(map[string]interface{})
 		if untrustedUncompressedDigest != "" {
 			flags[expectedLayerDiffIDFlag] = untrustedUncompressedDigest
-			logrus.Debugf("Setting uncompressed digest to %q for layer %q", untrustedUncompressedDigest, newLayerID)
+			log.Debugf("Setting uncompressed digest to %q for layer %q", untrustedUncompressedDigest, newLayerID)
 		}
 
+		progress := s.startLayerApply(types.BlobInfo{Digest: layerDigest, Size: -1})
 		args := storage.ApplyStagedLayerOptions{
 			ID:          newLayerID,
 			ParentLayer: parentLayer,
@@ -810,6 +824,7 @@ func (s *storageImageDestination) createNewLayer(index int, layerDigest digest.D
 		if err != nil && !errors.Is(err, storage.ErrDuplicateID) {
 			return nil, fmt.Errorf("failed to put layer using a partial pull: %w", err)
 		}
+		progress.done()
 		return layer, nil
 	}
 
@@ -817,10 +832,12 @@ func (s *storageImageDestination) createNewLayer(index int, layerDigest digest.D
 	al, ok := s.lockProtected.indexToAdditionalLayer[index]
 	s.lock.Unlock()
 	if ok {
+		progress := s.startLayerApply(types.BlobInfo{Digest: layerDigest, Size: -1})
 		layer, err := al.PutAs(newLayerID, parentLayer, nil)
 		if err != nil && !errors.Is(err, storage.ErrDuplicateID) {
 			return nil, fmt.Errorf("failed to put layer from digest and labels: %w", err)
 		}
+		progress.done()
 		return layer, nil
 	}
 
@@ -931,15 +948,21 @@ func (s *storageImageDestination) createNewLayer(index int, layerDigest digest.D
 		return nil, fmt.Errorf("opening file %q: %w", filename, err)
 	}
 	defer file.Close()
+	fileSize := int64(-1)
+	if fi, err := file.Stat(); err == nil {
+		fileSize = fi.Size()
+	}
+	progress := s.startLayerApply(types.BlobInfo{Digest: layerDigest, Size: fileSize})
 	// Build the new layer using the diff, regardless of where it came from.
 	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
 	layer, _, err := s.imageRef.transport.store.PutLayer(newLayerID, parentLayer, nil, "", false, &storage.LayerOptions{
 		OriginalDigest:     trustedOriginalDigest,
 		UncompressedDigest: trustedUncompressedDigest,
-	}, file)
+	}, progress.reader(file))
 	if err != nil && !errors.Is(err, storage.ErrDuplicateID) {
 		return nil, fmt.Errorf("adding layer with blob %q: %w", layerDigest, err)
 	}
+	progress.done()
 	return layer, nil
 }
 
@@ -1059,7 +1082,7 @@ func (s *storageImageDestination) Commit(ctx context.Context, unparsedToplevel t
 	// was originally created, in case we're just copying it.  If not, no harm done.
 	options := &storage.ImageOptions{}
 	if inspect, err := man.Inspect(s.getConfigBlob); err == nil && inspect.Created != nil {
-		logrus.Debugf("setting image creation date to %s", inspect.Created)
+		log.DebugfContext(ctx, "setting image creation date to %s", inspect.Created)
 		options.CreationDate = *inspect.Created
 	}
 
@@ -1155,7 +1178,7 @@ func (s *storageImageDestination) Commit(ctx context.Context, unparsedToplevel t
 	img, err := s.imageRef.transport.store.CreateImage(intendedID, nil, lastLayer, "", options)
 	if err != nil {
 		if !errors.Is(err, storage.ErrDuplicateID) {
-			logrus.Debugf("error creating image: %q", err)
+			log.DebugfContext(ctx, "error creating image: %q", err)
 			return fmt.Errorf("creating image %q: %w", intendedID, err)
 		}
 		img, err = s.imageRef.transport.store.Image(intendedID)
@@ -1163,10 +1186,10 @@ func (s *storageImageDestination) Commit(ctx context.Context, unparsedToplevel t
 			return fmt.Errorf("reading image %q: %w", intendedID, err)
 		}
 		if img.TopLayer != lastLayer {
-			logrus.Debugf("error creating image: image with ID %q exists, but uses different layers", intendedID)
+			log.DebugfContext(ctx, "error creating image: image with ID %q exists, but uses different layers", intendedID)
 			return fmt.Errorf("image with ID %q already exists, but uses a different top layer: %w", intendedID, storage.ErrDuplicateID)
 		}
-		logrus.Debugf("reusing image ID %q", img.ID)
+		log.DebugfContext(ctx, "reusing image ID %q", img.ID)
 		oldNames = append(oldNames, img.Names...)
 		// set the data items and metadata on the already-present image
 		// FIXME: this _replaces_ any "signatures" blobs and their
@@ -1175,19 +1198,19 @@ func (s *storageImageDestination) Commit(ctx context.Context, unparsedToplevel t
 		// to merge them since they all apply to the same image
 		for _, data := range options.BigData {
 			if err := s.imageRef.transport.store.SetImageBigData(img.ID, data.Key, data.Data, manifest.Digest); err != nil {
-				logrus.Debugf("error saving big data %q for image %q: %v", data.Key, img.ID, err)
+				log.DebugfContext(ctx, "error saving big data %q for image %q: %v", data.Key, img.ID, err)
 				return fmt.Errorf("saving big data %q for image %q: %w", data.Key, img.ID, err)
 			}
 		}
 		if options.Metadata != "" {
 			if err := s.imageRef.transport.store.SetMetadata(img.ID, options.Metadata); err != nil {
-				logrus.Debugf("error saving metadata for image %q: %v", img.ID, err)
+				log.DebugfContext(ctx, "error saving metadata for image %q: %v", img.ID, err)
 				return fmt.Errorf("saving metadata for image %q: %w", img.ID, err)
 			}
-			logrus.Debugf("saved image metadata %q", options.Metadata)
+			log.DebugfContext(ctx, "saved image metadata %q", options.Metadata)
 		}
 	} else {
-		logrus.Debugf("created new image ID %q with metadata %q", img.ID, options.Metadata)
+		log.DebugfContext(ctx, "created new image ID %q with metadata %q", img.ID, options.Metadata)
 	}
 
 	// Clean up the unfinished image on any error.
@@ -1195,9 +1218,9 @@ func (s *storageImageDestination) Commit(ctx context.Context, unparsedToplevel t
 	commitSucceeded := false
 	defer func() {
 		if !commitSucceeded {
-			logrus.Errorf("Updating image %q (old names %v) failed, deleting it", img.ID, oldNames)
+			log.ErrorfContext(ctx, "Updating image %q (old names %v) failed, deleting it", img.ID, oldNames)
 			if _, err := s.imageRef.transport.store.DeleteImage(img.ID, true); err != nil {
-				logrus.Errorf("Error deleting incomplete image %q: %v", img.ID, err)
+				log.ErrorfContext(ctx, "Error deleting incomplete image %q: %v", img.ID, err)
 			}
 		}
 	}()
@@ -1208,7 +1231,7 @@ func (s *storageImageDestination) Commit(ctx context.Context, unparsedToplevel t
 		if err := s.imageRef.transport.store.AddNames(img.ID, []string{name.String()}); err != nil {
 			return fmt.Errorf("adding names %v to image %q: %w", name, img.ID, err)
 		}
-		logrus.Debugf("added name %q to image %q", name, img.ID)
+		log.DebugfContext(ctx, "added name %q to image %q", name, img.ID)
 	}
 
 	commitSucceeded = true
@@ -10,12 +10,12 @@ import (
 	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage"
 	digest "github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 // A storageReference holds an arbitrary name and/or an ID, which is a 32-byte
@@ -152,7 +152,7 @@ func (s *storageReference) resolveImage(sys *types.SystemContext) (*storage.Imag
 		}
 	}
 	if s.id == "" {
-		logrus.Debugf("reference %q does not resolve to an image ID", s.StringWithinTransport())
+		log.Debugf("reference %q does not resolve to an image ID", s.StringWithinTransport())
 		return nil, fmt.Errorf("reference %q does not resolve to an image ID: %w", s.StringWithinTransport(), ErrNoSuchImage)
 	}
 	if loadedImage == nil {
@@ -164,7 +164,7 @@ func (s *storageReference) resolveImage(sys *types.SystemContext) (*storage.Imag
 	}
 	if s.named != nil {
 		if !imageMatchesRepo(loadedImage, s.named) {
-			logrus.Errorf("no image matching reference %q found", s.StringWithinTransport())
+			log.Errorf("no image matching reference %q found", s.StringWithinTransport())
 			return nil, ErrNoSuchImage
 		}
 	}
@@ -277,9 +277,9 @@ func (s storageReference) DeleteImage(ctx context.Context, sys *types.SystemCont
 	}
 	layers, err := s.transport.store.DeleteImage(img.ID, true)
 	if err == nil {
-		logrus.Debugf("deleted image %q", img.ID)
+		log.DebugfContext(ctx, "deleted image %q", img.ID)
 		for _, layer := range layers {
-			logrus.Debugf("deleted layer %q", layer)
+			log.DebugfContext(ctx, "deleted layer %q", layer)
 		}
 	}
 	return err
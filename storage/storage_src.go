@@ -17,6 +17,7 @@ import (
 	"github.com/containers/image/v5/internal/image"
 	"github.com/containers/image/v5/internal/imagesource/impl"
 	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/internal/signature"
 	"github.com/containers/image/v5/internal/tmpdir"
 	"github.com/containers/image/v5/manifest"
@@ -26,7 +27,6 @@ import (
 	"github.com/containers/storage/pkg/ioutils"
 	digest "github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
 )
 
 type storageImageSource struct {
@@ -146,7 +146,7 @@ func (s *storageImageSource) GetBlob(ctx context.Context, info types.BlobInfo, c
 			return nil, 0, err
 		}
 		r := bytes.NewReader(b)
-		logrus.Debugf("exporting opaque data as blob %q", digest.String())
+		log.DebugfContext(ctx, "exporting opaque data as blob %q", digest.String())
 		return io.NopCloser(r), int64(r.Len()), nil
 	}
 
@@ -225,7 +225,7 @@ func (s *storageImageSource) getBlobAndLayerID(digest digest.Digest, layers []st
 	} else {
 		n = layer.UncompressedSize
 	}
-	logrus.Debugf("exporting filesystem layer %q without compression for blob %q", layer.ID, digest)
+	log.Debugf("exporting filesystem layer %q without compression for blob %q", layer.ID, digest)
 	rc, err = s.imageRef.transport.store.Diff("", layer.ID, diffOptions)
 	if err != nil {
 		return nil, -1, "", err
@@ -17,6 +17,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -650,6 +651,60 @@ func TestSize(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestLayerApplyProgress(t *testing.T) {
+	ensureTestCanCreateImages(t)
+
+	newStore(t)
+	cache := memory.New()
+
+	layer := makeLayer(t, archive.Gzip)
+	configBytes := []byte(`{"config":{"labels":{}},"created":"2006-01-02T15:04:05Z"}`)
+	config := testBlob{
+		compressedDigest: digest.SHA256.FromBytes(configBytes),
+		uncompressedSize: int64(len(configBytes)),
+		compressedSize:   int64(len(configBytes)),
+		data:             configBytes,
+	}
+
+	ref, err := Transport.ParseReference("test")
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(context.Background(), nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	reporter, ok := dest.(private.LayerApplyProgressReporter)
+	require.True(t, ok)
+	var events []types.ProgressProperties
+	var eventsLock sync.Mutex
+	reporter.SetLayerApplyProgress(func(p types.ProgressProperties) {
+		eventsLock.Lock()
+		defer eventsLock.Unlock()
+		events = append(events, p)
+	}, 0)
+
+	desc := layer.storeBlob(t, dest, cache, manifest.DockerV2Schema2LayerMediaType)
+	configDescriptor := config.storeBlob(t, dest, cache, manifest.DockerV2Schema2ConfigMediaType)
+	man := manifest.Schema2FromComponents(configDescriptor, []manifest.Schema2Descriptor{desc})
+	manifestBytes, err := man.Serialize()
+	require.NoError(t, err)
+	err = dest.PutManifest(context.Background(), manifestBytes, nil)
+	require.NoError(t, err)
+	err = dest.Commit(context.Background(), &unparsedImage{manifestBytes: manifestBytes, manifestType: man.MediaType})
+	require.NoError(t, err)
+
+	require.GreaterOrEqual(t, len(events), 3)
+	first, last := events[0], events[len(events)-1]
+	assert.Equal(t, types.ProgressEventApplyStarted, first.Event)
+	assert.Equal(t, types.ProgressEventApplyDone, last.Event)
+	for _, e := range events {
+		assert.Equal(t, layer.compressedDigest, e.Artifact.Digest)
+		assert.Equal(t, layer.compressedSize, e.Artifact.Size)
+	}
+	for _, e := range events[1 : len(events)-1] {
+		assert.Equal(t, types.ProgressEventApplyRead, e.Event)
+	}
+	assert.Equal(t, uint64(layer.compressedSize), last.Offset)
+}
+
 func TestDuplicateBlob(t *testing.T) {
 	ensureTestCanCreateImages(t)
 
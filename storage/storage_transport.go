@@ -10,12 +10,12 @@ import (
 	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/log"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
 	"github.com/containers/storage"
 	"github.com/containers/storage/pkg/idtools"
 	digest "github.com/opencontainers/go-digest"
-	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -199,7 +199,7 @@ func (s storageTransport) ParseStoreReference(store storage.Store, ref string) (
 	if err != nil {
 		return nil, err
 	}
-	logrus.Debugf("parsed reference into %q", result.StringWithinTransport())
+	log.Debugf("parsed reference into %q", result.StringWithinTransport())
 	return result, nil
 }
 
@@ -1,6 +1,12 @@
 // Package tarball provides a way to generate images using one or more layer
 // tarballs and an optional template configuration.
 //
+// It can also write the root filesystem of an image, with all layers applied,
+// into a single tarball (compressed if the file name ends with .gz, .xz or .zst),
+// for use by tools which consume plain root filesystem archives.
+// The image configuration and annotations are then written alongside, as a
+// JSON-encoded Metadata object in a file with MetadataSuffix appended to the name.
+//
 // An example:
 //
 //	package main
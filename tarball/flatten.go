@@ -0,0 +1,182 @@
+package tarball
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/containers/image/v5/pkg/compression"
+)
+
+const (
+	// whiteoutPrefix marks a path deleted in a layer, see the OCI image specification.
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaqueDir marks a directory whose contents from lower layers are hidden.
+	whiteoutOpaqueDir = ".wh..wh..opq"
+)
+
+// layerTarReader returns a tar reader for the (possibly compressed) layer at path,
+// and a function which must be called to release its resources.
+func layerTarReader(path string) (*tar.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	uncompressed, _, err := compression.AutoDecompress(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(uncompressed), func() {
+		uncompressed.Close()
+		f.Close()
+	}, nil
+}
+
+// cleanLayerPath returns the canonical form of a path in a layer tarball, without any leading or trailing slashes.
+// The root directory is represented as "".
+func cleanLayerPath(p string) string {
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+// layerMasks tracks, while processing layers from the topmost one, which paths are hidden from lower layers.
+type layerMasks struct {
+	present map[string]struct{} // Paths which exist in an upper layer, and which override the same path in lower layers.
+	deleted map[string]struct{} // Paths whose whole subtree is hidden from lower layers: whiteouts, and non-directories.
+	opaque  map[string]struct{} // Directories whose contents are hidden from lower layers.
+}
+
+// hides returns true if the path p, as a member of a lower layer, is hidden by the layers processed so far.
+func (m *layerMasks) hides(p string) bool {
+	if _, ok := m.present[p]; ok {
+		return true
+	}
+	for ancestor := p; ; {
+		if _, ok := m.deleted[ancestor]; ok {
+			return true
+		}
+		if ancestor == "" {
+			return false
+		}
+		ancestor = path.Dir(ancestor)
+		if ancestor == "." {
+			ancestor = ""
+		}
+		if _, ok := m.opaque[ancestor]; ok {
+			return true
+		}
+	}
+}
+
+// visibleLayerEntries returns, for each of the layers (from the lowest one), a slice recording whether each tar entry in that layer
+// is visible in the flattened filesystem.
+func visibleLayerEntries(layerPaths []string) ([][]bool, error) {
+	masks := layerMasks{
+		present: map[string]struct{}{},
+		deleted: map[string]struct{}{},
+		opaque:  map[string]struct{}{},
+	}
+	res := make([][]bool, len(layerPaths))
+	for i := len(layerPaths) - 1; i >= 0; i-- {
+		visible, err := func() ([]bool, error) { // A scope for defer
+			tr, closeLayer, err := layerTarReader(layerPaths[i])
+			if err != nil {
+				return nil, err
+			}
+			defer closeLayer()
+
+			visible := []bool{}
+			// Changes to the masks only apply to lower layers, so collect them and apply them at the end.
+			layerPresent, layerDeleted, layerOpaque := []string{}, []string{}, []string{}
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				p := cleanLayerPath(hdr.Name)
+				dir, base := path.Split(p)
+				dir = strings.TrimSuffix(dir, "/")
+				switch {
+				case base == whiteoutOpaqueDir:
+					visible = append(visible, false)
+					layerOpaque = append(layerOpaque, dir)
+				case strings.HasPrefix(base, whiteoutPrefix):
+					visible = append(visible, false)
+					layerDeleted = append(layerDeleted, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+				default:
+					visible = append(visible, !masks.hides(p))
+					layerPresent = append(layerPresent, p)
+					if hdr.Typeflag != tar.TypeDir {
+						layerDeleted = append(layerDeleted, p)
+					}
+				}
+			}
+			for _, p := range layerPresent {
+				masks.present[p] = struct{}{}
+			}
+			for _, p := range layerDeleted {
+				masks.deleted[p] = struct{}{}
+			}
+			for _, p := range layerOpaque {
+				masks.opaque[p] = struct{}{}
+			}
+			return visible, nil
+		}()
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %d: %w", i, err)
+		}
+		res[i] = visible
+	}
+	return res, nil
+}
+
+// flattenLayers writes a single tar stream to dest, containing the filesystem created by applying the layers at layerPaths,
+// from the lowest one, and processing whiteouts.
+func flattenLayers(dest io.Writer, layerPaths []string) error {
+	visible, err := visibleLayerEntries(layerPaths)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(dest)
+	for i, layerPath := range layerPaths {
+		if err := func() error { // A scope for defer
+			tr, closeLayer, err := layerTarReader(layerPath)
+			if err != nil {
+				return err
+			}
+			defer closeLayer()
+			for entry := 0; ; entry++ {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if entry >= len(visible[i]) {
+					return fmt.Errorf("layer %s changed while being read", layerPath)
+				}
+				if !visible[i][entry] {
+					continue
+				}
+				if err := tw.WriteHeader(hdr); err != nil {
+					return err
+				}
+				if _, err := io.Copy(tw, tr); err != nil {
+					return err
+				}
+			}
+			return nil
+		}(); err != nil {
+			return fmt.Errorf("copying layer %d: %w", i, err)
+		}
+	}
+	return tw.Close()
+}
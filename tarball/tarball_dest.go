@@ -0,0 +1,333 @@
+package tarball
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/containers/image/v5/internal/imagedestination/impl"
+	"github.com/containers/image/v5/internal/imagedestination/stubs"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/putblobdigest"
+	"github.com/containers/image/v5/internal/tmpdir"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// MetadataSuffix is appended to the path of a root filesystem tarball written by the "tarball:" transport
+// to form the path of its metadata file, which contains a JSON-encoded Metadata object.
+const MetadataSuffix = ".json"
+
+// Metadata describes an image written as a root filesystem tarball by the "tarball:" transport.
+type Metadata struct {
+	// ManifestDigest is the digest of the manifest of the written image.
+	ManifestDigest digest.Digest `json:"manifestDigest"`
+	// Config is the image configuration, including e.g. the environment and the command to run.
+	Config imgspecv1.Image `json:"config"`
+	// Annotations are the annotations of the image manifest, if any.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type tarballImageDestination struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	stubs.NoPutBlobPartialInitialize
+	stubs.NoSignaturesInitialize
+
+	reference   tarballReference
+	path        string
+	compression *compression.Algorithm // nil if the tarball should not be compressed
+	workDir     string
+	manifest    []byte
+}
+
+// compressionForPath returns the compression algorithm to use for a tarball at path, based on its extension,
+// or nil if the tarball should not be compressed.
+func compressionForPath(path string) *compression.Algorithm {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".gz", ".tgz":
+		return &compression.Gzip
+	case ".xz", ".txz":
+		return &compression.Xz
+	case ".zst", ".tzst":
+		return &compression.Zstd
+	default:
+		return nil
+	}
+}
+
+// NewImageDestination returns a types.ImageDestination which writes the root filesystem of the image
+// to a single tarball, compressed if the file name ends with .gz, .xz or .zst,
+// and the image metadata to a file with the same name and MetadataSuffix appended.
+// The caller must call .Close() on the returned ImageDestination.
+func (r *tarballReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	if len(r.filenames) != 1 {
+		return nil, fmt.Errorf(`"tarball:" destinations must refer to exactly one file, not %d`, len(r.filenames))
+	}
+	path := r.filenames[0]
+	if path == "-" {
+		return nil, errors.New(`writing to standard output is not supported by the "tarball:" transport`)
+	}
+	workDir, err := tmpdir.MkDirBigFileTemp(sys, "tarball")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp directory: %w", err)
+	}
+	d := &tarballImageDestination{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			SupportedManifestMIMETypes: []string{imgspecv1.MediaTypeImageManifest, manifest.DockerV2Schema2MediaType},
+			// The layers are extracted and recompressed as a whole, so ask for them uncompressed.
+			DesiredLayerCompression:        types.Decompress,
+			AcceptsForeignLayerURLs:        false,
+			MustMatchRuntimeOS:             false,
+			IgnoresEmbeddedDockerReference: true, // The tarball does not record any image name.
+			HasThreadSafePutBlob:           true,
+		}),
+		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(r),
+		NoSignaturesInitialize:     stubs.NoSignatures(`Storing signatures is not supported by the "tarball:" transport`),
+
+		reference:   *r,
+		path:        path,
+		compression: compressionForPath(path),
+		workDir:     workDir,
+	}
+	d.Compat = impl.AddCompat(d)
+	return d, nil
+}
+
+// Reference returns the reference used to set up this destination.  Note that this should directly correspond to user's intent,
+// e.g. it should use the public hostname instead of the result of resolving CNAMEs or following redirects.
+func (d *tarballImageDestination) Reference() types.ImageReference {
+	return &d.reference
+}
+
+// Close removes resources associated with an initialized ImageDestination, if any.
+func (d *tarballImageDestination) Close() error {
+	return os.RemoveAll(d.workDir)
+}
+
+// blobPath returns a path for storing a blob with blobDigest in d.workDir.
+func (d *tarballImageDestination) blobPath(blobDigest digest.Digest) (string, error) {
+	if err := blobDigest.Validate(); err != nil { // Make sure blobDigest.Encoded() is safe to use as a file name
+		return "", err
+	}
+	return filepath.Join(d.workDir, blobDigest.Algorithm().String()+"-"+blobDigest.Encoded()), nil
+}
+
+// PutBlobWithOptions writes contents of stream and returns data representing the result.
+// inputInfo.Digest can be optionally provided if known; if provided, and stream is read to the end without error, the digest MUST match the stream contents.
+// inputInfo.Size is the expected length of stream, if known.
+// inputInfo.MediaType describes the blob format, if known.
+// WARNING: The contents of stream are being verified on the fly.  Until stream.Read() returns io.EOF, the contents of the data SHOULD NOT be available
+// to any other readers for download using the supplied digest.
+// If stream.Read() at any time, ESPECIALLY at end of input, returns an error, PutBlobWithOptions MUST 1) fail, and 2) delete any data stored so far.
+func (d *tarballImageDestination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	blobFile, err := os.CreateTemp(d.workDir, "put-blob")
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	succeeded := false
+	explicitClosed := false
+	defer func() {
+		if !explicitClosed {
+			blobFile.Close()
+		}
+		if !succeeded {
+			os.Remove(blobFile.Name())
+		}
+	}()
+
+	digester, stream := putblobdigest.DigestIfCanonicalUnknown(stream, inputInfo)
+	// TODO: This can take quite some time, and should ideally be cancellable using ctx.Done().
+	size, err := io.Copy(blobFile, stream)
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	blobDigest := digester.Digest()
+	if inputInfo.Size != -1 && size != inputInfo.Size {
+		return private.UploadedBlob{}, fmt.Errorf("Size mismatch when copying %s, expected %d, got %d", blobDigest, inputInfo.Size, size)
+	}
+	blobPath, err := d.blobPath(blobDigest)
+	if err != nil {
+		return private.UploadedBlob{}, err
+	}
+	blobFile.Close()
+	explicitClosed = true
+	if err := os.Rename(blobFile.Name(), blobPath); err != nil {
+		return private.UploadedBlob{}, err
+	}
+	succeeded = true
+	return private.UploadedBlob{Digest: blobDigest, Size: size}, nil
+}
+
+// TryReusingBlobWithOptions checks whether the transport already contains, or can efficiently reuse, a blob, and if so, applies it to the current destination
+// (e.g. if the blob is a filesystem layer, this signifies that the changes it describes need to be applied again when composing a filesystem tree).
+// info.Digest must not be empty.
+// If the blob has been successfully reused, returns (true, info, nil).
+// If the transport can not reuse the requested blob, TryReusingBlob returns (false, {}, nil); it returns a non-nil error only on an unexpected failure.
+func (d *tarballImageDestination) TryReusingBlobWithOptions(ctx context.Context, info types.BlobInfo, options private.TryReusingBlobOptions) (bool, private.ReusedBlob, error) {
+	if !impl.OriginalCandidateMatchesTryReusingBlobOptions(options) {
+		return false, private.ReusedBlob{}, nil
+	}
+	if info.Digest == "" {
+		return false, private.ReusedBlob{}, errors.New("Can not check for a blob with unknown digest")
+	}
+	blobPath, err := d.blobPath(info.Digest)
+	if err != nil {
+		return false, private.ReusedBlob{}, err
+	}
+	finfo, err := os.Stat(blobPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, private.ReusedBlob{}, nil
+		}
+		return false, private.ReusedBlob{}, err
+	}
+	return true, private.ReusedBlob{Digest: info.Digest, Size: finfo.Size()}, nil
+}
+
+// PutManifest writes manifest to the destination.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to write the manifest for
+// (when the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
+// It is expected but not enforced that the instanceDigest, when specified, matches the digest of `manifest` as generated
+// by `manifest.Digest()`.
+// FIXME? This should also receive a MIME type if known, to differentiate between schema versions.
+// If the destination is in principle available, refuses this manifest type (e.g. it does not recognize the schema),
+// but may accept a different manifest type, the returned error must be an ManifestTypeRejectedError.
+func (d *tarballImageDestination) PutManifest(ctx context.Context, m []byte, instanceDigest *digest.Digest) error {
+	if instanceDigest != nil {
+		return fmt.Errorf("manifest lists are not supported by the %q transport", transportName)
+	}
+	mimeType := manifest.GuessMIMEType(m)
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		return fmt.Errorf("manifest lists are not supported by the %q transport", transportName)
+	}
+	if mimeType != imgspecv1.MediaTypeImageManifest && mimeType != manifest.DockerV2Schema2MediaType {
+		return types.ManifestTypeRejectedError{Err: fmt.Errorf("manifest type %q is not supported by the %q transport", mimeType, transportName)}
+	}
+	d.manifest = m
+	return nil
+}
+
+// Commit marks the process of storing the image as successful and asks for the image to be persisted.
+// unparsedToplevel contains data about the top-level manifest of the source (which may be a single-arch image or a manifest list
+// if PutManifest was only called for the single-arch image with instanceDigest == nil), primarily to allow lookups by the
+// original manifest list digest, if desired.
+// WARNING: This does not have any transactional semantics:
+// - Uploaded data MAY be visible to others before Commit() is called
+// - Uploaded data MAY be removed or MAY remain around if Close() is called without Commit() (i.e. rollback is allowed but not guaranteed)
+func (d *tarballImageDestination) Commit(ctx context.Context, unparsedToplevel types.UnparsedImage) error {
+	if d.manifest == nil {
+		return fmt.Errorf("no manifest was written to the %q destination", transportName)
+	}
+	mimeType := manifest.GuessMIMEType(d.manifest)
+	m, err := manifest.FromBlob(d.manifest, mimeType)
+	if err != nil {
+		return fmt.Errorf("parsing manifest: %w", err)
+	}
+	manifestDigest, err := manifest.Digest(d.manifest)
+	if err != nil {
+		return err
+	}
+	metadata := Metadata{ManifestDigest: manifestDigest}
+	if mimeType == imgspecv1.MediaTypeImageManifest {
+		ociManifest, err := manifest.OCI1FromManifest(d.manifest)
+		if err != nil {
+			return err
+		}
+		metadata.Annotations = ociManifest.Annotations
+	}
+	configPath, err := d.blobPath(m.ConfigInfo().Digest)
+	if err != nil {
+		return err
+	}
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("reading image config: %w", err)
+	}
+	// Docker schema2 configs use the same field names for all of the data in imgspecv1.Image.
+	if err := json.Unmarshal(configBytes, &metadata.Config); err != nil {
+		return fmt.Errorf("parsing image config: %w", err)
+	}
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	layerPaths := []string{}
+	for _, layer := range m.LayerInfos() {
+		if layer.EmptyLayer {
+			continue
+		}
+		p, err := d.blobPath(layer.Digest)
+		if err != nil {
+			return err
+		}
+		layerPaths = append(layerPaths, p)
+	}
+
+	if err := writeFileAtomically(d.path, func(w io.Writer) error {
+		if d.compression == nil {
+			return flattenLayers(w, layerPaths)
+		}
+		compressor, err := compression.CompressStream(w, *d.compression, nil)
+		if err != nil {
+			return err
+		}
+		if err := flattenLayers(compressor, layerPaths); err != nil {
+			compressor.Close()
+			return err
+		}
+		return compressor.Close()
+	}); err != nil {
+		return fmt.Errorf("writing root filesystem tarball %q: %w", d.path, err)
+	}
+	if err := writeFileAtomically(d.path+MetadataSuffix, func(w io.Writer) error {
+		_, err := w.Write(metadataBytes)
+		return err
+	}); err != nil {
+		return fmt.Errorf("writing metadata %q: %w", d.path+MetadataSuffix, err)
+	}
+	return nil
+}
+
+// writeFileAtomically creates path with contents written by writeContents, so that it is either complete or not modified at all.
+func writeFileAtomically(path string, writeContents func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tarball-commit")
+	if err != nil {
+		return err
+	}
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err := writeContents(f); err != nil {
+		return err
+	}
+	// On POSIX systems, f was created with mode 0600, so we need to make it readable.
+	// On Windows, f is already readable, and f.Chmod always fails.
+	if runtime.GOOS != "windows" {
+		if err := f.Chmod(0644); err != nil {
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	succeeded = true
+	return nil
+}
@@ -0,0 +1,236 @@
+package tarball
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/internal/blobinfocache"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
+	digest "github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ private.ImageDestination = (*tarballImageDestination)(nil)
+
+// testTarEntry is a simplified tar entry used in tests: a directory if name ends with "/", a regular file otherwise.
+type testTarEntry struct {
+	name     string
+	contents string
+}
+
+// makeTestTar returns a tar archive containing entries.
+func makeTestTar(t *testing.T, entries []testTarEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.contents)), Typeflag: tar.TypeReg}
+		if e.name[len(e.name)-1] == '/' {
+			hdr.Mode = 0755
+			hdr.Typeflag = tar.TypeDir
+		}
+		err := tw.WriteHeader(hdr)
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(e.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// readTestTar returns the entries of the tar archive in r.
+func readTestTar(t *testing.T, r io.Reader) []testTarEntry {
+	res := []testTarEntry{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		res = append(res, testTarEntry{name: hdr.Name, contents: string(contents)})
+	}
+	return res
+}
+
+func TestCleanLayerPath(t *testing.T) {
+	for _, c := range []struct{ input, expected string }{
+		{"", ""},
+		{"/", ""},
+		{"./", ""},
+		{"a", "a"},
+		{"./a/b/", "a/b"},
+		{"/a//b/../c", "a/c"},
+	} {
+		assert.Equal(t, c.expected, cleanLayerPath(c.input), c.input)
+	}
+}
+
+func TestFlattenLayers(t *testing.T) {
+	layers := [][]testTarEntry{
+		{
+			{"./", ""},
+			{"etc/", ""},
+			{"etc/hosts", "lower hosts"},
+			{"etc/passwd", "root"},
+			{"deleted/", ""},
+			{"deleted/file", "gone"},
+			{"opaque/", ""},
+			{"opaque/lower", "hidden"},
+			{"replaced/", ""},
+			{"replaced/child", "hidden too"},
+		},
+		{
+			{"etc/", ""},
+			{"etc/hosts", "upper hosts"},
+			{".wh.deleted", ""},
+			{"opaque/", ""},
+			{"opaque/.wh..wh..opq", ""},
+			{"opaque/upper", "visible"},
+			{"replaced", "now a file"},
+			{"etc/.wh.passwd", ""},
+		},
+		{
+			{"deleted/", ""},
+			{"deleted/new", "recreated"},
+		},
+	}
+	dir := t.TempDir()
+	layerPaths := []string{}
+	for i, layer := range layers {
+		p := filepath.Join(dir, string(rune('a'+i)))
+		err := os.WriteFile(p, makeTestTar(t, layer), 0600)
+		require.NoError(t, err)
+		layerPaths = append(layerPaths, p)
+	}
+
+	var buf bytes.Buffer
+	err := flattenLayers(&buf, layerPaths)
+	require.NoError(t, err)
+	assert.Equal(t, []testTarEntry{
+		{"./", ""},
+		{"etc/", ""},
+		{"etc/hosts", "upper hosts"},
+		{"opaque/", ""},
+		{"opaque/upper", "visible"},
+		{"replaced", "now a file"},
+		{"deleted/", ""},
+		{"deleted/new", "recreated"},
+	}, readTestTar(t, &buf))
+}
+
+func TestCompressionForPath(t *testing.T) {
+	for _, c := range []struct {
+		path     string
+		expected *compression.Algorithm
+	}{
+		{"rootfs.tar", nil},
+		{"rootfs", nil},
+		{"rootfs.tar.gz", &compression.Gzip},
+		{"rootfs.TGZ", &compression.Gzip},
+		{"rootfs.tar.xz", &compression.Xz},
+		{"rootfs.tar.zst", &compression.Zstd},
+	} {
+		res := compressionForPath(c.path)
+		if c.expected == nil {
+			assert.Nil(t, res, c.path)
+		} else {
+			require.NotNil(t, res, c.path)
+			assert.Equal(t, c.expected.Name(), res.Name(), c.path)
+		}
+	}
+}
+
+func TestTarballDestination(t *testing.T) {
+	ctx := context.Background()
+	cache := blobinfocache.FromBlobInfoCache(memory.New())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rootfs.tar.gz")
+
+	ref, err := Transport.ParseReference(path) // The file does not exist yet
+	require.NoError(t, err)
+	dest, err := ref.NewImageDestination(ctx, nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	d, ok := dest.(*tarballImageDestination)
+	require.True(t, ok)
+
+	putBlob := func(blob []byte, isConfig bool) imgspecv1.Descriptor {
+		info, err := d.PutBlobWithOptions(ctx, bytes.NewReader(blob), types.BlobInfo{Size: -1}, private.PutBlobOptions{Cache: cache, IsConfig: isConfig})
+		require.NoError(t, err)
+		assert.Equal(t, digest.FromBytes(blob), info.Digest)
+		return imgspecv1.Descriptor{Digest: info.Digest, Size: info.Size}
+	}
+
+	config := imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: "amd64", OS: "linux"},
+		Config:   imgspecv1.ImageConfig{Env: []string{"A=B"}, Cmd: []string{"/bin/sh"}},
+	}
+	configBytes, err := json.Marshal(config)
+	require.NoError(t, err)
+	configDesc := putBlob(configBytes, true)
+	configDesc.MediaType = imgspecv1.MediaTypeImageConfig
+	layer1 := putBlob(makeTestTar(t, []testTarEntry{{"a", "1"}, {"b", "2"}}), false)
+	layer1.MediaType = imgspecv1.MediaTypeImageLayer
+	layer2 := putBlob(makeTestTar(t, []testTarEntry{{".wh.a", ""}, {"c", "3"}}), false)
+	layer2.MediaType = imgspecv1.MediaTypeImageLayer
+
+	reused, _, err := d.TryReusingBlobWithOptions(ctx, types.BlobInfo{Digest: layer1.Digest, Size: -1}, private.TryReusingBlobOptions{Cache: cache})
+	require.NoError(t, err)
+	assert.True(t, reused)
+
+	manifestBytes, err := json.Marshal(imgspecv1.Manifest{
+		Versioned:   imgspecs.Versioned{SchemaVersion: 2},
+		MediaType:   imgspecv1.MediaTypeImageManifest,
+		Config:      configDesc,
+		Layers:      []imgspecv1.Descriptor{layer1, layer2},
+		Annotations: map[string]string{"key": "value"},
+	})
+	require.NoError(t, err)
+	err = d.PutManifest(ctx, manifestBytes, nil)
+	require.NoError(t, err)
+	err = d.Commit(ctx, nil)
+	require.NoError(t, err)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	uncompressed, err := compression.GzipDecompressor(f)
+	require.NoError(t, err)
+	defer uncompressed.Close()
+	assert.Equal(t, []testTarEntry{{"b", "2"}, {"c", "3"}}, readTestTar(t, uncompressed))
+
+	metadataBytes, err := os.ReadFile(path + MetadataSuffix)
+	require.NoError(t, err)
+	var metadata Metadata
+	err = json.Unmarshal(metadataBytes, &metadata)
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromBytes(manifestBytes), metadata.ManifestDigest)
+	assert.Equal(t, config.Config, metadata.Config.Config)
+	assert.Equal(t, map[string]string{"key": "value"}, metadata.Annotations)
+}
+
+func TestTarballDestinationInvalidReferences(t *testing.T) {
+	for _, filenames := range [][]string{
+		{"a", "b"},
+		{"-"},
+	} {
+		ref, err := NewReference(filenames, nil)
+		require.NoError(t, err)
+		_, err = ref.NewImageDestination(context.Background(), nil)
+		assert.Error(t, err, filenames)
+	}
+}
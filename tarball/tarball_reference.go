@@ -76,7 +76,3 @@ func (r *tarballReference) DeleteImage(ctx context.Context, sys *types.SystemCon
 	}
 	return nil
 }
-
-func (r *tarballReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
-	return nil, fmt.Errorf(`"tarball:" locations can only be read from, not written to`)
-}
@@ -44,6 +44,10 @@ func (t *tarballTransport) ParseReference(reference string) (types.ImageReferenc
 		}
 		f, err := os.Open(filename)
 		if err != nil {
+			// A missing file is acceptable as a destination; NewImageSource will fail for it.
+			if os.IsNotExist(err) {
+				continue
+			}
 			return nil, fmt.Errorf("error opening %q: %v", filename, err)
 		}
 		f.Close()
@@ -8,13 +8,17 @@ import (
 	"github.com/containers/image/v5/types"
 
 	// Register all known transports.
-	// NOTE: Make sure docs/containers-transports.5.md and docs/containers-policy.json.5.md are updated when adding or updating
-	// a transport.
+	// NOTE: Make sure docs/containers-transports.5.md, docs/containers-policy.json.5.md and transportCapabilities are updated
+	// when adding or updating a transport.
+	_ "github.com/containers/image/v5/cache"
 	_ "github.com/containers/image/v5/directory"
 	_ "github.com/containers/image/v5/docker"
 	_ "github.com/containers/image/v5/docker/archive"
+	_ "github.com/containers/image/v5/memory"
 	_ "github.com/containers/image/v5/oci/archive"
+	_ "github.com/containers/image/v5/oci/httplayout"
 	_ "github.com/containers/image/v5/oci/layout"
+	_ "github.com/containers/image/v5/oci/s3"
 	_ "github.com/containers/image/v5/openshift"
 	_ "github.com/containers/image/v5/sif"
 	_ "github.com/containers/image/v5/tarball"
@@ -24,6 +28,7 @@ import (
 )
 
 // ParseImageName converts a URL-like image name to a types.ImageReference.
+// Transport plugins are only recognized if they have been registered using the transports/plugin package.
 func ParseImageName(imgName string) (types.ImageReference, error) {
 	// Keep this in sync with TransportFromImageName!
 	transportName, withinTransport, valid := strings.Cut(imgName, ":")
@@ -32,10 +32,16 @@ func TestImageNameHandling(t *testing.T) {
 		{"docker", "//busybox:notlatest", "//busybox:notlatest"}, // This also tests handling of multiple ":" characters
 		{"docker-archive", "/var/lib/oci/busybox.tar:busybox:latest", "/var/lib/oci/busybox.tar:docker.io/library/busybox:latest"},
 		{"docker-archive", "busybox.tar:busybox:latest", "busybox.tar:docker.io/library/busybox:latest"},
+		{"memory", "example/app:v1", "example/app:v1"},
 		{"oci", "/etc:someimage", "/etc:someimage"},
 		{"oci", "/etc:someimage:mytag", "/etc:someimage:mytag"},
 		{"oci-archive", "/etc:someimage", "/etc:someimage"},
 		{"oci-archive", "/etc:someimage:mytag", "/etc:someimage:mytag"},
+		{"oci-http", "https://example.com/layout:someimage:mytag", "https://example.com/layout:someimage:mytag"},
+		{"cache", "/var/cache/images:docker://busybox", "/var/cache/images:docker://busybox:latest"},
+		{"cache", "relative:dir:/etc", "relative:dir:/etc"},
+		{"oci-s3", "bucket", "bucket:"},
+		{"oci-s3", "bucket/some/prefix:someimage:mytag", "bucket/some/prefix:someimage:mytag"},
 		// "atomic" not tested here because it depends on per-user configuration for the default cluster.
 		// "containers-storage" not tested here because it needs to initialize various directories on the fs.
 	} {
@@ -61,3 +67,21 @@ func TestTransportFromImageName(t *testing.T) {
 	invalidName := TransportFromImageName("unknown")
 	assert.Equal(t, invalidName, nil)
 }
+
+func TestCapabilities(t *testing.T) {
+	for _, name := range transports.ListNames() {
+		_, err := Capabilities(name)
+		assert.NoError(t, err, name)
+	}
+
+	c, err := Capabilities("docker")
+	require.NoError(t, err)
+	assert.Equal(t, TransportCapabilities{Source: true, Destination: true, Signatures: true, Deletion: true, MultipleImages: true, Encryption: true}, c)
+	c, err = Capabilities("oci-http")
+	require.NoError(t, err)
+	assert.True(t, c.Source)
+	assert.False(t, c.Destination)
+
+	_, err = Capabilities("this-transport-does-not-exist")
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package alltransports
+
+import (
+	"fmt"
+
+	"github.com/containers/image/v5/transports"
+)
+
+// TransportCapabilities describes the features supported by a transport, so that generic tools can adapt their
+// behavior instead of hard-coding transport names.
+//
+// The values describe what the transport can support in general; individual references, or the systems they
+// refer to (e.g. a registry which does not accept signatures), may still fail to support a feature.
+type TransportCapabilities struct {
+	Source         bool // Images can be read (ImageReference.NewImageSource).
+	Destination    bool // Images can be written (ImageReference.NewImageDestination).
+	Signatures     bool // Signatures can be stored together with images.
+	Deletion       bool // Images can be deleted (ImageReference.DeleteImage).
+	MultipleImages bool // A single reference can refer to a multi-image manifest list or OCI index.
+	PartialPulls   bool // Layers can be pulled partially, fetching only data which is not already available locally.
+	Encryption     bool // OCI-encrypted images can be stored, and copied without decrypting them.
+}
+
+// transportCapabilities contains capabilities of transports built into this package, indexed by transport name.
+// Transports which may be stubbed out are added by the build-tag-specific files.
+var transportCapabilities = map[string]TransportCapabilities{
+	"atomic": {Source: true, Destination: true, Signatures: true},
+	// cache: wraps another reference; the features of the wrapped transport, other than writing, are passed through.
+	"cache":          {Source: true, Signatures: true, MultipleImages: true, Encryption: true},
+	"dir":            {Source: true, Destination: true, Signatures: true, MultipleImages: true, Encryption: true},
+	"docker":         {Source: true, Destination: true, Signatures: true, Deletion: true, MultipleImages: true, Encryption: true},
+	"docker-archive": {Source: true, Destination: true},
+	"memory":         {Source: true, Destination: true, Signatures: true, Deletion: true, MultipleImages: true, Encryption: true},
+	"oci":            {Source: true, Destination: true, Deletion: true, MultipleImages: true, Encryption: true},
+	"oci-archive":    {Source: true, Destination: true, MultipleImages: true, Encryption: true},
+	"oci-http":       {Source: true, MultipleImages: true, Encryption: true},
+	"oci-s3":         {Source: true, Destination: true, MultipleImages: true, Encryption: true},
+	"sif":            {Source: true, Destination: true},
+	"tarball":        {Source: true, Destination: true},
+}
+
+// Capabilities returns the capabilities of the transport named transportName.
+// Transport plugins, and transports registered by other packages, are only known to support Source and Destination.
+func Capabilities(transportName string) (TransportCapabilities, error) {
+	if c, ok := transportCapabilities[transportName]; ok {
+		return c, nil
+	}
+	if transports.Get(transportName) == nil {
+		return TransportCapabilities{}, fmt.Errorf("unknown transport %q", transportName)
+	}
+	return TransportCapabilities{Source: true, Destination: true}, nil
+}
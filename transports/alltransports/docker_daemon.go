@@ -7,3 +7,7 @@ import (
 	// Register the docker-daemon transport
 	_ "github.com/containers/image/v5/docker/daemon"
 )
+
+func init() {
+	transportCapabilities["docker-daemon"] = TransportCapabilities{Source: true, Destination: true}
+}
@@ -0,0 +1,134 @@
+package alltransports
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// ReferenceOptions are per-reference settings specified in an image name, see ParseImageNameWithOptions.
+// The zero value does not change any settings.
+type ReferenceOptions struct {
+	// From the "platform" option, "os/architecture[/variant]"; empty if not set.
+	OS           string
+	Architecture string
+	Variant      string
+	// From the "tls-verify" option.
+	TLSVerify types.OptionalBool
+	// From the "cert-dir" option; empty if not set.
+	CertDir string
+	// From the "authfile" option; empty if not set.
+	AuthFilePath string
+}
+
+// ParseImageNameWithOptions converts a URL-like image name, optionally followed by "?" and URL query-style options
+// (e.g. "docker://registry.example.com/repo:tag?platform=linux/arm64&tls-verify=false"), to a types.ImageReference
+// and the ReferenceOptions it specifies.
+//
+// The options are separated from the reference at the last "?"; to use a reference which contains "?" (e.g. a path),
+// add a trailing "?" with no options.
+// The recognized options are:
+//   - platform=os/architecture[/variant]
+//   - tls-verify=true|false
+//   - cert-dir=path
+//   - authfile=path
+//
+// Unknown options are rejected.
+func ParseImageNameWithOptions(imgName string) (types.ImageReference, *ReferenceOptions, error) {
+	queryStart := strings.LastIndex(imgName, "?")
+	if queryStart == -1 {
+		ref, err := ParseImageName(imgName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ref, &ReferenceOptions{}, nil
+	}
+	opts, err := parseReferenceOptions(imgName[queryStart+1:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid options in image name %q: %w", imgName, err)
+	}
+	ref, err := ParseImageName(imgName[:queryStart])
+	if err != nil {
+		return nil, nil, err
+	}
+	return ref, opts, nil
+}
+
+// parseReferenceOptions parses query, the options part of an image name.
+func parseReferenceOptions(query string) (*ReferenceOptions, error) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	res := ReferenceOptions{}
+	for key, vals := range values {
+		if len(vals) != 1 {
+			return nil, fmt.Errorf("option %q specified more than once", key)
+		}
+		value := vals[0]
+		switch key {
+		case "platform":
+			parts := strings.Split(value, "/")
+			if (len(parts) != 2 && len(parts) != 3) || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] == "") {
+				return nil, fmt.Errorf("invalid platform %q, expected os/architecture[/variant]", value)
+			}
+			res.OS, res.Architecture = parts[0], parts[1]
+			if len(parts) == 3 {
+				res.Variant = parts[2]
+			}
+		case "tls-verify":
+			verify, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tls-verify value %q: %w", value, err)
+			}
+			res.TLSVerify = types.NewOptionalBool(verify)
+		case "cert-dir":
+			if value == "" {
+				return nil, fmt.Errorf("option %q must not be empty", key)
+			}
+			res.CertDir = value
+		case "authfile":
+			if value == "" {
+				return nil, fmt.Errorf("option %q must not be empty", key)
+			}
+			res.AuthFilePath = value
+		default:
+			return nil, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return &res, nil
+}
+
+// SystemContext returns a copy of sys (which may be nil) updated with the settings in o.
+// The settings apply to all transports which support them: tls-verify and cert-dir affect the docker,
+// docker-daemon and OCI transports.
+func (o *ReferenceOptions) SystemContext(sys *types.SystemContext) *types.SystemContext {
+	res := types.SystemContext{}
+	if sys != nil {
+		res = *sys
+	}
+	if o.OS != "" {
+		res.OSChoice = o.OS
+		res.ArchitectureChoice = o.Architecture
+		res.VariantChoice = o.Variant
+	}
+	if o.TLSVerify != types.OptionalBoolUndefined {
+		skip := o.TLSVerify == types.OptionalBoolFalse
+		res.DockerInsecureSkipTLSVerify = types.NewOptionalBool(skip)
+		res.DockerDaemonInsecureSkipTLSVerify = skip
+		res.OCIInsecureSkipTLSVerify = skip
+	}
+	if o.CertDir != "" {
+		res.DockerCertPath = o.CertDir
+		res.DockerDaemonCertPath = o.CertDir
+		res.OCICertPath = o.CertDir
+	}
+	if o.AuthFilePath != "" {
+		res.AuthFilePath = o.AuthFilePath
+		res.DockerCompatAuthFilePath = "" // Must not be set together with AuthFilePath
+	}
+	return &res
+}
@@ -0,0 +1,99 @@
+package alltransports
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageNameWithOptions(t *testing.T) {
+	dirWithQuestion := filepath.Join(t.TempDir(), "with?question")
+	err := os.Mkdir(dirWithQuestion, 0o755)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		input, name string
+		options     ReferenceOptions
+	}{
+		{"docker://busybox", "docker://busybox:latest", ReferenceOptions{}},
+		{"docker://busybox?", "docker://busybox:latest", ReferenceOptions{}},
+		{
+			"docker://registry.example.com/repo:tag?platform=linux/arm64/v8&tls-verify=false",
+			"docker://registry.example.com/repo:tag",
+			ReferenceOptions{OS: "linux", Architecture: "arm64", Variant: "v8", TLSVerify: types.OptionalBoolFalse},
+		},
+		{
+			"docker://busybox?platform=windows/amd64&tls-verify=true&cert-dir=/etc/certs&authfile=/run/auth.json",
+			"docker://busybox:latest",
+			ReferenceOptions{OS: "windows", Architecture: "amd64", TLSVerify: types.OptionalBoolTrue, CertDir: "/etc/certs", AuthFilePath: "/run/auth.json"},
+		},
+		{"dir:" + dirWithQuestion + "?", "dir:" + dirWithQuestion, ReferenceOptions{}},
+		{"dir:" + dirWithQuestion + "?authfile=%2Fauth.json", "dir:" + dirWithQuestion, ReferenceOptions{AuthFilePath: "/auth.json"}},
+	} {
+		ref, opts, err := ParseImageNameWithOptions(c.input)
+		require.NoError(t, err, c.input)
+		assert.Equal(t, c.name, transports.ImageName(ref), c.input)
+		assert.Equal(t, &c.options, opts, c.input)
+	}
+
+	for _, input := range []string{
+		"busybox?tls-verify=false",                          // No transport name
+		"docker://busybox?unknown=1",                        // Unknown option
+		"docker://busybox?tls-verify=maybe",                 // Invalid boolean
+		"docker://busybox?tls-verify=true&tls-verify=false", // Repeated option
+		"docker://busybox?platform=linux",                   // Missing architecture
+		"docker://busybox?platform=linux/arm64/v8/x",        // Too many components
+		"docker://busybox?platform=/amd64",                  // Empty OS
+		"docker://busybox?cert-dir=",                        // Empty path
+		"docker://busybox?%zz",                              // Invalid query
+		"dir:/path/with?question",                           // "question" is treated as an unknown option
+	} {
+		_, _, err := ParseImageNameWithOptions(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestReferenceOptionsSystemContext(t *testing.T) {
+	// Zero options
+	sys := (&ReferenceOptions{}).SystemContext(nil)
+	assert.Equal(t, &types.SystemContext{}, sys)
+	base := &types.SystemContext{
+		OSChoice:                 "linux",
+		ArchitectureChoice:       "amd64",
+		DockerCompatAuthFilePath: "/compat.json",
+		DockerCertPath:           "/base-certs",
+	}
+	sys = (&ReferenceOptions{}).SystemContext(base)
+	assert.Equal(t, base, sys)
+	assert.NotSame(t, base, sys)
+
+	// All options
+	sys = (&ReferenceOptions{
+		OS: "linux", Architecture: "arm64", Variant: "v8",
+		TLSVerify:    types.OptionalBoolFalse,
+		CertDir:      "/certs",
+		AuthFilePath: "/auth.json",
+	}).SystemContext(base)
+	assert.Equal(t, &types.SystemContext{
+		OSChoice:                          "linux",
+		ArchitectureChoice:                "arm64",
+		VariantChoice:                     "v8",
+		DockerInsecureSkipTLSVerify:       types.OptionalBoolTrue,
+		DockerDaemonInsecureSkipTLSVerify: true,
+		OCIInsecureSkipTLSVerify:          true,
+		DockerCertPath:                    "/certs",
+		DockerDaemonCertPath:              "/certs",
+		OCICertPath:                       "/certs",
+		AuthFilePath:                      "/auth.json",
+	}, sys)
+	// The base value is not modified
+	assert.Equal(t, "/base-certs", base.DockerCertPath)
+
+	sys = (&ReferenceOptions{TLSVerify: types.OptionalBoolTrue}).SystemContext(nil)
+	assert.Equal(t, &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolFalse}, sys)
+}
@@ -7,3 +7,7 @@ import (
 	// Register the ostree transport
 	_ "github.com/containers/image/v5/ostree"
 )
+
+func init() {
+	transportCapabilities["ostree"] = TransportCapabilities{Source: true, Destination: true, Signatures: true}
+}
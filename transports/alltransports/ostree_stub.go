@@ -7,4 +7,5 @@ import "github.com/containers/image/v5/transports"
 
 func init() {
 	transports.Register(transports.NewStubTransport("ostree"))
+	transportCapabilities["ostree"] = TransportCapabilities{} // The stub does not support anything.
 }
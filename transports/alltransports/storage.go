@@ -7,3 +7,7 @@ import (
 	// Register the storage transport
 	_ "github.com/containers/image/v5/storage"
 )
+
+func init() {
+	transportCapabilities["containers-storage"] = TransportCapabilities{Source: true, Destination: true, Signatures: true, Deletion: true, PartialPulls: true}
+}
@@ -0,0 +1,325 @@
+// Package plugin allows out-of-tree transports, implemented as separate executables, to be used like the built-in transports.
+//
+// A plugin for a transport named $name is an executable named containers-image-transport-$name,
+// found in one of the directories listed in $CONTAINERS_IMAGE_TRANSPORT_PLUGIN_PATH, or in DefaultDirectories.
+// Plugins are never discovered implicitly; applications which want to use them must call Find, RegisterInstalled or Register
+// before parsing image names.
+// The library communicates with the plugin over its standard input and output; see docs/containers-transport-plugins.md.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/internal/image"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	// ExecutablePrefix is the prefix of file names of transport plugin executables; the rest of the file name is the transport name.
+	ExecutablePrefix = "containers-image-transport-"
+	// PathEnv is the name of an environment variable containing a colon-separated list of directories searched for plugins
+	// before DefaultDirectories.
+	PathEnv = "CONTAINERS_IMAGE_TRANSPORT_PLUGIN_PATH"
+)
+
+// DefaultDirectories are directories searched for plugins, in order.
+var DefaultDirectories = []string{
+	"/usr/local/libexec/containers/image-transports",
+	"/usr/libexec/containers/image-transports",
+}
+
+// transportNameRegexp matches valid plugin transport names.
+var transportNameRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// registrationLock serializes checking for, and registering, plugin transports.
+var registrationLock sync.Mutex
+
+// Register registers a transport named name, implemented by a plugin executable at path.
+// It fails if a transport with that name is already registered.
+func Register(name, path string) error {
+	if !transportNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid transport name %q", name)
+	}
+	registrationLock.Lock()
+	defer registrationLock.Unlock()
+	if transports.Get(name) != nil {
+		return fmt.Errorf("transport %q is already registered", name)
+	}
+	transports.Register(pluginTransport{name: name, path: path})
+	return nil
+}
+
+// Find returns a transport named name: an already registered transport, if any,
+// or a newly registered plugin transport if a plugin executable for name can be found.
+// It returns nil if there is no such transport.
+//
+// Note that this searches the file system and registers the plugin globally; it is not called by
+// alltransports.ParseImageName, applications must call it explicitly.
+func Find(name string) types.ImageTransport {
+	if !transportNameRegexp.MatchString(name) {
+		return transports.Get(name) // Not a valid plugin name, but there might be a built-in transport.
+	}
+	registrationLock.Lock()
+	defer registrationLock.Unlock()
+	if t := transports.Get(name); t != nil {
+		return t
+	}
+	path, ok := findExecutable(name)
+	if !ok {
+		return nil
+	}
+	t := pluginTransport{name: name, path: path}
+	transports.Register(t)
+	return t
+}
+
+// RegisterInstalled registers transports for all plugin executables found in the plugin search path,
+// so that they can be used by alltransports.ParseImageName.
+// Plugins for names of already registered transports are ignored.
+func RegisterInstalled() error {
+	registrationLock.Lock()
+	defer registrationLock.Unlock()
+	for _, dir := range searchDirectories() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("looking for transport plugins: %w", err)
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), ExecutablePrefix)
+			if !ok || !transportNameRegexp.MatchString(name) || transports.Get(name) != nil {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if isExecutable(path) {
+				transports.Register(pluginTransport{name: name, path: path})
+			}
+		}
+	}
+	return nil
+}
+
+// searchDirectories returns the directories to search for plugin executables, in order.
+func searchDirectories() []string {
+	dirs := []string{}
+	if env := os.Getenv(PathEnv); env != "" {
+		for _, dir := range filepath.SplitList(env) {
+			if dir != "" && filepath.IsAbs(dir) { // Don’t look up executables relative to the current working directory.
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return append(dirs, DefaultDirectories...)
+}
+
+// findExecutable looks for a plugin executable for a transport named name, and returns its path.
+func findExecutable(name string) (string, bool) {
+	for _, dir := range searchDirectories() {
+		path := filepath.Join(dir, ExecutablePrefix+name)
+		if isExecutable(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// isExecutable returns true if path is an executable regular file.
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Mode().Perm()&0o111 != 0
+}
+
+// pluginTransport is an ImageTransport implemented by a plugin executable.
+type pluginTransport struct {
+	name string
+	path string
+}
+
+func (t pluginTransport) Name() string {
+	return t.name
+}
+
+// withSession runs fn with a newly started session of the plugin.
+func (t pluginTransport) withSession(fn func(s *session) error) error {
+	s, err := startSession(t.path)
+	if err != nil {
+		return err
+	}
+	err = fn(s)
+	if closeErr := s.close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ParseReference converts a string, which should not start with the ImageTransport.Name prefix, into an ImageReference.
+func (t pluginTransport) ParseReference(ref string) (types.ImageReference, error) {
+	var res parseReferenceResult
+	if err := t.withSession(func(s *session) error {
+		return s.call(context.Background(), "ParseReference", referenceParams{Reference: ref}, &res)
+	}); err != nil {
+		return nil, err
+	}
+	return newReference(t, res)
+}
+
+// ValidatePolicyConfigurationScope checks that scope is a valid name for a signature.PolicyTransportScopes keys
+// (i.e. a valid PolicyConfigurationIdentity() or PolicyConfigurationNamespaces() return value).
+// It is acceptable to allow an invalid value which will never be matched, it can "only" cause user confusion.
+// scope passed to this function will not be "", that value is always allowed.
+func (t pluginTransport) ValidatePolicyConfigurationScope(scope string) error {
+	return t.withSession(func(s *session) error {
+		return s.call(context.Background(), "ValidatePolicyConfigurationScope", validateScopeParams{Scope: scope}, nil)
+	})
+}
+
+// referenceParams are parameters of requests which only refer to an image reference.
+type referenceParams struct {
+	Reference string `json:"reference"`
+}
+
+// validateScopeParams are parameters of a ValidatePolicyConfigurationScope request.
+type validateScopeParams struct {
+	Scope string `json:"scope"`
+}
+
+// parseReferenceResult is the result of a ParseReference request.
+type parseReferenceResult struct {
+	StringWithinTransport         string   `json:"stringWithinTransport"`
+	DockerReference               string   `json:"dockerReference,omitempty"`
+	PolicyConfigurationIdentity   string   `json:"policyConfigurationIdentity"`
+	PolicyConfigurationNamespaces []string `json:"policyConfigurationNamespaces,omitempty"`
+}
+
+// pluginReference is an ImageReference for a plugin transport.
+type pluginReference struct {
+	transport  pluginTransport
+	ref        string // The value of StringWithinTransport, as reported by the plugin
+	dockerRef  reference.Named
+	identity   string
+	namespaces []string
+}
+
+// newReference returns a pluginReference for t described by res.
+func newReference(t pluginTransport, res parseReferenceResult) (pluginReference, error) {
+	if res.StringWithinTransport == "" {
+		return pluginReference{}, fmt.Errorf("transport plugin %s returned an empty reference", t.path)
+	}
+	var dockerRef reference.Named
+	if res.DockerReference != "" {
+		ref, err := reference.ParseNormalizedNamed(res.DockerReference)
+		if err != nil {
+			return pluginReference{}, fmt.Errorf("transport plugin %s returned an invalid Docker reference: %w", t.path, err)
+		}
+		if reference.IsNameOnly(ref) {
+			return pluginReference{}, fmt.Errorf("transport plugin %s returned Docker reference %q without a tag or digest", t.path, res.DockerReference)
+		}
+		dockerRef = ref
+	}
+	namespaces := res.PolicyConfigurationNamespaces
+	if namespaces == nil {
+		namespaces = []string{}
+	}
+	return pluginReference{
+		transport:  t,
+		ref:        res.StringWithinTransport,
+		dockerRef:  dockerRef,
+		identity:   res.PolicyConfigurationIdentity,
+		namespaces: namespaces,
+	}, nil
+}
+
+func (ref pluginReference) Transport() types.ImageTransport {
+	return ref.transport
+}
+
+// StringWithinTransport returns a string representation of the reference, which MUST be such that
+// reference.Transport().ParseReference(reference.StringWithinTransport()) returns an equivalent reference.
+// NOTE: The returned string is not promised to be equal to the original input to ParseReference;
+// e.g. default attribute values omitted by the user may be filled in the return value, or vice versa.
+// WARNING: Do not use the return value in the UI to describe an image, it does not contain the Transport().Name() prefix.
+func (ref pluginReference) StringWithinTransport() string {
+	return ref.ref
+}
+
+// DockerReference returns a Docker reference associated with this reference
+// (fully explicit, i.e. !reference.IsNameOnly, but reflecting user intent,
+// not e.g. after redirect or alias processing), or nil if unknown/not applicable.
+func (ref pluginReference) DockerReference() reference.Named {
+	return ref.dockerRef
+}
+
+// PolicyConfigurationIdentity returns a string representation of the reference, suitable for policy lookup.
+// This MUST reflect user intent, not e.g. after processing of third-party redirects or aliases;
+// The value SHOULD be fully explicit about its semantics, with no hidden defaults, AND canonical
+// (i.e. various references with exactly the same semantics should return the same configuration identity)
+// It is fine for the return value to be equal to StringWithinTransport(), and it is desirable but
+// not required/guaranteed that it will be a valid input to Transport().ParseReference().
+// Returns "" if configuration identities for these references are not supported.
+func (ref pluginReference) PolicyConfigurationIdentity() string {
+	return ref.identity
+}
+
+// PolicyConfigurationNamespaces returns a list of other policy configuration namespaces to search
+// for if explicit configuration for PolicyConfigurationIdentity() is not set.  The list will be processed
+// in order, terminating on first match, and an implicit "" is always checked at the end.
+// It is STRONGLY recommended for the first element, if any, to be a prefix of PolicyConfigurationIdentity(),
+// and each following element to be a prefix of the element preceding it.
+func (ref pluginReference) PolicyConfigurationNamespaces() []string {
+	return ref.namespaces
+}
+
+// NewImage returns a types.ImageCloser for this reference, possibly specialized for this ImageTransport.
+// The caller must call .Close() on the returned ImageCloser.
+// NOTE: If any kind of signature verification should happen, build an UnparsedImage from the value returned by NewImageSource,
+// verify that UnparsedImage, and convert it into a real Image via image.FromUnparsedImage.
+// WARNING: This may not do the right thing for a manifest list, see image.FromSource for details.
+func (ref pluginReference) NewImage(ctx context.Context, sys *types.SystemContext) (types.ImageCloser, error) {
+	return image.FromReference(ctx, sys, ref)
+}
+
+// NewImageSource returns a types.ImageSource for this reference.
+// The caller must call .Close() on the returned ImageSource.
+func (ref pluginReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	return newImageSource(ctx, ref)
+}
+
+// NewImageDestination returns a types.ImageDestination for this reference.
+// The caller must call .Close() on the returned ImageDestination.
+func (ref pluginReference) NewImageDestination(ctx context.Context, sys *types.SystemContext) (types.ImageDestination, error) {
+	return newImageDestination(ctx, ref)
+}
+
+// DeleteImage deletes the named image from the registry, if supported.
+func (ref pluginReference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
+	return ref.transport.withSession(func(s *session) error {
+		return s.call(ctx, "DeleteImage", referenceParams{Reference: ref.ref}, nil)
+	})
+}
+
+// openSession starts a plugin session, and sends an initial request for ref, reading the response into result.
+func (ref pluginReference) openSession(ctx context.Context, method string, result any) (*session, error) {
+	s, err := startSession(ref.transport.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.call(ctx, method, referenceParams{Reference: ref.ref}, result); err != nil {
+		if closeErr := s.close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,227 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containers/image/v5/internal/imagedestination/impl"
+	"github.com/containers/image/v5/internal/imagedestination/stubs"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// openDestinationResult is the result of an OpenDestination request.
+type openDestinationResult struct {
+	SupportedManifestMIMETypes []string `json:"supportedManifestMIMETypes,omitempty"`
+	// DesiredLayerCompression is one of "preserve" (the default), "compress", "decompress".
+	DesiredLayerCompression        string `json:"desiredLayerCompression,omitempty"`
+	AcceptsForeignLayerURLs        bool   `json:"acceptsForeignLayerURLs,omitempty"`
+	MustMatchRuntimeOS             bool   `json:"mustMatchRuntimeOS,omitempty"`
+	IgnoresEmbeddedDockerReference bool   `json:"ignoresEmbeddedDockerReference,omitempty"`
+	SupportsSignatures             bool   `json:"supportsSignatures,omitempty"`
+}
+
+// putBlobParams are parameters of a PutBlob request.
+type putBlobParams struct {
+	blobParams
+	IsConfig bool `json:"isConfig,omitempty"`
+}
+
+// putBlobResult is the result of a PutBlob request.
+type putBlobResult struct {
+	Digest digest.Digest `json:"digest"`
+	Size   int64         `json:"size"`
+}
+
+// tryReusingBlobResult is the result of a TryReusingBlob request.
+type tryReusingBlobResult struct {
+	Reused bool  `json:"reused"`
+	Size   int64 `json:"size,omitempty"`
+}
+
+// putManifestParams are parameters of a PutManifest request.
+type putManifestParams struct {
+	Manifest       []byte         `json:"manifest"`
+	InstanceDigest *digest.Digest `json:"instanceDigest,omitempty"`
+}
+
+// putSignaturesParams are parameters of a PutSignatures request.
+type putSignaturesParams struct {
+	// Signatures contains signatures in the format of internal/signature.Blob.
+	Signatures     [][]byte       `json:"signatures"`
+	InstanceDigest *digest.Digest `json:"instanceDigest,omitempty"`
+}
+
+type pluginImageDestination struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	stubs.NoPutBlobPartialInitialize
+
+	ref                pluginReference
+	s                  *session
+	supportsSignatures bool
+}
+
+// newImageDestination returns an ImageDestination for writing to ref, using a newly started plugin session.
+func newImageDestination(ctx context.Context, ref pluginReference) (private.ImageDestination, error) {
+	var res openDestinationResult
+	s, err := ref.openSession(ctx, "OpenDestination", &res)
+	if err != nil {
+		return nil, err
+	}
+	var compression types.LayerCompression
+	switch res.DesiredLayerCompression {
+	case "", "preserve":
+		compression = types.PreserveOriginal
+	case "compress":
+		compression = types.Compress
+	case "decompress":
+		compression = types.Decompress
+	default:
+		err := fmt.Errorf("transport plugin %s requested unknown layer compression %q", ref.transport.path, res.DesiredLayerCompression)
+		if closeErr := s.close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+		return nil, err
+	}
+	d := &pluginImageDestination{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			SupportedManifestMIMETypes:     res.SupportedManifestMIMETypes,
+			DesiredLayerCompression:        compression,
+			AcceptsForeignLayerURLs:        res.AcceptsForeignLayerURLs,
+			MustMatchRuntimeOS:             res.MustMatchRuntimeOS,
+			IgnoresEmbeddedDockerReference: res.IgnoresEmbeddedDockerReference,
+			HasThreadSafePutBlob:           false, // The plugin processes one request at a time.
+		}),
+		NoPutBlobPartialInitialize: stubs.NoPutBlobPartial(ref),
+
+		ref:                ref,
+		s:                  s,
+		supportsSignatures: res.SupportsSignatures,
+	}
+	d.Compat = impl.AddCompat(d)
+	return d, nil
+}
+
+// Reference returns the reference used to set up this destination.  Note that this should directly correspond to user's intent,
+// e.g. it should use the public hostname instead of the result of resolving CNAMEs or following redirects.
+func (d *pluginImageDestination) Reference() types.ImageReference {
+	return d.ref
+}
+
+// Close removes resources associated with an initialized ImageDestination, if any.
+func (d *pluginImageDestination) Close() error {
+	return d.s.close()
+}
+
+// SupportsSignatures returns an error (to be displayed to the user) if the destination certainly can't store signatures.
+// Note: It is still possible for PutSignatures to fail if SupportsSignatures returns nil.
+func (d *pluginImageDestination) SupportsSignatures(ctx context.Context) error {
+	if !d.supportsSignatures {
+		return fmt.Errorf("Storing signatures is not supported by the %q transport", d.ref.transport.name)
+	}
+	return nil
+}
+
+// PutBlobWithOptions writes contents of stream and returns data representing the result.
+// inputInfo.Digest can be optionally provided if known; if provided, and stream is read to the end without error, the digest MUST match the stream contents.
+// inputInfo.Size is the expected length of stream, if known.
+// inputInfo.MediaType describes the blob format, if known.
+// WARNING: The contents of stream are being verified on the fly.  Until stream.Read() returns io.EOF, the contents of the data SHOULD NOT be available
+// to any other readers for download using the supplied digest.
+// If stream.Read() at any time, ESPECIALLY at end of input, returns an error, PutBlobWithOptions MUST 1) fail, and 2) delete any data stored so far.
+func (d *pluginImageDestination) PutBlobWithOptions(ctx context.Context, stream io.Reader, inputInfo types.BlobInfo, options private.PutBlobOptions) (private.UploadedBlob, error) {
+	var res putBlobResult
+	if err := d.s.callWithUpload(ctx, "PutBlob", putBlobParams{
+		blobParams: blobParams{
+			Digest:    inputInfo.Digest,
+			Size:      inputInfo.Size,
+			MediaType: inputInfo.MediaType,
+		},
+		IsConfig: options.IsConfig,
+	}, stream, &res); err != nil {
+		return private.UploadedBlob{}, err
+	}
+	if err := res.Digest.Validate(); err != nil {
+		return private.UploadedBlob{}, fmt.Errorf("transport plugin %s returned an invalid blob digest: %w", d.ref.transport.path, err)
+	}
+	if inputInfo.Digest != "" && res.Digest != inputInfo.Digest {
+		return private.UploadedBlob{}, fmt.Errorf("transport plugin %s stored blob %s as %s", d.ref.transport.path, inputInfo.Digest, res.Digest)
+	}
+	return private.UploadedBlob{Digest: res.Digest, Size: res.Size}, nil
+}
+
+// TryReusingBlobWithOptions checks whether the transport already contains, or can efficiently reuse, a blob, and if so, applies it to the current destination
+// (e.g. if the blob is a filesystem layer, this signifies that the changes it describes need to be applied again when composing a filesystem tree).
+// info.Digest must not be empty.
+// If the blob has been successfully reused, returns (true, info, nil).
+// If the transport can not reuse the requested blob, TryReusingBlob returns (false, {}, nil); it returns a non-nil error only on an unexpected failure.
+func (d *pluginImageDestination) TryReusingBlobWithOptions(ctx context.Context, info types.BlobInfo, options private.TryReusingBlobOptions) (bool, private.ReusedBlob, error) {
+	if !impl.OriginalCandidateMatchesTryReusingBlobOptions(options) {
+		return false, private.ReusedBlob{}, nil
+	}
+	if info.Digest == "" {
+		return false, private.ReusedBlob{}, errors.New("Can not check for a blob with unknown digest")
+	}
+	var res tryReusingBlobResult
+	if err := d.s.call(ctx, "TryReusingBlob", blobParams{
+		Digest:    info.Digest,
+		Size:      info.Size,
+		MediaType: info.MediaType,
+	}, &res); err != nil {
+		return false, private.ReusedBlob{}, err
+	}
+	if !res.Reused {
+		return false, private.ReusedBlob{}, nil
+	}
+	return true, private.ReusedBlob{Digest: info.Digest, Size: res.Size}, nil
+}
+
+// PutManifest writes manifest to the destination.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to write the manifest for
+// (when the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
+// It is expected but not enforced that the instanceDigest, when specified, matches the digest of `manifest` as generated
+// by `manifest.Digest()`.
+// FIXME? This should also receive a MIME type if known, to differentiate between schema versions.
+// If the destination is in principle available, refuses this manifest type (e.g. it does not recognize the schema),
+// but may accept a different manifest type, the returned error must be an ManifestTypeRejectedError.
+func (d *pluginImageDestination) PutManifest(ctx context.Context, manifest []byte, instanceDigest *digest.Digest) error {
+	return d.s.call(ctx, "PutManifest", putManifestParams{Manifest: manifest, InstanceDigest: instanceDigest}, nil)
+}
+
+// PutSignaturesWithFormat writes a set of signatures to the destination.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to write or overwrite the signatures for
+// (when the primary manifest is a manifest list); this should always be nil if the primary manifest is not a manifest list.
+// MUST be called after PutManifest (signatures may reference manifest contents).
+func (d *pluginImageDestination) PutSignaturesWithFormat(ctx context.Context, signatures []signature.Signature, instanceDigest *digest.Digest) error {
+	if len(signatures) == 0 {
+		return nil
+	}
+	if err := d.SupportsSignatures(ctx); err != nil {
+		return err
+	}
+	blobs := make([][]byte, 0, len(signatures))
+	for _, sig := range signatures {
+		blob, err := signature.Blob(sig)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, blob)
+	}
+	return d.s.call(ctx, "PutSignatures", putSignaturesParams{Signatures: blobs, InstanceDigest: instanceDigest}, nil)
+}
+
+// Commit marks the process of storing the image as successful and asks for the image to be persisted.
+// unparsedToplevel contains data about the top-level manifest of the source (which may be a single-arch image or a manifest list
+// if PutManifest was only called for the single-arch image with instanceDigest == nil), primarily to allow lookups by the
+// original manifest list digest, if desired.
+// WARNING: This does not have any transactional semantics:
+// - Uploaded data MAY be visible to others before Commit() is called
+// - Uploaded data MAY be removed or MAY remain around if Close() is called without Commit() (i.e. rollback is allowed but not guaranteed)
+func (d *pluginImageDestination) Commit(ctx context.Context, unparsedToplevel types.UnparsedImage) error {
+	return d.s.call(ctx, "Commit", nil, nil)
+}
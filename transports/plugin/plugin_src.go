@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/containers/image/v5/internal/imagesource/impl"
+	"github.com/containers/image/v5/internal/imagesource/stubs"
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// instanceParams are parameters of requests which may refer to a specific instance of a manifest list.
+type instanceParams struct {
+	InstanceDigest *digest.Digest `json:"instanceDigest,omitempty"`
+}
+
+// getManifestResult is the result of a GetManifest request.
+type getManifestResult struct {
+	Manifest []byte `json:"manifest"`
+	MIMEType string `json:"mimeType,omitempty"`
+}
+
+// blobParams are parameters of requests which refer to a blob.
+type blobParams struct {
+	Digest    digest.Digest `json:"digest,omitempty"`
+	Size      int64         `json:"size"`
+	MediaType string        `json:"mediaType,omitempty"`
+	URLs      []string      `json:"urls,omitempty"`
+}
+
+// getBlobResult is the result of a GetBlob request.
+type getBlobResult struct {
+	Size int64 `json:"size"`
+}
+
+// signaturesResult is the result of a GetSignatures request, and parameters of a PutSignatures request.
+type signaturesResult struct {
+	// Signatures contains signatures in the format of internal/signature.Blob.
+	Signatures [][]byte `json:"signatures"`
+}
+
+type pluginImageSource struct {
+	impl.Compat
+	impl.PropertyMethodsInitialize
+	impl.DoesNotAffectLayerInfosForCopy
+	stubs.NoGetBlobAtInitialize
+
+	ref pluginReference
+
+	sessionLock sync.Mutex // Protects s
+	s           *session
+}
+
+// newImageSource returns an ImageSource for reading from ref, using a newly started plugin session.
+func newImageSource(ctx context.Context, ref pluginReference) (private.ImageSource, error) {
+	s, err := ref.openSession(ctx, "OpenSource", nil)
+	if err != nil {
+		return nil, err
+	}
+	src := &pluginImageSource{
+		PropertyMethodsInitialize: impl.PropertyMethods(impl.Properties{
+			HasThreadSafeGetBlob: false, // The plugin processes one request at a time.
+		}),
+		NoGetBlobAtInitialize: stubs.NoGetBlobAt(ref),
+
+		ref: ref,
+		s:   s,
+	}
+	src.Compat = impl.AddCompat(src)
+	return src, nil
+}
+
+// Reference returns the reference used to set up this source.
+func (src *pluginImageSource) Reference() types.ImageReference {
+	return src.ref
+}
+
+// Close removes resources associated with an initialized ImageSource, if any.
+func (src *pluginImageSource) Close() error {
+	src.sessionLock.Lock()
+	defer src.sessionLock.Unlock()
+	return src.s.close()
+}
+
+// session returns a usable plugin session for src, starting a new one if the current one is no longer usable,
+// e.g. because a GetBlob stream was closed before reaching its end.
+func (src *pluginImageSource) session(ctx context.Context) (*session, error) {
+	src.sessionLock.Lock()
+	defer src.sessionLock.Unlock()
+	if src.s.usable() {
+		return src.s, nil
+	}
+	_ = src.s.close() // The session is broken, any error has already been reported.
+	s, err := src.ref.openSession(ctx, "OpenSource", nil)
+	if err != nil {
+		return nil, err
+	}
+	src.s = s
+	return s, nil
+}
+
+// GetManifest returns the image's manifest along with its MIME type (which may be empty when it can't be determined but the manifest is available).
+// It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve (when the primary manifest is a manifest list);
+// this never happens if the primary manifest is not a manifest list (e.g. if the source never returns manifest lists).
+func (src *pluginImageSource) GetManifest(ctx context.Context, instanceDigest *digest.Digest) ([]byte, string, error) {
+	var res getManifestResult
+	s, err := src.session(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.call(ctx, "GetManifest", instanceParams{InstanceDigest: instanceDigest}, &res); err != nil {
+		return nil, "", err
+	}
+	return res.Manifest, res.MIMEType, nil
+}
+
+// GetBlob returns a stream for the specified blob, and the blob’s size (or -1 if unknown).
+// The Digest field in BlobInfo is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
+// May update BlobInfoCache, preferably after it knows for certain that a blob truly exists at a specific location.
+func (src *pluginImageSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	s, err := src.session(ctx)
+	if err != nil {
+		return nil, -1, err
+	}
+	var res getBlobResult
+	stream, err := s.callWithDownload(ctx, "GetBlob", blobParams{
+		Digest:    info.Digest,
+		Size:      info.Size,
+		MediaType: info.MediaType,
+		URLs:      info.URLs,
+	}, &res)
+	if err != nil {
+		return nil, -1, err
+	}
+	return stream, res.Size, nil
+}
+
+// GetSignaturesWithFormat returns the image's signatures.  It may use a remote (= slow) service.
+// If instanceDigest is not nil, it contains a digest of the specific manifest instance to retrieve signatures for
+// (when the primary manifest is a manifest list); this never happens if the primary manifest is not a manifest list
+// (e.g. if the source never returns manifest lists).
+func (src *pluginImageSource) GetSignaturesWithFormat(ctx context.Context, instanceDigest *digest.Digest) ([]signature.Signature, error) {
+	var res signaturesResult
+	s, err := src.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.call(ctx, "GetSignatures", instanceParams{InstanceDigest: instanceDigest}, &res); err != nil {
+		return nil, err
+	}
+	sigs := make([]signature.Signature, 0, len(res.Signatures))
+	for _, blob := range res.Signatures {
+		sig, err := signature.FromBlob(blob)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
@@ -0,0 +1,421 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/internal/private"
+	"github.com/containers/image/v5/internal/signature"
+	"github.com/containers/image/v5/pkg/blobinfocache/memory"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ private.ImageSource      = (*pluginImageSource)(nil)
+	_ private.ImageDestination = (*pluginImageDestination)(nil)
+)
+
+// fakePluginDirEnv, if set, causes the test binary to act as a transport plugin storing images in the specified directory.
+const fakePluginDirEnv = "CONTAINERS_IMAGE_TEST_FAKE_PLUGIN_DIR"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(fakePluginDirEnv); dir != "" {
+		if err := runFakePlugin(dir, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "fake plugin failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakePlugin implements a transport plugin storing images in subdirectories of dir.
+// References are directory names; "docker/…" references also have a Docker reference.
+func runFakePlugin(dir string, stdin io.Reader, stdout io.Writer) error {
+	in := bufio.NewReader(stdin)
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	if err := enc.Encode(helloMessage{ProtocolVersion: ProtocolVersion}); err != nil {
+		return err
+	}
+	imageDir := ""
+	for {
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		line, err := in.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(line, &req); err != nil {
+			return err
+		}
+		var result any
+		var downloadData []byte
+		var reqErr error
+		switch req.Method {
+		case "ParseReference":
+			var p referenceParams
+			_ = json.Unmarshal(req.Params, &p)
+			if p.Reference == "" || strings.Contains(p.Reference, "..") {
+				reqErr = fmt.Errorf("invalid reference %q", p.Reference)
+				break
+			}
+			res := parseReferenceResult{StringWithinTransport: p.Reference, PolicyConfigurationIdentity: p.Reference}
+			if name, ok := strings.CutPrefix(p.Reference, "docker/"); ok {
+				res.DockerReference = name
+			}
+			result = res
+		case "ValidatePolicyConfigurationScope":
+			var p validateScopeParams
+			_ = json.Unmarshal(req.Params, &p)
+			if strings.Contains(p.Scope, "..") {
+				reqErr = errors.New("invalid scope")
+			}
+		case "OpenSource", "OpenDestination", "DeleteImage":
+			var p referenceParams
+			_ = json.Unmarshal(req.Params, &p)
+			imageDir = filepath.Join(dir, strings.ReplaceAll(p.Reference, "/", "_"))
+			switch req.Method {
+			case "OpenSource":
+				if _, err := os.Stat(imageDir); err != nil {
+					reqErr = err
+				}
+			case "OpenDestination":
+				reqErr = os.MkdirAll(imageDir, 0o755)
+				result = openDestinationResult{
+					SupportedManifestMIMETypes: []string{"application/vnd.oci.image.manifest.v1+json"},
+					DesiredLayerCompression:    "compress",
+					SupportsSignatures:         true,
+				}
+			case "DeleteImage":
+				reqErr = os.RemoveAll(imageDir)
+			}
+		case "GetManifest":
+			data, err := os.ReadFile(filepath.Join(imageDir, "manifest"))
+			reqErr = err
+			result = getManifestResult{Manifest: data}
+		case "GetBlob":
+			var p blobParams
+			_ = json.Unmarshal(req.Params, &p)
+			data, err := os.ReadFile(filepath.Join(imageDir, p.Digest.Encoded()))
+			reqErr = err
+			downloadData = data
+			result = getBlobResult{Size: int64(len(data))}
+		case "GetSignatures":
+			data, err := os.ReadFile(filepath.Join(imageDir, "signatures"))
+			if err != nil && !os.IsNotExist(err) {
+				reqErr = err
+			}
+			res := signaturesResult{Signatures: [][]byte{}}
+			if len(data) != 0 {
+				reqErr = json.Unmarshal(data, &res.Signatures)
+			}
+			result = res
+		case "PutBlob":
+			var p putBlobParams
+			_ = json.Unmarshal(req.Params, &p)
+			data, err := readFakePluginFrames(in)
+			if err != nil {
+				reqErr = err
+				break
+			}
+			d := digest.FromBytes(data)
+			reqErr = os.WriteFile(filepath.Join(imageDir, d.Encoded()), data, 0o644)
+			result = putBlobResult{Digest: d, Size: int64(len(data))}
+		case "TryReusingBlob":
+			var p blobParams
+			_ = json.Unmarshal(req.Params, &p)
+			fi, err := os.Stat(filepath.Join(imageDir, p.Digest.Encoded()))
+			if err == nil {
+				result = tryReusingBlobResult{Reused: true, Size: fi.Size()}
+			} else {
+				result = tryReusingBlobResult{Reused: false}
+			}
+		case "PutManifest":
+			var p putManifestParams
+			_ = json.Unmarshal(req.Params, &p)
+			reqErr = os.WriteFile(filepath.Join(imageDir, "manifest"), p.Manifest, 0o644)
+		case "PutSignatures":
+			var p putSignaturesParams
+			_ = json.Unmarshal(req.Params, &p)
+			data, err := json.Marshal(p.Signatures)
+			if err != nil {
+				return err
+			}
+			reqErr = os.WriteFile(filepath.Join(imageDir, "signatures"), data, 0o644)
+		case "Commit":
+			reqErr = os.WriteFile(filepath.Join(imageDir, "committed"), nil, 0o644)
+		default:
+			reqErr = fmt.Errorf("unknown method %q", req.Method)
+		}
+
+		res := response{}
+		if reqErr != nil {
+			res.Error = reqErr.Error()
+		} else if result != nil {
+			data, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			res.Result = data
+		}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		if reqErr == nil && downloadData != nil {
+			for len(downloadData) > 0 {
+				n := min(len(downloadData), 3) // Use tiny frames to exercise the reader
+				if err := binary.Write(out, binary.BigEndian, uint32(n)); err != nil {
+					return err
+				}
+				if _, err := out.Write(downloadData[:n]); err != nil {
+					return err
+				}
+				downloadData = downloadData[n:]
+			}
+			if err := binary.Write(out, binary.BigEndian, uint32(0)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readFakePluginFrames reads a sequence of frames, as sent by session.sendFrames.
+func readFakePluginFrames(r io.Reader) ([]byte, error) {
+	res := bytes.Buffer{}
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		switch size {
+		case 0:
+			return res.Bytes(), nil
+		case abortFrameMarker:
+			return nil, errors.New("upload aborted")
+		}
+		if _, err := io.CopyN(&res, r, int64(size)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// registerFakePlugin registers a transport named name, implemented by runFakePlugin.
+func registerFakePlugin(t *testing.T, name string) string {
+	dir := t.TempDir()
+	t.Setenv(fakePluginDirEnv, dir)
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	err = Register(name, executable)
+	require.NoError(t, err)
+	t.Cleanup(func() { transports.Delete(name) })
+	return dir
+}
+
+func TestRegister(t *testing.T) {
+	registerFakePlugin(t, "fake-register")
+	// Duplicate registrations are rejected
+	err := Register("fake-register", "/does/not/exist")
+	assert.Error(t, err)
+	// Invalid names are rejected
+	for _, name := range []string{"", "-a", "a-", "a/b", "A", "a:b"} {
+		err := Register(name, "/does/not/exist")
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFind(t *testing.T) {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	pluginDir := t.TempDir()
+	err = os.Symlink(executable, filepath.Join(pluginDir, ExecutablePrefix+"fake-find"))
+	require.NoError(t, err)
+	t.Setenv(PathEnv, "relative/dir:"+pluginDir)
+	t.Cleanup(func() { transports.Delete("fake-find") })
+
+	tr := Find("fake-find")
+	require.NotNil(t, tr)
+	assert.Equal(t, "fake-find", tr.Name())
+	assert.Equal(t, filepath.Join(pluginDir, ExecutablePrefix+"fake-find"), tr.(pluginTransport).path)
+	assert.Equal(t, tr, transports.Get("fake-find"))
+	// Already registered transports are not affected
+	registerFakePlugin(t, "fake-find-registered")
+	assert.Equal(t, transports.Get("fake-find-registered"), Find("fake-find-registered"))
+	// Missing transports
+	assert.Nil(t, Find("fake-missing"))
+	assert.Nil(t, Find("../fake-find"))
+}
+
+func TestRegisterInstalled(t *testing.T) {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	pluginDir := t.TempDir()
+	for _, name := range []string{"fake-installed", "fake-installed-registered", "Invalid"} {
+		err = os.Symlink(executable, filepath.Join(pluginDir, ExecutablePrefix+name))
+		require.NoError(t, err)
+	}
+	err = os.WriteFile(filepath.Join(pluginDir, ExecutablePrefix+"fake-installed-data"), []byte{}, 0o644)
+	require.NoError(t, err)
+	t.Setenv(PathEnv, "relative/dir:"+filepath.Join(pluginDir, "missing")+":"+pluginDir)
+	registerFakePlugin(t, "fake-installed-registered")
+	registered := transports.Get("fake-installed-registered")
+	t.Cleanup(func() { transports.Delete("fake-installed") })
+
+	err = RegisterInstalled()
+	require.NoError(t, err)
+	tr := transports.Get("fake-installed")
+	require.NotNil(t, tr)
+	assert.Equal(t, filepath.Join(pluginDir, ExecutablePrefix+"fake-installed"), tr.(pluginTransport).path)
+	// Already registered transports are not affected
+	assert.Equal(t, registered, transports.Get("fake-installed-registered"))
+	// Invalid names and non-executable files are ignored
+	assert.Nil(t, transports.Get("Invalid"))
+	assert.Nil(t, transports.Get("fake-installed-data"))
+}
+
+func TestParseReference(t *testing.T) {
+	registerFakePlugin(t, "fake-parse")
+	tr := transports.Get("fake-parse")
+	require.NotNil(t, tr)
+
+	ref, err := tr.ParseReference("image")
+	require.NoError(t, err)
+	assert.Equal(t, tr, ref.Transport())
+	assert.Equal(t, "image", ref.StringWithinTransport())
+	assert.Equal(t, "image", ref.PolicyConfigurationIdentity())
+	assert.Equal(t, []string{}, ref.PolicyConfigurationNamespaces())
+	assert.Nil(t, ref.DockerReference())
+
+	ref, err = tr.ParseReference("docker/busybox:latest")
+	require.NoError(t, err)
+	require.NotNil(t, ref.DockerReference())
+	assert.Equal(t, "docker.io/library/busybox:latest", ref.DockerReference().String())
+
+	_, err = tr.ParseReference("docker/busybox") // Name-only Docker references are rejected
+	assert.Error(t, err)
+	_, err = tr.ParseReference("../x")
+	assert.Error(t, err)
+
+	err = tr.ValidatePolicyConfigurationScope("scope")
+	assert.NoError(t, err)
+	err = tr.ValidatePolicyConfigurationScope("../scope")
+	assert.Error(t, err)
+}
+
+func TestImageRoundTrip(t *testing.T) {
+	registerFakePlugin(t, "fake-roundtrip")
+	tr := transports.Get("fake-roundtrip")
+	require.NotNil(t, tr)
+	ref, err := tr.ParseReference("image")
+	require.NoError(t, err)
+	ctx := context.Background()
+	cache := memory.New()
+
+	_, err = ref.NewImageSource(ctx, nil)
+	assert.Error(t, err) // Does not exist yet
+
+	dest, err := ref.NewImageDestination(ctx, nil)
+	require.NoError(t, err)
+	defer dest.Close()
+	assert.Equal(t, []string{"application/vnd.oci.image.manifest.v1+json"}, dest.SupportedManifestMIMETypes())
+	assert.Equal(t, types.Compress, dest.DesiredLayerCompression())
+	assert.False(t, dest.HasThreadSafePutBlob())
+	assert.NoError(t, dest.SupportsSignatures(ctx))
+
+	blob := []byte("blob contents")
+	info, err := dest.PutBlob(ctx, bytes.NewReader(blob), types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, cache, false)
+	require.NoError(t, err)
+	assert.Equal(t, types.BlobInfo{Digest: digest.FromBytes(blob), Size: int64(len(blob))}, info)
+
+	// A failing upload does not break the session
+	_, err = dest.PutBlob(ctx, io.MultiReader(bytes.NewReader(blob), iotestErrReader{}), types.BlobInfo{Size: -1}, cache, false)
+	assert.ErrorIs(t, err, errTestRead)
+
+	reused, reusedInfo, err := dest.TryReusingBlob(ctx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, cache, false)
+	require.NoError(t, err)
+	assert.True(t, reused)
+	assert.Equal(t, int64(len(blob)), reusedInfo.Size)
+	reused, _, err = dest.TryReusingBlob(ctx, types.BlobInfo{Digest: digest.FromBytes([]byte("missing")), Size: -1}, cache, false)
+	require.NoError(t, err)
+	assert.False(t, reused)
+
+	man := []byte(`{"schemaVersion":2}`)
+	err = dest.PutManifest(ctx, man, nil)
+	require.NoError(t, err)
+	sig := signature.SimpleSigningFromBlob([]byte("\xa3signature")) // 0xA3 is recognized by signature.FromBlob
+	err = dest.(private.ImageDestination).PutSignaturesWithFormat(ctx, []signature.Signature{sig}, nil)
+	require.NoError(t, err)
+	err = dest.Commit(ctx, nil) // nil unparsedToplevel is invalid, we don’t currently use the value
+	require.NoError(t, err)
+
+	src, err := ref.NewImageSource(ctx, nil)
+	require.NoError(t, err)
+	defer src.Close()
+	m, _, err := src.GetManifest(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, man, m)
+
+	_, _, err = src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromBytes([]byte("missing")), Size: -1}, cache)
+	assert.Error(t, err)
+	for i := 0; i < 3; i++ { // Repeat to verify the source is usable after a download
+		rc, size, err := src.GetBlob(ctx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, cache)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(blob)), size)
+		if i != 1 {
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, blob, data)
+		}
+		// For i == 1, close the stream without reading it; that terminates the session, and a new one is started.
+		err = rc.Close()
+		require.NoError(t, err)
+	}
+	// Reads from a download honor ctx
+	cancelCtx, cancel := context.WithCancel(ctx)
+	rc, _, err := src.GetBlob(cancelCtx, types.BlobInfo{Digest: digest.FromBytes(blob), Size: -1}, cache)
+	require.NoError(t, err)
+	cancel()
+	_, err = io.ReadAll(rc)
+	assert.ErrorIs(t, err, context.Canceled)
+	err = rc.Close()
+	require.NoError(t, err)
+
+	sigs, err := src.(private.ImageSource).GetSignaturesWithFormat(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []signature.Signature{sig}, sigs)
+
+	err = ref.DeleteImage(ctx, nil)
+	require.NoError(t, err)
+}
+
+var errTestRead = errors.New("test read error")
+
+// iotestErrReader is an io.Reader which always fails with errTestRead.
+type iotestErrReader struct{}
+
+func (iotestErrReader) Read(p []byte) (int, error) {
+	return 0, errTestRead
+}
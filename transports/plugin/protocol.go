@@ -0,0 +1,378 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/containers/image/v5/internal/iolimits"
+	"github.com/containers/image/v5/internal/log"
+)
+
+// ProtocolVersion is the version of the plugin protocol implemented by this package.
+// See docs/containers-transport-plugins.md for a description of the protocol.
+const ProtocolVersion = 1
+
+// ProtocolVersionEnv is the environment variable used to tell plugins the protocol version the library expects.
+const ProtocolVersionEnv = "CONTAINERS_IMAGE_PLUGIN_PROTOCOL"
+
+const (
+	// maxFrameSize is the largest data frame we send, and accept.
+	maxFrameSize = 1024 * 1024
+	// abortFrameMarker is a frame length value which indicates that the sender has failed to produce the data.
+	abortFrameMarker = 0xFFFFFFFF
+	// maxMessageSize is the largest JSON message we accept from plugins.
+	maxMessageSize = iolimits.MaxManifestBodySize * 2 // Messages can contain a manifest, with some overhead.
+)
+
+// helloMessage is the first message sent by a plugin after startup.
+type helloMessage struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// request is a request sent to a plugin.
+type request struct {
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+// response is a response to a request.
+type response struct {
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// session is a running plugin process, processing requests one at a time.
+type session struct {
+	path   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+
+	// mutex is held while a request, including any associated data stream, is in progress.
+	mutex sync.Mutex
+	// broken is set if the session can’t be used any more, e.g. because a data stream was not fully transferred.
+	broken error
+}
+
+// startSession starts the plugin at path, and verifies its protocol version.
+func startSession(path string) (*session, error) {
+	cmd := exec.Command(path, "serve")
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", ProtocolVersionEnv, ProtocolVersion))
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Starting transport plugin %s", path)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting transport plugin %s: %w", path, err)
+	}
+	s := &session{
+		path:   path,
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+
+	var hello helloMessage
+	if err := s.readMessage(&hello); err != nil {
+		s.kill()
+		return nil, fmt.Errorf("reading transport plugin %s handshake: %w", path, err)
+	}
+	if hello.ProtocolVersion != ProtocolVersion {
+		s.kill()
+		return nil, fmt.Errorf("transport plugin %s uses protocol version %d, only version %d is supported", path, hello.ProtocolVersion, ProtocolVersion)
+	}
+	return s, nil
+}
+
+// close asks the plugin to exit, and waits for it to do so.
+func (s *session) close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_ = s.stdin.Close()
+	if err := s.cmd.Wait(); err != nil && s.broken == nil {
+		return fmt.Errorf("transport plugin %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// usable returns true if s can still be used for requests.
+// It blocks while another request is in progress.
+func (s *session) usable() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.broken == nil
+}
+
+// kill terminates the plugin without waiting for it to clean up.
+func (s *session) kill() {
+	_ = s.cmd.Process.Kill()
+	_ = s.cmd.Wait()
+}
+
+// readMessage reads a single JSON message from the plugin into dest.
+func (s *session) readMessage(dest any) error {
+	var line []byte
+	for {
+		chunk, err := s.stdout.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxMessageSize {
+			return fmt.Errorf("plugin sent a message larger than %d bytes", maxMessageSize)
+		}
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			if err == io.EOF {
+				return errors.New("plugin exited unexpectedly")
+			}
+			return err
+		}
+	}
+	return json.Unmarshal(line, dest)
+}
+
+// writeMessage sends a single JSON message to the plugin.
+func (s *session) writeMessage(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = s.stdin.Write(data)
+	return err
+}
+
+// lock acquires s.mutex for a request, and fails if the session is no longer usable.
+// On failure, the mutex is not held.
+func (s *session) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	if s.broken != nil {
+		s.mutex.Unlock()
+		return fmt.Errorf("transport plugin %s is unusable: %w", s.path, s.broken)
+	}
+	return nil
+}
+
+// markBroken records err as a reason why s is no longer usable, and terminates the plugin.
+// The caller must hold s.mutex.
+func (s *session) markBroken(err error) {
+	if s.broken == nil {
+		s.broken = err
+		_ = s.cmd.Process.Kill()
+	}
+}
+
+// roundTrip sends a request, calls sendData (if not nil) to send associated data, and reads a response into result (if not nil).
+// The caller must hold s.mutex.
+func (s *session) roundTrip(ctx context.Context, method string, params any, sendData func() error, result any) error {
+	// There is no way to interrupt a request in the protocol, so if ctx is canceled, the plugin is terminated.
+	stop := context.AfterFunc(ctx, func() {
+		_ = s.cmd.Process.Kill()
+	})
+	defer stop()
+
+	if err := s.writeMessage(request{Method: method, Params: params}); err != nil {
+		s.markBroken(err)
+		return fmt.Errorf("sending %s to transport plugin %s: %w", method, s.path, err)
+	}
+	if sendData != nil {
+		if err := sendData(); err != nil {
+			return err
+		}
+	}
+	var res response
+	if err := s.readMessage(&res); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		s.markBroken(err)
+		return fmt.Errorf("reading %s response from transport plugin %s: %w", method, s.path, err)
+	}
+	if res.Error != "" {
+		return fmt.Errorf("transport plugin %s: %s", s.path, res.Error)
+	}
+	if result != nil {
+		if err := json.Unmarshal(res.Result, result); err != nil {
+			s.markBroken(err)
+			return fmt.Errorf("parsing %s response from transport plugin %s: %w", method, s.path, err)
+		}
+	}
+	return nil
+}
+
+// call sends a request to the plugin, and reads a response into result (if not nil).
+func (s *session) call(ctx context.Context, method string, params any, result any) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mutex.Unlock()
+	return s.roundTrip(ctx, method, params, nil, result)
+}
+
+// callWithUpload sends a request to the plugin followed by the contents of stream, and reads a response into result (if not nil).
+func (s *session) callWithUpload(ctx context.Context, method string, params any, stream io.Reader, result any) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mutex.Unlock()
+	return s.roundTrip(ctx, method, params, func() error {
+		return s.sendFrames(stream)
+	}, result)
+}
+
+// sendFrames sends the contents of stream to the plugin as a sequence of frames.
+// If reading stream fails, the plugin is told to abort the operation, and the read error is returned.
+// The caller must hold s.mutex.
+func (s *session) sendFrames(stream io.Reader) error {
+	buf := make([]byte, 4+maxFrameSize)
+	for {
+		n, readErr := stream.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := s.stdin.Write(buf[:4+n]); err != nil {
+				s.markBroken(err)
+				return fmt.Errorf("sending data to transport plugin %s: %w", s.path, err)
+			}
+		}
+		if readErr != nil {
+			marker := uint32(0)
+			if readErr != io.EOF {
+				marker = abortFrameMarker
+			}
+			binary.BigEndian.PutUint32(buf[:4], marker)
+			if _, err := s.stdin.Write(buf[:4]); err != nil {
+				s.markBroken(err)
+				return fmt.Errorf("sending data to transport plugin %s: %w", s.path, err)
+			}
+			if readErr != io.EOF {
+				// Read, and ignore, the plugin’s response to the aborted request, so that the session remains usable.
+				var res response
+				if err := s.readMessage(&res); err != nil {
+					s.markBroken(err)
+				}
+				return readErr
+			}
+			return nil
+		}
+	}
+}
+
+// callWithDownload sends a request to the plugin, reads a response into result, and returns a stream of data sent by the plugin after the response.
+// The session is locked until the returned stream is closed; reads from the stream fail, and the plugin is terminated, if ctx is canceled.
+func (s *session) callWithDownload(ctx context.Context, method string, params any, result any) (io.ReadCloser, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.roundTrip(ctx, method, params, nil, result); err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	return &frameReader{
+		ctx: ctx,
+		s:   s,
+		stop: context.AfterFunc(ctx, func() {
+			_ = s.cmd.Process.Kill()
+		}),
+	}, nil
+}
+
+// frameReader reads a sequence of frames from a plugin.
+type frameReader struct {
+	ctx       context.Context
+	s         *session
+	stop      func() bool // Stops terminating the plugin when ctx is canceled
+	remaining uint32      // Remaining bytes in the current frame
+	done      bool        // The terminating frame has been read
+	err       error       // A terminal error
+	closed    bool
+}
+
+// fail records err, wrapped, as a terminal error of r, and marks the session broken.
+func (r *frameReader) fail(err error) error {
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	r.err = fmt.Errorf("reading data from transport plugin %s: %w", r.s.path, err)
+	r.s.markBroken(r.err)
+	return r.err
+}
+
+func (r *frameReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.closed {
+		return 0, errors.New("read from a closed transport plugin data stream")
+	}
+	if err := r.ctx.Err(); err != nil {
+		return 0, r.fail(err)
+	}
+	for r.remaining == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		var header [4]byte
+		if _, err := io.ReadFull(r.s.stdout, header[:]); err != nil {
+			return 0, r.fail(err)
+		}
+		switch size := binary.BigEndian.Uint32(header[:]); {
+		case size == 0:
+			r.done = true
+		case size == abortFrameMarker:
+			r.done = true
+			r.err = fmt.Errorf("transport plugin %s failed to provide data", r.s.path)
+			return 0, r.err
+		case size > maxFrameSize:
+			r.err = fmt.Errorf("transport plugin %s sent an invalid frame of size %d", r.s.path, size)
+			r.s.markBroken(r.err)
+			return 0, r.err
+		default:
+			r.remaining = size
+		}
+	}
+	if uint32(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.s.stdout.Read(p)
+	r.remaining -= uint32(n)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, r.fail(err)
+	}
+	return n, nil
+}
+
+// Close releases the session for other requests.
+// If the data was not read to the end, the plugin is terminated instead of reading the rest of the data,
+// and the session becomes unusable.
+func (r *frameReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.stop()
+	if r.err == nil && (!r.done || r.remaining != 0) {
+		r.s.markBroken(errors.New("a data stream was closed before reaching its end"))
+	}
+	r.s.mutex.Unlock()
+	return nil
+}
@@ -2,11 +2,16 @@ package types
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/containers/image/v5/docker/reference"
 	compression "github.com/containers/image/v5/pkg/compression/types"
+	"github.com/containers/image/v5/pkg/faultinject"
+	"github.com/containers/image/v5/pkg/metrics"
+	"github.com/containers/image/v5/pkg/registryfeatures"
 	digest "github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
@@ -352,6 +357,56 @@ type ImageDestination interface {
 	Commit(ctx context.Context, unparsedToplevel UnparsedImage) error
 }
 
+// ImageSourceChunk is a portion of a blob.
+type ImageSourceChunk struct {
+	// Offset specifies the starting position of the chunk within the source blob.
+	Offset uint64
+
+	// Length specifies the size of the chunk.  If it is set to math.MaxUint64,
+	// then it refers to all the data from Offset to the end of the blob.
+	Length uint64
+}
+
+// BlobChunkAccessor allows fetching discontiguous chunks of a blob.
+// It is provided by the caller of PartialBlobDestination.PutBlobPartial; transports are not expected to implement it.
+type BlobChunkAccessor interface {
+	// GetBlobAt returns a sequential channel of readers that contain data for the requested
+	// blob chunks, and a channel that might get a single error value.
+	// The specified chunks must be not overlapping and sorted by their offset.
+	// The readers must be fully consumed, in the order they are returned, before blocking
+	// to read the next chunk.
+	// If the Length for the last chunk is set to math.MaxUint64, then it
+	// fully fetches the remaining data from the offset to the end of the blob.
+	GetBlobAt(ctx context.Context, info BlobInfo, chunks []ImageSourceChunk) (chan io.ReadCloser, chan error, error)
+}
+
+// PutBlobPartialOptions are used in PartialBlobDestination.PutBlobPartial.
+// New fields may be added in the future; implementations must work correctly if they ignore fields they don’t know about.
+type PutBlobPartialOptions struct {
+	Cache      BlobInfoCache // Cache to use and/or update.
+	LayerIndex int           // A zero-based index of the layer within the image (PutBlobPartial is only called with layer-like blobs, not configs)
+}
+
+// PartialBlobDestination is an optional interface an ImageDestination can implement to create layers
+// using only the parts of the blob that are not already available locally (e.g. for zstd:chunked or eStargz layers),
+// instead of receiving the full blob via PutBlob.
+//
+// Unlike ImageDestination, this interface is covered by the usual compatibility guarantees:
+// its methods will not be changed or removed within this major version; any new inputs will be
+// added as fields of PutBlobPartialOptions, and any new functionality as separate optional interfaces.
+type PartialBlobDestination interface {
+	// SupportsPutBlobPartial returns true if PutBlobPartial is supported.
+	SupportsPutBlobPartial() bool
+	// PutBlobPartial attempts to create a blob using the data that is already present
+	// at the destination. chunkAccessor is accessed in a non-sequential way to retrieve the missing chunks.
+	// It is called only if SupportsPutBlobPartial() returns true, and only for layer blobs with a known digest.
+	// Even then, the call can fail; the caller then falls back to PutBlob, so the implementation must not
+	// leave a partially-created blob behind.
+	// On success, the returned BlobInfo must contain at least the digest and size of the blob.
+	// May update options.Cache.
+	PutBlobPartial(ctx context.Context, chunkAccessor BlobChunkAccessor, srcInfo BlobInfo, options PutBlobPartialOptions) (BlobInfo, error)
+}
+
 // ManifestTypeRejectedError is returned by ImageDestination.PutManifest if the destination is in principle available,
 // refuses specifically this manifest type, but may accept a different manifest type.
 type ManifestTypeRejectedError struct { // We only use a struct to allow a type assertion, without limiting the contents of the error otherwise.
@@ -362,6 +417,18 @@ func (e ManifestTypeRejectedError) Error() string {
 	return e.Err.Error()
 }
 
+// InsufficientSpaceError is returned if a check requested by SystemContext.BigFilesTemporaryDirCheckFreeSpace
+// determines that a directory for big temporary files does not have enough free space.
+type InsufficientSpaceError struct {
+	Path      string // The directory
+	Required  int64  // The estimated number of bytes required
+	Available int64  // The number of bytes available
+}
+
+func (e InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient space in %s: %d bytes required, only %d bytes available", e.Path, e.Required, e.Available)
+}
+
 // UnparsedImage is an Image-to-be; until it is verified and accepted, it only caries its identity and caches manifest and signature blobs.
 // Thus, an UnparsedImage can be created from an ImageSource simply by fetching blobs without interpreting them,
 // allowing cryptographic signature verification to happen first, before even fetching the manifest, or parsing anything else.
@@ -396,6 +463,8 @@ type Image interface {
 	// OCIConfig returns the image configuration as per OCI v1 image-spec. Information about
 	// layers in the resulting configuration isn't guaranteed to be returned to due how
 	// old image manifests work (docker v2s1 especially).
+	// The parsed configuration is cached, so it is OK to call this however often you need;
+	// each call returns a separate copy, which the caller may modify.
 	OCIConfig(context.Context) (*v1.Image, error)
 	// LayerInfos returns a list of BlobInfos of layers referenced by this image, in order (the root layer first, and then successive layered layers).
 	// The Digest field is guaranteed to be provided, Size may be -1 and MediaType may be optionally provided.
@@ -477,14 +546,29 @@ type ImageInspectInfo struct {
 	LayersData    []ImageInspectLayer
 	Env           []string
 	Author        string
+	History       []ImageInspectHistory // Build history, oldest first; nil if unknown.
 }
 
 // ImageInspectLayer is a set of metadata describing an image layers' detail
 type ImageInspectLayer struct {
-	MIMEType    string // "" if unknown.
-	Digest      digest.Digest
-	Size        int64 // -1 if unknown.
-	Annotations map[string]string
+	MIMEType           string // "" if unknown.
+	Digest             digest.Digest
+	Size               int64 // -1 if unknown.
+	Annotations        map[string]string
+	UncompressedDigest digest.Digest // The DiffID of the layer; "" if unknown.
+	EmptyLayer         bool          // The layer is known to not change the filesystem (schema1 “throwaway” layers).
+}
+
+// ImageInspectHistory describes a single step of building an image.
+type ImageInspectHistory struct {
+	Created    *time.Time // nil if unknown.
+	CreatedBy  string
+	Author     string
+	Comment    string
+	EmptyLayer bool // The step did not create a filesystem layer.
+	// LayerIndex is the index into ImageInspectInfo.LayersData of the layer created by this step, or -1 if there is no such
+	// entry (usually because the step did not create a layer), or if the correspondence between history and layers can not be determined.
+	LayerIndex int
 }
 
 // DockerAuthConfig contains authorization information for connecting to a registry.
@@ -499,6 +583,38 @@ type DockerAuthConfig struct {
 	IdentityToken string
 }
 
+// DockerTokenRequest describes a bearer token needed by the docker transport, see SystemContext.DockerBearerTokenProvider.
+type DockerTokenRequest struct {
+	Registry string // The registry host (and port) the token is used for, e.g. "quay.io"
+	// Realm and Service are the parameters of the registry’s authentication challenge, if any
+	// (usually the token service URL, and the name of the registry at that service).
+	// Service is SystemContext.DockerTokenService instead, if that is set.
+	Realm   string
+	Service string
+	Scopes  []DockerTokenScope // The scopes the token must be valid for
+}
+
+// DockerTokenScope is a resource, and actions on it, a bearer token must allow.
+type DockerTokenScope struct {
+	ResourceType string // Usually "repository"
+	Name         string // The resource name, e.g. the repository path "library/busybox"
+	Actions      string // A comma-separated list of actions, e.g. "pull" or "pull,push"
+}
+
+// DockerToken is a bearer token returned by SystemContext.DockerBearerTokenProvider.
+type DockerToken struct {
+	Token string
+	// ExpiresAt is the time the token expires; if zero, or if it is too soon, the token is used for a minimal default lifetime.
+	ExpiresAt time.Time
+}
+
+// ObjectStorageCredentials contains access keys for an S3-compatible object storage service.
+type ObjectStorageCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Optional, used with temporary credentials.
+}
+
 // OptionalBool is a boolean with an additional undefined value, which is meant
 // to be used in the context of user input to distinguish between a
 // user-specified value and a default value.
@@ -553,6 +669,24 @@ const (
 	ShortNameModeEnforcing
 )
 
+// OSVersionMatchPolicy defines how the os.version values of instances of multi-platform images are matched
+// against SystemContext.OSVersionChoice.
+//
+// The values are primarily meaningful for Windows, which uses os.version values like "10.0.20348.2113"
+// (major.minor.build.revision).
+type OSVersionMatchPolicy int
+
+const (
+	// Only choose instances with the same major.minor.build as OSVersionChoice; the revision may differ.
+	// This is what Windows process isolation requires.
+	OSVersionMatchBuild OSVersionMatchPolicy = iota
+	// Only choose instances with exactly the same os.version as OSVersionChoice.
+	OSVersionMatchExact
+	// Choose instances with the same major.minor as OSVersionChoice, and a build not newer than OSVersionChoice,
+	// preferring the newest such build. This is what Windows Hyper-V isolation allows.
+	OSVersionMatchHyperV
+)
+
 // SystemContext allows parameterizing access to implicitly-accessed resources,
 // like configuration files in /etc and users' login state in their home directory.
 // Various components can share the same field only if their semantics is exactly
@@ -604,12 +738,40 @@ type SystemContext struct {
 	OSChoice string
 	// If not "", overrides the use of detected ARM platform variant when choosing an image or verifying variant match.
 	VariantChoice string
+	// If not "", the os.version (e.g. a Windows build) to use when choosing an image from a multi-platform image,
+	// matched according to OSVersionMatchPolicy. Instances without an os.version value are still accepted, but only if no
+	// instance with a matching os.version exists.
+	OSVersionChoice string
+	// How OSVersionChoice is matched against the os.version values of instances; ignored if OSVersionMatcher is set.
+	OSVersionMatchPolicy OSVersionMatchPolicy
+	// If not nil, returns true if an instance with instanceVersion can be used on a host with OSVersionChoice;
+	// instances with exactly the same os.version are preferred.
+	OSVersionMatcher func(instanceVersion, wantedVersion string) bool
 	// If not "", overrides the system's default directory containing a blob info cache.
 	BlobInfoCacheDir string
 	// Additional tags when creating or copying a docker-archive.
 	DockerArchiveAdditionalTags []reference.NamedTagged
 	// If not "", overrides the temporary directory to use for storing big files
 	BigFilesTemporaryDir string
+	// If true, operations which store big files in the temporary directory (see BigFilesTemporaryDir) for an image,
+	// e.g. writing to containers-storage: or reading oci-archive:, check before starting whether the directory has enough
+	// free space for the data, as estimated from sizes in the manifest or of the archive, and fail early with an InsufficientSpaceError
+	// if it does not.
+	// The estimate does not account for data which would not be stored after all (e.g. layers which are already present),
+	// so this can fail operations which would have succeeded.
+	BigFilesTemporaryDirCheckFreeSpace bool
+	// If not nil, receives metrics about operations using this SystemContext, e.g. registry requests made by the docker: transport.
+	MetricsRecorder metrics.Recorder
+	// If not nil, injects faults into operations using this SystemContext, e.g. registry requests, or blobs read by copy.Image
+	// from a source. This is only intended for testing retry and error handling.
+	FaultInjector faultinject.Injector
+	// If true, the dir: and oci: transports record in extended attributes of blob files that the blobs were verified
+	// to match their digests, and later copies from the same files skip verifying unchanged blobs again.
+	// This only has an effect on platforms and file systems which support extended attributes.
+	// WARNING: This trusts anyone who can modify the blob files not to record incorrect verification results.
+	LocalBlobDigestCache bool
+	// If > 0, verification results recorded because of LocalBlobDigestCache which are older than this are ignored.
+	LocalBlobDigestCacheMaxAge time.Duration
 
 	// === OCI.Transport overrides ===
 	// If not "", a directory containing a CA certificate (ending with ".crt"),
@@ -623,6 +785,22 @@ type SystemContext struct {
 	// Allow UnCompress image layer for OCI image layer
 	OCIAcceptUncompressedLayers bool
 
+	// === oci/s3.Transport overrides ===
+	// If not "", the base URL (e.g. "https://storage.googleapis.com" or "http://localhost:9000") of an
+	// S3-compatible object storage service.  If "", the AWS S3 endpoint for ObjectStorageRegion is used.
+	ObjectStorageEndpoint string
+	// If not "", the region used to sign object storage requests; defaults to $AWS_REGION, or "us-east-1" if that is unset.
+	ObjectStorageRegion string
+	// If not nil, credentials used to sign object storage requests.
+	// If nil, $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN are used; if those are unset, requests are anonymous.
+	ObjectStorageCredentials *ObjectStorageCredentials
+	// If not "", a directory containing a CA certificate (ending with ".crt"),
+	// a client certificate (ending with ".cert") and a client certificate key
+	// (ending with ".key") used when talking to the object storage service.
+	ObjectStorageCertPath string
+	// Allow contacting the object storage service over HTTPS with failed TLS verification.
+	ObjectStorageInsecureSkipTLSVerify bool
+
 	// === docker.Transport overrides ===
 	// If not "", a directory containing a CA certificate (ending with ".crt"),
 	// a client certificate (ending with ".cert") and a client certificate key
@@ -638,6 +816,26 @@ type SystemContext struct {
 	DockerAuthConfig *DockerAuthConfig
 	// if not "", the library uses this registry token to authenticate to the registry
 	DockerBearerRegistryToken string
+	// If not nil, called when a registry requires a bearer token, instead of obtaining the token from the registry’s
+	// token service using DockerAuthConfig or stored credentials. Tokens are cached until they expire.
+	// Ignored if DockerBearerRegistryToken is non-empty.
+	DockerBearerTokenProvider func(ctx context.Context, request DockerTokenRequest) (DockerToken, error)
+	// if not "", used as the service (token audience) parameter of bearer token requests, instead of the value
+	// in the registry’s authentication challenge; useful for token services which expect a nonstandard value.
+	DockerTokenService string
+	// Scopes requested in every bearer token, in addition to the scope needed for the accessed repository,
+	// e.g. pull access to other repositories used as sources of cross-repository blob mounts.
+	// Requesting them up front avoids obtaining further tokens when the scopes are needed.
+	DockerTokenAdditionalScopes []DockerTokenScope
+	// If not nil, called for every HTTP request the docker transport sends to a registry (including every retry and
+	// reconnection attempt), after authentication headers have been added; it can add headers, e.g. to sign the request.
+	// The mutator must not consume req.Body; use req.GetBody if the contents are needed. Authentication token requests
+	// are not passed to the mutator.
+	DockerRequestMutator func(req *http.Request) error
+	// If not nil, records which optional features (e.g. the referrers API, or manifest MIME types) registries support,
+	// so that later operations using the same cache, like copy.Image, choose a working strategy without failed attempts.
+	// If nil, what is learned is only used within a single image source or destination.
+	DockerRegistryFeatureCache *registryfeatures.Cache
 	// if not "", an User-Agent header is added to each request when contacting a registry.
 	DockerRegistryUserAgent string
 	// if true, a V1 ping attempt isn't done to give users a better error. Default is false.
@@ -650,10 +848,45 @@ type SystemContext struct {
 	DockerLogMirrorChoice bool
 	// Directory to use for OSTree temporary files
 	OSTreeTmpDirPath string
+	// If not "", the base URL (e.g. "http://localhost:30020") of a peer-to-peer distribution agent, such as Spegel or a
+	// Dragonfly dfdaemon running as a registry mirror, which is asked for blobs before the registry.
+	// Blobs fetched from the agent are verified against their digest; if the agent fails to provide a blob, the registry is used.
+	DockerPeerBlobAgentURL string
 	// If true, all blobs will have precomputed digests to ensure layers are not uploaded that already exist on the registry.
 	// Note that this requires writing blobs to temporary files, and takes more time than the default behavior,
 	// when the digest for a blob is unknown.
 	DockerRegistryPushPrecomputeDigests bool
+	// If not "", a manifest is only written to a tag if the tag currently refers to a manifest with this digest,
+	// so that concurrent updates of the tag (e.g. of a multi-platform index by two pushers) are not silently overwritten;
+	// otherwise the write fails with a docker.ManifestConflictError.
+	// The precondition is checked before the write, and also sent to the registry as an If-Match header, so that registries
+	// which support it can enforce it atomically. It does not apply to manifests written by digest.
+	DockerManifestIfMatch digest.Digest
+	// If true, a manifest is only written to a tag if the tag does not exist yet, similarly to DockerManifestIfMatch
+	// (using an If-None-Match header). Must not be set together with DockerManifestIfMatch.
+	DockerManifestIfNotExists bool
+	// Additional manifest MIME types (e.g. of proprietary artifacts) to accept when reading manifests from a registry,
+	// in addition to the types this library understands (manifest.DefaultRequestedManifestMIMETypes).
+	// Without this, registries doing content negotiation may convert such manifests, or refuse to return them.
+	DockerAdditionalManifestMIMETypes []string
+	// If not empty, only registries matching one of these patterns may be contacted by the docker transport,
+	// independently of signature policy; this applies to all registry accesses, including mirrors, CheckAuth and SearchRegistry.
+	// A pattern is a host[:port] value; a pattern without a port matches all ports, and a "*.example.com" pattern
+	// matches all subdomains of example.com. Docker Hub is matched as "docker.io".
+	// Every other host the docker transport contacts must match as well, including hosts the registry redirects to
+	// (e.g. blob storage), authentication servers, DockerPeerBlobAgentURL, foreign layer URLs, and lookaside signature storage.
+	DockerRegistryAllowList []string
+	// Registries matching one of these patterns (with the same syntax as DockerRegistryAllowList) are never contacted
+	// by the docker transport, even if they match DockerRegistryAllowList.
+	DockerRegistryDenyList []string
+	// If not empty, the external URLs of foreign (non-distributable) layers are only used if their host matches one of these
+	// patterns (with the same syntax as DockerRegistryAllowList); other URLs are skipped.
+	// If no URL of a layer is usable, the layer is pulled from the registry instead.
+	DockerForeignLayerURLAllowList []string
+	// If true, credentials for registries of cloud providers (Amazon ECR, Google Artifact Registry and Container Registry,
+	// Azure Container Registry) are obtained from the ambient credentials of the environment (e.g. $AWS_ACCESS_KEY_ID,
+	// Google application default credentials, or the cloud instance metadata services) when no other credentials are configured.
+	DockerEnableCloudCredentials bool
 
 	// === docker/daemon.Transport overrides ===
 	// A directory containing a CA certificate (ending with ".crt"),
@@ -661,7 +894,11 @@ type SystemContext struct {
 	// (ending with ".key") used when talking to a Docker daemon.
 	DockerDaemonCertPath string
 	// The hostname or IP to the Docker daemon. If not set (aka ""), client.DefaultDockerHost is assumed.
+	// An ssh://[user@]host[:port][/socket-path] value connects to the Docker daemon on a remote host,
+	// by running "docker system dial-stdio" there using ssh(1).
 	DockerDaemonHost string
+	// Additional options for ssh(1) (e.g. []string{"-i", "/path/to/key"}), used if DockerDaemonHost is an ssh:// URL.
+	DockerDaemonSSHFlags []string
 	// Used to skip TLS verification, off by default. To take effect DockerDaemonCertPath needs to be specified as well.
 	DockerDaemonInsecureSkipTLSVerify bool
 
@@ -696,6 +933,18 @@ const (
 	// ProgressEventSkipped is fired when the artifact has been skipped because
 	// its already available at the destination
 	ProgressEventSkipped
+
+	// ProgressEventApplyStarted is fired when the destination starts applying (e.g. extracting)
+	// a layer whose data has already been transferred; not all destinations report this.
+	ProgressEventApplyStarted
+
+	// ProgressEventApplyRead indicates that applying the layer is currently in
+	// progress; Offset is the amount of layer blob data consumed so far
+	ProgressEventApplyRead
+
+	// ProgressEventApplyDone is fired when the destination has finished applying
+	// the layer
+	ProgressEventApplyDone
 )
 
 // ProgressProperties is used to pass information from the copy code to a monitor which
@@ -714,4 +963,8 @@ type ProgressProperties struct {
 	// The additional offset which has been downloaded inside the last update
 	// interval. Will be reset after each ProgressEventRead event.
 	OffsetUpdate uint64
+
+	// The ID of the copy operation which reported this event, as set in copy.Options.OperationID
+	// (or generated by copy.Image); it allows attributing events if one channel is shared by concurrent copies.
+	OperationID string
 }